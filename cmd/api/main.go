@@ -1,24 +1,30 @@
 package main
 
 import (
-	"log"
+	"log/slog"
+	"os"
+	"os/signal"
 	"runtime"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/helmet"
 	"github.com/gofiber/fiber/v2/middleware/limiter"
-	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/joho/godotenv"
 	"github.com/shigake/tech-iq-back/internal/cache"
 	"github.com/shigake/tech-iq-back/internal/config"
 	"github.com/shigake/tech-iq-back/internal/database"
+	"github.com/shigake/tech-iq-back/internal/grpcapi"
 	"github.com/shigake/tech-iq-back/internal/handlers"
+	"github.com/shigake/tech-iq-back/internal/logger"
 	"github.com/shigake/tech-iq-back/internal/middleware"
 	"github.com/shigake/tech-iq-back/internal/repositories"
 	"github.com/shigake/tech-iq-back/internal/services"
+	"github.com/shigake/tech-iq-back/internal/storage"
 )
 
 // Build info - injected at compile time via ldflags
@@ -28,24 +34,61 @@ var (
 	CommitSHA = "unknown"
 )
 
+// @title TechERP API
+// @version 1.0
+// @description Field service management API (tickets, technicians, stock, financial)
+// @BasePath /api/v1
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
 func main() {
 	// Load .env file
 	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using environment variables")
+		slog.Info("No .env file found, using environment variables")
 	}
 
 	// Load configuration
 	cfg := config.Load()
+	logger.Setup(cfg.LogFormat, cfg.LogLevel)
+
+	// Check the settings of the enabled features; production refuses to start with errors
+	report := cfg.Validate()
+	report.Log()
+	if report.HasErrors() && cfg.IsProduction() {
+		logger.Fatal("Invalid configuration, refusing to start", "env", cfg.AppEnv)
+	}
 
 	// Connect to database
 	db, err := database.Connect(cfg)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		logger.Fatal("Failed to connect to database", "error", err)
 	}
 
 	// Run migrations
 	if err := database.Migrate(db); err != nil {
-		log.Fatalf("Failed to run migrations: %v", err)
+		logger.Fatal("Failed to run migrations", "error", err)
+	}
+
+	// Read replica for the report, dashboard and export queries
+	var replica *database.ReplicaResolver
+	if cfg.DBReplicaDSN != "" {
+		replica, err = database.RegisterReplica(db, cfg.DBReplicaDSN)
+		if err != nil {
+			logger.Fatal("Failed to configure the read replica", "error", err)
+		}
+		replica.Start(cfg.DBReplicaCheckInterval)
+		slog.Info("Read replica configured", "check_interval", cfg.DBReplicaCheckInterval)
+	}
+
+	// PostGIS column and indexes for the geo radius and map queries
+	postGIS := false
+	if cfg.GeoPostGISEnabled {
+		if err := database.EnablePostGIS(db); err != nil {
+			slog.Warn("PostGIS unavailable, geo spatial queries use plain SQL", "error", err)
+		} else {
+			postGIS = true
+			slog.Info("PostGIS enabled for the geo spatial queries")
+		}
 	}
 
 	// Initialize Redis cache
@@ -59,87 +102,487 @@ func main() {
 		})
 		
 		if err := redisClient.Ping(); err != nil {
-			log.Printf("⚠️  Failed to connect to Redis, running without cache: %v", err)
+			slog.Warn("Failed to connect to Redis, running without cache", "error", err)
 			redisClient = nil
 		} else {
-			log.Println("✅ Redis cache connected successfully")
-			defer redisClient.Close()
+			slog.Info("Redis cache connected successfully")
+			// Drop the cached dashboards when the tickets or entries behind them change
+			if err := database.RegisterChangeHook(db, "dashboard_cache", services.DashboardCacheInvalidator(redisClient)); err != nil {
+				logger.Fatal("Failed to register dashboard cache invalidation", "error", err)
+			}
 		}
 	} else {
-		log.Println("ℹ️  Cache disabled by configuration")
+		slog.Info("Cache disabled by configuration")
 	}
 
 	// Initialize Fiber app
 	app := fiber.New(fiber.Config{
 		AppName:      cfg.AppName,
 		ErrorHandler: handlers.ErrorHandler,
+		BodyLimit:    cfg.BodyLimitUpload, // hard cap; ticket attachments up to 20MB
 	})
 
 	// Middleware
 	app.Use(recover.New())
-	app.Use(logger.New(logger.Config{
-		Format: "[${time}] ${status} - ${method} ${path} - ${latency}\n",
-	}))
+	// Correlation ID of the request, then one structured access log line per request
+	app.Use(middleware.RequestID())
+	app.Use(middleware.AccessLog())
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     cfg.CorsOrigins,
 		AllowMethods:     "GET,POST,PUT,DELETE,OPTIONS,PATCH",
-		AllowHeaders:     "Origin,Content-Type,Accept,Authorization",
+		AllowHeaders:     "Origin,Content-Type,Accept,Authorization,X-Request-ID",
+		ExposeHeaders:    "X-Request-ID,X-RateLimit-Limit,X-RateLimit-Remaining,Retry-After",
 		AllowCredentials: true,
 	}))
 
 	// Security headers (XSS, Content-Type sniffing, etc)
 	app.Use(helmet.New())
 
+	// Response compression (brotli/gzip) for JSON, CSV and text above the minimum size
+	if cfg.CompressionEnabled {
+		app.Use(middleware.Compression(middleware.CompressionConfig{
+			MinSize:      cfg.CompressionMinSize,
+			ContentTypes: cfg.CompressionContentTypes,
+		}))
+	}
+
 	// Initialize repositories
+	repositories.SetBatchDeleteConfig(repositories.BatchDeleteConfig{
+		BatchSize: cfg.CleanupBatchSize,
+		Sleep:     cfg.CleanupBatchSleep,
+	})
 	userRepo := repositories.NewUserRepository(db)
 	technicianRepo := repositories.NewTechnicianRepository(db)
 	ticketRepo := repositories.NewTicketRepository(db)
 	clientRepo := repositories.NewClientRepository(db)
+	// The dashboard and the CSV exports read through these, from the replica when there is one
+	reportDB := database.ReplicaSession(db)
+	reportTechnicianRepo := repositories.NewTechnicianRepository(reportDB)
+	reportTicketRepo := repositories.NewTicketRepository(reportDB)
+	reportClientRepo := repositories.NewClientRepository(reportDB)
 	categoryRepo := repositories.NewCategoryRepository(db)
 	hierarchyRepo := repositories.NewHierarchyRepository(db)
+	resourceNodeRepo := repositories.NewResourceNodeRepository(db)
 	activityLogRepo := repositories.NewActivityLogRepository(db)
+	auditExportRepo := repositories.NewAuditExportRepository(db)
+	remediationRepo := repositories.NewRemediationRepository(db)
 	geoRepo := repositories.NewGeoRepository(db)
+	geoRepo.SetPostGIS(postGIS)
+	geoFenceRepo := repositories.NewGeoFenceRepository(db)
 	securityLogRepo := repositories.NewSecurityLogRepository(db)
 	financialRepo := repositories.NewFinancialRepository(db)
 	stockRepo := repositories.NewStockRepository(db)
 	errorLogRepo := repositories.NewErrorLogRepository(db)
+	schedulingRepo := repositories.NewSchedulingRepository(db)
+	dispatchRepo := repositories.NewDispatchRepository(db)
+	attachmentRepo := repositories.NewAttachmentRepository(db)
+	storageRepo := repositories.NewStorageRepository(db)
+	storedFileRepo := repositories.NewStoredFileRepository(db)
+	ticketTimelineRepo := repositories.NewTicketTimelineRepository(db)
+	statusRepo := repositories.NewStatusRepository(db)
+	requestMetricRepo := repositories.NewRequestMetricRepository(db)
+	npsRepo := repositories.NewNPSRepository(db)
+	satisfactionRepo := repositories.NewSatisfactionRepository(db)
+	complaintRepo := repositories.NewComplaintRepository(db)
+	priceListRepo := repositories.NewPriceListRepository(db)
+	discountRepo := repositories.NewDiscountRepository(db)
+	cancellationRepo := repositories.NewCancellationRepository(db)
+	complianceRepo := repositories.NewComplianceRepository(db)
+	coverageRepo := repositories.NewCoverageRepository(db)
+	slaRepo := repositories.NewSLARepository(db)
+	alertRepo := repositories.NewAlertRepository(db)
+	archiveRepo := repositories.NewArchiveRepository(db)
+	teamQueueRepo := repositories.NewTeamQueueRepository(db)
+	onCallRepo := repositories.NewOnCallRepository(db)
+	ticketBudgetRepo := repositories.NewTicketBudgetRepository(db)
+	ticketPartRepo := repositories.NewTicketPartRepository(db)
+	invoiceRepo := repositories.NewInvoiceRepository(db)
+	accountingExportRepo := repositories.NewAccountingExportRepository(db)
+	reportScheduleRepo := repositories.NewReportScheduleRepository(db)
+	clientDocumentRepo := repositories.NewClientDocumentRepository(db)
+	technicianHomeRepo := repositories.NewTechnicianHomeRepository(db)
+	technicianScheduleRepo := repositories.NewTechnicianScheduleRepository(db)
+	myWorkRepo := repositories.NewMyWorkRepository(db)
+	privacyRepo := repositories.NewPrivacyRepository(db)
+	supplierRepo := repositories.NewSupplierRepository(db)
+	notificationRepo := repositories.NewNotificationRepository(db)
+	brandingRepo := repositories.NewBrandingRepository(db)
+	sandboxRepo := repositories.NewSandboxRepository(db)
+	incidentTimelineRepo := repositories.NewIncidentTimelineRepository(db)
+	webhookRepo := repositories.NewWebhookRepository(db)
+	outboxRepo := repositories.NewOutboxRepository(db)
+	refreshTokenRepo := repositories.NewRefreshTokenRepository(db)
+	apiKeyRepo := repositories.NewAPIKeyRepository(db)
+	chatChannelRepo := repositories.NewChatChannelRepository(db)
+	gamificationRepo := repositories.NewGamificationRepository(db)
+	clientSegmentRepo := repositories.NewClientSegmentRepository(db)
+	recallCampaignRepo := repositories.NewRecallCampaignRepository(db)
+	ticketWorkflowRepo := repositories.NewTicketWorkflowRepository(db)
+	checklistRepo := repositories.NewChecklistRepository(db)
+	tenantRepo := repositories.NewTenantRepository(db)
+	auditRepo := repositories.NewAuditRepository(db)
+	graphqlRepo := repositories.NewGraphQLRepository(db)
 
 	// Initialize services
-	authService := services.NewAuthService(userRepo, securityLogRepo, hierarchyRepo, cfg)
+	emailSender := services.NewSMTPSender(services.SMTPConfig{
+		Host:     cfg.SMTPHost,
+		Port:     cfg.SMTPPort,
+		Username: cfg.SMTPUser,
+		Password: cfg.SMTPPassword,
+		From:     cfg.SMTPFrom,
+	})
+	notificationService := services.NewNotificationService(notificationRepo, userRepo, technicianRepo, webhookRepo, emailSender, services.NotificationConfig{
+		Channels:     cfg.NotificationChannels,
+		WebhookURL:   cfg.NotificationWebhookURL,
+		WebhookToken: cfg.NotificationWebhookToken,
+	})
+	webhookService := services.NewWebhookService(webhookRepo)
+	if cfg.WebhookDeliveryEnabled {
+		webhookService.Start(cfg.WebhookDeliveryInterval)
+		slog.Info("Webhook deliveries sent", "interval", cfg.WebhookDeliveryInterval)
+	}
+	authService := services.NewAuthService(userRepo, securityLogRepo, hierarchyRepo, refreshTokenRepo, cfg)
 	technicianService := services.NewTechnicianService(technicianRepo, redisClient)
-	ticketService := services.NewTicketService(ticketRepo, technicianRepo, clientRepo, categoryRepo)
-	dashboardService := services.NewDashboardService(technicianRepo, ticketRepo, clientRepo)
+	coverageService := services.NewCoverageService(coverageRepo, clientRepo, technicianRepo, stockRepo)
+	ticketWorkflowService := services.NewTicketWorkflowService(ticketWorkflowRepo, categoryRepo)
+	checklistService := services.NewChecklistService(checklistRepo, ticketRepo, categoryRepo)
+	ticketService := services.NewTicketService(ticketRepo, technicianRepo, clientRepo, categoryRepo, coverageService, cfg.CoverageEnforcement, notificationService, webhookService, ticketWorkflowService, checklistService)
+	dashboardService := services.NewDashboardService(reportTechnicianRepo, reportTicketRepo, reportClientRepo, redisClient)
 	activityLogService := services.NewActivityLogService(activityLogRepo)
+	auditService := services.NewAuditService(auditRepo)
+	apiKeyService := services.NewAPIKeyService(apiKeyRepo, activityLogService)
+	tenantService := services.NewTenantService(tenantRepo, userRepo, activityLogService)
+	auditChainService := services.NewAuditChainService(activityLogRepo, auditExportRepo, services.AuditChainConfig{
+		ExportDir:  cfg.AuditExportDir,
+		SigningKey: cfg.AuditSigningKey,
+	})
+	// Records written before the hash chain existed join it before anything new is logged
+	if sealed, err := auditChainService.SealUnchained(); err != nil {
+		slog.Warn("Failed to seal the audit log hash chain", "error", err)
+	} else if sealed > 0 {
+		slog.Info("Sealed audit log records into the hash chain", "sealed", sealed)
+	}
+	if cfg.AuditExportEnabled {
+		if cfg.AuditSigningKey == "" {
+			slog.Warn("AUDIT_SIGNING_KEY not set, signed audit log exports disabled")
+		} else {
+			auditChainService.Start(cfg.AuditExportInterval)
+			slog.Info("Signed audit log export running", "interval", cfg.AuditExportInterval)
+		}
+	}
 	hierarchyService := services.NewHierarchyService(hierarchyRepo)
-	geoService := services.NewGeoService(geoRepo, userRepo, technicianRepo, hierarchyService, redisClient)
+	permissionService := services.NewPermissionService(hierarchyRepo, redisClient)
+	permissions := middleware.NewPermissions(permissionService, resourceNodeRepo)
+	geocodingService := services.NewGeocodingService(geoRepo, clientRepo, services.GeocodingConfig{
+		Provider:     cfg.GeocodingProvider,
+		NominatimURL: cfg.NominatimURL,
+		UserAgent:    cfg.GeocodingUserAgent,
+		GoogleAPIKey: cfg.GoogleMapsAPIKey,
+		BatchSize:    cfg.GeocodingBatchSize,
+	})
+	if cfg.GeocodingEnabled {
+		geocodingService.Start(cfg.GeocodingInterval)
+		slog.Info("Client addresses geocoded", "provider", cfg.GeocodingProvider, "interval", cfg.GeocodingInterval)
+	}
+	geoService := services.NewGeoService(geoRepo, geoFenceRepo, userRepo, technicianRepo, clientRepo, hierarchyService, activityLogService, redisClient, geocodingService)
+	if cfg.GeoCleanupEnabled {
+		geoService.StartCleanup(cfg.GeoCleanupInterval)
+		slog.Info("Geo location cleanup running", "interval", cfg.GeoCleanupInterval)
+	}
 	securityLogService := services.NewSecurityLogService(securityLogRepo)
-	systemMetricsService := services.NewSystemMetricsService(db, redisClient, userRepo, ticketRepo, securityLogRepo)
-	financialService := services.NewFinancialService(financialRepo, categoryRepo)
-	stockService := services.NewStockService(stockRepo)
+	requestMetricsService := services.NewRequestMetricsService(requestMetricRepo)
+	requestMetricsService.Start(5 * time.Second)
+	systemMetricsService := services.NewSystemMetricsService(db, redisClient, userRepo, ticketRepo, securityLogRepo, requestMetricsService)
+	statusService := services.NewStatusService(statusRepo, db, redisClient)
+	priceListService := services.NewPriceListService(priceListRepo, clientRepo, categoryRepo, ticketRepo, stockRepo)
+	ticketBudgetService := services.NewTicketBudgetService(ticketBudgetRepo, ticketRepo, priceListService, activityLogService)
+	supplierService := services.NewSupplierService(supplierRepo)
+	financialService := services.NewFinancialService(financialRepo, categoryRepo, ticketBudgetService, supplierRepo, notificationService, webhookService, redisClient)
+	if cfg.RecurringEntriesEnabled {
+		financialService.Start(cfg.RecurringEntriesInterval)
+		slog.Info("Recurring financial entries running", "interval", cfg.RecurringEntriesInterval)
+	}
+	stockService := services.NewStockService(stockRepo, ticketRepo, userRepo, activityLogService, services.TransferApprovalConfig{
+		ValueThreshold:    cfg.TransferApprovalValue,
+		QuantityThreshold: cfg.TransferApprovalQuantity,
+	}, emailSender, ticketBudgetService, supplierRepo)
+	if cfg.CycleCountEnabled {
+		stockService.StartCycleCounts(cfg.CycleCountInterval, cfg.CycleCountItems)
+		slog.Info("Cycle count scheduler running", "interval", cfg.CycleCountInterval)
+	}
+	ticketPartService := services.NewTicketPartService(ticketPartRepo, ticketRepo, technicianRepo, stockService, priceListService)
+	outboxService := services.NewOutboxService(outboxRepo)
+	services.RegisterTicketClosingHandlers(outboxService, financialService, priceListService, ticketPartService, stockService, userRepo)
+	if cfg.OutboxEnabled {
+		outboxService.Start(cfg.OutboxInterval)
+		slog.Info("Outbox dispatcher running", "interval", cfg.OutboxInterval)
+	}
+	graphqlService := services.NewGraphQLService(graphqlRepo, ticketRepo, clientRepo, technicianRepo, stockService, financialService)
 	errorLogService := services.NewErrorLogService(errorLogRepo)
+	schedulingService := services.NewSchedulingService(schedulingRepo, ticketRepo, technicianRepo, activityLogService)
+	dispatchService := services.NewDispatchService(dispatchRepo, ticketService, technicianRepo, activityLogService, geoService, tenantRepo)
+	if cfg.AutoDispatchEnabled {
+		dispatchService.Start(cfg.AutoDispatchInterval)
+		slog.Info("Auto-dispatch running", "interval", cfg.AutoDispatchInterval)
+	}
+	ticketTimelineService := services.NewTicketTimelineService(ticketTimelineRepo, ticketService)
+	complaintService := services.NewComplaintService(complaintRepo, ticketService)
+	storageService := services.NewStorageService(storageRepo, cfg.UploadDir)
+	storageService.Start(24 * time.Hour)
+	attachmentService := services.NewAttachmentService(attachmentRepo, ticketRepo, storageService, services.AttachmentConfig{
+		UploadDir:     cfg.UploadDir,
+		ClamAVAddress: cfg.ClamAVAddress,
+	})
+	attachmentService.Start(time.Minute)
+
+	// File storage backend for /files uploads
+	var fileBackend storage.Backend
+	var localFiles *storage.Local
+	switch cfg.FileStorageBackend {
+	case storage.BackendS3:
+		s3Backend, err := storage.NewS3(storage.S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			PathStyle: cfg.S3PathStyle,
+		})
+		if err != nil {
+			logger.Fatal("Failed to configure S3 file storage", "error", err)
+		}
+		fileBackend = s3Backend
+	default:
+		localFiles = storage.NewLocal(cfg.UploadDir, "/api/v1/files/blob", []byte(cfg.JWTSecret))
+		fileBackend = localFiles
+	}
+	slog.Info("File storage backend", "backend", fileBackend.Name())
+	fileService := services.NewFileService(storedFileRepo, resourceNodeRepo, hierarchyRepo, userRepo, storageService, fileBackend, services.FileConfig{
+		MaxSize:       cfg.FileMaxSize,
+		URLTTL:        cfg.FileURLTTL,
+		ClamAVAddress: cfg.ClamAVAddress,
+	})
+	invoiceService := services.NewInvoiceService(invoiceRepo, financialService, fileService, services.InvoiceConfig{
+		Driver:       cfg.InvoiceDriver,
+		GatewayURL:   cfg.InvoiceGatewayURL,
+		GatewayToken: cfg.InvoiceGatewayToken,
+		ServiceCode:  cfg.InvoiceServiceCode,
+		ISSRate:      cfg.InvoiceISSRate,
+	})
+	services.RegisterInvoiceHandlers(outboxService, invoiceService)
+	accountingExportService := services.NewAccountingExportService(accountingExportRepo, fileService)
+	services.RegisterAccountingExportHandlers(outboxService, accountingExportService)
+	brandingService := services.NewBrandingService(brandingRepo, hierarchyRepo, storedFileRepo, fileBackend, cfg.CompanyName)
+	ticketPrintService := services.NewTicketPrintService(ticketRepo, brandingService, cfg.TrackingURL)
+	pdfService := services.NewPDFService(ticketRepo, stockRepo, financialRepo, brandingService, cfg.TrackingURL)
+	ticketTrackingService := services.NewTicketTrackingService(ticketRepo, ticketTimelineRepo, geoRepo, attachmentService, activityLogService, services.TrackingConfig{
+		Secret:    []byte(cfg.JWTSecret),
+		LinkTTL:   cfg.TrackingLinkTTL,
+		PortalURL: cfg.TrackingPortalURL,
+	})
+	settingsService := services.NewSettingsService(remediationRepo, activityLogService, redisClient, attachmentService)
+	settingsService.ApplyStored()
+	clientDocumentService := services.NewClientDocumentService(clientDocumentRepo, clientRepo, userRepo, storageService, activityLogService, emailSender, services.ClientDocumentConfig{
+		UploadDir:     cfg.UploadDir,
+		ClamAVAddress: cfg.ClamAVAddress,
+	})
+	if cfg.ClientDocumentRemindersEnabled {
+		clientDocumentService.Start(cfg.ClientDocumentReminderInterval)
+		slog.Info("Client document expiry reminders running", "interval", cfg.ClientDocumentReminderInterval)
+	}
+	privacyService := services.NewPrivacyService(privacyRepo, userRepo, refreshTokenRepo, activityLogService, permissionService, cfg.PrivacyDeletionGrace)
+	if cfg.PrivacyDeletionEnabled {
+		privacyService.Start(cfg.PrivacyDeletionInterval)
+		slog.Info("Account deletions processed", "interval", cfg.PrivacyDeletionInterval, "grace", cfg.PrivacyDeletionGrace)
+	}
+	incidentTimelineService := services.NewIncidentTimelineService(incidentTimelineRepo)
+	sandboxService := services.NewSandboxService(sandboxRepo, activityLogService, cfg.SandboxTTL)
+	if cfg.SandboxCleanupEnabled {
+		sandboxService.Start(cfg.SandboxCleanupInterval)
+		slog.Info("Expired sandboxes cleaned up", "interval", cfg.SandboxCleanupInterval)
+	}
+	npsService := services.NewNPSService(npsRepo, cfg.NPSSurveyURL, emailSender)
+	satisfactionService := services.NewSatisfactionService(satisfactionRepo, ticketRepo, notificationService, emailSender, services.SatisfactionConfig{
+		SurveyURL:     cfg.SatisfactionSurveyURL,
+		Lookback:      cfg.SatisfactionLookback,
+		ReminderAfter: cfg.SatisfactionReminderAfter,
+		MaxReminders:  cfg.SatisfactionMaxReminders,
+		ExpiresAfter:  cfg.SatisfactionSurveyExpiresIn,
+	})
+	if cfg.SatisfactionSurveysEnabled {
+		satisfactionService.Start(cfg.SatisfactionSurveyInterval)
+		slog.Info("Satisfaction surveys sent after closing", "interval", cfg.SatisfactionSurveyInterval, "reminder_after", cfg.SatisfactionReminderAfter)
+	}
+	gamificationService := services.NewGamificationService(gamificationRepo, technicianRepo)
+	clientSegmentService := services.NewClientSegmentService(clientSegmentRepo, clientRepo)
+	if cfg.ClientSegmentsEnabled {
+		clientSegmentService.Start(cfg.ClientSegmentsInterval)
+		slog.Info("Client segments recalculated", "interval", cfg.ClientSegmentsInterval)
+	}
+	recallCampaignService := services.NewRecallCampaignService(recallCampaignRepo, ticketService, activityLogService, cfg.RecallCampaignBatchSize)
+	if cfg.RecallCampaignsEnabled {
+		recallCampaignService.Start(cfg.RecallCampaignsInterval)
+		slog.Info("Recall campaign tickets generated", "interval", cfg.RecallCampaignsInterval, "batch_size", cfg.RecallCampaignBatchSize)
+	}
+	discountService := services.NewDiscountService(discountRepo, userRepo, ticketRepo, activityLogService, emailSender)
+	cancellationService := services.NewCancellationService(cancellationRepo, ticketRepo)
+	complianceService := services.NewComplianceService(complianceRepo)
+	slaService := services.NewSLAService(slaRepo, ticketRepo)
+	if cfg.SLABreachCheckEnabled {
+		slaService.Start(cfg.SLABreachCheckInterval)
+		slog.Info("SLA breach check running", "interval", cfg.SLABreachCheckInterval)
+	}
+	reportScheduleService := services.NewReportScheduleService(reportScheduleRepo, financialService, slaService, stockService, fileService, emailSender, notificationService)
+	if cfg.ReportSchedulesEnabled {
+		reportScheduleService.Start(cfg.ReportSchedulesInterval)
+		slog.Info("Scheduled reports running", "interval", cfg.ReportSchedulesInterval)
+	}
+	metaService := services.NewMetaService(db, database.Models())
+	archiveService := services.NewArchiveService(archiveRepo, activityLogService, cfg.ArchiveAfterMonths)
+	if cfg.ArchiveEnabled {
+		archiveService.Start(cfg.ArchiveInterval)
+		slog.Info("Ticket archival running", "interval", cfg.ArchiveInterval, "after_months", cfg.ArchiveAfterMonths)
+	}
+	teamQueueService := services.NewTeamQueueService(teamQueueRepo, hierarchyRepo, ticketRepo, activityLogService)
+	if cfg.TeamQueueEnabled {
+		teamQueueService.Start(cfg.TeamQueueInterval)
+		slog.Info("Team queue distribution running", "interval", cfg.TeamQueueInterval)
+	}
+	onCallService := services.NewOnCallService(onCallRepo, ticketRepo, technicianRepo, ticketService, activityLogService,
+		services.NewGatewaySender("PUSH", services.GatewayConfig{URL: cfg.PushGatewayURL, Token: cfg.PushGatewayToken}),
+		services.NewGatewaySender("SMS", services.GatewayConfig{URL: cfg.SMSGatewayURL, Token: cfg.SMSGatewayToken}),
+	)
+	if cfg.OnCallEscalationEnabled {
+		onCallService.Start(cfg.OnCallEscalationInterval)
+		slog.Info("On-call escalation running", "interval", cfg.OnCallEscalationInterval)
+	}
+	technicianHomeService := services.NewTechnicianHomeService(technicianHomeRepo, technicianRepo, geoRepo, onCallService)
+	technicianScheduleService := services.NewTechnicianScheduleService(technicianScheduleRepo, technicianRepo, ticketRepo, activityLogService)
+	myWorkService := services.NewMyWorkService(myWorkRepo, userRepo)
+	chatService := services.NewChatService(chatChannelRepo, hierarchyRepo)
+	if cfg.ChatDigestEnabled {
+		chatService.Start(cfg.ChatDigestHour)
+		slog.Info("Chat alert digest posted daily", "hour", cfg.ChatDigestHour)
+	}
+	alertService := services.NewAlertService(alertRepo, userRepo, slaService, stockService, financialService, statusService, notificationService, chatService, webhookService, services.AlertConfig{
+		SLARiskPercent:      cfg.AlertSLARiskPercent,
+		SLAComplianceTarget: cfg.AlertSLAComplianceTarget,
+		GeoLookback:         cfg.AlertGeoLookback,
+	})
+	if cfg.AlertsEnabled {
+		alertService.Start(cfg.AlertsInterval)
+		slog.Info("Alert scanner running", "interval", cfg.AlertsInterval)
+	}
+	remediationService := services.NewRemediationService(remediationRepo, alertRepo, settingsService, activityLogService)
+	if cfg.RemediationEnabled {
+		remediationService.Start(cfg.RemediationInterval)
+		slog.Info("Remediation rules running", "interval", cfg.RemediationInterval)
+	}
+
+	ticketSyncService := services.NewTicketSyncService(ticketService, ticketRepo, technicianRepo, permissionService)
 
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(authService)
 	technicianHandler := handlers.NewTechnicianHandler(technicianService)
-	ticketHandler := handlers.NewTicketHandler(ticketService)
+	ticketHandler := handlers.NewTicketHandler(ticketService, technicianScheduleService)
+	ticketSyncHandler := handlers.NewTicketSyncHandler(ticketSyncService)
+	ticketBulkHandler := handlers.NewTicketBulkHandler(services.NewTicketBulkService(ticketService, permissionService, activityLogService))
 	dashboardHandler := handlers.NewDashboardHandler(dashboardService)
-	clientHandler := handlers.NewClientHandler(clientRepo)
+	clientHandler := handlers.NewClientHandler(clientRepo, geocodingService)
 	categoryHandler := handlers.NewCategoryHandler(categoryRepo)
 	termsHandler := handlers.NewTermsHandler()
-	exportHandler := handlers.NewExportHandler(clientRepo, technicianRepo, ticketRepo)
-	hierarchyHandler := handlers.NewHierarchyHandler(hierarchyRepo)
+	exportHandler := handlers.NewExportHandler(reportClientRepo, reportTechnicianRepo, reportTicketRepo)
+	hierarchyHandler := handlers.NewHierarchyHandler(hierarchyRepo, permissionService)
 	userHandler := handlers.NewUserHandler(userRepo)
 	activityLogHandler := handlers.NewActivityLogHandler(activityLogService)
+	auditHandler := handlers.NewAuditHandler(auditService)
+	graphqlHandler := handlers.NewGraphQLHandler(graphqlService, permissions)
+	auditChainHandler := handlers.NewAuditChainHandler(auditChainService)
 	geoHandler := handlers.NewGeoHandler(geoService)
 	securityLogHandler := handlers.NewSecurityLogHandler(securityLogService)
 	adminHandler := handlers.NewAdminHandler(systemMetricsService)
-	financialHandler := handlers.NewFinancialHandler(financialService, categoryRepo)
-	stockHandler := handlers.NewStockHandler(stockService)
+	statusHandler := handlers.NewStatusHandler(statusService)
+	financialHandler := handlers.NewFinancialHandler(financialService, categoryRepo, ticketService)
+	invoiceHandler := handlers.NewInvoiceHandler(invoiceService)
+	accountingExportHandler := handlers.NewAccountingExportHandler(accountingExportService)
+	reportScheduleHandler := handlers.NewReportScheduleHandler(reportScheduleService)
+	stockHandler := handlers.NewStockHandler(stockService, permissions)
 	errorLogHandler := handlers.NewErrorLogHandler(errorLogService)
+	schedulingHandler := handlers.NewSchedulingHandler(schedulingService)
+	dispatchHandler := handlers.NewDispatchHandler(dispatchService)
+	attachmentHandler := handlers.NewAttachmentHandler(attachmentService)
+	storageHandler := handlers.NewStorageHandler(storageService)
+	fileHandler := handlers.NewFileHandler(fileService, localFiles, permissions)
+	settingsHandler := handlers.NewSettingsHandler(settingsService, remediationService)
+	ticketTimelineHandler := handlers.NewTicketTimelineHandler(ticketTimelineService, ticketService)
+	ticketPrintHandler := handlers.NewTicketPrintHandler(ticketPrintService)
+	pdfHandler := handlers.NewPDFHandler(pdfService)
+	ticketTrackingHandler := handlers.NewTicketTrackingHandler(ticketTrackingService)
+	brandingHandler := handlers.NewBrandingHandler(brandingService)
+	npsHandler := handlers.NewNPSHandler(npsService)
+	satisfactionHandler := handlers.NewSatisfactionHandler(satisfactionService)
+	complaintHandler := handlers.NewComplaintHandler(complaintService)
+	priceListHandler := handlers.NewPriceListHandler(priceListService)
+	discountHandler := handlers.NewDiscountHandler(discountService)
+	cancellationHandler := handlers.NewCancellationHandler(cancellationService)
+	complianceHandler := handlers.NewComplianceHandler(complianceService)
+	coverageHandler := handlers.NewCoverageHandler(coverageService)
+	slaHandler := handlers.NewSLAHandler(slaService)
+	alertHandler := handlers.NewAlertHandler(alertService)
+	metaHandler := handlers.NewMetaHandler(metaService)
+	archiveHandler := handlers.NewArchiveHandler(archiveService)
+	teamQueueHandler := handlers.NewTeamQueueHandler(teamQueueService)
+	onCallHandler := handlers.NewOnCallHandler(onCallService)
+	ticketBudgetHandler := handlers.NewTicketBudgetHandler(ticketBudgetService)
+	ticketPartHandler := handlers.NewTicketPartHandler(ticketPartService)
+	clientDocumentHandler := handlers.NewClientDocumentHandler(clientDocumentService)
+	technicianHomeHandler := handlers.NewTechnicianHomeHandler(technicianHomeService)
+	technicianScheduleHandler := handlers.NewTechnicianScheduleHandler(technicianScheduleService)
+	myWorkHandler := handlers.NewMyWorkHandler(myWorkService)
+	privacyHandler := handlers.NewPrivacyHandler(privacyService)
+	supplierHandler := handlers.NewSupplierHandler(supplierService)
+	notificationHandler := handlers.NewNotificationHandler(notificationService)
+	sandboxHandler := handlers.NewSandboxHandler(sandboxService)
+	incidentTimelineHandler := handlers.NewIncidentTimelineHandler(incidentTimelineService)
+	webhookHandler := handlers.NewWebhookHandler(webhookService)
+	outboxHandler := handlers.NewOutboxHandler(outboxService)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
+	chatChannelHandler := handlers.NewChatChannelHandler(chatService)
+	gamificationHandler := handlers.NewGamificationHandler(gamificationService)
+	clientSegmentHandler := handlers.NewClientSegmentHandler(clientSegmentService)
+	recallCampaignHandler := handlers.NewRecallCampaignHandler(recallCampaignService)
+	ticketWorkflowHandler := handlers.NewTicketWorkflowHandler(ticketWorkflowService)
+	checklistHandler := handlers.NewChecklistHandler(checklistService)
+	tenantHandler := handlers.NewTenantHandler(tenantService)
+
+	// Liveness and readiness probes, ahead of the error log and metrics middleware so
+	// polling does not flood them
+	app.Get("/healthz", statusHandler.Live)
+	app.Get("/readyz", statusHandler.Ready)
 
 	// Error logging middleware (add before routes)
 	app.Use(middleware.ErrorLoggerMiddleware(errorLogService))
 
+	// Request latency and payload size metrics (p95 vs latency budgets)
+	app.Use(middleware.RequestMetrics(requestMetricsService))
+
+	// Request body limits per endpoint group (413 above the limit)
+	app.Use(middleware.BodyLimit(cfg.BodyLimitDefault,
+		middleware.BodyLimitRule{Pattern: "/api/v1/auth/*", MaxBytes: cfg.BodyLimitAuth},
+		middleware.BodyLimitRule{Pattern: "/api/v1/geo/locations/batch", MaxBytes: cfg.BodyLimitGeoBatch},
+		middleware.BodyLimitRule{Pattern: "/api/v1/tickets/:id/files", MaxBytes: cfg.BodyLimitUpload},
+		middleware.BodyLimitRule{Pattern: "/api/v1/clients/:id/documents", MaxBytes: cfg.BodyLimitUpload},
+		middleware.BodyLimitRule{Pattern: "/api/v1/files/blob/*", MaxBytes: cfg.BodyLimitUpload},
+	))
+
+	// Query parameters in camelCase, snake_case still accepted by compat versions
+	app.Use(middleware.QueryCasing(middleware.QueryCasingConfig{
+		Modes: cfg.QueryCasingModes,
+	}))
+
+	// Tenant of the subdomain; authenticated requests must carry a token of the same tenant
+	app.Use(middleware.ResolveTenant(tenantService, cfg.TenantBaseDomain))
+
 	// Routes
 	api := app.Group("/api/v1")
 
@@ -149,6 +592,12 @@ func main() {
 	// Health check (public)
 	api.Get("/health", adminHandler.GetHealthCheck)
 
+	// Status page (public) with rate limiting
+	api.Get("/status", limiter.New(limiter.Config{
+		Max:        30,
+		Expiration: 1 * time.Minute,
+	}), statusHandler.GetStatus)
+
 	// Version info (public)
 	api.Get("/version", func(c *fiber.Ctx) error {
 		return c.JSON(fiber.Map{
@@ -159,18 +608,20 @@ func main() {
 		})
 	})
 
-	// Rate limiter for auth routes (5 requests per minute per IP)
-	authLimiter := limiter.New(limiter.Config{
-		Max:        5,
-		Expiration: 1 * time.Minute,
-		KeyGenerator: func(c *fiber.Ctx) string {
-			return c.IP()
-		},
-		LimitReached: func(c *fiber.Ctx) error {
-			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
-				"error": "Too many login attempts. Please try again later.",
-			})
-		},
+	// Rate limits shared by every instance through Redis: auth attempts per IP, writes
+	// per API key or user
+	rateLimiter := middleware.NewRateLimiter(redisClient, cfg.RateLimitEnabled)
+	authLimiter := rateLimiter.Limit(middleware.RateLimitRule{
+		Name:    "auth",
+		Max:     cfg.RateLimitAuthMax,
+		Window:  cfg.RateLimitAuthWindow,
+		Message: "Too many login attempts. Please try again later.",
+	})
+	writeLimiter := rateLimiter.Limit(middleware.RateLimitRule{
+		Name:    "write",
+		Max:     cfg.RateLimitWriteMax,
+		Window:  cfg.RateLimitWriteWindow,
+		Methods: []string{fiber.MethodPost, fiber.MethodPut, fiber.MethodPatch, fiber.MethodDelete},
 	})
 
 	// Auth routes (public) with rate limiting
@@ -179,11 +630,64 @@ func main() {
 	auth.Post("/signup", authLimiter, authHandler.SignUp)
 	auth.Post("/refresh", authHandler.RefreshToken)
 
+	// Client self-scheduling through public link (public) with rate limiting
+	publicScheduling := api.Group("/public/scheduling", limiter.New(limiter.Config{
+		Max:        30,
+		Expiration: 1 * time.Minute,
+	}))
+	publicScheduling.Get("/:token/slots", schedulingHandler.GetPublicSlots)
+	publicScheduling.Post("/:token/confirm", schedulingHandler.ConfirmPublicSlot)
+
+	// NPS survey answered through public link (public) with rate limiting
+	publicNPS := api.Group("/public/nps", limiter.New(limiter.Config{
+		Max:        30,
+		Expiration: 1 * time.Minute,
+	}))
+	publicNPS.Get("/:token", npsHandler.GetPublicSurvey)
+	publicNPS.Post("/:token", npsHandler.AnswerPublicSurvey)
+
+	// Ticket satisfaction survey answered through public link (public) with rate limiting
+	publicSatisfaction := api.Group("/public/satisfaction", limiter.New(limiter.Config{
+		Max:        30,
+		Expiration: 1 * time.Minute,
+	}))
+	publicSatisfaction.Get("/:token", satisfactionHandler.GetPublicSurvey)
+	publicSatisfaction.Post("/:token", satisfactionHandler.AnswerPublicSurvey)
+
+	// Ticket tracking page through signed link (public) with rate limiting
+	publicTracking := api.Group("/public/tracking", limiter.New(limiter.Config{
+		Max:        30,
+		Expiration: 1 * time.Minute,
+	}))
+	publicTracking.Get("/:token", ticketTrackingHandler.GetTracking)
+	publicTracking.Post("/:token/comments", ticketTrackingHandler.AddComment)
+	publicTracking.Get("/:token/files/:fileId", ticketTrackingHandler.DownloadAttachment)
+
+	// Client document download through share link (public) with rate limiting
+	publicClientDocuments := api.Group("/public/client-documents", limiter.New(limiter.Config{
+		Max:        30,
+		Expiration: 1 * time.Minute,
+	}))
+	publicClientDocuments.Get("/:token", clientDocumentHandler.PublicDownload)
+
+	// Technician ICS feed (public, authorized by the feed token) with rate limiting
+	api.Get("/technicians/:id/schedule.ics", limiter.New(limiter.Config{
+		Max:        30,
+		Expiration: 1 * time.Minute,
+	}), schedulingHandler.GetCalendarFeed)
+
+	// Local file storage presigned URLs (public, authorized by the signed token)
+	api.Put("/files/blob/:token", fileHandler.PutBlob)
+	api.Get("/files/blob/:token", fileHandler.GetBlob)
+
 	// Protected routes
-	protected := api.Group("", middleware.JWTProtected(cfg.JWTSecret))
+	protected := api.Group("", middleware.JWTProtected(cfg.JWTSecret, authService, apiKeyService), writeLimiter)
 
 	// Protected auth routes
 	protected.Post("/auth/change-password", authHandler.ChangePassword)
+	protected.Get("/auth/sessions", authHandler.ListSessions)
+	protected.Delete("/auth/sessions", authHandler.RevokeAllSessions)
+	protected.Delete("/auth/sessions/:id", authHandler.RevokeSession)
 
 	// User management routes (admin)
 	users := protected.Group("/users")
@@ -206,28 +710,151 @@ func main() {
 	technicians.Get("/search", technicianHandler.Search)
 	technicians.Get("/by-city/:city", technicianHandler.GetByCity)
 	technicians.Get("/by-state/:state", technicianHandler.GetByState)
+	// ICS subscription URL; technicians manage their own, office users any
+	technicians.Get("/:id/calendar-feed", schedulingHandler.GetCalendarFeedToken)
+	technicians.Post("/:id/calendar-feed/rotate", schedulingHandler.RotateCalendarFeedToken)
+	// Working week, days off and vacations; assignments outside them need an override
+	technicians.Get("/:id/schedule", technicianScheduleHandler.GetSchedule)
+	technicians.Put("/:id/schedule", middleware.AdminOrEmployee(), technicianScheduleHandler.SaveSchedule)
+	technicians.Delete("/:id/schedule", middleware.AdminOrEmployee(), technicianScheduleHandler.DeleteSchedule)
+	technicians.Get("/:id/time-off", technicianScheduleHandler.ListTimeOff)
+	technicians.Post("/:id/time-off", middleware.AdminOrEmployee(), technicianScheduleHandler.AddTimeOff)
+	technicians.Delete("/:id/time-off/:timeOffId", middleware.AdminOrEmployee(), technicianScheduleHandler.DeleteTimeOff)
+	technicians.Get("/:id/availability", technicianScheduleHandler.GetAvailability)
 
 	// Ticket routes
 	tickets := protected.Group("/tickets")
-	tickets.Get("/", ticketHandler.GetAll)
-	tickets.Get("/:id", ticketHandler.GetByID)
-	tickets.Post("/", middleware.WriteAccess(), ticketHandler.Create)
-	tickets.Put("/:id", middleware.WriteAccess(), ticketHandler.Update)
-	tickets.Delete("/:id", middleware.WriteAccess(), ticketHandler.Delete)
-	tickets.Put("/:id/status", middleware.WriteAccess(), ticketHandler.UpdateStatus)
-	tickets.Put("/:id/assign", middleware.WriteAccess(), ticketHandler.AssignTechnician)
-	tickets.Post("/:id/sign", middleware.WriteAccess(), ticketHandler.SignTicket)
+	tickets.Get("/", permissions.Require("tickets.view"), ticketHandler.GetAll)
+	tickets.Get("/calendar", permissions.Require("tickets.view"), schedulingHandler.GetCalendar)
+	tickets.Get("/calendar.ics", permissions.Require("tickets.view"), schedulingHandler.ExportCalendar)
+	tickets.Get("/:id", permissions.RequireOn("tickets.view", middleware.TicketNode("id")), ticketHandler.GetByID)
+	tickets.Post("/", permissions.RequireOn("tickets.create", middleware.CreatedNode), ticketHandler.Create)
+	// Bulk status change, assignment or deletion; the permission is checked on each ticket's node
+	tickets.Post("/bulk", ticketBulkHandler.Execute)
+	tickets.Put("/:id", permissions.RequireOn("tickets.edit", middleware.TicketNode("id")), ticketHandler.Update)
+	tickets.Delete("/:id", permissions.RequireOn("tickets.delete", middleware.TicketNode("id")), ticketHandler.Delete)
+	tickets.Put("/:id/status", permissions.RequireOn("tickets.edit", middleware.TicketNode("id")), ticketHandler.UpdateStatus)
+	tickets.Get("/:id/transitions", permissions.RequireOn("tickets.view", middleware.TicketNode("id")), ticketHandler.GetTransitions)
+	tickets.Get("/:id/checklist", permissions.RequireOn("tickets.view", middleware.TicketNode("id")), checklistHandler.GetTicketChecklist)
+	tickets.Put("/:id/checklist/:itemId", permissions.RequireOn("tickets.edit", middleware.TicketNode("id")), checklistHandler.UpdateItem)
+	tickets.Get("/:id/parts", permissions.RequireOn("tickets.view", middleware.TicketNode("id")), ticketPartHandler.List)
+	tickets.Post("/:id/parts", permissions.RequireOn("tickets.edit", middleware.TicketNode("id")), ticketPartHandler.Add)
+	tickets.Post("/:id/cancel", permissions.RequireOn("tickets.edit", middleware.TicketNode("id")), cancellationHandler.Cancel)
+	tickets.Get("/:id/sla", permissions.RequireOn("tickets.view", middleware.TicketNode("id")), slaHandler.GetTicketSLA)
+	tickets.Post("/:id/priority-dispatch", middleware.AdminOrEmployee(), onCallHandler.PriorityDispatch)
+	tickets.Get("/:id/suggested-technicians", middleware.AdminOrEmployee(), dispatchHandler.SuggestTechnicians)
+	tickets.Get("/:id/budget", middleware.AdminOrEmployee(), ticketBudgetHandler.Get)
+	tickets.Put("/:id/budget", middleware.AdminOrEmployee(), ticketBudgetHandler.Set)
+	tickets.Delete("/:id/budget", middleware.AdminOnly(), ticketBudgetHandler.Delete)
+	tickets.Get("/:id/budget/overrides", middleware.AdminOrEmployee(), ticketBudgetHandler.ListOverrides)
+	tickets.Post("/:id/budget/overrides", middleware.AdminOrEmployee(), ticketBudgetHandler.RequestOverride)
+	tickets.Post("/:id/budget/overrides/:overrideId/approve", middleware.AdminOnly(), ticketBudgetHandler.ApproveOverride)
+	tickets.Post("/:id/budget/overrides/:overrideId/reject", middleware.AdminOnly(), ticketBudgetHandler.RejectOverride)
+	tickets.Put("/:id/assign", permissions.RequireOn("tickets.assign", middleware.TicketNode("id")), ticketHandler.AssignTechnician)
+	tickets.Get("/:id/assignments", permissions.RequireOn("tickets.view", middleware.TicketNode("id")), ticketHandler.GetAssignments)
+	tickets.Get("/:id/payout-split", permissions.RequireOn("tickets.view", middleware.TicketNode("id")), ticketHandler.GetPayoutSplit)
+	tickets.Post("/:id/sign", permissions.RequireOn("tickets.edit", middleware.TicketNode("id")), ticketHandler.SignTicket)
 	tickets.Delete("/:id/sign", middleware.AdminOnly(), ticketHandler.DeleteSignature)
+	tickets.Get("/:id/print", permissions.RequireOn("tickets.view", middleware.TicketNode("id")), ticketPrintHandler.Print)
+	tickets.Get("/:id/pdf", permissions.RequireOn("tickets.view", middleware.TicketNode("id")), pdfHandler.ServiceOrder)
+	tickets.Get("/:id/comments", permissions.RequireOn("tickets.view", middleware.TicketNode("id")), ticketTimelineHandler.GetComments)
+	tickets.Post("/:id/comments", permissions.RequireOn("tickets.edit", middleware.TicketNode("id")), ticketTimelineHandler.AddComment)
+	tickets.Get("/:id/events", permissions.RequireOn("tickets.view", middleware.TicketNode("id")), ticketTimelineHandler.GetEvents)
+	tickets.Get("/:id/stream", permissions.RequireOn("tickets.view", middleware.TicketNode("id")), ticketTimelineHandler.Stream)
+	tickets.Get("/:id/files", permissions.RequireOn("tickets.view", middleware.TicketNode("id")), attachmentHandler.GetByTicket)
+	tickets.Post("/:id/files", permissions.RequireOn("tickets.edit", middleware.TicketNode("id")), attachmentHandler.Upload)
+	tickets.Get("/:id/files/:fileId", permissions.RequireOn("tickets.view", middleware.TicketNode("id")), attachmentHandler.GetByID)
+	tickets.Get("/:id/files/:fileId/download", permissions.RequireOn("tickets.view", middleware.TicketNode("id")), attachmentHandler.Download)
+	tickets.Post("/:id/files/:fileId/reprocess", permissions.RequireOn("tickets.edit", middleware.TicketNode("id")), attachmentHandler.Reprocess)
+	tickets.Delete("/:id/files/:fileId", permissions.RequireOn("tickets.edit", middleware.TicketNode("id")), attachmentHandler.Delete)
+	tickets.Get("/:id/slots", permissions.RequireOn("tickets.view", middleware.TicketNode("id")), schedulingHandler.GetTicketSlots)
+	tickets.Post("/:id/schedule", permissions.RequireOn("tickets.edit", middleware.TicketNode("id")), schedulingHandler.ConfirmTicketSlot)
+	tickets.Post("/:id/scheduling-link", permissions.RequireOn("tickets.edit", middleware.TicketNode("id")), schedulingHandler.CreateLink)
+	tickets.Get("/:id/satisfaction", permissions.RequireOn("tickets.view", middleware.TicketNode("id")), satisfactionHandler.GetTicketSurvey)
+	tickets.Post("/:id/satisfaction", permissions.RequireOn("tickets.edit", middleware.TicketNode("id")), satisfactionHandler.CreateTicketSurvey)
+	// Signs the link of the public tracking page; creating it needs a login
+	protected.Post("/public/tickets/:id/tracking-link", permissions.RequireOn("tickets.edit", middleware.TicketNode("id")), ticketTrackingHandler.CreateLink)
+
+	// Files of tickets, financial entries and stock movements (presigned uploads)
+	files := protected.Group("/files")
+	files.Get("/", fileHandler.List)
+	files.Post("/uploads", middleware.WriteAccess(), fileHandler.CreateUpload)
+	files.Get("/:id", fileHandler.GetByID)
+	files.Post("/:id/complete", middleware.WriteAccess(), fileHandler.Complete)
+	files.Get("/:id/download", fileHandler.Download)
+	files.Delete("/:id", middleware.WriteAccess(), fileHandler.Delete)
+
+	// Scheduling settings (travel time and buffer per scope)
+	scheduling := protected.Group("/scheduling")
+	scheduling.Get("/settings", schedulingHandler.GetSettings)
+	scheduling.Put("/settings", middleware.AdminOnly(), schedulingHandler.UpdateSettings)
+
+	// Auto-dispatch rules, decision audit and manual queue
+	dispatch := protected.Group("/dispatch")
+	dispatch.Get("/rules", dispatchHandler.ListRules)
+	dispatch.Post("/rules", middleware.AdminOnly(), dispatchHandler.CreateRule)
+	dispatch.Put("/rules/:id", middleware.AdminOnly(), dispatchHandler.UpdateRule)
+	dispatch.Delete("/rules/:id", middleware.AdminOnly(), dispatchHandler.DeleteRule)
+	dispatch.Get("/decisions", dispatchHandler.GetDecisions)
+	dispatch.Get("/queue", dispatchHandler.GetManualQueue)
+	dispatch.Post("/run", middleware.AdminOnly(), dispatchHandler.Run)
+	dispatch.Post("/simulate", middleware.AdminOrEmployee(), dispatchHandler.Simulate)
 
 	// Client routes
 	clients := protected.Group("/clients")
-	clients.Get("/", clientHandler.GetAll)
-	clients.Get("/count", clientHandler.Count)
-	clients.Get("/:id", clientHandler.GetByID)
+	clients.Get("/", permissions.Require("clients.view"), clientHandler.GetAll)
+	clients.Get("/count", permissions.Require("clients.view"), clientHandler.Count)
+	clients.Get("/:id", permissions.Require("clients.view"), clientHandler.GetByID)
 	clients.Post("/", middleware.WriteAccess(), clientHandler.Create)
 	clients.Put("/:id", middleware.WriteAccess(), clientHandler.Update)
 	clients.Delete("/:id", middleware.WriteAccess(), clientHandler.Delete)
 
+	// Client tags (managed by the users) and segments (computed by the segmentation job)
+	clients.Post("/:id/geocode", middleware.AdminOrEmployee(), clientHandler.Geocode)
+	clients.Get("/:id/segments", clientSegmentHandler.GetClient)
+	clients.Put("/:id/tags", middleware.WriteAccess(), clientSegmentHandler.SetClientTags)
+
+	clientTags := protected.Group("/client-tags")
+	clientTags.Get("/", clientSegmentHandler.ListTags)
+	clientTags.Post("/", middleware.AdminOrEmployee(), clientSegmentHandler.CreateTag)
+	clientTags.Put("/:id", middleware.AdminOrEmployee(), clientSegmentHandler.UpdateTag)
+	clientTags.Delete("/:id", middleware.AdminOnly(), clientSegmentHandler.DeleteTag)
+
+	clientSegments := protected.Group("/client-segments", middleware.AdminOrEmployee())
+	clientSegments.Get("/", clientSegmentHandler.GetSummary)
+	clientSegments.Post("/recalculate", middleware.AdminOnly(), clientSegmentHandler.Recalculate)
+
+	// Recall, preventive and warranty campaigns (tickets generated in batches by the campaign job)
+	recallCampaigns := protected.Group("/recall-campaigns", middleware.AdminOrEmployee())
+	recallCampaigns.Get("/", recallCampaignHandler.List)
+	recallCampaigns.Post("/", recallCampaignHandler.Create)
+	recallCampaigns.Post("/preview", recallCampaignHandler.Preview)
+	recallCampaigns.Get("/:id", recallCampaignHandler.Get)
+	recallCampaigns.Put("/:id", recallCampaignHandler.Update)
+	recallCampaigns.Post("/:id/start", recallCampaignHandler.Start)
+	recallCampaigns.Post("/:id/pause", recallCampaignHandler.Pause)
+	recallCampaigns.Post("/:id/cancel", recallCampaignHandler.Cancel)
+	recallCampaigns.Get("/:id/targets", recallCampaignHandler.GetTargets)
+	recallCampaigns.Get("/:id/report", recallCampaignHandler.GetReport)
+
+	// Client document vault (download and share links limited by the category roles)
+	clients.Get("/:id/documents", clientDocumentHandler.GetByClient)
+	clients.Post("/:id/documents", middleware.AdminOrEmployee(), clientDocumentHandler.Upload)
+	clients.Get("/:id/documents/:docId", clientDocumentHandler.GetByID)
+	clients.Put("/:id/documents/:docId", middleware.AdminOrEmployee(), clientDocumentHandler.Update)
+	clients.Delete("/:id/documents/:docId", middleware.AdminOnly(), clientDocumentHandler.Delete)
+	clients.Get("/:id/documents/:docId/download", clientDocumentHandler.Download)
+	clients.Get("/:id/documents/:docId/links", middleware.AdminOrEmployee(), clientDocumentHandler.ListLinks)
+	clients.Post("/:id/documents/:docId/links", middleware.AdminOrEmployee(), clientDocumentHandler.CreateLink)
+	clients.Delete("/:id/documents/:docId/links/:linkId", middleware.AdminOrEmployee(), clientDocumentHandler.RevokeLink)
+
+	clientDocuments := protected.Group("/client-documents")
+	clientDocuments.Get("/expiring", middleware.AdminOrEmployee(), clientDocumentHandler.GetExpiring)
+	clientDocuments.Post("/reminders/run", middleware.AdminOnly(), clientDocumentHandler.RunReminders)
+	clientDocuments.Get("/categories", clientDocumentHandler.ListCategories)
+	clientDocuments.Post("/categories", middleware.AdminOnly(), clientDocumentHandler.CreateCategory)
+	clientDocuments.Put("/categories/:id", middleware.AdminOnly(), clientDocumentHandler.UpdateCategory)
+
 	// Category routes
 	categories := protected.Group("/categories")
 	categories.Get("/", categoryHandler.GetAll)
@@ -240,9 +867,170 @@ func main() {
 	dashboard := protected.Group("/dashboard")
 	dashboard.Get("/stats", dashboardHandler.GetStats)
 	dashboard.Get("/tickets-by-status", dashboardHandler.GetTicketsByStatus)
+	dashboard.Get("/tickets-by-source", dashboardHandler.GetTicketsBySource)
 	dashboard.Get("/technicians-by-state", dashboardHandler.GetTechniciansByState)
 	dashboard.Get("/chart", dashboardHandler.GetChartData)
 	dashboard.Get("/recent-activity", dashboardHandler.GetRecentActivity)
+	dashboard.Get("/nps", middleware.AdminOrEmployee(), npsHandler.GetDashboard)
+	dashboard.Get("/satisfaction", middleware.AdminOrEmployee(), satisfactionHandler.GetReport)
+	dashboard.Get("/sla-compliance", middleware.AdminOrEmployee(), slaHandler.GetDashboard)
+	dashboard.Get("/on-duty", middleware.AdminOrEmployee(), technicianScheduleHandler.OnDuty)
+
+	// SLA policies (response and resolution targets per category/priority)
+	slaPolicies := protected.Group("/sla-policies", middleware.AdminOrEmployee())
+	slaPolicies.Get("/", slaHandler.ListPolicies)
+	slaPolicies.Post("/", middleware.AdminOnly(), slaHandler.CreatePolicy)
+	slaPolicies.Put("/:id", middleware.AdminOnly(), slaHandler.UpdatePolicy)
+	slaPolicies.Delete("/:id", middleware.AdminOnly(), slaHandler.DeletePolicy)
+
+	// Complaints / ombudsman (admin and employee access)
+	complaints := protected.Group("/complaints", middleware.AdminOrEmployee())
+	complaints.Get("/", complaintHandler.List)
+	complaints.Get("/report", complaintHandler.GetReport)
+	complaints.Get("/:id", complaintHandler.Get)
+	complaints.Post("/", complaintHandler.Create)
+	complaints.Put("/:id/root-cause", complaintHandler.Classify)
+	complaints.Post("/:id/close", complaintHandler.Close)
+
+	// NPS campaigns (admin and employee access)
+	nps := protected.Group("/nps", middleware.AdminOrEmployee())
+	nps.Get("/campaigns", npsHandler.ListCampaigns)
+	nps.Post("/campaigns", npsHandler.CreateCampaign)
+	nps.Get("/campaigns/:id", npsHandler.GetCampaign)
+	nps.Post("/campaigns/:id/send", npsHandler.SendCampaign)
+	nps.Post("/campaigns/:id/close", npsHandler.CloseCampaign)
+	nps.Get("/campaigns/:id/invitations", npsHandler.GetInvitations)
+	nps.Get("/campaigns/:id/results", npsHandler.GetResults)
+	nps.Get("/trend", npsHandler.GetTrend)
+
+	// Gamification: opt-in leaderboards per region, badges maintained by admins
+	gamification := protected.Group("/gamification")
+	gamification.Get("/leaderboard", gamificationHandler.GetLeaderboard)
+	gamification.Get("/me", gamificationHandler.GetMine)
+	gamification.Put("/me", gamificationHandler.UpdateMine)
+	gamification.Get("/badges", gamificationHandler.ListBadges)
+	gamification.Post("/badges", middleware.AdminOnly(), gamificationHandler.CreateBadge)
+	gamification.Put("/badges/:id", middleware.AdminOnly(), gamificationHandler.UpdateBadge)
+	gamification.Delete("/badges/:id", middleware.AdminOnly(), gamificationHandler.DeleteBadge)
+
+	// Price lists (admin and employee read, admin maintains prices)
+	priceLists := protected.Group("/price-lists", middleware.AdminOrEmployee())
+	priceLists.Get("/", priceListHandler.List)
+	priceLists.Post("/resolve", priceListHandler.Resolve)
+	priceLists.Get("/:id", priceListHandler.Get)
+	priceLists.Get("/:id/history", priceListHandler.GetHistory)
+	priceLists.Post("/", middleware.AdminOnly(), priceListHandler.Create)
+	priceLists.Put("/:id", middleware.AdminOnly(), priceListHandler.Update)
+	priceLists.Post("/:id/entries", middleware.AdminOnly(), priceListHandler.AddEntry)
+	priceLists.Put("/:id/entries/:entryId", middleware.AdminOnly(), priceListHandler.UpdateEntry)
+	priceLists.Delete("/:id/entries/:entryId", middleware.AdminOnly(), priceListHandler.DeleteEntry)
+
+	// Discounts on quotes/invoices (beyond the role limit they wait for admin approval)
+	discounts := protected.Group("/discounts", middleware.AdminOrEmployee())
+	discounts.Get("/", discountHandler.List)
+	discounts.Post("/", discountHandler.Create)
+	discounts.Get("/limits", discountHandler.GetLimits)
+	discounts.Put("/limits/:role", middleware.AdminOnly(), discountHandler.SetLimit)
+	discounts.Get("/report", middleware.AdminOnly(), discountHandler.GetReport)
+	discounts.Get("/:id", discountHandler.Get)
+	discounts.Post("/:id/approve", middleware.AdminOnly(), discountHandler.Approve)
+	discounts.Post("/:id/reject", middleware.AdminOnly(), discountHandler.Reject)
+
+	// Ticket cancellation reasons
+	cancellationReasons := protected.Group("/cancellation-reasons")
+	cancellationReasons.Get("/", cancellationHandler.ListReasons)
+	cancellationReasons.Post("/", middleware.AdminOnly(), cancellationHandler.CreateReason)
+	cancellationReasons.Put("/:id", middleware.AdminOnly(), cancellationHandler.UpdateReason)
+
+	// Operational reports (admin and employee access)
+	reports := protected.Group("/reports", middleware.AdminOrEmployee())
+	reports.Get("/cancellations", cancellationHandler.GetReport)
+	reports.Get("/sla", slaHandler.GetComplianceReport)
+	reports.Get("/budget-variance", ticketBudgetHandler.GetVarianceReport)
+	reports.Get("/compliance/expirations", complianceHandler.GetExpiryReport)
+
+	// Scheduled reports e-mailed on cron (admin only)
+	reports.Get("/schedules", middleware.AdminOnly(), reportScheduleHandler.List)
+	reports.Post("/schedules", middleware.AdminOnly(), reportScheduleHandler.Create)
+	reports.Get("/schedules/:id", middleware.AdminOnly(), reportScheduleHandler.Get)
+	reports.Put("/schedules/:id", middleware.AdminOnly(), reportScheduleHandler.Update)
+	reports.Delete("/schedules/:id", middleware.AdminOnly(), reportScheduleHandler.Delete)
+	reports.Post("/schedules/:id/run", middleware.AdminOnly(), reportScheduleHandler.Run)
+	reports.Get("/schedules/:id/runs", middleware.AdminOnly(), reportScheduleHandler.ListRuns)
+	reports.Get("/schedules/:id/runs/:runId/download", middleware.AdminOnly(), reportScheduleHandler.DownloadRun)
+
+	// Operational alerts center (admin and employee access)
+	// In-app notifications of the logged user
+	notifications := protected.Group("/notifications")
+	notifications.Get("/", notificationHandler.List)
+	notifications.Get("/unread-count", notificationHandler.UnreadCount)
+	notifications.Post("/read-all", notificationHandler.MarkAllRead)
+	notifications.Post("/:id/read", notificationHandler.MarkRead)
+
+	alerts := protected.Group("/alerts", middleware.AdminOrEmployee())
+	alerts.Get("/", alertHandler.List)
+	alerts.Get("/badges", alertHandler.Badges)
+	alerts.Post("/scan", middleware.AdminOnly(), alertHandler.Scan)
+	alerts.Get("/:id", alertHandler.Get)
+	alerts.Post("/:id/acknowledge", alertHandler.Acknowledge)
+	alerts.Put("/:id/assign", alertHandler.Assign)
+	alerts.Post("/:id/resolve", alertHandler.Resolve)
+
+	// Archive of long-closed tickets
+	archive := protected.Group("/archive", middleware.AdminOrEmployee())
+	archive.Get("/search", archiveHandler.Search)
+	archive.Post("/run", middleware.AdminOnly(), archiveHandler.Run)
+	archive.Get("/tickets/:id", archiveHandler.Get)
+	archive.Post("/tickets/:id/restore", middleware.AdminOnly(), archiveHandler.Restore)
+
+	// Team queues (intake distribution across teams)
+	teamQueues := protected.Group("/team-queues", middleware.AdminOrEmployee())
+	teamQueues.Get("/", teamQueueHandler.List)
+	teamQueues.Post("/", middleware.AdminOnly(), teamQueueHandler.Create)
+	teamQueues.Get("/metrics", teamQueueHandler.Metrics)
+	teamQueues.Get("/settings", teamQueueHandler.GetSettings)
+	teamQueues.Put("/settings", middleware.AdminOnly(), teamQueueHandler.UpdateSettings)
+	teamQueues.Post("/distribute", middleware.AdminOnly(), teamQueueHandler.Distribute)
+	teamQueues.Post("/rebalance", middleware.AdminOnly(), teamQueueHandler.Rebalance)
+	teamQueues.Get("/tickets/:ticketId", teamQueueHandler.GetTicketQueue)
+	teamQueues.Put("/tickets/:ticketId", teamQueueHandler.MoveTicket)
+	teamQueues.Put("/:id", middleware.AdminOnly(), teamQueueHandler.Update)
+	teamQueues.Get("/:id/tickets", teamQueueHandler.GetTickets)
+
+	// On-call rotations and emergency paging; technicians see and acknowledge their own pages
+	onCall := protected.Group("/on-call")
+	onCall.Get("/pages/mine", onCallHandler.MyPages)
+	onCall.Post("/pages/:id/acknowledge", onCallHandler.Acknowledge)
+	onCall.Get("/pages", middleware.AdminOrEmployee(), onCallHandler.ListPages)
+	onCall.Post("/escalate", middleware.AdminOnly(), onCallHandler.Escalate)
+	onCall.Get("/pages/:id", middleware.AdminOrEmployee(), onCallHandler.GetPage)
+	onCall.Post("/pages/:id/cancel", middleware.AdminOrEmployee(), onCallHandler.Cancel)
+	onCall.Get("/schedules", middleware.AdminOrEmployee(), onCallHandler.ListSchedules)
+	onCall.Post("/schedules", middleware.AdminOnly(), onCallHandler.CreateSchedule)
+	onCall.Get("/schedules/:id", middleware.AdminOrEmployee(), onCallHandler.GetSchedule)
+	onCall.Put("/schedules/:id", middleware.AdminOnly(), onCallHandler.UpdateSchedule)
+	onCall.Get("/schedules/:id/now", middleware.AdminOrEmployee(), onCallHandler.WhoIsOnCall)
+
+	// Data dictionary for integrators and BI
+	meta := protected.Group("/meta")
+	meta.Get("/schema", metaHandler.GetSchema)
+
+	// Coverage areas per branch/technician (check is open to every authenticated user)
+	coverage := protected.Group("/coverage")
+	coverage.Get("/check", coverageHandler.Check)
+	coverage.Get("/areas", middleware.AdminOrEmployee(), coverageHandler.ListAreas)
+	coverage.Get("/areas/:id", middleware.AdminOrEmployee(), coverageHandler.GetArea)
+	coverage.Post("/areas", middleware.AdminOnly(), coverageHandler.CreateArea)
+	coverage.Put("/areas/:id", middleware.AdminOnly(), coverageHandler.UpdateArea)
+	coverage.Delete("/areas/:id", middleware.AdminOnly(), coverageHandler.DeleteArea)
+
+	// Suppliers (linked from stock movements and financial entries)
+	suppliers := protected.Group("/suppliers", middleware.AdminOrEmployee())
+	suppliers.Get("/", supplierHandler.List)
+	suppliers.Get("/:id", supplierHandler.Get)
+	suppliers.Post("/", supplierHandler.Create)
+	suppliers.Put("/:id", supplierHandler.Update)
+	suppliers.Delete("/:id", middleware.AdminOnly(), supplierHandler.Delete)
 
 	// Cities endpoint for technicians
 	technicians.Get("/cities", technicianHandler.GetCities)
@@ -257,6 +1045,17 @@ func main() {
 	export.Get("/tickets", exportHandler.ExportTickets)
 	export.Get("/all", exportHandler.ExportAll)
 
+	// Financial exports in accounting layouts, generated in the background
+	export.Get("/financial/layouts", permissions.Require("finance.view"), accountingExportHandler.ListLayouts)
+	export.Get("/financial/layouts/:id", permissions.Require("finance.view"), accountingExportHandler.GetLayout)
+	export.Post("/financial/layouts", permissions.Require("finance.create"), accountingExportHandler.CreateLayout)
+	export.Put("/financial/layouts/:id", permissions.Require("finance.create"), accountingExportHandler.UpdateLayout)
+	export.Delete("/financial/layouts/:id", permissions.Require("finance.create"), accountingExportHandler.DeleteLayout)
+	export.Get("/financial", permissions.Require("finance.view"), accountingExportHandler.List)
+	export.Post("/financial", permissions.Require("finance.create"), accountingExportHandler.Create)
+	export.Get("/financial/:id", permissions.Require("finance.view"), accountingExportHandler.Get)
+	export.Get("/financial/:id/download", permissions.Require("finance.view"), accountingExportHandler.Download)
+
 	// ==================== Hierarchy Access Control Routes ====================
 	// Hierarchies
 	hierarchies := protected.Group("/hierarchies")
@@ -275,6 +1074,10 @@ func main() {
 	nodes.Put("/:id", middleware.WriteAccess(), hierarchyHandler.UpdateNode)
 	nodes.Put("/:id/move", middleware.WriteAccess(), hierarchyHandler.MoveNode)
 	nodes.Delete("/:id", middleware.WriteAccess(), hierarchyHandler.DeleteNode)
+	nodes.Get("/branding", brandingHandler.List)
+	nodes.Get("/:id/branding", brandingHandler.Get)
+	nodes.Put("/:id/branding", middleware.AdminOnly(), brandingHandler.Upsert)
+	nodes.Delete("/:id/branding", middleware.AdminOnly(), brandingHandler.Delete)
 	nodes.Get("/:id/members", hierarchyHandler.GetNodeMembers)
 	nodes.Post("/:id/members", middleware.WriteAccess(), hierarchyHandler.AddNodeMember)
 
@@ -294,11 +1097,37 @@ func main() {
 	// Permissions
 	protected.Get("/permissions", hierarchyHandler.GetAllPermissions)
 
+	// Aggregated screens of the logged user
+	me := protected.Group("/me")
+	me.Get("/technician/home", technicianHomeHandler.GetHome)
+	me.Get("/summary", myWorkHandler.GetSummary)
+	// Personal data export and account deletion (LGPD)
+	me.Post("/export", privacyHandler.Export)
+	me.Post("/delete", privacyHandler.RequestDeletion)
+	me.Delete("/delete", privacyHandler.CancelDeletion)
+	me.Get("/privacy-requests", privacyHandler.ListMine)
+
+	// Offline sync of the technician app: status changes made offline, server changes since the cursor
+	protected.Post("/sync/tickets", permissions.Require("tickets.view"), ticketSyncHandler.Sync)
+
+	// Unified audit trail: activity, financial and access logs (?format=csv to export)
+	protected.Get("/audit", middleware.AdminOnly(), auditHandler.Query)
+
+	// GraphQL gateway for dashboards (tickets, technicians, clients, stock balances, financial summaries)
+	protected.Get("/graphql", permissions.Require("tickets.view"), graphqlHandler.Query)
+	protected.Post("/graphql", permissions.Require("tickets.view"), graphqlHandler.Query)
+
 	// Activity logs
 	activityLogs := protected.Group("/activity-logs")
 	activityLogs.Get("/", activityLogHandler.GetActivityLogs)
 	activityLogs.Get("/me", activityLogHandler.GetMyActivityLogs)
 	activityLogs.Get("/recent", activityLogHandler.GetRecentActivityLogs)
+	activityLogs.Get("/verify", middleware.AdminOnly(), auditChainHandler.Verify)
+	activityLogs.Get("/exports", middleware.AdminOnly(), auditChainHandler.ListBundles)
+	activityLogs.Post("/exports/run", middleware.AdminOnly(), auditChainHandler.RunExport)
+	activityLogs.Get("/exports/public-key", middleware.AdminOnly(), auditChainHandler.GetPublicKey)
+	activityLogs.Get("/exports/:id/download", middleware.AdminOnly(), auditChainHandler.DownloadBundle)
+	activityLogs.Get("/exports/:id/verify", middleware.AdminOnly(), auditChainHandler.VerifyBundle)
 	activityLogs.Get("/:id", activityLogHandler.GetActivityLogByID)
 
 	// Access simulation and history
@@ -315,8 +1144,22 @@ func main() {
 	geo.Post("/locations/batch", geoHandler.CreateBatchLocations)
 	// Manager endpoints (view locations)
 	geo.Get("/technicians/last", geoHandler.GetTechniciansLastLocations)
+	geo.Get("/technicians/stream", geoHandler.StreamLocations)
+	geo.Get("/technicians/nearby", geoHandler.GetNearbyTechnicians)
+	geo.Get("/technicians/within", geoHandler.GetTechniciansWithin)
 	geo.Get("/technicians/:id/history", geoHandler.GetTechnicianHistory)
+	geo.Get("/technicians/:id/route-plan", geoHandler.GetRoutePlan)
+	geo.Post("/technicians/:id/route-plan", middleware.AdminOrEmployee(), geoHandler.SaveRoutePlan)
+	geo.Get("/technicians/:id/route-plan/saved", geoHandler.GetSavedRoutePlan)
 	geo.Get("/tickets/:id/locations", geoHandler.GetTicketLocations)
+	// Geofences (entry/exit events on new locations)
+	geo.Get("/fences", geoHandler.ListFences)
+	geo.Post("/fences", middleware.WriteAccess(), geoHandler.CreateFence)
+	geo.Get("/fences/:id", geoHandler.GetFence)
+	geo.Put("/fences/:id", middleware.WriteAccess(), geoHandler.UpdateFence)
+	geo.Delete("/fences/:id", middleware.WriteAccess(), geoHandler.DeleteFence)
+	geo.Get("/fences/:id/technicians", geoHandler.GetFenceTechnicians)
+	geo.Get("/fences/:id/events", geoHandler.GetFenceEvents)
 	// Admin endpoints (settings)
 	geo.Get("/settings", geoHandler.GetGeoSettings)
 	geo.Put("/settings", middleware.WriteAccess(), geoHandler.UpdateGeoSettings)
@@ -329,6 +1172,102 @@ func main() {
 	admin.Get("/security-logs/stats", securityLogHandler.GetSecurityStats)
 	// System metrics (admin only)
 	admin.Get("/system-metrics", adminHandler.GetSystemMetrics)
+	admin.Get("/system-metrics/latency-budgets", middleware.AdminOnly(), adminHandler.GetLatencyBudgets)
+	admin.Get("/system-metrics/payload-sizes", middleware.AdminOnly(), adminHandler.GetPayloadSizes)
+	// Geo backfill from historical ticket addresses (admin only)
+	admin.Post("/geo/backfill", middleware.AdminOnly(), geoHandler.RunBackfill)
+	// Storage usage, quotas and orphan cleanup (admin only)
+	admin.Get("/storage", middleware.AdminOnly(), storageHandler.GetReport)
+	admin.Get("/storage/orphans", middleware.AdminOnly(), storageHandler.GetOrphans)
+	admin.Post("/storage/cleanup", middleware.AdminOnly(), storageHandler.Cleanup)
+	admin.Get("/storage/quotas", middleware.AdminOnly(), storageHandler.ListQuotas)
+	admin.Put("/storage/quotas", middleware.AdminOnly(), storageHandler.UpsertQuota)
+	admin.Delete("/storage/quotas/:id", middleware.AdminOnly(), storageHandler.DeleteQuota)
+	admin.Get("/privacy-requests", middleware.AdminOnly(), privacyHandler.List)
+	admin.Get("/privacy-requests/:id", middleware.AdminOnly(), privacyHandler.GetByID)
+	admin.Post("/privacy-requests/:id/approve", middleware.AdminOnly(), privacyHandler.Approve)
+	admin.Post("/privacy-requests/:id/reject", middleware.AdminOnly(), privacyHandler.Reject)
+	// Partner sandboxes (isolated demo tenants with automatic expiry)
+	admin.Get("/sandboxes", middleware.PlatformAdmin(), sandboxHandler.List)
+	admin.Post("/sandboxes", middleware.PlatformAdmin(), sandboxHandler.Create)
+	admin.Get("/sandboxes/:id", middleware.PlatformAdmin(), sandboxHandler.GetByID)
+	admin.Delete("/sandboxes/:id", middleware.PlatformAdmin(), sandboxHandler.Delete)
+	// Runtime settings and the remediation rules that change them (admin only)
+	admin.Get("/settings", middleware.AdminOnly(), settingsHandler.ListSettings)
+	admin.Get("/settings/remediations", middleware.AdminOnly(), settingsHandler.ListActions)
+	admin.Post("/settings/remediations/run", middleware.AdminOnly(), settingsHandler.RunRemediations)
+	admin.Post("/settings/remediations/:id/revert", middleware.AdminOnly(), settingsHandler.RevertAction)
+	admin.Get("/settings/remediation-rules", middleware.AdminOnly(), settingsHandler.ListRules)
+	admin.Post("/settings/remediation-rules", middleware.AdminOnly(), settingsHandler.CreateRule)
+	admin.Put("/settings/remediation-rules/:id", middleware.AdminOnly(), settingsHandler.UpdateRule)
+	admin.Delete("/settings/remediation-rules/:id", middleware.AdminOnly(), settingsHandler.DeleteRule)
+	admin.Put("/settings/:key", middleware.AdminOnly(), settingsHandler.UpdateSetting)
+
+	// Status page incidents (admin only)
+	admin.Get("/status/incidents", middleware.AdminOnly(), statusHandler.ListIncidents)
+	admin.Post("/status/incidents", middleware.AdminOnly(), statusHandler.CreateIncident)
+	admin.Put("/status/incidents/:id", middleware.AdminOnly(), statusHandler.UpdateIncident)
+	admin.Delete("/status/incidents/:id", middleware.AdminOnly(), statusHandler.DeleteIncident)
+
+	// Postmortem incident timeline and the deploy markers posted by CI (admin only)
+	admin.Get("/incident-timeline", middleware.AdminOnly(), incidentTimelineHandler.GetTimeline)
+	admin.Get("/deploy-markers", middleware.AdminOnly(), incidentTimelineHandler.ListDeploys)
+	admin.Post("/deploy-markers", middleware.AdminOnly(), incidentTimelineHandler.CreateDeploy)
+	admin.Delete("/deploy-markers/:id", middleware.AdminOnly(), incidentTimelineHandler.DeleteDeploy)
+
+	// Webhook subscriptions with payload templates (admin only)
+	admin.Get("/webhooks", middleware.AdminOnly(), webhookHandler.List)
+	admin.Post("/webhooks", middleware.AdminOnly(), webhookHandler.Create)
+	admin.Post("/webhooks/validate-template", middleware.AdminOnly(), webhookHandler.ValidateTemplate)
+	admin.Get("/webhooks/:id", middleware.AdminOnly(), webhookHandler.GetByID)
+	admin.Put("/webhooks/:id", middleware.AdminOnly(), webhookHandler.Update)
+	admin.Delete("/webhooks/:id", middleware.AdminOnly(), webhookHandler.Delete)
+	admin.Post("/webhooks/:id/test", middleware.AdminOnly(), webhookHandler.TestFire)
+	admin.Post("/webhooks/:id/rotate-secret", middleware.AdminOnly(), webhookHandler.RotateSecret)
+	admin.Get("/webhooks/:id/deliveries", middleware.AdminOnly(), webhookHandler.ListDeliveries)
+
+	// Delivery log of the domain events (ticket.created, stock.low...) sent to webhooks
+	admin.Get("/webhook-deliveries", middleware.AdminOnly(), webhookHandler.ListDeliveries)
+	admin.Get("/webhook-deliveries/:deliveryId", middleware.AdminOnly(), webhookHandler.GetDelivery)
+	admin.Post("/webhook-deliveries/:deliveryId/redeliver", middleware.AdminOnly(), webhookHandler.Redeliver)
+
+	// Transactional outbox (ticket closing income and stock consumption), retried by hand once failed
+	admin.Get("/outbox-events", middleware.AdminOnly(), outboxHandler.List)
+	admin.Get("/outbox-events/:id", middleware.AdminOnly(), outboxHandler.Get)
+	admin.Post("/outbox-events/:id/retry", middleware.AdminOnly(), outboxHandler.Retry)
+
+	// API keys of machine integrations (X-API-Key on the ticket and stock routes)
+	admin.Get("/api-keys", middleware.AdminOnly(), apiKeyHandler.List)
+	admin.Get("/api-keys/scopes", middleware.AdminOnly(), apiKeyHandler.Scopes)
+	admin.Post("/api-keys", middleware.AdminOnly(), apiKeyHandler.Create)
+	admin.Get("/api-keys/:id", middleware.AdminOnly(), apiKeyHandler.GetByID)
+	admin.Put("/api-keys/:id", middleware.AdminOnly(), apiKeyHandler.Update)
+	admin.Delete("/api-keys/:id", middleware.AdminOnly(), apiKeyHandler.Revoke)
+
+	// Tenants (organizations), managed by the admins of the default tenant
+	admin.Get("/tenants", middleware.PlatformAdmin(), tenantHandler.List)
+	admin.Post("/tenants", middleware.PlatformAdmin(), tenantHandler.Create)
+	admin.Put("/tenants/:id", middleware.PlatformAdmin(), tenantHandler.Update)
+	admin.Post("/tenants/:id/admins", middleware.PlatformAdmin(), tenantHandler.CreateAdmin)
+
+	// Ticket status workflows per category (admin only)
+	admin.Get("/ticket-workflows", middleware.AdminOnly(), ticketWorkflowHandler.Get)
+	admin.Put("/ticket-workflows", middleware.AdminOnly(), ticketWorkflowHandler.Set)
+	admin.Delete("/ticket-workflows", middleware.AdminOnly(), ticketWorkflowHandler.Reset)
+
+	// Checklist templates per ticket category (admin only)
+	admin.Get("/checklist-templates", middleware.AdminOnly(), checklistHandler.ListTemplates)
+	admin.Put("/checklist-templates", middleware.AdminOnly(), checklistHandler.SetTemplate)
+	admin.Delete("/checklist-templates/:categoryId", middleware.AdminOnly(), checklistHandler.DeleteTemplate)
+
+	// Slack and Teams channels: alert routing per type and node, daily digest (admin only)
+	admin.Get("/chat-channels", middleware.AdminOnly(), chatChannelHandler.List)
+	admin.Post("/chat-channels", middleware.AdminOnly(), chatChannelHandler.Create)
+	admin.Post("/chat-channels/digest", middleware.AdminOnly(), chatChannelHandler.SendDigest)
+	admin.Get("/chat-channels/:id", middleware.AdminOnly(), chatChannelHandler.GetByID)
+	admin.Put("/chat-channels/:id", middleware.AdminOnly(), chatChannelHandler.Update)
+	admin.Delete("/chat-channels/:id", middleware.AdminOnly(), chatChannelHandler.Delete)
+	admin.Post("/chat-channels/:id/test", middleware.AdminOnly(), chatChannelHandler.Test)
 
 	// ==================== Error Logs Routes ====================
 	// Frontend errors (any authenticated user can submit)
@@ -349,17 +1288,46 @@ func main() {
 	// Categories (public info)
 	financial.Get("/categories", financialHandler.GetCategories)
 	// Dashboard and reports
-	financial.Get("/dashboard", financialHandler.GetDashboard)
-	financial.Get("/reports/cash-flow", financialHandler.GetCashFlowReport)
-	financial.Get("/reports/technician-payments", financialHandler.GetTechnicianPaymentsReport)
+	financial.Get("/dashboard", permissions.Require("finance.view"), financialHandler.GetDashboard)
+	financial.Get("/reports/cash-flow", permissions.Require("finance.view"), financialHandler.GetCashFlowReport)
+	financial.Get("/reports/technician-payments", permissions.Require("finance.view"), financialHandler.GetTechnicianPaymentsReport)
+	financial.Get("/reports/budget-vs-actual", permissions.Require("finance.view"), financialHandler.GetBudgetVsActualReport)
+	// Crew payouts for a ticket
+	financial.Post("/tickets/:id/payouts", permissions.RequireOn("finance.create", middleware.TicketNode("id")), financialHandler.CreateTicketPayouts)
 	// Financial entries
 	entries := financial.Group("/entries")
-	entries.Get("/", financialHandler.ListEntries)
-	entries.Get("/:id", financialHandler.GetEntry)
-	entries.Post("/", middleware.WriteAccess(), financialHandler.CreateEntry)
-	entries.Put("/:id", middleware.WriteAccess(), financialHandler.UpdateEntry)
-	entries.Patch("/:id/status", middleware.WriteAccess(), financialHandler.UpdateEntryStatus)
+	entries.Get("/", permissions.Require("finance.view"), financialHandler.ListEntries)
+	entries.Get("/:id", permissions.RequireOn("finance.view", middleware.FinancialEntryNode("id")), financialHandler.GetEntry)
+	entries.Post("/", permissions.Require("finance.create"), financialHandler.CreateEntry)
+	entries.Put("/:id", permissions.RequireOn("finance.create", middleware.FinancialEntryNode("id")), financialHandler.UpdateEntry)
+	entries.Patch("/:id/status", permissions.RequireOn("finance.create", middleware.FinancialEntryNode("id")), financialHandler.UpdateEntryStatus)
 	entries.Delete("/:id", middleware.AdminOnly(), financialHandler.DeleteEntry)
+	// Recurring entries (subscriptions / fixed costs)
+	recurring := financial.Group("/recurring")
+	recurring.Get("/", permissions.Require("finance.view"), financialHandler.ListRecurring)
+	recurring.Get("/upcoming", permissions.Require("finance.view"), financialHandler.UpcomingRecurring)
+	recurring.Get("/:id", permissions.Require("finance.view"), financialHandler.GetRecurring)
+	recurring.Get("/:id/preview", permissions.Require("finance.view"), financialHandler.PreviewRecurring)
+	recurring.Post("/", permissions.Require("finance.create"), financialHandler.CreateRecurring)
+	recurring.Put("/:id", permissions.Require("finance.create"), financialHandler.UpdateRecurring)
+	recurring.Patch("/:id/pause", permissions.Require("finance.create"), financialHandler.PauseRecurring)
+	recurring.Patch("/:id/resume", permissions.Require("finance.create"), financialHandler.ResumeRecurring)
+	recurring.Delete("/:id", middleware.AdminOnly(), financialHandler.DeleteRecurring)
+	// Budgets per category and month
+	budgets := financial.Group("/budgets")
+	budgets.Get("/", permissions.Require("finance.view"), financialHandler.ListBudgets)
+	budgets.Get("/:id", permissions.Require("finance.view"), financialHandler.GetBudget)
+	budgets.Post("/", permissions.Require("finance.create"), financialHandler.CreateBudget)
+	budgets.Put("/:id", permissions.Require("finance.create"), financialHandler.UpdateBudget)
+	budgets.Delete("/:id", middleware.AdminOnly(), financialHandler.DeleteBudget)
+	// Invoices (NFS-e) of income entries, issued in the background through the outbox
+	invoices := financial.Group("/invoices")
+	invoices.Get("/", permissions.Require("finance.view"), invoiceHandler.List)
+	invoices.Get("/:id", permissions.Require("finance.view"), invoiceHandler.Get)
+	invoices.Get("/:id/:document", permissions.Require("finance.view"), invoiceHandler.Document)
+	invoices.Post("/", permissions.Require("finance.create"), invoiceHandler.Create)
+	invoices.Post("/:id/retry", permissions.Require("finance.create"), invoiceHandler.Retry)
+	invoices.Post("/:id/cancel", permissions.Require("finance.create"), invoiceHandler.Cancel)
 	// Payment batches (admin only)
 	batches := financial.Group("/batches", middleware.AdminOnly())
 	batches.Get("/", financialHandler.ListBatches)
@@ -370,9 +1338,10 @@ func main() {
 	batches.Delete("/:id/entries/:entryId", financialHandler.RemoveEntryFromBatch)
 	batches.Patch("/:id/approve", financialHandler.ApproveBatch)
 	batches.Patch("/:id/pay", financialHandler.PayBatch)
+	batches.Get("/:id/receipt.pdf", pdfHandler.BatchReceipt)
 
 	// ==================== Stock Module Routes ====================
-	stockHandler.RegisterRoutes(app, middleware.JWTProtected(cfg.JWTSecret))
+	stockHandler.RegisterRoutes(app, middleware.JWTProtected(cfg.JWTSecret, authService, apiKeyService), writeLimiter)
 
 	// Start server
 	port := cfg.AppPort
@@ -380,10 +1349,84 @@ func main() {
 		port = "8080"
 	}
 
-	log.Printf("🚀 Server starting on port %s", port)
-	log.Printf("📚 API docs: http://localhost:%s/api/v1/health", port)
+	slog.Info("Server starting", "port", port)
+	slog.Info("API docs", "url", "http://localhost:"+port+"/api/v1/health")
+
+	go func() {
+		if err := app.Listen(":" + port); err != nil {
+			logger.Fatal("Failed to start server", "error", err)
+		}
+	}()
 
-	if err := app.Listen(":" + port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	// gRPC sync API of the technician app, on its own port
+	var grpcServer *grpcapi.Server
+	if cfg.GRPCEnabled {
+		grpcServer = grpcapi.NewServer(grpcapi.Config{
+			Port:           cfg.GRPCPort,
+			JWTSecret:      cfg.JWTSecret,
+			MaxMessageSize: cfg.BodyLimitGeoBatch,
+		}, authService, grpcapi.NewTechnicianSyncService(geoService, ticketService, ticketSyncService))
+		if err := grpcServer.Start(); err != nil {
+			logger.Fatal("Failed to start gRPC server", "error", err)
+		}
+		slog.Info("gRPC server starting", "port", cfg.GRPCPort)
+	}
+
+	// Graceful shutdown: stop taking traffic, let the requests in flight finish, stop the
+	// background jobs, then close the database and Redis
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	sig := <-quit
+	slog.Info("Shutting down", "signal", sig.String(), "timeout", cfg.ShutdownTimeout)
+	statusService.Drain()
+	if grpcServer != nil {
+		grpcServer.Drain()
+	}
+	if err := app.ShutdownWithTimeout(cfg.ShutdownTimeout); err != nil {
+		slog.Warn("Requests still in flight at the shutdown timeout", "error", err)
+	}
+	if grpcServer != nil {
+		grpcServer.Stop(cfg.ShutdownTimeout)
+	}
+	// Each Stop waits for the pass its job has in flight, so nothing writes to the
+	// database or Redis once they are closed; jobs still busy at the timeout are abandoned
+	jobsStopped := make(chan struct{})
+	go func() {
+		var wg sync.WaitGroup
+		for _, stop := range []func(){
+			webhookService.Stop, outboxService.Stop, auditChainService.Stop, geocodingService.Stop, geoService.StopCleanup,
+			financialService.Stop, stockService.StopCycleCounts, dispatchService.Stop, storageService.Stop,
+			attachmentService.Stop, clientDocumentService.Stop, privacyService.Stop, sandboxService.Stop,
+			clientSegmentService.Stop, recallCampaignService.Stop, slaService.Stop, archiveService.Stop,
+			teamQueueService.Stop, onCallService.Stop, chatService.Stop, alertService.Stop,
+			remediationService.Stop, reportScheduleService.Stop, requestMetricsService.Stop, satisfactionService.Stop,
+		} {
+			wg.Add(1)
+			go func(stop func()) {
+				defer wg.Done()
+				stop()
+			}(stop)
+		}
+		wg.Wait()
+		close(jobsStopped)
+	}()
+	select {
+	case <-jobsStopped:
+	case <-time.After(cfg.ShutdownTimeout):
+		slog.Warn("Background jobs still running at the shutdown timeout")
+	}
+	if replica != nil {
+		replica.Stop()
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		if err := sqlDB.Close(); err != nil {
+			slog.Warn("Failed to close the database", "error", err)
+		}
+	}
+	if redisClient != nil {
+		if err := redisClient.Close(); err != nil {
+			slog.Warn("Failed to close Redis", "error", err)
+		}
 	}
+	slog.Info("Server stopped")
 }
@@ -55,6 +55,9 @@ func TechnicianLastLocationKey(technicianID string) string {
 
 // SetAllTechniciansGeo armazena todos os técnicos no cache usando Hash
 func (r *RedisClient) SetAllTechniciansGeo(technicians []TechnicianGeoData) error {
+	if r.Degraded() {
+		return ErrCacheDegraded
+	}
 	if len(technicians) == 0 {
 		return nil
 	}
@@ -86,6 +89,9 @@ func (r *RedisClient) SetAllTechniciansGeo(technicians []TechnicianGeoData) erro
 
 // GetAllTechniciansGeo retorna todos os técnicos do cache
 func (r *RedisClient) GetAllTechniciansGeo() ([]TechnicianGeoData, error) {
+	if r.Degraded() {
+		return nil, ErrCacheDegraded
+	}
 	result, err := r.client.HGetAll(r.ctx, AllTechniciansGeoKey).Result()
 	if err != nil {
 		return nil, err
@@ -105,6 +111,9 @@ func (r *RedisClient) GetAllTechniciansGeo() ([]TechnicianGeoData, error) {
 
 // UpdateTechnicianLocation atualiza a localização de um técnico específico no cache
 func (r *RedisClient) UpdateTechnicianLocation(tech TechnicianGeoData) error {
+	if r.Degraded() {
+		return ErrCacheDegraded
+	}
 	jsonValue, err := json.Marshal(tech)
 	if err != nil {
 		return err
@@ -116,6 +125,9 @@ func (r *RedisClient) UpdateTechnicianLocation(tech TechnicianGeoData) error {
 
 // GetTechnicianGeo retorna um técnico específico do cache
 func (r *RedisClient) GetTechnicianGeo(technicianID string) (*TechnicianGeoData, error) {
+	if r.Degraded() {
+		return nil, ErrCacheDegraded
+	}
 	jsonValue, err := r.client.HGet(r.ctx, AllTechniciansGeoKey, technicianID).Result()
 	if err != nil {
 		return nil, err
@@ -140,5 +152,8 @@ func (r *RedisClient) IsGeoCacheLoaded() bool {
 
 // GetGeoCacheCount retorna a quantidade de técnicos no cache
 func (r *RedisClient) GetGeoCacheCount() (int64, error) {
+	if r.Degraded() {
+		return 0, ErrCacheDegraded
+	}
 	return r.client.HLen(r.ctx, AllTechniciansGeoKey).Result()
 }
@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// slidingWindowScript keeps one sorted set entry per hit, scored by its time in ms.
+// Hits older than the window are dropped, then the new hit is added when there is room.
+// Returns {allowed, hits in the window, ms until the oldest hit leaves the window}.
+var slidingWindowScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], 0, now - window)
+local count = redis.call('ZCARD', KEYS[1])
+if count < limit then
+	redis.call('ZADD', KEYS[1], now, ARGV[4])
+	redis.call('PEXPIRE', KEYS[1], window)
+	return {1, count + 1, 0}
+end
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+return {0, count, tonumber(oldest[2]) + window - now}
+`)
+
+// RateLimitHit is the outcome of one request against a sliding window
+type RateLimitHit struct {
+	Allowed    bool
+	Count      int           // hits in the window, this one included when allowed
+	RetryAfter time.Duration // until a slot frees up, when not allowed
+}
+
+// SlidingWindowHit records a hit on the key unless limit hits already happened within
+// the window. The check and the write are atomic, so every instance shares the limit.
+func (r *RedisClient) SlidingWindowHit(key string, limit int, window time.Duration) (*RateLimitHit, error) {
+	if r.Degraded() {
+		return nil, ErrCacheDegraded
+	}
+	now := time.Now().UnixMilli()
+	result, err := slidingWindowScript.Run(r.ctx, r.client, []string{key},
+		now, window.Milliseconds(), limit, fmt.Sprintf("%d-%s", now, uuid.New().String())).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	if len(result) != 3 {
+		return nil, fmt.Errorf("unexpected rate limit script result %v", result)
+	}
+	return &RateLimitHit{
+		Allowed:    result[0] == 1,
+		Count:      int(result[1]),
+		RetryAfter: time.Duration(result[2]) * time.Millisecond,
+	}, nil
+}
@@ -3,15 +3,29 @@ package cache
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"github.com/shigake/tech-iq-back/internal/tenant"
 )
 
+// ErrCacheDegraded is returned by the cache operations while degraded mode is on
+var ErrCacheDegraded = errors.New("cache is in degraded mode")
+
 type RedisClient struct {
 	client *redis.Client
 	ctx    context.Context
+
+	// Prepended to every key, see ForTenant
+	prefix string
+
+	// In degraded mode reads and writes fail fast so callers go straight to the
+	// database instead of waiting on an unhealthy Redis; Ping still reaches it.
+	// Shared by the tenant views of the client.
+	degraded *atomic.Bool
 }
 
 type CacheConfig struct {
@@ -29,27 +43,73 @@ func NewRedisClient(config *CacheConfig) *RedisClient {
 	})
 
 	return &RedisClient{
-		client: rdb,
-		ctx:    context.Background(),
+		client:   rdb,
+		ctx:      context.Background(),
+		degraded: new(atomic.Bool),
+	}
+}
+
+// ForTenant returns a view of the client whose keys (and patterns) are prefixed with the
+// tenant, so tenants never read each other's entries. The default tenant keeps the
+// unprefixed keys cached before multi-tenancy.
+func (r *RedisClient) ForTenant(tenantID string) *RedisClient {
+	if r == nil {
+		return nil
+	}
+	scoped := *r
+	scoped.prefix = tenant.KeyPrefix(tenantID)
+	return &scoped
+}
+
+// ForContext is ForTenant for the tenant carried by ctx; the client itself without one
+func (r *RedisClient) ForContext(ctx context.Context) *RedisClient {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return r
 	}
+	return r.ForTenant(tenantID)
+}
+
+func (r *RedisClient) key(key string) string {
+	return r.prefix + key
 }
 
 func (r *RedisClient) Ping() error {
-	_, err := r.client.Ping(r.ctx).Result()
+	return r.PingContext(r.ctx)
+}
+
+// PingContext is Ping bounded by the context
+func (r *RedisClient) PingContext(ctx context.Context) error {
+	_, err := r.client.Ping(ctx).Result()
 	return err
 }
 
+// SetDegraded turns degraded mode on or off
+func (r *RedisClient) SetDegraded(on bool) {
+	r.degraded.Store(on)
+}
+
+func (r *RedisClient) Degraded() bool {
+	return r.degraded.Load()
+}
+
 func (r *RedisClient) Set(key string, value interface{}, ttl time.Duration) error {
+	if r.Degraded() {
+		return ErrCacheDegraded
+	}
 	jsonValue, err := json.Marshal(value)
 	if err != nil {
 		return err
 	}
 
-	return r.client.Set(r.ctx, key, jsonValue, ttl).Err()
+	return r.client.Set(r.ctx, r.key(key), jsonValue, ttl).Err()
 }
 
 func (r *RedisClient) Get(key string, dest interface{}) error {
-	val, err := r.client.Get(r.ctx, key).Result()
+	if r.Degraded() {
+		return ErrCacheDegraded
+	}
+	val, err := r.client.Get(r.ctx, r.key(key)).Result()
 	if err != nil {
 		return err
 	}
@@ -58,11 +118,17 @@ func (r *RedisClient) Get(key string, dest interface{}) error {
 }
 
 func (r *RedisClient) Delete(key string) error {
-	return r.client.Del(r.ctx, key).Err()
+	if r.Degraded() {
+		return ErrCacheDegraded
+	}
+	return r.client.Del(r.ctx, r.key(key)).Err()
 }
 
 func (r *RedisClient) DeletePattern(pattern string) error {
-	keys, err := r.client.Keys(r.ctx, pattern).Result()
+	if r.Degraded() {
+		return ErrCacheDegraded
+	}
+	keys, err := r.client.Keys(r.ctx, r.key(pattern)).Result()
 	if err != nil {
 		return err
 	}
@@ -75,16 +141,25 @@ func (r *RedisClient) DeletePattern(pattern string) error {
 }
 
 func (r *RedisClient) Exists(key string) (bool, error) {
-	result, err := r.client.Exists(r.ctx, key).Result()
+	if r.Degraded() {
+		return false, ErrCacheDegraded
+	}
+	result, err := r.client.Exists(r.ctx, r.key(key)).Result()
 	return result > 0, err
 }
 
 func (r *RedisClient) SetTTL(key string, ttl time.Duration) error {
-	return r.client.Expire(r.ctx, key, ttl).Err()
+	if r.Degraded() {
+		return ErrCacheDegraded
+	}
+	return r.client.Expire(r.ctx, r.key(key), ttl).Err()
 }
 
 func (r *RedisClient) GetTTL(key string) (time.Duration, error) {
-	return r.client.TTL(r.ctx, key).Result()
+	if r.Degraded() {
+		return 0, ErrCacheDegraded
+	}
+	return r.client.TTL(r.ctx, r.key(key)).Result()
 }
 
 func (r *RedisClient) Close() error {
@@ -93,6 +168,9 @@ func (r *RedisClient) Close() error {
 
 // GetStats returns Redis statistics
 func (r *RedisClient) GetStats() (dbSize int64, hitRate float64, err error) {
+	if r.Degraded() {
+		return 0, 0, ErrCacheDegraded
+	}
 	// Get DB size (number of keys)
 	dbSize, err = r.client.DBSize(r.ctx).Result()
 	if err != nil {
@@ -138,21 +216,47 @@ func TechniciansByStateCacheKey(state string) string {
 	return fmt.Sprintf("technicians:state:%s", state)
 }
 
-func DashboardCacheKey() string {
-	return "dashboard:stats"
+// AllTenants in place of the tenant ID turns the dashboard keys into patterns matching
+// the keys of every tenant
+const AllTenants = "*"
+
+func DashboardCacheKey(tenantID string) string {
+	return "dashboard:stats:" + tenantID
+}
+
+func DashboardChartCacheKey(tenantID string) string {
+	return "dashboard:chart:" + tenantID
+}
+
+func FinancialDashboardCacheKey(tenantID, period, startDate, endDate, clientTag, clientSegment string) string {
+	return fmt.Sprintf("financial:dashboard:%s:%s:%s:%s:tag:%s:segment:%s", tenantID, period, startDate, endDate, clientTag, clientSegment)
+}
+
+// FinancialDashboardPattern matches the financial dashboards cached for the tenant with every filter
+func FinancialDashboardPattern(tenantID string) string {
+	return "financial:dashboard:" + tenantID + ":*"
 }
 
 func ClientsCacheKey(page, size int) string {
 	return fmt.Sprintf("clients:list:page:%d:size:%d", page, size)
 }
 
+func UserPermissionsCacheKey(userID string) string {
+	return fmt.Sprintf("permissions:user:%s", userID)
+}
+
+// UserPermissionsPattern matches the permissions cached for every user
+const UserPermissionsPattern = "permissions:user:*"
+
 // Cache TTL constants
 const (
-	TechnicianListTTL    = 5 * time.Minute   // Lista de técnicos
-	TechnicianDetailTTL  = 10 * time.Minute  // Detalhes do técnico
-	TechnicianSearchTTL  = 2 * time.Minute   // Busca de técnicos
-	TechnicianFilterTTL  = 3 * time.Minute   // Filtros por cidade/estado
-	DashboardTTL         = 1 * time.Minute   // Dashboard stats
-	ClientsTTL           = 5 * time.Minute   // Lista de clientes
-	DefaultTTL           = 10 * time.Minute  // TTL padrão
+	TechnicianListTTL     = 5 * time.Minute  // Lista de técnicos
+	TechnicianDetailTTL   = 10 * time.Minute // Detalhes do técnico
+	TechnicianSearchTTL   = 2 * time.Minute  // Busca de técnicos
+	TechnicianFilterTTL   = 3 * time.Minute  // Filtros por cidade/estado
+	DashboardTTL          = 1 * time.Minute  // Dashboard stats
+	FinancialDashboardTTL = 2 * time.Minute  // Dashboard financeiro por período
+	ClientsTTL            = 5 * time.Minute  // Lista de clientes
+	UserPermissionsTTL    = 10 * time.Minute // Permissões por node do usuário
+	DefaultTTL            = 10 * time.Minute // TTL padrão
 )
\ No newline at end of file
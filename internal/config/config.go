@@ -3,13 +3,18 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/shopspring/decimal"
 )
 
 type Config struct {
 	AppEnv      string
 	AppPort     string
 	AppName     string
+	// Time given to the requests in flight and the background jobs on SIGTERM
+	ShutdownTimeout time.Duration
 	
 	DBHost     string
 	DBPort     string
@@ -17,13 +22,19 @@ type Config struct {
 	DBPassword string
 	DBName     string
 	DBSSLMode  string
+
+	// Read replica serving the report, dashboard and export queries (empty: all on the
+	// primary), checked every DBReplicaCheckInterval to fall back while it is down
+	DBReplicaDSN           string
+	DBReplicaCheckInterval time.Duration
 	
 	JWTSecret           string
 	JWTExpiration       time.Duration
 	JWTRefreshExpiration time.Duration
 	
 	CorsOrigins string
-	LogLevel    string
+	LogLevel    string // debug, info, warn or error
+	LogFormat   string // json or text
 	
 	// Redis Cache Configuration
 	RedisHost     string
@@ -31,6 +42,204 @@ type Config struct {
 	RedisPassword string
 	RedisDB       int
 	CacheEnabled  bool
+
+	// Auto-dispatch background worker
+	AutoDispatchEnabled  bool
+	AutoDispatchInterval time.Duration
+
+	// Ticket attachments
+	UploadDir     string
+	ClamAVAddress string
+
+	// File storage for /files uploads: local (UPLOAD_DIR) or s3 (AWS S3, MinIO)
+	FileStorageBackend string
+	FileMaxSize        int64
+	FileURLTTL         time.Duration
+	S3Endpoint         string
+	S3Region           string
+	S3Bucket           string
+	S3AccessKey        string
+	S3SecretKey        string
+	S3PathStyle        bool
+
+	// Printed service orders
+	CompanyName string
+	TrackingURL string // e.g. https://portal.example.com/tracking/{id}; {id} and {osNumber} are replaced
+
+	// Client tracking links; the portal URL gets {token} replaced, the API route is used when empty
+	TrackingPortalURL string
+	TrackingLinkTTL   time.Duration
+
+	// Multi-tenancy: <slug>.<base domain> serves the tenant with that slug; empty disables it
+	TenantBaseDomain string
+
+	// Outgoing e-mail (client messaging)
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUser     string
+	SMTPPassword string
+	SMTPFrom     string
+
+	// NPS surveys
+	NPSSurveyURL string // public survey page; {token} is replaced
+
+	// Satisfaction surveys sent after tickets are closed
+	SatisfactionSurveysEnabled  bool
+	SatisfactionSurveyInterval  time.Duration
+	SatisfactionSurveyURL       string        // public survey page; {token} is replaced
+	SatisfactionLookback        time.Duration // tickets closed longer ago are never surveyed
+	SatisfactionReminderAfter   time.Duration
+	SatisfactionMaxReminders    int
+	SatisfactionSurveyExpiresIn time.Duration
+
+	// Cycle count scheduler
+	CycleCountEnabled  bool
+	CycleCountInterval time.Duration
+	CycleCountItems    int // tasks generated per location and run
+
+	// Stock transfers above these thresholds need approval (0 disables)
+	TransferApprovalValue    decimal.Decimal
+	TransferApprovalQuantity int
+
+	// Ticket creation for a client site outside every coverage area: off, warn or block
+	CoverageEnforcement string
+
+	// Operational alerts scanner
+	AlertsEnabled            bool
+	AlertsInterval           time.Duration
+	AlertSLARiskPercent      int // share of the SLA target used before a ticket is at risk
+	AlertSLAComplianceTarget int // minimum SLA compliance (%) over 30 days, 0 disables the KPI alert
+	AlertGeoLookback         time.Duration
+
+	// SLA breach flagging
+	SLABreachCheckEnabled  bool
+	SLABreachCheckInterval time.Duration
+
+	// Recurring financial entries scheduler
+	RecurringEntriesEnabled  bool
+	RecurringEntriesInterval time.Duration
+
+	// Retention cleanups (technician locations, request metrics, security and error logs)
+	GeoCleanupEnabled  bool
+	GeoCleanupInterval time.Duration
+	CleanupBatchSize   int
+	CleanupBatchSleep  time.Duration
+
+	// Archival of closed tickets
+	ArchiveEnabled     bool
+	ArchiveInterval    time.Duration
+	ArchiveAfterMonths int
+
+	// Team queue distribution
+	TeamQueueEnabled  bool
+	TeamQueueInterval time.Duration
+
+	// On-call paging (priority dispatch)
+	OnCallEscalationEnabled  bool
+	OnCallEscalationInterval time.Duration
+	SMSGatewayURL            string
+	SMSGatewayToken          string
+	PushGatewayURL           string
+	PushGatewayToken         string
+
+	// Client document vault expiry reminders
+	ClientDocumentRemindersEnabled bool
+	ClientDocumentReminderInterval time.Duration
+
+	// Audit log signed exports
+	AuditExportEnabled  bool
+	AuditExportInterval time.Duration
+	AuditExportDir      string
+	AuditSigningKey     string
+
+	// LGPD account deletions: grace period before anonymization and scheduler interval
+	PrivacyDeletionEnabled  bool
+	PrivacyDeletionGrace    time.Duration
+	PrivacyDeletionInterval time.Duration
+
+	// Partner sandboxes: default lifetime and cleanup interval
+	SandboxTTL             time.Duration
+	SandboxCleanupEnabled  bool
+	SandboxCleanupInterval time.Duration
+
+	// Slack and Teams channels: daily alert digest and its hour (0-23, server time)
+	ChatDigestEnabled bool
+	ChatDigestHour    int
+
+	// Client segments (revenue tier, ticket volume, churn risk) recalculation job
+	ClientSegmentsEnabled  bool
+	ClientSegmentsInterval time.Duration
+
+	// Client address geocoding: provider (local, nominatim or google) and backfill job
+	GeocodingProvider  string
+	GeocodingEnabled   bool
+	GeocodingInterval  time.Duration
+	GeocodingBatchSize int
+	NominatimURL       string
+	GeocodingUserAgent string
+	GoogleMapsAPIKey   string
+
+	// PostGIS spatial column and indexes for the geo radius and map queries (plain SQL otherwise)
+	GeoPostGISEnabled bool
+
+	// Recall campaigns: ticket generation job and how many tickets it opens per run
+	RecallCampaignsEnabled  bool
+	RecallCampaignsInterval time.Duration
+	RecallCampaignBatchSize int
+
+	// Notification channels (DATABASE, EMAIL, WEBHOOK) and the webhook endpoint
+	NotificationChannels     []string
+	NotificationWebhookURL   string
+	NotificationWebhookToken string
+
+	// Delivery queue of the domain events posted to the webhook subscriptions
+	WebhookDeliveryEnabled  bool
+	WebhookDeliveryInterval time.Duration
+
+	// Outbox dispatcher: follow-up work of committed changes (ticket closing income and stock)
+	OutboxEnabled  bool
+	OutboxInterval time.Duration
+
+	// Runbook automations reacting to system alerts
+	RemediationEnabled  bool
+	RemediationInterval time.Duration
+
+	// Scheduled reports: how often the due report schedules are checked
+	ReportSchedulesEnabled  bool
+	ReportSchedulesInterval time.Duration
+
+	// Response compression
+	CompressionEnabled      bool
+	CompressionMinSize      int
+	CompressionContentTypes []string
+
+	// Request body limits per endpoint group, in bytes
+	BodyLimitDefault  int
+	BodyLimitAuth     int
+	BodyLimitGeoBatch int
+	BodyLimitUpload   int
+
+	// Rate limits per caller (API key, user or IP), in Redis sliding windows
+	RateLimitEnabled     bool
+	RateLimitAuthMax     int
+	RateLimitAuthWindow  time.Duration
+	RateLimitWriteMax    int
+	RateLimitWriteWindow time.Duration
+
+	// Query parameter casing mode per API version (compat or strict)
+	QueryCasingModes map[string]string
+
+	// gRPC server of the technician app, on its own port
+	GRPCEnabled bool
+	GRPCPort    string
+
+	// Service invoice (NFS-e) issuance: driver (none, sandbox or gateway), the gateway of the
+	// provider and the municipal service code and ISS rate the invoices are issued with
+	InvoiceDriver       string
+	InvoiceGatewayURL   string
+	InvoiceGatewayToken string
+	InvoiceServiceCode  string
+	InvoiceISSRate      decimal.Decimal
 }
 
 func Load() *Config {
@@ -38,6 +247,7 @@ func Load() *Config {
 		AppEnv:      getEnv("APP_ENV", "development"),
 		AppPort:     getEnv("APP_PORT", "8080"),
 		AppName:     getEnv("APP_NAME", "tech-erp-api"),
+		ShutdownTimeout: parseDuration(getEnv("SHUTDOWN_TIMEOUT", "30s")),
 		
 		DBHost:     getEnv("DB_HOST", "localhost"),
 		DBPort:     getEnv("DB_PORT", "5432"),
@@ -45,6 +255,9 @@ func Load() *Config {
 		DBPassword: getEnv("DB_PASSWORD", "erp123"),
 		DBName:     getEnv("DB_NAME", "tech_erp"),
 		DBSSLMode:  getEnv("DB_SSLMODE", "disable"),
+
+		DBReplicaDSN:           getEnv("DB_REPLICA_DSN", ""),
+		DBReplicaCheckInterval: parseDuration(getEnv("DB_REPLICA_CHECK_INTERVAL", "15s")),
 		
 		JWTSecret:           getEnv("JWT_SECRET", "your-super-secret-key"),
 		JWTExpiration:       parseDuration(getEnv("JWT_EXPIRATION", "8h")),
@@ -52,6 +265,7 @@ func Load() *Config {
 		
 		CorsOrigins: getEnv("CORS_ORIGINS", "*"),
 		LogLevel:    getEnv("LOG_LEVEL", "debug"),
+		LogFormat:   getEnv("LOG_FORMAT", "json"),
 		
 		// Redis Cache Configuration
 		RedisHost:     getEnv("REDIS_HOST", "redis-service"),
@@ -59,6 +273,202 @@ func Load() *Config {
 		RedisPassword: getEnv("REDIS_PASSWORD", ""),
 		RedisDB:       parseInt(getEnv("REDIS_DB", "0")),
 		CacheEnabled:  parseBool(getEnv("CACHE_ENABLED", "true")),
+
+		// Auto-dispatch background worker
+		AutoDispatchEnabled:  parseBool(getEnv("AUTO_DISPATCH_ENABLED", "false")),
+		AutoDispatchInterval: parseDuration(getEnv("AUTO_DISPATCH_INTERVAL", "1m")),
+
+		// Ticket attachments
+		UploadDir:     getEnv("UPLOAD_DIR", "./uploads"),
+		ClamAVAddress: getEnv("CLAMAV_ADDRESS", ""),
+
+		FileStorageBackend: getEnv("FILE_STORAGE_BACKEND", "local"),
+		FileMaxSize:        int64(parseInt(getEnv("FILE_MAX_SIZE", "26214400"))),
+		FileURLTTL:         parseDuration(getEnv("FILE_URL_TTL", "15m")),
+		S3Endpoint:         getEnv("S3_ENDPOINT", ""),
+		S3Region:           getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:           getEnv("S3_BUCKET", ""),
+		S3AccessKey:        getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey:        getEnv("S3_SECRET_KEY", ""),
+		S3PathStyle:        parseBool(getEnv("S3_PATH_STYLE", "true")),
+
+		// Printed service orders
+		CompanyName: getEnv("COMPANY_NAME", "TechERP"),
+		TrackingURL: getEnv("TRACKING_URL", ""),
+
+		// Client tracking links
+		TrackingPortalURL: getEnv("TRACKING_PORTAL_URL", ""),
+		TrackingLinkTTL:   parseDuration(getEnv("TRACKING_LINK_TTL", "720h")),
+
+		// Multi-tenancy
+		TenantBaseDomain: getEnv("TENANT_BASE_DOMAIN", ""),
+
+		// Outgoing e-mail (client messaging)
+		SMTPHost:     getEnv("SMTP_HOST", ""),
+		SMTPPort:     getEnv("SMTP_PORT", "587"),
+		SMTPUser:     getEnv("SMTP_USER", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:     getEnv("SMTP_FROM", ""),
+
+		// NPS surveys
+		NPSSurveyURL: getEnv("NPS_SURVEY_URL", "http://localhost:3000/nps/{token}"),
+
+		// Satisfaction surveys
+		SatisfactionSurveysEnabled:  parseBool(getEnv("SATISFACTION_SURVEYS_ENABLED", "true")),
+		SatisfactionSurveyInterval:  parseDuration(getEnv("SATISFACTION_SURVEY_INTERVAL", "15m")),
+		SatisfactionSurveyURL:       getEnv("SATISFACTION_SURVEY_URL", "http://localhost:3000/satisfaction/{token}"),
+		SatisfactionLookback:        parseDuration(getEnv("SATISFACTION_LOOKBACK", "168h")),
+		SatisfactionReminderAfter:   parseDuration(getEnv("SATISFACTION_REMINDER_AFTER", "72h")),
+		SatisfactionMaxReminders:    parseInt(getEnv("SATISFACTION_MAX_REMINDERS", "2")),
+		SatisfactionSurveyExpiresIn: parseDuration(getEnv("SATISFACTION_SURVEY_EXPIRES_IN", "720h")),
+
+		// Cycle count scheduler
+		CycleCountEnabled:  parseBool(getEnv("CYCLE_COUNT_ENABLED", "true")),
+		CycleCountInterval: parseDuration(getEnv("CYCLE_COUNT_INTERVAL", "24h")),
+		CycleCountItems:    parseInt(getEnv("CYCLE_COUNT_ITEMS", "10")),
+
+		// Stock transfer approval
+		TransferApprovalValue:    parseDecimal(getEnv("STOCK_TRANSFER_APPROVAL_VALUE", "0")),
+		TransferApprovalQuantity: parseInt(getEnv("STOCK_TRANSFER_APPROVAL_QUANTITY", "0")),
+
+		// Coverage areas
+		CoverageEnforcement: getEnv("COVERAGE_ENFORCEMENT", "warn"),
+
+		// Operational alerts
+		AlertsEnabled:            parseBool(getEnv("ALERTS_ENABLED", "true")),
+		AlertsInterval:           parseDuration(getEnv("ALERTS_INTERVAL", "5m")),
+		AlertSLARiskPercent:      parseInt(getEnv("ALERT_SLA_RISK_PERCENT", "80")),
+		AlertSLAComplianceTarget: parseInt(getEnv("ALERT_SLA_COMPLIANCE_TARGET", "90")),
+		AlertGeoLookback:         parseDuration(getEnv("ALERT_GEO_LOOKBACK", "24h")),
+
+		// SLA breach flagging (response and resolution targets of the SLA policies)
+		SLABreachCheckEnabled:  parseBool(getEnv("SLA_BREACH_CHECK_ENABLED", "true")),
+		SLABreachCheckInterval: parseDuration(getEnv("SLA_BREACH_CHECK_INTERVAL", "5m")),
+
+		// Recurring financial entries (subscriptions and fixed costs)
+		RecurringEntriesEnabled:  parseBool(getEnv("RECURRING_ENTRIES_ENABLED", "true")),
+		RecurringEntriesInterval: parseDuration(getEnv("RECURRING_ENTRIES_INTERVAL", "1h")),
+
+		// Retention cleanups, deleted in batches with a pause between them
+		GeoCleanupEnabled:  parseBool(getEnv("GEO_CLEANUP_ENABLED", "true")),
+		GeoCleanupInterval: parseDuration(getEnv("GEO_CLEANUP_INTERVAL", "24h")),
+		CleanupBatchSize:   parseInt(getEnv("CLEANUP_BATCH_SIZE", "5000")),
+		CleanupBatchSleep:  parseDuration(getEnv("CLEANUP_BATCH_SLEEP", "100ms")),
+
+		// Ticket archival
+		ArchiveEnabled:     parseBool(getEnv("ARCHIVE_ENABLED", "true")),
+		ArchiveInterval:    parseDuration(getEnv("ARCHIVE_INTERVAL", "24h")),
+		ArchiveAfterMonths: parseInt(getEnv("ARCHIVE_AFTER_MONTHS", "12")),
+
+		// Team queue distribution
+		TeamQueueEnabled:  parseBool(getEnv("TEAM_QUEUE_ENABLED", "true")),
+		TeamQueueInterval: parseDuration(getEnv("TEAM_QUEUE_INTERVAL", "1m")),
+
+		// On-call paging
+		OnCallEscalationEnabled:  parseBool(getEnv("ONCALL_ESCALATION_ENABLED", "true")),
+		OnCallEscalationInterval: parseDuration(getEnv("ONCALL_ESCALATION_INTERVAL", "1m")),
+		SMSGatewayURL:            getEnv("SMS_GATEWAY_URL", ""),
+		SMSGatewayToken:          getEnv("SMS_GATEWAY_TOKEN", ""),
+		PushGatewayURL:           getEnv("PUSH_GATEWAY_URL", ""),
+		PushGatewayToken:         getEnv("PUSH_GATEWAY_TOKEN", ""),
+
+		// Client document vault
+		ClientDocumentRemindersEnabled: parseBool(getEnv("CLIENT_DOCUMENT_REMINDERS_ENABLED", "true")),
+		ClientDocumentReminderInterval: parseDuration(getEnv("CLIENT_DOCUMENT_REMINDER_INTERVAL", "6h")),
+
+		// Audit log signed exports (Ed25519 seed, hex or base64)
+		AuditExportEnabled:  parseBool(getEnv("AUDIT_EXPORT_ENABLED", "true")),
+		AuditExportInterval: parseDuration(getEnv("AUDIT_EXPORT_INTERVAL", "1h")),
+		AuditExportDir:      getEnv("AUDIT_EXPORT_DIR", "./audit-exports"),
+		AuditSigningKey:     getEnv("AUDIT_SIGNING_KEY", ""),
+
+		// LGPD account deletions (anonymized once the grace period ends)
+		PrivacyDeletionEnabled:  parseBool(getEnv("PRIVACY_DELETION_ENABLED", "true")),
+		PrivacyDeletionGrace:    parseDuration(getEnv("PRIVACY_DELETION_GRACE", "720h")),
+		PrivacyDeletionInterval: parseDuration(getEnv("PRIVACY_DELETION_INTERVAL", "1h")),
+
+		// Partner sandboxes (demo data removed once they expire)
+		SandboxTTL:             parseDuration(getEnv("SANDBOX_TTL", "72h")),
+		SandboxCleanupEnabled:  parseBool(getEnv("SANDBOX_CLEANUP_ENABLED", "true")),
+		SandboxCleanupInterval: parseDuration(getEnv("SANDBOX_CLEANUP_INTERVAL", "15m")),
+
+		// Chat channels (alerts are posted as raised, the digest once a day)
+		ChatDigestEnabled: parseBool(getEnv("CHAT_DIGEST_ENABLED", "true")),
+		ChatDigestHour:    parseInt(getEnv("CHAT_DIGEST_HOUR", "8")),
+
+		// Client segments (membership rebuilt from revenue, tickets and NPS answers)
+		ClientSegmentsEnabled:  parseBool(getEnv("CLIENT_SEGMENTS_ENABLED", "true")),
+		ClientSegmentsInterval: parseDuration(getEnv("CLIENT_SEGMENTS_INTERVAL", "24h")),
+
+		// Client geocoding (local uses only the city and state capital tables)
+		GeocodingProvider:  strings.ToLower(getEnv("GEOCODING_PROVIDER", "local")),
+		GeocodingEnabled:   parseBool(getEnv("GEOCODING_ENABLED", "true")),
+		GeocodingInterval:  parseDuration(getEnv("GEOCODING_INTERVAL", "10m")),
+		GeocodingBatchSize: parseInt(getEnv("GEOCODING_BATCH_SIZE", "100")),
+		NominatimURL:       getEnv("NOMINATIM_URL", "https://nominatim.openstreetmap.org"),
+		GeocodingUserAgent: getEnv("GEOCODING_USER_AGENT", "tech-iq-back"),
+		GoogleMapsAPIKey:   getEnv("GOOGLE_MAPS_API_KEY", ""),
+
+		// Needs the postgis extension on the database server
+		GeoPostGISEnabled: parseBool(getEnv("GEO_POSTGIS_ENABLED", "false")),
+
+		// Recall campaigns (tickets generated in batches to not flood the queues)
+		RecallCampaignsEnabled:  parseBool(getEnv("RECALL_CAMPAIGNS_ENABLED", "true")),
+		RecallCampaignsInterval: parseDuration(getEnv("RECALL_CAMPAIGNS_INTERVAL", "1m")),
+		RecallCampaignBatchSize: parseInt(getEnv("RECALL_CAMPAIGN_BATCH_SIZE", "50")),
+
+		// Notifications (in-app inbox, e-mail through SMTP, JSON webhook)
+		NotificationChannels:     parseList(getEnv("NOTIFICATION_CHANNELS", "DATABASE,EMAIL")),
+		NotificationWebhookURL:   getEnv("NOTIFICATION_WEBHOOK_URL", ""),
+		NotificationWebhookToken: getEnv("NOTIFICATION_WEBHOOK_TOKEN", ""),
+
+		// Webhook deliveries (queued domain events, retried with exponential backoff)
+		WebhookDeliveryEnabled:  parseBool(getEnv("WEBHOOK_DELIVERY_ENABLED", "true")),
+		WebhookDeliveryInterval: parseDuration(getEnv("WEBHOOK_DELIVERY_INTERVAL", "10s")),
+
+		// Outbox dispatcher (retried with exponential backoff)
+		OutboxEnabled:  parseBool(getEnv("OUTBOX_ENABLED", "true")),
+		OutboxInterval: parseDuration(getEnv("OUTBOX_INTERVAL", "5s")),
+
+		// Runbook automations (remediation rules on active alerts)
+		RemediationEnabled:  parseBool(getEnv("REMEDIATION_ENABLED", "true")),
+		RemediationInterval: parseDuration(getEnv("REMEDIATION_INTERVAL", "1m")),
+
+		// Scheduled reports (e-mailed with the CSV attached, on the cron of each schedule)
+		ReportSchedulesEnabled:  parseBool(getEnv("REPORT_SCHEDULES_ENABLED", "true")),
+		ReportSchedulesInterval: parseDuration(getEnv("REPORT_SCHEDULES_INTERVAL", "1m")),
+
+		// Response compression (brotli or gzip, as the client accepts)
+		CompressionEnabled:      parseBool(getEnv("COMPRESSION_ENABLED", "true")),
+		CompressionMinSize:      parseInt(getEnv("COMPRESSION_MIN_SIZE", "1024")),
+		CompressionContentTypes: parseList(getEnv("COMPRESSION_CONTENT_TYPES", "application/json,text/plain,text/csv,text/html,text/calendar,application/xml")),
+
+		// Request body limits (the upload limit is also the hard cap of the server)
+		BodyLimitDefault:  parseInt(getEnv("BODY_LIMIT_DEFAULT", "2097152")),
+		BodyLimitAuth:     parseInt(getEnv("BODY_LIMIT_AUTH", "16384")),
+		BodyLimitGeoBatch: parseInt(getEnv("BODY_LIMIT_GEO_BATCH", "5242880")),
+		BodyLimitUpload:   parseInt(getEnv("BODY_LIMIT_UPLOAD", "26214400")),
+
+		// Sign-in/sign-up attempts per IP, and writes (POST/PUT/PATCH/DELETE) per caller
+		RateLimitEnabled:     parseBool(getEnv("RATE_LIMIT_ENABLED", "true")),
+		RateLimitAuthMax:     parseInt(getEnv("RATE_LIMIT_AUTH_MAX", "5")),
+		RateLimitAuthWindow:  parseDuration(getEnv("RATE_LIMIT_AUTH_WINDOW", "1m")),
+		RateLimitWriteMax:    parseInt(getEnv("RATE_LIMIT_WRITE_MAX", "120")),
+		RateLimitWriteWindow: parseDuration(getEnv("RATE_LIMIT_WRITE_WINDOW", "1m")),
+
+		// v1 keeps accepting the legacy snake_case query parameters (v1=compat,v2=strict)
+		QueryCasingModes: parseMap(getEnv("QUERY_CASING_MODES", "v1=compat")),
+
+		// gRPC sync API of the technician app (proto/mobile/v1/mobile.proto)
+		GRPCEnabled: parseBool(getEnv("GRPC_ENABLED", "true")),
+		GRPCPort:    getEnv("GRPC_PORT", "9090"),
+
+		// Invoicing (sandbox issues fake invoices for development, gateway posts to the provider)
+		InvoiceDriver:       strings.ToLower(getEnv("INVOICE_DRIVER", "none")),
+		InvoiceGatewayURL:   getEnv("INVOICE_GATEWAY_URL", ""),
+		InvoiceGatewayToken: getEnv("INVOICE_GATEWAY_TOKEN", ""),
+		InvoiceServiceCode:  getEnv("INVOICE_SERVICE_CODE", ""),
+		InvoiceISSRate:      parseDecimal(getEnv("INVOICE_ISS_RATE", "0")),
 	}
 }
 
@@ -85,6 +495,14 @@ func parseInt(s string) int {
 	return i
 }
 
+func parseDecimal(s string) decimal.Decimal {
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		return decimal.Zero
+	}
+	return d
+}
+
 func parseBool(s string) bool {
 	b, err := strconv.ParseBool(s)
 	if err != nil {
@@ -93,6 +511,26 @@ func parseBool(s string) bool {
 	return b
 }
 
+func parseList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func parseMap(s string) map[string]string {
+	items := make(map[string]string)
+	for _, item := range parseList(s) {
+		if key, value, ok := strings.Cut(item, "="); ok {
+			items[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return items
+}
+
 func (c *Config) GetDSN() string {
 	return "host=" + c.DBHost +
 		" user=" + c.DBUser +
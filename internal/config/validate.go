@@ -0,0 +1,319 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Check levels of the validation report
+const (
+	CheckWarning = "WARNING"
+	CheckError   = "ERROR"
+)
+
+// Defaults that must not reach production
+const (
+	defaultJWTSecret  = "your-super-secret-key"
+	defaultDBPassword = "erp123"
+	minJWTSecretBytes = 32
+)
+
+// durationVars are checked for parse errors: parseDuration silently falls back to 8h
+var durationVars = []string{
+	"JWT_EXPIRATION", "JWT_REFRESH_EXPIRATION", "AUTO_DISPATCH_INTERVAL", "FILE_URL_TTL",
+	"CYCLE_COUNT_INTERVAL", "ALERTS_INTERVAL", "ALERT_GEO_LOOKBACK", "SLA_BREACH_CHECK_INTERVAL",
+	"RECURRING_ENTRIES_INTERVAL", "GEO_CLEANUP_INTERVAL", "CLEANUP_BATCH_SLEEP", "ARCHIVE_INTERVAL",
+	"TEAM_QUEUE_INTERVAL", "ONCALL_ESCALATION_INTERVAL", "CLIENT_DOCUMENT_REMINDER_INTERVAL",
+	"AUDIT_EXPORT_INTERVAL", "PRIVACY_DELETION_GRACE", "PRIVACY_DELETION_INTERVAL", "SANDBOX_TTL",
+	"SANDBOX_CLEANUP_INTERVAL", "WEBHOOK_DELIVERY_INTERVAL", "REMEDIATION_INTERVAL",
+	"CLIENT_SEGMENTS_INTERVAL", "GEOCODING_INTERVAL", "RECALL_CAMPAIGNS_INTERVAL",
+	"SHUTDOWN_TIMEOUT", "RATE_LIMIT_AUTH_WINDOW", "RATE_LIMIT_WRITE_WINDOW", "TRACKING_LINK_TTL",
+	"SATISFACTION_SURVEY_INTERVAL", "SATISFACTION_LOOKBACK", "SATISFACTION_REMINDER_AFTER",
+	"SATISFACTION_SURVEY_EXPIRES_IN", "OUTBOX_INTERVAL",
+}
+
+// ConfigCheck is one line of the validation report
+type ConfigCheck struct {
+	Feature string
+	Key     string
+	Level   string
+	Message string
+}
+
+// ValidationReport is the outcome of Validate: the features checked, in order, and the
+// problems found
+type ValidationReport struct {
+	Env      string
+	Features []string
+	Checks   []ConfigCheck
+}
+
+func (r *ValidationReport) check(feature string) {
+	r.Features = append(r.Features, feature)
+}
+
+func (r *ValidationReport) add(feature, key, level, message string) {
+	r.Checks = append(r.Checks, ConfigCheck{Feature: feature, Key: key, Level: level, Message: message})
+}
+
+// Count returns the checks of the level
+func (r *ValidationReport) Count(level string) int {
+	count := 0
+	for _, check := range r.Checks {
+		if check.Level == level {
+			count++
+		}
+	}
+	return count
+}
+
+func (r *ValidationReport) HasErrors() bool {
+	return r.Count(CheckError) > 0
+}
+
+// Log prints the summary and one line per feature checked, or per problem found
+func (r *ValidationReport) Log() {
+	slog.Info("Config validation", "env", r.Env, "features", len(r.Features),
+		"warnings", r.Count(CheckWarning), "errors", r.Count(CheckError))
+	for _, feature := range r.Features {
+		clean := true
+		for _, check := range r.Checks {
+			if check.Feature != feature {
+				continue
+			}
+			clean = false
+			if check.Level == CheckError {
+				slog.Error("Config check failed", "feature", feature, "key", check.Key, "message", check.Message)
+			} else {
+				slog.Warn("Config check warning", "feature", feature, "key", check.Key, "message", check.Message)
+			}
+		}
+		if clean {
+			slog.Info("Config check passed", "feature", feature)
+		}
+	}
+}
+
+// IsProduction reports whether APP_ENV is production; an invalid config refuses to start there
+func (c *Config) IsProduction() bool {
+	env := strings.ToLower(c.AppEnv)
+	return env == "production" || env == "prod"
+}
+
+// Validate checks the settings each enabled feature needs. Problems that would only
+// degrade a development setup are errors in production.
+func (c *Config) Validate() *ValidationReport {
+	report := &ValidationReport{Env: c.AppEnv}
+	prod := c.IsProduction()
+	prodError := CheckWarning
+	if prod {
+		prodError = CheckError
+	}
+
+	// Database
+	report.check("database")
+	for _, v := range []struct{ key, value string }{{"DB_HOST", c.DBHost}, {"DB_NAME", c.DBName}, {"DB_USER", c.DBUser}} {
+		if v.value == "" {
+			report.add("database", v.key, CheckError, "required")
+		}
+	}
+	if c.DBPassword == defaultDBPassword {
+		report.add("database", "DB_PASSWORD", prodError, "default password in use")
+	}
+	if prod && c.DBSSLMode == "disable" {
+		report.add("database", "DB_SSLMODE", CheckWarning, "TLS disabled in production")
+	}
+	if c.DBReplicaDSN != "" && prod && strings.Contains(c.DBReplicaDSN, "sslmode=disable") {
+		report.add("database", "DB_REPLICA_DSN", CheckWarning, "TLS disabled in production")
+	}
+
+	// Authentication
+	report.check("auth")
+	switch {
+	case c.JWTSecret == defaultJWTSecret:
+		report.add("auth", "JWT_SECRET", prodError, "default secret in use")
+	case len(c.JWTSecret) < minJWTSecretBytes:
+		report.add("auth", "JWT_SECRET", prodError, fmt.Sprintf("must be at least %d bytes, got %d", minJWTSecretBytes, len(c.JWTSecret)))
+	}
+	if c.JWTRefreshExpiration <= c.JWTExpiration {
+		report.add("auth", "JWT_REFRESH_EXPIRATION", CheckError, "must be longer than JWT_EXPIRATION")
+	}
+	report.check("http")
+	if prod && c.CorsOrigins == "*" {
+		report.add("http", "CORS_ORIGINS", CheckWarning, "any origin allowed in production")
+	}
+	if c.GRPCEnabled {
+		report.check("grpc")
+		if _, err := strconv.Atoi(c.GRPCPort); err != nil {
+			report.add("grpc", "GRPC_PORT", CheckError, "must be a port number")
+		} else if c.GRPCPort == c.AppPort {
+			report.add("grpc", "GRPC_PORT", CheckError, "must differ from APP_PORT")
+		}
+	}
+	report.check("logging")
+	switch strings.ToLower(c.LogLevel) {
+	case "debug", "info", "warn", "error":
+	default:
+		report.add("logging", "LOG_LEVEL", CheckWarning, "expected debug, info, warn or error, using info")
+	}
+	if c.LogFormat != "json" && c.LogFormat != "text" {
+		report.add("logging", "LOG_FORMAT", CheckWarning, "expected json or text, using json")
+	}
+
+	// Redis (response cache, geo cache, permissions cache)
+	if c.CacheEnabled {
+		report.check("cache")
+		if c.RedisHost == "" {
+			report.add("cache", "REDIS_HOST", CheckError, "required when CACHE_ENABLED")
+		}
+		if _, err := strconv.Atoi(c.RedisPort); err != nil {
+			report.add("cache", "REDIS_PORT", CheckError, "must be a port number")
+		}
+		if prod && c.RedisPassword == "" {
+			report.add("cache", "REDIS_PASSWORD", CheckWarning, "Redis without a password in production")
+		}
+	}
+
+	// E-mail, used by the EMAIL notification channel
+	if c.hasNotificationChannel("EMAIL") {
+		report.check("email")
+		if c.SMTPHost == "" {
+			report.add("email", "SMTP_HOST", CheckError, "required when NOTIFICATION_CHANNELS has EMAIL")
+		}
+		if c.SMTPFrom == "" {
+			report.add("email", "SMTP_FROM", CheckError, "required when NOTIFICATION_CHANNELS has EMAIL")
+		}
+		if _, err := strconv.Atoi(c.SMTPPort); err != nil {
+			report.add("email", "SMTP_PORT", CheckError, "must be a port number")
+		}
+		if c.SMTPUser != "" && c.SMTPPassword == "" {
+			report.add("email", "SMTP_PASSWORD", CheckWarning, "SMTP_USER set without a password")
+		}
+	}
+	if c.ReportSchedulesEnabled {
+		report.check("reports")
+		if c.SMTPHost == "" || c.SMTPFrom == "" {
+			report.add("reports", "SMTP_HOST", CheckWarning, "scheduled reports are e-mailed; without SMTP_HOST and SMTP_FROM every run fails")
+		}
+	}
+	report.check("notifications")
+	if c.hasNotificationChannel("WEBHOOK") && c.NotificationWebhookURL == "" {
+		report.add("notifications", "NOTIFICATION_WEBHOOK_URL", CheckWarning, "WEBHOOK channel without an endpoint, only subscriptions are posted to")
+	}
+
+	// Attachments and file storage
+	report.check("storage")
+	switch c.FileStorageBackend {
+	case "local":
+		if c.UploadDir == "" {
+			report.add("storage", "UPLOAD_DIR", CheckError, "required for the local backend")
+		} else if err := os.MkdirAll(c.UploadDir, 0o755); err != nil {
+			report.add("storage", "UPLOAD_DIR", CheckError, fmt.Sprintf("not writable: %v", err))
+		}
+	case "s3":
+		for _, v := range []struct{ key, value string }{{"S3_BUCKET", c.S3Bucket}, {"S3_ACCESS_KEY", c.S3AccessKey}, {"S3_SECRET_KEY", c.S3SecretKey}} {
+			if v.value == "" {
+				report.add("storage", v.key, CheckError, "required for the s3 backend")
+			}
+		}
+		if c.S3Region == "" && c.S3Endpoint == "" {
+			report.add("storage", "S3_REGION", CheckError, "S3_REGION or S3_ENDPOINT required for the s3 backend")
+		}
+	default:
+		report.add("storage", "FILE_STORAGE_BACKEND", CheckError, "expected local or s3")
+	}
+	if c.FileMaxSize <= 0 {
+		report.add("storage", "FILE_MAX_SIZE", CheckError, "must be positive")
+	}
+	if prod && c.ClamAVAddress == "" {
+		report.add("storage", "CLAMAV_ADDRESS", CheckWarning, "attachments are not scanned for viruses")
+	}
+
+	// Background jobs and feature settings
+	if c.AuditExportEnabled {
+		report.check("audit")
+		if c.AuditSigningKey == "" {
+			report.add("audit", "AUDIT_SIGNING_KEY", CheckWarning, "signed audit log exports are disabled without it")
+		}
+	}
+	if c.OnCallEscalationEnabled {
+		report.check("on-call")
+		if c.SMSGatewayURL == "" && c.PushGatewayURL == "" {
+			report.add("on-call", "SMS_GATEWAY_URL", CheckWarning, "no SMS or push gateway, pages only reach the in-app inbox")
+		}
+	}
+	report.check("geocoding")
+	switch c.GeocodingProvider {
+	case "local":
+	case "nominatim":
+		if c.GeocodingUserAgent == "" {
+			report.add("geocoding", "GEOCODING_USER_AGENT", CheckError, "required by the Nominatim usage policy")
+		}
+	case "google":
+		if c.GoogleMapsAPIKey == "" {
+			report.add("geocoding", "GOOGLE_MAPS_API_KEY", CheckError, "required when GEOCODING_PROVIDER is google")
+		}
+	default:
+		report.add("geocoding", "GEOCODING_PROVIDER", CheckError, "expected local, nominatim or google")
+	}
+	report.check("invoicing")
+	switch c.InvoiceDriver {
+	case "none":
+	case "sandbox":
+		if prod {
+			report.add("invoicing", "INVOICE_DRIVER", CheckWarning, "sandbox invoices have no fiscal value")
+		}
+	case "gateway":
+		if c.InvoiceGatewayURL == "" {
+			report.add("invoicing", "INVOICE_GATEWAY_URL", CheckError, "required when INVOICE_DRIVER is gateway")
+		}
+		if c.InvoiceGatewayToken == "" {
+			report.add("invoicing", "INVOICE_GATEWAY_TOKEN", CheckError, "required when INVOICE_DRIVER is gateway")
+		}
+		if c.InvoiceServiceCode == "" {
+			report.add("invoicing", "INVOICE_SERVICE_CODE", CheckWarning, "invoices are issued without a municipal service code")
+		}
+	default:
+		report.add("invoicing", "INVOICE_DRIVER", CheckError, "expected none, sandbox or gateway")
+	}
+	if c.InvoiceISSRate.IsNegative() || c.InvoiceISSRate.GreaterThan(decimal.NewFromInt(100)) {
+		report.add("invoicing", "INVOICE_ISS_RATE", CheckError, "must be a percentage between 0 and 100")
+	}
+	report.check("settings")
+	if c.ChatDigestEnabled && (c.ChatDigestHour < 0 || c.ChatDigestHour > 23) {
+		report.add("settings", "CHAT_DIGEST_HOUR", CheckError, "must be between 0 and 23")
+	}
+	switch c.CoverageEnforcement {
+	case "off", "warn", "block":
+	default:
+		report.add("settings", "COVERAGE_ENFORCEMENT", CheckError, "expected off, warn or block")
+	}
+	for version, mode := range c.QueryCasingModes {
+		if mode != "compat" && mode != "strict" {
+			report.add("settings", "QUERY_CASING_MODES", CheckError, fmt.Sprintf("%s: expected compat or strict, got %q", version, mode))
+		}
+	}
+	for _, key := range durationVars {
+		if value := os.Getenv(key); value != "" {
+			if _, err := time.ParseDuration(value); err != nil {
+				report.add("settings", key, CheckError, fmt.Sprintf("invalid duration %q, falling back to 8h", value))
+			}
+		}
+	}
+	return report
+}
+
+func (c *Config) hasNotificationChannel(channel string) bool {
+	for _, name := range c.NotificationChannels {
+		if strings.EqualFold(name, channel) {
+			return true
+		}
+	}
+	return false
+}
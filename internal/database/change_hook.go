@@ -0,0 +1,27 @@
+package database
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// RegisterChangeHook calls onChange with the context and the table of every create,
+// update and delete that changed rows, e.g. to drop the caches computed from the table.
+// Inside a transaction it runs before the commit. Raw SQL is not seen.
+func RegisterChangeHook(db *gorm.DB, name string, onChange func(ctx context.Context, table string)) error {
+	hook := func(tx *gorm.DB) {
+		if tx.Error != nil || tx.RowsAffected == 0 || tx.Statement.Table == "" {
+			return
+		}
+		onChange(tx.Statement.Context, tx.Statement.Table)
+	}
+	callbacks := db.Callback()
+	if err := callbacks.Create().After("gorm:create").Register(name+":create", hook); err != nil {
+		return err
+	}
+	if err := callbacks.Update().After("gorm:update").Register(name+":update", hook); err != nil {
+		return err
+	}
+	return callbacks.Delete().After("gorm:delete").Register(name+":delete", hook)
+}
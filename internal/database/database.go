@@ -1,10 +1,12 @@
 package database
 
 import (
-	"log"
+	"log/slog"
 
 	"github.com/shigake/tech-iq-back/internal/config"
+	applog "github.com/shigake/tech-iq-back/internal/logger"
 	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/tenant"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -14,17 +16,21 @@ import (
 func Connect(cfg *config.Config) (*gorm.DB, error) {
 	dsn := cfg.GetDSN()
 
-	logLevel := logger.Silent
+	// Failed and slow queries are always logged, every query in development
+	logLevel := logger.Warn
 	if cfg.AppEnv == "development" {
 		logLevel = logger.Info
 	}
 
 	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logLevel),
+		Logger: applog.NewGormLogger(logLevel),
 	})
 	if err != nil {
 		return nil, err
 	}
+	if err := RegisterTenantScope(db); err != nil {
+		return nil, err
+	}
 
 	sqlDB, err := db.DB()
 	if err != nil {
@@ -35,20 +41,23 @@ func Connect(cfg *config.Config) (*gorm.DB, error) {
 	sqlDB.SetMaxIdleConns(10)
 	sqlDB.SetMaxOpenConns(100)
 
-	log.Println("✅ Database connected successfully")
+	slog.Info("Database connected successfully")
 	return db, nil
 }
 
-func Migrate(db *gorm.DB) error {
-	log.Println("🔄 Running database migrations...")
-
-	err := db.AutoMigrate(
+// Models lists every persisted model, in migration order
+func Models() []interface{} {
+	return []interface{}{
 		&models.User{},
 		&models.Technician{},
 		&models.Client{},
 		&models.Category{},
 		&models.Ticket{},
 		&models.TicketFile{},
+		&models.TicketTechnician{},
+		&models.TicketComment{},
+		&models.TicketEvent{},
+		&models.StatusIncident{},
 		// Hierarchy Access Control
 		&models.Hierarchy{},
 		&models.Node{},
@@ -65,22 +74,187 @@ func Migrate(db *gorm.DB) error {
 		// Security and Metrics
 		&models.SecurityLog{},
 		&models.RequestMetric{},
+		// Suppliers (referenced by financial entries and stock movements)
+		&models.Supplier{},
 		// Financial Module
 		&models.FinancialEntry{},
 		&models.PaymentBatch{},
 		&models.FinancialAuditLog{},
+		&models.RecurringEntry{},
 		// Stock Module
 		&models.StockItem{},
 		&models.StockLocation{},
 		&models.StockMovement{},
 		&models.StockBalance{},
+		&models.StockItemCompatibility{},
+		&models.StockLevel{},
+		&models.StockCountTask{},
+		&models.StockCount{},
+		&models.StockKit{},
+		&models.StockKitItem{},
+		&models.StockRMA{},
+		&models.StockReservation{},
+		&models.StockSerialUnit{},
+		&models.StockMovementSerial{},
 		// Error Logs
 		&models.ErrorLog{},
-	)
+		// Scheduling
+		&models.SchedulingLink{},
+		&models.SchedulingSettings{},
+		&models.TechnicianCalendarToken{},
+		// Auto-dispatch
+		&models.DispatchRule{},
+		&models.DispatchDecision{},
+		// Storage
+		&models.StorageQuota{},
+		&models.StoredFile{},
+		// LGPD data subject requests
+		&models.PrivacyRequest{},
+		// Complaints (ombudsman)
+		&models.TicketComplaint{},
+		// NPS campaigns
+		&models.NPSCampaign{},
+		&models.NPSInvitation{},
+		// Price lists
+		&models.PriceList{},
+		&models.PriceListEntry{},
+		&models.PriceChange{},
+		// Discounts
+		&models.DiscountLimit{},
+		&models.Discount{},
+		// Ticket cancellations
+		&models.CancellationReason{},
+		&models.TicketCancellation{},
+		// Coverage areas
+		&models.CoverageArea{},
+		// SLA pauses (waiting states)
+		&models.TicketSLAPause{},
+		&models.SLAPolicy{},
+		&models.TicketSLABreach{},
+		// Operational alerts
+		&models.Alert{},
+		// Notifications (in-app inbox)
+		&models.Notification{},
+		// Ticket archive
+		&models.ArchivedTicket{},
+		&models.ArchivedTicketRecord{},
+		// Team queues
+		&models.TeamQueue{},
+		&models.TicketQueueAssignment{},
+		&models.TeamQueueSettings{},
+		// On-call paging
+		&models.OnCallSchedule{},
+		&models.OnCallMember{},
+		&models.OnCallPage{},
+		&models.OnCallPageAttempt{},
+		// Ticket budgets
+		&models.TicketBudget{},
+		&models.TicketBudgetOverride{},
+		// Parts used on tickets
+		&models.TicketPart{},
+		// Financial budgets
+		&models.Budget{},
+		// Invoices of income entries
+		&models.Invoice{},
+		// Accounting layouts and financial exports
+		&models.AccountingLayout{},
+		&models.FinancialExport{},
+		// Scheduled reports and their runs
+		&models.ReportSchedule{},
+		&models.ReportRun{},
+		// Client document vault
+		&models.ClientDocumentCategory{},
+		&models.ClientDocument{},
+		&models.ClientDocumentLink{},
+		// Signed audit log exports
+		&models.AuditExportBundle{},
+		// Runtime settings and remediation rules
+		&models.SystemSetting{},
+		&models.RemediationRule{},
+		&models.RemediationAction{},
+		// Per-node document branding
+		&models.NodeBranding{},
+		// Geofences
+		&models.GeoFence{},
+		&models.GeoFencePresence{},
+		&models.GeoFenceEvent{},
+		// Partner sandboxes
+		&models.SandboxTenant{},
+		&models.SandboxResource{},
+		// Deploy markers (incident timeline)
+		&models.DeployMarker{},
+		// Webhook subscriptions (notification payload templates)
+		&models.WebhookSubscription{},
+		&models.WebhookDelivery{},
+		// Login sessions (rotating refresh tokens)
+		&models.RefreshToken{},
+		// API keys of machine integrations
+		&models.APIKey{},
+		// Slack and Teams channels
+		&models.ChatChannel{},
+		// Gamification (opt-in profiles and badges)
+		&models.GamificationProfile{},
+		&models.GamificationBadge{},
+		// Ticket status workflows
+		&models.TicketStatusTransition{},
+		// Checklists per ticket category
+		&models.ChecklistTemplate{},
+		&models.ChecklistTemplateItem{},
+		&models.TicketChecklistItem{},
+		// Client tags and computed segments
+		&models.ClientTag{}, &models.ClientTagAssignment{}, &models.ClientSegment{},
+		// Technician route plans
+		&models.TechnicianRoutePlan{},
+		// Geocoded addresses cache
+		&models.GeocodeCacheEntry{},
+		// Recall campaigns
+		&models.RecallCampaign{},
+		&models.RecallCampaignTarget{},
+		// Technician working weeks and time off
+		&models.TechnicianSchedule{},
+		&models.TechnicianWorkingHours{},
+		&models.TechnicianTimeOff{},
+		// Satisfaction surveys of closed tickets
+		&models.TicketFeedback{},
+		// Tenants (organizations)
+		&models.Tenant{},
+		// Transactional outbox (follow-up work of committed changes)
+		&models.OutboxEvent{},
+	}
+}
+
+func Migrate(db *gorm.DB) error {
+	slog.Info("Running database migrations...")
+
+	// Ticket crews: ticket_technicians carries role, payout share and check-in
+	if err := db.SetupJoinTable(&models.Ticket{}, "Technicians", &models.TicketTechnician{}); err != nil {
+		return err
+	}
+
+	if err := mergeDuplicateStockBalances(db); err != nil {
+		slog.Warn("Failed to merge duplicate stock balances", "error", err)
+	}
+
+	err := db.AutoMigrate(Models()...)
 	if err != nil {
-		log.Println("⚠️ Migration warning (continuing anyway):", err)
+		slog.Warn("Migration warning, continuing anyway", "error", err)
 		// Continue anyway - tables may already exist
 	}
+	if err := ensureGlobalSchedulingSettingsIndex(db); err != nil {
+		slog.Warn("Failed to index the global scheduling settings", "error", err)
+	}
+	if err := ensureTicketIncomeIndex(db); err != nil {
+		slog.Warn("Failed to index the ticket closing incomes", "error", err)
+	}
+	if err := backfillNodes(db); err != nil {
+		slog.Warn("Failed to place clients and technicians in the nodes of their tickets", "error", err)
+	}
+	if err := ensureTenantColumns(db); err != nil {
+		slog.Warn("Failed to move financial, stock, file, outbox, export and campaign rows to their tenant", "error", err)
+	}
+
+	// Seed the default tenant, owner of the rows created before multi-tenancy
+	SeedDefaultTenant(db)
 
 	// Seed default permissions and roles
 	SeedAccessControl(db)
@@ -91,13 +265,22 @@ func Migrate(db *gorm.DB) error {
 	// Seed default financial categories
 	SeedFinancialCategories(db)
 
-	log.Println("✅ Migrations completed")
+	// Seed default cancellation reasons
+	SeedCancellationReasons(db)
+
+	// Seed default client document categories
+	SeedClientDocumentCategories(db)
+
+	// Seed default remediation rules
+	SeedRemediationRules(db)
+
+	slog.Info("Migrations completed")
 	return nil
 }
 
 // SeedAccessControl creates default permissions and roles
 func SeedAccessControl(db *gorm.DB) {
-	log.Println("🔄 Seeding access control data...")
+	slog.Info("Seeding access control data...")
 
 	// Define default permissions
 	permissions := []models.Permission{
@@ -227,16 +410,16 @@ func SeedAccessControl(db *gorm.DB) {
 		}
 	}
 
-	log.Println("✅ Access control data seeded")
+	slog.Info("Access control data seeded")
 }
 
 // SeedAdminUser creates the default admin user
 func SeedAdminUser(db *gorm.DB) {
-	log.Println("🔄 Checking admin user...")
+	slog.Info("Checking admin user...")
 
 	var existing models.User
 	if db.Where("email = ?", "admin@techerp.com").First(&existing).RowsAffected > 0 {
-		log.Println("✅ Admin user already exists")
+		slog.Info("Admin user already exists")
 		return
 	}
 
@@ -245,7 +428,7 @@ func SeedAdminUser(db *gorm.DB) {
 	// Generate hash at runtime to ensure it's valid
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("admin123"), bcrypt.DefaultCost)
 	if err != nil {
-		log.Printf("⚠️ Failed to hash password: %v", err)
+		slog.Warn("Failed to hash password", "error", err)
 		return
 	}
 
@@ -260,22 +443,22 @@ func SeedAdminUser(db *gorm.DB) {
 	}
 
 	if err := db.Create(&admin).Error; err != nil {
-		log.Printf("⚠️ Failed to create admin user: %v", err)
+		slog.Warn("Failed to create admin user", "error", err)
 		return
 	}
 
-	log.Println("✅ Admin user created (admin@techerp.com / admin123)")
+	slog.Info("Admin user created (admin@techerp.com / admin123)")
 }
 
 // SeedFinancialCategories creates default financial categories
 func SeedFinancialCategories(db *gorm.DB) {
-	log.Println("🔄 Seeding financial categories...")
+	slog.Info("Seeding financial categories...")
 
 	// Check if financial categories already exist
 	var count int64
 	db.Model(&models.Category{}).Where("type IN ?", []string{"finance_income", "finance_expense"}).Count(&count)
 	if count > 0 {
-		log.Println("✅ Financial categories already exist")
+		slog.Info("Financial categories already exist")
 		return
 	}
 
@@ -303,7 +486,7 @@ func SeedFinancialCategories(db *gorm.DB) {
 			SortOrder:   i,
 		}
 		if err := db.Create(&category).Error; err != nil {
-			log.Printf("⚠️ Failed to create income category %s: %v", cat.Name, err)
+			slog.Warn("Failed to create income category", "category", cat.Name, "error", err)
 			continue
 		}
 		// Create subcategories
@@ -345,7 +528,7 @@ func SeedFinancialCategories(db *gorm.DB) {
 			SortOrder:   i,
 		}
 		if err := db.Create(&category).Error; err != nil {
-			log.Printf("⚠️ Failed to create expense category %s: %v", cat.Name, err)
+			slog.Warn("Failed to create expense category", "category", cat.Name, "error", err)
 			continue
 		}
 		// Create subcategories
@@ -361,5 +544,90 @@ func SeedFinancialCategories(db *gorm.DB) {
 		}
 	}
 
-	log.Println("✅ Financial categories seeded")
+	slog.Info("Financial categories seeded")
+}
+
+// SeedDefaultTenant creates the tenant owning the rows created before multi-tenancy
+func SeedDefaultTenant(db *gorm.DB) {
+	var count int64
+	db.Model(&models.Tenant{}).Where("id = ?", tenant.DefaultID).Count(&count)
+	if count > 0 {
+		return
+	}
+
+	defaultTenant := models.Tenant{ID: tenant.DefaultID, Name: "Default", Slug: "default", Active: true}
+	if err := db.Create(&defaultTenant).Error; err != nil {
+		slog.Warn("Failed to create default tenant", "error", err)
+		return
+	}
+
+	slog.Info("Default tenant seeded")
+}
+
+// SeedCancellationReasons creates the built-in ticket cancellation reasons
+func SeedCancellationReasons(db *gorm.DB) {
+	var count int64
+	db.Model(&models.CancellationReason{}).Count(&count)
+	if count > 0 {
+		return
+	}
+
+	reasons := []models.CancellationReason{
+		{Code: models.CancellationClientCancelled, Name: "Cliente cancelou", SortOrder: 0},
+		{Code: models.CancellationNoParts, Name: "Sem peças", SortOrder: 1},
+		{Code: models.CancellationDuplicate, Name: "Chamado duplicado", SortOrder: 2},
+		{Code: models.CancellationOutOfCoverage, Name: "Fora da área de cobertura", SortOrder: 3},
+	}
+	for i := range reasons {
+		reasons[i].Active = true
+		if err := db.Create(&reasons[i]).Error; err != nil {
+			slog.Warn("Failed to create cancellation reason", "code", reasons[i].Code, "error", err)
+		}
+	}
+
+	slog.Info("Cancellation reasons seeded")
+}
+
+func SeedClientDocumentCategories(db *gorm.DB) {
+	var count int64
+	db.Model(&models.ClientDocumentCategory{}).Count(&count)
+	if count > 0 {
+		return
+	}
+
+	categories := []models.ClientDocumentCategory{
+		{Name: "Contrato", Description: "Contratos de prestação de serviço", ReminderDays: 60, DownloadRoles: "ADMIN,EMPLOYEE"},
+		{Name: "Certificado de seguro", Description: "Apólices e certificados de seguro", RequiresExpiry: true, ReminderDays: 30, DownloadRoles: "ADMIN,EMPLOYEE"},
+		{Name: "Autorização de acesso", Description: "Autorizações de acesso ao local do cliente", RequiresExpiry: true, ReminderDays: 15, DownloadRoles: "ADMIN,EMPLOYEE,USER"},
+	}
+	for i := range categories {
+		categories[i].Active = true
+		if err := db.Create(&categories[i]).Error; err != nil {
+			slog.Warn("Failed to create client document category", "category", categories[i].Name, "error", err)
+		}
+	}
+
+	slog.Info("Client document categories seeded")
+}
+
+func SeedRemediationRules(db *gorm.DB) {
+	var count int64
+	db.Model(&models.RemediationRule{}).Count(&count)
+	if count > 0 {
+		return
+	}
+
+	rules := []models.RemediationRule{
+		{Name: "Cache fora do ar: modo degradado", AlertType: models.AlertTypeCacheDown, Setting: models.SettingCacheDegraded, Value: "true", CooldownMinutes: 5},
+		{Name: "Fila de anexos acumulada: mais workers", AlertType: models.AlertTypeJobBacklog, ResourceID: "attachments", Setting: models.SettingAttachmentWorkers, Value: "4", CooldownMinutes: 30},
+	}
+	for i := range rules {
+		rules[i].RevertOnResolve = true
+		rules[i].Enabled = true
+		if err := db.Create(&rules[i]).Error; err != nil {
+			slog.Warn("Failed to create remediation rule", "rule", rules[i].Name, "error", err)
+		}
+	}
+
+	slog.Info("Remediation rules seeded")
 }
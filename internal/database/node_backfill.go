@@ -0,0 +1,34 @@
+package database
+
+import (
+	"gorm.io/gorm"
+)
+
+// nodeBackfillStatements place the clients and technicians left without node, visible to
+// every user, in the hierarchy node all their tickets share. Those whose tickets span
+// several nodes are left for an admin to place.
+var nodeBackfillStatements = []string{
+	`UPDATE clients c SET node_id = t.node_id
+		FROM (SELECT client_id, MIN(node_id) AS node_id FROM tickets
+			WHERE client_id IS NOT NULL AND node_id IS NOT NULL AND deleted_at IS NULL
+			GROUP BY client_id HAVING COUNT(DISTINCT node_id) = 1) t
+		WHERE c.id = t.client_id AND c.node_id IS NULL`,
+	`UPDATE technicians tc SET node_id = t.node_id
+		FROM (SELECT tt.technician_id, MIN(k.node_id) AS node_id FROM ticket_technicians tt
+			JOIN tickets k ON k.id = tt.ticket_id
+			WHERE k.node_id IS NOT NULL AND k.deleted_at IS NULL
+			GROUP BY tt.technician_id HAVING COUNT(DISTINCT k.node_id) = 1) t
+		WHERE tc.id = t.technician_id AND tc.node_id IS NULL`,
+}
+
+// backfillNodes runs after AutoMigrate added the node columns
+func backfillNodes(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range nodeBackfillStatements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
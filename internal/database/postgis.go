@@ -0,0 +1,32 @@
+package database
+
+import (
+	"gorm.io/gorm"
+)
+
+// postGISStatements install the extension and the spatial column and indexes of the
+// technicians' last locations. geom is generated from latitude/longitude, so the
+// writes need no change and the column can't drift from them.
+var postGISStatements = []string{
+	`CREATE EXTENSION IF NOT EXISTS postgis`,
+	`ALTER TABLE technician_last_locations ADD COLUMN IF NOT EXISTS geom geometry(Point, 4326)
+		GENERATED ALWAYS AS (ST_SetSRID(ST_MakePoint(longitude, latitude), 4326)) STORED`,
+	// Bounding-box (map) queries
+	`CREATE INDEX IF NOT EXISTS idx_tech_last_loc_geom ON technician_last_locations USING GIST (geom)`,
+	// Radius queries, measured in meters on the geography
+	`CREATE INDEX IF NOT EXISTS idx_tech_last_loc_geog ON technician_last_locations USING GIST ((geom::geography))`,
+}
+
+// EnablePostGIS prepares the spatial queries of the geo module. It needs the postgis
+// extension available on the server (and the privilege to create it); on error the
+// caller keeps the plain SQL queries.
+func EnablePostGIS(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range postGISStatements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
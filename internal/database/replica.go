@@ -0,0 +1,155 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"log/slog"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// replicaSetting marks the statements the read replica may serve
+const replicaSetting = "database:replica"
+
+// replicaPingTimeout bounds each health check of the replica
+const replicaPingTimeout = 5 * time.Second
+
+// Replica is a GORM scope marking a heavy read (report, dashboard or export) that the
+// read replica can serve. It runs there when a replica is registered, healthy and the
+// statement is not in a transaction; otherwise on the primary. Writes always go to the
+// primary.
+func Replica(db *gorm.DB) *gorm.DB {
+	return db.Set(replicaSetting, true)
+}
+
+// ReplicaSession returns a session of db whose every read is marked with Replica, for the
+// repositories serving reports only
+func ReplicaSession(db *gorm.DB) *gorm.DB {
+	return db.Scopes(Replica).Session(&gorm.Session{})
+}
+
+// ReplicaResolver routes the queries marked with Replica to the read replica while it
+// answers, and back to the primary while it doesn't
+type ReplicaResolver struct {
+	pool    *sql.DB
+	healthy atomic.Bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// RegisterReplica opens the read replica at dsn and routes the Replica queries of db to
+// it. An unreachable replica is not an error: the queries stay on the primary until a
+// health check (Start) finds it up.
+func RegisterReplica(db *gorm.DB, dsn string) (*ReplicaResolver, error) {
+	replica, err := gorm.Open(postgres.Open(dsn), &gorm.Config{DisableAutomaticPing: true})
+	if err != nil {
+		return nil, err
+	}
+	pool, err := replica.DB()
+	if err != nil {
+		return nil, err
+	}
+	pool.SetMaxIdleConns(10)
+	pool.SetMaxOpenConns(100)
+
+	r := &ReplicaResolver{pool: pool}
+	if r.check(); !r.Healthy() {
+		slog.Warn("Read replica unreachable, report queries stay on the primary until it answers")
+	}
+
+	callbacks := db.Callback()
+	if err := callbacks.Query().Before("gorm:query").Register("replica:route_query", r.route); err != nil {
+		return nil, err
+	}
+	if err := callbacks.Row().Before("gorm:row").Register("replica:route_row", r.route); err != nil {
+		return nil, err
+	}
+	if err := callbacks.Query().After("gorm:query").Register("replica:check_query", r.failed); err != nil {
+		return nil, err
+	}
+	if err := callbacks.Row().After("gorm:row").Register("replica:check_row", r.failed); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Healthy tells whether the marked queries currently go to the replica
+func (r *ReplicaResolver) Healthy() bool {
+	return r.healthy.Load()
+}
+
+// route sends the marked statement to the replica
+func (r *ReplicaResolver) route(db *gorm.DB) {
+	if marked, _ := db.Get(replicaSetting); marked != true || !r.healthy.Load() {
+		return
+	}
+	if _, inTx := db.Statement.ConnPool.(gorm.TxCommitter); inTx {
+		return
+	}
+	db.Statement.ConnPool = r.pool
+}
+
+// failed takes the replica out as soon as a query on it loses the connection, rather
+// than at the next health check
+func (r *ReplicaResolver) failed(db *gorm.DB) {
+	if db.Statement.ConnPool != r.pool || db.Error == nil {
+		return
+	}
+	var netErr net.Error
+	if errors.Is(db.Error, driver.ErrBadConn) || errors.As(db.Error, &netErr) {
+		r.setHealthy(false, db.Error)
+	}
+}
+
+func (r *ReplicaResolver) check() {
+	ctx, cancel := context.WithTimeout(context.Background(), replicaPingTimeout)
+	defer cancel()
+	err := r.pool.PingContext(ctx)
+	r.setHealthy(err == nil, err)
+}
+
+func (r *ReplicaResolver) setHealthy(healthy bool, err error) {
+	if r.healthy.Swap(healthy) == healthy {
+		return
+	}
+	if healthy {
+		slog.Info("Read replica available, routing report queries to it")
+	} else {
+		slog.Warn("Read replica unavailable, routing report queries to the primary", "error", err)
+	}
+}
+
+// Start checks the replica every interval, bringing it back once it answers
+func (r *ReplicaResolver) Start(interval time.Duration) {
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.check()
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the health checks, waiting for the one in flight, and closes the replica
+// connections
+func (r *ReplicaResolver) Stop() {
+	if r.stop != nil {
+		close(r.stop)
+		<-r.done
+	}
+	r.pool.Close()
+}
@@ -0,0 +1,29 @@
+package database
+
+import (
+	"gorm.io/gorm"
+)
+
+// globalSchedulingSettingsStatements keep a single global (NULL node) scheduling settings
+// row: the unique index on node_id lets any number of NULLs through, so the global row
+// gets its own index over the constant (node_id IS NULL). Duplicates left before it
+// existed are dropped, keeping the last updated.
+var globalSchedulingSettingsStatements = []string{
+	`DELETE FROM scheduling_settings s USING scheduling_settings k
+		WHERE s.node_id IS NULL AND k.node_id IS NULL
+			AND (s.updated_at, s.id) < (k.updated_at, k.id)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_scheduling_settings_global
+		ON scheduling_settings ((node_id IS NULL)) WHERE node_id IS NULL`,
+}
+
+// ensureGlobalSchedulingSettingsIndex runs after AutoMigrate created scheduling_settings
+func ensureGlobalSchedulingSettingsIndex(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range globalSchedulingSettingsStatements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,32 @@
+package database
+
+import (
+	"gorm.io/gorm"
+)
+
+// mergeStockBalanceStatements fold the duplicate balances of an item and location, left
+// by concurrent entries before the unique index existed, into the first one, so the index
+// can be created
+var mergeStockBalanceStatements = []string{
+	`UPDATE stock_balances b SET quantity = d.total
+		FROM (SELECT MIN(id::text) AS keep, SUM(quantity) AS total FROM stock_balances
+			GROUP BY item_id, location_id HAVING COUNT(*) > 1) d
+		WHERE b.id::text = d.keep`,
+	`DELETE FROM stock_balances b USING stock_balances k
+		WHERE b.item_id = k.item_id AND b.location_id = k.location_id AND b.id::text > k.id::text`,
+}
+
+// mergeDuplicateStockBalances runs before AutoMigrate adds idx_stock_balance_item_location
+func mergeDuplicateStockBalances(db *gorm.DB) error {
+	if !db.Migrator().HasTable("stock_balances") {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range mergeStockBalanceStatements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
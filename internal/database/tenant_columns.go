@@ -0,0 +1,47 @@
+package database
+
+import (
+	"gorm.io/gorm"
+)
+
+// tenantColumnStatements move the financial, stock, file, outbox, export and campaign rows
+// tied to a ticket, technician, layout or user of another tenant, created before those
+// tables had a tenant_id and left in the default tenant, to the tenant they belong to. The unique indexes
+// of budgets and SKUs are now per tenant, so the global ones are dropped.
+var tenantColumnStatements = []string{
+	`DROP INDEX IF EXISTS idx_financial_budgets_category_month`,
+	`DROP INDEX IF EXISTS idx_stock_items_sku`,
+	`UPDATE financial_entries e SET tenant_id = t.tenant_id
+		FROM tickets t
+		WHERE e.ticket_id = t.id AND e.tenant_id <> t.tenant_id`,
+	`UPDATE stock_movements m SET tenant_id = t.tenant_id
+		FROM tickets t
+		WHERE m.ticket_id = t.id AND m.tenant_id <> t.tenant_id`,
+	`UPDATE stock_locations l SET tenant_id = tc.tenant_id
+		FROM technicians tc
+		WHERE l.technician_id = tc.id AND l.tenant_id <> tc.tenant_id`,
+	`UPDATE stored_files f SET tenant_id = t.tenant_id
+		FROM tickets t
+		WHERE f.owner_type = 'TICKET' AND f.owner_id = t.id::text AND f.tenant_id <> t.tenant_id`,
+	`UPDATE outbox_events o SET tenant_id = t.tenant_id
+		FROM tickets t
+		WHERE o.aggregate_type = 'ticket' AND o.aggregate_id = t.id::text AND o.tenant_id <> t.tenant_id`,
+	`UPDATE financial_exports e SET tenant_id = l.tenant_id
+		FROM accounting_layouts l
+		WHERE e.layout_id = l.id AND e.tenant_id <> l.tenant_id`,
+	`UPDATE recall_campaigns c SET tenant_id = u.tenant_id
+		FROM users u
+		WHERE c.created_by = u.id AND c.tenant_id <> u.tenant_id`,
+}
+
+// ensureTenantColumns runs after AutoMigrate added the tenant columns
+func ensureTenantColumns(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range tenantColumnStatements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
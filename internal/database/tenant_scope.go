@@ -0,0 +1,86 @@
+package database
+
+import (
+	"reflect"
+
+	"github.com/shigake/tech-iq-back/internal/tenant"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// tenantField is the field marking a model as owned by a tenant
+const tenantField = "TenantID"
+
+// RegisterTenantScope keeps the statements on tenant-owned models (those with a TenantID
+// field) inside the tenant of their context: queries, updates and deletes get a
+// tenant_id condition and created rows get the tenant. Rows created without a tenant in
+// the context go to the default tenant, whatever TenantID they were given, so a request
+// body can't place rows in another tenant. Raw SQL is not rewritten.
+func RegisterTenantScope(db *gorm.DB) error {
+	callbacks := db.Callback()
+	if err := callbacks.Create().Before("gorm:create").Register("tenant:assign", assignTenant); err != nil {
+		return err
+	}
+	if err := callbacks.Query().Before("gorm:query").Register("tenant:scope_query", scopeTenant); err != nil {
+		return err
+	}
+	if err := callbacks.Row().Before("gorm:row").Register("tenant:scope_row", scopeTenant); err != nil {
+		return err
+	}
+	if err := callbacks.Update().Before("gorm:update").Register("tenant:scope_update", scopeTenant); err != nil {
+		return err
+	}
+	return callbacks.Delete().Before("gorm:delete").Register("tenant:scope_delete", scopeTenant)
+}
+
+func scopeTenant(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return
+	}
+	tenantID, ok := tenant.FromContext(db.Statement.Context)
+	if !ok {
+		return
+	}
+	field := db.Statement.Schema.LookUpField(tenantField)
+	if field == nil || field.DBName == "" {
+		return
+	}
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: tenantID},
+	}})
+}
+
+func assignTenant(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return
+	}
+	field := db.Statement.Schema.LookUpField(tenantField)
+	if field == nil {
+		return
+	}
+	tenantID, ok := tenant.FromContext(db.Statement.Context)
+	if !ok {
+		tenantID = tenant.DefaultID
+	}
+
+	value := db.Statement.ReflectValue
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			setTenant(db, field, value.Index(i), tenantID)
+		}
+	case reflect.Struct:
+		setTenant(db, field, value, tenantID)
+	}
+}
+
+func setTenant(db *gorm.DB, field *schema.Field, row reflect.Value, tenantID string) {
+	row = reflect.Indirect(row)
+	if row.Kind() != reflect.Struct {
+		return
+	}
+	if err := field.Set(db.Statement.Context, row, tenantID); err != nil {
+		db.AddError(err)
+	}
+}
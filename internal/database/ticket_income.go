@@ -0,0 +1,35 @@
+package database
+
+import (
+	"gorm.io/gorm"
+)
+
+// ticketIncomeStatements give a ticket at most one live closing income (os_completion),
+// so the outbox handler can't record it twice when it runs concurrently. Duplicates left
+// before the index existed are cancelled, keeping the first recorded.
+var ticketIncomeStatements = []string{
+	`UPDATE financial_entries e SET status = 'cancelled', updated_at = NOW()
+		FROM financial_entries k
+		WHERE e.ticket_id = k.ticket_id
+			AND e.type = 'income' AND e.category = 'service' AND e.subcategory = 'os_completion'
+			AND k.type = 'income' AND k.category = 'service' AND k.subcategory = 'os_completion'
+			AND e.status <> 'cancelled' AND k.status <> 'cancelled'
+			AND e.deleted_at IS NULL AND k.deleted_at IS NULL
+			AND (e.created_at, e.id) > (k.created_at, k.id)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_financial_entries_ticket_income
+		ON financial_entries (ticket_id)
+		WHERE type = 'income' AND category = 'service' AND subcategory = 'os_completion'
+			AND status <> 'cancelled' AND deleted_at IS NULL`,
+}
+
+// ensureTicketIncomeIndex runs after AutoMigrate created financial_entries
+func ensureTicketIncomeIndex(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range ticketIncomeStatements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,30 @@
+package graph
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/shopspring/decimal"
+)
+
+// MarshalDecimal writes decimals as strings, like the REST API, so they keep their precision
+func MarshalDecimal(d decimal.Decimal) graphql.Marshaler {
+	return graphql.WriterFunc(func(w io.Writer) {
+		io.WriteString(w, strconv.Quote(d.String()))
+	})
+}
+
+func UnmarshalDecimal(v interface{}) (decimal.Decimal, error) {
+	switch v := v.(type) {
+	case string:
+		return decimal.NewFromString(v)
+	case int64:
+		return decimal.NewFromInt(v), nil
+	case float64:
+		return decimal.NewFromFloat(v), nil
+	default:
+		return decimal.Zero, fmt.Errorf("%T is not a decimal", v)
+	}
+}
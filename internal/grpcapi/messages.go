@@ -0,0 +1,506 @@
+package grpcapi
+
+import "time"
+
+// Messages of proto/mobile/v1/mobile.proto; field numbers must match the .proto file
+
+type Location struct {
+	LocalID    string
+	TicketID   string
+	EventType  string
+	Latitude   float64
+	Longitude  float64
+	AccuracyM  *float64
+	AltitudeM  *float64
+	SpeedMps   *float64
+	HeadingDeg *float64
+	Provider   string
+	DeviceTime *time.Time
+	IsMocked   bool
+}
+
+func (m *Location) marshal(b []byte) []byte {
+	b = appendString(b, 1, m.LocalID)
+	b = appendString(b, 2, m.TicketID)
+	b = appendString(b, 3, m.EventType)
+	b = appendDouble(b, 4, m.Latitude)
+	b = appendDouble(b, 5, m.Longitude)
+	b = appendOptionalDouble(b, 6, m.AccuracyM)
+	b = appendOptionalDouble(b, 7, m.AltitudeM)
+	b = appendOptionalDouble(b, 8, m.SpeedMps)
+	b = appendOptionalDouble(b, 9, m.HeadingDeg)
+	b = appendString(b, 10, m.Provider)
+	b = appendTime(b, 11, m.DeviceTime)
+	return appendBool(b, 12, m.IsMocked)
+}
+
+func (m *Location) unmarshal(b []byte) error {
+	d := decoder{b: b}
+	for d.next() {
+		switch d.num {
+		case 1:
+			m.LocalID = d.string()
+		case 2:
+			m.TicketID = d.string()
+		case 3:
+			m.EventType = d.string()
+		case 4:
+			m.Latitude = d.double()
+		case 5:
+			m.Longitude = d.double()
+		case 6:
+			m.AccuracyM = d.optionalDouble()
+		case 7:
+			m.AltitudeM = d.optionalDouble()
+		case 8:
+			m.SpeedMps = d.optionalDouble()
+		case 9:
+			m.HeadingDeg = d.optionalDouble()
+		case 10:
+			m.Provider = d.string()
+		case 11:
+			m.DeviceTime = d.time()
+		case 12:
+			m.IsMocked = d.bool()
+		default:
+			d.skip()
+		}
+	}
+	return d.err
+}
+
+type IngestLocationsRequest struct {
+	Locations []Location
+}
+
+func (m *IngestLocationsRequest) marshal(b []byte) []byte {
+	for i := range m.Locations {
+		b = appendMessage(b, 1, &m.Locations[i])
+	}
+	return b
+}
+
+func (m *IngestLocationsRequest) unmarshal(b []byte) error {
+	d := decoder{b: b}
+	for d.next() {
+		switch d.num {
+		case 1:
+			var location Location
+			d.message(&location)
+			m.Locations = append(m.Locations, location)
+		default:
+			d.skip()
+		}
+	}
+	return d.err
+}
+
+type LocationResult struct {
+	LocalID  string
+	ServerID string
+	Status   string
+	Error    string
+}
+
+func (m *LocationResult) marshal(b []byte) []byte {
+	b = appendString(b, 1, m.LocalID)
+	b = appendString(b, 2, m.ServerID)
+	b = appendString(b, 3, m.Status)
+	return appendString(b, 4, m.Error)
+}
+
+func (m *LocationResult) unmarshal(b []byte) error {
+	d := decoder{b: b}
+	for d.next() {
+		switch d.num {
+		case 1:
+			m.LocalID = d.string()
+		case 2:
+			m.ServerID = d.string()
+		case 3:
+			m.Status = d.string()
+		case 4:
+			m.Error = d.string()
+		default:
+			d.skip()
+		}
+	}
+	return d.err
+}
+
+type IngestLocationsResponse struct {
+	Processed int32
+	Results   []LocationResult
+}
+
+func (m *IngestLocationsResponse) marshal(b []byte) []byte {
+	b = appendInt32(b, 1, m.Processed)
+	for i := range m.Results {
+		b = appendMessage(b, 2, &m.Results[i])
+	}
+	return b
+}
+
+func (m *IngestLocationsResponse) unmarshal(b []byte) error {
+	d := decoder{b: b}
+	for d.next() {
+		switch d.num {
+		case 1:
+			m.Processed = d.int32()
+		case 2:
+			var result LocationResult
+			d.message(&result)
+			m.Results = append(m.Results, result)
+		default:
+			d.skip()
+		}
+	}
+	return d.err
+}
+
+type Ticket struct {
+	ID               string
+	OSNumber         string
+	Status           string
+	Type             string
+	Priority         string
+	ErrorDescription string
+	ClientName       string
+	CategoryName     string
+	LeadTechnicianID string
+	DueDate          *time.Time
+	ScheduledStart   *time.Time
+	ScheduledEnd     *time.Time
+	Version          int32
+	CreatedAt        *time.Time
+}
+
+func (m *Ticket) marshal(b []byte) []byte {
+	b = appendString(b, 1, m.ID)
+	b = appendString(b, 2, m.OSNumber)
+	b = appendString(b, 3, m.Status)
+	b = appendString(b, 4, m.Type)
+	b = appendString(b, 5, m.Priority)
+	b = appendString(b, 6, m.ErrorDescription)
+	b = appendString(b, 7, m.ClientName)
+	b = appendString(b, 8, m.CategoryName)
+	b = appendString(b, 9, m.LeadTechnicianID)
+	b = appendTime(b, 10, m.DueDate)
+	b = appendTime(b, 11, m.ScheduledStart)
+	b = appendTime(b, 12, m.ScheduledEnd)
+	b = appendInt32(b, 13, m.Version)
+	return appendTime(b, 14, m.CreatedAt)
+}
+
+func (m *Ticket) unmarshal(b []byte) error {
+	d := decoder{b: b}
+	for d.next() {
+		switch d.num {
+		case 1:
+			m.ID = d.string()
+		case 2:
+			m.OSNumber = d.string()
+		case 3:
+			m.Status = d.string()
+		case 4:
+			m.Type = d.string()
+		case 5:
+			m.Priority = d.string()
+		case 6:
+			m.ErrorDescription = d.string()
+		case 7:
+			m.ClientName = d.string()
+		case 8:
+			m.CategoryName = d.string()
+		case 9:
+			m.LeadTechnicianID = d.string()
+		case 10:
+			m.DueDate = d.time()
+		case 11:
+			m.ScheduledStart = d.time()
+		case 12:
+			m.ScheduledEnd = d.time()
+		case 13:
+			m.Version = d.int32()
+		case 14:
+			m.CreatedAt = d.time()
+		default:
+			d.skip()
+		}
+	}
+	return d.err
+}
+
+type ListTicketsRequest struct {
+	Page   int32
+	Size   int32
+	Status string
+}
+
+func (m *ListTicketsRequest) marshal(b []byte) []byte {
+	b = appendInt32(b, 1, m.Page)
+	b = appendInt32(b, 2, m.Size)
+	return appendString(b, 3, m.Status)
+}
+
+func (m *ListTicketsRequest) unmarshal(b []byte) error {
+	d := decoder{b: b}
+	for d.next() {
+		switch d.num {
+		case 1:
+			m.Page = d.int32()
+		case 2:
+			m.Size = d.int32()
+		case 3:
+			m.Status = d.string()
+		default:
+			d.skip()
+		}
+	}
+	return d.err
+}
+
+type ListTicketsResponse struct {
+	Tickets []Ticket
+	Page    int32
+	Size    int32
+	Total   int64
+	HasNext bool
+}
+
+func (m *ListTicketsResponse) marshal(b []byte) []byte {
+	for i := range m.Tickets {
+		b = appendMessage(b, 1, &m.Tickets[i])
+	}
+	b = appendInt32(b, 2, m.Page)
+	b = appendInt32(b, 3, m.Size)
+	b = appendInt64(b, 4, m.Total)
+	return appendBool(b, 5, m.HasNext)
+}
+
+func (m *ListTicketsResponse) unmarshal(b []byte) error {
+	d := decoder{b: b}
+	for d.next() {
+		switch d.num {
+		case 1:
+			var ticket Ticket
+			d.message(&ticket)
+			m.Tickets = append(m.Tickets, ticket)
+		case 2:
+			m.Page = d.int32()
+		case 3:
+			m.Size = d.int32()
+		case 4:
+			m.Total = d.int64()
+		case 5:
+			m.HasNext = d.bool()
+		default:
+			d.skip()
+		}
+	}
+	return d.err
+}
+
+type AcceptTicketRequest struct {
+	TicketID string
+}
+
+func (m *AcceptTicketRequest) marshal(b []byte) []byte {
+	return appendString(b, 1, m.TicketID)
+}
+
+func (m *AcceptTicketRequest) unmarshal(b []byte) error {
+	d := decoder{b: b}
+	for d.next() {
+		switch d.num {
+		case 1:
+			m.TicketID = d.string()
+		default:
+			d.skip()
+		}
+	}
+	return d.err
+}
+
+type AcceptTicketResponse struct {
+	Ticket *Ticket
+}
+
+func (m *AcceptTicketResponse) marshal(b []byte) []byte {
+	if m.Ticket != nil {
+		b = appendMessage(b, 1, m.Ticket)
+	}
+	return b
+}
+
+func (m *AcceptTicketResponse) unmarshal(b []byte) error {
+	d := decoder{b: b}
+	for d.next() {
+		switch d.num {
+		case 1:
+			m.Ticket = &Ticket{}
+			d.message(m.Ticket)
+		default:
+			d.skip()
+		}
+	}
+	return d.err
+}
+
+type TicketChange struct {
+	LocalID     string
+	TicketID    string
+	Status      string
+	Notes       string
+	BaseVersion int32
+	ChangedAt   *time.Time
+}
+
+func (m *TicketChange) marshal(b []byte) []byte {
+	b = appendString(b, 1, m.LocalID)
+	b = appendString(b, 2, m.TicketID)
+	b = appendString(b, 3, m.Status)
+	b = appendString(b, 4, m.Notes)
+	b = appendInt32(b, 5, m.BaseVersion)
+	return appendTime(b, 6, m.ChangedAt)
+}
+
+func (m *TicketChange) unmarshal(b []byte) error {
+	d := decoder{b: b}
+	for d.next() {
+		switch d.num {
+		case 1:
+			m.LocalID = d.string()
+		case 2:
+			m.TicketID = d.string()
+		case 3:
+			m.Status = d.string()
+		case 4:
+			m.Notes = d.string()
+		case 5:
+			m.BaseVersion = d.int32()
+		case 6:
+			m.ChangedAt = d.time()
+		default:
+			d.skip()
+		}
+	}
+	return d.err
+}
+
+type SyncTicketsRequest struct {
+	Changes []TicketChange
+	Cursor  string
+}
+
+func (m *SyncTicketsRequest) marshal(b []byte) []byte {
+	for i := range m.Changes {
+		b = appendMessage(b, 1, &m.Changes[i])
+	}
+	return appendString(b, 2, m.Cursor)
+}
+
+func (m *SyncTicketsRequest) unmarshal(b []byte) error {
+	d := decoder{b: b}
+	for d.next() {
+		switch d.num {
+		case 1:
+			var change TicketChange
+			d.message(&change)
+			m.Changes = append(m.Changes, change)
+		case 2:
+			m.Cursor = d.string()
+		default:
+			d.skip()
+		}
+	}
+	return d.err
+}
+
+type TicketChangeResult struct {
+	LocalID  string
+	TicketID string
+	Status   string
+	Error    string
+	Current  *Ticket
+}
+
+func (m *TicketChangeResult) marshal(b []byte) []byte {
+	b = appendString(b, 1, m.LocalID)
+	b = appendString(b, 2, m.TicketID)
+	b = appendString(b, 3, m.Status)
+	b = appendString(b, 4, m.Error)
+	if m.Current != nil {
+		b = appendMessage(b, 5, m.Current)
+	}
+	return b
+}
+
+func (m *TicketChangeResult) unmarshal(b []byte) error {
+	d := decoder{b: b}
+	for d.next() {
+		switch d.num {
+		case 1:
+			m.LocalID = d.string()
+		case 2:
+			m.TicketID = d.string()
+		case 3:
+			m.Status = d.string()
+		case 4:
+			m.Error = d.string()
+		case 5:
+			m.Current = &Ticket{}
+			d.message(m.Current)
+		default:
+			d.skip()
+		}
+	}
+	return d.err
+}
+
+type SyncTicketsResponse struct {
+	Results    []TicketChangeResult
+	Changed    []Ticket
+	DeletedIDs []string
+	Cursor     string
+	HasMore    bool
+}
+
+func (m *SyncTicketsResponse) marshal(b []byte) []byte {
+	for i := range m.Results {
+		b = appendMessage(b, 1, &m.Results[i])
+	}
+	for i := range m.Changed {
+		b = appendMessage(b, 2, &m.Changed[i])
+	}
+	for _, id := range m.DeletedIDs {
+		b = appendString(b, 3, id)
+	}
+	b = appendString(b, 4, m.Cursor)
+	return appendBool(b, 5, m.HasMore)
+}
+
+func (m *SyncTicketsResponse) unmarshal(b []byte) error {
+	d := decoder{b: b}
+	for d.next() {
+		switch d.num {
+		case 1:
+			var result TicketChangeResult
+			d.message(&result)
+			m.Results = append(m.Results, result)
+		case 2:
+			var ticket Ticket
+			d.message(&ticket)
+			m.Changed = append(m.Changed, ticket)
+		case 3:
+			m.DeletedIDs = append(m.DeletedIDs, d.string())
+		case 4:
+			m.Cursor = d.string()
+		case 5:
+			m.HasMore = d.bool()
+		default:
+			d.skip()
+		}
+	}
+	return d.err
+}
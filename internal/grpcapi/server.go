@@ -0,0 +1,182 @@
+// Package grpcapi is the gRPC server of the technician app (location ingest, ticket list
+// and accept, offline ticket sync). It listens on its own port and calls the services the
+// REST handlers use; the contract is proto/mobile/v1/mobile.proto.
+package grpcapi
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shigake/tech-iq-back/internal/logger"
+	"github.com/shigake/tech-iq-back/internal/middleware"
+	"github.com/shigake/tech-iq-back/internal/tenant"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// healthService is left out of authentication, probes carry no token
+const healthService = "/grpc.health.v1.Health/"
+
+// Config of the gRPC server
+type Config struct {
+	Port           string
+	JWTSecret      string
+	MaxMessageSize int // bytes, per request
+}
+
+// SessionChecker tells whether the session an access token was issued for was revoked
+type SessionChecker = middleware.SessionChecker
+
+// Server is the gRPC listener, stopped with the REST server on shutdown
+type Server struct {
+	config Config
+	grpc   *grpc.Server
+	health *health.Server
+}
+
+func NewServer(config Config, sessions SessionChecker, technicianSync *TechnicianSyncService) *Server {
+	s := &Server{
+		config: config,
+		health: health.NewServer(),
+	}
+	s.grpc = grpc.NewServer(
+		grpc.ForceServerCodec(codec{}),
+		grpc.MaxRecvMsgSize(config.MaxMessageSize),
+		grpc.ChainUnaryInterceptor(accessLog, authenticate(config.JWTSecret, sessions)),
+	)
+	healthpb.RegisterHealthServer(s.grpc, s.health)
+	s.grpc.RegisterService(&technicianSyncServiceDesc, technicianSync)
+	s.health.SetServingStatus(technicianSyncServiceName, healthpb.HealthCheckResponse_SERVING)
+	return s
+}
+
+// Start listens on the port and serves in the background
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", ":"+s.config.Port)
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := s.grpc.Serve(listener); err != nil {
+			slog.Error("gRPC server stopped", "error", err)
+		}
+	}()
+	return nil
+}
+
+// Drain reports NOT_SERVING to the health checks, on shutdown
+func (s *Server) Drain() {
+	s.health.Shutdown()
+}
+
+// Stop lets the calls in flight finish, cancelling those still running at the timeout
+func (s *Server) Stop(timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		slog.Warn("gRPC calls still in flight at the shutdown timeout")
+		s.grpc.Stop()
+	}
+}
+
+// caller is the authenticated user of a call
+type caller struct {
+	UserID string
+	Role   string
+}
+
+type callerKey struct{}
+
+func callerFromContext(ctx context.Context) (caller, bool) {
+	c, ok := ctx.Value(callerKey{}).(caller)
+	return c, ok
+}
+
+// authenticate checks the access token of the "authorization" metadata, as JWTProtected
+// does for the REST routes, and binds the user and tenant of the token to the context
+func authenticate(secret string, sessions SessionChecker) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if strings.HasPrefix(info.FullMethod, healthService) {
+			return handler(ctx, req)
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		values := md.Get("authorization")
+		if len(values) == 0 || values[0] == "" {
+			return nil, status.Error(codes.Unauthenticated, "missing authorization metadata")
+		}
+		claims, err := middleware.ParseAccessToken(secret, strings.TrimPrefix(values[0], "Bearer "))
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, "invalid or expired token")
+		}
+		sessionID, _ := claims["sid"].(string)
+		if sessionID != "" && sessions != nil {
+			revoked, err := sessions.IsSessionRevoked(sessionID)
+			if err != nil {
+				return nil, status.Error(codes.Unavailable, "session check unavailable")
+			}
+			if revoked {
+				return nil, status.Error(codes.Unauthenticated, "session has been revoked")
+			}
+		}
+
+		userID, _ := claims["userId"].(string)
+		role, _ := claims["role"].(string)
+		if userID == "" {
+			return nil, status.Error(codes.Unauthenticated, "invalid token claims")
+		}
+		tenantID, _ := claims["tenantId"].(string)
+		if tenantID == "" {
+			tenantID = tenant.DefaultID
+		}
+		ctx = tenant.WithID(ctx, tenantID)
+		ctx = context.WithValue(ctx, callerKey{}, caller{UserID: userID, Role: role})
+		return handler(ctx, req)
+	}
+}
+
+// accessLog tags the call with the x-request-id metadata (or a new ID) and writes one
+// line per call, like the AccessLog middleware of the REST server
+func accessLog(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	md, _ := metadata.FromIncomingContext(ctx)
+	id := ""
+	if values := md.Get(strings.ToLower(logger.RequestIDHeader)); len(values) > 0 && len(values[0]) <= 64 {
+		id = values[0]
+	}
+	if id == "" {
+		id = uuid.New().String()
+	}
+	ctx = logger.WithRequestID(ctx, id)
+
+	resp, err := handler(ctx, req)
+
+	code := status.Code(err)
+	level := slog.LevelInfo
+	switch code {
+	case codes.OK:
+	case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable:
+		level = slog.LevelError
+	default:
+		level = slog.LevelWarn
+	}
+	slog.Log(ctx, level, "gRPC call",
+		"method", info.FullMethod,
+		"code", code.String(),
+		"latency_ms", time.Since(start).Milliseconds(),
+	)
+	return resp, err
+}
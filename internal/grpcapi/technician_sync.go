@@ -0,0 +1,264 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
+	"github.com/shigake/tech-iq-back/internal/services"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const technicianSyncServiceName = "techiq.mobile.v1.TechnicianSync"
+
+// TechnicianSyncServer is the TechnicianSync service of mobile.proto
+type TechnicianSyncServer interface {
+	IngestLocations(ctx context.Context, req *IngestLocationsRequest) (*IngestLocationsResponse, error)
+	ListTickets(ctx context.Context, req *ListTicketsRequest) (*ListTicketsResponse, error)
+	AcceptTicket(ctx context.Context, req *AcceptTicketRequest) (*AcceptTicketResponse, error)
+	SyncTickets(ctx context.Context, req *SyncTicketsRequest) (*SyncTicketsResponse, error)
+}
+
+var technicianSyncServiceDesc = grpc.ServiceDesc{
+	ServiceName: technicianSyncServiceName,
+	HandlerType: (*TechnicianSyncServer)(nil),
+	Methods: []grpc.MethodDesc{
+		unaryMethod("IngestLocations", func() message { return &IngestLocationsRequest{} },
+			func(srv TechnicianSyncServer, ctx context.Context, req message) (message, error) {
+				return srv.IngestLocations(ctx, req.(*IngestLocationsRequest))
+			}),
+		unaryMethod("ListTickets", func() message { return &ListTicketsRequest{} },
+			func(srv TechnicianSyncServer, ctx context.Context, req message) (message, error) {
+				return srv.ListTickets(ctx, req.(*ListTicketsRequest))
+			}),
+		unaryMethod("AcceptTicket", func() message { return &AcceptTicketRequest{} },
+			func(srv TechnicianSyncServer, ctx context.Context, req message) (message, error) {
+				return srv.AcceptTicket(ctx, req.(*AcceptTicketRequest))
+			}),
+		unaryMethod("SyncTickets", func() message { return &SyncTicketsRequest{} },
+			func(srv TechnicianSyncServer, ctx context.Context, req message) (message, error) {
+				return srv.SyncTickets(ctx, req.(*SyncTicketsRequest))
+			}),
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "proto/mobile/v1/mobile.proto",
+}
+
+// unaryMethod is the method handler protoc-gen-go-grpc would generate
+func unaryMethod(name string, newRequest func() message, call func(TechnicianSyncServer, context.Context, message) (message, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+			req := newRequest()
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			if interceptor == nil {
+				return call(srv.(TechnicianSyncServer), ctx, req)
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + technicianSyncServiceName + "/" + name}
+			return interceptor(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+				return call(srv.(TechnicianSyncServer), ctx, req.(message))
+			})
+		},
+	}
+}
+
+// TechnicianSyncService serves TechnicianSync with the services of the REST routes
+type TechnicianSyncService struct {
+	geoService        *services.GeoService
+	ticketService     services.TicketService
+	ticketSyncService services.TicketSyncService
+	validate          *validator.Validate
+}
+
+func NewTechnicianSyncService(geoService *services.GeoService, ticketService services.TicketService, ticketSyncService services.TicketSyncService) *TechnicianSyncService {
+	return &TechnicianSyncService{
+		geoService:        geoService,
+		ticketService:     ticketService,
+		ticketSyncService: ticketSyncService,
+		validate:          validator.New(),
+	}
+}
+
+func (s *TechnicianSyncService) IngestLocations(ctx context.Context, req *IngestLocationsRequest) (*IngestLocationsResponse, error) {
+	user, _ := callerFromContext(ctx)
+
+	batch := models.BatchLocationRequest{Locations: make([]models.BatchLocationItem, 0, len(req.Locations))}
+	for _, l := range req.Locations {
+		item := models.BatchLocationItem{
+			LocalID:    l.LocalID,
+			EventType:  models.EventType(l.EventType),
+			Latitude:   l.Latitude,
+			Longitude:  l.Longitude,
+			AccuracyM:  l.AccuracyM,
+			AltitudeM:  l.AltitudeM,
+			SpeedMps:   l.SpeedMps,
+			HeadingDeg: l.HeadingDeg,
+			DeviceTime: l.DeviceTime,
+			IsMocked:   l.IsMocked,
+		}
+		if l.TicketID != "" {
+			ticketID, err := uuid.Parse(l.TicketID)
+			if err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "location %s: invalid ticket_id", l.LocalID)
+			}
+			item.TicketID = &ticketID
+		}
+		if l.Provider != "" {
+			provider := l.Provider
+			item.Provider = &provider
+		}
+		batch.Locations = append(batch.Locations, item)
+	}
+	if err := s.validate.Struct(&batch); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	results, err := s.geoService.WithContext(ctx).CreateBatchLocations(user.UserID, &batch)
+	if err != nil {
+		return nil, internalError(ctx, err)
+	}
+	resp := &IngestLocationsResponse{Results: make([]LocationResult, 0, len(results))}
+	for _, r := range results {
+		result := LocationResult{LocalID: r.LocalID, Status: r.Status, Error: r.Error}
+		if r.ServerID != uuid.Nil {
+			result.ServerID = r.ServerID.String()
+		}
+		if r.Status == "created" {
+			resp.Processed++
+		}
+		resp.Results = append(resp.Results, result)
+	}
+	return resp, nil
+}
+
+func (s *TechnicianSyncService) ListTickets(ctx context.Context, req *ListTicketsRequest) (*ListTicketsResponse, error) {
+	user, _ := callerFromContext(ctx)
+	page := int(req.Page)
+	if page < 0 {
+		page = 0
+	}
+	size := pagination.Size(pagination.Tickets, int(req.Size))
+	filters := &models.TicketFilters{
+		Status: req.Status,
+		Scope:  models.NewAccessScope(user.UserID, user.Role),
+	}
+
+	list, err := s.ticketService.WithContext(ctx).GetAllForUser(page, size, filters, user.UserID, user.Role)
+	if err != nil {
+		return nil, internalError(ctx, err)
+	}
+	dtos, _ := list.Content.([]models.TicketDTO)
+	resp := &ListTicketsResponse{
+		Tickets: make([]Ticket, 0, len(dtos)),
+		Page:    int32(list.Page),
+		Size:    int32(list.Size),
+		Total:   list.TotalElements,
+		HasNext: list.HasNext,
+	}
+	for i := range dtos {
+		resp.Tickets = append(resp.Tickets, *ticketMessage(&dtos[i]))
+	}
+	return resp, nil
+}
+
+func (s *TechnicianSyncService) AcceptTicket(ctx context.Context, req *AcceptTicketRequest) (*AcceptTicketResponse, error) {
+	user, _ := callerFromContext(ctx)
+	if req.TicketID == "" {
+		return nil, status.Error(codes.InvalidArgument, "ticket_id is required")
+	}
+
+	ticket, err := s.ticketSyncService.WithContext(ctx).Accept(req.TicketID, user.UserID, user.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrSyncTicketNotFound):
+			return nil, status.Error(codes.NotFound, err.Error())
+		case errors.Is(err, services.ErrSyncPermissionRequired), errors.Is(err, services.ErrTechnicianNotAssigned):
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		case errors.Is(err, services.ErrTicketNotAcceptable), errors.Is(err, services.ErrTransitionNotAllowed),
+			errors.Is(err, services.ErrTransitionFieldsMissing):
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		return nil, internalError(ctx, err)
+	}
+	dto := ticket.ToDTO()
+	return &AcceptTicketResponse{Ticket: ticketMessage(&dto)}, nil
+}
+
+func (s *TechnicianSyncService) SyncTickets(ctx context.Context, req *SyncTicketsRequest) (*SyncTicketsResponse, error) {
+	user, _ := callerFromContext(ctx)
+
+	upload := models.TicketSyncRequest{Changes: make([]models.TicketSyncChange, 0, len(req.Changes)), Cursor: req.Cursor}
+	for _, c := range req.Changes {
+		upload.Changes = append(upload.Changes, models.TicketSyncChange{
+			LocalID:     c.LocalID,
+			TicketID:    c.TicketID,
+			Status:      c.Status,
+			Notes:       c.Notes,
+			BaseVersion: int(c.BaseVersion),
+			ChangedAt:   c.ChangedAt,
+		})
+	}
+	if err := s.validate.Struct(&upload); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	sync, err := s.ticketSyncService.WithContext(ctx).Sync(&upload, user.UserID, user.Role)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidSyncCursor) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, internalError(ctx, err)
+	}
+	resp := &SyncTicketsResponse{
+		Results:    make([]TicketChangeResult, 0, len(sync.Results)),
+		Changed:    make([]Ticket, 0, len(sync.Changed)),
+		DeletedIDs: sync.DeletedIDs,
+		Cursor:     sync.Cursor,
+		HasMore:    sync.HasMore,
+	}
+	for _, r := range sync.Results {
+		result := TicketChangeResult{LocalID: r.LocalID, TicketID: r.TicketID, Status: r.Status, Error: r.Error}
+		if r.Current != nil {
+			result.Current = ticketMessage(r.Current)
+		}
+		resp.Results = append(resp.Results, result)
+	}
+	for i := range sync.Changed {
+		resp.Changed = append(resp.Changed, *ticketMessage(&sync.Changed[i]))
+	}
+	return resp, nil
+}
+
+func ticketMessage(t *models.TicketDTO) *Ticket {
+	createdAt := t.CreatedAt
+	return &Ticket{
+		ID:               t.ID,
+		OSNumber:         t.OSNumber,
+		Status:           t.Status,
+		Type:             t.Type,
+		Priority:         t.Priority,
+		ErrorDescription: t.ErrorDescription,
+		ClientName:       t.ClientName,
+		CategoryName:     t.CategoryName,
+		LeadTechnicianID: t.LeadTechnicianID,
+		DueDate:          t.DueDate,
+		ScheduledStart:   t.ScheduledStart,
+		ScheduledEnd:     t.ScheduledEnd,
+		Version:          int32(t.Version),
+		CreatedAt:        &createdAt,
+	}
+}
+
+// internalError logs the cause and hides it from the app
+func internalError(ctx context.Context, err error) error {
+	slog.ErrorContext(ctx, "gRPC call failed", "error", err)
+	return status.Error(codes.Internal, "internal error")
+}
@@ -0,0 +1,250 @@
+package grpcapi
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/encoding/proto"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// message is a message of proto/mobile/v1/mobile.proto. The messages are encoded by hand
+// on the protobuf wire format, so the apps use clients generated from the .proto file
+// while the server needs no protoc step.
+type message interface {
+	marshal(b []byte) []byte
+	unmarshal(b []byte) error
+}
+
+// codec encodes the messages of this package and hands any other one (health checks) to
+// the standard protobuf codec
+type codec struct{}
+
+func (codec) Marshal(v any) ([]byte, error) {
+	if m, ok := v.(message); ok {
+		return m.marshal(nil), nil
+	}
+	return encoding.GetCodec(proto.Name).Marshal(v)
+}
+
+func (codec) Unmarshal(data []byte, v any) error {
+	if m, ok := v.(message); ok {
+		return m.unmarshal(data)
+	}
+	return encoding.GetCodec(proto.Name).Unmarshal(data, v)
+}
+
+func (codec) Name() string {
+	return proto.Name
+}
+
+// Encoding. Fields holding the zero value are left out, as proto3 does.
+
+func appendString(b []byte, num protowire.Number, v string) []byte {
+	if v == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+func appendInt64(b []byte, num protowire.Number, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(v))
+}
+
+func appendInt32(b []byte, num protowire.Number, v int32) []byte {
+	return appendInt64(b, num, int64(v))
+}
+
+func appendBool(b []byte, num protowire.Number, v bool) []byte {
+	if !v {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, 1)
+}
+
+func appendDouble(b []byte, num protowire.Number, v float64) []byte {
+	if v == 0 {
+		return b
+	}
+	return appendOptionalDouble(b, num, &v)
+}
+
+// appendOptionalDouble writes a proto3 optional field, zero included when set
+func appendOptionalDouble(b []byte, num protowire.Number, v *float64) []byte {
+	if v == nil {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, math.Float64bits(*v))
+}
+
+func appendMessage(b []byte, num protowire.Number, m message) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, m.marshal(nil))
+}
+
+// appendTime writes a google.protobuf.Timestamp
+func appendTime(b []byte, num protowire.Number, t *time.Time) []byte {
+	if t == nil || t.IsZero() {
+		return b
+	}
+	var ts []byte
+	ts = appendInt64(ts, 1, t.Unix())
+	ts = appendInt32(ts, 2, int32(t.Nanosecond()))
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, ts)
+}
+
+// decoder walks the fields of a message:
+//
+//	d := decoder{b: b}
+//	for d.next() {
+//		switch d.num {
+//		case 1:
+//			m.Name = d.string()
+//		default:
+//			d.skip()
+//		}
+//	}
+//	return d.err
+//
+// A field of an unexpected wire type is skipped, leaving the zero value.
+type decoder struct {
+	b   []byte
+	num protowire.Number
+	typ protowire.Type
+	err error
+}
+
+func (d *decoder) next() bool {
+	if d.err != nil || len(d.b) == 0 {
+		return false
+	}
+	num, typ, n := protowire.ConsumeTag(d.b)
+	if !d.consume(n) {
+		return false
+	}
+	d.num, d.typ = num, typ
+	return true
+}
+
+// consume moves past n bytes, or records the parse error of a negative n
+func (d *decoder) consume(n int) bool {
+	if n < 0 {
+		d.err = fmt.Errorf("field %d: %w", d.num, protowire.ParseError(n))
+		d.b = nil
+		return false
+	}
+	d.b = d.b[n:]
+	return true
+}
+
+func (d *decoder) skip() {
+	d.consume(protowire.ConsumeFieldValue(d.num, d.typ, d.b))
+}
+
+func (d *decoder) varint() uint64 {
+	if d.typ != protowire.VarintType {
+		d.skip()
+		return 0
+	}
+	v, n := protowire.ConsumeVarint(d.b)
+	if !d.consume(n) {
+		return 0
+	}
+	return v
+}
+
+func (d *decoder) int64() int64 {
+	return int64(d.varint())
+}
+
+func (d *decoder) int32() int32 {
+	return int32(d.varint())
+}
+
+func (d *decoder) bool() bool {
+	return d.varint() != 0
+}
+
+func (d *decoder) double() float64 {
+	if d.typ != protowire.Fixed64Type {
+		d.skip()
+		return 0
+	}
+	v, n := protowire.ConsumeFixed64(d.b)
+	if !d.consume(n) {
+		return 0
+	}
+	return math.Float64frombits(v)
+}
+
+func (d *decoder) optionalDouble() *float64 {
+	if d.typ != protowire.Fixed64Type {
+		d.skip()
+		return nil
+	}
+	v := d.double()
+	return &v
+}
+
+func (d *decoder) bytes() []byte {
+	if d.typ != protowire.BytesType {
+		d.skip()
+		return nil
+	}
+	v, n := protowire.ConsumeBytes(d.b)
+	if !d.consume(n) {
+		return nil
+	}
+	return v
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes())
+}
+
+func (d *decoder) message(m message) {
+	b := d.bytes()
+	if d.err != nil {
+		return
+	}
+	if err := m.unmarshal(b); err != nil {
+		d.err = fmt.Errorf("field %d: %w", d.num, err)
+	}
+}
+
+// time reads a google.protobuf.Timestamp
+func (d *decoder) time() *time.Time {
+	b := d.bytes()
+	if d.err != nil {
+		return nil
+	}
+	var seconds int64
+	var nanos int32
+	ts := decoder{b: b}
+	for ts.next() {
+		switch ts.num {
+		case 1:
+			seconds = ts.int64()
+		case 2:
+			nanos = ts.int32()
+		default:
+			ts.skip()
+		}
+	}
+	if ts.err != nil {
+		d.err = fmt.Errorf("field %d: %w", d.num, ts.err)
+		return nil
+	}
+	t := time.Unix(seconds, int64(nanos)).UTC()
+	return &t
+}
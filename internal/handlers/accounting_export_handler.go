@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type AccountingExportHandler struct {
+	service  services.AccountingExportService
+	validate *validator.Validate
+}
+
+func NewAccountingExportHandler(service services.AccountingExportService) *AccountingExportHandler {
+	return &AccountingExportHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// ListLayouts returns the accounting layouts
+// @Summary List accounting layouts
+// @Tags Export
+// @Produce json
+// @Success 200 {array} models.AccountingLayout
+// @Router /export/financial/layouts [get]
+func (h *AccountingExportHandler) ListLayouts(c *fiber.Ctx) error {
+	layouts, err := h.service.WithContext(c.UserContext()).ListLayouts()
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(layouts)
+}
+
+// GetLayout returns an accounting layout with its columns and account mapping
+// @Summary Get accounting layout
+// @Tags Export
+// @Produce json
+// @Param id path string true "Layout ID"
+// @Success 200 {object} models.AccountingLayout
+// @Router /export/financial/layouts/{id} [get]
+func (h *AccountingExportHandler) GetLayout(c *fiber.Ctx) error {
+	layout, err := h.service.WithContext(c.UserContext()).GetLayout(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(layout)
+}
+
+// CreateLayout creates an accounting layout
+// @Summary Create accounting layout
+// @Tags Export
+// @Accept json
+// @Produce json
+// @Param body body models.SaveAccountingLayoutRequest true "Layout"
+// @Success 201 {object} models.AccountingLayout
+// @Router /export/financial/layouts [post]
+func (h *AccountingExportHandler) CreateLayout(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+
+	var req models.SaveAccountingLayoutRequest
+	if errResponse := h.parseLayout(c, &req); errResponse != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errResponse)
+	}
+	layout, err := h.service.WithContext(c.UserContext()).CreateLayout(&req, userID)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(layout)
+}
+
+// UpdateLayout replaces an accounting layout; exports already generated keep their files
+// @Summary Update accounting layout
+// @Tags Export
+// @Accept json
+// @Produce json
+// @Param id path string true "Layout ID"
+// @Param body body models.SaveAccountingLayoutRequest true "Layout"
+// @Success 200 {object} models.AccountingLayout
+// @Router /export/financial/layouts/{id} [put]
+func (h *AccountingExportHandler) UpdateLayout(c *fiber.Ctx) error {
+	var req models.SaveAccountingLayoutRequest
+	if errResponse := h.parseLayout(c, &req); errResponse != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errResponse)
+	}
+	layout, err := h.service.WithContext(c.UserContext()).UpdateLayout(c.Params("id"), &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(layout)
+}
+
+// DeleteLayout deletes an accounting layout
+// @Summary Delete accounting layout
+// @Tags Export
+// @Param id path string true "Layout ID"
+// @Success 204
+// @Router /export/financial/layouts/{id} [delete]
+func (h *AccountingExportHandler) DeleteLayout(c *fiber.Ctx) error {
+	if err := h.service.WithContext(c.UserContext()).DeleteLayout(c.Params("id")); err != nil {
+		return h.handleError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// parseLayout reads, normalizes and validates the layout body, returning the error
+// response (nil when valid)
+func (h *AccountingExportHandler) parseLayout(c *fiber.Ctx, req *models.SaveAccountingLayoutRequest) fiber.Map {
+	if err := c.BodyParser(req); err != nil {
+		return fiber.Map{"error": "Invalid request body"}
+	}
+	req.Format = strings.ToUpper(req.Format)
+	req.Encoding = strings.ToUpper(req.Encoding)
+	for i := range req.Columns {
+		req.Columns[i].Field = strings.ToLower(req.Columns[i].Field)
+		req.Columns[i].Align = strings.ToLower(req.Columns[i].Align)
+	}
+	if err := h.validate.Struct(req); err != nil {
+		return fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		}
+	}
+	return nil
+}
+
+// Create queues a financial export; the file is generated in the background
+// @Summary Request financial export
+// @Tags Export
+// @Accept json
+// @Produce json
+// @Param body body models.CreateFinancialExportRequest true "Export"
+// @Success 202 {object} models.FinancialExport
+// @Router /export/financial [post]
+func (h *AccountingExportHandler) Create(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+
+	var req models.CreateFinancialExportRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+	export, err := h.service.WithContext(c.UserContext()).RequestExport(&req, userID)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Status(fiber.StatusAccepted).JSON(export)
+}
+
+// List returns the financial exports, newest first
+// @Summary List financial exports
+// @Tags Export
+// @Produce json
+// @Param status query string false "PENDING, COMPLETED or FAILED"
+// @Param mine query bool false "Only the exports requested by the user"
+// @Param page query int false "Page (0-based)"
+// @Param size query int false "Page size"
+// @Success 200 {object} models.PaginatedResponse
+// @Router /export/financial [get]
+func (h *AccountingExportHandler) List(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 0)
+	size := pageSize(c, pagination.Default, "size")
+	if page < 0 {
+		page = 0
+	}
+	filter := models.FinancialExportFilter{Status: strings.ToUpper(c.Query("status"))}
+	if c.QueryBool("mine") {
+		filter.RequestedBy, _ = c.Locals("userId").(string)
+	}
+
+	exports, err := h.service.WithContext(c.UserContext()).ListExports(filter, page, size)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(exports)
+}
+
+// Get returns a financial export with its status, row count and last error
+// @Summary Get financial export
+// @Tags Export
+// @Produce json
+// @Param id path string true "Export ID"
+// @Success 200 {object} models.FinancialExport
+// @Router /export/financial/{id} [get]
+func (h *AccountingExportHandler) Get(c *fiber.Ctx) error {
+	export, err := h.service.WithContext(c.UserContext()).GetExport(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(export)
+}
+
+// Download returns a short-lived download URL of the file of a completed export
+// @Summary Download financial export
+// @Tags Export
+// @Produce json
+// @Param id path string true "Export ID"
+// @Success 200 {object} models.FileDownload
+// @Router /export/financial/{id}/download [get]
+func (h *AccountingExportHandler) Download(c *fiber.Ctx) error {
+	download, err := h.service.WithContext(c.UserContext()).DownloadURL(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(download)
+}
+
+func (h *AccountingExportHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrAccountingLayoutNotFound),
+		errors.Is(err, services.ErrFinancialExportNotFound),
+		errors.Is(err, services.ErrFileNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrAccountingInvalidField),
+		errors.Is(err, services.ErrAccountingWidthRequired),
+		errors.Is(err, services.ErrAccountingInvalidDelimiter),
+		errors.Is(err, services.ErrAccountingDuplicateMapping),
+		errors.Is(err, services.ErrFinancialExportInvalidDate),
+		errors.Is(err, services.ErrFinancialExportRange):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrFinancialExportNotReady):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
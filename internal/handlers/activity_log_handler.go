@@ -7,6 +7,7 @@ import (
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
 	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
 	"github.com/shigake/tech-iq-back/internal/services"
 )
 
@@ -32,10 +33,11 @@ func NewActivityLogHandler(service services.ActivityLogService) *ActivityLogHand
 // @Param userId query string false "Filter by user ID"
 // @Param action query string false "Filter by action type"
 // @Param resource query string false "Filter by resource type"
+// @Param requestId query string false "Filter by request correlation ID (X-Request-ID)"
 // @Param startDate query string false "Filter by start date (RFC3339)"
 // @Param endDate query string false "Filter by end date (RFC3339)"
 // @Success 200 {object} models.PaginatedActivityLogs
-// @Router /api/v1/activity-logs [get]
+// @Router /activity-logs [get]
 func (h *ActivityLogHandler) GetActivityLogs(c *fiber.Ctx) error {
 	// Check if user is admin
 	userRole := getUserRole(c)
@@ -46,13 +48,14 @@ func (h *ActivityLogHandler) GetActivityLogs(c *fiber.Ctx) error {
 	}
 
 	page, _ := strconv.Atoi(c.Query("page", "1"))
-	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	limit := pageSize(c, pagination.Logs, "limit")
 
 	filter := &models.ActivityLogFilter{
 		UserID:     c.Query("userId"),
 		Action:     c.Query("action"),
 		Resource:   c.Query("resource"),
 		ResourceID: c.Query("resourceId"),
+		RequestID:  c.Query("requestId"),
 	}
 
 	// Parse date filters
@@ -85,7 +88,7 @@ func (h *ActivityLogHandler) GetActivityLogs(c *fiber.Ctx) error {
 // @Param page query int false "Page number" default(1)
 // @Param limit query int false "Items per page" default(20)
 // @Success 200 {object} models.PaginatedActivityLogs
-// @Router /api/v1/activity-logs/me [get]
+// @Router /activity-logs/me [get]
 func (h *ActivityLogHandler) GetMyActivityLogs(c *fiber.Ctx) error {
 	userID := getUserID(c)
 	if userID == "" {
@@ -95,7 +98,7 @@ func (h *ActivityLogHandler) GetMyActivityLogs(c *fiber.Ctx) error {
 	}
 
 	page, _ := strconv.Atoi(c.Query("page", "1"))
-	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	limit := pageSize(c, pagination.Logs, "limit")
 
 	result, err := h.service.GetByUserID(userID, page, limit)
 	if err != nil {
@@ -114,7 +117,7 @@ func (h *ActivityLogHandler) GetMyActivityLogs(c *fiber.Ctx) error {
 // @Produce json
 // @Param limit query int false "Number of logs" default(10)
 // @Success 200 {array} models.ActivityLog
-// @Router /api/v1/activity-logs/recent [get]
+// @Router /activity-logs/recent [get]
 func (h *ActivityLogHandler) GetRecentActivityLogs(c *fiber.Ctx) error {
 	// Check if user is admin
 	userRole := getUserRole(c)
@@ -143,7 +146,7 @@ func (h *ActivityLogHandler) GetRecentActivityLogs(c *fiber.Ctx) error {
 // @Produce json
 // @Param id path string true "Activity Log ID"
 // @Success 200 {object} models.ActivityLog
-// @Router /api/v1/activity-logs/{id} [get]
+// @Router /activity-logs/{id} [get]
 func (h *ActivityLogHandler) GetActivityLogByID(c *fiber.Ctx) error {
 	id := c.Params("id")
 
@@ -45,6 +45,42 @@ func (h *AdminHandler) GetSystemMetrics(c *fiber.Ctx) error {
 	return c.JSON(metrics)
 }
 
+// GetLatencyBudgets godoc
+// @Summary Get p95 latency of the hottest routes against their budgets (admin only)
+// @Description Compares the p95 response time of the last hour with the configured latency budgets
+// @Tags Admin
+// @Produce json
+// @Success 200 {array} models.LatencyBudgetStatus
+// @Security BearerAuth
+// @Router /admin/system-metrics/latency-budgets [get]
+func (h *AdminHandler) GetLatencyBudgets(c *fiber.Ctx) error {
+	budgets, err := h.systemMetricsService.GetLatencyBudgets()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch latency budgets",
+		})
+	}
+	return c.JSON(budgets)
+}
+
+// GetPayloadSizes godoc
+// @Summary Get request and response sizes per route (admin only)
+// @Description Average, p95 and max payload sizes of the last 24 hours, largest responses first. Response sizes are before compression.
+// @Tags Admin
+// @Produce json
+// @Success 200 {array} models.RoutePayload
+// @Security BearerAuth
+// @Router /admin/system-metrics/payload-sizes [get]
+func (h *AdminHandler) GetPayloadSizes(c *fiber.Ctx) error {
+	sizes, err := h.systemMetricsService.GetPayloadSizes()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch payload sizes",
+		})
+	}
+	return c.JSON(sizes)
+}
+
 // GetHealthCheck godoc
 // @Summary Get server health status
 // @Description Returns basic health check information
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type AlertHandler struct {
+	service  services.AlertService
+	validate *validator.Validate
+}
+
+func NewAlertHandler(service services.AlertService) *AlertHandler {
+	return &AlertHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// List returns alerts, most severe first (?module=&type=&severity=&status=OPEN|ACKNOWLEDGED|RESOLVED|ACTIVE
+// &ownerId=&mine=true&from=&to=&page=&size=)
+func (h *AlertHandler) List(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 0)
+	size := pageSize(c, pagination.Default, "size")
+	if page < 0 {
+		page = 0
+	}
+
+	filters := &models.AlertFilters{
+		Module:   strings.ToUpper(c.Query("module")),
+		Type:     strings.ToUpper(c.Query("type")),
+		Severity: c.Query("severity"),
+		Status:   strings.ToUpper(c.Query("status")),
+		OwnerID:  c.Query("ownerId"),
+	}
+	if c.QueryBool("mine") {
+		filters.OwnerID, _ = c.Locals("userId").(string)
+	}
+	if s := c.Query("from"); s != "" {
+		t, err := parseTime(s)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid from date"})
+		}
+		filters.From = &t
+	}
+	if s := c.Query("to"); s != "" {
+		t, err := parseTime(s)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid to date"})
+		}
+		filters.To = &t
+	}
+
+	result, err := h.service.List(page, size, filters)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(result)
+}
+
+// Badges returns the active alert counts per module for the topbar
+func (h *AlertHandler) Badges(c *fiber.Ctx) error {
+	badges, err := h.service.Badges()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to count alerts",
+		})
+	}
+	return c.JSON(badges)
+}
+
+// Get returns an alert
+func (h *AlertHandler) Get(c *fiber.Ctx) error {
+	alert, err := h.service.Get(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(alert)
+}
+
+// Acknowledge marks the alert as seen by the current user
+func (h *AlertHandler) Acknowledge(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+
+	alert, err := h.service.Acknowledge(c.Params("id"), userID)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(alert)
+}
+
+// Assign sets (or clears) the owner of the alert
+func (h *AlertHandler) Assign(c *fiber.Ctx) error {
+	var req models.AssignAlertRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	alert, err := h.service.Assign(c.Params("id"), &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(alert)
+}
+
+// Resolve closes the alert with an optional note
+func (h *AlertHandler) Resolve(c *fiber.Ctx) error {
+	var req models.ResolveAlertRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	userID, _ := c.Locals("userId").(string)
+
+	alert, err := h.service.Resolve(c.Params("id"), userID, &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(alert)
+}
+
+// Scan runs the alert detectors now instead of waiting for the next scheduled pass
+func (h *AlertHandler) Scan(c *fiber.Ctx) error {
+	result, err := h.service.Scan()
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(result)
+}
+
+func (h *AlertHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrAlertNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrAlertOwnerNotFound),
+		errors.Is(err, services.ErrAlertInvalidSeverity):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrAlertResolved),
+		errors.Is(err, services.ErrAlertAcknowledged):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type APIKeyHandler struct {
+	service  services.APIKeyService
+	validate *validator.Validate
+}
+
+func NewAPIKeyHandler(service services.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// List returns the API keys, revoked ones included
+// @Summary List API keys
+// @Tags Admin
+// @Produce json
+// @Success 200 {array} models.APIKey
+// @Router /admin/api-keys [get]
+func (h *APIKeyHandler) List(c *fiber.Ctx) error {
+	keys, err := h.service.WithContext(c.UserContext()).List()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch API keys",
+		})
+	}
+	return c.JSON(keys)
+}
+
+// Scopes returns the permission codes a key can be granted
+// @Summary List API key scopes
+// @Tags Admin
+// @Produce json
+// @Success 200 {array} string
+// @Router /admin/api-keys/scopes [get]
+func (h *APIKeyHandler) Scopes(c *fiber.Ctx) error {
+	return c.JSON(models.APIKeyScopes)
+}
+
+// GetByID returns an API key
+// @Summary Get API key
+// @Tags Admin
+// @Produce json
+// @Param id path string true "Key ID"
+// @Success 200 {object} models.APIKey
+// @Router /admin/api-keys/{id} [get]
+func (h *APIKeyHandler) GetByID(c *fiber.Ctx) error {
+	key, err := h.service.WithContext(c.UserContext()).Get(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(key)
+}
+
+// Create issues an API key; the key is only shown in this response
+// @Summary Create API key
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param body body models.CreateAPIKeyRequest true "Name, scopes and expiry"
+// @Success 201 {object} models.APIKeyCreated
+// @Router /admin/api-keys [post]
+func (h *APIKeyHandler) Create(c *fiber.Ctx) error {
+	var req models.CreateAPIKeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if err := h.validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	adminID, _ := c.Locals("userId").(string)
+	created, err := h.service.WithContext(c.UserContext()).Create(adminID, &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(created)
+}
+
+// Update renames an API key or changes its scopes
+// @Summary Update API key
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Key ID"
+// @Param body body models.UpdateAPIKeyRequest true "Name and scopes"
+// @Success 200 {object} models.APIKey
+// @Router /admin/api-keys/{id} [put]
+func (h *APIKeyHandler) Update(c *fiber.Ctx) error {
+	var req models.UpdateAPIKeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if err := h.validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	adminID, _ := c.Locals("userId").(string)
+	key, err := h.service.WithContext(c.UserContext()).Update(adminID, c.Params("id"), &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(key)
+}
+
+// Revoke disables an API key and its service account
+// @Summary Revoke API key
+// @Tags Admin
+// @Produce json
+// @Param id path string true "Key ID"
+// @Success 200 {object} models.APIKey
+// @Router /admin/api-keys/{id} [delete]
+func (h *APIKeyHandler) Revoke(c *fiber.Ctx) error {
+	adminID, _ := c.Locals("userId").(string)
+	key, err := h.service.WithContext(c.UserContext()).Revoke(adminID, c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(key)
+}
+
+func (h *APIKeyHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrAPIKeyNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrAPIKeyRevoked):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
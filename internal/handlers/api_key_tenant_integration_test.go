@@ -0,0 +1,67 @@
+//go:build integration
+
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/handlers"
+	"github.com/shigake/tech-iq-back/internal/middleware"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"github.com/shigake/tech-iq-back/internal/services"
+	"github.com/shigake/tech-iq-back/internal/testutil"
+)
+
+func TestAPIKeysBelongToTheCreatorsTenant(t *testing.T) {
+	env.Reset(t)
+	keys := services.NewAPIKeyService(
+		repositories.NewAPIKeyRepository(env.DB),
+		services.NewActivityLogService(repositories.NewActivityLogRepository(env.DB)),
+	)
+	auth := middleware.JWTProtected(env.Config.JWTSecret, nil, keys)
+
+	app := fiber.New()
+	keyHandler := handlers.NewAPIKeyHandler(keys)
+	admin := app.Group("/admin", auth, middleware.AdminOnly())
+	admin.Get("/api-keys", keyHandler.List)
+	admin.Post("/api-keys", keyHandler.Create)
+	admin.Delete("/api-keys/:id", keyHandler.Revoke)
+	app.Get("/api/v1/tickets", auth, func(c *fiber.Ctx) error {
+		return c.SendString(c.Locals("tenantId").(string))
+	})
+
+	tokenA := env.AdminToken(t)
+	tokenB := env.TenantToken(t, testutil.OtherTenantID, otherTenantAdminID, "admin@other.test", "ADMIN")
+
+	var created models.APIKeyCreated
+	testutil.Do(t, app, http.MethodPost, "/admin/api-keys", models.CreateAPIKeyRequest{Name: "Tenant B ERP", Scopes: []string{"tickets.view"}}, tokenB).
+		Expect(t, http.StatusCreated).JSON(t, &created)
+	if created.APIKey.TenantID != testutil.OtherTenantID {
+		t.Fatalf("key of tenant B stamped with tenant %q", created.APIKey.TenantID)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/tickets", nil)
+	req.Header.Set("X-API-Key", created.Key)
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("request with key: %v", err)
+	}
+	body := make([]byte, 64)
+	n, _ := resp.Body.Read(body)
+	if resp.StatusCode != http.StatusOK || string(body[:n]) != testutil.OtherTenantID {
+		t.Fatalf("key request: status %d, tenant %q; want tenant B", resp.StatusCode, body[:n])
+	}
+
+	var listed []models.APIKey
+	testutil.Do(t, app, http.MethodGet, "/admin/api-keys", nil, tokenA).Expect(t, http.StatusOK).JSON(t, &listed)
+	for _, key := range listed {
+		if key.ID == created.APIKey.ID {
+			t.Fatal("tenant A listed tenant B's key")
+		}
+	}
+	testutil.Do(t, app, http.MethodDelete, "/admin/api-keys/"+created.APIKey.ID, nil, tokenA).Expect(t, http.StatusNotFound)
+}
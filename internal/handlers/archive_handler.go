@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type ArchiveHandler struct {
+	service services.ArchiveService
+}
+
+func NewArchiveHandler(service services.ArchiveService) *ArchiveHandler {
+	return &ArchiveHandler{service: service}
+}
+
+// Search lists archived tickets, most recently closed first
+// (?q=&clientId=&from=&to= on the closing date, &page=&size=)
+func (h *ArchiveHandler) Search(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 0)
+	size := pageSize(c, pagination.Default, "size")
+	if page < 0 {
+		page = 0
+	}
+
+	filters := &models.ArchiveSearchFilters{
+		Query:    c.Query("q"),
+		ClientID: c.Query("clientId"),
+	}
+	if s := c.Query("from"); s != "" {
+		t, err := parseTime(s)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid from date"})
+		}
+		filters.From = &t
+	}
+	if s := c.Query("to"); s != "" {
+		t, err := parseTime(s)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid to date"})
+		}
+		filters.To = &t
+	}
+
+	result, err := h.service.Search(page, size, filters)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to search the archive",
+		})
+	}
+	return c.JSON(result)
+}
+
+// Get returns an archived ticket with its archived comments, attachments metadata and timeline
+func (h *ArchiveHandler) Get(c *fiber.Ctx) error {
+	detail, err := h.service.Get(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(detail)
+}
+
+// Restore moves an archived ticket back to the active tickets
+func (h *ArchiveHandler) Restore(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+
+	if err := h.service.Restore(c.Params("id"), userID); err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(fiber.Map{"message": "Ticket restored successfully"})
+}
+
+// Run archives now the tickets closed more than ?months= ago (default from configuration)
+func (h *ArchiveHandler) Run(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+
+	result, err := h.service.Run(c.QueryInt("months", 0), userID)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(result)
+}
+
+func (h *ArchiveHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrArchivedTicketNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrArchiveInvalidMonths):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrArchiveRestoreConflict):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
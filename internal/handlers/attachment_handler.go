@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/services"
+	"gorm.io/gorm"
+)
+
+type AttachmentHandler struct {
+	service services.AttachmentService
+}
+
+func NewAttachmentHandler(service services.AttachmentService) *AttachmentHandler {
+	return &AttachmentHandler{service: service}
+}
+
+// Upload stores a ticket attachment and queues it for processing
+func (h *AttachmentHandler) Upload(c *fiber.Ctx) error {
+	header, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "File is required",
+		})
+	}
+
+	userID, _ := c.Locals("userId").(string)
+
+	file, err := h.service.WithContext(c.UserContext()).Upload(c.Params("id"), userID, header)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Status(fiber.StatusAccepted).JSON(file)
+}
+
+// GetByTicket lists the attachments of a ticket with their processing status
+func (h *AttachmentHandler) GetByTicket(c *fiber.Ctx) error {
+	files, err := h.service.WithContext(c.UserContext()).GetByTicket(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch attachments",
+		})
+	}
+	return c.JSON(files)
+}
+
+// GetByID returns a single attachment and its processing status
+func (h *AttachmentHandler) GetByID(c *fiber.Ctx) error {
+	file, err := h.service.WithContext(c.UserContext()).GetFile(c.Params("id"), c.Params("fileId"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(file)
+}
+
+// Download serves the original or a processed variant (?variant=thumbnail|sanitized|webp).
+// Clients get the metadata-stripped copy through the tracking link instead.
+func (h *AttachmentHandler) Download(c *fiber.Ctx) error {
+	file, err := h.service.WithContext(c.UserContext()).GetFile(c.Params("id"), c.Params("fileId"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	variant := c.Query("variant", "original")
+
+	path, err := h.service.ResolvePath(file, variant)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	if variant == "original" {
+		return c.Download(path, file.FileName)
+	}
+	return c.SendFile(path)
+}
+
+// Reprocess retries the pipeline for a failed attachment
+func (h *AttachmentHandler) Reprocess(c *fiber.Ctx) error {
+	file, err := h.service.WithContext(c.UserContext()).Reprocess(c.Params("id"), c.Params("fileId"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Status(fiber.StatusAccepted).JSON(file)
+}
+
+// Delete removes an attachment and all its variants
+func (h *AttachmentHandler) Delete(c *fiber.Ctx) error {
+	if err := h.service.WithContext(c.UserContext()).Delete(c.Params("id"), c.Params("fileId")); err != nil {
+		return h.handleError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *AttachmentHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Ticket not found"})
+	case errors.Is(err, services.ErrAttachmentNotFound), errors.Is(err, services.ErrAttachmentNoVariant):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrAttachmentTooLarge):
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrStorageQuotaExceeded):
+		return c.Status(fiber.StatusInsufficientStorage).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrAttachmentNotReady), errors.Is(err, services.ErrAttachmentNotRetrying):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrAttachmentInfected):
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"errors"
+	"path/filepath"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/pagination"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type AuditChainHandler struct {
+	service services.AuditChainService
+}
+
+func NewAuditChainHandler(service services.AuditChainService) *AuditChainHandler {
+	return &AuditChainHandler{service: service}
+}
+
+// Verify checks the hash chain of the activity log records created in the period
+// (?from=&to=, default last 30 days)
+func (h *AuditChainHandler) Verify(c *fiber.Ctx) error {
+	to := time.Now()
+	if s := c.Query("to"); s != "" {
+		t, err := parseTime(s)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid to date"})
+		}
+		to = t
+	}
+	from := to.AddDate(0, 0, -30)
+	if s := c.Query("from"); s != "" {
+		t, err := parseTime(s)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid from date"})
+		}
+		from = t
+	}
+	if !from.Before(to) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "from must be before to"})
+	}
+
+	result, err := h.service.Verify(from, to)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(result)
+}
+
+// ListBundles lists the signed export bundles, most recent period first (?page=&size=)
+func (h *AuditChainHandler) ListBundles(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 0)
+	size := pageSize(c, pagination.Default, "size")
+	if page < 0 {
+		page = 0
+	}
+
+	result, err := h.service.ListBundles(page, size)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch audit export bundles",
+		})
+	}
+	return c.JSON(result)
+}
+
+// RunExport exports the closed days not exported yet instead of waiting for the next run
+func (h *AuditChainHandler) RunExport(c *fiber.Ctx) error {
+	result, err := h.service.ExportPending()
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(result)
+}
+
+// DownloadBundle serves the bundle file: manifest, signature and records
+func (h *AuditChainHandler) DownloadBundle(c *fiber.Ctx) error {
+	_, path, err := h.service.OpenBundle(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Download(path, filepath.Base(path))
+}
+
+// VerifyBundle checks the signature and records of a bundle and compares them with the
+// records in the database
+func (h *AuditChainHandler) VerifyBundle(c *fiber.Ctx) error {
+	result, err := h.service.VerifyBundle(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(result)
+}
+
+// GetPublicKey returns the Ed25519 public key that verifies the bundle signatures
+func (h *AuditChainHandler) GetPublicKey(c *fiber.Ctx) error {
+	key, err := h.service.PublicKey()
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(fiber.Map{"algorithm": "Ed25519", "publicKey": key})
+}
+
+func (h *AuditChainHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrAuditBundleNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrAuditBundleUnreadable):
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrAuditSigningKeyMissing):
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
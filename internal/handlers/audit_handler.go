@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type AuditHandler struct {
+	service services.AuditService
+}
+
+func NewAuditHandler(service services.AuditService) *AuditHandler {
+	return &AuditHandler{service: service}
+}
+
+// Query returns the unified audit trail (activity, financial and access logs), newest
+// first; ?format=csv downloads it instead of paginating
+// @Summary Query audit trail
+// @Tags Audit
+// @Security BearerAuth
+// @Produce json
+// @Param source query string false "activity, financial or access"
+// @Param entity query string false "Entity type (ticket, financial_entry, role...)"
+// @Param entityId query string false "Entity ID"
+// @Param userId query string false "User who performed the action"
+// @Param action query string false "Action (CREATE, UPDATE, LOGIN...)"
+// @Param from query string false "Start date (RFC3339 or 2006-01-02)"
+// @Param to query string false "End date (RFC3339 or 2006-01-02)"
+// @Param format query string false "csv to download"
+// @Param cursor query string false "Keyset cursor (empty for the first page) instead of page"
+// @Success 200 {object} models.PaginatedAuditEvents
+// @Router /audit [get]
+func (h *AuditHandler) Query(c *fiber.Ctx) error {
+	filter := &models.AuditFilter{
+		Source:     c.Query("source"),
+		EntityType: c.Query("entity"),
+		EntityID:   c.Query("entityId"),
+		UserID:     c.Query("userId"),
+		Action:     c.Query("action"),
+	}
+	if s := c.Query("from"); s != "" {
+		t, err := parseTime(s)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid from date"})
+		}
+		filter.From = t
+	}
+	if s := c.Query("to"); s != "" {
+		t, err := parseTime(s)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid to date"})
+		}
+		filter.To = t
+	}
+
+	if c.Query("format") == "csv" {
+		data, truncated, err := h.service.ExportCSV(filter)
+		if err != nil {
+			return h.handleError(c, err)
+		}
+		if truncated {
+			c.Set("X-Export-Truncated", "true")
+		}
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+		c.Attachment(fmt.Sprintf("audit-%s.csv", time.Now().Format("20060102-150405")))
+		return c.Send(data)
+	}
+
+	limit := pageSize(c, pagination.Logs, "limit")
+	cursor, err := queryCursor(c)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	if cursor != nil {
+		result, err := h.service.QueryByCursor(filter, *cursor, limit)
+		if err != nil {
+			return h.handleError(c, err)
+		}
+		return c.JSON(result)
+	}
+
+	page := c.QueryInt("page", 1)
+	result, err := h.service.Query(filter, page, limit)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(result)
+}
+
+func (h *AuditHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrAuditInvalidSource),
+		errors.Is(err, services.ErrAuditInvalidPeriod),
+		errors.Is(err, pagination.ErrInvalidCursor):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch audit trail"})
+	}
+}
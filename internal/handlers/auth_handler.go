@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	
 	"github.com/gofiber/fiber/v2"
@@ -30,7 +31,7 @@ func NewAuthHandler(service services.AuthService) *AuthHandler {
 // @Success 200 {object} models.AuthResponse
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
-// @Router /api/v1/auth/signin [post]
+// @Router /auth/signin [post]
 func (h *AuthHandler) SignIn(c *fiber.Ctx) error {
 	var req models.SignInRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -68,7 +69,7 @@ func (h *AuthHandler) SignIn(c *fiber.Ctx) error {
 // @Param request body models.SignUpRequest true "Registration data"
 // @Success 201 {object} models.AuthResponse
 // @Failure 400 {object} map[string]string
-// @Router /api/v1/auth/signup [post]
+// @Router /auth/signup [post]
 func (h *AuthHandler) SignUp(c *fiber.Ctx) error {
 	var req models.SignUpRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -84,7 +85,7 @@ func (h *AuthHandler) SignUp(c *fiber.Ctx) error {
 		})
 	}
 
-	response, err := h.service.SignUp(&req)
+	response, err := h.service.SignUp(&req, c.IP(), c.Get("User-Agent"))
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
@@ -94,24 +95,31 @@ func (h *AuthHandler) SignUp(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusCreated).JSON(response)
 }
 
-// RefreshToken refreshes the JWT token
+// RefreshToken exchanges the refresh token for a new access token and the next refresh token
 // @Summary Refresh JWT token
 // @Tags Auth
 // @Accept json
 // @Produce json
-// @Param Authorization header string true "Current JWT token"
+// @Param request body models.RefreshTokenRequest true "Current refresh token"
 // @Success 200 {object} models.AuthResponse
 // @Failure 401 {object} map[string]string
-// @Router /api/v1/auth/refresh [post]
+// @Router /auth/refresh [post]
 func (h *AuthHandler) RefreshToken(c *fiber.Ctx) error {
-	token := c.Get("Authorization")
-	if token == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Missing token",
+	var req models.RefreshTokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
 		})
 	}
 
-	response, err := h.service.RefreshToken(token)
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	response, err := h.service.RefreshToken(req.RefreshToken, c.IP(), c.Get("User-Agent"))
 	if err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": err.Error(),
@@ -130,7 +138,7 @@ func (h *AuthHandler) RefreshToken(c *fiber.Ctx) error {
 // @Success 200 {object} map[string]string
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
-// @Router /api/v1/auth/change-password [post]
+// @Router /auth/change-password [post]
 func (h *AuthHandler) ChangePassword(c *fiber.Ctx) error {
 	// Get user ID from JWT token (set by middleware)
 	userIDRaw := c.Locals("userId")
@@ -185,3 +193,64 @@ func (h *AuthHandler) ChangePassword(c *fiber.Ctx) error {
 		"message": "Password changed successfully",
 	})
 }
+
+// ListSessions returns the active sessions of the logged user
+// @Summary List my sessions
+// @Tags Auth
+// @Produce json
+// @Success 200 {array} models.SessionDTO
+// @Router /auth/sessions [get]
+func (h *AuthHandler) ListSessions(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+	sessionID, _ := c.Locals("sessionId").(string)
+
+	sessions, err := h.service.ListSessions(userID, sessionID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch sessions",
+		})
+	}
+	return c.JSON(sessions)
+}
+
+// RevokeSession signs a session of the logged user out
+// @Summary Revoke a session
+// @Tags Auth
+// @Param id path string true "Session ID"
+// @Success 204
+// @Failure 404 {object} map[string]string
+// @Router /auth/sessions/{id} [delete]
+func (h *AuthHandler) RevokeSession(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+
+	if err := h.service.RevokeSession(userID, c.Params("id"), c.IP(), c.Get("User-Agent")); err != nil {
+		if errors.Is(err, services.ErrSessionNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// RevokeAllSessions signs the logged user out of every other session
+// (?includeCurrent=true signs this one out too)
+// @Summary Revoke my other sessions
+// @Tags Auth
+// @Produce json
+// @Success 200 {object} models.RevokeSessionsResult
+// @Router /auth/sessions [delete]
+func (h *AuthHandler) RevokeAllSessions(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+	sessionID, _ := c.Locals("sessionId").(string)
+	if c.QueryBool("includeCurrent") {
+		sessionID = ""
+	}
+
+	revoked, err := h.service.RevokeAllSessions(userID, sessionID, c.IP(), c.Get("User-Agent"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to revoke sessions",
+		})
+	}
+	return c.JSON(models.RevokeSessionsResult{Revoked: revoked})
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type BrandingHandler struct {
+	service  services.BrandingService
+	validate *validator.Validate
+}
+
+func NewBrandingHandler(service services.BrandingService) *BrandingHandler {
+	return &BrandingHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// List returns the branding of every node that has one
+func (h *BrandingHandler) List(c *fiber.Ctx) error {
+	brandings, err := h.service.List()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch brandings",
+		})
+	}
+	return c.JSON(brandings)
+}
+
+// Get returns the branding of a top-level node
+func (h *BrandingHandler) Get(c *fiber.Ctx) error {
+	nodeID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid node ID"})
+	}
+
+	branding, err := h.service.Get(uint(nodeID))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(branding)
+}
+
+// Upsert sets the logo (a NODE_BRANDING file of the node), colors, footer and OS number
+// prefix printed on the documents of a top-level node
+func (h *BrandingHandler) Upsert(c *fiber.Ctx) error {
+	nodeID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid node ID"})
+	}
+
+	var req models.UpsertNodeBrandingRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	userID, _ := c.Locals("userId").(string)
+
+	branding, err := h.service.Upsert(uint(nodeID), &req, userID)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(branding)
+}
+
+// Delete removes the branding of a node; its documents go back to the defaults
+func (h *BrandingHandler) Delete(c *fiber.Ctx) error {
+	nodeID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid node ID"})
+	}
+
+	if err := h.service.Delete(uint(nodeID)); err != nil {
+		return h.handleError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *BrandingHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrBrandingNotFound),
+		errors.Is(err, services.ErrBrandingNodeNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrBrandingNodeNotTopLevel),
+		errors.Is(err, services.ErrBrandingLogoInvalid),
+		errors.Is(err, services.ErrBrandingLogoTooLarge):
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
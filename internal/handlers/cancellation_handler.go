@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type CancellationHandler struct {
+	service  services.CancellationService
+	validate *validator.Validate
+}
+
+func NewCancellationHandler(service services.CancellationService) *CancellationHandler {
+	return &CancellationHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// ListReasons returns the cancellation reasons (?inactive=true to include inactive ones)
+func (h *CancellationHandler) ListReasons(c *fiber.Ctx) error {
+	reasons, err := h.service.WithContext(c.UserContext()).ListReasons(c.QueryBool("inactive"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch cancellation reasons",
+		})
+	}
+	return c.JSON(reasons)
+}
+
+// CreateReason adds a reason to the taxonomy
+func (h *CancellationHandler) CreateReason(c *fiber.Ctx) error {
+	var req models.CreateCancellationReasonRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	reason, err := h.service.WithContext(c.UserContext()).CreateReason(&req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(reason)
+}
+
+// UpdateReason renames, reorders or (de)activates a reason
+func (h *CancellationHandler) UpdateReason(c *fiber.Ctx) error {
+	var req models.UpdateCancellationReasonRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	reason, err := h.service.WithContext(c.UserContext()).UpdateReason(c.Params("id"), &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(reason)
+}
+
+// Cancel cancels a ticket with a reason from the taxonomy
+func (h *CancellationHandler) Cancel(c *fiber.Ctx) error {
+	var req models.CancelTicketRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	userID, _ := c.Locals("userId").(string)
+
+	ticket, err := h.service.WithContext(c.UserContext()).Cancel(c.Params("id"), userID, &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(ticket)
+}
+
+// GetReport returns cancellations per reason, region and period
+// (?from=&to=, default last 90 days; ?period=day|week|month, default month)
+func (h *CancellationHandler) GetReport(c *fiber.Ctx) error {
+	to := time.Now()
+	from := to.AddDate(0, 0, -90)
+	if s := c.Query("from"); s != "" {
+		t, err := parseTime(s)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid from date"})
+		}
+		from = t
+	}
+	if s := c.Query("to"); s != "" {
+		t, err := parseTime(s)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid to date"})
+		}
+		to = t
+	}
+	if !from.Before(to) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "from must be before to"})
+	}
+
+	report, err := h.service.WithContext(c.UserContext()).GetReport(from, to, c.Query("period"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(report)
+}
+
+func (h *CancellationHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrCancellationReasonNotFound),
+		errors.Is(err, services.ErrCancellationTicketNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrCancellationReasonInactive),
+		errors.Is(err, services.ErrCancellationInvalidPeriod):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrCancellationReasonExists),
+		errors.Is(err, services.ErrTicketAlreadyCancelled),
+		errors.Is(err, services.ErrTicketNotCancellable):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
@@ -28,9 +28,9 @@ func (h *CategoryHandler) GetAll(c *fiber.Ctx) error {
 	var err error
 	
 	if categoryType != "" {
-		categories, err = h.repo.GetByTypeWithChildren(models.CategoryType(categoryType))
+		categories, err = h.repo.WithContext(c.UserContext()).GetByTypeWithChildren(models.CategoryType(categoryType))
 	} else {
-		categories, err = h.repo.GetAll()
+		categories, err = h.repo.WithContext(c.UserContext()).GetAll()
 	}
 	
 	if err != nil {
@@ -51,7 +51,7 @@ func (h *CategoryHandler) GetByID(c *fiber.Ctx) error {
 		})
 	}
 
-	category, err := h.repo.GetByID(id)
+	category, err := h.repo.WithContext(c.UserContext()).GetByID(id)
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Category not found",
@@ -77,7 +77,7 @@ func (h *CategoryHandler) Create(c *fiber.Ctx) error {
 
 	// Check if category name already exists for this type (only for parent categories)
 	if category.ParentID == nil {
-		existing, _ := h.repo.GetByNameAndType(category.Name, category.Type)
+		existing, _ := h.repo.WithContext(c.UserContext()).GetByNameAndType(category.Name, category.Type)
 		if existing != nil {
 			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
 				"error": "Category with this name already exists for this type",
@@ -85,7 +85,7 @@ func (h *CategoryHandler) Create(c *fiber.Ctx) error {
 		}
 	}
 
-	if err := h.repo.Create(&category); err != nil {
+	if err := h.repo.WithContext(c.UserContext()).Create(&category); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
 		})
@@ -103,7 +103,7 @@ func (h *CategoryHandler) Update(c *fiber.Ctx) error {
 		})
 	}
 
-	existing, err := h.repo.GetByID(id)
+	existing, err := h.repo.WithContext(c.UserContext()).GetByID(id)
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Category not found",
@@ -124,7 +124,7 @@ func (h *CategoryHandler) Update(c *fiber.Ctx) error {
 	existing.Active = update.Active
 	existing.SortOrder = update.SortOrder
 
-	if err := h.repo.Update(existing); err != nil {
+	if err := h.repo.WithContext(c.UserContext()).Update(existing); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
 		})
@@ -142,7 +142,7 @@ func (h *CategoryHandler) Delete(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := h.repo.Delete(id); err != nil {
+	if err := h.repo.WithContext(c.UserContext()).Delete(id); err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Category not found",
 		})
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type ChatChannelHandler struct {
+	service  services.ChatService
+	validate *validator.Validate
+}
+
+func NewChatChannelHandler(service services.ChatService) *ChatChannelHandler {
+	return &ChatChannelHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// List returns the Slack and Teams channels
+// @Summary List chat channels
+// @Tags Admin
+// @Produce json
+// @Success 200 {array} models.ChatChannel
+// @Router /admin/chat-channels [get]
+func (h *ChatChannelHandler) List(c *fiber.Ctx) error {
+	channels, err := h.service.List()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch chat channels",
+		})
+	}
+	return c.JSON(channels)
+}
+
+// GetByID returns a chat channel
+// @Summary Get chat channel
+// @Tags Admin
+// @Produce json
+// @Param id path string true "Channel ID"
+// @Success 200 {object} models.ChatChannel
+// @Router /admin/chat-channels/{id} [get]
+func (h *ChatChannelHandler) GetByID(c *fiber.Ctx) error {
+	channel, err := h.service.Get(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(channel)
+}
+
+// Create adds a chat channel, global or scoped to a node
+// @Summary Create chat channel
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param body body models.ChatChannelRequest true "Channel"
+// @Success 201 {object} models.ChatChannel
+// @Router /admin/chat-channels [post]
+func (h *ChatChannelHandler) Create(c *fiber.Ctx) error {
+	var req models.ChatChannelRequest
+	if errResponse := h.parse(c, &req); errResponse != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errResponse)
+	}
+	userID, _ := c.Locals("userId").(string)
+	channel, err := h.service.Create(userID, &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(channel)
+}
+
+// Update replaces a chat channel; omit webhookUrl and botToken to keep the current ones
+// @Summary Update chat channel
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Channel ID"
+// @Param body body models.ChatChannelRequest true "Channel"
+// @Success 200 {object} models.ChatChannel
+// @Router /admin/chat-channels/{id} [put]
+func (h *ChatChannelHandler) Update(c *fiber.Ctx) error {
+	var req models.ChatChannelRequest
+	if errResponse := h.parse(c, &req); errResponse != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errResponse)
+	}
+	channel, err := h.service.Update(c.Params("id"), &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(channel)
+}
+
+// Delete removes a chat channel
+// @Summary Delete chat channel
+// @Tags Admin
+// @Param id path string true "Channel ID"
+// @Success 204
+// @Router /admin/chat-channels/{id} [delete]
+func (h *ChatChannelHandler) Delete(c *fiber.Ctx) error {
+	if err := h.service.Delete(c.Params("id")); err != nil {
+		return h.handleError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// Test posts a test message to the channel
+// @Summary Test chat channel
+// @Tags Admin
+// @Produce json
+// @Param id path string true "Channel ID"
+// @Success 200 {object} models.ChatPostResult
+// @Router /admin/chat-channels/{id}/test [post]
+func (h *ChatChannelHandler) Test(c *fiber.Ctx) error {
+	result, err := h.service.Test(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(result)
+}
+
+// SendDigest posts the daily alert digest now
+// @Summary Send chat digest
+// @Tags Admin
+// @Produce json
+// @Success 200 {object} models.ChatDigestResult
+// @Router /admin/chat-channels/digest [post]
+func (h *ChatChannelHandler) SendDigest(c *fiber.Ctx) error {
+	result, err := h.service.SendDigest()
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(result)
+}
+
+// parse reads and validates the body, returning the error response (nil when valid)
+func (h *ChatChannelHandler) parse(c *fiber.Ctx, req interface{}) fiber.Map {
+	if err := c.BodyParser(req); err != nil {
+		return fiber.Map{"error": "Invalid request body"}
+	}
+	if err := h.validate.Struct(req); err != nil {
+		return fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		}
+	}
+	return nil
+}
+
+func (h *ChatChannelHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrChatChannelNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrChatChannelTarget), errors.Is(err, services.ErrChatNodeNotFound):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type ChecklistHandler struct {
+	service  services.ChecklistService
+	validate *validator.Validate
+}
+
+func NewChecklistHandler(service services.ChecklistService) *ChecklistHandler {
+	return &ChecklistHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// ListTemplates returns the checklist templates of every category, or of one with categoryId
+// @Summary List checklist templates
+// @Tags Admin
+// @Produce json
+// @Param categoryId query string false "Ticket category ID"
+// @Success 200 {array} models.ChecklistTemplate
+// @Router /admin/checklist-templates [get]
+func (h *ChecklistHandler) ListTemplates(c *fiber.Ctx) error {
+	if categoryID := c.Query("categoryId"); categoryID != "" {
+		template, err := h.service.WithContext(c.UserContext()).GetTemplate(categoryID)
+		if err != nil {
+			return h.handleError(c, err)
+		}
+		return c.JSON(template)
+	}
+	templates, err := h.service.WithContext(c.UserContext()).ListTemplates()
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(templates)
+}
+
+// SetTemplate creates or replaces the checklist template of a category
+// @Summary Set checklist template
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param body body models.SetChecklistTemplateRequest true "Checklist template"
+// @Success 200 {object} models.ChecklistTemplate
+// @Router /admin/checklist-templates [put]
+func (h *ChecklistHandler) SetTemplate(c *fiber.Ctx) error {
+	var req models.SetChecklistTemplateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+	template, err := h.service.WithContext(c.UserContext()).SetTemplate(&req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(template)
+}
+
+// DeleteTemplate drops the checklist template of a category
+// @Summary Delete checklist template
+// @Tags Admin
+// @Param categoryId path string true "Ticket category ID"
+// @Success 204
+// @Router /admin/checklist-templates/{categoryId} [delete]
+func (h *ChecklistHandler) DeleteTemplate(c *fiber.Ctx) error {
+	if err := h.service.WithContext(c.UserContext()).DeleteTemplate(c.Params("categoryId")); err != nil {
+		return h.handleError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// GetTicketChecklist returns the checklist of a ticket and the mandatory steps pending
+// @Summary Get ticket checklist
+// @Tags Tickets
+// @Produce json
+// @Param id path string true "Ticket ID"
+// @Success 200 {object} models.TicketChecklist
+// @Router /tickets/{id}/checklist [get]
+func (h *ChecklistHandler) GetTicketChecklist(c *fiber.Ctx) error {
+	checklist, err := h.service.WithContext(c.UserContext()).GetTicketChecklist(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(checklist)
+}
+
+// UpdateItem ticks or unticks a step of a ticket checklist
+// @Summary Update ticket checklist item
+// @Tags Tickets
+// @Accept json
+// @Produce json
+// @Param id path string true "Ticket ID"
+// @Param itemId path string true "Checklist item ID"
+// @Param body body models.UpdateChecklistItemRequest true "Item"
+// @Success 200 {object} models.TicketChecklistItem
+// @Router /tickets/{id}/checklist/{itemId} [put]
+func (h *ChecklistHandler) UpdateItem(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+
+	var req models.UpdateChecklistItemRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+	item, err := h.service.WithContext(c.UserContext()).UpdateItem(c.Params("id"), c.Params("itemId"), &req, userID)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(item)
+}
+
+func (h *ChecklistHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrChecklistTemplateNotFound),
+		errors.Is(err, services.ErrChecklistCategoryNotFound),
+		errors.Is(err, services.ErrChecklistTicketNotFound),
+		errors.Is(err, services.ErrChecklistItemNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrChecklistDuplicateItem):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
@@ -0,0 +1,315 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type ClientDocumentHandler struct {
+	service  services.ClientDocumentService
+	validate *validator.Validate
+}
+
+func NewClientDocumentHandler(service services.ClientDocumentService) *ClientDocumentHandler {
+	return &ClientDocumentHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// ListCategories lists the document categories (?active=true for the active ones only)
+func (h *ClientDocumentHandler) ListCategories(c *fiber.Ctx) error {
+	categories, err := h.service.ListCategories(c.QueryBool("active", false))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch document categories",
+		})
+	}
+	return c.JSON(categories)
+}
+
+// CreateCategory creates a document category
+func (h *ClientDocumentHandler) CreateCategory(c *fiber.Ctx) error {
+	var req models.ClientDocumentCategoryRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	category, err := h.service.CreateCategory(&req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(category)
+}
+
+// UpdateCategory updates a document category
+func (h *ClientDocumentHandler) UpdateCategory(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid category ID"})
+	}
+
+	var req models.ClientDocumentCategoryRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	category, err := h.service.UpdateCategory(uint(id), &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(category)
+}
+
+// GetByClient lists the documents of a client, soonest to expire first
+// (?categoryId=&status=VALID|EXPIRING|EXPIRED&page=&size=)
+func (h *ClientDocumentHandler) GetByClient(c *fiber.Ctx) error {
+	return h.list(c, c.Params("id"), c.Query("status"))
+}
+
+// GetExpiring lists the documents of all clients that are expiring or expired
+// (?status=EXPIRING|EXPIRED, default EXPIRING)
+func (h *ClientDocumentHandler) GetExpiring(c *fiber.Ctx) error {
+	status := c.Query("status", models.DocumentStatusExpiring)
+	if status != models.DocumentStatusExpiring && status != models.DocumentStatusExpired {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "status must be EXPIRING or EXPIRED"})
+	}
+	return h.list(c, c.Query("clientId"), status)
+}
+
+func (h *ClientDocumentHandler) list(c *fiber.Ctx, clientID, status string) error {
+	page := c.QueryInt("page", 0)
+	size := pageSize(c, pagination.Default, "size")
+	if page < 0 {
+		page = 0
+	}
+	switch status {
+	case "", models.DocumentStatusValid, models.DocumentStatusExpiring, models.DocumentStatusExpired:
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid status"})
+	}
+
+	role, _ := c.Locals("userRole").(string)
+	filters := &models.ClientDocumentFilters{
+		ClientID:   clientID,
+		CategoryID: uint(c.QueryInt("categoryId", 0)),
+		Status:     status,
+		Role:       role,
+	}
+
+	result, err := h.service.List(page, size, filters)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch client documents",
+		})
+	}
+	return c.JSON(result)
+}
+
+// GetByID returns a document of a client
+func (h *ClientDocumentHandler) GetByID(c *fiber.Ctx) error {
+	document, err := h.service.Get(c.Params("id"), c.Params("docId"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(document)
+}
+
+// Upload stores a document in the vault of a client; multipart with the file under "file"
+// and categoryId, title, issuedAt, expiresAt and notes as form fields
+func (h *ClientDocumentHandler) Upload(c *fiber.Ctx) error {
+	header, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "File is required",
+		})
+	}
+
+	var req models.ClientDocumentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	userID, _ := c.Locals("userId").(string)
+
+	document, err := h.service.Upload(c.Params("id"), userID, &req, header)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(document)
+}
+
+// Update changes the category, title, dates or notes of a document
+func (h *ClientDocumentHandler) Update(c *fiber.Ctx) error {
+	var req models.ClientDocumentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	userID, _ := c.Locals("userId").(string)
+
+	document, err := h.service.Update(c.Params("id"), c.Params("docId"), userID, &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(document)
+}
+
+// Delete removes a document, its file and its download links
+func (h *ClientDocumentHandler) Delete(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+
+	if err := h.service.Delete(c.Params("id"), c.Params("docId"), userID); err != nil {
+		return h.handleError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// Download serves the document file when the role may download its category
+func (h *ClientDocumentHandler) Download(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+	role, _ := c.Locals("userRole").(string)
+
+	document, path, err := h.service.Open(c.Params("id"), c.Params("docId"), userID, role)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Download(path, document.FileName)
+}
+
+// ListLinks lists the download links of a document
+func (h *ClientDocumentHandler) ListLinks(c *fiber.Ctx) error {
+	links, err := h.service.ListLinks(c.Params("id"), c.Params("docId"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(links)
+}
+
+// CreateLink creates a public download link of a document; the token is only returned here
+func (h *ClientDocumentHandler) CreateLink(c *fiber.Ctx) error {
+	var req models.CreateDocumentLinkRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	userID, _ := c.Locals("userId").(string)
+	role, _ := c.Locals("userRole").(string)
+
+	link, err := h.service.CreateLink(c.Params("id"), c.Params("docId"), userID, role, &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(link)
+}
+
+// RevokeLink disables a download link
+func (h *ClientDocumentHandler) RevokeLink(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+
+	if err := h.service.RevokeLink(c.Params("id"), c.Params("docId"), c.Params("linkId"), userID); err != nil {
+		return h.handleError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// PublicDownload serves a document through its download link, without login
+func (h *ClientDocumentHandler) PublicDownload(c *fiber.Ctx) error {
+	document, path, err := h.service.OpenLink(c.Params("token"), c.IP())
+	if err != nil {
+		// Unknown and used up links look the same from outside
+		if errors.Is(err, services.ErrDocumentLinkNotFound) || errors.Is(err, services.ErrDocumentLinkUnavailable) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Link not found or expired"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to open document"})
+	}
+	return c.Download(path, document.FileName)
+}
+
+// RunReminders sends the expiry reminders now instead of waiting for the next run
+func (h *ClientDocumentHandler) RunReminders(c *fiber.Ctx) error {
+	result, err := h.service.SendReminders()
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(result)
+}
+
+func (h *ClientDocumentHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrClientDocumentNotFound),
+		errors.Is(err, services.ErrClientDocumentClient),
+		errors.Is(err, services.ErrDocumentCategoryNotFound),
+		errors.Is(err, services.ErrDocumentLinkNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrDocumentCategoryInactive),
+		errors.Is(err, services.ErrDocumentExpiryRequired),
+		errors.Is(err, services.ErrDocumentInvalidDate),
+		errors.Is(err, services.ErrDocumentExpiresBeforeIssue):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrDocumentCategoryExists):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrDocumentDownloadForbidden):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrAttachmentTooLarge):
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrStorageQuotaExceeded):
+		return c.Status(fiber.StatusInsufficientStorage).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrDocumentInfected):
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
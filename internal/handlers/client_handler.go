@@ -1,41 +1,55 @@
 package handlers
 
 import (
+	"errors"
 	"strconv"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/go-playground/validator/v10"
 	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
 	"github.com/shigake/tech-iq-back/internal/repositories"
+	"github.com/shigake/tech-iq-back/internal/services"
 )
 
 type ClientHandler struct {
 	repo     repositories.ClientRepository
+	geocoder services.GeocodingService
 	validate *validator.Validate
 }
 
-func NewClientHandler(repo repositories.ClientRepository) *ClientHandler {
+func NewClientHandler(repo repositories.ClientRepository, geocoder services.GeocodingService) *ClientHandler {
 	return &ClientHandler{
 		repo:     repo,
+		geocoder: geocoder,
 		validate: validator.New(),
 	}
 }
 
-// GetAll returns paginated list of clients
+// GetAll returns paginated list of clients, optionally with a tag (tag) and in a computed
+// segment (segment=DIMENSION:VALUE)
 func (h *ClientHandler) GetAll(c *fiber.Ctx) error {
 	page, _ := strconv.Atoi(c.Query("page", "0"))
-	size, _ := strconv.Atoi(c.Query("size", "20"))
+	size := pageSize(c, pagination.Clients, "size")
 	search := c.Query("search", "")
+	segment, err := clientSegmentFilter(c, "tag", "segment")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 
 	var clients []models.Client
 	var total int64
-	var err error
 
-	if search != "" {
-		clients, total, err = h.repo.Search(search, page, size)
+	repo := h.repo.WithContext(c.UserContext()).WithScope(accessScope(c))
+	if !segment.IsEmpty() {
+		clients, total, err = repo.Filter(search, segment, page, size)
+	} else if search != "" {
+		clients, total, err = repo.Search(search, page, size)
 	} else {
-		clients, total, err = h.repo.GetAll(page, size)
+		clients, total, err = repo.GetAll(page, size)
 	}
 
 	if err != nil {
@@ -49,18 +63,7 @@ func (h *ClientHandler) GetAll(c *fiber.Ctx) error {
 		dtos[i] = client.ToDTO()
 	}
 
-	totalPages := int(total) / size
-	if int(total)%size > 0 {
-		totalPages++
-	}
-
-	return c.JSON(fiber.Map{
-		"content":       dtos,
-		"page":          page,
-		"size":          size,
-		"totalElements": total,
-		"totalPages":    totalPages,
-	})
+	return c.JSON(models.NewPaginatedResponse(dtos, page, size, total))
 }
 
 // GetByID returns a client by ID
@@ -72,7 +75,7 @@ func (h *ClientHandler) GetByID(c *fiber.Ctx) error {
 		})
 	}
 
-	client, err := h.repo.GetByID(id)
+	client, err := h.repo.WithContext(c.UserContext()).WithScope(accessScope(c)).GetByID(id)
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Client not found",
@@ -129,16 +132,18 @@ func (h *ClientHandler) Create(c *fiber.Ctx) error {
 		State:             getStringFromMap(body, "state"),
 		ZipCode:           getStringFromMap(body, "zipCode"),
 	}
+	client.NodeID, _ = getNodeIDFromMap(body, "nodeId")
 	
 	// Sanitize empty strings to avoid unique constraint issues
 	client.CPF = sanitizeUniqueField(client.CPF)
 	client.CNPJ = sanitizeUniqueField(client.CNPJ)
 
-	if err := h.repo.Create(&client); err != nil {
+	if err := h.repo.WithContext(c.UserContext()).Create(&client); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
+	h.geocode(client)
 
 	return c.Status(fiber.StatusCreated).JSON(client)
 }
@@ -153,6 +158,20 @@ func getStringFromMap(m map[string]interface{}, key string) string {
 	return ""
 }
 
+// getNodeIDFromMap extracts a hierarchy node ID; ok is false when the key is absent and
+// the ID is nil when the key is null (no node)
+func getNodeIDFromMap(m map[string]interface{}, key string) (id *uint, ok bool) {
+	v, exists := m[key]
+	if !exists {
+		return nil, false
+	}
+	if f, isNumber := v.(float64); isNumber && f > 0 {
+		nodeID := uint(f)
+		return &nodeID, true
+	}
+	return nil, true
+}
+
 // sanitizeUniqueField returns empty string as is but trims whitespace
 // For the unique index to work correctly, we need to handle this at DB level
 func sanitizeUniqueField(s string) string {
@@ -169,7 +188,7 @@ func (h *ClientHandler) Update(c *fiber.Ctx) error {
 		})
 	}
 
-	existing, err := h.repo.GetByID(id)
+	existing, err := h.repo.WithContext(c.UserContext()).WithScope(accessScope(c)).GetByID(id)
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Client not found",
@@ -195,6 +214,8 @@ func (h *ClientHandler) Update(c *fiber.Ctx) error {
 		}
 	}
 
+	address := addressOf(existing)
+
 	// Update other fields
 	if v := getStringFromMap(body, "cpf"); v != "" || body["cpf"] != nil {
 		existing.CPF = sanitizeUniqueField(v)
@@ -232,16 +253,68 @@ func (h *ClientHandler) Update(c *fiber.Ctx) error {
 	if v := getStringFromMap(body, "zipCode"); v != "" || body["zipCode"] != nil {
 		existing.ZipCode = v
 	}
+	if nodeID, ok := getNodeIDFromMap(body, "nodeId"); ok {
+		existing.NodeID = nodeID
+	}
+	addressChanged := addressOf(existing) != address
+	if addressChanged {
+		existing.Latitude, existing.Longitude = nil, nil
+		existing.GeoPrecision = ""
+		existing.GeocodedAt = nil
+	}
 
-	if err := h.repo.Update(existing); err != nil {
+	// The edit is saved only over the version it was based on
+	if v, ok := body["version"].(float64); ok && v > 0 {
+		existing.Version = int(v)
+	}
+	if err := h.repo.WithContext(c.UserContext()).WithScope(accessScope(c)).Update(existing); err != nil {
+		if errors.Is(err, repositories.ErrVersionConflict) {
+			if current, findErr := h.repo.WithContext(c.UserContext()).WithScope(accessScope(c)).GetByID(id); findErr == nil {
+				conflict := services.NewVersionConflict("client", existing.Version, existing, current, current.Version)
+				return c.Status(fiber.StatusConflict).JSON(conflict.Response())
+			}
+		}
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
+	if addressChanged {
+		h.geocode(*existing)
+	}
 
 	return c.JSON(existing)
 }
 
+// Geocode geocodes the client address again and stores the coordinates
+func (h *ClientHandler) Geocode(c *fiber.Ctx) error {
+	client, err := h.geocoder.Regeocode(c.Params("id"))
+	if errors.Is(err, services.ErrGeocodeClientNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Client not found",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to geocode client",
+		})
+	}
+	return c.JSON(client)
+}
+
+// geocode finds the coordinates of the client address in the background; the backfill job
+// retries the clients it misses
+func (h *ClientHandler) geocode(client models.Client) {
+	if h.geocoder == nil {
+		return
+	}
+	go h.geocoder.Locate(&client)
+}
+
+// addressOf returns the address fields used for geocoding
+func addressOf(client *models.Client) [6]string {
+	return [6]string{client.Street, client.Number, client.Neighborhood, client.City, client.State, client.ZipCode}
+}
+
 // Delete deletes a client
 func (h *ClientHandler) Delete(c *fiber.Ctx) error {
 	id := c.Params("id")
@@ -251,7 +324,7 @@ func (h *ClientHandler) Delete(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := h.repo.Delete(id); err != nil {
+	if err := h.repo.WithContext(c.UserContext()).WithScope(accessScope(c)).Delete(id); err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Client not found",
 		})
@@ -262,7 +335,7 @@ func (h *ClientHandler) Delete(c *fiber.Ctx) error {
 
 // Count returns total number of clients
 func (h *ClientHandler) Count(c *fiber.Ctx) error {
-	count, err := h.repo.Count()
+	count, err := h.repo.WithContext(c.UserContext()).WithScope(accessScope(c)).Count()
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to count clients",
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type ClientSegmentHandler struct {
+	service  services.ClientSegmentService
+	validate *validator.Validate
+}
+
+func NewClientSegmentHandler(service services.ClientSegmentService) *ClientSegmentHandler {
+	return &ClientSegmentHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// ListTags returns the client tags with the number of tagged clients
+// @Summary List client tags
+// @Tags Clients
+// @Produce json
+// @Success 200 {array} models.ClientTag
+// @Router /client-tags [get]
+func (h *ClientSegmentHandler) ListTags(c *fiber.Ctx) error {
+	tags, err := h.service.ListTags()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch client tags",
+		})
+	}
+	return c.JSON(tags)
+}
+
+// CreateTag creates a client tag
+// @Summary Create client tag
+// @Tags Clients
+// @Accept json
+// @Produce json
+// @Param body body models.ClientTagRequest true "Tag"
+// @Success 201 {object} models.ClientTag
+// @Router /client-tags [post]
+func (h *ClientSegmentHandler) CreateTag(c *fiber.Ctx) error {
+	var req models.ClientTagRequest
+	if errResponse := h.parse(c, &req); errResponse != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errResponse)
+	}
+	tag, err := h.service.CreateTag(&req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(tag)
+}
+
+// UpdateTag renames or recolors a client tag
+// @Summary Update client tag
+// @Tags Clients
+// @Accept json
+// @Produce json
+// @Param id path string true "Tag ID"
+// @Param body body models.ClientTagRequest true "Tag"
+// @Success 200 {object} models.ClientTag
+// @Router /client-tags/{id} [put]
+func (h *ClientSegmentHandler) UpdateTag(c *fiber.Ctx) error {
+	var req models.ClientTagRequest
+	if errResponse := h.parse(c, &req); errResponse != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errResponse)
+	}
+	tag, err := h.service.UpdateTag(c.Params("id"), &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(tag)
+}
+
+// DeleteTag removes a client tag from every client and deletes it
+// @Summary Delete client tag
+// @Tags Clients
+// @Param id path string true "Tag ID"
+// @Success 204
+// @Router /client-tags/{id} [delete]
+func (h *ClientSegmentHandler) DeleteTag(c *fiber.Ctx) error {
+	if err := h.service.DeleteTag(c.Params("id")); err != nil {
+		return h.handleError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// GetClient returns the tags and computed segments of a client
+// @Summary Client tags and segments
+// @Tags Clients
+// @Produce json
+// @Param id path string true "Client ID"
+// @Success 200 {object} models.ClientSegmentation
+// @Router /clients/{id}/segments [get]
+func (h *ClientSegmentHandler) GetClient(c *fiber.Ctx) error {
+	segmentation, err := h.service.GetClient(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(segmentation)
+}
+
+// SetClientTags replaces the tags of a client
+// @Summary Set client tags
+// @Tags Clients
+// @Accept json
+// @Produce json
+// @Param id path string true "Client ID"
+// @Param body body models.SetClientTagsRequest true "Tags"
+// @Success 200 {object} models.ClientSegmentation
+// @Router /clients/{id}/tags [put]
+func (h *ClientSegmentHandler) SetClientTags(c *fiber.Ctx) error {
+	var req models.SetClientTagsRequest
+	if errResponse := h.parse(c, &req); errResponse != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errResponse)
+	}
+	segmentation, err := h.service.SetClientTags(c.Params("id"), &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(segmentation)
+}
+
+// GetSummary counts the clients of each computed segment
+// @Summary Client segments summary
+// @Tags Clients
+// @Produce json
+// @Success 200 {object} models.ClientSegmentSummary
+// @Router /client-segments [get]
+func (h *ClientSegmentHandler) GetSummary(c *fiber.Ctx) error {
+	summary, err := h.service.Summary()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch client segments",
+		})
+	}
+	return c.JSON(summary)
+}
+
+// Recalculate recomputes the segments of every client now instead of waiting for the job
+// @Summary Recalculate client segments
+// @Tags Clients
+// @Produce json
+// @Success 200 {object} models.ClientSegmentSummary
+// @Router /client-segments/recalculate [post]
+func (h *ClientSegmentHandler) Recalculate(c *fiber.Ctx) error {
+	summary, err := h.service.Recalculate()
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(summary)
+}
+
+// parse reads and validates the body, returning the error response (nil when valid)
+func (h *ClientSegmentHandler) parse(c *fiber.Ctx, req interface{}) fiber.Map {
+	if err := c.BodyParser(req); err != nil {
+		return fiber.Map{"error": "Invalid request body"}
+	}
+	if err := h.validate.Struct(req); err != nil {
+		return fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		}
+	}
+	return nil
+}
+
+func (h *ClientSegmentHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrClientTagNotFound), errors.Is(err, services.ErrClientNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrClientTagNameTaken):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
+
+// clientSegmentFilter reads the client tag and segment filter from the query parameters
+func clientSegmentFilter(c *fiber.Ctx, tagParam, segmentParam string) (models.ClientSegmentFilter, error) {
+	filter := models.ClientSegmentFilter{TagID: c.Query(tagParam), Segment: c.Query(segmentParam)}
+	if !filter.IsValid() {
+		return filter, services.ErrInvalidClientSegment
+	}
+	return filter, nil
+}
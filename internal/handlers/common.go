@@ -5,6 +5,8 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/go-playground/validator/v10"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
 )
 
 // ErrorResponse is a standard error response
@@ -52,6 +54,32 @@ func formatValidationErrors(err error) map[string]string {
 	return errors
 }
 
+// accessScope returns the hierarchy scope of the authenticated user, restricting the lists
+// of tickets, clients and technicians to the nodes of the user's memberships
+func accessScope(c *fiber.Ctx) *models.AccessScope {
+	userID, _ := c.Locals("userId").(string)
+	role, _ := c.Locals("userRole").(string)
+	return models.NewAccessScope(userID, role)
+}
+
+// pageSize reads the page size parameter, defaulted and capped by the limits of the resource
+func pageSize(c *fiber.Ctx, resource, key string) int {
+	return pagination.Size(resource, c.QueryInt(key, 0))
+}
+
+// queryCursor reads the ?cursor= parameter of the listings paged by keyset; nil when it
+// is absent (page/limit paging), the first page when it is empty
+func queryCursor(c *fiber.Ctx) (*pagination.Cursor, error) {
+	if !c.Context().QueryArgs().Has("cursor") {
+		return nil, nil
+	}
+	cursor, err := pagination.DecodeCursor(c.Query("cursor"))
+	if err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
+
 // parseTime parses a time string in multiple formats
 func parseTime(s string) (time.Time, error) {
 	formats := []string{
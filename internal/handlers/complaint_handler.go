@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type ComplaintHandler struct {
+	service  services.ComplaintService
+	validate *validator.Validate
+}
+
+func NewComplaintHandler(service services.ComplaintService) *ComplaintHandler {
+	return &ComplaintHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// List returns complaint tickets, closest SLA deadline first (?status=open|closed&technicianId=&rootCause=)
+func (h *ComplaintHandler) List(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 0)
+	size := pageSize(c, pagination.Default, "size")
+	if page < 0 {
+		page = 0
+	}
+
+	filters := &models.ComplaintFilters{
+		Status:       c.Query("status"),
+		TechnicianID: c.Query("technicianId"),
+		RootCause:    c.Query("rootCause"),
+	}
+
+	result, err := h.service.List(page, size, filters)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch complaints",
+		})
+	}
+	return c.JSON(result)
+}
+
+// Get returns a complaint ticket with its ombudsman data
+func (h *ComplaintHandler) Get(c *fiber.Ctx) error {
+	complaint, err := h.service.Get(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(complaint)
+}
+
+// Create opens a complaint about a service ticket
+func (h *ComplaintHandler) Create(c *fiber.Ctx) error {
+	var req models.CreateComplaintRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	userID, _ := c.Locals("userId").(string)
+
+	complaint, err := h.service.Create(userID, &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(complaint)
+}
+
+// Classify records the root cause of a complaint
+func (h *ComplaintHandler) Classify(c *fiber.Ctx) error {
+	var req models.ClassifyComplaintRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	userID, _ := c.Locals("userId").(string)
+
+	complaint, err := h.service.Classify(c.Params("id"), userID, &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(complaint)
+}
+
+// Close classifies the root cause and closes the complaint
+func (h *ComplaintHandler) Close(c *fiber.Ctx) error {
+	var req models.ClassifyComplaintRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	userID, _ := c.Locals("userId").(string)
+
+	complaint, err := h.service.Close(c.Params("id"), userID, &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(complaint)
+}
+
+// GetReport returns complaints per technician, category and root cause (?from=&to=, default last 90 days)
+func (h *ComplaintHandler) GetReport(c *fiber.Ctx) error {
+	to := time.Now()
+	from := to.AddDate(0, 0, -90)
+	if s := c.Query("from"); s != "" {
+		t, err := parseTime(s)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid from date"})
+		}
+		from = t
+	}
+	if s := c.Query("to"); s != "" {
+		t, err := parseTime(s)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid to date"})
+		}
+		to = t
+	}
+	if !from.Before(to) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "from must be before to"})
+	}
+
+	report, err := h.service.GetReport(from, to)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to build complaint report",
+		})
+	}
+	return c.JSON(report)
+}
+
+func (h *ComplaintHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrComplaintNotFound),
+		errors.Is(err, services.ErrComplaintOriginalNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrComplaintOriginalIsComplaint),
+		errors.Is(err, services.ErrComplaintTechnicianNotOnTicket),
+		errors.Is(err, services.ErrComplaintInvalidRootCause):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrComplaintAlreadyClosed):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type ComplianceHandler struct {
+	service services.ComplianceService
+}
+
+func NewComplianceHandler(service services.ComplianceService) *ComplianceHandler {
+	return &ComplianceHandler{service: service}
+}
+
+// GetExpiryReport returns the tracked expirations due within ?days= (default 30, max 365),
+// already expired included, grouped by responsible node
+func (h *ComplianceHandler) GetExpiryReport(c *fiber.Ctx) error {
+	report, err := h.service.GetExpiryReport(c.QueryInt("days", 0))
+	if err != nil {
+		if errors.Is(err, services.ErrComplianceInvalidDays) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(report)
+}
@@ -0,0 +1,113 @@
+//go:build integration
+
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/handlers"
+	"github.com/shigake/tech-iq-back/internal/middleware"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"github.com/shigake/tech-iq-back/internal/services"
+	"github.com/shigake/tech-iq-back/internal/testutil"
+)
+
+// missingID is a well-formed ID no fixture uses
+const missingID = "00000000-0000-4000-8000-0000000009ff"
+
+func ticketsApp() *fiber.App {
+	categoryRepo := repositories.NewCategoryRepository(env.DB)
+	ticketService := services.NewTicketService(
+		repositories.NewTicketRepository(env.DB),
+		repositories.NewTechnicianRepository(env.DB),
+		repositories.NewClientRepository(env.DB),
+		categoryRepo,
+		nil,
+		models.CoverageEnforcementOff,
+		nil,
+		nil,
+		services.NewTicketWorkflowService(repositories.NewTicketWorkflowRepository(env.DB), categoryRepo),
+		nil,
+	)
+	ticketHandler := handlers.NewTicketHandler(ticketService, nil)
+
+	app := fiber.New()
+	tickets := app.Group("/tickets", middleware.JWTProtected(env.Config.JWTSecret, nil, nil))
+	tickets.Get("/", ticketHandler.GetAll)
+	tickets.Post("/", ticketHandler.Create)
+	tickets.Get("/:id", ticketHandler.GetByID)
+	tickets.Put("/:id", ticketHandler.Update)
+	tickets.Delete("/:id", ticketHandler.Delete)
+	tickets.Put("/:id/status", ticketHandler.UpdateStatus)
+	tickets.Get("/:id/transitions", ticketHandler.GetTransitions)
+	tickets.Put("/:id/assign", ticketHandler.AssignTechnician)
+	tickets.Get("/:id/assignments", ticketHandler.GetAssignments)
+	tickets.Get("/:id/payout-split", ticketHandler.GetPayoutSplit)
+	return app
+}
+
+// TestTicketResponsesMatchContract walks a ticket through the API and checks every
+// response against the spec generated from the handler annotations
+func TestTicketResponsesMatchContract(t *testing.T) {
+	spec := testutil.LoadSpec(t)
+	env.Reset(t)
+	app := ticketsApp()
+	token := env.AdminToken(t)
+
+	var ticket models.Ticket
+	testutil.Do(t, app, http.MethodPost, "/tickets", models.CreateTicketRequest{
+		ErrorDescription: "Contract ticket",
+		ClientID:         testutil.ClientID,
+	}, token).Expect(t, http.StatusCreated).Conforms(t, spec, http.MethodPost, "/tickets").JSON(t, &ticket)
+	path := "/tickets/" + ticket.ID
+
+	testutil.Do(t, app, http.MethodPost, "/tickets", map[string]string{}, token).
+		Expect(t, http.StatusBadRequest).Conforms(t, spec, http.MethodPost, "/tickets")
+	testutil.Do(t, app, http.MethodGet, "/tickets?size=10", nil, token).
+		Expect(t, http.StatusOK).Conforms(t, spec, http.MethodGet, "/tickets")
+	testutil.Do(t, app, http.MethodGet, path, nil, token).
+		Expect(t, http.StatusOK).Conforms(t, spec, http.MethodGet, "/tickets/{id}")
+	testutil.Do(t, app, http.MethodGet, "/tickets/"+missingID, nil, token).
+		Expect(t, http.StatusNotFound).Conforms(t, spec, http.MethodGet, "/tickets/{id}")
+
+	edit := models.CreateTicketRequest{ErrorDescription: "Contract ticket, edited", ClientID: testutil.ClientID, Version: ticket.Version}
+	testutil.Do(t, app, http.MethodPut, path, edit, token).
+		Expect(t, http.StatusOK).Conforms(t, spec, http.MethodPut, "/tickets/{id}")
+	testutil.Do(t, app, http.MethodPut, path, edit, token).
+		Expect(t, http.StatusConflict).Conforms(t, spec, http.MethodPut, "/tickets/{id}")
+
+	testutil.Do(t, app, http.MethodPut, path+"/assign", models.AssignTechnicianRequest{TechnicianIDs: []string{testutil.TechnicianID}}, token).
+		Expect(t, http.StatusOK).Conforms(t, spec, http.MethodPut, "/tickets/{id}/assign")
+	testutil.Do(t, app, http.MethodGet, path+"/assignments", nil, token).
+		Expect(t, http.StatusOK).Conforms(t, spec, http.MethodGet, "/tickets/{id}/assignments")
+	testutil.Do(t, app, http.MethodGet, path+"/payout-split?amount=150", nil, token).
+		Expect(t, http.StatusOK).Conforms(t, spec, http.MethodGet, "/tickets/{id}/payout-split")
+	testutil.Do(t, app, http.MethodGet, path+"/transitions", nil, token).
+		Expect(t, http.StatusOK).Conforms(t, spec, http.MethodGet, "/tickets/{id}/transitions")
+	testutil.Do(t, app, http.MethodPut, path+"/status", models.UpdateStatusRequest{Status: string(models.TicketStatusInProgress)}, token).
+		Expect(t, http.StatusOK).Conforms(t, spec, http.MethodPut, "/tickets/{id}/status")
+
+	testutil.Do(t, app, http.MethodDelete, path, nil, token).
+		Expect(t, http.StatusNoContent).Conforms(t, spec, http.MethodDelete, "/tickets/{id}")
+}
+
+func TestTechnicianResponsesMatchContract(t *testing.T) {
+	spec := testutil.LoadSpec(t)
+	env.Reset(t)
+	technicianHandler := handlers.NewTechnicianHandler(services.NewTechnicianService(repositories.NewTechnicianRepository(env.DB), env.Redis))
+	app := fiber.New()
+	technicians := app.Group("/technicians", middleware.JWTProtected(env.Config.JWTSecret, nil, nil))
+	technicians.Get("/", technicianHandler.GetAll)
+	technicians.Get("/:id", technicianHandler.GetByID)
+	token := env.AdminToken(t)
+
+	testutil.Do(t, app, http.MethodGet, "/technicians", nil, token).
+		Expect(t, http.StatusOK).Conforms(t, spec, http.MethodGet, "/technicians")
+	testutil.Do(t, app, http.MethodGet, "/technicians/"+testutil.TechnicianID, nil, token).
+		Expect(t, http.StatusOK).Conforms(t, spec, http.MethodGet, "/technicians/{id}")
+	testutil.Do(t, app, http.MethodGet, "/technicians/"+missingID, nil, token).
+		Expect(t, http.StatusNotFound).Conforms(t, spec, http.MethodGet, "/technicians/{id}")
+}
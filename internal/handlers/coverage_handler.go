@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type CoverageHandler struct {
+	service  services.CoverageService
+	validate *validator.Validate
+}
+
+func NewCoverageHandler(service services.CoverageService) *CoverageHandler {
+	return &CoverageHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// ListAreas returns coverage areas (?ownerType=BRANCH|TECHNICIAN&ownerId=&inactive=true)
+func (h *CoverageHandler) ListAreas(c *fiber.Ctx) error {
+	filters := &models.CoverageAreaFilters{
+		OwnerType: c.Query("ownerType"),
+		OwnerID:   c.Query("ownerId"),
+		Inactive:  c.QueryBool("inactive"),
+	}
+
+	areas, err := h.service.List(filters)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch coverage areas",
+		})
+	}
+	return c.JSON(areas)
+}
+
+// GetArea returns a coverage area
+func (h *CoverageHandler) GetArea(c *fiber.Ctx) error {
+	area, err := h.service.Get(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(area)
+}
+
+// CreateArea adds a serviceable area to a branch or technician
+func (h *CoverageHandler) CreateArea(c *fiber.Ctx) error {
+	var req models.CoverageAreaRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	userID, _ := c.Locals("userId").(string)
+
+	area, err := h.service.Create(userID, &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(area)
+}
+
+// UpdateArea replaces a coverage area definition
+func (h *CoverageHandler) UpdateArea(c *fiber.Ctx) error {
+	var req models.CoverageAreaRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	area, err := h.service.Update(c.Params("id"), &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(area)
+}
+
+// DeleteArea removes a coverage area
+func (h *CoverageHandler) DeleteArea(c *fiber.Ctx) error {
+	if err := h.service.Delete(c.Params("id")); err != nil {
+		return h.handleError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// Check tells whether a site is covered and by whom (?clientId= | ?city=&state= | ?lat=&lng=)
+func (h *CoverageHandler) Check(c *fiber.Ctx) error {
+	req := &models.CoverageCheckRequest{
+		ClientID: c.Query("clientId"),
+		City:     c.Query("city"),
+		State:    c.Query("state"),
+	}
+	if c.Query("lat") != "" || c.Query("lng") != "" {
+		lat, errLat := strconv.ParseFloat(c.Query("lat"), 64)
+		lng, errLng := strconv.ParseFloat(c.Query("lng"), 64)
+		if errLat != nil || errLng != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid lat/lng"})
+		}
+		req.Latitude, req.Longitude = &lat, &lng
+	}
+
+	result, err := h.service.Check(req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(result)
+}
+
+func (h *CoverageHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrCoverageAreaNotFound),
+		errors.Is(err, services.ErrCoverageClientNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrCoverageOwnerNotFound),
+		errors.Is(err, services.ErrCoverageOwnerNotBranch),
+		errors.Is(err, services.ErrCoverageNoMunicipalities),
+		errors.Is(err, services.ErrCoverageInvalidPolygon),
+		errors.Is(err, services.ErrCoverageSiteRequired):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
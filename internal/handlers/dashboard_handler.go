@@ -13,9 +13,9 @@ func NewDashboardHandler(service services.DashboardService) *DashboardHandler {
 	return &DashboardHandler{service: service}
 }
 
-// GetStats returns dashboard statistics
+// GetStats returns dashboard statistics; ?fresh=true bypasses the cache
 func (h *DashboardHandler) GetStats(c *fiber.Ctx) error {
-	stats, err := h.service.GetStats()
+	stats, err := h.service.WithContext(c.UserContext()).GetStats(c.QueryBool("fresh"))
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch stats",
@@ -24,9 +24,20 @@ func (h *DashboardHandler) GetStats(c *fiber.Ctx) error {
 	return c.JSON(stats)
 }
 
-// GetTicketsByStatus returns tickets grouped by status
+// GetTicketsByStatus returns tickets grouped by status; ?fresh=true bypasses the cache
 func (h *DashboardHandler) GetTicketsByStatus(c *fiber.Ctx) error {
-	data, err := h.service.GetTicketsByStatus()
+	data, err := h.service.WithContext(c.UserContext()).GetTicketsByStatus(c.QueryBool("fresh"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch data",
+		})
+	}
+	return c.JSON(data)
+}
+
+// GetTicketsBySource returns tickets grouped by source channel
+func (h *DashboardHandler) GetTicketsBySource(c *fiber.Ctx) error {
+	data, err := h.service.WithContext(c.UserContext()).GetTicketsBySource()
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch data",
@@ -37,7 +48,7 @@ func (h *DashboardHandler) GetTicketsByStatus(c *fiber.Ctx) error {
 
 // GetTechniciansByState returns technicians grouped by state
 func (h *DashboardHandler) GetTechniciansByState(c *fiber.Ctx) error {
-	data, err := h.service.GetTechniciansByState()
+	data, err := h.service.WithContext(c.UserContext()).GetTechniciansByState()
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch data",
@@ -46,9 +57,9 @@ func (h *DashboardHandler) GetTechniciansByState(c *fiber.Ctx) error {
 	return c.JSON(data)
 }
 
-// GetChartData returns chart data for the dashboard
+// GetChartData returns chart data for the dashboard; ?fresh=true bypasses the cache
 func (h *DashboardHandler) GetChartData(c *fiber.Ctx) error {
-	data, err := h.service.GetTicketsByStatus()
+	data, err := h.service.WithContext(c.UserContext()).GetTicketsByStatus(c.QueryBool("fresh"))
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch chart data",
@@ -71,7 +82,7 @@ func (h *DashboardHandler) GetChartData(c *fiber.Ctx) error {
 func (h *DashboardHandler) GetRecentActivity(c *fiber.Ctx) error {
 	limit := c.QueryInt("limit", 10)
 	
-	activities, err := h.service.GetRecentActivity(limit)
+	activities, err := h.service.WithContext(c.UserContext()).GetRecentActivity(limit)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch recent activity",
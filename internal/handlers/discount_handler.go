@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type DiscountHandler struct {
+	service  services.DiscountService
+	validate *validator.Validate
+}
+
+func NewDiscountHandler(service services.DiscountService) *DiscountHandler {
+	return &DiscountHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// GetLimits returns the discount limit of each role
+func (h *DiscountHandler) GetLimits(c *fiber.Ctx) error {
+	limits, err := h.service.WithContext(c.UserContext()).GetLimits()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch discount limits",
+		})
+	}
+	return c.JSON(limits)
+}
+
+// SetLimit changes the discount limit of a role
+func (h *DiscountHandler) SetLimit(c *fiber.Ctx) error {
+	var req models.UpdateDiscountLimitRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	userID, _ := c.Locals("userId").(string)
+
+	limit, err := h.service.WithContext(c.UserContext()).SetLimit(c.Params("role"), userID, &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(limit)
+}
+
+// List returns discounts, newest first (?status=&userId=&documentType=&documentRef=&from=&to=)
+func (h *DiscountHandler) List(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 0)
+	size := pageSize(c, pagination.Default, "size")
+	if page < 0 {
+		page = 0
+	}
+
+	filters := &models.DiscountFilters{
+		Status:       c.Query("status"),
+		RequestedBy:  c.Query("userId"),
+		DocumentType: c.Query("documentType"),
+		DocumentRef:  c.Query("documentRef"),
+	}
+	if s := c.Query("from"); s != "" {
+		t, err := parseTime(s)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid from date"})
+		}
+		filters.From = &t
+	}
+	if s := c.Query("to"); s != "" {
+		t, err := parseTime(s)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid to date"})
+		}
+		filters.To = &t
+	}
+
+	result, err := h.service.WithContext(c.UserContext()).List(page, size, filters)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch discounts",
+		})
+	}
+	return c.JSON(result)
+}
+
+// Get returns a discount with its approval trail
+func (h *DiscountHandler) Get(c *fiber.Ctx) error {
+	discount, err := h.service.WithContext(c.UserContext()).Get(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(discount)
+}
+
+// Create applies a discount on a quote or invoice; beyond the role limit it answers 202
+// and waits for approval
+func (h *DiscountHandler) Create(c *fiber.Ctx) error {
+	var req models.CreateDiscountRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	userID, _ := c.Locals("userId").(string)
+
+	discount, err := h.service.WithContext(c.UserContext()).Create(userID, &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	if discount.Status == models.DiscountStatusPending {
+		return c.Status(fiber.StatusAccepted).JSON(discount)
+	}
+	return c.Status(fiber.StatusCreated).JSON(discount)
+}
+
+// Approve applies a pending discount
+func (h *DiscountHandler) Approve(c *fiber.Ctx) error {
+	return h.decide(c, h.service.Approve)
+}
+
+// Reject refuses a pending discount
+func (h *DiscountHandler) Reject(c *fiber.Ctx) error {
+	return h.decide(c, h.service.Reject)
+}
+
+func (h *DiscountHandler) decide(c *fiber.Ctx, decide func(id, userID string, req *models.DiscountDecisionRequest) (*models.Discount, error)) error {
+	var req models.DiscountDecisionRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+
+	userID, _ := c.Locals("userId").(string)
+
+	discount, err := decide(c.Params("id"), userID, &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(discount)
+}
+
+// GetReport returns the discounts given per user (?from=&to=, default last 30 days)
+func (h *DiscountHandler) GetReport(c *fiber.Ctx) error {
+	to := time.Now()
+	from := to.AddDate(0, 0, -30)
+	if s := c.Query("from"); s != "" {
+		t, err := parseTime(s)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid from date"})
+		}
+		from = t
+	}
+	if s := c.Query("to"); s != "" {
+		t, err := parseTime(s)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid to date"})
+		}
+		to = t
+	}
+	if !from.Before(to) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "from must be before to"})
+	}
+
+	report, err := h.service.WithContext(c.UserContext()).GetReport(from, to)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to build discount report",
+		})
+	}
+	return c.JSON(report)
+}
+
+func (h *DiscountHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrDiscountNotFound),
+		errors.Is(err, services.ErrDiscountTicketNotFound),
+		errors.Is(err, services.ErrDiscountUserNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrDiscountInvalidRole),
+		errors.Is(err, services.ErrDiscountInvalidLimit),
+		errors.Is(err, services.ErrDiscountInvalidAmount),
+		errors.Is(err, services.ErrDiscountInvalidValue):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrDiscountSelfApproval):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrDiscountNotPending):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
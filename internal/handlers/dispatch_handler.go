@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type DispatchHandler struct {
+	service  services.DispatchService
+	validate *validator.Validate
+}
+
+func NewDispatchHandler(service services.DispatchService) *DispatchHandler {
+	return &DispatchHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// ListRules returns all auto-dispatch rules in evaluation order
+func (h *DispatchHandler) ListRules(c *fiber.Ctx) error {
+	rules, err := h.service.WithContext(c.UserContext()).ListRules()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch dispatch rules",
+		})
+	}
+	return c.JSON(rules)
+}
+
+// CreateRule creates a new auto-dispatch rule
+func (h *DispatchHandler) CreateRule(c *fiber.Ctx) error {
+	var req models.CreateDispatchRuleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	userID, _ := c.Locals("userId").(string)
+
+	rule, err := h.service.WithContext(c.UserContext()).CreateRule(&req, userID)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(rule)
+}
+
+// UpdateRule replaces an auto-dispatch rule
+func (h *DispatchHandler) UpdateRule(c *fiber.Ctx) error {
+	var req models.CreateDispatchRuleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	rule, err := h.service.WithContext(c.UserContext()).UpdateRule(c.Params("id"), &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(rule)
+}
+
+// DeleteRule removes an auto-dispatch rule
+func (h *DispatchHandler) DeleteRule(c *fiber.Ctx) error {
+	if err := h.service.WithContext(c.UserContext()).DeleteRule(c.Params("id")); err != nil {
+		return h.handleError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// GetDecisions returns the audit trail of automatic dispatch decisions
+func (h *DispatchHandler) GetDecisions(c *fiber.Ctx) error {
+	filter := &models.DispatchDecisionFilter{
+		TicketID: c.Query("ticketId"),
+		Outcome:  c.Query("outcome"),
+		Page:     c.QueryInt("page", 1),
+		PageSize: c.QueryInt("pageSize", 20),
+	}
+
+	decisions, err := h.service.WithContext(c.UserContext()).GetDecisions(filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch dispatch decisions",
+		})
+	}
+	return c.JSON(decisions)
+}
+
+// GetManualQueue returns tickets the dispatcher could not assign in time
+func (h *DispatchHandler) GetManualQueue(c *fiber.Ctx) error {
+	tickets, err := h.service.WithContext(c.UserContext()).GetManualQueue()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch manual queue",
+		})
+	}
+	return c.JSON(tickets)
+}
+
+// Run triggers a dispatch pass immediately instead of waiting for the next tick
+func (h *DispatchHandler) Run(c *fiber.Ctx) error {
+	result, err := h.service.WithContext(c.UserContext()).RunOnce()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to run auto-dispatch",
+		})
+	}
+	return c.JSON(result)
+}
+
+// Simulate replays a historical day against alternative dispatch policies
+func (h *DispatchHandler) Simulate(c *fiber.Ctx) error {
+	var req models.SimulateDispatchRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	result, err := h.service.WithContext(c.UserContext()).Simulate(&req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(result)
+}
+
+// SuggestTechnicians ranks the technicians for assigning the ticket by hand (skill, live
+// distance to the client and workload)
+func (h *DispatchHandler) SuggestTechnicians(c *fiber.Ctx) error {
+	var query models.SuggestTechniciansQuery
+	if err := c.QueryParser(&query); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid query parameters",
+		})
+	}
+
+	if err := h.validate.Struct(&query); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	suggestions, err := h.service.WithContext(c.UserContext()).SuggestTechnicians(c.Params("id"), &query)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(suggestions)
+}
+
+func (h *DispatchHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrDispatchRuleNotFound), errors.Is(err, services.ErrDispatchTicketNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidDispatchRule), errors.Is(err, services.ErrInvalidSimulationDate):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
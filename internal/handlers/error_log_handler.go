@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
 	"github.com/shigake/tech-iq-back/internal/services"
 
 	"github.com/gofiber/fiber/v2"
@@ -32,18 +33,20 @@ func NewErrorLogHandler(service *services.ErrorLogService) *ErrorLogHandler {
 // @Param endpoint query string false "Filter by endpoint"
 // @Param resolved query bool false "Filter by resolved status"
 // @Param search query string false "Search in error message, feature, endpoint"
+// @Param requestId query string false "Filter by request correlation ID (X-Request-ID)"
 // @Security BearerAuth
 // @Success 200 {object} models.PaginatedErrorLogs
-// @Router /api/v1/errors [get]
+// @Router /errors [get]
 func (h *ErrorLogHandler) GetAll(c *fiber.Ctx) error {
 	page, _ := strconv.Atoi(c.Query("page", "0"))
-	size, _ := strconv.Atoi(c.Query("size", "20"))
+	size := pageSize(c, pagination.Logs, "size")
 
 	filter := &models.ErrorLogFilter{
-		Level:    c.Query("level"),
-		Feature:  c.Query("feature"),
-		Endpoint: c.Query("endpoint"),
-		Search:   c.Query("search"),
+		Level:     c.Query("level"),
+		Feature:   c.Query("feature"),
+		Endpoint:  c.Query("endpoint"),
+		RequestID: c.Query("requestId"),
+		Search:    c.Query("search"),
 	}
 
 	// Handle resolved filter
@@ -71,7 +74,7 @@ func (h *ErrorLogHandler) GetAll(c *fiber.Ctx) error {
 // @Security BearerAuth
 // @Success 200 {object} models.ErrorLog
 // @Failure 404 {object} map[string]string
-// @Router /api/v1/errors/{id} [get]
+// @Router /errors/{id} [get]
 func (h *ErrorLogHandler) GetByID(c *fiber.Ctx) error {
 	id := c.Params("id")
 
@@ -92,7 +95,7 @@ func (h *ErrorLogHandler) GetByID(c *fiber.Ctx) error {
 // @Produce json
 // @Security BearerAuth
 // @Success 200 {object} models.ErrorLogStats
-// @Router /api/v1/errors/stats [get]
+// @Router /errors/stats [get]
 func (h *ErrorLogHandler) GetStats(c *fiber.Ctx) error {
 	stats, err := h.service.GetStats()
 	if err != nil {
@@ -114,7 +117,7 @@ func (h *ErrorLogHandler) GetStats(c *fiber.Ctx) error {
 // @Security BearerAuth
 // @Success 200 {object} map[string]string
 // @Failure 400 {object} map[string]string
-// @Router /api/v1/errors/{id}/resolve [post]
+// @Router /errors/{id}/resolve [post]
 func (h *ErrorLogHandler) Resolve(c *fiber.Ctx) error {
 	id := c.Params("id")
 	
@@ -145,7 +148,7 @@ func (h *ErrorLogHandler) Resolve(c *fiber.Ctx) error {
 // @Param body body object true "IDs to resolve"
 // @Security BearerAuth
 // @Success 200 {object} map[string]string
-// @Router /api/v1/errors/bulk-resolve [post]
+// @Router /errors/bulk-resolve [post]
 func (h *ErrorLogHandler) BulkResolve(c *fiber.Ctx) error {
 	userID := c.Locals("userId").(string)
 
@@ -180,7 +183,7 @@ func (h *ErrorLogHandler) BulkResolve(c *fiber.Ctx) error {
 // @Security BearerAuth
 // @Success 204
 // @Failure 400 {object} map[string]string
-// @Router /api/v1/errors/{id} [delete]
+// @Router /errors/{id} [delete]
 func (h *ErrorLogHandler) Delete(c *fiber.Ctx) error {
 	id := c.Params("id")
 
@@ -200,7 +203,7 @@ func (h *ErrorLogHandler) Delete(c *fiber.Ctx) error {
 // @Produce json
 // @Security BearerAuth
 // @Success 200 {object} map[string]interface{}
-// @Router /api/v1/errors/cleanup [post]
+// @Router /errors/cleanup [post]
 func (h *ErrorLogHandler) Cleanup(c *fiber.Ctx) error {
 	count, err := h.service.CleanupOldLogs()
 	if err != nil {
@@ -242,7 +245,7 @@ type FrontendErrorsRequest struct {
 // @Param body body FrontendErrorsRequest true "Frontend errors"
 // @Security BearerAuth
 // @Success 200 {object} map[string]interface{}
-// @Router /api/v1/errors/frontend [post]
+// @Router /errors/frontend [post]
 func (h *ErrorLogHandler) CreateFromFrontend(c *fiber.Ctx) error {
 	var req FrontendErrorsRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -26,7 +26,7 @@ func NewExportHandler(clientRepo repositories.ClientRepository, technicianRepo r
 // ExportClients exports clients data as CSV
 func (h *ExportHandler) ExportClients(c *fiber.Ctx) error {
 	// Get all clients with large page size
-	clients, _, err := h.clientRepo.GetAll(0, 10000)
+	clients, _, err := h.clientRepo.WithContext(c.UserContext()).GetAll(0, 10000)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"success": false,
@@ -83,7 +83,7 @@ func (h *ExportHandler) ExportClients(c *fiber.Ctx) error {
 // ExportTechnicians exports technicians data as CSV
 func (h *ExportHandler) ExportTechnicians(c *fiber.Ctx) error {
 	// Get all technicians with large page size
-	technicians, _, err := h.technicianRepo.FindAll(0, 10000)
+	technicians, _, err := h.technicianRepo.WithContext(c.UserContext()).FindAll(0, 10000)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"success": false,
@@ -143,7 +143,7 @@ func (h *ExportHandler) ExportTechnicians(c *fiber.Ctx) error {
 // ExportTickets exports tickets data as CSV
 func (h *ExportHandler) ExportTickets(c *fiber.Ctx) error {
 	// Get all tickets with large page size
-	tickets, _, err := h.ticketRepo.FindAll(0, 10000, nil)
+	tickets, _, err := h.ticketRepo.WithContext(c.UserContext()).FindAll(0, 10000, nil)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"success": false,
@@ -227,7 +227,7 @@ func (h *ExportHandler) ExportAll(c *fiber.Ctx) error {
 
 	// Export clients section
 	writer.Write([]string{"=== CLIENTES ==="})
-	clients, _, err := h.clientRepo.GetAll(0, 10000)
+	clients, _, err := h.clientRepo.WithContext(c.UserContext()).GetAll(0, 10000)
 	if err == nil {
 		clientHeader := []string{"ID", "Nome Completo", "CPF", "CNPJ", "Email", "Telefone", "Cidade", "Estado", "CEP", "Data de Criação"}
 		writer.Write(clientHeader)
@@ -253,7 +253,7 @@ func (h *ExportHandler) ExportAll(c *fiber.Ctx) error {
 
 	// Export technicians section
 	writer.Write([]string{"=== TÉCNICOS ==="})
-	technicians, _, err := h.technicianRepo.FindAll(0, 10000)
+	technicians, _, err := h.technicianRepo.WithContext(c.UserContext()).FindAll(0, 10000)
 	if err == nil {
 		techHeader := []string{"ID", "Nome", "CPF", "CNPJ", "Status", "Tipo", "Cidade", "Estado", "Data de Criação"}
 		writer.Write(techHeader)
@@ -278,7 +278,7 @@ func (h *ExportHandler) ExportAll(c *fiber.Ctx) error {
 
 	// Export tickets section
 	writer.Write([]string{"=== TICKETS ==="})
-	tickets, _, err := h.ticketRepo.FindAll(0, 10000, nil)
+	tickets, _, err := h.ticketRepo.WithContext(c.UserContext()).FindAll(0, 10000, nil)
 	if err == nil {
 		ticketHeader := []string{
 			"ID", "Número OS", "Descrição do Erro", "Status", "Prioridade",
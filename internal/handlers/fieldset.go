@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+)
+
+// fieldsets are the fields each list endpoint accepts in ?fields= (JSON:API sparse
+// fieldsets, also as ?fields[<resource>]=). The id is always returned.
+var fieldsets = map[string]map[string]bool{
+	"tickets": fieldset(
+		"id", "osNumber", "status", "type", "priority", "source", "errorDescription", "customerFeedback",
+		"nodeId", "nodeName", "clientName", "categoryName", "technicianCount",
+		"leadTechnicianId", "leadTechnicianName", "computerBrand", "computerModel", "serialNumber",
+		"technicianSignature", "clientSignature", "signedAt", "signedByName", "isSigned",
+		"startDate", "dueDate", "closedAt", "scheduledStart", "scheduledEnd", "dispatchStatus", "campaignId", "createdAt",
+	),
+	"technicians": fieldset(
+		"id", "fullName", "tradeName", "city", "state", "status", "type", "emails", "phones",
+		"inAttendance", "nodeId", "createdAt",
+	),
+	"financialEntries": fieldset(
+		"id", "type", "category", "subcategory", "description", "amount", "currency",
+		"entryDate", "dueDate", "paymentDate", "status", "ticketId", "ticket", "technicianId", "technician",
+		"clientId", "client", "recurringEntryId", "paymentMethod", "paymentReference", "attachmentUrls",
+		"createdBy", "updatedBy", "createdAt", "updatedAt",
+	),
+}
+
+func fieldset(names ...string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[name] = true
+	}
+	return set
+}
+
+// parseFields reads the requested fields of the resource, nil when the client wants them all
+func parseFields(c *fiber.Ctx, resource string) ([]string, error) {
+	raw := c.Query("fields[" + resource + "]")
+	if raw == "" {
+		raw = c.Query("fields")
+	}
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+
+	allowed := fieldsets[resource]
+	fields := []string{"id"}
+	seen := map[string]bool{"id": true}
+	var unknown []string
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		if !allowed[name] {
+			unknown = append(unknown, name)
+			continue
+		}
+		seen[name] = true
+		fields = append(fields, name)
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown fields for %s: %s", resource, strings.Join(unknown, ", "))
+	}
+	return fields, nil
+}
+
+// invalidFields answers a request whose ?fields= is not in the allowlist
+func invalidFields(c *fiber.Ctx, resource string, err error) error {
+	allowed := make([]string, 0, len(fieldsets[resource]))
+	for name := range fieldsets[resource] {
+		allowed = append(allowed, name)
+	}
+	sort.Strings(allowed)
+
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"error":   "Invalid fields",
+		"details": err.Error(),
+		"allowed": allowed,
+	})
+}
+
+// withFields applies the fieldset to the content of a page
+func withFields(page *models.PaginatedResponse, fields []string) *models.PaginatedResponse {
+	if page != nil && fields != nil {
+		page.Content = selectFields(page.Content, fields)
+	}
+	return page
+}
+
+// selectFields keeps only the fields (JSON names) of each item of a slice of structs or
+// maps; unselected fields, nested relations included, are never serialized
+func selectFields(items interface{}, fields []string) []map[string]interface{} {
+	v := reflect.ValueOf(items)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return []map[string]interface{}{}
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Slice {
+		return []map[string]interface{}{}
+	}
+
+	out := make([]map[string]interface{}, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		if item := projectItem(v.Index(i), fields); item != nil {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func projectItem(v reflect.Value, fields []string) map[string]interface{} {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	item := make(map[string]interface{}, len(fields))
+	switch v.Kind() {
+	case reflect.Struct:
+		index := jsonFieldIndex(v.Type())
+		for _, name := range fields {
+			if idx, ok := index[name]; ok {
+				if field, err := v.FieldByIndexErr(idx); err == nil {
+					item[name] = field.Interface()
+				}
+			}
+		}
+	case reflect.Map:
+		// Cached pages come back decoded into maps
+		if v.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		for _, name := range fields {
+			if value := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key())); value.IsValid() {
+				item[name] = value.Interface()
+			}
+		}
+	default:
+		return nil
+	}
+	return item
+}
+
+var jsonFieldIndexes sync.Map // reflect.Type -> map[string][]int
+
+// jsonFieldIndex maps the JSON names of a struct (embedded structs included) to their fields
+func jsonFieldIndex(t reflect.Type) map[string][]int {
+	if cached, ok := jsonFieldIndexes.Load(t); ok {
+		return cached.(map[string][]int)
+	}
+
+	index := make(map[string][]int)
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			tagName := strings.Split(tag, ",")[0]
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		}
+		if _, exists := index[name]; !exists {
+			index[name] = f.Index
+		}
+	}
+
+	jsonFieldIndexes.Store(t, index)
+	return index
+}
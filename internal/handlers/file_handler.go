@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/middleware"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/services"
+	"github.com/shigake/tech-iq-back/internal/storage"
+)
+
+// FileHandler serves the /files uploads. Every route checks the permission the owner
+// of the files requires (models.FilePermissions) on the node of the owner.
+type FileHandler struct {
+	service     services.FileService
+	local       *storage.Local // nil unless files are kept on local disk
+	permissions *middleware.Permissions
+	validate    *validator.Validate
+}
+
+func NewFileHandler(service services.FileService, local *storage.Local, permissions *middleware.Permissions) *FileHandler {
+	return &FileHandler{
+		service:     service,
+		local:       local,
+		permissions: permissions,
+		validate:    validator.New(),
+	}
+}
+
+// CreateUpload registers a file and returns the presigned URL its content must be PUT to
+func (h *FileHandler) CreateUpload(c *fiber.Ctx) error {
+	var req models.CreateFileUploadRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if err := h.validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	if ok, err := h.authorize(c, req.OwnerType, req.OwnerID, true); !ok {
+		return err
+	}
+
+	userID, _ := c.Locals("userId").(string)
+	upload, err := h.service.WithContext(c.UserContext()).CreateUpload(&req, userID)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(upload)
+}
+
+// Complete is called once the content was uploaded: it checks the size and scans it
+func (h *FileHandler) Complete(c *fiber.Ctx) error {
+	if ok, err := h.authorizeFile(c, true); !ok {
+		return err
+	}
+	file, err := h.service.WithContext(c.UserContext()).Complete(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(file)
+}
+
+// List returns the files of a record (?ownerType=TICKET|FINANCIAL_ENTRY|STOCK_MOVEMENT&ownerId=)
+func (h *FileHandler) List(c *fiber.Ctx) error {
+	ownerType, ownerID := strings.ToUpper(c.Query("ownerType")), c.Query("ownerId")
+	if ownerType == "" || ownerID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ownerType and ownerId are required",
+		})
+	}
+
+	if ok, err := h.authorize(c, ownerType, ownerID, false); !ok {
+		return err
+	}
+
+	files, err := h.service.WithContext(c.UserContext()).List(ownerType, ownerID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch files",
+		})
+	}
+	return c.JSON(files)
+}
+
+// GetByID returns a file and its status
+func (h *FileHandler) GetByID(c *fiber.Ctx) error {
+	file, err := h.service.WithContext(c.UserContext()).Get(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	if ok, err := h.authorize(c, file.OwnerType, file.OwnerID, false); !ok {
+		return err
+	}
+	return c.JSON(file)
+}
+
+// Download returns a short-lived URL serving the file
+func (h *FileHandler) Download(c *fiber.Ctx) error {
+	if ok, err := h.authorizeFile(c, false); !ok {
+		return err
+	}
+	download, err := h.service.WithContext(c.UserContext()).DownloadURL(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(download)
+}
+
+// Delete removes a file from the backend
+func (h *FileHandler) Delete(c *fiber.Ctx) error {
+	if ok, err := h.authorizeFile(c, true); !ok {
+		return err
+	}
+	if err := h.service.WithContext(c.UserContext()).Delete(c.Params("id")); err != nil {
+		return h.handleError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// PutBlob receives the content of a local presigned upload URL (no JWT, the token
+// is the grant)
+func (h *FileHandler) PutBlob(c *fiber.Ctx) error {
+	if h.local == nil {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+	grant, err := h.local.Verify(c.Params("token"), fiber.MethodPut)
+	if err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	}
+	if contentType := c.Get(fiber.HeaderContentType); !strings.EqualFold(contentType, grant.ContentType) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Content-Type must be " + grant.ContentType,
+		})
+	}
+
+	body := c.Body()
+	if err := h.local.Put(grant.Key, grant.ContentType, bytes.NewReader(body), int64(len(body))); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to store file",
+		})
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// GetBlob serves the content of a local presigned download URL
+func (h *FileHandler) GetBlob(c *fiber.Ctx) error {
+	if h.local == nil {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+	grant, err := h.local.Verify(c.Params("token"), fiber.MethodGet)
+	if err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	content, err := h.local.Open(grant.Key)
+	if err != nil {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+	c.Attachment(grant.FileName)
+	return c.SendStream(content)
+}
+
+// authorizeFile checks the permission on the owner of the file named by the route; it
+// writes the error response and returns false when the request can't go on
+func (h *FileHandler) authorizeFile(c *fiber.Ctx, edit bool) (bool, error) {
+	file, err := h.service.WithContext(c.UserContext()).Get(c.Params("id"))
+	if err != nil {
+		return false, h.handleError(c, err)
+	}
+	return h.authorize(c, file.OwnerType, file.OwnerID, edit)
+}
+
+// authorize checks the user holds the view (or edit) permission of the owner type on the
+// node of the owner; it writes the error response and returns false otherwise
+func (h *FileHandler) authorize(c *fiber.Ctx, ownerType, ownerID string, edit bool) (bool, error) {
+	permission, ok := models.FilePermissions[ownerType]
+	if !ok {
+		return false, h.handleError(c, services.ErrFileOwnerNotFound)
+	}
+	code := permission.View
+	if edit {
+		code = permission.Edit
+	}
+
+	nodeID, err := h.service.WithContext(c.UserContext()).OwnerNode(ownerType, ownerID)
+	if err != nil {
+		return false, h.handleError(c, err)
+	}
+	allowed, err := h.permissions.HasOn(c, code, nodeID)
+	if err != nil {
+		return false, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to check permissions",
+		})
+	}
+	if !allowed {
+		return false, c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error":      "Permission required",
+			"permission": code,
+		})
+	}
+	return true, nil
+}
+
+func (h *FileHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrFileNotFound), errors.Is(err, services.ErrFileOwnerNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrFileTooLarge):
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrFileTypeNotAllowed):
+		return c.Status(fiber.StatusUnsupportedMediaType).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrStorageQuotaExceeded):
+		return c.Status(fiber.StatusInsufficientStorage).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrFileNotUploaded), errors.Is(err, services.ErrFileSizeMismatch):
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrFileNotReady), errors.Is(err, services.ErrFileAlreadyComplete):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrFileInfected):
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+// =============== Budgets ===============
+
+// ListBudgets lists budgets
+// @Summary List financial budgets
+// @Tags Financial
+// @Produce json
+// @Param type query string false "Entry type (income/expense)"
+// @Param category query string false "Category"
+// @Param fromMonth query string false "First month (YYYY-MM)"
+// @Param toMonth query string false "Last month (YYYY-MM)"
+// @Param page query int false "Page number"
+// @Param limit query int false "Items per page"
+// @Success 200 {object} map[string]interface{}
+// @Router /financial/budgets [get]
+func (h *FinancialHandler) ListBudgets(c *fiber.Ctx) error {
+	filter := models.BudgetFilter{
+		Type:      models.FinancialEntryType(c.Query("type")),
+		Category:  c.Query("category"),
+		FromMonth: c.Query("fromMonth"),
+		ToMonth:   c.Query("toMonth"),
+		Page:      c.QueryInt("page", 1),
+		Limit:     pageSize(c, pagination.Financial, "limit"),
+	}
+
+	budgets, total, err := h.service.WithContext(c.UserContext()).ListBudgets(filter)
+	if err != nil {
+		return h.handleBudgetError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"budgets": budgets,
+		"total":   total,
+		"page":    filter.Page,
+		"limit":   filter.Limit,
+		"hasNext": pagination.HasNext((filter.Page-1)*filter.Limit, filter.Limit, total),
+	})
+}
+
+// GetBudget retrieves a budget by ID
+// @Summary Get financial budget
+// @Tags Financial
+// @Produce json
+// @Param id path string true "Budget ID"
+// @Success 200 {object} models.Budget
+// @Router /financial/budgets/{id} [get]
+func (h *FinancialHandler) GetBudget(c *fiber.Ctx) error {
+	budget, err := h.service.WithContext(c.UserContext()).GetBudgetByID(c.Params("id"))
+	if err != nil {
+		return h.handleBudgetError(c, err)
+	}
+
+	return c.JSON(budget)
+}
+
+// CreateBudget plans the income or expense of a category in a month
+// @Summary Create financial budget
+// @Tags Financial
+// @Accept json
+// @Produce json
+// @Param body body models.CreateBudgetRequest true "Budget data"
+// @Success 201 {object} models.Budget
+// @Router /financial/budgets [post]
+func (h *FinancialHandler) CreateBudget(c *fiber.Ctx) error {
+	var req models.CreateBudgetRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	userID := c.Locals("userId").(string)
+	budget, err := h.service.WithContext(c.UserContext()).CreateBudget(req, userID, c.IP(), c.Get("User-Agent"))
+	if err != nil {
+		return h.handleBudgetError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(budget)
+}
+
+// UpdateBudget changes the amount or notes of a budget
+// @Summary Update financial budget
+// @Tags Financial
+// @Accept json
+// @Produce json
+// @Param id path string true "Budget ID"
+// @Param body body models.UpdateBudgetRequest true "Budget data"
+// @Success 200 {object} models.Budget
+// @Router /financial/budgets/{id} [put]
+func (h *FinancialHandler) UpdateBudget(c *fiber.Ctx) error {
+	var req models.UpdateBudgetRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	userID := c.Locals("userId").(string)
+	budget, err := h.service.WithContext(c.UserContext()).UpdateBudget(c.Params("id"), req, userID, c.IP(), c.Get("User-Agent"))
+	if err != nil {
+		return h.handleBudgetError(c, err)
+	}
+
+	return c.JSON(budget)
+}
+
+// DeleteBudget deletes a budget
+// @Summary Delete financial budget
+// @Tags Financial
+// @Param id path string true "Budget ID"
+// @Success 204
+// @Router /financial/budgets/{id} [delete]
+func (h *FinancialHandler) DeleteBudget(c *fiber.Ctx) error {
+	userID := c.Locals("userId").(string)
+	if err := h.service.WithContext(c.UserContext()).DeleteBudget(c.Params("id"), userID, c.IP(), c.Get("User-Agent")); err != nil {
+		return h.handleBudgetError(c, err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// GetBudgetVsActualReport compares the budgets with the entries of the months
+// @Summary Get budget vs actual report
+// @Tags Financial
+// @Produce json
+// @Param startMonth query string false "First month (YYYY-MM, default January of this year)"
+// @Param endMonth query string false "Last month (YYYY-MM, default December of this year)"
+// @Param type query string false "Entry type (income/expense)"
+// @Param threshold query number false "Deviation from the budget still on track, in percent (default 10)"
+// @Success 200 {object} models.BudgetVsActualReport
+// @Router /financial/reports/budget-vs-actual [get]
+func (h *FinancialHandler) GetBudgetVsActualReport(c *fiber.Ctx) error {
+	filter := models.BudgetVsActualFilter{
+		StartMonth: c.Query("startMonth"),
+		EndMonth:   c.Query("endMonth"),
+		Type:       models.FinancialEntryType(c.Query("type")),
+		Threshold:  c.QueryFloat("threshold", 0),
+	}
+
+	report, err := h.service.WithContext(c.UserContext()).GetBudgetVsActualReport(filter)
+	if err != nil {
+		return h.handleBudgetError(c, err)
+	}
+
+	return c.JSON(report)
+}
+
+func (h *FinancialHandler) handleBudgetError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrBudgetNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Budget not found"})
+	case errors.Is(err, services.ErrBudgetExists):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrBudgetInvalidMonth),
+		errors.Is(err, services.ErrBudgetInvalidCategory),
+		errors.Is(err, services.ErrBudgetInvalidRange):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
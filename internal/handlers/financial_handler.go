@@ -1,19 +1,26 @@
 package handlers
 
 import (
+	"errors"
+
+	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
 	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
 	"github.com/shigake/tech-iq-back/internal/repositories"
 	"github.com/shigake/tech-iq-back/internal/services"
+	"github.com/shigake/tech-iq-back/internal/tenant"
 )
 
 type FinancialHandler struct {
-	service      *services.FinancialService
-	categoryRepo repositories.CategoryRepository
+	service       *services.FinancialService
+	categoryRepo  repositories.CategoryRepository
+	ticketService services.TicketService
+	validate      *validator.Validate
 }
 
-func NewFinancialHandler(service *services.FinancialService, categoryRepo repositories.CategoryRepository) *FinancialHandler {
-	return &FinancialHandler{service: service, categoryRepo: categoryRepo}
+func NewFinancialHandler(service *services.FinancialService, categoryRepo repositories.CategoryRepository, ticketService services.TicketService) *FinancialHandler {
+	return &FinancialHandler{service: service, categoryRepo: categoryRepo, ticketService: ticketService, validate: validator.New()}
 }
 
 // =============== Financial Entries ===============
@@ -45,8 +52,13 @@ func (h *FinancialHandler) CreateEntry(c *fiber.Ctx) error {
 	ip := c.IP()
 	userAgent := c.Get("User-Agent")
 
-	entry, err := h.service.CreateEntry(req, userID, ip, userAgent)
+	entry, err := h.service.WithContext(c.UserContext()).CreateEntry(req, userID, ip, userAgent)
 	if err != nil {
+		if errors.Is(err, services.ErrExpenseBudgetExceeded) {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
 		})
@@ -55,6 +67,58 @@ func (h *FinancialHandler) CreateEntry(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusCreated).JSON(entry)
 }
 
+// CreateTicketPayouts splits a payout among the ticket crew and creates one entry per technician
+// @Summary Create technician payouts for a ticket
+// @Tags Financial
+// @Accept json
+// @Produce json
+// @Param id path string true "Ticket ID"
+// @Param body body models.CreateTicketPayoutRequest true "Payout data"
+// @Success 201 {array} models.FinancialEntry
+// @Failure 409 {object} ErrorResponse
+// @Router /financial/tickets/{id}/payouts [post]
+func (h *FinancialHandler) CreateTicketPayouts(c *fiber.Ctx) error {
+	ticketID := c.Params("id")
+
+	var req models.CreateTicketPayoutRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if req.TotalAmount <= 0 || req.EntryDate == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Missing required fields: totalAmount, entryDate",
+		})
+	}
+
+	shares, err := h.ticketService.WithContext(c.UserContext()).WithScope(accessScope(c)).GetPayoutSplit(ticketID, req.TotalAmount)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Ticket not found",
+		})
+	}
+
+	userID := c.Locals("userId").(string)
+	entries, err := h.service.WithContext(c.UserContext()).CreateTicketPayouts(ticketID, shares, req, userID, c.IP(), c.Get("User-Agent"))
+	if err != nil {
+		if errors.Is(err, services.ErrTicketPayoutsExist) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"shares":  shares,
+		"entries": entries,
+	})
+}
+
 // GetEntry retrieves a financial entry by ID
 // @Summary Get financial entry
 // @Tags Financial
@@ -65,7 +129,7 @@ func (h *FinancialHandler) CreateEntry(c *fiber.Ctx) error {
 func (h *FinancialHandler) GetEntry(c *fiber.Ctx) error {
 	id := c.Params("id")
 
-	entry, err := h.service.GetEntryByID(id)
+	entry, err := h.service.WithContext(c.UserContext()).GetEntryByID(id)
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Entry not found",
@@ -104,13 +168,18 @@ func (h *FinancialHandler) UpdateEntry(c *fiber.Ctx) error {
 	ip := c.IP()
 	userAgent := c.Get("User-Agent")
 
-	entry, err := h.service.UpdateEntry(id, req, userID, ip, userAgent)
+	entry, err := h.service.WithContext(c.UserContext()).UpdateEntry(id, req, userID, ip, userAgent)
 	if err != nil {
 		if err.Error() == "entry was modified by another user, please refresh and try again" {
 			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
 				"error": err.Error(),
 			})
 		}
+		if errors.Is(err, services.ErrExpenseBudgetExceeded) {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
 		})
@@ -148,7 +217,7 @@ func (h *FinancialHandler) UpdateEntryStatus(c *fiber.Ctx) error {
 	ip := c.IP()
 	userAgent := c.Get("User-Agent")
 
-	entry, err := h.service.UpdateEntryStatus(id, req, userID, ip, userAgent)
+	entry, err := h.service.WithContext(c.UserContext()).UpdateEntryStatus(id, req, userID, ip, userAgent)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
@@ -171,7 +240,7 @@ func (h *FinancialHandler) DeleteEntry(c *fiber.Ctx) error {
 	ip := c.IP()
 	userAgent := c.Get("User-Agent")
 
-	if err := h.service.DeleteEntry(id, userID, ip, userAgent); err != nil {
+	if err := h.service.WithContext(c.UserContext()).DeleteEntry(id, userID, ip, userAgent); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
 		})
@@ -191,36 +260,91 @@ func (h *FinancialHandler) DeleteEntry(c *fiber.Ctx) error {
 // @Param endDate query string false "End date (YYYY-MM-DD)"
 // @Param technicianId query string false "Technician ID"
 // @Param clientId query string false "Client ID"
+// @Param clientTag query string false "Client tag ID"
+// @Param clientSegment query string false "Client segment (DIMENSION:VALUE)"
 // @Param page query int false "Page number"
 // @Param limit query int false "Items per page"
+// @Param cursor query string false "Keyset cursor (empty for the first page) instead of page"
 // @Success 200 {object} map[string]interface{}
 // @Router /financial/entries [get]
 func (h *FinancialHandler) ListEntries(c *fiber.Ctx) error {
 	filter := models.FinancialEntryFilter{
-		Type:         models.FinancialEntryType(c.Query("type")),
-		Status:       models.FinancialEntryStatus(c.Query("status")),
-		Category:     c.Query("category"),
-		StartDate:    c.Query("startDate"),
-		EndDate:      c.Query("endDate"),
-		TechnicianID: c.Query("technicianId"),
-		ClientID:     c.Query("clientId"),
-		TicketID:     c.Query("ticketId"),
-		Page:         c.QueryInt("page", 1),
-		Limit:        c.QueryInt("limit", 20),
+		Type:             models.FinancialEntryType(c.Query("type")),
+		Status:           models.FinancialEntryStatus(c.Query("status")),
+		Category:         c.Query("category"),
+		StartDate:        c.Query("startDate"),
+		EndDate:          c.Query("endDate"),
+		TechnicianID:     c.Query("technicianId"),
+		ClientID:         c.Query("clientId"),
+		TicketID:         c.Query("ticketId"),
+		SupplierID:       c.Query("supplierId"),
+		RecurringEntryID: c.Query("recurringEntryId"),
+		Page:             c.QueryInt("page", 1),
+		Limit:            pageSize(c, pagination.Financial, "limit"),
+	}
+	clients, err := clientSegmentFilter(c, "clientTag", "clientSegment")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	filter.Clients = clients
+
+	cursor, err := queryCursor(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	filter.Cursor = cursor
+
+	fields, err := parseFields(c, "financialEntries")
+	if err != nil {
+		return invalidFields(c, "financialEntries", err)
+	}
+
+	if filter.Cursor != nil {
+		entries, next, prev, err := h.service.WithContext(c.UserContext()).ListEntriesByCursor(filter)
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		var content interface{} = entries
+		if fields != nil {
+			content = selectFields(entries, fields)
+		}
+		return c.JSON(fiber.Map{
+			"entries":    content,
+			"limit":      filter.Limit,
+			"hasNext":    next != "",
+			"nextCursor": next,
+			"prevCursor": prev,
+		})
 	}
 
-	entries, total, err := h.service.ListEntries(filter)
+	entries, total, err := h.service.WithContext(c.UserContext()).ListEntries(filter)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
 
+	var content interface{} = entries
+	if fields != nil {
+		content = selectFields(entries, fields)
+	}
 	return c.JSON(fiber.Map{
-		"entries": entries,
+		"entries": content,
 		"total":   total,
 		"page":    filter.Page,
 		"limit":   filter.Limit,
+		"hasNext": pagination.HasNext((filter.Page-1)*filter.Limit, filter.Limit, total),
 	})
 }
 
@@ -252,7 +376,7 @@ func (h *FinancialHandler) CreateBatch(c *fiber.Ctx) error {
 	ip := c.IP()
 	userAgent := c.Get("User-Agent")
 
-	batch, err := h.service.CreateBatch(req, userID, ip, userAgent)
+	batch, err := h.service.WithContext(c.UserContext()).CreateBatch(req, userID, ip, userAgent)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
@@ -272,7 +396,7 @@ func (h *FinancialHandler) CreateBatch(c *fiber.Ctx) error {
 func (h *FinancialHandler) GetBatch(c *fiber.Ctx) error {
 	id := c.Params("id")
 
-	batch, err := h.service.GetBatchByID(id)
+	batch, err := h.service.WithContext(c.UserContext()).GetBatchByID(id)
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Batch not found",
@@ -299,10 +423,10 @@ func (h *FinancialHandler) ListBatches(c *fiber.Ctx) error {
 		PeriodStart: c.Query("periodStart"),
 		PeriodEnd:   c.Query("periodEnd"),
 		Page:        c.QueryInt("page", 1),
-		Limit:       c.QueryInt("limit", 20),
+		Limit:       pageSize(c, pagination.Financial, "limit"),
 	}
 
-	batches, total, err := h.service.ListBatches(filter)
+	batches, total, err := h.service.WithContext(c.UserContext()).ListBatches(filter)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
@@ -314,6 +438,7 @@ func (h *FinancialHandler) ListBatches(c *fiber.Ctx) error {
 		"total":   total,
 		"page":    filter.Page,
 		"limit":   filter.Limit,
+		"hasNext": pagination.HasNext((filter.Page-1)*filter.Limit, filter.Limit, total),
 	})
 }
 
@@ -346,7 +471,7 @@ func (h *FinancialHandler) AddEntriesToBatch(c *fiber.Ctx) error {
 	ip := c.IP()
 	userAgent := c.Get("User-Agent")
 
-	batch, err := h.service.AddEntriesToBatch(id, req, userID, ip, userAgent)
+	batch, err := h.service.WithContext(c.UserContext()).AddEntriesToBatch(id, req, userID, ip, userAgent)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
@@ -371,7 +496,7 @@ func (h *FinancialHandler) RemoveEntryFromBatch(c *fiber.Ctx) error {
 	ip := c.IP()
 	userAgent := c.Get("User-Agent")
 
-	if err := h.service.RemoveEntryFromBatch(batchID, entryID, userID, ip, userAgent); err != nil {
+	if err := h.service.WithContext(c.UserContext()).RemoveEntryFromBatch(batchID, entryID, userID, ip, userAgent); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
 		})
@@ -394,7 +519,7 @@ func (h *FinancialHandler) ApproveBatch(c *fiber.Ctx) error {
 	ip := c.IP()
 	userAgent := c.Get("User-Agent")
 
-	batch, err := h.service.ApproveBatch(id, userID, ip, userAgent)
+	batch, err := h.service.WithContext(c.UserContext()).ApproveBatch(id, userID, ip, userAgent)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
@@ -423,7 +548,7 @@ func (h *FinancialHandler) PayBatch(c *fiber.Ctx) error {
 	ip := c.IP()
 	userAgent := c.Get("User-Agent")
 
-	batch, err := h.service.PayBatch(id, req, userID, ip, userAgent)
+	batch, err := h.service.WithContext(c.UserContext()).PayBatch(id, req, userID, ip, userAgent)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
@@ -446,7 +571,7 @@ func (h *FinancialHandler) DeleteBatch(c *fiber.Ctx) error {
 	ip := c.IP()
 	userAgent := c.Get("User-Agent")
 
-	if err := h.service.DeleteBatch(id, userID, ip, userAgent); err != nil {
+	if err := h.service.WithContext(c.UserContext()).DeleteBatch(id, userID, ip, userAgent); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
 		})
@@ -464,6 +589,9 @@ func (h *FinancialHandler) DeleteBatch(c *fiber.Ctx) error {
 // @Param period query string false "Period (today/week/month/quarter/year/custom)"
 // @Param startDate query string false "Start date for custom period"
 // @Param endDate query string false "End date for custom period"
+// @Param clientTag query string false "Client tag ID"
+// @Param clientSegment query string false "Client segment (DIMENSION:VALUE)"
+// @Param fresh query bool false "Bypass the cached dashboard"
 // @Success 200 {object} models.FinancialDashboard
 // @Router /financial/dashboard [get]
 func (h *FinancialHandler) GetDashboard(c *fiber.Ctx) error {
@@ -471,9 +599,18 @@ func (h *FinancialHandler) GetDashboard(c *fiber.Ctx) error {
 		Period:    c.Query("period", "month"),
 		StartDate: c.Query("startDate"),
 		EndDate:   c.Query("endDate"),
+		Fresh:     c.QueryBool("fresh"),
 	}
+	clients, err := clientSegmentFilter(c, "clientTag", "clientSegment")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	filter.Clients = clients
+	filter.TenantID, _ = tenant.FromContext(c.UserContext())
 
-	dashboard, err := h.service.GetDashboard(filter)
+	dashboard, err := h.service.WithContext(c.UserContext()).GetDashboard(filter)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
@@ -490,6 +627,8 @@ func (h *FinancialHandler) GetDashboard(c *fiber.Ctx) error {
 // @Param startDate query string true "Start date (YYYY-MM-DD)"
 // @Param endDate query string true "End date (YYYY-MM-DD)"
 // @Param groupBy query string false "Group by (day/week/month)"
+// @Param clientTag query string false "Client tag ID"
+// @Param clientSegment query string false "Client segment (DIMENSION:VALUE)"
 // @Success 200 {object} models.CashFlowReport
 // @Router /financial/reports/cash-flow [get]
 func (h *FinancialHandler) GetCashFlowReport(c *fiber.Ctx) error {
@@ -498,6 +637,13 @@ func (h *FinancialHandler) GetCashFlowReport(c *fiber.Ctx) error {
 		EndDate:   c.Query("endDate"),
 		GroupBy:   c.Query("groupBy", "day"),
 	}
+	clients, err := clientSegmentFilter(c, "clientTag", "clientSegment")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	filter.Clients = clients
 
 	if filter.StartDate == "" || filter.EndDate == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -505,7 +651,7 @@ func (h *FinancialHandler) GetCashFlowReport(c *fiber.Ctx) error {
 		})
 	}
 
-	report, err := h.service.GetCashFlowReport(filter)
+	report, err := h.service.WithContext(c.UserContext()).GetCashFlowReport(filter)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
@@ -537,7 +683,7 @@ func (h *FinancialHandler) GetTechnicianPaymentsReport(c *fiber.Ctx) error {
 		})
 	}
 
-	report, err := h.service.GetTechnicianPaymentsReport(filter)
+	report, err := h.service.WithContext(c.UserContext()).GetTechnicianPaymentsReport(filter)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
@@ -558,7 +704,7 @@ func (h *FinancialHandler) GetCategories(c *fiber.Ctx) error {
 	categoryType := c.Query("type")
 	
 	if categoryType != "" {
-		categories, err := h.categoryRepo.GetByTypeWithChildren(models.CategoryType(categoryType))
+		categories, err := h.categoryRepo.WithContext(c.UserContext()).GetByTypeWithChildren(models.CategoryType(categoryType))
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": "Failed to fetch categories",
@@ -568,14 +714,14 @@ func (h *FinancialHandler) GetCategories(c *fiber.Ctx) error {
 	}
 	
 	// Return both income and expense categories
-	incomeCategories, err := h.categoryRepo.GetByTypeWithChildren(models.CategoryTypeFinanceIncome)
+	incomeCategories, err := h.categoryRepo.WithContext(c.UserContext()).GetByTypeWithChildren(models.CategoryTypeFinanceIncome)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch income categories",
 		})
 	}
 	
-	expenseCategories, err := h.categoryRepo.GetByTypeWithChildren(models.CategoryTypeFinanceExpense)
+	expenseCategories, err := h.categoryRepo.WithContext(c.UserContext()).GetByTypeWithChildren(models.CategoryTypeFinanceExpense)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch expense categories",
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+// =============== Recurring Entries ===============
+
+// ListRecurring lists recurring entries
+// @Summary List recurring financial entries
+// @Tags Financial
+// @Produce json
+// @Param type query string false "Entry type (income/expense)"
+// @Param status query string false "Status (active/paused/finished)"
+// @Param page query int false "Page number"
+// @Param limit query int false "Items per page"
+// @Success 200 {object} map[string]interface{}
+// @Router /financial/recurring [get]
+func (h *FinancialHandler) ListRecurring(c *fiber.Ctx) error {
+	filter := models.RecurringEntryFilter{
+		Type:   models.FinancialEntryType(c.Query("type")),
+		Status: models.RecurringEntryStatus(c.Query("status")),
+		Page:   c.QueryInt("page", 1),
+		Limit:  pageSize(c, pagination.Financial, "limit"),
+	}
+
+	recurring, total, err := h.service.WithContext(c.UserContext()).ListRecurringEntries(filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"recurring": recurring,
+		"total":     total,
+		"page":      filter.Page,
+		"limit":     filter.Limit,
+		"hasNext":   pagination.HasNext((filter.Page-1)*filter.Limit, filter.Limit, total),
+	})
+}
+
+// GetRecurring retrieves a recurring entry by ID
+// @Summary Get recurring financial entry
+// @Tags Financial
+// @Produce json
+// @Param id path string true "Recurring entry ID"
+// @Success 200 {object} models.RecurringEntry
+// @Router /financial/recurring/{id} [get]
+func (h *FinancialHandler) GetRecurring(c *fiber.Ctx) error {
+	recurring, err := h.service.WithContext(c.UserContext()).GetRecurringEntryByID(c.Params("id"))
+	if err != nil {
+		return h.handleRecurringError(c, err)
+	}
+
+	return c.JSON(recurring)
+}
+
+// CreateRecurring creates a recurring entry
+// @Summary Create recurring financial entry
+// @Tags Financial
+// @Accept json
+// @Produce json
+// @Param body body models.CreateRecurringEntryRequest true "Recurring entry data"
+// @Success 201 {object} models.RecurringEntry
+// @Router /financial/recurring [post]
+func (h *FinancialHandler) CreateRecurring(c *fiber.Ctx) error {
+	var req models.CreateRecurringEntryRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	userID := c.Locals("userId").(string)
+	recurring, err := h.service.WithContext(c.UserContext()).CreateRecurringEntry(req, userID, c.IP(), c.Get("User-Agent"))
+	if err != nil {
+		return h.handleRecurringError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(recurring)
+}
+
+// UpdateRecurring updates the next occurrences of a recurring entry
+// @Summary Update recurring financial entry
+// @Tags Financial
+// @Accept json
+// @Produce json
+// @Param id path string true "Recurring entry ID"
+// @Param body body models.UpdateRecurringEntryRequest true "Recurring entry data"
+// @Success 200 {object} models.RecurringEntry
+// @Router /financial/recurring/{id} [put]
+func (h *FinancialHandler) UpdateRecurring(c *fiber.Ctx) error {
+	var req models.UpdateRecurringEntryRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	userID := c.Locals("userId").(string)
+	recurring, err := h.service.WithContext(c.UserContext()).UpdateRecurringEntry(c.Params("id"), req, userID, c.IP(), c.Get("User-Agent"))
+	if err != nil {
+		return h.handleRecurringError(c, err)
+	}
+
+	return c.JSON(recurring)
+}
+
+// DeleteRecurring deletes a recurring entry, keeping the entries it generated
+// @Summary Delete recurring financial entry
+// @Tags Financial
+// @Param id path string true "Recurring entry ID"
+// @Success 204
+// @Router /financial/recurring/{id} [delete]
+func (h *FinancialHandler) DeleteRecurring(c *fiber.Ctx) error {
+	userID := c.Locals("userId").(string)
+	if err := h.service.WithContext(c.UserContext()).DeleteRecurringEntry(c.Params("id"), userID, c.IP(), c.Get("User-Agent")); err != nil {
+		return h.handleRecurringError(c, err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// PauseRecurring stops generating entries for a recurring entry
+// @Summary Pause recurring financial entry
+// @Tags Financial
+// @Produce json
+// @Param id path string true "Recurring entry ID"
+// @Success 200 {object} models.RecurringEntry
+// @Router /financial/recurring/{id}/pause [patch]
+func (h *FinancialHandler) PauseRecurring(c *fiber.Ctx) error {
+	userID := c.Locals("userId").(string)
+	recurring, err := h.service.WithContext(c.UserContext()).PauseRecurringEntry(c.Params("id"), userID, c.IP(), c.Get("User-Agent"))
+	if err != nil {
+		return h.handleRecurringError(c, err)
+	}
+
+	return c.JSON(recurring)
+}
+
+// ResumeRecurring generates entries again from the next occurrence on
+// @Summary Resume recurring financial entry
+// @Tags Financial
+// @Produce json
+// @Param id path string true "Recurring entry ID"
+// @Success 200 {object} models.RecurringEntry
+// @Router /financial/recurring/{id}/resume [patch]
+func (h *FinancialHandler) ResumeRecurring(c *fiber.Ctx) error {
+	userID := c.Locals("userId").(string)
+	recurring, err := h.service.WithContext(c.UserContext()).ResumeRecurringEntry(c.Params("id"), userID, c.IP(), c.Get("User-Agent"))
+	if err != nil {
+		return h.handleRecurringError(c, err)
+	}
+
+	return c.JSON(recurring)
+}
+
+// PreviewRecurring lists the next occurrences of a recurring entry
+// @Summary Preview recurring financial entry occurrences
+// @Tags Financial
+// @Produce json
+// @Param id path string true "Recurring entry ID"
+// @Param count query int false "Number of occurrences (default 12, max 60)"
+// @Success 200 {array} models.RecurringOccurrence
+// @Router /financial/recurring/{id}/preview [get]
+func (h *FinancialHandler) PreviewRecurring(c *fiber.Ctx) error {
+	occurrences, err := h.service.WithContext(c.UserContext()).PreviewRecurringEntry(c.Params("id"), c.QueryInt("count", 0))
+	if err != nil {
+		return h.handleRecurringError(c, err)
+	}
+
+	return c.JSON(occurrences)
+}
+
+// UpcomingRecurring lists the occurrences of every active recurring entry in the next days
+// @Summary List upcoming recurring financial entries
+// @Tags Financial
+// @Produce json
+// @Param days query int false "Days ahead (default 30, max 366)"
+// @Success 200 {array} models.RecurringOccurrence
+// @Router /financial/recurring/upcoming [get]
+func (h *FinancialHandler) UpcomingRecurring(c *fiber.Ctx) error {
+	occurrences, err := h.service.WithContext(c.UserContext()).GetUpcomingOccurrences(c.QueryInt("days", 30))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(occurrences)
+}
+
+func (h *FinancialHandler) handleRecurringError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrRecurringEntryNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Recurring entry not found"})
+	case errors.Is(err, services.ErrRecurringEntryNotActive),
+		errors.Is(err, services.ErrRecurringEntryNotPaused):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+}
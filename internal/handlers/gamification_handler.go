@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type GamificationHandler struct {
+	service  services.GamificationService
+	validate *validator.Validate
+}
+
+func NewGamificationHandler(service services.GamificationService) *GamificationHandler {
+	return &GamificationHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// GetLeaderboard ranks the opted-in technicians of the current week or month
+// @Summary Technician leaderboard
+// @Tags Gamification
+// @Produce json
+// @Param period query string false "WEEK (default) or MONTH"
+// @Param state query string false "Region (technician state)"
+// @Success 200 {object} models.Leaderboard
+// @Router /gamification/leaderboard [get]
+func (h *GamificationHandler) GetLeaderboard(c *fiber.Ctx) error {
+	leaderboard, err := h.service.Leaderboard(c.Query("period"), c.Query("state"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(leaderboard)
+}
+
+// GetMine returns the technician's points, rank and badges of the week and month
+// @Summary My gamification stats
+// @Tags Gamification
+// @Produce json
+// @Success 200 {object} models.MyGamification
+// @Router /gamification/me [get]
+func (h *GamificationHandler) GetMine(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+	mine, err := h.service.GetMine(userID)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(mine)
+}
+
+// UpdateMine opts the technician in or out of the leaderboards
+// @Summary Update my gamification preferences
+// @Tags Gamification
+// @Accept json
+// @Produce json
+// @Param body body models.GamificationProfileRequest true "Preferences"
+// @Success 200 {object} models.GamificationProfile
+// @Router /gamification/me [put]
+func (h *GamificationHandler) UpdateMine(c *fiber.Ctx) error {
+	var req models.GamificationProfileRequest
+	if errResponse := h.parse(c, &req); errResponse != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errResponse)
+	}
+	userID, _ := c.Locals("userId").(string)
+	profile, err := h.service.UpdateMine(userID, &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(profile)
+}
+
+// ListBadges returns the active badges; admins also get the inactive ones with all=true
+// @Summary List badges
+// @Tags Gamification
+// @Produce json
+// @Param all query bool false "Include inactive badges (admin)"
+// @Success 200 {array} models.GamificationBadge
+// @Router /gamification/badges [get]
+func (h *GamificationHandler) ListBadges(c *fiber.Ctx) error {
+	role, _ := c.Locals("userRole").(string)
+	activeOnly := !(role == "ADMIN" && c.QueryBool("all"))
+	badges, err := h.service.ListBadges(activeOnly)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch badges",
+		})
+	}
+	return c.JSON(badges)
+}
+
+// CreateBadge defines a badge
+// @Summary Create badge
+// @Tags Gamification
+// @Accept json
+// @Produce json
+// @Param body body models.GamificationBadgeRequest true "Badge"
+// @Success 201 {object} models.GamificationBadge
+// @Router /gamification/badges [post]
+func (h *GamificationHandler) CreateBadge(c *fiber.Ctx) error {
+	var req models.GamificationBadgeRequest
+	if errResponse := h.parse(c, &req); errResponse != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errResponse)
+	}
+	badge, err := h.service.CreateBadge(&req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(badge)
+}
+
+// UpdateBadge replaces a badge
+// @Summary Update badge
+// @Tags Gamification
+// @Accept json
+// @Produce json
+// @Param id path string true "Badge ID"
+// @Param body body models.GamificationBadgeRequest true "Badge"
+// @Success 200 {object} models.GamificationBadge
+// @Router /gamification/badges/{id} [put]
+func (h *GamificationHandler) UpdateBadge(c *fiber.Ctx) error {
+	var req models.GamificationBadgeRequest
+	if errResponse := h.parse(c, &req); errResponse != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errResponse)
+	}
+	badge, err := h.service.UpdateBadge(c.Params("id"), &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(badge)
+}
+
+// DeleteBadge removes a badge
+// @Summary Delete badge
+// @Tags Gamification
+// @Param id path string true "Badge ID"
+// @Success 204
+// @Router /gamification/badges/{id} [delete]
+func (h *GamificationHandler) DeleteBadge(c *fiber.Ctx) error {
+	if err := h.service.DeleteBadge(c.Params("id")); err != nil {
+		return h.handleError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// parse reads and validates the body, returning the error response (nil when valid)
+func (h *GamificationHandler) parse(c *fiber.Ctx, req interface{}) fiber.Map {
+	if err := c.BodyParser(req); err != nil {
+		return fiber.Map{"error": "Invalid request body"}
+	}
+	if err := h.validate.Struct(req); err != nil {
+		return fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		}
+	}
+	return nil
+}
+
+func (h *GamificationHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrNotATechnician), errors.Is(err, services.ErrBadgeNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidGamificationPeriod):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrBadgeCodeTaken):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
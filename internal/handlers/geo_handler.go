@@ -1,22 +1,37 @@
 package handlers
 
 import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"strconv"
 	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
 	"github.com/shigake/tech-iq-back/internal/repositories"
 	"github.com/shigake/tech-iq-back/internal/services"
 	"time"
 
+	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 )
 
+const (
+	locationStreamHeartbeat = 15 * time.Second
+	// O stream é encerrado periodicamente; o EventSource reconecta e o escopo é recalculado
+	locationStreamMaxDuration = 30 * time.Minute
+	// Raio máximo da busca por técnicos próximos
+	geoMaxNearbyRadiusKm = 500
+)
+
 type GeoHandler struct {
 	geoService *services.GeoService
+	validate   *validator.Validate
 }
 
 func NewGeoHandler(geoService *services.GeoService) *GeoHandler {
-	return &GeoHandler{geoService: geoService}
+	return &GeoHandler{geoService: geoService, validate: validator.New()}
 }
 
 // CreateLocation godoc
@@ -68,8 +83,17 @@ func (h *GeoHandler) CreateLocation(c *fiber.Ctx) error {
 	}
 
 	// Criar localização
-	location, err := h.geoService.CreateLocation(userID.String(), &req)
+	location, err := h.geoService.WithContext(c.UserContext()).CreateLocation(userID.String(), &req)
 	if err != nil {
+		if errors.Is(err, services.ErrTechnicianNotAssigned) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"success": false,
+				"error": fiber.Map{
+					"code":    "NOT_ASSIGNED",
+					"message": err.Error(),
+				},
+			})
+		}
 		if err.Error() == "rate limited: too many location updates" {
 			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
 				"success": false,
@@ -152,7 +176,7 @@ func (h *GeoHandler) CreateBatchLocations(c *fiber.Ctx) error {
 		})
 	}
 
-	results, err := h.geoService.CreateBatchLocations(userID.String(), &req)
+	results, err := h.geoService.WithContext(c.UserContext()).CreateBatchLocations(userID.String(), &req)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
@@ -222,10 +246,7 @@ func (h *GeoHandler) GetTechniciansLastLocations(c *fiber.Ctx) error {
 	}
 
 	page, _ := strconv.Atoi(c.Query("page", "1"))
-	limit, _ := strconv.Atoi(c.Query("limit", "50"))
-	if limit > 200 {
-		limit = 200
-	}
+	limit := pageSize(c, pagination.GeoLocations, "limit")
 	filter.Limit = limit
 	filter.Offset = (page - 1) * limit
 
@@ -240,11 +261,6 @@ func (h *GeoHandler) GetTechniciansLastLocations(c *fiber.Ctx) error {
 		})
 	}
 
-	totalPages := int(total) / limit
-	if int(total)%limit > 0 {
-		totalPages++
-	}
-
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"success": true,
 		"data": fiber.Map{
@@ -253,12 +269,277 @@ func (h *GeoHandler) GetTechniciansLastLocations(c *fiber.Ctx) error {
 				"page":       page,
 				"limit":      limit,
 				"total":      total,
-				"totalPages": totalPages,
+				"totalPages": pagination.TotalPages(total, limit),
+				"hasNext":    pagination.HasNext(filter.Offset, limit, total),
 			},
 		},
 	})
 }
 
+// GetNearbyTechnicians godoc
+// @Summary Técnicos próximos a um ponto
+// @Description Retorna a última localização dos técnicos dentro do raio, da mais próxima para
+// @Description a mais distante, com a distância em km (PostGIS quando habilitado)
+// @Tags Geo
+// @Produce json
+// @Param lat query number true "Latitude do centro"
+// @Param lng query number true "Longitude do centro"
+// @Param radiusKm query number true "Raio em km (máximo 500)"
+// @Param status query string false "Filtrar por status do técnico"
+// @Param updatedSince query string false "Apenas atualizados após (ISO8601)"
+// @Param limit query int false "Limite" default(50)
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/geo/technicians/nearby [get]
+func (h *GeoHandler) GetNearbyTechnicians(c *fiber.Ctx) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"code":    "UNAUTHORIZED",
+				"message": "Invalid or missing token",
+			},
+		})
+	}
+
+	lat, okLat := queryFloat(c, "lat", -90, 90)
+	lng, okLng := queryFloat(c, "lng", -180, 180)
+	if !okLat || !okLng {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"code":    "INVALID_COORDINATES",
+				"message": "lat must be between -90 and 90 and lng between -180 and 180",
+			},
+		})
+	}
+	radiusKm, ok := queryFloat(c, "radiusKm", 0, geoMaxNearbyRadiusKm)
+	if !ok || radiusKm == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"code":    "INVALID_RADIUS",
+				"message": fmt.Sprintf("radiusKm must be greater than 0 and at most %d", geoMaxNearbyRadiusKm),
+			},
+		})
+	}
+
+	area := repositories.GeoArea{
+		Center:  &repositories.GeoPoint{Lat: lat, Lng: lng},
+		RadiusM: radiusKm * 1000,
+	}
+	return h.findTechniciansInArea(c, userID, area)
+}
+
+// GetTechniciansWithin godoc
+// @Summary Técnicos na área do mapa
+// @Description Retorna a última localização dos técnicos dentro da caixa visível do mapa. Com
+// @Description lat/lng, ordena pela distância a esse ponto; sem, pela atualização mais recente
+// @Tags Geo
+// @Produce json
+// @Param minLat query number true "Latitude mínima (sul)"
+// @Param minLng query number true "Longitude mínima (oeste)"
+// @Param maxLat query number true "Latitude máxima (norte)"
+// @Param maxLng query number true "Longitude máxima (leste)"
+// @Param lat query number false "Latitude do ponto de referência da distância"
+// @Param lng query number false "Longitude do ponto de referência da distância"
+// @Param status query string false "Filtrar por status do técnico"
+// @Param updatedSince query string false "Apenas atualizados após (ISO8601)"
+// @Param limit query int false "Limite" default(50)
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/geo/technicians/within [get]
+func (h *GeoHandler) GetTechniciansWithin(c *fiber.Ctx) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"code":    "UNAUTHORIZED",
+				"message": "Invalid or missing token",
+			},
+		})
+	}
+
+	minLat, okMinLat := queryFloat(c, "minLat", -90, 90)
+	minLng, okMinLng := queryFloat(c, "minLng", -180, 180)
+	maxLat, okMaxLat := queryFloat(c, "maxLat", -90, 90)
+	maxLng, okMaxLng := queryFloat(c, "maxLng", -180, 180)
+	if !okMinLat || !okMinLng || !okMaxLat || !okMaxLng || minLat > maxLat || minLng > maxLng {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"code":    "INVALID_BOUNDS",
+				"message": "minLat/maxLat must be between -90 and 90, minLng/maxLng between -180 and 180, with min <= max",
+			},
+		})
+	}
+
+	area := repositories.GeoArea{
+		Box: &repositories.GeoBox{MinLat: minLat, MinLng: minLng, MaxLat: maxLat, MaxLng: maxLng},
+	}
+	if c.Query("lat") != "" || c.Query("lng") != "" {
+		lat, okLat := queryFloat(c, "lat", -90, 90)
+		lng, okLng := queryFloat(c, "lng", -180, 180)
+		if !okLat || !okLng {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"error": fiber.Map{
+					"code":    "INVALID_COORDINATES",
+					"message": "lat must be between -90 and 90 and lng between -180 and 180",
+				},
+			})
+		}
+		area.Center = &repositories.GeoPoint{Lat: lat, Lng: lng}
+	}
+	return h.findTechniciansInArea(c, userID, area)
+}
+
+// findTechniciansInArea completa a área com os filtros comuns e responde a busca espacial
+func (h *GeoHandler) findTechniciansInArea(c *fiber.Ctx, userID uuid.UUID, area repositories.GeoArea) error {
+	area.Status = c.Query("status")
+	area.Limit = pageSize(c, pagination.GeoLocations, "limit")
+	if updatedSince := c.Query("updatedSince"); updatedSince != "" {
+		t, err := time.Parse(time.RFC3339, updatedSince)
+		if err == nil {
+			area.UpdatedSince = &t
+		}
+	}
+
+	technicians, err := h.geoService.FindTechniciansInArea(userID, area)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"code":    "INTERNAL_ERROR",
+				"message": err.Error(),
+			},
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"technicians": technicians,
+			"count":       len(technicians),
+			"limit":       area.Limit,
+		},
+	})
+}
+
+// queryFloat lê um parâmetro numérico obrigatório dentro de [min, max]
+func queryFloat(c *fiber.Ctx, key string, min, max float64) (float64, bool) {
+	value, err := strconv.ParseFloat(c.Query(key), 64)
+	if err != nil || value < min || value > max {
+		return 0, false
+	}
+	return value, true
+}
+
+// StreamLocations godoc
+// @Summary Stream de localizações em tempo real
+// @Description Envia por SSE as localizações (event: location) e as entradas/saídas de cercas
+// @Description (event: geofence) dos técnicos visíveis ao usuário (admins: todos; demais:
+// @Description membros dos seus escopos da hierarquia)
+// @Tags Geo
+// @Produce text/event-stream
+// @Param scopeId query int false "Restringir a um node da hierarquia"
+// @Success 200 {string} string "event: location"
+// @Failure 403 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/geo/technicians/stream [get]
+func (h *GeoHandler) StreamLocations(c *fiber.Ctx) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"code":    "UNAUTHORIZED",
+				"message": "Invalid or missing token",
+			},
+		})
+	}
+
+	scopeID, err := strconv.ParseUint(c.Query("scopeId", "0"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"code":    "INVALID_SCOPE",
+				"message": "scopeId must be a node ID",
+			},
+		})
+	}
+
+	role, _ := c.Locals("userRole").(string)
+	sub, err := h.geoService.SubscribeLocations(userID.String(), role, uint(scopeID))
+	if err != nil {
+		if errors.Is(err, services.ErrNoHierarchyScope) || errors.Is(err, services.ErrScopeNotAccessible) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"success": false,
+				"error": fiber.Map{
+					"code":    "SCOPE_FORBIDDEN",
+					"message": err.Error(),
+				},
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"code":    "INTERNAL_ERROR",
+				"message": err.Error(),
+			},
+		})
+	}
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer h.geoService.UnsubscribeLocations(sub)
+
+		fmt.Fprint(w, "retry: 2000\n\n")
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(locationStreamHeartbeat)
+		defer heartbeat.Stop()
+		deadline := time.NewTimer(locationStreamMaxDuration)
+		defer deadline.Stop()
+
+		for {
+			select {
+			case event := <-sub.Events:
+				// Eventos descartados por lentidão: o cliente deve recarregar /technicians/last
+				if dropped := sub.Dropped(); dropped > 0 {
+					fmt.Fprintf(w, "event: lagged\ndata: {\"dropped\":%d}\n\n", dropped)
+				}
+				data, _ := json.Marshal(event)
+				fmt.Fprintf(w, "id: %s\nevent: location\ndata: %s\n\n", event.LocationID, data)
+			case event := <-sub.FenceEvents:
+				data, _ := json.Marshal(event)
+				fmt.Fprintf(w, "id: %s\nevent: geofence\ndata: %s\n\n", event.ID, data)
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": ping\n\n")
+			case <-deadline.C:
+				return
+			}
+			// Um flush com erro significa que o cliente desconectou
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	})
+
+	return nil
+}
+
 // GetTechnicianHistory godoc
 // @Summary Histórico de localização de um técnico
 // @Description Retorna o histórico de localizações do técnico por período
@@ -271,6 +552,7 @@ func (h *GeoHandler) GetTechniciansLastLocations(c *fiber.Ctx) error {
 // @Param eventType query string false "Filtrar por tipo (CHECKIN, CHECKOUT, HEARTBEAT)"
 // @Param page query int false "Página" default(1)
 // @Param limit query int false "Limite" default(100)
+// @Param cursor query string false "Cursor de paginação (vazio para a primeira página) em vez de page"
 // @Success 200 {object} map[string]interface{}
 // @Failure 400 {object} map[string]interface{}
 // @Failure 403 {object} map[string]interface{}
@@ -351,14 +633,32 @@ func (h *GeoHandler) GetTechnicianHistory(c *fiber.Ctx) error {
 	}
 
 	page, _ := strconv.Atoi(c.Query("page", "1"))
-	limit, _ := strconv.Atoi(c.Query("limit", "100"))
-	if limit > 1000 {
-		limit = 1000
-	}
+	limit := pageSize(c, pagination.GeoHistory, "limit")
 	filter.Limit = limit
 	filter.Offset = (page - 1) * limit
 
+	cursor, err := queryCursor(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"code":    "INVALID_CURSOR",
+				"message": err.Error(),
+			},
+		})
+	}
+	filter.Cursor = cursor
+
 	history, total, err := h.geoService.GetTechnicianHistory(userID, technicianID, filter)
+	if errors.Is(err, pagination.ErrInvalidCursor) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"code":    "INVALID_CURSOR",
+				"message": err.Error(),
+			},
+		})
+	}
 	if err != nil {
 		if err.Error() == "access denied: cannot view this technician's history" {
 			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
@@ -378,9 +678,20 @@ func (h *GeoHandler) GetTechnicianHistory(c *fiber.Ctx) error {
 		})
 	}
 
-	totalPages := int(total) / limit
-	if int(total)%limit > 0 {
-		totalPages++
+	pageInfo := fiber.Map{
+		"page":       page,
+		"limit":      limit,
+		"total":      total,
+		"totalPages": pagination.TotalPages(total, limit),
+		"hasNext":    pagination.HasNext(filter.Offset, limit, total),
+	}
+	if cursor != nil {
+		pageInfo = fiber.Map{
+			"limit":      limit,
+			"hasNext":    history.NextCursor != "",
+			"nextCursor": history.NextCursor,
+			"prevCursor": history.PrevCursor,
+		}
 	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
@@ -391,12 +702,7 @@ func (h *GeoHandler) GetTechnicianHistory(c *fiber.Ctx) error {
 			"period":         history.Period,
 			"summary":        history.Summary,
 			"locations":      history.Locations,
-			"pagination": fiber.Map{
-				"page":       page,
-				"limit":      limit,
-				"total":      total,
-				"totalPages": totalPages,
-			},
+			"pagination":     pageInfo,
 		},
 	})
 }
@@ -513,6 +819,48 @@ func (h *GeoHandler) UpdateGeoSettings(c *fiber.Ctx) error {
 	})
 }
 
+// RunBackfill godoc
+// @Summary Backfill geográfico a partir dos tickets
+// @Description Geocodifica os endereços dos clientes e cria check-ins aproximados dos técnicos nos tickets antigos, para as análises não começarem vazias. Repita enquanto remaining for true.
+// @Tags Geo
+// @Accept json
+// @Produce json
+// @Param request body models.GeoBackfillRequest false "Limite por execução e dry run"
+// @Success 200 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/admin/geo/backfill [post]
+func (h *GeoHandler) RunBackfill(c *fiber.Ctx) error {
+	var req models.GeoBackfillRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"error": fiber.Map{
+					"code":    "INVALID_BODY",
+					"message": "Invalid request body",
+				},
+			})
+		}
+	}
+
+	userID, _ := c.Locals("userId").(string)
+	result, err := h.geoService.BackfillFromTickets(userID, &req)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"code":    "INTERNAL_ERROR",
+				"message": err.Error(),
+			},
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    result,
+	})
+}
+
 // Helper para obter userID do contexto JWT
 func getUserIDFromContext(c *fiber.Ctx) (uuid.UUID, error) {
 	userIDStr := c.Locals("userId")
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+// GetRoutePlan godoc
+// @Summary Plano de rota do técnico
+// @Description Calcula a ordem otimizada de visitas dos tickets agendados do técnico no dia (vizinho mais próximo + 2-opt), com distância em linha reta e tempo estimado de deslocamento. Não grava o plano.
+// @Tags Geo
+// @Produce json
+// @Param id path string true "ID do técnico"
+// @Param date query string false "Dia (YYYY-MM-DD), padrão hoje"
+// @Param startLatitude query number false "Latitude do ponto de partida"
+// @Param startLongitude query number false "Longitude do ponto de partida"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/geo/technicians/{id}/route-plan [get]
+func (h *GeoHandler) GetRoutePlan(c *fiber.Ctx) error {
+	var req models.RoutePlanRequest
+	if err := c.QueryParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"code":    "INVALID_QUERY",
+				"message": "Invalid query parameters",
+			},
+		})
+	}
+	if errResponse := h.validateRoutePlanRequest(&req); errResponse != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errResponse)
+	}
+
+	plan, err := h.geoService.PlanRoute(c.Params("id"), &req)
+	if err != nil {
+		return h.routePlanError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    plan,
+	})
+}
+
+// SaveRoutePlan godoc
+// @Summary Gravar plano de rota
+// @Description Calcula o plano de rota do técnico no dia e o grava, substituindo o anterior
+// @Tags Geo
+// @Accept json
+// @Produce json
+// @Param id path string true "ID do técnico"
+// @Param request body models.RoutePlanRequest false "Dia e ponto de partida"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/geo/technicians/{id}/route-plan [post]
+func (h *GeoHandler) SaveRoutePlan(c *fiber.Ctx) error {
+	var req models.RoutePlanRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"error": fiber.Map{
+					"code":    "INVALID_BODY",
+					"message": "Invalid request body",
+				},
+			})
+		}
+	}
+	if errResponse := h.validateRoutePlanRequest(&req); errResponse != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errResponse)
+	}
+
+	userID, _ := c.Locals("userId").(string)
+	plan, err := h.geoService.SaveRoutePlan(userID, c.Params("id"), &req)
+	if err != nil {
+		return h.routePlanError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    plan,
+	})
+}
+
+// GetSavedRoutePlan godoc
+// @Summary Plano de rota gravado
+// @Tags Geo
+// @Produce json
+// @Param id path string true "ID do técnico"
+// @Param date query string false "Dia (YYYY-MM-DD), padrão hoje"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/geo/technicians/{id}/route-plan/saved [get]
+func (h *GeoHandler) GetSavedRoutePlan(c *fiber.Ctx) error {
+	plan, err := h.geoService.GetSavedRoutePlan(c.Params("id"), c.Query("date"))
+	if err != nil {
+		return h.routePlanError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    plan,
+	})
+}
+
+// validateRoutePlanRequest devolve a resposta de erro (nil se válido)
+func (h *GeoHandler) validateRoutePlanRequest(req *models.RoutePlanRequest) fiber.Map {
+	if err := h.validate.Struct(req); err != nil {
+		return fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"code":    "VALIDATION_FAILED",
+				"message": "Validation failed",
+				"details": formatValidationErrors(err),
+			},
+		}
+	}
+	return nil
+}
+
+func (h *GeoHandler) routePlanError(c *fiber.Ctx, err error) error {
+	status, code := fiber.StatusInternalServerError, "INTERNAL_ERROR"
+	switch {
+	case errors.Is(err, services.ErrRoutePlanTechnicianNotFound):
+		status, code = fiber.StatusNotFound, "TECHNICIAN_NOT_FOUND"
+	case errors.Is(err, services.ErrRoutePlanNotFound):
+		status, code = fiber.StatusNotFound, "ROUTE_PLAN_NOT_FOUND"
+	case errors.Is(err, services.ErrRoutePlanInvalidDate):
+		status, code = fiber.StatusBadRequest, "INVALID_DATE"
+	case errors.Is(err, services.ErrRoutePlanInvalidStart):
+		status, code = fiber.StatusBadRequest, "INVALID_START"
+	}
+
+	return c.Status(status).JSON(fiber.Map{
+		"success": false,
+		"error": fiber.Map{
+			"code":    code,
+			"message": err.Error(),
+		},
+	})
+}
@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+// ListFences godoc
+// @Summary Listar cercas
+// @Description Lista as cercas geográficas
+// @Tags Geo
+// @Produce json
+// @Param clientId query string false "Filtrar por cliente"
+// @Param nodeId query int false "Filtrar por node da hierarquia"
+// @Param inactive query bool false "Incluir cercas inativas"
+// @Success 200 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/geo/fences [get]
+func (h *GeoHandler) ListFences(c *fiber.Ctx) error {
+	filters := &models.GeoFenceFilters{
+		ClientID: c.Query("clientId"),
+		NodeID:   uint(c.QueryInt("nodeId", 0)),
+		Inactive: c.QueryBool("inactive", false),
+	}
+
+	fences, err := h.geoService.ListFences(filters)
+	if err != nil {
+		return h.fenceError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    fences,
+	})
+}
+
+// GetFence godoc
+// @Summary Obter cerca
+// @Tags Geo
+// @Produce json
+// @Param id path string true "ID da cerca"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/geo/fences/{id} [get]
+func (h *GeoHandler) GetFence(c *fiber.Ctx) error {
+	fence, err := h.geoService.GetFence(c.Params("id"))
+	if err != nil {
+		return h.fenceError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    fence,
+	})
+}
+
+// CreateFence godoc
+// @Summary Criar cerca
+// @Description Cria uma cerca circular (centro + raio) ou poligonal para um site de cliente ou node
+// @Tags Geo
+// @Accept json
+// @Produce json
+// @Param request body models.GeoFenceRequest true "Cerca"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/geo/fences [post]
+func (h *GeoHandler) CreateFence(c *fiber.Ctx) error {
+	var req models.GeoFenceRequest
+	if body := h.parseFenceRequest(c, &req); body != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(body)
+	}
+
+	userID, _ := c.Locals("userId").(string)
+	fence, err := h.geoService.CreateFence(&req, userID)
+	if err != nil {
+		return h.fenceError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"data":    fence,
+	})
+}
+
+// UpdateFence godoc
+// @Summary Atualizar cerca
+// @Tags Geo
+// @Accept json
+// @Produce json
+// @Param id path string true "ID da cerca"
+// @Param request body models.GeoFenceRequest true "Cerca"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/geo/fences/{id} [put]
+func (h *GeoHandler) UpdateFence(c *fiber.Ctx) error {
+	var req models.GeoFenceRequest
+	if body := h.parseFenceRequest(c, &req); body != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(body)
+	}
+
+	fence, err := h.geoService.UpdateFence(c.Params("id"), &req)
+	if err != nil {
+		return h.fenceError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    fence,
+	})
+}
+
+// DeleteFence godoc
+// @Summary Remover cerca
+// @Description Remove a cerca; o histórico de entradas e saídas é mantido
+// @Tags Geo
+// @Param id path string true "ID da cerca"
+// @Success 204
+// @Failure 404 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/geo/fences/{id} [delete]
+func (h *GeoHandler) DeleteFence(c *fiber.Ctx) error {
+	if err := h.geoService.DeleteFence(c.Params("id")); err != nil {
+		return h.fenceError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// GetFenceTechnicians godoc
+// @Summary Técnicos dentro da cerca
+// @Description Lista os técnicos que estão dentro da cerca agora
+// @Tags Geo
+// @Produce json
+// @Param id path string true "ID da cerca"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/geo/fences/{id}/technicians [get]
+func (h *GeoHandler) GetFenceTechnicians(c *fiber.Ctx) error {
+	technicians, err := h.geoService.GetFenceTechnicians(c.Params("id"))
+	if err != nil {
+		return h.fenceError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    technicians,
+	})
+}
+
+// GetFenceEvents godoc
+// @Summary Entradas e saídas da cerca
+// @Tags Geo
+// @Produce json
+// @Param id path string true "ID da cerca"
+// @Param page query int false "Página" default(0)
+// @Param size query int false "Tamanho" default(20)
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/geo/fences/{id}/events [get]
+func (h *GeoHandler) GetFenceEvents(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 0)
+	size := pageSize(c, pagination.Default, "size")
+	if page < 0 {
+		page = 0
+	}
+
+	events, total, err := h.geoService.GetFenceEvents(c.Params("id"), page, size)
+	if err != nil {
+		return h.fenceError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    models.NewPaginatedResponse(events, page, size, total),
+	})
+}
+
+// parseFenceRequest lê e valida o corpo, devolvendo a resposta de erro (nil se válido)
+func (h *GeoHandler) parseFenceRequest(c *fiber.Ctx, req *models.GeoFenceRequest) fiber.Map {
+	if err := c.BodyParser(req); err != nil {
+		return fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"code":    "INVALID_BODY",
+				"message": "Invalid request body",
+			},
+		}
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		return fiber.Map{
+			"success": false,
+			"error": fiber.Map{
+				"code":    "VALIDATION_FAILED",
+				"message": "Validation failed",
+				"details": formatValidationErrors(err),
+			},
+		}
+	}
+	return nil
+}
+
+func (h *GeoHandler) fenceError(c *fiber.Ctx, err error) error {
+	status, code := fiber.StatusInternalServerError, "INTERNAL_ERROR"
+	switch {
+	case errors.Is(err, services.ErrGeoFenceNotFound):
+		status, code = fiber.StatusNotFound, "FENCE_NOT_FOUND"
+	case errors.Is(err, services.ErrGeoFenceInvalidCircle),
+		errors.Is(err, services.ErrGeoFenceInvalidPolygon):
+		status, code = fiber.StatusBadRequest, "INVALID_SHAPE"
+	case errors.Is(err, services.ErrGeoFenceClientNotFound),
+		errors.Is(err, services.ErrGeoFenceNodeNotFound):
+		status, code = fiber.StatusBadRequest, "INVALID_OWNER"
+	}
+
+	return c.Status(status).JSON(fiber.Map{
+		"success": false,
+		"error": fiber.Map{
+			"code":    code,
+			"message": err.Error(),
+		},
+	})
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/json"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/middleware"
+	"github.com/shigake/tech-iq-back/internal/services"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+type GraphQLHandler struct {
+	service     services.GraphQLService
+	permissions *middleware.Permissions
+}
+
+func NewGraphQLHandler(service services.GraphQLService, permissions *middleware.Permissions) *GraphQLHandler {
+	return &GraphQLHandler{service: service, permissions: permissions}
+}
+
+// Query runs a GraphQL query over tickets, technicians, clients, stock balances and
+// financial summaries (schema in internal/graph/schema.graphqls). Stock fields need
+// inventory.view and financial ones finance.view; without them those fields are null with
+// an error. Nested relations are loaded through dataloaders, once per level, not per row.
+// @Summary Run GraphQL query
+// @Tags GraphQL
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body graphql.RawParams true "Query, variables and operation name"
+// @Success 200 {object} graphql.Response
+// @Router /graphql [post]
+func (h *GraphQLHandler) Query(c *fiber.Ctx) error {
+	var req graphql.RawParams
+	if c.Method() == fiber.MethodGet {
+		req.Query = c.Query("query")
+		req.OperationName = c.Query("operationName")
+		if variables := c.Query("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(graphql.Response{Errors: gqlerror.List{{Message: "Invalid variables"}}})
+			}
+		}
+	} else if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(graphql.Response{Errors: gqlerror.List{{Message: "Invalid request body"}}})
+	}
+	if req.Query == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(graphql.Response{Errors: gqlerror.List{{Message: "query is required"}}})
+	}
+
+	response := h.service.Execute(c.UserContext(), &req, services.GraphQLAccess{
+		Scope: accessScope(c),
+		Can: func(permission string) bool {
+			allowed, err := h.permissions.Has(c, permission)
+			return err == nil && allowed
+		},
+	})
+	if response.Data == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response)
+	}
+	return c.JSON(response)
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"strconv"
 	"time"
 
@@ -9,17 +10,20 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/shigake/tech-iq-back/internal/models"
 	"github.com/shigake/tech-iq-back/internal/repositories"
+	"github.com/shigake/tech-iq-back/internal/services"
 )
 
 type HierarchyHandler struct {
-	repo     repositories.HierarchyRepository
-	validate *validator.Validate
+	repo        repositories.HierarchyRepository
+	permissions services.PermissionService
+	validate    *validator.Validate
 }
 
-func NewHierarchyHandler(repo repositories.HierarchyRepository) *HierarchyHandler {
+func NewHierarchyHandler(repo repositories.HierarchyRepository, permissions services.PermissionService) *HierarchyHandler {
 	return &HierarchyHandler{
-		repo:     repo,
-		validate: validator.New(),
+		repo:        repo,
+		permissions: permissions,
+		validate:    validator.New(),
 	}
 }
 
@@ -291,6 +295,11 @@ func (h *HierarchyHandler) MoveNode(c *fiber.Ctx) error {
 	oldValue := *existing
 
 	if err := h.repo.MoveNode(uint(id), req.NewParentID); err != nil {
+		if errors.Is(err, repositories.ErrNodeMoveIntoSubtree) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to move node: " + err.Error(),
 		})
@@ -298,6 +307,7 @@ func (h *HierarchyHandler) MoveNode(c *fiber.Ctx) error {
 
 	updatedNode, _ := h.repo.GetNodeByID(uint(id))
 	h.logAction(c, "MOVE", "node", existing.ID, oldValue, updatedNode)
+	h.permissions.InvalidateAll()
 
 	return c.JSON(updatedNode)
 }
@@ -325,6 +335,7 @@ func (h *HierarchyHandler) DeleteNode(c *fiber.Ctx) error {
 	}
 
 	h.logAction(c, "DELETE", "node", uint(id), existing, nil)
+	h.permissions.InvalidateAll()
 
 	return c.SendStatus(fiber.StatusNoContent)
 }
@@ -415,6 +426,7 @@ func (h *HierarchyHandler) AddNodeMember(c *fiber.Ctx) error {
 	}
 
 	h.logAction(c, "CREATE", "membership", membership.ID, nil, membership)
+	h.permissions.InvalidateUser(membership.UserID)
 
 	return c.Status(fiber.StatusCreated).JSON(membership)
 }
@@ -452,6 +464,7 @@ func (h *HierarchyHandler) UpdateMembership(c *fiber.Ctx) error {
 	}
 
 	h.logAction(c, "UPDATE", "membership", existing.ID, oldValue, existing)
+	h.permissions.InvalidateUser(existing.UserID)
 
 	return c.JSON(existing)
 }
@@ -479,6 +492,7 @@ func (h *HierarchyHandler) DeleteMembership(c *fiber.Ctx) error {
 	}
 
 	h.logAction(c, "DELETE", "membership", uint(id), existing, nil)
+	h.permissions.InvalidateUser(existing.UserID)
 
 	return c.SendStatus(fiber.StatusNoContent)
 }
@@ -614,6 +628,7 @@ func (h *HierarchyHandler) UpdateRole(c *fiber.Ctx) error {
 	}
 
 	h.logAction(c, "UPDATE", "role", existing.ID, oldValue, existing)
+	h.permissions.InvalidateAll()
 
 	return c.JSON(existing)
 }
@@ -641,6 +656,7 @@ func (h *HierarchyHandler) DeleteRole(c *fiber.Ctx) error {
 	}
 
 	h.logAction(c, "DELETE", "role", uint(id), existing, nil)
+	h.permissions.InvalidateAll()
 
 	return c.SendStatus(fiber.StatusNoContent)
 }
@@ -729,6 +745,7 @@ func (h *HierarchyHandler) RevertChange(c *fiber.Ctx) error {
 			"error": "Failed to revert change: " + err.Error(),
 		})
 	}
+	h.permissions.InvalidateAll()
 
 	return c.JSON(fiber.Map{
 		"message": "Change reverted successfully",
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type IncidentTimelineHandler struct {
+	service  services.IncidentTimelineService
+	validate *validator.Validate
+}
+
+func NewIncidentTimelineHandler(service services.IncidentTimelineService) *IncidentTimelineHandler {
+	return &IncidentTimelineHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// GetTimeline assembles the incident timeline of a window (?from=&to=, RFC3339; to defaults
+// to now). ?format=markdown downloads it as a postmortem document.
+// @Summary Incident timeline
+// @Tags Admin
+// @Produce json
+// @Param from query string true "Window start (RFC3339)"
+// @Param to query string false "Window end (RFC3339)"
+// @Param format query string false "json or markdown"
+// @Success 200 {object} models.IncidentTimeline
+// @Router /admin/incident-timeline [get]
+func (h *IncidentTimelineHandler) GetTimeline(c *fiber.Ctx) error {
+	if c.Query("from") == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "from is required"})
+	}
+	from, to, err := h.window(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	timeline, err := h.service.Build(from, to)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	if c.Query("format") == "markdown" {
+		c.Set(fiber.HeaderContentType, "text/markdown; charset=utf-8")
+		c.Attachment(fmt.Sprintf("incident-timeline-%s.md", from.Format("20060102-1504")))
+		return c.SendString(h.service.RenderMarkdown(timeline))
+	}
+	return c.JSON(timeline)
+}
+
+// ListDeploys returns the deploy markers of a window (?from=&to=, default the last 7 days)
+// @Summary List deploy markers
+// @Tags Admin
+// @Produce json
+// @Success 200 {array} models.DeployMarker
+// @Router /admin/deploy-markers [get]
+func (h *IncidentTimelineHandler) ListDeploys(c *fiber.Ctx) error {
+	from, to, err := h.window(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if c.Query("from") == "" {
+		from = to.AddDate(0, 0, -7)
+	}
+
+	markers, err := h.service.ListDeploys(from, to)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch deploy markers",
+		})
+	}
+	return c.JSON(markers)
+}
+
+// CreateDeploy records a deploy, usually posted by the CI pipeline
+// @Summary Create deploy marker
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param body body models.CreateDeployMarkerRequest true "Deploy"
+// @Success 201 {object} models.DeployMarker
+// @Router /admin/deploy-markers [post]
+func (h *IncidentTimelineHandler) CreateDeploy(c *fiber.Ctx) error {
+	var req models.CreateDeployMarkerRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if err := h.validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	userID, _ := c.Locals("userId").(string)
+	marker, err := h.service.CreateDeploy(userID, &req)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create deploy marker",
+		})
+	}
+	return c.Status(fiber.StatusCreated).JSON(marker)
+}
+
+// DeleteDeploy removes a deploy marker
+// @Summary Delete deploy marker
+// @Tags Admin
+// @Param id path string true "Marker ID"
+// @Success 204
+// @Router /admin/deploy-markers/{id} [delete]
+func (h *IncidentTimelineHandler) DeleteDeploy(c *fiber.Ctx) error {
+	if err := h.service.DeleteDeploy(c.Params("id")); err != nil {
+		return h.handleError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// window reads ?from= and ?to=; a missing to is now, a missing from is left zero
+func (h *IncidentTimelineHandler) window(c *fiber.Ctx) (time.Time, time.Time, error) {
+	var from time.Time
+	to := time.Now()
+	if value := c.Query("from"); value != "" {
+		parsed, err := parseTime(value)
+		if err != nil {
+			return from, to, errors.New("invalid from date format")
+		}
+		from = parsed
+	}
+	if value := c.Query("to"); value != "" {
+		parsed, err := parseTime(value)
+		if err != nil {
+			return from, to, errors.New("invalid to date format")
+		}
+		to = parsed
+	}
+	return from, to, nil
+}
+
+func (h *IncidentTimelineHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrDeployMarkerNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrIncidentWindowInvalid), errors.Is(err, services.ErrIncidentWindowTooLong):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
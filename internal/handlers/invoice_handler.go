@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type InvoiceHandler struct {
+	service  services.InvoiceService
+	validate *validator.Validate
+}
+
+func NewInvoiceHandler(service services.InvoiceService) *InvoiceHandler {
+	return &InvoiceHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// List returns the invoices, newest first
+// @Summary List invoices
+// @Tags Financial
+// @Produce json
+// @Param status query string false "PENDING, PROCESSING, ISSUED, ERROR, REJECTED or CANCELLED"
+// @Param entryId query string false "Financial entry ID"
+// @Param clientId query string false "Client ID"
+// @Param page query int false "Page (0-based)"
+// @Param size query int false "Page size"
+// @Success 200 {object} models.PaginatedResponse
+// @Router /financial/invoices [get]
+func (h *InvoiceHandler) List(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 0)
+	size := pageSize(c, pagination.Default, "size")
+	if page < 0 {
+		page = 0
+	}
+	filter := models.InvoiceFilter{
+		Status:   strings.ToUpper(c.Query("status")),
+		EntryID:  c.Query("entryId"),
+		ClientID: c.Query("clientId"),
+	}
+
+	invoices, err := h.service.WithContext(c.UserContext()).List(filter, page, size)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(invoices)
+}
+
+// Get returns an invoice with its issuance status and last error
+// @Summary Get invoice
+// @Tags Financial
+// @Produce json
+// @Param id path string true "Invoice ID"
+// @Success 200 {object} models.Invoice
+// @Router /financial/invoices/{id} [get]
+func (h *InvoiceHandler) Get(c *fiber.Ctx) error {
+	invoice, err := h.service.WithContext(c.UserContext()).Get(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(invoice)
+}
+
+// Create queues the invoice of an income entry; it is issued in the background
+// @Summary Create invoice
+// @Tags Financial
+// @Accept json
+// @Produce json
+// @Param body body models.CreateInvoiceRequest true "Invoice"
+// @Success 202 {object} models.Invoice
+// @Router /financial/invoices [post]
+func (h *InvoiceHandler) Create(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+
+	var req models.CreateInvoiceRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	req.Kind = strings.ToUpper(req.Kind)
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+	invoice, err := h.service.WithContext(c.UserContext()).Create(&req, userID)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Status(fiber.StatusAccepted).JSON(invoice)
+}
+
+// Retry queues the issuance again, e.g. after fixing the client data of a rejected invoice
+// @Summary Retry invoice
+// @Tags Financial
+// @Produce json
+// @Param id path string true "Invoice ID"
+// @Success 202 {object} models.Invoice
+// @Router /financial/invoices/{id}/retry [post]
+func (h *InvoiceHandler) Retry(c *fiber.Ctx) error {
+	invoice, err := h.service.WithContext(c.UserContext()).Retry(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Status(fiber.StatusAccepted).JSON(invoice)
+}
+
+// Cancel cancels the invoice, with the provider when it was issued
+// @Summary Cancel invoice
+// @Tags Financial
+// @Accept json
+// @Produce json
+// @Param id path string true "Invoice ID"
+// @Param body body models.CancelInvoiceRequest true "Reason"
+// @Success 200 {object} models.Invoice
+// @Router /financial/invoices/{id}/cancel [post]
+func (h *InvoiceHandler) Cancel(c *fiber.Ctx) error {
+	var req models.CancelInvoiceRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+	invoice, err := h.service.WithContext(c.UserContext()).Cancel(c.Params("id"), req.Reason)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(invoice)
+}
+
+// Document returns a short-lived download URL of the XML or PDF of an issued invoice
+// @Summary Invoice document
+// @Tags Financial
+// @Produce json
+// @Param id path string true "Invoice ID"
+// @Param document path string true "xml or pdf"
+// @Success 200 {object} models.FileDownload
+// @Router /financial/invoices/{id}/{document} [get]
+func (h *InvoiceHandler) Document(c *fiber.Ctx) error {
+	document := strings.ToLower(c.Params("document"))
+	if document != "xml" && document != "pdf" {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "document must be xml or pdf"})
+	}
+	download, err := h.service.WithContext(c.UserContext()).DocumentURL(c.Params("id"), document)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(download)
+}
+
+func (h *InvoiceHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrInvoiceNotFound),
+		errors.Is(err, services.ErrInvoiceEntryNotFound),
+		errors.Is(err, services.ErrInvoiceDocumentMissing),
+		errors.Is(err, services.ErrFileNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrInvoiceEntryNotIncome),
+		errors.Is(err, services.ErrInvoiceEntryCancelled),
+		errors.Is(err, services.ErrInvoiceClientRequired),
+		errors.Is(err, services.ErrInvoiceClientDocument):
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrInvoiceEntryInvoiced),
+		errors.Is(err, services.ErrInvoiceNotRetryable),
+		errors.Is(err, services.ErrInvoiceNotCancellable):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrInvoicingNotConfigured):
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
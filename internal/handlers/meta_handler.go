@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type MetaHandler struct {
+	service services.MetaService
+}
+
+func NewMetaHandler(service services.MetaService) *MetaHandler {
+	return &MetaHandler{service: service}
+}
+
+// GetSchema returns the data dictionary: entities, fields, types, enums and relationships.
+// The version doubles as ETag, so integrators can poll with If-None-Match.
+func (h *MetaHandler) GetSchema(c *fiber.Ctx) error {
+	schema, err := h.service.Schema()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to describe the data model",
+		})
+	}
+
+	etag := `"` + schema.Version + `"`
+	c.Set(fiber.HeaderETag, etag)
+	if c.Get(fiber.HeaderIfNoneMatch) == etag {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+	return c.JSON(schema)
+}
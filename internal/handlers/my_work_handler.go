@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type MyWorkHandler struct {
+	service services.MyWorkService
+}
+
+func NewMyWorkHandler(service services.MyWorkService) *MyWorkHandler {
+	return &MyWorkHandler{service: service}
+}
+
+// GetSummary returns the unified inbox of the logged user: pending approvals, tickets of
+// the user's team queues, mentions in ticket comments and open alerts
+// @Summary My-work summary
+// @Tags Me
+// @Produce json
+// @Success 200 {object} models.MyWorkSummary
+// @Router /me/summary [get]
+func (h *MyWorkHandler) GetSummary(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+	userRole, _ := c.Locals("userRole").(string)
+
+	summary, err := h.service.GetSummary(userID, userRole)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load my-work summary",
+		})
+	}
+	return c.JSON(summary)
+}
@@ -0,0 +1,67 @@
+//go:build integration
+
+package handlers_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/handlers"
+	"github.com/shigake/tech-iq-back/internal/middleware"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"github.com/shigake/tech-iq-back/internal/services"
+	"github.com/shigake/tech-iq-back/internal/testutil"
+)
+
+func node(t *testing.T, repo repositories.HierarchyRepository, id uint) *models.Node {
+	t.Helper()
+	n, err := repo.GetNodeByID(id)
+	if err != nil {
+		t.Fatalf("node %d: %v", id, err)
+	}
+	return n
+}
+
+// TestMoveNodeRebasesSubtree moves Branch (with a child of its own) from Tenant to Other
+// Tenant, then tries to move Other Tenant under that child
+func TestMoveNodeRebasesSubtree(t *testing.T) {
+	env.Reset(t)
+	repo := repositories.NewHierarchyRepository(env.DB)
+	handler := handlers.NewHierarchyHandler(repo, services.NewPermissionService(repo, nil))
+	app := fiber.New()
+	nodes := app.Group("/nodes", middleware.JWTProtected(env.Config.JWTSecret, nil, nil))
+	nodes.Put("/:id/move", handler.MoveNode)
+	token := env.AdminToken(t)
+
+	branch := node(t, repo, testutil.BranchNodeID)
+	team := &models.Node{HierarchyID: branch.HierarchyID, Name: "Team", ParentID: &testutil.BranchNodeID}
+	if err := repo.CreateNode(team); err != nil {
+		t.Fatalf("create team: %v", err)
+	}
+
+	testutil.Do(t, app, http.MethodPut, fmt.Sprintf("/nodes/%d/move", testutil.BranchNodeID),
+		models.MoveNodeRequest{NewParentID: &testutil.OtherNodeID}, token).Expect(t, http.StatusOK)
+
+	other := node(t, repo, testutil.OtherNodeID)
+	branch = node(t, repo, testutil.BranchNodeID)
+	if want := fmt.Sprintf("%s.%d", other.Path, branch.ID); branch.Path != want || branch.Depth != other.Depth+1 {
+		t.Fatalf("branch at %q depth %d, want %q depth %d", branch.Path, branch.Depth, want, other.Depth+1)
+	}
+	team = node(t, repo, team.ID)
+	if want := fmt.Sprintf("%s.%d", branch.Path, team.ID); team.Path != want || team.Depth != branch.Depth+1 {
+		t.Fatalf("team at %q depth %d, want %q depth %d", team.Path, team.Depth, want, branch.Depth+1)
+	}
+
+	tenantNode := node(t, repo, testutil.TenantNodeID)
+	testutil.Do(t, app, http.MethodPut, fmt.Sprintf("/nodes/%d/move", testutil.OtherNodeID),
+		models.MoveNodeRequest{NewParentID: &team.ID}, token).Expect(t, http.StatusBadRequest)
+	if moved := node(t, repo, testutil.OtherNodeID); moved.Path != other.Path || moved.ParentID != nil {
+		t.Fatalf("other tenant moved into its own subtree: %q", moved.Path)
+	}
+	if unchanged := node(t, repo, testutil.TenantNodeID); unchanged.Path != tenantNode.Path {
+		t.Fatalf("tenant path changed to %q", unchanged.Path)
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type NotificationHandler struct {
+	service services.NotificationService
+}
+
+func NewNotificationHandler(service services.NotificationService) *NotificationHandler {
+	return &NotificationHandler{service: service}
+}
+
+// List returns the logged user's notifications, newest first (?unread=true&event=&page=&size=)
+func (h *NotificationHandler) List(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+	page := c.QueryInt("page", 0)
+	size := pageSize(c, pagination.Default, "size")
+	if page < 0 {
+		page = 0
+	}
+
+	filters := &models.NotificationFilters{
+		UnreadOnly: c.QueryBool("unread"),
+		Event:      strings.ToUpper(c.Query("event")),
+	}
+
+	result, err := h.service.List(userID, page, size, filters)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch notifications",
+		})
+	}
+	return c.JSON(result)
+}
+
+// UnreadCount returns the number of unread notifications of the logged user (topbar badge)
+func (h *NotificationHandler) UnreadCount(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+
+	unread, err := h.service.UnreadCount(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to count notifications",
+		})
+	}
+	return c.JSON(fiber.Map{"unread": unread})
+}
+
+// MarkRead marks one notification of the logged user as read
+func (h *NotificationHandler) MarkRead(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+
+	result, err := h.service.MarkRead(userID, c.Params("id"))
+	if err != nil {
+		if errors.Is(err, services.ErrNotificationNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(result)
+}
+
+// MarkAllRead marks every notification of the logged user as read
+func (h *NotificationHandler) MarkAllRead(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+
+	result, err := h.service.MarkAllRead(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(result)
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type NPSHandler struct {
+	service  services.NPSService
+	validate *validator.Validate
+}
+
+func NewNPSHandler(service services.NPSService) *NPSHandler {
+	return &NPSHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// ListCampaigns returns the NPS campaigns, newest period first
+func (h *NPSHandler) ListCampaigns(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 0)
+	size := pageSize(c, pagination.Default, "size")
+	if page < 0 {
+		page = 0
+	}
+
+	result, err := h.service.ListCampaigns(page, size)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch NPS campaigns",
+		})
+	}
+	return c.JSON(result)
+}
+
+// GetCampaign returns one NPS campaign
+func (h *NPSHandler) GetCampaign(c *fiber.Ctx) error {
+	campaign, err := h.service.GetCampaign(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(campaign)
+}
+
+// CreateCampaign creates a draft campaign with its sample criteria
+func (h *NPSHandler) CreateCampaign(c *fiber.Ctx) error {
+	var req models.CreateNPSCampaignRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	userID, _ := c.Locals("userId").(string)
+
+	campaign, err := h.service.CreateCampaign(userID, &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(campaign)
+}
+
+// SendCampaign draws the client sample and sends the survey links
+func (h *NPSHandler) SendCampaign(c *fiber.Ctx) error {
+	campaign, err := h.service.SendCampaign(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Status(fiber.StatusAccepted).JSON(campaign)
+}
+
+// CloseCampaign stops accepting answers
+func (h *NPSHandler) CloseCampaign(c *fiber.Ctx) error {
+	campaign, err := h.service.CloseCampaign(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(campaign)
+}
+
+// GetInvitations lists the campaign invitations with their delivery status and survey links
+func (h *NPSHandler) GetInvitations(c *fiber.Ctx) error {
+	invitations, err := h.service.GetInvitations(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(invitations)
+}
+
+// GetResults returns the campaign NPS, score distribution, regions and comments
+func (h *NPSHandler) GetResults(c *fiber.Ctx) error {
+	results, err := h.service.GetResults(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(results)
+}
+
+// GetTrend returns the NPS per month or quarter (?from=&to=&groupBy=&state=)
+func (h *NPSHandler) GetTrend(c *fiber.Ctx) error {
+	to := time.Now()
+	from := to.AddDate(-1, 0, 0)
+	if s := c.Query("from"); s != "" {
+		t, err := parseTime(s)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid from date"})
+		}
+		from = t
+	}
+	if s := c.Query("to"); s != "" {
+		t, err := parseTime(s)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid to date"})
+		}
+		to = t
+	}
+	if !from.Before(to) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "from must be before to"})
+	}
+
+	trend, err := h.service.GetTrend(from, to, c.Query("groupBy"), c.Query("state"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(trend)
+}
+
+// GetDashboard returns the current and previous quarter NPS with the 12-month trend
+func (h *NPSHandler) GetDashboard(c *fiber.Ctx) error {
+	dashboard, err := h.service.GetDashboard()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch NPS dashboard",
+		})
+	}
+	return c.JSON(dashboard)
+}
+
+// GetPublicSurvey returns the survey behind a link (public, authorized by the token)
+func (h *NPSHandler) GetPublicSurvey(c *fiber.Ctx) error {
+	survey, err := h.service.GetSurvey(c.Params("token"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(survey)
+}
+
+// AnswerPublicSurvey records the client score (0-10) and comment
+func (h *NPSHandler) AnswerPublicSurvey(c *fiber.Ctx) error {
+	var req models.NPSAnswerRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	if err := h.service.Answer(c.Params("token"), &req); err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(fiber.Map{"message": "Thank you for your feedback"})
+}
+
+func (h *NPSHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrNPSCampaignNotFound),
+		errors.Is(err, services.ErrNPSSurveyNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrNPSCampaignAlreadySent),
+		errors.Is(err, services.ErrNPSAlreadyAnswered):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrNPSCampaignClosed):
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrNPSInvalidPeriod),
+		errors.Is(err, services.ErrNPSInvalidGroupBy),
+		errors.Is(err, services.ErrInvalidClientSegment):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrNPSEmptySample),
+		errors.Is(err, services.ErrNPSUnknownChannel):
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
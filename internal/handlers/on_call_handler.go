@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type OnCallHandler struct {
+	service  services.OnCallService
+	validate *validator.Validate
+}
+
+func NewOnCallHandler(service services.OnCallService) *OnCallHandler {
+	return &OnCallHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// ListSchedules returns the on-call schedules with their members in rotation order
+func (h *OnCallHandler) ListSchedules(c *fiber.Ctx) error {
+	schedules, err := h.service.ListSchedules()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch on-call schedules",
+		})
+	}
+	return c.JSON(schedules)
+}
+
+// GetSchedule returns an on-call schedule
+func (h *OnCallHandler) GetSchedule(c *fiber.Ctx) error {
+	schedule, err := h.service.GetSchedule(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(schedule)
+}
+
+// CreateSchedule adds the on-call rotation of a region
+func (h *OnCallHandler) CreateSchedule(c *fiber.Ctx) error {
+	var req models.OnCallScheduleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	schedule, err := h.service.CreateSchedule(&req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(schedule)
+}
+
+// UpdateSchedule replaces an on-call schedule and its rotation
+func (h *OnCallHandler) UpdateSchedule(c *fiber.Ctx) error {
+	var req models.OnCallScheduleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	schedule, err := h.service.UpdateSchedule(c.Params("id"), &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(schedule)
+}
+
+// WhoIsOnCall returns the escalation order of a schedule (?at=, default now)
+func (h *OnCallHandler) WhoIsOnCall(c *fiber.Ctx) error {
+	at := time.Now()
+	if s := c.Query("at"); s != "" {
+		t, err := parseTime(s)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid at date"})
+		}
+		at = t
+	}
+
+	now, err := h.service.WhoIsOnCall(c.Params("id"), at)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(now)
+}
+
+// PriorityDispatch pages the on-call of the ticket region for an urgent ticket
+func (h *OnCallHandler) PriorityDispatch(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+
+	var req models.PriorityDispatchRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	page, err := h.service.PriorityDispatch(c.Params("id"), &req, userID)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(page)
+}
+
+// ListPages lists the on-call pages, newest first (?status=&ticketId=&technicianId=&page=&size=)
+func (h *OnCallHandler) ListPages(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 0)
+	size := pageSize(c, pagination.Default, "size")
+	if page < 0 {
+		page = 0
+	}
+
+	filters := &models.OnCallPageFilters{
+		Status:       c.Query("status"),
+		TicketID:     c.Query("ticketId"),
+		TechnicianID: c.Query("technicianId"),
+	}
+
+	result, err := h.service.ListPages(page, size, filters)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch on-call pages",
+		})
+	}
+	return c.JSON(result)
+}
+
+// GetPage returns an on-call page with its delivery attempts
+func (h *OnCallHandler) GetPage(c *fiber.Ctx) error {
+	page, err := h.service.GetPage(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(page)
+}
+
+// MyPages returns the pending pages of the logged technician
+func (h *OnCallHandler) MyPages(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+
+	pages, err := h.service.MyPages(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch on-call pages",
+		})
+	}
+	return c.JSON(pages)
+}
+
+// Acknowledge takes the paged ticket
+func (h *OnCallHandler) Acknowledge(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+	userRole, _ := c.Locals("userRole").(string)
+
+	page, err := h.service.Acknowledge(c.Params("id"), userID, userRole)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(page)
+}
+
+// Cancel stops paging the on-call for a ticket
+func (h *OnCallHandler) Cancel(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+
+	page, err := h.service.Cancel(c.Params("id"), userID)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(page)
+}
+
+// Escalate pages now the next technician of the pages whose acknowledgement timed out
+func (h *OnCallHandler) Escalate(c *fiber.Ctx) error {
+	result, err := h.service.Escalate()
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(result)
+}
+
+func (h *OnCallHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrOnCallScheduleNotFound),
+		errors.Is(err, services.ErrOnCallPageNotFound),
+		errors.Is(err, services.ErrOnCallTicketNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrOnCallInvalidSchedule),
+		errors.Is(err, services.ErrOnCallNotEmergency),
+		errors.Is(err, services.ErrOnCallTicketFinished),
+		errors.Is(err, services.ErrOnCallNoSchedule):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrOnCallBusinessHours),
+		errors.Is(err, services.ErrOnCallAlreadyPaged),
+		errors.Is(err, services.ErrOnCallPageNotPending):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrOnCallNotPaged):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type OutboxHandler struct {
+	service services.OutboxService
+}
+
+func NewOutboxHandler(service services.OutboxService) *OutboxHandler {
+	return &OutboxHandler{service: service}
+}
+
+// List returns the outbox events, newest first
+// @Summary List outbox events
+// @Tags Admin
+// @Produce json
+// @Param topic query string false "Topic"
+// @Param status query string false "PENDING, PROCESSED or FAILED"
+// @Param aggregateId query string false "Aggregate ID (e.g. the ticket)"
+// @Param page query int false "Page (0-based)"
+// @Param size query int false "Page size"
+// @Success 200 {object} models.PaginatedResponse
+// @Router /admin/outbox-events [get]
+func (h *OutboxHandler) List(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 0)
+	size := pageSize(c, pagination.Default, "size")
+	if page < 0 {
+		page = 0
+	}
+	filter := models.OutboxEventFilter{
+		Topic:       c.Query("topic"),
+		Status:      strings.ToUpper(c.Query("status")),
+		AggregateID: c.Query("aggregateId"),
+	}
+
+	events, err := h.service.WithContext(c.UserContext()).List(filter, page, size)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(events)
+}
+
+// Get returns an outbox event with its last error or result
+// @Summary Get outbox event
+// @Tags Admin
+// @Produce json
+// @Param id path string true "Event ID"
+// @Success 200 {object} models.OutboxEvent
+// @Router /admin/outbox-events/{id} [get]
+func (h *OutboxHandler) Get(c *fiber.Ctx) error {
+	event, err := h.service.WithContext(c.UserContext()).Get(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(event)
+}
+
+// Retry queues the event again, typically a FAILED one after fixing its cause
+// @Summary Retry outbox event
+// @Tags Admin
+// @Produce json
+// @Param id path string true "Event ID"
+// @Success 200 {object} models.OutboxEvent
+// @Router /admin/outbox-events/{id}/retry [post]
+func (h *OutboxHandler) Retry(c *fiber.Ctx) error {
+	event, err := h.service.WithContext(c.UserContext()).Retry(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(event)
+}
+
+func (h *OutboxHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrOutboxEventNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/services"
+	"gorm.io/gorm"
+)
+
+type PDFHandler struct {
+	service services.PDFService
+}
+
+func NewPDFHandler(service services.PDFService) *PDFHandler {
+	return &PDFHandler{service: service}
+}
+
+// ServiceOrder returns the A4 service order of a ticket, with the parts used and the signatures
+func (h *PDFHandler) ServiceOrder(c *fiber.Ctx) error {
+	content, err := h.service.WithContext(c.UserContext()).ServiceOrder(c.Params("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Ticket not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to render service order"})
+	}
+	return sendPDF(c, content, "os-"+c.Params("id")+".pdf")
+}
+
+// BatchReceipt returns the payment receipt of an approved or paid batch
+func (h *PDFHandler) BatchReceipt(c *fiber.Ctx) error {
+	content, err := h.service.WithContext(c.UserContext()).BatchReceipt(c.Params("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Batch not found"})
+		case errors.Is(err, services.ErrReceiptUnavailable):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to render receipt"})
+	}
+	return sendPDF(c, content, "recibo-"+c.Params("id")+".pdf")
+}
+
+// sendPDF sends the document inline, so browsers open it instead of downloading it
+func sendPDF(c *fiber.Ctx, content []byte, filename string) error {
+	c.Set("Content-Type", "application/pdf")
+	c.Set("Content-Disposition", `inline; filename="`+filename+`"`)
+	return c.Send(content)
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type PriceListHandler struct {
+	service  services.PriceListService
+	validate *validator.Validate
+}
+
+func NewPriceListHandler(service services.PriceListService) *PriceListHandler {
+	return &PriceListHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// List returns price lists (?clientId=<id>|default&activeOn=YYYY-MM-DD&inactive=true)
+func (h *PriceListHandler) List(c *fiber.Ctx) error {
+	filters := &models.PriceListFilters{
+		ClientID: c.Query("clientId"),
+		Inactive: c.QueryBool("inactive"),
+	}
+	if s := c.Query("activeOn"); s != "" {
+		t, err := parseTime(s)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid activeOn date"})
+		}
+		filters.ActiveOn = &t
+	}
+
+	lists, err := h.service.List(filters)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch price lists",
+		})
+	}
+	return c.JSON(lists)
+}
+
+// Get returns a price list with its entries
+func (h *PriceListHandler) Get(c *fiber.Ctx) error {
+	list, err := h.service.Get(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(list)
+}
+
+// Create adds a client price list, or a default one when clientId is empty
+func (h *PriceListHandler) Create(c *fiber.Ctx) error {
+	var req models.CreatePriceListRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	userID, _ := c.Locals("userId").(string)
+
+	list, err := h.service.Create(userID, &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(list)
+}
+
+// Update changes the name, validity period or active flag of a price list
+func (h *PriceListHandler) Update(c *fiber.Ctx) error {
+	var req models.UpdatePriceListRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	userID, _ := c.Locals("userId").(string)
+
+	list, err := h.service.Update(c.Params("id"), userID, &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(list)
+}
+
+// AddEntry prices a service, labor rate or part on the list
+func (h *PriceListHandler) AddEntry(c *fiber.Ctx) error {
+	var req models.PriceListEntryRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	userID, _ := c.Locals("userId").(string)
+
+	list, err := h.service.AddEntry(c.Params("id"), userID, &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(list)
+}
+
+// UpdateEntry replaces an entry of the list
+func (h *PriceListHandler) UpdateEntry(c *fiber.Ctx) error {
+	var req models.PriceListEntryRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	userID, _ := c.Locals("userId").(string)
+
+	list, err := h.service.UpdateEntry(c.Params("id"), c.Params("entryId"), userID, &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(list)
+}
+
+// DeleteEntry removes an entry from the list
+func (h *PriceListHandler) DeleteEntry(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+
+	if err := h.service.DeleteEntry(c.Params("id"), c.Params("entryId"), userID); err != nil {
+		return h.handleError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// GetHistory returns the change history of a price list, newest first
+func (h *PriceListHandler) GetHistory(c *fiber.Ctx) error {
+	changes, err := h.service.GetHistory(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(changes)
+}
+
+// Resolve returns the effective price of ticket lines for quotes, invoicing and profitability reports
+func (h *PriceListHandler) Resolve(c *fiber.Ctx) error {
+	var req models.ResolvePricesRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	result, err := h.service.Resolve(&req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(result)
+}
+
+func (h *PriceListHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrPriceListNotFound),
+		errors.Is(err, services.ErrPriceEntryNotFound),
+		errors.Is(err, services.ErrPriceTicketNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrPriceClientNotFound),
+		errors.Is(err, services.ErrPriceCategoryNotFound),
+		errors.Is(err, services.ErrPriceItemNotFound),
+		errors.Is(err, services.ErrPriceInvalidDate),
+		errors.Is(err, services.ErrPriceInvalidPeriod),
+		errors.Is(err, services.ErrPriceNegative),
+		errors.Is(err, services.ErrPriceInvalidQuantity),
+		errors.Is(err, services.ErrPriceEntryInvalidTarget):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrPriceEntryDuplicate):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
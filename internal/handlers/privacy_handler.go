@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type PrivacyHandler struct {
+	service  services.PrivacyService
+	validate *validator.Validate
+}
+
+func NewPrivacyHandler(service services.PrivacyService) *PrivacyHandler {
+	return &PrivacyHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// Export returns a JSON copy of the personal data of the logged user
+// @Summary Export my personal data (LGPD)
+// @Tags Me
+// @Produce json
+// @Success 200 {object} models.PersonalDataExport
+// @Router /me/export [post]
+func (h *PrivacyHandler) Export(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+
+	export, err := h.service.Export(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to export personal data",
+		})
+	}
+	c.Attachment(fmt.Sprintf("personal-data-%s.json", time.Now().Format("20060102")))
+	return c.JSON(export)
+}
+
+// RequestDeletion schedules the deletion of the logged user's account after the grace period
+// @Summary Request account deletion (LGPD)
+// @Tags Me
+// @Accept json
+// @Produce json
+// @Param body body models.RequestAccountDeletionRequest true "Password confirmation and reason"
+// @Success 202 {object} models.PrivacyRequest
+// @Router /me/delete [post]
+func (h *PrivacyHandler) RequestDeletion(c *fiber.Ctx) error {
+	var req models.RequestAccountDeletionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if err := h.validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	userID, _ := c.Locals("userId").(string)
+	request, err := h.service.RequestDeletion(userID, &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Status(fiber.StatusAccepted).JSON(request)
+}
+
+// CancelDeletion cancels the scheduled deletion of the logged user's account
+// @Summary Cancel account deletion
+// @Tags Me
+// @Produce json
+// @Success 200 {object} models.PrivacyRequest
+// @Router /me/delete [delete]
+func (h *PrivacyHandler) CancelDeletion(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+	request, err := h.service.CancelDeletion(userID)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(request)
+}
+
+// ListMine returns the privacy requests of the logged user
+// @Summary My privacy requests
+// @Tags Me
+// @Produce json
+// @Success 200 {array} models.PrivacyRequest
+// @Router /me/privacy-requests [get]
+func (h *PrivacyHandler) ListMine(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+	requests, err := h.service.ListMine(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch privacy requests",
+		})
+	}
+	return c.JSON(requests)
+}
+
+// List returns the privacy requests of every user (?userId=&type=&status=)
+// @Summary List privacy requests
+// @Tags Admin
+// @Produce json
+// @Success 200 {object} models.PaginatedResponse
+// @Router /admin/privacy-requests [get]
+func (h *PrivacyHandler) List(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 0)
+	size := pageSize(c, pagination.Users, "size")
+	if page < 0 {
+		page = 0
+	}
+	filters := &models.PrivacyRequestFilters{
+		UserID: c.Query("userId"),
+		Type:   strings.ToUpper(c.Query("type")),
+		Status: strings.ToUpper(c.Query("status")),
+	}
+
+	result, err := h.service.List(page, size, filters)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch privacy requests",
+		})
+	}
+	return c.JSON(result)
+}
+
+// GetByID returns a privacy request
+// @Summary Get privacy request
+// @Tags Admin
+// @Produce json
+// @Param id path string true "Request ID"
+// @Success 200 {object} models.PrivacyRequest
+// @Router /admin/privacy-requests/{id} [get]
+func (h *PrivacyHandler) GetByID(c *fiber.Ctx) error {
+	request, err := h.service.Get(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(request)
+}
+
+// Approve anonymizes the account now instead of waiting for the grace period
+// @Summary Run an account deletion now
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Request ID"
+// @Success 200 {object} models.PrivacyRequest
+// @Router /admin/privacy-requests/{id}/approve [post]
+func (h *PrivacyHandler) Approve(c *fiber.Ctx) error {
+	return h.process(c, h.service.Approve)
+}
+
+// Reject closes a scheduled deletion without anonymizing the account (e.g. legal hold)
+// @Summary Reject an account deletion
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Request ID"
+// @Success 200 {object} models.PrivacyRequest
+// @Router /admin/privacy-requests/{id}/reject [post]
+func (h *PrivacyHandler) Reject(c *fiber.Ctx) error {
+	return h.process(c, h.service.Reject)
+}
+
+func (h *PrivacyHandler) process(c *fiber.Ctx, action func(id, adminID string, req *models.ProcessPrivacyRequest) (*models.PrivacyRequest, error)) error {
+	var req models.ProcessPrivacyRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+	if err := h.validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	adminID, _ := c.Locals("userId").(string)
+	request, err := action(c.Params("id"), adminID, &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(request)
+}
+
+func (h *PrivacyHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrPrivacyRequestNotFound), errors.Is(err, services.ErrNoPendingDeletion):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrPrivacyWrongPassword):
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrDeletionAlreadyPending), errors.Is(err, services.ErrPrivacyRequestClosed):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
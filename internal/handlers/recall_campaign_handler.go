@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type RecallCampaignHandler struct {
+	service  services.RecallCampaignService
+	validate *validator.Validate
+}
+
+func NewRecallCampaignHandler(service services.RecallCampaignService) *RecallCampaignHandler {
+	return &RecallCampaignHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// List returns the campaigns, newest first (?status=)
+func (h *RecallCampaignHandler) List(c *fiber.Ctx) error {
+	campaigns, err := h.service.WithContext(c.UserContext()).List(c.Query("status"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch recall campaigns",
+		})
+	}
+	return c.JSON(campaigns)
+}
+
+func (h *RecallCampaignHandler) Get(c *fiber.Ctx) error {
+	campaign, err := h.service.WithContext(c.UserContext()).Get(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(campaign)
+}
+
+// Create creates a DRAFT campaign
+func (h *RecallCampaignHandler) Create(c *fiber.Ctx) error {
+	var req models.RecallCampaignRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+	userID, _ := c.Locals("userId").(string)
+
+	campaign, err := h.service.WithContext(c.UserContext()).Create(&req, userID)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(campaign)
+}
+
+// Update edits a campaign that has not started yet
+func (h *RecallCampaignHandler) Update(c *fiber.Ctx) error {
+	var req models.RecallCampaignRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	campaign, err := h.service.WithContext(c.UserContext()).Update(c.Params("id"), &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(campaign)
+}
+
+// Preview counts the equipment the criteria would select, without saving the campaign
+func (h *RecallCampaignHandler) Preview(c *fiber.Ctx) error {
+	var req models.RecallCampaignRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	preview, err := h.service.WithContext(c.UserContext()).Preview(&req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(preview)
+}
+
+// Start selects the targets and starts generating the tickets, or resumes a paused campaign
+func (h *RecallCampaignHandler) Start(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+	campaign, err := h.service.WithContext(c.UserContext()).StartCampaign(c.Params("id"), userID)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(campaign)
+}
+
+func (h *RecallCampaignHandler) Pause(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+	campaign, err := h.service.WithContext(c.UserContext()).PauseCampaign(c.Params("id"), userID)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(campaign)
+}
+
+// Cancel stops the campaign; tickets already generated are kept
+func (h *RecallCampaignHandler) Cancel(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+	campaign, err := h.service.WithContext(c.UserContext()).CancelCampaign(c.Params("id"), userID)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(campaign)
+}
+
+// GetTargets returns the equipment of the campaign and its tickets (?status=&page=&size=)
+func (h *RecallCampaignHandler) GetTargets(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 0)
+	size := pageSize(c, pagination.Default, "size")
+	if page < 0 {
+		page = 0
+	}
+
+	targets, err := h.service.WithContext(c.UserContext()).GetTargets(c.Params("id"), c.Query("status"), page, size)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(targets)
+}
+
+// GetReport returns the completion report of the campaign
+func (h *RecallCampaignHandler) GetReport(c *fiber.Ctx) error {
+	report, err := h.service.WithContext(c.UserContext()).GetReport(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(report)
+}
+
+func (h *RecallCampaignHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrRecallCampaignNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrRecallCampaignNotDraft), errors.Is(err, services.ErrRecallCampaignTransition):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidRecallCampaign), errors.Is(err, services.ErrRecallCampaignEmpty),
+		errors.Is(err, services.ErrRecallCampaignTooLarge):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
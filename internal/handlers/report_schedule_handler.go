@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type ReportScheduleHandler struct {
+	service  services.ReportScheduleService
+	validate *validator.Validate
+}
+
+func NewReportScheduleHandler(service services.ReportScheduleService) *ReportScheduleHandler {
+	return &ReportScheduleHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// List returns the report schedules
+// @Summary List report schedules
+// @Tags Reports
+// @Produce json
+// @Param page query int false "Page (0-based)"
+// @Param size query int false "Page size"
+// @Success 200 {object} models.PaginatedResponse
+// @Router /reports/schedules [get]
+func (h *ReportScheduleHandler) List(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 0)
+	size := pageSize(c, pagination.Default, "size")
+	if page < 0 {
+		page = 0
+	}
+	schedules, err := h.service.List(page, size)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(schedules)
+}
+
+// Get returns a report schedule with its next run and last outcome
+// @Summary Get report schedule
+// @Tags Reports
+// @Produce json
+// @Param id path string true "Schedule ID"
+// @Success 200 {object} models.ReportSchedule
+// @Router /reports/schedules/{id} [get]
+func (h *ReportScheduleHandler) Get(c *fiber.Ctx) error {
+	schedule, err := h.service.Get(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(schedule)
+}
+
+// Create creates a report schedule
+// @Summary Create report schedule
+// @Tags Reports
+// @Accept json
+// @Produce json
+// @Param body body models.ReportScheduleRequest true "Schedule"
+// @Success 201 {object} models.ReportSchedule
+// @Router /reports/schedules [post]
+func (h *ReportScheduleHandler) Create(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+
+	var req models.ReportScheduleRequest
+	if errResponse := h.parse(c, &req); errResponse != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errResponse)
+	}
+	schedule, err := h.service.Create(&req, userID)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(schedule)
+}
+
+// Update replaces a report schedule; its next run is recalculated from now
+// @Summary Update report schedule
+// @Tags Reports
+// @Accept json
+// @Produce json
+// @Param id path string true "Schedule ID"
+// @Param body body models.ReportScheduleRequest true "Schedule"
+// @Success 200 {object} models.ReportSchedule
+// @Router /reports/schedules/{id} [put]
+func (h *ReportScheduleHandler) Update(c *fiber.Ctx) error {
+	var req models.ReportScheduleRequest
+	if errResponse := h.parse(c, &req); errResponse != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errResponse)
+	}
+	schedule, err := h.service.Update(c.Params("id"), &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(schedule)
+}
+
+// Delete deletes a report schedule with its run history
+// @Summary Delete report schedule
+// @Tags Reports
+// @Param id path string true "Schedule ID"
+// @Success 204
+// @Router /reports/schedules/{id} [delete]
+func (h *ReportScheduleHandler) Delete(c *fiber.Ctx) error {
+	if err := h.service.Delete(c.Params("id")); err != nil {
+		return h.handleError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// Run generates and e-mails the report right away
+// @Summary Run report schedule now
+// @Tags Reports
+// @Produce json
+// @Param id path string true "Schedule ID"
+// @Success 200 {object} models.ReportRun
+// @Router /reports/schedules/{id}/run [post]
+func (h *ReportScheduleHandler) Run(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+
+	run, err := h.service.RunNow(c.Params("id"), userID)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(run)
+}
+
+// ListRuns returns the run history of a report schedule, newest first
+// @Summary List report runs
+// @Tags Reports
+// @Produce json
+// @Param id path string true "Schedule ID"
+// @Param page query int false "Page (0-based)"
+// @Param size query int false "Page size"
+// @Success 200 {object} models.PaginatedResponse
+// @Router /reports/schedules/{id}/runs [get]
+func (h *ReportScheduleHandler) ListRuns(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 0)
+	size := pageSize(c, pagination.Default, "size")
+	if page < 0 {
+		page = 0
+	}
+	runs, err := h.service.ListRuns(c.Params("id"), page, size)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(runs)
+}
+
+// DownloadRun returns a short-lived download URL of the CSV of a run
+// @Summary Download report run
+// @Tags Reports
+// @Produce json
+// @Param id path string true "Schedule ID"
+// @Param runId path string true "Run ID"
+// @Success 200 {object} models.FileDownload
+// @Router /reports/schedules/{id}/runs/{runId}/download [get]
+func (h *ReportScheduleHandler) DownloadRun(c *fiber.Ctx) error {
+	download, err := h.service.RunDownloadURL(c.Params("id"), c.Params("runId"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(download)
+}
+
+// parse reads, normalizes and validates the body, returning the error response (nil when valid)
+func (h *ReportScheduleHandler) parse(c *fiber.Ctx, req *models.ReportScheduleRequest) fiber.Map {
+	if err := c.BodyParser(req); err != nil {
+		return fiber.Map{"error": "Invalid request body"}
+	}
+	req.ReportType = strings.ToUpper(req.ReportType)
+	req.Period = strings.ToUpper(req.Period)
+	if err := h.validate.Struct(req); err != nil {
+		return fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		}
+	}
+	return nil
+}
+
+func (h *ReportScheduleHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrReportScheduleNotFound),
+		errors.Is(err, services.ErrReportRunNotFound),
+		errors.Is(err, services.ErrReportRunNoFile),
+		errors.Is(err, services.ErrFileNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidCron),
+		errors.Is(err, services.ErrReportCronNeverRuns),
+		errors.Is(err, services.ErrReportTimezone):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type SandboxHandler struct {
+	service  services.SandboxService
+	validate *validator.Validate
+}
+
+func NewSandboxHandler(service services.SandboxService) *SandboxHandler {
+	return &SandboxHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// List returns the sandboxes (?status=ACTIVE|EXPIRED)
+// @Summary List sandboxes
+// @Tags Admin
+// @Produce json
+// @Success 200 {array} models.SandboxTenant
+// @Router /admin/sandboxes [get]
+func (h *SandboxHandler) List(c *fiber.Ctx) error {
+	sandboxes, err := h.service.List(strings.ToUpper(c.Query("status")))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch sandboxes",
+		})
+	}
+	return c.JSON(sandboxes)
+}
+
+// GetByID returns a sandbox and its demo records
+// @Summary Get sandbox
+// @Tags Admin
+// @Produce json
+// @Param id path string true "Sandbox ID"
+// @Success 200 {object} models.SandboxTenant
+// @Router /admin/sandboxes/{id} [get]
+func (h *SandboxHandler) GetByID(c *fiber.Ctx) error {
+	sandbox, err := h.service.Get(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(sandbox)
+}
+
+// Create provisions a sandbox seeded with demo data and returns its login, once
+// @Summary Create sandbox
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param body body models.CreateSandboxRequest true "Sandbox name and lifetime"
+// @Success 201 {object} models.SandboxCredentials
+// @Router /admin/sandboxes [post]
+func (h *SandboxHandler) Create(c *fiber.Ctx) error {
+	var req models.CreateSandboxRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if err := h.validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	adminID, _ := c.Locals("userId").(string)
+	credentials, err := h.service.Create(&req, adminID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create sandbox",
+		})
+	}
+	return c.Status(fiber.StatusCreated).JSON(credentials)
+}
+
+// Delete removes the sandbox data now and disables its login
+// @Summary Remove sandbox
+// @Tags Admin
+// @Produce json
+// @Param id path string true "Sandbox ID"
+// @Success 200 {object} models.SandboxTenant
+// @Router /admin/sandboxes/{id} [delete]
+func (h *SandboxHandler) Delete(c *fiber.Ctx) error {
+	adminID, _ := c.Locals("userId").(string)
+	sandbox, err := h.service.Delete(c.Params("id"), adminID)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(sandbox)
+}
+
+func (h *SandboxHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrSandboxNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrSandboxExpired):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
@@ -0,0 +1,70 @@
+//go:build integration
+
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/handlers"
+	"github.com/shigake/tech-iq-back/internal/middleware"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"github.com/shigake/tech-iq-back/internal/services"
+	"github.com/shigake/tech-iq-back/internal/tenant"
+	"github.com/shigake/tech-iq-back/internal/testutil"
+)
+
+func TestSandboxLoginOnlySeesSandboxClients(t *testing.T) {
+	env.Reset(t)
+	permissions := middleware.NewPermissions(
+		services.NewPermissionService(repositories.NewHierarchyRepository(env.DB), nil),
+		repositories.NewResourceNodeRepository(env.DB),
+	)
+	sandboxes := services.NewSandboxService(
+		repositories.NewSandboxRepository(env.DB),
+		services.NewActivityLogService(repositories.NewActivityLogRepository(env.DB)),
+		0,
+	)
+
+	creds, err := sandboxes.Create(&models.CreateSandboxRequest{Name: "Partner"}, testutil.AdminUserID)
+	if err != nil {
+		t.Fatalf("create sandbox: %v", err)
+	}
+	if creds.Sandbox.TenantID == "" || creds.Sandbox.TenantID == tenant.DefaultID {
+		t.Fatalf("sandbox seeded into tenant %q", creds.Sandbox.TenantID)
+	}
+	token := env.TenantToken(t, creds.Sandbox.TenantID, creds.Sandbox.UserID, creds.Email, "USER")
+
+	app := fiber.New()
+	clientHandler := handlers.NewClientHandler(repositories.NewClientRepository(env.DB), nil)
+	clients := app.Group("/clients", middleware.JWTProtected(env.Config.JWTSecret, nil, nil))
+	clients.Get("/", permissions.Require("clients.view"), clientHandler.GetAll)
+	clients.Get("/:id", permissions.Require("clients.view"), clientHandler.GetByID)
+
+	testutil.Do(t, app, http.MethodGet, "/clients/"+testutil.ClientID, nil, token).Expect(t, http.StatusNotFound)
+
+	var page models.PaginatedResponse
+	testutil.Do(t, app, http.MethodGet, "/clients?size=100", nil, token).Expect(t, http.StatusOK).JSON(t, &page)
+	listed, _ := page.Content.([]interface{})
+	if len(listed) != 3 {
+		t.Fatalf("sandbox login listed %d clients, want the 3 demo ones", len(listed))
+	}
+	for _, item := range listed {
+		if client, _ := item.(map[string]interface{}); client["id"] == testutil.ClientID {
+			t.Fatal("sandbox login listed a production client")
+		}
+	}
+
+	if _, err := sandboxes.Delete(creds.Sandbox.ID, testutil.AdminUserID); err != nil {
+		t.Fatalf("delete sandbox: %v", err)
+	}
+	var sandboxTenant models.Tenant
+	if err := env.DB.First(&sandboxTenant, "id = ?", creds.Sandbox.TenantID).Error; err != nil {
+		t.Fatalf("sandbox tenant: %v", err)
+	}
+	if sandboxTenant.Active {
+		t.Fatal("sandbox tenant still active after cleanup")
+	}
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/services"
+	"gorm.io/gorm"
+)
+
+type SatisfactionHandler struct {
+	service  services.SatisfactionService
+	validate *validator.Validate
+}
+
+func NewSatisfactionHandler(service services.SatisfactionService) *SatisfactionHandler {
+	return &SatisfactionHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// GetTicketSurvey returns the satisfaction survey of a ticket and its answer
+func (h *SatisfactionHandler) GetTicketSurvey(c *fiber.Ctx) error {
+	survey, err := h.service.GetTicketSurvey(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(survey)
+}
+
+// CreateTicketSurvey returns the survey link of a closed ticket, creating it if needed
+func (h *SatisfactionHandler) CreateTicketSurvey(c *fiber.Ctx) error {
+	survey, err := h.service.CreateTicketSurvey(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(survey)
+}
+
+// GetReport returns the satisfaction scores by technician, category and period
+// (?from=&to=, default the last 12 months; ?groupBy=month|quarter)
+func (h *SatisfactionHandler) GetReport(c *fiber.Ctx) error {
+	to := time.Now()
+	from := to.AddDate(-1, 0, 0)
+	if s := c.Query("from"); s != "" {
+		t, err := parseTime(s)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid from date"})
+		}
+		from = t
+	}
+	if s := c.Query("to"); s != "" {
+		t, err := parseTime(s)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid to date"})
+		}
+		to = t
+	}
+
+	report, err := h.service.GetReport(from, to, c.Query("groupBy"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(report)
+}
+
+// GetPublicSurvey returns the survey behind a link (public, authorized by the token)
+func (h *SatisfactionHandler) GetPublicSurvey(c *fiber.Ctx) error {
+	survey, err := h.service.GetSurvey(c.Params("token"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(survey)
+}
+
+// AnswerPublicSurvey records the client rating (1-5), the optional NPS score and comment
+func (h *SatisfactionHandler) AnswerPublicSurvey(c *fiber.Ctx) error {
+	var req models.SatisfactionAnswerRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	if err := h.service.Answer(c.Params("token"), &req); err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(fiber.Map{"message": "Thank you for your feedback"})
+}
+
+func (h *SatisfactionHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Ticket not found"})
+	case errors.Is(err, services.ErrSatisfactionSurveyNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrSatisfactionSurveyExpired):
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrSatisfactionAlreadyAnswered):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrSatisfactionTicketNotClosed):
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrSatisfactionInvalidGroupBy),
+		errors.Is(err, services.ErrSatisfactionInvalidPeriod):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
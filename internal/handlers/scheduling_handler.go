@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/services"
+	"gorm.io/gorm"
+)
+
+type SchedulingHandler struct {
+	service  services.SchedulingService
+	validate *validator.Validate
+}
+
+func NewSchedulingHandler(service services.SchedulingService) *SchedulingHandler {
+	return &SchedulingHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// CreateLink generates a public self-scheduling link for a ticket
+func (h *SchedulingHandler) CreateLink(c *fiber.Ctx) error {
+	var req models.CreateSchedulingLinkRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	userID, _ := c.Locals("userId").(string)
+
+	link, err := h.service.WithContext(c.UserContext()).CreateLink(c.Params("id"), userID, &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(link)
+}
+
+// GetTicketSlots returns available appointment slots for a ticket (office users)
+func (h *SchedulingHandler) GetTicketSlots(c *fiber.Ctx) error {
+	slots, err := h.service.WithContext(c.UserContext()).GetSlotsForTicket(c.Params("id"), parseSlotQuery(c))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(slots)
+}
+
+// ConfirmTicketSlot books an appointment slot for a ticket (office users)
+func (h *SchedulingHandler) ConfirmTicketSlot(c *fiber.Ctx) error {
+	var req models.ConfirmAppointmentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	confirmation, err := h.service.WithContext(c.UserContext()).ConfirmForTicket(c.Params("id"), &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(confirmation)
+}
+
+// GetPublicSlots returns available appointment slots for a public scheduling link
+func (h *SchedulingHandler) GetPublicSlots(c *fiber.Ctx) error {
+	slots, err := h.service.WithContext(c.UserContext()).GetSlotsByToken(c.Params("token"), parseSlotQuery(c))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(slots)
+}
+
+// ConfirmPublicSlot books an appointment slot through a public scheduling link
+func (h *SchedulingHandler) ConfirmPublicSlot(c *fiber.Ctx) error {
+	var req models.ConfirmAppointmentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	confirmation, err := h.service.WithContext(c.UserContext()).ConfirmByToken(c.Params("token"), &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(confirmation)
+}
+
+// GetSettings returns global and per-node travel-time settings
+func (h *SchedulingHandler) GetSettings(c *fiber.Ctx) error {
+	settings, err := h.service.WithContext(c.UserContext()).GetSettings()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch scheduling settings",
+		})
+	}
+	return c.JSON(settings)
+}
+
+// UpdateSettings updates global (nodeId null) or per-node travel-time settings
+func (h *SchedulingHandler) UpdateSettings(c *fiber.Ctx) error {
+	var req models.UpdateSchedulingSettingsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	settings, err := h.service.WithContext(c.UserContext()).UpdateSettings(&req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.JSON(settings)
+}
+
+// GetCalendarFeedToken returns the ICS subscription URL of a technician
+func (h *SchedulingHandler) GetCalendarFeedToken(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+
+	feed, err := h.service.WithContext(c.UserContext()).GetCalendarToken(c.Params("id"), userID, getUserRole(c))
+	if err != nil {
+		return h.handleCalendarError(c, err)
+	}
+	feed.URL = c.BaseURL() + feed.URL
+	return c.JSON(feed)
+}
+
+// RotateCalendarFeedToken invalidates the current ICS URL and issues a new one
+func (h *SchedulingHandler) RotateCalendarFeedToken(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+
+	feed, err := h.service.WithContext(c.UserContext()).RotateCalendarToken(c.Params("id"), userID, getUserRole(c))
+	if err != nil {
+		return h.handleCalendarError(c, err)
+	}
+	feed.URL = c.BaseURL() + feed.URL
+	return c.JSON(feed)
+}
+
+// GetCalendarFeed serves the technician's scheduled tickets as an ICS feed (?token=)
+func (h *SchedulingHandler) GetCalendarFeed(c *fiber.Ctx) error {
+	feed, err := h.service.WithContext(c.UserContext()).GetCalendarFeed(c.Params("id"), c.Query("token"))
+	if err != nil {
+		return h.handleCalendarError(c, err)
+	}
+
+	c.Set("Content-Type", "text/calendar; charset=utf-8")
+	c.Set("Content-Disposition", `inline; filename="schedule.ics"`)
+	c.Set("Cache-Control", "private, max-age=300")
+	return c.Send(feed)
+}
+
+// GetCalendar returns the scheduled tickets between from and to as calendar events
+// (?technicianId=&from=&to=, dates or RFC3339)
+func (h *SchedulingHandler) GetCalendar(c *fiber.Ctx) error {
+	query, err := parseCalendarQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	userID, _ := c.Locals("userId").(string)
+
+	calendar, err := h.service.WithContext(c.UserContext()).GetCalendar(query, userID, getUserRole(c))
+	if err != nil {
+		return h.handleCalendarError(c, err)
+	}
+	return c.JSON(calendar)
+}
+
+// ExportCalendar downloads the agenda of a technician between from and to as an ICS file
+func (h *SchedulingHandler) ExportCalendar(c *fiber.Ctx) error {
+	query, err := parseCalendarQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	userID, _ := c.Locals("userId").(string)
+
+	calendar, err := h.service.WithContext(c.UserContext()).ExportCalendar(query, userID, getUserRole(c))
+	if err != nil {
+		return h.handleCalendarError(c, err)
+	}
+
+	c.Set("Content-Type", "text/calendar; charset=utf-8")
+	c.Set("Content-Disposition", `attachment; filename="agenda-`+query.TechnicianID+`.ics"`)
+	return c.Send(calendar)
+}
+
+// parseCalendarQuery reads technicianId, from and to; the range defaults to the current week
+func parseCalendarQuery(c *fiber.Ctx) (*models.CalendarQuery, error) {
+	now := time.Now()
+	weekStart := time.Date(now.Year(), now.Month(), now.Day()-int(now.Weekday()), 0, 0, 0, 0, now.Location())
+	query := &models.CalendarQuery{
+		TechnicianID: c.Query("technicianId"),
+		From:         weekStart,
+		To:           weekStart.AddDate(0, 0, 7),
+		Scope:        accessScope(c),
+	}
+	if from := c.Query("from"); from != "" {
+		t, err := parseTime(from)
+		if err != nil {
+			return nil, errors.New("invalid from, expected YYYY-MM-DD or RFC3339")
+		}
+		query.From = t
+	}
+	if to := c.Query("to"); to != "" {
+		t, err := parseTime(to)
+		if err != nil {
+			return nil, errors.New("invalid to, expected YYYY-MM-DD or RFC3339")
+		}
+		query.To = t
+	}
+	return query, nil
+}
+
+func (h *SchedulingHandler) handleCalendarError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrInvalidCalendarRange),
+		errors.Is(err, services.ErrCalendarTechnicianRequired):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Technician not found"})
+	case errors.Is(err, services.ErrCalendarFeedNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrCalendarAccessDenied):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+}
+
+func (h *SchedulingHandler) handleError(c *fiber.Ctx, err error) error {
+	var conflictErr *services.ScheduleConflictError
+	if errors.As(err, &conflictErr) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":     err.Error(),
+			"conflicts": conflictErr.Conflicts,
+			"canForce":  conflictErr.CanForce,
+		})
+	}
+
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Ticket not found"})
+	case errors.Is(err, services.ErrSchedulingLinkNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrSchedulingLinkExpired):
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrSlotUnavailable):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrTicketNotSchedulable),
+		errors.Is(err, services.ErrInvalidSlotStart):
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+}
+
+// parseSlotQuery reads from (date or RFC3339), days and duration query params
+func parseSlotQuery(c *fiber.Ctx) models.SlotQuery {
+	query := models.SlotQuery{From: time.Now()}
+	if from := c.Query("from"); from != "" {
+		if t, err := parseTime(from); err == nil {
+			query.From = t
+		}
+	}
+	query.Days, _ = strconv.Atoi(c.Query("days"))
+	query.DurationMinutes, _ = strconv.Atoi(c.Query("duration"))
+	return query
+}
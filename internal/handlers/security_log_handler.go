@@ -7,6 +7,7 @@ import (
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
 	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
 	"github.com/shigake/tech-iq-back/internal/services"
 )
 
@@ -52,7 +53,7 @@ func (h *SecurityLogHandler) GetSecurityLogs(c *fiber.Ctx) error {
 
 	// Parse pagination
 	page, _ := strconv.Atoi(c.Query("page", "1"))
-	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	limit := pageSize(c, pagination.Logs, "limit")
 
 	// Parse filters
 	filter := &models.SecurityLogFilter{
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+// SettingsHandler serves the runtime settings and the remediation rules that change them
+type SettingsHandler struct {
+	settingsService    services.SettingsService
+	remediationService services.RemediationService
+	validate           *validator.Validate
+}
+
+func NewSettingsHandler(settingsService services.SettingsService, remediationService services.RemediationService) *SettingsHandler {
+	return &SettingsHandler{
+		settingsService:    settingsService,
+		remediationService: remediationService,
+		validate:           validator.New(),
+	}
+}
+
+// ListSettings lists the runtime settings with their current values
+func (h *SettingsHandler) ListSettings(c *fiber.Ctx) error {
+	settings, err := h.settingsService.List()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch settings",
+		})
+	}
+	return c.JSON(settings)
+}
+
+// UpdateSetting changes a runtime setting; it is applied right away
+func (h *SettingsHandler) UpdateSetting(c *fiber.Ctx) error {
+	var req models.UpdateSettingRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	userID, _ := c.Locals("userId").(string)
+	setting, err := h.settingsService.Update(c.Params("key"), &req, userID)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(setting)
+}
+
+// ListRules lists the remediation rules
+func (h *SettingsHandler) ListRules(c *fiber.Ctx) error {
+	rules, err := h.remediationService.ListRules()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch remediation rules",
+		})
+	}
+	return c.JSON(rules)
+}
+
+// CreateRule creates a remediation rule
+func (h *SettingsHandler) CreateRule(c *fiber.Ctx) error {
+	var req models.RemediationRuleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	userID, _ := c.Locals("userId").(string)
+	rule, err := h.remediationService.CreateRule(&req, userID)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(rule)
+}
+
+// UpdateRule updates a remediation rule
+func (h *SettingsHandler) UpdateRule(c *fiber.Ctx) error {
+	var req models.RemediationRuleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	rule, err := h.remediationService.UpdateRule(c.Params("id"), &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(rule)
+}
+
+// DeleteRule deletes a remediation rule
+func (h *SettingsHandler) DeleteRule(c *fiber.Ctx) error {
+	if err := h.remediationService.DeleteRule(c.Params("id")); err != nil {
+		return h.handleError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ListActions lists the settings changed by remediation rules, most recent first
+// (?status=&page=&size=)
+func (h *SettingsHandler) ListActions(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 0)
+	size := pageSize(c, pagination.Default, "size")
+	if page < 0 {
+		page = 0
+	}
+
+	result, err := h.remediationService.ListActions(page, size, strings.ToUpper(c.Query("status")))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch remediation actions",
+		})
+	}
+	return c.JSON(result)
+}
+
+// RevertAction restores the value a remediation action replaced
+func (h *SettingsHandler) RevertAction(c *fiber.Ctx) error {
+	var req models.RevertRemediationRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	userID, _ := c.Locals("userId").(string)
+	action, err := h.remediationService.Revert(c.Params("id"), userID, &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(action)
+}
+
+// RunRemediations runs the rules against the active alerts instead of waiting for the
+// next run
+func (h *SettingsHandler) RunRemediations(c *fiber.Ctx) error {
+	result, err := h.remediationService.Run()
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(result)
+}
+
+func (h *SettingsHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrSettingNotFound),
+		errors.Is(err, services.ErrRemediationRuleNotFound),
+		errors.Is(err, services.ErrRemediationActionNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrSettingInvalid),
+		errors.Is(err, services.ErrRemediationAlertType):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrRemediationNotApplied):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+// defaultSLARiskPercent is the share of the target past which a running ticket is at risk
+const defaultSLARiskPercent = 80
+
+type SLAHandler struct {
+	service  services.SLAService
+	validate *validator.Validate
+}
+
+func NewSLAHandler(service services.SLAService) *SLAHandler {
+	return &SLAHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// GetTicketSLA returns the SLA clock of a ticket with its waiting intervals
+func (h *SLAHandler) GetTicketSLA(c *fiber.Ctx) error {
+	sla, err := h.service.GetTicketSLA(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(sla)
+}
+
+// GetComplianceReport returns the SLA compliance of the tickets closed in the window
+// (?from=&to=, default last 30 days)
+func (h *SLAHandler) GetComplianceReport(c *fiber.Ctx) error {
+	from, to, err := parseSLAWindow(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	report, err := h.service.GetComplianceReport(from, to)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(report)
+}
+
+// GetDashboard returns the SLA compliance of the window (?from=&to=, default last 30
+// days), the running clocks (?riskPercent=, default 80) and the latest breaches
+func (h *SLAHandler) GetDashboard(c *fiber.Ctx) error {
+	from, to, err := parseSLAWindow(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	riskPercent := c.QueryInt("riskPercent", defaultSLARiskPercent)
+	if riskPercent < 1 || riskPercent > 100 {
+		riskPercent = defaultSLARiskPercent
+	}
+
+	dashboard, err := h.service.GetDashboard(from, to, riskPercent)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(dashboard)
+}
+
+// ListPolicies lists the SLA policies, inactive ones included
+func (h *SLAHandler) ListPolicies(c *fiber.Ctx) error {
+	policies, err := h.service.ListPolicies()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch SLA policies",
+		})
+	}
+	return c.JSON(policies)
+}
+
+// CreatePolicy creates an SLA policy
+func (h *SLAHandler) CreatePolicy(c *fiber.Ctx) error {
+	var req models.SLAPolicyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	userID, _ := c.Locals("userId").(string)
+	policy, err := h.service.CreatePolicy(&req, userID)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(policy)
+}
+
+// UpdatePolicy updates an SLA policy; open tickets follow the new targets right away
+func (h *SLAHandler) UpdatePolicy(c *fiber.Ctx) error {
+	var req models.SLAPolicyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	policy, err := h.service.UpdatePolicy(c.Params("id"), &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(policy)
+}
+
+// DeletePolicy deletes an SLA policy; the breaches already flagged are kept
+func (h *SLAHandler) DeletePolicy(c *fiber.Ctx) error {
+	if err := h.service.DeletePolicy(c.Params("id")); err != nil {
+		return h.handleError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// parseSLAWindow reads ?from=&to=, by default the last 30 days
+func parseSLAWindow(c *fiber.Ctx) (time.Time, time.Time, error) {
+	to := time.Now()
+	from := to.AddDate(0, 0, -30)
+	if s := c.Query("from"); s != "" {
+		t, err := parseTime(s)
+		if err != nil {
+			return from, to, errors.New("Invalid from date")
+		}
+		from = t
+	}
+	if s := c.Query("to"); s != "" {
+		t, err := parseTime(s)
+		if err != nil {
+			return from, to, errors.New("Invalid to date")
+		}
+		to = t
+	}
+	if !from.Before(to) {
+		return from, to, errors.New("from must be before to")
+	}
+	return from, to, nil
+}
+
+func (h *SLAHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrSLATicketNotFound),
+		errors.Is(err, services.ErrSLAPolicyNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrSLAPolicyTargets),
+		errors.Is(err, services.ErrSLAPolicyCategory):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrSLAPolicyConflict):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type StatusHandler struct {
+	service  services.StatusService
+	validate *validator.Validate
+}
+
+func NewStatusHandler(service services.StatusService) *StatusHandler {
+	return &StatusHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// GetStatus returns the public status page: dependencies, job backlog and incidents.
+// Responds 503 during an outage so uptime monitors can rely on the status code.
+func (h *StatusHandler) GetStatus(c *fiber.Ctx) error {
+	status := h.service.GetStatus()
+	c.Set("Cache-Control", "public, max-age=15")
+	if status.Status == models.SystemStatusOutage {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(status)
+	}
+	return c.JSON(status)
+}
+
+// Live is the liveness probe: the process is up and serving, whatever its dependencies
+func (h *StatusHandler) Live(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"status": "ok"})
+}
+
+// Ready is the readiness probe: 503 while the database is unreachable or the instance is
+// shutting down, so the load balancer stops sending it traffic
+func (h *StatusHandler) Ready(c *fiber.Ctx) error {
+	readiness := h.service.Ready()
+	c.Set("Cache-Control", "no-store")
+	if !readiness.Ready {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(readiness)
+	}
+	return c.JSON(readiness)
+}
+
+// ListIncidents returns the latest incident notes, resolved or not
+func (h *StatusHandler) ListIncidents(c *fiber.Ctx) error {
+	incidents, err := h.service.ListIncidents()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch incidents",
+		})
+	}
+	return c.JSON(incidents)
+}
+
+// CreateIncident publishes an incident note on the status page
+func (h *StatusHandler) CreateIncident(c *fiber.Ctx) error {
+	var req models.CreateStatusIncidentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	userID, _ := c.Locals("userId").(string)
+
+	incident, err := h.service.CreateIncident(userID, &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(incident)
+}
+
+// UpdateIncident updates an incident note, e.g. to mark it resolved
+func (h *StatusHandler) UpdateIncident(c *fiber.Ctx) error {
+	var req models.UpdateStatusIncidentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	incident, err := h.service.UpdateIncident(c.Params("id"), &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(incident)
+}
+
+// DeleteIncident removes an incident note
+func (h *StatusHandler) DeleteIncident(c *fiber.Ctx) error {
+	if err := h.service.DeleteIncident(c.Params("id")); err != nil {
+		return h.handleError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *StatusHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrStatusIncidentNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
@@ -1,20 +1,25 @@
 package handlers
 
 import (
+	"errors"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/shigake/tech-iq-back/internal/middleware"
 	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
 	"github.com/shigake/tech-iq-back/internal/services"
 )
 
 type StockHandler struct {
-	service services.StockService
+	service     services.StockService
+	permissions *middleware.Permissions
 }
 
-func NewStockHandler(service services.StockService) *StockHandler {
-	return &StockHandler{service: service}
+func NewStockHandler(service services.StockService, permissions *middleware.Permissions) *StockHandler {
+	return &StockHandler{service: service, permissions: permissions}
 }
 
 // =============== Items ===============
@@ -39,7 +44,7 @@ func (h *StockHandler) CreateItem(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "SKU and Name are required"})
 	}
 
-	item, err := h.service.CreateItem(req)
+	item, err := h.service.WithContext(c.UserContext()).CreateItem(req)
 	if err != nil {
 		if err == services.ErrItemSKUExists {
 			return c.Status(fiber.StatusConflict).JSON(ErrorResponse{Error: err.Error()})
@@ -60,7 +65,7 @@ func (h *StockHandler) CreateItem(c *fiber.Ctx) error {
 // @Router /stock/items/{id} [get]
 func (h *StockHandler) GetItem(c *fiber.Ctx) error {
 	id := c.Params("id")
-	item, err := h.service.GetItem(id)
+	item, err := h.service.WithContext(c.UserContext()).GetItem(id)
 	if err != nil {
 		if err == services.ErrItemNotFound {
 			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{Error: err.Error()})
@@ -89,7 +94,7 @@ func (h *StockHandler) UpdateItem(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "Invalid request body"})
 	}
 
-	item, err := h.service.UpdateItem(id, req)
+	item, err := h.service.WithContext(c.UserContext()).UpdateItem(id, req)
 	if err != nil {
 		if err == services.ErrItemNotFound {
 			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{Error: err.Error()})
@@ -112,7 +117,7 @@ func (h *StockHandler) UpdateItem(c *fiber.Ctx) error {
 // @Router /stock/items/{id} [delete]
 func (h *StockHandler) DeleteItem(c *fiber.Ctx) error {
 	id := c.Params("id")
-	err := h.service.DeleteItem(id)
+	err := h.service.WithContext(c.UserContext()).DeleteItem(id)
 	if err != nil {
 		if err == services.ErrItemNotFound {
 			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{Error: err.Error()})
@@ -128,9 +133,9 @@ func (h *StockHandler) DeleteItem(c *fiber.Ctx) error {
 // @Produce json
 // @Param search query string false "Search in name, SKU, description"
 // @Param category query string false "Filter by category"
-// @Param is_active query bool false "Filter by active status"
+// @Param isActive query bool false "Filter by active status"
 // @Param page query int false "Page number"
-// @Param page_size query int false "Page size"
+// @Param pageSize query int false "Page size"
 // @Success 200 {object} models.PaginatedStockItems
 // @Router /stock/items [get]
 func (h *StockHandler) ListItems(c *fiber.Ctx) error {
@@ -138,15 +143,15 @@ func (h *StockHandler) ListItems(c *fiber.Ctx) error {
 		Search:   c.Query("search"),
 		Category: c.Query("category"),
 		Page:     getIntQuery(c, "page", 1),
-		PageSize: getIntQuery(c, "page_size", 20),
+		PageSize: pageSize(c, pagination.Stock, "pageSize"),
 	}
 
-	if isActiveStr := c.Query("is_active"); isActiveStr != "" {
+	if isActiveStr := c.Query("isActive"); isActiveStr != "" {
 		isActive := isActiveStr == "true"
 		filter.IsActive = &isActive
 	}
 
-	result, err := h.service.ListItems(filter)
+	result, err := h.service.WithContext(c.UserContext()).ListItems(filter)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{Error: err.Error()})
 	}
@@ -175,7 +180,7 @@ func (h *StockHandler) CreateLocation(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "ScopeID, Type and Name are required"})
 	}
 
-	location, err := h.service.CreateLocation(req)
+	location, err := h.service.WithContext(c.UserContext()).CreateLocation(req)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{Error: err.Error()})
 	}
@@ -193,7 +198,7 @@ func (h *StockHandler) CreateLocation(c *fiber.Ctx) error {
 // @Router /stock/locations/{id} [get]
 func (h *StockHandler) GetLocation(c *fiber.Ctx) error {
 	id := c.Params("id")
-	location, err := h.service.GetLocation(id)
+	location, err := h.service.WithContext(c.UserContext()).GetLocation(id)
 	if err != nil {
 		if err == services.ErrLocationNotFound {
 			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{Error: err.Error()})
@@ -222,7 +227,7 @@ func (h *StockHandler) UpdateLocation(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "Invalid request body"})
 	}
 
-	location, err := h.service.UpdateLocation(id, req)
+	location, err := h.service.WithContext(c.UserContext()).UpdateLocation(id, req)
 	if err != nil {
 		if err == services.ErrLocationNotFound {
 			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{Error: err.Error()})
@@ -242,7 +247,7 @@ func (h *StockHandler) UpdateLocation(c *fiber.Ctx) error {
 // @Router /stock/locations/{id} [delete]
 func (h *StockHandler) DeleteLocation(c *fiber.Ctx) error {
 	id := c.Params("id")
-	err := h.service.DeleteLocation(id)
+	err := h.service.WithContext(c.UserContext()).DeleteLocation(id)
 	if err != nil {
 		if err == services.ErrLocationNotFound {
 			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{Error: err.Error()})
@@ -256,29 +261,31 @@ func (h *StockHandler) DeleteLocation(c *fiber.Ctx) error {
 // @Summary List stock locations with filters
 // @Tags Stock Locations
 // @Produce json
-// @Param scope_id query string false "Filter by scope ID"
+// @Param scopeId query string false "Filter by scope ID"
 // @Param type query string false "Filter by location type"
+// @Param technicianId query string false "Filter by technician carrying the stock"
 // @Param search query string false "Search in name"
-// @Param is_active query bool false "Filter by active status"
+// @Param isActive query bool false "Filter by active status"
 // @Param page query int false "Page number"
-// @Param page_size query int false "Page size"
+// @Param pageSize query int false "Page size"
 // @Success 200 {object} models.PaginatedStockLocations
 // @Router /stock/locations [get]
 func (h *StockHandler) ListLocations(c *fiber.Ctx) error {
 	filter := models.StockLocationFilter{
-		ScopeID:  c.Query("scope_id"),
-		Type:     c.Query("type"),
-		Search:   c.Query("search"),
-		Page:     getIntQuery(c, "page", 1),
-		PageSize: getIntQuery(c, "page_size", 20),
+		ScopeID:      c.Query("scopeId"),
+		Type:         c.Query("type"),
+		TechnicianID: c.Query("technicianId"),
+		Search:       c.Query("search"),
+		Page:         getIntQuery(c, "page", 1),
+		PageSize:     pageSize(c, pagination.Stock, "pageSize"),
 	}
 
-	if isActiveStr := c.Query("is_active"); isActiveStr != "" {
+	if isActiveStr := c.Query("isActive"); isActiveStr != "" {
 		isActive := isActiveStr == "true"
 		filter.IsActive = &isActive
 	}
 
-	result, err := h.service.ListLocations(filter)
+	result, err := h.service.WithContext(c.UserContext()).ListLocations(filter)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{Error: err.Error()})
 	}
@@ -290,11 +297,14 @@ func (h *StockHandler) ListLocations(c *fiber.Ctx) error {
 
 // CreateMovement godoc
 // @Summary Create a stock movement (entry, exit, transfer)
+// @Description Consuming a part on a ticket whose equipment is not listed as compatible is recorded with warnings.
+// @Description Transfers above the approval thresholds are created as PENDING (202) and only move stock once approved.
 // @Tags Stock Movements
 // @Accept json
 // @Produce json
 // @Param request body models.CreateStockMovementRequest true "Movement data"
 // @Success 201 {object} models.StockMovement
+// @Success 202 {object} models.StockMovement
 // @Failure 400 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
 // @Failure 422 {object} ErrorResponse
@@ -312,20 +322,26 @@ func (h *StockHandler) CreateMovement(c *fiber.Ctx) error {
 	// Get user ID from JWT context
 	userID := c.Locals("userId").(string)
 
-	movement, err := h.service.CreateMovement(req, userID)
+	movement, err := h.service.WithContext(c.UserContext()).CreateMovement(req, userID)
 	if err != nil {
 		switch err {
-		case services.ErrItemNotFound, services.ErrLocationNotFound:
+		case services.ErrItemNotFound, services.ErrLocationNotFound, services.ErrSupplierNotFound:
 			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{Error: err.Error()})
-		case services.ErrInsufficientStock, services.ErrInvalidMovementType,
+		case services.ErrInsufficientStock, services.ErrStockReserved, services.ErrInvalidMovementType,
 			services.ErrMissingFromLocation, services.ErrMissingToLocation,
-			services.ErrTransferSameLocation, services.ErrNegativeQuantity:
+			services.ErrTransferSameLocation, services.ErrNegativeQuantity,
+			services.ErrSerialsRequired, services.ErrSerialDuplicate, services.ErrSerialNotTracked,
+			services.ErrSerialNotFound, services.ErrSerialInStock, services.ErrSerialNotAtLocation,
+			services.ErrSupplierInactive:
 			return c.Status(fiber.StatusUnprocessableEntity).JSON(ErrorResponse{Error: err.Error()})
 		default:
 			return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{Error: err.Error()})
 		}
 	}
 
+	if movement.Status == models.MovementStatusPending {
+		return c.Status(fiber.StatusAccepted).JSON(movement)
+	}
 	return c.Status(fiber.StatusCreated).JSON(movement)
 }
 
@@ -339,7 +355,7 @@ func (h *StockHandler) CreateMovement(c *fiber.Ctx) error {
 // @Router /stock/movements/{id} [get]
 func (h *StockHandler) GetMovement(c *fiber.Ctx) error {
 	id := c.Params("id")
-	movement, err := h.service.GetMovement(id)
+	movement, err := h.service.WithContext(c.UserContext()).GetMovement(id)
 	if err != nil {
 		if err == services.ErrMovementNotFound {
 			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{Error: err.Error()})
@@ -353,41 +369,54 @@ func (h *StockHandler) GetMovement(c *fiber.Ctx) error {
 // @Summary List stock movements with filters
 // @Tags Stock Movements
 // @Produce json
-// @Param scope_id query string false "Filter by scope ID"
+// @Param scopeId query string false "Filter by scope ID"
 // @Param type query string false "Filter by movement type"
-// @Param item_id query string false "Filter by item ID"
-// @Param location_id query string false "Filter by location (from or to)"
-// @Param ticket_id query string false "Filter by ticket ID"
-// @Param start_date query string false "Filter by start date (RFC3339)"
-// @Param end_date query string false "Filter by end date (RFC3339)"
+// @Param status query string false "APPROVED, PENDING or REJECTED"
+// @Param itemId query string false "Filter by item ID"
+// @Param locationId query string false "Filter by location (from or to)"
+// @Param ticketId query string false "Filter by ticket ID"
+// @Param startDate query string false "Filter by start date (RFC3339)"
+// @Param endDate query string false "Filter by end date (RFC3339)"
 // @Param page query int false "Page number"
-// @Param page_size query int false "Page size"
+// @Param pageSize query int false "Page size"
+// @Param cursor query string false "Keyset cursor (empty for the first page) instead of page"
 // @Success 200 {object} models.PaginatedStockMovements
 // @Router /stock/movements [get]
 func (h *StockHandler) ListMovements(c *fiber.Ctx) error {
 	filter := models.StockMovementFilter{
-		ScopeID:    c.Query("scope_id"),
+		ScopeID:    c.Query("scopeId"),
 		Type:       c.Query("type"),
-		ItemID:     c.Query("item_id"),
-		LocationID: c.Query("location_id"),
-		TicketID:   c.Query("ticket_id"),
+		Status:     strings.ToUpper(c.Query("status")),
+		ItemID:     c.Query("itemId"),
+		LocationID: c.Query("locationId"),
+		TicketID:   c.Query("ticketId"),
+		SupplierID: c.Query("supplierId"),
 		Page:       getIntQuery(c, "page", 1),
-		PageSize:   getIntQuery(c, "page_size", 20),
+		PageSize:   pageSize(c, pagination.Stock, "pageSize"),
 	}
 
 	// Parse dates if provided
-	if startDate := c.Query("start_date"); startDate != "" {
+	if startDate := c.Query("startDate"); startDate != "" {
 		if t, err := parseTime(startDate); err == nil {
 			filter.StartDate = &t
 		}
 	}
-	if endDate := c.Query("end_date"); endDate != "" {
+	if endDate := c.Query("endDate"); endDate != "" {
 		if t, err := parseTime(endDate); err == nil {
 			filter.EndDate = &t
 		}
 	}
 
-	result, err := h.service.ListMovements(filter)
+	cursor, err := queryCursor(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: err.Error()})
+	}
+	filter.Cursor = cursor
+
+	result, err := h.service.WithContext(c.UserContext()).ListMovements(filter)
+	if errors.Is(err, pagination.ErrInvalidCursor) {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: err.Error()})
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{Error: err.Error()})
 	}
@@ -395,33 +424,108 @@ func (h *StockHandler) ListMovements(c *fiber.Ctx) error {
 	return c.JSON(result)
 }
 
+// ApproveMovement godoc
+// @Summary Approve a pending transfer and apply it to the balances
+// @Tags Stock Movements
+// @Accept json
+// @Produce json
+// @Param id path string true "Movement ID"
+// @Param request body models.MovementDecisionRequest false "Decision notes"
+// @Success 200 {object} models.StockMovement
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 422 {object} ErrorResponse
+// @Router /stock/movements/{id}/approve [post]
+func (h *StockHandler) ApproveMovement(c *fiber.Ctx) error {
+	var req models.MovementDecisionRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "Invalid request body"})
+		}
+	}
+
+	userID := c.Locals("userId").(string)
+
+	movement, err := h.service.WithContext(c.UserContext()).ApproveMovement(c.Params("id"), userID, req)
+	if err != nil {
+		return movementDecisionError(c, err)
+	}
+	return c.JSON(movement)
+}
+
+// RejectMovement godoc
+// @Summary Reject a pending transfer; balances are left untouched
+// @Tags Stock Movements
+// @Accept json
+// @Produce json
+// @Param id path string true "Movement ID"
+// @Param request body models.MovementDecisionRequest false "Decision notes"
+// @Success 200 {object} models.StockMovement
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /stock/movements/{id}/reject [post]
+func (h *StockHandler) RejectMovement(c *fiber.Ctx) error {
+	var req models.MovementDecisionRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "Invalid request body"})
+		}
+	}
+
+	userID := c.Locals("userId").(string)
+
+	movement, err := h.service.WithContext(c.UserContext()).RejectMovement(c.Params("id"), userID, req)
+	if err != nil {
+		return movementDecisionError(c, err)
+	}
+	return c.JSON(movement)
+}
+
+func movementDecisionError(c *fiber.Ctx, err error) error {
+	switch err {
+	case services.ErrMovementNotFound:
+		return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{Error: err.Error()})
+	case services.ErrMovementNotPending:
+		return c.Status(fiber.StatusConflict).JSON(ErrorResponse{Error: err.Error()})
+	case services.ErrSelfApproval:
+		return c.Status(fiber.StatusForbidden).JSON(ErrorResponse{Error: err.Error()})
+	case services.ErrInsufficientStock, services.ErrStockReserved,
+		services.ErrSerialNotFound, services.ErrSerialNotAtLocation:
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(ErrorResponse{Error: err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{Error: err.Error()})
+	}
+}
+
 // =============== Balances ===============
 
 // ListBalances godoc
 // @Summary List stock balances with filters
 // @Tags Stock Balances
 // @Produce json
-// @Param scope_id query string false "Filter by scope ID"
-// @Param item_id query string false "Filter by item ID"
-// @Param location_id query string false "Filter by location ID"
+// @Param scopeId query string false "Filter by scope ID"
+// @Param itemId query string false "Filter by item ID"
+// @Param locationId query string false "Filter by location ID"
 // @Param search query string false "Search in item name, SKU, location name"
-// @Param low_stock query bool false "Filter low stock items"
+// @Param lowStock query bool false "Filter low stock items"
 // @Param page query int false "Page number"
-// @Param page_size query int false "Page size"
+// @Param pageSize query int false "Page size"
 // @Success 200 {object} models.PaginatedStockBalances
 // @Router /stock/balances [get]
 func (h *StockHandler) ListBalances(c *fiber.Ctx) error {
 	filter := models.StockBalanceFilter{
-		ScopeID:    c.Query("scope_id"),
-		ItemID:     c.Query("item_id"),
-		LocationID: c.Query("location_id"),
+		ScopeID:    c.Query("scopeId"),
+		ItemID:     c.Query("itemId"),
+		LocationID: c.Query("locationId"),
 		Search:     c.Query("search"),
-		LowStock:   c.Query("low_stock") == "true",
+		LowStock:   c.Query("lowStock") == "true",
 		Page:       getIntQuery(c, "page", 1),
-		PageSize:   getIntQuery(c, "page_size", 20),
+		PageSize:   pageSize(c, pagination.Stock, "pageSize"),
 	}
 
-	result, err := h.service.ListBalances(filter)
+	result, err := h.service.WithContext(c.UserContext()).ListBalances(filter)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{Error: err.Error()})
 	}
@@ -433,21 +537,21 @@ func (h *StockHandler) ListBalances(c *fiber.Ctx) error {
 // @Summary Get stock balance for a specific item and location
 // @Tags Stock Balances
 // @Produce json
-// @Param item_id query string true "Item ID"
-// @Param location_id query string true "Location ID"
+// @Param itemId query string true "Item ID"
+// @Param locationId query string true "Location ID"
 // @Success 200 {object} models.StockBalance
 // @Failure 400 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
 // @Router /stock/balances/single [get]
 func (h *StockHandler) GetBalance(c *fiber.Ctx) error {
-	itemID := c.Query("item_id")
-	locationID := c.Query("location_id")
+	itemID := c.Query("itemId")
+	locationID := c.Query("locationId")
 
 	if itemID == "" || locationID == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "item_id and location_id are required"})
 	}
 
-	balance, err := h.service.GetBalance(itemID, locationID)
+	balance, err := h.service.WithContext(c.UserContext()).GetBalance(itemID, locationID)
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{Error: "Balance not found"})
 	}
@@ -462,9 +566,11 @@ func (h *StockHandler) GetBalance(c *fiber.Ctx) error {
 // @Tags Stock Balances
 // @Accept json
 // @Produce json
-// @Param request body models.InventoryCountRequest true "Inventory count data"
+// @Param request body models.InventoryCountRequest true "Inventory count data (taskId completes a cycle-count task)"
 // @Success 200 {object} models.InventoryCountResponse
 // @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
 // @Failure 422 {object} ErrorResponse
 // @Router /stock/inventory-count [post]
 func (h *StockHandler) PerformInventoryCount(c *fiber.Ctx) error {
@@ -483,51 +589,1066 @@ func (h *StockHandler) PerformInventoryCount(c *fiber.Ctx) error {
 
 	userID := c.Locals("userId").(string)
 
-	result, err := h.service.PerformInventoryCount(req, userID)
+	result, err := h.service.WithContext(c.UserContext()).PerformInventoryCount(req, userID)
 	if err != nil {
-		if err == services.ErrInsufficientStock {
+		switch err {
+		case services.ErrInsufficientStock, services.ErrSerialsRequired, services.ErrSerialDuplicate,
+			services.ErrSerialNotTracked, services.ErrSerialNotFound, services.ErrSerialInStock,
+			services.ErrSerialNotAtLocation:
 			return c.Status(fiber.StatusUnprocessableEntity).JSON(ErrorResponse{Error: err.Error()})
+		case services.ErrCountTaskNotFound:
+			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{Error: err.Error()})
+		case services.ErrCountTaskMismatch:
+			return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: err.Error()})
+		case services.ErrCountTaskNotPending:
+			return c.Status(fiber.StatusConflict).JSON(ErrorResponse{Error: err.Error()})
+		default:
+			return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{Error: err.Error()})
+		}
+	}
+
+	return c.JSON(result)
+}
+
+// =============== Compatibility ===============
+
+// ListCompatibilities godoc
+// @Summary List the equipment models a stock item fits
+// @Tags Stock Compatibility
+// @Produce json
+// @Param id path string true "Item ID"
+// @Success 200 {array} models.StockItemCompatibility
+// @Failure 404 {object} ErrorResponse
+// @Router /stock/items/{id}/compatibility [get]
+func (h *StockHandler) ListCompatibilities(c *fiber.Ctx) error {
+	compatibilities, err := h.service.WithContext(c.UserContext()).ListCompatibilities(c.Params("id"))
+	if err != nil {
+		if err == services.ErrItemNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{Error: err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{Error: err.Error()})
+	}
+	return c.JSON(compatibilities)
+}
+
+// AddCompatibility godoc
+// @Summary Declare that a stock item fits an equipment brand/model (empty model = whole brand)
+// @Tags Stock Compatibility
+// @Accept json
+// @Produce json
+// @Param id path string true "Item ID"
+// @Param request body models.CreateStockCompatibilityRequest true "Equipment"
+// @Success 201 {object} models.StockItemCompatibility
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /stock/items/{id}/compatibility [post]
+func (h *StockHandler) AddCompatibility(c *fiber.Ctx) error {
+	var req models.CreateStockCompatibilityRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "Invalid request body"})
+	}
+
+	if strings.TrimSpace(req.Brand) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "Brand is required"})
+	}
+
+	userID := c.Locals("userId").(string)
+
+	compatibility, err := h.service.WithContext(c.UserContext()).AddCompatibility(c.Params("id"), req, userID)
+	if err != nil {
+		switch err {
+		case services.ErrItemNotFound:
+			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{Error: err.Error()})
+		case services.ErrCompatibilityExists:
+			return c.Status(fiber.StatusConflict).JSON(ErrorResponse{Error: err.Error()})
+		default:
+			return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{Error: err.Error()})
+		}
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(compatibility)
+}
+
+// RemoveCompatibility godoc
+// @Summary Remove a compatibility entry from a stock item
+// @Tags Stock Compatibility
+// @Param id path string true "Item ID"
+// @Param compatibilityId path string true "Compatibility ID"
+// @Success 204
+// @Failure 404 {object} ErrorResponse
+// @Router /stock/items/{id}/compatibility/{compatibilityId} [delete]
+func (h *StockHandler) RemoveCompatibility(c *fiber.Ctx) error {
+	if err := h.service.WithContext(c.UserContext()).RemoveCompatibility(c.Params("id"), c.Params("compatibilityId")); err != nil {
+		if err == services.ErrCompatibilityNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{Error: err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{Error: err.Error()})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// FindCompatibleParts godoc
+// @Summary Parts that fit an equipment, for part selection in the field
+// @Tags Stock Compatibility
+// @Produce json
+// @Param brand query string false "Equipment brand (required without ticket_id)"
+// @Param model query string false "Equipment model"
+// @Param ticketId query string false "Resolve brand/model from the ticket equipment"
+// @Param locationId query string false "Include the quantity on hand at this location"
+// @Param search query string false "Search by name or SKU"
+// @Param includeUniversal query bool false "Include items without compatibility entries"
+// @Param inStock query bool false "Only items with quantity at location_id"
+// @Success 200 {object} models.CompatiblePartsResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /stock/compatible-parts [get]
+func (h *StockHandler) FindCompatibleParts(c *fiber.Ctx) error {
+	filter := models.CompatiblePartsFilter{
+		Brand:            c.Query("brand"),
+		Model:            c.Query("model"),
+		TicketID:         c.Query("ticketId"),
+		LocationID:       c.Query("locationId"),
+		Search:           c.Query("search"),
+		IncludeUniversal: c.Query("includeUniversal") == "true",
+		OnlyInStock:      c.Query("inStock") == "true",
+	}
+
+	result, err := h.service.WithContext(c.UserContext()).FindCompatibleParts(filter)
+	if err != nil {
+		switch err {
+		case services.ErrStockTicketNotFound, services.ErrLocationNotFound:
+			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{Error: err.Error()})
+		case services.ErrEquipmentRequired, services.ErrTicketWithoutEquipment:
+			return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: err.Error()})
+		default:
+			return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{Error: err.Error()})
+		}
+	}
+
+	return c.JSON(result)
+}
+
+// =============== Levels ===============
+
+// ListLevels godoc
+// @Summary List per-location min/max overrides
+// @Tags Stock Levels
+// @Produce json
+// @Param locationId query string false "Filter by location ID"
+// @Param itemId query string false "Filter by item ID"
+// @Success 200 {array} models.StockLevel
+// @Router /stock/levels [get]
+func (h *StockHandler) ListLevels(c *fiber.Ctx) error {
+	filter := models.StockLevelFilter{
+		LocationID: c.Query("locationId"),
+		ItemID:     c.Query("itemId"),
+	}
+
+	levels, err := h.service.WithContext(c.UserContext()).ListLevels(filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{Error: err.Error()})
+	}
+	return c.JSON(levels)
+}
+
+// SetLevel godoc
+// @Summary Set the min/max of an item at a location (max 0 = replenish up to min)
+// @Tags Stock Levels
+// @Accept json
+// @Produce json
+// @Param request body models.SetStockLevelRequest true "Levels"
+// @Success 200 {object} models.StockLevel
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /stock/levels [put]
+func (h *StockHandler) SetLevel(c *fiber.Ctx) error {
+	var req models.SetStockLevelRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "Invalid request body"})
+	}
+
+	if req.LocationID == "" || req.ItemID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "Location ID and item ID are required"})
+	}
+
+	userID := c.Locals("userId").(string)
+
+	level, err := h.service.WithContext(c.UserContext()).SetLevel(req, userID)
+	if err != nil {
+		switch err {
+		case services.ErrItemNotFound, services.ErrLocationNotFound:
+			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{Error: err.Error()})
+		case services.ErrInvalidStockLevels:
+			return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: err.Error()})
+		default:
+			return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{Error: err.Error()})
+		}
+	}
+
+	return c.JSON(level)
+}
+
+// DeleteLevel godoc
+// @Summary Remove a per-location override (the item MinQty applies again)
+// @Tags Stock Levels
+// @Param id path string true "Level ID"
+// @Success 204
+// @Failure 404 {object} ErrorResponse
+// @Router /stock/levels/{id} [delete]
+func (h *StockHandler) DeleteLevel(c *fiber.Ctx) error {
+	if err := h.service.WithContext(c.UserContext()).DeleteLevel(c.Params("id")); err != nil {
+		if err == services.ErrStockLevelNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{Error: err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{Error: err.Error()})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// GetReplenishmentSuggestions godoc
+// @Summary Transfers and purchases needed to bring each location back to its levels
+// @Tags Stock Levels
+// @Produce json
+// @Param scopeId query string false "Filter by scope ID"
+// @Param locationId query string false "Only suggestions into this location"
+// @Success 200 {array} models.ReplenishmentSuggestion
+// @Router /stock/replenishment-suggestions [get]
+func (h *StockHandler) GetReplenishmentSuggestions(c *fiber.Ctx) error {
+	filter := models.ReplenishmentFilter{
+		ScopeID:    c.Query("scopeId"),
+		LocationID: c.Query("locationId"),
+	}
+
+	suggestions, err := h.service.WithContext(c.UserContext()).GetReplenishmentSuggestions(filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{Error: err.Error()})
+	}
+	return c.JSON(suggestions)
+}
+
+// =============== Cycle Counting ===============
+
+// GenerateCycleCount godoc
+// @Summary Create count tasks for the items of a location due for counting (by ABC class and last count)
+// @Tags Stock Cycle Counts
+// @Accept json
+// @Produce json
+// @Param request body models.GenerateCycleCountRequest true "Location and batch size"
+// @Success 201 {array} models.StockCountTask
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /stock/cycle-counts/generate [post]
+func (h *StockHandler) GenerateCycleCount(c *fiber.Ctx) error {
+	var req models.GenerateCycleCountRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "Invalid request body"})
+	}
+
+	if req.LocationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "Location ID is required"})
+	}
+
+	if req.MaxItems < 0 || req.MaxItems > 100 {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "maxItems must be between 0 and 100"})
+	}
+
+	userID := c.Locals("userId").(string)
+
+	tasks, err := h.service.WithContext(c.UserContext()).GenerateCycleCount(req, userID)
+	if err != nil {
+		if err == services.ErrLocationNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{Error: err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{Error: err.Error()})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(tasks)
+}
+
+// ListCountTasks godoc
+// @Summary List cycle-count tasks, earliest due first
+// @Tags Stock Cycle Counts
+// @Produce json
+// @Param scopeId query string false "Filter by scope ID"
+// @Param locationId query string false "Filter by location ID"
+// @Param assignedTo query string false "Filter by assigned user ID (mine = current user)"
+// @Param status query string false "PENDING, COUNTED or CANCELLED"
+// @Param page query int false "Page number" default(1)
+// @Param pageSize query int false "Page size" default(20)
+// @Success 200 {object} models.PaginatedStockCountTasks
+// @Router /stock/cycle-counts/tasks [get]
+func (h *StockHandler) ListCountTasks(c *fiber.Ctx) error {
+	filter := models.StockCountTaskFilter{
+		ScopeID:    c.Query("scopeId"),
+		LocationID: c.Query("locationId"),
+		AssignedTo: c.Query("assignedTo"),
+		Status:     strings.ToUpper(c.Query("status")),
+		Page:       getIntQuery(c, "page", 1),
+		PageSize:   pageSize(c, pagination.Stock, "pageSize"),
+	}
+
+	if filter.AssignedTo == "mine" {
+		filter.AssignedTo = c.Locals("userId").(string)
+	}
+
+	result, err := h.service.WithContext(c.UserContext()).ListCountTasks(filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{Error: err.Error()})
+	}
+
+	return c.JSON(result)
+}
+
+// AssignCountTask godoc
+// @Summary Assign a pending count task to a user
+// @Tags Stock Cycle Counts
+// @Accept json
+// @Produce json
+// @Param id path string true "Task ID"
+// @Param request body models.AssignCountTaskRequest true "User"
+// @Success 200 {object} models.StockCountTask
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /stock/cycle-counts/tasks/{id}/assign [put]
+func (h *StockHandler) AssignCountTask(c *fiber.Ctx) error {
+	var req models.AssignCountTaskRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "Invalid request body"})
+	}
+
+	if req.AssignedTo == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "assignedTo is required"})
+	}
+
+	task, err := h.service.WithContext(c.UserContext()).AssignCountTask(c.Params("id"), req)
+	if err != nil {
+		return countTaskError(c, err)
+	}
+
+	return c.JSON(task)
+}
+
+// CancelCountTask godoc
+// @Summary Cancel a pending count task
+// @Tags Stock Cycle Counts
+// @Produce json
+// @Param id path string true "Task ID"
+// @Success 200 {object} models.StockCountTask
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /stock/cycle-counts/tasks/{id}/cancel [post]
+func (h *StockHandler) CancelCountTask(c *fiber.Ctx) error {
+	task, err := h.service.WithContext(c.UserContext()).CancelCountTask(c.Params("id"))
+	if err != nil {
+		return countTaskError(c, err)
+	}
+
+	return c.JSON(task)
+}
+
+// GetCountAccuracy godoc
+// @Summary Count accuracy over time, per month and per location
+// @Tags Stock Cycle Counts
+// @Produce json
+// @Param scopeId query string false "Filter by scope ID"
+// @Param locationId query string false "Filter by location ID"
+// @Param from query string false "Start date (default 12 months ago)"
+// @Param to query string false "End date (default now)"
+// @Success 200 {object} models.CountAccuracyReport
+// @Failure 400 {object} ErrorResponse
+// @Router /stock/cycle-counts/accuracy [get]
+func (h *StockHandler) GetCountAccuracy(c *fiber.Ctx) error {
+	to := time.Now()
+	from := to.AddDate(-1, 0, 0)
+	if s := c.Query("from"); s != "" {
+		t, err := parseTime(s)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "Invalid from date"})
+		}
+		from = t
+	}
+	if s := c.Query("to"); s != "" {
+		t, err := parseTime(s)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "Invalid to date"})
+		}
+		to = t
+	}
+	if !from.Before(to) {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "from must be before to"})
+	}
+
+	report, err := h.service.WithContext(c.UserContext()).GetCountAccuracy(models.CountAccuracyFilter{
+		ScopeID:    c.Query("scopeId"),
+		LocationID: c.Query("locationId"),
+		From:       from,
+		To:         to,
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{Error: err.Error()})
+	}
+
+	return c.JSON(report)
+}
+
+func countTaskError(c *fiber.Ctx, err error) error {
+	switch err {
+	case services.ErrCountTaskNotFound:
+		return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{Error: err.Error()})
+	case services.ErrCountTaskNotPending:
+		return c.Status(fiber.StatusConflict).JSON(ErrorResponse{Error: err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{Error: err.Error()})
+	}
+}
+
+// =============== Kits ===============
+
+// ListKits godoc
+// @Summary List kits (bundles of items per service type)
+// @Tags Stock Kits
+// @Produce json
+// @Param search query string false "Search by name"
+// @Param categoryId query string false "Kits attached to this ticket category"
+// @Param isActive query bool false "Filter by active status"
+// @Param page query int false "Page number" default(1)
+// @Param pageSize query int false "Page size" default(20)
+// @Success 200 {object} models.PaginatedStockKits
+// @Router /stock/kits [get]
+func (h *StockHandler) ListKits(c *fiber.Ctx) error {
+	filter := models.StockKitFilter{
+		Search:     c.Query("search"),
+		CategoryID: c.Query("categoryId"),
+		Page:       getIntQuery(c, "page", 1),
+		PageSize:   pageSize(c, pagination.Stock, "pageSize"),
+	}
+
+	if isActiveStr := c.Query("isActive"); isActiveStr != "" {
+		isActive := isActiveStr == "true"
+		filter.IsActive = &isActive
+	}
+
+	result, err := h.service.WithContext(c.UserContext()).ListKits(filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{Error: err.Error()})
+	}
+
+	return c.JSON(result)
+}
+
+// GetKit godoc
+// @Summary Get a kit with its items and categories
+// @Tags Stock Kits
+// @Produce json
+// @Param id path string true "Kit ID"
+// @Success 200 {object} models.StockKit
+// @Failure 404 {object} ErrorResponse
+// @Router /stock/kits/{id} [get]
+func (h *StockHandler) GetKit(c *fiber.Ctx) error {
+	kit, err := h.service.WithContext(c.UserContext()).GetKit(c.Params("id"))
+	if err != nil {
+		return kitError(c, err)
+	}
+	return c.JSON(kit)
+}
+
+// CreateKit godoc
+// @Summary Create a kit
+// @Tags Stock Kits
+// @Accept json
+// @Produce json
+// @Param request body models.CreateStockKitRequest true "Kit data"
+// @Success 201 {object} models.StockKit
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /stock/kits [post]
+func (h *StockHandler) CreateKit(c *fiber.Ctx) error {
+	var req models.CreateStockKitRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "Invalid request body"})
+	}
+
+	if strings.TrimSpace(req.Name) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "Name is required"})
+	}
+
+	userID := c.Locals("userId").(string)
+
+	kit, err := h.service.WithContext(c.UserContext()).CreateKit(req, userID)
+	if err != nil {
+		return kitError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(kit)
+}
+
+// UpdateKit godoc
+// @Summary Update a kit; items and categoryIds replace the current ones when given
+// @Tags Stock Kits
+// @Accept json
+// @Produce json
+// @Param id path string true "Kit ID"
+// @Param request body models.UpdateStockKitRequest true "Kit data"
+// @Success 200 {object} models.StockKit
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /stock/kits/{id} [put]
+func (h *StockHandler) UpdateKit(c *fiber.Ctx) error {
+	var req models.UpdateStockKitRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "Invalid request body"})
+	}
+
+	kit, err := h.service.WithContext(c.UserContext()).UpdateKit(c.Params("id"), req)
+	if err != nil {
+		return kitError(c, err)
+	}
+
+	return c.JSON(kit)
+}
+
+// DeleteKit godoc
+// @Summary Delete a kit (movements already made are kept)
+// @Tags Stock Kits
+// @Param id path string true "Kit ID"
+// @Success 204
+// @Failure 404 {object} ErrorResponse
+// @Router /stock/kits/{id} [delete]
+func (h *StockHandler) DeleteKit(c *fiber.Ctx) error {
+	if err := h.service.WithContext(c.UserContext()).DeleteKit(c.Params("id")); err != nil {
+		return kitError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ConsumeKit godoc
+// @Summary Consume a kit on a ticket: one SAIDA_CONSUMO_OS movement per item, in one transaction
+// @Tags Stock Kits
+// @Accept json
+// @Produce json
+// @Param id path string true "Kit ID"
+// @Param request body models.ConsumeKitRequest true "Ticket and source location"
+// @Success 201 {object} models.ConsumeKitResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 422 {object} ErrorResponse
+// @Router /stock/kits/{id}/consume [post]
+func (h *StockHandler) ConsumeKit(c *fiber.Ctx) error {
+	var req models.ConsumeKitRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "Invalid request body"})
+	}
+
+	if req.TicketID == "" || req.FromLocationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "TicketID and FromLocationID are required"})
+	}
+
+	userID := c.Locals("userId").(string)
+
+	result, err := h.service.WithContext(c.UserContext()).ConsumeKit(c.Params("id"), req, userID)
+	if err != nil {
+		return kitError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(result)
+}
+
+// GetKitAvailability godoc
+// @Summary Check whether a location can supply a kit
+// @Tags Stock Kits
+// @Produce json
+// @Param id path string true "Kit ID"
+// @Param locationId query string true "Location ID"
+// @Param quantity query int false "Number of kits" default(1)
+// @Success 200 {object} models.KitAvailability
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /stock/kits/{id}/availability [get]
+func (h *StockHandler) GetKitAvailability(c *fiber.Ctx) error {
+	locationID := c.Query("locationId")
+	if locationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "location_id is required"})
+	}
+
+	result, err := h.service.WithContext(c.UserContext()).GetKitAvailability(c.Params("id"), locationID, getIntQuery(c, "quantity", 1))
+	if err != nil {
+		return kitError(c, err)
+	}
+
+	return c.JSON(result)
+}
+
+// GetTicketKitAvailability godoc
+// @Summary Availability at a location of the kits attached to a ticket category (check before dispatch)
+// @Tags Stock Kits
+// @Produce json
+// @Param ticketId query string true "Ticket ID"
+// @Param locationId query string true "Location ID (e.g. the technician van)"
+// @Success 200 {array} models.KitAvailability
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /stock/kits/ticket-availability [get]
+func (h *StockHandler) GetTicketKitAvailability(c *fiber.Ctx) error {
+	ticketID, locationID := c.Query("ticketId"), c.Query("locationId")
+	if ticketID == "" || locationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "ticket_id and location_id are required"})
+	}
+
+	result, err := h.service.WithContext(c.UserContext()).GetTicketKitAvailability(ticketID, locationID)
+	if err != nil {
+		return kitError(c, err)
+	}
+
+	return c.JSON(result)
+}
+
+func kitError(c *fiber.Ctx, err error) error {
+	switch err {
+	case services.ErrKitNotFound, services.ErrItemNotFound, services.ErrLocationNotFound,
+		services.ErrStockTicketNotFound, services.ErrKitCategoryNotFound:
+		return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{Error: err.Error()})
+	case services.ErrKitNameExists:
+		return c.Status(fiber.StatusConflict).JSON(ErrorResponse{Error: err.Error()})
+	case services.ErrKitEmpty, services.ErrKitDuplicateItem, services.ErrNegativeQuantity,
+		services.ErrSerialsRequired, services.ErrSerialDuplicate, services.ErrSerialNotTracked:
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: err.Error()})
+	case services.ErrInsufficientStock, services.ErrStockReserved, services.ErrKitInactive, services.ErrPartsBudgetExceeded,
+		services.ErrSerialNotFound, services.ErrSerialNotAtLocation:
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(ErrorResponse{Error: err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{Error: err.Error()})
+	}
+}
+
+// =============== RMA ===============
+
+// ListRMAs godoc
+// @Summary List RMAs of defective parts
+// @Tags Stock RMA
+// @Produce json
+// @Param status query string false "QUARANTINE, SENT_TO_SUPPLIER or CLOSED"
+// @Param supplier query string false "Search by supplier"
+// @Param ticketId query string false "Filter by ticket ID"
+// @Param itemId query string false "Filter by item ID"
+// @Param page query int false "Page number" default(1)
+// @Param pageSize query int false "Page size" default(20)
+// @Success 200 {object} models.PaginatedStockRMAs
+// @Router /stock/rmas [get]
+func (h *StockHandler) ListRMAs(c *fiber.Ctx) error {
+	filter := models.StockRMAFilter{
+		Status:   strings.ToUpper(c.Query("status")),
+		Supplier: c.Query("supplier"),
+		TicketID: c.Query("ticketId"),
+		ItemID:   c.Query("itemId"),
+		Page:     getIntQuery(c, "page", 1),
+		PageSize: pageSize(c, pagination.Stock, "pageSize"),
+	}
+
+	result, err := h.service.WithContext(c.UserContext()).ListRMAs(filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{Error: err.Error()})
+	}
+
+	return c.JSON(result)
+}
+
+// GetRMA godoc
+// @Summary Get an RMA
+// @Tags Stock RMA
+// @Produce json
+// @Param id path string true "RMA ID"
+// @Success 200 {object} models.StockRMAResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /stock/rmas/{id} [get]
+func (h *StockHandler) GetRMA(c *fiber.Ctx) error {
+	rma, err := h.service.WithContext(c.UserContext()).GetRMA(c.Params("id"))
+	if err != nil {
+		return rmaError(c, err)
+	}
+	return c.JSON(rma)
+}
+
+// CreateRMA godoc
+// @Summary Receive a defective part removed from a client into quarantine
+// @Tags Stock RMA
+// @Accept json
+// @Produce json
+// @Param request body models.CreateRMARequest true "RMA data"
+// @Success 201 {object} models.StockRMAResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /stock/rmas [post]
+func (h *StockHandler) CreateRMA(c *fiber.Ctx) error {
+	var req models.CreateRMARequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "Invalid request body"})
+	}
+
+	if req.TicketID == "" || req.ItemID == "" || req.QuarantineLocationID == "" || req.Quantity <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "TicketID, ItemID, QuarantineLocationID and positive Quantity are required"})
+	}
+
+	if strings.TrimSpace(req.DefectDescription) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "Defect description is required"})
+	}
+
+	userID := c.Locals("userId").(string)
+
+	rma, err := h.service.WithContext(c.UserContext()).CreateRMA(req, userID)
+	if err != nil {
+		return rmaError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(rma)
+}
+
+// ShipRMA godoc
+// @Summary Ship a quarantined part back to its supplier
+// @Tags Stock RMA
+// @Accept json
+// @Produce json
+// @Param id path string true "RMA ID"
+// @Param request body models.ShipRMARequest true "Supplier and tracking"
+// @Success 200 {object} models.StockRMAResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /stock/rmas/{id}/ship [post]
+func (h *StockHandler) ShipRMA(c *fiber.Ctx) error {
+	var req models.ShipRMARequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "Invalid request body"})
+	}
+
+	if strings.TrimSpace(req.Supplier) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "Supplier is required"})
+	}
+
+	userID := c.Locals("userId").(string)
+
+	rma, err := h.service.WithContext(c.UserContext()).ShipRMA(c.Params("id"), req, userID)
+	if err != nil {
+		return rmaError(c, err)
+	}
+
+	return c.JSON(rma)
+}
+
+// ResolveRMA godoc
+// @Summary Close an RMA with the supplier outcome (CREDIT, REPLACEMENT, REJECTED) or scrap it from quarantine (SCRAPPED)
+// @Tags Stock RMA
+// @Accept json
+// @Produce json
+// @Param id path string true "RMA ID"
+// @Param request body models.ResolveRMARequest true "Outcome"
+// @Success 200 {object} models.StockRMAResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /stock/rmas/{id}/resolve [post]
+func (h *StockHandler) ResolveRMA(c *fiber.Ctx) error {
+	var req models.ResolveRMARequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "Invalid request body"})
+	}
+
+	userID := c.Locals("userId").(string)
+
+	rma, err := h.service.WithContext(c.UserContext()).ResolveRMA(c.Params("id"), req, userID)
+	if err != nil {
+		return rmaError(c, err)
+	}
+
+	return c.JSON(rma)
+}
+
+func rmaError(c *fiber.Ctx, err error) error {
+	switch err {
+	case services.ErrRMANotFound, services.ErrItemNotFound, services.ErrLocationNotFound,
+		services.ErrStockTicketNotFound, services.ErrMovementNotFound:
+		return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{Error: err.Error()})
+	case services.ErrRMANotQuarantineLocation, services.ErrRMAOriginalMismatch,
+		services.ErrRMAReplacementLocation, services.ErrRMAInvalidOutcome, services.ErrNegativeQuantity,
+		services.ErrSerialsRequired, services.ErrSerialDuplicate:
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: err.Error()})
+	case services.ErrRMAInvalidStatus:
+		return c.Status(fiber.StatusConflict).JSON(ErrorResponse{Error: err.Error()})
+	case services.ErrInsufficientStock, services.ErrSerialInStock, services.ErrSerialNotFound, services.ErrSerialNotAtLocation:
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(ErrorResponse{Error: err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{Error: err.Error()})
+	}
+}
+
+// =============== Reservations ===============
+
+// ListReservations godoc
+// @Summary List stock reservations
+// @Tags Stock Reservations
+// @Produce json
+// @Param ticketId query string false "Filter by ticket ID"
+// @Param itemId query string false "Filter by item ID"
+// @Param locationId query string false "Filter by location ID"
+// @Param status query string false "ACTIVE, CONSUMED or RELEASED"
+// @Param page query int false "Page number" default(1)
+// @Param pageSize query int false "Page size" default(20)
+// @Success 200 {object} models.PaginatedStockReservations
+// @Router /stock/reservations [get]
+func (h *StockHandler) ListReservations(c *fiber.Ctx) error {
+	filter := models.StockReservationFilter{
+		TicketID:   c.Query("ticketId"),
+		ItemID:     c.Query("itemId"),
+		LocationID: c.Query("locationId"),
+		Status:     strings.ToUpper(c.Query("status")),
+		Page:       getIntQuery(c, "page", 1),
+		PageSize:   pageSize(c, pagination.Stock, "pageSize"),
+	}
+
+	result, err := h.service.WithContext(c.UserContext()).ListReservations(filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{Error: err.Error()})
+	}
+
+	return c.JSON(result)
+}
+
+// GetReservation godoc
+// @Summary Get a stock reservation
+// @Tags Stock Reservations
+// @Produce json
+// @Param id path string true "Reservation ID"
+// @Success 200 {object} models.StockReservation
+// @Failure 404 {object} ErrorResponse
+// @Router /stock/reservations/{id} [get]
+func (h *StockHandler) GetReservation(c *fiber.Ctx) error {
+	reservation, err := h.service.WithContext(c.UserContext()).GetReservation(c.Params("id"))
+	if err != nil {
+		return reservationError(c, err)
+	}
+	return c.JSON(reservation)
+}
+
+// CreateReservation godoc
+// @Summary Reserve parts at a location for a ticket
+// @Description The reserved quantity is taken from the available balance and can only be consumed by the ticket
+// @Tags Stock Reservations
+// @Accept json
+// @Produce json
+// @Param request body models.CreateStockReservationRequest true "Reservation data"
+// @Success 201 {object} models.StockReservation
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 422 {object} ErrorResponse
+// @Router /stock/reservations [post]
+func (h *StockHandler) CreateReservation(c *fiber.Ctx) error {
+	var req models.CreateStockReservationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "Invalid request body"})
+	}
+
+	if req.TicketID == "" || req.ItemID == "" || req.LocationID == "" || req.Quantity <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "TicketID, ItemID, LocationID and positive Quantity are required"})
+	}
+
+	userID := c.Locals("userId").(string)
+
+	reservation, err := h.service.WithContext(c.UserContext()).CreateReservation(req, userID)
+	if err != nil {
+		return reservationError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(reservation)
+}
+
+// ReleaseReservation godoc
+// @Summary Release a reservation without consuming it
+// @Tags Stock Reservations
+// @Accept json
+// @Produce json
+// @Param id path string true "Reservation ID"
+// @Param request body models.ReleaseStockReservationRequest false "Reason"
+// @Success 200 {object} models.StockReservation
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /stock/reservations/{id}/release [post]
+func (h *StockHandler) ReleaseReservation(c *fiber.Ctx) error {
+	var req models.ReleaseStockReservationRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: "Invalid request body"})
 		}
+	}
+
+	reservation, err := h.service.WithContext(c.UserContext()).ReleaseReservation(c.Params("id"), req)
+	if err != nil {
+		return reservationError(c, err)
+	}
+
+	return c.JSON(reservation)
+}
+
+func reservationError(c *fiber.Ctx, err error) error {
+	switch err {
+	case services.ErrReservationNotFound, services.ErrItemNotFound, services.ErrLocationNotFound,
+		services.ErrStockTicketNotFound:
+		return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{Error: err.Error()})
+	case services.ErrNegativeQuantity:
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: err.Error()})
+	case services.ErrReservationNotActive:
+		return c.Status(fiber.StatusConflict).JSON(ErrorResponse{Error: err.Error()})
+	case services.ErrInsufficientStock, services.ErrReservationTicketClosed, services.ErrLocationInactive:
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(ErrorResponse{Error: err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{Error: err.Error()})
+	}
+}
+
+// =============== Serial numbers ===============
+
+// ListSerials godoc
+// @Summary List the units of serial-tracked items
+// @Tags Stock Serials
+// @Produce json
+// @Param itemId query string false "Item ID"
+// @Param locationId query string false "Location ID"
+// @Param status query string false "IN_STOCK, INSTALLED, LOST or RETURNED"
+// @Param search query string false "Serial number contains"
+// @Param page query int false "Page number"
+// @Param pageSize query int false "Page size"
+// @Success 200 {object} models.PaginatedStockSerials
+// @Router /stock/serials [get]
+func (h *StockHandler) ListSerials(c *fiber.Ctx) error {
+	filter := models.StockSerialFilter{
+		ItemID:     c.Query("itemId"),
+		LocationID: c.Query("locationId"),
+		Status:     strings.ToUpper(c.Query("status")),
+		Search:     c.Query("search"),
+		Page:       getIntQuery(c, "page", 1),
+		PageSize:   pageSize(c, pagination.Stock, "pageSize"),
+	}
+
+	result, err := h.service.WithContext(c.UserContext()).ListSerials(filter)
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{Error: err.Error()})
 	}
 
 	return c.JSON(result)
 }
 
+// GetSerialHistory godoc
+// @Summary Trace a serial number
+// @Description Current status and location of the unit with every movement that moved it, oldest first. Items may share a serial number, so one history per unit is returned.
+// @Tags Stock Serials
+// @Produce json
+// @Param serial path string true "Serial number"
+// @Param itemId query string false "Item ID"
+// @Success 200 {array} models.SerialHistory
+// @Failure 404 {object} ErrorResponse
+// @Router /stock/serials/{serial}/history [get]
+func (h *StockHandler) GetSerialHistory(c *fiber.Ctx) error {
+	history, err := h.service.WithContext(c.UserContext()).GetSerialHistory(c.Params("serial"), c.Query("itemId"))
+	if err != nil {
+		if err == services.ErrSerialNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{Error: err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{Error: err.Error()})
+	}
+
+	return c.JSON(history)
+}
+
 // =============== Route Registration ===============
 
-func (h *StockHandler) RegisterRoutes(app *fiber.App, authMiddleware fiber.Handler) {
-	stock := app.Group("/api/v1/stock", authMiddleware)
+func (h *StockHandler) RegisterRoutes(app *fiber.App, authMiddleware, rateLimit fiber.Handler) {
+	stock := app.Group("/api/v1/stock", authMiddleware, rateLimit, h.permissions.Require("inventory.view"))
 
-	// Items - write requires ADMIN or EMPLOYEE
+	// Items - write requires inventory.manage
 	items := stock.Group("/items")
-	items.Get("/", h.ListItems)                                            // All authenticated users
-	items.Get("/:id", h.GetItem)                                           // All authenticated users
-	items.Post("/", middleware.AdminOrEmployee(), h.CreateItem)            // ADMIN/EMPLOYEE only
-	items.Put("/:id", middleware.AdminOrEmployee(), h.UpdateItem)          // ADMIN/EMPLOYEE only
+	items.Get("/", h.ListItems)                                            // inventory.view
+	items.Get("/:id", h.GetItem)                                           // inventory.view
+	items.Post("/", h.permissions.Require("inventory.manage"), h.CreateItem)            // inventory.manage
+	items.Put("/:id", h.permissions.Require("inventory.manage"), h.UpdateItem)          // inventory.manage
 	items.Delete("/:id", middleware.AdminOnly(), h.DeleteItem)             // ADMIN only
 
-	// Locations - write requires ADMIN or EMPLOYEE
+	// Compatibility - write requires inventory.manage
+	items.Get("/:id/compatibility", h.ListCompatibilities)                                               // inventory.view
+	items.Post("/:id/compatibility", h.permissions.Require("inventory.manage"), h.AddCompatibility)                   // inventory.manage
+	items.Delete("/:id/compatibility/:compatibilityId", h.permissions.Require("inventory.manage"), h.RemoveCompatibility) // inventory.manage
+	stock.Get("/compatible-parts", h.FindCompatibleParts)                                                // inventory.view (mobile part picker)
+
+	// Locations - write requires inventory.manage
 	locations := stock.Group("/locations")
-	locations.Get("/", h.ListLocations)                                    // All authenticated users
-	locations.Get("/:id", h.GetLocation)                                   // All authenticated users
-	locations.Post("/", middleware.AdminOrEmployee(), h.CreateLocation)    // ADMIN/EMPLOYEE only
-	locations.Put("/:id", middleware.AdminOrEmployee(), h.UpdateLocation)  // ADMIN/EMPLOYEE only
+	locations.Get("/", h.ListLocations)                                    // inventory.view
+	locations.Get("/:id", h.GetLocation)                                   // inventory.view
+	locations.Post("/", h.permissions.Require("inventory.manage"), h.CreateLocation)    // inventory.manage
+	locations.Put("/:id", h.permissions.Require("inventory.manage"), h.UpdateLocation)  // inventory.manage
 	locations.Delete("/:id", middleware.AdminOnly(), h.DeleteLocation)     // ADMIN only
 
-	// Movements - write requires ADMIN or EMPLOYEE
+	// Movements - write requires inventory.manage
 	movements := stock.Group("/movements")
-	movements.Get("/", h.ListMovements)                                    // All authenticated users
-	movements.Get("/:id", h.GetMovement)                                   // All authenticated users
-	movements.Post("/", middleware.AdminOrEmployee(), h.CreateMovement)    // ADMIN/EMPLOYEE only
+	movements.Get("/", h.ListMovements)                                    // inventory.view
+	movements.Get("/:id", h.permissions.RequireOn("inventory.view", middleware.StockMovementNode("id")), h.GetMovement) // inventory.view on the node of its ticket
+	movements.Post("/", h.permissions.Require("inventory.manage"), h.CreateMovement)    // inventory.manage
+	movements.Post("/:id/approve", middleware.AdminOnly(), h.ApproveMovement) // ADMIN only
+	movements.Post("/:id/reject", middleware.AdminOnly(), h.RejectMovement)   // ADMIN only
 
-	// Balances - read only for all, inventory count for ADMIN/EMPLOYEE
+	// Balances - read only for all, inventory count with inventory.manage
 	balances := stock.Group("/balances")
-	balances.Get("/", h.ListBalances)                                      // All authenticated users
-	balances.Get("/single", h.GetBalance)                                  // All authenticated users
-
-	// Inventory Count - ADMIN or EMPLOYEE only
-	stock.Post("/inventory-count", middleware.AdminOrEmployee(), h.PerformInventoryCount)
+	balances.Get("/", h.ListBalances)                                      // inventory.view
+	balances.Get("/single", h.GetBalance)                                  // inventory.view
+
+	// Inventory Count - inventory.manage only
+	stock.Post("/inventory-count", h.permissions.Require("inventory.manage"), h.PerformInventoryCount)
+
+	// Levels and replenishment - write requires inventory.manage
+	levels := stock.Group("/levels")
+	levels.Get("/", h.ListLevels)                                          // inventory.view
+	levels.Put("/", h.permissions.Require("inventory.manage"), h.SetLevel)              // inventory.manage
+	levels.Delete("/:id", h.permissions.Require("inventory.manage"), h.DeleteLevel)     // inventory.manage
+	stock.Get("/replenishment-suggestions", h.permissions.Require("inventory.manage"), h.GetReplenishmentSuggestions) // inventory.manage
+
+	// Kits - write requires inventory.manage
+	kits := stock.Group("/kits")
+	kits.Get("/", h.ListKits)                                              // inventory.view
+	kits.Get("/ticket-availability", h.GetTicketKitAvailability)           // inventory.view
+	kits.Get("/:id", h.GetKit)                                             // inventory.view
+	kits.Get("/:id/availability", h.GetKitAvailability)                    // inventory.view
+	kits.Post("/", h.permissions.Require("inventory.manage"), h.CreateKit)              // inventory.manage
+	kits.Put("/:id", h.permissions.Require("inventory.manage"), h.UpdateKit)            // inventory.manage
+	kits.Delete("/:id", middleware.AdminOnly(), h.DeleteKit)               // ADMIN only
+	kits.Post("/:id/consume", h.permissions.Require("inventory.manage"), h.ConsumeKit)  // inventory.manage
+
+	// RMA - write requires inventory.manage
+	rmas := stock.Group("/rmas")
+	rmas.Get("/", h.ListRMAs)                                              // inventory.view
+	rmas.Get("/:id", h.GetRMA)                                             // inventory.view
+	rmas.Post("/", h.permissions.Require("inventory.manage"), h.CreateRMA)              // inventory.manage
+	rmas.Post("/:id/ship", h.permissions.Require("inventory.manage"), h.ShipRMA)        // inventory.manage
+	rmas.Post("/:id/resolve", h.permissions.Require("inventory.manage"), h.ResolveRMA)  // inventory.manage
+
+	// Reservations - parts held for a ticket, consumed by its SAIDA_CONSUMO_OS movements
+	reservations := stock.Group("/reservations")
+	reservations.Get("/", h.ListReservations)                                                          // inventory.view
+	reservations.Get("/:id", h.GetReservation)                                                         // inventory.view
+	reservations.Post("/", h.permissions.Require("inventory.manage"), h.CreateReservation)      // inventory.manage
+	reservations.Post("/:id/release", h.permissions.Require("inventory.manage"), h.ReleaseReservation) // inventory.manage
+
+	// Serial numbers - units move with the movements of items with trackSerial
+	serials := stock.Group("/serials")
+	serials.Get("/", h.ListSerials)                      // inventory.view
+	serials.Get("/:serial/history", h.GetSerialHistory)  // inventory.view
+
+	// Cycle counts - counts are recorded through /inventory-count with a taskId
+	cycleCounts := stock.Group("/cycle-counts")
+	cycleCounts.Get("/tasks", h.ListCountTasks)                                                   // inventory.view
+	cycleCounts.Post("/generate", h.permissions.Require("inventory.manage"), h.GenerateCycleCount)              // inventory.manage
+	cycleCounts.Put("/tasks/:id/assign", h.permissions.Require("inventory.manage"), h.AssignCountTask)          // inventory.manage
+	cycleCounts.Post("/tasks/:id/cancel", h.permissions.Require("inventory.manage"), h.CancelCountTask)         // inventory.manage
+	cycleCounts.Get("/accuracy", h.permissions.Require("inventory.manage"), h.GetCountAccuracy)                 // inventory.manage
 }
 
 // =============== Helpers ===============
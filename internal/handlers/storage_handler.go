@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type StorageHandler struct {
+	service  services.StorageService
+	validate *validator.Validate
+}
+
+func NewStorageHandler(service services.StorageService) *StorageHandler {
+	return &StorageHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// GetReport returns storage usage by module, tenant and age
+func (h *StorageHandler) GetReport(c *fiber.Ctx) error {
+	report, err := h.service.GetReport()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to build storage report",
+		})
+	}
+	return c.JSON(report)
+}
+
+// GetOrphans lists files that no entity references anymore
+func (h *StorageHandler) GetOrphans(c *fiber.Ctx) error {
+	orphans, err := h.service.FindOrphans()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to detect orphan files",
+		})
+	}
+	return c.JSON(orphans)
+}
+
+// Cleanup removes orphan files immediately
+func (h *StorageHandler) Cleanup(c *fiber.Ctx) error {
+	result, err := h.service.CleanupOrphans()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to clean up storage",
+		})
+	}
+	return c.JSON(result)
+}
+
+// ListQuotas returns the configured storage quotas
+func (h *StorageHandler) ListQuotas(c *fiber.Ctx) error {
+	quotas, err := h.service.ListQuotas()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch storage quotas",
+		})
+	}
+	return c.JSON(quotas)
+}
+
+// UpsertQuota creates or updates the quota of a tenant (nodeId) and module
+func (h *StorageHandler) UpsertQuota(c *fiber.Ctx) error {
+	var req models.UpsertStorageQuotaRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	quota, err := h.service.UpsertQuota(&req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.JSON(quota)
+}
+
+// DeleteQuota removes a storage quota
+func (h *StorageHandler) DeleteQuota(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid quota ID",
+		})
+	}
+
+	if err := h.service.DeleteQuota(uint(id)); err != nil {
+		if errors.Is(err, services.ErrStorageQuotaNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type SupplierHandler struct {
+	service  services.SupplierService
+	validate *validator.Validate
+}
+
+func NewSupplierHandler(service services.SupplierService) *SupplierHandler {
+	return &SupplierHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// List returns suppliers (?page=&size=&search=&inactive=true)
+func (h *SupplierHandler) List(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "0"))
+	size := pageSize(c, pagination.Default, "size")
+	filters := &models.SupplierFilters{
+		Search:   c.Query("search"),
+		Inactive: c.QueryBool("inactive"),
+	}
+
+	result, err := h.service.List(page, size, filters)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch suppliers",
+		})
+	}
+	return c.JSON(result)
+}
+
+// Get returns a supplier
+func (h *SupplierHandler) Get(c *fiber.Ctx) error {
+	supplier, err := h.service.Get(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(supplier)
+}
+
+// Create registers a supplier
+func (h *SupplierHandler) Create(c *fiber.Ctx) error {
+	var req models.SupplierRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	userID, _ := c.Locals("userId").(string)
+
+	supplier, err := h.service.Create(userID, &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(supplier)
+}
+
+// Update replaces a supplier's data
+func (h *SupplierHandler) Update(c *fiber.Ctx) error {
+	var req models.SupplierRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	supplier, err := h.service.Update(c.Params("id"), &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(supplier)
+}
+
+// Delete removes a supplier; movements and entries linked to it keep the reference
+func (h *SupplierHandler) Delete(c *fiber.Ctx) error {
+	if err := h.service.Delete(c.Params("id")); err != nil {
+		return h.handleError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *SupplierHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrSupplierNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrSupplierCNPJExists):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type TeamQueueHandler struct {
+	service  services.TeamQueueService
+	validate *validator.Validate
+}
+
+func NewTeamQueueHandler(service services.TeamQueueService) *TeamQueueHandler {
+	return &TeamQueueHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// List returns the team queues with their headcount and open backlog
+func (h *TeamQueueHandler) List(c *fiber.Ctx) error {
+	queues, err := h.service.ListQueues()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch team queues",
+		})
+	}
+	return c.JSON(queues)
+}
+
+// Create maps a new team queue to a hierarchy node
+func (h *TeamQueueHandler) Create(c *fiber.Ctx) error {
+	var req models.TeamQueueRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	queue, err := h.service.CreateQueue(&req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(queue)
+}
+
+// Update renames, remaps, reweights or (de)activates a team queue
+func (h *TeamQueueHandler) Update(c *fiber.Ctx) error {
+	var req models.TeamQueueRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	queue, err := h.service.UpdateQueue(c.Params("id"), &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(queue)
+}
+
+// GetTickets lists the open tickets of a queue, oldest first (?page=&size=)
+func (h *TeamQueueHandler) GetTickets(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 0)
+	size := pageSize(c, pagination.Default, "size")
+	if page < 0 {
+		page = 0
+	}
+
+	result, err := h.service.GetQueueTickets(c.Params("id"), page, size)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(result)
+}
+
+// GetSettings returns the distribution policy
+func (h *TeamQueueHandler) GetSettings(c *fiber.Ctx) error {
+	settings, err := h.service.GetSettings()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch distribution settings",
+		})
+	}
+	return c.JSON(settings)
+}
+
+// UpdateSettings changes the distribution policy
+func (h *TeamQueueHandler) UpdateSettings(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+
+	var req models.UpdateTeamQueueSettingsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	settings, err := h.service.UpdateSettings(&req, userID)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(settings)
+}
+
+// Metrics returns the backlog of every team queue
+func (h *TeamQueueHandler) Metrics(c *fiber.Ctx) error {
+	metrics, err := h.service.Metrics()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to compute team queue metrics",
+		})
+	}
+	return c.JSON(metrics)
+}
+
+// Distribute queues now the unowned tickets that are in no queue yet
+func (h *TeamQueueHandler) Distribute(c *fiber.Ctx) error {
+	result, err := h.service.Distribute()
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(result)
+}
+
+// Rebalance moves open, unowned tickets out of an overloaded queue
+func (h *TeamQueueHandler) Rebalance(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+
+	var req models.RebalanceRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	result, err := h.service.Rebalance(&req, userID)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(result)
+}
+
+// GetTicketQueue returns the queue a ticket is in
+func (h *TeamQueueHandler) GetTicketQueue(c *fiber.Ctx) error {
+	assignment, err := h.service.GetTicketQueue(c.Params("ticketId"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(assignment)
+}
+
+// MoveTicket puts a ticket in another queue
+func (h *TeamQueueHandler) MoveTicket(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+
+	var req models.MoveTicketQueueRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	assignment, err := h.service.MoveTicket(c.Params("ticketId"), &req, userID)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(assignment)
+}
+
+func (h *TeamQueueHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrTeamQueueNotFound),
+		errors.Is(err, services.ErrTeamQueueTicketNotFound),
+		errors.Is(err, services.ErrTicketNotQueued):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrTeamQueueNodeNotFound),
+		errors.Is(err, services.ErrTeamQueueInactive),
+		errors.Is(err, services.ErrRebalanceSameQueue),
+		errors.Is(err, services.ErrInvalidDistributionMode):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
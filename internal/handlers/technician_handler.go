@@ -1,12 +1,14 @@
 package handlers
 
 import (
-	"fmt"
+	"errors"
+	"log/slog"
 	"strconv"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/go-playground/validator/v10"
 	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
 	"github.com/shigake/tech-iq-back/internal/services"
 )
 
@@ -33,10 +35,10 @@ func NewTechnicianHandler(service services.TechnicianService) *TechnicianHandler
 // @Param ids query string false "Comma-separated IDs"
 // @Security BearerAuth
 // @Success 200 {object} models.PaginatedResponse
-// @Router /api/v1/technicians [get]
+// @Router /technicians [get]
 func (h *TechnicianHandler) GetAll(c *fiber.Ctx) error {
 	page, _ := strconv.Atoi(c.Query("page", "0"))
-	size, _ := strconv.Atoi(c.Query("size", "20"))
+	size := pageSize(c, pagination.Technicians, "size")
 	search := c.Query("search", "")
 	idsParam := c.Query("ids", "")
 	
@@ -46,44 +48,45 @@ func (h *TechnicianHandler) GetAll(c *fiber.Ctx) error {
 	city := c.Query("city", "")
 	state := c.Query("state", "")
 	
-	fmt.Printf(">>> GetAll params: page=%d, size=%d, search='%s', status='%s', type='%s', city='%s', state='%s'\n", 
-		page, size, search, status, techType, city, state)
+	slog.DebugContext(c.UserContext(), "Technician list params", "page", page, "size", size, "search", search,
+		"status", status, "type", techType, "city", city, "state", state)
 
-	if size > 1000 {
-		size = 1000
+	fields, err := parseFields(c, "technicians")
+	if err != nil {
+		return invalidFields(c, "technicians", err)
 	}
 
 	// Handle specific IDs filter
 	if idsParam != "" {
-		response, err := h.service.FindByIDs(idsParam)
+		response, err := h.service.WithContext(c.UserContext()).WithScope(accessScope(c)).FindByIDs(idsParam)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": "Failed to fetch technicians",
 			})
 		}
-		return c.JSON(response)
+		return c.JSON(withFields(response, fields))
 	}
 
 	// Handle search with optional filters
 	if search != "" || status != "" || techType != "" || city != "" || state != "" {
-		response, err := h.service.SearchWithFilters(search, status, techType, city, state, page, size)
+		response, err := h.service.WithContext(c.UserContext()).WithScope(accessScope(c)).SearchWithFilters(search, status, techType, city, state, page, size)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": "Failed to search technicians",
 			})
 		}
-		return c.JSON(response)
+		return c.JSON(withFields(response, fields))
 	}
 
 	// Regular listing
-	response, err := h.service.GetAll(page, size)
+	response, err := h.service.WithContext(c.UserContext()).WithScope(accessScope(c)).GetAll(page, size)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch technicians",
 		})
 	}
 
-	return c.JSON(response)
+	return c.JSON(withFields(response, fields))
 }
 
 // GetByID returns a technician by ID
@@ -95,11 +98,11 @@ func (h *TechnicianHandler) GetAll(c *fiber.Ctx) error {
 // @Security BearerAuth
 // @Success 200 {object} models.Technician
 // @Failure 404 {object} map[string]string
-// @Router /api/v1/technicians/{id} [get]
+// @Router /technicians/{id} [get]
 func (h *TechnicianHandler) GetByID(c *fiber.Ctx) error {
 	id := c.Params("id")
 	
-	technician, err := h.service.GetByID(id)
+	technician, err := h.service.WithContext(c.UserContext()).WithScope(accessScope(c)).GetByID(id)
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Technician not found",
@@ -118,7 +121,7 @@ func (h *TechnicianHandler) GetByID(c *fiber.Ctx) error {
 // @Security BearerAuth
 // @Success 201 {object} models.Technician
 // @Failure 400 {object} map[string]string
-// @Router /api/v1/technicians [post]
+// @Router /technicians [post]
 func (h *TechnicianHandler) Create(c *fiber.Ctx) error {
 	var req models.CreateTechnicianRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -134,7 +137,7 @@ func (h *TechnicianHandler) Create(c *fiber.Ctx) error {
 		})
 	}
 
-	technician, err := h.service.Create(&req)
+	technician, err := h.service.WithContext(c.UserContext()).Create(&req)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
@@ -155,7 +158,8 @@ func (h *TechnicianHandler) Create(c *fiber.Ctx) error {
 // @Success 200 {object} models.Technician
 // @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
-// @Router /api/v1/technicians/{id} [put]
+// @Failure 409 {object} models.VersionConflictResponse
+// @Router /technicians/{id} [put]
 func (h *TechnicianHandler) Update(c *fiber.Ctx) error {
 	id := c.Params("id")
 	
@@ -166,8 +170,12 @@ func (h *TechnicianHandler) Update(c *fiber.Ctx) error {
 		})
 	}
 
-	technician, err := h.service.Update(id, &req)
+	technician, err := h.service.WithContext(c.UserContext()).WithScope(accessScope(c)).Update(id, &req)
 	if err != nil {
+		var conflict *services.VersionConflictError
+		if errors.As(err, &conflict) {
+			return c.Status(fiber.StatusConflict).JSON(conflict.Response())
+		}
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Technician not found",
 		})
@@ -185,11 +193,11 @@ func (h *TechnicianHandler) Update(c *fiber.Ctx) error {
 // @Security BearerAuth
 // @Success 204
 // @Failure 404 {object} map[string]string
-// @Router /api/v1/technicians/{id} [delete]
+// @Router /technicians/{id} [delete]
 func (h *TechnicianHandler) Delete(c *fiber.Ctx) error {
 	id := c.Params("id")
 	
-	if err := h.service.Delete(id); err != nil {
+	if err := h.service.WithContext(c.UserContext()).WithScope(accessScope(c)).Delete(id); err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Technician not found",
 		})
@@ -208,13 +216,13 @@ func (h *TechnicianHandler) Delete(c *fiber.Ctx) error {
 // @Param size query int false "Page size" default(20)
 // @Security BearerAuth
 // @Success 200 {object} models.PaginatedResponse
-// @Router /api/v1/technicians/search [get]
+// @Router /technicians/search [get]
 func (h *TechnicianHandler) Search(c *fiber.Ctx) error {
 	query := c.Query("q", "")
 	page, _ := strconv.Atoi(c.Query("page", "0"))
-	size, _ := strconv.Atoi(c.Query("size", "20"))
+	size := pageSize(c, pagination.Technicians, "size")
 
-	response, err := h.service.Search(query, page, size)
+	response, err := h.service.WithContext(c.UserContext()).WithScope(accessScope(c)).Search(query, page, size)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Search failed",
@@ -232,11 +240,11 @@ func (h *TechnicianHandler) Search(c *fiber.Ctx) error {
 // @Param city path string true "City name"
 // @Security BearerAuth
 // @Success 200 {array} models.TechnicianDTO
-// @Router /api/v1/technicians/by-city/{city} [get]
+// @Router /technicians/by-city/{city} [get]
 func (h *TechnicianHandler) GetByCity(c *fiber.Ctx) error {
 	city := c.Params("city")
 	
-	technicians, err := h.service.GetByCity(city)
+	technicians, err := h.service.WithContext(c.UserContext()).WithScope(accessScope(c)).GetByCity(city)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch technicians",
@@ -254,11 +262,11 @@ func (h *TechnicianHandler) GetByCity(c *fiber.Ctx) error {
 // @Param state path string true "State code (e.g., SP, RJ)"
 // @Security BearerAuth
 // @Success 200 {array} models.TechnicianDTO
-// @Router /api/v1/technicians/by-state/{state} [get]
+// @Router /technicians/by-state/{state} [get]
 func (h *TechnicianHandler) GetByState(c *fiber.Ctx) error {
 	state := c.Params("state")
 	
-	technicians, err := h.service.GetByState(state)
+	technicians, err := h.service.WithContext(c.UserContext()).WithScope(accessScope(c)).GetByState(state)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch technicians",
@@ -275,9 +283,9 @@ func (h *TechnicianHandler) GetByState(c *fiber.Ctx) error {
 // @Produce json
 // @Security BearerAuth
 // @Success 200 {array} string
-// @Router /api/v1/technicians/cities [get]
+// @Router /technicians/cities [get]
 func (h *TechnicianHandler) GetCities(c *fiber.Ctx) error {
-	cities, err := h.service.GetCities()
+	cities, err := h.service.WithContext(c.UserContext()).WithScope(accessScope(c)).GetCities()
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch cities",
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type TechnicianHomeHandler struct {
+	service services.TechnicianHomeService
+}
+
+func NewTechnicianHomeHandler(service services.TechnicianHomeService) *TechnicianHomeHandler {
+	return &TechnicianHomeHandler{service: service}
+}
+
+// GetHome returns the home screen of the technician app: today's tickets with the client
+// site, pending counts, the stock carried and the shift status
+// @Summary Technician app home
+// @Tags Me
+// @Produce json
+// @Success 200 {object} models.TechnicianHome
+// @Router /me/technician/home [get]
+func (h *TechnicianHomeHandler) GetHome(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+
+	home, err := h.service.GetHome(userID)
+	if err != nil {
+		if errors.Is(err, services.ErrNotATechnician) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "User is not linked to a technician",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load technician home",
+		})
+	}
+	return c.JSON(home)
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/services"
+	"gorm.io/gorm"
+)
+
+type TechnicianScheduleHandler struct {
+	service  services.TechnicianScheduleService
+	validate *validator.Validate
+}
+
+func NewTechnicianScheduleHandler(service services.TechnicianScheduleService) *TechnicianScheduleHandler {
+	return &TechnicianScheduleHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// GetSchedule returns the working week of a technician
+func (h *TechnicianScheduleHandler) GetSchedule(c *fiber.Ctx) error {
+	schedule, err := h.service.GetSchedule(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(schedule)
+}
+
+// SaveSchedule replaces the working week of a technician
+func (h *TechnicianScheduleHandler) SaveSchedule(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+
+	var req models.TechnicianScheduleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	schedule, err := h.service.SaveSchedule(c.Params("id"), userID, &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(schedule)
+}
+
+// DeleteSchedule removes the working week; the technician is always available again
+func (h *TechnicianScheduleHandler) DeleteSchedule(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+	if err := h.service.DeleteSchedule(c.Params("id"), userID); err != nil {
+		return h.handleError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ListTimeOff returns the days off and vacations of a technician (?from=&to=, default the
+// next 90 days)
+func (h *TechnicianScheduleHandler) ListTimeOff(c *fiber.Ctx) error {
+	now := time.Now()
+	from, to := now, now.AddDate(0, 0, 90)
+	if s := c.Query("from"); s != "" {
+		t, err := parseTime(s)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid from date"})
+		}
+		from = t
+	}
+	if s := c.Query("to"); s != "" {
+		t, err := parseTime(s)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid to date"})
+		}
+		to = t
+	}
+
+	timeOff, err := h.service.ListTimeOff(c.Params("id"), from, to)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(timeOff)
+}
+
+// AddTimeOff registers a day off or a vacation
+func (h *TechnicianScheduleHandler) AddTimeOff(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+
+	var req models.TechnicianTimeOffRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	timeOff, err := h.service.AddTimeOff(c.Params("id"), userID, &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(timeOff)
+}
+
+// DeleteTimeOff removes a day off or a vacation
+func (h *TechnicianScheduleHandler) DeleteTimeOff(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+	if err := h.service.DeleteTimeOff(c.Params("id"), c.Params("timeOffId"), userID); err != nil {
+		return h.handleError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// GetAvailability checks a technician over a period (?start=&end=, default now)
+func (h *TechnicianScheduleHandler) GetAvailability(c *fiber.Ctx) error {
+	start := time.Now()
+	if s := c.Query("start"); s != "" {
+		t, err := parseTime(s)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid start date"})
+		}
+		start = t
+	}
+	end := start
+	if s := c.Query("end"); s != "" {
+		t, err := parseTime(s)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid end date"})
+		}
+		end = t
+	}
+
+	availability, err := h.service.Availability(c.Params("id"), start, end)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(availability)
+}
+
+// OnDuty returns the technicians within working hours right now, for the dashboard
+func (h *TechnicianScheduleHandler) OnDuty(c *fiber.Ctx) error {
+	summary, err := h.service.OnDuty(time.Now())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch on-duty technicians",
+		})
+	}
+	return c.JSON(summary)
+}
+
+func (h *TechnicianScheduleHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Technician not found"})
+	case errors.Is(err, services.ErrTechnicianScheduleNotFound),
+		errors.Is(err, services.ErrTimeOffNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidTechnicianSchedule),
+		errors.Is(err, services.ErrInvalidTimeOff),
+		errors.Is(err, services.ErrInvalidAvailabilityRange):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type TenantHandler struct {
+	service  services.TenantService
+	validate *validator.Validate
+}
+
+func NewTenantHandler(service services.TenantService) *TenantHandler {
+	return &TenantHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// List returns the tenants of the platform
+// @Summary List tenants
+// @Tags Admin
+// @Produce json
+// @Success 200 {array} models.Tenant
+// @Router /admin/tenants [get]
+func (h *TenantHandler) List(c *fiber.Ctx) error {
+	tenants, err := h.service.List()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch tenants",
+		})
+	}
+	return c.JSON(tenants)
+}
+
+// Create adds a tenant, served from the subdomain of its slug
+// @Summary Create tenant
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param body body models.CreateTenantRequest true "Name and slug"
+// @Success 201 {object} models.Tenant
+// @Router /admin/tenants [post]
+func (h *TenantHandler) Create(c *fiber.Ctx) error {
+	var req models.CreateTenantRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if err := h.validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	adminID, _ := c.Locals("userId").(string)
+	t, err := h.service.Create(adminID, &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(t)
+}
+
+// Update renames a tenant or (de)activates it
+// @Summary Update tenant
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Tenant ID"
+// @Param body body models.UpdateTenantRequest true "Name and active flag"
+// @Success 200 {object} models.Tenant
+// @Router /admin/tenants/{id} [put]
+func (h *TenantHandler) Update(c *fiber.Ctx) error {
+	var req models.UpdateTenantRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if err := h.validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	adminID, _ := c.Locals("userId").(string)
+	t, err := h.service.Update(adminID, c.Params("id"), &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(t)
+}
+
+// CreateAdmin creates an admin user inside a tenant
+// @Summary Create tenant admin
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Tenant ID"
+// @Param body body models.CreateTenantAdminRequest true "Admin user"
+// @Success 201 {object} models.UserResponse
+// @Router /admin/tenants/{id}/admins [post]
+func (h *TenantHandler) CreateAdmin(c *fiber.Ctx) error {
+	var req models.CreateTenantAdminRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if err := h.validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	adminID, _ := c.Locals("userId").(string)
+	user, err := h.service.CreateAdmin(adminID, c.Params("id"), &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(user.ToResponse())
+}
+
+func (h *TenantHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrTenantNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrTenantSlugTaken),
+		errors.Is(err, services.ErrTenantEmailTaken):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrTenantDefaultLocked):
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
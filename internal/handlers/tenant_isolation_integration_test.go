@@ -0,0 +1,78 @@
+//go:build integration
+
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/handlers"
+	"github.com/shigake/tech-iq-back/internal/middleware"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"github.com/shigake/tech-iq-back/internal/testutil"
+)
+
+// otherTenantAdminID is the (unseeded) user of the tokens of the second tenant
+const otherTenantAdminID = "00000000-0000-4000-8000-000000000502"
+
+func clientsApp() *fiber.App {
+	app := fiber.New()
+	clientHandler := handlers.NewClientHandler(repositories.NewClientRepository(env.DB), nil)
+	clients := app.Group("/clients", middleware.JWTProtected(env.Config.JWTSecret, nil, nil))
+	clients.Get("/", clientHandler.GetAll)
+	clients.Post("/", clientHandler.Create)
+	clients.Get("/:id", clientHandler.GetByID)
+	clients.Put("/:id", clientHandler.Update)
+	clients.Delete("/:id", clientHandler.Delete)
+	return app
+}
+
+func TestClientsAreIsolatedByTenant(t *testing.T) {
+	env.Reset(t)
+	app := clientsApp()
+	tokenA := env.AdminToken(t)
+	tokenB := env.TenantToken(t, testutil.OtherTenantID, otherTenantAdminID, "admin@other.test", "ADMIN")
+
+	var created models.Client
+	testutil.Do(t, app, http.MethodPost, "/clients", map[string]string{"fullName": "Tenant B Client"}, tokenB).
+		Expect(t, http.StatusCreated).JSON(t, &created)
+	if created.TenantID != testutil.OtherTenantID {
+		t.Fatalf("client created by tenant B stamped with tenant %q", created.TenantID)
+	}
+	path := "/clients/" + created.ID
+
+	t.Run("tenant A cannot read it", func(t *testing.T) {
+		testutil.Do(t, app, http.MethodGet, path, nil, tokenA).Expect(t, http.StatusNotFound)
+
+		var page models.PaginatedResponse
+		testutil.Do(t, app, http.MethodGet, "/clients?size=100", nil, tokenA).Expect(t, http.StatusOK).JSON(t, &page)
+		listed, _ := page.Content.([]interface{})
+		for _, item := range listed {
+			if client, _ := item.(map[string]interface{}); client["id"] == created.ID {
+				t.Fatal("tenant A listed tenant B's client")
+			}
+		}
+	})
+
+	t.Run("tenant A cannot update or delete it", func(t *testing.T) {
+		testutil.Do(t, app, http.MethodPut, path, map[string]string{"fullName": "Changed by A"}, tokenA).
+			Expect(t, http.StatusNotFound)
+		testutil.Do(t, app, http.MethodDelete, path, nil, tokenA)
+
+		var current models.Client
+		testutil.Do(t, app, http.MethodGet, path, nil, tokenB).Expect(t, http.StatusOK).JSON(t, &current)
+		if current.FullName != "Tenant B Client" {
+			t.Fatalf("tenant B's client renamed to %q", current.FullName)
+		}
+	})
+
+	t.Run("tenant B sees it", func(t *testing.T) {
+		var page models.PaginatedResponse
+		testutil.Do(t, app, http.MethodGet, "/clients?size=100", nil, tokenB).Expect(t, http.StatusOK).JSON(t, &page)
+		if page.TotalElements != 1 {
+			t.Fatalf("tenant B lists %d clients, want only its own", page.TotalElements)
+		}
+	})
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type TicketBudgetHandler struct {
+	service  services.TicketBudgetService
+	validate *validator.Validate
+}
+
+func NewTicketBudgetHandler(service services.TicketBudgetService) *TicketBudgetHandler {
+	return &TicketBudgetHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// Get returns the budget of a ticket with what has been spent against it
+func (h *TicketBudgetHandler) Get(c *fiber.Ctx) error {
+	status, err := h.service.Get(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(status)
+}
+
+// Set sets the parts and expense budget of a ticket, optionally priced from quote lines
+func (h *TicketBudgetHandler) Set(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+
+	var req models.SetTicketBudgetRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	status, err := h.service.Set(c.Params("id"), &req, userID)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(status)
+}
+
+// Delete removes the budget of a ticket; its caps stop being enforced
+func (h *TicketBudgetHandler) Delete(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+
+	if err := h.service.Delete(c.Params("id"), userID); err != nil {
+		return h.handleError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ListOverrides returns the expense budget overrides of a ticket, newest first
+func (h *TicketBudgetHandler) ListOverrides(c *fiber.Ctx) error {
+	overrides, err := h.service.ListOverrides(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch budget overrides",
+		})
+	}
+	return c.JSON(overrides)
+}
+
+// RequestOverride asks to raise the expense budget of a ticket
+func (h *TicketBudgetHandler) RequestOverride(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+
+	var req models.CreateBudgetOverrideRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	override, err := h.service.RequestOverride(c.Params("id"), &req, userID)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(override)
+}
+
+// ApproveOverride raises the expense budget by the override amount
+func (h *TicketBudgetHandler) ApproveOverride(c *fiber.Ctx) error {
+	return h.decide(c, h.service.ApproveOverride)
+}
+
+// RejectOverride refuses an override; the expense budget is left as is
+func (h *TicketBudgetHandler) RejectOverride(c *fiber.Ctx) error {
+	return h.decide(c, h.service.RejectOverride)
+}
+
+func (h *TicketBudgetHandler) decide(c *fiber.Ctx, decide func(string, *models.BudgetOverrideDecisionRequest, string) (*models.TicketBudgetOverride, error)) error {
+	userID, _ := c.Locals("userId").(string)
+
+	var req models.BudgetOverrideDecisionRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+
+	override, err := decide(c.Params("overrideId"), &req, userID)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(override)
+}
+
+// GetVarianceReport compares budget and actual spending per ticket and per lead technician
+// for the budgeted tickets opened in the period (?from=&to=, default last 30 days)
+func (h *TicketBudgetHandler) GetVarianceReport(c *fiber.Ctx) error {
+	var from, to time.Time
+	if s := c.Query("from"); s != "" {
+		t, err := parseTime(s)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid from date"})
+		}
+		from = t
+	}
+	if s := c.Query("to"); s != "" {
+		t, err := parseTime(s)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid to date"})
+		}
+		to = t
+	}
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "from must be before to"})
+	}
+
+	report, err := h.service.VarianceReport(from, to)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(report)
+}
+
+func (h *TicketBudgetHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrTicketBudgetNotFound),
+		errors.Is(err, services.ErrTicketBudgetTicketNotFound),
+		errors.Is(err, services.ErrBudgetOverrideNotFound),
+		errors.Is(err, services.ErrPriceItemNotFound),
+		errors.Is(err, services.ErrPriceCategoryNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrTicketBudgetEmpty),
+		errors.Is(err, services.ErrTicketBudgetNegative),
+		errors.Is(err, services.ErrBudgetOverrideAmount),
+		errors.Is(err, services.ErrExpenseBudgetNotSet),
+		errors.Is(err, services.ErrPriceInvalidQuantity):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrBudgetOverrideNotPending):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrBudgetOverrideSelfApproval):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
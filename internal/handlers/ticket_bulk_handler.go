@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type TicketBulkHandler struct {
+	service  services.TicketBulkService
+	validate *validator.Validate
+}
+
+func NewTicketBulkHandler(service services.TicketBulkService) *TicketBulkHandler {
+	return &TicketBulkHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// Execute applies a status change, an assignment or a deletion to many tickets, reporting
+// the outcome of each one. Tickets the user lacks the permission on fail individually.
+func (h *TicketBulkHandler) Execute(c *fiber.Ctx) error {
+	var req models.BulkTicketRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	// API keys carry no hierarchy node: their scopes must hold the permission of the action
+	if key, ok := c.Locals("apiKey").(*models.APIKey); ok {
+		if code := services.BulkTicketPermission(req.Action); !key.HasScope(code) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error":      "API key scope required",
+				"permission": code,
+			})
+		}
+	}
+
+	userID, _ := c.Locals("userId").(string)
+	role, _ := c.Locals("userRole").(string)
+
+	result, err := h.service.Execute(c.UserContext(), &req, userID, role)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.JSON(result)
+}
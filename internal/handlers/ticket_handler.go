@@ -1,59 +1,123 @@
 package handlers
 
 import (
+	"errors"
 	"strconv"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/go-playground/validator/v10"
 	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
 	"github.com/shigake/tech-iq-back/internal/services"
+	"gorm.io/gorm"
 )
 
 type TicketHandler struct {
-	service  services.TicketService
-	validate *validator.Validate
+	service      services.TicketService
+	availability services.TechnicianScheduleService
+	validate     *validator.Validate
 }
 
-func NewTicketHandler(service services.TicketService) *TicketHandler {
+func NewTicketHandler(service services.TicketService, availability services.TechnicianScheduleService) *TicketHandler {
 	return &TicketHandler{
-		service:  service,
-		validate: validator.New(),
+		service:      service,
+		availability: availability,
+		validate:     validator.New(),
 	}
 }
 
-// GetAll returns paginated list of tickets with filters
+// GetAll returns paginated list of tickets with filters; ?cursor= pages by keyset
+// (empty for the first page, then nextCursor/prevCursor) instead of page/size
+// @Summary List tickets
+// @Tags Tickets
+// @Produce json
+// @Param page query int false "Page number" default(0)
+// @Param size query int false "Page size"
+// @Param cursor query string false "Keyset cursor (empty for the first page) instead of page"
+// @Param status query string false "Status"
+// @Param type query string false "Type"
+// @Param priority query string false "Priority"
+// @Param source query string false "Source"
+// @Param campaignId query string false "Campaign ID"
+// @Param nodeId query string false "Hierarchy node ID"
+// @Param clientId query string false "Client ID"
+// @Param categoryId query string false "Category ID"
+// @Param technicianId query string false "Technician ID"
+// @Param technicianRole query string false "Role of the technician in the crew"
+// @Param search query string false "Search term"
+// @Param dateFrom query string false "Created from (YYYY-MM-DD)"
+// @Param dateTo query string false "Created until (YYYY-MM-DD)"
+// @Param fields query string false "Comma-separated fields to return"
+// @Security BearerAuth
+// @Success 200 {object} models.PaginatedResponse{content=[]models.TicketDTO}
+// @Failure 400 {object} map[string]string
+// @Router /tickets [get]
 func (h *TicketHandler) GetAll(c *fiber.Ctx) error {
 	page, _ := strconv.Atoi(c.Query("page", "0"))
-	size, _ := strconv.Atoi(c.Query("size", "20"))
+	size := pageSize(c, pagination.Tickets, "size")
 
 	// Parse filters
 	filters := &models.TicketFilters{
 		Status:       c.Query("status"),
+		Type:         c.Query("type"),
 		Priority:     c.Query("priority"),
+		Source:       c.Query("source"),
+		CampaignID:   c.Query("campaignId"),
 		NodeID:       c.Query("nodeId"),
 		ClientID:     c.Query("clientId"),
 		CategoryID:   c.Query("categoryId"),
 		TechnicianID: c.Query("technicianId"),
+		TechnicianRole: c.Query("technicianRole"),
 		Search:       c.Query("search"),
 		DateFrom:     c.Query("dateFrom"),
 		DateTo:       c.Query("dateTo"),
+		Scope:        accessScope(c),
+	}
+
+	cursor, err := queryCursor(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	filters.Cursor = cursor
+
+	fields, err := parseFields(c, "tickets")
+	if err != nil {
+		return invalidFields(c, "tickets", err)
 	}
 
 	// Get user context for role-based filtering
 	userID, _ := c.Locals("userId").(string)
 	userRole, _ := c.Locals("userRole").(string)
 
-	response, err := h.service.GetAllForUser(page, size, filters, userID, userRole)
+	response, err := h.service.WithContext(c.UserContext()).GetAllForUser(page, size, filters, userID, userRole)
+	if errors.Is(err, pagination.ErrInvalidCursor) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch tickets",
 		})
 	}
 
+	if fields != nil {
+		response.Content = selectFields(response.Content, fields)
+	}
 	return c.JSON(response)
 }
 
 // GetByID returns a ticket by ID
+// @Summary Get ticket by ID
+// @Tags Tickets
+// @Produce json
+// @Param id path string true "Ticket ID"
+// @Security BearerAuth
+// @Success 200 {object} models.Ticket
+// @Failure 404 {object} map[string]string
+// @Router /tickets/{id} [get]
 func (h *TicketHandler) GetByID(c *fiber.Ctx) error {
 	id := c.Params("id")
 	if id == "" {
@@ -62,7 +126,7 @@ func (h *TicketHandler) GetByID(c *fiber.Ctx) error {
 		})
 	}
 
-	ticket, err := h.service.GetByID(id)
+	ticket, err := h.service.WithContext(c.UserContext()).WithScope(accessScope(c)).GetByID(id)
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Ticket not found",
@@ -73,6 +137,17 @@ func (h *TicketHandler) GetByID(c *fiber.Ctx) error {
 }
 
 // Create creates a new ticket
+// @Summary Create ticket
+// @Tags Tickets
+// @Accept json
+// @Produce json
+// @Param request body models.CreateTicketRequest true "Ticket data"
+// @Security BearerAuth
+// @Success 201 {object} models.Ticket
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Router /tickets [post]
 func (h *TicketHandler) Create(c *fiber.Ctx) error {
 	var req models.CreateTicketRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -88,7 +163,22 @@ func (h *TicketHandler) Create(c *fiber.Ctx) error {
 		})
 	}
 
-	ticket, err := h.service.Create(&req)
+	// Tickets opened with an API key come from a partner integration unless it says otherwise
+	if _, ok := c.Locals("apiKey").(*models.APIKey); ok && req.Source == "" {
+		req.Source = string(models.TicketSourcePartnerAPI)
+	}
+
+	ticket, err := h.service.WithContext(c.UserContext()).WithScope(accessScope(c)).Create(&req)
+	if errors.Is(err, services.ErrTicketNodeOutOfScope) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if errors.Is(err, services.ErrClientOutOfCoverage) {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
@@ -99,6 +189,19 @@ func (h *TicketHandler) Create(c *fiber.Ctx) error {
 }
 
 // Update updates a ticket
+// @Summary Update ticket
+// @Tags Tickets
+// @Accept json
+// @Produce json
+// @Param id path string true "Ticket ID"
+// @Param request body models.CreateTicketRequest true "Ticket data"
+// @Security BearerAuth
+// @Success 200 {object} models.Ticket
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} models.VersionConflictResponse
+// @Router /tickets/{id} [put]
 func (h *TicketHandler) Update(c *fiber.Ctx) error {
 	id := c.Params("id")
 	if id == "" {
@@ -114,8 +217,22 @@ func (h *TicketHandler) Update(c *fiber.Ctx) error {
 		})
 	}
 
-	ticket, err := h.service.Update(id, &req)
+	ticket, err := h.service.WithContext(c.UserContext()).WithScope(accessScope(c)).Update(id, &req)
 	if err != nil {
+		var conflict *services.VersionConflictError
+		if errors.As(err, &conflict) {
+			return c.Status(fiber.StatusConflict).JSON(conflict.Response())
+		}
+		if errors.Is(err, services.ErrTicketNodeRequired) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if errors.Is(err, services.ErrTicketNodeOutOfScope) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Ticket not found",
 		})
@@ -125,6 +242,13 @@ func (h *TicketHandler) Update(c *fiber.Ctx) error {
 }
 
 // Delete deletes a ticket
+// @Summary Delete ticket
+// @Tags Tickets
+// @Param id path string true "Ticket ID"
+// @Security BearerAuth
+// @Success 204
+// @Failure 404 {object} map[string]string
+// @Router /tickets/{id} [delete]
 func (h *TicketHandler) Delete(c *fiber.Ctx) error {
 	id := c.Params("id")
 	if id == "" {
@@ -133,7 +257,7 @@ func (h *TicketHandler) Delete(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := h.service.Delete(id); err != nil {
+	if err := h.service.WithContext(c.UserContext()).WithScope(accessScope(c)).Delete(id); err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Ticket not found",
 		})
@@ -142,7 +266,18 @@ func (h *TicketHandler) Delete(c *fiber.Ctx) error {
 	return c.SendStatus(fiber.StatusNoContent)
 }
 
-// UpdateStatus updates the ticket status
+// UpdateStatus updates the ticket status; waiting statuses pause the SLA clock
+// @Summary Change ticket status
+// @Tags Tickets
+// @Accept json
+// @Produce json
+// @Param id path string true "Ticket ID"
+// @Param request body models.UpdateStatusRequest true "New status"
+// @Security BearerAuth
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Router /tickets/{id}/status [put]
 func (h *TicketHandler) UpdateStatus(c *fiber.Ctx) error {
 	id := c.Params("id")
 	if id == "" {
@@ -158,7 +293,15 @@ func (h *TicketHandler) UpdateStatus(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := h.service.UpdateStatus(id, req.Status); err != nil {
+	userID, _ := c.Locals("userId").(string)
+
+	if err := h.service.WithContext(c.UserContext()).WithScope(accessScope(c)).ChangeStatus(id, userID, &req); err != nil {
+		if errors.Is(err, services.ErrTransitionNotAllowed) || errors.Is(err, services.ErrTransitionFieldsMissing) ||
+			errors.Is(err, services.ErrChecklistIncomplete) {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
 		})
@@ -167,7 +310,20 @@ func (h *TicketHandler) UpdateStatus(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"message": "Status updated successfully"})
 }
 
-// AssignTechnician assigns technicians to a ticket
+// AssignTechnician assigns technicians to a ticket. Accepts either
+// technicianIds (first one leads) or assignments with explicit roles and payout shares.
+// @Summary Assign technicians to ticket
+// @Tags Tickets
+// @Accept json
+// @Produce json
+// @Param id path string true "Ticket ID"
+// @Param request body models.AssignTechnicianRequest true "Crew"
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]interface{}
+// @Router /tickets/{id}/assign [put]
 func (h *TicketHandler) AssignTechnician(c *fiber.Ctx) error {
 	id := c.Params("id")
 	if id == "" {
@@ -183,15 +339,150 @@ func (h *TicketHandler) AssignTechnician(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := h.service.AssignTechnicians(id, req.TechnicianIDs); err != nil {
+	if len(req.TechnicianIDs) == 0 && len(req.Assignments) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "technicianIds or assignments is required",
+		})
+	}
+
+	inputs := req.ToAssignments()
+
+	// Technicians outside their availability need an explicit override
+	var warnings []models.AvailabilityConflict
+	if h.availability != nil {
+		technicianIDs := make([]string, len(inputs))
+		for i, input := range inputs {
+			technicianIDs[i] = input.TechnicianID
+		}
+		conflicts, err := h.availability.AssignmentConflicts(id, technicianIDs)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Ticket not found",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to check technician availability",
+			})
+		}
+		if len(conflicts) > 0 && !req.Override {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error":       "Technicians are not available for this ticket",
+				"conflicts":   conflicts,
+				"canOverride": true,
+			})
+		}
+		warnings = conflicts
+	}
+
+	assignments, err := h.service.WithContext(c.UserContext()).WithScope(accessScope(c)).SetAssignments(id, inputs)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Ticket not found",
+			})
+		}
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
 
-	return c.JSON(fiber.Map{"message": "Technicians assigned successfully"})
+	response := fiber.Map{
+		"message":     "Technicians assigned successfully",
+		"assignments": assignments,
+	}
+	if len(warnings) > 0 {
+		response["warnings"] = warnings
+	}
+	return c.JSON(response)
+}
+
+// GetTransitions returns the statuses the workflow of the ticket's category allows next,
+// with the fields each change requires
+// @Summary List allowed status transitions
+// @Tags Tickets
+// @Produce json
+// @Param id path string true "Ticket ID"
+// @Security BearerAuth
+// @Success 200 {array} models.AllowedTransition
+// @Failure 404 {object} map[string]string
+// @Router /tickets/{id}/transitions [get]
+func (h *TicketHandler) GetTransitions(c *fiber.Ctx) error {
+	transitions, err := h.service.WithContext(c.UserContext()).WithScope(accessScope(c)).AllowedTransitions(c.Params("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Ticket not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load the ticket workflow",
+		})
+	}
+
+	return c.JSON(transitions)
+}
+
+// GetAssignments returns the ticket crew with roles
+// @Summary List ticket crew
+// @Tags Tickets
+// @Produce json
+// @Param id path string true "Ticket ID"
+// @Security BearerAuth
+// @Success 200 {array} models.TicketTechnician
+// @Failure 404 {object} map[string]string
+// @Router /tickets/{id}/assignments [get]
+func (h *TicketHandler) GetAssignments(c *fiber.Ctx) error {
+	assignments, err := h.service.WithContext(c.UserContext()).WithScope(accessScope(c)).GetAssignments(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Ticket not found",
+		})
+	}
+
+	return c.JSON(assignments)
+}
+
+// GetPayoutSplit previews how a payout amount is split among the ticket crew
+// @Summary Preview payout split
+// @Tags Tickets
+// @Produce json
+// @Param id path string true "Ticket ID"
+// @Param amount query number true "Payout amount"
+// @Security BearerAuth
+// @Success 200 {array} models.TicketPayoutShare
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /tickets/{id}/payout-split [get]
+func (h *TicketHandler) GetPayoutSplit(c *fiber.Ctx) error {
+	amount, err := strconv.ParseFloat(c.Query("amount"), 64)
+	if err != nil || amount <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "amount must be a positive number",
+		})
+	}
+
+	shares, err := h.service.WithContext(c.UserContext()).WithScope(accessScope(c)).GetPayoutSplit(c.Params("id"), amount)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Ticket not found",
+		})
+	}
+
+	return c.JSON(shares)
 }
 
+// SignTicket stores the technician and client signatures
+// @Summary Sign ticket
+// @Tags Tickets
+// @Accept json
+// @Produce json
+// @Param id path string true "Ticket ID"
+// @Param request body models.SignTicketRequest true "Signatures"
+// @Security BearerAuth
+// @Success 200 {object} models.Ticket
+// @Failure 400 {object} map[string]string
+// @Router /tickets/{id}/sign [post]
 func (h *TicketHandler) SignTicket(c *fiber.Ctx) error {
 	id := c.Params("id")
 	if id == "" {
@@ -213,7 +504,7 @@ func (h *TicketHandler) SignTicket(c *fiber.Ctx) error {
 		})
 	}
 
-	ticket, err := h.service.SignTicket(id, &req)
+	ticket, err := h.service.WithContext(c.UserContext()).WithScope(accessScope(c)).SignTicket(id, &req)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
@@ -223,6 +514,15 @@ func (h *TicketHandler) SignTicket(c *fiber.Ctx) error {
 	return c.JSON(ticket)
 }
 
+// DeleteSignature clears the signatures of a ticket
+// @Summary Delete ticket signatures
+// @Tags Tickets
+// @Produce json
+// @Param id path string true "Ticket ID"
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Router /tickets/{id}/sign [delete]
 func (h *TicketHandler) DeleteSignature(c *fiber.Ctx) error {
 	id := c.Params("id")
 	if id == "" {
@@ -231,7 +531,7 @@ func (h *TicketHandler) DeleteSignature(c *fiber.Ctx) error {
 		})
 	}
 
-	ticket, err := h.service.DeleteSignature(id)
+	ticket, err := h.service.WithContext(c.UserContext()).WithScope(accessScope(c)).DeleteSignature(id)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
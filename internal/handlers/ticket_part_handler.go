@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type TicketPartHandler struct {
+	service  services.TicketPartService
+	validate *validator.Validate
+}
+
+func NewTicketPartHandler(service services.TicketPartService) *TicketPartHandler {
+	return &TicketPartHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// List returns the parts used on a ticket and their billable total
+// @Summary List ticket parts
+// @Tags Tickets
+// @Produce json
+// @Param id path string true "Ticket ID"
+// @Success 200 {object} models.TicketPartsSummary
+// @Router /tickets/{id}/parts [get]
+func (h *TicketPartHandler) List(c *fiber.Ctx) error {
+	summary, err := h.service.WithContext(c.UserContext()).List(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(summary)
+}
+
+// Add records a part used on a ticket: the stock leaves the technician's location through
+// a SAIDA_CONSUMO_OS movement and, when billable, the part is charged at ticket closing
+// @Summary Add ticket part
+// @Tags Tickets
+// @Accept json
+// @Produce json
+// @Param id path string true "Ticket ID"
+// @Param body body models.AddTicketPartRequest true "Part"
+// @Success 201 {object} models.TicketPartResponse
+// @Router /tickets/{id}/parts [post]
+func (h *TicketPartHandler) Add(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+
+	var req models.AddTicketPartRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+	part, err := h.service.WithContext(c.UserContext()).Add(c.Params("id"), &req, userID)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(part)
+}
+
+func (h *TicketPartHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrTicketPartTicketNotFound),
+		errors.Is(err, services.ErrItemNotFound),
+		errors.Is(err, services.ErrLocationNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrTicketPartLocationRequired),
+		errors.Is(err, services.ErrTicketPartNegativePrice),
+		errors.Is(err, services.ErrNegativeQuantity),
+		errors.Is(err, services.ErrSerialsRequired),
+		errors.Is(err, services.ErrSerialDuplicate),
+		errors.Is(err, services.ErrSerialNotTracked):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrTicketPartTicketClosed),
+		errors.Is(err, services.ErrTicketPartPriceMissing),
+		errors.Is(err, services.ErrInsufficientStock),
+		errors.Is(err, services.ErrStockReserved),
+		errors.Is(err, services.ErrSerialNotFound),
+		errors.Is(err, services.ErrSerialNotAtLocation):
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/services"
+	"gorm.io/gorm"
+)
+
+type TicketPrintHandler struct {
+	service services.TicketPrintService
+}
+
+func NewTicketPrintHandler(service services.TicketPrintService) *TicketPrintHandler {
+	return &TicketPrintHandler{service: service}
+}
+
+// Print returns the service order for 80mm thermal printers (?format=escpos|html80mm).
+// escpos is raw printer commands to be sent as-is to the printer; html80mm opens the print dialog.
+func (h *TicketPrintHandler) Print(c *fiber.Ctx) error {
+	format := c.Query("format", services.PrintFormatHTML80mm)
+
+	content, contentType, err := h.service.WithContext(c.UserContext()).Print(c.Params("id"), format)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Ticket not found"})
+		case errors.Is(err, services.ErrUnsupportedPrintFormat):
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to render service order"})
+	}
+
+	c.Set("Content-Type", contentType)
+	if format == services.PrintFormatESCPOS {
+		c.Set("Content-Disposition", `attachment; filename="os-`+c.Params("id")+`.bin"`)
+	}
+	return c.Send(content)
+}
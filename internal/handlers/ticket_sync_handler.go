@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type TicketSyncHandler struct {
+	service  services.TicketSyncService
+	validate *validator.Validate
+}
+
+func NewTicketSyncHandler(service services.TicketSyncService) *TicketSyncHandler {
+	return &TicketSyncHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// Sync takes the ticket changes the technician app made offline and returns the outcome
+// of each one (applied, conflict, stale or error) with the tickets changed on the server
+// since the cursor of the previous sync
+// @Summary Sync offline ticket changes
+// @Tags Sync
+// @Accept json
+// @Produce json
+// @Param request body models.TicketSyncRequest true "Changes since the last sync and its cursor"
+// @Success 200 {object} models.TicketSyncResponse
+// @Failure 400 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /sync/tickets [post]
+func (h *TicketSyncHandler) Sync(c *fiber.Ctx) error {
+	var req models.TicketSyncRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	userID, _ := c.Locals("userId").(string)
+	role, _ := c.Locals("userRole").(string)
+
+	response, err := h.service.WithContext(c.UserContext()).Sync(&req, userID, role)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidSyncCursor) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to sync tickets",
+		})
+	}
+	return c.JSON(response)
+}
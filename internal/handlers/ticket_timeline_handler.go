@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+const (
+	timelinePollInterval = 2 * time.Second
+	timelineHeartbeat    = 15 * time.Second
+	// Streams are closed periodically; EventSource reconnects with Last-Event-ID
+	timelineMaxDuration = 30 * time.Minute
+)
+
+type TicketTimelineHandler struct {
+	service       services.TicketTimelineService
+	ticketService services.TicketService
+	validate      *validator.Validate
+}
+
+func NewTicketTimelineHandler(service services.TicketTimelineService, ticketService services.TicketService) *TicketTimelineHandler {
+	return &TicketTimelineHandler{
+		service:       service,
+		ticketService: ticketService,
+		validate:      validator.New(),
+	}
+}
+
+// GetComments returns the comments of a ticket
+func (h *TicketTimelineHandler) GetComments(c *fiber.Ctx) error {
+	comments, err := h.service.GetComments(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Ticket not found",
+		})
+	}
+	return c.JSON(comments)
+}
+
+// AddComment adds a comment to the ticket timeline
+func (h *TicketTimelineHandler) AddComment(c *fiber.Ctx) error {
+	var req models.CreateTicketCommentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	userID, _ := c.Locals("userId").(string)
+
+	comment, err := h.service.AddComment(c.Params("id"), userID, &req)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Ticket not found",
+		})
+	}
+	return c.Status(fiber.StatusCreated).JSON(comment)
+}
+
+// GetEvents returns timeline events after ?after=<eventId> (polling fallback for the stream)
+func (h *TicketTimelineHandler) GetEvents(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if _, err := h.ticketService.WithContext(c.UserContext()).WithScope(accessScope(c)).GetByID(id); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Ticket not found",
+		})
+	}
+
+	after, _ := strconv.ParseUint(c.Query("after", "0"), 10, 64)
+	events, err := h.service.GetEvents(id, uint(after), c.QueryInt("limit", 100))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch ticket events",
+		})
+	}
+	return c.JSON(events)
+}
+
+// Stream pushes new comments, status changes and check-ins of a ticket over SSE.
+// Access follows GET /tickets/:id; the cursor comes from Last-Event-ID (or ?lastEventId),
+// without it only events created after the connection are sent.
+func (h *TicketTimelineHandler) Stream(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if _, err := h.ticketService.WithContext(c.UserContext()).WithScope(accessScope(c)).GetByID(id); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Ticket not found",
+		})
+	}
+
+	lastEventID := c.Get("Last-Event-ID", c.Query("lastEventId"))
+	var cursor uint
+	if lastEventID != "" {
+		parsed, err := strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid Last-Event-ID",
+			})
+		}
+		cursor = uint(parsed)
+	} else {
+		latest, err := h.service.LatestEventID(id)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to open ticket stream",
+			})
+		}
+		cursor = latest
+	}
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		fmt.Fprintf(w, "retry: %d\n\n", timelinePollInterval.Milliseconds())
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		deadline := time.Now().Add(timelineMaxDuration)
+		lastWrite := time.Now()
+		for time.Now().Before(deadline) {
+			events, err := h.service.GetEvents(id, cursor, 0)
+			if err != nil {
+				fmt.Fprintf(w, "event: error\ndata: {\"error\":\"failed to load events\"}\n\n")
+				w.Flush()
+				return
+			}
+
+			for _, event := range events {
+				data, _ := json.Marshal(event)
+				fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+				cursor = event.ID
+			}
+			if len(events) == 0 && time.Since(lastWrite) >= timelineHeartbeat {
+				fmt.Fprint(w, ": ping\n\n")
+			}
+			if len(events) > 0 || time.Since(lastWrite) >= timelineHeartbeat {
+				// A failed flush means the client went away
+				if err := w.Flush(); err != nil {
+					return
+				}
+				lastWrite = time.Now()
+			}
+
+			time.Sleep(timelinePollInterval)
+		}
+	})
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/services"
+	"gorm.io/gorm"
+)
+
+type TicketTrackingHandler struct {
+	service  services.TicketTrackingService
+	validate *validator.Validate
+}
+
+func NewTicketTrackingHandler(service services.TicketTrackingService) *TicketTrackingHandler {
+	return &TicketTrackingHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// CreateLink signs a tracking link to send to the client; the body is optional
+func (h *TicketTrackingHandler) CreateLink(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+
+	var req models.CreateTrackingLinkRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	link, err := h.service.WithContext(c.UserContext()).CreateLink(c.Params("id"), userID, &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(link)
+}
+
+// GetTracking returns the ticket behind a tracking link (public, authorized by the token)
+func (h *TicketTrackingHandler) GetTracking(c *fiber.Ctx) error {
+	tracking, err := h.service.WithContext(c.UserContext()).GetTracking(c.Params("token"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(tracking)
+}
+
+// DownloadAttachment serves the metadata-stripped copy of a ticket attachment (public,
+// authorized by the token)
+func (h *TicketTrackingHandler) DownloadAttachment(c *fiber.Ctx) error {
+	path, err := h.service.WithContext(c.UserContext()).AttachmentPath(c.Params("token"), c.Params("fileId"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.SendFile(path)
+}
+
+// AddComment adds a client comment to the ticket timeline
+func (h *TicketTrackingHandler) AddComment(c *fiber.Ctx) error {
+	var req models.TrackingCommentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+
+	event, err := h.service.WithContext(c.UserContext()).AddComment(c.Params("token"), &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(event)
+}
+
+func (h *TicketTrackingHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Ticket not found"})
+	case errors.Is(err, services.ErrTrackingLinkInvalid),
+		errors.Is(err, services.ErrAttachmentNotFound),
+		errors.Is(err, services.ErrAttachmentNoVariant),
+		errors.Is(err, services.ErrAttachmentNotReady),
+		errors.Is(err, services.ErrAttachmentInfected):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrTrackingCommentsClosed),
+		errors.Is(err, services.ErrTrackingTicketCancelled):
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to process tracking request"})
+	}
+}
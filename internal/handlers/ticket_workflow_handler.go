@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type TicketWorkflowHandler struct {
+	service  services.TicketWorkflowService
+	validate *validator.Validate
+}
+
+func NewTicketWorkflowHandler(service services.TicketWorkflowService) *TicketWorkflowHandler {
+	return &TicketWorkflowHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// Get returns the workflow a category follows, or the default workflow without categoryId
+// @Summary Get ticket workflow
+// @Tags Admin
+// @Produce json
+// @Param categoryId query string false "Ticket category ID"
+// @Success 200 {object} models.TicketWorkflow
+// @Router /admin/ticket-workflows [get]
+func (h *TicketWorkflowHandler) Get(c *fiber.Ctx) error {
+	workflow, err := h.service.Get(categoryParam(c))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(workflow)
+}
+
+// Set replaces the transitions of a category, or of the default workflow
+// @Summary Set ticket workflow
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param body body models.SetWorkflowRequest true "Workflow"
+// @Success 200 {object} models.TicketWorkflow
+// @Router /admin/ticket-workflows [put]
+func (h *TicketWorkflowHandler) Set(c *fiber.Ctx) error {
+	var req models.SetWorkflowRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if err := h.validate.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		})
+	}
+	workflow, err := h.service.Set(&req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(workflow)
+}
+
+// Reset drops the transitions of a category, which then follows the default workflow
+// @Summary Reset ticket workflow
+// @Tags Admin
+// @Param categoryId query string false "Ticket category ID"
+// @Success 204
+// @Router /admin/ticket-workflows [delete]
+func (h *TicketWorkflowHandler) Reset(c *fiber.Ctx) error {
+	if err := h.service.Reset(categoryParam(c)); err != nil {
+		return h.handleError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func categoryParam(c *fiber.Ctx) *string {
+	if categoryID := c.Query("categoryId"); categoryID != "" {
+		return &categoryID
+	}
+	return nil
+}
+
+func (h *TicketWorkflowHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrWorkflowCategoryNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrWorkflowDuplicateTransition):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
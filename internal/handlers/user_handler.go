@@ -6,6 +6,7 @@ import (
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
 	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
 	"github.com/shigake/tech-iq-back/internal/repositories"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -51,7 +52,7 @@ func getUserID(c *fiber.Ctx) string {
 // @Param limit query int false "Items per page" default(20)
 // @Param search query string false "Search term"
 // @Success 200 {object} map[string]interface{}
-// @Router /api/v1/users [get]
+// @Router /users [get]
 func (h *UserHandler) GetUsers(c *fiber.Ctx) error {
 	// Check if user is admin
 	userRole := getUserRole(c)
@@ -62,17 +63,14 @@ func (h *UserHandler) GetUsers(c *fiber.Ctx) error {
 	}
 
 	page, _ := strconv.Atoi(c.Query("page", "1"))
-	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	limit := pageSize(c, pagination.Users, "limit")
 	search := c.Query("search", "")
 
 	if page < 1 {
 		page = 1
 	}
-	if limit < 1 || limit > 100 {
-		limit = 20
-	}
 
-	users, total, err := h.repo.GetAllPaginated(page, limit, search)
+	users, total, err := h.repo.WithContext(c.UserContext()).GetAllPaginated(page, limit, search)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch users",
@@ -86,11 +84,12 @@ func (h *UserHandler) GetUsers(c *fiber.Ctx) error {
 	}
 
 	return c.JSON(fiber.Map{
-		"data":  userResponses,
-		"total": total,
-		"page":  page,
-		"limit": limit,
-		"pages": (total + int64(limit) - 1) / int64(limit),
+		"data":    userResponses,
+		"total":   total,
+		"page":    page,
+		"limit":   limit,
+		"pages":   pagination.TotalPages(total, limit),
+		"hasNext": pagination.HasNext((page-1)*limit, limit, total),
 	})
 }
 
@@ -101,7 +100,7 @@ func (h *UserHandler) GetUsers(c *fiber.Ctx) error {
 // @Produce json
 // @Param id path string true "User ID"
 // @Success 200 {object} models.UserResponse
-// @Router /api/v1/users/{id} [get]
+// @Router /users/{id} [get]
 func (h *UserHandler) GetUser(c *fiber.Ctx) error {
 	userRole := getUserRole(c)
 	currentUserID := getUserID(c)
@@ -114,7 +113,7 @@ func (h *UserHandler) GetUser(c *fiber.Ctx) error {
 		})
 	}
 
-	user, err := h.repo.FindByID(requestedID)
+	user, err := h.repo.WithContext(c.UserContext()).FindByID(requestedID)
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "User not found",
@@ -132,7 +131,7 @@ func (h *UserHandler) GetUser(c *fiber.Ctx) error {
 // @Produce json
 // @Param request body models.CreateUserRequest true "User data"
 // @Success 201 {object} models.UserResponse
-// @Router /api/v1/users [post]
+// @Router /users [post]
 func (h *UserHandler) CreateUser(c *fiber.Ctx) error {
 	// Check if user is admin
 	userRole := getUserRole(c)
@@ -156,7 +155,7 @@ func (h *UserHandler) CreateUser(c *fiber.Ctx) error {
 		})
 	}
 
-	// Check if email already exists
+	// Check if email already exists, in any tenant: it is the login
 	existingUser, _ := h.repo.FindByEmail(req.Email)
 	if existingUser != nil {
 		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
@@ -181,7 +180,7 @@ func (h *UserHandler) CreateUser(c *fiber.Ctx) error {
 		Active:    true,
 	}
 
-	if err := h.repo.Create(user); err != nil {
+	if err := h.repo.WithContext(c.UserContext()).Create(user); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create user",
 		})
@@ -199,7 +198,7 @@ func (h *UserHandler) CreateUser(c *fiber.Ctx) error {
 // @Param id path string true "User ID"
 // @Param request body models.UpdateUserRequest true "User data"
 // @Success 200 {object} models.UserResponse
-// @Router /api/v1/users/{id} [put]
+// @Router /users/{id} [put]
 func (h *UserHandler) UpdateUser(c *fiber.Ctx) error {
 	userRole := getUserRole(c)
 	currentUserID := getUserID(c)
@@ -226,7 +225,7 @@ func (h *UserHandler) UpdateUser(c *fiber.Ctx) error {
 		})
 	}
 
-	user, err := h.repo.FindByID(targetID)
+	user, err := h.repo.WithContext(c.UserContext()).FindByID(targetID)
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "User not found",
@@ -241,7 +240,7 @@ func (h *UserHandler) UpdateUser(c *fiber.Ctx) error {
 
 	// Update fields if provided
 	if req.Email != "" && req.Email != user.Email {
-		// Check if email is taken
+		// Check if email is taken, in any tenant: it is the login
 		existing, _ := h.repo.FindByEmail(req.Email)
 		if existing != nil && existing.ID != user.ID {
 			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
@@ -264,7 +263,7 @@ func (h *UserHandler) UpdateUser(c *fiber.Ctx) error {
 		user.Active = *req.Active
 	}
 
-	if err := h.repo.Update(user); err != nil {
+	if err := h.repo.WithContext(c.UserContext()).Update(user); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update user",
 		})
@@ -279,7 +278,7 @@ func (h *UserHandler) UpdateUser(c *fiber.Ctx) error {
 // @Security BearerAuth
 // @Param id path string true "User ID"
 // @Success 204
-// @Router /api/v1/users/{id} [delete]
+// @Router /users/{id} [delete]
 func (h *UserHandler) DeleteUser(c *fiber.Ctx) error {
 	userRole := getUserRole(c)
 	if userRole != "ADMIN" {
@@ -298,7 +297,7 @@ func (h *UserHandler) DeleteUser(c *fiber.Ctx) error {
 		})
 	}
 
-	user, err := h.repo.FindByID(targetID)
+	user, err := h.repo.WithContext(c.UserContext()).FindByID(targetID)
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "User not found",
@@ -307,7 +306,7 @@ func (h *UserHandler) DeleteUser(c *fiber.Ctx) error {
 
 	// Don't allow deleting the last admin
 	if user.Role == "ADMIN" {
-		count, _ := h.repo.CountByRole("ADMIN")
+		count, _ := h.repo.WithContext(c.UserContext()).CountByRole("ADMIN")
 		if count <= 1 {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"error": "Cannot delete the last admin user",
@@ -315,7 +314,7 @@ func (h *UserHandler) DeleteUser(c *fiber.Ctx) error {
 		}
 	}
 
-	if err := h.repo.Delete(targetID); err != nil {
+	if err := h.repo.WithContext(c.UserContext()).Delete(targetID); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to delete user",
 		})
@@ -333,7 +332,7 @@ func (h *UserHandler) DeleteUser(c *fiber.Ctx) error {
 // @Param id path string true "User ID"
 // @Param request body models.ResetPasswordRequest true "New password"
 // @Success 200 {object} map[string]string
-// @Router /api/v1/users/{id}/reset-password [post]
+// @Router /users/{id}/reset-password [post]
 func (h *UserHandler) ResetPassword(c *fiber.Ctx) error {
 	userRole := getUserRole(c)
 	if userRole != "ADMIN" {
@@ -358,7 +357,7 @@ func (h *UserHandler) ResetPassword(c *fiber.Ctx) error {
 		})
 	}
 
-	user, err := h.repo.FindByID(targetID)
+	user, err := h.repo.WithContext(c.UserContext()).FindByID(targetID)
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "User not found",
@@ -375,7 +374,7 @@ func (h *UserHandler) ResetPassword(c *fiber.Ctx) error {
 
 	user.Password = string(hashedPassword)
 
-	if err := h.repo.Update(user); err != nil {
+	if err := h.repo.WithContext(c.UserContext()).Update(user); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to reset password",
 		})
@@ -392,7 +391,7 @@ func (h *UserHandler) ResetPassword(c *fiber.Ctx) error {
 // @Security BearerAuth
 // @Param id path string true "User ID"
 // @Success 200 {object} models.UserResponse
-// @Router /api/v1/users/{id}/toggle-status [post]
+// @Router /users/{id}/toggle-status [post]
 func (h *UserHandler) ToggleUserStatus(c *fiber.Ctx) error {
 	userRole := getUserRole(c)
 	if userRole != "ADMIN" {
@@ -411,7 +410,7 @@ func (h *UserHandler) ToggleUserStatus(c *fiber.Ctx) error {
 		})
 	}
 
-	user, err := h.repo.FindByID(targetID)
+	user, err := h.repo.WithContext(c.UserContext()).FindByID(targetID)
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "User not found",
@@ -420,7 +419,7 @@ func (h *UserHandler) ToggleUserStatus(c *fiber.Ctx) error {
 
 	user.Active = !user.Active
 
-	if err := h.repo.Update(user); err != nil {
+	if err := h.repo.WithContext(c.UserContext()).Update(user); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update user status",
 		})
@@ -437,7 +436,7 @@ func (h *UserHandler) ToggleUserStatus(c *fiber.Ctx) error {
 // @Param q query string true "Search query"
 // @Param limit query int false "Max results" default(10)
 // @Success 200 {array} models.UserResponse
-// @Router /api/v1/users/search [get]
+// @Router /users/search [get]
 func (h *UserHandler) SearchUsers(c *fiber.Ctx) error {
 	query := c.Query("q", "")
 	limit, _ := strconv.Atoi(c.Query("limit", "10"))
@@ -450,7 +449,7 @@ func (h *UserHandler) SearchUsers(c *fiber.Ctx) error {
 		limit = 10
 	}
 
-	users, _, err := h.repo.GetAllPaginated(1, limit, query)
+	users, _, err := h.repo.WithContext(c.UserContext()).GetAllPaginated(1, limit, query)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to search users",
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+type WebhookHandler struct {
+	service  services.WebhookService
+	validate *validator.Validate
+}
+
+func NewWebhookHandler(service services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		service:  service,
+		validate: validator.New(),
+	}
+}
+
+// List returns the webhook subscriptions
+// @Summary List webhook subscriptions
+// @Tags Admin
+// @Produce json
+// @Success 200 {array} models.WebhookSubscription
+// @Router /admin/webhooks [get]
+func (h *WebhookHandler) List(c *fiber.Ctx) error {
+	subscriptions, err := h.service.WithContext(c.UserContext()).List()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch webhook subscriptions",
+		})
+	}
+	return c.JSON(subscriptions)
+}
+
+// GetByID returns a webhook subscription
+// @Summary Get webhook subscription
+// @Tags Admin
+// @Produce json
+// @Param id path string true "Subscription ID"
+// @Success 200 {object} models.WebhookSubscription
+// @Router /admin/webhooks/{id} [get]
+func (h *WebhookHandler) GetByID(c *fiber.Ctx) error {
+	subscription, err := h.service.WithContext(c.UserContext()).Get(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(subscription)
+}
+
+// Create adds a webhook subscription; its template is checked before saving
+// @Summary Create webhook subscription
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param body body models.WebhookSubscriptionRequest true "Subscription"
+// @Success 201 {object} models.WebhookSubscription
+// @Router /admin/webhooks [post]
+func (h *WebhookHandler) Create(c *fiber.Ctx) error {
+	var req models.WebhookSubscriptionRequest
+	if errResponse := h.parse(c, &req); errResponse != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errResponse)
+	}
+	userID, _ := c.Locals("userId").(string)
+	subscription, err := h.service.WithContext(c.UserContext()).Create(userID, &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(subscription)
+}
+
+// Update replaces a webhook subscription; omit token to keep the current one
+// @Summary Update webhook subscription
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Subscription ID"
+// @Param body body models.WebhookSubscriptionRequest true "Subscription"
+// @Success 200 {object} models.WebhookSubscription
+// @Router /admin/webhooks/{id} [put]
+func (h *WebhookHandler) Update(c *fiber.Ctx) error {
+	var req models.WebhookSubscriptionRequest
+	if errResponse := h.parse(c, &req); errResponse != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errResponse)
+	}
+	subscription, err := h.service.WithContext(c.UserContext()).Update(c.Params("id"), &req)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(subscription)
+}
+
+// Delete removes a webhook subscription
+// @Summary Delete webhook subscription
+// @Tags Admin
+// @Param id path string true "Subscription ID"
+// @Success 204
+// @Router /admin/webhooks/{id} [delete]
+func (h *WebhookHandler) Delete(c *fiber.Ctx) error {
+	if err := h.service.WithContext(c.UserContext()).Delete(c.Params("id")); err != nil {
+		return h.handleError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ValidateTemplate renders a template with a sample payload
+// @Summary Validate webhook template
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param body body models.WebhookTemplateRequest true "Template"
+// @Success 200 {object} models.WebhookTemplatePreview
+// @Router /admin/webhooks/validate-template [post]
+func (h *WebhookHandler) ValidateTemplate(c *fiber.Ctx) error {
+	var req models.WebhookTemplateRequest
+	if errResponse := h.parse(c, &req); errResponse != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errResponse)
+	}
+	return c.JSON(h.service.ValidateTemplate(&req))
+}
+
+// TestFire posts a sample payload to the subscription and reports the receiver's answer
+// @Summary Test-fire webhook
+// @Tags Admin
+// @Produce json
+// @Param id path string true "Subscription ID"
+// @Success 200 {object} models.WebhookTestResult
+// @Router /admin/webhooks/{id}/test [post]
+func (h *WebhookHandler) TestFire(c *fiber.Ctx) error {
+	result, err := h.service.WithContext(c.UserContext()).TestFire(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(result)
+}
+
+// RotateSecret replaces the signing secret of the subscription; the response is the only
+// time the new secret is shown
+// @Summary Rotate webhook secret
+// @Tags Admin
+// @Produce json
+// @Param id path string true "Subscription ID"
+// @Success 200 {object} models.WebhookSubscription
+// @Router /admin/webhooks/{id}/rotate-secret [post]
+func (h *WebhookHandler) RotateSecret(c *fiber.Ctx) error {
+	subscription, err := h.service.WithContext(c.UserContext()).RotateSecret(c.Params("id"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(subscription)
+}
+
+// ListDeliveries returns the delivery log, newest first, of a subscription or of all
+// @Summary List webhook deliveries
+// @Tags Admin
+// @Produce json
+// @Param id path string false "Subscription ID"
+// @Param event query string false "Event"
+// @Param status query string false "PENDING, SUCCEEDED or FAILED"
+// @Param page query int false "Page (0-based)"
+// @Param size query int false "Page size"
+// @Success 200 {object} models.PaginatedResponse
+// @Router /admin/webhook-deliveries [get]
+func (h *WebhookHandler) ListDeliveries(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 0)
+	size := pageSize(c, pagination.Default, "size")
+	if page < 0 {
+		page = 0
+	}
+	filter := models.WebhookDeliveryFilter{
+		SubscriptionID: c.Params("id", c.Query("subscriptionId")),
+		Event:          c.Query("event"),
+		Status:         strings.ToUpper(c.Query("status")),
+	}
+
+	deliveries, err := h.service.WithContext(c.UserContext()).ListDeliveries(filter, page, size)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(deliveries)
+}
+
+// GetDelivery returns a delivery with the payload sent and the receiver's last answer
+// @Summary Get webhook delivery
+// @Tags Admin
+// @Produce json
+// @Param deliveryId path string true "Delivery ID"
+// @Success 200 {object} models.WebhookDelivery
+// @Router /admin/webhook-deliveries/{deliveryId} [get]
+func (h *WebhookHandler) GetDelivery(c *fiber.Ctx) error {
+	delivery, err := h.service.WithContext(c.UserContext()).GetDelivery(c.Params("deliveryId"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(delivery)
+}
+
+// Redeliver queues the delivery again
+// @Summary Redeliver webhook delivery
+// @Tags Admin
+// @Produce json
+// @Param deliveryId path string true "Delivery ID"
+// @Success 200 {object} models.WebhookDelivery
+// @Router /admin/webhook-deliveries/{deliveryId}/redeliver [post]
+func (h *WebhookHandler) Redeliver(c *fiber.Ctx) error {
+	delivery, err := h.service.WithContext(c.UserContext()).Redeliver(c.Params("deliveryId"))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+	return c.JSON(delivery)
+}
+
+// parse reads and validates the body, returning the error response (nil when valid)
+func (h *WebhookHandler) parse(c *fiber.Ctx, req interface{}) fiber.Map {
+	if err := c.BodyParser(req); err != nil {
+		return fiber.Map{"error": "Invalid request body"}
+	}
+	if err := h.validate.Struct(req); err != nil {
+		return fiber.Map{
+			"error":   "Validation failed",
+			"details": formatValidationErrors(err),
+		}
+	}
+	return nil
+}
+
+func (h *WebhookHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrWebhookNotFound), errors.Is(err, services.ErrWebhookDeliveryNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrWebhookTemplateInvalid):
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
@@ -0,0 +1,67 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// slowQueryThreshold is the duration above which a query is logged as slow
+const slowQueryThreshold = 200 * time.Millisecond
+
+// gormLogger writes the GORM logs through slog. Queries run with the request context
+// (db.WithContext) are tagged with its request ID.
+type gormLogger struct {
+	level gormlogger.LogLevel
+}
+
+func NewGormLogger(level gormlogger.LogLevel) gormlogger.Interface {
+	return &gormLogger{level: level}
+}
+
+func (l *gormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	return &gormLogger{level: level}
+}
+
+func (l *gormLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= gormlogger.Info {
+		slog.InfoContext(ctx, fmt.Sprintf(msg, data...))
+	}
+}
+
+func (l *gormLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= gormlogger.Warn {
+		slog.WarnContext(ctx, fmt.Sprintf(msg, data...))
+	}
+}
+
+func (l *gormLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= gormlogger.Error {
+		slog.ErrorContext(ctx, fmt.Sprintf(msg, data...))
+	}
+}
+
+// Trace logs failed queries, slow queries and, at Info level, every query. Not found is
+// an expected outcome and is not logged as a failure.
+func (l *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= gormlogger.Silent {
+		return
+	}
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && l.level >= gormlogger.Error && !errors.Is(err, gorm.ErrRecordNotFound):
+		sql, rows := fc()
+		slog.ErrorContext(ctx, "Query failed", "error", err, "sql", sql, "rows", rows, "elapsed_ms", elapsed.Milliseconds())
+	case elapsed > slowQueryThreshold && l.level >= gormlogger.Warn:
+		sql, rows := fc()
+		slog.WarnContext(ctx, "Slow query", "sql", sql, "rows", rows, "elapsed_ms", elapsed.Milliseconds())
+	case l.level >= gormlogger.Info:
+		sql, rows := fc()
+		slog.DebugContext(ctx, "Query", "sql", sql, "rows", rows, "elapsed_ms", elapsed.Milliseconds())
+	}
+}
@@ -1,15 +1,39 @@
 package middleware
 
 import (
+	"errors"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/tenant"
 )
 
-func JWTProtected(secret string) fiber.Handler {
+// SessionChecker tells whether the session an access token was issued for was revoked;
+// the request is rejected when it can't tell
+type SessionChecker interface {
+	IsSessionRevoked(sessionID string) (bool, error)
+}
+
+// APIKeyAuthenticator resolves the key of an X-API-Key header
+type APIKeyAuthenticator interface {
+	Authenticate(rawKey, ipAddress string) (*models.APIKey, error)
+}
+
+// apiKeyPaths are the only routes machine integrations can call; within them the scopes
+// of the key are checked by Permissions.Require
+var apiKeyPaths = []string{"/api/v1/tickets", "/api/v1/stock"}
+
+// JWTProtected validates the access token. Tokens carrying a session ID (sid) are refused
+// once their session is revoked; sessions may be nil to skip that check. Requests without
+// a token may authenticate with an X-API-Key header instead, when apiKeys is set.
+func JWTProtected(secret string, sessions SessionChecker, apiKeys APIKeyAuthenticator) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		authHeader := c.Get("Authorization")
+		if authHeader == "" && apiKeys != nil && c.Get("X-API-Key") != "" {
+			return apiKeyAuth(c, apiKeys)
+		}
 		if authHeader == "" {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 				"error": "Missing authorization header",
@@ -22,28 +46,47 @@ func JWTProtected(secret string) fiber.Handler {
 			tokenString = strings.TrimPrefix(authHeader, "Bearer ")
 		}
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fiber.NewError(fiber.StatusUnauthorized, "Invalid signing method")
-			}
-			return []byte(secret), nil
-		})
-
-		if err != nil || !token.Valid {
+		claims, err := ParseAccessToken(secret, tokenString)
+		if errors.Is(err, errInvalidClaims) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Invalid token claims",
+			})
+		}
+		if err != nil {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 				"error": "Invalid or expired token",
 			})
 		}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Invalid token claims",
+		sessionID, _ := claims["sid"].(string)
+		if sessionID != "" && sessions != nil {
+			revoked, err := sessions.IsSessionRevoked(sessionID)
+			if err != nil {
+				return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+					"error": "Session check unavailable",
+				})
+			}
+			if revoked {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+					"error": "Session has been revoked",
+				})
+			}
+		}
+
+		// Tokens issued before multi-tenancy carry no tenant and belong to the default one
+		tenantID, _ := claims["tenantId"].(string)
+		if tenantID == "" {
+			tenantID = tenant.DefaultID
+		}
+		if !bindTenant(c, tenantID) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Token was not issued for this tenant",
 			})
 		}
 
 		// Store user info in context for later use
 		c.Locals("userId", claims["userId"])
+		c.Locals("sessionId", sessionID)
 		c.Locals("email", claims["email"])
 		c.Locals("userRole", claims["role"])
 
@@ -51,6 +94,88 @@ func JWTProtected(secret string) fiber.Handler {
 	}
 }
 
+var errInvalidClaims = errors.New("invalid token claims")
+
+// ParseAccessToken validates an access token signed with secret and returns its claims.
+// It is the token check of JWTProtected, shared with the gRPC server.
+func ParseAccessToken(secret, tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fiber.NewError(fiber.StatusUnauthorized, "Invalid signing method")
+		}
+		return []byte(secret), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errInvalidClaims
+	}
+	return claims, nil
+}
+
+func apiKeyAuth(c *fiber.Ctx, apiKeys APIKeyAuthenticator) error {
+	key, err := apiKeys.Authenticate(c.Get("X-API-Key"), c.IP())
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid or expired API key",
+		})
+	}
+
+	allowed := false
+	for _, prefix := range apiKeyPaths {
+		if c.Path() == prefix || strings.HasPrefix(c.Path(), prefix+"/") {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Route not available to API keys",
+		})
+	}
+
+	// Keys act in the tenant of the admin who created them
+	if !bindTenant(c, key.TenantID) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "API key was not issued for this tenant",
+		})
+	}
+
+	c.Locals("userId", key.UserID)
+	c.Locals("userRole", models.RoleIntegration)
+	c.Locals("apiKey", key)
+	return c.Next()
+}
+
+// bindTenant makes tenantID the tenant of the request, unless the subdomain already
+// resolved to another one (see ResolveTenant)
+func bindTenant(c *fiber.Ctx, tenantID string) bool {
+	if resolved, ok := c.Locals("tenantId").(string); ok && resolved != "" && resolved != tenantID {
+		return false
+	}
+	c.Locals("tenantId", tenantID)
+	c.SetUserContext(tenant.WithID(c.UserContext(), tenantID))
+	return true
+}
+
+// PlatformAdmin allows the admins of the default tenant, who manage the other tenants
+func PlatformAdmin() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		tenantID, _ := c.Locals("tenantId").(string)
+		if c.Locals("userRole") != "ADMIN" || tenantID != tenant.DefaultID {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Platform admin access required",
+			})
+		}
+		return c.Next()
+	}
+}
+
 func AdminOnly() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		role := c.Locals("userRole")
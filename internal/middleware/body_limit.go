@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// BodyLimitRule caps the request body of the routes matching Pattern. Patterns use
+// the route syntax: /api/v1/tickets/:id/files, or /api/v1/auth/* for a whole group.
+type BodyLimitRule struct {
+	Pattern  string
+	MaxBytes int
+}
+
+// BodyLimit rejects with 413 the requests whose body exceeds the limit of their
+// endpoint group: the first matching rule, or defaultMax. The app BodyLimit still
+// applies on top of it as the hard cap for every route.
+func BodyLimit(defaultMax int, rules ...BodyLimitRule) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		maxBytes := defaultMax
+		for _, rule := range rules {
+			if matchRoutePattern(rule.Pattern, c.Path()) {
+				maxBytes = rule.MaxBytes
+				break
+			}
+		}
+
+		size := c.Request().Header.ContentLength()
+		if n := len(c.Request().Body()); n > size {
+			size = n
+		}
+		if maxBytes > 0 && size > maxBytes {
+			return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+				"error":    "Request body too large",
+				"details":  fmt.Sprintf("request body of %d bytes exceeds the limit of %d bytes for this endpoint", size, maxBytes),
+				"maxBytes": maxBytes,
+			})
+		}
+
+		return c.Next()
+	}
+}
+
+// matchRoutePattern matches a path against a route pattern; :param matches one
+// segment and a trailing * matches the rest of the path
+func matchRoutePattern(pattern, path string) bool {
+	patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathParts := strings.Split(strings.Trim(path, "/"), "/")
+
+	for i, part := range patternParts {
+		if part == "*" && i == len(patternParts)-1 {
+			return true
+		}
+		if i >= len(pathParts) {
+			return false
+		}
+		if !strings.HasPrefix(part, ":") && part != pathParts[i] {
+			return false
+		}
+	}
+	return len(patternParts) == len(pathParts)
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+// CompressionConfig controls which responses are compressed
+type CompressionConfig struct {
+	// MinSize is the smallest body worth compressing, in bytes
+	MinSize int
+	// ContentTypes are the compressible media types (application/json, text/csv, ...)
+	ContentTypes []string
+}
+
+// Compression compresses responses with brotli or gzip, as negotiated through
+// Accept-Encoding. Streams (SSE), already encoded bodies (downloads), bodies under
+// MinSize and content types outside the list are sent as they are.
+func Compression(config CompressionConfig) fiber.Handler {
+	contentTypes := make(map[string]bool, len(config.ContentTypes))
+	for _, contentType := range config.ContentTypes {
+		if contentType = strings.ToLower(strings.TrimSpace(contentType)); contentType != "" {
+			contentTypes[contentType] = true
+		}
+	}
+
+	return func(c *fiber.Ctx) error {
+		if err := c.Next(); err != nil {
+			return err
+		}
+
+		req, resp := c.Request(), c.Response()
+		if c.Method() == fiber.MethodHead || resp.IsBodyStream() || len(resp.Header.ContentEncoding()) > 0 {
+			return nil
+		}
+		if status := resp.StatusCode(); status == fiber.StatusNoContent || status == fiber.StatusNotModified {
+			return nil
+		}
+
+		mediaType := string(resp.Header.ContentType())
+		if i := strings.IndexByte(mediaType, ';'); i >= 0 {
+			mediaType = mediaType[:i]
+		}
+		if !contentTypes[strings.ToLower(strings.TrimSpace(mediaType))] {
+			return nil
+		}
+
+		body := resp.Body()
+		if len(body) < config.MinSize {
+			return nil
+		}
+
+		var encoded []byte
+		switch {
+		case req.Header.HasAcceptEncoding("br"):
+			encoded = fasthttp.AppendBrotliBytesLevel(nil, body, fasthttp.CompressBrotliDefaultCompression)
+			resp.Header.Set(fiber.HeaderContentEncoding, "br")
+		case req.Header.HasAcceptEncoding("gzip"):
+			encoded = fasthttp.AppendGzipBytesLevel(nil, body, fasthttp.CompressDefaultCompression)
+			resp.Header.Set(fiber.HeaderContentEncoding, "gzip")
+		default:
+			c.Vary(fiber.HeaderAcceptEncoding)
+			return nil
+		}
+
+		resp.SetBodyRaw(encoded)
+		c.Vary(fiber.HeaderAcceptEncoding)
+		return nil
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"runtime/debug"
 	"strings"
 	"time"
@@ -39,7 +40,7 @@ func logError(c *fiber.Ctx, service *services.ErrorLogService, statusCode int, d
 	// Recover from any panic in logging
 	defer func() {
 		if r := recover(); r != nil {
-			fmt.Printf("Error logging failed: %v\n", r)
+			slog.Error("Error logging failed", "panic", r)
 		}
 	}()
 
@@ -90,13 +91,14 @@ func logError(c *fiber.Ctx, service *services.ErrorLogService, statusCode int, d
 		UserEmail:    userEmail,
 		IPAddress:    c.IP(),
 		UserAgent:    c.Get("User-Agent"),
+		RequestID:    requestID(c),
 		StatusCode:   statusCode,
 		Duration:     duration,
 	}
 	
 	// Log to database
 	if err := service.LogError(errorLog); err != nil {
-		fmt.Printf("Failed to log error to database: %v\n", err)
+		slog.Error("Failed to log error to database", "request_id", errorLog.RequestID, "error", err)
 	}
 }
 
@@ -246,12 +248,14 @@ func PanicRecoveryMiddleware(errorLogService *services.ErrorLogService) fiber.Ha
 					UserEmail:    userEmail,
 					IPAddress:    c.IP(),
 					UserAgent:    c.Get("User-Agent"),
+					RequestID:    requestID(c),
 					StatusCode:   500,
 				}
 				
 				// Log to database
+				slog.ErrorContext(c.UserContext(), "Panic recovered", "path", path, "panic", r)
 				if err := errorLogService.LogError(errorLog); err != nil {
-					fmt.Printf("Failed to log panic to database: %v\n", err)
+					slog.ErrorContext(c.UserContext(), "Failed to log panic to database", "error", err)
 				}
 				
 				// Return 500 error
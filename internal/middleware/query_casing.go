@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Query parameter casing modes of an API version
+const (
+	// CasingCompat accepts the legacy snake_case parameters (page_size) next to the
+	// camelCase ones (pageSize), flagging the response as deprecated
+	CasingCompat = "compat"
+	// CasingStrict rejects snake_case parameters with 400
+	CasingStrict = "strict"
+)
+
+// QueryCasingConfig sets the casing mode of each API version
+type QueryCasingConfig struct {
+	// Modes maps an API version (v1, v2, ...) to its mode; versions not listed are strict
+	Modes map[string]string
+}
+
+// QueryCasing unifies the query parameters on camelCase, the casing of the JSON bodies.
+// Handlers read camelCase only: in compat mode each snake_case parameter is copied to
+// its camelCase name (unless both are sent) and the response carries a Deprecation
+// header naming the legacy parameters, so older clients keep working while they migrate.
+func QueryCasing(config QueryCasingConfig) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		version := apiVersion(c.Path())
+		if version == "" {
+			return c.Next()
+		}
+
+		args := c.Context().QueryArgs()
+		var legacy []string
+		args.VisitAll(func(key, _ []byte) {
+			if isSnakeCase(string(key)) {
+				legacy = append(legacy, string(key))
+			}
+		})
+		if len(legacy) == 0 {
+			return c.Next()
+		}
+
+		if config.Modes[version] != CasingCompat {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":  "Query parameters must be camelCase",
+				"params": legacy,
+			})
+		}
+
+		for _, key := range legacy {
+			camel := snakeToCamel(key)
+			if args.Has(camel) {
+				continue
+			}
+			for _, value := range args.PeekMulti(key) {
+				args.Add(camel, string(value))
+			}
+		}
+		c.Set("Deprecation", "true")
+		c.Set("X-Deprecated-Query-Params", strings.Join(legacy, ", "))
+		return c.Next()
+	}
+}
+
+// apiVersion returns the version segment of /api/<version>/... paths
+func apiVersion(path string) string {
+	rest, ok := strings.CutPrefix(path, "/api/")
+	if !ok {
+		return ""
+	}
+	version, _, _ := strings.Cut(rest, "/")
+	if len(version) < 2 || version[0] != 'v' {
+		return ""
+	}
+	return version
+}
+
+// isSnakeCase tells whether the key is lowercase words joined by underscores (page_size)
+func isSnakeCase(key string) bool {
+	if !strings.Contains(key, "_") || key[0] == '_' || key[len(key)-1] == '_' || strings.Contains(key, "__") {
+		return false
+	}
+	for _, r := range key {
+		if r != '_' && (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func snakeToCamel(key string) string {
+	words := strings.Split(key, "_")
+	for i := 1; i < len(words); i++ {
+		words[i] = strings.ToUpper(words[i][:1]) + words[i][1:]
+	}
+	return strings.Join(words, "")
+}
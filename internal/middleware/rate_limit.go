@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"log/slog"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/cache"
+	"github.com/shigake/tech-iq-back/internal/models"
+)
+
+// RateLimitRule limits a route group to Max requests per Window and per caller: the API
+// key, else the authenticated user, else the client IP
+type RateLimitRule struct {
+	Name    string // namespaces the counters, one per group
+	Max     int
+	Window  time.Duration
+	Methods []string // methods counted, every method when empty
+	Message string   // error of the 429 response
+}
+
+// RateLimiter counts requests in Redis sliding windows, shared by every instance. While
+// Redis is unavailable it falls back to per-instance windows in memory.
+type RateLimiter struct {
+	redis   *cache.RedisClient
+	enabled bool
+	local   *memoryWindows
+}
+
+func NewRateLimiter(redisClient *cache.RedisClient, enabled bool) *RateLimiter {
+	return &RateLimiter{
+		redis:   redisClient,
+		enabled: enabled,
+		local:   &memoryWindows{hits: make(map[string][]time.Time)},
+	}
+}
+
+// Limit returns the middleware enforcing the rule. Responses carry X-RateLimit-Limit and
+// X-RateLimit-Remaining; a 429 also carries Retry-After, in seconds.
+func (l *RateLimiter) Limit(rule RateLimitRule) fiber.Handler {
+	methods := make(map[string]bool, len(rule.Methods))
+	for _, method := range rule.Methods {
+		methods[method] = true
+	}
+	message := rule.Message
+	if message == "" {
+		message = "Too many requests. Please try again later."
+	}
+
+	return func(c *fiber.Ctx) error {
+		if !l.enabled || rule.Max <= 0 || (len(methods) > 0 && !methods[c.Method()]) {
+			return c.Next()
+		}
+
+		hit := l.hit("ratelimit:"+rule.Name+":"+rateLimitSubject(c), rule.Max, rule.Window)
+		remaining := rule.Max - hit.Count
+		if remaining < 0 || !hit.Allowed {
+			remaining = 0
+		}
+		c.Set("X-RateLimit-Limit", strconv.Itoa(rule.Max))
+		c.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+
+		if !hit.Allowed {
+			retryAfter := int(math.Ceil(hit.RetryAfter.Seconds()))
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"error":      message,
+				"retryAfter": retryAfter,
+			})
+		}
+		return c.Next()
+	}
+}
+
+func (l *RateLimiter) hit(key string, limit int, window time.Duration) *cache.RateLimitHit {
+	if l.redis != nil {
+		hit, err := l.redis.SlidingWindowHit(key, limit, window)
+		if err == nil {
+			return hit
+		}
+		slog.Debug("Rate limit falling back to memory", "error", err)
+	}
+	return l.local.hit(key, limit, window)
+}
+
+// rateLimitSubject identifies the caller: API key, user, or IP before authentication
+func rateLimitSubject(c *fiber.Ctx) string {
+	if key, ok := c.Locals("apiKey").(*models.APIKey); ok && key != nil {
+		return "key:" + key.ID
+	}
+	if userID, ok := c.Locals("userId").(string); ok && userID != "" {
+		return "user:" + userID
+	}
+	return "ip:" + c.IP()
+}
+
+// memoryWindows is the per-instance fallback of the Redis sliding windows
+type memoryWindows struct {
+	mu        sync.Mutex
+	hits      map[string][]time.Time
+	maxWindow time.Duration
+	calls     int
+}
+
+// memorySweepEvery is how many hits go by between sweeps of the idle keys
+const memorySweepEvery = 1000
+
+func (m *memoryWindows) hit(key string, limit int, window time.Duration) *cache.RateLimitHit {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if window > m.maxWindow {
+		m.maxWindow = window
+	}
+	hits := pruneHits(m.hits[key], now.Add(-window))
+	m.calls++
+	if m.calls%memorySweepEvery == 0 {
+		m.sweep(now)
+	}
+
+	if len(hits) >= limit {
+		m.hits[key] = hits
+		return &cache.RateLimitHit{Count: len(hits), RetryAfter: hits[0].Add(window).Sub(now)}
+	}
+	m.hits[key] = append(hits, now)
+	return &cache.RateLimitHit{Allowed: true, Count: len(hits) + 1}
+}
+
+// sweep drops the keys without a hit in the longest window in use
+func (m *memoryWindows) sweep(now time.Time) {
+	for key, hits := range m.hits {
+		if len(hits) == 0 || now.Sub(hits[len(hits)-1]) > m.maxWindow {
+			delete(m.hits, key)
+		}
+	}
+}
+
+// pruneHits drops the hits before the start of the window; hits are in time order
+func pruneHits(hits []time.Time, start time.Time) []time.Time {
+	i := 0
+	for i < len(hits) && !hits[i].After(start) {
+		i++
+	}
+	return hits[i:]
+}
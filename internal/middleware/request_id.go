@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"log/slog"
+	"regexp"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/google/uuid"
+	"github.com/shigake/tech-iq-back/internal/logger"
+)
+
+// validRequestID bounds the IDs accepted from clients and proxies, they end up in logs
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestID tags every request with a correlation ID: the X-Request-ID sent by the client
+// or a proxy when valid, a new UUID otherwise. The ID is returned in the response header,
+// stored in the "requestId" local and carried by the user context, so the logs, error logs
+// and audit records of the request share it.
+func RequestID() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := utils.CopyString(c.Get(logger.RequestIDHeader))
+		if !validRequestID.MatchString(id) {
+			id = uuid.New().String()
+		}
+		c.Locals("requestId", id)
+		c.Set(logger.RequestIDHeader, id)
+		c.SetUserContext(logger.WithRequestID(c.UserContext(), id))
+		return c.Next()
+	}
+}
+
+// requestID returns the correlation ID set by RequestID
+func requestID(c *fiber.Ctx) string {
+	id, _ := c.Locals("requestId").(string)
+	return id
+}
+
+// AccessLog writes one structured line per request, tagged with its request ID. Like the
+// fiber logger it replaces, errors are handed to the error handler first so the line has
+// the status actually sent.
+func AccessLog() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+
+		if err := c.Next(); err != nil {
+			if err := c.App().Config().ErrorHandler(c, err); err != nil {
+				_ = c.SendStatus(fiber.StatusInternalServerError)
+			}
+		}
+
+		status := c.Response().StatusCode()
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+		slog.Log(c.UserContext(), level, "Request",
+			"method", c.Method(),
+			"path", c.Path(),
+			"status", status,
+			"latency_ms", time.Since(start).Milliseconds(),
+			"ip", c.IP(),
+		)
+		return nil
+	}
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/services"
+)
+
+// RequestMetrics records the latency and payload sizes of every request, grouped by
+// route pattern (/api/v1/tickets/:id) so p95 can be compared with the latency budgets
+func RequestMetrics(service services.RequestMetricsService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+
+		err := c.Next()
+
+		path := c.Route().Path
+		if len(path) > 1 {
+			path = strings.TrimRight(path, "/")
+		}
+		userID, _ := c.Locals("userId").(string)
+
+		service.Record(models.RequestMetric{
+			Path:          utils.CopyString(path),
+			Method:        utils.CopyString(c.Method()),
+			StatusCode:    c.Response().StatusCode(),
+			ResponseTime:  float64(time.Since(start).Microseconds()) / 1000,
+			RequestBytes:  len(c.Request().Body()),
+			ResponseBytes: len(c.Response().Body()),
+			UserID:        userID,
+			IPAddress:     utils.CopyString(c.IP()),
+		})
+
+		return err
+	}
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/tenant"
+)
+
+// TenantResolver finds the tenant served from a subdomain
+type TenantResolver interface {
+	ResolveSlug(slug string) (*models.Tenant, error)
+}
+
+// ResolveTenant binds requests to <slug>.<baseDomain> to the tenant with that slug, so
+// tokens of other tenants are refused there (see JWTProtected). Requests to the base
+// domain itself, or any other host, are bound to the tenant of their token. An empty
+// baseDomain disables subdomain resolution.
+func ResolveTenant(resolver TenantResolver, baseDomain string) fiber.Handler {
+	baseDomain = strings.ToLower(strings.TrimPrefix(baseDomain, "."))
+	return func(c *fiber.Ctx) error {
+		if baseDomain == "" {
+			return c.Next()
+		}
+		slug := tenantSlug(c.Hostname(), baseDomain)
+		if slug == "" {
+			return c.Next()
+		}
+
+		t, err := resolver.ResolveSlug(slug)
+		if err != nil || t == nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Tenant not found",
+			})
+		}
+		if !t.Active {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Tenant is inactive",
+			})
+		}
+
+		c.Locals("tenantId", t.ID)
+		c.SetUserContext(tenant.WithID(c.UserContext(), t.ID))
+		return c.Next()
+	}
+}
+
+// tenantSlug returns the label right under baseDomain ("acme" for acme.example.com and
+// api.acme.example.com), empty when host is not a subdomain of it
+func tenantSlug(host, baseDomain string) string {
+	host = strings.ToLower(host)
+	if i := strings.LastIndexByte(host, ':'); i >= 0 && !strings.Contains(host[i:], "]") {
+		host = host[:i]
+	}
+	rest, ok := strings.CutSuffix(host, "."+baseDomain)
+	if !ok || rest == "" {
+		return ""
+	}
+	if i := strings.LastIndexByte(rest, '.'); i >= 0 {
+		rest = rest[i+1:]
+	}
+	return rest
+}
+
+// TenantID returns the tenant bound to the request
+func TenantID(c *fiber.Ctx) string {
+	if id, ok := c.Locals("tenantId").(string); ok && id != "" {
+		return id
+	}
+	return tenant.DefaultID
+}
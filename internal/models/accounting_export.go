@@ -0,0 +1,225 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Accounting layout formats
+const (
+	AccountingFormatCSV        = "CSV"
+	AccountingFormatFixedWidth = "FIXED_WIDTH"
+)
+
+// Fields an accounting layout column can take from a financial entry
+const (
+	AccountingFieldDate             = "date" // entry date
+	AccountingFieldDueDate          = "due_date"
+	AccountingFieldPaymentDate      = "payment_date"
+	AccountingFieldDebitAccount     = "debit_account"
+	AccountingFieldCreditAccount    = "credit_account"
+	AccountingFieldAmount           = "amount"
+	AccountingFieldHistoryCode      = "history_code"
+	AccountingFieldDescription      = "description"
+	AccountingFieldEntryID          = "entry_id"
+	AccountingFieldType             = "type"
+	AccountingFieldCategory         = "category"
+	AccountingFieldSubcategory      = "subcategory"
+	AccountingFieldStatus           = "status"
+	AccountingFieldCounterpartName  = "counterpart_name"     // client, else supplier
+	AccountingFieldCounterpartDoc   = "counterpart_document" // CNPJ or CPF, digits only
+	AccountingFieldPaymentReference = "payment_reference"
+	AccountingFieldFixed            = "fixed" // the Value of the column, e.g. a record type
+)
+
+// AccountingFields are the fields accepted in layout columns
+var AccountingFields = []string{
+	AccountingFieldDate, AccountingFieldDueDate, AccountingFieldPaymentDate, AccountingFieldDebitAccount,
+	AccountingFieldCreditAccount, AccountingFieldAmount, AccountingFieldHistoryCode, AccountingFieldDescription,
+	AccountingFieldEntryID, AccountingFieldType, AccountingFieldCategory, AccountingFieldSubcategory,
+	AccountingFieldStatus, AccountingFieldCounterpartName, AccountingFieldCounterpartDoc,
+	AccountingFieldPaymentReference, AccountingFieldFixed,
+}
+
+// AccountingColumn is a column of an accounting layout. Width is required by fixed-width
+// layouts, which pad with Pad (default space) on the side opposite to Align and cut
+// longer values.
+type AccountingColumn struct {
+	Field  string `json:"field" validate:"required"`
+	Header string `json:"header,omitempty" validate:"max=60"`
+	Width  int    `json:"width,omitempty" validate:"min=0,max=500"`
+	Align  string `json:"align,omitempty" validate:"omitempty,oneof=left right"`
+	Pad    string `json:"pad,omitempty" validate:"max=1"`
+	Value  string `json:"value,omitempty" validate:"max=500"` // text of fixed columns
+}
+
+// AccountingColumns is the column list of a layout, kept as JSONB
+type AccountingColumns []AccountingColumn
+
+func (c AccountingColumns) Value() (driver.Value, error) {
+	if c == nil {
+		return "[]", nil
+	}
+	return json.Marshal(c)
+}
+
+func (c *AccountingColumns) Scan(value interface{}) error {
+	if value == nil {
+		*c = AccountingColumns{}
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("failed to scan AccountingColumns")
+	}
+	return json.Unmarshal(bytes, c)
+}
+
+// AccountMapping maps the entries of a category (and optionally a subcategory) to the
+// accounts of the chart of accounts of the accountant
+type AccountMapping struct {
+	Type          FinancialEntryType `json:"type" validate:"required,oneof=income expense"`
+	Category      string             `json:"category" validate:"required"`
+	Subcategory   string             `json:"subcategory,omitempty"`
+	DebitAccount  string             `json:"debitAccount" validate:"required,max=30"`
+	CreditAccount string             `json:"creditAccount" validate:"required,max=30"`
+	HistoryCode   string             `json:"historyCode,omitempty" validate:"max=20"`
+}
+
+// AccountMappings is the chart-of-accounts mapping of a layout, kept as JSONB
+type AccountMappings []AccountMapping
+
+func (m AccountMappings) Value() (driver.Value, error) {
+	if m == nil {
+		return "[]", nil
+	}
+	return json.Marshal(m)
+}
+
+func (m *AccountMappings) Scan(value interface{}) error {
+	if value == nil {
+		*m = AccountMappings{}
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("failed to scan AccountMappings")
+	}
+	return json.Unmarshal(bytes, m)
+}
+
+// AccountingLayout is a file layout of the accountant: the columns of each record, how
+// dates and amounts are written and the accounts each category is booked to. Entries of
+// unmapped categories use the default accounts.
+type AccountingLayout struct {
+	ID                   string            `json:"id" gorm:"type:uuid;primaryKey"`
+	TenantID             string            `json:"tenantId" gorm:"type:varchar(36);not null;default:'00000000-0000-0000-0000-000000000001';index"`
+	Name                 string            `json:"name" gorm:"type:varchar(100);not null"`
+	Format               string            `json:"format" gorm:"type:varchar(20);not null"`
+	Delimiter            string            `json:"delimiter" gorm:"type:varchar(1)"` // CSV only
+	IncludeHeader        bool              `json:"includeHeader" gorm:"not null"`
+	DateFormat           string            `json:"dateFormat" gorm:"type:varchar(10);not null"`
+	DecimalSeparator     string            `json:"decimalSeparator" gorm:"type:varchar(1)"` // empty writes amounts in cents
+	Encoding             string            `json:"encoding" gorm:"type:varchar(20);not null"`
+	Columns              AccountingColumns `json:"columns" gorm:"type:jsonb;default:'[]'"`
+	Accounts             AccountMappings   `json:"accounts" gorm:"type:jsonb;default:'[]'"`
+	DefaultDebitAccount  string            `json:"defaultDebitAccount" gorm:"type:varchar(30)"`
+	DefaultCreditAccount string            `json:"defaultCreditAccount" gorm:"type:varchar(30)"`
+	CreatedBy            string            `json:"createdBy" gorm:"type:varchar(36);not null"`
+	CreatedAt            time.Time         `json:"createdAt"`
+	UpdatedAt            time.Time         `json:"updatedAt"`
+}
+
+func (l *AccountingLayout) BeforeCreate(tx *gorm.DB) error {
+	if l.ID == "" {
+		l.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (AccountingLayout) TableName() string {
+	return "accounting_layouts"
+}
+
+// Lifecycle of a financial export: queued through the outbox and generated in the
+// background. A failed attempt keeps it PENDING with the error until the outbox gives up.
+const (
+	FinancialExportPending   = "PENDING"
+	FinancialExportCompleted = "COMPLETED"
+	FinancialExportFailed    = "FAILED" // the layout was deleted before the export ran
+)
+
+// FinancialExport is a file of financial entries generated with an accounting layout
+type FinancialExport struct {
+	ID          string             `json:"id" gorm:"type:uuid;primaryKey"`
+	TenantID    string             `json:"tenantId" gorm:"type:varchar(36);not null;default:'00000000-0000-0000-0000-000000000001';index"`
+	LayoutID    string             `json:"layoutId" gorm:"type:uuid;not null;index"`
+	StartDate   time.Time          `json:"startDate" gorm:"type:date;not null"`
+	EndDate     time.Time          `json:"endDate" gorm:"type:date;not null"`
+	DateField   string             `json:"dateField" gorm:"type:varchar(20);not null"` // entry_date or payment_date
+	Type        FinancialEntryType `json:"type" gorm:"type:varchar(10)"`               // empty for both
+	Status      string             `json:"status" gorm:"type:varchar(20);not null;index"`
+	RowCount    int                `json:"rowCount"`
+	Unmapped    int                `json:"unmapped"` // entries booked to the default accounts
+	FileID      *string            `json:"fileId" gorm:"type:uuid"`
+	Error       string             `json:"error" gorm:"type:text"`
+	RequestedBy string             `json:"requestedBy" gorm:"type:varchar(36);not null;index"`
+	CompletedAt *time.Time         `json:"completedAt"`
+	CreatedAt   time.Time          `json:"createdAt" gorm:"index"`
+	UpdatedAt   time.Time          `json:"updatedAt"`
+
+	Layout *AccountingLayout `json:"layout,omitempty" gorm:"foreignKey:LayoutID"`
+}
+
+func (e *FinancialExport) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (FinancialExport) TableName() string {
+	return "financial_exports"
+}
+
+// FinancialExportPayload is the payload of the financial export topic
+type FinancialExportPayload struct {
+	ExportID string `json:"exportId"`
+}
+
+// =============== DTOs ===============
+
+// SaveAccountingLayoutRequest DTO, for creating and replacing layouts
+type SaveAccountingLayoutRequest struct {
+	Name                 string             `json:"name" validate:"required,max=100"`
+	Format               string             `json:"format" validate:"required,oneof=CSV FIXED_WIDTH"`
+	Delimiter            string             `json:"delimiter" validate:"max=1"`                                                    // default ;
+	IncludeHeader        bool               `json:"includeHeader"`                                                                 // CSV only
+	DateFormat           string             `json:"dateFormat" validate:"omitempty,oneof=DD/MM/YYYY DDMMYYYY YYYY-MM-DD YYYYMMDD"` // default DD/MM/YYYY
+	DecimalSeparator     *string            `json:"decimalSeparator" validate:"omitempty,oneof=, ."`                               // default ","; "" for cents
+	Encoding             string             `json:"encoding" validate:"omitempty,oneof=UTF-8 WINDOWS-1252"`                        // default UTF-8
+	Columns              []AccountingColumn `json:"columns" validate:"required,min=1,max=60,dive"`
+	Accounts             []AccountMapping   `json:"accounts" validate:"max=500,dive"`
+	DefaultDebitAccount  string             `json:"defaultDebitAccount" validate:"max=30"`
+	DefaultCreditAccount string             `json:"defaultCreditAccount" validate:"max=30"`
+}
+
+// CreateFinancialExportRequest DTO
+type CreateFinancialExportRequest struct {
+	LayoutID  string             `json:"layoutId" validate:"required,uuid"`
+	StartDate string             `json:"startDate" validate:"required"` // Format: YYYY-MM-DD
+	EndDate   string             `json:"endDate" validate:"required"`
+	DateField string             `json:"dateField" validate:"omitempty,oneof=entry_date payment_date"` // default entry_date
+	Type      FinancialEntryType `json:"type" validate:"omitempty,oneof=income expense"`
+}
+
+// FinancialExportFilter DTO
+type FinancialExportFilter struct {
+	Status      string
+	RequestedBy string
+}
@@ -1,6 +1,9 @@
 package models
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -19,7 +22,14 @@ type ActivityLog struct {
 	IPAddress   string    `json:"ipAddress" gorm:"type:varchar(45)"`        // IPv4 or IPv6
 	UserAgent   string    `json:"userAgent" gorm:"type:text"`               // Browser/device info
 	Metadata    string    `json:"metadata" gorm:"type:text"`                // JSON string with additional data
+	RequestID   string    `json:"requestId" gorm:"type:varchar(64);index"`  // Correlation ID of the request, not hashed
 	CreatedAt   time.Time `json:"createdAt" gorm:"index"`
+
+	// Hash chain: every record stores the hash of the one before it, so altering or
+	// deleting a record breaks the chain from that point on
+	Sequence *int64 `json:"sequence" gorm:"uniqueIndex"`
+	PrevHash string `json:"prevHash" gorm:"type:varchar(64)"`
+	Hash     string `json:"hash" gorm:"type:varchar(64)"`
 }
 
 func (a *ActivityLog) BeforeCreate(tx *gorm.DB) error {
@@ -29,6 +39,34 @@ func (a *ActivityLog) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+// ComputeHash returns the SHA-256 of the record content, its position in the chain and
+// the hash of the previous record
+func (a *ActivityLog) ComputeHash() string {
+	var sequence int64
+	if a.Sequence != nil {
+		sequence = *a.Sequence
+	}
+	// A JSON array keeps the fields unambiguous whatever they contain
+	content, _ := json.Marshal([]interface{}{
+		sequence, a.PrevHash, a.ID, a.UserID, a.Action, a.Resource, a.ResourceID,
+		a.Description, a.IPAddress, a.UserAgent, a.Metadata, a.CreatedAt.UnixMicro(),
+	})
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Seal links the record after prev (nil for the first record of the chain) and hashes it
+func (a *ActivityLog) Seal(prev *ActivityLog) {
+	sequence := int64(1)
+	a.PrevHash = ""
+	if prev != nil && prev.Sequence != nil {
+		sequence = *prev.Sequence + 1
+		a.PrevHash = prev.Hash
+	}
+	a.Sequence = &sequence
+	a.Hash = a.ComputeHash()
+}
+
 // CreateActivityLogRequest represents the request to create an activity log
 type CreateActivityLogRequest struct {
 	Action      string `json:"action" validate:"required"`
@@ -46,6 +84,7 @@ type ActivityLogFilter struct {
 	Action     string    `json:"action"`
 	Resource   string    `json:"resource"`
 	ResourceID string    `json:"resourceId"`
+	RequestID  string    `json:"requestId"`
 	StartDate  time.Time `json:"startDate"`
 	EndDate    time.Time `json:"endDate"`
 }
@@ -57,4 +96,5 @@ type PaginatedActivityLogs struct {
 	Page       int           `json:"page"`
 	PageSize   int           `json:"pageSize"`
 	TotalPages int           `json:"totalPages"`
+	HasNext    bool          `json:"hasNext"`
 }
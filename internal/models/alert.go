@@ -0,0 +1,132 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Alert modules, used for the topbar badges
+const (
+	AlertModuleSLA       = "SLA"
+	AlertModuleStock     = "STOCK"
+	AlertModuleFinancial = "FINANCIAL"
+	AlertModuleGeo       = "GEO"
+	AlertModuleKPI       = "KPI"
+	AlertModuleSystem    = "SYSTEM"
+)
+
+// Alert types raised by the scanner
+const (
+	AlertTypeSLAAtRisk      = "SLA_AT_RISK" // CRITICAL once breached
+	AlertTypeLowStock       = "LOW_STOCK"
+	AlertTypeOverduePayment = "OVERDUE_PAYMENT"
+	AlertTypeMockedLocation = "MOCKED_LOCATION"
+	AlertTypeKPIBreach      = "KPI_BREACH"
+	AlertTypeCacheDown      = "CACHE_DOWN"
+	AlertTypeJobBacklog     = "JOB_BACKLOG" // ResourceID is the job name
+)
+
+// Alert severities
+const (
+	AlertSeverityInfo     = "INFO"
+	AlertSeverityWarning  = "WARNING"
+	AlertSeverityCritical = "CRITICAL"
+)
+
+// Alert statuses
+const (
+	AlertStatusOpen         = "OPEN"
+	AlertStatusAcknowledged = "ACKNOWLEDGED"
+	AlertStatusResolved     = "RESOLVED"
+)
+
+// Alert is an operational alert. DedupKey identifies the condition (e.g. the ticket at
+// risk): while an alert is unresolved the same condition refreshes it instead of raising
+// a new one.
+type Alert struct {
+	ID           string `json:"id" gorm:"type:uuid;primaryKey"`
+	Module       string `json:"module" gorm:"type:varchar(20);not null;index"`
+	Type         string `json:"type" gorm:"type:varchar(50);not null;index"`
+	Severity     string `json:"severity" gorm:"type:varchar(20);not null;index"`
+	Status       string `json:"status" gorm:"type:varchar(20);not null;default:OPEN;index"`
+	Title        string `json:"title" gorm:"type:varchar(255);not null"`
+	Message      string `json:"message" gorm:"type:text"`
+	ResourceType string `json:"resourceType" gorm:"type:varchar(50)"`
+	ResourceID   string `json:"resourceId" gorm:"type:varchar(36);index"`
+	DedupKey     string `json:"dedupKey" gorm:"type:varchar(255);not null;index"`
+
+	// Ownership
+	OwnerID *string `json:"ownerId" gorm:"type:varchar(36);index"`
+	Owner   *User   `json:"owner,omitempty" gorm:"foreignKey:OwnerID"`
+
+	AcknowledgedBy *string    `json:"acknowledgedBy" gorm:"type:varchar(36)"`
+	AcknowledgedAt *time.Time `json:"acknowledgedAt"`
+	ResolvedBy     *string    `json:"resolvedBy" gorm:"type:varchar(36)"` // nil when resolved automatically
+	ResolvedAt     *time.Time `json:"resolvedAt"`
+	ResolutionNote string     `json:"resolutionNote" gorm:"type:text"`
+
+	LastSeenAt  time.Time `json:"lastSeenAt"`
+	Occurrences int       `json:"occurrences" gorm:"default:1"`
+	CreatedAt   time.Time `json:"createdAt" gorm:"index"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+func (a *Alert) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == "" {
+		a.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (Alert) TableName() string {
+	return "alerts"
+}
+
+// =============== DTOs ===============
+
+// AlertFilters DTO; Status "ACTIVE" means open or acknowledged
+type AlertFilters struct {
+	Module   string
+	Type     string
+	Severity string
+	Status   string
+	OwnerID  string
+	From     *time.Time
+	To       *time.Time
+}
+
+// AssignAlertRequest DTO; an empty owner releases the alert
+type AssignAlertRequest struct {
+	OwnerID string `json:"ownerId"`
+}
+
+// ResolveAlertRequest DTO
+type ResolveAlertRequest struct {
+	Note string `json:"note" validate:"max=2000"`
+}
+
+// AlertBadge is the count of active alerts of a module
+type AlertBadge struct {
+	Module       string `json:"module"`
+	Open         int64  `json:"open"`         // not yet acknowledged
+	Acknowledged int64  `json:"acknowledged"` // acknowledged, not resolved
+	Critical     int64  `json:"critical"`     // active and critical
+}
+
+// AlertBadges DTO for the frontend topbar
+type AlertBadges struct {
+	Total    int64        `json:"total"`    // active alerts
+	Open     int64        `json:"open"`     // not yet acknowledged
+	Critical int64        `json:"critical"` // active and critical
+	Modules  []AlertBadge `json:"modules"`
+}
+
+// AlertScanResult summarizes a scanner run
+type AlertScanResult struct {
+	Raised    int      `json:"raised"`
+	Refreshed int      `json:"refreshed"`
+	Resolved  int      `json:"resolved"`
+	Errors    []string `json:"errors,omitempty"`
+}
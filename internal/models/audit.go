@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// Stores the unified audit trail reads from
+const (
+	AuditSourceActivity  = "activity"  // activity_logs: user actions, hash chained
+	AuditSourceFinancial = "financial" // financial_audit_logs: financial entry changes
+	AuditSourceAccess    = "access"    // access_audit_logs: hierarchy, roles and memberships
+)
+
+// AuditSources lists the valid sources
+var AuditSources = []string{AuditSourceActivity, AuditSourceFinancial, AuditSourceAccess}
+
+// AuditEvent is a record of any of the audit stores in one shape. Actions are upper case
+// (CREATE, UPDATE, LOGIN...); Changes holds the JSON diff of the stores that keep one.
+type AuditEvent struct {
+	Source      string    `json:"source"`
+	ID          string    `json:"id"`
+	UserID      string    `json:"userId"`
+	UserName    string    `json:"userName"`
+	Action      string    `json:"action"`
+	EntityType  string    `json:"entityType"`
+	EntityID    string    `json:"entityId"`
+	Description string    `json:"description"`
+	Changes     string    `json:"changes,omitempty"`
+	IPAddress   string    `json:"ipAddress"`
+	UserAgent   string    `json:"userAgent"`
+	RequestID   string    `json:"requestId"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// AuditFilter narrows the unified audit trail; empty fields match everything
+type AuditFilter struct {
+	Source     string
+	EntityType string // case insensitive
+	EntityID   string
+	UserID     string
+	Action     string // case insensitive
+	From       time.Time
+	To         time.Time
+}
+
+// PaginatedAuditEvents DTO
+type PaginatedAuditEvents struct {
+	Data       []AuditEvent `json:"data"`
+	Total      int64        `json:"total"`
+	Page       int          `json:"page"`
+	PageSize   int          `json:"pageSize"`
+	TotalPages int          `json:"totalPages"`
+	HasNext    bool         `json:"hasNext"`
+	NextCursor string       `json:"nextCursor,omitempty"`
+	PrevCursor string       `json:"prevCursor,omitempty"`
+}
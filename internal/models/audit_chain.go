@@ -0,0 +1,113 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Reasons a record breaks the activity log hash chain
+const (
+	AuditBreakHashMismatch  = "HASH_MISMATCH"  // the record was altered after being written
+	AuditBreakLinkMismatch  = "LINK_MISMATCH"  // the previous hash does not match the record before it
+	AuditBreakMissingRecord = "MISSING_RECORD" // a gap in the sequence, records were deleted
+	AuditBreakUnsealed      = "UNSEALED"       // the record is not part of the chain
+)
+
+// AuditChainBreak is a point where the hash chain does not hold
+type AuditChainBreak struct {
+	Sequence int64  `json:"sequence"`
+	LogID    string `json:"logId,omitempty"`
+	Reason   string `json:"reason"`
+}
+
+// AuditChainVerification is the result of checking the chain of a period
+type AuditChainVerification struct {
+	From          time.Time         `json:"from"`
+	To            time.Time         `json:"to"`
+	Records       int64             `json:"records"`
+	FirstSequence int64             `json:"firstSequence"`
+	LastSequence  int64             `json:"lastSequence"`
+	LastHash      string            `json:"lastHash"`
+	Valid         bool              `json:"valid"`
+	Breaks        []AuditChainBreak `json:"breaks"`
+	// Only the first breaks are listed
+	BreaksTruncated bool      `json:"breaksTruncated"`
+	VerifiedAt      time.Time `json:"verifiedAt"`
+}
+
+// AuditExportBundle is a signed export of the activity log of a period, written to disk so
+// compliance can later prove the records were not altered
+type AuditExportBundle struct {
+	ID            string    `json:"id" gorm:"type:uuid;primaryKey"`
+	PeriodStart   time.Time `json:"periodStart" gorm:"not null;uniqueIndex"`
+	PeriodEnd     time.Time `json:"periodEnd" gorm:"not null"`
+	Records       int64     `json:"records"`
+	FirstSequence int64     `json:"firstSequence"`
+	LastSequence  int64     `json:"lastSequence"`
+	FirstPrevHash string    `json:"firstPrevHash" gorm:"type:varchar(64)"`
+	LastHash      string    `json:"lastHash" gorm:"type:varchar(64)"`
+	RecordsHash   string    `json:"recordsHash" gorm:"type:varchar(64);not null"`
+	Signature     string    `json:"signature" gorm:"type:varchar(128);not null"`
+	PublicKey     string    `json:"publicKey" gorm:"type:varchar(64);not null"`
+	FilePath      string    `json:"-" gorm:"type:varchar(500);not null"`
+	FileSize      int64     `json:"fileSize"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+func (b *AuditExportBundle) BeforeCreate(tx *gorm.DB) error {
+	if b.ID == "" {
+		b.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (AuditExportBundle) TableName() string {
+	return "audit_export_bundles"
+}
+
+// AuditBundleManifest describes the records of a bundle; it is what the signature covers.
+// RecordsHash is the SHA-256 of the records array exactly as written in the file.
+type AuditBundleManifest struct {
+	Version       int       `json:"version"`
+	PeriodStart   time.Time `json:"periodStart"`
+	PeriodEnd     time.Time `json:"periodEnd"`
+	Records       int64     `json:"records"`
+	FirstSequence int64     `json:"firstSequence"`
+	LastSequence  int64     `json:"lastSequence"`
+	FirstPrevHash string    `json:"firstPrevHash"`
+	LastHash      string    `json:"lastHash"`
+	RecordsHash   string    `json:"recordsHash"`
+	HashAlgorithm string    `json:"hashAlgorithm"`
+	SignAlgorithm string    `json:"signAlgorithm"`
+	PublicKey     string    `json:"publicKey"`
+	GeneratedAt   time.Time `json:"generatedAt"`
+}
+
+// AuditBundleFile is the layout of a bundle on disk. Manifest and Records are kept raw so
+// the signed and hashed bytes can be checked as they are.
+type AuditBundleFile struct {
+	Manifest  json.RawMessage `json:"manifest"`
+	Signature string          `json:"signature"` // base64 Ed25519 signature of the manifest bytes
+	Records   json.RawMessage `json:"records"`
+}
+
+// AuditBundleVerification is the result of checking a bundle against its signature and
+// against the records still in the database
+type AuditBundleVerification struct {
+	BundleID        string            `json:"bundleId"`
+	SignatureValid  bool              `json:"signatureValid"`
+	ContentValid    bool              `json:"contentValid"` // records match the signed manifest
+	ChainValid      bool              `json:"chainValid"`   // records chain among themselves
+	MatchesDatabase bool              `json:"matchesDatabase"`
+	Valid           bool              `json:"valid"`
+	Breaks          []AuditChainBreak `json:"breaks"`
+	VerifiedAt      time.Time         `json:"verifiedAt"`
+}
+
+// AuditExportResult summarizes an export run
+type AuditExportResult struct {
+	Bundles []AuditExportBundle `json:"bundles"`
+}
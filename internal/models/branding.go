@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// NodeBranding customizes the documents generated for tickets of a top-level node
+// (branch/company): printed service orders show its name, logo, colors, footer and
+// numbering prefix instead of the global company name
+type NodeBranding struct {
+	NodeID             uint      `json:"nodeId" gorm:"primaryKey;autoIncrement:false"`
+	DisplayName        string    `json:"displayName" gorm:"type:varchar(255)"`
+	LogoFileID         *string   `json:"logoFileId" gorm:"type:uuid"` // READY stored file of owner NODE_BRANDING
+	PrimaryColor       string    `json:"primaryColor" gorm:"type:varchar(7)"`
+	AccentColor        string    `json:"accentColor" gorm:"type:varchar(7)"`
+	FooterText         string    `json:"footerText" gorm:"type:text"`
+	ServiceOrderPrefix string    `json:"serviceOrderPrefix" gorm:"type:varchar(20)"` // printed before the OS number
+	UpdatedBy          string    `json:"updatedBy" gorm:"type:varchar(36)"`
+	CreatedAt          time.Time `json:"createdAt"`
+	UpdatedAt          time.Time `json:"updatedAt"`
+
+	Node *Node `json:"node,omitempty" gorm:"foreignKey:NodeID"`
+}
+
+func (NodeBranding) TableName() string {
+	return "node_brandings"
+}
+
+// =============== DTOs ===============
+
+// UpsertNodeBrandingRequest DTO (replaces the branding of the node)
+type UpsertNodeBrandingRequest struct {
+	DisplayName        string `json:"displayName" validate:"max=255"`
+	LogoFileID         string `json:"logoFileId" validate:"omitempty,uuid"`
+	PrimaryColor       string `json:"primaryColor" validate:"omitempty,hexcolor,len=7"`
+	AccentColor        string `json:"accentColor" validate:"omitempty,hexcolor,len=7"`
+	FooterText         string `json:"footerText" validate:"max=1000"`
+	ServiceOrderPrefix string `json:"serviceOrderPrefix" validate:"max=20"`
+}
+
+// DocumentBranding is the branding resolved for a document: the branding of the owning
+// top-level node, or the defaults when it has none
+type DocumentBranding struct {
+	NodeID             *uint  `json:"nodeId"`
+	DisplayName        string `json:"displayName"`
+	PrimaryColor       string `json:"primaryColor"`
+	AccentColor        string `json:"accentColor"`
+	FooterText         string `json:"footerText"`
+	ServiceOrderPrefix string `json:"serviceOrderPrefix"`
+
+	Logo            []byte `json:"-"`
+	LogoContentType string `json:"-"`
+}
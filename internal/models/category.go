@@ -18,6 +18,7 @@ const (
 
 type Category struct {
 	ID          string         `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	TenantID    string         `json:"tenantId" gorm:"type:varchar(36);not null;default:'00000000-0000-0000-0000-000000000001';index"`
 	Name        string         `json:"name" gorm:"not null" validate:"required"`
 	Description string         `json:"description"`
 	Color       string         `json:"color"`
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// Chat providers
+const (
+	ChatProviderSlack = "SLACK"
+	ChatProviderTeams = "TEAMS"
+)
+
+// ChatChannel posts operational alerts and the daily digest to a Slack or Microsoft Teams
+// channel, through an incoming webhook or, for Slack, a bot token. A channel of a node
+// only gets the alerts of the tickets in that node's subtree; channels without a node get
+// every alert. AlertTypes and MinSeverity route which alerts each channel receives.
+type ChatChannel struct {
+	ID           string         `json:"id" gorm:"type:uuid;primaryKey"`
+	Name         string         `json:"name" gorm:"type:varchar(100);not null"`
+	Provider     string         `json:"provider" gorm:"type:varchar(10);not null"`
+	NodeID       *uint          `json:"nodeId" gorm:"index"`
+	WebhookURL   string         `json:"-" gorm:"type:varchar(500)"`
+	BotToken     string         `json:"-" gorm:"type:varchar(255)"`
+	SlackChannel string         `json:"slackChannel" gorm:"type:varchar(100)"` // channel ID for the bot token
+	AlertTypes   pq.StringArray `json:"alertTypes" gorm:"type:text[]"`         // empty = every type
+	MinSeverity  string         `json:"minSeverity" gorm:"type:varchar(20);not null;default:WARNING"`
+	DailyDigest  bool           `json:"dailyDigest" gorm:"default:false"`
+	Active       bool           `json:"active" gorm:"default:true;index"`
+	CreatedBy    string         `json:"createdBy" gorm:"type:varchar(36)"`
+	CreatedAt    time.Time      `json:"createdAt"`
+	UpdatedAt    time.Time      `json:"updatedAt"`
+
+	HasWebhook  bool  `json:"hasWebhook" gorm:"-"`
+	HasBotToken bool  `json:"hasBotToken" gorm:"-"`
+	Node        *Node `json:"node,omitempty" gorm:"foreignKey:NodeID"`
+}
+
+func (c *ChatChannel) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == "" {
+		c.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (c *ChatChannel) AfterFind(tx *gorm.DB) error {
+	c.HasWebhook = c.WebhookURL != ""
+	c.HasBotToken = c.BotToken != ""
+	return nil
+}
+
+func (ChatChannel) TableName() string {
+	return "chat_channels"
+}
+
+// =============== DTOs ===============
+
+// ChatChannelRequest DTO; webhookUrl and botToken are kept when omitted
+type ChatChannelRequest struct {
+	Name         string   `json:"name" validate:"required,max=100"`
+	Provider     string   `json:"provider" validate:"required,oneof=SLACK TEAMS"`
+	NodeID       *uint    `json:"nodeId"`
+	WebhookURL   *string  `json:"webhookUrl" validate:"omitempty,max=500"`
+	BotToken     *string  `json:"botToken" validate:"omitempty,max=255"`
+	SlackChannel string   `json:"slackChannel" validate:"max=100"`
+	AlertTypes   []string `json:"alertTypes" validate:"dive,oneof=SLA_AT_RISK LOW_STOCK OVERDUE_PAYMENT MOCKED_LOCATION KPI_BREACH CACHE_DOWN JOB_BACKLOG"`
+	MinSeverity  string   `json:"minSeverity" validate:"omitempty,oneof=INFO WARNING CRITICAL"`
+	DailyDigest  bool     `json:"dailyDigest"`
+	Active       *bool    `json:"active"`
+}
+
+// AlertDigestRow counts the alerts of a type for the daily digest
+type AlertDigestRow struct {
+	Type     string `json:"type"`
+	Raised   int64  `json:"raised"`   // in the last 24 hours
+	Active   int64  `json:"active"`   // not resolved
+	Critical int64  `json:"critical"` // active and critical
+}
+
+// ChatPostResult is the outcome of a test post
+type ChatPostResult struct {
+	Delivered bool   `json:"delivered"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ChatDigestResult summarizes a digest run
+type ChatDigestResult struct {
+	Sent   int      `json:"sent"`
+	Errors []string `json:"errors,omitempty"`
+}
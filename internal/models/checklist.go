@@ -0,0 +1,119 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ChecklistTemplate lists the steps technicians go through on the tickets of a category,
+// e.g. "photo before" or "test signal". With BlockClosure set, a ticket cannot move to
+// PARA_FECHAMENTO or FECHADO while a mandatory step is not done.
+type ChecklistTemplate struct {
+	ID           string                  `json:"id" gorm:"type:uuid;primaryKey"`
+	TenantID     string                  `json:"tenantId" gorm:"type:varchar(36);not null;default:'00000000-0000-0000-0000-000000000001';index"`
+	CategoryID   string                  `json:"categoryId" gorm:"type:uuid;not null;uniqueIndex"`
+	Name         string                  `json:"name" gorm:"type:varchar(150);not null"`
+	BlockClosure bool                    `json:"blockClosure" gorm:"not null"`
+	Items        []ChecklistTemplateItem `json:"items" gorm:"foreignKey:TemplateID"`
+	CreatedAt    time.Time               `json:"createdAt"`
+	UpdatedAt    time.Time               `json:"updatedAt"`
+}
+
+func (t *ChecklistTemplate) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == "" {
+		t.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (ChecklistTemplate) TableName() string {
+	return "checklist_templates"
+}
+
+// ChecklistTemplateItem is a step of a checklist template
+type ChecklistTemplateItem struct {
+	ID          string    `json:"id" gorm:"type:uuid;primaryKey"`
+	TemplateID  string    `json:"templateId" gorm:"type:uuid;not null;index"`
+	Label       string    `json:"label" gorm:"type:varchar(200);not null"`
+	Description string    `json:"description" gorm:"type:text"`
+	Mandatory   bool      `json:"mandatory" gorm:"not null;default:false"`
+	SortOrder   int       `json:"sortOrder" gorm:"not null;default:0"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+func (i *ChecklistTemplateItem) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == "" {
+		i.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (ChecklistTemplateItem) TableName() string {
+	return "checklist_template_items"
+}
+
+// TicketChecklistItem is a step of the checklist of a ticket, copied from the template of
+// its category. Steps not done follow later edits of the template; done ones are kept as
+// they were ticked.
+type TicketChecklistItem struct {
+	ID             string     `json:"id" gorm:"type:uuid;primaryKey"`
+	TicketID       string     `json:"ticketId" gorm:"type:uuid;not null;index;uniqueIndex:idx_ticket_checklist_template_item"`
+	TemplateItemID string     `json:"templateItemId" gorm:"type:uuid;not null;uniqueIndex:idx_ticket_checklist_template_item"`
+	Label          string     `json:"label" gorm:"type:varchar(200);not null"`
+	Description    string     `json:"description" gorm:"type:text"`
+	Mandatory      bool       `json:"mandatory" gorm:"not null;default:false"`
+	SortOrder      int        `json:"sortOrder" gorm:"not null;default:0"`
+	Done           bool       `json:"done" gorm:"not null;default:false"`
+	DoneAt         *time.Time `json:"doneAt"`
+	DoneBy         *string    `json:"doneBy" gorm:"type:varchar(36)"`
+	Notes          string     `json:"notes" gorm:"type:text"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+}
+
+func (i *TicketChecklistItem) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == "" {
+		i.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (TicketChecklistItem) TableName() string {
+	return "ticket_checklist_items"
+}
+
+// =============== DTOs ===============
+
+// ChecklistTemplateItemInput DTO
+type ChecklistTemplateItemInput struct {
+	Label       string `json:"label" validate:"required,max=200"`
+	Description string `json:"description"`
+	Mandatory   bool   `json:"mandatory"`
+}
+
+// SetChecklistTemplateRequest DTO: replaces the checklist of the category; items keep the
+// order given
+type SetChecklistTemplateRequest struct {
+	CategoryID   string                       `json:"categoryId" validate:"required,uuid"`
+	Name         string                       `json:"name" validate:"required,max=150"`
+	BlockClosure *bool                        `json:"blockClosure"` // default true
+	Items        []ChecklistTemplateItemInput `json:"items" validate:"required,min=1,max=50,dive"`
+}
+
+// UpdateChecklistItemRequest DTO: ticks or unticks a step of a ticket checklist
+type UpdateChecklistItemRequest struct {
+	Done  bool   `json:"done"`
+	Notes string `json:"notes" validate:"max=1000"`
+}
+
+// TicketChecklist is the checklist of a ticket with what is left of it
+type TicketChecklist struct {
+	TicketID         string                `json:"ticketId"`
+	TemplateID       *string               `json:"templateId"`
+	BlockClosure     bool                  `json:"blockClosure"`
+	Items            []TicketChecklistItem `json:"items"`
+	MandatoryPending int                   `json:"mandatoryPending"`
+	Complete         bool                  `json:"complete"` // every mandatory step done
+}
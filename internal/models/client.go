@@ -9,6 +9,7 @@ import (
 
 type Client struct {
 	ID                 string         `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	TenantID           string         `json:"tenantId" gorm:"type:varchar(36);not null;default:'00000000-0000-0000-0000-000000000001';index"`
 	FullName           string         `json:"fullName" gorm:"not null;type:varchar(255)" validate:"required"`
 	CPF                string         `json:"cpf" gorm:"type:varchar(14);index:idx_clients_cpf,unique,where:cpf <> ''"`
 	CNPJ               string         `json:"cnpj" gorm:"type:varchar(18);index:idx_clients_cnpj,unique,where:cnpj <> ''"`
@@ -24,6 +25,18 @@ type Client struct {
 	City         string `json:"city" gorm:"type:varchar(100)"`
 	State        string `json:"state" gorm:"type:varchar(2)"`
 	ZipCode      string `json:"zipCode" gorm:"type:varchar(10)"`
+
+	// Site coordinates geocoded from the address; geocoded again when the address changes
+	Latitude     *float64   `json:"latitude" gorm:"type:double precision"`
+	Longitude    *float64   `json:"longitude" gorm:"type:double precision"`
+	GeoPrecision string     `json:"geoPrecision" gorm:"type:varchar(10)"` // ADDRESS, CITY, STATE or NONE (address not found)
+	GeocodedAt   *time.Time `json:"geocodedAt"`
+
+	// Hierarchy node that owns the client (nil = visible in every scope)
+	NodeID *uint `json:"nodeId" gorm:"index"`
+
+	// Optimistic locking: bumped by every update, sent back on edits
+	Version int `json:"version" gorm:"not null;default:1"`
 	
 	CreatedAt time.Time      `json:"createdAt"`
 	UpdatedAt time.Time      `json:"updatedAt"`
@@ -58,6 +71,7 @@ type ClientDTO struct {
 	City     string `json:"city"`
 	State    string `json:"state"`
 	IsPJ     bool   `json:"isPJ"`
+	NodeID   *uint  `json:"nodeId"`
 }
 
 func (c *Client) ToDTO() ClientDTO {
@@ -71,6 +85,7 @@ func (c *Client) ToDTO() ClientDTO {
 		City:     c.City,
 		State:    c.State,
 		IsPJ:     c.IsPJ(),
+		NodeID:   c.NodeID,
 	}
 }
 
@@ -0,0 +1,182 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Expiry statuses of a client document, derived from its expiry date
+const (
+	DocumentStatusValid    = "VALID"
+	DocumentStatusExpiring = "EXPIRING" // inside the reminder window of its category
+	DocumentStatusExpired  = "EXPIRED"
+)
+
+// ClientDocumentCategory groups client documents (contracts, insurance certificates,
+// access authorizations). DownloadRoles are the roles allowed to download the documents
+// of the category and to share them through download links.
+type ClientDocumentCategory struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	Name           string    `json:"name" gorm:"type:varchar(100);not null;uniqueIndex"`
+	Description    string    `json:"description" gorm:"type:text"`
+	RequiresExpiry bool      `json:"requiresExpiry" gorm:"not null;default:false"`
+	ReminderDays   int       `json:"reminderDays" gorm:"not null;default:30"` // days before expiry
+	DownloadRoles  string    `json:"downloadRoles" gorm:"type:varchar(100);not null;default:'ADMIN,EMPLOYEE'"`
+	Active         bool      `json:"active" gorm:"not null;default:true"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+func (ClientDocumentCategory) TableName() string {
+	return "client_document_categories"
+}
+
+// AllowsDownload reports whether the role may download documents of the category; admins
+// always may
+func (c *ClientDocumentCategory) AllowsDownload(role string) bool {
+	if role == "ADMIN" {
+		return true
+	}
+	for _, r := range strings.Split(c.DownloadRoles, ",") {
+		if strings.TrimSpace(r) == role {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientDocument is a file in the document vault of a client
+type ClientDocument struct {
+	ID             string     `json:"id" gorm:"type:uuid;primaryKey"`
+	ClientID       string     `json:"clientId" gorm:"type:uuid;not null;index"`
+	CategoryID     uint       `json:"categoryId" gorm:"not null;index"`
+	Title          string     `json:"title" gorm:"type:varchar(255);not null"`
+	FileName       string     `json:"fileName" gorm:"type:varchar(255);not null"`
+	FileType       string     `json:"fileType" gorm:"type:varchar(100)"`
+	FileSize       int64      `json:"fileSize"`
+	FilePath       string     `json:"-" gorm:"type:varchar(500);not null"`
+	ScanResult     string     `json:"scanResult" gorm:"type:varchar(255)"`
+	IssuedAt       *time.Time `json:"issuedAt" gorm:"type:date"`
+	ExpiresAt      *time.Time `json:"expiresAt" gorm:"type:date;index"`
+	ReminderSentAt *time.Time `json:"reminderSentAt"`
+	Notes          string     `json:"notes" gorm:"type:text"`
+	UploadedBy     string     `json:"uploadedBy" gorm:"type:varchar(36)"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+
+	// Derived from ExpiresAt and the reminder window of the category
+	Status string `json:"status" gorm:"-"`
+
+	Client   *Client                 `json:"client,omitempty" gorm:"foreignKey:ClientID"`
+	Category *ClientDocumentCategory `json:"category,omitempty" gorm:"foreignKey:CategoryID"`
+}
+
+func (d *ClientDocument) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == "" {
+		d.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (ClientDocument) TableName() string {
+	return "client_documents"
+}
+
+// ExpiryStatus returns the status of the document at the given moment
+func (d *ClientDocument) ExpiryStatus(now time.Time) string {
+	if d.ExpiresAt == nil {
+		return DocumentStatusValid
+	}
+	if !now.Before(d.ExpiresAt.AddDate(0, 0, 1)) { // valid through the expiry day
+		return DocumentStatusExpired
+	}
+	reminderDays := 0
+	if d.Category != nil {
+		reminderDays = d.Category.ReminderDays
+	}
+	if !now.Before(d.ExpiresAt.AddDate(0, 0, -reminderDays)) {
+		return DocumentStatusExpiring
+	}
+	return DocumentStatusValid
+}
+
+// ClientDocumentLink is a token-based download link of a document, for people without a
+// login (e.g. the building manager checking an access authorization)
+type ClientDocumentLink struct {
+	ID             string     `json:"id" gorm:"type:uuid;primaryKey"`
+	DocumentID     string     `json:"documentId" gorm:"type:uuid;not null;index"`
+	Token          string     `json:"-" gorm:"type:varchar(64);uniqueIndex;not null"`
+	ExpiresAt      time.Time  `json:"expiresAt" gorm:"not null"`
+	MaxDownloads   int        `json:"maxDownloads" gorm:"not null;default:0"` // 0 = unlimited
+	Downloads      int        `json:"downloads" gorm:"not null;default:0"`
+	LastDownloadAt *time.Time `json:"lastDownloadAt"`
+	RevokedAt      *time.Time `json:"revokedAt"`
+	CreatedBy      string     `json:"createdBy" gorm:"type:varchar(36)"`
+	CreatedAt      time.Time  `json:"createdAt"`
+
+	Document *ClientDocument `json:"document,omitempty" gorm:"foreignKey:DocumentID"`
+}
+
+func (l *ClientDocumentLink) BeforeCreate(tx *gorm.DB) error {
+	if l.ID == "" {
+		l.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (ClientDocumentLink) TableName() string {
+	return "client_document_links"
+}
+
+// =============== DTOs ===============
+
+// ClientDocumentCategoryRequest DTO
+type ClientDocumentCategoryRequest struct {
+	Name           string   `json:"name" validate:"required,max=100"`
+	Description    string   `json:"description"`
+	RequiresExpiry bool     `json:"requiresExpiry"`
+	ReminderDays   *int     `json:"reminderDays" validate:"omitempty,min=0,max=365"`
+	DownloadRoles  []string `json:"downloadRoles" validate:"omitempty,dive,oneof=ADMIN EMPLOYEE USER"`
+	Active         *bool    `json:"active"`
+}
+
+// ClientDocumentRequest DTO; sent as form fields with the upload, as JSON on update.
+// Dates are YYYY-MM-DD.
+type ClientDocumentRequest struct {
+	CategoryID uint   `json:"categoryId" form:"categoryId" validate:"required"`
+	Title      string `json:"title" form:"title" validate:"max=255"`
+	IssuedAt   string `json:"issuedAt" form:"issuedAt"`
+	ExpiresAt  string `json:"expiresAt" form:"expiresAt"`
+	Notes      string `json:"notes" form:"notes"`
+}
+
+// ClientDocumentFilters DTO
+type ClientDocumentFilters struct {
+	ClientID   string
+	CategoryID uint
+	Status     string // VALID, EXPIRING or EXPIRED
+	Role       string // only categories this role may download
+}
+
+// CreateDocumentLinkRequest DTO
+type CreateDocumentLinkRequest struct {
+	ExpiresInHours int `json:"expiresInHours" validate:"omitempty,min=1,max=720"`
+	MaxDownloads   int `json:"maxDownloads" validate:"omitempty,min=1"`
+}
+
+// DocumentLinkResponse DTO; the token is only shown when the link is created
+type DocumentLinkResponse struct {
+	ClientDocumentLink
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+// DocumentReminderResult summarizes a reminder run
+type DocumentReminderResult struct {
+	Documents  int      `json:"documents"`
+	Recipients int      `json:"recipients"`
+	Errors     []string `json:"errors,omitempty"`
+}
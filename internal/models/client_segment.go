@@ -0,0 +1,139 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Computed client segment dimensions
+const (
+	SegmentRevenueTier  = "REVENUE_TIER"  // income over the last 12 months, relative to the other clients
+	SegmentTicketVolume = "TICKET_VOLUME" // tickets opened over the last 90 days
+	SegmentChurnRisk    = "CHURN_RISK"    // time since the last ticket and the last NPS answer
+)
+
+// Segment values, the same for every dimension
+const (
+	SegmentHigh   = "HIGH"
+	SegmentMedium = "MEDIUM"
+	SegmentLow    = "LOW"
+)
+
+var segmentDimensions = map[string]bool{SegmentRevenueTier: true, SegmentTicketVolume: true, SegmentChurnRisk: true}
+var segmentValues = map[string]bool{SegmentHigh: true, SegmentMedium: true, SegmentLow: true}
+
+// ClientTag is a free label managed by the users and attached to any number of clients
+type ClientTag struct {
+	ID        string    `json:"id" gorm:"type:uuid;primaryKey"`
+	Name      string    `json:"name" gorm:"type:varchar(60);not null;uniqueIndex"`
+	Color     string    `json:"color" gorm:"type:varchar(7)"` // #RRGGBB
+	Clients   int64     `json:"clients" gorm:"-"`             // tagged clients, filled on listing
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func (t *ClientTag) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == "" {
+		t.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (ClientTag) TableName() string {
+	return "client_tags"
+}
+
+// ClientTagAssignment attaches a tag to a client
+type ClientTagAssignment struct {
+	ClientID  string    `json:"clientId" gorm:"type:uuid;primaryKey"`
+	TagID     string    `json:"tagId" gorm:"type:uuid;primaryKey;index"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (ClientTagAssignment) TableName() string {
+	return "client_tag_assignments"
+}
+
+// ClientSegment is the computed membership of a client in one dimension; the whole table
+// is rebuilt by the segmentation job
+type ClientSegment struct {
+	ClientID   string    `json:"clientId" gorm:"type:uuid;primaryKey"`
+	Dimension  string    `json:"dimension" gorm:"type:varchar(20);primaryKey"`
+	Value      string    `json:"value" gorm:"type:varchar(10);not null;index"`
+	Metric     float64   `json:"metric"` // revenue, ticket count or days since the last ticket
+	ComputedAt time.Time `json:"computedAt"`
+}
+
+func (ClientSegment) TableName() string {
+	return "client_segments"
+}
+
+// =============== DTOs ===============
+
+// ClientTagRequest DTO
+type ClientTagRequest struct {
+	Name  string `json:"name" validate:"required,max=60"`
+	Color string `json:"color" validate:"omitempty,hexcolor"`
+}
+
+// SetClientTagsRequest replaces the tags of a client
+type SetClientTagsRequest struct {
+	TagIDs []string `json:"tagIds" validate:"dive,uuid"`
+}
+
+// ClientSegmentation lists the tags and computed segments of one client
+type ClientSegmentation struct {
+	ClientID string          `json:"clientId"`
+	Tags     []ClientTag     `json:"tags"`
+	Segments []ClientSegment `json:"segments"`
+}
+
+// ClientSegmentMetrics are the figures a client is segmented by
+type ClientSegmentMetrics struct {
+	ClientID     string
+	Revenue      float64
+	Tickets      int64
+	LastTicketAt *time.Time
+	LastNPSScore *int
+}
+
+// ClientSegmentCount is the number of clients in one segment
+type ClientSegmentCount struct {
+	Dimension string `json:"dimension"`
+	Value     string `json:"value"`
+	Clients   int64  `json:"clients"`
+}
+
+// ClientSegmentSummary DTO
+type ClientSegmentSummary struct {
+	ComputedAt *time.Time           `json:"computedAt"`
+	Segments   []ClientSegmentCount `json:"segments"`
+}
+
+// ClientSegmentFilter restricts clients, or records of clients, to a tag and a segment.
+// Segment is written DIMENSION:VALUE, e.g. REVENUE_TIER:HIGH.
+type ClientSegmentFilter struct {
+	TagID   string
+	Segment string
+}
+
+func (f ClientSegmentFilter) IsEmpty() bool {
+	return f.TagID == "" && f.Segment == ""
+}
+
+// SegmentParts splits Segment into its dimension and value
+func (f ClientSegmentFilter) SegmentParts() (dimension, value string) {
+	dimension, value, _ = strings.Cut(strings.ToUpper(strings.TrimSpace(f.Segment)), ":")
+	return dimension, value
+}
+
+func (f ClientSegmentFilter) IsValid() bool {
+	if f.Segment == "" {
+		return true
+	}
+	dimension, value := f.SegmentParts()
+	return segmentDimensions[dimension] && segmentValues[value]
+}
@@ -1,5 +1,7 @@
 package models
 
+import "github.com/shigake/tech-iq-back/internal/pagination"
+
 // Address represents an embedded address structure
 type Address struct {
 	Street       string `json:"street" gorm:"type:varchar(255)"`
@@ -19,6 +21,33 @@ type PaginatedResponse struct {
 	Size          int         `json:"size"`
 	TotalElements int64       `json:"totalElements"`
 	TotalPages    int         `json:"totalPages"`
+	HasNext       bool        `json:"hasNext"`
+	// Keyset paging (?cursor=): the cursors of the pages after and before this one
+	NextCursor string `json:"nextCursor,omitempty"`
+	PrevCursor string `json:"prevCursor,omitempty"`
+}
+
+// NewPaginatedResponse builds the response of a zero-based page
+func NewPaginatedResponse(content interface{}, page, size int, total int64) *PaginatedResponse {
+	return &PaginatedResponse{
+		Content:       content,
+		Page:          page,
+		Size:          size,
+		TotalElements: total,
+		TotalPages:    pagination.TotalPages(total, size),
+		HasNext:       pagination.HasNext(page*size, size, total),
+	}
+}
+
+// NewCursorResponse builds the response of a keyset page; the total is not counted
+func NewCursorResponse(content interface{}, size int, next, prev string) *PaginatedResponse {
+	return &PaginatedResponse{
+		Content:    content,
+		Size:       size,
+		HasNext:    next != "",
+		NextCursor: next,
+		PrevCursor: prev,
+	}
 }
 
 // DashboardStats represents dashboard statistics
@@ -31,6 +60,8 @@ type DashboardStats struct {
 	InProgressTickets int64 `json:"inProgressTickets"`
 	ClosedTickets     int64 `json:"closedTickets"`
 	TotalClients      int64 `json:"totalClients"`
+
+	TicketsBySource []TicketsBySource `json:"ticketsBySource"`
 }
 
 // TicketsByStatus represents tickets grouped by status
@@ -39,6 +70,12 @@ type TicketsByStatus struct {
 	Count  int64  `json:"count"`
 }
 
+// TicketsBySource represents tickets grouped by source channel
+type TicketsBySource struct {
+	Source string `json:"source"`
+	Count  int64  `json:"count"`
+}
+
 // TechniciansByState represents technicians grouped by state
 type TechniciansByState struct {
 	State string `json:"state"`
@@ -0,0 +1,140 @@
+package models
+
+import "time"
+
+type TicketType string
+
+const (
+	TicketTypeService   TicketType = "SERVICO"
+	TicketTypeComplaint TicketType = "RECLAMACAO"
+)
+
+// ComplaintRootCause classifies why a complaint happened; required to close it
+type ComplaintRootCause string
+
+const (
+	RootCauseTechnicianConduct ComplaintRootCause = "TECHNICIAN_CONDUCT"
+	RootCauseWorkmanship       ComplaintRootCause = "WORKMANSHIP"
+	RootCauseDelay             ComplaintRootCause = "DELAY"
+	RootCauseParts             ComplaintRootCause = "PARTS"
+	RootCauseCommunication     ComplaintRootCause = "COMMUNICATION"
+	RootCauseBilling           ComplaintRootCause = "BILLING"
+	RootCauseProcess           ComplaintRootCause = "PROCESS"
+	RootCauseUnfounded         ComplaintRootCause = "UNFOUNDED" // investigated, complaint not valid
+)
+
+func (c ComplaintRootCause) IsValid() bool {
+	switch c {
+	case RootCauseTechnicianConduct, RootCauseWorkmanship, RootCauseDelay, RootCauseParts,
+		RootCauseCommunication, RootCauseBilling, RootCauseProcess, RootCauseUnfounded:
+		return true
+	}
+	return false
+}
+
+// Founded reports whether the complaint counts against the technician
+func (c ComplaintRootCause) Founded() bool {
+	return c != "" && c != RootCauseUnfounded
+}
+
+// TicketComplaint holds the ombudsman data of a RECLAMACAO ticket
+type TicketComplaint struct {
+	TicketID         string  `json:"ticketId" gorm:"type:uuid;primaryKey"`
+	OriginalTicketID string  `json:"originalTicketId" gorm:"type:uuid;not null;index"`
+	TechnicianID     *string `json:"technicianId" gorm:"type:varchar(36);index"`
+	Source           string  `json:"source" gorm:"type:varchar(30)"` // PHONE, EMAIL, WHATSAPP, PORTAL...
+
+	SLADueAt   time.Time  `json:"slaDueAt" gorm:"not null;index"`
+	ResolvedAt *time.Time `json:"resolvedAt"`
+
+	RootCause      ComplaintRootCause `json:"rootCause" gorm:"type:varchar(30);index"`
+	RootCauseNotes string             `json:"rootCauseNotes" gorm:"type:text"`
+	ClassifiedBy   string             `json:"classifiedBy" gorm:"type:varchar(36)"`
+	ClassifiedAt   *time.Time         `json:"classifiedAt"`
+
+	CreatedBy string    `json:"createdBy" gorm:"type:varchar(36)"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	OriginalTicket *Ticket     `json:"originalTicket,omitempty" gorm:"foreignKey:OriginalTicketID"`
+	Technician     *Technician `json:"technician,omitempty" gorm:"foreignKey:TechnicianID"`
+}
+
+func (TicketComplaint) TableName() string {
+	return "ticket_complaints"
+}
+
+// SLABreached reports whether the complaint was (or still is) past its deadline
+func (c *TicketComplaint) SLABreached(now time.Time) bool {
+	if c.ResolvedAt != nil {
+		return c.ResolvedAt.After(c.SLADueAt)
+	}
+	return now.After(c.SLADueAt)
+}
+
+// =============== DTOs ===============
+
+// CreateComplaintRequest DTO; the technician defaults to the lead of the original ticket
+type CreateComplaintRequest struct {
+	OriginalTicketID string `json:"originalTicketId" validate:"required"`
+	TechnicianID     string `json:"technicianId"`
+	Description      string `json:"description" validate:"required"`
+	Priority         string `json:"priority" validate:"omitempty,oneof=BAIXA NORMAL ALTA URGENTE"`
+	Source           string `json:"source" validate:"max=30"`
+}
+
+// ClassifyComplaintRequest DTO
+type ClassifyComplaintRequest struct {
+	RootCause string `json:"rootCause" validate:"required"`
+	Notes     string `json:"notes"`
+}
+
+// ComplaintDTO is a complaint ticket with its ombudsman data
+type ComplaintDTO struct {
+	TicketDTO
+	Complaint        *TicketComplaint `json:"complaint"`
+	OriginalOSNumber string           `json:"originalOsNumber"`
+	TechnicianName   string           `json:"technicianName"`
+	SLABreached      bool             `json:"slaBreached"`
+}
+
+// ComplaintFilters DTO
+type ComplaintFilters struct {
+	Status       string // open (not closed) or closed
+	TechnicianID string
+	RootCause    string
+}
+
+// ComplaintReport summarizes complaints of a period
+type ComplaintReport struct {
+	From         time.Time                  `json:"from"`
+	To           time.Time                  `json:"to"`
+	Total        int                        `json:"total"`
+	Open         int                        `json:"open"`
+	Founded      int                        `json:"founded"`
+	Unfounded    int                        `json:"unfounded"`
+	SLABreached  int                        `json:"slaBreached"`
+	ByTechnician []TechnicianComplaintStats `json:"byTechnician"`
+	ByCategory   []CategoryComplaintStats   `json:"byCategory"`
+	ByRootCause  map[ComplaintRootCause]int `json:"byRootCause"`
+}
+
+// TechnicianComplaintStats feeds the technician performance score:
+// 100 minus 10 points per founded complaint for every 100 closed tickets
+type TechnicianComplaintStats struct {
+	TechnicianID     string  `json:"technicianId"`
+	TechnicianName   string  `json:"technicianName"`
+	Complaints       int     `json:"complaints"`
+	Founded          int     `json:"founded"`
+	ClosedTickets    int64   `json:"closedTickets"`
+	ComplaintRate    float64 `json:"complaintRate"` // founded complaints per 100 closed tickets
+	PerformanceScore float64 `json:"performanceScore"`
+}
+
+// CategoryComplaintStats DTO
+type CategoryComplaintStats struct {
+	CategoryID   string `json:"categoryId"`
+	CategoryName string `json:"categoryName"`
+	Complaints   int    `json:"complaints"`
+	Founded      int    `json:"founded"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// Sources of the expirations in the compliance report
+const (
+	ExpirySourceClientDocument = "client_document"
+)
+
+// ComplianceExpiration is one tracked expiration (e.g. a client contract or insurance
+// certificate) with the hierarchy node responsible for it
+type ComplianceExpiration struct {
+	Source    string    `json:"source"`
+	EntityID  string    `json:"entityId"`
+	Title     string    `json:"title"`
+	Category  string    `json:"category"`
+	OwnerID   string    `json:"ownerId"`
+	OwnerName string    `json:"ownerName"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	DaysLeft  int       `json:"daysLeft" gorm:"-"` // negative when already expired
+	Status    string    `json:"status" gorm:"-"`   // EXPIRING or EXPIRED
+	NodeID    *uint     `json:"-"`
+	NodeName  *string   `json:"-"`
+}
+
+// ComplianceNodeGroup gathers the expirations of one responsible node; NodeID is nil for
+// the ones without a node
+type ComplianceNodeGroup struct {
+	NodeID      *uint                  `json:"nodeId"`
+	NodeName    string                 `json:"nodeName"`
+	Expired     int                    `json:"expired"`
+	Expiring    int                    `json:"expiring"`
+	Expirations []ComplianceExpiration `json:"expirations"`
+}
+
+// ExpiryComplianceReport lists what expires within Days, already expired included
+type ExpiryComplianceReport struct {
+	GeneratedAt time.Time             `json:"generatedAt"`
+	Days        int                   `json:"days"`
+	Total       int                   `json:"total"`
+	Expired     int                   `json:"expired"`
+	Expiring    int                   `json:"expiring"`
+	Groups      []ComplianceNodeGroup `json:"groups"`
+}
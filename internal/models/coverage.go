@@ -0,0 +1,176 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Coverage area owners; a BRANCH is a stock location of type BRANCH
+const (
+	CoverageOwnerBranch     = "BRANCH"
+	CoverageOwnerTechnician = "TECHNICIAN"
+)
+
+// Coverage area kinds
+const (
+	CoverageKindMunicipalities = "MUNICIPALITIES"
+	CoverageKindPolygon        = "POLYGON"
+)
+
+// Coverage enforcement modes on ticket creation
+const (
+	CoverageEnforcementOff   = "off"
+	CoverageEnforcementWarn  = "warn"
+	CoverageEnforcementBlock = "block"
+)
+
+// Municipality identifies a city by name and state (UF)
+type Municipality struct {
+	City  string `json:"city" validate:"required"`
+	State string `json:"state" validate:"required,len=2"`
+}
+
+// MunicipalityList is a custom type for PostgreSQL JSONB array of municipalities
+type MunicipalityList []Municipality
+
+func (m MunicipalityList) Value() (driver.Value, error) {
+	if m == nil {
+		return "[]", nil
+	}
+	return json.Marshal(m)
+}
+
+func (m *MunicipalityList) Scan(value interface{}) error {
+	if value == nil {
+		*m = MunicipalityList{}
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("failed to scan MunicipalityList")
+	}
+	return json.Unmarshal(bytes, m)
+}
+
+// GeoPoint is a polygon vertex
+type GeoPoint struct {
+	Latitude  float64 `json:"lat"`
+	Longitude float64 `json:"lng"`
+}
+
+// GeoPolygon is a custom type for PostgreSQL JSONB array of vertices
+type GeoPolygon []GeoPoint
+
+func (p GeoPolygon) Value() (driver.Value, error) {
+	if p == nil {
+		return "[]", nil
+	}
+	return json.Marshal(p)
+}
+
+func (p *GeoPolygon) Scan(value interface{}) error {
+	if value == nil {
+		*p = GeoPolygon{}
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("failed to scan GeoPolygon")
+	}
+	return json.Unmarshal(bytes, p)
+}
+
+// Contains tells whether the point lies inside the polygon (ray casting)
+func (p GeoPolygon) Contains(lat, lng float64) bool {
+	inside := false
+	for i, j := 0, len(p)-1; i < len(p); j, i = i, i+1 {
+		a, b := p[i], p[j]
+		if (a.Latitude > lat) != (b.Latitude > lat) &&
+			lng < (b.Longitude-a.Longitude)*(lat-a.Latitude)/(b.Latitude-a.Latitude)+a.Longitude {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// CoverageArea is the serviceable area of a branch or a technician, either a list of
+// municipalities or a drawn polygon
+type CoverageArea struct {
+	ID             string           `json:"id" gorm:"type:uuid;primaryKey"`
+	OwnerType      string           `json:"ownerType" gorm:"type:varchar(20);not null;index:idx_coverage_owner"`
+	OwnerID        string           `json:"ownerId" gorm:"type:varchar(36);not null;index:idx_coverage_owner"`
+	Name           string           `json:"name" gorm:"type:varchar(255);not null"`
+	Kind           string           `json:"kind" gorm:"type:varchar(20);not null"`
+	Municipalities MunicipalityList `json:"municipalities" gorm:"type:jsonb;default:'[]'"`
+	Polygon        GeoPolygon       `json:"polygon" gorm:"type:jsonb;default:'[]'"`
+	Active         bool             `json:"active" gorm:"default:true"`
+	CreatedBy      string           `json:"createdBy" gorm:"type:varchar(36)"`
+	CreatedAt      time.Time        `json:"createdAt"`
+	UpdatedAt      time.Time        `json:"updatedAt"`
+}
+
+func (a *CoverageArea) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == "" {
+		a.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (CoverageArea) TableName() string {
+	return "coverage_areas"
+}
+
+// =============== DTOs ===============
+
+// CoverageAreaRequest DTO; municipalities for MUNICIPALITIES areas, polygon (3+ points) for POLYGON
+type CoverageAreaRequest struct {
+	OwnerType      string           `json:"ownerType" validate:"required,oneof=BRANCH TECHNICIAN"`
+	OwnerID        string           `json:"ownerId" validate:"required"`
+	Name           string           `json:"name" validate:"required,max=255"`
+	Kind           string           `json:"kind" validate:"required,oneof=MUNICIPALITIES POLYGON"`
+	Municipalities MunicipalityList `json:"municipalities" validate:"dive"`
+	Polygon        GeoPolygon       `json:"polygon"`
+	Active         *bool            `json:"active"`
+}
+
+// CoverageAreaFilters DTO
+type CoverageAreaFilters struct {
+	OwnerType string
+	OwnerID   string
+	Inactive  bool // include inactive areas
+}
+
+// CoverageCheckRequest locates the site by client, by city/state or by coordinates
+type CoverageCheckRequest struct {
+	ClientID  string
+	City      string
+	State     string
+	Latitude  *float64
+	Longitude *float64
+}
+
+// CoverageMatch is an area covering the site
+type CoverageMatch struct {
+	AreaID    string `json:"areaId"`
+	AreaName  string `json:"areaName"`
+	Kind      string `json:"kind"`
+	OwnerType string `json:"ownerType"`
+	OwnerID   string `json:"ownerId"`
+	OwnerName string `json:"ownerName"`
+}
+
+// CoverageCheckResult DTO
+type CoverageCheckResult struct {
+	City      string          `json:"city"`
+	State     string          `json:"state"`
+	Latitude  *float64        `json:"lat,omitempty"`
+	Longitude *float64        `json:"lng,omitempty"`
+	Covered   bool            `json:"covered"`
+	Matches   []CoverageMatch `json:"matches"`
+	Note      string          `json:"note,omitempty"`
+}
@@ -0,0 +1,144 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// Documents a discount can be given on
+const (
+	DiscountDocumentQuote   = "QUOTE"
+	DiscountDocumentInvoice = "INVOICE"
+)
+
+// Discount statuses; a discount within the requester limit is APPROVED on creation
+const (
+	DiscountStatusPending  = "PENDING"
+	DiscountStatusApproved = "APPROVED"
+	DiscountStatusRejected = "REJECTED"
+)
+
+// DefaultDiscountLimits apply to a role without a DiscountLimit row:
+// operators (EMPLOYEE) up to 5%, managers (ADMIN) up to 15%
+var DefaultDiscountLimits = map[string]decimal.Decimal{
+	"USER":     decimal.Zero,
+	"EMPLOYEE": decimal.NewFromInt(5),
+	"ADMIN":    decimal.NewFromInt(15),
+}
+
+// DiscountLimit is the largest discount percent a role may give without approval
+type DiscountLimit struct {
+	Role       string          `json:"role" gorm:"type:varchar(20);primaryKey"`
+	MaxPercent decimal.Decimal `json:"maxPercent" gorm:"type:decimal(5,2);not null"`
+	UpdatedBy  string          `json:"updatedBy" gorm:"type:varchar(36)"`
+	UpdatedAt  time.Time       `json:"updatedAt"`
+}
+
+func (DiscountLimit) TableName() string {
+	return "discount_limits"
+}
+
+// Discount is a discount given on a quote or invoice, with the approval trail when it
+// exceeded the requester limit
+type Discount struct {
+	ID            string          `json:"id" gorm:"type:uuid;primaryKey"`
+	DocumentType  string          `json:"documentType" gorm:"type:varchar(20);not null;index:idx_discount_document"`
+	DocumentRef   string          `json:"documentRef" gorm:"type:varchar(100);not null;index:idx_discount_document"`
+	TicketID      *string         `json:"ticketId" gorm:"type:uuid;index"`
+	ClientID      *string         `json:"clientId" gorm:"type:uuid;index"`
+	GrossAmount   decimal.Decimal `json:"grossAmount" gorm:"type:decimal(12,2);not null"`
+	Percent       decimal.Decimal `json:"percent" gorm:"type:decimal(5,2);not null"`
+	Amount        decimal.Decimal `json:"amount" gorm:"type:decimal(12,2);not null"`
+	Reason        string          `json:"reason" gorm:"type:text;not null"`
+	Status        string          `json:"status" gorm:"type:varchar(20);not null;index"`
+	RequestedBy   string          `json:"requestedBy" gorm:"type:varchar(36);not null;index"`
+	RequesterRole string          `json:"requesterRole" gorm:"type:varchar(20);not null"`
+	LimitPercent  decimal.Decimal `json:"limitPercent" gorm:"type:decimal(5,2);not null"` // requester limit at the time
+	DecidedBy     *string         `json:"decidedBy" gorm:"type:varchar(36)"`
+	DecidedAt     *time.Time      `json:"decidedAt"`
+	DecisionNotes *string         `json:"decisionNotes" gorm:"type:text"`
+	CreatedAt     time.Time       `json:"createdAt" gorm:"index"`
+	UpdatedAt     time.Time       `json:"updatedAt"`
+
+	// Relations (for eager loading)
+	Requester *User `json:"requester,omitempty" gorm:"foreignKey:RequestedBy"`
+	Decider   *User `json:"decider,omitempty" gorm:"foreignKey:DecidedBy"`
+}
+
+func (d *Discount) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == "" {
+		d.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (Discount) TableName() string {
+	return "discounts"
+}
+
+// OverLimit reports whether the discount exceeded the requester limit
+func (d *Discount) OverLimit() bool {
+	return d.Percent.GreaterThan(d.LimitPercent)
+}
+
+// =============== DTOs ===============
+
+// UpdateDiscountLimitRequest DTO
+type UpdateDiscountLimitRequest struct {
+	MaxPercent decimal.Decimal `json:"maxPercent"`
+}
+
+// CreateDiscountRequest DTO; give either percent or amount
+type CreateDiscountRequest struct {
+	DocumentType string           `json:"documentType" validate:"required,oneof=QUOTE INVOICE"`
+	DocumentRef  string           `json:"documentRef" validate:"required,max=100"`
+	TicketID     *string          `json:"ticketId" validate:"omitempty,uuid"`
+	GrossAmount  decimal.Decimal  `json:"grossAmount"`
+	Percent      *decimal.Decimal `json:"percent"`
+	Amount       *decimal.Decimal `json:"amount"`
+	Reason       string           `json:"reason" validate:"required"`
+}
+
+// DiscountDecisionRequest DTO
+type DiscountDecisionRequest struct {
+	Notes string `json:"notes"`
+}
+
+// DiscountFilters DTO
+type DiscountFilters struct {
+	Status       string
+	RequestedBy  string
+	DocumentType string
+	DocumentRef  string
+	From         *time.Time
+	To           *time.Time
+}
+
+// UserDiscountSummary is the discounts given by one user in the period
+type UserDiscountSummary struct {
+	UserID         string          `json:"userId"`
+	UserName       string          `json:"userName"`
+	Role           string          `json:"role"`
+	Applied        int64           `json:"applied"`
+	Pending        int64           `json:"pending"`
+	Rejected       int64           `json:"rejected"`
+	OverLimit      int64           `json:"overLimit"` // applied after approval
+	GrossAmount    decimal.Decimal `json:"grossAmount"`
+	DiscountAmount decimal.Decimal `json:"discountAmount"`
+	AvgPercent     decimal.Decimal `json:"avgPercent"` // discount amount over gross amount
+	MaxPercent     decimal.Decimal `json:"maxPercent"`
+}
+
+// DiscountReport summarizes applied discounts per user for margin-leak analysis
+type DiscountReport struct {
+	From           time.Time             `json:"from"`
+	To             time.Time             `json:"to"`
+	Users          []UserDiscountSummary `json:"users"`
+	Applied        int64                 `json:"applied"`
+	GrossAmount    decimal.Decimal       `json:"grossAmount"`
+	DiscountAmount decimal.Decimal       `json:"discountAmount"`
+	AvgPercent     decimal.Decimal       `json:"avgPercent"`
+}
@@ -0,0 +1,247 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DispatchStatus tracks where a ticket is in the auto-dispatch flow
+type DispatchStatus string
+
+const (
+	DispatchStatusPending      DispatchStatus = "PENDING"
+	DispatchStatusAutoAssigned DispatchStatus = "AUTO_ASSIGNED"
+	DispatchStatusManualQueue  DispatchStatus = "MANUAL_QUEUE"
+)
+
+// DispatchOutcome is the result of a single automatic dispatch decision
+type DispatchOutcome string
+
+const (
+	DispatchOutcomeAssigned       DispatchOutcome = "ASSIGNED"
+	DispatchOutcomeNoCandidate    DispatchOutcome = "NO_CANDIDATE"
+	DispatchOutcomeFallbackManual DispatchOutcome = "FALLBACK_MANUAL"
+)
+
+// DispatchWeights controls how the suggestion scorer ranks technicians
+type DispatchWeights struct {
+	Skill    float64 `json:"skill"`
+	Distance float64 `json:"distance"`
+	Workload float64 `json:"workload"`
+}
+
+// DefaultDispatchWeights returns the weights used when a rule does not set them
+func DefaultDispatchWeights() DispatchWeights {
+	return DispatchWeights{Skill: 0.4, Distance: 0.4, Workload: 0.2}
+}
+
+// DispatchRule selects which new tickets are dispatched automatically and how
+type DispatchRule struct {
+	ID        string `json:"id" gorm:"type:uuid;primaryKey"`
+	Name      string `json:"name" gorm:"type:varchar(100);not null"`
+	Enabled   bool   `json:"enabled" gorm:"default:true"`
+	SortOrder int    `json:"sortOrder" gorm:"default:0"`
+
+	// Matching criteria (empty = any)
+	Priority   string  `json:"priority" gorm:"type:varchar(20)"`
+	CategoryID *string `json:"categoryId" gorm:"type:uuid"`
+	NodeID     *uint   `json:"nodeId"`
+
+	// Dispatch parameters
+	MaxWaitMinutes int       `json:"maxWaitMinutes" gorm:"not null;default:15"` // fallback to manual queue after this
+	MaxRadiusKm    float64   `json:"maxRadiusKm" gorm:"type:double precision;not null;default:100"`
+	MaxOpenTickets int       `json:"maxOpenTickets" gorm:"not null;default:5"`
+	RequireSkill   bool      `json:"requireSkill" gorm:"default:true"`
+	WeightSkill    float64   `json:"weightSkill" gorm:"type:double precision;default:0.4"`
+	WeightDistance float64   `json:"weightDistance" gorm:"type:double precision;default:0.4"`
+	WeightWorkload float64   `json:"weightWorkload" gorm:"type:double precision;default:0.2"`
+	CreatedBy      string    `json:"createdBy" gorm:"type:varchar(36)"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+func (r *DispatchRule) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (DispatchRule) TableName() string {
+	return "dispatch_rules"
+}
+
+// Weights returns the scorer weights configured on the rule
+func (r *DispatchRule) Weights() DispatchWeights {
+	return DispatchWeights{Skill: r.WeightSkill, Distance: r.WeightDistance, Workload: r.WeightWorkload}
+}
+
+// Matches reports whether the rule applies to the ticket
+func (r *DispatchRule) Matches(ticket *Ticket) bool {
+	if !r.Enabled {
+		return false
+	}
+	if r.Priority != "" && r.Priority != string(ticket.Priority) {
+		return false
+	}
+	if r.CategoryID != nil && (ticket.CategoryID == nil || *ticket.CategoryID != *r.CategoryID) {
+		return false
+	}
+	if r.NodeID != nil && (ticket.NodeID == nil || *ticket.NodeID != *r.NodeID) {
+		return false
+	}
+	return true
+}
+
+// DispatchDecision is the audit record of every automatic dispatch attempt
+type DispatchDecision struct {
+	ID           string          `json:"id" gorm:"type:uuid;primaryKey"`
+	TicketID     string          `json:"ticketId" gorm:"type:uuid;not null;index"`
+	RuleID       *string         `json:"ruleId" gorm:"type:uuid;index"`
+	TechnicianID *string         `json:"technicianId" gorm:"type:varchar(36)"`
+	Outcome      DispatchOutcome `json:"outcome" gorm:"type:varchar(30);not null;index"`
+	Score        float64         `json:"score"`
+	Reason       string          `json:"reason" gorm:"type:text"`
+	Candidates   string          `json:"candidates" gorm:"type:jsonb"` // ranked candidates snapshot
+	CreatedAt    time.Time       `json:"createdAt" gorm:"index"`
+
+	Ticket     *Ticket       `json:"ticket,omitempty" gorm:"foreignKey:TicketID"`
+	Rule       *DispatchRule `json:"rule,omitempty" gorm:"foreignKey:RuleID"`
+	Technician *Technician   `json:"technician,omitempty" gorm:"foreignKey:TechnicianID"`
+}
+
+func (d *DispatchDecision) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == "" {
+		d.ID = uuid.New().String()
+	}
+	if d.Candidates == "" {
+		d.Candidates = "[]"
+	}
+	return nil
+}
+
+func (DispatchDecision) TableName() string {
+	return "dispatch_decisions"
+}
+
+// =============== DTOs ===============
+
+// ScoredTechnician is a technician ranked by the suggestion scorer
+type ScoredTechnician struct {
+	TechnicianID   string  `json:"technicianId"`
+	TechnicianName string  `json:"technicianName"`
+	Score          float64 `json:"score"`
+	DistanceKm     float64 `json:"distanceKm"`
+	OpenTickets    int     `json:"openTickets"`
+	HasSkill       bool    `json:"hasSkill"`
+	LocationSource string  `json:"locationSource,omitempty"` // where the distance was measured from
+}
+
+// Technician location used by the scorer
+const (
+	LocationSourceLive = "LIVE" // recent last location reported by the app
+	LocationSourceBase = "BASE" // city/state of the technician record
+)
+
+// SuggestTechniciansQuery DTO
+type SuggestTechniciansQuery struct {
+	Limit        int     `query:"limit" validate:"omitempty,min=1,max=50"`
+	RadiusKm     float64 `query:"radiusKm" validate:"omitempty,gt=0,max=5000"`
+	RequireSkill bool    `query:"requireSkill"`
+}
+
+// TechnicianSuggestions ranks the technicians for a manual assignment
+type TechnicianSuggestions struct {
+	TicketID           string             `json:"ticketId"`
+	ClientLatitude     *float64           `json:"clientLatitude"`
+	ClientLongitude    *float64           `json:"clientLongitude"`
+	ClientGeoPrecision string             `json:"clientGeoPrecision"` // EXACT, CITY, STATE or NONE
+	RuleID             *string            `json:"ruleId"`             // dispatch rule whose weights were used
+	Weights            DispatchWeights    `json:"weights"`
+	RadiusKm           float64            `json:"radiusKm"`
+	Candidates         []ScoredTechnician `json:"candidates"`
+}
+
+// CreateDispatchRuleRequest DTO
+type CreateDispatchRuleRequest struct {
+	Name           string           `json:"name" validate:"required,min=1,max=100"`
+	Enabled        *bool            `json:"enabled"`
+	SortOrder      int              `json:"sortOrder"`
+	Priority       string           `json:"priority"`
+	CategoryID     *string          `json:"categoryId"`
+	NodeID         *uint            `json:"nodeId"`
+	MaxWaitMinutes int              `json:"maxWaitMinutes"`
+	MaxRadiusKm    float64          `json:"maxRadiusKm"`
+	MaxOpenTickets int              `json:"maxOpenTickets"`
+	RequireSkill   *bool            `json:"requireSkill"`
+	Weights        *DispatchWeights `json:"weights"`
+}
+
+// DispatchDecisionFilter DTO
+type DispatchDecisionFilter struct {
+	TicketID string
+	Outcome  string
+	Page     int
+	PageSize int
+}
+
+// PaginatedDispatchDecisions DTO
+type PaginatedDispatchDecisions struct {
+	Data       []DispatchDecision `json:"data"`
+	Total      int64              `json:"total"`
+	Page       int                `json:"page"`
+	PageSize   int                `json:"pageSize"`
+	TotalPages int                `json:"totalPages"`
+	HasNext    bool               `json:"hasNext"`
+}
+
+// DispatchRunResult summarizes one pass of the auto-dispatcher
+type DispatchRunResult struct {
+	Evaluated      int `json:"evaluated"`
+	Assigned       int `json:"assigned"`
+	NoCandidate    int `json:"noCandidate"`
+	FallbackManual int `json:"fallbackManual"`
+}
+
+// DispatchPolicy is an alternative assignment policy evaluated by the simulator
+type DispatchPolicy struct {
+	Name           string          `json:"name"`
+	Weights        DispatchWeights `json:"weights"`
+	MaxRadiusKm    float64         `json:"maxRadiusKm"`
+	MaxOpenTickets int             `json:"maxOpenTickets"`
+	RequireSkill   bool            `json:"requireSkill"`
+}
+
+// SimulateDispatchRequest replays a historical day against alternative policies.
+// When no policy is given the enabled dispatch rules are used.
+type SimulateDispatchRequest struct {
+	Date           string           `json:"date" validate:"required"` // YYYY-MM-DD
+	Policies       []DispatchPolicy `json:"policies"`
+	ServiceMinutes int              `json:"serviceMinutes"` // time a technician spends on site, default 120
+}
+
+// DispatchSimulationMetrics summarizes the outcome of one policy over the replayed day
+type DispatchSimulationMetrics struct {
+	Policy          string         `json:"policy"`
+	Tickets         int            `json:"tickets"`
+	Assigned        int            `json:"assigned"`
+	Unassigned      int            `json:"unassigned"`
+	SLAMet          int            `json:"slaMet"`
+	SLAMetPercent   float64        `json:"slaMetPercent"`
+	TotalTravelKm   float64        `json:"totalTravelKm"`
+	AvgTravelKm     float64        `json:"avgTravelKm"`
+	AvgResponseMin  float64        `json:"avgResponseMinutes"`
+	TechniciansUsed int            `json:"techniciansUsed"`
+	MaxPerTech      int            `json:"maxPerTechnician"`
+	WorkloadStdDev  float64        `json:"workloadStdDev"` // lower = better balanced
+	Workload        map[string]int `json:"workload"`       // technicianId -> tickets
+}
+
+// SimulateDispatchResponse compares what actually happened with each policy
+type SimulateDispatchResponse struct {
+	Date     string                      `json:"date"`
+	Baseline DispatchSimulationMetrics   `json:"baseline"`
+	Results  []DispatchSimulationMetrics `json:"results"`
+}
@@ -25,6 +25,7 @@ type ErrorLog struct {
 	UserEmail    string    `json:"userEmail" gorm:"type:varchar(255)"`           // Email do usuário
 	IPAddress    string    `json:"ipAddress" gorm:"type:varchar(45)"`            // IP do cliente
 	UserAgent    string    `json:"userAgent" gorm:"type:text"`                   // Browser/device info
+	RequestID    string    `json:"requestId" gorm:"type:varchar(64);index"`      // ID de correlação (X-Request-ID)
 	StatusCode   int       `json:"statusCode" gorm:"index"`                      // HTTP status code retornado
 	Duration     int64     `json:"duration"`                                     // Duração da request em ms
 	Resolved     bool      `json:"resolved" gorm:"default:false;index"`          // Se o erro foi resolvido
@@ -51,6 +52,7 @@ type ErrorLogFilter struct {
 	Endpoint   string    `json:"endpoint"`
 	ErrorCode  string    `json:"errorCode"`
 	UserID     string    `json:"userId"`
+	RequestID  string    `json:"requestId"`
 	StatusCode int       `json:"statusCode"`
 	Resolved   *bool     `json:"resolved"`
 	StartDate  time.Time `json:"startDate"`
@@ -65,6 +67,7 @@ type PaginatedErrorLogs struct {
 	Page       int        `json:"page"`
 	PageSize   int        `json:"pageSize"`
 	TotalPages int        `json:"totalPages"`
+	HasNext    bool       `json:"hasNext"`
 }
 
 // ErrorLogStats represents statistics about error logs
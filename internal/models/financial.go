@@ -5,6 +5,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/lib/pq"
+	"github.com/shigake/tech-iq-back/internal/pagination"
 	"gorm.io/gorm"
 )
 
@@ -63,6 +64,7 @@ func GetFinancialCategories() []FinancialCategory {
 // FinancialEntry represents a financial entry (income or expense)
 type FinancialEntry struct {
 	ID          string             `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	TenantID    string             `json:"tenantId" gorm:"type:varchar(36);not null;default:'00000000-0000-0000-0000-000000000001';index"`
 	Type        FinancialEntryType `json:"type" gorm:"type:varchar(10);not null;index"`
 	Category    string             `json:"category" gorm:"type:varchar(50);not null;index"`
 	Subcategory string             `json:"subcategory" gorm:"type:varchar(50)"`
@@ -71,7 +73,7 @@ type FinancialEntry struct {
 	Currency    string             `json:"currency" gorm:"type:varchar(3);default:BRL"`
 
 	// Dates
-	EntryDate   time.Time  `json:"entryDate" gorm:"type:date;not null;index"`
+	EntryDate   time.Time  `json:"entryDate" gorm:"type:date;not null;index;uniqueIndex:idx_financial_entries_recurrence,priority:2"`
 	DueDate     *time.Time `json:"dueDate" gorm:"type:date"`
 	PaymentDate *time.Time `json:"paymentDate" gorm:"type:date"`
 
@@ -85,6 +87,11 @@ type FinancialEntry struct {
 	Technician   *Technician `json:"technician,omitempty" gorm:"foreignKey:TechnicianID"`
 	ClientID     *string     `json:"clientId" gorm:"type:uuid;index"`
 	Client       *Client     `json:"client,omitempty" gorm:"foreignKey:ClientID"`
+	SupplierID   *string     `json:"supplierId" gorm:"type:uuid;index"`
+	Supplier     *Supplier   `json:"supplier,omitempty" gorm:"foreignKey:SupplierID"`
+
+	// Recurring entry that generated it, one entry per occurrence date
+	RecurringEntryID *string `json:"recurringEntryId" gorm:"type:uuid;uniqueIndex:idx_financial_entries_recurrence,priority:1"`
 
 	// Payment info
 	PaymentMethod    string `json:"paymentMethod" gorm:"type:varchar(30)"`
@@ -129,6 +136,7 @@ func (FinancialEntry) TableName() string {
 // PaymentBatch represents a batch of payments to be processed together
 type PaymentBatch struct {
 	ID          string `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	TenantID    string `json:"tenantId" gorm:"type:varchar(36);not null;default:'00000000-0000-0000-0000-000000000001';index"`
 	Name        string `json:"name" gorm:"type:varchar(100);not null"`
 	Description string `json:"description" gorm:"type:text"`
 
@@ -256,6 +264,7 @@ type CreateFinancialEntryRequest struct {
 	TicketID         string             `json:"ticketId"`
 	TechnicianID     string             `json:"technicianId"`
 	ClientID         string             `json:"clientId"`
+	SupplierID       string             `json:"supplierId"`
 	PaymentMethod    string             `json:"paymentMethod"`
 	PaymentReference string             `json:"paymentReference"`
 	AttachmentURLs   []string           `json:"attachmentUrls"`
@@ -273,6 +282,7 @@ type UpdateFinancialEntryRequest struct {
 	TicketID         string             `json:"ticketId"`
 	TechnicianID     string             `json:"technicianId"`
 	ClientID         string             `json:"clientId"`
+	SupplierID       string             `json:"supplierId"`
 	PaymentMethod    string             `json:"paymentMethod"`
 	PaymentReference string             `json:"paymentReference"`
 	AttachmentURLs   []string           `json:"attachmentUrls"`
@@ -306,16 +316,20 @@ type PayBatchRequest struct {
 
 // FinancialEntryFilter represents filters for querying financial entries
 type FinancialEntryFilter struct {
-	Type         FinancialEntryType   `query:"type"`
-	Status       FinancialEntryStatus `query:"status"`
-	Category     string               `query:"category"`
-	StartDate    string               `query:"startDate"`
-	EndDate      string               `query:"endDate"`
-	TechnicianID string               `query:"technicianId"`
-	ClientID     string               `query:"clientId"`
-	TicketID     string               `query:"ticketId"`
-	Page         int                  `query:"page"`
-	Limit        int                  `query:"limit"`
+	Type             FinancialEntryType   `query:"type"`
+	Status           FinancialEntryStatus `query:"status"`
+	Category         string               `query:"category"`
+	StartDate        string               `query:"startDate"`
+	EndDate          string               `query:"endDate"`
+	TechnicianID     string               `query:"technicianId"`
+	ClientID         string               `query:"clientId"`
+	TicketID         string               `query:"ticketId"`
+	SupplierID       string               `query:"supplierId"`
+	RecurringEntryID string               `query:"recurringEntryId"`
+	Clients          ClientSegmentFilter  // entries of the clients with the tag and in the segment
+	Page             int                  `query:"page"`
+	Limit            int                  `query:"limit"`
+	Cursor           *pagination.Cursor   // keyset position to page from instead of Page
 }
 
 // PaymentBatchFilter represents filters for querying payment batches
@@ -329,16 +343,20 @@ type PaymentBatchFilter struct {
 
 // DashboardFilter represents filters for the financial dashboard
 type DashboardFilter struct {
-	Period    string `query:"period"` // today, week, month, quarter, year, custom
-	StartDate string `query:"startDate"`
-	EndDate   string `query:"endDate"`
+	Period    string              `query:"period"` // today, week, month, quarter, year, custom
+	StartDate string              `query:"startDate"`
+	EndDate   string              `query:"endDate"`
+	Clients   ClientSegmentFilter // only the entries of the clients with the tag and in the segment
+	Fresh     bool                // skip the cached dashboard
+	TenantID  string              // the tenant the dashboard is cached for, the default one if empty
 }
 
 // CashFlowFilter represents filters for the cash flow report
 type CashFlowFilter struct {
-	StartDate string `query:"startDate" validate:"required"`
-	EndDate   string `query:"endDate" validate:"required"`
-	GroupBy   string `query:"groupBy"` // day, week, month
+	StartDate string              `query:"startDate" validate:"required"`
+	EndDate   string              `query:"endDate" validate:"required"`
+	GroupBy   string              `query:"groupBy"` // day, week, month
+	Clients   ClientSegmentFilter // only the entries of the clients with the tag and in the segment
 }
 
 // TechnicianPaymentsFilter represents filters for the technician payments report
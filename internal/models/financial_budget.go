@@ -0,0 +1,130 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Budget is the planned income or expense of a financial category in a month, compared
+// with the entries of the month in the budget-vs-actual report
+type Budget struct {
+	ID       string             `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	TenantID string             `json:"tenantId" gorm:"type:varchar(36);not null;default:'00000000-0000-0000-0000-000000000001';uniqueIndex:idx_financial_budgets_tenant_category_month,priority:1"`
+	Type     FinancialEntryType `json:"type" gorm:"type:varchar(10);not null;uniqueIndex:idx_financial_budgets_tenant_category_month,priority:2"`
+	Category string             `json:"category" gorm:"type:varchar(50);not null;uniqueIndex:idx_financial_budgets_tenant_category_month,priority:3"`
+	Month    string             `json:"month" gorm:"type:varchar(7);not null;uniqueIndex:idx_financial_budgets_tenant_category_month,priority:4;index"` // YYYY-MM
+	Amount   float64            `json:"amount" gorm:"type:decimal(12,2);not null"`
+	Notes    string             `json:"notes" gorm:"type:text"`
+
+	// Audit
+	CreatedBy string    `json:"createdBy" gorm:"type:uuid;not null"`
+	UpdatedBy *string   `json:"updatedBy" gorm:"type:uuid"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func (b *Budget) BeforeCreate(tx *gorm.DB) error {
+	if b.ID == "" {
+		b.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (Budget) TableName() string {
+	return "financial_budgets"
+}
+
+// Variance statuses of a budget-vs-actual line, from the projection of the month
+const (
+	BudgetStatusOnTrack    = "on_track"   // projection within the threshold of the budget
+	BudgetStatusOver       = "over"       // projection above the budget
+	BudgetStatusUnder      = "under"      // projection below the budget
+	BudgetStatusUnbudgeted = "unbudgeted" // entries in a category without a budget
+)
+
+// =============== DTOs ===============
+
+// CreateBudgetRequest DTO
+type CreateBudgetRequest struct {
+	Type     FinancialEntryType `json:"type" validate:"required,oneof=income expense"`
+	Category string             `json:"category" validate:"required"`
+	Month    string             `json:"month" validate:"required"` // Format: YYYY-MM
+	Amount   float64            `json:"amount" validate:"gte=0"`
+	Notes    string             `json:"notes" validate:"max=1000"`
+}
+
+// UpdateBudgetRequest DTO; type, category and month cannot change
+type UpdateBudgetRequest struct {
+	Amount *float64 `json:"amount" validate:"omitempty,gte=0"`
+	Notes  *string  `json:"notes" validate:"omitempty,max=1000"`
+}
+
+// BudgetFilter represents filters for querying budgets
+type BudgetFilter struct {
+	Type      FinancialEntryType `query:"type"`
+	Category  string             `query:"category"`
+	FromMonth string             `query:"fromMonth"` // YYYY-MM, inclusive
+	ToMonth   string             `query:"toMonth"`
+	Page      int                `query:"page"`
+	Limit     int                `query:"limit"`
+}
+
+// BudgetVsActualFilter represents filters for the budget-vs-actual report
+type BudgetVsActualFilter struct {
+	StartMonth string             // YYYY-MM
+	EndMonth   string             // YYYY-MM
+	Type       FinancialEntryType // empty for both
+	Threshold  float64            // percent of the budget a projection may deviate and still be on track
+}
+
+// BudgetVarianceLine compares the budget of a category in a month with its entries.
+// Variance is actual minus budget; the projection extrapolates the current month from
+// its daily run rate, so past months project their actual.
+type BudgetVarianceLine struct {
+	Month             string             `json:"month"`
+	Type              FinancialEntryType `json:"type"`
+	Category          string             `json:"category"`
+	BudgetID          *string            `json:"budgetId"`
+	Budgeted          float64            `json:"budgeted"`
+	Actual            float64            `json:"actual"`
+	Variance          float64            `json:"variance"`
+	VariancePercent   *float64           `json:"variancePercent"` // nil without a budget
+	Projected         float64            `json:"projected"`
+	ProjectedVariance float64            `json:"projectedVariance"`
+	Status            string             `json:"status"`
+	// Favorable is whether the projection deviates the good way: more income or less expense
+	Favorable bool `json:"favorable"`
+}
+
+// BudgetVarianceTotals sums the lines of one entry type
+type BudgetVarianceTotals struct {
+	Budgeted          float64 `json:"budgeted"`
+	Actual            float64 `json:"actual"`
+	Variance          float64 `json:"variance"`
+	Projected         float64 `json:"projected"`
+	ProjectedVariance float64 `json:"projectedVariance"`
+}
+
+// BudgetVsActualReport is the budget-vs-actual report of a range of months
+type BudgetVsActualReport struct {
+	StartMonth string               `json:"startMonth"`
+	EndMonth   string               `json:"endMonth"`
+	AsOf       time.Time            `json:"asOf"`
+	Threshold  float64              `json:"threshold"`
+	Lines      []BudgetVarianceLine `json:"lines"`
+	Income     BudgetVarianceTotals `json:"income"`
+	Expense    BudgetVarianceTotals `json:"expense"`
+	// ProjectedBalance is the projected income minus the projected expense of the range
+	ProjectedBalance float64 `json:"projectedBalance"`
+	BudgetedBalance  float64 `json:"budgetedBalance"`
+}
+
+// BudgetActual is the total of the entries of a category in a month
+type BudgetActual struct {
+	Month    string
+	Type     FinancialEntryType
+	Category string
+	Total    float64
+}
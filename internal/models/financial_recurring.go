@@ -0,0 +1,159 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RecurringFrequency is how often a recurring entry repeats
+type RecurringFrequency string
+
+const (
+	RecurringFrequencyWeekly  RecurringFrequency = "weekly"
+	RecurringFrequencyMonthly RecurringFrequency = "monthly"
+	RecurringFrequencyYearly  RecurringFrequency = "yearly"
+)
+
+// RecurringEntryStatus is the state of a recurring entry
+type RecurringEntryStatus string
+
+const (
+	RecurringEntryStatusActive   RecurringEntryStatus = "active"
+	RecurringEntryStatusPaused   RecurringEntryStatus = "paused"
+	RecurringEntryStatusFinished RecurringEntryStatus = "finished" // past its end date
+)
+
+// RecurringEntry is a subscription or fixed cost (or income) the scheduler turns into
+// one pending FinancialEntry per occurrence. Occurrences follow StartDate: monthly and
+// yearly ones keep its day of the month, on the last day of shorter months.
+type RecurringEntry struct {
+	ID          string             `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	TenantID    string             `json:"tenantId" gorm:"type:varchar(36);not null;default:'00000000-0000-0000-0000-000000000001';index"`
+	Type        FinancialEntryType `json:"type" gorm:"type:varchar(10);not null;index"`
+	Category    string             `json:"category" gorm:"type:varchar(50);not null"`
+	Subcategory string             `json:"subcategory" gorm:"type:varchar(50)"`
+	Description string             `json:"description" gorm:"type:text;not null"`
+	Amount      float64            `json:"amount" gorm:"type:decimal(12,2);not null"`
+	Currency    string             `json:"currency" gorm:"type:varchar(3);default:BRL"`
+
+	// Schedule
+	Frequency RecurringFrequency `json:"frequency" gorm:"type:varchar(10);not null"`
+	StartDate time.Time          `json:"startDate" gorm:"type:date;not null"`
+	EndDate   *time.Time         `json:"endDate" gorm:"type:date"`          // last possible occurrence, nil runs forever
+	DueDays   int                `json:"dueDays" gorm:"not null;default:0"` // due date = occurrence + DueDays
+	// NextOccurrence is the position in the schedule (0 = StartDate) of NextDate
+	NextOccurrence int                  `json:"nextOccurrence" gorm:"not null;default:0"`
+	NextDate       time.Time            `json:"nextDate" gorm:"type:date;not null;index"`
+	Status         RecurringEntryStatus `json:"status" gorm:"type:varchar(20);not null;default:active;index"`
+	LastEntryDate  *time.Time           `json:"lastEntryDate" gorm:"type:date"`
+
+	// Optional relationships, copied to the generated entries
+	TechnicianID *string     `json:"technicianId" gorm:"type:uuid;index"`
+	Technician   *Technician `json:"technician,omitempty" gorm:"foreignKey:TechnicianID"`
+	ClientID     *string     `json:"clientId" gorm:"type:uuid;index"`
+	Client       *Client     `json:"client,omitempty" gorm:"foreignKey:ClientID"`
+
+	PaymentMethod string `json:"paymentMethod" gorm:"type:varchar(30)"`
+
+	// Audit
+	CreatedBy string         `json:"createdBy" gorm:"type:uuid;not null"`
+	UpdatedBy *string        `json:"updatedBy" gorm:"type:uuid"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+func (r *RecurringEntry) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	if r.Currency == "" {
+		r.Currency = "BRL"
+	}
+	if r.Status == "" {
+		r.Status = RecurringEntryStatusActive
+	}
+	return nil
+}
+
+func (RecurringEntry) TableName() string {
+	return "financial_recurring_entries"
+}
+
+// OccurrenceDate returns the date of the n-th occurrence (0 = StartDate)
+func (r *RecurringEntry) OccurrenceDate(n int) time.Time {
+	start := r.StartDate
+	switch r.Frequency {
+	case RecurringFrequencyWeekly:
+		return start.AddDate(0, 0, 7*n)
+	case RecurringFrequencyYearly:
+		return addMonthsClamped(start, 12*n)
+	default:
+		return addMonthsClamped(start, n)
+	}
+}
+
+// addMonthsClamped adds months keeping the day, or the last day of a shorter month
+// (Jan 31 + 1 month = Feb 28), unlike time.AddDate that overflows into the next month
+func addMonthsClamped(t time.Time, months int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(months), 1, 0, 0, 0, 0, t.Location())
+	lastDay := first.AddDate(0, 1, -1).Day()
+	day := t.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return time.Date(first.Year(), first.Month(), day, 0, 0, 0, 0, t.Location())
+}
+
+// =============== DTOs ===============
+
+// CreateRecurringEntryRequest represents the request to create a recurring entry
+type CreateRecurringEntryRequest struct {
+	Type          FinancialEntryType `json:"type" validate:"required,oneof=income expense"`
+	Category      string             `json:"category" validate:"required"`
+	Subcategory   string             `json:"subcategory"`
+	Description   string             `json:"description" validate:"required"`
+	Amount        float64            `json:"amount" validate:"required,gt=0"`
+	Frequency     RecurringFrequency `json:"frequency" validate:"required,oneof=weekly monthly yearly"`
+	StartDate     string             `json:"startDate" validate:"required"` // Format: YYYY-MM-DD
+	EndDate       string             `json:"endDate"`                       // Format: YYYY-MM-DD
+	DueDays       int                `json:"dueDays" validate:"min=0,max=365"`
+	TechnicianID  string             `json:"technicianId"`
+	ClientID      string             `json:"clientId"`
+	PaymentMethod string             `json:"paymentMethod"`
+}
+
+// UpdateRecurringEntryRequest changes the next occurrences; the schedule (frequency and
+// start date) cannot change, create a new recurring entry instead
+type UpdateRecurringEntryRequest struct {
+	Category      string  `json:"category"`
+	Subcategory   string  `json:"subcategory"`
+	Description   string  `json:"description"`
+	Amount        float64 `json:"amount" validate:"omitempty,gt=0"`
+	EndDate       *string `json:"endDate"` // "" removes the end date
+	DueDays       *int    `json:"dueDays" validate:"omitempty,min=0,max=365"`
+	TechnicianID  *string `json:"technicianId"`
+	ClientID      *string `json:"clientId"`
+	PaymentMethod *string `json:"paymentMethod"`
+}
+
+// RecurringEntryFilter represents filters for querying recurring entries
+type RecurringEntryFilter struct {
+	Type   FinancialEntryType   `query:"type"`
+	Status RecurringEntryStatus `query:"status"`
+	Page   int                  `query:"page"`
+	Limit  int                  `query:"limit"`
+}
+
+// RecurringOccurrence is an upcoming occurrence of a recurring entry
+type RecurringOccurrence struct {
+	RecurringEntryID string             `json:"recurringEntryId"`
+	Description      string             `json:"description"`
+	Type             FinancialEntryType `json:"type"`
+	Category         string             `json:"category"`
+	Amount           float64            `json:"amount"`
+	EntryDate        time.Time          `json:"entryDate"`
+	DueDate          *time.Time         `json:"dueDate"`
+}
@@ -0,0 +1,133 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Leaderboard periods
+const (
+	GamificationPeriodWeek  = "WEEK"
+	GamificationPeriodMonth = "MONTH"
+)
+
+// Badge metrics, counted over the badge period
+const (
+	BadgeMetricPoints         = "POINTS"
+	BadgeMetricClosedTickets  = "CLOSED_TICKETS"
+	BadgeMetricOnTimeCheckIns = "ON_TIME_CHECKINS"
+	BadgeMetricPromoters      = "PROMOTERS"
+)
+
+// GamificationProfile holds the choice of a technician about gamification. It is opt-in:
+// technicians without a profile, or who opted out, are left out of every leaderboard.
+type GamificationProfile struct {
+	TechnicianID string     `json:"technicianId" gorm:"type:varchar(36);primaryKey"`
+	OptedIn      bool       `json:"optedIn" gorm:"default:false;index"`
+	DisplayName  string     `json:"displayName" gorm:"type:varchar(50)"` // shown instead of the full name when set
+	OptedInAt    *time.Time `json:"optedInAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+}
+
+func (GamificationProfile) TableName() string {
+	return "gamification_profiles"
+}
+
+// GamificationBadge is awarded to the technicians whose metric reaches the threshold
+// within a week or month
+type GamificationBadge struct {
+	ID          string    `json:"id" gorm:"type:uuid;primaryKey"`
+	Code        string    `json:"code" gorm:"type:varchar(50);uniqueIndex;not null"`
+	Name        string    `json:"name" gorm:"type:varchar(100);not null"`
+	Description string    `json:"description" gorm:"type:text"`
+	Icon        string    `json:"icon" gorm:"type:varchar(100)"`
+	Metric      string    `json:"metric" gorm:"type:varchar(30);not null"`
+	Threshold   int64     `json:"threshold" gorm:"not null"`
+	Period      string    `json:"period" gorm:"type:varchar(10);not null"`
+	Active      bool      `json:"active" gorm:"default:true"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+func (b *GamificationBadge) BeforeCreate(tx *gorm.DB) error {
+	if b.ID == "" {
+		b.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (GamificationBadge) TableName() string {
+	return "gamification_badges"
+}
+
+// =============== DTOs ===============
+
+// GamificationBadgeRequest DTO
+type GamificationBadgeRequest struct {
+	Code        string `json:"code" validate:"required,max=50"`
+	Name        string `json:"name" validate:"required,max=100"`
+	Description string `json:"description" validate:"max=1000"`
+	Icon        string `json:"icon" validate:"max=100"`
+	Metric      string `json:"metric" validate:"required,oneof=POINTS CLOSED_TICKETS ON_TIME_CHECKINS PROMOTERS"`
+	Threshold   int64  `json:"threshold" validate:"required,min=1"`
+	Period      string `json:"period" validate:"required,oneof=WEEK MONTH"`
+	Active      *bool  `json:"active"`
+}
+
+// GamificationProfileRequest DTO
+type GamificationProfileRequest struct {
+	OptedIn     bool   `json:"optedIn"`
+	DisplayName string `json:"displayName" validate:"max=50"`
+}
+
+// GamificationStats are the points of a technician over a period
+type GamificationStats struct {
+	Points         int64 `json:"points"`
+	ClosedTickets  int64 `json:"closedTickets"`
+	OnTimeCheckIns int64 `json:"onTimeCheckIns"`
+	Promoters      int64 `json:"promoters"` // NPS promoter answers of clients served
+}
+
+// LeaderboardEntry is one opted-in technician of a leaderboard
+type LeaderboardEntry struct {
+	Rank         int    `json:"rank"`
+	TechnicianID string `json:"technicianId"`
+	DisplayName  string `json:"displayName"`
+	State        string `json:"state"`
+	GamificationStats
+	Badges []GamificationBadge `json:"badges"`
+}
+
+// Leaderboard DTO
+type Leaderboard struct {
+	Period  string             `json:"period"`
+	From    time.Time          `json:"from"`
+	To      time.Time          `json:"to"`
+	State   string             `json:"state,omitempty"`
+	Entries []LeaderboardEntry `json:"entries"`
+}
+
+// GamificationPeriodStats are the stats and badges of a technician in the current period
+type GamificationPeriodStats struct {
+	Period string    `json:"period"`
+	From   time.Time `json:"from"`
+	GamificationStats
+	Rank   int                 `json:"rank,omitempty"` // in the leaderboard of the region, when opted in
+	Badges []GamificationBadge `json:"badges"`
+}
+
+// MyGamification DTO for the technician app
+type MyGamification struct {
+	Profile GamificationProfile       `json:"profile"`
+	Periods []GamificationPeriodStats `json:"periods"`
+}
+
+// GamificationCandidate is an opted-in technician, loaded for the leaderboards
+type GamificationCandidate struct {
+	TechnicianID string
+	FullName     string
+	DisplayName  string
+	State        string
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// Provedores de geocodificação de endereços
+const (
+	GeocodingProviderLocal     = "local"     // só as tabelas de cidades e capitais
+	GeocodingProviderNominatim = "nominatim" // OpenStreetMap
+	GeocodingProviderGoogle    = "google"    // Google Geocoding API
+)
+
+// GeocodeCacheEntry guarda o resultado da geocodificação de um endereço normalizado, para
+// não consultar o provedor de novo. Endereços não encontrados também ficam guardados, mas
+// expiram (ver GeocodingService).
+type GeocodeCacheEntry struct {
+	Key       string    `json:"key" gorm:"type:varchar(64);primaryKey"` // SHA-256 do endereço normalizado
+	Address   string    `json:"address" gorm:"type:text"`
+	Provider  string    `json:"provider" gorm:"type:varchar(20)"`
+	Latitude  *float64  `json:"latitude" gorm:"type:double precision"`
+	Longitude *float64  `json:"longitude" gorm:"type:double precision"`
+	Precision string    `json:"precision" gorm:"type:varchar(10)"`
+	CreatedAt time.Time `json:"createdAt" gorm:"index"`
+}
+
+func (GeocodeCacheEntry) TableName() string {
+	return "geocode_cache"
+}
+
+// GeocodeResult são as coordenadas de um endereço
+type GeocodeResult struct {
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+	Precision string   `json:"precision"` // ADDRESS, CITY, STATE ou NONE
+	Provider  string   `json:"provider"`
+	Cached    bool     `json:"cached"`
+}
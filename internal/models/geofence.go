@@ -0,0 +1,153 @@
+package models
+
+import (
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Formatos de cerca
+const (
+	GeoFenceShapeCircle  = "CIRCLE"
+	GeoFenceShapePolygon = "POLYGON"
+)
+
+// Eventos de cerca
+const (
+	GeoFenceEventEntry = "ENTRY"
+	GeoFenceEventExit  = "EXIT"
+)
+
+// GeoFence é uma cerca geográfica de um site de cliente ou de um node da hierarquia:
+// um círculo (centro + raio) ou um polígono
+type GeoFence struct {
+	ID          string     `json:"id" gorm:"type:uuid;primaryKey"`
+	Name        string     `json:"name" gorm:"type:varchar(255);not null"`
+	ClientID    *string    `json:"clientId" gorm:"type:uuid;index"`
+	Client      *Client    `json:"client,omitempty" gorm:"foreignKey:ClientID"`
+	NodeID      *uint      `json:"nodeId" gorm:"index"`
+	Shape       string     `json:"shape" gorm:"type:varchar(20);not null"`
+	CenterLat   *float64   `json:"centerLat" gorm:"type:double precision"`
+	CenterLng   *float64   `json:"centerLng" gorm:"type:double precision"`
+	RadiusM     *float64   `json:"radiusM" gorm:"type:double precision"`
+	Polygon     GeoPolygon `json:"polygon" gorm:"type:jsonb;default:'[]'"`
+	NotifyEntry bool       `json:"notifyEntry" gorm:"default:true"`
+	NotifyExit  bool       `json:"notifyExit" gorm:"default:true"`
+	Active      bool       `json:"active" gorm:"default:true;index"`
+	CreatedBy   string     `json:"createdBy" gorm:"type:varchar(36)"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+}
+
+func (f *GeoFence) BeforeCreate(tx *gorm.DB) error {
+	if f.ID == "" {
+		f.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (GeoFence) TableName() string {
+	return "geo_fences"
+}
+
+// Contains verifica se o ponto está dentro da cerca
+func (f *GeoFence) Contains(lat, lng float64) bool {
+	switch f.Shape {
+	case GeoFenceShapeCircle:
+		if f.CenterLat == nil || f.CenterLng == nil || f.RadiusM == nil {
+			return false
+		}
+		return haversineMeters(*f.CenterLat, *f.CenterLng, lat, lng) <= *f.RadiusM
+	case GeoFenceShapePolygon:
+		return f.Polygon.Contains(lat, lng)
+	}
+	return false
+}
+
+// GeoFencePresence indica que o técnico está dentro da cerca desde EnteredAt
+type GeoFencePresence struct {
+	FenceID        string    `json:"fenceId" gorm:"type:uuid;primaryKey"`
+	TechnicianID   string    `json:"technicianId" gorm:"type:varchar(36);primaryKey"`
+	UserID         string    `json:"userId" gorm:"type:varchar(36);index"`
+	EnteredAt      time.Time `json:"enteredAt"`
+	LastSeenAt     time.Time `json:"lastSeenAt"`
+	LastLocationID uuid.UUID `json:"lastLocationId" gorm:"type:uuid"`
+
+	Technician *Technician `json:"technician,omitempty" gorm:"foreignKey:TechnicianID"`
+}
+
+func (GeoFencePresence) TableName() string {
+	return "geo_fence_presences"
+}
+
+// GeoFenceEvent registra a entrada ou saída de um técnico de uma cerca
+type GeoFenceEvent struct {
+	ID           string    `json:"id" gorm:"type:uuid;primaryKey"`
+	FenceID      string    `json:"fenceId" gorm:"type:uuid;not null;index:idx_geo_fence_event_fence_time,priority:1"`
+	FenceName    string    `json:"fenceName" gorm:"-"`
+	TechnicianID string    `json:"technicianId" gorm:"type:varchar(36);not null;index"`
+	UserID       string    `json:"userId" gorm:"type:varchar(36)"`
+	EventType    string    `json:"eventType" gorm:"type:varchar(10);not null"`
+	LocationID   uuid.UUID `json:"locationId" gorm:"type:uuid"`
+	Latitude     float64   `json:"latitude" gorm:"type:double precision"`
+	Longitude    float64   `json:"longitude" gorm:"type:double precision"`
+	DwellSeconds *int64    `json:"dwellSeconds,omitempty"` // tempo dentro da cerca, na saída
+	OccurredAt   time.Time `json:"occurredAt" gorm:"not null;index:idx_geo_fence_event_fence_time,priority:2,sort:desc"`
+}
+
+func (e *GeoFenceEvent) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (GeoFenceEvent) TableName() string {
+	return "geo_fence_events"
+}
+
+// haversineMeters calcula a distância entre dois pontos em metros
+func haversineMeters(lat1, lng1, lat2, lng2 float64) float64 {
+	const earthRadius = 6371000
+	dLat := (lat2 - lat1) * math.Pi / 180
+	dLng := (lng2 - lng1) * math.Pi / 180
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*math.Pi/180)*math.Cos(lat2*math.Pi/180)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return earthRadius * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// =============== DTOs ===============
+
+// GeoFenceRequest DTO; centerLat, centerLng e radiusM para CIRCLE, polygon (3+ pontos) para POLYGON
+type GeoFenceRequest struct {
+	Name        string     `json:"name" validate:"required,max=255"`
+	ClientID    *string    `json:"clientId"`
+	NodeID      *uint      `json:"nodeId"`
+	Shape       string     `json:"shape" validate:"required,oneof=CIRCLE POLYGON"`
+	CenterLat   *float64   `json:"centerLat" validate:"omitempty,min=-90,max=90"`
+	CenterLng   *float64   `json:"centerLng" validate:"omitempty,min=-180,max=180"`
+	RadiusM     *float64   `json:"radiusM" validate:"omitempty,gt=0,max=100000"`
+	Polygon     GeoPolygon `json:"polygon"`
+	NotifyEntry *bool      `json:"notifyEntry"`
+	NotifyExit  *bool      `json:"notifyExit"`
+	Active      *bool      `json:"active"`
+}
+
+// GeoFenceFilters DTO
+type GeoFenceFilters struct {
+	ClientID string
+	NodeID   uint
+	Inactive bool // inclui cercas inativas
+}
+
+// GeoFenceTechnician é um técnico dentro de uma cerca
+type GeoFenceTechnician struct {
+	TechnicianID string    `json:"technicianId"`
+	Name         string    `json:"name"`
+	Status       string    `json:"status"`
+	EnteredAt    time.Time `json:"enteredAt"`
+	LastSeenAt   time.Time `json:"lastSeenAt"`
+	DwellMinutes int       `json:"dwellMinutes"`
+}
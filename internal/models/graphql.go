@@ -0,0 +1,18 @@
+package models
+
+// TicketFinancialTotals sums the financial entries of a ticket, cancelled ones excluded
+type TicketFinancialTotals struct {
+	TicketID string  `json:"ticketId"`
+	Income   float64 `json:"income"`
+	Expense  float64 `json:"expense"`
+	Balance  float64 `json:"balance"`
+}
+
+// FinancialSummary is the financial summary of the GraphQL API
+type FinancialSummary struct {
+	TotalIncome     float64 `json:"totalIncome"`
+	TotalExpense    float64 `json:"totalExpense"`
+	Balance         float64 `json:"balance"`
+	PendingPayments int64   `json:"pendingPayments"`
+	OverdueCount    int64   `json:"overdueCount"`
+}
@@ -81,6 +81,24 @@ type AccessAuditLog struct {
 	CreatedAt  time.Time       `json:"createdAt"`
 }
 
+// AccessScope restricts the tickets, clients and technicians a user sees to the hierarchy
+// subtrees of the user's memberships: a membership on a node grants its descendants.
+// Records without node belong to no subtree and stay visible to everyone.
+type AccessScope struct {
+	UserID       string
+	Unrestricted bool // admins and integrations see every node
+}
+
+// NewAccessScope returns the scope of the user; ADMIN and INTEGRATION bypass the hierarchy
+func NewAccessScope(userID, role string) *AccessScope {
+	return &AccessScope{UserID: userID, Unrestricted: role == RoleAdmin || role == RoleIntegration}
+}
+
+// Restricted reports whether queries must be filtered by the scope
+func (s *AccessScope) Restricted() bool {
+	return s != nil && !s.Unrestricted
+}
+
 // ======= REQUEST/RESPONSE DTOs =======
 
 // CreateHierarchyRequest represents the request to create a hierarchy
@@ -147,6 +165,13 @@ type SimulateAccessResponse struct {
 	Impact      ImpactSummary  `json:"impact"`
 }
 
+// NodePermission is a permission code granted to a user on a node and its descendants
+type NodePermission struct {
+	NodeID   uint   `json:"nodeId"`
+	NodePath string `json:"nodePath"`
+	Code     string `json:"code"`
+}
+
 // UserAccessView represents a user's current access state
 type UserAccessView struct {
 	Nodes       []NodeAccess      `json:"nodes"`
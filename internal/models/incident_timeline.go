@@ -0,0 +1,98 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Incident timeline event sources
+const (
+	TimelineSourceDeploy    = "DEPLOY"
+	TimelineSourceErrorRate = "ERROR_RATE" // 5xx spike in the request metrics
+	TimelineSourceError     = "ERROR"      // CRITICAL error log (panics)
+	TimelineSourceJob       = "JOB"        // failed attachment processing or remediation
+	TimelineSourceSecurity  = "SECURITY"   // failed security events, grouped per minute
+	TimelineSourceAlert     = "ALERT"      // SYSTEM alerts (cache down, job backlog)
+	TimelineSourceIncident  = "INCIDENT"   // status page incidents
+)
+
+// DeployMarker records a deploy, posted by the CI pipeline, so incidents can be
+// correlated with releases
+type DeployMarker struct {
+	ID          string    `json:"id" gorm:"type:uuid;primaryKey"`
+	Version     string    `json:"version" gorm:"type:varchar(100);not null"`
+	Environment string    `json:"environment" gorm:"type:varchar(50);index"`
+	CommitSHA   string    `json:"commitSha" gorm:"type:varchar(64)"`
+	Description string    `json:"description" gorm:"type:text"`
+	DeployedAt  time.Time `json:"deployedAt" gorm:"not null;index"`
+	CreatedBy   string    `json:"createdBy" gorm:"type:varchar(36)"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+func (d *DeployMarker) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == "" {
+		d.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (DeployMarker) TableName() string {
+	return "deploy_markers"
+}
+
+// =============== DTOs ===============
+
+// CreateDeployMarkerRequest DTO
+type CreateDeployMarkerRequest struct {
+	Version     string     `json:"version" validate:"required,max=100"`
+	Environment string     `json:"environment" validate:"max=50"`
+	CommitSHA   string     `json:"commitSha" validate:"max=64"`
+	Description string     `json:"description" validate:"max=2000"`
+	DeployedAt  *time.Time `json:"deployedAt"` // default now
+}
+
+// ErrorRateBucket is the request count and 5xx count of one minute
+type ErrorRateBucket struct {
+	Bucket  time.Time `json:"bucket"`
+	Samples int64     `json:"samples"`
+	Errors  int64     `json:"errors"`
+}
+
+// RouteErrors is the 5xx count of a route
+type RouteErrors struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Errors int64  `json:"errors"`
+}
+
+// SecurityEventBucket groups the failed security events of one action in one minute
+type SecurityEventBucket struct {
+	Bucket   time.Time `json:"bucket"`
+	Action   string    `json:"action"`
+	Attempts int64     `json:"attempts"`
+	Accounts int64     `json:"accounts"`
+	IPs      int64     `json:"ips"`
+}
+
+// IncidentTimelineEvent is one entry of the timeline; EndAt is set for events spanning time
+type IncidentTimelineEvent struct {
+	At           time.Time  `json:"at"`
+	EndAt        *time.Time `json:"endAt,omitempty"`
+	Source       string     `json:"source"`
+	Severity     string     `json:"severity"` // INFO, WARNING, CRITICAL
+	Title        string     `json:"title"`
+	Details      string     `json:"details,omitempty"`
+	ResourceType string     `json:"resourceType,omitempty"`
+	ResourceID   string     `json:"resourceId,omitempty"`
+}
+
+// IncidentTimeline is the cross-module timeline of a time window, oldest event first
+type IncidentTimeline struct {
+	From        time.Time               `json:"from"`
+	To          time.Time               `json:"to"`
+	GeneratedAt time.Time               `json:"generatedAt"`
+	Counts      map[string]int          `json:"counts"` // events per source
+	Events      []IncidentTimelineEvent `json:"events"`
+}
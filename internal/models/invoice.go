@@ -0,0 +1,104 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// Invoice kinds: service invoices (NFS-e, municipal) and product invoices (NF-e, state)
+const (
+	InvoiceKindNFSe = "NFSE"
+	InvoiceKindNFe  = "NFE"
+)
+
+// Lifecycle of an invoice: queued through the outbox when created, then issued by the
+// driver. PROCESSING invoices were accepted by the provider and are queried until they
+// are issued or rejected.
+const (
+	InvoiceStatusPending    = "PENDING"
+	InvoiceStatusProcessing = "PROCESSING"
+	InvoiceStatusIssued     = "ISSUED"
+	InvoiceStatusError      = "ERROR"    // the attempt failed before the provider answered, retried
+	InvoiceStatusRejected   = "REJECTED" // refused by the provider, retried by hand after fixing it
+	InvoiceStatusCancelled  = "CANCELLED"
+)
+
+// Invoice is the fiscal document of an income entry, issued with the provider of the
+// configured driver. The XML and PDF it returns are kept as stored files of the invoice.
+type Invoice struct {
+	ID          string          `json:"id" gorm:"type:uuid;primaryKey"`
+	Kind        string          `json:"kind" gorm:"type:varchar(10);not null"`
+	EntryID     string          `json:"entryId" gorm:"type:uuid;not null;index"`
+	ClientID    string          `json:"clientId" gorm:"type:uuid;not null;index"`
+	TicketID    *string         `json:"ticketId" gorm:"type:uuid;index"`
+	Amount      decimal.Decimal `json:"amount" gorm:"type:decimal(12,2);not null"`
+	ISSRate     decimal.Decimal `json:"issRate" gorm:"type:decimal(5,2);not null"`
+	ISSAmount   decimal.Decimal `json:"issAmount" gorm:"type:decimal(12,2);not null"`
+	ServiceCode string          `json:"serviceCode" gorm:"type:varchar(20)"`
+	Description string          `json:"description" gorm:"type:text;not null"`
+	Status      string          `json:"status" gorm:"type:varchar(20);not null;index"`
+	Driver      string          `json:"driver" gorm:"type:varchar(20);not null"`
+
+	// Answer of the provider
+	Protocol         string     `json:"protocol" gorm:"type:varchar(100)"`
+	Number           string     `json:"number" gorm:"type:varchar(30)"`
+	VerificationCode string     `json:"verificationCode" gorm:"type:varchar(60)"`
+	IssuedAt         *time.Time `json:"issuedAt"`
+	XMLFileID        *string    `json:"xmlFileId" gorm:"type:uuid"`
+	PDFFileID        *string    `json:"pdfFileId" gorm:"type:uuid"`
+
+	// Issuance attempts, counted across retries
+	Attempts      int        `json:"attempts" gorm:"not null;default:0"`
+	LastAttemptAt *time.Time `json:"lastAttemptAt"`
+	LastError     string     `json:"lastError" gorm:"type:text"`
+
+	CancelledAt  *time.Time `json:"cancelledAt"`
+	CancelReason string     `json:"cancelReason" gorm:"type:text"`
+
+	CreatedBy string    `json:"createdBy" gorm:"type:varchar(36);not null"`
+	CreatedAt time.Time `json:"createdAt" gorm:"index"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	Entry  *FinancialEntry `json:"entry,omitempty" gorm:"foreignKey:EntryID"`
+	Client *Client         `json:"client,omitempty" gorm:"foreignKey:ClientID"`
+}
+
+func (i *Invoice) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == "" {
+		i.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (Invoice) TableName() string {
+	return "invoices"
+}
+
+// InvoiceIssuePayload is the payload of the invoice issuance topic
+type InvoiceIssuePayload struct {
+	InvoiceID string `json:"invoiceId"`
+}
+
+// =============== DTOs ===============
+
+// CreateInvoiceRequest DTO: invoices the income entry, which must have a client
+type CreateInvoiceRequest struct {
+	EntryID     string `json:"entryId" validate:"required,uuid"`
+	Kind        string `json:"kind" validate:"omitempty,oneof=NFSE NFE"` // default NFSE
+	Description string `json:"description" validate:"max=2000"`          // default the entry description
+}
+
+// CancelInvoiceRequest DTO
+type CancelInvoiceRequest struct {
+	Reason string `json:"reason" validate:"required,min=15,max=255"`
+}
+
+// InvoiceFilter DTO
+type InvoiceFilter struct {
+	Status   string
+	EntryID  string
+	ClientID string
+}
@@ -0,0 +1,68 @@
+package models
+
+import "time"
+
+// Kinds of approval in the my-work inbox
+const (
+	ApprovalKindPaymentBatch   = "PAYMENT_BATCH"
+	ApprovalKindDiscount       = "DISCOUNT"
+	ApprovalKindBudgetOverride = "BUDGET_OVERRIDE"
+	ApprovalKindStockMovement  = "STOCK_MOVEMENT"
+)
+
+// MyWorkSummary is the unified inbox of an office user: what waits on them, in one call
+type MyWorkSummary struct {
+	Approvals     MyWorkApprovals     `json:"approvals"`
+	Tickets       MyWorkTickets       `json:"tickets"`
+	Mentions      MyWorkMentions      `json:"mentions"`
+	Notifications MyWorkNotifications `json:"notifications"`
+}
+
+// MyWorkApprovals lists the pending approvals the user can decide, newest first
+type MyWorkApprovals struct {
+	Total           int64            `json:"total"`
+	PaymentBatches  int64            `json:"paymentBatches"`
+	Discounts       int64            `json:"discounts"`
+	BudgetOverrides int64            `json:"budgetOverrides"`
+	StockMovements  int64            `json:"stockMovements"`
+	Items           []MyWorkApproval `json:"items"`
+}
+
+// MyWorkApproval is a pending approval of any kind
+type MyWorkApproval struct {
+	Kind        string    `json:"kind"`
+	ID          string    `json:"id"`
+	TicketID    *string   `json:"ticketId,omitempty"`
+	Description string    `json:"description"`
+	RequestedBy string    `json:"requestedBy"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// MyWorkTickets are the open tickets in the team queues of the user's nodes
+type MyWorkTickets struct {
+	Total int64          `json:"total"`
+	Items []MyWorkTicket `json:"items"`
+}
+
+// MyWorkTicket is an open ticket of the inbox
+type MyWorkTicket struct {
+	ID        string         `json:"id"`
+	OSNumber  string         `json:"osNumber"`
+	Status    TicketStatus   `json:"status"`
+	Priority  TicketPriority `json:"priority"`
+	Client    string         `json:"client,omitempty"`
+	DueDate   *time.Time     `json:"dueDate"`
+	CreatedAt time.Time      `json:"createdAt"`
+}
+
+// MyWorkMentions are the recent ticket comments mentioning the user (@ + e-mail name)
+type MyWorkMentions struct {
+	Total int64           `json:"total"`
+	Items []TicketComment `json:"items"`
+}
+
+// MyWorkNotifications are the open operational alerts owned by the user or by nobody
+type MyWorkNotifications struct {
+	Unread int64   `json:"unread"`
+	Items  []Alert `json:"items"`
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Notification events
+const (
+	NotificationTicketAssigned       = "TICKET_ASSIGNED"
+	NotificationSLABreach            = "SLA_BREACH"
+	NotificationLowStock             = "LOW_STOCK"
+	NotificationPaymentBatchApproved = "PAYMENT_BATCH_APPROVED"
+	NotificationLowSatisfaction      = "LOW_SATISFACTION" // client rated a ticket 1 or 2
+	NotificationReportFailed         = "REPORT_FAILED"    // a scheduled report was not delivered
+)
+
+// Notification delivery channels
+const (
+	NotificationChannelDatabase = "DATABASE" // in-app inbox
+	NotificationChannelEmail    = "EMAIL"
+	NotificationChannelWebhook  = "WEBHOOK"
+)
+
+// Notification is an in-app message to one user about an event of interest to them
+type Notification struct {
+	ID           string     `json:"id" gorm:"type:uuid;primaryKey"`
+	UserID       string     `json:"userId" gorm:"type:varchar(36);not null;index:idx_notifications_user_read,priority:1"`
+	Event        string     `json:"event" gorm:"type:varchar(50);not null;index"`
+	Title        string     `json:"title" gorm:"type:varchar(255);not null"`
+	Message      string     `json:"message" gorm:"type:text"`
+	ResourceType string     `json:"resourceType" gorm:"type:varchar(50)"`
+	ResourceID   string     `json:"resourceId" gorm:"type:varchar(36)"`
+	ReadAt       *time.Time `json:"readAt" gorm:"index:idx_notifications_user_read,priority:2"`
+	CreatedAt    time.Time  `json:"createdAt" gorm:"index"`
+}
+
+func (n *Notification) BeforeCreate(tx *gorm.DB) error {
+	if n.ID == "" {
+		n.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (Notification) TableName() string {
+	return "notifications"
+}
+
+// =============== DTOs ===============
+
+// NotificationFilters DTO
+type NotificationFilters struct {
+	UnreadOnly bool
+	Event      string
+}
+
+// NotificationReadResult is returned when notifications are marked as read
+type NotificationReadResult struct {
+	Updated int64 `json:"updated"`
+	Unread  int64 `json:"unread"`
+}
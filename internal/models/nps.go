@@ -0,0 +1,189 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type NPSCampaignStatus string
+
+const (
+	NPSCampaignDraft   NPSCampaignStatus = "DRAFT"
+	NPSCampaignSending NPSCampaignStatus = "SENDING"
+	NPSCampaignOpen    NPSCampaignStatus = "OPEN"
+	NPSCampaignClosed  NPSCampaignStatus = "CLOSED"
+)
+
+// Survey delivery channels
+const (
+	NPSChannelEmail = "EMAIL"
+	NPSChannelLink  = "LINK" // links are generated and shared manually
+)
+
+// NPSCampaign is a periodic Net Promoter Score survey sent to a sample of clients
+type NPSCampaign struct {
+	ID          string            `json:"id" gorm:"type:uuid;primaryKey"`
+	Name        string            `json:"name" gorm:"type:varchar(150);not null"`
+	Question    string            `json:"question" gorm:"type:text"`
+	Channel     string            `json:"channel" gorm:"type:varchar(20);not null;default:EMAIL"`
+	Status      NPSCampaignStatus `json:"status" gorm:"type:varchar(20);not null;default:DRAFT;index"`
+	PeriodStart time.Time         `json:"periodStart" gorm:"not null;index"`
+	PeriodEnd   time.Time         `json:"periodEnd" gorm:"not null"`
+
+	// Sample selection
+	SampleSize      int    `json:"sampleSize" gorm:"not null"`
+	NodeID          *uint  `json:"nodeId" gorm:"index"`                   // clients with tickets in this node subtree
+	State           string `json:"state" gorm:"type:varchar(2)"`          // client region (UF)
+	ActiveSinceDays int    `json:"activeSinceDays" gorm:"default:180"`    // clients with tickets in the last N days
+	CooldownDays    int    `json:"cooldownDays" gorm:"default:90"`        // skip clients surveyed recently
+	ClientTagID     string `json:"clientTagId" gorm:"type:varchar(36)"`   // clients with this tag
+	ClientSegment   string `json:"clientSegment" gorm:"type:varchar(40)"` // clients in this segment, e.g. CHURN_RISK:HIGH
+
+	InvitationCount int        `json:"invitationCount" gorm:"default:0"`
+	CreatedBy       string     `json:"createdBy" gorm:"type:varchar(36)"`
+	SentAt          *time.Time `json:"sentAt"`
+	ClosedAt        *time.Time `json:"closedAt"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	UpdatedAt       time.Time  `json:"updatedAt"`
+}
+
+func (c *NPSCampaign) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == "" {
+		c.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (NPSCampaign) TableName() string {
+	return "nps_campaigns"
+}
+
+// NPSInvitation is the survey sent to one client; the token authorizes the public answer
+type NPSInvitation struct {
+	ID          string     `json:"id" gorm:"type:uuid;primaryKey"`
+	CampaignID  string     `json:"campaignId" gorm:"type:uuid;not null;index"`
+	ClientID    string     `json:"clientId" gorm:"type:uuid;not null;index"`
+	Token       string     `json:"-" gorm:"type:varchar(64);uniqueIndex;not null"`
+	State       string     `json:"state" gorm:"type:varchar(2);index"` // client region when invited
+	Channel     string     `json:"channel" gorm:"type:varchar(20)"`
+	SentTo      string     `json:"sentTo" gorm:"type:varchar(255)"`
+	SentAt      *time.Time `json:"sentAt"`
+	SendError   string     `json:"sendError,omitempty" gorm:"type:text"`
+	Score       *int       `json:"score"`
+	Comment     string     `json:"comment" gorm:"type:text"`
+	RespondedAt *time.Time `json:"respondedAt" gorm:"index"`
+	CreatedAt   time.Time  `json:"createdAt"`
+
+	Client *Client `json:"client,omitempty" gorm:"foreignKey:ClientID"`
+}
+
+func (i *NPSInvitation) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == "" {
+		i.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (NPSInvitation) TableName() string {
+	return "nps_invitations"
+}
+
+// =============== DTOs ===============
+
+// CreateNPSCampaignRequest DTO
+type CreateNPSCampaignRequest struct {
+	Name            string `json:"name" validate:"required,max=150"`
+	Question        string `json:"question" validate:"max=500"`
+	Channel         string `json:"channel" validate:"omitempty,oneof=EMAIL LINK"`
+	PeriodStart     string `json:"periodStart" validate:"required"` // YYYY-MM-DD
+	PeriodEnd       string `json:"periodEnd" validate:"required"`   // YYYY-MM-DD
+	SampleSize      int    `json:"sampleSize" validate:"required,min=1,max=5000"`
+	NodeID          *uint  `json:"nodeId"`
+	State           string `json:"state" validate:"omitempty,len=2"`
+	ActiveSinceDays int    `json:"activeSinceDays" validate:"omitempty,min=1,max=3650"`
+	CooldownDays    *int   `json:"cooldownDays" validate:"omitempty,min=0,max=365"`
+	ClientTagID     string `json:"clientTagId" validate:"omitempty,uuid"`
+	ClientSegment   string `json:"clientSegment" validate:"omitempty,max=40"` // DIMENSION:VALUE
+}
+
+// NPSSurveyView is what the public survey page shows
+type NPSSurveyView struct {
+	CampaignName string     `json:"campaignName"`
+	Question     string     `json:"question"`
+	ClientName   string     `json:"clientName"`
+	Answered     bool       `json:"answered"`
+	Closed       bool       `json:"closed"`
+	Score        *int       `json:"score,omitempty"`
+	RespondedAt  *time.Time `json:"respondedAt,omitempty"`
+}
+
+// NPSAnswerRequest DTO
+type NPSAnswerRequest struct {
+	Score   *int   `json:"score" validate:"required,min=0,max=10"`
+	Comment string `json:"comment" validate:"max=2000"`
+}
+
+// NPSScore aggregates answers: NPS = %promoters (9-10) - %detractors (0-6)
+type NPSScore struct {
+	Responses  int     `json:"responses"`
+	Promoters  int     `json:"promoters"`
+	Passives   int     `json:"passives"`
+	Detractors int     `json:"detractors"`
+	NPS        float64 `json:"nps"`
+}
+
+// NPSRegionScore is the NPS of a region (client UF)
+type NPSRegionScore struct {
+	State string `json:"state"`
+	NPSScore
+}
+
+// NPSCampaignResults DTO
+type NPSCampaignResults struct {
+	Campaign     NPSCampaign      `json:"campaign"`
+	Invited      int              `json:"invited"`
+	Delivered    int              `json:"delivered"`
+	ResponseRate float64          `json:"responseRate"` // percentage of invited
+	Overall      NPSScore         `json:"overall"`
+	Distribution [11]int          `json:"distribution"` // answers per score 0..10
+	ByRegion     []NPSRegionScore `json:"byRegion"`
+	Comments     []NPSComment     `json:"comments"`
+}
+
+// NPSComment DTO
+type NPSComment struct {
+	ClientName  string    `json:"clientName"`
+	State       string    `json:"state"`
+	Score       int       `json:"score"`
+	Comment     string    `json:"comment"`
+	RespondedAt time.Time `json:"respondedAt"`
+}
+
+// NPSTrendPoint is the NPS of one period
+type NPSTrendPoint struct {
+	Period string `json:"period"` // 2024-01 (month) or 2024-Q1 (quarter)
+	NPSScore
+}
+
+// NPSResponse is a raw answer used for aggregation
+type NPSResponse struct {
+	Score       int
+	State       string
+	RespondedAt time.Time
+}
+
+// NPSDashboard DTO
+type NPSDashboard struct {
+	Current  NPSScore         `json:"current"` // current quarter
+	Previous NPSScore         `json:"previous"`
+	Trend    []NPSTrendPoint  `json:"trend"` // last 12 months
+	ByRegion []NPSRegionScore `json:"byRegion"`
+}
+
+// NPSInvitationResponse DTO exposes the survey link so it can be shared manually
+type NPSInvitationResponse struct {
+	NPSInvitation
+	Link string `json:"link"`
+}
@@ -0,0 +1,175 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// On-call page statuses
+const (
+	OnCallPagePending      = "PENDING"      // waiting for the paged technician to acknowledge
+	OnCallPageAcknowledged = "ACKNOWLEDGED" // someone took the ticket
+	OnCallPageExhausted    = "EXHAUSTED"    // the whole escalation order timed out
+	OnCallPageCancelled    = "CANCELLED"
+)
+
+// Timeline events of the priority dispatch
+const (
+	TicketEventOnCallPaged        = "oncall.paged"
+	TicketEventOnCallEscalated    = "oncall.escalated"
+	TicketEventOnCallAcknowledged = "oncall.acknowledged"
+	TicketEventOnCallExhausted    = "oncall.exhausted"
+)
+
+// OnCallSchedule is the on-call rotation of a region (client UF); an empty State is the
+// fallback for regions without a schedule of their own. The primary changes every
+// ShiftHours counted from RotationStart, in member order; when the primary doesn't
+// acknowledge, the page escalates to the next members in that same order.
+type OnCallSchedule struct {
+	ID                string    `json:"id" gorm:"type:uuid;primaryKey"`
+	Name              string    `json:"name" gorm:"type:varchar(100);not null"`
+	State             string    `json:"state" gorm:"type:varchar(2);index"`
+	Timezone          string    `json:"timezone" gorm:"type:varchar(50);not null;default:America/Sao_Paulo"`
+	BusinessStartHour int       `json:"businessStartHour" gorm:"not null;default:8"` // weekdays, local time
+	BusinessEndHour   int       `json:"businessEndHour" gorm:"not null;default:18"`
+	RotationStart     time.Time `json:"rotationStart" gorm:"not null"`
+	ShiftHours        int       `json:"shiftHours" gorm:"not null;default:168"`
+	AckTimeoutMinutes int       `json:"ackTimeoutMinutes" gorm:"not null;default:15"`
+	Active            bool      `json:"active" gorm:"not null;default:true"`
+	CreatedAt         time.Time `json:"createdAt"`
+	UpdatedAt         time.Time `json:"updatedAt"`
+
+	Members []OnCallMember `json:"members,omitempty" gorm:"foreignKey:ScheduleID"`
+}
+
+func (s *OnCallSchedule) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (OnCallSchedule) TableName() string {
+	return "on_call_schedules"
+}
+
+// OnCallMember is a technician in a rotation; Position is the rotation and escalation order
+type OnCallMember struct {
+	ID           uint   `json:"id" gorm:"primaryKey;autoIncrement"`
+	ScheduleID   string `json:"scheduleId" gorm:"type:uuid;not null;index"`
+	TechnicianID string `json:"technicianId" gorm:"type:varchar(36);not null;index"`
+	Position     int    `json:"position" gorm:"not null"`
+
+	Technician *Technician `json:"technician,omitempty" gorm:"foreignKey:TechnicianID"`
+}
+
+func (OnCallMember) TableName() string {
+	return "on_call_members"
+}
+
+// OnCallPage is a priority dispatch of a ticket to the on-call technicians. Level is the
+// step of the escalation order currently paged.
+type OnCallPage struct {
+	ID                   string     `json:"id" gorm:"type:uuid;primaryKey"`
+	TicketID             string     `json:"ticketId" gorm:"type:uuid;not null;index"`
+	ScheduleID           string     `json:"scheduleId" gorm:"type:uuid;not null;index"`
+	Status               string     `json:"status" gorm:"type:varchar(20);not null;index"`
+	Level                int        `json:"level" gorm:"not null;default:0"`
+	TechnicianID         string     `json:"technicianId" gorm:"type:varchar(36)"` // currently paged
+	Reason               string     `json:"reason" gorm:"type:text"`
+	OutsideBusinessHours bool       `json:"outsideBusinessHours"`
+	RequestedBy          string     `json:"requestedBy" gorm:"type:varchar(36)"`
+	AckDeadline          time.Time  `json:"ackDeadline" gorm:"index"`
+	AcknowledgedAt       *time.Time `json:"acknowledgedAt"`
+	AcknowledgedBy       string     `json:"acknowledgedBy" gorm:"type:varchar(36)"`
+	ClosedAt             *time.Time `json:"closedAt"` // acknowledged, exhausted or cancelled
+	CreatedAt            time.Time  `json:"createdAt"`
+	UpdatedAt            time.Time  `json:"updatedAt"`
+
+	Ticket     *Ticket             `json:"ticket,omitempty" gorm:"foreignKey:TicketID"`
+	Schedule   *OnCallSchedule     `json:"schedule,omitempty" gorm:"foreignKey:ScheduleID"`
+	Technician *Technician         `json:"technician,omitempty" gorm:"foreignKey:TechnicianID"`
+	Attempts   []OnCallPageAttempt `json:"attempts,omitempty" gorm:"foreignKey:PageID"`
+}
+
+func (p *OnCallPage) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == "" {
+		p.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (OnCallPage) TableName() string {
+	return "on_call_pages"
+}
+
+// OnCallPageAttempt records who was paged at each escalation level and through which channels
+type OnCallPageAttempt struct {
+	ID           uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	PageID       string    `json:"pageId" gorm:"type:uuid;not null;index"`
+	Level        int       `json:"level"`
+	TechnicianID string    `json:"technicianId" gorm:"type:varchar(36)"`
+	Channels     string    `json:"channels" gorm:"type:varchar(50)"`  // delivered, e.g. "PUSH,SMS"
+	Errors       string    `json:"errors,omitempty" gorm:"type:text"` // failed deliveries
+	SentAt       time.Time `json:"sentAt"`
+}
+
+func (OnCallPageAttempt) TableName() string {
+	return "on_call_page_attempts"
+}
+
+// =============== DTOs ===============
+
+// OnCallScheduleRequest DTO; TechnicianIDs are in rotation and escalation order
+type OnCallScheduleRequest struct {
+	Name              string     `json:"name" validate:"required,max=100"`
+	State             string     `json:"state" validate:"omitempty,len=2"`
+	Timezone          string     `json:"timezone"`
+	BusinessStartHour *int       `json:"businessStartHour" validate:"omitempty,min=0,max=23"`
+	BusinessEndHour   *int       `json:"businessEndHour" validate:"omitempty,min=1,max=24"`
+	RotationStart     *time.Time `json:"rotationStart"`
+	ShiftHours        int        `json:"shiftHours" validate:"omitempty,min=1"`
+	AckTimeoutMinutes int        `json:"ackTimeoutMinutes" validate:"omitempty,min=1,max=240"`
+	Active            *bool      `json:"active"`
+	TechnicianIDs     []string   `json:"technicianIds" validate:"required,min=1,dive,required"`
+}
+
+// PriorityDispatchRequest DTO; Force pages the on-call during business hours too
+type PriorityDispatchRequest struct {
+	Reason string `json:"reason" validate:"max=1000"`
+	Force  bool   `json:"force"`
+}
+
+// OnCallPageFilters DTO
+type OnCallPageFilters struct {
+	Status       string
+	TicketID     string
+	TechnicianID string
+}
+
+// OnCallNow is who is on call in a schedule at a given moment
+type OnCallNow struct {
+	ScheduleID      string        `json:"scheduleId"`
+	ScheduleName    string        `json:"scheduleName"`
+	State           string        `json:"state"`
+	At              time.Time     `json:"at"`
+	ShiftEndsAt     time.Time     `json:"shiftEndsAt"`
+	BusinessHours   bool          `json:"businessHours"`
+	EscalationOrder []OnCallEntry `json:"escalationOrder"` // primary first
+}
+
+// OnCallEntry is a technician in the escalation order
+type OnCallEntry struct {
+	Level          int    `json:"level"`
+	TechnicianID   string `json:"technicianId"`
+	TechnicianName string `json:"technicianName"`
+}
+
+// OnCallEscalationResult summarizes an escalation pass
+type OnCallEscalationResult struct {
+	Escalated int      `json:"escalated"`
+	Exhausted int      `json:"exhausted"`
+	Errors    []string `json:"errors,omitempty"`
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Outbox topics: the follow-up work of a change, enqueued in the transaction of the change
+const (
+	OutboxTopicTicketIncome    = "financial.ticket_income"  // income entry of a closed ticket
+	OutboxTopicTicketStock     = "stock.ticket_consumption" // consumption of the parts reserved for a closed ticket
+	OutboxTopicInvoiceIssue    = "fiscal.invoice_issue"     // issuance of an invoice with the provider
+	OutboxTopicFinancialExport = "export.financial"         // generation of an accounting export file
+)
+
+// Outbox event statuses
+const (
+	OutboxPending   = "PENDING" // waiting for its first attempt or a retry
+	OutboxProcessed = "PROCESSED"
+	OutboxFailed    = "FAILED" // out of attempts, retried by hand
+)
+
+// OutboxEvent is work another module must do because of a committed change. It is
+// written in the same transaction as the change, so the work can't be lost or done for a
+// change that rolled back, and processed by the outbox dispatcher with retries.
+type OutboxEvent struct {
+	ID            string     `json:"id" gorm:"type:uuid;primaryKey"`
+	TenantID      string     `json:"tenantId" gorm:"type:varchar(36);not null;default:'00000000-0000-0000-0000-000000000001';index"`
+	Topic         string     `json:"topic" gorm:"type:varchar(50);not null;index"`
+	AggregateType string     `json:"aggregateType" gorm:"type:varchar(30);not null"`
+	AggregateID   string     `json:"aggregateId" gorm:"type:varchar(36);not null;index"`
+	Payload       string     `json:"payload" gorm:"type:text;not null"`
+	Status        string     `json:"status" gorm:"type:varchar(20);not null;default:PENDING;index"`
+	Attempts      int        `json:"attempts" gorm:"default:0"`
+	NextAttemptAt *time.Time `json:"nextAttemptAt" gorm:"index"`
+	LastAttemptAt *time.Time `json:"lastAttemptAt"`
+	Error         string     `json:"error" gorm:"type:text"`
+	Result        string     `json:"result" gorm:"type:varchar(255)"` // what the handler did
+	ProcessedAt   *time.Time `json:"processedAt"`
+	CreatedAt     time.Time  `json:"createdAt" gorm:"index"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+}
+
+func (e *OutboxEvent) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (OutboxEvent) TableName() string {
+	return "outbox_events"
+}
+
+// NewOutboxEvent builds a pending event of the aggregate with the JSON payload
+func NewOutboxEvent(topic, aggregateType, aggregateID string, payload interface{}) (*OutboxEvent, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &OutboxEvent{
+		Topic:         topic,
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		Payload:       string(data),
+		Status:        OutboxPending,
+		NextAttemptAt: &now,
+	}, nil
+}
+
+// TicketClosedPayload is the payload of the ticket closing topics
+type TicketClosedPayload struct {
+	TicketID string    `json:"ticketId"`
+	ClosedBy string    `json:"closedBy,omitempty"` // empty when closed by the system
+	ClosedAt time.Time `json:"closedAt"`
+}
+
+// OutboxEventFilter DTO
+type OutboxEventFilter struct {
+	Topic       string
+	Status      string
+	AggregateID string
+}
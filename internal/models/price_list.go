@@ -0,0 +1,201 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// PriceEntryKind is what a price list entry prices
+type PriceEntryKind string
+
+const (
+	PriceKindService PriceEntryKind = "SERVICE" // flat price per ticket category
+	PriceKindLabor   PriceEntryKind = "LABOR"   // hourly labor rate, per ticket category or generic
+	PriceKindPart    PriceEntryKind = "PART"    // stock item
+)
+
+func (k PriceEntryKind) IsValid() bool {
+	return k == PriceKindService || k == PriceKindLabor || k == PriceKindPart
+}
+
+// PriceList holds the prices of a client, or the default prices when ClientID is nil.
+// When several lists apply on a date, the one with the latest ValidFrom wins.
+type PriceList struct {
+	ID        string     `json:"id" gorm:"type:uuid;primaryKey"`
+	Name      string     `json:"name" gorm:"type:varchar(255);not null"`
+	ClientID  *string    `json:"clientId" gorm:"type:uuid;index"`
+	ValidFrom time.Time  `json:"validFrom" gorm:"type:date;not null"`
+	ValidTo   *time.Time `json:"validTo" gorm:"type:date"` // inclusive; nil = open-ended
+	Currency  string     `json:"currency" gorm:"type:varchar(3);default:BRL"`
+	IsActive  bool       `json:"isActive" gorm:"default:true"`
+	CreatedBy string     `json:"createdBy" gorm:"type:varchar(36)"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+
+	// Relations (for eager loading)
+	Client  *Client          `json:"client,omitempty" gorm:"foreignKey:ClientID"`
+	Entries []PriceListEntry `json:"entries,omitempty" gorm:"foreignKey:PriceListID"`
+}
+
+func (p *PriceList) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == "" {
+		p.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (PriceList) TableName() string {
+	return "price_lists"
+}
+
+// ValidOn reports whether the list applies on the given day
+func (p *PriceList) ValidOn(day time.Time) bool {
+	if !p.IsActive || day.Before(p.ValidFrom) {
+		return false
+	}
+	return p.ValidTo == nil || !day.After(*p.ValidTo)
+}
+
+// PriceListEntry prices a service, labor hour or part. A SERVICE/LABOR entry without
+// CategoryID applies to every category not priced explicitly.
+type PriceListEntry struct {
+	ID          string          `json:"id" gorm:"type:uuid;primaryKey"`
+	PriceListID string          `json:"priceListId" gorm:"type:uuid;not null;index"`
+	Kind        PriceEntryKind  `json:"kind" gorm:"type:varchar(20);not null"`
+	CategoryID  *string         `json:"categoryId" gorm:"type:uuid"`
+	ItemID      *string         `json:"itemId" gorm:"type:uuid"`
+	Description string          `json:"description" gorm:"type:varchar(255)"`
+	Unit        string          `json:"unit" gorm:"type:varchar(20);not null;default:'UN'"`
+	Price       decimal.Decimal `json:"price" gorm:"type:decimal(12,2);not null"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	UpdatedAt   time.Time       `json:"updatedAt"`
+
+	// Relations (for eager loading)
+	Category *Category  `json:"category,omitempty" gorm:"foreignKey:CategoryID"`
+	Item     *StockItem `json:"item,omitempty" gorm:"foreignKey:ItemID"`
+}
+
+func (e *PriceListEntry) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (PriceListEntry) TableName() string {
+	return "price_list_entries"
+}
+
+// Price change actions
+const (
+	PriceChangeListCreated  = "LIST_CREATED"
+	PriceChangeListUpdated  = "LIST_UPDATED"
+	PriceChangeEntryAdded   = "ENTRY_ADDED"
+	PriceChangeEntryUpdated = "ENTRY_UPDATED"
+	PriceChangeEntryRemoved = "ENTRY_REMOVED"
+)
+
+// PriceChange is the history of a price list
+type PriceChange struct {
+	ID          string           `json:"id" gorm:"type:uuid;primaryKey"`
+	PriceListID string           `json:"priceListId" gorm:"type:uuid;not null;index"`
+	EntryID     *string          `json:"entryId" gorm:"type:uuid;index"`
+	Action      string           `json:"action" gorm:"type:varchar(20);not null"`
+	Description string           `json:"description" gorm:"type:text"`
+	OldPrice    *decimal.Decimal `json:"oldPrice" gorm:"type:decimal(12,2)"`
+	NewPrice    *decimal.Decimal `json:"newPrice" gorm:"type:decimal(12,2)"`
+	ChangedBy   string           `json:"changedBy" gorm:"type:varchar(36)"`
+	ChangedAt   time.Time        `json:"changedAt" gorm:"not null;index"`
+}
+
+func (c *PriceChange) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == "" {
+		c.ID = uuid.New().String()
+	}
+	if c.ChangedAt.IsZero() {
+		c.ChangedAt = time.Now()
+	}
+	return nil
+}
+
+func (PriceChange) TableName() string {
+	return "price_changes"
+}
+
+// =============== DTOs ===============
+
+// CreatePriceListRequest DTO
+type CreatePriceListRequest struct {
+	Name      string  `json:"name" validate:"required,max=255"`
+	ClientID  *string `json:"clientId" validate:"omitempty,uuid"`
+	ValidFrom string  `json:"validFrom" validate:"required"` // YYYY-MM-DD
+	ValidTo   *string `json:"validTo"`
+	Currency  string  `json:"currency" validate:"omitempty,len=3"`
+}
+
+// UpdatePriceListRequest DTO
+type UpdatePriceListRequest struct {
+	Name      *string `json:"name" validate:"omitempty,max=255"`
+	ValidFrom *string `json:"validFrom"`
+	ValidTo   *string `json:"validTo"` // empty string clears it
+	IsActive  *bool   `json:"isActive"`
+}
+
+// PriceListEntryRequest DTO
+type PriceListEntryRequest struct {
+	Kind        string          `json:"kind" validate:"required,oneof=SERVICE LABOR PART"`
+	CategoryID  *string         `json:"categoryId" validate:"omitempty,uuid"`
+	ItemID      *string         `json:"itemId" validate:"omitempty,uuid"`
+	Description string          `json:"description" validate:"max=255"`
+	Unit        string          `json:"unit" validate:"max=20"`
+	Price       decimal.Decimal `json:"price"`
+}
+
+// PriceListFilters DTO
+type PriceListFilters struct {
+	ClientID string // "default" for lists without client
+	ActiveOn *time.Time
+	Inactive bool // include inactive lists
+}
+
+// PriceLine is one line to price (a quote or invoice line)
+type PriceLine struct {
+	Kind       string          `json:"kind" validate:"required,oneof=SERVICE LABOR PART"`
+	CategoryID string          `json:"categoryId"` // SERVICE/LABOR; defaults to the ticket category
+	ItemID     string          `json:"itemId"`     // PART
+	Quantity   decimal.Decimal `json:"quantity"`   // hours for LABOR; default 1
+}
+
+// ResolvePricesRequest DTO
+type ResolvePricesRequest struct {
+	TicketID string      `json:"ticketId"` // supplies client and category
+	ClientID string      `json:"clientId"`
+	Date     string      `json:"date"` // YYYY-MM-DD, default today
+	Lines    []PriceLine `json:"lines" validate:"required,min=1,dive"`
+}
+
+// ResolvedPrice is the effective price of a line
+type ResolvedPrice struct {
+	PriceLine
+	Found         bool            `json:"found"`
+	UnitPrice     decimal.Decimal `json:"unitPrice"`
+	Total         decimal.Decimal `json:"total"`
+	Unit          string          `json:"unit"`
+	Source        string          `json:"source,omitempty"` // CLIENT or DEFAULT
+	PriceListID   string          `json:"priceListId,omitempty"`
+	PriceListName string          `json:"priceListName,omitempty"`
+	EntryID       string          `json:"entryId,omitempty"`
+}
+
+// ResolvePricesResponse DTO
+type ResolvePricesResponse struct {
+	ClientID string          `json:"clientId,omitempty"`
+	Date     time.Time       `json:"date"`
+	Currency string          `json:"currency"`
+	Lines    []ResolvedPrice `json:"lines"`
+	Total    decimal.Decimal `json:"total"`
+	Missing  int             `json:"missing"` // lines without a price
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Data subject requests (LGPD)
+const (
+	PrivacyRequestExport   = "EXPORT"
+	PrivacyRequestDeletion = "DELETION"
+)
+
+// Lifecycle of a privacy request: deletions wait for their grace period as SCHEDULED and
+// can be cancelled by the user or rejected by an admin until they are COMPLETED
+const (
+	PrivacyStatusScheduled = "SCHEDULED"
+	PrivacyStatusCompleted = "COMPLETED"
+	PrivacyStatusCancelled = "CANCELLED"
+	PrivacyStatusRejected  = "REJECTED"
+)
+
+// PrivacyRequest records a data export or an account deletion asked by a user
+type PrivacyRequest struct {
+	ID           string     `json:"id" gorm:"type:uuid;primaryKey"`
+	UserID       string     `json:"userId" gorm:"type:varchar(36);not null;index"`
+	Type         string     `json:"type" gorm:"type:varchar(20);not null;index"`
+	Status       string     `json:"status" gorm:"type:varchar(20);not null;index"`
+	Reason       string     `json:"reason" gorm:"type:text"`
+	ScheduledFor *time.Time `json:"scheduledFor"` // deletions only: end of the grace period
+	ProcessedAt  *time.Time `json:"processedAt"`
+	ProcessedBy  string     `json:"processedBy" gorm:"type:varchar(36)"` // admin, empty when done by the user or the scheduler
+	Notes        string     `json:"notes" gorm:"type:text"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+}
+
+func (PrivacyRequest) TableName() string {
+	return "privacy_requests"
+}
+
+func (p *PrivacyRequest) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == "" {
+		p.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// =============== DTOs ===============
+
+// RequestAccountDeletionRequest DTO; the password confirms the account owner
+type RequestAccountDeletionRequest struct {
+	Password string `json:"password" validate:"required"`
+	Reason   string `json:"reason" validate:"max=1000"`
+}
+
+// ProcessPrivacyRequest DTO for the admin oversight actions
+type ProcessPrivacyRequest struct {
+	Notes string `json:"notes" validate:"max=1000"`
+}
+
+// PrivacyRequestFilters for the admin list
+type PrivacyRequestFilters struct {
+	UserID string
+	Type   string
+	Status string
+}
+
+// PersonalDataExport is the machine-readable copy of the personal data kept about a user
+type PersonalDataExport struct {
+	GeneratedAt     time.Time        `json:"generatedAt"`
+	Profile         *User            `json:"profile"`
+	Technician      *Technician      `json:"technician,omitempty"`
+	Memberships     []Membership     `json:"memberships"`
+	ActivityLogs    []ActivityLog    `json:"activityLogs"`
+	SecurityLogs    []SecurityLog    `json:"securityLogs"`
+	Sessions        []RefreshToken   `json:"sessions"`
+	PrivacyRequests []PrivacyRequest `json:"privacyRequests"`
+}
@@ -0,0 +1,171 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// RecallCampaignType is why the campaign visits the equipment
+type RecallCampaignType string
+
+const (
+	RecallCampaignRecall     RecallCampaignType = "RECALL"
+	RecallCampaignPreventive RecallCampaignType = "PREVENTIVE"
+	RecallCampaignWarranty   RecallCampaignType = "WARRANTY"
+)
+
+// RecallCampaignStatus is the lifecycle of a campaign: the targets are selected when it
+// starts, then the campaign job generates their tickets a batch at a time while it runs
+type RecallCampaignStatus string
+
+const (
+	RecallCampaignDraft     RecallCampaignStatus = "DRAFT"
+	RecallCampaignRunning   RecallCampaignStatus = "RUNNING"
+	RecallCampaignPaused    RecallCampaignStatus = "PAUSED"
+	RecallCampaignCompleted RecallCampaignStatus = "COMPLETED"
+	RecallCampaignCancelled RecallCampaignStatus = "CANCELLED"
+)
+
+// Status of the ticket generation of one target
+const (
+	RecallTargetPending = "PENDING"
+	RecallTargetCreated = "CREATED"
+	RecallTargetFailed  = "FAILED"
+	RecallTargetSkipped = "SKIPPED" // campaign cancelled before its turn
+)
+
+// RecallCampaignCriteria selects the equipment of the campaign among the equipment
+// registered on the client tickets. The install date is the first ticket of the
+// equipment at the client.
+type RecallCampaignCriteria struct {
+	Brand         string         `json:"brand" gorm:"type:varchar(100)"` // case-insensitive, partial
+	Model         string         `json:"model" gorm:"type:varchar(100)"` // case-insensitive, partial
+	InstalledFrom *time.Time     `json:"installedFrom"`
+	InstalledTo   *time.Time     `json:"installedTo"`
+	States        pq.StringArray `json:"states" gorm:"type:text[]"` // client UFs, empty = every state
+	City          string         `json:"city" gorm:"type:varchar(100)"`
+}
+
+// RecallCampaign bulk-generates preventive or recall tickets, all of them carrying the
+// campaign ID
+type RecallCampaign struct {
+	ID          string               `json:"id" gorm:"type:uuid;primaryKey"`
+	TenantID    string               `json:"tenantId" gorm:"type:varchar(36);not null;default:'00000000-0000-0000-0000-000000000001';index"`
+	Name        string               `json:"name" gorm:"type:varchar(150);not null"`
+	Type        RecallCampaignType   `json:"type" gorm:"type:varchar(20);not null"`
+	Description string               `json:"description" gorm:"type:text"` // problem description of the generated tickets
+	Priority    TicketPriority       `json:"priority" gorm:"type:varchar(20);not null;default:NORMAL"`
+	CategoryID  *string              `json:"categoryId" gorm:"type:uuid"`
+	Status      RecallCampaignStatus `json:"status" gorm:"type:varchar(20);not null;default:DRAFT;index"`
+
+	RecallCampaignCriteria `gorm:"embedded;embeddedPrefix:criteria_"`
+
+	// Progress, kept up to date by the campaign job
+	TotalTargets   int        `json:"totalTargets"`
+	CreatedTickets int        `json:"createdTickets"`
+	FailedTargets  int        `json:"failedTargets"`
+	StartedAt      *time.Time `json:"startedAt"`
+	CompletedAt    *time.Time `json:"completedAt"`
+
+	CreatedBy string    `json:"createdBy" gorm:"type:varchar(36)"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func (c *RecallCampaign) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == "" {
+		c.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (RecallCampaign) TableName() string {
+	return "recall_campaigns"
+}
+
+// RecallCampaignTarget is one piece of equipment of the campaign and its ticket
+type RecallCampaignTarget struct {
+	ID            string     `json:"id" gorm:"type:uuid;primaryKey"`
+	CampaignID    string     `json:"campaignId" gorm:"type:uuid;not null;uniqueIndex:idx_recall_target,priority:1;index:idx_recall_target_status,priority:1"`
+	ClientID      string     `json:"clientId" gorm:"type:uuid;not null;uniqueIndex:idx_recall_target,priority:2"`
+	SerialNumber  string     `json:"serialNumber" gorm:"type:varchar(100);not null;uniqueIndex:idx_recall_target,priority:3"`
+	ComputerBrand string     `json:"computerBrand" gorm:"type:varchar(100)"`
+	ComputerModel string     `json:"computerModel" gorm:"type:varchar(100)"`
+	InstalledAt   time.Time  `json:"installedAt"`
+	Status        string     `json:"status" gorm:"type:varchar(20);not null;default:PENDING;index:idx_recall_target_status,priority:2"`
+	TicketID      *string    `json:"ticketId" gorm:"type:uuid"`
+	Error         string     `json:"error,omitempty" gorm:"type:text"`
+	ProcessedAt   *time.Time `json:"processedAt"`
+
+	Client *Client `json:"client,omitempty" gorm:"foreignKey:ClientID"`
+}
+
+func (t *RecallCampaignTarget) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == "" {
+		t.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (RecallCampaignTarget) TableName() string {
+	return "recall_campaign_targets"
+}
+
+// =============== DTOs ===============
+
+// RecallCampaignRequest creates or edits a DRAFT campaign
+type RecallCampaignRequest struct {
+	Name          string   `json:"name" validate:"required,max=150"`
+	Type          string   `json:"type" validate:"required,oneof=RECALL PREVENTIVE WARRANTY"`
+	Description   string   `json:"description" validate:"required"`
+	Priority      string   `json:"priority" validate:"omitempty,oneof=BAIXA NORMAL ALTA URGENTE"`
+	CategoryID    *string  `json:"categoryId"`
+	Brand         string   `json:"brand" validate:"max=100"`
+	Model         string   `json:"model" validate:"max=100"`
+	InstalledFrom string   `json:"installedFrom"` // YYYY-MM-DD
+	InstalledTo   string   `json:"installedTo"`   // YYYY-MM-DD, inclusive
+	States        []string `json:"states" validate:"dive,len=2"`
+	City          string   `json:"city" validate:"max=100"`
+}
+
+// RecallCampaignAsset is a piece of equipment installed at a client, as matched by the criteria
+type RecallCampaignAsset struct {
+	ClientID      string    `json:"clientId"`
+	ClientName    string    `json:"clientName"`
+	City          string    `json:"city"`
+	State         string    `json:"state"`
+	ComputerBrand string    `json:"computerBrand"`
+	ComputerModel string    `json:"computerModel"`
+	SerialNumber  string    `json:"serialNumber"`
+	InstalledAt   time.Time `json:"installedAt"`
+}
+
+// RecallCampaignPreview is what the criteria of a campaign would select
+type RecallCampaignPreview struct {
+	Total   int                   `json:"total"`
+	Clients int                   `json:"clients"`
+	Sample  []RecallCampaignAsset `json:"sample"`
+}
+
+// RecallCampaignCount is one row of the completion report
+type RecallCampaignCount struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+// RecallCampaignReport is the completion of a campaign: generation of the tickets and
+// how far the generated tickets got
+type RecallCampaignReport struct {
+	Campaign          RecallCampaign         `json:"campaign"`
+	Pending           int                    `json:"pending"`
+	GenerationPercent float64                `json:"generationPercent"` // targets processed
+	ClosedTickets     int                    `json:"closedTickets"`
+	CompletionPercent float64                `json:"completionPercent"` // generated tickets closed
+	ByTargetStatus    []RecallCampaignCount  `json:"byTargetStatus"`
+	ByTicketStatus    []RecallCampaignCount  `json:"byTicketStatus"`
+	ByState           []RecallCampaignCount  `json:"byState"` // generated tickets per client UF
+	Failures          []RecallCampaignTarget `json:"failures"`
+}
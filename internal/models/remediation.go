@@ -0,0 +1,147 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Runtime settings changed through the admin settings API and by remediation rules
+const (
+	SettingCacheDegraded     = "cache.degraded_mode"
+	SettingAttachmentWorkers = "attachments.worker_concurrency"
+	// SettingPaginationPrefix + resource holds its page size limits as default/max (20/100)
+	SettingPaginationPrefix = "pagination."
+)
+
+// Who last changed a setting
+const (
+	SettingSourceDefault     = "DEFAULT"
+	SettingSourceManual      = "MANUAL"
+	SettingSourceRemediation = "REMEDIATION"
+)
+
+// Remediation action statuses
+const (
+	RemediationApplied    = "APPLIED"
+	RemediationReverted   = "REVERTED"
+	RemediationSuperseded = "SUPERSEDED" // the setting was changed by hand meanwhile, left as is
+	RemediationFailed     = "FAILED"
+)
+
+// SystemSetting is the stored value of a runtime setting, applied again on startup
+type SystemSetting struct {
+	Key       string    `json:"key" gorm:"type:varchar(100);primaryKey"`
+	Value     string    `json:"value" gorm:"type:varchar(255);not null"`
+	Source    string    `json:"source" gorm:"type:varchar(20);not null"`
+	UpdatedBy string    `json:"updatedBy" gorm:"type:varchar(36)"` // empty when changed by a rule
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func (SystemSetting) TableName() string {
+	return "system_settings"
+}
+
+// RemediationRule reacts to an active alert by changing a runtime setting. ResourceID
+// narrows the alerts it reacts to (e.g. the job name of a JOB_BACKLOG alert).
+type RemediationRule struct {
+	ID              string    `json:"id" gorm:"type:uuid;primaryKey"`
+	Name            string    `json:"name" gorm:"type:varchar(100);not null"`
+	AlertType       string    `json:"alertType" gorm:"type:varchar(50);not null;index"`
+	ResourceID      string    `json:"resourceId" gorm:"type:varchar(100)"`
+	Setting         string    `json:"setting" gorm:"type:varchar(100);not null"`
+	Value           string    `json:"value" gorm:"type:varchar(255);not null"`
+	RevertOnResolve bool      `json:"revertOnResolve" gorm:"not null;default:true"`
+	CooldownMinutes int       `json:"cooldownMinutes" gorm:"not null;default:30"` // between two applications
+	Enabled         bool      `json:"enabled" gorm:"not null;default:true"`
+	CreatedBy       string    `json:"createdBy" gorm:"type:varchar(36)"`
+	CreatedAt       time.Time `json:"createdAt"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+}
+
+func (r *RemediationRule) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (RemediationRule) TableName() string {
+	return "remediation_rules"
+}
+
+// RemediationAction records a setting changed by a rule and how it was undone
+type RemediationAction struct {
+	ID            string     `json:"id" gorm:"type:uuid;primaryKey"`
+	RuleID        string     `json:"ruleId" gorm:"type:uuid;not null;index"`
+	AlertID       string     `json:"alertId" gorm:"type:uuid;index"`
+	Setting       string     `json:"setting" gorm:"type:varchar(100);not null"`
+	PreviousValue string     `json:"previousValue" gorm:"type:varchar(255)"`
+	NewValue      string     `json:"newValue" gorm:"type:varchar(255);not null"`
+	Status        string     `json:"status" gorm:"type:varchar(20);not null;index"`
+	Error         string     `json:"error" gorm:"type:text"`
+	AppliedAt     time.Time  `json:"appliedAt" gorm:"index"`
+	RevertedAt    *time.Time `json:"revertedAt"`
+	RevertedBy    *string    `json:"revertedBy" gorm:"type:varchar(36)"` // nil when reverted automatically
+	RevertReason  string     `json:"revertReason" gorm:"type:text"`
+
+	Rule  *RemediationRule `json:"rule,omitempty" gorm:"foreignKey:RuleID"`
+	Alert *Alert           `json:"alert,omitempty" gorm:"foreignKey:AlertID"`
+}
+
+func (a *RemediationAction) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == "" {
+		a.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (RemediationAction) TableName() string {
+	return "remediation_actions"
+}
+
+// =============== DTOs ===============
+
+// SystemSettingView is a runtime setting with its current value
+type SystemSettingView struct {
+	Key         string     `json:"key"`
+	Description string     `json:"description"`
+	Value       string     `json:"value"`
+	Default     string     `json:"default"`
+	Source      string     `json:"source"`
+	UpdatedBy   string     `json:"updatedBy,omitempty"`
+	UpdatedAt   *time.Time `json:"updatedAt,omitempty"`
+}
+
+// UpdateSettingRequest DTO
+type UpdateSettingRequest struct {
+	Value string `json:"value" validate:"required,max=255"`
+}
+
+// RemediationRuleRequest DTO
+type RemediationRuleRequest struct {
+	Name            string `json:"name" validate:"required,max=100"`
+	AlertType       string `json:"alertType" validate:"required,max=50"`
+	ResourceID      string `json:"resourceId" validate:"max=100"`
+	Setting         string `json:"setting" validate:"required"`
+	Value           string `json:"value" validate:"required,max=255"`
+	RevertOnResolve *bool  `json:"revertOnResolve"`
+	CooldownMinutes *int   `json:"cooldownMinutes" validate:"omitempty,min=0,max=1440"`
+	Enabled         *bool  `json:"enabled"`
+}
+
+// RevertRemediationRequest DTO; DisableRule keeps the rule from applying again while
+// its alert is still active
+type RevertRemediationRequest struct {
+	Reason      string `json:"reason" validate:"max=2000"`
+	DisableRule bool   `json:"disableRule"`
+}
+
+// RemediationRunResult summarizes a rules engine run
+type RemediationRunResult struct {
+	Applied  int      `json:"applied"`
+	Reverted int      `json:"reverted"`
+	Failed   int      `json:"failed"`
+	Errors   []string `json:"errors,omitempty"`
+}
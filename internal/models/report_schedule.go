@@ -0,0 +1,115 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// Reports a schedule can deliver
+const (
+	ReportTypeFinancialDashboard = "FINANCIAL_DASHBOARD"
+	ReportTypeSLACompliance      = "SLA_COMPLIANCE"
+	ReportTypeLowStock           = "LOW_STOCK" // current balances, the period is ignored
+)
+
+// Periods covered by a scheduled report, relative to the time it runs
+const (
+	ReportPeriodPreviousDay   = "PREVIOUS_DAY"
+	ReportPeriodPreviousWeek  = "PREVIOUS_WEEK" // Monday to Sunday
+	ReportPeriodPreviousMonth = "PREVIOUS_MONTH"
+	ReportPeriodLast7Days     = "LAST_7_DAYS"
+	ReportPeriodLast30Days    = "LAST_30_DAYS"
+	ReportPeriodMonthToDate   = "MONTH_TO_DATE"
+)
+
+// ReportSchedule e-mails a report on a cron expression (minute hour day-of-month month
+// day-of-week, in the timezone of the schedule), with the CSV attached. NextRunAt is
+// kept by the report job, which also records every run.
+type ReportSchedule struct {
+	ID         string         `json:"id" gorm:"type:uuid;primaryKey"`
+	Name       string         `json:"name" gorm:"type:varchar(150);not null"`
+	ReportType string         `json:"reportType" gorm:"type:varchar(30);not null"`
+	Cron       string         `json:"cron" gorm:"type:varchar(100);not null"`
+	Timezone   string         `json:"timezone" gorm:"type:varchar(50);not null;default:'America/Sao_Paulo'"`
+	Period     string         `json:"period" gorm:"type:varchar(20);not null"`
+	Recipients pq.StringArray `json:"recipients" gorm:"type:text[]"`
+	Active     bool           `json:"active" gorm:"not null;default:true;index"`
+
+	// Kept by the report job
+	NextRunAt           *time.Time `json:"nextRunAt" gorm:"index"`
+	LastRunAt           *time.Time `json:"lastRunAt"`
+	LastStatus          string     `json:"lastStatus" gorm:"type:varchar(20)"`
+	ConsecutiveFailures int        `json:"consecutiveFailures" gorm:"not null;default:0"`
+
+	CreatedBy string    `json:"createdBy" gorm:"type:varchar(36);not null"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func (s *ReportSchedule) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (ReportSchedule) TableName() string {
+	return "report_schedules"
+}
+
+// Status of a report run
+const (
+	ReportRunRunning = "RUNNING"
+	ReportRunSuccess = "SUCCESS"
+	ReportRunFailed  = "FAILED" // not generated, or not delivered to any recipient
+)
+
+// How a report run started
+const (
+	ReportTriggerScheduled = "SCHEDULED"
+	ReportTriggerManual    = "MANUAL"
+)
+
+// ReportRun is one generation of a scheduled report. The CSV is kept in the file storage;
+// Error lists the recipients it could not be delivered to, if any.
+type ReportRun struct {
+	ID          string     `json:"id" gorm:"type:uuid;primaryKey"`
+	ScheduleID  string     `json:"scheduleId" gorm:"type:uuid;not null;index:idx_report_runs_schedule,priority:1"`
+	Trigger     string     `json:"trigger" gorm:"type:varchar(20);not null"`
+	Status      string     `json:"status" gorm:"type:varchar(20);not null;index"`
+	PeriodStart *time.Time `json:"periodStart"`
+	PeriodEnd   *time.Time `json:"periodEnd"`
+	Delivered   int        `json:"delivered"` // recipients the e-mail was sent to
+	FileID      *string    `json:"fileId" gorm:"type:uuid"`
+	Error       string     `json:"error" gorm:"type:text"`
+	TriggeredBy string     `json:"triggeredBy" gorm:"type:varchar(36)"` // manual runs
+	StartedAt   time.Time  `json:"startedAt" gorm:"not null;index:idx_report_runs_schedule,priority:2"`
+	FinishedAt  *time.Time `json:"finishedAt"`
+}
+
+func (r *ReportRun) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (ReportRun) TableName() string {
+	return "report_runs"
+}
+
+// =============== DTOs ===============
+
+// ReportScheduleRequest creates or replaces a report schedule
+type ReportScheduleRequest struct {
+	Name       string   `json:"name" validate:"required,max=150"`
+	ReportType string   `json:"reportType" validate:"required,oneof=FINANCIAL_DASHBOARD SLA_COMPLIANCE LOW_STOCK"`
+	Cron       string   `json:"cron" validate:"required,max=100"`
+	Timezone   string   `json:"timezone" validate:"max=50"`                                                                                         // default America/Sao_Paulo
+	Period     string   `json:"period" validate:"omitempty,oneof=PREVIOUS_DAY PREVIOUS_WEEK PREVIOUS_MONTH LAST_7_DAYS LAST_30_DAYS MONTH_TO_DATE"` // default PREVIOUS_DAY
+	Recipients []string `json:"recipients" validate:"required,min=1,max=50,dive,email"`
+	Active     *bool    `json:"active"` // default true
+}
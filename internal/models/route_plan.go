@@ -0,0 +1,98 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Origem do ponto de partida da rota
+const (
+	RouteStartRequest      = "REQUEST"       // coordenadas informadas na requisição
+	RouteStartLastLocation = "LAST_LOCATION" // última localização do técnico no dia
+	RouteStartBase         = "BASE"          // cidade/estado do cadastro do técnico
+	RouteStartFirstStop    = "FIRST_STOP"    // sem referência: parte da primeira visita agendada
+)
+
+// RouteStop é uma visita da rota planejada
+type RouteStop struct {
+	Order          int        `json:"order"` // 1 = primeira visita; 0 para tickets sem coordenadas
+	TicketID       string     `json:"ticketId"`
+	OSNumber       string     `json:"osNumber"`
+	Status         string     `json:"status"`
+	ClientID       *string    `json:"clientId,omitempty"`
+	ClientName     string     `json:"clientName"`
+	City           string     `json:"city"`
+	State          string     `json:"state"`
+	ScheduledStart *time.Time `json:"scheduledStart"`
+	Latitude       *float64   `json:"latitude"`
+	Longitude      *float64   `json:"longitude"`
+	GeoPrecision   string     `json:"geoPrecision"`  // EXACT (coordenadas do cliente), CITY, STATE ou NONE
+	LegDistanceKm  float64    `json:"legDistanceKm"` // desde a parada anterior (ou o ponto de partida)
+	LegMinutes     int        `json:"legMinutes"`
+}
+
+// RouteStops é a lista de paradas gravada em JSONB
+type RouteStops []RouteStop
+
+func (s RouteStops) Value() (driver.Value, error) {
+	if s == nil {
+		return "[]", nil
+	}
+	return json.Marshal(s)
+}
+
+func (s *RouteStops) Scan(value interface{}) error {
+	if value == nil {
+		*s = RouteStops{}
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("failed to scan RouteStops")
+	}
+	return json.Unmarshal(bytes, s)
+}
+
+// TechnicianRoutePlan é a ordem de visitas otimizada de um técnico em um dia. As distâncias
+// são em linha reta (Haversine) e o tempo usa a velocidade média do agendamento.
+type TechnicianRoutePlan struct {
+	ID                 string     `json:"id" gorm:"type:uuid;primaryKey"`
+	TechnicianID       string     `json:"technicianId" gorm:"type:varchar(36);not null;uniqueIndex:idx_route_plan_day"`
+	Date               string     `json:"date" gorm:"type:varchar(10);not null;uniqueIndex:idx_route_plan_day"` // YYYY-MM-DD
+	Algorithm          string     `json:"algorithm" gorm:"type:varchar(40)"`
+	StartSource        string     `json:"startSource" gorm:"type:varchar(20)"`
+	StartLatitude      *float64   `json:"startLatitude" gorm:"type:double precision"`
+	StartLongitude     *float64   `json:"startLongitude" gorm:"type:double precision"`
+	AverageSpeedKmh    float64    `json:"averageSpeedKmh" gorm:"type:double precision"`
+	TotalDistanceKm    float64    `json:"totalDistanceKm" gorm:"type:double precision"`
+	TotalTravelMinutes int        `json:"totalTravelMinutes"`
+	BaselineDistanceKm float64    `json:"baselineDistanceKm" gorm:"type:double precision"` // na ordem dos horários agendados
+	Stops              RouteStops `json:"stops" gorm:"type:jsonb;default:'[]'"`
+	Saved              bool       `json:"saved" gorm:"-"`
+	CreatedBy          string     `json:"createdBy" gorm:"type:varchar(36)"`
+	CreatedAt          time.Time  `json:"createdAt"`
+	UpdatedAt          time.Time  `json:"updatedAt"`
+}
+
+func (p *TechnicianRoutePlan) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == "" {
+		p.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (TechnicianRoutePlan) TableName() string {
+	return "technician_route_plans"
+}
+
+// RoutePlanRequest DTO
+type RoutePlanRequest struct {
+	Date           string   `json:"date" query:"date"` // YYYY-MM-DD; padrão: hoje
+	StartLatitude  *float64 `json:"startLatitude" query:"startLatitude" validate:"omitempty,latitude"`
+	StartLongitude *float64 `json:"startLongitude" query:"startLongitude" validate:"omitempty,longitude"`
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Sandbox statuses
+const (
+	SandboxStatusActive  = "ACTIVE"
+	SandboxStatusExpired = "EXPIRED" // demo data removed and partner account disabled
+)
+
+// Records created for a sandbox, removed when it expires
+const (
+	SandboxResourceClient     = "CLIENT"
+	SandboxResourceTicket     = "TICKET"
+	SandboxResourceTechnician = "TECHNICIAN"
+)
+
+// SandboxHierarchyName is the hierarchy the nodes of every sandbox are created in
+const SandboxHierarchyName = "Sandbox"
+
+// SandboxRoleName is the access role the partner account gets on the sandbox node (read only)
+const SandboxRoleName = "Visualizador"
+
+// SandboxTenant is a throwaway tenant for integration partners: a tenant of its own with a
+// top-level node in the Sandbox hierarchy, a USER account linked to a demo technician and
+// member of that node only, and seeded demo data, all removed once ExpiresAt passes
+type SandboxTenant struct {
+	ID           string     `json:"id" gorm:"type:uuid;primaryKey"`
+	Name         string     `json:"name" gorm:"type:varchar(100);not null"`
+	Status       string     `json:"status" gorm:"type:varchar(20);not null;default:ACTIVE;index"`
+	TenantID     string     `json:"tenantId" gorm:"type:varchar(36)"` // the tenant holding the demo data, disabled on expiry
+	NodeID       uint       `json:"nodeId" gorm:"not null"`
+	UserID       string     `json:"userId" gorm:"type:varchar(36);not null"`
+	TechnicianID string     `json:"technicianId" gorm:"type:varchar(36);not null"`
+	Email        string     `json:"email" gorm:"type:varchar(255);not null"` // login of the partner account
+	ExpiresAt    time.Time  `json:"expiresAt" gorm:"not null;index"`
+	CleanedAt    *time.Time `json:"cleanedAt"`
+	CreatedBy    string     `json:"createdBy" gorm:"type:varchar(36)"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+
+	Resources []SandboxResource `json:"resources,omitempty" gorm:"foreignKey:SandboxID"`
+}
+
+func (s *SandboxTenant) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (SandboxTenant) TableName() string {
+	return "sandbox_tenants"
+}
+
+// SandboxResource is a demo record seeded for a sandbox
+type SandboxResource struct {
+	ID           string `json:"-" gorm:"type:uuid;primaryKey"`
+	SandboxID    string `json:"sandboxId" gorm:"type:uuid;not null;index"`
+	ResourceType string `json:"resourceType" gorm:"type:varchar(20);not null"`
+	ResourceID   string `json:"resourceId" gorm:"type:varchar(36);not null"`
+}
+
+func (r *SandboxResource) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (SandboxResource) TableName() string {
+	return "sandbox_resources"
+}
+
+// =============== DTOs ===============
+
+// CreateSandboxRequest DTO
+type CreateSandboxRequest struct {
+	Name     string `json:"name" validate:"required,max=100"`
+	TTLHours int    `json:"ttlHours" validate:"omitempty,min=1,max=720"` // default SANDBOX_TTL
+}
+
+// SandboxSeed is the demo data provisioned with a sandbox
+type SandboxSeed struct {
+	Tenant     *Tenant
+	User       *User
+	Technician *Technician
+	Clients    []Client
+	Tickets    []Ticket
+}
+
+// SandboxCredentials is returned once, when the sandbox is created; the password is not stored
+type SandboxCredentials struct {
+	Sandbox  *SandboxTenant `json:"sandbox"`
+	Email    string         `json:"email"`
+	Password string         `json:"password"`
+}
@@ -0,0 +1,134 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TicketFeedback is the satisfaction survey of a closed ticket: a CSAT rating of the
+// service (1-5) and the NPS question (0-10). The token authorizes the public answer.
+type TicketFeedback struct {
+	ID           string  `json:"id" gorm:"type:uuid;primaryKey"`
+	TicketID     string  `json:"ticketId" gorm:"type:uuid;not null;uniqueIndex"`
+	ClientID     *string `json:"clientId" gorm:"type:uuid;index"`
+	TechnicianID *string `json:"technicianId" gorm:"type:varchar(36);index"` // lead technician when closed
+	CategoryID   *string `json:"categoryId" gorm:"type:uuid;index"`
+	Token        string  `json:"-" gorm:"type:varchar(64);uniqueIndex;not null"`
+
+	// Delivery; LINK surveys are shared manually and get no reminders
+	Channel        string     `json:"channel" gorm:"type:varchar(20)"`
+	SentTo         string     `json:"sentTo" gorm:"type:varchar(255)"`
+	SentAt         *time.Time `json:"sentAt"`
+	SendError      string     `json:"sendError,omitempty" gorm:"type:text"`
+	Reminders      int        `json:"reminders" gorm:"default:0"`
+	LastReminderAt *time.Time `json:"lastReminderAt"`
+	ExpiresAt      time.Time  `json:"expiresAt" gorm:"not null"`
+
+	// Answer
+	CSATScore   *int       `json:"csatScore"`
+	NPSScore    *int       `json:"npsScore"`
+	Comment     string     `json:"comment" gorm:"type:text"`
+	RespondedAt *time.Time `json:"respondedAt" gorm:"index"`
+
+	CreatedAt time.Time `json:"createdAt" gorm:"index"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	Ticket *Ticket `json:"ticket,omitempty" gorm:"foreignKey:TicketID"`
+}
+
+func (f *TicketFeedback) BeforeCreate(tx *gorm.DB) error {
+	if f.ID == "" {
+		f.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (TicketFeedback) TableName() string {
+	return "ticket_feedback"
+}
+
+// =============== DTOs ===============
+
+// TicketFeedbackResponse DTO exposes the survey link so it can be shared manually
+type TicketFeedbackResponse struct {
+	TicketFeedback
+	Link string `json:"link"`
+}
+
+// SatisfactionSurveyView is what the public survey page shows
+type SatisfactionSurveyView struct {
+	OSNumber       string     `json:"osNumber"`
+	ClientName     string     `json:"clientName"`
+	TechnicianName string     `json:"technicianName,omitempty"` // first name only
+	ClosedAt       *time.Time `json:"closedAt,omitempty"`
+	CSATQuestion   string     `json:"csatQuestion"`
+	NPSQuestion    string     `json:"npsQuestion"`
+	Answered       bool       `json:"answered"`
+	Expired        bool       `json:"expired"`
+	CSATScore      *int       `json:"csatScore,omitempty"`
+	NPSScore       *int       `json:"npsScore,omitempty"`
+	RespondedAt    *time.Time `json:"respondedAt,omitempty"`
+}
+
+// SatisfactionAnswerRequest DTO; the NPS question is optional
+type SatisfactionAnswerRequest struct {
+	CSATScore *int   `json:"csatScore" validate:"required,min=1,max=5"`
+	NPSScore  *int   `json:"npsScore" validate:"omitempty,min=0,max=10"`
+	Comment   string `json:"comment" validate:"max=2000"`
+}
+
+// SatisfactionScore aggregates answers: CSAT is the percentage of ratings 4 and 5
+type SatisfactionScore struct {
+	Responses     int      `json:"responses"`
+	AverageRating float64  `json:"averageRating"`
+	Satisfied     int      `json:"satisfied"`
+	CSAT          float64  `json:"csat"`
+	Distribution  [5]int   `json:"distribution"` // answers per rating 1..5
+	NPS           NPSScore `json:"nps"`          // answers with the optional NPS question
+}
+
+// SatisfactionGroupScore is the score of a technician or a category
+type SatisfactionGroupScore struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	SatisfactionScore
+}
+
+// SatisfactionPeriodScore is the score of one month or quarter
+type SatisfactionPeriodScore struct {
+	Period string `json:"period"` // 2024-01 (month) or 2024-Q1 (quarter)
+	SatisfactionScore
+}
+
+// SatisfactionReport DTO
+type SatisfactionReport struct {
+	From         time.Time                 `json:"from"`
+	To           time.Time                 `json:"to"`
+	Surveys      int64                     `json:"surveys"`
+	ResponseRate float64                   `json:"responseRate"` // percentage of the surveys created in the period
+	Overall      SatisfactionScore         `json:"overall"`
+	ByTechnician []SatisfactionGroupScore  `json:"byTechnician"`
+	ByCategory   []SatisfactionGroupScore  `json:"byCategory"`
+	ByPeriod     []SatisfactionPeriodScore `json:"byPeriod"`
+}
+
+// SatisfactionResponse is a raw answer used for aggregation
+type SatisfactionResponse struct {
+	TechnicianID   string
+	TechnicianName string
+	CategoryID     string
+	CategoryName   string
+	CSATScore      int
+	NPSScore       *int
+	RespondedAt    time.Time
+}
+
+// SatisfactionRunResult is the outcome of one pass of the survey scheduler
+type SatisfactionRunResult struct {
+	Created  int      `json:"created"`
+	Sent     int      `json:"sent"`
+	Reminded int      `json:"reminded"`
+	Errors   []string `json:"errors,omitempty"`
+}
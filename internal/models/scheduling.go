@@ -0,0 +1,202 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SchedulingLink is a public, token-based link that lets a client pick an
+// appointment window for a ticket without logging in
+type SchedulingLink struct {
+	ID        string     `json:"id" gorm:"type:uuid;primaryKey"`
+	TicketID  string     `json:"ticketId" gorm:"type:uuid;not null;index"`
+	Token     string     `json:"token" gorm:"type:varchar(64);uniqueIndex;not null"`
+	ExpiresAt time.Time  `json:"expiresAt" gorm:"not null"`
+	UsedAt    *time.Time `json:"usedAt"`
+	CreatedBy string     `json:"createdBy" gorm:"type:varchar(36)"`
+	CreatedAt time.Time  `json:"createdAt"`
+
+	Ticket *Ticket `json:"ticket,omitempty" gorm:"foreignKey:TicketID"`
+}
+
+func (l *SchedulingLink) BeforeCreate(tx *gorm.DB) error {
+	if l.ID == "" {
+		l.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (SchedulingLink) TableName() string {
+	return "scheduling_links"
+}
+
+// IsUsable reports whether the link can still be used to book an appointment
+func (l *SchedulingLink) IsUsable() bool {
+	return l.UsedAt == nil && time.Now().Before(l.ExpiresAt)
+}
+
+// TechnicianCalendarToken authorizes the read-only ICS feed of a technician.
+// Calendar apps can't send an Authorization header, so the token goes in the URL.
+type TechnicianCalendarToken struct {
+	TechnicianID string    `json:"technicianId" gorm:"type:varchar(36);primaryKey"`
+	Token        string    `json:"-" gorm:"type:varchar(64);uniqueIndex;not null"`
+	CreatedBy    string    `json:"createdBy" gorm:"type:varchar(36)"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+func (TechnicianCalendarToken) TableName() string {
+	return "technician_calendar_tokens"
+}
+
+type ScheduleEnforcement string
+
+const (
+	ScheduleEnforcementReject ScheduleEnforcement = "REJECT"
+	ScheduleEnforcementWarn   ScheduleEnforcement = "WARN"
+)
+
+func (e ScheduleEnforcement) IsValid() bool {
+	return e == ScheduleEnforcementReject || e == ScheduleEnforcementWarn
+}
+
+// SchedulingSettings holds travel-time rules, globally (NodeID nil) or per hierarchy node
+type SchedulingSettings struct {
+	ID              uint                `json:"id" gorm:"primaryKey"`
+	NodeID          *uint               `json:"nodeId" gorm:"uniqueIndex"` // nil = global settings, kept single by idx_scheduling_settings_global
+	BufferMinutes   int                 `json:"bufferMinutes" gorm:"not null;default:15"`
+	AverageSpeedKmh float64             `json:"averageSpeedKmh" gorm:"type:double precision;not null;default:40"`
+	Enforcement     ScheduleEnforcement `json:"enforcement" gorm:"type:varchar(20);not null;default:REJECT"`
+	CreatedAt       time.Time           `json:"createdAt"`
+	UpdatedAt       time.Time           `json:"updatedAt"`
+}
+
+func (SchedulingSettings) TableName() string {
+	return "scheduling_settings"
+}
+
+// DefaultSchedulingSettings returns the settings used when nothing is configured
+func DefaultSchedulingSettings() *SchedulingSettings {
+	return &SchedulingSettings{
+		BufferMinutes:   15,
+		AverageSpeedKmh: 40,
+		Enforcement:     ScheduleEnforcementReject,
+	}
+}
+
+// =============== DTOs ===============
+
+// AppointmentSlot represents a bookable window for a given technician
+type AppointmentSlot struct {
+	TechnicianID   string    `json:"technicianId"`
+	TechnicianName string    `json:"technicianName"`
+	Start          time.Time `json:"start"`
+	End            time.Time `json:"end"`
+	DistanceKm     float64   `json:"distanceKm"`
+}
+
+// AvailableSlotsResponse lists the windows a client can choose from
+type AvailableSlotsResponse struct {
+	TicketID        string            `json:"ticketId"`
+	OSNumber        string            `json:"osNumber"`
+	DurationMinutes int               `json:"durationMinutes"`
+	Slots           []AppointmentSlot `json:"slots"`
+}
+
+// SlotQuery controls the search window for available slots
+type SlotQuery struct {
+	From            time.Time
+	Days            int
+	DurationMinutes int
+}
+
+// CreateSchedulingLinkRequest DTO
+type CreateSchedulingLinkRequest struct {
+	ExpiresInHours int `json:"expiresInHours" validate:"omitempty,min=1,max=720"`
+}
+
+// SchedulingLinkResponse DTO
+type SchedulingLinkResponse struct {
+	Token     string    `json:"token"`
+	TicketID  string    `json:"ticketId"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// CalendarFeedResponse DTO
+type CalendarFeedResponse struct {
+	TechnicianID string `json:"technicianId"`
+	Token        string `json:"token"`
+	URL          string `json:"url"`
+}
+
+// CalendarQuery selects the appointments shown on the calendar, within [From, To)
+type CalendarQuery struct {
+	TechnicianID string // empty for every technician, office users only
+	From         time.Time
+	To           time.Time
+	Scope        *AccessScope
+}
+
+// CalendarEvent is one scheduled ticket on the calendar
+type CalendarEvent struct {
+	TicketID      string         `json:"ticketId"`
+	OSNumber      string         `json:"osNumber"`
+	Title         string         `json:"title"`
+	Start         time.Time      `json:"start"`
+	End           time.Time      `json:"end"`
+	Status        TicketStatus   `json:"status"`
+	Priority      TicketPriority `json:"priority"`
+	ClientID      *string        `json:"clientId"`
+	ClientName    string         `json:"clientName,omitempty"`
+	Location      string         `json:"location,omitempty"`
+	TechnicianIDs []string       `json:"technicianIds"`
+	// Conflict is set when the appointment overlaps another one of the same technician
+	Conflict bool `json:"conflict"`
+}
+
+// TicketCalendar is the calendar view of the scheduled tickets
+type TicketCalendar struct {
+	TechnicianID string          `json:"technicianId,omitempty"`
+	From         time.Time       `json:"from"`
+	To           time.Time       `json:"to"`
+	Events       []CalendarEvent `json:"events"`
+}
+
+// ConfirmAppointmentRequest DTO
+type ConfirmAppointmentRequest struct {
+	TechnicianID    string `json:"technicianId" validate:"required"`
+	Start           string `json:"start" validate:"required"`
+	DurationMinutes int    `json:"durationMinutes"`
+	// Force books despite travel-time warnings (only honored in WARN mode)
+	Force bool `json:"force"`
+}
+
+// AppointmentConfirmation is returned once a slot has been booked
+type AppointmentConfirmation struct {
+	TicketID       string             `json:"ticketId"`
+	OSNumber       string             `json:"osNumber"`
+	TechnicianID   string             `json:"technicianId"`
+	TechnicianName string             `json:"technicianName"`
+	ScheduledStart time.Time          `json:"scheduledStart"`
+	ScheduledEnd   time.Time          `json:"scheduledEnd"`
+	Warnings       []ScheduleConflict `json:"warnings,omitempty"`
+}
+
+// ScheduleConflict describes why an appointment does not fit next to another one
+type ScheduleConflict struct {
+	TicketID      string    `json:"ticketId"`
+	OSNumber      string    `json:"osNumber"`
+	Start         time.Time `json:"start"`
+	End           time.Time `json:"end"`
+	TravelMinutes int       `json:"travelMinutes"`
+	Reason        string    `json:"reason"` // OVERLAP, TRAVEL_BEFORE, TRAVEL_AFTER
+}
+
+// UpdateSchedulingSettingsRequest DTO
+type UpdateSchedulingSettingsRequest struct {
+	NodeID          *uint    `json:"nodeId"`
+	BufferMinutes   *int     `json:"bufferMinutes"`
+	AverageSpeedKmh *float64 `json:"averageSpeedKmh"`
+	Enforcement     *string  `json:"enforcement"`
+}
@@ -0,0 +1,120 @@
+package models
+
+import "time"
+
+// SchemaFormatVersion is the version of the /meta/schema document layout; the model
+// itself is versioned by the content hash in SchemaDescription.Version
+const SchemaFormatVersion = "1"
+
+// SchemaEnums lists the allowed values of the enum types, by Go type name
+var SchemaEnums = map[string][]string{
+	"TicketStatus": {
+		string(TicketStatusOpen), string(TicketStatusInProgress), string(TicketStatusForClosing),
+		string(TicketStatusClosed), string(TicketStatusUnproductive), string(TicketStatusCancelled),
+		string(TicketStatusWaitingClient), string(TicketStatusWaitingParts),
+	},
+	"TicketPriority": {
+		string(TicketPriorityLow), string(TicketPriorityNormal), string(TicketPriorityHigh), string(TicketPriorityUrgent),
+	},
+	"TicketType":     {string(TicketTypeService), string(TicketTypeComplaint)},
+	"AssignmentRole": {string(AssignmentRoleLead), string(AssignmentRoleAssistant)},
+	"AttachmentStatus": {
+		string(AttachmentStatusPending), string(AttachmentStatusProcessing), string(AttachmentStatusReady),
+		string(AttachmentStatusFailed), string(AttachmentStatusInfected),
+	},
+	"ComplaintRootCause": {
+		string(RootCauseTechnicianConduct), string(RootCauseWorkmanship), string(RootCauseDelay), string(RootCauseParts),
+		string(RootCauseCommunication), string(RootCauseBilling), string(RootCauseProcess), string(RootCauseUnfounded),
+	},
+	"CategoryType": {
+		string(CategoryTypeTicket), string(CategoryTypeFinanceIncome), string(CategoryTypeFinanceExpense),
+	},
+	"FinancialEntryType": {string(FinancialEntryTypeIncome), string(FinancialEntryTypeExpense)},
+	"FinancialEntryStatus": {
+		string(FinancialEntryStatusPending), string(FinancialEntryStatusPaid),
+		string(FinancialEntryStatusOverdue), string(FinancialEntryStatusCancelled),
+	},
+	"PaymentBatchStatus": {
+		string(PaymentBatchStatusDraft), string(PaymentBatchStatusApproved), string(PaymentBatchStatusProcessing),
+		string(PaymentBatchStatusPaid), string(PaymentBatchStatusCancelled),
+	},
+	"NPSCampaignStatus": {
+		string(NPSCampaignDraft), string(NPSCampaignSending), string(NPSCampaignOpen), string(NPSCampaignClosed),
+	},
+	"StockLocationType": {
+		string(LocationWarehouse), string(LocationBranch), string(LocationTechnician),
+		string(LocationClient), string(LocationQuarantine),
+	},
+	"StockMovementType": {
+		string(MovementTypeEntradaCompra), string(MovementTypeEntradaDevolucao), string(MovementTypeTransferencia),
+		string(MovementTypeSaidaConsumoOS), string(MovementTypeSaidaPerda), string(MovementTypeAjusteInventario),
+		string(MovementTypeSaidaFornecedor),
+	},
+	"StockMovementStatus": {
+		string(MovementStatusApproved), string(MovementStatusPending), string(MovementStatusRejected),
+	},
+	"IncidentSeverity": {
+		string(IncidentSeverityMinor), string(IncidentSeverityMajor), string(IncidentSeverityCritical),
+	},
+	"IncidentStatus": {
+		string(IncidentStatusInvestigating), string(IncidentStatusIdentified),
+		string(IncidentStatusMonitoring), string(IncidentStatusResolved),
+	},
+	"DispatchStatus": {
+		string(DispatchStatusPending), string(DispatchStatusAutoAssigned), string(DispatchStatusManualQueue),
+	},
+	"DispatchOutcome": {
+		string(DispatchOutcomeAssigned), string(DispatchOutcomeNoCandidate), string(DispatchOutcomeFallbackManual),
+	},
+	"EventType":           {string(EventTypeCheckin), string(EventTypeCheckout), string(EventTypeHeartbeat)},
+	"PriceEntryKind":      {string(PriceKindService), string(PriceKindLabor), string(PriceKindPart)},
+	"ScheduleEnforcement": {string(ScheduleEnforcementReject), string(ScheduleEnforcementWarn)},
+	"DistributionPolicy": {
+		string(DistributionRoundRobin), string(DistributionWeighted), string(DistributionLeastLoaded),
+	},
+	"QueueAssignmentMethod": {
+		string(QueueAssignmentAuto), string(QueueAssignmentManual), string(QueueAssignmentRebalance),
+	},
+}
+
+// =============== DTOs ===============
+
+// SchemaField describes a column of an entity
+type SchemaField struct {
+	Name       string   `json:"name"`   // JSON name in the API
+	Column     string   `json:"column"` // database column
+	GoType     string   `json:"goType"`
+	DataType   string   `json:"dataType"` // database type
+	PrimaryKey bool     `json:"primaryKey,omitempty"`
+	Nullable   bool     `json:"nullable"`
+	Unique     bool     `json:"unique,omitempty"`
+	Default    string   `json:"default,omitempty"`
+	Size       int      `json:"size,omitempty"`
+	Enum       []string `json:"enum,omitempty"`
+}
+
+// SchemaRelationship describes an association between entities
+type SchemaRelationship struct {
+	Name        string   `json:"name"`
+	Type        string   `json:"type"` // has_one, has_many, belongs_to or many_to_many
+	Entity      string   `json:"entity"`
+	ForeignKeys []string `json:"foreignKeys"`
+	JoinTable   string   `json:"joinTable,omitempty"`
+}
+
+// SchemaEntity describes a persisted model
+type SchemaEntity struct {
+	Name          string               `json:"name"`
+	Table         string               `json:"table"`
+	PrimaryKey    []string             `json:"primaryKey"`
+	Fields        []SchemaField        `json:"fields"`
+	Relationships []SchemaRelationship `json:"relationships"`
+}
+
+// SchemaDescription is the data dictionary served at /meta/schema
+type SchemaDescription struct {
+	FormatVersion string         `json:"formatVersion"`
+	Version       string         `json:"version"` // changes whenever the model changes
+	GeneratedAt   time.Time      `json:"generatedAt"`
+	Entities      []SchemaEntity `json:"entities"`
+}
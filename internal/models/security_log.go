@@ -47,4 +47,5 @@ type PaginatedSecurityLogs struct {
 	Size          int           `json:"size"`
 	TotalElements int64         `json:"totalElements"`
 	TotalPages    int           `json:"totalPages"`
+	HasNext       bool          `json:"hasNext"`
 }
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RefreshToken is a login session. Only hashes of the tokens are stored: every refresh
+// rotates the token in place, and presenting the previous token again (a copy was stolen
+// or replayed) revokes the session. Access tokens carry the session ID (sid claim), so
+// revoking a session also rejects the access tokens issued for it.
+type RefreshToken struct {
+	ID                string     `json:"id" gorm:"type:uuid;primaryKey"`
+	UserID            string     `json:"userId" gorm:"type:varchar(36);not null;index"`
+	TokenHash         string     `json:"-" gorm:"type:varchar(64);not null;uniqueIndex"`
+	PreviousTokenHash string     `json:"-" gorm:"type:varchar(64);index"`
+	Rotations         int        `json:"rotations" gorm:"not null;default:0"`
+	DeviceName        string     `json:"deviceName" gorm:"type:varchar(100)"`
+	UserAgent         string     `json:"userAgent" gorm:"type:text"`
+	IPAddress         string     `json:"ipAddress" gorm:"type:varchar(45)"`
+	LastUsedAt        time.Time  `json:"lastUsedAt"`
+	ExpiresAt         time.Time  `json:"expiresAt" gorm:"not null;index"`
+	RevokedAt         *time.Time `json:"revokedAt"`
+	RevokedReason     string     `json:"revokedReason,omitempty" gorm:"type:varchar(50)"`
+	CreatedAt         time.Time  `json:"createdAt"`
+}
+
+func (t *RefreshToken) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == "" {
+		t.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
+
+// Session revocation reasons
+const (
+	SessionRevokedLogout         = "LOGOUT"
+	SessionRevokedPasswordChange = "PASSWORD_CHANGE"
+	SessionRevokedTokenReuse     = "TOKEN_REUSE"
+	SessionRevokedAccountDeleted = "ACCOUNT_DELETED"
+)
+
+// =============== DTOs ===============
+
+// RefreshTokenRequest represents the token refresh request
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
+// SessionDTO is a login session as listed to its user
+type SessionDTO struct {
+	RefreshToken
+	Current bool `json:"current"` // the session of the request
+}
+
+// RevokeSessionsResult is returned when sessions are revoked
+type RevokeSessionsResult struct {
+	Revoked int64 `json:"revoked"`
+}
@@ -0,0 +1,233 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Ticket timeline events of the SLA clock
+const (
+	TicketEventSLAPaused   = "sla.paused"
+	TicketEventSLAResumed  = "sla.resumed"
+	TicketEventSLABreached = "sla.breached"
+)
+
+// SLA targets a ticket can miss
+const (
+	SLABreachResponse   = "RESPONSE"
+	SLABreachResolution = "RESOLUTION"
+)
+
+// IsWaitingStatus tells whether the status pauses the SLA clock
+func IsWaitingStatus(status TicketStatus) bool {
+	return status == TicketStatusWaitingClient || status == TicketStatusWaitingParts
+}
+
+// TicketSLAPause is an interval during which the ticket was waiting on the client or on
+// parts; the SLA clock does not run while it is open (EndedAt nil)
+type TicketSLAPause struct {
+	ID        string       `json:"id" gorm:"type:uuid;primaryKey"`
+	TicketID  string       `json:"ticketId" gorm:"type:uuid;not null;index"`
+	Status    TicketStatus `json:"status" gorm:"type:varchar(50);not null"` // the waiting status, i.e. the reason
+	Notes     string       `json:"notes" gorm:"type:text"`
+	PausedBy  string       `json:"pausedBy" gorm:"type:varchar(36)"`
+	StartedAt time.Time    `json:"startedAt" gorm:"not null"`
+	EndedAt   *time.Time   `json:"endedAt"`
+	ResumedBy string       `json:"resumedBy" gorm:"type:varchar(36)"`
+}
+
+func (p *TicketSLAPause) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == "" {
+		p.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (TicketSLAPause) TableName() string {
+	return "ticket_sla_pauses"
+}
+
+// Duration is the time spent waiting, up to now for an open interval
+func (p *TicketSLAPause) Duration(now time.Time) time.Duration {
+	end := now
+	if p.EndedAt != nil {
+		end = *p.EndedAt
+	}
+	if end.Before(p.StartedAt) {
+		return 0
+	}
+	return end.Sub(p.StartedAt)
+}
+
+// SLAPolicy sets the response and resolution targets of the tickets of a source channel,
+// category and/or priority. The most specific active policy wins: a policy of the source
+// overrides any without one, then category and priority, then category, then priority, then
+// neither; without one the built-in targets of the priority apply.
+type SLAPolicy struct {
+	ID                string          `json:"id" gorm:"type:uuid;primaryKey"`
+	Name              string          `json:"name" gorm:"type:varchar(100);not null"`
+	CategoryID        *string         `json:"categoryId" gorm:"type:uuid;index"`
+	Priority          *TicketPriority `json:"priority" gorm:"type:varchar(20)"`
+	Source            *TicketSource   `json:"source" gorm:"type:varchar(20)"`
+	ResponseMinutes   int             `json:"responseMinutes" gorm:"not null"`   // opening to first response
+	ResolutionMinutes int             `json:"resolutionMinutes" gorm:"not null"` // opening to closing, pauses taken out
+	Active            bool            `json:"active" gorm:"not null;default:true"`
+	CreatedBy         string          `json:"createdBy" gorm:"type:varchar(36)"`
+	CreatedAt         time.Time       `json:"createdAt"`
+	UpdatedAt         time.Time       `json:"updatedAt"`
+
+	Category *Category `json:"category,omitempty" gorm:"foreignKey:CategoryID"`
+}
+
+func (p *SLAPolicy) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == "" {
+		p.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (SLAPolicy) TableName() string {
+	return "sla_policies"
+}
+
+// Matches tells whether the policy applies to a ticket of the source, category and
+// priority, and how specific it is (higher wins)
+func (p *SLAPolicy) Matches(source TicketSource, categoryID *string, priority TicketPriority) (bool, int) {
+	specificity := 0
+	if p.Source != nil {
+		if *p.Source != source {
+			return false, 0
+		}
+		specificity += 4
+	}
+	if p.CategoryID != nil {
+		if categoryID == nil || *categoryID != *p.CategoryID {
+			return false, 0
+		}
+		specificity += 2
+	}
+	if p.Priority != nil {
+		if *p.Priority != priority {
+			return false, 0
+		}
+		specificity++
+	}
+	return true, specificity
+}
+
+// TicketSLABreach records that a ticket missed one of its targets. The SLA job writes it
+// once per ticket and target, together with a sla.breached timeline event.
+type TicketSLABreach struct {
+	ID         string    `json:"id" gorm:"type:uuid;primaryKey"`
+	TicketID   string    `json:"ticketId" gorm:"type:uuid;not null;uniqueIndex:idx_ticket_sla_breaches_ticket_kind,priority:1"`
+	Kind       string    `json:"kind" gorm:"type:varchar(20);not null;uniqueIndex:idx_ticket_sla_breaches_ticket_kind,priority:2"`
+	PolicyID   *string   `json:"policyId" gorm:"type:uuid"`
+	DueAt      time.Time `json:"dueAt"`
+	DetectedAt time.Time `json:"detectedAt" gorm:"not null;index"`
+
+	Ticket *Ticket `json:"ticket,omitempty" gorm:"foreignKey:TicketID"`
+}
+
+func (b *TicketSLABreach) BeforeCreate(tx *gorm.DB) error {
+	if b.ID == "" {
+		b.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (TicketSLABreach) TableName() string {
+	return "ticket_sla_breaches"
+}
+
+// =============== DTOs ===============
+
+// SLAPolicyRequest creates or updates an SLA policy; leave source, categoryId and priority
+// empty for a policy that applies to every ticket
+type SLAPolicyRequest struct {
+	Name              string          `json:"name" validate:"required,max=100"`
+	CategoryID        *string         `json:"categoryId"`
+	Priority          *TicketPriority `json:"priority" validate:"omitempty,oneof=BAIXA NORMAL ALTA URGENTE"`
+	Source            *TicketSource   `json:"source" validate:"omitempty,oneof=PORTAL PHONE EMAIL PARTNER_API WHATSAPP"`
+	ResponseMinutes   int             `json:"responseMinutes" validate:"required,min=1"`
+	ResolutionMinutes int             `json:"resolutionMinutes" validate:"required,min=1"`
+	Active            *bool           `json:"active"`
+}
+
+// TicketSLA is the SLA clock of a ticket, with the waiting intervals taken out
+type TicketSLA struct {
+	TicketID       string           `json:"ticketId"`
+	OSNumber       string           `json:"osNumber"`
+	Priority       TicketPriority   `json:"priority"`
+	Source         TicketSource     `json:"source"`
+	CategoryID     *string          `json:"categoryId"`
+	Status         TicketStatus     `json:"status"`
+	PolicyID       *string          `json:"policyId"` // nil when the built-in targets apply
+	PolicyName     string           `json:"policyName"`
+	TargetHours    float64          `json:"targetHours"`
+	OpenedAt       time.Time        `json:"openedAt"`
+	ClosedAt       *time.Time       `json:"closedAt"`
+	ElapsedHours   float64          `json:"elapsedHours"`
+	PausedHours    float64          `json:"pausedHours"`
+	EffectiveHours float64          `json:"effectiveHours"` // elapsed minus paused
+	DueAt          time.Time        `json:"dueAt"`          // opening + target, pushed back by the pauses
+	Paused         bool             `json:"paused"`
+	Breached       bool             `json:"breached"`
+	Pauses         []TicketSLAPause `json:"pauses"`
+
+	// Response: first status change, comment or check-in after opening
+	ResponseTargetHours float64    `json:"responseTargetHours"`
+	ResponseDueAt       time.Time  `json:"responseDueAt"`
+	RespondedAt         *time.Time `json:"respondedAt"`
+	ResponseBreached    bool       `json:"responseBreached"`
+}
+
+// SLAComplianceRow is the compliance of one slice of the closed tickets
+type SLAComplianceRow struct {
+	Key                       string  `json:"key"`
+	Total                     int     `json:"total"`
+	Met                       int     `json:"met"`
+	Breached                  int     `json:"breached"`
+	CompliancePercent         float64 `json:"compliancePercent"`
+	RawCompliancePercent      float64 `json:"rawCompliancePercent"` // without discounting the waiting time
+	ResponseBreached          int     `json:"responseBreached"`
+	ResponseCompliancePercent float64 `json:"responseCompliancePercent"`
+}
+
+// SLAWaitingSummary aggregates the time spent in one waiting status
+type SLAWaitingSummary struct {
+	Status       TicketStatus `json:"status"`
+	Pauses       int          `json:"pauses"`
+	Tickets      int          `json:"tickets"`
+	TotalHours   float64      `json:"totalHours"`
+	AverageHours float64      `json:"averageHours"`
+}
+
+// SLAComplianceReport covers the tickets closed in [From, To)
+type SLAComplianceReport struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	SLAComplianceRow
+	ByPriority []SLAComplianceRow  `json:"byPriority"`
+	ByCategory []SLAComplianceRow  `json:"byCategory"` // keyed by category ID, "NONE" without one
+	BySource   []SLAComplianceRow  `json:"bySource"`
+	Waiting    []SLAWaitingSummary `json:"waiting"`
+}
+
+// SLARunningSummary is the state of the SLA clocks of the open tickets
+type SLARunningSummary struct {
+	Open             int `json:"open"`
+	Paused           int `json:"paused"`
+	AtRisk           int `json:"atRisk"` // not breached yet, past the risk share of the target
+	Breached         int `json:"breached"`
+	ResponseBreached int `json:"responseBreached"`
+}
+
+// SLADashboard is the SLA compliance of the tickets closed in the window, the running
+// clocks and the latest breaches flagged by the SLA job
+type SLADashboard struct {
+	Compliance     SLAComplianceReport `json:"compliance"`
+	Running        SLARunningSummary   `json:"running"`
+	RecentBreaches []TicketSLABreach   `json:"recentBreaches"`
+}
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/shigake/tech-iq-back/internal/pagination"
 	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
@@ -17,11 +18,12 @@ const (
 	LocationBranch     StockLocationType = "BRANCH"
 	LocationTechnician StockLocationType = "TECHNICIAN"
 	LocationClient     StockLocationType = "CLIENT"
+	LocationQuarantine StockLocationType = "QUARANTINE" // defective parts awaiting RMA
 )
 
 func (t StockLocationType) IsValid() bool {
 	switch t {
-	case LocationWarehouse, LocationBranch, LocationTechnician, LocationClient:
+	case LocationWarehouse, LocationBranch, LocationTechnician, LocationClient, LocationQuarantine:
 		return true
 	}
 	return false
@@ -36,12 +38,14 @@ const (
 	MovementTypeSaidaConsumoOS   StockMovementType = "SAIDA_CONSUMO_OS"
 	MovementTypeSaidaPerda       StockMovementType = "SAIDA_PERDA"
 	MovementTypeAjusteInventario StockMovementType = "AJUSTE_INVENTARIO"
+	MovementTypeSaidaFornecedor  StockMovementType = "SAIDA_DEVOLUCAO_FORNECEDOR" // RMA part shipped back to the supplier
 )
 
 func (t StockMovementType) IsValid() bool {
 	switch t {
 	case MovementTypeEntradaCompra, MovementTypeEntradaDevolucao, MovementTypeTransferencia,
-		MovementTypeSaidaConsumoOS, MovementTypeSaidaPerda, MovementTypeAjusteInventario:
+		MovementTypeSaidaConsumoOS, MovementTypeSaidaPerda, MovementTypeAjusteInventario,
+		MovementTypeSaidaFornecedor:
 		return true
 	}
 	return false
@@ -52,7 +56,7 @@ func (t StockMovementType) IsEntry() bool {
 }
 
 func (t StockMovementType) IsExit() bool {
-	return t == MovementTypeSaidaConsumoOS || t == MovementTypeSaidaPerda
+	return t == MovementTypeSaidaConsumoOS || t == MovementTypeSaidaPerda || t == MovementTypeSaidaFornecedor
 }
 
 func (t StockMovementType) IsTransfer() bool {
@@ -63,12 +67,22 @@ func (t StockMovementType) IsAdjustment() bool {
 	return t == MovementTypeAjusteInventario
 }
 
+// StockMovementStatus tracks the approval of a movement
+type StockMovementStatus string
+
+const (
+	MovementStatusApproved StockMovementStatus = "APPROVED"
+	MovementStatusPending  StockMovementStatus = "PENDING"
+	MovementStatusRejected StockMovementStatus = "REJECTED"
+)
+
 // =============== Models ===============
 
 // StockItem represents an inventory item
 type StockItem struct {
 	ID          string    `json:"id" gorm:"type:uuid;primaryKey"`
-	SKU         string    `json:"sku" gorm:"type:varchar(100);uniqueIndex;not null"`
+	TenantID    string    `json:"tenantId" gorm:"type:varchar(36);not null;default:'00000000-0000-0000-0000-000000000001';uniqueIndex:idx_stock_items_tenant_sku,priority:1"`
+	SKU         string    `json:"sku" gorm:"type:varchar(100);uniqueIndex:idx_stock_items_tenant_sku,priority:2;not null"`
 	Name        string    `json:"name" gorm:"type:varchar(255);not null"`
 	Description *string   `json:"description" gorm:"type:text"`
 	Category    *string   `json:"category" gorm:"type:varchar(100)"`
@@ -93,13 +107,16 @@ func (StockItem) TableName() string {
 
 // StockLocation represents a storage location
 type StockLocation struct {
-	ID        string            `json:"id" gorm:"type:uuid;primaryKey"`
-	ScopeID   string            `json:"scopeId" gorm:"type:uuid;index;not null"`
-	Type      StockLocationType `json:"type" gorm:"type:varchar(50);not null"`
-	Name      string            `json:"name" gorm:"type:varchar(255);not null"`
-	IsActive  bool              `json:"isActive" gorm:"default:true"`
-	CreatedAt time.Time         `json:"createdAt"`
-	UpdatedAt time.Time         `json:"updatedAt"`
+	ID       string            `json:"id" gorm:"type:uuid;primaryKey"`
+	TenantID string            `json:"tenantId" gorm:"type:varchar(36);not null;default:'00000000-0000-0000-0000-000000000001';index"`
+	ScopeID  string            `json:"scopeId" gorm:"type:uuid;index;not null"`
+	Type     StockLocationType `json:"type" gorm:"type:varchar(50);not null"`
+	Name     string            `json:"name" gorm:"type:varchar(255);not null"`
+	// Technician carrying the stock, only for TECHNICIAN locations (van, backpack)
+	TechnicianID *string   `json:"technicianId" gorm:"type:varchar(36);index"`
+	IsActive     bool      `json:"isActive" gorm:"default:true"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
 }
 
 func (s *StockLocation) BeforeCreate(tx *gorm.DB) error {
@@ -116,12 +133,14 @@ func (StockLocation) TableName() string {
 // StockMovement represents an immutable ledger entry
 type StockMovement struct {
 	ID             string            `json:"id" gorm:"type:uuid;primaryKey"`
+	TenantID       string            `json:"tenantId" gorm:"type:varchar(36);not null;default:'00000000-0000-0000-0000-000000000001';index"`
 	ScopeID        string            `json:"scopeId" gorm:"type:uuid;index;not null"`
 	Type           StockMovementType `json:"type" gorm:"type:varchar(50);not null;index"`
 	ItemID         string            `json:"itemId" gorm:"type:uuid;not null;index"`
 	FromLocationID *string           `json:"fromLocationId" gorm:"type:uuid;index"`
 	ToLocationID   *string           `json:"toLocationId" gorm:"type:uuid;index"`
 	TicketID       *string           `json:"ticketId" gorm:"type:uuid;index"`
+	SupplierID     *string           `json:"supplierId" gorm:"type:uuid;index"` // vendor of entries from purchases, destination of returns
 	Quantity       int               `json:"quantity" gorm:"not null"`
 	UnitCost       *decimal.Decimal  `json:"unitCost" gorm:"type:decimal(12,2)"`
 	Notes          *string           `json:"notes" gorm:"type:text"`
@@ -129,11 +148,25 @@ type StockMovement struct {
 	PerformedAt    time.Time         `json:"performedAt" gorm:"not null"`
 	CreatedAt      time.Time         `json:"createdAt"`
 
+	// Approval of high-value transfers and over-budget consumption; balances only change once APPROVED
+	Status         StockMovementStatus `json:"status" gorm:"type:varchar(20);not null;default:'APPROVED';index"`
+	ApprovalReason *string             `json:"approvalReason,omitempty" gorm:"type:varchar(255)"`
+	DecidedBy      *string             `json:"decidedBy,omitempty" gorm:"type:uuid"`
+	DecidedAt      *time.Time          `json:"decidedAt,omitempty"`
+	DecisionNotes  *string             `json:"decisionNotes,omitempty" gorm:"type:text"`
+
 	// Relations (for eager loading)
 	Item         *StockItem     `json:"item,omitempty" gorm:"foreignKey:ItemID"`
 	FromLocation *StockLocation `json:"fromLocation,omitempty" gorm:"foreignKey:FromLocationID"`
 	ToLocation   *StockLocation `json:"toLocation,omitempty" gorm:"foreignKey:ToLocationID"`
 	Performer    *User          `json:"performer,omitempty" gorm:"foreignKey:PerformedBy"`
+	Supplier     *Supplier      `json:"supplier,omitempty" gorm:"foreignKey:SupplierID"`
+
+	// Serial numbers moved, for items with TrackSerial
+	Serials []StockMovementSerial `json:"serials,omitempty" gorm:"foreignKey:MovementID"`
+
+	// Non-blocking issues found when recording the movement (e.g. incompatible part)
+	Warnings []string `json:"warnings,omitempty" gorm:"-"`
 }
 
 func (s *StockMovement) BeforeCreate(tx *gorm.DB) error {
@@ -153,12 +186,17 @@ func (StockMovement) TableName() string {
 // StockBalance represents the materialized balance per item + location
 type StockBalance struct {
 	ID         string    `json:"id" gorm:"type:uuid;primaryKey"`
+	TenantID   string    `json:"tenantId" gorm:"type:varchar(36);not null;default:'00000000-0000-0000-0000-000000000001';index"`
 	ScopeID    string    `json:"scopeId" gorm:"type:uuid;index;not null"`
-	ItemID     string    `json:"itemId" gorm:"type:uuid;not null"`
-	LocationID string    `json:"locationId" gorm:"type:uuid;not null"`
+	ItemID     string    `json:"itemId" gorm:"type:uuid;not null;uniqueIndex:idx_stock_balance_item_location"` // one balance per item and location, the upserts' conflict target
+	LocationID string    `json:"locationId" gorm:"type:uuid;not null;uniqueIndex:idx_stock_balance_item_location"`
 	Quantity   int       `json:"quantity" gorm:"not null;default:0"`
 	UpdatedAt  time.Time `json:"updatedAt"`
 
+	// Held by active reservations and what is left for new exits and reservations
+	Reserved  int `json:"reserved" gorm:"-"`
+	Available int `json:"available" gorm:"-"`
+
 	// Relations (for eager loading)
 	Item     *StockItem     `json:"item,omitempty" gorm:"foreignKey:ItemID"`
 	Location *StockLocation `json:"location,omitempty" gorm:"foreignKey:LocationID"`
@@ -205,6 +243,8 @@ type CreateStockLocationRequest struct {
 	ScopeID string `json:"scopeId" validate:"required,uuid"`
 	Type    string `json:"type" validate:"required"`
 	Name    string `json:"name" validate:"required,min=1,max=255"`
+	// TechnicianID links a TECHNICIAN location to the technician carrying it
+	TechnicianID string `json:"technicianId"`
 }
 
 // UpdateStockLocationRequest DTO
@@ -213,6 +253,8 @@ type UpdateStockLocationRequest struct {
 	Type     *string `json:"type"`
 	Name     *string `json:"name"`
 	IsActive *bool   `json:"isActive"`
+	// TechnicianID "" unlinks the technician
+	TechnicianID *string `json:"technicianId"`
 }
 
 // CreateStockMovementRequest DTO
@@ -223,9 +265,17 @@ type CreateStockMovementRequest struct {
 	FromLocationID string            `json:"fromLocationId"`
 	ToLocationID   string            `json:"toLocationId"`
 	TicketID       string            `json:"ticketId"`
+	SupplierID     string            `json:"supplierId" validate:"omitempty,uuid"`
 	Quantity       int               `json:"quantity" validate:"required,gt=0"`
 	UnitCost       *decimal.Decimal  `json:"unitCost"`
 	Notes          string            `json:"notes"`
+	// SerialNumbers lists one serial per unit, required for items with TrackSerial
+	SerialNumbers []string `json:"serialNumbers"`
+}
+
+// MovementDecisionRequest DTO (approve/reject a pending transfer)
+type MovementDecisionRequest struct {
+	Notes string `json:"notes"`
 }
 
 // InventoryCountRequest DTO
@@ -235,6 +285,10 @@ type InventoryCountRequest struct {
 	ItemID          string  `json:"itemId" validate:"required,uuid"`
 	CountedQuantity int     `json:"countedQuantity" validate:"gte=0"`
 	Notes           *string `json:"notes"`
+	TaskID          *string `json:"taskId"` // completes a cycle-count task
+	// SerialNumbers of the units found (positive delta) or missing (negative delta), for
+	// items with TrackSerial
+	SerialNumbers []string `json:"serialNumbers"`
 }
 
 // InventoryCountResponse DTO
@@ -246,6 +300,7 @@ type InventoryCountResponse struct {
 	Delta          int    `json:"delta"`
 	AdjustmentMade bool   `json:"adjustmentMade"`
 	MovementID     string `json:"movementId,omitempty"`
+	CountID        string `json:"countId"`
 }
 
 // StockBalanceResponse with joined data
@@ -255,11 +310,15 @@ type StockBalanceResponse struct {
 	ItemID       string  `json:"itemId"`
 	LocationID   string  `json:"locationId"`
 	Quantity     int     `json:"quantity"`
+	Reserved     int     `json:"reserved"`  // held by active reservations
+	Available    int     `json:"available"` // quantity - reserved
 	ItemSKU      string  `json:"itemSku"`
 	ItemName     string  `json:"itemName"`
 	ItemUnit     string  `json:"itemUnit"`
 	LocationName string  `json:"locationName"`
 	LocationType string  `json:"locationType"`
+	MinQty       int     `json:"minQty"` // effective: location override, else item MinQty
+	MaxQty       int     `json:"maxQty"`
 	UpdatedAt    string  `json:"updatedAt"`
 }
 
@@ -274,8 +333,9 @@ type StockItemFilter struct {
 }
 
 type StockLocationFilter struct {
-	ScopeID  string
-	Type     string
+	ScopeID      string
+	Type         string
+	TechnicianID string
 	Search   string
 	IsActive *bool
 	Page     int
@@ -287,7 +347,7 @@ type StockBalanceFilter struct {
 	ItemID     string
 	LocationID string
 	Search     string
-	LowStock   bool // quantity <= minQty
+	LowStock   bool // quantity <= minQty (per-location override, else item MinQty)
 	Page       int
 	PageSize   int
 }
@@ -295,13 +355,16 @@ type StockBalanceFilter struct {
 type StockMovementFilter struct {
 	ScopeID    string
 	Type       string
+	Status     string
 	ItemID     string
 	LocationID string
 	TicketID   string
+	SupplierID string
 	StartDate  *time.Time
 	EndDate    *time.Time
 	Page       int
 	PageSize   int
+	Cursor     *pagination.Cursor // keyset position to page from instead of Page
 }
 
 // =============== Paginated Responses ===============
@@ -312,6 +375,7 @@ type PaginatedStockItems struct {
 	Page       int         `json:"page"`
 	PageSize   int         `json:"pageSize"`
 	TotalPages int         `json:"totalPages"`
+	HasNext    bool        `json:"hasNext"`
 }
 
 type PaginatedStockLocations struct {
@@ -320,6 +384,7 @@ type PaginatedStockLocations struct {
 	Page       int             `json:"page"`
 	PageSize   int             `json:"pageSize"`
 	TotalPages int             `json:"totalPages"`
+	HasNext    bool            `json:"hasNext"`
 }
 
 type PaginatedStockBalances struct {
@@ -328,6 +393,7 @@ type PaginatedStockBalances struct {
 	Page       int                    `json:"page"`
 	PageSize   int                    `json:"pageSize"`
 	TotalPages int                    `json:"totalPages"`
+	HasNext    bool                   `json:"hasNext"`
 }
 
 type PaginatedStockMovements struct {
@@ -336,4 +402,7 @@ type PaginatedStockMovements struct {
 	Page       int             `json:"page"`
 	PageSize   int             `json:"pageSize"`
 	TotalPages int             `json:"totalPages"`
+	HasNext    bool            `json:"hasNext"`
+	NextCursor string          `json:"nextCursor,omitempty"`
+	PrevCursor string          `json:"prevCursor,omitempty"`
 }
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// StockItemCompatibility states that a part fits an equipment model. An empty
+// Model means every model of the brand. Items without any entry are treated as
+// universal (cables, consumables) and never raise warnings.
+type StockItemCompatibility struct {
+	ID        string    `json:"id" gorm:"type:uuid;primaryKey"`
+	ItemID    string    `json:"itemId" gorm:"type:uuid;not null;uniqueIndex:idx_item_compatibility"`
+	Brand     string    `json:"brand" gorm:"type:varchar(100);not null;uniqueIndex:idx_item_compatibility;index"`
+	Model     string    `json:"model" gorm:"type:varchar(100);not null;default:'';uniqueIndex:idx_item_compatibility"`
+	Notes     *string   `json:"notes" gorm:"type:text"`
+	CreatedBy string    `json:"createdBy" gorm:"type:varchar(36)"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (s *StockItemCompatibility) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (StockItemCompatibility) TableName() string {
+	return "stock_item_compatibilities"
+}
+
+// Compatibility match kinds
+const (
+	CompatibilityMatchModel     = "MODEL"     // listed for the exact model
+	CompatibilityMatchBrand     = "BRAND"     // listed for every model of the brand
+	CompatibilityMatchUniversal = "UNIVERSAL" // item without compatibility entries
+)
+
+// =============== DTOs ===============
+
+// CreateStockCompatibilityRequest DTO
+type CreateStockCompatibilityRequest struct {
+	Brand string  `json:"brand" validate:"required,max=100"`
+	Model string  `json:"model" validate:"max=100"`
+	Notes *string `json:"notes"`
+}
+
+// CompatiblePartResponse is a part that fits the requested equipment
+type CompatiblePartResponse struct {
+	Item      StockItem `json:"item"`
+	MatchedBy string    `json:"matchedBy"`
+	Quantity  *int      `json:"quantity,omitempty"` // on hand at the requested location
+}
+
+// CompatiblePartsFilter DTO
+type CompatiblePartsFilter struct {
+	Brand            string
+	Model            string
+	TicketID         string // resolves brand/model from the ticket equipment
+	LocationID       string // adds the quantity on hand, e.g. the technician van
+	Search           string
+	IncludeUniversal bool
+	OnlyInStock      bool // requires LocationID
+}
+
+// CompatiblePartsResponse DTO
+type CompatiblePartsResponse struct {
+	Brand string                   `json:"brand"`
+	Model string                   `json:"model"`
+	Data  []CompatiblePartResponse `json:"data"`
+}
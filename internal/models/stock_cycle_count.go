@@ -0,0 +1,174 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ABC classes rank the items of a location by outbound usage: A items make up the
+// first 80% of the quantity moved out, B the next 15% and C the rest (or no usage)
+const (
+	ABCClassA = "A"
+	ABCClassB = "B"
+	ABCClassC = "C"
+)
+
+// Count task statuses
+const (
+	CountTaskPending   = "PENDING"
+	CountTaskCounted   = "COUNTED"
+	CountTaskCancelled = "CANCELLED"
+)
+
+// StockCountTask asks for an item to be counted at a location
+type StockCountTask struct {
+	ID          string     `json:"id" gorm:"type:uuid;primaryKey"`
+	ScopeID     string     `json:"scopeId" gorm:"type:uuid;index;not null"`
+	LocationID  string     `json:"locationId" gorm:"type:uuid;not null;index"`
+	ItemID      string     `json:"itemId" gorm:"type:uuid;not null;index"`
+	ABCClass    string     `json:"abcClass" gorm:"type:varchar(1);not null"`
+	Status      string     `json:"status" gorm:"type:varchar(20);not null;default:'PENDING';index"`
+	AssignedTo  *string    `json:"assignedTo" gorm:"type:uuid;index"`
+	DueDate     time.Time  `json:"dueDate" gorm:"not null"`
+	CountID     *string    `json:"countId" gorm:"type:uuid"`
+	CreatedBy   string     `json:"createdBy" gorm:"type:varchar(36)"` // empty when created by the scheduler
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+	CompletedAt *time.Time `json:"completedAt"`
+
+	// Relations (for eager loading)
+	Item     *StockItem     `json:"item,omitempty" gorm:"foreignKey:ItemID"`
+	Location *StockLocation `json:"location,omitempty" gorm:"foreignKey:LocationID"`
+	Assignee *User          `json:"assignee,omitempty" gorm:"foreignKey:AssignedTo"`
+}
+
+func (s *StockCountTask) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (StockCountTask) TableName() string {
+	return "stock_count_tasks"
+}
+
+// StockCount records every inventory count, with or without a task, whether or
+// not it led to an adjustment
+type StockCount struct {
+	ID          string    `json:"id" gorm:"type:uuid;primaryKey"`
+	ScopeID     string    `json:"scopeId" gorm:"type:uuid;index;not null"`
+	LocationID  string    `json:"locationId" gorm:"type:uuid;not null;index:idx_stock_count_location_item"`
+	ItemID      string    `json:"itemId" gorm:"type:uuid;not null;index:idx_stock_count_location_item"`
+	TaskID      *string   `json:"taskId" gorm:"type:uuid"`
+	PreviousQty int       `json:"previousQty"`
+	CountedQty  int       `json:"countedQty"`
+	Delta       int       `json:"delta"`
+	MovementID  *string   `json:"movementId" gorm:"type:uuid"`
+	CountedBy   string    `json:"countedBy" gorm:"type:varchar(36)"`
+	CountedAt   time.Time `json:"countedAt" gorm:"not null;index"`
+
+	// Relations (for eager loading)
+	Location *StockLocation `json:"location,omitempty" gorm:"foreignKey:LocationID"`
+}
+
+func (s *StockCount) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	if s.CountedAt.IsZero() {
+		s.CountedAt = time.Now()
+	}
+	return nil
+}
+
+func (StockCount) TableName() string {
+	return "stock_counts"
+}
+
+// CycleCountCandidate is an item stocked at a location with its usage and last count
+type CycleCountCandidate struct {
+	ItemID        string
+	ItemSKU       string
+	ItemName      string
+	Quantity      int
+	Usage         int // quantity moved out in the usage window
+	LastCountedAt *time.Time
+	HasOpenTask   bool
+}
+
+// =============== DTOs ===============
+
+// GenerateCycleCountRequest DTO
+type GenerateCycleCountRequest struct {
+	LocationID string     `json:"locationId" validate:"required,uuid"`
+	MaxItems   int        `json:"maxItems" validate:"gte=0,lte=100"` // default 10
+	AssignedTo *string    `json:"assignedTo"`
+	DueDate    *time.Time `json:"dueDate"` // default in 7 days
+}
+
+// AssignCountTaskRequest DTO
+type AssignCountTaskRequest struct {
+	AssignedTo string `json:"assignedTo" validate:"required,uuid"`
+}
+
+// StockCountTaskFilter DTO
+type StockCountTaskFilter struct {
+	ScopeID    string
+	LocationID string
+	AssignedTo string
+	Status     string
+	Page       int
+	PageSize   int
+}
+
+type PaginatedStockCountTasks struct {
+	Data       []StockCountTask `json:"data"`
+	Total      int64            `json:"total"`
+	Page       int              `json:"page"`
+	PageSize   int              `json:"pageSize"`
+	TotalPages int              `json:"totalPages"`
+	HasNext    bool             `json:"hasNext"`
+}
+
+// CountAccuracyFilter DTO
+type CountAccuracyFilter struct {
+	ScopeID    string
+	LocationID string
+	From       time.Time
+	To         time.Time
+}
+
+// CountAccuracy is the share of counts that matched the system balance
+type CountAccuracy struct {
+	Counts        int     `json:"counts"`
+	Accurate      int     `json:"accurate"`
+	AccuracyPct   float64 `json:"accuracyPct"`
+	AbsoluteDelta int     `json:"absoluteDelta"` // sum of |counted - system|
+}
+
+// CountAccuracyPoint is the accuracy of one month
+type CountAccuracyPoint struct {
+	Period string `json:"period"` // YYYY-MM
+	CountAccuracy
+}
+
+// LocationCountAccuracy is the accuracy of one location
+type LocationCountAccuracy struct {
+	LocationID   string `json:"locationId"`
+	LocationName string `json:"locationName"`
+	CountAccuracy
+}
+
+// CountAccuracyReport DTO
+type CountAccuracyReport struct {
+	From         time.Time               `json:"from"`
+	To           time.Time               `json:"to"`
+	Overall      CountAccuracy           `json:"overall"`
+	Trend        []CountAccuracyPoint    `json:"trend"`
+	ByLocation   []LocationCountAccuracy `json:"byLocation"`
+	TasksCounted int                     `json:"tasksCounted"`
+	TasksOverdue int64                   `json:"tasksOverdue"` // pending tasks past their due date
+}
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// StockKit is a named bundle of items used together for a kind of service
+// (e.g. "Troca de HD": disk, SATA cable, screws). Kits attached to a ticket
+// category are suggested for its tickets.
+type StockKit struct {
+	ID          string    `json:"id" gorm:"type:uuid;primaryKey"`
+	Name        string    `json:"name" gorm:"type:varchar(255);uniqueIndex;not null"`
+	Description *string   `json:"description" gorm:"type:text"`
+	IsActive    bool      `json:"isActive" gorm:"default:true"`
+	CreatedBy   string    `json:"createdBy" gorm:"type:varchar(36)"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+
+	// Relations (for eager loading)
+	Items      []StockKitItem `json:"items,omitempty" gorm:"foreignKey:KitID"`
+	Categories []Category     `json:"categories,omitempty" gorm:"many2many:stock_kit_categories;joinForeignKey:KitID;joinReferences:CategoryID"`
+}
+
+func (s *StockKit) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (StockKit) TableName() string {
+	return "stock_kits"
+}
+
+// StockKitItem is one line of a kit
+type StockKitItem struct {
+	ID       string `json:"id" gorm:"type:uuid;primaryKey"`
+	KitID    string `json:"kitId" gorm:"type:uuid;not null;uniqueIndex:idx_kit_item"`
+	ItemID   string `json:"itemId" gorm:"type:uuid;not null;uniqueIndex:idx_kit_item"`
+	Quantity int    `json:"quantity" gorm:"not null"`
+
+	// Relations (for eager loading)
+	Item *StockItem `json:"item,omitempty" gorm:"foreignKey:ItemID"`
+}
+
+func (s *StockKitItem) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (StockKitItem) TableName() string {
+	return "stock_kit_items"
+}
+
+// =============== DTOs ===============
+
+// StockKitItemRequest DTO
+type StockKitItemRequest struct {
+	ItemID   string `json:"itemId" validate:"required,uuid"`
+	Quantity int    `json:"quantity" validate:"required,gt=0"`
+}
+
+// CreateStockKitRequest DTO
+type CreateStockKitRequest struct {
+	Name        string                `json:"name" validate:"required,max=255"`
+	Description *string               `json:"description"`
+	Items       []StockKitItemRequest `json:"items" validate:"required,min=1,dive"`
+	CategoryIDs []string              `json:"categoryIds"`
+}
+
+// UpdateStockKitRequest DTO; Items and CategoryIDs replace the current ones when given
+type UpdateStockKitRequest struct {
+	Name        *string                `json:"name"`
+	Description *string                `json:"description"`
+	IsActive    *bool                  `json:"isActive"`
+	Items       *[]StockKitItemRequest `json:"items"`
+	CategoryIDs *[]string              `json:"categoryIds"`
+}
+
+// ConsumeKitRequest DTO
+type ConsumeKitRequest struct {
+	TicketID       string `json:"ticketId" validate:"required,uuid"`
+	FromLocationID string `json:"fromLocationId" validate:"required,uuid"`
+	Quantity       int    `json:"quantity"` // number of kits, default 1
+	Notes          string `json:"notes"`
+	// SerialNumbers per item ID, for the kit items with TrackSerial
+	SerialNumbers map[string][]string `json:"serialNumbers"`
+}
+
+// ConsumeKitResponse DTO
+type ConsumeKitResponse struct {
+	KitID     string          `json:"kitId"`
+	TicketID  string          `json:"ticketId"`
+	Movements []StockMovement `json:"movements"`
+	Warnings  []string        `json:"warnings,omitempty"`
+}
+
+// KitItemAvailability is the stock of one kit line at a location
+type KitItemAvailability struct {
+	ItemID   string `json:"itemId"`
+	ItemSKU  string `json:"itemSku"`
+	ItemName string `json:"itemName"`
+	ItemUnit string `json:"itemUnit"`
+	Required int    `json:"required"`
+	OnHand   int    `json:"onHand"`
+	Shortage int    `json:"shortage"`
+}
+
+// KitAvailability tells whether a location can supply a kit
+type KitAvailability struct {
+	KitID         string                `json:"kitId"`
+	KitName       string                `json:"kitName"`
+	LocationID    string                `json:"locationId"`
+	LocationName  string                `json:"locationName"`
+	Requested     int                   `json:"requested"`
+	Available     bool                  `json:"available"`
+	KitsAvailable int                   `json:"kitsAvailable"` // whole kits the location can supply
+	Items         []KitItemAvailability `json:"items"`
+}
+
+// StockKitFilter DTO
+type StockKitFilter struct {
+	Search     string
+	CategoryID string
+	IsActive   *bool
+	Page       int
+	PageSize   int
+}
+
+type PaginatedStockKits struct {
+	Data       []StockKit `json:"data"`
+	Total      int64      `json:"total"`
+	Page       int        `json:"page"`
+	PageSize   int        `json:"pageSize"`
+	TotalPages int        `json:"totalPages"`
+	HasNext    bool       `json:"hasNext"`
+}
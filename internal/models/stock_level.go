@@ -0,0 +1,103 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// StockLevel overrides the item MinQty for one location and sets the level to
+// replenish up to (a technician van needs less than the central warehouse).
+// MaxQty 0 means replenish only up to MinQty.
+type StockLevel struct {
+	ID         string    `json:"id" gorm:"type:uuid;primaryKey"`
+	LocationID string    `json:"locationId" gorm:"type:uuid;not null;uniqueIndex:idx_stock_level"`
+	ItemID     string    `json:"itemId" gorm:"type:uuid;not null;uniqueIndex:idx_stock_level;index"`
+	MinQty     int       `json:"minQty" gorm:"not null;default:0"`
+	MaxQty     int       `json:"maxQty" gorm:"not null;default:0"`
+	UpdatedBy  string    `json:"updatedBy" gorm:"type:varchar(36)"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+
+	// Relations (for eager loading)
+	Item     *StockItem     `json:"item,omitempty" gorm:"foreignKey:ItemID"`
+	Location *StockLocation `json:"location,omitempty" gorm:"foreignKey:LocationID"`
+}
+
+func (s *StockLevel) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (StockLevel) TableName() string {
+	return "stock_levels"
+}
+
+// =============== DTOs ===============
+
+// SetStockLevelRequest DTO
+type SetStockLevelRequest struct {
+	LocationID string `json:"locationId" validate:"required,uuid"`
+	ItemID     string `json:"itemId" validate:"required,uuid"`
+	MinQty     int    `json:"minQty" validate:"gte=0"`
+	MaxQty     int    `json:"maxQty" validate:"gte=0"`
+}
+
+// StockLevelFilter DTO
+type StockLevelFilter struct {
+	LocationID string
+	ItemID     string
+}
+
+// StockLevelRow is the quantity and effective levels of an item at a location
+type StockLevelRow struct {
+	ScopeID      string
+	LocationID   string
+	LocationName string
+	LocationType string
+	ItemID       string
+	ItemSKU      string
+	ItemName     string
+	ItemUnit     string
+	Quantity     int
+	MinQty       int // location override, else the item MinQty
+	MaxQty       int
+}
+
+// ReplenishmentTransfer is a suggested transfer into a location
+type ReplenishmentTransfer struct {
+	ItemID           string `json:"itemId"`
+	ItemSKU          string `json:"itemSku"`
+	ItemName         string `json:"itemName"`
+	FromLocationID   string `json:"fromLocationId"`
+	FromLocationName string `json:"fromLocationName"`
+	Quantity         int    `json:"quantity"`
+}
+
+// ReplenishmentPurchase is a shortage no source location can cover
+type ReplenishmentPurchase struct {
+	ItemID   string `json:"itemId"`
+	ItemSKU  string `json:"itemSku"`
+	ItemName string `json:"itemName"`
+	ItemUnit string `json:"itemUnit"`
+	Quantity int    `json:"quantity"`
+}
+
+// ReplenishmentSuggestion groups what a location needs
+type ReplenishmentSuggestion struct {
+	ScopeID      string                  `json:"scopeId"`
+	LocationID   string                  `json:"locationId"`
+	LocationName string                  `json:"locationName"`
+	LocationType string                  `json:"locationType"`
+	Transfers    []ReplenishmentTransfer `json:"transfers"`
+	Purchases    []ReplenishmentPurchase `json:"purchases"`
+}
+
+// ReplenishmentFilter DTO
+type ReplenishmentFilter struct {
+	ScopeID    string
+	LocationID string // only suggestions into this location
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Reservation statuses
+const (
+	ReservationStatusActive   = "ACTIVE"   // holds the quantity at the location
+	ReservationStatusConsumed = "CONSUMED" // the ticket consumed the parts (SAIDA_CONSUMO_OS)
+	ReservationStatusReleased = "RELEASED" // released by hand or by the ticket cancellation
+)
+
+// StockReservation holds parts at a location for a ticket before they are consumed.
+// Active reservations are not available to other tickets: exits and transfers cannot take
+// the balance below the reserved quantity.
+type StockReservation struct {
+	ID                 string     `json:"id" gorm:"type:uuid;primaryKey"`
+	ScopeID            string     `json:"scopeId" gorm:"type:uuid;index;not null"`
+	TicketID           string     `json:"ticketId" gorm:"type:uuid;not null;index"`
+	ItemID             string     `json:"itemId" gorm:"type:uuid;not null;index:idx_reservation_item_location"`
+	LocationID         string     `json:"locationId" gorm:"type:uuid;not null;index:idx_reservation_item_location"`
+	Quantity           int        `json:"quantity" gorm:"not null"`
+	Status             string     `json:"status" gorm:"type:varchar(20);not null;default:'ACTIVE';index"`
+	Notes              *string    `json:"notes" gorm:"type:text"`
+	ConsumedMovementID *string    `json:"consumedMovementId" gorm:"type:uuid"`
+	ReleaseReason      *string    `json:"releaseReason" gorm:"type:varchar(255)"`
+	ReleasedAt         *time.Time `json:"releasedAt"`
+	CreatedBy          string     `json:"createdBy" gorm:"type:varchar(36)"`
+	CreatedAt          time.Time  `json:"createdAt"`
+	UpdatedAt          time.Time  `json:"updatedAt"`
+
+	// Relations (for eager loading)
+	Item     *StockItem     `json:"item,omitempty" gorm:"foreignKey:ItemID"`
+	Location *StockLocation `json:"location,omitempty" gorm:"foreignKey:LocationID"`
+}
+
+func (s *StockReservation) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (StockReservation) TableName() string {
+	return "stock_reservations"
+}
+
+// =============== DTOs ===============
+
+// CreateStockReservationRequest DTO
+type CreateStockReservationRequest struct {
+	TicketID   string `json:"ticketId" validate:"required,uuid"`
+	ItemID     string `json:"itemId" validate:"required,uuid"`
+	LocationID string `json:"locationId" validate:"required,uuid"`
+	Quantity   int    `json:"quantity" validate:"required,gt=0"`
+	Notes      string `json:"notes"`
+}
+
+// ReleaseStockReservationRequest DTO
+type ReleaseStockReservationRequest struct {
+	Reason string `json:"reason"`
+}
+
+// StockReservationFilter DTO
+type StockReservationFilter struct {
+	TicketID   string
+	ItemID     string
+	LocationID string
+	Status     string
+	Page       int
+	PageSize   int
+}
+
+type PaginatedStockReservations struct {
+	Data       []StockReservation `json:"data"`
+	Total      int64              `json:"total"`
+	Page       int                `json:"page"`
+	PageSize   int                `json:"pageSize"`
+	TotalPages int                `json:"totalPages"`
+	HasNext    bool               `json:"hasNext"`
+}
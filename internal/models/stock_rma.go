@@ -0,0 +1,142 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// RMA statuses
+const (
+	RMAStatusQuarantine     = "QUARANTINE"       // part received from the client, held in quarantine
+	RMAStatusSentToSupplier = "SENT_TO_SUPPLIER" // shipped back, awaiting the supplier outcome
+	RMAStatusClosed         = "CLOSED"
+)
+
+// RMA outcomes
+const (
+	RMAOutcomeCredit      = "CREDIT"      // supplier credited the part
+	RMAOutcomeReplacement = "REPLACEMENT" // supplier sent a replacement part
+	RMAOutcomeRejected    = "REJECTED"    // supplier refused the return
+	RMAOutcomeScrapped    = "SCRAPPED"    // discarded without going back to the supplier
+)
+
+// StockRMA tracks a defective part removed from a client through quarantine and the
+// return to its supplier
+type StockRMA struct {
+	ID                    string           `json:"id" gorm:"type:uuid;primaryKey"`
+	RMANumber             string           `json:"rmaNumber" gorm:"type:varchar(20);uniqueIndex;not null"`
+	ScopeID               string           `json:"scopeId" gorm:"type:uuid;index;not null"`
+	ItemID                string           `json:"itemId" gorm:"type:uuid;not null;index"`
+	Quantity              int              `json:"quantity" gorm:"not null"`
+	SerialNumber          *string          `json:"serialNumber" gorm:"type:varchar(100)"`
+	DefectDescription     string           `json:"defectDescription" gorm:"type:text;not null"`
+	TicketID              string           `json:"ticketId" gorm:"type:uuid;not null;index"`
+	OriginalMovementID    *string          `json:"originalMovementId" gorm:"type:uuid;index"` // consumption that installed the part
+	QuarantineLocationID  string           `json:"quarantineLocationId" gorm:"type:uuid;not null"`
+	ReturnMovementID      string           `json:"returnMovementId" gorm:"type:uuid"` // entry into quarantine
+	Status                string           `json:"status" gorm:"type:varchar(20);not null;default:'QUARANTINE';index"`
+	Supplier              *string          `json:"supplier" gorm:"type:varchar(255);index"`
+	SupplierRMANumber     *string          `json:"supplierRmaNumber" gorm:"type:varchar(100)"`
+	TrackingCode          *string          `json:"trackingCode" gorm:"type:varchar(100)"`
+	ShipmentMovementID    *string          `json:"shipmentMovementId" gorm:"type:uuid"` // exit from quarantine
+	ShippedAt             *time.Time       `json:"shippedAt"`
+	Outcome               *string          `json:"outcome" gorm:"type:varchar(20);index"`
+	CreditAmount          *decimal.Decimal `json:"creditAmount" gorm:"type:decimal(12,2)"`
+	ReplacementMovementID *string          `json:"replacementMovementId" gorm:"type:uuid"`
+	ResolutionNotes       *string          `json:"resolutionNotes" gorm:"type:text"`
+	ResolvedAt            *time.Time       `json:"resolvedAt"`
+	CreatedBy             string           `json:"createdBy" gorm:"type:varchar(36)"`
+	CreatedAt             time.Time        `json:"createdAt"`
+	UpdatedAt             time.Time        `json:"updatedAt"`
+
+	// Relations (for eager loading)
+	Item               *StockItem     `json:"item,omitempty" gorm:"foreignKey:ItemID"`
+	Ticket             *Ticket        `json:"-" gorm:"foreignKey:TicketID"`
+	QuarantineLocation *StockLocation `json:"quarantineLocation,omitempty" gorm:"foreignKey:QuarantineLocationID"`
+}
+
+func (r *StockRMA) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	if r.RMANumber == "" {
+		prefix := fmt.Sprintf("RMA-%d-", time.Now().Year())
+		var last string
+		tx.Model(&StockRMA{}).
+			Where("rma_number LIKE ?", prefix+"%").
+			Order("rma_number DESC").
+			Limit(1).
+			Pluck("rma_number", &last)
+
+		next := 1
+		if seq, err := strconv.Atoi(strings.TrimPrefix(last, prefix)); err == nil {
+			next = seq + 1
+		}
+		r.RMANumber = fmt.Sprintf("%s%06d", prefix, next)
+	}
+	return nil
+}
+
+func (StockRMA) TableName() string {
+	return "stock_rmas"
+}
+
+// =============== DTOs ===============
+
+// CreateRMARequest DTO
+type CreateRMARequest struct {
+	TicketID             string  `json:"ticketId" validate:"required,uuid"`
+	ItemID               string  `json:"itemId" validate:"required,uuid"`
+	Quantity             int     `json:"quantity" validate:"required,gt=0"`
+	SerialNumber         *string `json:"serialNumber"`
+	DefectDescription    string  `json:"defectDescription" validate:"required"`
+	OriginalMovementID   string  `json:"originalMovementId"` // default: latest consumption of the item on the ticket
+	QuarantineLocationID string  `json:"quarantineLocationId" validate:"required,uuid"`
+}
+
+// ShipRMARequest DTO
+type ShipRMARequest struct {
+	Supplier          string  `json:"supplier" validate:"required,max=255"`
+	SupplierRMANumber *string `json:"supplierRmaNumber"`
+	TrackingCode      *string `json:"trackingCode"`
+}
+
+// ResolveRMARequest DTO
+type ResolveRMARequest struct {
+	Outcome               string           `json:"outcome" validate:"required,oneof=CREDIT REPLACEMENT REJECTED SCRAPPED"`
+	CreditAmount          *decimal.Decimal `json:"creditAmount"`          // CREDIT
+	ReplacementLocationID string           `json:"replacementLocationId"` // REPLACEMENT: where the new part goes
+	ReplacementSerials    []string         `json:"replacementSerials"`    // REPLACEMENT of a serial-tracked item
+	Notes                 *string          `json:"notes"`
+}
+
+// StockRMAResponse adds the ticket reference to the RMA
+type StockRMAResponse struct {
+	StockRMA
+	OSNumber string `json:"osNumber"`
+}
+
+// StockRMAFilter DTO
+type StockRMAFilter struct {
+	Status   string
+	Supplier string
+	TicketID string
+	ItemID   string
+	Page     int
+	PageSize int
+}
+
+type PaginatedStockRMAs struct {
+	Data       []StockRMAResponse `json:"data"`
+	Total      int64              `json:"total"`
+	Page       int                `json:"page"`
+	PageSize   int                `json:"pageSize"`
+	TotalPages int                `json:"totalPages"`
+	HasNext    bool               `json:"hasNext"`
+}
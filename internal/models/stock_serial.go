@@ -0,0 +1,94 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Serial unit statuses
+const (
+	SerialStatusInStock   = "IN_STOCK"  // at LocationID
+	SerialStatusInstalled = "INSTALLED" // consumed on a ticket (SAIDA_CONSUMO_OS)
+	SerialStatusLost      = "LOST"      // SAIDA_PERDA or negative inventory adjustment
+	SerialStatusReturned  = "RETURNED"  // shipped back to the supplier
+)
+
+// StockSerialUnit is one physical unit of a serial-tracked item (TrackSerial). Every
+// movement of such an item lists the serial numbers it moves, so each unit has a
+// current location or destination and a traceable history.
+type StockSerialUnit struct {
+	ID             string    `json:"id" gorm:"type:uuid;primaryKey"`
+	ItemID         string    `json:"itemId" gorm:"type:uuid;not null;uniqueIndex:idx_serial_unit"`
+	SerialNumber   string    `json:"serialNumber" gorm:"type:varchar(100);not null;uniqueIndex:idx_serial_unit;index"`
+	ScopeID        string    `json:"scopeId" gorm:"type:uuid;index;not null"`
+	Status         string    `json:"status" gorm:"type:varchar(20);not null;index"`
+	LocationID     *string   `json:"locationId" gorm:"type:uuid;index"` // only while IN_STOCK
+	TicketID       *string   `json:"ticketId" gorm:"type:uuid;index"`   // ticket of the last movement, if any
+	LastMovementID string    `json:"lastMovementId" gorm:"type:uuid"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+
+	// Relations (for eager loading)
+	Item     *StockItem     `json:"item,omitempty" gorm:"foreignKey:ItemID"`
+	Location *StockLocation `json:"location,omitempty" gorm:"foreignKey:LocationID"`
+}
+
+func (s *StockSerialUnit) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (StockSerialUnit) TableName() string {
+	return "stock_serial_units"
+}
+
+// StockMovementSerial lists a serial number moved by a movement; pending movements keep
+// their serials here until they are approved
+type StockMovementSerial struct {
+	ID           string `json:"-" gorm:"type:uuid;primaryKey"`
+	MovementID   string `json:"movementId" gorm:"type:uuid;not null;index"`
+	ItemID       string `json:"itemId" gorm:"type:uuid;not null;index:idx_movement_serial"`
+	SerialNumber string `json:"serialNumber" gorm:"type:varchar(100);not null;index:idx_movement_serial"`
+}
+
+func (s *StockMovementSerial) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (StockMovementSerial) TableName() string {
+	return "stock_movement_serials"
+}
+
+// =============== DTOs ===============
+
+// SerialHistory traces one unit: where it is now and every movement that moved it
+type SerialHistory struct {
+	Unit      StockSerialUnit `json:"unit"`
+	Movements []StockMovement `json:"movements"`
+}
+
+// StockSerialFilter DTO
+type StockSerialFilter struct {
+	ItemID     string
+	LocationID string
+	Status     string
+	Search     string
+	Page       int
+	PageSize   int
+}
+
+type PaginatedStockSerials struct {
+	Data       []StockSerialUnit `json:"data"`
+	Total      int64             `json:"total"`
+	Page       int               `json:"page"`
+	PageSize   int               `json:"pageSize"`
+	TotalPages int               `json:"totalPages"`
+	HasNext    bool              `json:"hasNext"`
+}
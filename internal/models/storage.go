@@ -0,0 +1,93 @@
+package models
+
+import "time"
+
+// StorageModuleTickets is the upload directory (and accounting module) of ticket attachments
+const StorageModuleTickets = "tickets"
+
+// StorageModuleClientDocuments is the upload directory of the client document vault
+const StorageModuleClientDocuments = "client-documents"
+
+// StorageQuota limits how many bytes a tenant (root hierarchy node) may store.
+// NodeID nil is the default applied to every tenant, Module "" covers all modules.
+type StorageQuota struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	NodeID    *uint     `json:"nodeId" gorm:"uniqueIndex:idx_storage_quota_scope"`
+	Module    string    `json:"module" gorm:"type:varchar(50);not null;default:'';uniqueIndex:idx_storage_quota_scope"`
+	MaxBytes  int64     `json:"maxBytes" gorm:"not null"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func (StorageQuota) TableName() string {
+	return "storage_quotas"
+}
+
+// =============== DTOs ===============
+
+// UpsertStorageQuotaRequest DTO
+type UpsertStorageQuotaRequest struct {
+	NodeID   *uint  `json:"nodeId"`
+	Module   string `json:"module"`
+	MaxBytes int64  `json:"maxBytes" validate:"gte=0"`
+}
+
+// StorageModuleUsage is the disk usage of one module directory
+type StorageModuleUsage struct {
+	Module string `json:"module"`
+	Files  int64  `json:"files"`
+	Bytes  int64  `json:"bytes"`
+}
+
+// StorageTenantUsage is the accounted usage of one tenant against its quota
+type StorageTenantUsage struct {
+	NodeID       *uint   `json:"nodeId"` // nil = tickets without hierarchy node
+	NodeName     string  `json:"nodeName"`
+	Files        int64   `json:"files"`
+	Bytes        int64   `json:"bytes"`
+	QuotaBytes   int64   `json:"quotaBytes"` // 0 = unlimited
+	UsagePercent float64 `json:"usagePercent"`
+}
+
+// StorageAgeBucket groups stored files by age
+type StorageAgeBucket struct {
+	Bucket string `json:"bucket"`
+	Files  int64  `json:"files"`
+	Bytes  int64  `json:"bytes"`
+}
+
+// OrphanFile is a file on disk or an attachment record no entity references anymore
+type OrphanFile struct {
+	Path       string    `json:"path"`
+	Module     string    `json:"module"`
+	Bytes      int64     `json:"bytes"`
+	ModifiedAt time.Time `json:"modifiedAt"`
+	Reason     string    `json:"reason"` // UNREFERENCED_FILE, DELETED_TICKET
+	RecordID   string    `json:"recordId,omitempty"`
+}
+
+// OrphanReport lists orphan files found in storage
+type OrphanReport struct {
+	Files []OrphanFile `json:"files"`
+	Count int          `json:"count"`
+	Bytes int64        `json:"bytes"`
+}
+
+// StorageReport is returned by /admin/storage
+type StorageReport struct {
+	TotalFiles  int64                `json:"totalFiles"`
+	TotalBytes  int64                `json:"totalBytes"`
+	ByModule    []StorageModuleUsage `json:"byModule"`
+	ByTenant    []StorageTenantUsage `json:"byTenant"`
+	ByAge       []StorageAgeBucket   `json:"byAge"`
+	OrphanCount int                  `json:"orphanCount"`
+	OrphanBytes int64                `json:"orphanBytes"`
+	GeneratedAt time.Time            `json:"generatedAt"`
+}
+
+// StorageCleanupResult summarizes an orphan cleanup run
+type StorageCleanupResult struct {
+	FilesRemoved   int   `json:"filesRemoved"`
+	RecordsRemoved int   `json:"recordsRemoved"`
+	BytesFreed     int64 `json:"bytesFreed"`
+}
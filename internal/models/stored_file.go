@@ -0,0 +1,115 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// StorageModuleFiles is the upload directory (and quota module) of the /files uploads
+const StorageModuleFiles = "files"
+
+// Records a stored file can be attached to
+const (
+	FileOwnerTicket          = "TICKET"
+	FileOwnerFinancialEntry  = "FINANCIAL_ENTRY"
+	FileOwnerStockMovement   = "STOCK_MOVEMENT"
+	FileOwnerNodeBranding    = "NODE_BRANDING"    // OwnerID is the node ID; logos of NodeBranding
+	FileOwnerInvoice         = "INVOICE"          // XML and PDF returned by the invoicing provider, stored by the server
+	FileOwnerFinancialExport = "FINANCIAL_EXPORT" // accounting export files, stored by the server
+	FileOwnerReportRun       = "REPORT_RUN"       // CSV of a scheduled report run, stored by the server
+)
+
+// Lifecycle of a stored file: the client uploads it through the presigned URL, then
+// completes it, which checks the size and runs the antivirus
+const (
+	FileStatusPendingUpload = "PENDING_UPLOAD"
+	FileStatusReady         = "READY"
+	FileStatusInfected      = "INFECTED"
+)
+
+// FileContentTypes are the content types accepted per owner
+var FileContentTypes = map[string][]string{
+	FileOwnerTicket:          {"image/jpeg", "image/png", "image/webp", "application/pdf", "video/mp4"},
+	FileOwnerFinancialEntry:  {"application/pdf", "image/jpeg", "image/png", "application/xml", "text/xml"},
+	FileOwnerStockMovement:   {"application/pdf", "image/jpeg", "image/png"},
+	FileOwnerNodeBranding:    {"image/jpeg", "image/png"},
+	FileOwnerInvoice:         {"application/xml", "application/pdf"},
+	FileOwnerFinancialExport: {"text/csv", "text/plain"},
+	FileOwnerReportRun:       {"text/csv"},
+}
+
+// FilePermission is the permission reading (View) or changing (Edit) the files of an
+// owner requires, held on the node of the owner
+type FilePermission struct {
+	View string
+	Edit string
+}
+
+// FilePermissions are the permissions per owner
+var FilePermissions = map[string]FilePermission{
+	FileOwnerTicket:          {View: "tickets.view", Edit: "tickets.edit"},
+	FileOwnerFinancialEntry:  {View: "finance.view", Edit: "finance.create"},
+	FileOwnerStockMovement:   {View: "inventory.view", Edit: "inventory.manage"},
+	FileOwnerNodeBranding:    {View: "settings.view", Edit: "settings.manage"},
+	FileOwnerInvoice:         {View: "finance.view", Edit: "finance.create"},
+	FileOwnerFinancialExport: {View: "finance.view", Edit: "finance.create"},
+	FileOwnerReportRun:       {View: "reports.view", Edit: "reports.export"},
+}
+
+// StoredFile is a file kept in the storage backend (local disk or S3) for a ticket,
+// financial entry, stock movement or node branding
+type StoredFile struct {
+	ID          string     `json:"id" gorm:"type:uuid;primaryKey"`
+	TenantID    string     `json:"tenantId" gorm:"type:varchar(36);not null;default:'00000000-0000-0000-0000-000000000001';index"`
+	OwnerType   string     `json:"ownerType" gorm:"type:varchar(30);not null;index:idx_stored_file_owner"`
+	OwnerID     string     `json:"ownerId" gorm:"type:varchar(36);not null;index:idx_stored_file_owner"`
+	FileName    string     `json:"fileName" gorm:"type:varchar(255);not null"`
+	ContentType string     `json:"contentType" gorm:"type:varchar(100);not null"`
+	Size        int64      `json:"size" gorm:"not null"`
+	Backend     string     `json:"backend" gorm:"type:varchar(10);not null"`
+	Key         string     `json:"-" gorm:"type:varchar(500);not null"`
+	Status      string     `json:"status" gorm:"type:varchar(20);not null;index"`
+	ScanResult  string     `json:"scanResult,omitempty" gorm:"type:varchar(255)"`
+	UploadedBy  string     `json:"uploadedBy" gorm:"type:varchar(36)"`
+	UploadedAt  *time.Time `json:"uploadedAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+func (StoredFile) TableName() string {
+	return "stored_files"
+}
+
+func (f *StoredFile) BeforeCreate(tx *gorm.DB) error {
+	if f.ID == "" {
+		f.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// =============== DTOs ===============
+
+// CreateFileUploadRequest DTO
+type CreateFileUploadRequest struct {
+	OwnerType   string `json:"ownerType" validate:"required,oneof=TICKET FINANCIAL_ENTRY STOCK_MOVEMENT NODE_BRANDING"`
+	OwnerID     string `json:"ownerId" validate:"required"`
+	FileName    string `json:"fileName" validate:"required,max=255"`
+	ContentType string `json:"contentType" validate:"required,max=100"`
+	Size        int64  `json:"size" validate:"required,gt=0"`
+}
+
+// FileUpload is a pending file with the URL its content must be PUT to
+type FileUpload struct {
+	File      *StoredFile       `json:"file"`
+	UploadURL string            `json:"uploadUrl"`
+	Method    string            `json:"method"`
+	Headers   map[string]string `json:"headers"`
+	ExpiresAt time.Time         `json:"expiresAt"`
+}
+
+// FileDownload is a short-lived URL serving a ready file
+type FileDownload struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Supplier is a vendor of parts and services; stock purchases and expenses may reference it
+type Supplier struct {
+	ID          string `json:"id" gorm:"type:uuid;primaryKey"`
+	Name        string `json:"name" gorm:"type:varchar(255);not null;index"` // legal name (razão social)
+	TradeName   string `json:"tradeName" gorm:"type:varchar(255)"`           // nome fantasia
+	CNPJ        string `json:"cnpj" gorm:"type:varchar(18);index:idx_suppliers_cnpj,unique,where:cnpj <> '' AND deleted_at IS NULL"`
+	Email       string `json:"email" gorm:"type:varchar(255)"`
+	Phone       string `json:"phone" gorm:"type:varchar(20)"`
+	ContactName string `json:"contactName" gorm:"type:varchar(255)"`
+
+	// Address
+	Street       string `json:"street" gorm:"type:varchar(255)"`
+	Number       string `json:"number" gorm:"type:varchar(20)"`
+	Complement   string `json:"complement" gorm:"type:varchar(100)"`
+	Neighborhood string `json:"neighborhood" gorm:"type:varchar(100)"`
+	City         string `json:"city" gorm:"type:varchar(100)"`
+	State        string `json:"state" gorm:"type:varchar(2)"`
+	ZipCode      string `json:"zipCode" gorm:"type:varchar(10)"`
+
+	Notes  string `json:"notes" gorm:"type:text"`
+	Active bool   `json:"active" gorm:"not null;default:true"`
+
+	CreatedBy string         `json:"createdBy" gorm:"type:varchar(36)"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+func (s *Supplier) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (Supplier) TableName() string {
+	return "suppliers"
+}
+
+// =============== DTOs ===============
+
+// SupplierRequest DTO (create and full update)
+type SupplierRequest struct {
+	Name         string `json:"name" validate:"required,max=255"`
+	TradeName    string `json:"tradeName" validate:"max=255"`
+	CNPJ         string `json:"cnpj" validate:"max=18"`
+	Email        string `json:"email" validate:"omitempty,email,max=255"`
+	Phone        string `json:"phone" validate:"max=20"`
+	ContactName  string `json:"contactName" validate:"max=255"`
+	Street       string `json:"street" validate:"max=255"`
+	Number       string `json:"number" validate:"max=20"`
+	Complement   string `json:"complement" validate:"max=100"`
+	Neighborhood string `json:"neighborhood" validate:"max=100"`
+	City         string `json:"city" validate:"max=100"`
+	State        string `json:"state" validate:"omitempty,len=2"`
+	ZipCode      string `json:"zipCode" validate:"max=10"`
+	Notes        string `json:"notes"`
+	Active       *bool  `json:"active"`
+}
+
+// SupplierFilters DTO
+type SupplierFilters struct {
+	Search   string // name, trade name or CNPJ
+	Inactive bool   // include inactive suppliers
+}
@@ -26,6 +26,10 @@ type SystemMetrics struct {
 	TotalRequests   int64   `json:"totalRequests"`
 	AvgResponseTime float64 `json:"avgResponseTime"` // Milliseconds
 	ErrorRate       float64 `json:"errorRate"`       // Percentage
+	P95ResponseTime float64 `json:"p95ResponseTime"` // Milliseconds
+	
+	// p95 latency of the hottest routes against their budget (last hour)
+	LatencyBudgets  []LatencyBudgetStatus `json:"latencyBudgets"`
 	
 	// Cache metrics
 	CacheHitRate    float64 `json:"cacheHitRate"`    // Percentage
@@ -42,12 +46,62 @@ type SystemMetrics struct {
 
 // RequestMetric stores individual request metrics for aggregation
 type RequestMetric struct {
-	ID           string    `json:"id" gorm:"type:varchar(36);primaryKey"`
-	Path         string    `json:"path" gorm:"type:varchar(255);index"`
-	Method       string    `json:"method" gorm:"type:varchar(10)"`
-	StatusCode   int       `json:"statusCode" gorm:"index"`
-	ResponseTime float64   `json:"responseTime"` // Milliseconds
-	UserID       string    `json:"userId" gorm:"type:varchar(36);index"`
-	IPAddress    string    `json:"ipAddress" gorm:"type:varchar(45)"`
-	CreatedAt    time.Time `json:"createdAt" gorm:"index"`
+	ID           string  `json:"id" gorm:"type:varchar(36);primaryKey"`
+	Path         string  `json:"path" gorm:"type:varchar(255);index"`
+	Method       string  `json:"method" gorm:"type:varchar(10)"`
+	StatusCode   int     `json:"statusCode" gorm:"index"`
+	ResponseTime float64 `json:"responseTime"` // Milliseconds
+	// Payload sizes in bytes, response before compression
+	RequestBytes  int       `json:"requestBytes"`
+	ResponseBytes int       `json:"responseBytes"`
+	UserID        string    `json:"userId" gorm:"type:varchar(36);index"`
+	IPAddress     string    `json:"ipAddress" gorm:"type:varchar(45)"`
+	CreatedAt     time.Time `json:"createdAt" gorm:"index"`
+}
+
+// LatencyBudget is the p95 latency a route must stay under
+type LatencyBudget struct {
+	Method string  `json:"method"`
+	Path   string  `json:"path"` // Route pattern, e.g. /api/v1/tickets/:id
+	P95Ms  float64 `json:"p95Ms"`
+}
+
+// DefaultLatencyBudgets covers the hottest endpoints; keep in sync with loadtest/budgets.json
+var DefaultLatencyBudgets = []LatencyBudget{
+	{Method: "POST", Path: "/api/v1/geo/locations", P95Ms: 150},
+	{Method: "POST", Path: "/api/v1/geo/locations/batch", P95Ms: 300},
+	{Method: "GET", Path: "/api/v1/geo/technicians/last", P95Ms: 300},
+	{Method: "GET", Path: "/api/v1/tickets", P95Ms: 300},
+	{Method: "GET", Path: "/api/v1/tickets/:id", P95Ms: 150},
+	{Method: "GET", Path: "/api/v1/dashboard/stats", P95Ms: 250},
+}
+
+// RouteLatency is the observed latency of a route over a window
+type RouteLatency struct {
+	Method  string  `json:"method"`
+	Path    string  `json:"path"`
+	P95Ms   float64 `json:"p95Ms"`
+	Samples int64   `json:"samples"`
+}
+
+// RoutePayload is the observed request and response size of a route over a window
+type RoutePayload struct {
+	Method           string  `json:"method"`
+	Path             string  `json:"path"`
+	Samples          int64   `json:"samples"`
+	AvgRequestBytes  float64 `json:"avgRequestBytes"`
+	MaxRequestBytes  int64   `json:"maxRequestBytes"`
+	AvgResponseBytes float64 `json:"avgResponseBytes"`
+	P95ResponseBytes float64 `json:"p95ResponseBytes"`
+	MaxResponseBytes int64   `json:"maxResponseBytes"`
+}
+
+// LatencyBudgetStatus compares the observed p95 of a route with its budget
+type LatencyBudgetStatus struct {
+	Method   string  `json:"method"`
+	Path     string  `json:"path"`
+	BudgetMs float64 `json:"budgetMs"`
+	P95Ms    float64 `json:"p95Ms"`
+	Samples  int64   `json:"samples"`
+	Exceeded bool    `json:"exceeded"`
 }
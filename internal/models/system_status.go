@@ -0,0 +1,111 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Overall / component status values shown on the public status page
+const (
+	SystemStatusOperational = "operational"
+	SystemStatusDegraded    = "degraded"
+	SystemStatusOutage      = "outage"
+	SystemStatusDisabled    = "disabled"
+	SystemStatusDraining    = "draining" // shutting down, finishing the requests in flight
+)
+
+type IncidentSeverity string
+
+const (
+	IncidentSeverityMinor    IncidentSeverity = "MINOR"
+	IncidentSeverityMajor    IncidentSeverity = "MAJOR"
+	IncidentSeverityCritical IncidentSeverity = "CRITICAL"
+)
+
+type IncidentStatus string
+
+const (
+	IncidentStatusInvestigating IncidentStatus = "INVESTIGATING"
+	IncidentStatusIdentified    IncidentStatus = "IDENTIFIED"
+	IncidentStatusMonitoring    IncidentStatus = "MONITORING"
+	IncidentStatusResolved      IncidentStatus = "RESOLVED"
+)
+
+// StatusIncident is an incident note published on the status page by an admin
+type StatusIncident struct {
+	ID         string           `json:"id" gorm:"type:uuid;primaryKey"`
+	Title      string           `json:"title" gorm:"type:varchar(200);not null"`
+	Message    string           `json:"message" gorm:"type:text"`
+	Severity   IncidentSeverity `json:"severity" gorm:"type:varchar(20);not null;default:MINOR"`
+	Status     IncidentStatus   `json:"status" gorm:"type:varchar(20);not null;default:INVESTIGATING;index"`
+	StartedAt  time.Time        `json:"startedAt" gorm:"not null;index"`
+	ResolvedAt *time.Time       `json:"resolvedAt,omitempty"`
+	CreatedBy  string           `json:"-" gorm:"type:varchar(36)"`
+	CreatedAt  time.Time        `json:"createdAt"`
+	UpdatedAt  time.Time        `json:"updatedAt"`
+}
+
+func (i *StatusIncident) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == "" {
+		i.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (StatusIncident) TableName() string {
+	return "status_incidents"
+}
+
+// =============== DTOs ===============
+
+// CreateStatusIncidentRequest DTO
+type CreateStatusIncidentRequest struct {
+	Title     string     `json:"title" validate:"required,max=200"`
+	Message   string     `json:"message" validate:"max=5000"`
+	Severity  string     `json:"severity" validate:"omitempty,oneof=MINOR MAJOR CRITICAL"`
+	Status    string     `json:"status" validate:"omitempty,oneof=INVESTIGATING IDENTIFIED MONITORING RESOLVED"`
+	StartedAt *time.Time `json:"startedAt"`
+}
+
+// UpdateStatusIncidentRequest DTO
+type UpdateStatusIncidentRequest struct {
+	Title    *string `json:"title" validate:"omitempty,max=200"`
+	Message  *string `json:"message" validate:"omitempty,max=5000"`
+	Severity *string `json:"severity" validate:"omitempty,oneof=MINOR MAJOR CRITICAL"`
+	Status   *string `json:"status" validate:"omitempty,oneof=INVESTIGATING IDENTIFIED MONITORING RESOLVED"`
+}
+
+// ComponentStatus is the health of a single dependency
+type ComponentStatus struct {
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latencyMs,omitempty"`
+}
+
+// JobBacklog summarizes the queue of a background job
+type JobBacklog struct {
+	Name    string `json:"name"`
+	Pending int64  `json:"pending"`
+	Failed  int64  `json:"failed"`
+	Status  string `json:"status"`
+}
+
+// SystemStatus is the public status page payload
+type SystemStatus struct {
+	Status     string            `json:"status"`
+	Uptime     int64             `json:"uptime"`
+	Version    string            `json:"version"`
+	Components []ComponentStatus `json:"components"`
+	Jobs       []JobBacklog      `json:"jobs"`
+	Incidents  []StatusIncident  `json:"incidents"`
+	UpdatedAt  time.Time         `json:"updatedAt"`
+}
+
+// Readiness is the answer of the readiness probe: whether the instance should get traffic
+type Readiness struct {
+	Ready      bool              `json:"ready"`
+	Status     string            `json:"status"`
+	Components []ComponentStatus `json:"components"`
+}
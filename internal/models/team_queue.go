@@ -0,0 +1,155 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DistributionPolicy decides which team queue receives an incoming ticket
+type DistributionPolicy string
+
+const (
+	DistributionRoundRobin  DistributionPolicy = "ROUND_ROBIN"  // each queue in turn
+	DistributionWeighted    DistributionPolicy = "WEIGHTED"     // in turn, proportionally to headcount
+	DistributionLeastLoaded DistributionPolicy = "LEAST_LOADED" // queue with the smallest open backlog per head
+)
+
+func (p DistributionPolicy) IsValid() bool {
+	return p == DistributionRoundRobin || p == DistributionWeighted || p == DistributionLeastLoaded
+}
+
+// QueueAssignmentMethod records how a ticket reached its queue
+type QueueAssignmentMethod string
+
+const (
+	QueueAssignmentAuto      QueueAssignmentMethod = "AUTO"
+	QueueAssignmentManual    QueueAssignmentMethod = "MANUAL"
+	QueueAssignmentRebalance QueueAssignmentMethod = "REBALANCE"
+)
+
+// TicketEventQueueAssigned is written on the ticket timeline whenever it enters a queue
+const TicketEventQueueAssigned = "queue.assigned"
+
+// TeamQueue is the intake queue of a team, mapped to a hierarchy node. Tickets of the
+// node (or of its descendants) are distributed among the queues closest to it.
+type TeamQueue struct {
+	ID            string    `json:"id" gorm:"type:uuid;primaryKey"`
+	Name          string    `json:"name" gorm:"type:varchar(100);not null"`
+	NodeID        uint      `json:"nodeId" gorm:"not null;index"`
+	Weight        *int      `json:"weight"` // overrides the headcount for weighted distribution
+	Active        bool      `json:"active" gorm:"not null;default:true"`
+	AssignedCount int64     `json:"assignedCount" gorm:"not null;default:0"` // tickets distributed so far
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+
+	Node *Node `json:"node,omitempty" gorm:"foreignKey:NodeID"`
+}
+
+func (q *TeamQueue) BeforeCreate(tx *gorm.DB) error {
+	if q.ID == "" {
+		q.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (TeamQueue) TableName() string {
+	return "team_queues"
+}
+
+// TicketQueueAssignment places a ticket in a team queue
+type TicketQueueAssignment struct {
+	TicketID   string                `json:"ticketId" gorm:"type:uuid;primaryKey"`
+	QueueID    string                `json:"queueId" gorm:"type:uuid;not null;index"`
+	Method     QueueAssignmentMethod `json:"method" gorm:"type:varchar(20);not null"`
+	Policy     DistributionPolicy    `json:"policy" gorm:"type:varchar(20)"`     // empty for manual moves
+	AssignedBy string                `json:"assignedBy" gorm:"type:varchar(36)"` // empty for the distributor
+	AssignedAt time.Time             `json:"assignedAt" gorm:"not null;index"`
+
+	Ticket *Ticket    `json:"ticket,omitempty" gorm:"foreignKey:TicketID"`
+	Queue  *TeamQueue `json:"queue,omitempty" gorm:"foreignKey:QueueID"`
+}
+
+func (TicketQueueAssignment) TableName() string {
+	return "ticket_queue_assignments"
+}
+
+// TeamQueueSettings holds the distribution policy; a single row
+type TeamQueueSettings struct {
+	ID        uint               `json:"id" gorm:"primaryKey"`
+	Policy    DistributionPolicy `json:"policy" gorm:"type:varchar(20);not null;default:ROUND_ROBIN"`
+	UpdatedBy string             `json:"updatedBy" gorm:"type:varchar(36)"`
+	CreatedAt time.Time          `json:"createdAt"`
+	UpdatedAt time.Time          `json:"updatedAt"`
+}
+
+func (TeamQueueSettings) TableName() string {
+	return "team_queue_settings"
+}
+
+// DefaultTeamQueueSettings returns the settings used when nothing is configured
+func DefaultTeamQueueSettings() *TeamQueueSettings {
+	return &TeamQueueSettings{Policy: DistributionRoundRobin}
+}
+
+// =============== DTOs ===============
+
+// TeamQueueRequest DTO
+type TeamQueueRequest struct {
+	Name   string `json:"name" validate:"required,max=100"`
+	NodeID uint   `json:"nodeId" validate:"required"`
+	Weight *int   `json:"weight" validate:"omitempty,min=0"`
+	Active *bool  `json:"active"`
+}
+
+// UpdateTeamQueueSettingsRequest DTO
+type UpdateTeamQueueSettingsRequest struct {
+	Policy string `json:"policy" validate:"required,oneof=ROUND_ROBIN WEIGHTED LEAST_LOADED"`
+}
+
+// MoveTicketQueueRequest DTO
+type MoveTicketQueueRequest struct {
+	QueueID string `json:"queueId" validate:"required"`
+}
+
+// RebalanceRequest moves open, unowned tickets out of a queue: to ToQueueID when given,
+// otherwise spread over the other eligible queues by the current policy
+type RebalanceRequest struct {
+	FromQueueID string `json:"fromQueueId" validate:"required"`
+	ToQueueID   string `json:"toQueueId"`
+	Limit       int    `json:"limit" validate:"omitempty,min=1,max=500"`
+}
+
+// TeamQueueLoad is a queue with its headcount and open backlog
+type TeamQueueLoad struct {
+	TeamQueue
+	NodePath  string `json:"-"`
+	Headcount int64  `json:"headcount"`
+	Backlog   int64  `json:"backlog"`
+}
+
+// TeamQueueMetrics is the backlog of a team queue
+type TeamQueueMetrics struct {
+	QueueID         string                   `json:"queueId"`
+	Name            string                   `json:"name"`
+	NodeID          uint                     `json:"nodeId"`
+	Active          bool                     `json:"active"`
+	Headcount       int64                    `json:"headcount"`
+	Backlog         int64                    `json:"backlog"` // open tickets in the queue
+	Unowned         int64                    `json:"unowned"` // open tickets without technician
+	BacklogPerHead  float64                  `json:"backlogPerHead"`
+	ByPriority      map[TicketPriority]int64 `json:"byPriority"`
+	OldestOpenAt    *time.Time               `json:"oldestOpenAt"`
+	OldestAgeHours  float64                  `json:"oldestAgeHours"`
+	AssignedLast24h int64                    `json:"assignedLast24h"`
+}
+
+// DistributionResult summarizes a distribution or rebalancing run
+type DistributionResult struct {
+	Policy   DistributionPolicy `json:"policy"`
+	Assigned int                `json:"assigned"`
+	ByQueue  map[string]int     `json:"byQueue"` // tickets per queue ID
+	Skipped  int                `json:"skipped"` // no eligible queue
+	Errors   []string           `json:"errors,omitempty"`
+}
@@ -172,6 +172,7 @@ func (s *SkillsMap) Scan(value interface{}) error {
 
 type Technician struct {
 	ID        string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	TenantID  string    `json:"tenantId" gorm:"type:varchar(36);not null;default:'00000000-0000-0000-0000-000000000001';index"`
 	FullName  string    `json:"fullName" gorm:"not null;type:varchar(255)"`
 	TradeName string    `json:"tradeName" gorm:"type:varchar(255)"`
 	CPF       string    `json:"cpf" gorm:"type:varchar(14);index"`
@@ -185,6 +186,9 @@ type Technician struct {
 	UserID *string `json:"userId" gorm:"type:varchar(36);index"`
 	User   *User   `json:"user,omitempty" gorm:"foreignKey:UserID"`
 
+	// Hierarchy node the technician works for (nil = visible in every scope)
+	NodeID *uint `json:"nodeId" gorm:"index"`
+
 	// Contact info (JSONB arrays)
 	Emails EmailArray `json:"emails" gorm:"type:jsonb;default:'[]'"`
 	Phones PhoneArray `json:"phones" gorm:"type:jsonb;default:'[]'"`
@@ -223,6 +227,7 @@ type Technician struct {
 
 	// Control
 	InAttendance bool      `json:"inAttendance" gorm:"default:false"`
+	Version      int       `json:"version" gorm:"not null;default:1"` // optimistic locking, sent back on edits
 	CreatedAt    time.Time `json:"createdAt"`
 	UpdatedAt    time.Time `json:"updatedAt"`
 
@@ -258,6 +263,7 @@ type TechnicianDTO struct {
 	Emails       []EmailEntry `json:"emails"`
 	Phones       []PhoneEntry `json:"phones"`
 	InAttendance bool         `json:"inAttendance"`
+	NodeID       *uint        `json:"nodeId"`
 	CreatedAt    time.Time    `json:"createdAt"`
 }
 
@@ -273,6 +279,7 @@ func (t *Technician) ToDTO() TechnicianDTO {
 		Emails:       t.Emails,
 		Phones:       t.Phones,
 		InAttendance: t.InAttendance,
+		NodeID:       t.NodeID,
 		CreatedAt:    t.CreatedAt,
 	}
 }
@@ -310,6 +317,8 @@ type CreateTechnicianRequest struct {
 	KnowledgeDescription string            `json:"knowledgeDescription"`
 	EquipmentDescription string            `json:"equipmentDescription"`
 	Vehicle              string            `json:"vehicle"`
+	NodeID               *uint             `json:"nodeId"`
+	Version              int               `json:"version"` // updates only: version the edit is based on, 0 skips the check
 }
 
 func (r *CreateTechnicianRequest) ToModel() *Technician {
@@ -358,5 +367,6 @@ func (r *CreateTechnicianRequest) ToModel() *Technician {
 		KnowledgeDescription: r.KnowledgeDescription,
 		EquipmentDescription: r.EquipmentDescription,
 		Vehicle:              vehicle,
+		NodeID:               r.NodeID,
 	}
 }
@@ -0,0 +1,70 @@
+package models
+
+import "time"
+
+// TechnicianHome is everything the mobile app home screen shows, assembled in one call
+type TechnicianHome struct {
+	TechnicianID   string                 `json:"technicianId"`
+	TechnicianName string                 `json:"technicianName"`
+	Date           string                 `json:"date"` // YYYY-MM-DD
+	Tickets        []TechnicianHomeTicket `json:"tickets"`
+	Pending        TechnicianHomePending  `json:"pending"`
+	Stock          []TechnicianHomeStock  `json:"stock"`
+	Shift          TechnicianShiftStatus  `json:"shift"`
+}
+
+// TechnicianHomeTicket is a ticket of the day with what is needed to get to the site
+type TechnicianHomeTicket struct {
+	ID               string         `json:"id"`
+	OSNumber         string         `json:"osNumber"`
+	Status           TicketStatus   `json:"status"`
+	Priority         TicketPriority `json:"priority"`
+	ErrorDescription string         `json:"errorDescription"`
+	Category         string         `json:"category,omitempty"`
+	ScheduledStart   *time.Time     `json:"scheduledStart"`
+	ScheduledEnd     *time.Time     `json:"scheduledEnd"`
+	Client           *HomeClient    `json:"client,omitempty"`
+}
+
+// HomeClient is the client and site address of a ticket
+type HomeClient struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	Phone        string `json:"phone"`
+	Street       string `json:"street"`
+	Number       string `json:"number"`
+	Complement   string `json:"complement"`
+	Neighborhood string `json:"neighborhood"`
+	City         string `json:"city"`
+	State        string `json:"state"`
+	ZipCode      string `json:"zipCode"`
+}
+
+// TechnicianHomePending counts what waits on the technician
+type TechnicianHomePending struct {
+	OnCallPages      int   `json:"onCallPages"`      // priority dispatches to acknowledge
+	IncomingTransfer int64 `json:"incomingTransfer"` // stock transfers to the technician's locations awaiting approval
+	CountTasks       int64 `json:"countTasks"`       // cycle counts due on the technician's locations
+}
+
+// TechnicianHomeStock is the balance of an item at a location carried by the technician
+type TechnicianHomeStock struct {
+	LocationID   string `json:"locationId"`
+	LocationName string `json:"locationName"`
+	ItemID       string `json:"itemId"`
+	ItemSKU      string `json:"itemSku"`
+	ItemName     string `json:"itemName"`
+	ItemUnit     string `json:"itemUnit"`
+	Quantity     int    `json:"quantity"`
+}
+
+// TechnicianShiftStatus tells whether the technician is checked in and on call
+type TechnicianShiftStatus struct {
+	CheckedIn    bool       `json:"checkedIn"`
+	LastEvent    EventType  `json:"lastEvent,omitempty"`
+	LastSeenAt   *time.Time `json:"lastSeenAt"`
+	OnCall       bool       `json:"onCall"` // primary of an on-call rotation right now
+	OnCallLevel  *int       `json:"onCallLevel"`
+	ScheduleName string     `json:"scheduleName,omitempty"`
+	ShiftEndsAt  *time.Time `json:"shiftEndsAt"`
+}
@@ -54,13 +54,24 @@ func (TechnicianLocation) TableName() string {
 	return "technician_locations"
 }
 
+// Provider dos pontos gerados pelo backfill a partir dos endereços dos tickets
+const LocationProviderBackfill = "backfill"
+
+// Precisão das coordenadas geocodificadas a partir do endereço do cliente
+const (
+	GeoPrecisionAddress = "ADDRESS" // endereço completo, pelo provedor de geocodificação
+	GeoPrecisionCity    = "CITY"    // coordenadas da cidade
+	GeoPrecisionState   = "STATE"   // coordenadas da capital do estado
+	GeoPrecisionNone    = "NONE"    // endereço não encontrado
+)
+
 // TechnicianLastLocation representa a última localização conhecida do técnico (cache)
 type TechnicianLastLocation struct {
 	TechnicianID string `json:"technicianId" gorm:"type:varchar(36);primary_key"`
 
 	// Última localização
-	Latitude   float64  `json:"latitude" gorm:"type:double precision;not null"`
-	Longitude  float64  `json:"longitude" gorm:"type:double precision;not null"`
+	Latitude   float64  `json:"latitude" gorm:"type:double precision;not null;index:idx_tech_last_loc_lat_lng"`
+	Longitude  float64  `json:"longitude" gorm:"type:double precision;not null;index:idx_tech_last_loc_lat_lng"`
 	AccuracyM  *float64 `json:"accuracyM,omitempty" gorm:"type:double precision"`
 
 	// Contexto
@@ -152,6 +163,8 @@ type TechnicianLocationResponse struct {
 	CurrentTicketID     *uuid.UUID `json:"currentTicketId,omitempty"`
 	CurrentTicketNumber *string    `json:"currentTicketNumber,omitempty"`
 	Location            *LocationInfo `json:"location,omitempty"`
+	// DistanceKm é a distância ao centro das buscas por proximidade
+	DistanceKm *float64 `json:"distanceKm,omitempty"`
 }
 
 type LocationInfo struct {
@@ -169,6 +182,9 @@ type TechnicianHistoryResponse struct {
 	Period         PeriodInfo          `json:"period"`
 	Summary        HistorySummary      `json:"summary"`
 	Locations      []LocationHistoryItem `json:"locations"`
+	// Cursores das páginas seguinte e anterior (paginação por cursor)
+	NextCursor string `json:"nextCursor,omitempty"`
+	PrevCursor string `json:"prevCursor,omitempty"`
 }
 
 type PeriodInfo struct {
@@ -257,3 +273,50 @@ type UpdateGeoSettingsRequest struct {
 	HeartbeatEnabled      *bool      `json:"heartbeatEnabled"`
 	RequireLocationCheckin *bool      `json:"requireLocationCheckin"`
 }
+
+// LocationStreamEvent é enviado aos dashboards inscritos quando um técnico registra
+// uma nova localização
+type LocationStreamEvent struct {
+	LocationID    uuid.UUID  `json:"locationId"`
+	TechnicianID  string     `json:"technicianId"`
+	UserID        string     `json:"userId"`
+	Name          string     `json:"name"`
+	Status        string     `json:"status"`
+	TicketID      *uuid.UUID `json:"ticketId,omitempty"`
+	EventType     EventType  `json:"eventType"`
+	Latitude      float64    `json:"latitude"`
+	Longitude     float64    `json:"longitude"`
+	AccuracyM     *float64   `json:"accuracyM,omitempty"`
+	SpeedMps      *float64   `json:"speedMps,omitempty"`
+	HeadingDeg    *float64   `json:"headingDeg,omitempty"`
+	DeviceTime    *time.Time `json:"deviceTime,omitempty"`
+	ServerTime    time.Time  `json:"serverTime"`
+	IsMocked      bool       `json:"isMocked"`
+	IsOfflineSync bool       `json:"isOfflineSync"`
+}
+
+// GeoBackfillRequest DTO
+type GeoBackfillRequest struct {
+	Limit  int  `json:"limit"`  // clientes e visitas por execução
+	DryRun bool `json:"dryRun"` // apenas conta, sem gravar
+}
+
+// GeoBackfillVisit é a visita de um técnico a um ticket antigo, no endereço do cliente
+type GeoBackfillVisit struct {
+	TicketID     string
+	TechnicianID string
+	VisitedAt    time.Time
+	Latitude     float64
+	Longitude    float64
+	GeoPrecision string
+}
+
+// GeoBackfillResult resume uma execução do backfill
+type GeoBackfillResult struct {
+	Since            time.Time `json:"since"` // visitas anteriores seriam removidas pela retenção
+	DryRun           bool      `json:"dryRun"`
+	ClientsGeocoded  int       `json:"clientsGeocoded"`
+	ClientsNotFound  int       `json:"clientsNotFound"`
+	LocationsCreated int       `json:"locationsCreated"`
+	Remaining        bool      `json:"remaining"` // há mais a processar; execute novamente
+}
@@ -0,0 +1,133 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Technician time-off types
+const (
+	TimeOffDayOff   = "DAY_OFF"
+	TimeOffVacation = "VACATION"
+)
+
+// Why a technician is not available at a moment
+const (
+	AvailabilityOutsideHours = "OUTSIDE_HOURS" // not a working period of the weekday
+	AvailabilityTimeOff      = "TIME_OFF"      // day off or vacation
+	AvailabilityInactive     = "INACTIVE"      // technician status other than ATIVO
+)
+
+// TechnicianSchedule is the working week of a technician, in the local time of Timezone.
+// Technicians without a schedule are treated as always available.
+type TechnicianSchedule struct {
+	TechnicianID string    `json:"technicianId" gorm:"type:varchar(36);primaryKey"`
+	Timezone     string    `json:"timezone" gorm:"type:varchar(50);not null;default:America/Sao_Paulo"`
+	UpdatedBy    string    `json:"updatedBy" gorm:"type:varchar(36)"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+
+	WorkingHours []TechnicianWorkingHours `json:"workingHours" gorm:"foreignKey:TechnicianID;references:TechnicianID"`
+	Technician   *Technician              `json:"technician,omitempty" gorm:"foreignKey:TechnicianID"`
+}
+
+func (TechnicianSchedule) TableName() string {
+	return "technician_schedules"
+}
+
+// TechnicianWorkingHours is a working period of one weekday; a weekday may have several
+// (e.g. morning and afternoon). Times are "HH:MM", End after Start on the same day.
+type TechnicianWorkingHours struct {
+	ID           uint   `json:"id" gorm:"primaryKey;autoIncrement"`
+	TechnicianID string `json:"technicianId" gorm:"type:varchar(36);not null;index"`
+	Weekday      int    `json:"weekday" gorm:"not null"` // 0 = Sunday
+	Start        string `json:"start" gorm:"column:start_time;type:varchar(5);not null"`
+	End          string `json:"end" gorm:"column:end_time;type:varchar(5);not null"`
+}
+
+func (TechnicianWorkingHours) TableName() string {
+	return "technician_working_hours"
+}
+
+// TechnicianTimeOff is a day off or a vacation: whole days from StartDate to EndDate,
+// inclusive, in the timezone of the technician schedule
+type TechnicianTimeOff struct {
+	ID           string    `json:"id" gorm:"type:uuid;primaryKey"`
+	TechnicianID string    `json:"technicianId" gorm:"type:varchar(36);not null;index:idx_time_off_range,priority:1"`
+	Type         string    `json:"type" gorm:"type:varchar(20);not null"`
+	StartDate    time.Time `json:"startDate" gorm:"type:date;not null;index:idx_time_off_range,priority:2"`
+	EndDate      time.Time `json:"endDate" gorm:"type:date;not null"`
+	Reason       string    `json:"reason" gorm:"type:varchar(500)"`
+	CreatedBy    string    `json:"createdBy" gorm:"type:varchar(36)"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+func (t *TechnicianTimeOff) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == "" {
+		t.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (TechnicianTimeOff) TableName() string {
+	return "technician_time_off"
+}
+
+// =============== DTOs ===============
+
+// TechnicianScheduleRequest replaces the working week of a technician
+type TechnicianScheduleRequest struct {
+	Timezone     string              `json:"timezone"`
+	WorkingHours []WorkingHoursInput `json:"workingHours" validate:"required,min=1,dive"`
+}
+
+// WorkingHoursInput is one working period, "HH:MM" local time
+type WorkingHoursInput struct {
+	Weekday int    `json:"weekday" validate:"min=0,max=6"`
+	Start   string `json:"start" validate:"required"`
+	End     string `json:"end" validate:"required"`
+}
+
+// TechnicianTimeOffRequest DTO; EndDate defaults to StartDate
+type TechnicianTimeOffRequest struct {
+	Type      string `json:"type" validate:"required,oneof=DAY_OFF VACATION"`
+	StartDate string `json:"startDate" validate:"required"` // YYYY-MM-DD
+	EndDate   string `json:"endDate"`                       // YYYY-MM-DD, inclusive
+	Reason    string `json:"reason" validate:"max=500"`
+}
+
+// AvailabilityConflict tells why a technician is not available for a period
+type AvailabilityConflict struct {
+	TechnicianID   string `json:"technicianId"`
+	TechnicianName string `json:"technicianName"`
+	Reason         string `json:"reason"` // OUTSIDE_HOURS, TIME_OFF, INACTIVE
+	Detail         string `json:"detail,omitempty"`
+}
+
+// TechnicianAvailability is the availability of a technician over a period
+type TechnicianAvailability struct {
+	TechnicianID string                 `json:"technicianId"`
+	Start        time.Time              `json:"start"`
+	End          time.Time              `json:"end"`
+	Available    bool                   `json:"available"`
+	HasSchedule  bool                   `json:"hasSchedule"`
+	Conflicts    []AvailabilityConflict `json:"conflicts"`
+}
+
+// OnDutyTechnician is a technician within working hours right now
+type OnDutyTechnician struct {
+	TechnicianID   string    `json:"technicianId"`
+	TechnicianName string    `json:"technicianName"`
+	City           string    `json:"city"`
+	State          string    `json:"state"`
+	ShiftEndsAt    time.Time `json:"shiftEndsAt"`
+}
+
+// OnDutySummary lists the technicians on duty at a moment
+type OnDutySummary struct {
+	At          time.Time          `json:"at"`
+	Total       int                `json:"total"`
+	Technicians []OnDutyTechnician `json:"technicians"`
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Tenant is an organization using the platform. Its slug is the subdomain it is served
+// from; users, clients, technicians, categories and tickets belong to one tenant.
+type Tenant struct {
+	ID        string    `json:"id" gorm:"type:varchar(36);primaryKey"`
+	Name      string    `json:"name" gorm:"type:varchar(150);not null"`
+	Slug      string    `json:"slug" gorm:"type:varchar(63);uniqueIndex;not null"`
+	Active    bool      `json:"active" gorm:"default:true"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func (t *Tenant) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == "" {
+		t.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (Tenant) TableName() string {
+	return "tenants"
+}
+
+// =============== DTOs ===============
+
+// CreateTenantRequest DTO; the slug must be a valid DNS label
+type CreateTenantRequest struct {
+	Name string `json:"name" validate:"required,max=150"`
+	Slug string `json:"slug" validate:"required,min=2,max=63,hostname_rfc1123,excludesall=."`
+}
+
+// UpdateTenantRequest DTO
+type UpdateTenantRequest struct {
+	Name   string `json:"name" validate:"required,max=150"`
+	Active *bool  `json:"active"`
+}
+
+// CreateTenantAdminRequest DTO creates the first admin of a tenant
+type CreateTenantAdminRequest struct {
+	Email     string `json:"email" validate:"required,email"`
+	Password  string `json:"password" validate:"required,min=6"`
+	FirstName string `json:"firstName" validate:"required,min=2"`
+	LastName  string `json:"lastName" validate:"required,min=2"`
+}
@@ -1,12 +1,14 @@
 package models
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/shigake/tech-iq-back/internal/pagination"
 	"gorm.io/gorm"
 )
 
@@ -18,6 +20,10 @@ const (
 	TicketStatusForClosing TicketStatus = "PARA_FECHAMENTO"
 	TicketStatusClosed     TicketStatus = "FECHADO"
 	TicketStatusUnproductive TicketStatus = "IMPRODUTIVO"
+	TicketStatusCancelled    TicketStatus = "CANCELADO"
+	// Waiting states pause the SLA clock
+	TicketStatusWaitingClient TicketStatus = "AGUARDANDO_CLIENTE"
+	TicketStatusWaitingParts  TicketStatus = "AGUARDANDO_PECAS"
 )
 
 type TicketPriority string
@@ -29,11 +35,39 @@ const (
 	TicketPriorityUrgent TicketPriority = "URGENTE"
 )
 
+// TicketSource is the channel the ticket was opened through
+type TicketSource string
+
+const (
+	TicketSourcePortal     TicketSource = "PORTAL"
+	TicketSourcePhone      TicketSource = "PHONE"
+	TicketSourceEmail      TicketSource = "EMAIL" // e-mail gateway
+	TicketSourcePartnerAPI TicketSource = "PARTNER_API"
+	TicketSourceWhatsApp   TicketSource = "WHATSAPP"
+)
+
+// TicketSources lists the channels in display order
+var TicketSources = []TicketSource{
+	TicketSourcePortal, TicketSourcePhone, TicketSourceEmail, TicketSourcePartnerAPI, TicketSourceWhatsApp,
+}
+
+func (s TicketSource) IsValid() bool {
+	for _, source := range TicketSources {
+		if s == source {
+			return true
+		}
+	}
+	return false
+}
+
 type Ticket struct {
 	ID               string         `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	TenantID         string         `json:"tenantId" gorm:"type:varchar(36);not null;default:'00000000-0000-0000-0000-000000000001';index"`
 	OSNumber         string         `json:"osNumber" gorm:"type:varchar(50);uniqueIndex"`
 	Status           TicketStatus   `json:"status" gorm:"type:varchar(50);default:ABERTO;index"`
+	Type             TicketType     `json:"type" gorm:"type:varchar(20);default:SERVICO;index"`
 	Priority         TicketPriority `json:"priority" gorm:"type:varchar(20);default:NORMAL"`
+	Source           TicketSource   `json:"source" gorm:"type:varchar(20);default:PORTAL;index"`
 	ErrorDescription string         `json:"errorDescription" gorm:"type:text"`
 	CustomerFeedback string         `json:"customerFeedback" gorm:"type:text"`
 
@@ -52,6 +86,9 @@ type Ticket struct {
 	// Technicians (many-to-many) - uses string ID like Java
 	Technicians []Technician `json:"technicians,omitempty" gorm:"many2many:ticket_technicians;joinForeignKey:ticket_id;joinReferences:technician_id"`
 
+	// Assignments carry the role/payout share of each technician (join table rows)
+	Assignments []TicketTechnician `json:"assignments,omitempty" gorm:"foreignKey:TicketID"`
+
 	// Files
 	Files []TicketFile `json:"files,omitempty" gorm:"foreignKey:TicketID"`
 
@@ -70,6 +107,29 @@ type Ticket struct {
 	StartDate *time.Time     `json:"startDate"`
 	DueDate   *time.Time     `json:"dueDate"`
 	ClosedAt  *time.Time     `json:"closedAt" gorm:"column:closed_at"`
+
+	// Appointment window agreed with the client
+	ScheduledStart *time.Time `json:"scheduledStart" gorm:"column:scheduled_start;index"`
+	ScheduledEnd   *time.Time `json:"scheduledEnd" gorm:"column:scheduled_end"`
+
+	// Recall/preventive campaign that generated the ticket
+	CampaignID *string `json:"campaignId" gorm:"type:uuid;index"`
+
+	// Auto-dispatch state (empty when no dispatch rule matched)
+	DispatchStatus DispatchStatus `json:"dispatchStatus" gorm:"type:varchar(20);index"`
+
+	// Ombudsman data, only for RECLAMACAO tickets
+	Complaint *TicketComplaint `json:"complaint,omitempty" gorm:"foreignKey:TicketID"`
+
+	// Why the ticket was cancelled, only for CANCELADO tickets
+	Cancellation *TicketCancellation `json:"cancellation,omitempty" gorm:"foreignKey:TicketID"`
+
+	// Set on creation when the client site is outside every coverage area (not persisted)
+	CoverageWarning string `json:"coverageWarning,omitempty" gorm:"-"`
+
+	// Optimistic locking: bumped by every update, sent back on edits
+	Version int `json:"version" gorm:"not null;default:1"`
+
 	CreatedAt time.Time      `json:"createdAt"`
 	UpdatedAt time.Time      `json:"updatedAt"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
@@ -82,10 +142,11 @@ func (t *Ticket) BeforeCreate(tx *gorm.DB) error {
 	}
 	// Generate OS number if not set
 	if t.OSNumber == "" {
-		// Get the highest sequence number for the current year
+		// Get the highest sequence number for the current year. OS numbers are unique
+		// across tenants, so the lookup runs outside the tenant of the statement.
 		year := time.Now().Year()
 		var maxOS string
-		tx.Model(&Ticket{}).
+		tx.Session(&gorm.Session{NewDB: true, Context: context.Background()}).Model(&Ticket{}).
 			Where("os_number LIKE ?", fmt.Sprintf("%d-%%", year)).
 			Order("os_number DESC").
 			Limit(1).
@@ -112,6 +173,45 @@ func generateOSNumber(seq int) string {
 	return fmt.Sprintf("%d-%06d", year, seq)
 }
 
+type AssignmentRole string
+
+const (
+	AssignmentRoleLead      AssignmentRole = "LEAD"
+	AssignmentRoleAssistant AssignmentRole = "ASSISTANT"
+)
+
+func (r AssignmentRole) IsValid() bool {
+	return r == AssignmentRoleLead || r == AssignmentRoleAssistant
+}
+
+// TicketTechnician is the ticket_technicians join table, extended with the
+// crew role, payout share and per-assignee check-in/out
+type TicketTechnician struct {
+	TicketID     string         `json:"ticketId" gorm:"type:uuid;primaryKey"`
+	TechnicianID string         `json:"technicianId" gorm:"type:varchar(36);primaryKey"`
+	Role         AssignmentRole `json:"role" gorm:"type:varchar(20);not null;default:LEAD"`
+	PayoutShare  *float64       `json:"payoutShare" gorm:"type:decimal(5,2)"` // percentage, nil = split equally
+	CheckedInAt  *time.Time     `json:"checkedInAt"`
+	CheckedOutAt *time.Time     `json:"checkedOutAt"`
+	CreatedAt    time.Time      `json:"createdAt"`
+
+	Technician *Technician `json:"technician,omitempty" gorm:"foreignKey:TechnicianID"`
+}
+
+func (TicketTechnician) TableName() string {
+	return "ticket_technicians"
+}
+
+type AttachmentStatus string
+
+const (
+	AttachmentStatusPending    AttachmentStatus = "PENDING"
+	AttachmentStatusProcessing AttachmentStatus = "PROCESSING"
+	AttachmentStatusReady      AttachmentStatus = "READY"
+	AttachmentStatusFailed     AttachmentStatus = "FAILED"
+	AttachmentStatusInfected   AttachmentStatus = "INFECTED"
+)
+
 type TicketFile struct {
 	ID        string    `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
 	TicketID  string    `json:"ticketId" gorm:"type:uuid"`
@@ -120,6 +220,42 @@ type TicketFile struct {
 	FileType  string    `json:"fileType" gorm:"type:varchar(100)"`
 	FileSize  int64     `json:"fileSize"`
 	CreatedAt time.Time `json:"createdAt"`
+
+	// Bytes on disk including processed variants (storage accounting)
+	StoredBytes int64 `json:"storedBytes" gorm:"default:0"`
+
+	// Processing pipeline (AV scan, thumbnail, EXIF stripping, WebP)
+	ProcessingStatus AttachmentStatus `json:"processingStatus" gorm:"type:varchar(20);default:PENDING;index"`
+	ProcessingError  string           `json:"processingError,omitempty" gorm:"type:text"`
+	Attempts         int              `json:"attempts" gorm:"default:0"`
+	NextAttemptAt    *time.Time       `json:"-"`
+	ScanResult       string           `json:"scanResult,omitempty" gorm:"type:varchar(255)"`
+	ThumbnailPath    string           `json:"-" gorm:"type:varchar(500)"`
+	SanitizedPath    string           `json:"-" gorm:"type:varchar(500)"`
+	WebPPath         string           `json:"-" gorm:"column:webp_path;type:varchar(500)"`
+	ProcessedAt      *time.Time       `json:"processedAt"`
+
+	UploadedBy string `json:"uploadedBy,omitempty" gorm:"type:varchar(36)"`
+}
+
+func (f *TicketFile) BeforeCreate(tx *gorm.DB) error {
+	if f.ID == "" {
+		f.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// HasVariant reports whether a processed variant (thumbnail, sanitized, webp) is available
+func (f *TicketFile) HasVariant(variant string) bool {
+	switch variant {
+	case "thumbnail":
+		return f.ThumbnailPath != ""
+	case "sanitized":
+		return f.SanitizedPath != ""
+	case "webp":
+		return f.WebPPath != ""
+	}
+	return false
 }
 
 // DTOs
@@ -128,7 +264,9 @@ type TicketDTO struct {
 	ID                  string     `json:"id"`
 	OSNumber            string     `json:"osNumber"`
 	Status              string     `json:"status"`
+	Type                string     `json:"type"`
 	Priority            string     `json:"priority"`
+	Source              string     `json:"source"`
 	ErrorDescription    string     `json:"errorDescription"`
 	CustomerFeedback    string     `json:"customerFeedback"`
 	NodeID              *uint      `json:"nodeId"`
@@ -136,6 +274,8 @@ type TicketDTO struct {
 	ClientName          string     `json:"clientName"`
 	CategoryName        string     `json:"categoryName"`
 	TechnicianCount     int        `json:"technicianCount"`
+	LeadTechnicianID    string     `json:"leadTechnicianId,omitempty"`
+	LeadTechnicianName  string     `json:"leadTechnicianName,omitempty"`
 	ComputerBrand       string     `json:"computerBrand"`
 	ComputerModel       string     `json:"computerModel"`
 	SerialNumber        string     `json:"serialNumber"`
@@ -147,6 +287,11 @@ type TicketDTO struct {
 	StartDate           *time.Time `json:"startDate"`
 	DueDate             *time.Time `json:"dueDate"`
 	ClosedAt            *time.Time `json:"closedAt"`
+	ScheduledStart      *time.Time `json:"scheduledStart"`
+	ScheduledEnd        *time.Time `json:"scheduledEnd"`
+	DispatchStatus      string     `json:"dispatchStatus,omitempty"`
+	CampaignID          *string    `json:"campaignId,omitempty"`
+	Version             int        `json:"version"`
 	CreatedAt           time.Time  `json:"createdAt"`
 }
 
@@ -163,12 +308,24 @@ func (t *Ticket) ToDTO() TicketDTO {
 	if t.Node != nil && t.Node.Name != "" {
 		nodeName = t.Node.Name
 	}
+	leadID, leadName := "", ""
+	for _, a := range t.Assignments {
+		if a.Role == AssignmentRoleLead {
+			leadID = a.TechnicianID
+			if a.Technician != nil {
+				leadName = a.Technician.FullName
+			}
+			break
+		}
+	}
 
 	return TicketDTO{
 		ID:                  t.ID,
 		OSNumber:            t.OSNumber,
 		Status:              string(t.Status),
+		Type:                string(t.Type),
 		Priority:            string(t.Priority),
+		Source:              string(t.Source),
 		ErrorDescription:    t.ErrorDescription,
 		CustomerFeedback:    t.CustomerFeedback,
 		NodeID:              t.NodeID,
@@ -176,6 +333,8 @@ func (t *Ticket) ToDTO() TicketDTO {
 		ClientName:          clientName,
 		CategoryName:        categoryName,
 		TechnicianCount:     len(t.Technicians),
+		LeadTechnicianID:    leadID,
+		LeadTechnicianName:  leadName,
 		ComputerBrand:       t.ComputerBrand,
 		ComputerModel:       t.ComputerModel,
 		SerialNumber:        t.SerialNumber,
@@ -187,6 +346,11 @@ func (t *Ticket) ToDTO() TicketDTO {
 		StartDate:           t.StartDate,
 		DueDate:             t.DueDate,
 		ClosedAt:            t.ClosedAt,
+		ScheduledStart:      t.ScheduledStart,
+		ScheduledEnd:        t.ScheduledEnd,
+		DispatchStatus:      string(t.DispatchStatus),
+		CampaignID:          t.CampaignID,
+		Version:             t.Version,
 		CreatedAt:           t.CreatedAt,
 	}
 }
@@ -194,6 +358,7 @@ func (t *Ticket) ToDTO() TicketDTO {
 type CreateTicketRequest struct {
 	ErrorDescription string   `json:"errorDescription" validate:"required"`
 	Priority         string   `json:"priority"`
+	Source           string   `json:"source" validate:"omitempty,oneof=PORTAL PHONE EMAIL PARTNER_API WHATSAPP"` // only read on creation
 	NodeID           *uint    `json:"nodeId"`
 	ClientID         string   `json:"clientId"`
 	CategoryID       string   `json:"categoryId"`
@@ -206,6 +371,10 @@ type CreateTicketRequest struct {
 	Manufacturer     string   `json:"manufacturer"`    // alias for ComputerBrand
 	Model            string   `json:"model"`           // alias for ComputerModel
 	SerialNumber     string   `json:"serialNumber"`
+	// Set by the recall campaigns job, never read from the request body
+	CampaignID string `json:"-"`
+	// Version the edit is based on (updates only); 0 skips the stale check
+	Version int `json:"version"`
 }
 
 // GetBrand returns computerBrand or manufacturer (for backward compatibility)
@@ -226,6 +395,8 @@ func (r *CreateTicketRequest) GetModel() string {
 
 type UpdateStatusRequest struct {
 	Status string `json:"status" validate:"required"`
+	// Notes explain a waiting state (what the client or which parts we wait for)
+	Notes string `json:"notes"`
 }
 
 type SignTicketRequest struct {
@@ -234,19 +405,73 @@ type SignTicketRequest struct {
 	SignedByName        string `json:"signedByName" validate:"required"`
 }
 
+// AssignTechnicianRequest accepts either a plain list of technician IDs (the
+// first one becomes the lead) or explicit assignments with roles
 type AssignTechnicianRequest struct {
-	TechnicianIDs []string `json:"technicianIds" validate:"required"`
+	TechnicianIDs []string                `json:"technicianIds"`
+	Assignments   []TicketAssignmentInput `json:"assignments"`
+	// Override assigns technicians outside their working hours or on time off
+	Override bool `json:"override"`
+}
+
+// TicketAssignmentInput describes one crew member of a ticket
+type TicketAssignmentInput struct {
+	TechnicianID string   `json:"technicianId" validate:"required"`
+	Role         string   `json:"role"`
+	PayoutShare  *float64 `json:"payoutShare"`
+}
+
+// ToAssignments normalizes the request into assignment inputs
+func (r *AssignTechnicianRequest) ToAssignments() []TicketAssignmentInput {
+	if len(r.Assignments) > 0 {
+		return r.Assignments
+	}
+	inputs := make([]TicketAssignmentInput, len(r.TechnicianIDs))
+	for i, id := range r.TechnicianIDs {
+		role := AssignmentRoleAssistant
+		if i == 0 {
+			role = AssignmentRoleLead
+		}
+		inputs[i] = TicketAssignmentInput{TechnicianID: id, Role: string(role)}
+	}
+	return inputs
+}
+
+// TicketPayoutShare is the portion of a payout owed to one assignee
+type TicketPayoutShare struct {
+	TechnicianID   string         `json:"technicianId"`
+	TechnicianName string         `json:"technicianName"`
+	Role           AssignmentRole `json:"role"`
+	SharePercent   float64        `json:"sharePercent"`
+	Amount         float64        `json:"amount"`
+}
+
+// CreateTicketPayoutRequest splits a payout among the ticket assignees
+type CreateTicketPayoutRequest struct {
+	TotalAmount float64 `json:"totalAmount" validate:"required,gt=0"`
+	EntryDate   string  `json:"entryDate" validate:"required"`
+	DueDate     string  `json:"dueDate"`
+	Description string  `json:"description"`
 }
 
 // TicketFilters contains all possible filters for ticket queries
 type TicketFilters struct {
-	Status       string `json:"status"`
-	Priority     string `json:"priority"`
-	NodeID       string `json:"nodeId"`
-	ClientID     string `json:"clientId"`
-	CategoryID   string `json:"categoryId"`
-	TechnicianID string `json:"technicianId"`
-	Search       string `json:"search"`
-	DateFrom     string `json:"dateFrom"`
-	DateTo       string `json:"dateTo"`
+	Status         string `json:"status"`
+	Type           string `json:"type"` // SERVICO or RECLAMACAO
+	Priority       string `json:"priority"`
+	Source         string `json:"source"`
+	CampaignID     string `json:"campaignId"`
+	NodeID         string `json:"nodeId"`
+	ClientID       string `json:"clientId"`
+	CategoryID     string `json:"categoryId"`
+	TechnicianID   string `json:"technicianId"`
+	TechnicianRole string `json:"technicianRole"` // LEAD or ASSISTANT, combined with TechnicianID
+	Search         string `json:"search"`
+	DateFrom       string `json:"dateFrom"`
+	DateTo         string `json:"dateTo"`
+
+	// Hierarchy scope of the user listing the tickets
+	Scope *AccessScope `json:"-"`
+	// Keyset position to page from instead of the page number
+	Cursor *pagination.Cursor `json:"-"`
 }
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ArchivedTicket is a closed ticket moved out of the hot tables. The searchable columns
+// are copied out of Data, which holds every column of the tickets row keyed by column name.
+type ArchivedTicket struct {
+	TicketID         string         `json:"ticketId" gorm:"type:uuid;primaryKey"`
+	OSNumber         string         `json:"osNumber" gorm:"type:varchar(50);index"`
+	Type             TicketType     `json:"type" gorm:"type:varchar(20)"`
+	Priority         TicketPriority `json:"priority" gorm:"type:varchar(20)"`
+	ClientID         *string        `json:"clientId" gorm:"type:uuid;index"`
+	ClientName       string         `json:"clientName" gorm:"type:varchar(255)"`
+	CategoryID       *string        `json:"categoryId" gorm:"type:uuid"`
+	SerialNumber     string         `json:"serialNumber" gorm:"type:varchar(100)"`
+	ErrorDescription string         `json:"errorDescription" gorm:"type:text"`
+	TicketCreatedAt  time.Time      `json:"ticketCreatedAt"`
+	ClosedAt         *time.Time     `json:"closedAt" gorm:"index"`
+	ArchivedAt       time.Time      `json:"archivedAt" gorm:"not null;index"`
+	ArchivedBy       string         `json:"archivedBy" gorm:"type:varchar(36)"` // empty for the scheduled process
+	Data             string         `json:"-" gorm:"type:jsonb;not null"`
+
+	Records []ArchivedTicketRecord `json:"-" gorm:"foreignKey:TicketID"`
+}
+
+func (ArchivedTicket) TableName() string {
+	return "archived_tickets"
+}
+
+// ArchivedTicketRecord is a row of a ticket child table (comments, attachment metadata,
+// crew, timeline...) archived along with the ticket
+type ArchivedTicketRecord struct {
+	ID          uint   `json:"id" gorm:"primaryKey;autoIncrement"`
+	TicketID    string `json:"ticketId" gorm:"type:uuid;not null;index"`
+	SourceTable string `json:"sourceTable" gorm:"type:varchar(100);not null"`
+	Data        string `json:"-" gorm:"type:jsonb;not null"`
+}
+
+func (ArchivedTicketRecord) TableName() string {
+	return "archived_ticket_records"
+}
+
+// =============== DTOs ===============
+
+// ArchiveSearchFilters DTO; Query matches OS number, client, serial number and description
+type ArchiveSearchFilters struct {
+	Query    string
+	ClientID string
+	From     *time.Time // closed at or after
+	To       *time.Time // closed before
+}
+
+// ArchivedTicketDetail exposes an archived ticket with its raw rows
+type ArchivedTicketDetail struct {
+	ArchivedTicket
+	Ticket  json.RawMessage            `json:"ticket"`
+	Records map[string]json.RawMessage `json:"records"` // rows per source table, as a JSON array
+}
+
+// ArchiveRunResult summarizes an archival run
+type ArchiveRunResult struct {
+	Cutoff   time.Time `json:"cutoff"`
+	Archived int       `json:"archived"`
+	Skipped  int64     `json:"skipped"` // still referenced by financial entries, RMAs, complaints or on-call pages
+	Failed   int       `json:"failed"`
+	Errors   []string  `json:"errors,omitempty"`
+}
@@ -0,0 +1,135 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// Ticket budget sources
+const (
+	BudgetSourceManual = "MANUAL"
+	BudgetSourceQuote  = "QUOTE" // priced from the accepted quote lines
+)
+
+// Budget override statuses
+const (
+	BudgetOverridePending  = "PENDING"
+	BudgetOverrideApproved = "APPROVED"
+	BudgetOverrideRejected = "REJECTED"
+)
+
+// BudgetExcludedPayouts are the technician_payment subcategories that pay labor; they are not
+// expenses of the ticket and do not count against its expense budget
+var BudgetExcludedPayouts = []string{"commission", "bonus"}
+
+// TicketBudget caps what a ticket may spend on parts (stock consumption, valued at cost)
+// and on expenses (expense entries linked to the ticket). A nil cap is not enforced.
+type TicketBudget struct {
+	TicketID      string           `json:"ticketId" gorm:"type:uuid;primaryKey"`
+	PartsBudget   *decimal.Decimal `json:"partsBudget" gorm:"type:decimal(12,2)"`
+	ExpenseBudget *decimal.Decimal `json:"expenseBudget" gorm:"type:decimal(12,2)"`
+	Source        string           `json:"source" gorm:"type:varchar(20);not null;default:MANUAL"`
+	QuoteRef      string           `json:"quoteRef" gorm:"type:varchar(100)"`
+	Notes         string           `json:"notes" gorm:"type:text"`
+	SetBy         string           `json:"setBy" gorm:"type:varchar(36)"`
+	CreatedAt     time.Time        `json:"createdAt"`
+	UpdatedAt     time.Time        `json:"updatedAt"`
+
+	Ticket *Ticket `json:"ticket,omitempty" gorm:"foreignKey:TicketID"`
+}
+
+func (TicketBudget) TableName() string {
+	return "ticket_budgets"
+}
+
+// TicketBudgetOverride raises the expense cap of a ticket once approved. Parts overruns are
+// approved on the pending stock movement itself.
+type TicketBudgetOverride struct {
+	ID            string          `json:"id" gorm:"type:uuid;primaryKey"`
+	TicketID      string          `json:"ticketId" gorm:"type:uuid;not null;index"`
+	Amount        decimal.Decimal `json:"amount" gorm:"type:decimal(12,2);not null"`
+	Reason        string          `json:"reason" gorm:"type:text;not null"`
+	Status        string          `json:"status" gorm:"type:varchar(20);not null;index"`
+	RequestedBy   string          `json:"requestedBy" gorm:"type:varchar(36);not null"`
+	DecidedBy     *string         `json:"decidedBy" gorm:"type:varchar(36)"`
+	DecidedAt     *time.Time      `json:"decidedAt"`
+	DecisionNotes *string         `json:"decisionNotes" gorm:"type:text"`
+	CreatedAt     time.Time       `json:"createdAt" gorm:"index"`
+	UpdatedAt     time.Time       `json:"updatedAt"`
+
+	// Relations (for eager loading)
+	Ticket    *Ticket `json:"ticket,omitempty" gorm:"foreignKey:TicketID"`
+	Requester *User   `json:"requester,omitempty" gorm:"foreignKey:RequestedBy"`
+}
+
+func (o *TicketBudgetOverride) BeforeCreate(tx *gorm.DB) error {
+	if o.ID == "" {
+		o.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (TicketBudgetOverride) TableName() string {
+	return "ticket_budget_overrides"
+}
+
+// =============== DTOs ===============
+
+// SetTicketBudgetRequest DTO. With Lines, the parts budget is priced from the PART lines of
+// the quote through the price lists; the amounts given explicitly win.
+type SetTicketBudgetRequest struct {
+	PartsBudget   *decimal.Decimal `json:"partsBudget"`
+	ExpenseBudget *decimal.Decimal `json:"expenseBudget"`
+	QuoteRef      string           `json:"quoteRef" validate:"max=100"`
+	Lines         []PriceLine      `json:"lines" validate:"omitempty,dive"`
+	Notes         string           `json:"notes"`
+}
+
+// CreateBudgetOverrideRequest DTO
+type CreateBudgetOverrideRequest struct {
+	Amount decimal.Decimal `json:"amount"`
+	Reason string          `json:"reason" validate:"required,min=3"`
+}
+
+// BudgetOverrideDecisionRequest DTO
+type BudgetOverrideDecisionRequest struct {
+	Notes string `json:"notes"`
+}
+
+// TicketBudgetStatus is a budget with what has been spent against it
+type TicketBudgetStatus struct {
+	TicketBudget
+	PartsSpent       decimal.Decimal        `json:"partsSpent"`   // approved consumption
+	PartsPending     decimal.Decimal        `json:"partsPending"` // consumption waiting for approval
+	PartsRemaining   *decimal.Decimal       `json:"partsRemaining"`
+	ExpenseSpent     decimal.Decimal        `json:"expenseSpent"`
+	ExpenseOverrides decimal.Decimal        `json:"expenseOverrides"` // approved raises of the cap
+	ExpenseRemaining *decimal.Decimal       `json:"expenseRemaining"`
+	Overrides        []TicketBudgetOverride `json:"overrides"`
+	MissingPrices    int                    `json:"missingPrices,omitempty"` // quote lines without a price
+}
+
+// BudgetVarianceRow compares budget and actual spending, for a ticket or a technician
+type BudgetVarianceRow struct {
+	Key             string          `json:"key"` // ticket ID or technician ID
+	Label           string          `json:"label"`
+	Tickets         int             `json:"tickets"`
+	PartsBudget     decimal.Decimal `json:"partsBudget"`
+	PartsActual     decimal.Decimal `json:"partsActual"`
+	PartsVariance   decimal.Decimal `json:"partsVariance"` // actual - budget, positive is an overrun
+	ExpenseBudget   decimal.Decimal `json:"expenseBudget"`
+	ExpenseActual   decimal.Decimal `json:"expenseActual"`
+	ExpenseVariance decimal.Decimal `json:"expenseVariance"`
+	OverBudget      int             `json:"overBudget"` // tickets above any of their caps
+}
+
+// BudgetVarianceReport DTO; tickets are attributed to their lead technician
+type BudgetVarianceReport struct {
+	From         time.Time           `json:"from"`
+	To           time.Time           `json:"to"`
+	ByTicket     []BudgetVarianceRow `json:"byTicket"`
+	ByTechnician []BudgetVarianceRow `json:"byTechnician"`
+}
@@ -0,0 +1,37 @@
+package models
+
+// Bulk ticket actions
+const (
+	TicketBulkStatus = "STATUS"
+	TicketBulkAssign = "ASSIGN"
+	TicketBulkDelete = "DELETE"
+)
+
+// BulkTicketRequest applies one action to many tickets; each ticket succeeds or fails on
+// its own
+type BulkTicketRequest struct {
+	Action    string   `json:"action" validate:"required,oneof=STATUS ASSIGN DELETE"`
+	TicketIDs []string `json:"ticketIds" validate:"required,min=1,max=200,dive,required"`
+
+	// STATUS: the change goes through the workflow of each ticket's category
+	Status string `json:"status" validate:"required_if=Action STATUS"`
+	Notes  string `json:"notes"`
+
+	// ASSIGN: replaces the crew of every ticket; the first technician leads
+	TechnicianIDs []string `json:"technicianIds" validate:"required_if=Action ASSIGN,dive,required"`
+}
+
+// BulkTicketItemResult is the outcome of the action on one ticket
+type BulkTicketItemResult struct {
+	TicketID string `json:"ticketId"`
+	Success  bool   `json:"success"`
+	Error    string `json:"error,omitempty"`
+}
+
+type BulkTicketResult struct {
+	Action    string                 `json:"action"`
+	Total     int                    `json:"total"`
+	Succeeded int                    `json:"succeeded"`
+	Failed    int                    `json:"failed"`
+	Results   []BulkTicketItemResult `json:"results"`
+}
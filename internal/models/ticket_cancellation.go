@@ -0,0 +1,98 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Built-in cancellation reason codes, seeded on migration
+const (
+	CancellationClientCancelled = "CLIENT_CANCELLED"
+	CancellationNoParts         = "NO_PARTS"
+	CancellationDuplicate       = "DUPLICATE"
+	CancellationOutOfCoverage   = "OUT_OF_COVERAGE"
+)
+
+// CancellationReason is an entry of the managed cancellation taxonomy
+type CancellationReason struct {
+	ID          string    `json:"id" gorm:"type:uuid;primaryKey"`
+	Code        string    `json:"code" gorm:"type:varchar(50);uniqueIndex;not null"`
+	Name        string    `json:"name" gorm:"type:varchar(255);not null"`
+	Description string    `json:"description" gorm:"type:text"`
+	Active      bool      `json:"active" gorm:"default:true"`
+	SortOrder   int       `json:"sortOrder" gorm:"default:0"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+func (r *CancellationReason) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (CancellationReason) TableName() string {
+	return "cancellation_reasons"
+}
+
+// TicketCancellation records why and by whom a ticket was cancelled
+type TicketCancellation struct {
+	TicketID       string       `json:"ticketId" gorm:"type:uuid;primaryKey"`
+	ReasonID       string       `json:"reasonId" gorm:"type:uuid;not null;index"`
+	Notes          string       `json:"notes" gorm:"type:text"`
+	PreviousStatus TicketStatus `json:"previousStatus" gorm:"type:varchar(50)"`
+	CancelledBy    string       `json:"cancelledBy" gorm:"type:varchar(36)"`
+	CancelledAt    time.Time    `json:"cancelledAt" gorm:"not null;index"`
+
+	Reason *CancellationReason `json:"reason,omitempty" gorm:"foreignKey:ReasonID"`
+}
+
+func (TicketCancellation) TableName() string {
+	return "ticket_cancellations"
+}
+
+// =============== DTOs ===============
+
+// CancelTicketRequest DTO
+type CancelTicketRequest struct {
+	ReasonID string `json:"reasonId" validate:"required,uuid"`
+	Notes    string `json:"notes"`
+}
+
+// CreateCancellationReasonRequest DTO
+type CreateCancellationReasonRequest struct {
+	Code        string `json:"code" validate:"required,max=50"`
+	Name        string `json:"name" validate:"required,max=255"`
+	Description string `json:"description"`
+	SortOrder   int    `json:"sortOrder"`
+}
+
+// UpdateCancellationReasonRequest DTO; the code is fixed once created
+type UpdateCancellationReasonRequest struct {
+	Name        *string `json:"name" validate:"omitempty,max=255"`
+	Description *string `json:"description"`
+	Active      *bool   `json:"active"`
+	SortOrder   *int    `json:"sortOrder"`
+}
+
+// CancellationCount is one row of a cancellation breakdown
+type CancellationCount struct {
+	Key     string  `json:"key"`
+	Label   string  `json:"label"`
+	Count   int64   `json:"count"`
+	Percent float64 `json:"percent"`
+}
+
+// CancellationReport breaks cancellations down per reason, region (client state) and period
+type CancellationReport struct {
+	From     time.Time           `json:"from"`
+	To       time.Time           `json:"to"`
+	Period   string              `json:"period"` // day, week or month
+	Total    int64               `json:"total"`
+	ByReason []CancellationCount `json:"byReason"`
+	ByRegion []CancellationCount `json:"byRegion"`
+	ByPeriod []CancellationCount `json:"byPeriod"`
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// TicketPart is a part used on a ticket: the SAIDA_CONSUMO_OS movement that took it out of
+// stock and, when billable, the line charged to the client. Billable lines roll up into the
+// income entry created when the ticket is closed.
+type TicketPart struct {
+	ID         string           `json:"id" gorm:"type:uuid;primaryKey"`
+	TicketID   string           `json:"ticketId" gorm:"type:uuid;not null;index"`
+	ItemID     string           `json:"itemId" gorm:"type:uuid;not null;index"`
+	LocationID string           `json:"locationId" gorm:"type:uuid;not null"`
+	MovementID string           `json:"movementId" gorm:"type:uuid;not null;uniqueIndex"`
+	Quantity   int              `json:"quantity" gorm:"not null"`
+	Billable   bool             `json:"billable" gorm:"not null"`
+	UnitPrice  *decimal.Decimal `json:"unitPrice" gorm:"type:decimal(12,2)"`
+	Total      *decimal.Decimal `json:"total" gorm:"type:decimal(12,2)"`
+	Notes      string           `json:"notes" gorm:"type:text"`
+	RecordedBy string           `json:"recordedBy" gorm:"type:varchar(36);not null"`
+	// Income entry the line was billed in, set when the ticket is closed
+	BilledEntryID *string   `json:"billedEntryId" gorm:"type:uuid;index"`
+	CreatedAt     time.Time `json:"createdAt"`
+
+	Item     *StockItem     `json:"item,omitempty" gorm:"foreignKey:ItemID"`
+	Location *StockLocation `json:"location,omitempty" gorm:"foreignKey:LocationID"`
+	Movement *StockMovement `json:"movement,omitempty" gorm:"foreignKey:MovementID"`
+}
+
+func (p *TicketPart) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == "" {
+		p.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (TicketPart) TableName() string {
+	return "ticket_parts"
+}
+
+// =============== DTOs ===============
+
+// AddTicketPartRequest DTO. The part leaves the TECHNICIAN location of the caller unless
+// locationId is given; billable lines are priced from the price lists unless unitPrice is.
+type AddTicketPartRequest struct {
+	ItemID        string           `json:"itemId" validate:"required,uuid"`
+	Quantity      int              `json:"quantity" validate:"required,gt=0"`
+	LocationID    string           `json:"locationId" validate:"omitempty,uuid"`
+	Billable      bool             `json:"billable"`
+	UnitPrice     *decimal.Decimal `json:"unitPrice"`
+	Notes         string           `json:"notes" validate:"max=1000"`
+	SerialNumbers []string         `json:"serialNumbers"`
+}
+
+// TicketPartResponse is the recorded part with the warnings of its movement; the movement
+// may be PENDING when it takes the ticket over its parts budget
+type TicketPartResponse struct {
+	TicketPart
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// TicketPartsSummary lists the parts of a ticket and what is billed for them
+type TicketPartsSummary struct {
+	TicketID      string          `json:"ticketId"`
+	Parts         []TicketPart    `json:"parts"`
+	BillableTotal decimal.Decimal `json:"billableTotal"` // billable lines whose movement was not rejected
+}
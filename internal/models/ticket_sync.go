@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// Outcomes of a ticket change sent by the technician app
+const (
+	TicketSyncApplied  = "applied"
+	TicketSyncConflict = "conflict" // the ticket changed on the server since the app read it
+	TicketSyncStale    = "stale"    // a later change on the server supersedes it
+	TicketSyncError    = "error"
+)
+
+// TicketSyncChange is a status change the technician app made, possibly offline
+type TicketSyncChange struct {
+	LocalID  string `json:"localId" validate:"required"`
+	TicketID string `json:"ticketId" validate:"required"`
+	Status   string `json:"status" validate:"required"`
+	Notes    string `json:"notes"`
+	// Version of the ticket the change was made on; 0 applies it over any version
+	BaseVersion int `json:"baseVersion"`
+	// Device time of the change; a change older than the last server change is stale
+	ChangedAt *time.Time `json:"changedAt"`
+}
+
+// TicketSyncResult is the outcome of one change, with the ticket as stored after it so the
+// app can replace its copy (or merge, on a conflict)
+type TicketSyncResult struct {
+	LocalID  string     `json:"localId"`
+	TicketID string     `json:"ticketId"`
+	Status   string     `json:"status"`
+	Error    string     `json:"error,omitempty"`
+	Current  *TicketDTO `json:"current,omitempty"`
+}
+
+// TicketSyncRequest is a sync of the technician app: the changes made since the last one,
+// applied in order, and the cursor that sync returned (empty on the first sync)
+type TicketSyncRequest struct {
+	Changes []TicketSyncChange `json:"changes" validate:"max=100,dive"`
+	Cursor  string             `json:"cursor"`
+}
+
+// TicketSyncResponse has the outcome of each change and the tickets changed on the server
+// since the cursor, oldest first. HasMore asks for another sync with the new cursor.
+type TicketSyncResponse struct {
+	Results    []TicketSyncResult `json:"results"`
+	Changed    []TicketDTO        `json:"changed"`
+	DeletedIDs []string           `json:"deletedIds"`
+	Cursor     string             `json:"cursor"`
+	HasMore    bool               `json:"hasMore"`
+}
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Ticket timeline event types
+const (
+	TicketEventCommentCreated = "comment.created"
+	TicketEventClientComment  = "comment.client" // left by the client on the tracking page
+	TicketEventStatusChanged  = "ticket.status_changed"
+	TicketEventCheckin        = "location.checkin"
+	TicketEventCheckout       = "location.checkout"
+	TicketEventComplaint      = "complaint.opened" // on the original ticket of a complaint
+	TicketEventCancelled      = "ticket.cancelled"
+	TicketEventRestored       = "ticket.restored" // back from the archive
+)
+
+// TicketEvent is an outbox row written in the same transaction as the change it
+// describes. The auto-increment ID is the cursor used by timeline streams.
+type TicketEvent struct {
+	ID        uint      `json:"id" gorm:"primaryKey;autoIncrement;index:idx_ticket_events_ticket_id_id,priority:2"`
+	TicketID  string    `json:"ticketId" gorm:"type:uuid;not null;index:idx_ticket_events_ticket_id_id,priority:1"`
+	Type      string    `json:"type" gorm:"type:varchar(50);not null"`
+	ActorID   string    `json:"actorId,omitempty" gorm:"type:varchar(36)"`
+	Payload   string    `json:"payload" gorm:"type:jsonb;not null;default:'{}'"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (TicketEvent) TableName() string {
+	return "ticket_events"
+}
+
+// NewTicketEvent builds an event with its payload serialized as JSON
+func NewTicketEvent(ticketID, eventType, actorID string, payload interface{}) *TicketEvent {
+	data, err := json.Marshal(payload)
+	if err != nil || payload == nil {
+		data = []byte("{}")
+	}
+	return &TicketEvent{
+		TicketID: ticketID,
+		Type:     eventType,
+		ActorID:  actorID,
+		Payload:  string(data),
+	}
+}
+
+// Ticket comment authors
+const (
+	CommentSourceStaff  = "STAFF"
+	CommentSourceClient = "CLIENT" // no user; AuthorName is what the client typed
+)
+
+// TicketComment is a note added to the ticket timeline
+type TicketComment struct {
+	ID         string    `json:"id" gorm:"type:uuid;primaryKey"`
+	TicketID   string    `json:"ticketId" gorm:"type:uuid;not null;index"`
+	UserID     string    `json:"userId" gorm:"type:varchar(36)"`
+	Source     string    `json:"source" gorm:"type:varchar(10);not null;default:STAFF"`
+	AuthorName string    `json:"authorName,omitempty" gorm:"type:varchar(100)"`
+	Body       string    `json:"body" gorm:"type:text;not null"`
+	CreatedAt  time.Time `json:"createdAt"`
+
+	User *User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+func (c *TicketComment) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == "" {
+		c.ID = uuid.New().String()
+	}
+	if c.Source == "" {
+		c.Source = CommentSourceStaff
+	}
+	return nil
+}
+
+func (TicketComment) TableName() string {
+	return "ticket_comments"
+}
+
+// =============== DTOs ===============
+
+// CreateTicketCommentRequest DTO
+type CreateTicketCommentRequest struct {
+	Body string `json:"body" validate:"required,min=1,max=5000"`
+}
+
+// TicketEventDTO exposes the payload as raw JSON instead of an escaped string
+type TicketEventDTO struct {
+	ID        uint            `json:"id"`
+	TicketID  string          `json:"ticketId"`
+	Type      string          `json:"type"`
+	ActorID   string          `json:"actorId,omitempty"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+func (e *TicketEvent) ToDTO() TicketEventDTO {
+	payload := json.RawMessage(e.Payload)
+	if !json.Valid(payload) {
+		payload = json.RawMessage("{}")
+	}
+	return TicketEventDTO{
+		ID:        e.ID,
+		TicketID:  e.TicketID,
+		Type:      e.Type,
+		ActorID:   e.ActorID,
+		Payload:   payload,
+		CreatedAt: e.CreatedAt,
+	}
+}
@@ -0,0 +1,77 @@
+package models
+
+import "time"
+
+// Technician progress shown on the tracking page
+const (
+	TrackingTechnicianAssigned = "ASSIGNED"
+	TrackingTechnicianOnTheWay = "ON_THE_WAY" // recent location, ETA available
+	TrackingTechnicianOnSite   = "ON_SITE"
+	TrackingTechnicianDone     = "DONE"
+)
+
+// =============== DTOs ===============
+
+// CreateTrackingLinkRequest DTO; the configured lifetime is used when ExpiresInHours is 0
+type CreateTrackingLinkRequest struct {
+	ExpiresInHours int `json:"expiresInHours" validate:"omitempty,min=1,max=2160"`
+}
+
+// TrackingLinkResponse DTO
+type TrackingLinkResponse struct {
+	Token     string    `json:"token"`
+	URL       string    `json:"url"`
+	TicketID  string    `json:"ticketId"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// TicketTracking is what the client sees through a tracking link: no internal notes,
+// no prices and only the first name of the technician
+type TicketTracking struct {
+	TicketID       string              `json:"ticketId"`
+	OSNumber       string              `json:"osNumber"`
+	Status         TicketStatus        `json:"status"`
+	StatusLabel    string              `json:"statusLabel"`
+	CreatedAt      time.Time           `json:"createdAt"`
+	ScheduledStart *time.Time          `json:"scheduledStart,omitempty"`
+	ScheduledEnd   *time.Time          `json:"scheduledEnd,omitempty"`
+	ClosedAt       *time.Time          `json:"closedAt,omitempty"`
+	Technician     *TrackingTechnician `json:"technician,omitempty"`
+	Timeline       []TrackingEvent     `json:"timeline"`
+	Attachments    []TrackingFile      `json:"attachments"`
+	CanComment     bool                `json:"canComment"`
+}
+
+// TrackingFile is a processed ticket attachment; URL serves its metadata-stripped copy
+type TrackingFile struct {
+	ID       string `json:"id"`
+	FileName string `json:"fileName"`
+	FileType string `json:"fileType"`
+	URL      string `json:"url"`
+}
+
+// TrackingTechnician is the lead technician; the ETA comes from the last known location
+// and the client address, as the crow flies at the average scheduling speed
+type TrackingTechnician struct {
+	Name              string     `json:"name"`
+	Stage             string     `json:"stage"`
+	CheckedInAt       *time.Time `json:"checkedInAt,omitempty"`
+	ETAMinutes        *int       `json:"etaMinutes,omitempty"`
+	DistanceKm        *float64   `json:"distanceKm,omitempty"`
+	LocationUpdatedAt *time.Time `json:"locationUpdatedAt,omitempty"`
+}
+
+// TrackingEvent is one entry of the public timeline
+type TrackingEvent struct {
+	Type       string    `json:"type"`
+	Label      string    `json:"label"`
+	AuthorName string    `json:"authorName,omitempty"`
+	Body       string    `json:"body,omitempty"`
+	At         time.Time `json:"at"`
+}
+
+// TrackingCommentRequest DTO
+type TrackingCommentRequest struct {
+	Name string `json:"name" validate:"omitempty,max=100"`
+	Body string `json:"body" validate:"required,min=1,max=2000"`
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// Fields a status transition can require
+const (
+	TransitionFieldNotes      = "NOTES"      // notes sent with the status change
+	TransitionFieldTechnician = "TECHNICIAN" // at least one technician assigned
+	TransitionFieldSignature  = "SIGNATURE"  // ticket signed by the technician and the client
+)
+
+// TicketStatusTransition allows a ticket to move from one status to another. The
+// transitions of a category make up its workflow; categories without transitions of their
+// own follow the default workflow (CategoryID nil), or the built-in one when none is set.
+type TicketStatusTransition struct {
+	ID             string         `json:"id" gorm:"type:uuid;primaryKey"`
+	CategoryID     *string        `json:"categoryId" gorm:"type:uuid;index"`
+	FromStatus     TicketStatus   `json:"fromStatus" gorm:"type:varchar(50);not null"`
+	ToStatus       TicketStatus   `json:"toStatus" gorm:"type:varchar(50);not null"`
+	RequiredFields pq.StringArray `json:"requiredFields" gorm:"type:text[]"`
+	CreatedAt      time.Time      `json:"createdAt"`
+}
+
+func (t *TicketStatusTransition) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == "" {
+		t.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (TicketStatusTransition) TableName() string {
+	return "ticket_status_transitions"
+}
+
+// =============== DTOs ===============
+
+// TransitionInput DTO
+type TransitionInput struct {
+	FromStatus     string   `json:"fromStatus" validate:"required,oneof=ABERTO EM_ATENDIMENTO PARA_FECHAMENTO FECHADO IMPRODUTIVO CANCELADO AGUARDANDO_CLIENTE AGUARDANDO_PECAS"`
+	ToStatus       string   `json:"toStatus" validate:"required,nefield=FromStatus,oneof=ABERTO EM_ATENDIMENTO PARA_FECHAMENTO FECHADO IMPRODUTIVO AGUARDANDO_CLIENTE AGUARDANDO_PECAS"`
+	RequiredFields []string `json:"requiredFields" validate:"dive,oneof=NOTES TECHNICIAN SIGNATURE"`
+}
+
+// SetWorkflowRequest DTO: replaces the transitions of the category, or of the default
+// workflow without categoryId
+type SetWorkflowRequest struct {
+	CategoryID  *string           `json:"categoryId"`
+	Transitions []TransitionInput `json:"transitions" validate:"required,min=1,dive"`
+}
+
+// TicketWorkflow is the workflow a category follows
+type TicketWorkflow struct {
+	CategoryID  *string                  `json:"categoryId"`
+	Source      string                   `json:"source"` // CATEGORY, DEFAULT or BUILT_IN
+	Transitions []TicketStatusTransition `json:"transitions"`
+}
+
+// AllowedTransition is a status a ticket can move to and what the change requires
+type AllowedTransition struct {
+	Status         TicketStatus `json:"status"`
+	RequiredFields []string     `json:"requiredFields"`
+	Missing        []string     `json:"missing"` // required fields the ticket lacks (NOTES is given with the change)
+}
@@ -7,8 +7,12 @@ import (
 	"gorm.io/gorm"
 )
 
+// RoleAdmin manages the whole tenant and sees every hierarchy node
+const RoleAdmin = "ADMIN"
+
 type User struct {
 	ID             string    `json:"id" gorm:"type:varchar(36);primaryKey"`
+	TenantID       string    `json:"tenantId" gorm:"type:varchar(36);not null;default:'00000000-0000-0000-0000-000000000001';index"`
 	Email          string    `json:"email" gorm:"uniqueIndex;not null;type:varchar(255)"`
 	Password       string    `json:"-" gorm:"not null"`
 	FirstName      string    `json:"firstName" gorm:"type:varchar(100)"`
@@ -40,8 +44,9 @@ func (u *User) BeforeSave(tx *gorm.DB) error {
 
 // SignInRequest represents the login request
 type SignInRequest struct {
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=6"`
+	Email      string `json:"email" validate:"required,email"`
+	Password   string `json:"password" validate:"required,min=6"`
+	DeviceName string `json:"deviceName" validate:"max=100"` // shown in the session list
 }
 
 // SignUpRequest represents the registration request
@@ -54,12 +59,14 @@ type SignUpRequest struct {
 
 // AuthResponse represents the authentication response
 type AuthResponse struct {
-	Token       string   `json:"token"`
-	FirstName   string   `json:"firstName"`
-	LastName    string   `json:"lastName"`
-	Email       string   `json:"email"`
-	Role        string   `json:"role"`
-	Permissions []string `json:"permissions"` // List of permission codes for UI control
+	Token        string   `json:"token"`
+	RefreshToken string   `json:"refreshToken"` // single use: each refresh returns the next one
+	SessionID    string   `json:"sessionId"`
+	FirstName    string   `json:"firstName"`
+	LastName     string   `json:"lastName"`
+	Email        string   `json:"email"`
+	Role         string   `json:"role"`
+	Permissions  []string `json:"permissions"` // List of permission codes for UI control
 }
 
 // ChangePasswordRequest represents the change password request
@@ -0,0 +1,19 @@
+package models
+
+// FieldConflict is a field whose submitted value differs from the stored one
+type FieldConflict struct {
+	Field     string      `json:"field"`
+	Submitted interface{} `json:"submitted"`
+	Current   interface{} `json:"current"`
+}
+
+// VersionConflictResponse is the 409 body of an update based on a stale version, with
+// what a merge UI needs: the stored record and the fields the two versions disagree on
+type VersionConflictResponse struct {
+	Error            string          `json:"error"`
+	Entity           string          `json:"entity"`
+	SubmittedVersion int             `json:"submittedVersion"`
+	CurrentVersion   int             `json:"currentVersion"`
+	Current          interface{}     `json:"current"`
+	Conflicts        []FieldConflict `json:"conflicts"`
+}
@@ -0,0 +1,157 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// Domain events delivered to the webhook subscriptions through the delivery queue
+const (
+	WebhookEventTicketCreated       = "ticket.created"
+	WebhookEventTicketStatusChanged = "ticket.status_changed"
+	WebhookEventStockLow            = "stock.low"
+	WebhookEventFinancialBatchPaid  = "financial.batch_paid"
+)
+
+// Webhook delivery statuses
+const (
+	WebhookDeliveryPending   = "PENDING" // waiting for its first attempt or a retry
+	WebhookDeliverySucceeded = "SUCCEEDED"
+	WebhookDeliveryFailed    = "FAILED" // out of attempts
+)
+
+// WebhookSubscription sends the notifications and domain events of its events to an
+// external receiver. Notifications are posted as the default JSON payload, or the output
+// of Template (a Go text/template over WebhookPayload) for receivers expecting their own
+// shape, like Slack, Teams or an ERP. Domain events are queued as WebhookDelivery rows and
+// always posted as the WebhookEvent JSON envelope. Every post is signed with Secret.
+type WebhookSubscription struct {
+	ID          string         `json:"id" gorm:"type:uuid;primaryKey"`
+	TenantID    string         `json:"tenantId" gorm:"type:varchar(36);not null;default:'00000000-0000-0000-0000-000000000001';index"`
+	Name        string         `json:"name" gorm:"type:varchar(100);not null"`
+	URL         string         `json:"url" gorm:"type:varchar(500);not null"`
+	Token       string         `json:"-" gorm:"type:varchar(255)"` // sent as a bearer token
+	HasToken    bool           `json:"hasToken" gorm:"-"`
+	Secret      string         `json:"-" gorm:"type:varchar(100)"` // HMAC-SHA256 key of X-Webhook-Signature
+	NewSecret   string         `json:"secret,omitempty" gorm:"-"`  // only returned when created or rotated
+	Events      pq.StringArray `json:"events" gorm:"type:text[]"`  // empty = every event
+	Template    string         `json:"template" gorm:"type:text"`
+	ContentType string         `json:"contentType" gorm:"type:varchar(100);not null;default:application/json"`
+	Active      bool           `json:"active" gorm:"default:true;index"`
+	CreatedBy   string         `json:"createdBy" gorm:"type:varchar(36)"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	UpdatedAt   time.Time      `json:"updatedAt"`
+}
+
+func (w *WebhookSubscription) BeforeCreate(tx *gorm.DB) error {
+	if w.ID == "" {
+		w.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (w *WebhookSubscription) AfterFind(tx *gorm.DB) error {
+	w.HasToken = w.Token != ""
+	return nil
+}
+
+func (WebhookSubscription) TableName() string {
+	return "webhook_subscriptions"
+}
+
+// WebhookDelivery is a domain event queued for a subscription, and the log of its attempts
+type WebhookDelivery struct {
+	ID             string     `json:"id" gorm:"type:uuid;primaryKey"`
+	SubscriptionID string     `json:"subscriptionId" gorm:"type:uuid;not null;index"`
+	EventID        string     `json:"eventId" gorm:"type:uuid;not null;index"` // shared by the deliveries of an event
+	Event          string     `json:"event" gorm:"type:varchar(50);not null;index"`
+	Payload        string     `json:"payload" gorm:"type:text;not null"`
+	Status         string     `json:"status" gorm:"type:varchar(20);not null;default:PENDING;index"`
+	Attempts       int        `json:"attempts" gorm:"default:0"`
+	NextAttemptAt  *time.Time `json:"nextAttemptAt" gorm:"index"`
+	LastAttemptAt  *time.Time `json:"lastAttemptAt"`
+	ResponseStatus int        `json:"responseStatus"`
+	ResponseBody   string     `json:"responseBody" gorm:"type:text"` // truncated
+	Error          string     `json:"error" gorm:"type:text"`
+	DurationMs     int64      `json:"durationMs"`
+	DeliveredAt    *time.Time `json:"deliveredAt"`
+	CreatedAt      time.Time  `json:"createdAt" gorm:"index"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+}
+
+func (d *WebhookDelivery) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == "" {
+		d.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
+
+// =============== DTOs ===============
+
+// WebhookPayload is the data of a delivery, and the dot of the subscription templates
+type WebhookPayload struct {
+	Event        string    `json:"event"`
+	UserID       string    `json:"userId"`
+	Email        string    `json:"email"`
+	Title        string    `json:"title"`
+	Message      string    `json:"message"`
+	ResourceType string    `json:"resourceType"`
+	ResourceID   string    `json:"resourceId"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// WebhookEvent is the body of a domain event delivery
+type WebhookEvent struct {
+	ID         string      `json:"id"`
+	Event      string      `json:"event"`
+	OccurredAt time.Time   `json:"occurredAt"`
+	Data       interface{} `json:"data"`
+}
+
+// WebhookDeliveryFilter DTO
+type WebhookDeliveryFilter struct {
+	SubscriptionID string
+	Event          string
+	Status         string
+}
+
+// WebhookSubscriptionRequest DTO
+type WebhookSubscriptionRequest struct {
+	Name        string   `json:"name" validate:"required,max=100"`
+	URL         string   `json:"url" validate:"required,url,max=500"`
+	Token       *string  `json:"token" validate:"omitempty,max=255"` // nil keeps the current token
+	Events      []string `json:"events" validate:"dive,oneof=TICKET_ASSIGNED SLA_BREACH LOW_STOCK PAYMENT_BATCH_APPROVED ticket.created ticket.status_changed stock.low financial.batch_paid"`
+	Template    string   `json:"template" validate:"max=20000"`
+	ContentType string   `json:"contentType" validate:"max=100"`
+	Active      *bool    `json:"active"`
+}
+
+// WebhookTemplateRequest DTO, to check a template before saving it
+type WebhookTemplateRequest struct {
+	Template    string `json:"template" validate:"required,max=20000"`
+	ContentType string `json:"contentType" validate:"max=100"`
+}
+
+// WebhookTemplatePreview is the template rendered with a sample payload
+type WebhookTemplatePreview struct {
+	Valid       bool   `json:"valid"`
+	Error       string `json:"error,omitempty"`
+	ContentType string `json:"contentType"`
+	Body        string `json:"body,omitempty"`
+}
+
+// WebhookTestResult is the outcome of a test delivery
+type WebhookTestResult struct {
+	Delivered  bool   `json:"delivered"`
+	StatusCode int    `json:"statusCode,omitempty"`
+	Error      string `json:"error,omitempty"`
+	Body       string `json:"body"` // what was sent
+	DurationMs int64  `json:"durationMs"`
+}
@@ -0,0 +1,93 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor is returned for a cursor that wasn't issued by the listing
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor is a keyset position in a listing: the sort keys and ID of a row. The zero
+// cursor is the first page. Before asks for the page preceding the row instead of the
+// one following it.
+type Cursor struct {
+	Keys   []time.Time
+	ID     string
+	Before bool
+}
+
+// IsStart tells whether the cursor is the first page rather than a row position
+func (c Cursor) IsStart() bool {
+	return c.ID == ""
+}
+
+// Encode returns the opaque form of the cursor handed to clients
+func (c Cursor) Encode() string {
+	direction := "n"
+	if c.Before {
+		direction = "p"
+	}
+	keys := make([]string, len(c.Keys))
+	for i, key := range c.Keys {
+		keys[i] = key.UTC().Format(time.RFC3339Nano)
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(direction + "|" + strings.Join(keys, ",") + "|" + c.ID))
+}
+
+// DecodeCursor parses a cursor returned by Encode; "" is the first page
+func DecodeCursor(cursor string) (Cursor, error) {
+	if cursor == "" {
+		return Cursor{}, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	parts := strings.SplitN(string(raw), "|", 3)
+	if len(parts) != 3 || (parts[0] != "n" && parts[0] != "p") || parts[1] == "" || parts[2] == "" {
+		return Cursor{}, ErrInvalidCursor
+	}
+	c := Cursor{ID: parts[2], Before: parts[0] == "p"}
+	for _, value := range strings.Split(parts[1], ",") {
+		key, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return Cursor{}, ErrInvalidCursor
+		}
+		c.Keys = append(c.Keys, key)
+	}
+	return c, nil
+}
+
+// CursorPage trims the rows fetched for the cursor (up to size+1, in fetch order: reversed
+// when going Before) to the page, in list order, and returns the cursors of the pages
+// after and before it, empty at the ends of the listing. key returns the sort keys and
+// ID of a row.
+func CursorPage[T any](rows []T, cursor Cursor, size int, key func(T) ([]time.Time, string)) (page []T, next, prev string) {
+	more := len(rows) > size
+	if more {
+		rows = rows[:size]
+	}
+	if cursor.Before {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+	if len(rows) == 0 {
+		return rows, "", ""
+	}
+
+	// Going forward there are rows before the page unless it is the first one; going
+	// back there are rows after it, the ones the cursor came from
+	if more && !cursor.Before || cursor.Before && !cursor.IsStart() {
+		keys, id := key(rows[len(rows)-1])
+		next = Cursor{Keys: keys, ID: id}.Encode()
+	}
+	if more && cursor.Before || !cursor.Before && !cursor.IsStart() {
+		keys, id := key(rows[0])
+		prev = Cursor{Keys: keys, ID: id, Before: true}.Encode()
+	}
+	return rows, next, prev
+}
@@ -0,0 +1,109 @@
+package pagination
+
+import (
+	"sort"
+	"sync"
+)
+
+// Resources with their own page size limits; any other resource uses Default
+const (
+	Default      = "default"
+	Tickets      = "tickets"
+	Technicians  = "technicians"
+	Clients      = "clients"
+	Users        = "users"
+	Stock        = "stock"
+	Financial    = "financial"
+	Logs         = "logs"
+	GeoLocations = "geo.locations"
+	GeoHistory   = "geo.history"
+)
+
+// HardMax is the largest page size any resource can be configured with
+const HardMax = 1000
+
+// Limits are the page size used when the client asks for none and the largest one it
+// may ask for
+type Limits struct {
+	Default int
+	Max     int
+}
+
+var defaults = map[string]Limits{
+	Default:      {Default: 20, Max: 100},
+	Tickets:      {Default: 20, Max: 1000},
+	Technicians:  {Default: 20, Max: 1000},
+	Clients:      {Default: 20, Max: 1000},
+	Users:        {Default: 20, Max: 100},
+	Stock:        {Default: 20, Max: 100},
+	Financial:    {Default: 20, Max: 100},
+	Logs:         {Default: 20, Max: 100},
+	GeoLocations: {Default: 50, Max: 200},
+	GeoHistory:   {Default: 100, Max: 1000},
+}
+
+var (
+	mu     sync.RWMutex
+	limits = make(map[string]Limits)
+)
+
+// Resources returns the resources with configurable limits, in name order
+func Resources() []string {
+	resources := make([]string, 0, len(defaults))
+	for resource := range defaults {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+	return resources
+}
+
+// DefaultLimits returns the built-in limits of the resource
+func DefaultLimits(resource string) Limits {
+	if l, ok := defaults[resource]; ok {
+		return l
+	}
+	return defaults[Default]
+}
+
+// Get returns the limits in use for the resource
+func Get(resource string) Limits {
+	mu.RLock()
+	defer mu.RUnlock()
+	if l, ok := limits[resource]; ok {
+		return l
+	}
+	return DefaultLimits(resource)
+}
+
+// Set changes the limits of the resource (runtime settings)
+func Set(resource string, l Limits) {
+	mu.Lock()
+	defer mu.Unlock()
+	limits[resource] = l
+}
+
+// Size returns the page size to use for the requested one: the default when none
+// (or an invalid one) was asked for, never more than the cap of the resource
+func Size(resource string, requested int) int {
+	l := Get(resource)
+	if requested < 1 {
+		return l.Default
+	}
+	if requested > l.Max {
+		return l.Max
+	}
+	return requested
+}
+
+// TotalPages returns the number of pages of the given size holding total items
+func TotalPages(total int64, size int) int {
+	if size < 1 {
+		return 0
+	}
+	return int((total + int64(size) - 1) / int64(size))
+}
+
+// HasNext tells whether items remain after the page starting at offset
+func HasNext(offset, size int, total int64) bool {
+	return int64(offset+size) < total
+}
@@ -0,0 +1,123 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/database"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+type AccountingExportRepository interface {
+	// WithContext returns the repository running its statements with ctx, inside the tenant it carries
+	WithContext(ctx context.Context) AccountingExportRepository
+	FindLayouts() ([]models.AccountingLayout, error)
+	FindLayoutByID(id string) (*models.AccountingLayout, error)
+	SaveLayout(layout *models.AccountingLayout) error
+	DeleteLayout(id string) error
+
+	// EnqueueExport creates the export and writes its generation outbox event in the same
+	// transaction
+	EnqueueExport(export *models.FinancialExport) error
+	UpdateExport(export *models.FinancialExport) error
+	FindExportByID(id string) (*models.FinancialExport, error)
+	FindExports(filter models.FinancialExportFilter, page, size int) ([]models.FinancialExport, int64, error)
+
+	// FindEntries returns the entries that were not cancelled with the date field
+	// (entry_date or payment_date) in the range, oldest first
+	FindEntries(dateField string, start, end time.Time, entryType models.FinancialEntryType) ([]models.FinancialEntry, error)
+}
+
+type accountingExportRepository struct {
+	db *gorm.DB
+}
+
+func NewAccountingExportRepository(db *gorm.DB) AccountingExportRepository {
+	return &accountingExportRepository{db: db}
+}
+
+func (r *accountingExportRepository) WithContext(ctx context.Context) AccountingExportRepository {
+	return &accountingExportRepository{db: r.db.WithContext(ctx)}
+}
+
+func (r *accountingExportRepository) FindLayouts() ([]models.AccountingLayout, error) {
+	var layouts []models.AccountingLayout
+	err := r.db.Order("name").Find(&layouts).Error
+	return layouts, err
+}
+
+func (r *accountingExportRepository) FindLayoutByID(id string) (*models.AccountingLayout, error) {
+	var layout models.AccountingLayout
+	if err := r.db.First(&layout, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &layout, nil
+}
+
+func (r *accountingExportRepository) SaveLayout(layout *models.AccountingLayout) error {
+	return r.db.Save(layout).Error
+}
+
+func (r *accountingExportRepository) DeleteLayout(id string) error {
+	return r.db.Delete(&models.AccountingLayout{}, "id = ?", id).Error
+}
+
+func (r *accountingExportRepository) EnqueueExport(export *models.FinancialExport) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Layout").Create(export).Error; err != nil {
+			return err
+		}
+		event, err := models.NewOutboxEvent(models.OutboxTopicFinancialExport, "financial_export", export.ID,
+			models.FinancialExportPayload{ExportID: export.ID})
+		if err != nil {
+			return err
+		}
+		return tx.Create(event).Error
+	})
+}
+
+func (r *accountingExportRepository) UpdateExport(export *models.FinancialExport) error {
+	return r.db.Omit("Layout").Save(export).Error
+}
+
+func (r *accountingExportRepository) FindExportByID(id string) (*models.FinancialExport, error) {
+	var export models.FinancialExport
+	if err := r.db.Preload("Layout").First(&export, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+func (r *accountingExportRepository) FindExports(filter models.FinancialExportFilter, page, size int) ([]models.FinancialExport, int64, error) {
+	query := r.db.Model(&models.FinancialExport{})
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.RequestedBy != "" {
+		query = query.Where("requested_by = ?", filter.RequestedBy)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var exports []models.FinancialExport
+	err := query.Preload("Layout").Order("created_at DESC").Offset(page * size).Limit(size).Find(&exports).Error
+	return exports, total, err
+}
+
+func (r *accountingExportRepository) FindEntries(dateField string, start, end time.Time, entryType models.FinancialEntryType) ([]models.FinancialEntry, error) {
+	column := "entry_date"
+	if dateField == "payment_date" {
+		column = "payment_date"
+	}
+	query := r.db.Scopes(database.Replica).Preload("Client").Preload("Supplier").
+		Where(column+" BETWEEN ? AND ? AND status <> ?", start, end, models.FinancialEntryStatusCancelled)
+	if entryType != "" {
+		query = query.Where("type = ?", entryType)
+	}
+	var entries []models.FinancialEntry
+	err := query.Order(column + ", created_at").Find(&entries).Error
+	return entries, err
+}
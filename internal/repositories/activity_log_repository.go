@@ -1,6 +1,7 @@
 package repositories
 
 import (
+	"context"
 	"time"
 
 	"github.com/shigake/tech-iq-back/internal/models"
@@ -8,6 +9,8 @@ import (
 )
 
 type ActivityLogRepository interface {
+	// WithContext runs the queries with the context, so their logs carry its request ID
+	WithContext(ctx context.Context) ActivityLogRepository
 	Create(log *models.ActivityLog) error
 	FindByID(id string) (*models.ActivityLog, error)
 	FindByUserID(userID string, page, limit int) ([]models.ActivityLog, int64, error)
@@ -16,8 +19,26 @@ type ActivityLogRepository interface {
 	DeleteOlderThan(date time.Time) (int64, error)
 	CountByAction(action string) (int64, error)
 	GetRecentLogs(limit int) ([]models.ActivityLog, error)
+
+	// Hash chain
+	// SealUnchained links the records written before the chain existed, oldest first
+	SealUnchained() (int64, error)
+	// FindChained returns the chained records created in the period after the given
+	// sequence, in chain order
+	FindChained(from, to time.Time, afterSequence int64, limit int) ([]models.ActivityLog, error)
+	FindBySequenceRange(first, last int64) ([]models.ActivityLog, error)
+	FindBySequence(sequence int64) (*models.ActivityLog, error)
+	// FindNextChained returns the first record after the sequence, nil at the end of the chain
+	FindNextChained(afterSequence int64) (*models.ActivityLog, error)
+	// FindLastChainedBefore returns the last record created before t, nil when there is none
+	FindLastChainedBefore(t time.Time) (*models.ActivityLog, error)
+	FindFirstChained() (*models.ActivityLog, error)
+	FindUnsealed(from, to time.Time, limit int) ([]models.ActivityLog, error)
 }
 
+// activityLogChainLock is the advisory lock key serializing the writers of the hash chain
+const activityLogChainLock = 0x61756469746c6f67
+
 type activityLogRepository struct {
 	db *gorm.DB
 }
@@ -26,8 +47,140 @@ func NewActivityLogRepository(db *gorm.DB) ActivityLogRepository {
 	return &activityLogRepository{db: db}
 }
 
+func (r *activityLogRepository) WithContext(ctx context.Context) ActivityLogRepository {
+	return &activityLogRepository{db: r.db.WithContext(ctx)}
+}
+
+// Create appends the record to the hash chain
 func (r *activityLogRepository) Create(log *models.ActivityLog) error {
-	return r.db.Create(log).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// Writers take turns so every record links to the one written before it
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", activityLogChainLock).Error; err != nil {
+			return err
+		}
+		prev, err := lastChained(tx)
+		if err != nil {
+			return err
+		}
+		// Postgres keeps microseconds; the hash must match what is read back
+		log.CreatedAt = time.Now().Truncate(time.Microsecond)
+		if log.ID == "" {
+			log.BeforeCreate(tx)
+		}
+		log.Seal(prev)
+		return tx.Create(log).Error
+	})
+}
+
+func lastChained(tx *gorm.DB) (*models.ActivityLog, error) {
+	var logs []models.ActivityLog
+	err := tx.Where("sequence IS NOT NULL").Order("sequence DESC").Limit(1).Find(&logs).Error
+	if err != nil || len(logs) == 0 {
+		return nil, err
+	}
+	return &logs[0], nil
+}
+
+func (r *activityLogRepository) SealUnchained() (int64, error) {
+	var sealed int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", activityLogChainLock).Error; err != nil {
+			return err
+		}
+		prev, err := lastChained(tx)
+		if err != nil {
+			return err
+		}
+		for {
+			var batch []models.ActivityLog
+			err := tx.Where("sequence IS NULL").
+				Order("created_at ASC, id ASC").
+				Limit(500).
+				Find(&batch).Error
+			if err != nil {
+				return err
+			}
+			if len(batch) == 0 {
+				return nil
+			}
+			for i := range batch {
+				log := &batch[i]
+				log.Seal(prev)
+				err := tx.Model(&models.ActivityLog{}).Where("id = ?", log.ID).Updates(map[string]interface{}{
+					"sequence":  log.Sequence,
+					"prev_hash": log.PrevHash,
+					"hash":      log.Hash,
+				}).Error
+				if err != nil {
+					return err
+				}
+				prev = log
+				sealed++
+			}
+		}
+	})
+	return sealed, err
+}
+
+func (r *activityLogRepository) FindChained(from, to time.Time, afterSequence int64, limit int) ([]models.ActivityLog, error) {
+	var logs []models.ActivityLog
+	err := r.db.
+		Where("sequence > ? AND created_at >= ? AND created_at < ?", afterSequence, from, to).
+		Order("sequence ASC").
+		Limit(limit).
+		Find(&logs).Error
+	return logs, err
+}
+
+func (r *activityLogRepository) FindBySequenceRange(first, last int64) ([]models.ActivityLog, error) {
+	var logs []models.ActivityLog
+	err := r.db.Where("sequence BETWEEN ? AND ?", first, last).Order("sequence ASC").Find(&logs).Error
+	return logs, err
+}
+
+func (r *activityLogRepository) FindBySequence(sequence int64) (*models.ActivityLog, error) {
+	var log models.ActivityLog
+	if err := r.db.Where("sequence = ?", sequence).First(&log).Error; err != nil {
+		return nil, err
+	}
+	return &log, nil
+}
+
+func (r *activityLogRepository) FindNextChained(afterSequence int64) (*models.ActivityLog, error) {
+	var logs []models.ActivityLog
+	err := r.db.Where("sequence > ?", afterSequence).Order("sequence ASC").Limit(1).Find(&logs).Error
+	if err != nil || len(logs) == 0 {
+		return nil, err
+	}
+	return &logs[0], nil
+}
+
+func (r *activityLogRepository) FindLastChainedBefore(t time.Time) (*models.ActivityLog, error) {
+	var logs []models.ActivityLog
+	err := r.db.Where("sequence IS NOT NULL AND created_at < ?", t).Order("sequence DESC").Limit(1).Find(&logs).Error
+	if err != nil || len(logs) == 0 {
+		return nil, err
+	}
+	return &logs[0], nil
+}
+
+func (r *activityLogRepository) FindFirstChained() (*models.ActivityLog, error) {
+	var logs []models.ActivityLog
+	err := r.db.Where("sequence IS NOT NULL").Order("sequence ASC").Limit(1).Find(&logs).Error
+	if err != nil || len(logs) == 0 {
+		return nil, err
+	}
+	return &logs[0], nil
+}
+
+func (r *activityLogRepository) FindUnsealed(from, to time.Time, limit int) ([]models.ActivityLog, error) {
+	var logs []models.ActivityLog
+	err := r.db.
+		Where("sequence IS NULL AND created_at >= ? AND created_at < ?", from, to).
+		Order("created_at ASC").
+		Limit(limit).
+		Find(&logs).Error
+	return logs, err
 }
 
 func (r *activityLogRepository) FindByID(id string) (*models.ActivityLog, error) {
@@ -86,6 +239,9 @@ func (r *activityLogRepository) FindAll(filter *models.ActivityLogFilter, page,
 		if filter.ResourceID != "" {
 			query = query.Where("resource_id = ?", filter.ResourceID)
 		}
+		if filter.RequestID != "" {
+			query = query.Where("request_id = ?", filter.RequestID)
+		}
 		if !filter.StartDate.IsZero() {
 			query = query.Where("created_at >= ?", filter.StartDate)
 		}
@@ -0,0 +1,146 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+// AlertModuleCount is a raw badge row
+type AlertModuleCount struct {
+	Module   string
+	Status   string
+	Severity string
+	Count    int64
+}
+
+// MockedLocationCount groups the mocked GPS fixes of a technician
+type MockedLocationCount struct {
+	TechnicianID   string
+	TechnicianName string
+	Count          int64
+	LastAt         time.Time
+}
+
+type AlertRepository interface {
+	FindAll(page, size int, filters *models.AlertFilters) ([]models.Alert, int64, error)
+	FindByID(id string) (*models.Alert, error)
+	FindActiveByKey(dedupKey string) (*models.Alert, error)
+	FindActiveByType(alertType string) ([]models.Alert, error)
+	Create(alert *models.Alert) error
+	Update(alert *models.Alert) error
+	CountActive() ([]AlertModuleCount, error)
+	CountMockedLocations(since time.Time) ([]MockedLocationCount, error)
+}
+
+type alertRepository struct {
+	db *gorm.DB
+}
+
+func NewAlertRepository(db *gorm.DB) AlertRepository {
+	return &alertRepository{db: db}
+}
+
+var activeAlertStatuses = []string{models.AlertStatusOpen, models.AlertStatusAcknowledged}
+
+func (r *alertRepository) FindAll(page, size int, filters *models.AlertFilters) ([]models.Alert, int64, error) {
+	var alerts []models.Alert
+	var total int64
+
+	query := r.db.Model(&models.Alert{})
+	if filters != nil {
+		if filters.Module != "" {
+			query = query.Where("module = ?", filters.Module)
+		}
+		if filters.Type != "" {
+			query = query.Where("type = ?", filters.Type)
+		}
+		if filters.Severity != "" {
+			query = query.Where("severity = ?", filters.Severity)
+		}
+		switch filters.Status {
+		case "":
+		case "ACTIVE":
+			query = query.Where("status IN ?", activeAlertStatuses)
+		default:
+			query = query.Where("status = ?", filters.Status)
+		}
+		if filters.OwnerID != "" {
+			query = query.Where("owner_id = ?", filters.OwnerID)
+		}
+		if filters.From != nil {
+			query = query.Where("created_at >= ?", *filters.From)
+		}
+		if filters.To != nil {
+			query = query.Where("created_at < ?", *filters.To)
+		}
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Preload("Owner").
+		Order("CASE severity WHEN 'CRITICAL' THEN 0 WHEN 'WARNING' THEN 1 ELSE 2 END, last_seen_at DESC").
+		Offset(page * size).
+		Limit(size).
+		Find(&alerts).Error
+	return alerts, total, err
+}
+
+func (r *alertRepository) FindByID(id string) (*models.Alert, error) {
+	var alert models.Alert
+	if err := r.db.Preload("Owner").First(&alert, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &alert, nil
+}
+
+func (r *alertRepository) FindActiveByKey(dedupKey string) (*models.Alert, error) {
+	var alert models.Alert
+	err := r.db.Where("dedup_key = ? AND status IN ?", dedupKey, activeAlertStatuses).
+		Order("created_at DESC").
+		First(&alert).Error
+	if err != nil {
+		return nil, err
+	}
+	return &alert, nil
+}
+
+func (r *alertRepository) FindActiveByType(alertType string) ([]models.Alert, error) {
+	var alerts []models.Alert
+	err := r.db.Where("type = ? AND status IN ?", alertType, activeAlertStatuses).Find(&alerts).Error
+	return alerts, err
+}
+
+func (r *alertRepository) Create(alert *models.Alert) error {
+	return r.db.Omit("Owner").Create(alert).Error
+}
+
+func (r *alertRepository) Update(alert *models.Alert) error {
+	return r.db.Omit("Owner").Save(alert).Error
+}
+
+// CountActive counts open and acknowledged alerts per module, status and severity
+func (r *alertRepository) CountActive() ([]AlertModuleCount, error) {
+	var rows []AlertModuleCount
+	err := r.db.Model(&models.Alert{}).
+		Select("module, status, severity, COUNT(*) AS count").
+		Where("status IN ?", activeAlertStatuses).
+		Group("module, status, severity").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// CountMockedLocations groups the GPS fixes flagged as mocked since the given time per technician
+func (r *alertRepository) CountMockedLocations(since time.Time) ([]MockedLocationCount, error) {
+	var rows []MockedLocationCount
+	err := r.db.Table("technician_locations tl").
+		Select("tl.technician_id, COALESCE(t.full_name, '') AS technician_name, COUNT(*) AS count, MAX(tl.server_time) AS last_at").
+		Joins("LEFT JOIN technicians t ON t.id = tl.technician_id").
+		Where("tl.is_mocked = ? AND tl.server_time >= ?", true, since).
+		Group("tl.technician_id, t.full_name").
+		Scan(&rows).Error
+	return rows, err
+}
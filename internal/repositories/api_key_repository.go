@@ -0,0 +1,87 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+type APIKeyRepository interface {
+	FindAll() ([]models.APIKey, error)
+	FindByID(id string) (*models.APIKey, error)
+	// FindByPrefix looks the key up in every tenant: the key itself tells the tenant
+	FindByPrefix(prefix string) (*models.APIKey, error)
+	// Create saves the key with its service account
+	Create(key *models.APIKey, user *models.User) error
+	Update(key *models.APIKey) error
+	// Revoke revokes the key and deactivates its service account
+	Revoke(key *models.APIKey, at time.Time) error
+	TouchLastUsed(id, ip string, at time.Time) error
+	// WithContext returns the repository running its statements with ctx, inside the
+	// tenant it carries
+	WithContext(ctx context.Context) APIKeyRepository
+}
+
+type apiKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyRepository(db *gorm.DB) APIKeyRepository {
+	return &apiKeyRepository{db: db}
+}
+
+func (r *apiKeyRepository) WithContext(ctx context.Context) APIKeyRepository {
+	return &apiKeyRepository{db: r.db.WithContext(ctx)}
+}
+
+func (r *apiKeyRepository) FindAll() ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := r.db.Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+func (r *apiKeyRepository) FindByID(id string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := r.db.First(&key, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepository) FindByPrefix(prefix string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := r.db.First(&key, "prefix = ?", prefix).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepository) Create(key *models.APIKey, user *models.User) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		key.UserID = user.ID
+		return tx.Create(key).Error
+	})
+}
+
+func (r *apiKeyRepository) Update(key *models.APIKey) error {
+	return r.db.Save(key).Error
+}
+
+func (r *apiKeyRepository) Revoke(key *models.APIKey, at time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(key).Update("revoked_at", at).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).Where("id = ?", key.UserID).Update("active", false).Error
+	})
+}
+
+func (r *apiKeyRepository) TouchLastUsed(id, ip string, at time.Time) error {
+	return r.db.Model(&models.APIKey{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"last_used_at": at, "last_used_ip": ip}).Error
+}
@@ -0,0 +1,283 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// archiveChild is a table whose rows belong to a ticket and move with it
+type archiveChild struct {
+	table string
+	model func() interface{} // pointer to a zero row
+}
+
+// archiveChildren are archived in this order and restored in the same order
+var archiveChildren = []archiveChild{
+	{"ticket_technicians", func() interface{} { return &models.TicketTechnician{} }},
+	{"ticket_files", func() interface{} { return &models.TicketFile{} }},
+	{"ticket_comments", func() interface{} { return &models.TicketComment{} }},
+	{"ticket_events", func() interface{} { return &models.TicketEvent{} }},
+	{"ticket_sla_pauses", func() interface{} { return &models.TicketSLAPause{} }},
+	{"dispatch_decisions", func() interface{} { return &models.DispatchDecision{} }},
+	{"scheduling_links", func() interface{} { return &models.SchedulingLink{} }},
+	{"ticket_queue_assignments", func() interface{} { return &models.TicketQueueAssignment{} }},
+	{"ticket_budgets", func() interface{} { return &models.TicketBudget{} }},
+	{"ticket_budget_overrides", func() interface{} { return &models.TicketBudgetOverride{} }},
+}
+
+// archiveBlockers hold foreign keys to tickets and are business records of their own:
+// tickets they reference stay in the hot tables
+const archiveBlockers = `
+	NOT EXISTS (SELECT 1 FROM financial_entries fe WHERE fe.ticket_id = tickets.id)
+	AND NOT EXISTS (SELECT 1 FROM stock_rmas r WHERE r.ticket_id = tickets.id)
+	AND NOT EXISTS (SELECT 1 FROM ticket_complaints tc WHERE tc.ticket_id = tickets.id OR tc.original_ticket_id = tickets.id)
+	AND NOT EXISTS (SELECT 1 FROM ticket_cancellations c WHERE c.ticket_id = tickets.id)
+	AND NOT EXISTS (SELECT 1 FROM on_call_pages p WHERE p.ticket_id = tickets.id)
+	AND NOT EXISTS (SELECT 1 FROM ticket_feedback f WHERE f.ticket_id = tickets.id)`
+
+type ArchiveRepository interface {
+	FindArchivable(cutoff time.Time, limit int) ([]string, error)
+	CountBlocked(cutoff time.Time) (int64, error)
+	Archive(ticketID, actorID string) error
+	Restore(ticketID, actorID string) error
+	Search(page, size int, filters *models.ArchiveSearchFilters) ([]models.ArchivedTicket, int64, error)
+	FindByID(ticketID string) (*models.ArchivedTicket, error)
+	ExistsInHot(ticketID string) (bool, error)
+}
+
+type archiveRepository struct {
+	db      *gorm.DB
+	schemas sync.Map
+}
+
+func NewArchiveRepository(db *gorm.DB) ArchiveRepository {
+	return &archiveRepository{db: db}
+}
+
+// closedBefore scopes to the closed service tickets whose closing is older than the cutoff
+func (r *archiveRepository) closedBefore(cutoff time.Time) *gorm.DB {
+	return r.db.Model(&models.Ticket{}).
+		Where("status = ? AND type = ?", models.TicketStatusClosed, models.TicketTypeService).
+		Where("COALESCE(closed_at, updated_at) < ?", cutoff)
+}
+
+func (r *archiveRepository) FindArchivable(cutoff time.Time, limit int) ([]string, error) {
+	var ids []string
+	err := r.closedBefore(cutoff).
+		Where(archiveBlockers).
+		Order("COALESCE(closed_at, updated_at)").
+		Limit(limit).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+func (r *archiveRepository) CountBlocked(cutoff time.Time) (int64, error) {
+	var count int64
+	err := r.closedBefore(cutoff).Where("NOT (" + archiveBlockers + ")").Count(&count).Error
+	return count, err
+}
+
+// Archive moves the ticket and its child rows to the archive tables in one transaction
+func (r *archiveRepository) Archive(ticketID, actorID string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var ticket models.Ticket
+		if err := tx.First(&ticket, "id = ?", ticketID).Error; err != nil {
+			return err
+		}
+		data, err := r.encodeRow(&ticket)
+		if err != nil {
+			return err
+		}
+
+		archived := &models.ArchivedTicket{
+			TicketID:         ticket.ID,
+			OSNumber:         ticket.OSNumber,
+			Type:             ticket.Type,
+			Priority:         ticket.Priority,
+			ClientID:         ticket.ClientID,
+			CategoryID:       ticket.CategoryID,
+			SerialNumber:     ticket.SerialNumber,
+			ErrorDescription: ticket.ErrorDescription,
+			TicketCreatedAt:  ticket.CreatedAt,
+			ClosedAt:         ticket.ClosedAt,
+			ArchivedAt:       time.Now(),
+			ArchivedBy:       actorID,
+			Data:             data,
+		}
+		if archived.ClosedAt == nil {
+			archived.ClosedAt = &ticket.UpdatedAt
+		}
+		if ticket.ClientID != nil {
+			tx.Model(&models.Client{}).Where("id = ?", *ticket.ClientID).Pluck("full_name", &archived.ClientName)
+		}
+
+		for _, child := range archiveChildren {
+			rows := reflect.New(reflect.SliceOf(reflect.TypeOf(child.model()).Elem()))
+			if err := tx.Table(child.table).Where("ticket_id = ?", ticketID).Find(rows.Interface()).Error; err != nil {
+				return err
+			}
+			for i := 0; i < rows.Elem().Len(); i++ {
+				data, err := r.encodeRow(rows.Elem().Index(i).Addr().Interface())
+				if err != nil {
+					return err
+				}
+				archived.Records = append(archived.Records, models.ArchivedTicketRecord{SourceTable: child.table, Data: data})
+			}
+			if err := tx.Unscoped().Where("ticket_id = ?", ticketID).Delete(child.model()).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Unscoped().Delete(&models.Ticket{}, "id = ?", ticketID).Error; err != nil {
+			return err
+		}
+		return tx.Create(archived).Error
+	})
+}
+
+// Restore moves an archived ticket back to the hot tables, child rows included, and notes
+// it on the ticket timeline
+func (r *archiveRepository) Restore(ticketID, actorID string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var archived models.ArchivedTicket
+		err := tx.Preload("Records", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+			First(&archived, "ticket_id = ?", ticketID).Error
+		if err != nil {
+			return err
+		}
+
+		var ticket models.Ticket
+		if err := r.decodeRow(archived.Data, &ticket); err != nil {
+			return err
+		}
+		if err := tx.Omit(clause.Associations).Create(&ticket).Error; err != nil {
+			return err
+		}
+
+		for _, child := range archiveChildren {
+			for _, record := range archived.Records {
+				if record.SourceTable != child.table {
+					continue
+				}
+				row := child.model()
+				if err := r.decodeRow(record.Data, row); err != nil {
+					return err
+				}
+				if err := tx.Table(child.table).Omit(clause.Associations).Create(row).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := tx.Select(clause.Associations).Delete(&archived).Error; err != nil {
+			return err
+		}
+		return tx.Create(models.NewTicketEvent(ticketID, models.TicketEventRestored, actorID, map[string]interface{}{
+			"archivedAt": archived.ArchivedAt,
+		})).Error
+	})
+}
+
+func (r *archiveRepository) Search(page, size int, filters *models.ArchiveSearchFilters) ([]models.ArchivedTicket, int64, error) {
+	var tickets []models.ArchivedTicket
+	var total int64
+
+	query := r.db.Model(&models.ArchivedTicket{})
+	if filters != nil {
+		if filters.Query != "" {
+			like := "%" + filters.Query + "%"
+			query = query.Where("os_number ILIKE ? OR client_name ILIKE ? OR serial_number ILIKE ? OR error_description ILIKE ?",
+				like, like, like, like)
+		}
+		if filters.ClientID != "" {
+			query = query.Where("client_id = ?", filters.ClientID)
+		}
+		if filters.From != nil {
+			query = query.Where("closed_at >= ?", *filters.From)
+		}
+		if filters.To != nil {
+			query = query.Where("closed_at < ?", *filters.To)
+		}
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Omit("data").
+		Order("closed_at DESC").
+		Offset(page * size).
+		Limit(size).
+		Find(&tickets).Error
+	return tickets, total, err
+}
+
+func (r *archiveRepository) FindByID(ticketID string) (*models.ArchivedTicket, error) {
+	var archived models.ArchivedTicket
+	err := r.db.Preload("Records", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		First(&archived, "ticket_id = ?", ticketID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &archived, nil
+}
+
+func (r *archiveRepository) ExistsInHot(ticketID string) (bool, error) {
+	var count int64
+	err := r.db.Unscoped().Model(&models.Ticket{}).Where("id = ?", ticketID).Count(&count).Error
+	return count > 0, err
+}
+
+// encodeRow serializes every column of a row, keyed by column name. Going through the
+// GORM schema instead of the JSON tags keeps the columns hidden from the API.
+func (r *archiveRepository) encodeRow(row interface{}) (string, error) {
+	sch, err := schema.Parse(row, &r.schemas, r.db.NamingStrategy)
+	if err != nil {
+		return "", err
+	}
+	value := reflect.ValueOf(row).Elem()
+	columns := make(map[string]json.RawMessage, len(sch.DBNames))
+	for _, name := range sch.DBNames {
+		field := sch.FieldsByDBName[name]
+		data, err := json.Marshal(field.ReflectValueOf(context.Background(), value).Interface())
+		if err != nil {
+			return "", fmt.Errorf("archive %s.%s: %w", sch.Table, name, err)
+		}
+		columns[name] = data
+	}
+	data, err := json.Marshal(columns)
+	return string(data), err
+}
+
+// decodeRow is the inverse of encodeRow; columns added after archiving keep their zero value
+func (r *archiveRepository) decodeRow(data string, row interface{}) error {
+	sch, err := schema.Parse(row, &r.schemas, r.db.NamingStrategy)
+	if err != nil {
+		return err
+	}
+	var columns map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(data), &columns); err != nil {
+		return err
+	}
+	value := reflect.ValueOf(row).Elem()
+	for name, raw := range columns {
+		field, ok := sch.FieldsByDBName[name]
+		if !ok {
+			continue // column dropped since archiving
+		}
+		target := field.ReflectValueOf(context.Background(), value)
+		if err := json.Unmarshal(raw, target.Addr().Interface()); err != nil {
+			return fmt.Errorf("restore %s.%s: %w", sch.Table, name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+type AttachmentRepository interface {
+	Create(file *models.TicketFile) error
+	FindByID(id string) (*models.TicketFile, error)
+	FindByTicket(ticketID string) ([]models.TicketFile, error)
+	Update(file *models.TicketFile) error
+	Delete(id string) error
+	Claim(id string) (bool, error)
+	ResetProcessing() error
+	// FindDue returns attachments waiting for processing or due for a retry
+	FindDue(now time.Time, maxAttempts, limit int) ([]models.TicketFile, error)
+}
+
+type attachmentRepository struct {
+	db *gorm.DB
+}
+
+func NewAttachmentRepository(db *gorm.DB) AttachmentRepository {
+	return &attachmentRepository{db: db}
+}
+
+func (r *attachmentRepository) Create(file *models.TicketFile) error {
+	return r.db.Create(file).Error
+}
+
+func (r *attachmentRepository) FindByID(id string) (*models.TicketFile, error) {
+	var file models.TicketFile
+	if err := r.db.Where("id = ?", id).First(&file).Error; err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+func (r *attachmentRepository) FindByTicket(ticketID string) ([]models.TicketFile, error) {
+	var files []models.TicketFile
+	err := r.db.Where("ticket_id = ?", ticketID).Order("created_at ASC").Find(&files).Error
+	return files, err
+}
+
+func (r *attachmentRepository) Update(file *models.TicketFile) error {
+	return r.db.Save(file).Error
+}
+
+func (r *attachmentRepository) Delete(id string) error {
+	return r.db.Delete(&models.TicketFile{}, "id = ?", id).Error
+}
+
+// Claim moves an attachment to PROCESSING, returning false if another worker got it first
+func (r *attachmentRepository) Claim(id string) (bool, error) {
+	result := r.db.Model(&models.TicketFile{}).
+		Where("id = ? AND processing_status IN ?", id, []string{string(models.AttachmentStatusPending), string(models.AttachmentStatusFailed)}).
+		Update("processing_status", models.AttachmentStatusProcessing)
+	return result.RowsAffected > 0, result.Error
+}
+
+// ResetProcessing requeues attachments left in PROCESSING by a previous run
+func (r *attachmentRepository) ResetProcessing() error {
+	return r.db.Model(&models.TicketFile{}).
+		Where("processing_status = ?", models.AttachmentStatusProcessing).
+		Update("processing_status", models.AttachmentStatusPending).Error
+}
+
+func (r *attachmentRepository) FindDue(now time.Time, maxAttempts, limit int) ([]models.TicketFile, error) {
+	var files []models.TicketFile
+	err := r.db.
+		Where("processing_status IN ?", []string{string(models.AttachmentStatusPending), string(models.AttachmentStatusFailed)}).
+		Where("attempts < ?", maxAttempts).
+		Where("next_attempt_at IS NULL OR next_attempt_at <= ?", now).
+		Order("created_at ASC").
+		Limit(limit).
+		Find(&files).Error
+	return files, err
+}
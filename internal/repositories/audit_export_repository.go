@@ -0,0 +1,58 @@
+package repositories
+
+import (
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+type AuditExportRepository interface {
+	FindBundles(page, size int) ([]models.AuditExportBundle, int64, error)
+	FindBundleByID(id string) (*models.AuditExportBundle, error)
+	// FindLatestBundle returns the bundle of the most recent period, nil when there is none
+	FindLatestBundle() (*models.AuditExportBundle, error)
+	CreateBundle(bundle *models.AuditExportBundle) error
+}
+
+type auditExportRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditExportRepository(db *gorm.DB) AuditExportRepository {
+	return &auditExportRepository{db: db}
+}
+
+func (r *auditExportRepository) FindBundles(page, size int) ([]models.AuditExportBundle, int64, error) {
+	var bundles []models.AuditExportBundle
+	var total int64
+
+	if err := r.db.Model(&models.AuditExportBundle{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := r.db.
+		Order("period_start DESC").
+		Offset(page * size).
+		Limit(size).
+		Find(&bundles).Error
+	return bundles, total, err
+}
+
+func (r *auditExportRepository) FindBundleByID(id string) (*models.AuditExportBundle, error) {
+	var bundle models.AuditExportBundle
+	if err := r.db.Where("id = ?", id).First(&bundle).Error; err != nil {
+		return nil, err
+	}
+	return &bundle, nil
+}
+
+func (r *auditExportRepository) FindLatestBundle() (*models.AuditExportBundle, error) {
+	var bundles []models.AuditExportBundle
+	err := r.db.Order("period_start DESC").Limit(1).Find(&bundles).Error
+	if err != nil || len(bundles) == 0 {
+		return nil, err
+	}
+	return &bundles[0], nil
+}
+
+func (r *auditExportRepository) CreateBundle(bundle *models.AuditExportBundle) error {
+	return r.db.Create(bundle).Error
+}
@@ -0,0 +1,114 @@
+package repositories
+
+import (
+	"strings"
+
+	"github.com/shigake/tech-iq-back/internal/database"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
+	"gorm.io/gorm"
+)
+
+// auditEventsSQL maps the three audit stores onto the AuditEvent columns. The stores keep
+// their own writers (the activity log is hash chained), so they are unified on read.
+const auditEventsSQL = `
+SELECT 'activity' AS source, a.id::text AS id, a.user_id::text AS user_id, UPPER(a.action) AS action,
+	COALESCE(a.resource, '') AS entity_type, COALESCE(a.resource_id, '') AS entity_id,
+	COALESCE(a.description, '') AS description, '' AS changes, COALESCE(a.ip_address, '') AS ip_address,
+	COALESCE(a.user_agent, '') AS user_agent, COALESCE(a.request_id, '') AS request_id, a.created_at
+FROM activity_logs a
+UNION ALL
+SELECT 'financial', f.id::text, f.performed_by::text, UPPER(f.action),
+	f.entity_type, f.entity_id::text, '', COALESCE(f.changes::text, ''),
+	COALESCE(f.ip_address, ''), COALESCE(f.user_agent, ''), '', f.performed_at
+FROM financial_audit_logs f
+UNION ALL
+SELECT 'access', x.id::text, COALESCE(x.user_id, ''), UPPER(x.action),
+	x.entity_type, x.entity_id::text, '', jsonb_build_object('old', x.old_value, 'new', x.new_value)::text,
+	'', '', '', x.created_at
+FROM access_audit_logs x`
+
+type AuditRepository interface {
+	FindAll(filter *models.AuditFilter, page, limit int) ([]models.AuditEvent, int64, error)
+	// FindByCursor returns the events past the cursor, newest first, as fetched by
+	// keysetScope (not counted); the event ID of the cursor is "source:id"
+	FindByCursor(filter *models.AuditFilter, cursor pagination.Cursor, limit int) ([]models.AuditEvent, error)
+	// FindForExport returns up to limit events, newest first
+	FindForExport(filter *models.AuditFilter, limit int) ([]models.AuditEvent, error)
+}
+
+type auditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+	return &auditRepository{db: db}
+}
+
+func (r *auditRepository) FindAll(filter *models.AuditFilter, page, limit int) ([]models.AuditEvent, int64, error) {
+	var total int64
+	if err := r.query(filter).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	events := []models.AuditEvent{}
+	err := r.query(filter).
+		Select("e.*, COALESCE(u.full_name, '') AS user_name").
+		Joins("LEFT JOIN users u ON u.id = e.user_id").
+		Order("e.created_at DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Scan(&events).Error
+	return events, total, err
+}
+
+func (r *auditRepository) FindByCursor(filter *models.AuditFilter, cursor pagination.Cursor, limit int) ([]models.AuditEvent, error) {
+	events := []models.AuditEvent{}
+	err := r.query(filter).
+		Select("e.*, COALESCE(u.full_name, '') AS user_name").
+		Joins("LEFT JOIN users u ON u.id = e.user_id").
+		Scopes(keysetScope(cursor, limit, true, "e.source || ':' || e.id", "e.created_at")).
+		Scan(&events).Error
+	return events, err
+}
+
+func (r *auditRepository) FindForExport(filter *models.AuditFilter, limit int) ([]models.AuditEvent, error) {
+	events := []models.AuditEvent{}
+	err := r.query(filter).
+		Scopes(database.Replica).
+		Select("e.*, COALESCE(u.full_name, '') AS user_name").
+		Joins("LEFT JOIN users u ON u.id = e.user_id").
+		Order("e.created_at DESC").
+		Limit(limit).
+		Scan(&events).Error
+	return events, err
+}
+
+func (r *auditRepository) query(filter *models.AuditFilter) *gorm.DB {
+	query := r.db.Table("(" + auditEventsSQL + ") AS e")
+	if filter == nil {
+		return query
+	}
+	if filter.Source != "" {
+		query = query.Where("e.source = ?", filter.Source)
+	}
+	if filter.EntityType != "" {
+		query = query.Where("LOWER(e.entity_type) = ?", strings.ToLower(filter.EntityType))
+	}
+	if filter.EntityID != "" {
+		query = query.Where("e.entity_id = ?", filter.EntityID)
+	}
+	if filter.UserID != "" {
+		query = query.Where("e.user_id = ?", filter.UserID)
+	}
+	if filter.Action != "" {
+		query = query.Where("e.action = ?", strings.ToUpper(filter.Action))
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("e.created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("e.created_at <= ?", filter.To)
+	}
+	return query
+}
@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// BatchDeleteConfig controls retention cleanups: rows are deleted BatchSize at a time
+// with a Sleep between batches, so a cleanup of millions of rows never holds long locks
+// or floods the replicas with one huge transaction.
+type BatchDeleteConfig struct {
+	BatchSize int
+	Sleep     time.Duration
+}
+
+var batchDelete = BatchDeleteConfig{BatchSize: 5000, Sleep: 100 * time.Millisecond}
+
+// SetBatchDeleteConfig changes the batch size and pause of every retention cleanup
+func SetBatchDeleteConfig(cfg BatchDeleteConfig) {
+	if cfg.BatchSize > 0 {
+		batchDelete.BatchSize = cfg.BatchSize
+	}
+	if cfg.Sleep >= 0 {
+		batchDelete.Sleep = cfg.Sleep
+	}
+}
+
+// deleteInBatches deletes the rows of model matching the condition in batches. Postgres
+// has no DELETE ... LIMIT, so each batch deletes the ids of a limited subquery.
+func deleteInBatches(db *gorm.DB, label string, model interface{}, query string, args ...interface{}) (int64, error) {
+	cfg := batchDelete
+	var total int64
+	for {
+		batch := db.Model(model).Select("id").Where(query, args...).Limit(cfg.BatchSize)
+		result := db.Where("id IN (?)", batch).Delete(model)
+		if result.Error != nil {
+			return total, result.Error
+		}
+		total += result.RowsAffected
+		if result.RowsAffected < int64(cfg.BatchSize) {
+			break
+		}
+		slog.Info("Cleanup in progress", "table", label, "deleted", total)
+		time.Sleep(cfg.Sleep)
+	}
+	if total > 0 {
+		slog.Info("Cleanup completed", "table", label, "deleted", total)
+	}
+	return total, nil
+}
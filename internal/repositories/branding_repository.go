@@ -0,0 +1,50 @@
+package repositories
+
+import (
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+type BrandingRepository interface {
+	FindAll() ([]models.NodeBranding, error)
+	FindByNodeID(nodeID uint) (*models.NodeBranding, error)
+	Save(branding *models.NodeBranding) error
+	Delete(nodeID uint) error
+}
+
+type brandingRepository struct {
+	db *gorm.DB
+}
+
+func NewBrandingRepository(db *gorm.DB) BrandingRepository {
+	return &brandingRepository{db: db}
+}
+
+func (r *brandingRepository) FindAll() ([]models.NodeBranding, error) {
+	var brandings []models.NodeBranding
+	err := r.db.Preload("Node").Order("node_id").Find(&brandings).Error
+	return brandings, err
+}
+
+func (r *brandingRepository) FindByNodeID(nodeID uint) (*models.NodeBranding, error) {
+	var branding models.NodeBranding
+	if err := r.db.Preload("Node").First(&branding, "node_id = ?", nodeID).Error; err != nil {
+		return nil, err
+	}
+	return &branding, nil
+}
+
+func (r *brandingRepository) Save(branding *models.NodeBranding) error {
+	return r.db.Omit("Node").Save(branding).Error
+}
+
+func (r *brandingRepository) Delete(nodeID uint) error {
+	result := r.db.Delete(&models.NodeBranding{}, "node_id = ?", nodeID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
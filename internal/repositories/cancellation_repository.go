@@ -0,0 +1,148 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CancellationRepository interface {
+	FindReasons(includeInactive bool) ([]models.CancellationReason, error)
+	FindReasonByID(id string) (*models.CancellationReason, error)
+	ExistsReasonCode(code string) (bool, error)
+	CreateReason(reason *models.CancellationReason) error
+	UpdateReason(reason *models.CancellationReason) error
+	Cancel(cancellation *models.TicketCancellation) error
+	CountByReason(from, to time.Time) ([]models.CancellationCount, error)
+	CountByRegion(from, to time.Time) ([]models.CancellationCount, error)
+	CountByPeriod(from, to time.Time, period string) ([]models.CancellationCount, error)
+}
+
+type cancellationRepository struct {
+	db *gorm.DB
+}
+
+func NewCancellationRepository(db *gorm.DB) CancellationRepository {
+	return &cancellationRepository{db: db}
+}
+
+func (r *cancellationRepository) FindReasons(includeInactive bool) ([]models.CancellationReason, error) {
+	var reasons []models.CancellationReason
+	query := r.db.Order("sort_order, name")
+	if !includeInactive {
+		query = query.Where("active = ?", true)
+	}
+	err := query.Find(&reasons).Error
+	return reasons, err
+}
+
+func (r *cancellationRepository) FindReasonByID(id string) (*models.CancellationReason, error) {
+	var reason models.CancellationReason
+	if err := r.db.First(&reason, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &reason, nil
+}
+
+func (r *cancellationRepository) ExistsReasonCode(code string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.CancellationReason{}).Where("code = ?", code).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *cancellationRepository) CreateReason(reason *models.CancellationReason) error {
+	return r.db.Create(reason).Error
+}
+
+func (r *cancellationRepository) UpdateReason(reason *models.CancellationReason) error {
+	return r.db.Save(reason).Error
+}
+
+// Cancel moves the ticket to CANCELADO, stores the reason and records the transition on the
+// ticket timeline, all in one transaction
+func (r *cancellationRepository) Cancel(cancellation *models.TicketCancellation) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var ticket models.Ticket
+		if err := tx.Select("id, status").First(&ticket, "id = ?", cancellation.TicketID).Error; err != nil {
+			return err
+		}
+		cancellation.PreviousStatus = ticket.Status
+
+		if err := tx.Model(&models.Ticket{}).Where("id = ?", ticket.ID).
+			Update("status", models.TicketStatusCancelled).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Omit("Reason").Create(cancellation).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(models.NewTicketEvent(ticket.ID, models.TicketEventStatusChanged, cancellation.CancelledBy, map[string]string{
+			"from": string(ticket.Status),
+			"to":   string(models.TicketStatusCancelled),
+		})).Error; err != nil {
+			return err
+		}
+		if models.IsWaitingStatus(ticket.Status) {
+			if err := resumeSLA(tx, ticket.ID, cancellation.CancelledBy, cancellation.Notes); err != nil {
+				return err
+			}
+		}
+		// Parts reserved for the ticket go back to their locations
+		if err := tx.Model(&models.StockReservation{}).
+			Where("ticket_id = ? AND status = ?", ticket.ID, models.ReservationStatusActive).
+			Updates(map[string]interface{}{
+				"status":         models.ReservationStatusReleased,
+				"release_reason": "ticket cancelled",
+				"released_at":    cancellation.CancelledAt,
+			}).Error; err != nil {
+			return err
+		}
+		return tx.Create(models.NewTicketEvent(ticket.ID, models.TicketEventCancelled, cancellation.CancelledBy, map[string]string{
+			"reasonId": cancellation.ReasonID,
+			"notes":    cancellation.Notes,
+		})).Error
+	})
+}
+
+// cancelledBetween scopes to tickets still cancelled whose cancellation happened in [from, to)
+func (r *cancellationRepository) cancelledBetween(from, to time.Time) *gorm.DB {
+	return r.db.Table("ticket_cancellations tc").
+		Joins("JOIN tickets t ON t.id = tc.ticket_id AND t.deleted_at IS NULL").
+		Where("t.status = ?", models.TicketStatusCancelled).
+		Where("tc.cancelled_at >= ? AND tc.cancelled_at < ?", from, to)
+}
+
+func (r *cancellationRepository) CountByReason(from, to time.Time) ([]models.CancellationCount, error) {
+	var rows []models.CancellationCount
+	err := r.cancelledBetween(from, to).
+		Select("cr.code AS key, cr.name AS label, COUNT(*) AS count").
+		Joins("JOIN cancellation_reasons cr ON cr.id = tc.reason_id").
+		Group("cr.code, cr.name").
+		Order("count DESC").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// CountByRegion groups by the state of the ticket client
+func (r *cancellationRepository) CountByRegion(from, to time.Time) ([]models.CancellationCount, error) {
+	var rows []models.CancellationCount
+	err := r.cancelledBetween(from, to).
+		Select("COALESCE(NULLIF(c.state, ''), '') AS key, COUNT(*) AS count").
+		Joins("LEFT JOIN clients c ON c.id = t.client_id").
+		Group("1").
+		Order("count DESC").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// CountByPeriod groups by day, week or month of the cancellation, oldest first
+func (r *cancellationRepository) CountByPeriod(from, to time.Time, period string) ([]models.CancellationCount, error) {
+	var rows []models.CancellationCount
+	err := r.cancelledBetween(from, to).
+		Select("TO_CHAR(DATE_TRUNC(?, tc.cancelled_at), 'YYYY-MM-DD') AS key, COUNT(*) AS count", period).
+		Group("1").
+		Order("1").
+		Scan(&rows).Error
+	return rows, err
+}
@@ -1,6 +1,7 @@
 package repositories
 
 import (
+	"context"
 	"github.com/shigake/tech-iq-back/internal/models"
 	"gorm.io/gorm"
 )
@@ -15,6 +16,9 @@ type CategoryRepository interface {
 	Delete(id string) error
 	GetByName(name string) (*models.Category, error)
 	GetByNameAndType(name string, categoryType models.CategoryType) (*models.Category, error)
+	// WithContext returns the repository running its statements with ctx, inside the
+	// tenant it carries
+	WithContext(ctx context.Context) CategoryRepository
 }
 
 type categoryRepository struct {
@@ -25,6 +29,10 @@ func NewCategoryRepository(db *gorm.DB) CategoryRepository {
 	return &categoryRepository{db: db}
 }
 
+func (r *categoryRepository) WithContext(ctx context.Context) CategoryRepository {
+	return &categoryRepository{db: r.db.WithContext(ctx)}
+}
+
 func (r *categoryRepository) Create(category *models.Category) error {
 	return r.db.Create(category).Error
 }
@@ -0,0 +1,103 @@
+package repositories
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+type ChatChannelRepository interface {
+	FindAll() ([]models.ChatChannel, error)
+	FindActive() ([]models.ChatChannel, error)
+	FindByID(id string) (*models.ChatChannel, error)
+	Create(channel *models.ChatChannel) error
+	Update(channel *models.ChatChannel) error
+	Delete(id string) error
+
+	// TicketNodePath returns the path of the node of the ticket, "" when it has none
+	TicketNodePath(ticketID string) (string, error)
+	// DigestCounts counts the alerts per type, only those of the tickets under nodePath
+	// when given
+	DigestCounts(since time.Time, nodePath string) ([]models.AlertDigestRow, error)
+}
+
+type chatChannelRepository struct {
+	db *gorm.DB
+}
+
+func NewChatChannelRepository(db *gorm.DB) ChatChannelRepository {
+	return &chatChannelRepository{db: db}
+}
+
+func (r *chatChannelRepository) FindAll() ([]models.ChatChannel, error) {
+	var channels []models.ChatChannel
+	err := r.db.Preload("Node").Order("name").Find(&channels).Error
+	return channels, err
+}
+
+func (r *chatChannelRepository) FindActive() ([]models.ChatChannel, error) {
+	var channels []models.ChatChannel
+	err := r.db.Preload("Node").Where("active = ?", true).Find(&channels).Error
+	return channels, err
+}
+
+func (r *chatChannelRepository) FindByID(id string) (*models.ChatChannel, error) {
+	var channel models.ChatChannel
+	if err := r.db.Preload("Node").First(&channel, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &channel, nil
+}
+
+func (r *chatChannelRepository) Create(channel *models.ChatChannel) error {
+	return r.db.Omit("Node").Create(channel).Error
+}
+
+func (r *chatChannelRepository) Update(channel *models.ChatChannel) error {
+	return r.db.Omit("Node").Save(channel).Error
+}
+
+func (r *chatChannelRepository) Delete(id string) error {
+	result := r.db.Delete(&models.ChatChannel{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *chatChannelRepository) TicketNodePath(ticketID string) (string, error) {
+	var paths []string
+	err := r.db.Table("tickets").
+		Select("nodes.path").
+		Joins("JOIN nodes ON nodes.id = tickets.node_id").
+		Where("tickets.id::text = ?", ticketID).
+		Pluck("nodes.path", &paths).Error
+	if err != nil || len(paths) == 0 {
+		return "", err
+	}
+	return paths[0], nil
+}
+
+func (r *chatChannelRepository) DigestCounts(since time.Time, nodePath string) ([]models.AlertDigestRow, error) {
+	var rows []models.AlertDigestRow
+	query := r.db.Model(&models.Alert{}).
+		Select("alerts.type AS type, "+
+			"COUNT(*) FILTER (WHERE alerts.created_at >= ?) AS raised, "+
+			"COUNT(*) FILTER (WHERE alerts.status <> ?) AS active, "+
+			"COUNT(*) FILTER (WHERE alerts.status <> ? AND alerts.severity = ?) AS critical",
+			since, models.AlertStatusResolved, models.AlertStatusResolved, models.AlertSeverityCritical).
+		Where("alerts.created_at >= ? OR alerts.status <> ?", since, models.AlertStatusResolved)
+	if nodePath != "" {
+		query = query.
+			Joins("JOIN tickets ON tickets.id::text = alerts.resource_id").
+			Joins("JOIN nodes ON nodes.id = tickets.node_id").
+			Where("alerts.resource_type = ? AND (nodes.path = ? OR nodes.path LIKE ?)", "TICKET", nodePath, fmt.Sprintf("%s.%%", nodePath))
+	}
+	err := query.Group("alerts.type").Order("alerts.type").Scan(&rows).Error
+	return rows, err
+}
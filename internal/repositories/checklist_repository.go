@@ -0,0 +1,138 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+type ChecklistRepository interface {
+	// WithContext returns the repository running its statements with ctx, inside the tenant it carries
+	WithContext(ctx context.Context) ChecklistRepository
+
+	// Templates, with their items in order
+	FindTemplates() ([]models.ChecklistTemplate, error)
+	FindTemplateByCategory(categoryID string) (*models.ChecklistTemplate, error)
+	// SaveTemplate saves the template and swaps its items in one transaction; items keep
+	// their IDs when given
+	SaveTemplate(template *models.ChecklistTemplate) error
+	DeleteTemplate(template *models.ChecklistTemplate) error
+
+	// Ticket checklists
+	FindTicketItems(ticketID string) ([]models.TicketChecklistItem, error)
+	FindTicketItem(ticketID, itemID string) (*models.TicketChecklistItem, error)
+	// SyncTicketItems creates, updates and deletes the items of a ticket checklist in one
+	// transaction
+	SyncTicketItems(create, update []models.TicketChecklistItem, deleteIDs []string) error
+	UpdateTicketItem(item *models.TicketChecklistItem) error
+}
+
+type checklistRepository struct {
+	db *gorm.DB
+}
+
+func NewChecklistRepository(db *gorm.DB) ChecklistRepository {
+	return &checklistRepository{db: db}
+}
+
+func (r *checklistRepository) WithContext(ctx context.Context) ChecklistRepository {
+	return &checklistRepository{db: r.db.WithContext(ctx)}
+}
+
+func orderedChecklistItems(db *gorm.DB) *gorm.DB {
+	return db.Order("sort_order, created_at")
+}
+
+func (r *checklistRepository) FindTemplates() ([]models.ChecklistTemplate, error) {
+	var templates []models.ChecklistTemplate
+	err := r.db.Preload("Items", orderedChecklistItems).Order("name").Find(&templates).Error
+	return templates, err
+}
+
+func (r *checklistRepository) FindTemplateByCategory(categoryID string) (*models.ChecklistTemplate, error) {
+	var template models.ChecklistTemplate
+	err := r.db.Preload("Items", orderedChecklistItems).Where("category_id = ?", categoryID).First(&template).Error
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+func (r *checklistRepository) SaveTemplate(template *models.ChecklistTemplate) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		items := template.Items
+		if err := tx.Omit("Items").Save(template).Error; err != nil {
+			return err
+		}
+
+		keep := make([]string, 0, len(items))
+		for i := range items {
+			items[i].TemplateID = template.ID
+			if items[i].ID != "" {
+				keep = append(keep, items[i].ID)
+			}
+		}
+		dropped := tx.Where("template_id = ?", template.ID)
+		if len(keep) > 0 {
+			dropped = dropped.Where("id NOT IN ?", keep)
+		}
+		if err := dropped.Delete(&models.ChecklistTemplateItem{}).Error; err != nil {
+			return err
+		}
+		for i := range items {
+			if err := tx.Save(&items[i]).Error; err != nil {
+				return err
+			}
+		}
+		template.Items = items
+		return nil
+	})
+}
+
+func (r *checklistRepository) DeleteTemplate(template *models.ChecklistTemplate) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("template_id = ?", template.ID).Delete(&models.ChecklistTemplateItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(template).Error
+	})
+}
+
+func (r *checklistRepository) FindTicketItems(ticketID string) ([]models.TicketChecklistItem, error) {
+	var items []models.TicketChecklistItem
+	err := orderedChecklistItems(r.db).Where("ticket_id = ?", ticketID).Find(&items).Error
+	return items, err
+}
+
+func (r *checklistRepository) FindTicketItem(ticketID, itemID string) (*models.TicketChecklistItem, error) {
+	var item models.TicketChecklistItem
+	err := r.db.Where("id = ? AND ticket_id = ?", itemID, ticketID).First(&item).Error
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (r *checklistRepository) SyncTicketItems(create, update []models.TicketChecklistItem, deleteIDs []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if len(deleteIDs) > 0 {
+			if err := tx.Where("id IN ?", deleteIDs).Delete(&models.TicketChecklistItem{}).Error; err != nil {
+				return err
+			}
+		}
+		for i := range update {
+			if err := tx.Save(&update[i]).Error; err != nil {
+				return err
+			}
+		}
+		if len(create) > 0 {
+			return tx.Create(&create).Error
+		}
+		return nil
+	})
+}
+
+func (r *checklistRepository) UpdateTicketItem(item *models.TicketChecklistItem) error {
+	return r.db.Save(item).Error
+}
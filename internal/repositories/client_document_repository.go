@@ -0,0 +1,196 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ClientDocumentRepository interface {
+	// Categories
+	FindCategories(activeOnly bool) ([]models.ClientDocumentCategory, error)
+	FindCategoryByID(id uint) (*models.ClientDocumentCategory, error)
+	SaveCategory(category *models.ClientDocumentCategory) error
+
+	// Documents
+	FindDocuments(page, size int, filters *models.ClientDocumentFilters, now time.Time) ([]models.ClientDocument, int64, error)
+	FindDocumentByID(id string) (*models.ClientDocument, error)
+	CreateDocument(document *models.ClientDocument) error
+	UpdateDocument(document *models.ClientDocument) error
+	// DeleteDocument removes the document with its download links
+	DeleteDocument(id string) error
+	// FindDueReminders returns the documents inside the reminder window of their category
+	// that have not been reminded yet
+	FindDueReminders(now time.Time) ([]models.ClientDocument, error)
+	MarkReminded(ids []string, at time.Time) error
+
+	// Links
+	FindLinks(documentID string) ([]models.ClientDocumentLink, error)
+	FindLinkByID(id string) (*models.ClientDocumentLink, error)
+	FindLinkByToken(token string) (*models.ClientDocumentLink, error)
+	CreateLink(link *models.ClientDocumentLink) error
+	RevokeLink(id string, at time.Time) error
+	// UseLink counts a download, false when the link is revoked, expired or used up
+	UseLink(id string, now time.Time) (bool, error)
+}
+
+type clientDocumentRepository struct {
+	db *gorm.DB
+}
+
+func NewClientDocumentRepository(db *gorm.DB) ClientDocumentRepository {
+	return &clientDocumentRepository{db: db}
+}
+
+func (r *clientDocumentRepository) FindCategories(activeOnly bool) ([]models.ClientDocumentCategory, error) {
+	var categories []models.ClientDocumentCategory
+	query := r.db.Order("name ASC")
+	if activeOnly {
+		query = query.Where("active = ?", true)
+	}
+	err := query.Find(&categories).Error
+	return categories, err
+}
+
+func (r *clientDocumentRepository) FindCategoryByID(id uint) (*models.ClientDocumentCategory, error) {
+	var category models.ClientDocumentCategory
+	if err := r.db.First(&category, id).Error; err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+func (r *clientDocumentRepository) SaveCategory(category *models.ClientDocumentCategory) error {
+	return r.db.Save(category).Error
+}
+
+func (r *clientDocumentRepository) FindDocuments(page, size int, filters *models.ClientDocumentFilters, now time.Time) ([]models.ClientDocument, int64, error) {
+	var documents []models.ClientDocument
+	var total int64
+
+	today := now.Format("2006-01-02")
+	query := r.db.Model(&models.ClientDocument{}).
+		Joins("JOIN client_document_categories c ON c.id = client_documents.category_id")
+	if filters != nil {
+		if filters.ClientID != "" {
+			query = query.Where("client_documents.client_id = ?", filters.ClientID)
+		}
+		if filters.CategoryID != 0 {
+			query = query.Where("client_documents.category_id = ?", filters.CategoryID)
+		}
+		if filters.Role != "" && filters.Role != "ADMIN" {
+			query = query.Where("? = ANY(string_to_array(c.download_roles, ','))", filters.Role)
+		}
+		switch filters.Status {
+		case models.DocumentStatusExpired:
+			query = query.Where("client_documents.expires_at < ?", today)
+		case models.DocumentStatusExpiring:
+			query = query.Where("client_documents.expires_at >= ? AND client_documents.expires_at <= CAST(? AS date) + c.reminder_days", today, today)
+		case models.DocumentStatusValid:
+			query = query.Where("client_documents.expires_at IS NULL OR client_documents.expires_at > CAST(? AS date) + c.reminder_days", today)
+		}
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.
+		Preload("Client").
+		Preload("Category").
+		Order("client_documents.expires_at ASC NULLS LAST, client_documents.created_at DESC").
+		Offset(page * size).
+		Limit(size).
+		Find(&documents).Error
+	return documents, total, err
+}
+
+func (r *clientDocumentRepository) FindDocumentByID(id string) (*models.ClientDocument, error) {
+	var document models.ClientDocument
+	if err := r.db.Preload("Client").Preload("Category").Where("id = ?", id).First(&document).Error; err != nil {
+		return nil, err
+	}
+	return &document, nil
+}
+
+func (r *clientDocumentRepository) CreateDocument(document *models.ClientDocument) error {
+	return r.db.Omit(clause.Associations).Create(document).Error
+}
+
+func (r *clientDocumentRepository) UpdateDocument(document *models.ClientDocument) error {
+	return r.db.Omit(clause.Associations).Save(document).Error
+}
+
+func (r *clientDocumentRepository) DeleteDocument(id string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("document_id = ?", id).Delete(&models.ClientDocumentLink{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", id).Delete(&models.ClientDocument{}).Error
+	})
+}
+
+func (r *clientDocumentRepository) FindDueReminders(now time.Time) ([]models.ClientDocument, error) {
+	var documents []models.ClientDocument
+	err := r.db.
+		Joins("JOIN client_document_categories c ON c.id = client_documents.category_id").
+		Preload("Client").
+		Preload("Category").
+		Where("client_documents.expires_at IS NOT NULL AND client_documents.reminder_sent_at IS NULL").
+		Where("client_documents.expires_at <= CAST(? AS date) + c.reminder_days", now.Format("2006-01-02")).
+		Order("client_documents.expires_at ASC").
+		Find(&documents).Error
+	return documents, err
+}
+
+func (r *clientDocumentRepository) MarkReminded(ids []string, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.Model(&models.ClientDocument{}).Where("id IN ?", ids).Update("reminder_sent_at", at).Error
+}
+
+func (r *clientDocumentRepository) FindLinks(documentID string) ([]models.ClientDocumentLink, error) {
+	var links []models.ClientDocumentLink
+	err := r.db.Where("document_id = ?", documentID).Order("created_at DESC").Find(&links).Error
+	return links, err
+}
+
+func (r *clientDocumentRepository) FindLinkByID(id string) (*models.ClientDocumentLink, error) {
+	var link models.ClientDocumentLink
+	if err := r.db.Where("id = ?", id).First(&link).Error; err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+func (r *clientDocumentRepository) FindLinkByToken(token string) (*models.ClientDocumentLink, error) {
+	var link models.ClientDocumentLink
+	if err := r.db.Preload("Document").Where("token = ?", token).First(&link).Error; err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+func (r *clientDocumentRepository) CreateLink(link *models.ClientDocumentLink) error {
+	return r.db.Omit(clause.Associations).Create(link).Error
+}
+
+func (r *clientDocumentRepository) RevokeLink(id string, at time.Time) error {
+	return r.db.Model(&models.ClientDocumentLink{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", at).Error
+}
+
+func (r *clientDocumentRepository) UseLink(id string, now time.Time) (bool, error) {
+	result := r.db.Model(&models.ClientDocumentLink{}).
+		Where("id = ? AND revoked_at IS NULL AND expires_at > ?", id, now).
+		Where("max_downloads = 0 OR downloads < max_downloads").
+		Updates(map[string]interface{}{
+			"downloads":        gorm.Expr("downloads + 1"),
+			"last_download_at": now,
+		})
+	return result.RowsAffected == 1, result.Error
+}
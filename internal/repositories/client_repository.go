@@ -1,6 +1,7 @@
 package repositories
 
 import (
+	"context"
 	"github.com/shigake/tech-iq-back/internal/models"
 	"gorm.io/gorm"
 )
@@ -9,11 +10,19 @@ type ClientRepository interface {
 	Create(client *models.Client) error
 	GetByID(id string) (*models.Client, error)
 	GetAll(page, size int) ([]models.Client, int64, error)
+	// Update saves the client if it is still at its Version and bumps it (ErrVersionConflict otherwise)
 	Update(client *models.Client) error
 	Delete(id string) error
 	GetByDocument(cpf, cnpj string) (*models.Client, error)
 	Search(query string, page, size int) ([]models.Client, int64, error)
+	// Filter lists the clients with the tag and in the segment, matching query when given
+	Filter(query string, segment models.ClientSegmentFilter, page, size int) ([]models.Client, int64, error)
 	Count() (int64, error)
+	// WithScope returns the repository restricted to the hierarchy scope of a user
+	WithScope(scope *models.AccessScope) ClientRepository
+	// WithContext returns the repository running its statements with ctx, inside the
+	// tenant it carries
+	WithContext(ctx context.Context) ClientRepository
 }
 
 type clientRepository struct {
@@ -24,6 +33,10 @@ func NewClientRepository(db *gorm.DB) ClientRepository {
 	return &clientRepository{db: db}
 }
 
+func (r *clientRepository) WithContext(ctx context.Context) ClientRepository {
+	return &clientRepository{db: r.db.WithContext(ctx)}
+}
+
 func (r *clientRepository) Create(client *models.Client) error {
 	return r.db.Create(client).Error
 }
@@ -36,6 +49,13 @@ func (r *clientRepository) GetByID(id string) (*models.Client, error) {
 	return &client, nil
 }
 
+func (r *clientRepository) WithScope(scope *models.AccessScope) ClientRepository {
+	if !scope.Restricted() {
+		return r
+	}
+	return &clientRepository{db: scopedDB(r.db, scope, "clients.node_id")}
+}
+
 func (r *clientRepository) GetAll(page, size int) ([]models.Client, int64, error) {
 	var clients []models.Client
 	var total int64
@@ -51,7 +71,7 @@ func (r *clientRepository) GetAll(page, size int) ([]models.Client, int64, error
 }
 
 func (r *clientRepository) Update(client *models.Client) error {
-	return r.db.Save(client).Error
+	return updateVersioned(r.db, client, &client.Version)
 }
 
 func (r *clientRepository) Delete(id string) error {
@@ -93,6 +113,31 @@ func (r *clientRepository) Search(query string, page, size int) ([]models.Client
 	return clients, total, nil
 }
 
+func (r *clientRepository) Filter(query string, segment models.ClientSegmentFilter, page, size int) ([]models.Client, int64, error) {
+	var clients []models.Client
+	var total int64
+
+	baseQuery := r.db.Model(&models.Client{}).Scopes(clientSegmentScope("id", segment))
+	if query != "" {
+		searchQuery := "%" + query + "%"
+		baseQuery = baseQuery.Where(
+			"(full_name ILIKE ? OR cpf ILIKE ? OR cnpj ILIKE ? OR email ILIKE ?)",
+			searchQuery, searchQuery, searchQuery, searchQuery,
+		)
+	}
+
+	if err := baseQuery.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := page * size
+	if err := baseQuery.Offset(offset).Limit(size).Order("id DESC").Find(&clients).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return clients, total, nil
+}
+
 func (r *clientRepository) Count() (int64, error) {
 	var count int64
 	err := r.db.Model(&models.Client{}).Count(&count).Error
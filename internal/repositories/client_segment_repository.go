@@ -0,0 +1,214 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+type ClientSegmentRepository interface {
+	// Tags
+	FindTags() ([]models.ClientTag, error)
+	FindTagByID(id string) (*models.ClientTag, error)
+	FindTagByName(name string) (*models.ClientTag, error)
+	CountTags(ids []string) (int64, error)
+	CreateTag(tag *models.ClientTag) error
+	UpdateTag(tag *models.ClientTag) error
+	DeleteTag(id string) error
+	FindClientTags(clientID string) ([]models.ClientTag, error)
+	SetClientTags(clientID string, tagIDs []string) error
+
+	// Computed segments
+	FindClientSegments(clientID string) ([]models.ClientSegment, error)
+	SegmentMetrics(revenueSince, ticketsSince time.Time) ([]models.ClientSegmentMetrics, error)
+	ReplaceSegments(segments []models.ClientSegment) error
+	CountSegments() ([]models.ClientSegmentCount, *time.Time, error)
+}
+
+type clientSegmentRepository struct {
+	db *gorm.DB
+}
+
+func NewClientSegmentRepository(db *gorm.DB) ClientSegmentRepository {
+	return &clientSegmentRepository{db: db}
+}
+
+// clientSegmentScope keeps the rows whose client (the column holding the client id) has the
+// tag and is in the segment of the filter
+func clientSegmentScope(column string, filter models.ClientSegmentFilter) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if filter.TagID != "" {
+			db = db.Where(column+" IN (SELECT client_id FROM client_tag_assignments WHERE tag_id = ?)", filter.TagID)
+		}
+		if filter.Segment != "" {
+			dimension, value := filter.SegmentParts()
+			db = db.Where(column+" IN (SELECT client_id FROM client_segments WHERE dimension = ? AND value = ?)", dimension, value)
+		}
+		return db
+	}
+}
+
+func (r *clientSegmentRepository) FindTags() ([]models.ClientTag, error) {
+	var tags []models.ClientTag
+	if err := r.db.Order("name").Find(&tags).Error; err != nil {
+		return nil, err
+	}
+
+	var counts []struct {
+		TagID   string
+		Clients int64
+	}
+	err := r.db.Model(&models.ClientTagAssignment{}).
+		Select("tag_id, COUNT(*) AS clients").
+		Group("tag_id").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+	byTag := make(map[string]int64, len(counts))
+	for _, count := range counts {
+		byTag[count.TagID] = count.Clients
+	}
+	for i := range tags {
+		tags[i].Clients = byTag[tags[i].ID]
+	}
+	return tags, nil
+}
+
+func (r *clientSegmentRepository) FindTagByID(id string) (*models.ClientTag, error) {
+	var tag models.ClientTag
+	if err := r.db.First(&tag, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+func (r *clientSegmentRepository) FindTagByName(name string) (*models.ClientTag, error) {
+	var tag models.ClientTag
+	if err := r.db.First(&tag, "LOWER(name) = LOWER(?)", name).Error; err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+func (r *clientSegmentRepository) CountTags(ids []string) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.ClientTag{}).Where("id IN ?", ids).Count(&count).Error
+	return count, err
+}
+
+func (r *clientSegmentRepository) CreateTag(tag *models.ClientTag) error {
+	return r.db.Create(tag).Error
+}
+
+func (r *clientSegmentRepository) UpdateTag(tag *models.ClientTag) error {
+	return r.db.Save(tag).Error
+}
+
+// DeleteTag removes the tag from its clients as well
+func (r *clientSegmentRepository) DeleteTag(id string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("tag_id = ?", id).Delete(&models.ClientTagAssignment{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.ClientTag{}, "id = ?", id).Error
+	})
+}
+
+func (r *clientSegmentRepository) FindClientTags(clientID string) ([]models.ClientTag, error) {
+	var tags []models.ClientTag
+	err := r.db.Joins("JOIN client_tag_assignments a ON a.tag_id = client_tags.id").
+		Where("a.client_id = ?", clientID).
+		Order("client_tags.name").
+		Find(&tags).Error
+	return tags, err
+}
+
+func (r *clientSegmentRepository) SetClientTags(clientID string, tagIDs []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("client_id = ?", clientID).Delete(&models.ClientTagAssignment{}).Error; err != nil {
+			return err
+		}
+		if len(tagIDs) == 0 {
+			return nil
+		}
+		assignments := make([]models.ClientTagAssignment, 0, len(tagIDs))
+		for _, tagID := range tagIDs {
+			assignments = append(assignments, models.ClientTagAssignment{ClientID: clientID, TagID: tagID})
+		}
+		return tx.Create(&assignments).Error
+	})
+}
+
+func (r *clientSegmentRepository) FindClientSegments(clientID string) ([]models.ClientSegment, error) {
+	var segments []models.ClientSegment
+	err := r.db.Where("client_id = ?", clientID).Order("dimension").Find(&segments).Error
+	return segments, err
+}
+
+// SegmentMetrics computes, for every client, the income since revenueSince, the tickets
+// opened since ticketsSince, the last ticket ever and the score of the last NPS answer
+func (r *clientSegmentRepository) SegmentMetrics(revenueSince, ticketsSince time.Time) ([]models.ClientSegmentMetrics, error) {
+	var metrics []models.ClientSegmentMetrics
+	err := r.db.Raw(`
+		SELECT c.id AS client_id,
+			COALESCE(rev.total, 0) AS revenue,
+			COALESCE(tk.recent, 0) AS tickets,
+			tk.last_ticket_at,
+			nps.score AS last_nps_score
+		FROM clients c
+		LEFT JOIN (
+			SELECT client_id, SUM(amount) AS total
+			FROM financial_entries
+			WHERE type = ? AND status <> ? AND entry_date >= ? AND deleted_at IS NULL
+			GROUP BY client_id
+		) rev ON rev.client_id = c.id
+		LEFT JOIN (
+			SELECT client_id, COUNT(*) FILTER (WHERE created_at >= ?) AS recent, MAX(created_at) AS last_ticket_at
+			FROM tickets
+			WHERE deleted_at IS NULL
+			GROUP BY client_id
+		) tk ON tk.client_id = c.id
+		LEFT JOIN LATERAL (
+			SELECT score FROM nps_invitations
+			WHERE client_id = c.id AND responded_at IS NOT NULL
+			ORDER BY responded_at DESC
+			LIMIT 1
+		) nps ON true
+		WHERE c.deleted_at IS NULL`,
+		models.FinancialEntryTypeIncome, models.FinancialEntryStatusCancelled, revenueSince, ticketsSince,
+	).Scan(&metrics).Error
+	return metrics, err
+}
+
+// ReplaceSegments swaps the whole membership table for the freshly computed one
+func (r *clientSegmentRepository) ReplaceSegments(segments []models.ClientSegment) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&models.ClientSegment{}).Error; err != nil {
+			return err
+		}
+		if len(segments) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(segments, 500).Error
+	})
+}
+
+func (r *clientSegmentRepository) CountSegments() ([]models.ClientSegmentCount, *time.Time, error) {
+	var counts []models.ClientSegmentCount
+	err := r.db.Model(&models.ClientSegment{}).
+		Select("dimension, value, COUNT(*) AS clients").
+		Group("dimension, value").
+		Order("dimension, value").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var computedAt struct{ ComputedAt *time.Time }
+	if err := r.db.Model(&models.ClientSegment{}).Select("MAX(computed_at) AS computed_at").Scan(&computedAt).Error; err != nil {
+		return nil, nil, err
+	}
+	return counts, computedAt.ComputedAt, nil
+}
@@ -0,0 +1,134 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+type ComplaintRepository interface {
+	Create(ticket *models.Ticket, complaint *models.TicketComplaint, actorID string) error
+	FindByTicketID(ticketID string) (*models.TicketComplaint, error)
+	Update(complaint *models.TicketComplaint) error
+	FindAll(page, size int, filters *models.ComplaintFilters) ([]models.Ticket, int64, error)
+	FindCreatedBetween(from, to time.Time) ([]models.TicketComplaint, error)
+	CountClosedTicketsByTechnician(from, to time.Time) (map[string]int64, error)
+}
+
+type complaintRepository struct {
+	db *gorm.DB
+}
+
+func NewComplaintRepository(db *gorm.DB) ComplaintRepository {
+	return &complaintRepository{db: db}
+}
+
+// Create opens the complaint ticket with its ombudsman data and records it on the original ticket timeline
+func (r *complaintRepository) Create(ticket *models.Ticket, complaint *models.TicketComplaint, actorID string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Complaint").Create(ticket).Error; err != nil {
+			return err
+		}
+		complaint.TicketID = ticket.ID
+		if err := tx.Create(complaint).Error; err != nil {
+			return err
+		}
+		return tx.Create(models.NewTicketEvent(complaint.OriginalTicketID, models.TicketEventComplaint, actorID, map[string]string{
+			"complaintTicketId": ticket.ID,
+			"osNumber":          ticket.OSNumber,
+		})).Error
+	})
+}
+
+func (r *complaintRepository) FindByTicketID(ticketID string) (*models.TicketComplaint, error) {
+	var complaint models.TicketComplaint
+	err := r.db.
+		Preload("OriginalTicket").
+		Preload("Technician").
+		First(&complaint, "ticket_id = ?", ticketID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &complaint, nil
+}
+
+func (r *complaintRepository) Update(complaint *models.TicketComplaint) error {
+	return r.db.Omit("OriginalTicket", "Technician").Save(complaint).Error
+}
+
+func (r *complaintRepository) FindAll(page, size int, filters *models.ComplaintFilters) ([]models.Ticket, int64, error) {
+	var tickets []models.Ticket
+	var total int64
+
+	query := r.db.Model(&models.Ticket{}).
+		Joins("JOIN ticket_complaints tc ON tc.ticket_id = tickets.id").
+		Where("tickets.type = ?", models.TicketTypeComplaint)
+	if filters != nil {
+		switch filters.Status {
+		case "open":
+			query = query.Where("tickets.status <> ?", models.TicketStatusClosed)
+		case "closed":
+			query = query.Where("tickets.status = ?", models.TicketStatusClosed)
+		}
+		if filters.TechnicianID != "" {
+			query = query.Where("tc.technician_id = ?", filters.TechnicianID)
+		}
+		if filters.RootCause != "" {
+			query = query.Where("tc.root_cause = ?", filters.RootCause)
+		}
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.
+		Preload("Client").
+		Preload("Category").
+		Preload("Complaint.OriginalTicket").
+		Preload("Complaint.Technician").
+		Order("tc.sla_due_at ASC").
+		Offset(page * size).
+		Limit(size).
+		Find(&tickets).Error
+	return tickets, total, err
+}
+
+// FindCreatedBetween returns the complaints opened in [from, to) with the original ticket category
+func (r *complaintRepository) FindCreatedBetween(from, to time.Time) ([]models.TicketComplaint, error) {
+	var complaints []models.TicketComplaint
+	err := r.db.
+		Preload("OriginalTicket.Category").
+		Preload("Technician").
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Where("ticket_id IN (?)", r.db.Model(&models.Ticket{}).Select("id")).
+		Find(&complaints).Error
+	return complaints, err
+}
+
+// CountClosedTicketsByTechnician counts the service tickets each technician closed in [from, to)
+func (r *complaintRepository) CountClosedTicketsByTechnician(from, to time.Time) (map[string]int64, error) {
+	var rows []struct {
+		TechnicianID string
+		Total        int64
+	}
+	err := r.db.Table("tickets").
+		Select("tt.technician_id, COUNT(DISTINCT tickets.id) AS total").
+		Joins("JOIN ticket_technicians tt ON tt.ticket_id = tickets.id").
+		Where("tickets.deleted_at IS NULL").
+		Where("tickets.type <> ?", models.TicketTypeComplaint).
+		Where("tickets.status = ?", models.TicketStatusClosed).
+		Where("COALESCE(tickets.closed_at, tickets.updated_at) >= ? AND COALESCE(tickets.closed_at, tickets.updated_at) < ?", from, to).
+		Group("tt.technician_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.TechnicianID] = row.Total
+	}
+	return counts, nil
+}
@@ -0,0 +1,43 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+// ComplianceRepository reads the tracked expirations of the other modules
+type ComplianceRepository interface {
+	// FindClientDocumentExpirations returns the client documents expiring up to the date,
+	// with the node of the latest ticket of the client as the responsible node
+	FindClientDocumentExpirations(until time.Time) ([]models.ComplianceExpiration, error)
+}
+
+type complianceRepository struct {
+	db *gorm.DB
+}
+
+func NewComplianceRepository(db *gorm.DB) ComplianceRepository {
+	return &complianceRepository{db: db}
+}
+
+func (r *complianceRepository) FindClientDocumentExpirations(until time.Time) ([]models.ComplianceExpiration, error) {
+	var rows []models.ComplianceExpiration
+	err := r.db.Table("client_documents d").
+		Select(`? AS source, d.id AS entity_id, d.title, cat.name AS category,
+			d.client_id AS owner_id, c.full_name AS owner_name, d.expires_at, tn.node_id, tn.node_name`,
+			models.ExpirySourceClientDocument).
+		Joins("JOIN client_document_categories cat ON cat.id = d.category_id").
+		Joins("JOIN clients c ON c.id = d.client_id AND c.deleted_at IS NULL").
+		Joins(`LEFT JOIN LATERAL (
+			SELECT t.node_id, n.name AS node_name FROM tickets t
+			JOIN nodes n ON n.id = t.node_id
+			WHERE t.client_id = d.client_id AND t.deleted_at IS NULL
+			ORDER BY t.created_at DESC LIMIT 1
+		) tn ON true`).
+		Where("d.expires_at IS NOT NULL AND d.expires_at <= CAST(? AS date)", until.Format("2006-01-02")).
+		Order("d.expires_at ASC").
+		Scan(&rows).Error
+	return rows, err
+}
@@ -0,0 +1,69 @@
+package repositories
+
+import (
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+type CoverageRepository interface {
+	FindAll(filters *models.CoverageAreaFilters) ([]models.CoverageArea, error)
+	FindByID(id string) (*models.CoverageArea, error)
+	Create(area *models.CoverageArea) error
+	Update(area *models.CoverageArea) error
+	Delete(id string) error
+}
+
+type coverageRepository struct {
+	db *gorm.DB
+}
+
+func NewCoverageRepository(db *gorm.DB) CoverageRepository {
+	return &coverageRepository{db: db}
+}
+
+func (r *coverageRepository) FindAll(filters *models.CoverageAreaFilters) ([]models.CoverageArea, error) {
+	var areas []models.CoverageArea
+
+	query := r.db.Model(&models.CoverageArea{})
+	if filters != nil {
+		if filters.OwnerType != "" {
+			query = query.Where("owner_type = ?", filters.OwnerType)
+		}
+		if filters.OwnerID != "" {
+			query = query.Where("owner_id = ?", filters.OwnerID)
+		}
+		if !filters.Inactive {
+			query = query.Where("active = ?", true)
+		}
+	}
+
+	err := query.Order("owner_type, name").Find(&areas).Error
+	return areas, err
+}
+
+func (r *coverageRepository) FindByID(id string) (*models.CoverageArea, error) {
+	var area models.CoverageArea
+	if err := r.db.First(&area, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &area, nil
+}
+
+func (r *coverageRepository) Create(area *models.CoverageArea) error {
+	return r.db.Create(area).Error
+}
+
+func (r *coverageRepository) Update(area *models.CoverageArea) error {
+	return r.db.Save(area).Error
+}
+
+func (r *coverageRepository) Delete(id string) error {
+	result := r.db.Delete(&models.CoverageArea{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type DiscountRepository interface {
+	FindLimits() ([]models.DiscountLimit, error)
+	SaveLimit(limit *models.DiscountLimit) error
+	Create(discount *models.Discount) error
+	FindByID(id string) (*models.Discount, error)
+	Decide(discount *models.Discount) (bool, error)
+	FindAll(page, size int, filters *models.DiscountFilters) ([]models.Discount, int64, error)
+	SummarizeByUser(from, to time.Time) ([]models.UserDiscountSummary, error)
+}
+
+type discountRepository struct {
+	db *gorm.DB
+}
+
+func NewDiscountRepository(db *gorm.DB) DiscountRepository {
+	return &discountRepository{db: db}
+}
+
+func (r *discountRepository) FindLimits() ([]models.DiscountLimit, error) {
+	var limits []models.DiscountLimit
+	err := r.db.Order("role").Find(&limits).Error
+	return limits, err
+}
+
+func (r *discountRepository) SaveLimit(limit *models.DiscountLimit) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "role"}},
+		DoUpdates: clause.AssignmentColumns([]string{"max_percent", "updated_by", "updated_at"}),
+	}).Create(limit).Error
+}
+
+func (r *discountRepository) Create(discount *models.Discount) error {
+	return r.db.Omit("Requester", "Decider").Create(discount).Error
+}
+
+func (r *discountRepository) FindByID(id string) (*models.Discount, error) {
+	var discount models.Discount
+	err := r.db.
+		Preload("Requester").
+		Preload("Decider").
+		First(&discount, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &discount, nil
+}
+
+// Decide records the decision only while the discount is still pending; false means
+// someone else decided it first
+func (r *discountRepository) Decide(discount *models.Discount) (bool, error) {
+	result := r.db.Model(&models.Discount{}).
+		Where("id = ? AND status = ?", discount.ID, models.DiscountStatusPending).
+		Updates(map[string]interface{}{
+			"status":         discount.Status,
+			"decided_by":     discount.DecidedBy,
+			"decided_at":     discount.DecidedAt,
+			"decision_notes": discount.DecisionNotes,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *discountRepository) FindAll(page, size int, filters *models.DiscountFilters) ([]models.Discount, int64, error) {
+	var discounts []models.Discount
+	var total int64
+
+	query := r.db.Model(&models.Discount{})
+	if filters != nil {
+		if filters.Status != "" {
+			query = query.Where("status = ?", filters.Status)
+		}
+		if filters.RequestedBy != "" {
+			query = query.Where("requested_by = ?", filters.RequestedBy)
+		}
+		if filters.DocumentType != "" {
+			query = query.Where("document_type = ?", filters.DocumentType)
+		}
+		if filters.DocumentRef != "" {
+			query = query.Where("document_ref = ?", filters.DocumentRef)
+		}
+		if filters.From != nil {
+			query = query.Where("created_at >= ?", *filters.From)
+		}
+		if filters.To != nil {
+			query = query.Where("created_at < ?", *filters.To)
+		}
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.
+		Preload("Requester").
+		Preload("Decider").
+		Order("created_at DESC").
+		Offset(page * size).
+		Limit(size).
+		Find(&discounts).Error
+	return discounts, total, err
+}
+
+// SummarizeByUser aggregates the discounts requested in [from, to) per requester,
+// largest applied discount amount first
+func (r *discountRepository) SummarizeByUser(from, to time.Time) ([]models.UserDiscountSummary, error) {
+	var rows []models.UserDiscountSummary
+	err := r.db.Table("discounts d").
+		Select(`d.requested_by AS user_id,
+			COALESCE(u.full_name, '') AS user_name,
+			MAX(d.requester_role) AS role,
+			COUNT(*) FILTER (WHERE d.status = ?) AS applied,
+			COUNT(*) FILTER (WHERE d.status = ?) AS pending,
+			COUNT(*) FILTER (WHERE d.status = ?) AS rejected,
+			COUNT(*) FILTER (WHERE d.status = ? AND d.percent > d.limit_percent) AS over_limit,
+			COALESCE(SUM(d.gross_amount) FILTER (WHERE d.status = ?), 0) AS gross_amount,
+			COALESCE(SUM(d.amount) FILTER (WHERE d.status = ?), 0) AS discount_amount,
+			COALESCE(MAX(d.percent) FILTER (WHERE d.status = ?), 0) AS max_percent`,
+			models.DiscountStatusApproved, models.DiscountStatusPending, models.DiscountStatusRejected,
+			models.DiscountStatusApproved, models.DiscountStatusApproved, models.DiscountStatusApproved,
+			models.DiscountStatusApproved).
+		Joins("LEFT JOIN users u ON u.id = d.requested_by").
+		Where("d.created_at >= ? AND d.created_at < ?", from, to).
+		Group("d.requested_by, u.full_name").
+		Order("discount_amount DESC").
+		Scan(&rows).Error
+	return rows, err
+}
@@ -0,0 +1,208 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+type DispatchRepository interface {
+	// WithContext returns the repository running its statements with ctx, inside the tenant it carries
+	WithContext(ctx context.Context) DispatchRepository
+	// Rules
+	ListRules() ([]models.DispatchRule, error)
+	FindRuleByID(id string) (*models.DispatchRule, error)
+	CreateRule(rule *models.DispatchRule) error
+	UpdateRule(rule *models.DispatchRule) error
+	DeleteRule(id string) error
+
+	// Decisions (audit)
+	CreateDecision(decision *models.DispatchDecision) error
+	FindDecisions(filter *models.DispatchDecisionFilter) ([]models.DispatchDecision, int64, error)
+
+	// Tickets
+	FindTicket(id string) (*models.Ticket, error)
+	FindUndispatchedTickets(since time.Time) ([]models.Ticket, error)
+	FindManualQueue() ([]models.Ticket, error)
+	UpdateDispatchStatus(ticketID string, status models.DispatchStatus) error
+	CountOpenTicketsByTechnician() (map[string]int, error)
+
+	// Simulation
+	FindTicketsCreatedBetween(from, to time.Time) ([]models.Ticket, error)
+	CountOpenTicketsByTechnicianAt(at time.Time) (map[string]int, error)
+}
+
+type dispatchRepository struct {
+	db *gorm.DB
+}
+
+func NewDispatchRepository(db *gorm.DB) DispatchRepository {
+	return &dispatchRepository{db: db}
+}
+
+func (r *dispatchRepository) WithContext(ctx context.Context) DispatchRepository {
+	return &dispatchRepository{db: r.db.WithContext(ctx)}
+}
+
+func (r *dispatchRepository) ListRules() ([]models.DispatchRule, error) {
+	var rules []models.DispatchRule
+	err := r.db.Order("sort_order ASC, created_at ASC").Find(&rules).Error
+	return rules, err
+}
+
+func (r *dispatchRepository) FindRuleByID(id string) (*models.DispatchRule, error) {
+	var rule models.DispatchRule
+	if err := r.db.Where("id = ?", id).First(&rule).Error; err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (r *dispatchRepository) CreateRule(rule *models.DispatchRule) error {
+	return r.db.Create(rule).Error
+}
+
+func (r *dispatchRepository) UpdateRule(rule *models.DispatchRule) error {
+	return r.db.Save(rule).Error
+}
+
+func (r *dispatchRepository) DeleteRule(id string) error {
+	return r.db.Delete(&models.DispatchRule{}, "id = ?", id).Error
+}
+
+func (r *dispatchRepository) CreateDecision(decision *models.DispatchDecision) error {
+	return r.db.Create(decision).Error
+}
+
+func (r *dispatchRepository) FindDecisions(filter *models.DispatchDecisionFilter) ([]models.DispatchDecision, int64, error) {
+	var decisions []models.DispatchDecision
+	var total int64
+
+	query := r.db.Model(&models.DispatchDecision{})
+	if filter.TicketID != "" {
+		query = query.Where("ticket_id = ?", filter.TicketID)
+	}
+	if filter.Outcome != "" {
+		query = query.Where("outcome = ?", filter.Outcome)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (filter.Page - 1) * filter.PageSize
+	err := query.
+		Preload("Ticket").
+		Preload("Rule").
+		Preload("Technician").
+		Order("created_at DESC").
+		Offset(offset).
+		Limit(filter.PageSize).
+		Find(&decisions).Error
+	return decisions, total, err
+}
+
+// FindUndispatchedTickets returns open tickets created after since that have
+// no technician yet and were not moved out of the auto-dispatch flow
+func (r *dispatchRepository) FindUndispatchedTickets(since time.Time) ([]models.Ticket, error) {
+	var tickets []models.Ticket
+	err := r.db.
+		Preload("Client").
+		Preload("Category").
+		Where("status = ?", models.TicketStatusOpen).
+		Where("type <> ?", models.TicketTypeComplaint). // complaints are handled by the ombudsman, not dispatched
+		Where("created_at >= ?", since).
+		Where("dispatch_status IS NULL OR dispatch_status IN ?", []string{"", string(models.DispatchStatusPending)}).
+		Where("id NOT IN (SELECT ticket_id FROM ticket_technicians)").
+		Order("created_at ASC").
+		Find(&tickets).Error
+	return tickets, err
+}
+
+// FindTicket loads a ticket with what the scorer needs: client, category and current technicians
+func (r *dispatchRepository) FindTicket(id string) (*models.Ticket, error) {
+	var ticket models.Ticket
+	err := r.db.
+		Preload("Client").
+		Preload("Category").
+		Preload("Technicians").
+		First(&ticket, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &ticket, nil
+}
+
+// FindManualQueue returns tickets the dispatcher handed over to the manual queue
+// that are still waiting for a technician
+func (r *dispatchRepository) FindManualQueue() ([]models.Ticket, error) {
+	var tickets []models.Ticket
+	err := r.db.
+		Preload("Client").
+		Preload("Category").
+		Where("dispatch_status = ?", models.DispatchStatusManualQueue).
+		Where("status = ?", models.TicketStatusOpen).
+		Where("id NOT IN (SELECT ticket_id FROM ticket_technicians)").
+		Order("created_at ASC").
+		Find(&tickets).Error
+	return tickets, err
+}
+
+func (r *dispatchRepository) UpdateDispatchStatus(ticketID string, status models.DispatchStatus) error {
+	return r.db.Model(&models.Ticket{}).Where("id = ?", ticketID).Update("dispatch_status", status).Error
+}
+
+// CountOpenTicketsByTechnician returns how many unfinished tickets each technician holds
+func (r *dispatchRepository) CountOpenTicketsByTechnician() (map[string]int, error) {
+	query := r.db.Table("ticket_technicians").
+		Joins("JOIN tickets ON tickets.id = ticket_technicians.ticket_id").
+		Where("tickets.deleted_at IS NULL").
+		Where("tickets.status NOT IN ?", []string{string(models.TicketStatusClosed), string(models.TicketStatusUnproductive), string(models.TicketStatusCancelled)})
+	return countByTechnician(query)
+}
+
+// FindTicketsCreatedBetween returns the tickets opened in [from, to) with the
+// data needed to replay their dispatch
+func (r *dispatchRepository) FindTicketsCreatedBetween(from, to time.Time) ([]models.Ticket, error) {
+	var tickets []models.Ticket
+	err := r.db.
+		Preload("Client").
+		Preload("Category").
+		Preload("Assignments").
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Order("created_at ASC").
+		Find(&tickets).Error
+	return tickets, err
+}
+
+// CountOpenTicketsByTechnicianAt returns how many tickets each technician held at a point in time
+func (r *dispatchRepository) CountOpenTicketsByTechnicianAt(at time.Time) (map[string]int, error) {
+	query := r.db.Table("ticket_technicians").
+		Joins("JOIN tickets ON tickets.id = ticket_technicians.ticket_id").
+		Where("tickets.deleted_at IS NULL").
+		Where("tickets.created_at < ?", at).
+		Where("tickets.closed_at IS NULL OR tickets.closed_at > ?", at)
+	return countByTechnician(query)
+}
+
+func countByTechnician(query *gorm.DB) (map[string]int, error) {
+	var rows []struct {
+		TechnicianID string
+		Count        int
+	}
+	err := query.
+		Select("ticket_technicians.technician_id, COUNT(*) as count").
+		Group("ticket_technicians.technician_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int, len(rows))
+	for _, row := range rows {
+		counts[row.TechnicianID] = row.Count
+	}
+	return counts, nil
+}
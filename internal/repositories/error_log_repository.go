@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
 
 	"gorm.io/gorm"
 )
@@ -55,6 +56,9 @@ func (r *ErrorLogRepository) GetAll(page, pageSize int, filter *models.ErrorLogF
 		if filter.UserID != "" {
 			query = query.Where("user_id = ?", filter.UserID)
 		}
+		if filter.RequestID != "" {
+			query = query.Where("request_id = ?", filter.RequestID)
+		}
 		if filter.StatusCode > 0 {
 			query = query.Where("status_code = ?", filter.StatusCode)
 		}
@@ -97,6 +101,7 @@ func (r *ErrorLogRepository) GetAll(page, pageSize int, filter *models.ErrorLogF
 		Page:       page,
 		PageSize:   pageSize,
 		TotalPages: totalPages,
+		HasNext:    pagination.HasNext(offset, pageSize, total),
 	}, nil
 }
 
@@ -204,8 +209,7 @@ func (r *ErrorLogRepository) Delete(id string) error {
 // DeleteOld deletes error logs older than the specified duration
 func (r *ErrorLogRepository) DeleteOld(olderThan time.Duration) (int64, error) {
 	cutoff := time.Now().Add(-olderThan)
-	result := r.db.Where("timestamp < ? AND resolved = ?", cutoff, true).Delete(&models.ErrorLog{})
-	return result.RowsAffected, result.Error
+	return deleteInBatches(r.db, "error_logs", &models.ErrorLog{}, "timestamp < ? AND resolved = ?", cutoff, true)
 }
 
 // GetRecentByFeature returns recent errors for a specific feature
@@ -1,11 +1,14 @@
 package repositories
 
 import (
+	"context"
 	"encoding/json"
 	"time"
 
+	"github.com/shigake/tech-iq-back/internal/database"
 	"github.com/shigake/tech-iq-back/internal/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type FinancialRepository struct {
@@ -16,6 +19,11 @@ func NewFinancialRepository(db *gorm.DB) *FinancialRepository {
 	return &FinancialRepository{db: db}
 }
 
+// WithContext returns the repository running its statements with ctx, inside the tenant it carries
+func (r *FinancialRepository) WithContext(ctx context.Context) *FinancialRepository {
+	return &FinancialRepository{db: r.db.WithContext(ctx)}
+}
+
 // =============== Financial Entries ===============
 
 // CreateEntry creates a new financial entry
@@ -23,12 +31,69 @@ func (r *FinancialRepository) CreateEntry(entry *models.FinancialEntry) error {
 	return r.db.Create(entry).Error
 }
 
+// CreateTicketIncome creates the closing income of a ticket and marks the parts it bills
+// in one transaction. Nothing is written (false) when the ticket already has a live one.
+func (r *FinancialRepository) CreateTicketIncome(entry *models.FinancialEntry, partIDs []string) (bool, error) {
+	created := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// idx_financial_entries_ticket_income turns a second income into a no-op
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(entry)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		created = true
+		if len(partIDs) == 0 {
+			return nil
+		}
+		return tx.Model(&models.TicketPart{}).Where("id IN ?", partIDs).Update("billed_entry_id", entry.ID).Error
+	})
+	return created, err
+}
+
+// CreateTicketPayouts creates the technician payments of a ticket in one transaction.
+// The ticket row is locked while its open or paid payments are counted, and nothing is
+// created (false) when it already has some.
+func (r *FinancialRepository) CreateTicketPayouts(ticketID string, entries []models.FinancialEntry) (bool, error) {
+	created := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var ticket models.Ticket
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").First(&ticket, "id = ?", ticketID).Error; err != nil {
+			return err
+		}
+
+		var existing int64
+		if err := tx.Model(&models.FinancialEntry{}).
+			Where("ticket_id = ? AND category = ?", ticketID, "technician_payment").
+			Where("status <> ?", models.FinancialEntryStatusCancelled).
+			Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return nil
+		}
+
+		if len(entries) > 0 {
+			if err := tx.Create(&entries).Error; err != nil {
+				return err
+			}
+		}
+		created = true
+		return nil
+	})
+	return created, err
+}
+
 // GetEntryByID retrieves a financial entry by ID
 func (r *FinancialRepository) GetEntryByID(id string) (*models.FinancialEntry, error) {
 	var entry models.FinancialEntry
 	err := r.db.Preload("Ticket").
 		Preload("Technician").
 		Preload("Client").
+		Preload("Supplier").
 		Preload("CreatedByUser").
 		First(&entry, "id = ?", id).Error
 	if err != nil {
@@ -54,6 +119,7 @@ func (r *FinancialRepository) UpdateEntry(entry *models.FinancialEntry) error {
 			"ticket_id":         entry.TicketID,
 			"technician_id":     entry.TechnicianID,
 			"client_id":         entry.ClientID,
+			"supplier_id":       entry.SupplierID,
 			"payment_method":    entry.PaymentMethod,
 			"payment_reference": entry.PaymentReference,
 			"attachment_urls":   entry.AttachmentURLs,
@@ -93,6 +159,51 @@ func (r *FinancialRepository) ListEntries(filter models.FinancialEntryFilter) ([
 	var entries []models.FinancialEntry
 	var total int64
 
+	query := r.entriesQuery(filter)
+
+	// Count total
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// Pagination
+	if filter.Limit <= 0 {
+		filter.Limit = 20
+	}
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+	offset := (filter.Page - 1) * filter.Limit
+
+	// Fetch entries
+	err := query.
+		Preload("Technician").
+		Preload("Client").
+		Preload("Supplier").
+		Order("entry_date DESC, created_at DESC").
+		Offset(offset).
+		Limit(filter.Limit).
+		Find(&entries).Error
+
+	return entries, total, err
+}
+
+// ListEntriesByCursor returns the entries of the filter past filter.Cursor in the order
+// of ListEntries, as fetched by keysetScope (not counted)
+func (r *FinancialRepository) ListEntriesByCursor(filter models.FinancialEntryFilter) ([]models.FinancialEntry, error) {
+	var entries []models.FinancialEntry
+	err := r.entriesQuery(filter).
+		Preload("Technician").
+		Preload("Client").
+		Preload("Supplier").
+		Scopes(keysetScope(*filter.Cursor, filter.Limit, true, "financial_entries.id::text",
+			"financial_entries.entry_date", "financial_entries.created_at")).
+		Find(&entries).Error
+	return entries, err
+}
+
+// entriesQuery returns the entries matching the filter
+func (r *FinancialRepository) entriesQuery(filter models.FinancialEntryFilter) *gorm.DB {
 	query := r.db.Model(&models.FinancialEntry{})
 
 	// Apply filters
@@ -122,31 +233,13 @@ func (r *FinancialRepository) ListEntries(filter models.FinancialEntryFilter) ([
 	if filter.TicketID != "" {
 		query = query.Where("ticket_id = ?", filter.TicketID)
 	}
-
-	// Count total
-	if err := query.Count(&total).Error; err != nil {
-		return nil, 0, err
-	}
-
-	// Pagination
-	if filter.Limit <= 0 {
-		filter.Limit = 20
+	if filter.SupplierID != "" {
+		query = query.Where("supplier_id = ?", filter.SupplierID)
 	}
-	if filter.Page <= 0 {
-		filter.Page = 1
+	if filter.RecurringEntryID != "" {
+		query = query.Where("recurring_entry_id = ?", filter.RecurringEntryID)
 	}
-	offset := (filter.Page - 1) * filter.Limit
-
-	// Fetch entries
-	err := query.
-		Preload("Technician").
-		Preload("Client").
-		Order("entry_date DESC, created_at DESC").
-		Offset(offset).
-		Limit(filter.Limit).
-		Find(&entries).Error
-
-	return entries, total, err
+	return query.Scopes(clientSegmentScope("client_id", filter.Clients))
 }
 
 // GetEntriesByIDs retrieves multiple entries by their IDs
@@ -176,6 +269,97 @@ func (r *FinancialRepository) MarkOverdueEntries() (int64, error) {
 	return result.RowsAffected, result.Error
 }
 
+// =============== Recurring Entries ===============
+
+// CreateRecurringEntry creates a new recurring entry
+func (r *FinancialRepository) CreateRecurringEntry(recurring *models.RecurringEntry) error {
+	return r.db.Omit("Technician", "Client").Create(recurring).Error
+}
+
+// GetRecurringEntryByID retrieves a recurring entry by ID
+func (r *FinancialRepository) GetRecurringEntryByID(id string) (*models.RecurringEntry, error) {
+	var recurring models.RecurringEntry
+	err := r.db.Preload("Technician").
+		Preload("Client").
+		First(&recurring, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &recurring, nil
+}
+
+// UpdateRecurringEntry saves a recurring entry
+func (r *FinancialRepository) UpdateRecurringEntry(recurring *models.RecurringEntry) error {
+	return r.db.Omit("Technician", "Client").Save(recurring).Error
+}
+
+// DeleteRecurringEntry soft-deletes a recurring entry; the entries it generated are kept
+func (r *FinancialRepository) DeleteRecurringEntry(id string) error {
+	return r.db.Delete(&models.RecurringEntry{}, "id = ?", id).Error
+}
+
+// ListRecurringEntries retrieves recurring entries with filters, next occurrence first
+func (r *FinancialRepository) ListRecurringEntries(filter models.RecurringEntryFilter) ([]models.RecurringEntry, int64, error) {
+	var recurring []models.RecurringEntry
+	var total int64
+
+	query := r.db.Model(&models.RecurringEntry{})
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = 20
+	}
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+	offset := (filter.Page - 1) * filter.Limit
+
+	err := query.
+		Preload("Technician").
+		Preload("Client").
+		Order("next_date ASC, created_at ASC").
+		Offset(offset).
+		Limit(filter.Limit).
+		Find(&recurring).Error
+
+	return recurring, total, err
+}
+
+// GetActiveRecurringEntries retrieves the active recurring entries with an occurrence
+// on or before the given date
+func (r *FinancialRepository) GetActiveRecurringEntries(until time.Time) ([]models.RecurringEntry, error) {
+	var recurring []models.RecurringEntry
+	err := r.db.Where("status = ? AND next_date <= ?", models.RecurringEntryStatusActive, until).
+		Order("next_date ASC").
+		Find(&recurring).Error
+	return recurring, err
+}
+
+// MaterializeOccurrence creates the entry of an occurrence and moves the recurring entry
+// to its next occurrence in one transaction. An occurrence already generated is skipped
+// (false) but the recurring entry still moves on.
+func (r *FinancialRepository) MaterializeOccurrence(recurring *models.RecurringEntry, entry *models.FinancialEntry) (bool, error) {
+	created := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(entry)
+		if result.Error != nil {
+			return result.Error
+		}
+		created = result.RowsAffected > 0
+		return tx.Omit("Technician", "Client").Save(recurring).Error
+	})
+	return created, err
+}
+
 // =============== Payment Batches ===============
 
 // CreateBatch creates a new payment batch
@@ -360,10 +544,115 @@ func (r *FinancialRepository) PayBatch(batchID string, paymentReference string)
 	})
 }
 
+// =============== Budgets ===============
+
+// CreateBudget creates a budget
+func (r *FinancialRepository) CreateBudget(budget *models.Budget) error {
+	return r.db.Create(budget).Error
+}
+
+// GetBudgetByID retrieves a budget by ID
+func (r *FinancialRepository) GetBudgetByID(id string) (*models.Budget, error) {
+	var budget models.Budget
+	if err := r.db.First(&budget, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &budget, nil
+}
+
+// FindBudget retrieves the budget of a category in a month
+func (r *FinancialRepository) FindBudget(entryType models.FinancialEntryType, category, month string) (*models.Budget, error) {
+	var budget models.Budget
+	err := r.db.Where("type = ? AND category = ? AND month = ?", entryType, category, month).First(&budget).Error
+	if err != nil {
+		return nil, err
+	}
+	return &budget, nil
+}
+
+// UpdateBudget saves a budget
+func (r *FinancialRepository) UpdateBudget(budget *models.Budget) error {
+	return r.db.Save(budget).Error
+}
+
+// DeleteBudget deletes a budget
+func (r *FinancialRepository) DeleteBudget(id string) error {
+	return r.db.Delete(&models.Budget{}, "id = ?", id).Error
+}
+
+// ListBudgets retrieves budgets with filters, by month then category
+func (r *FinancialRepository) ListBudgets(filter models.BudgetFilter) ([]models.Budget, int64, error) {
+	var budgets []models.Budget
+	var total int64
+
+	query := r.db.Model(&models.Budget{})
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.Category != "" {
+		query = query.Where("category = ?", filter.Category)
+	}
+	// Months are YYYY-MM, so they compare as strings
+	if filter.FromMonth != "" {
+		query = query.Where("month >= ?", filter.FromMonth)
+	}
+	if filter.ToMonth != "" {
+		query = query.Where("month <= ?", filter.ToMonth)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = 20
+	}
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+	offset := (filter.Page - 1) * filter.Limit
+
+	err := query.
+		Order("month ASC, type ASC, category ASC").
+		Offset(offset).
+		Limit(filter.Limit).
+		Find(&budgets).Error
+
+	return budgets, total, err
+}
+
+// GetBudgetsInRange retrieves every budget of the months between start and end (YYYY-MM)
+func (r *FinancialRepository) GetBudgetsInRange(startMonth, endMonth string, entryType models.FinancialEntryType) ([]models.Budget, error) {
+	var budgets []models.Budget
+	query := r.db.Where("month BETWEEN ? AND ?", startMonth, endMonth)
+	if entryType != "" {
+		query = query.Where("type = ?", entryType)
+	}
+	err := query.Order("month ASC, type ASC, category ASC").Find(&budgets).Error
+	return budgets, err
+}
+
+// GetActualsByCategoryMonth sums the entries that were not cancelled per month, type and
+// category, between the dates
+func (r *FinancialRepository) GetActualsByCategoryMonth(startDate, endDate time.Time, entryType models.FinancialEntryType) ([]models.BudgetActual, error) {
+	var actuals []models.BudgetActual
+	query := r.db.Scopes(database.Replica).Model(&models.FinancialEntry{}).
+		Where("entry_date BETWEEN ? AND ? AND status <> ?", startDate, endDate, models.FinancialEntryStatusCancelled)
+	if entryType != "" {
+		query = query.Where("type = ?", entryType)
+	}
+	err := query.
+		Select("TO_CHAR(entry_date, 'YYYY-MM') AS month, type, category, COALESCE(SUM(amount), 0) AS total").
+		Group("TO_CHAR(entry_date, 'YYYY-MM'), type, category").
+		Scan(&actuals).Error
+	return actuals, err
+}
+
 // =============== Dashboard & Reports ===============
 
 // GetDashboardData retrieves dashboard statistics
-func (r *FinancialRepository) GetDashboardData(startDate, endDate time.Time) (*models.FinancialDashboard, error) {
+func (r *FinancialRepository) GetDashboardData(startDate, endDate time.Time, clients models.ClientSegmentFilter) (*models.FinancialDashboard, error) {
+	db := database.ReplicaSession(r.db)
 	dashboard := &models.FinancialDashboard{
 		ByCategory: struct {
 			Income  map[string]float64 `json:"income"`
@@ -375,14 +664,16 @@ func (r *FinancialRepository) GetDashboardData(startDate, endDate time.Time) (*m
 	}
 
 	// Total income
-	r.db.Model(&models.FinancialEntry{}).
+	db.Model(&models.FinancialEntry{}).
 		Where("type = ? AND entry_date BETWEEN ? AND ?", models.FinancialEntryTypeIncome, startDate, endDate).
+		Scopes(clientSegmentScope("client_id", clients)).
 		Select("COALESCE(SUM(amount), 0)").
 		Scan(&dashboard.Summary.TotalIncome)
 
 	// Total expense
-	r.db.Model(&models.FinancialEntry{}).
+	db.Model(&models.FinancialEntry{}).
 		Where("type = ? AND entry_date BETWEEN ? AND ?", models.FinancialEntryTypeExpense, startDate, endDate).
+		Scopes(clientSegmentScope("client_id", clients)).
 		Select("COALESCE(SUM(amount), 0)").
 		Scan(&dashboard.Summary.TotalExpense)
 
@@ -393,8 +684,9 @@ func (r *FinancialRepository) GetDashboardData(startDate, endDate time.Time) (*m
 		Category string
 		Total    float64
 	}
-	r.db.Model(&models.FinancialEntry{}).
+	db.Model(&models.FinancialEntry{}).
 		Where("type = ? AND entry_date BETWEEN ? AND ?", models.FinancialEntryTypeIncome, startDate, endDate).
+		Scopes(clientSegmentScope("client_id", clients)).
 		Select("category, COALESCE(SUM(amount), 0) as total").
 		Group("category").
 		Scan(&incomeByCategory)
@@ -407,8 +699,9 @@ func (r *FinancialRepository) GetDashboardData(startDate, endDate time.Time) (*m
 		Category string
 		Total    float64
 	}
-	r.db.Model(&models.FinancialEntry{}).
+	db.Model(&models.FinancialEntry{}).
 		Where("type = ? AND entry_date BETWEEN ? AND ?", models.FinancialEntryTypeExpense, startDate, endDate).
+		Scopes(clientSegmentScope("client_id", clients)).
 		Select("category, COALESCE(SUM(amount), 0) as total").
 		Group("category").
 		Scan(&expenseByCategory)
@@ -417,19 +710,22 @@ func (r *FinancialRepository) GetDashboardData(startDate, endDate time.Time) (*m
 	}
 
 	// Pending payments count
-	r.db.Model(&models.FinancialEntry{}).
+	db.Model(&models.FinancialEntry{}).
 		Where("status = ?", models.FinancialEntryStatusPending).
+		Scopes(clientSegmentScope("client_id", clients)).
 		Count(&dashboard.PendingPayments)
 
 	// Overdue count
-	r.db.Model(&models.FinancialEntry{}).
+	db.Model(&models.FinancialEntry{}).
 		Where("status = ?", models.FinancialEntryStatusOverdue).
+		Scopes(clientSegmentScope("client_id", clients)).
 		Count(&dashboard.OverdueCount)
 
 	// Recent entries
-	r.db.Preload("Technician").
+	db.Preload("Technician").
 		Preload("Client").
 		Where("entry_date BETWEEN ? AND ?", startDate, endDate).
+		Scopes(clientSegmentScope("client_id", clients)).
 		Order("created_at DESC").
 		Limit(10).
 		Find(&dashboard.RecentEntries)
@@ -438,7 +734,8 @@ func (r *FinancialRepository) GetDashboardData(startDate, endDate time.Time) (*m
 }
 
 // GetCashFlowReport generates cash flow report grouped by period
-func (r *FinancialRepository) GetCashFlowReport(startDate, endDate time.Time, groupBy string) (*models.CashFlowReport, error) {
+func (r *FinancialRepository) GetCashFlowReport(startDate, endDate time.Time, groupBy string, clients models.ClientSegmentFilter) (*models.CashFlowReport, error) {
+	db := database.ReplicaSession(r.db)
 	report := &models.CashFlowReport{
 		Periods: []models.CashFlowPeriod{},
 	}
@@ -460,8 +757,9 @@ func (r *FinancialRepository) GetCashFlowReport(startDate, endDate time.Time, gr
 		Period string
 		Total  float64
 	}
-	r.db.Model(&models.FinancialEntry{}).
+	db.Model(&models.FinancialEntry{}).
 		Where("type = ? AND entry_date BETWEEN ? AND ?", models.FinancialEntryTypeIncome, startDate, endDate).
+		Scopes(clientSegmentScope("client_id", clients)).
 		Select("TO_CHAR(entry_date, ?) as period, COALESCE(SUM(amount), 0) as total", dateFormat).
 		Group("period").
 		Order("period").
@@ -472,8 +770,9 @@ func (r *FinancialRepository) GetCashFlowReport(startDate, endDate time.Time, gr
 		Period string
 		Total  float64
 	}
-	r.db.Model(&models.FinancialEntry{}).
+	db.Model(&models.FinancialEntry{}).
 		Where("type = ? AND entry_date BETWEEN ? AND ?", models.FinancialEntryTypeExpense, startDate, endDate).
+		Scopes(clientSegmentScope("client_id", clients)).
 		Select("TO_CHAR(entry_date, ?) as period, COALESCE(SUM(amount), 0) as total", dateFormat).
 		Group("period").
 		Order("period").
@@ -509,11 +808,12 @@ func (r *FinancialRepository) GetCashFlowReport(startDate, endDate time.Time, gr
 
 // GetTechnicianPaymentsReport generates technician payments report
 func (r *FinancialRepository) GetTechnicianPaymentsReport(startDate, endDate time.Time, technicianID string) (*models.TechnicianPaymentsReport, error) {
+	db := database.ReplicaSession(r.db)
 	report := &models.TechnicianPaymentsReport{
 		Technicians: []models.TechnicianPaymentReport{},
 	}
 
-	query := r.db.Model(&models.FinancialEntry{}).
+	query := db.Model(&models.FinancialEntry{}).
 		Where("category = ? AND entry_date BETWEEN ? AND ? AND technician_id IS NOT NULL",
 			"technician_payment", startDate, endDate)
 
@@ -533,7 +833,7 @@ func (r *FinancialRepository) GetTechnicianPaymentsReport(startDate, endDate tim
 	// Get technician names
 	for _, item := range data {
 		var tech models.Technician
-		r.db.Select("full_name").First(&tech, "id = ?", item.TechnicianID)
+		db.Select("full_name").First(&tech, "id = ?", item.TechnicianID)
 
 		report.Technicians = append(report.Technicians, models.TechnicianPaymentReport{
 			TechnicianID:   item.TechnicianID,
@@ -0,0 +1,162 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type GamificationRepository interface {
+	FindProfile(technicianID string) (*models.GamificationProfile, error)
+	SaveProfile(profile *models.GamificationProfile) error
+	// FindCandidates returns the opted-in active technicians, of the state when given
+	FindCandidates(state string) ([]models.GamificationCandidate, error)
+
+	// The counts are keyed by technician, restricted to technicianIDs
+	CountClosedTickets(technicianIDs []string, from, to time.Time) (map[string]int64, error)
+	CountOnTimeCheckIns(technicianIDs []string, from, to time.Time, grace time.Duration) (map[string]int64, error)
+	CountPromoters(technicianIDs []string, from, to time.Time, window time.Duration) (map[string]int64, error)
+
+	FindBadges(activeOnly bool) ([]models.GamificationBadge, error)
+	FindBadgeByID(id string) (*models.GamificationBadge, error)
+	FindBadgeByCode(code string) (*models.GamificationBadge, error)
+	CreateBadge(badge *models.GamificationBadge) error
+	UpdateBadge(badge *models.GamificationBadge) error
+	DeleteBadge(id string) error
+}
+
+type gamificationRepository struct {
+	db *gorm.DB
+}
+
+func NewGamificationRepository(db *gorm.DB) GamificationRepository {
+	return &gamificationRepository{db: db}
+}
+
+func (r *gamificationRepository) FindProfile(technicianID string) (*models.GamificationProfile, error) {
+	var profile models.GamificationProfile
+	if err := r.db.First(&profile, "technician_id = ?", technicianID).Error; err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+func (r *gamificationRepository) SaveProfile(profile *models.GamificationProfile) error {
+	return r.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(profile).Error
+}
+
+func (r *gamificationRepository) FindCandidates(state string) ([]models.GamificationCandidate, error) {
+	var candidates []models.GamificationCandidate
+	query := r.db.Table("gamification_profiles AS p").
+		Select("p.technician_id, t.full_name, p.display_name, t.state").
+		Joins("JOIN technicians t ON t.id = p.technician_id").
+		Where("p.opted_in = ? AND t.status = ?", true, "ATIVO")
+	if state != "" {
+		query = query.Where("UPPER(t.state) = UPPER(?)", state)
+	}
+	err := query.Scan(&candidates).Error
+	return candidates, err
+}
+
+type technicianCount struct {
+	TechnicianID string
+	Count        int64
+}
+
+func countsByTechnician(rows []technicianCount) map[string]int64 {
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.TechnicianID] = row.Count
+	}
+	return counts
+}
+
+func (r *gamificationRepository) CountClosedTickets(technicianIDs []string, from, to time.Time) (map[string]int64, error) {
+	var rows []technicianCount
+	err := r.db.Table("ticket_technicians tt").
+		Select("tt.technician_id, COUNT(*) AS count").
+		Joins("JOIN tickets t ON t.id = tt.ticket_id AND t.deleted_at IS NULL").
+		Where("tt.technician_id IN ?", technicianIDs).
+		Where("t.status = ? AND t.closed_at >= ? AND t.closed_at < ?", models.TicketStatusClosed, from, to).
+		Group("tt.technician_id").
+		Scan(&rows).Error
+	return countsByTechnician(rows), err
+}
+
+// CountOnTimeCheckIns counts the check-ins of scheduled tickets made before the scheduled
+// start plus the grace
+func (r *gamificationRepository) CountOnTimeCheckIns(technicianIDs []string, from, to time.Time, grace time.Duration) (map[string]int64, error) {
+	var rows []technicianCount
+	err := r.db.Table("ticket_technicians tt").
+		Select("tt.technician_id, COUNT(*) AS count").
+		Joins("JOIN tickets t ON t.id = tt.ticket_id AND t.deleted_at IS NULL").
+		Where("tt.technician_id IN ?", technicianIDs).
+		Where("tt.checked_in_at >= ? AND tt.checked_in_at < ?", from, to).
+		Where("t.scheduled_start IS NOT NULL AND tt.checked_in_at <= t.scheduled_start + make_interval(secs => ?)", grace.Seconds()).
+		Group("tt.technician_id").
+		Scan(&rows).Error
+	return countsByTechnician(rows), err
+}
+
+// CountPromoters counts the NPS promoter answers (9-10) received in the period from the
+// clients of the tickets the technician closed within the window before the answer
+func (r *gamificationRepository) CountPromoters(technicianIDs []string, from, to time.Time, window time.Duration) (map[string]int64, error) {
+	var rows []technicianCount
+	err := r.db.Table("nps_invitations i").
+		Select("tt.technician_id, COUNT(DISTINCT i.id) AS count").
+		Joins("JOIN tickets t ON t.client_id = i.client_id AND t.deleted_at IS NULL AND t.status = ?", models.TicketStatusClosed).
+		Joins("JOIN ticket_technicians tt ON tt.ticket_id = t.id").
+		Where("tt.technician_id IN ?", technicianIDs).
+		Where("i.score >= 9 AND i.responded_at >= ? AND i.responded_at < ?", from, to).
+		Where("t.closed_at <= i.responded_at AND t.closed_at >= i.responded_at - make_interval(secs => ?)", window.Seconds()).
+		Group("tt.technician_id").
+		Scan(&rows).Error
+	return countsByTechnician(rows), err
+}
+
+func (r *gamificationRepository) FindBadges(activeOnly bool) ([]models.GamificationBadge, error) {
+	var badges []models.GamificationBadge
+	query := r.db.Order("period, metric, threshold")
+	if activeOnly {
+		query = query.Where("active = ?", true)
+	}
+	err := query.Find(&badges).Error
+	return badges, err
+}
+
+func (r *gamificationRepository) FindBadgeByID(id string) (*models.GamificationBadge, error) {
+	var badge models.GamificationBadge
+	if err := r.db.First(&badge, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &badge, nil
+}
+
+func (r *gamificationRepository) FindBadgeByCode(code string) (*models.GamificationBadge, error) {
+	var badge models.GamificationBadge
+	if err := r.db.First(&badge, "code = ?", code).Error; err != nil {
+		return nil, err
+	}
+	return &badge, nil
+}
+
+func (r *gamificationRepository) CreateBadge(badge *models.GamificationBadge) error {
+	return r.db.Create(badge).Error
+}
+
+func (r *gamificationRepository) UpdateBadge(badge *models.GamificationBadge) error {
+	return r.db.Save(badge).Error
+}
+
+func (r *gamificationRepository) DeleteBadge(id string) error {
+	result := r.db.Delete(&models.GamificationBadge{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -1,21 +1,37 @@
 package repositories
 
 import (
+	"context"
+	"math"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type GeoRepository struct {
 	db *gorm.DB
+	// postGIS indica que technician_last_locations tem a coluna geom (ver database.EnablePostGIS)
+	postGIS bool
 }
 
 func NewGeoRepository(db *gorm.DB) *GeoRepository {
 	return &GeoRepository{db: db}
 }
 
+// WithContext retorna o repositório executando seus comandos com ctx, no tenant que ele carrega
+func (r *GeoRepository) WithContext(ctx context.Context) *GeoRepository {
+	return &GeoRepository{db: r.db.WithContext(ctx), postGIS: r.postGIS}
+}
+
+// SetPostGIS passa as buscas por área a usar a coluna geom e os índices GiST do PostGIS
+func (r *GeoRepository) SetPostGIS(enabled bool) {
+	r.postGIS = enabled
+}
+
 // CreateLocation cria um novo registro de localização
 func (r *GeoRepository) CreateLocation(location *models.TechnicianLocation) error {
 	return r.db.Create(location).Error
@@ -31,6 +47,40 @@ func (r *GeoRepository) UpsertLastLocation(lastLoc *models.TechnicianLastLocatio
 	return r.db.Save(lastLoc).Error
 }
 
+// GetAssignment obtém a atribuição do técnico no ticket
+func (r *GeoRepository) GetAssignment(ticketID string, technicianID string) (*models.TicketTechnician, error) {
+	var assignment models.TicketTechnician
+	err := r.db.Where("ticket_id = ? AND technician_id = ?", ticketID, technicianID).First(&assignment).Error
+	if err != nil {
+		return nil, err
+	}
+	return &assignment, nil
+}
+
+// MarkAssignmentEvent registra check-in/check-out na atribuição do técnico
+func (r *GeoRepository) MarkAssignmentEvent(ticketID string, technicianID string, eventType models.EventType, at time.Time) error {
+	column := "checked_in_at"
+	if eventType == models.EventTypeCheckout {
+		column = "checked_out_at"
+	}
+	eventName := models.TicketEventCheckin
+	if eventType == models.EventTypeCheckout {
+		eventName = models.TicketEventCheckout
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.TicketTechnician{}).
+			Where("ticket_id = ? AND technician_id = ?", ticketID, technicianID).
+			Update(column, at).Error; err != nil {
+			return err
+		}
+		// Evento na timeline do ticket (consumido pelo stream SSE)
+		return tx.Create(models.NewTicketEvent(ticketID, eventName, "", map[string]interface{}{
+			"technicianId": technicianID,
+			"at":           at,
+		})).Error
+	})
+}
+
 // GetLastLocation obtém a última localização de um técnico
 func (r *GeoRepository) GetLastLocation(technicianID string) (*models.TechnicianLastLocation, error) {
 	var lastLoc models.TechnicianLastLocation
@@ -85,6 +135,74 @@ func (r *GeoRepository) GetAllLastLocations(filter GeoFilter) ([]models.Technici
 	return locations, total, err
 }
 
+// FindLastLocationsInArea busca as últimas localizações dentro do raio e/ou da caixa da
+// área, filtradas e limitadas no banco. Com centro, ordena da mais próxima para a mais
+// distante e calcula a distância; sem centro, da mais recente para a mais antiga.
+func (r *GeoRepository) FindLastLocationsInArea(area GeoArea) ([]NearbyLocation, error) {
+	const table = "technician_last_locations"
+
+	distance := "NULL::double precision"
+	var distanceArgs []interface{}
+	query := r.db.Table(table).
+		Joins("JOIN technicians ON technicians.id = " + table + ".technician_id")
+
+	if r.postGIS {
+		if area.Center != nil {
+			// Em geography, ST_DWithin usa o índice GiST da expressão geom::geography
+			point := "ST_SetSRID(ST_MakePoint(?, ?), 4326)::geography"
+			distance = "ST_Distance(" + table + ".geom::geography, " + point + ")"
+			distanceArgs = []interface{}{area.Center.Lng, area.Center.Lat}
+			if area.RadiusM > 0 {
+				query = query.Where("ST_DWithin("+table+".geom::geography, "+point+", ?)",
+					area.Center.Lng, area.Center.Lat, area.RadiusM)
+			}
+		}
+		if area.Box != nil {
+			query = query.Where(table+".geom && ST_MakeEnvelope(?, ?, ?, ?, 4326)",
+				area.Box.MinLng, area.Box.MinLat, area.Box.MaxLng, area.Box.MaxLat)
+		}
+	} else {
+		if area.Center != nil {
+			distance = "2 * 6371000 * ASIN(LEAST(1, SQRT(POWER(SIN(RADIANS(" + table + ".latitude - ?) / 2), 2) + " +
+				"COS(RADIANS(?)) * COS(RADIANS(" + table + ".latitude)) * POWER(SIN(RADIANS(" + table + ".longitude - ?) / 2), 2))))"
+			distanceArgs = []interface{}{area.Center.Lat, area.Center.Lat, area.Center.Lng}
+			if area.RadiusM > 0 {
+				// A caixa envolvente do raio usa o índice de latitude/longitude antes do cálculo exato
+				box := area.Center.BoundingBox(area.RadiusM)
+				query = query.Where(table+".latitude BETWEEN ? AND ? AND "+table+".longitude BETWEEN ? AND ?",
+					box.MinLat, box.MaxLat, box.MinLng, box.MaxLng).
+					Where(distance+" <= ?", append(distanceArgs, area.RadiusM)...)
+			}
+		}
+		if area.Box != nil {
+			query = query.Where(table+".latitude BETWEEN ? AND ? AND "+table+".longitude BETWEEN ? AND ?",
+				area.Box.MinLat, area.Box.MaxLat, area.Box.MinLng, area.Box.MaxLng)
+		}
+	}
+
+	if area.Status != "" {
+		query = query.Where("technicians.status = ?", area.Status)
+	}
+	if area.UpdatedSince != nil {
+		query = query.Where(table+".server_time >= ?", area.UpdatedSince)
+	}
+
+	if area.Center != nil {
+		query = query.Order("distance_m ASC")
+	} else {
+		query = query.Order(table + ".server_time DESC")
+	}
+	if area.Limit > 0 {
+		query = query.Limit(area.Limit)
+	}
+
+	var locations []NearbyLocation
+	err := query.Select(table+".*, technicians.full_name AS technician_name, technicians.status AS technician_status, "+
+		distance+" AS distance_m", distanceArgs...).
+		Scan(&locations).Error
+	return locations, err
+}
+
 // GetLocationHistory obtém o histórico de localizações de um técnico
 func (r *GeoRepository) GetLocationHistory(technicianID string, filter HistoryFilter) ([]models.TechnicianLocation, int64, error) {
 	var locations []models.TechnicianLocation
@@ -110,6 +228,13 @@ func (r *GeoRepository) GetLocationHistory(technicianID string, filter HistoryFi
 		query = query.Where("event_type = ?", filter.EventType)
 	}
 
+	// Paginação por cursor: Limit+1 localizações após o cursor, para pagination.CursorPage
+	if filter.Cursor != nil {
+		err := query.Scopes(keysetScope(*filter.Cursor, filter.Limit, false, "technician_locations.id::text", "technician_locations.server_time")).
+			Find(&locations).Error
+		return locations, 0, err
+	}
+
 	// Contar total
 	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
@@ -225,11 +350,10 @@ func (r *GeoRepository) UpsertGeoSettings(settings *models.GeoSettings) error {
 	return r.db.Save(settings).Error
 }
 
-// DeleteOldLocations remove localizações antigas baseado na retenção
+// DeleteOldLocations remove localizações antigas baseado na retenção, em lotes
 func (r *GeoRepository) DeleteOldLocations(retentionDays int) (int64, error) {
 	cutoff := time.Now().AddDate(0, 0, -retentionDays)
-	result := r.db.Where("server_time < ?", cutoff).Delete(&models.TechnicianLocation{})
-	return result.RowsAffected, result.Error
+	return deleteInBatches(r.db, "technician_locations", &models.TechnicianLocation{}, "server_time < ?", cutoff)
 }
 
 // CountRecentLocations conta localizações recentes (para rate limiting)
@@ -259,4 +383,138 @@ type HistoryFilter struct {
 	EventType string
 	Limit     int
 	Offset    int
+	// Cursor pagina por keyset em vez de Offset (sem contar o total)
+	Cursor *pagination.Cursor
+}
+
+// GeoPoint é uma coordenada em graus (WGS 84)
+type GeoPoint struct {
+	Lat float64
+	Lng float64
+}
+
+// BoundingBox retorna a caixa que contém o círculo de raio radiusM em torno do ponto
+func (p GeoPoint) BoundingBox(radiusM float64) GeoBox {
+	const metersPerDegree = 111320.0
+	dLat := radiusM / metersPerDegree
+	dLng := 180.0
+	if cos := math.Cos(p.Lat * math.Pi / 180); cos > 0.01 {
+		dLng = math.Min(dLat/cos, 180)
+	}
+	return GeoBox{MinLat: p.Lat - dLat, MinLng: p.Lng - dLng, MaxLat: p.Lat + dLat, MaxLng: p.Lng + dLng}
+}
+
+// GeoBox é uma caixa de coordenadas, como a área visível do mapa
+type GeoBox struct {
+	MinLat float64
+	MinLng float64
+	MaxLat float64
+	MaxLng float64
+}
+
+// GeoArea delimita a busca espacial: o raio RadiusM em torno de Center, a caixa Box, ou
+// ambos. Center sem raio apenas ordena por distância.
+type GeoArea struct {
+	Center       *GeoPoint
+	RadiusM      float64
+	Box          *GeoBox
+	Status       string
+	UpdatedSince *time.Time
+	Limit        int
+}
+
+// NearbyLocation é a última localização de um técnico com o nome, o status e a distância
+// em metros ao centro da busca (nil sem centro)
+type NearbyLocation struct {
+	models.TechnicianLastLocation
+	TechnicianName   string   `gorm:"column:technician_name"`
+	TechnicianStatus string   `gorm:"column:technician_status"`
+	DistanceM        *float64 `gorm:"column:distance_m"`
+}
+
+// FindClientsToGeocode retorna clientes com endereço que ainda não foram geocodificados
+func (r *GeoRepository) FindClientsToGeocode(limit int) ([]models.Client, error) {
+	var clients []models.Client
+	err := r.db.Where("geo_precision IS NULL OR geo_precision = ''").
+		Where("city <> '' OR state <> ''").
+		Order("created_at ASC").
+		Limit(limit).
+		Find(&clients).Error
+	return clients, err
+}
+
+// UpdateClientCoordinates grava as coordenadas geocodificadas do cliente
+func (r *GeoRepository) UpdateClientCoordinates(clientID string, lat, lng *float64, precision string, at time.Time) error {
+	return r.db.Model(&models.Client{}).Where("id = ?", clientID).UpdateColumns(map[string]interface{}{
+		"latitude":      lat,
+		"longitude":     lng,
+		"geo_precision": precision,
+		"geocoded_at":   at,
+	}).Error
+}
+
+// GetGeocodeCache busca o resultado guardado do endereço
+func (r *GeoRepository) GetGeocodeCache(key string) (*models.GeocodeCacheEntry, error) {
+	var entry models.GeocodeCacheEntry
+	if err := r.db.First(&entry, "key = ?", key).Error; err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// SaveGeocodeCache grava o resultado do endereço, substituindo o anterior
+func (r *GeoRepository) SaveGeocodeCache(entry *models.GeocodeCacheEntry) error {
+	return r.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(entry).Error
+}
+
+// FindVisitsToBackfill retorna as visitas de técnicos a tickets entre since e until, em clientes
+// geocodificados, que ainda não têm nenhuma localização registrada
+func (r *GeoRepository) FindVisitsToBackfill(since, until time.Time, limit int) ([]models.GeoBackfillVisit, error) {
+	var visits []models.GeoBackfillVisit
+	err := r.db.Table("ticket_technicians tt").
+		Select("tt.ticket_id, tt.technician_id, COALESCE(tt.checked_in_at, t.start_date, t.closed_at) AS visited_at, c.latitude, c.longitude, c.geo_precision").
+		Joins("JOIN tickets t ON t.id = tt.ticket_id AND t.deleted_at IS NULL").
+		Joins("JOIN clients c ON c.id = t.client_id AND c.latitude IS NOT NULL AND c.longitude IS NOT NULL").
+		Where("COALESCE(tt.checked_in_at, t.start_date, t.closed_at) BETWEEN ? AND ?", since, until).
+		Where("NOT EXISTS (SELECT 1 FROM technician_locations l WHERE l.ticket_id = tt.ticket_id AND l.technician_id = tt.technician_id)").
+		Order("visited_at ASC").
+		Limit(limit).
+		Scan(&visits).Error
+	return visits, err
+}
+
+// FindRouteTickets retorna os tickets em aberto do técnico agendados entre dayStart e dayEnd
+func (r *GeoRepository) FindRouteTickets(technicianID string, dayStart, dayEnd time.Time) ([]models.Ticket, error) {
+	var tickets []models.Ticket
+	err := r.db.Model(&models.Ticket{}).
+		Joins("JOIN ticket_technicians tt ON tt.ticket_id = tickets.id").
+		Where("tt.technician_id = ?", technicianID).
+		Where("tickets.status NOT IN ?", []models.TicketStatus{
+			models.TicketStatusClosed, models.TicketStatusCancelled, models.TicketStatusUnproductive,
+		}).
+		Where("tickets.scheduled_start >= ? AND tickets.scheduled_start < ?", dayStart, dayEnd).
+		Preload("Client").
+		Order("tickets.scheduled_start ASC, tickets.created_at ASC").
+		Find(&tickets).Error
+	return tickets, err
+}
+
+// SaveRoutePlan grava o plano de rota, substituindo o do mesmo técnico e dia
+func (r *GeoRepository) SaveRoutePlan(plan *models.TechnicianRoutePlan) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "technician_id"}, {Name: "date"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"algorithm", "start_source", "start_latitude", "start_longitude", "average_speed_kmh",
+			"total_distance_km", "total_travel_minutes", "baseline_distance_km", "stops", "created_by", "updated_at",
+		}),
+	}).Create(plan).Error
+}
+
+// GetRoutePlan obtém o plano de rota gravado do técnico no dia (YYYY-MM-DD)
+func (r *GeoRepository) GetRoutePlan(technicianID, date string) (*models.TechnicianRoutePlan, error) {
+	var plan models.TechnicianRoutePlan
+	if err := r.db.Where("technician_id = ? AND date = ?", technicianID, date).First(&plan).Error; err != nil {
+		return nil, err
+	}
+	return &plan, nil
 }
@@ -0,0 +1,128 @@
+package repositories
+
+import (
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+type GeoFenceRepository interface {
+	FindAll(filters *models.GeoFenceFilters) ([]models.GeoFence, error)
+	FindByID(id string) (*models.GeoFence, error)
+	Create(fence *models.GeoFence) error
+	Update(fence *models.GeoFence) error
+	// Delete removes the fence with its presences; the event history is kept
+	Delete(id string) error
+
+	// Presences
+	FindPresences(fenceID string) ([]models.GeoFencePresence, error)
+	FindPresencesByTechnician(technicianID string) ([]models.GeoFencePresence, error)
+	SavePresence(presence *models.GeoFencePresence) error
+	DeletePresence(fenceID, technicianID string) error
+	DeletePresences(fenceID string) error
+
+	// Events
+	FindEvents(fenceID string, page, size int) ([]models.GeoFenceEvent, int64, error)
+	CreateEvent(event *models.GeoFenceEvent) error
+}
+
+type geoFenceRepository struct {
+	db *gorm.DB
+}
+
+func NewGeoFenceRepository(db *gorm.DB) GeoFenceRepository {
+	return &geoFenceRepository{db: db}
+}
+
+func (r *geoFenceRepository) FindAll(filters *models.GeoFenceFilters) ([]models.GeoFence, error) {
+	var fences []models.GeoFence
+
+	query := r.db.Model(&models.GeoFence{})
+	if filters != nil {
+		if filters.ClientID != "" {
+			query = query.Where("client_id = ?", filters.ClientID)
+		}
+		if filters.NodeID != 0 {
+			query = query.Where("node_id = ?", filters.NodeID)
+		}
+		if !filters.Inactive {
+			query = query.Where("active = ?", true)
+		}
+	}
+
+	err := query.Order("name").Find(&fences).Error
+	return fences, err
+}
+
+func (r *geoFenceRepository) FindByID(id string) (*models.GeoFence, error) {
+	var fence models.GeoFence
+	if err := r.db.First(&fence, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &fence, nil
+}
+
+func (r *geoFenceRepository) Create(fence *models.GeoFence) error {
+	return r.db.Omit("Client").Create(fence).Error
+}
+
+func (r *geoFenceRepository) Update(fence *models.GeoFence) error {
+	return r.db.Omit("Client").Save(fence).Error
+}
+
+func (r *geoFenceRepository) Delete(id string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ?", id).Delete(&models.GeoFence{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Where("fence_id = ?", id).Delete(&models.GeoFencePresence{}).Error
+	})
+}
+
+func (r *geoFenceRepository) FindPresences(fenceID string) ([]models.GeoFencePresence, error) {
+	var presences []models.GeoFencePresence
+	err := r.db.Preload("Technician").
+		Where("fence_id = ?", fenceID).
+		Order("entered_at ASC").
+		Find(&presences).Error
+	return presences, err
+}
+
+func (r *geoFenceRepository) FindPresencesByTechnician(technicianID string) ([]models.GeoFencePresence, error) {
+	var presences []models.GeoFencePresence
+	err := r.db.Where("technician_id = ?", technicianID).Find(&presences).Error
+	return presences, err
+}
+
+func (r *geoFenceRepository) SavePresence(presence *models.GeoFencePresence) error {
+	return r.db.Omit("Technician").Save(presence).Error
+}
+
+func (r *geoFenceRepository) DeletePresence(fenceID, technicianID string) error {
+	return r.db.Where("fence_id = ? AND technician_id = ?", fenceID, technicianID).
+		Delete(&models.GeoFencePresence{}).Error
+}
+
+func (r *geoFenceRepository) DeletePresences(fenceID string) error {
+	return r.db.Where("fence_id = ?", fenceID).Delete(&models.GeoFencePresence{}).Error
+}
+
+func (r *geoFenceRepository) FindEvents(fenceID string, page, size int) ([]models.GeoFenceEvent, int64, error) {
+	var events []models.GeoFenceEvent
+	var total int64
+
+	query := r.db.Model(&models.GeoFenceEvent{}).Where("fence_id = ?", fenceID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("occurred_at DESC").Offset(page * size).Limit(size).Find(&events).Error
+	return events, total, err
+}
+
+func (r *geoFenceRepository) CreateEvent(event *models.GeoFenceEvent) error {
+	return r.db.Create(event).Error
+}
@@ -0,0 +1,114 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+// GraphQLRepository loads the relations of the GraphQL API in batches: one query for the
+// relation of every parent at a level, instead of one per parent
+type GraphQLRepository interface {
+	ClientsByIDs(ids []string, scope *models.AccessScope) ([]models.Client, error)
+	TicketsByIDs(ids []string) ([]models.Ticket, error)
+	// TicketsByClientIDs returns the latest tickets of each client, up to perClient
+	TicketsByClientIDs(clientIDs []string, perClient int, scope *models.AccessScope) ([]models.Ticket, error)
+	// TicketLinksByTechnicianIDs returns the assignments of the latest tickets of each
+	// technician, up to perTechnician
+	TicketLinksByTechnicianIDs(technicianIDs []string, perTechnician int, scope *models.AccessScope) ([]models.TicketTechnician, error)
+	AssignmentsByTicketIDs(ticketIDs []string) ([]models.TicketTechnician, error)
+	StockUsageByTicketIDs(ticketIDs []string) ([]models.StockMovement, error)
+	FinancialTotalsByTicketIDs(ticketIDs []string) ([]models.TicketFinancialTotals, error)
+	// WithContext returns the repository running its statements with ctx, inside the
+	// tenant it carries
+	WithContext(ctx context.Context) GraphQLRepository
+}
+
+type graphQLRepository struct {
+	db *gorm.DB
+}
+
+func NewGraphQLRepository(db *gorm.DB) GraphQLRepository {
+	return &graphQLRepository{db: db}
+}
+
+func (r *graphQLRepository) WithContext(ctx context.Context) GraphQLRepository {
+	return &graphQLRepository{db: r.db.WithContext(ctx)}
+}
+
+func (r *graphQLRepository) ClientsByIDs(ids []string, scope *models.AccessScope) ([]models.Client, error) {
+	var clients []models.Client
+	err := r.db.Scopes(NodeScope(scope, "clients.node_id")).Where("id IN ?", ids).Find(&clients).Error
+	return clients, err
+}
+
+func (r *graphQLRepository) TicketsByIDs(ids []string) ([]models.Ticket, error) {
+	var tickets []models.Ticket
+	err := r.db.Where("id IN ?", ids).Find(&tickets).Error
+	return tickets, err
+}
+
+func (r *graphQLRepository) TicketsByClientIDs(clientIDs []string, perClient int, scope *models.AccessScope) ([]models.Ticket, error) {
+	ranked := r.db.Model(&models.Ticket{}).
+		Select("tickets.*, ROW_NUMBER() OVER (PARTITION BY tickets.client_id ORDER BY tickets.created_at DESC) AS row_rank").
+		Scopes(NodeScope(scope, "tickets.node_id")).
+		Where("tickets.client_id IN ?", clientIDs)
+
+	var tickets []models.Ticket
+	err := r.db.Table("(?) AS tickets", ranked).
+		Where("row_rank <= ?", perClient).
+		Order("created_at DESC").
+		Find(&tickets).Error
+	return tickets, err
+}
+
+func (r *graphQLRepository) TicketLinksByTechnicianIDs(technicianIDs []string, perTechnician int, scope *models.AccessScope) ([]models.TicketTechnician, error) {
+	ranked := r.db.Model(&models.TicketTechnician{}).
+		Select("ticket_technicians.ticket_id, ticket_technicians.technician_id, ticket_technicians.role, "+
+			"ROW_NUMBER() OVER (PARTITION BY ticket_technicians.technician_id ORDER BY t.created_at DESC) AS row_rank").
+		Joins("JOIN tickets t ON t.id = ticket_technicians.ticket_id AND t.deleted_at IS NULL").
+		Scopes(NodeScope(scope, "t.node_id")).
+		Where("ticket_technicians.technician_id IN ?", technicianIDs)
+
+	var links []models.TicketTechnician
+	err := r.db.Table("(?) AS links", ranked).
+		Where("row_rank <= ?", perTechnician).
+		Order("row_rank").
+		Scan(&links).Error
+	return links, err
+}
+
+func (r *graphQLRepository) AssignmentsByTicketIDs(ticketIDs []string) ([]models.TicketTechnician, error) {
+	var assignments []models.TicketTechnician
+	err := r.db.Preload("Technician").
+		Where("ticket_id IN ?", ticketIDs).
+		Order("created_at").
+		Find(&assignments).Error
+	return assignments, err
+}
+
+func (r *graphQLRepository) StockUsageByTicketIDs(ticketIDs []string) ([]models.StockMovement, error) {
+	var movements []models.StockMovement
+	err := r.db.Preload("Item").
+		Where("ticket_id IN ?", ticketIDs).
+		Order("performed_at").
+		Find(&movements).Error
+	return movements, err
+}
+
+func (r *graphQLRepository) FinancialTotalsByTicketIDs(ticketIDs []string) ([]models.TicketFinancialTotals, error) {
+	var totals []models.TicketFinancialTotals
+	err := r.db.Model(&models.FinancialEntry{}).
+		Select("ticket_id, "+
+			"COALESCE(SUM(CASE WHEN type = ? THEN amount END), 0) AS income, "+
+			"COALESCE(SUM(CASE WHEN type = ? THEN amount END), 0) AS expense",
+			models.FinancialEntryTypeIncome, models.FinancialEntryTypeExpense).
+		Where("ticket_id IN ? AND status <> ?", ticketIDs, models.FinancialEntryStatusCancelled).
+		Group("ticket_id").
+		Scan(&totals).Error
+	for i := range totals {
+		totals[i].Balance = totals[i].Income - totals[i].Expense
+	}
+	return totals, err
+}
@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 
@@ -9,6 +10,9 @@ import (
 	"gorm.io/gorm"
 )
 
+// ErrNodeMoveIntoSubtree is returned when a node would be moved under itself or one of its descendants
+var ErrNodeMoveIntoSubtree = errors.New("node cannot be moved under itself or its descendants")
+
 type HierarchyRepository interface {
 	// Hierarchy CRUD
 	GetAllHierarchies() ([]models.Hierarchy, error)
@@ -40,11 +44,15 @@ type HierarchyRepository interface {
 	GetAllPermissions() ([]models.Permission, error)
 	GetPermissionsByCategory() (map[string][]models.Permission, error)
 	GetUserPermissions(userID string) ([]string, error)
+	// GetUserNodePermissions returns the permission codes of the user per membership node
+	GetUserNodePermissions(userID string) ([]models.NodePermission, error)
 
 	// Membership CRUD
 	GetMembersByNode(nodeID uint) ([]models.MemberWithDetails, error)
 	GetMembershipByID(id uint) (*models.Membership, error)
 	GetUserMemberships(userID string) ([]models.Membership, error)
+	// GetScopeMemberUserIDs returns the users that are members of the nodes or of any of their descendants
+	GetScopeMemberUserIDs(nodeIDs []uint) ([]string, error)
 	AddMember(membership *models.Membership) error
 	UpdateMembership(membership *models.Membership) error
 	RemoveMembership(id uint) error
@@ -249,6 +257,7 @@ func (r *hierarchyRepository) MoveNode(nodeID uint, newParentID *uint) error {
 	}
 
 	oldPath := node.Path
+	oldDepth := node.Depth
 
 	// Calculate new path and depth
 	if newParentID != nil {
@@ -256,6 +265,9 @@ func (r *hierarchyRepository) MoveNode(nodeID uint, newParentID *uint) error {
 		if err := r.db.First(&parent, *newParentID).Error; err != nil {
 			return fmt.Errorf("new parent node not found: %w", err)
 		}
+		if parent.Path == oldPath || strings.HasPrefix(parent.Path, oldPath+".") {
+			return ErrNodeMoveIntoSubtree
+		}
 		node.ParentID = newParentID
 		node.Depth = parent.Depth + 1
 		node.Path = fmt.Sprintf("%s.%d", parent.Path, node.ID)
@@ -265,20 +277,20 @@ func (r *hierarchyRepository) MoveNode(nodeID uint, newParentID *uint) error {
 		node.Path = fmt.Sprintf("%d", node.ID)
 	}
 
-	// Update the node
-	if err := r.db.Save(&node).Error; err != nil {
-		return err
-	}
-
-	// Update all descendants' paths
-	var descendants []models.Node
-	r.db.Where("path LIKE ?", oldPath+".%").Find(&descendants)
-	for _, desc := range descendants {
-		newDescPath := strings.Replace(desc.Path, oldPath, node.Path, 1)
-		r.db.Model(&desc).Update("path", newDescPath)
-	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// Update the node
+		if err := tx.Save(&node).Error; err != nil {
+			return err
+		}
 
-	return nil
+		// Rebase all descendants' paths and depths in one statement
+		return tx.Model(&models.Node{}).
+			Where("path LIKE ?", oldPath+".%").
+			Updates(map[string]interface{}{
+				"path":  gorm.Expr("? || SUBSTRING(path FROM ?)", node.Path, len(oldPath)+1),
+				"depth": gorm.Expr("depth + ?", node.Depth-oldDepth),
+			}).Error
+	})
 }
 
 func (r *hierarchyRepository) DeleteNode(id uint) error {
@@ -416,6 +428,21 @@ func (r *hierarchyRepository) GetUserPermissions(userID string) ([]string, error
 	return permissions, nil
 }
 
+// GetUserNodePermissions returns the permission codes granted by each membership of the
+// user, with the path of the membership node; a membership whose role has no permission
+// still gives a row, with an empty code
+func (r *hierarchyRepository) GetUserNodePermissions(userID string) ([]models.NodePermission, error) {
+	permissions := []models.NodePermission{}
+	err := r.db.Table("memberships m").
+		Select("n.id AS node_id, n.path AS node_path, COALESCE(p.code, '') AS code").
+		Joins("JOIN nodes n ON n.id = m.node_id").
+		Joins("LEFT JOIN role_permissions rp ON rp.role_id = m.role_id").
+		Joins("LEFT JOIN permissions p ON p.id = rp.permission_id").
+		Where("m.user_id = ?", userID).
+		Scan(&permissions).Error
+	return permissions, err
+}
+
 // ==================== Membership CRUD ====================
 
 func (r *hierarchyRepository) GetMembersByNode(nodeID uint) ([]models.MemberWithDetails, error) {
@@ -516,6 +543,20 @@ func (r *hierarchyRepository) GetUserMemberships(userID string) ([]models.Member
 	return memberships, err
 }
 
+func (r *hierarchyRepository) GetScopeMemberUserIDs(nodeIDs []uint) ([]string, error) {
+	var userIDs []string
+	if len(nodeIDs) == 0 {
+		return userIDs, nil
+	}
+	err := r.db.Table("memberships m").
+		Distinct("m.user_id").
+		Joins("JOIN nodes n ON n.id = m.node_id").
+		Joins("JOIN nodes s ON n.path = s.path OR n.path LIKE s.path || '.%'").
+		Where("s.id IN ?", nodeIDs).
+		Pluck("m.user_id", &userIDs).Error
+	return userIDs, err
+}
+
 func (r *hierarchyRepository) AddMember(membership *models.Membership) error {
 	return r.db.Create(membership).Error
 }
@@ -0,0 +1,43 @@
+package repositories
+
+import (
+	"fmt"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+// NodeScope is a GORM scope restricting the rows to the hierarchy subtrees the user is a
+// member of (membership nodes and their descendants, by path) plus the rows without node.
+// column is the qualified node column, e.g. "tickets.node_id".
+func NodeScope(scope *models.AccessScope, column string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if !scope.Restricted() {
+			return db
+		}
+		return db.Where(fmt.Sprintf(`(%[1]s IS NULL OR %[1]s IN (
+			SELECT n.id FROM nodes n
+			JOIN nodes s ON n.path = s.path OR n.path LIKE s.path || '.%%'
+			JOIN memberships m ON m.node_id = s.id
+			WHERE m.user_id = ?))`, column), scope.UserID)
+	}
+}
+
+// nodeInScope tells whether the node is inside the hierarchy subtrees of the scope
+func nodeInScope(db *gorm.DB, scope *models.AccessScope, nodeID uint) (bool, error) {
+	if !scope.Restricted() {
+		return true, nil
+	}
+	var count int64
+	err := db.Table("nodes n").
+		Joins("JOIN nodes s ON n.path = s.path OR n.path LIKE s.path || '.%'").
+		Joins("JOIN memberships m ON m.node_id = s.id").
+		Where("n.id = ? AND m.user_id = ?", nodeID, scope.UserID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// scopedDB returns a session of db with the scope applied to every query run on it
+func scopedDB(db *gorm.DB, scope *models.AccessScope, column string) *gorm.DB {
+	return db.Scopes(NodeScope(scope, column)).Session(&gorm.Session{})
+}
@@ -0,0 +1,189 @@
+//go:build integration
+
+package repositories_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"github.com/shigake/tech-iq-back/internal/testutil"
+	"gorm.io/gorm"
+)
+
+// grantTenantNode makes the fixture employee a member of the Tenant node (parent of Branch)
+// and returns the employee's scope
+func grantTenantNode(t *testing.T) *models.AccessScope {
+	t.Helper()
+	var role models.Role
+	if err := env.DB.First(&role).Error; err != nil {
+		t.Fatalf("role: %v", err)
+	}
+	membership := models.Membership{UserID: testutil.EmployeeUserID, NodeID: testutil.TenantNodeID, RoleID: role.ID}
+	if err := env.DB.Create(&membership).Error; err != nil {
+		t.Fatalf("membership: %v", err)
+	}
+	return models.NewAccessScope(testutil.EmployeeUserID, "EMPLOYEE")
+}
+
+func TestNodeScopeInheritsClientsOfDescendants(t *testing.T) {
+	env.Reset(t)
+	scope := grantTenantNode(t)
+	clients := repositories.NewClientRepository(env.DB)
+
+	branch := &models.Client{FullName: "Branch Client", Email: "branch@fixture.test", NodeID: &testutil.BranchNodeID}
+	other := &models.Client{FullName: "Other Client", Email: "other@fixture.test", NodeID: &testutil.OtherNodeID}
+	for _, client := range []*models.Client{branch, other} {
+		if err := clients.Create(client); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+	scoped := clients.WithScope(scope)
+
+	t.Run("read by id", func(t *testing.T) {
+		if _, err := scoped.GetByID(branch.ID); err != nil {
+			t.Fatalf("client of a descendant node not inherited: %v", err)
+		}
+		if _, err := scoped.GetByID(testutil.ClientID); err != nil {
+			t.Fatalf("client without node hidden: %v", err)
+		}
+		if _, err := scoped.GetByID(other.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Fatalf("client outside the scope read: err = %v", err)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		listed, total, err := scoped.GetAll(0, 100)
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		if total != 2 {
+			t.Fatalf("total = %d, want 2 (branch and unassigned)", total)
+		}
+		for _, c := range listed {
+			if c.ID == other.ID {
+				t.Fatal("client outside the scope listed")
+			}
+		}
+	})
+
+	t.Run("update and delete", func(t *testing.T) {
+		changed := *other
+		changed.FullName = "Changed"
+		if err := scoped.Update(&changed); !errors.Is(err, repositories.ErrVersionConflict) {
+			t.Fatalf("update outside the scope: err = %v, want ErrVersionConflict", err)
+		}
+		if err := scoped.Delete(other.ID); err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+		current, err := clients.GetByID(other.ID)
+		if err != nil {
+			t.Fatalf("client outside the scope deleted: %v", err)
+		}
+		if current.FullName != other.FullName {
+			t.Fatalf("client outside the scope renamed to %q", current.FullName)
+		}
+	})
+
+	t.Run("admin", func(t *testing.T) {
+		admin := clients.WithScope(models.NewAccessScope(testutil.AdminUserID, models.RoleAdmin))
+		if _, err := admin.GetByID(other.ID); err != nil {
+			t.Fatalf("admin read: %v", err)
+		}
+	})
+}
+
+func TestNodeScopeInheritsTechniciansOfDescendants(t *testing.T) {
+	env.Reset(t)
+	scope := grantTenantNode(t)
+	technicians := repositories.NewTechnicianRepository(env.DB)
+
+	branch := &models.Technician{FullName: "Branch Technician", Status: "ATIVO", Type: "PARCERIA", NodeID: &testutil.BranchNodeID}
+	other := &models.Technician{FullName: "Other Technician", Status: "ATIVO", Type: "PARCERIA", NodeID: &testutil.OtherNodeID}
+	for _, technician := range []*models.Technician{branch, other} {
+		if err := technicians.Create(technician); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+	scoped := technicians.WithScope(scope)
+
+	if _, err := scoped.FindByID(branch.ID); err != nil {
+		t.Fatalf("technician of a descendant node not inherited: %v", err)
+	}
+	if _, err := scoped.FindByID(other.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("technician outside the scope read: err = %v", err)
+	}
+	if err := scoped.Delete(other.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := technicians.FindByID(other.ID); err != nil {
+		t.Fatalf("technician outside the scope deleted: %v", err)
+	}
+}
+
+func TestNodeScopeInheritsTicketsOfDescendants(t *testing.T) {
+	env.Reset(t)
+	scope := grantTenantNode(t)
+	tickets := repositories.NewTicketRepository(env.DB)
+
+	branch := &models.Ticket{ErrorDescription: "Branch ticket", Status: models.TicketStatusOpen, Priority: models.TicketPriorityNormal, NodeID: &testutil.BranchNodeID}
+	other := &models.Ticket{ErrorDescription: "Other ticket", Status: models.TicketStatusOpen, Priority: models.TicketPriorityNormal, NodeID: &testutil.OtherNodeID}
+	for _, ticket := range []*models.Ticket{branch, other} {
+		if err := tickets.Create(ticket); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+	scoped := tickets.WithScope(scope)
+
+	if _, err := scoped.FindByID(branch.ID); err != nil {
+		t.Fatalf("ticket of a descendant node not inherited: %v", err)
+	}
+	if _, err := scoped.FindByID(other.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("ticket outside the scope read: err = %v", err)
+	}
+	listed, _, err := tickets.FindAll(0, 100, &models.TicketFilters{Scope: scope})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	for _, ticket := range listed {
+		if ticket.ID == other.ID {
+			t.Fatal("ticket outside the scope listed")
+		}
+	}
+
+	changed := *other
+	changed.ErrorDescription = "Changed"
+	if err := scoped.Update(&changed); !errors.Is(err, repositories.ErrVersionConflict) {
+		t.Fatalf("update outside the scope: err = %v, want ErrVersionConflict", err)
+	}
+	if err := scoped.UpdateStatus(other.ID, string(models.TicketStatusClosed), testutil.EmployeeUserID, ""); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("status change outside the scope: err = %v", err)
+	}
+	if err := scoped.Delete(other.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	current, err := tickets.FindByID(other.ID)
+	if err != nil {
+		t.Fatalf("ticket outside the scope deleted: %v", err)
+	}
+	if current.Status != models.TicketStatusOpen || current.ErrorDescription != other.ErrorDescription {
+		t.Fatalf("ticket outside the scope changed: %s %q", current.Status, current.ErrorDescription)
+	}
+}
+
+func TestTicketsArePlacedInNodesOfTheScope(t *testing.T) {
+	env.Reset(t)
+	scope := grantTenantNode(t)
+	scoped := repositories.NewTicketRepository(env.DB).WithScope(scope)
+
+	for node, want := range map[uint]bool{testutil.TenantNodeID: true, testutil.BranchNodeID: true, testutil.OtherNodeID: false} {
+		inScope, err := scoped.NodeInScope(node)
+		if err != nil {
+			t.Fatalf("node %d: %v", node, err)
+		}
+		if inScope != want {
+			t.Fatalf("node %d in scope = %v, want %v", node, inScope, want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+// IncidentTimelineRepository reads the records of every module that feed the incident
+// timeline; all queries cover [from, to)
+type IncidentTimelineRepository interface {
+	ErrorRateByMinute(from, to time.Time) ([]models.ErrorRateBucket, error)
+	TopErrorRoutes(from, to time.Time, limit int) ([]models.RouteErrors, error)
+	CriticalErrors(from, to time.Time, limit int) ([]models.ErrorLog, error)
+	FailedSecurityEvents(from, to time.Time) ([]models.SecurityEventBucket, error)
+	// FailedAttachments returns the attachments still FAILED whose retry comes after from
+	FailedAttachments(from, to time.Time) ([]models.TicketFile, error)
+	FailedRemediations(from, to time.Time) ([]models.RemediationAction, error)
+	SystemAlerts(from, to time.Time) ([]models.Alert, error)
+	StatusIncidents(from, to time.Time) ([]models.StatusIncident, error)
+
+	// Deploy markers
+	FindDeploys(from, to time.Time) ([]models.DeployMarker, error)
+	CreateDeploy(marker *models.DeployMarker) error
+	DeleteDeploy(id string) error
+}
+
+type incidentTimelineRepository struct {
+	db *gorm.DB
+}
+
+func NewIncidentTimelineRepository(db *gorm.DB) IncidentTimelineRepository {
+	return &incidentTimelineRepository{db: db}
+}
+
+func (r *incidentTimelineRepository) ErrorRateByMinute(from, to time.Time) ([]models.ErrorRateBucket, error) {
+	var rows []models.ErrorRateBucket
+	err := r.db.Model(&models.RequestMetric{}).
+		Select("date_trunc('minute', created_at) AS bucket, COUNT(*) AS samples, "+
+			"COUNT(*) FILTER (WHERE status_code >= 500) AS errors").
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Group("bucket").
+		Order("bucket").
+		Scan(&rows).Error
+	return rows, err
+}
+
+func (r *incidentTimelineRepository) TopErrorRoutes(from, to time.Time, limit int) ([]models.RouteErrors, error) {
+	var rows []models.RouteErrors
+	err := r.db.Model(&models.RequestMetric{}).
+		Select("method, path, COUNT(*) AS errors").
+		Where("created_at >= ? AND created_at < ? AND status_code >= 500", from, to).
+		Group("method, path").
+		Order("errors DESC").
+		Limit(limit).
+		Scan(&rows).Error
+	return rows, err
+}
+
+func (r *incidentTimelineRepository) CriticalErrors(from, to time.Time, limit int) ([]models.ErrorLog, error) {
+	var logs []models.ErrorLog
+	err := r.db.Where("level = ? AND created_at >= ? AND created_at < ?", "CRITICAL", from, to).
+		Order("created_at").
+		Limit(limit).
+		Find(&logs).Error
+	return logs, err
+}
+
+func (r *incidentTimelineRepository) FailedSecurityEvents(from, to time.Time) ([]models.SecurityEventBucket, error) {
+	var rows []models.SecurityEventBucket
+	err := r.db.Model(&models.SecurityLog{}).
+		Select("date_trunc('minute', created_at) AS bucket, action, COUNT(*) AS attempts, "+
+			"COUNT(DISTINCT email) AS accounts, COUNT(DISTINCT ip_address) AS ips").
+		Where("success = ? AND created_at >= ? AND created_at < ?", false, from, to).
+		Group("bucket, action").
+		Order("bucket").
+		Scan(&rows).Error
+	return rows, err
+}
+
+func (r *incidentTimelineRepository) FailedAttachments(from, to time.Time) ([]models.TicketFile, error) {
+	var files []models.TicketFile
+	err := r.db.Where("processing_status = ? AND next_attempt_at >= ? AND created_at < ?",
+		models.AttachmentStatusFailed, from, to).
+		Find(&files).Error
+	return files, err
+}
+
+func (r *incidentTimelineRepository) FailedRemediations(from, to time.Time) ([]models.RemediationAction, error) {
+	var actions []models.RemediationAction
+	err := r.db.Preload("Rule").
+		Where("status = ? AND applied_at >= ? AND applied_at < ?", models.RemediationFailed, from, to).
+		Order("applied_at").
+		Find(&actions).Error
+	return actions, err
+}
+
+func (r *incidentTimelineRepository) SystemAlerts(from, to time.Time) ([]models.Alert, error) {
+	var alerts []models.Alert
+	err := r.db.Where("module = ? AND created_at >= ? AND created_at < ?", models.AlertModuleSystem, from, to).
+		Order("created_at").
+		Find(&alerts).Error
+	return alerts, err
+}
+
+// StatusIncidents returns the incidents started in the window or resolved in it
+func (r *incidentTimelineRepository) StatusIncidents(from, to time.Time) ([]models.StatusIncident, error) {
+	var incidents []models.StatusIncident
+	err := r.db.Where("(started_at >= ? AND started_at < ?) OR (resolved_at >= ? AND resolved_at < ?)", from, to, from, to).
+		Order("started_at").
+		Find(&incidents).Error
+	return incidents, err
+}
+
+func (r *incidentTimelineRepository) FindDeploys(from, to time.Time) ([]models.DeployMarker, error) {
+	var markers []models.DeployMarker
+	err := r.db.Where("deployed_at >= ? AND deployed_at < ?", from, to).
+		Order("deployed_at").
+		Find(&markers).Error
+	return markers, err
+}
+
+func (r *incidentTimelineRepository) CreateDeploy(marker *models.DeployMarker) error {
+	return r.db.Create(marker).Error
+}
+
+func (r *incidentTimelineRepository) DeleteDeploy(id string) error {
+	result := r.db.Delete(&models.DeployMarker{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
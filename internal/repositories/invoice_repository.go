@@ -0,0 +1,83 @@
+package repositories
+
+import (
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+type InvoiceRepository interface {
+	// Enqueue saves the invoice and writes its issuance outbox event in the same
+	// transaction, for new invoices and retried ones alike
+	Enqueue(invoice *models.Invoice) error
+	Update(invoice *models.Invoice) error
+	FindByID(id string) (*models.Invoice, error)
+	FindAll(filter models.InvoiceFilter, page, size int) ([]models.Invoice, int64, error)
+	// FindActiveByEntry returns the invoice of the entry that was not rejected or cancelled
+	FindActiveByEntry(entryID string) (*models.Invoice, error)
+}
+
+type invoiceRepository struct {
+	db *gorm.DB
+}
+
+func NewInvoiceRepository(db *gorm.DB) InvoiceRepository {
+	return &invoiceRepository{db: db}
+}
+
+func (r *invoiceRepository) Enqueue(invoice *models.Invoice) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Entry", "Client").Save(invoice).Error; err != nil {
+			return err
+		}
+		event, err := models.NewOutboxEvent(models.OutboxTopicInvoiceIssue, "invoice", invoice.ID,
+			models.InvoiceIssuePayload{InvoiceID: invoice.ID})
+		if err != nil {
+			return err
+		}
+		return tx.Create(event).Error
+	})
+}
+
+func (r *invoiceRepository) Update(invoice *models.Invoice) error {
+	return r.db.Omit("Entry", "Client").Save(invoice).Error
+}
+
+func (r *invoiceRepository) FindByID(id string) (*models.Invoice, error) {
+	var invoice models.Invoice
+	if err := r.db.Preload("Entry").Preload("Client").First(&invoice, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+func (r *invoiceRepository) FindAll(filter models.InvoiceFilter, page, size int) ([]models.Invoice, int64, error) {
+	query := r.db.Model(&models.Invoice{})
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.EntryID != "" {
+		query = query.Where("entry_id = ?", filter.EntryID)
+	}
+	if filter.ClientID != "" {
+		query = query.Where("client_id = ?", filter.ClientID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var invoices []models.Invoice
+	err := query.Preload("Client").Order("created_at DESC").Offset(page * size).Limit(size).Find(&invoices).Error
+	return invoices, total, err
+}
+
+func (r *invoiceRepository) FindActiveByEntry(entryID string) (*models.Invoice, error) {
+	var invoice models.Invoice
+	err := r.db.Where("entry_id = ? AND status NOT IN ?", entryID,
+		[]string{models.InvoiceStatusRejected, models.InvoiceStatusCancelled}).
+		First(&invoice).Error
+	if err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
@@ -0,0 +1,45 @@
+package repositories
+
+import (
+	"strings"
+
+	"github.com/shigake/tech-iq-back/internal/pagination"
+	"gorm.io/gorm"
+)
+
+// keysetScope is a GORM scope paging by the cursor instead of an offset: it orders by the
+// key columns then idColumn (a text expression, e.g. "tickets.id::text"), all descending
+// or all ascending, keeps the rows past the cursor position and fetches size+1 of them
+// for pagination.CursorPage. Going Before reverses the order.
+func keysetScope(cursor pagination.Cursor, size int, descending bool, idColumn string, columns ...string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if !cursor.IsStart() && len(cursor.Keys) != len(columns) {
+			db.AddError(pagination.ErrInvalidCursor)
+			return db
+		}
+
+		// Rows later in the fetch order come after the position
+		direction, after := "ASC", ">"
+		if descending != cursor.Before {
+			direction, after = "DESC", "<"
+		}
+		order := make([]string, 0, len(columns)+1)
+		for _, column := range columns {
+			order = append(order, column+" "+direction)
+		}
+		db = db.Order(strings.Join(order, ", ") + ", " + idColumn + " " + direction)
+
+		if !cursor.IsStart() {
+			// The bound on the first column alone lets its index narrow the scan
+			args := make([]interface{}, 0, len(columns)+2)
+			args = append(args, cursor.Keys[0])
+			for _, key := range cursor.Keys {
+				args = append(args, key)
+			}
+			args = append(args, cursor.ID)
+			placeholders := strings.Repeat("?, ", len(columns)) + "?"
+			db = db.Where(columns[0]+" "+after+"= ? AND ("+strings.Join(columns, ", ")+", "+idColumn+") "+after+" ("+placeholders+")", args...)
+		}
+		return db.Limit(size + 1)
+	}
+}
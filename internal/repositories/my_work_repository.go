@@ -0,0 +1,149 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+// MyWorkRepository reads the unified inbox of an office user
+type MyWorkRepository interface {
+	// FindPendingApprovals counts the pending approvals of every kind and returns the
+	// newest ones of each kind (limit per kind)
+	FindPendingApprovals(limit int) (*models.MyWorkApprovals, error)
+	// FindQueueTickets returns the open tickets in the team queues of the user's nodes,
+	// oldest first
+	FindQueueTickets(userID string, limit int) ([]models.Ticket, int64, error)
+	// FindMentions returns the ticket comments since the given time containing the
+	// handle, newest first
+	FindMentions(handle string, since time.Time, limit int) ([]models.TicketComment, int64, error)
+	// FindOpenAlerts returns the open alerts owned by the user or by nobody, newest first
+	FindOpenAlerts(userID string, limit int) ([]models.Alert, int64, error)
+}
+
+type myWorkRepository struct {
+	db *gorm.DB
+}
+
+func NewMyWorkRepository(db *gorm.DB) MyWorkRepository {
+	return &myWorkRepository{db: db}
+}
+
+func (r *myWorkRepository) FindPendingApprovals(limit int) (*models.MyWorkApprovals, error) {
+	approvals := &models.MyWorkApprovals{Items: []models.MyWorkApproval{}}
+
+	kinds := []struct {
+		kind   string
+		count  *int64
+		query  *gorm.DB
+		fields string
+	}{
+		{
+			kind:   models.ApprovalKindPaymentBatch,
+			count:  &approvals.PaymentBatches,
+			query:  r.db.Model(&models.PaymentBatch{}).Where("status = ?", models.PaymentBatchStatusDraft),
+			fields: "id, NULL AS ticket_id, name AS description, created_by AS requested_by, created_at",
+		},
+		{
+			kind:   models.ApprovalKindDiscount,
+			count:  &approvals.Discounts,
+			query:  r.db.Model(&models.Discount{}).Where("status = ?", models.DiscountStatusPending),
+			fields: "id, ticket_id, document_ref || ': ' || reason AS description, requested_by, created_at",
+		},
+		{
+			kind:   models.ApprovalKindBudgetOverride,
+			count:  &approvals.BudgetOverrides,
+			query:  r.db.Model(&models.TicketBudgetOverride{}).Where("status = ?", models.BudgetOverridePending),
+			fields: "id, ticket_id, reason AS description, requested_by, created_at",
+		},
+		{
+			kind:   models.ApprovalKindStockMovement,
+			count:  &approvals.StockMovements,
+			query:  r.db.Model(&models.StockMovement{}).Where("status = ?", models.MovementStatusPending),
+			fields: "id, ticket_id, COALESCE(approval_reason, type) AS description, performed_by AS requested_by, created_at",
+		},
+	}
+
+	for _, k := range kinds {
+		if err := k.query.Session(&gorm.Session{}).Count(k.count).Error; err != nil {
+			return nil, err
+		}
+		if *k.count == 0 {
+			continue
+		}
+		var items []models.MyWorkApproval
+		err := k.query.Session(&gorm.Session{}).
+			Select(k.fields).
+			Order("created_at DESC").
+			Limit(limit).
+			Scan(&items).Error
+		if err != nil {
+			return nil, err
+		}
+		for i := range items {
+			items[i].Kind = k.kind
+		}
+		approvals.Items = append(approvals.Items, items...)
+		approvals.Total += *k.count
+	}
+	return approvals, nil
+}
+
+func (r *myWorkRepository) FindQueueTickets(userID string, limit int) ([]models.Ticket, int64, error) {
+	var tickets []models.Ticket
+	var total int64
+
+	query := r.db.Model(&models.Ticket{}).
+		Where("status NOT IN ?", queueFinishedStatuses).
+		Where(`id IN (SELECT a.ticket_id FROM ticket_queue_assignments a
+			JOIN team_queues q ON q.id = a.queue_id
+			JOIN memberships m ON m.node_id = q.node_id
+			WHERE m.user_id = ?)`, userID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.
+		Preload("Client").
+		Order("created_at ASC").
+		Limit(limit).
+		Find(&tickets).Error
+	return tickets, total, err
+}
+
+func (r *myWorkRepository) FindMentions(handle string, since time.Time, limit int) ([]models.TicketComment, int64, error) {
+	var comments []models.TicketComment
+	var total int64
+
+	query := r.db.Model(&models.TicketComment{}).
+		Where("created_at >= ? AND body ILIKE ?", since, "%@"+handle+"%")
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.
+		Preload("User").
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&comments).Error
+	return comments, total, err
+}
+
+func (r *myWorkRepository) FindOpenAlerts(userID string, limit int) ([]models.Alert, int64, error) {
+	var alerts []models.Alert
+	var total int64
+
+	query := r.db.Model(&models.Alert{}).
+		Where("status = ?", models.AlertStatusOpen).
+		Where("owner_id = ? OR owner_id IS NULL", userID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&alerts).Error
+	return alerts, total, err
+}
@@ -0,0 +1,83 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+type NotificationRepository interface {
+	FindByUser(userID string, page, size int, filters *models.NotificationFilters) ([]models.Notification, int64, error)
+	FindByID(userID, id string) (*models.Notification, error)
+	CountUnread(userID string) (int64, error)
+	Create(notification *models.Notification) error
+	MarkRead(userID, id string, at time.Time) (int64, error)
+	MarkAllRead(userID string, at time.Time) (int64, error)
+}
+
+type notificationRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationRepository(db *gorm.DB) NotificationRepository {
+	return &notificationRepository{db: db}
+}
+
+func (r *notificationRepository) FindByUser(userID string, page, size int, filters *models.NotificationFilters) ([]models.Notification, int64, error) {
+	var notifications []models.Notification
+	var total int64
+
+	query := r.db.Model(&models.Notification{}).Where("user_id = ?", userID)
+	if filters != nil {
+		if filters.UnreadOnly {
+			query = query.Where("read_at IS NULL")
+		}
+		if filters.Event != "" {
+			query = query.Where("event = ?", filters.Event)
+		}
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("created_at DESC").Offset(page * size).Limit(size).Find(&notifications).Error
+	return notifications, total, err
+}
+
+// FindByID returns a notification of the user
+func (r *notificationRepository) FindByID(userID, id string) (*models.Notification, error) {
+	var notification models.Notification
+	if err := r.db.First(&notification, "id = ? AND user_id = ?", id, userID).Error; err != nil {
+		return nil, err
+	}
+	return &notification, nil
+}
+
+func (r *notificationRepository) CountUnread(userID string) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *notificationRepository) Create(notification *models.Notification) error {
+	return r.db.Create(notification).Error
+}
+
+// MarkRead marks one of the user's notifications as read; 0 when it is not theirs or
+// was already read
+func (r *notificationRepository) MarkRead(userID, id string, at time.Time) (int64, error) {
+	result := r.db.Model(&models.Notification{}).
+		Where("id = ? AND user_id = ? AND read_at IS NULL", id, userID).
+		Update("read_at", at)
+	return result.RowsAffected, result.Error
+}
+
+func (r *notificationRepository) MarkAllRead(userID string, at time.Time) (int64, error) {
+	result := r.db.Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", at)
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,149 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/database"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+// NPSSampleFilter selects the clients eligible for a campaign
+type NPSSampleFilter struct {
+	Size          int
+	NodeID        *uint
+	State         string
+	ActiveSince   time.Time
+	SurveyedSince time.Time // clients invited after this are skipped
+	RequireEmail  bool
+	Clients       models.ClientSegmentFilter
+}
+
+type NPSRepository interface {
+	// Campaigns
+	CreateCampaign(campaign *models.NPSCampaign) error
+	UpdateCampaign(campaign *models.NPSCampaign) error
+	FindCampaignByID(id string) (*models.NPSCampaign, error)
+	FindCampaigns(page, size int) ([]models.NPSCampaign, int64, error)
+
+	// Invitations
+	SelectSample(filter NPSSampleFilter) ([]models.Client, error)
+	CreateInvitations(invitations []models.NPSInvitation) error
+	UpdateInvitation(invitation *models.NPSInvitation) error
+	FindInvitationByToken(token string) (*models.NPSInvitation, error)
+	FindInvitations(campaignID string) ([]models.NPSInvitation, error)
+
+	// Results
+	FindResponses(from, to time.Time, state string) ([]models.NPSResponse, error)
+}
+
+type npsRepository struct {
+	db *gorm.DB
+}
+
+func NewNPSRepository(db *gorm.DB) NPSRepository {
+	return &npsRepository{db: db}
+}
+
+func (r *npsRepository) CreateCampaign(campaign *models.NPSCampaign) error {
+	return r.db.Create(campaign).Error
+}
+
+func (r *npsRepository) UpdateCampaign(campaign *models.NPSCampaign) error {
+	return r.db.Save(campaign).Error
+}
+
+func (r *npsRepository) FindCampaignByID(id string) (*models.NPSCampaign, error) {
+	var campaign models.NPSCampaign
+	if err := r.db.First(&campaign, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &campaign, nil
+}
+
+func (r *npsRepository) FindCampaigns(page, size int) ([]models.NPSCampaign, int64, error) {
+	var campaigns []models.NPSCampaign
+	var total int64
+
+	if err := r.db.Model(&models.NPSCampaign{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := r.db.Order("period_start DESC, created_at DESC").
+		Offset(page * size).
+		Limit(size).
+		Find(&campaigns).Error
+	return campaigns, total, err
+}
+
+// SelectSample picks a random sample of clients that had tickets since filter.ActiveSince
+func (r *npsRepository) SelectSample(filter NPSSampleFilter) ([]models.Client, error) {
+	tickets := r.db.Model(&models.Ticket{}).
+		Select("client_id").
+		Where("created_at >= ?", filter.ActiveSince)
+	if filter.NodeID != nil {
+		tickets = tickets.Where(
+			"node_id IN (SELECT n.id FROM nodes n, nodes root WHERE root.id = ? AND (n.path = root.path OR n.path LIKE root.path || '.%'))",
+			*filter.NodeID,
+		)
+	}
+
+	surveyed := r.db.Model(&models.NPSInvitation{}).
+		Select("client_id").
+		Where("created_at >= ?", filter.SurveyedSince)
+
+	query := r.db.Where("id IN (?)", tickets).
+		Where("id NOT IN (?)", surveyed)
+	if filter.State != "" {
+		query = query.Where("state = ?", filter.State)
+	}
+	if filter.RequireEmail {
+		query = query.Where("email IS NOT NULL AND email <> ''")
+	}
+	query = query.Scopes(clientSegmentScope("id", filter.Clients))
+
+	var clients []models.Client
+	err := query.Order("RANDOM()").Limit(filter.Size).Find(&clients).Error
+	return clients, err
+}
+
+func (r *npsRepository) CreateInvitations(invitations []models.NPSInvitation) error {
+	if len(invitations) == 0 {
+		return nil
+	}
+	return r.db.CreateInBatches(invitations, 200).Error
+}
+
+func (r *npsRepository) UpdateInvitation(invitation *models.NPSInvitation) error {
+	return r.db.Omit("Client").Save(invitation).Error
+}
+
+func (r *npsRepository) FindInvitationByToken(token string) (*models.NPSInvitation, error) {
+	var invitation models.NPSInvitation
+	if err := r.db.Preload("Client").First(&invitation, "token = ?", token).Error; err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+func (r *npsRepository) FindInvitations(campaignID string) ([]models.NPSInvitation, error) {
+	var invitations []models.NPSInvitation
+	err := r.db.Preload("Client").
+		Where("campaign_id = ?", campaignID).
+		Order("created_at ASC").
+		Find(&invitations).Error
+	return invitations, err
+}
+
+// FindResponses returns the answers given in [from, to), optionally for one region
+func (r *npsRepository) FindResponses(from, to time.Time, state string) ([]models.NPSResponse, error) {
+	query := r.db.Scopes(database.Replica).Model(&models.NPSInvitation{}).
+		Select("score, state, responded_at").
+		Where("score IS NOT NULL AND responded_at >= ? AND responded_at < ?", from, to)
+	if state != "" {
+		query = query.Where("state = ?", state)
+	}
+
+	var responses []models.NPSResponse
+	err := query.Order("responded_at ASC").Scan(&responses).Error
+	return responses, err
+}
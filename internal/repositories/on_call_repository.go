@@ -0,0 +1,202 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type OnCallRepository interface {
+	// Schedules
+	FindSchedules() ([]models.OnCallSchedule, error)
+	FindScheduleByID(id string) (*models.OnCallSchedule, error)
+	FindScheduleForState(state string) (*models.OnCallSchedule, error)
+	CreateSchedule(schedule *models.OnCallSchedule) error
+	UpdateSchedule(schedule *models.OnCallSchedule) error
+
+	// Pages
+	FindPages(page, size int, filters *models.OnCallPageFilters) ([]models.OnCallPage, int64, error)
+	FindPageByID(id string) (*models.OnCallPage, error)
+	FindPendingPageByTicket(ticketID string) (*models.OnCallPage, error)
+	FindPendingPagesForTechnician(technicianID string) ([]models.OnCallPage, error)
+	FindExpiredPages(now time.Time) ([]models.OnCallPage, error)
+	// SavePage writes the page with the delivery attempt and the timeline event, when given,
+	// in one transaction
+	SavePage(page *models.OnCallPage, attempt *models.OnCallPageAttempt, event *models.TicketEvent) error
+}
+
+type onCallRepository struct {
+	db *gorm.DB
+}
+
+func NewOnCallRepository(db *gorm.DB) OnCallRepository {
+	return &onCallRepository{db: db}
+}
+
+func preloadMembers(db *gorm.DB) *gorm.DB {
+	return db.Order("position ASC")
+}
+
+func (r *onCallRepository) FindSchedules() ([]models.OnCallSchedule, error) {
+	var schedules []models.OnCallSchedule
+	err := r.db.
+		Preload("Members", preloadMembers).
+		Preload("Members.Technician").
+		Order("state ASC, name ASC").
+		Find(&schedules).Error
+	return schedules, err
+}
+
+func (r *onCallRepository) FindScheduleByID(id string) (*models.OnCallSchedule, error) {
+	var schedule models.OnCallSchedule
+	err := r.db.
+		Preload("Members", preloadMembers).
+		Preload("Members.Technician").
+		Where("id = ?", id).
+		First(&schedule).Error
+	if err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// FindScheduleForState returns the active schedule of a region, falling back to the
+// active schedule without region
+func (r *onCallRepository) FindScheduleForState(state string) (*models.OnCallSchedule, error) {
+	var schedule models.OnCallSchedule
+	err := r.db.
+		Preload("Members", preloadMembers).
+		Preload("Members.Technician").
+		Where("active = ? AND (state = ? OR state = '' OR state IS NULL)", true, state).
+		Order("CASE WHEN state = '' OR state IS NULL THEN 1 ELSE 0 END, created_at ASC").
+		First(&schedule).Error
+	if err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+func (r *onCallRepository) CreateSchedule(schedule *models.OnCallSchedule) error {
+	return r.db.Omit("Members.Technician").Create(schedule).Error
+}
+
+// UpdateSchedule saves the schedule and replaces its members
+func (r *onCallRepository) UpdateSchedule(schedule *models.OnCallSchedule) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Save(schedule).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("schedule_id = ?", schedule.ID).Delete(&models.OnCallMember{}).Error; err != nil {
+			return err
+		}
+		for i := range schedule.Members {
+			schedule.Members[i].ID = 0
+			schedule.Members[i].ScheduleID = schedule.ID
+		}
+		if len(schedule.Members) == 0 {
+			return nil
+		}
+		return tx.Omit(clause.Associations).Create(&schedule.Members).Error
+	})
+}
+
+func (r *onCallRepository) FindPages(page, size int, filters *models.OnCallPageFilters) ([]models.OnCallPage, int64, error) {
+	var pages []models.OnCallPage
+	var total int64
+
+	query := r.db.Model(&models.OnCallPage{})
+	if filters != nil {
+		if filters.Status != "" {
+			query = query.Where("status = ?", filters.Status)
+		}
+		if filters.TicketID != "" {
+			query = query.Where("ticket_id = ?", filters.TicketID)
+		}
+		if filters.TechnicianID != "" {
+			query = query.Where("id IN (SELECT page_id FROM on_call_page_attempts WHERE technician_id = ?)", filters.TechnicianID)
+		}
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.
+		Preload("Ticket").
+		Preload("Technician").
+		Order("created_at DESC").
+		Offset(page * size).
+		Limit(size).
+		Find(&pages).Error
+	return pages, total, err
+}
+
+func (r *onCallRepository) FindPageByID(id string) (*models.OnCallPage, error) {
+	var page models.OnCallPage
+	err := r.db.
+		Preload("Ticket.Client").
+		Preload("Schedule.Members", preloadMembers).
+		Preload("Schedule.Members.Technician").
+		Preload("Technician").
+		Preload("Attempts", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+		Where("id = ?", id).
+		First(&page).Error
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+func (r *onCallRepository) FindPendingPageByTicket(ticketID string) (*models.OnCallPage, error) {
+	var page models.OnCallPage
+	err := r.db.Where("ticket_id = ? AND status = ?", ticketID, models.OnCallPagePending).First(&page).Error
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// FindPendingPagesForTechnician returns the pending pages the technician has been paged for
+func (r *onCallRepository) FindPendingPagesForTechnician(technicianID string) ([]models.OnCallPage, error) {
+	var pages []models.OnCallPage
+	err := r.db.
+		Preload("Ticket.Client").
+		Where("status = ?", models.OnCallPagePending).
+		Where("id IN (SELECT page_id FROM on_call_page_attempts WHERE technician_id = ?)", technicianID).
+		Order("created_at ASC").
+		Find(&pages).Error
+	return pages, err
+}
+
+// FindExpiredPages returns the pending pages whose acknowledgement deadline has passed
+func (r *onCallRepository) FindExpiredPages(now time.Time) ([]models.OnCallPage, error) {
+	var pages []models.OnCallPage
+	err := r.db.
+		Preload("Ticket.Client").
+		Preload("Schedule.Members", preloadMembers).
+		Preload("Schedule.Members.Technician").
+		Where("status = ? AND ack_deadline < ?", models.OnCallPagePending, now).
+		Order("ack_deadline ASC").
+		Find(&pages).Error
+	return pages, err
+}
+
+func (r *onCallRepository) SavePage(page *models.OnCallPage, attempt *models.OnCallPageAttempt, event *models.TicketEvent) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Save(page).Error; err != nil {
+			return err
+		}
+		if attempt != nil {
+			attempt.PageID = page.ID
+			if err := tx.Create(attempt).Error; err != nil {
+				return err
+			}
+		}
+		if event != nil {
+			return tx.Create(event).Error
+		}
+		return nil
+	})
+}
@@ -0,0 +1,88 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type OutboxRepository interface {
+	// WithContext returns the repository running its statements with ctx, inside the tenant it carries
+	WithContext(ctx context.Context) OutboxRepository
+	// ClaimDue locks up to limit pending events due at now and pushes their next attempt
+	// past the lease, so other instances skip them while they are processed
+	ClaimDue(now time.Time, lease time.Duration, limit int) ([]models.OutboxEvent, error)
+	Update(event *models.OutboxEvent) error
+	FindAll(filter models.OutboxEventFilter, page, size int) ([]models.OutboxEvent, int64, error)
+	FindByID(id string) (*models.OutboxEvent, error)
+}
+
+type outboxRepository struct {
+	db *gorm.DB
+}
+
+func NewOutboxRepository(db *gorm.DB) OutboxRepository {
+	return &outboxRepository{db: db}
+}
+
+func (r *outboxRepository) WithContext(ctx context.Context) OutboxRepository {
+	return &outboxRepository{db: r.db.WithContext(ctx)}
+}
+
+func (r *outboxRepository) ClaimDue(now time.Time, lease time.Duration, limit int) ([]models.OutboxEvent, error) {
+	var events []models.OutboxEvent
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.OutboxPending, now).
+			Order("next_attempt_at").
+			Limit(limit).
+			Find(&events).Error
+		if err != nil || len(events) == 0 {
+			return err
+		}
+		ids := make([]string, len(events))
+		for i := range events {
+			ids[i] = events[i].ID
+		}
+		return tx.Model(&models.OutboxEvent{}).
+			Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(lease)).Error
+	})
+	return events, err
+}
+
+func (r *outboxRepository) Update(event *models.OutboxEvent) error {
+	return r.db.Save(event).Error
+}
+
+func (r *outboxRepository) FindAll(filter models.OutboxEventFilter, page, size int) ([]models.OutboxEvent, int64, error) {
+	query := r.db.Model(&models.OutboxEvent{})
+	if filter.Topic != "" {
+		query = query.Where("topic = ?", filter.Topic)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.AggregateID != "" {
+		query = query.Where("aggregate_id = ?", filter.AggregateID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var events []models.OutboxEvent
+	err := query.Order("created_at DESC").Offset(page * size).Limit(size).Find(&events).Error
+	return events, total, err
+}
+
+func (r *outboxRepository) FindByID(id string) (*models.OutboxEvent, error) {
+	var event models.OutboxEvent
+	if err := r.db.First(&event, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
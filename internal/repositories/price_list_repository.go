@@ -0,0 +1,140 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+type PriceListRepository interface {
+	FindAll(filters *models.PriceListFilters) ([]models.PriceList, error)
+	FindByID(id string) (*models.PriceList, error)
+	Create(list *models.PriceList, change *models.PriceChange) error
+	Update(list *models.PriceList, change *models.PriceChange) error
+	FindEntry(listID, entryID string) (*models.PriceListEntry, error)
+	SaveEntry(entry *models.PriceListEntry, change *models.PriceChange) error
+	DeleteEntry(entry *models.PriceListEntry, change *models.PriceChange) error
+	FindApplicable(clientID string, day time.Time) ([]models.PriceList, error)
+	FindChanges(listID string) ([]models.PriceChange, error)
+}
+
+type priceListRepository struct {
+	db *gorm.DB
+}
+
+func NewPriceListRepository(db *gorm.DB) PriceListRepository {
+	return &priceListRepository{db: db}
+}
+
+func (r *priceListRepository) FindAll(filters *models.PriceListFilters) ([]models.PriceList, error) {
+	var lists []models.PriceList
+
+	query := r.db.Preload("Client")
+	if filters != nil {
+		switch filters.ClientID {
+		case "":
+		case "default":
+			query = query.Where("client_id IS NULL")
+		default:
+			query = query.Where("client_id = ?", filters.ClientID)
+		}
+		if filters.ActiveOn != nil {
+			query = query.Where("valid_from <= ? AND (valid_to IS NULL OR valid_to >= ?)", *filters.ActiveOn, *filters.ActiveOn)
+		}
+		if !filters.Inactive {
+			query = query.Where("is_active = ?", true)
+		}
+	}
+
+	err := query.Order("client_id NULLS FIRST, valid_from DESC").Find(&lists).Error
+	return lists, err
+}
+
+func (r *priceListRepository) FindByID(id string) (*models.PriceList, error) {
+	var list models.PriceList
+	err := r.db.
+		Preload("Client").
+		Preload("Entries", func(db *gorm.DB) *gorm.DB {
+			return db.Order("kind, description")
+		}).
+		Preload("Entries.Category").
+		Preload("Entries.Item").
+		First(&list, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// Create saves the list with its first history record
+func (r *priceListRepository) Create(list *models.PriceList, change *models.PriceChange) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Client", "Entries").Create(list).Error; err != nil {
+			return err
+		}
+		change.PriceListID = list.ID
+		return tx.Create(change).Error
+	})
+}
+
+func (r *priceListRepository) Update(list *models.PriceList, change *models.PriceChange) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Client", "Entries").Save(list).Error; err != nil {
+			return err
+		}
+		return tx.Create(change).Error
+	})
+}
+
+func (r *priceListRepository) FindEntry(listID, entryID string) (*models.PriceListEntry, error) {
+	var entry models.PriceListEntry
+	err := r.db.First(&entry, "id = ? AND price_list_id = ?", entryID, listID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (r *priceListRepository) SaveEntry(entry *models.PriceListEntry, change *models.PriceChange) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Category", "Item").Save(entry).Error; err != nil {
+			return err
+		}
+		change.EntryID = &entry.ID
+		return tx.Create(change).Error
+	})
+}
+
+func (r *priceListRepository) DeleteEntry(entry *models.PriceListEntry, change *models.PriceChange) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(entry).Error; err != nil {
+			return err
+		}
+		return tx.Create(change).Error
+	})
+}
+
+// FindApplicable returns the active lists valid on the day for the client and the default
+// lists, with their entries, client lists first and then the most recent ValidFrom
+func (r *priceListRepository) FindApplicable(clientID string, day time.Time) ([]models.PriceList, error) {
+	var lists []models.PriceList
+
+	query := r.db.Preload("Entries").
+		Where("is_active = ?", true).
+		Where("valid_from <= ? AND (valid_to IS NULL OR valid_to >= ?)", day, day)
+	if clientID != "" {
+		query = query.Where("client_id = ? OR client_id IS NULL", clientID)
+	} else {
+		query = query.Where("client_id IS NULL")
+	}
+
+	err := query.Order("client_id NULLS LAST, valid_from DESC, created_at DESC").Find(&lists).Error
+	return lists, err
+}
+
+func (r *priceListRepository) FindChanges(listID string) ([]models.PriceChange, error) {
+	var changes []models.PriceChange
+	err := r.db.Where("price_list_id = ?", listID).Order("changed_at DESC").Find(&changes).Error
+	return changes, err
+}
@@ -0,0 +1,175 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+type PrivacyRepository interface {
+	CreateRequest(request *models.PrivacyRequest) error
+	FindRequestByID(id string) (*models.PrivacyRequest, error)
+	FindRequestsByUser(userID string) ([]models.PrivacyRequest, error)
+	FindRequests(page, size int, filters *models.PrivacyRequestFilters) ([]models.PrivacyRequest, int64, error)
+	// FindScheduledDeletion returns the pending deletion of the user, if any
+	FindScheduledDeletion(userID string) (*models.PrivacyRequest, error)
+	// FindDueDeletions returns the deletions whose grace period ended before now
+	FindDueDeletions(now time.Time) ([]models.PrivacyRequest, error)
+	UpdateRequest(request *models.PrivacyRequest) error
+
+	// CollectPersonalData gathers everything kept about the user for an export
+	CollectPersonalData(userID string) (*models.PersonalDataExport, error)
+	// Anonymize replaces the personal data of the user in place, keeping the user row so
+	// the records pointing to it (tickets, entries, logs) stay valid; the device data of
+	// its sessions is cleared too
+	Anonymize(userID, email, password string) error
+}
+
+type privacyRepository struct {
+	db *gorm.DB
+}
+
+func NewPrivacyRepository(db *gorm.DB) PrivacyRepository {
+	return &privacyRepository{db: db}
+}
+
+func (r *privacyRepository) CreateRequest(request *models.PrivacyRequest) error {
+	return r.db.Create(request).Error
+}
+
+func (r *privacyRepository) FindRequestByID(id string) (*models.PrivacyRequest, error) {
+	var request models.PrivacyRequest
+	if err := r.db.Where("id = ?", id).First(&request).Error; err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+func (r *privacyRepository) FindRequestsByUser(userID string) ([]models.PrivacyRequest, error) {
+	var requests []models.PrivacyRequest
+	err := r.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&requests).Error
+	return requests, err
+}
+
+func (r *privacyRepository) FindRequests(page, size int, filters *models.PrivacyRequestFilters) ([]models.PrivacyRequest, int64, error) {
+	var requests []models.PrivacyRequest
+	var total int64
+
+	query := r.db.Model(&models.PrivacyRequest{})
+	if filters != nil {
+		if filters.UserID != "" {
+			query = query.Where("user_id = ?", filters.UserID)
+		}
+		if filters.Type != "" {
+			query = query.Where("type = ?", filters.Type)
+		}
+		if filters.Status != "" {
+			query = query.Where("status = ?", filters.Status)
+		}
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("created_at DESC").Offset(page * size).Limit(size).Find(&requests).Error
+	return requests, total, err
+}
+
+func (r *privacyRepository) FindScheduledDeletion(userID string) (*models.PrivacyRequest, error) {
+	var request models.PrivacyRequest
+	err := r.db.Where("user_id = ? AND type = ? AND status = ?", userID, models.PrivacyRequestDeletion, models.PrivacyStatusScheduled).
+		First(&request).Error
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+func (r *privacyRepository) FindDueDeletions(now time.Time) ([]models.PrivacyRequest, error) {
+	var requests []models.PrivacyRequest
+	err := r.db.Where("type = ? AND status = ? AND scheduled_for <= ?", models.PrivacyRequestDeletion, models.PrivacyStatusScheduled, now).
+		Order("scheduled_for ASC").
+		Find(&requests).Error
+	return requests, err
+}
+
+func (r *privacyRepository) UpdateRequest(request *models.PrivacyRequest) error {
+	return r.db.Save(request).Error
+}
+
+func (r *privacyRepository) CollectPersonalData(userID string) (*models.PersonalDataExport, error) {
+	export := &models.PersonalDataExport{GeneratedAt: time.Now()}
+
+	var user models.User
+	if err := r.db.Where("id = ?", userID).First(&user).Error; err != nil {
+		return nil, err
+	}
+	export.Profile = &user
+
+	var technician models.Technician
+	err := r.db.Where("user_id = ?", userID).First(&technician).Error
+	switch {
+	case err == nil:
+		export.Technician = &technician
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, err
+	}
+
+	if err := r.db.Preload("Node").Preload("Role").Where("user_id = ?", userID).
+		Order("created_at ASC").Find(&export.Memberships).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.Where("user_id = ?", userID).Order("created_at ASC").Find(&export.ActivityLogs).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.Where("user_id = ?", userID).Order("created_at ASC").Find(&export.SecurityLogs).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.Where("user_id = ?", userID).Order("created_at ASC").Find(&export.Sessions).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.Where("user_id = ?", userID).Order("created_at ASC").Find(&export.PrivacyRequests).Error; err != nil {
+		return nil, err
+	}
+	return export, nil
+}
+
+func (r *privacyRepository) Anonymize(userID, email, password string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// UpdateColumns skips the BeforeSave hook that rebuilds FullName
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).UpdateColumns(map[string]interface{}{
+			"email":           email,
+			"password":        password,
+			"first_name":      "Deleted",
+			"last_name":       "User",
+			"full_name":       "Deleted User",
+			"profile_picture": "",
+			"active":          false,
+			"updated_at":      time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.Membership{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Technician{}).Where("user_id = ?", userID).
+			Update("user_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.RefreshToken{}).Where("user_id = ?", userID).UpdateColumns(map[string]interface{}{
+			"ip_address":  "",
+			"user_agent":  "",
+			"device_name": "",
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.SecurityLog{}).Where("user_id = ?", userID).UpdateColumns(map[string]interface{}{
+			"email":      "",
+			"ip_address": "",
+			"user_agent": "",
+			"location":   "",
+		}).Error
+	})
+}
@@ -0,0 +1,258 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/tenant"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RecallCampaignRepository interface {
+	// WithContext returns the repository running its statements with ctx, inside the tenant it carries
+	WithContext(ctx context.Context) RecallCampaignRepository
+	FindAll(status string) ([]models.RecallCampaign, error)
+	FindByID(id string) (*models.RecallCampaign, error)
+	FindRunning() ([]models.RecallCampaign, error)
+	Create(campaign *models.RecallCampaign) error
+	Update(campaign *models.RecallCampaign) error
+	CategoryExists(id string) (bool, error)
+
+	// Equipment selection
+	FindAssets(criteria *models.RecallCampaignCriteria, limit int) ([]models.RecallCampaignAsset, error)
+	CountAssets(criteria *models.RecallCampaignCriteria) (int, int, error)
+
+	// Targets
+	// StartCampaign stores the targets and updates the campaign in one transaction
+	StartCampaign(campaign *models.RecallCampaign, targets []models.RecallCampaignTarget) error
+	FindPendingTargets(campaignID string, limit int) ([]models.RecallCampaignTarget, error)
+	UpdateTarget(target *models.RecallCampaignTarget) error
+	SkipPendingTargets(campaignID string, at time.Time) error
+	// RefreshProgress recounts the generated and failed targets; returns the pending ones
+	RefreshProgress(campaign *models.RecallCampaign) (int, error)
+	FindTargets(campaignID, status string, page, size int) ([]models.RecallCampaignTarget, int64, error)
+
+	// Completion report
+	CountTargetsByStatus(campaignID string) ([]models.RecallCampaignCount, error)
+	CountTicketsByStatus(campaignID string) ([]models.RecallCampaignCount, error)
+	CountTicketsByState(campaignID string) ([]models.RecallCampaignCount, error)
+}
+
+type recallCampaignRepository struct {
+	db *gorm.DB
+}
+
+func NewRecallCampaignRepository(db *gorm.DB) RecallCampaignRepository {
+	return &recallCampaignRepository{db: db}
+}
+
+func (r *recallCampaignRepository) WithContext(ctx context.Context) RecallCampaignRepository {
+	return &recallCampaignRepository{db: r.db.WithContext(ctx)}
+}
+
+func (r *recallCampaignRepository) FindAll(status string) ([]models.RecallCampaign, error) {
+	var campaigns []models.RecallCampaign
+	query := r.db.Order("created_at DESC")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Find(&campaigns).Error
+	return campaigns, err
+}
+
+func (r *recallCampaignRepository) FindByID(id string) (*models.RecallCampaign, error) {
+	var campaign models.RecallCampaign
+	if err := r.db.First(&campaign, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &campaign, nil
+}
+
+// FindRunning returns the running campaigns, oldest first so they are served in turn
+func (r *recallCampaignRepository) FindRunning() ([]models.RecallCampaign, error) {
+	var campaigns []models.RecallCampaign
+	err := r.db.Where("status = ?", models.RecallCampaignRunning).Order("started_at").Find(&campaigns).Error
+	return campaigns, err
+}
+
+func (r *recallCampaignRepository) Create(campaign *models.RecallCampaign) error {
+	return r.db.Create(campaign).Error
+}
+
+func (r *recallCampaignRepository) Update(campaign *models.RecallCampaign) error {
+	return r.db.Save(campaign).Error
+}
+
+func (r *recallCampaignRepository) CategoryExists(id string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.Category{}).Where("id = ?", id).Count(&count).Error
+	return count > 0, err
+}
+
+// assetsQuery groups the equipment (serial number) registered on the tickets of each client;
+// the install date is the first of those tickets. Tickets generated by campaigns do not
+// count, or every campaign would move the install date of its own equipment.
+func (r *recallCampaignRepository) assetsQuery(criteria *models.RecallCampaignCriteria) *gorm.DB {
+	query := r.db.Table("tickets t").
+		Joins("JOIN clients c ON c.id = t.client_id AND c.deleted_at IS NULL").
+		Where("t.deleted_at IS NULL AND t.campaign_id IS NULL AND t.serial_number <> ''")
+	// The tenant scope doesn't rewrite aliased tables
+	if tenantID, ok := tenant.FromContext(r.db.Statement.Context); ok {
+		query = query.Where("t.tenant_id = ?", tenantID)
+	}
+	if criteria.Brand != "" {
+		query = query.Where("t.computer_brand ILIKE ?", "%"+criteria.Brand+"%")
+	}
+	if criteria.Model != "" {
+		query = query.Where("t.computer_model ILIKE ?", "%"+criteria.Model+"%")
+	}
+	if len(criteria.States) > 0 {
+		query = query.Where("UPPER(c.state) IN ?", []string(criteria.States))
+	}
+	if criteria.City != "" {
+		query = query.Where("LOWER(c.city) = LOWER(?)", criteria.City)
+	}
+	query = query.Group("t.client_id, t.serial_number")
+	if criteria.InstalledFrom != nil {
+		query = query.Having("MIN(t.created_at) >= ?", *criteria.InstalledFrom)
+	}
+	if criteria.InstalledTo != nil {
+		query = query.Having("MIN(t.created_at) < ?", *criteria.InstalledTo)
+	}
+	return query
+}
+
+func (r *recallCampaignRepository) FindAssets(criteria *models.RecallCampaignCriteria, limit int) ([]models.RecallCampaignAsset, error) {
+	var assets []models.RecallCampaignAsset
+	query := r.assetsQuery(criteria).
+		Select(`t.client_id, MAX(c.full_name) AS client_name, MAX(c.city) AS city, MAX(c.state) AS state,
+			MAX(t.computer_brand) AS computer_brand, MAX(t.computer_model) AS computer_model,
+			t.serial_number, MIN(t.created_at) AS installed_at`).
+		Order("installed_at, t.serial_number")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	err := query.Scan(&assets).Error
+	return assets, err
+}
+
+func (r *recallCampaignRepository) CountAssets(criteria *models.RecallCampaignCriteria) (int, int, error) {
+	var counts struct {
+		Total   int
+		Clients int
+	}
+	err := r.db.Table("(?) AS assets", r.assetsQuery(criteria).Select("t.client_id")).
+		Select("COUNT(*) AS total, COUNT(DISTINCT client_id) AS clients").
+		Scan(&counts).Error
+	return counts.Total, counts.Clients, err
+}
+
+func (r *recallCampaignRepository) StartCampaign(campaign *models.RecallCampaign, targets []models.RecallCampaignTarget) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if len(targets) > 0 {
+			if err := tx.Omit("Client").Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(targets, 500).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Save(campaign).Error
+	})
+}
+
+// FindPendingTargets returns the next targets to generate, in install date order
+func (r *recallCampaignRepository) FindPendingTargets(campaignID string, limit int) ([]models.RecallCampaignTarget, error) {
+	var targets []models.RecallCampaignTarget
+	err := r.db.Where("campaign_id = ? AND status = ?", campaignID, models.RecallTargetPending).
+		Order("installed_at, id").
+		Limit(limit).
+		Find(&targets).Error
+	return targets, err
+}
+
+func (r *recallCampaignRepository) UpdateTarget(target *models.RecallCampaignTarget) error {
+	return r.db.Omit("Client").Save(target).Error
+}
+
+func (r *recallCampaignRepository) SkipPendingTargets(campaignID string, at time.Time) error {
+	return r.db.Model(&models.RecallCampaignTarget{}).
+		Where("campaign_id = ? AND status = ?", campaignID, models.RecallTargetPending).
+		Updates(map[string]interface{}{"status": models.RecallTargetSkipped, "processed_at": at}).Error
+}
+
+func (r *recallCampaignRepository) RefreshProgress(campaign *models.RecallCampaign) (int, error) {
+	var counts struct {
+		Created int
+		Failed  int
+		Pending int
+	}
+	err := r.db.Model(&models.RecallCampaignTarget{}).
+		Select(`COUNT(*) FILTER (WHERE status = ?) AS created,
+			COUNT(*) FILTER (WHERE status = ?) AS failed,
+			COUNT(*) FILTER (WHERE status = ?) AS pending`,
+			models.RecallTargetCreated, models.RecallTargetFailed, models.RecallTargetPending).
+		Where("campaign_id = ?", campaign.ID).
+		Scan(&counts).Error
+	if err != nil {
+		return 0, err
+	}
+	campaign.CreatedTickets, campaign.FailedTargets = counts.Created, counts.Failed
+	err = r.db.Model(&models.RecallCampaign{}).Where("id = ?", campaign.ID).UpdateColumns(map[string]interface{}{
+		"created_tickets": counts.Created,
+		"failed_targets":  counts.Failed,
+	}).Error
+	return counts.Pending, err
+}
+
+func (r *recallCampaignRepository) FindTargets(campaignID, status string, page, size int) ([]models.RecallCampaignTarget, int64, error) {
+	var targets []models.RecallCampaignTarget
+	var total int64
+
+	query := r.db.Model(&models.RecallCampaignTarget{}).Where("campaign_id = ?", campaignID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Preload("Client").
+		Order("installed_at, id").
+		Offset(page * size).
+		Limit(size).
+		Find(&targets).Error
+	return targets, total, err
+}
+
+func (r *recallCampaignRepository) CountTargetsByStatus(campaignID string) ([]models.RecallCampaignCount, error) {
+	var counts []models.RecallCampaignCount
+	err := r.db.Model(&models.RecallCampaignTarget{}).
+		Select("status AS key, COUNT(*) AS count").
+		Where("campaign_id = ?", campaignID).
+		Group("status").
+		Order("count DESC").
+		Scan(&counts).Error
+	return counts, err
+}
+
+func (r *recallCampaignRepository) CountTicketsByStatus(campaignID string) ([]models.RecallCampaignCount, error) {
+	var counts []models.RecallCampaignCount
+	err := r.db.Model(&models.Ticket{}).
+		Select("status AS key, COUNT(*) AS count").
+		Where("campaign_id = ?", campaignID).
+		Group("status").
+		Order("count DESC").
+		Scan(&counts).Error
+	return counts, err
+}
+
+func (r *recallCampaignRepository) CountTicketsByState(campaignID string) ([]models.RecallCampaignCount, error) {
+	var counts []models.RecallCampaignCount
+	err := r.db.Table("tickets t").
+		Select("COALESCE(NULLIF(UPPER(c.state), ''), 'NONE') AS key, COUNT(*) AS count").
+		Joins("LEFT JOIN clients c ON c.id = t.client_id").
+		Where("t.campaign_id = ? AND t.deleted_at IS NULL", campaignID).
+		Group("key").
+		Order("count DESC").
+		Scan(&counts).Error
+	return counts, err
+}
@@ -0,0 +1,96 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+type RefreshTokenRepository interface {
+	Create(token *models.RefreshToken) error
+	FindByID(id string) (*models.RefreshToken, error)
+	// FindByHash returns the session whose current or previous token has the hash
+	FindByHash(hash string) (*models.RefreshToken, error)
+	// FindActiveByUser returns the sessions not revoked nor expired, most recently used first
+	FindActiveByUser(userID string, now time.Time) ([]models.RefreshToken, error)
+	// Rotate replaces the current token of the session, if it still is currentHash
+	Rotate(id, currentHash, newHash string, expiresAt, now time.Time) (bool, error)
+	Revoke(id, reason string, now time.Time) (int64, error)
+	// RevokeAllForUser revokes the active sessions of the user except exceptID
+	RevokeAllForUser(userID, exceptID, reason string, now time.Time) (int64, error)
+	IsRevoked(id string) (bool, error)
+}
+
+type refreshTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewRefreshTokenRepository(db *gorm.DB) RefreshTokenRepository {
+	return &refreshTokenRepository{db: db}
+}
+
+func (r *refreshTokenRepository) Create(token *models.RefreshToken) error {
+	return r.db.Create(token).Error
+}
+
+func (r *refreshTokenRepository) FindByID(id string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	if err := r.db.First(&token, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *refreshTokenRepository) FindByHash(hash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	if err := r.db.Where("token_hash = ? OR previous_token_hash = ?", hash, hash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *refreshTokenRepository) FindActiveByUser(userID string, now time.Time) ([]models.RefreshToken, error) {
+	var tokens []models.RefreshToken
+	err := r.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now).
+		Order("last_used_at DESC").
+		Find(&tokens).Error
+	return tokens, err
+}
+
+func (r *refreshTokenRepository) Rotate(id, currentHash, newHash string, expiresAt, now time.Time) (bool, error) {
+	result := r.db.Model(&models.RefreshToken{}).
+		Where("id = ? AND token_hash = ? AND revoked_at IS NULL", id, currentHash).
+		Updates(map[string]interface{}{
+			"token_hash":          newHash,
+			"previous_token_hash": currentHash,
+			"rotations":           gorm.Expr("rotations + 1"),
+			"last_used_at":        now,
+			"expires_at":          expiresAt,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *refreshTokenRepository) Revoke(id, reason string, now time.Time) (int64, error) {
+	result := r.db.Model(&models.RefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Updates(map[string]interface{}{"revoked_at": now, "revoked_reason": reason})
+	return result.RowsAffected, result.Error
+}
+
+func (r *refreshTokenRepository) RevokeAllForUser(userID, exceptID, reason string, now time.Time) (int64, error) {
+	query := r.db.Model(&models.RefreshToken{}).Where("user_id = ? AND revoked_at IS NULL", userID)
+	if exceptID != "" {
+		query = query.Where("id <> ?", exceptID)
+	}
+	result := query.Updates(map[string]interface{}{"revoked_at": now, "revoked_reason": reason})
+	return result.RowsAffected, result.Error
+}
+
+func (r *refreshTokenRepository) IsRevoked(id string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.RefreshToken{}).
+		Where("id = ? AND revoked_at IS NOT NULL", id).
+		Count(&count).Error
+	return count > 0, err
+}
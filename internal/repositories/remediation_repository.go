@@ -0,0 +1,143 @@
+package repositories
+
+import (
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RemediationRepository interface {
+	// Settings
+	FindSettings() ([]models.SystemSetting, error)
+	SaveSetting(setting *models.SystemSetting) error
+
+	// Rules
+	FindRules(enabledOnly bool) ([]models.RemediationRule, error)
+	FindRuleByID(id string) (*models.RemediationRule, error)
+	SaveRule(rule *models.RemediationRule) error
+	DeleteRule(id string) error
+
+	// Actions
+	FindActions(page, size int, status string) ([]models.RemediationAction, int64, error)
+	FindActionByID(id string) (*models.RemediationAction, error)
+	// FindAppliedAction returns the action of the rule still in effect, nil when there is none
+	FindAppliedAction(ruleID string) (*models.RemediationAction, error)
+	// FindLastAction returns the most recent action of the rule, nil when there is none
+	FindLastAction(ruleID string) (*models.RemediationAction, error)
+	CreateAction(action *models.RemediationAction) error
+	// CloseAction saves how an applied action ended, false when it was closed meanwhile
+	CloseAction(action *models.RemediationAction) (bool, error)
+}
+
+type remediationRepository struct {
+	db *gorm.DB
+}
+
+func NewRemediationRepository(db *gorm.DB) RemediationRepository {
+	return &remediationRepository{db: db}
+}
+
+func (r *remediationRepository) FindSettings() ([]models.SystemSetting, error) {
+	var settings []models.SystemSetting
+	err := r.db.Order("key ASC").Find(&settings).Error
+	return settings, err
+}
+
+func (r *remediationRepository) SaveSetting(setting *models.SystemSetting) error {
+	return r.db.Save(setting).Error
+}
+
+func (r *remediationRepository) FindRules(enabledOnly bool) ([]models.RemediationRule, error) {
+	var rules []models.RemediationRule
+	query := r.db.Order("created_at ASC")
+	if enabledOnly {
+		query = query.Where("enabled = ?", true)
+	}
+	err := query.Find(&rules).Error
+	return rules, err
+}
+
+func (r *remediationRepository) FindRuleByID(id string) (*models.RemediationRule, error) {
+	var rule models.RemediationRule
+	if err := r.db.Where("id = ?", id).First(&rule).Error; err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (r *remediationRepository) SaveRule(rule *models.RemediationRule) error {
+	return r.db.Save(rule).Error
+}
+
+func (r *remediationRepository) DeleteRule(id string) error {
+	return r.db.Where("id = ?", id).Delete(&models.RemediationRule{}).Error
+}
+
+func (r *remediationRepository) FindActions(page, size int, status string) ([]models.RemediationAction, int64, error) {
+	var actions []models.RemediationAction
+	var total int64
+
+	query := r.db.Model(&models.RemediationAction{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.
+		Preload("Rule").
+		Preload("Alert").
+		Order("applied_at DESC").
+		Offset(page * size).
+		Limit(size).
+		Find(&actions).Error
+	return actions, total, err
+}
+
+func (r *remediationRepository) FindActionByID(id string) (*models.RemediationAction, error) {
+	var action models.RemediationAction
+	if err := r.db.Preload("Rule").Preload("Alert").Where("id = ?", id).First(&action).Error; err != nil {
+		return nil, err
+	}
+	return &action, nil
+}
+
+func (r *remediationRepository) FindAppliedAction(ruleID string) (*models.RemediationAction, error) {
+	var actions []models.RemediationAction
+	err := r.db.
+		Where("rule_id = ? AND status = ?", ruleID, models.RemediationApplied).
+		Order("applied_at DESC").
+		Limit(1).
+		Find(&actions).Error
+	if err != nil || len(actions) == 0 {
+		return nil, err
+	}
+	return &actions[0], nil
+}
+
+func (r *remediationRepository) FindLastAction(ruleID string) (*models.RemediationAction, error) {
+	var actions []models.RemediationAction
+	err := r.db.Where("rule_id = ?", ruleID).Order("applied_at DESC").Limit(1).Find(&actions).Error
+	if err != nil || len(actions) == 0 {
+		return nil, err
+	}
+	return &actions[0], nil
+}
+
+func (r *remediationRepository) CreateAction(action *models.RemediationAction) error {
+	return r.db.Omit(clause.Associations).Create(action).Error
+}
+
+func (r *remediationRepository) CloseAction(action *models.RemediationAction) (bool, error) {
+	result := r.db.Model(&models.RemediationAction{}).
+		Where("id = ? AND status = ?", action.ID, models.RemediationApplied).
+		Updates(map[string]interface{}{
+			"status":        action.Status,
+			"error":         action.Error,
+			"reverted_at":   action.RevertedAt,
+			"reverted_by":   action.RevertedBy,
+			"revert_reason": action.RevertReason,
+		})
+	return result.RowsAffected == 1, result.Error
+}
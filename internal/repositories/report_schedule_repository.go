@@ -0,0 +1,130 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+type ReportScheduleRepository interface {
+	FindAll(page, size int) ([]models.ReportSchedule, int64, error)
+	FindByID(id string) (*models.ReportSchedule, error)
+	Create(schedule *models.ReportSchedule) error
+	Update(schedule *models.ReportSchedule) error
+	// Delete removes the schedule with its run history
+	Delete(id string) error
+
+	// FindDue returns the active schedules whose next run is at or before now
+	FindDue(now time.Time, limit int) ([]models.ReportSchedule, error)
+	// Claim moves the next run of the schedule from due to next, reporting false when
+	// another instance claimed that run first
+	Claim(id string, due time.Time, next *time.Time) (bool, error)
+	// RecordOutcome sets the last run of the schedule and counts its consecutive failures
+	RecordOutcome(id, status string, at time.Time) error
+
+	CreateRun(run *models.ReportRun) error
+	UpdateRun(run *models.ReportRun) error
+	FindRuns(scheduleID string, page, size int) ([]models.ReportRun, int64, error)
+	FindRunByID(scheduleID, id string) (*models.ReportRun, error)
+}
+
+type reportScheduleRepository struct {
+	db *gorm.DB
+}
+
+func NewReportScheduleRepository(db *gorm.DB) ReportScheduleRepository {
+	return &reportScheduleRepository{db: db}
+}
+
+func (r *reportScheduleRepository) FindAll(page, size int) ([]models.ReportSchedule, int64, error) {
+	var total int64
+	if err := r.db.Model(&models.ReportSchedule{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var schedules []models.ReportSchedule
+	err := r.db.Order("name").Offset(page * size).Limit(size).Find(&schedules).Error
+	return schedules, total, err
+}
+
+func (r *reportScheduleRepository) FindByID(id string) (*models.ReportSchedule, error) {
+	var schedule models.ReportSchedule
+	if err := r.db.First(&schedule, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+func (r *reportScheduleRepository) Create(schedule *models.ReportSchedule) error {
+	return r.db.Create(schedule).Error
+}
+
+func (r *reportScheduleRepository) Update(schedule *models.ReportSchedule) error {
+	return r.db.Save(schedule).Error
+}
+
+func (r *reportScheduleRepository) Delete(id string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&models.ReportSchedule{}, "id = ?", id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Delete(&models.ReportRun{}, "schedule_id = ?", id).Error
+	})
+}
+
+func (r *reportScheduleRepository) FindDue(now time.Time, limit int) ([]models.ReportSchedule, error) {
+	var schedules []models.ReportSchedule
+	err := r.db.Where("active = ? AND next_run_at <= ?", true, now).
+		Order("next_run_at").Limit(limit).Find(&schedules).Error
+	return schedules, err
+}
+
+func (r *reportScheduleRepository) Claim(id string, due time.Time, next *time.Time) (bool, error) {
+	result := r.db.Model(&models.ReportSchedule{}).
+		Where("id = ? AND next_run_at = ?", id, due).
+		Update("next_run_at", next)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *reportScheduleRepository) RecordOutcome(id, status string, at time.Time) error {
+	failures := gorm.Expr("0")
+	if status == models.ReportRunFailed {
+		failures = gorm.Expr("consecutive_failures + 1")
+	}
+	return r.db.Model(&models.ReportSchedule{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"last_run_at":          at,
+		"last_status":          status,
+		"consecutive_failures": failures,
+	}).Error
+}
+
+func (r *reportScheduleRepository) CreateRun(run *models.ReportRun) error {
+	return r.db.Create(run).Error
+}
+
+func (r *reportScheduleRepository) UpdateRun(run *models.ReportRun) error {
+	return r.db.Save(run).Error
+}
+
+func (r *reportScheduleRepository) FindRuns(scheduleID string, page, size int) ([]models.ReportRun, int64, error) {
+	query := r.db.Model(&models.ReportRun{}).Where("schedule_id = ?", scheduleID)
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var runs []models.ReportRun
+	err := query.Order("started_at DESC").Offset(page * size).Limit(size).Find(&runs).Error
+	return runs, total, err
+}
+
+func (r *reportScheduleRepository) FindRunByID(scheduleID, id string) (*models.ReportRun, error) {
+	var run models.ReportRun
+	if err := r.db.First(&run, "id = ? AND schedule_id = ?", id, scheduleID).Error; err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
@@ -0,0 +1,61 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+type RequestMetricRepository interface {
+	CreateBatch(metrics []models.RequestMetric) error
+	LatencyByRoute(since time.Time) ([]models.RouteLatency, error)
+	PayloadByRoute(since time.Time) ([]models.RoutePayload, error)
+	DeleteBefore(before time.Time) (int64, error)
+}
+
+type requestMetricRepository struct {
+	db *gorm.DB
+}
+
+func NewRequestMetricRepository(db *gorm.DB) RequestMetricRepository {
+	return &requestMetricRepository{db: db}
+}
+
+func (r *requestMetricRepository) CreateBatch(metrics []models.RequestMetric) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+	return r.db.CreateInBatches(metrics, 500).Error
+}
+
+// LatencyByRoute returns the p95 response time of every route seen since the given time
+func (r *requestMetricRepository) LatencyByRoute(since time.Time) ([]models.RouteLatency, error) {
+	var rows []models.RouteLatency
+	err := r.db.Model(&models.RequestMetric{}).
+		Select("method, path, PERCENTILE_CONT(0.95) WITHIN GROUP (ORDER BY response_time) AS p95_ms, COUNT(*) AS samples").
+		Where("created_at >= ?", since).
+		Group("method, path").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// PayloadByRoute returns the request and response sizes of every route seen since the
+// given time, largest responses first
+func (r *requestMetricRepository) PayloadByRoute(since time.Time) ([]models.RoutePayload, error) {
+	var rows []models.RoutePayload
+	err := r.db.Model(&models.RequestMetric{}).
+		Select("method, path, COUNT(*) AS samples, "+
+			"AVG(request_bytes) AS avg_request_bytes, MAX(request_bytes) AS max_request_bytes, "+
+			"AVG(response_bytes) AS avg_response_bytes, MAX(response_bytes) AS max_response_bytes, "+
+			"PERCENTILE_CONT(0.95) WITHIN GROUP (ORDER BY response_bytes) AS p95_response_bytes").
+		Where("created_at >= ?", since).
+		Group("method, path").
+		Order("avg_response_bytes DESC").
+		Scan(&rows).Error
+	return rows, err
+}
+
+func (r *requestMetricRepository) DeleteBefore(before time.Time) (int64, error) {
+	return deleteInBatches(r.db, "request_metrics", &models.RequestMetric{}, "created_at < ?", before)
+}
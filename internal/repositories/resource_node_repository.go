@@ -0,0 +1,66 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+// ResourceNodeRepository returns the hierarchy node of the records the permission checks
+// are made on. Financial entries and stock movements belong to the node of their ticket;
+// records outside the hierarchy have no node (nil).
+type ResourceNodeRepository interface {
+	// WithContext returns the repository running its statements with ctx, inside the tenant it carries
+	WithContext(ctx context.Context) ResourceNodeRepository
+	TicketNode(id string) (*uint, error)
+	FinancialEntryNode(id string) (*uint, error)
+	StockMovementNode(id string) (*uint, error)
+}
+
+type resourceNodeRepository struct {
+	db *gorm.DB
+}
+
+func NewResourceNodeRepository(db *gorm.DB) ResourceNodeRepository {
+	return &resourceNodeRepository{db: db}
+}
+
+func (r *resourceNodeRepository) WithContext(ctx context.Context) ResourceNodeRepository {
+	return &resourceNodeRepository{db: r.db.WithContext(ctx)}
+}
+
+func (r *resourceNodeRepository) TicketNode(id string) (*uint, error) {
+	return r.node(r.db.Model(&models.Ticket{}).Where("tickets.id = ?", id), id, "tickets.node_id")
+}
+
+func (r *resourceNodeRepository) FinancialEntryNode(id string) (*uint, error) {
+	query := r.db.Model(&models.FinancialEntry{}).
+		Joins("LEFT JOIN tickets ON tickets.id = financial_entries.ticket_id").
+		Where("financial_entries.id = ?", id)
+	return r.node(query, id, "tickets.node_id")
+}
+
+func (r *resourceNodeRepository) StockMovementNode(id string) (*uint, error) {
+	query := r.db.Model(&models.StockMovement{}).
+		Joins("LEFT JOIN tickets ON tickets.id = stock_movements.ticket_id").
+		Where("stock_movements.id = ?", id)
+	return r.node(query, id, "tickets.node_id")
+}
+
+// node plucks the node column of the single record the query selects
+func (r *resourceNodeRepository) node(query *gorm.DB, id, column string) (*uint, error) {
+	// IDs are UUIDs; anything else can't name a record
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	var nodes []*uint
+	if err := query.Limit(1).Pluck(column, &nodes).Error; err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return nodes[0], nil
+}
@@ -0,0 +1,205 @@
+package repositories
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/tenant"
+	"gorm.io/gorm"
+)
+
+type SandboxRepository interface {
+	FindAll(status string) ([]models.SandboxTenant, error)
+	FindByID(id string) (*models.SandboxTenant, error)
+	// FindExpired returns the active sandboxes whose expiry passed
+	FindExpired(now time.Time) ([]models.SandboxTenant, error)
+	// Provision creates the sandbox tenant and node, the partner account and the demo data
+	// in one transaction
+	Provision(sandbox *models.SandboxTenant, seed *models.SandboxSeed) error
+	// Cleanup removes the sandbox data, disables the partner account and tenant and marks
+	// it EXPIRED
+	Cleanup(sandbox *models.SandboxTenant, disabledEmail string, now time.Time) error
+}
+
+type sandboxRepository struct {
+	db *gorm.DB
+}
+
+func NewSandboxRepository(db *gorm.DB) SandboxRepository {
+	return &sandboxRepository{db: db}
+}
+
+func (r *sandboxRepository) FindAll(status string) ([]models.SandboxTenant, error) {
+	var sandboxes []models.SandboxTenant
+	query := r.db.Model(&models.SandboxTenant{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Order("created_at DESC").Find(&sandboxes).Error
+	return sandboxes, err
+}
+
+func (r *sandboxRepository) FindByID(id string) (*models.SandboxTenant, error) {
+	var sandbox models.SandboxTenant
+	if err := r.db.Preload("Resources").First(&sandbox, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &sandbox, nil
+}
+
+func (r *sandboxRepository) FindExpired(now time.Time) ([]models.SandboxTenant, error) {
+	var sandboxes []models.SandboxTenant
+	err := r.db.Where("status = ? AND expires_at <= ?", models.SandboxStatusActive, now).
+		Order("expires_at").Find(&sandboxes).Error
+	return sandboxes, err
+}
+
+func (r *sandboxRepository) Provision(sandbox *models.SandboxTenant, seed *models.SandboxSeed) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var hierarchy models.Hierarchy
+		err := tx.Where("name = ?", models.SandboxHierarchyName).First(&hierarchy).Error
+		if err == gorm.ErrRecordNotFound {
+			hierarchy = models.Hierarchy{
+				Name:        models.SandboxHierarchyName,
+				Icon:        "flask",
+				Description: "Tenants temporários para testes de integração",
+			}
+			err = tx.Create(&hierarchy).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		var role models.Role
+		if err := tx.Where("name = ?", models.SandboxRoleName).First(&role).Error; err != nil {
+			return fmt.Errorf("sandbox role %q: %w", models.SandboxRoleName, err)
+		}
+
+		if err := tx.Create(seed.Tenant).Error; err != nil {
+			return err
+		}
+		sandbox.TenantID = seed.Tenant.ID
+
+		node := &models.Node{HierarchyID: hierarchy.ID, Name: sandbox.Name}
+		if err := tx.Create(node).Error; err != nil {
+			return err
+		}
+		node.Path = fmt.Sprintf("%d", node.ID)
+		if err := tx.Save(node).Error; err != nil {
+			return err
+		}
+		sandbox.NodeID = node.ID
+
+		// The demo data and the partner account belong to the sandbox tenant
+		seeded := tx.WithContext(tenant.WithID(tx.Statement.Context, seed.Tenant.ID))
+		if err := seeded.Create(seed.User).Error; err != nil {
+			return err
+		}
+		membership := &models.Membership{
+			UserID:    seed.User.ID,
+			NodeID:    node.ID,
+			RoleID:    role.ID,
+			GrantedBy: &sandbox.CreatedBy,
+			GrantedAt: time.Now(),
+		}
+		if err := tx.Create(membership).Error; err != nil {
+			return err
+		}
+		seed.Technician.UserID = &seed.User.ID
+		seed.Technician.NodeID = &node.ID
+		if err := seeded.Create(seed.Technician).Error; err != nil {
+			return err
+		}
+		sandbox.UserID = seed.User.ID
+		sandbox.TechnicianID = seed.Technician.ID
+		sandbox.Resources = append(sandbox.Resources,
+			models.SandboxResource{ResourceType: models.SandboxResourceTechnician, ResourceID: seed.Technician.ID})
+
+		for i := range seed.Clients {
+			seed.Clients[i].NodeID = &node.ID
+			if err := seeded.Create(&seed.Clients[i]).Error; err != nil {
+				return err
+			}
+			sandbox.Resources = append(sandbox.Resources,
+				models.SandboxResource{ResourceType: models.SandboxResourceClient, ResourceID: seed.Clients[i].ID})
+		}
+		for i := range seed.Tickets {
+			ticket := &seed.Tickets[i]
+			ticket.NodeID = &node.ID
+			if err := seeded.Omit("Technicians", "Assignments").Create(ticket).Error; err != nil {
+				return err
+			}
+			assignment := models.TicketTechnician{TicketID: ticket.ID, TechnicianID: seed.Technician.ID, Role: models.AssignmentRoleLead}
+			if err := tx.Create(&assignment).Error; err != nil {
+				return err
+			}
+			sandbox.Resources = append(sandbox.Resources,
+				models.SandboxResource{ResourceType: models.SandboxResourceTicket, ResourceID: ticket.ID})
+		}
+
+		return tx.Create(sandbox).Error
+	})
+}
+
+func (r *sandboxRepository) Cleanup(sandbox *models.SandboxTenant, disabledEmail string, now time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		ids := map[string][]string{}
+		for _, resource := range sandbox.Resources {
+			ids[resource.ResourceType] = append(ids[resource.ResourceType], resource.ResourceID)
+		}
+
+		// Tickets of the sandbox node include the ones the partner created
+		tickets := tx.Model(&models.Ticket{}).Select("id").Where("node_id = ?", sandbox.NodeID)
+		if len(ids[models.SandboxResourceTicket]) > 0 {
+			tickets = tickets.Or("id IN ?", ids[models.SandboxResourceTicket])
+		}
+		var ticketIDs []string
+		if err := tickets.Pluck("id", &ticketIDs).Error; err != nil {
+			return err
+		}
+		if len(ticketIDs) > 0 {
+			if err := tx.Exec("DELETE FROM ticket_technicians WHERE ticket_id IN ?", ticketIDs).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("id IN ?", ticketIDs).Delete(&models.Ticket{}).Error; err != nil {
+				return err
+			}
+		}
+		if clientIDs := ids[models.SandboxResourceClient]; len(clientIDs) > 0 {
+			if err := tx.Where("id IN ?", clientIDs).Delete(&models.Client{}).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Exec("DELETE FROM ticket_technicians WHERE technician_id = ?", sandbox.TechnicianID).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("id = ?", sandbox.TechnicianID).Delete(&models.Technician{}).Error; err != nil {
+			return err
+		}
+
+		// The partner account is kept (logs point to it) but can no longer sign in
+		if err := tx.Model(&models.User{}).Where("id = ?", sandbox.UserID).
+			Updates(map[string]interface{}{"active": false, "email": disabledEmail}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("node_id = ?", sandbox.NodeID).Delete(&models.Membership{}).Error; err != nil {
+			return err
+		}
+		if sandbox.TenantID != "" {
+			if err := tx.Model(&models.Tenant{}).Where("id = ?", sandbox.TenantID).Update("active", false).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("path LIKE ? OR id = ?", fmt.Sprintf("%d.%%", sandbox.NodeID), sandbox.NodeID).
+			Delete(&models.Node{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(sandbox).Updates(map[string]interface{}{
+			"status":     models.SandboxStatusExpired,
+			"cleaned_at": now,
+		}).Error
+	})
+}
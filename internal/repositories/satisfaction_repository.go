@@ -0,0 +1,113 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/database"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+type SatisfactionRepository interface {
+	// FindClosedWithoutSurvey returns the tickets closed since the given time that have
+	// no survey yet, with their client and crew
+	FindClosedWithoutSurvey(since time.Time, limit int) ([]models.Ticket, error)
+	Create(feedback *models.TicketFeedback) error
+	Update(feedback *models.TicketFeedback) error
+	FindByToken(token string) (*models.TicketFeedback, error)
+	FindByTicket(ticketID string) (*models.TicketFeedback, error)
+	// FindDueReminders returns the unanswered e-mail surveys last contacted before the given
+	// time that can still get a reminder
+	FindDueReminders(contactedBefore time.Time, maxReminders, limit int) ([]models.TicketFeedback, error)
+
+	// Reports
+	FindResponses(from, to time.Time) ([]models.SatisfactionResponse, error)
+	CountSurveys(from, to time.Time) (int64, error)
+}
+
+type satisfactionRepository struct {
+	db *gorm.DB
+}
+
+func NewSatisfactionRepository(db *gorm.DB) SatisfactionRepository {
+	return &satisfactionRepository{db: db}
+}
+
+func (r *satisfactionRepository) FindClosedWithoutSurvey(since time.Time, limit int) ([]models.Ticket, error) {
+	surveyed := r.db.Model(&models.TicketFeedback{}).Select("ticket_id")
+
+	var tickets []models.Ticket
+	err := r.db.
+		Preload("Client").
+		Preload("Assignments.Technician").
+		Where("status = ? AND closed_at >= ?", models.TicketStatusClosed, since).
+		Where("id NOT IN (?)", surveyed).
+		Order("closed_at ASC").
+		Limit(limit).
+		Find(&tickets).Error
+	return tickets, err
+}
+
+func (r *satisfactionRepository) Create(feedback *models.TicketFeedback) error {
+	return r.db.Omit("Ticket").Create(feedback).Error
+}
+
+func (r *satisfactionRepository) Update(feedback *models.TicketFeedback) error {
+	return r.db.Omit("Ticket").Save(feedback).Error
+}
+
+func (r *satisfactionRepository) FindByToken(token string) (*models.TicketFeedback, error) {
+	var feedback models.TicketFeedback
+	err := r.db.
+		Preload("Ticket.Client").
+		Preload("Ticket.Assignments.Technician").
+		First(&feedback, "token = ?", token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &feedback, nil
+}
+
+func (r *satisfactionRepository) FindByTicket(ticketID string) (*models.TicketFeedback, error) {
+	var feedback models.TicketFeedback
+	if err := r.db.First(&feedback, "ticket_id = ?", ticketID).Error; err != nil {
+		return nil, err
+	}
+	return &feedback, nil
+}
+
+func (r *satisfactionRepository) FindDueReminders(contactedBefore time.Time, maxReminders, limit int) ([]models.TicketFeedback, error) {
+	var surveys []models.TicketFeedback
+	err := r.db.
+		Preload("Ticket.Client").
+		Where("responded_at IS NULL AND sent_at IS NOT NULL AND channel = ?", models.NPSChannelEmail).
+		Where("reminders < ? AND expires_at > ?", maxReminders, time.Now()).
+		Where("COALESCE(last_reminder_at, sent_at) <= ?", contactedBefore).
+		Order("sent_at ASC").
+		Limit(limit).
+		Find(&surveys).Error
+	return surveys, err
+}
+
+// FindResponses returns the answers given in [from, to) with the technician and category
+// names; surveys without a technician or a category get empty ones
+func (r *satisfactionRepository) FindResponses(from, to time.Time) ([]models.SatisfactionResponse, error) {
+	var responses []models.SatisfactionResponse
+	err := r.db.Scopes(database.Replica).Table("ticket_feedback f").
+		Select(`COALESCE(f.technician_id, '') AS technician_id, COALESCE(t.full_name, '') AS technician_name,
+			COALESCE(CAST(f.category_id AS text), '') AS category_id, COALESCE(c.name, '') AS category_name,
+			f.csat_score, f.nps_score, f.responded_at`).
+		Joins("LEFT JOIN technicians t ON t.id = f.technician_id").
+		Joins("LEFT JOIN categories c ON c.id = f.category_id").
+		Where("f.csat_score IS NOT NULL AND f.responded_at >= ? AND f.responded_at < ?", from, to).
+		Scan(&responses).Error
+	return responses, err
+}
+
+func (r *satisfactionRepository) CountSurveys(from, to time.Time) (int64, error) {
+	var count int64
+	err := r.db.Scopes(database.Replica).Model(&models.TicketFeedback{}).
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Count(&count).Error
+	return count, err
+}
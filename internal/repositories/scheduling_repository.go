@@ -0,0 +1,213 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SchedulingRepository interface {
+	CreateLink(link *models.SchedulingLink) error
+	FindLinkByToken(token string) (*models.SchedulingLink, error)
+	FindScheduledTickets(technicianIDs []string, from, to time.Time) ([]models.Ticket, error)
+	BookSchedule(booking *ScheduleBooking, check func(scheduled []models.Ticket) error) error
+	FindCalendarTickets(query *models.CalendarQuery) ([]models.Ticket, error)
+
+	// Calendar feed
+	FindCalendarToken(technicianID string) (*models.TechnicianCalendarToken, error)
+	SaveCalendarToken(token *models.TechnicianCalendarToken) error
+
+	// Settings
+	GetSettings(nodeID *uint) (*models.SchedulingSettings, error)
+	ListSettings() ([]models.SchedulingSettings, error)
+	UpsertSettings(settings *models.SchedulingSettings) error
+}
+
+// ScheduleBooking is an appointment written by BookSchedule
+type ScheduleBooking struct {
+	TicketID   string
+	Technician models.Technician
+	Start, End time.Time
+	// Day bounds the appointments handed to the check
+	DayStart, DayEnd time.Time
+	// LinkID, when set, is the self-scheduling link consumed by the booking
+	LinkID string
+}
+
+type schedulingRepository struct {
+	db *gorm.DB
+}
+
+func NewSchedulingRepository(db *gorm.DB) SchedulingRepository {
+	return &schedulingRepository{db: db}
+}
+
+func (r *schedulingRepository) CreateLink(link *models.SchedulingLink) error {
+	return r.db.Create(link).Error
+}
+
+func (r *schedulingRepository) FindLinkByToken(token string) (*models.SchedulingLink, error) {
+	var link models.SchedulingLink
+	err := r.db.Where("token = ?", token).First(&link).Error
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// FindScheduledTickets returns open tickets of the given technicians whose
+// appointment window overlaps [from, to)
+func (r *schedulingRepository) FindScheduledTickets(technicianIDs []string, from, to time.Time) ([]models.Ticket, error) {
+	return findScheduledTickets(r.db, technicianIDs, from, to)
+}
+
+func findScheduledTickets(db *gorm.DB, technicianIDs []string, from, to time.Time) ([]models.Ticket, error) {
+	var tickets []models.Ticket
+	if len(technicianIDs) == 0 {
+		return tickets, nil
+	}
+	err := db.
+		Preload("Technicians").
+		Preload("Client").
+		Where("id IN (SELECT ticket_id FROM ticket_technicians WHERE technician_id IN ?)", technicianIDs).
+		Where("scheduled_start IS NOT NULL AND scheduled_end IS NOT NULL").
+		Where("scheduled_start < ? AND scheduled_end > ?", to, from).
+		Where("status NOT IN ?", []string{string(models.TicketStatusClosed), string(models.TicketStatusUnproductive), string(models.TicketStatusCancelled)}).
+		Order("scheduled_start ASC").
+		Find(&tickets).Error
+	return tickets, err
+}
+
+// FindCalendarTickets returns the tickets whose appointment window overlaps the query range,
+// closed ones included so the calendar shows the work done; cancelled ones are left out
+func (r *schedulingRepository) FindCalendarTickets(query *models.CalendarQuery) ([]models.Ticket, error) {
+	var tickets []models.Ticket
+	db := r.db.Scopes(NodeScope(query.Scope, "tickets.node_id")).
+		Preload("Technicians").
+		Preload("Client").
+		Where("scheduled_start IS NOT NULL AND scheduled_end IS NOT NULL").
+		Where("scheduled_start < ? AND scheduled_end > ?", query.To, query.From).
+		Where("status <> ?", models.TicketStatusCancelled)
+	if query.TechnicianID != "" {
+		db = db.Where("id IN (SELECT ticket_id FROM ticket_technicians WHERE technician_id = ?)", query.TechnicianID)
+	}
+	err := db.Order("scheduled_start ASC").Find(&tickets).Error
+	return tickets, err
+}
+
+// BookSchedule consumes the link, runs check against the technician's
+// appointments of the day and writes the schedule in one transaction. The
+// technician row stays locked until commit, so concurrent bookings of the same
+// technician are checked one after the other. A link that is already used or
+// expired returns gorm.ErrRecordNotFound.
+func (r *schedulingRepository) BookSchedule(booking *ScheduleBooking, check func(scheduled []models.Ticket) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if booking.LinkID != "" {
+			now := time.Now()
+			result := tx.Model(&models.SchedulingLink{}).
+				Where("id = ? AND used_at IS NULL AND expires_at > ?", booking.LinkID, now).
+				Update("used_at", now)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return gorm.ErrRecordNotFound
+			}
+		}
+
+		var technician models.Technician
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").First(&technician, "id = ?", booking.Technician.ID).Error; err != nil {
+			return err
+		}
+		scheduled, err := findScheduledTickets(tx, []string{booking.Technician.ID}, booking.DayStart, booking.DayEnd)
+		if err != nil {
+			return err
+		}
+		if err := check(scheduled); err != nil {
+			return err
+		}
+
+		var ticket models.Ticket
+		if err := tx.First(&ticket, "id = ?", booking.TicketID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&ticket).Updates(map[string]interface{}{
+			"scheduled_start": booking.Start,
+			"scheduled_end":   booking.End,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&ticket).Association("Technicians").Replace([]models.Technician{booking.Technician})
+	})
+}
+
+func (r *schedulingRepository) FindCalendarToken(technicianID string) (*models.TechnicianCalendarToken, error) {
+	var token models.TechnicianCalendarToken
+	if err := r.db.Where("technician_id = ?", technicianID).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// SaveCalendarToken creates or replaces the feed token of a technician
+func (r *schedulingRepository) SaveCalendarToken(token *models.TechnicianCalendarToken) error {
+	return r.db.Save(token).Error
+}
+
+// GetSettings returns the settings for a node, falling back to the global
+// settings and then to the defaults
+func (r *schedulingRepository) GetSettings(nodeID *uint) (*models.SchedulingSettings, error) {
+	var settings models.SchedulingSettings
+	if nodeID != nil {
+		err := r.db.Where("node_id = ?", *nodeID).First(&settings).Error
+		if err == nil {
+			return &settings, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+
+	err := r.db.Where("node_id IS NULL").First(&settings).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.DefaultSchedulingSettings(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+func (r *schedulingRepository) ListSettings() ([]models.SchedulingSettings, error) {
+	var settings []models.SchedulingSettings
+	err := r.db.Order("node_id ASC NULLS FIRST").Find(&settings).Error
+	return settings, err
+}
+
+func (r *schedulingRepository) UpsertSettings(settings *models.SchedulingSettings) error {
+	if settings.ID != 0 {
+		return r.db.Save(settings).Error
+	}
+
+	// Another update may have created the row of the node meanwhile (the unique indexes
+	// refuse a second one): then update that row
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(settings)
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.Error
+	}
+	query := r.db.Where("node_id IS NULL")
+	if settings.NodeID != nil {
+		query = r.db.Where("node_id = ?", *settings.NodeID)
+	}
+	var existing models.SchedulingSettings
+	if err := query.First(&existing).Error; err != nil {
+		return err
+	}
+	settings.ID = existing.ID
+	settings.CreatedAt = existing.CreatedAt
+	return r.db.Save(settings).Error
+}
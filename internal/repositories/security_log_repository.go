@@ -124,8 +124,7 @@ func (r *securityLogRepository) Delete(id string) error {
 }
 
 func (r *securityLogRepository) DeleteOlderThan(date time.Time) (int64, error) {
-	result := r.db.Delete(&models.SecurityLog{}, "created_at < ?", date)
-	return result.RowsAffected, result.Error
+	return deleteInBatches(r.db, "security_logs", &models.SecurityLog{}, "created_at < ?", date)
 }
 
 func (r *securityLogRepository) CountByAction(action string, since time.Time) (int64, error) {
@@ -0,0 +1,230 @@
+package repositories
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/database"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SLARepository interface {
+	FindPauses(ticketID string) ([]models.TicketSLAPause, error)
+	FindPausesByTickets(ticketIDs []string) ([]models.TicketSLAPause, error)
+	FindClosedBetween(from, to time.Time) ([]models.Ticket, error)
+	FindOpenTickets() ([]models.Ticket, error)
+	// FirstResponses returns when each ticket got its first status change, comment or check-in
+	FirstResponses(ticketIDs []string) (map[string]time.Time, error)
+
+	// Policies
+	FindPolicies(activeOnly bool) ([]models.SLAPolicy, error)
+	FindPolicyByID(id string) (*models.SLAPolicy, error)
+	CreatePolicy(policy *models.SLAPolicy) error
+	UpdatePolicy(policy *models.SLAPolicy) error
+	DeletePolicy(id string) error
+	CategoryExists(id string) (bool, error)
+
+	// Breaches
+	FindBreaches(ticketIDs []string) ([]models.TicketSLABreach, error)
+	// CreateBreach records the breach with its timeline event; false when it was already recorded
+	CreateBreach(breach *models.TicketSLABreach) (bool, error)
+	FindRecentBreaches(limit int) ([]models.TicketSLABreach, error)
+}
+
+type slaRepository struct {
+	db *gorm.DB
+}
+
+func NewSLARepository(db *gorm.DB) SLARepository {
+	return &slaRepository{db: db}
+}
+
+func (r *slaRepository) FindPauses(ticketID string) ([]models.TicketSLAPause, error) {
+	var pauses []models.TicketSLAPause
+	err := r.db.Where("ticket_id = ?", ticketID).Order("started_at").Find(&pauses).Error
+	return pauses, err
+}
+
+func (r *slaRepository) FindPausesByTickets(ticketIDs []string) ([]models.TicketSLAPause, error) {
+	var pauses []models.TicketSLAPause
+	if len(ticketIDs) == 0 {
+		return pauses, nil
+	}
+	err := r.db.Where("ticket_id IN ?", ticketIDs).Order("ticket_id, started_at").Find(&pauses).Error
+	return pauses, err
+}
+
+// FindClosedBetween returns the service tickets closed in [from, to); tickets closed before
+// closed_at was stamped fall back to their last update
+func (r *slaRepository) FindClosedBetween(from, to time.Time) ([]models.Ticket, error) {
+	var tickets []models.Ticket
+	err := r.db.Scopes(database.Replica).Select("id, os_number, priority, source, category_id, status, created_at, updated_at, closed_at").
+		Where("status = ? AND type <> ?", models.TicketStatusClosed, models.TicketTypeComplaint).
+		Where("COALESCE(closed_at, updated_at) >= ? AND COALESCE(closed_at, updated_at) < ?", from, to).
+		Find(&tickets).Error
+	return tickets, err
+}
+
+// FindOpenTickets returns the service tickets whose SLA clock is still running or paused
+func (r *slaRepository) FindOpenTickets() ([]models.Ticket, error) {
+	var tickets []models.Ticket
+	err := r.db.Select("id, os_number, priority, source, category_id, status, created_at, updated_at, closed_at").
+		Where("status NOT IN ? AND type <> ?", []string{
+			string(models.TicketStatusClosed), string(models.TicketStatusUnproductive), string(models.TicketStatusCancelled),
+		}, models.TicketTypeComplaint).
+		Find(&tickets).Error
+	return tickets, err
+}
+
+func (r *slaRepository) FirstResponses(ticketIDs []string) (map[string]time.Time, error) {
+	responses := make(map[string]time.Time)
+	if len(ticketIDs) == 0 {
+		return responses, nil
+	}
+
+	var rows []struct {
+		TicketID    string
+		RespondedAt time.Time
+	}
+	err := r.db.Model(&models.TicketEvent{}).
+		Select("ticket_id, MIN(created_at) AS responded_at").
+		Where("ticket_id IN ? AND type IN ?", ticketIDs, []string{
+			models.TicketEventStatusChanged, models.TicketEventCommentCreated, models.TicketEventCheckin,
+		}).
+		Group("ticket_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		responses[row.TicketID] = row.RespondedAt
+	}
+	return responses, nil
+}
+
+func (r *slaRepository) FindPolicies(activeOnly bool) ([]models.SLAPolicy, error) {
+	var policies []models.SLAPolicy
+	query := r.db.Preload("Category")
+	if activeOnly {
+		query = query.Where("active = ?", true)
+	}
+	err := query.Order("name").Find(&policies).Error
+	return policies, err
+}
+
+func (r *slaRepository) FindPolicyByID(id string) (*models.SLAPolicy, error) {
+	var policy models.SLAPolicy
+	if err := r.db.Preload("Category").First(&policy, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func (r *slaRepository) CreatePolicy(policy *models.SLAPolicy) error {
+	return r.db.Omit("Category").Create(policy).Error
+}
+
+func (r *slaRepository) UpdatePolicy(policy *models.SLAPolicy) error {
+	return r.db.Omit("Category").Save(policy).Error
+}
+
+func (r *slaRepository) DeletePolicy(id string) error {
+	result := r.db.Where("id = ?", id).Delete(&models.SLAPolicy{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *slaRepository) CategoryExists(id string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.Category{}).Where("id = ?", id).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *slaRepository) FindBreaches(ticketIDs []string) ([]models.TicketSLABreach, error) {
+	var breaches []models.TicketSLABreach
+	if len(ticketIDs) == 0 {
+		return breaches, nil
+	}
+	err := r.db.Where("ticket_id IN ?", ticketIDs).Find(&breaches).Error
+	return breaches, err
+}
+
+func (r *slaRepository) CreateBreach(breach *models.TicketSLABreach) (bool, error) {
+	created := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Omit("Ticket").Clauses(clause.OnConflict{DoNothing: true}).Create(breach)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		created = true
+		return tx.Create(models.NewTicketEvent(breach.TicketID, models.TicketEventSLABreached, "", map[string]interface{}{
+			"kind":     breach.Kind,
+			"policyId": breach.PolicyID,
+			"dueAt":    breach.DueAt,
+		})).Error
+	})
+	return created, err
+}
+
+func (r *slaRepository) FindRecentBreaches(limit int) ([]models.TicketSLABreach, error) {
+	var breaches []models.TicketSLABreach
+	err := r.db.Preload("Ticket", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id, os_number, priority, source, category_id, status, created_at, updated_at")
+	}).
+		Order("detected_at DESC").
+		Limit(limit).
+		Find(&breaches).Error
+	return breaches, err
+}
+
+// pauseSLA opens a waiting interval and records it on the ticket timeline
+func pauseSLA(tx *gorm.DB, ticketID string, status models.TicketStatus, actorID, notes string) error {
+	pause := &models.TicketSLAPause{
+		TicketID:  ticketID,
+		Status:    status,
+		Notes:     notes,
+		PausedBy:  actorID,
+		StartedAt: time.Now(),
+	}
+	if err := tx.Create(pause).Error; err != nil {
+		return err
+	}
+	return tx.Create(models.NewTicketEvent(ticketID, models.TicketEventSLAPaused, actorID, map[string]string{
+		"status": string(status),
+		"notes":  notes,
+	})).Error
+}
+
+// resumeSLA closes the open waiting interval, if any, and records the time spent waiting
+// on the ticket timeline
+func resumeSLA(tx *gorm.DB, ticketID, actorID, notes string) error {
+	var pause models.TicketSLAPause
+	err := tx.Where("ticket_id = ? AND ended_at IS NULL", ticketID).Order("started_at DESC").First(&pause).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	pause.EndedAt = &now
+	pause.ResumedBy = actorID
+	if err := tx.Save(&pause).Error; err != nil {
+		return err
+	}
+	return tx.Create(models.NewTicketEvent(ticketID, models.TicketEventSLAResumed, actorID, map[string]interface{}{
+		"status":        pause.Status,
+		"pauseNotes":    pause.Notes,
+		"notes":         notes,
+		"waitedMinutes": int(math.Round(pause.Duration(now).Minutes())),
+	})).Error
+}
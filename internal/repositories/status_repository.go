@@ -0,0 +1,102 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+type StatusRepository interface {
+	// Incidents
+	ListIncidents(limit int) ([]models.StatusIncident, error)
+	FindIncidentsSince(since time.Time) ([]models.StatusIncident, error)
+	FindIncidentByID(id string) (*models.StatusIncident, error)
+	CreateIncident(incident *models.StatusIncident) error
+	UpdateIncident(incident *models.StatusIncident) error
+	DeleteIncident(id string) error
+
+	// Job backlog
+	CountDispatchBacklog() (pending int64, manual int64, err error)
+	CountAttachmentBacklog() (pending int64, failed int64, err error)
+}
+
+type statusRepository struct {
+	db *gorm.DB
+}
+
+func NewStatusRepository(db *gorm.DB) StatusRepository {
+	return &statusRepository{db: db}
+}
+
+func (r *statusRepository) ListIncidents(limit int) ([]models.StatusIncident, error) {
+	var incidents []models.StatusIncident
+	err := r.db.Order("started_at DESC").Limit(limit).Find(&incidents).Error
+	return incidents, err
+}
+
+// FindIncidentsSince returns unresolved incidents plus those resolved after since
+func (r *statusRepository) FindIncidentsSince(since time.Time) ([]models.StatusIncident, error) {
+	var incidents []models.StatusIncident
+	err := r.db.Where("status <> ? OR resolved_at >= ?", models.IncidentStatusResolved, since).
+		Order("started_at DESC").
+		Find(&incidents).Error
+	return incidents, err
+}
+
+func (r *statusRepository) FindIncidentByID(id string) (*models.StatusIncident, error) {
+	var incident models.StatusIncident
+	if err := r.db.First(&incident, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &incident, nil
+}
+
+func (r *statusRepository) CreateIncident(incident *models.StatusIncident) error {
+	return r.db.Create(incident).Error
+}
+
+func (r *statusRepository) UpdateIncident(incident *models.StatusIncident) error {
+	return r.db.Save(incident).Error
+}
+
+func (r *statusRepository) DeleteIncident(id string) error {
+	result := r.db.Delete(&models.StatusIncident{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *statusRepository) CountDispatchBacklog() (int64, int64, error) {
+	var pending, manual int64
+	if err := r.db.Model(&models.Ticket{}).
+		Where("dispatch_status = ?", models.DispatchStatusPending).
+		Count(&pending).Error; err != nil {
+		return 0, 0, err
+	}
+	if err := r.db.Model(&models.Ticket{}).
+		Where("dispatch_status = ?", models.DispatchStatusManualQueue).
+		Count(&manual).Error; err != nil {
+		return 0, 0, err
+	}
+	return pending, manual, nil
+}
+
+func (r *statusRepository) CountAttachmentBacklog() (int64, int64, error) {
+	var pending, failed int64
+	if err := r.db.Model(&models.TicketFile{}).
+		Where("processing_status IN ?", []models.AttachmentStatus{models.AttachmentStatusPending, models.AttachmentStatusProcessing}).
+		Count(&pending).Error; err != nil {
+		return 0, 0, err
+	}
+	if err := r.db.Model(&models.TicketFile{}).
+		Where("processing_status = ?", models.AttachmentStatusFailed).
+		Count(&failed).Error; err != nil {
+		return 0, 0, err
+	}
+	return pending, failed, nil
+}
@@ -1,16 +1,23 @@
 package repositories
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math"
 	"time"
 
 	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
+	"github.com/shigake/tech-iq-back/internal/tenant"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
 type StockRepository interface {
+	// WithContext returns the repository running its statements with ctx, inside the tenant it carries
+	WithContext(ctx context.Context) StockRepository
 	// Items
 	CreateItem(item *models.StockItem) error
 	GetItemByID(id string) (*models.StockItem, error)
@@ -35,11 +42,76 @@ type StockRepository interface {
 	GetBalance(itemID, locationID string) (*models.StockBalance, error)
 	GetBalanceForUpdate(tx *gorm.DB, itemID, locationID string) (*models.StockBalance, error)
 	UpsertBalance(tx *gorm.DB, balance *models.StockBalance) error
+	// EnsureBalance creates the empty balance unless it exists, waiting for a concurrent creation
+	EnsureBalance(tx *gorm.DB, scopeID, itemID, locationID string) error
 	ListBalances(filter models.StockBalanceFilter) (*models.PaginatedStockBalances, error)
 
 	// Transaction support
 	BeginTx() *gorm.DB
 	CreateMovementTx(tx *gorm.DB, movement *models.StockMovement) error
+
+	// Compatibility (which parts fit which equipment models)
+	ListCompatibilities(itemID string) ([]models.StockItemCompatibility, error)
+	CreateCompatibility(compatibility *models.StockItemCompatibility) error
+	DeleteCompatibility(itemID, id string) error
+	CheckCompatibility(itemID, brand, model string) (mapped bool, compatible bool, err error)
+	FindCompatibleItems(filter models.CompatiblePartsFilter) ([]models.CompatiblePartResponse, error)
+	GetLocationQuantities(locationID string, itemIDs []string) (map[string]int, error)
+
+	// Per-location levels and replenishment
+	ListLevels(filter models.StockLevelFilter) ([]models.StockLevel, error)
+	GetLevelByID(id string) (*models.StockLevel, error)
+	UpsertLevel(level *models.StockLevel) error
+	DeleteLevel(id string) error
+	ListLevelRows(scopeID string) ([]models.StockLevelRow, error)
+
+	// Cycle counting
+	ListCountCandidates(locationID string, usageSince time.Time) ([]models.CycleCountCandidate, error)
+	CreateCountTasks(tasks []models.StockCountTask) error
+	GetCountTaskByID(id string) (*models.StockCountTask, error)
+	UpdateCountTask(task *models.StockCountTask) error
+	ListCountTasks(filter models.StockCountTaskFilter) (*models.PaginatedStockCountTasks, error)
+	CountOverdueTasks(scopeID, locationID string, now time.Time) (int64, error)
+	CreateCount(count *models.StockCount) error
+	FindCounts(filter models.CountAccuracyFilter) ([]models.StockCount, error)
+
+	// Transfer approval
+	GetMovementForUpdate(tx *gorm.DB, id string) (*models.StockMovement, error)
+	UpdateMovementTx(tx *gorm.DB, movement *models.StockMovement) error
+	GetLastUnitCost(itemID string) (*decimal.Decimal, error)
+
+	// Kits
+	ListKits(filter models.StockKitFilter) (*models.PaginatedStockKits, error)
+	GetKitByID(id string) (*models.StockKit, error)
+	SaveKit(kit *models.StockKit, items []models.StockKitItem, categoryIDs []string) error
+	DeleteKit(id string) error
+	CountCategories(ids []string) (int64, error)
+
+	// RMA
+	ListRMAs(filter models.StockRMAFilter) ([]models.StockRMA, int64, error)
+	GetRMAByID(id string) (*models.StockRMA, error)
+	GetRMAForUpdate(tx *gorm.DB, id string) (*models.StockRMA, error)
+	SaveRMATx(tx *gorm.DB, rma *models.StockRMA) error
+	FindLatestConsumption(ticketID, itemID string) (*models.StockMovement, error)
+
+	// Reservations
+	ListReservations(filter models.StockReservationFilter) (*models.PaginatedStockReservations, error)
+	GetReservationByID(id string) (*models.StockReservation, error)
+	GetReservationForUpdate(tx *gorm.DB, id string) (*models.StockReservation, error)
+	CreateReservationTx(tx *gorm.DB, reservation *models.StockReservation) error
+	SaveReservationTx(tx *gorm.DB, reservation *models.StockReservation) error
+	// ReservedQuantityTx sums the active reservations of an item at a location, in total and
+	// for one ticket
+	ReservedQuantityTx(tx *gorm.DB, itemID, locationID, ticketID string) (total int, forTicket int, err error)
+	FindActiveReservationsForUpdate(tx *gorm.DB, ticketID, itemID, locationID string) ([]models.StockReservation, error)
+
+	// Serial numbers
+	ListSerials(filter models.StockSerialFilter) (*models.PaginatedStockSerials, error)
+	FindSerialUnits(serialNumber, itemID string) ([]models.StockSerialUnit, error)
+	GetSerialUnitForUpdate(tx *gorm.DB, itemID, serialNumber string) (*models.StockSerialUnit, error)
+	SaveSerialUnitTx(tx *gorm.DB, unit *models.StockSerialUnit) error
+	ListMovementSerialsTx(tx *gorm.DB, movementID string) ([]string, error)
+	FindSerialMovements(itemID, serialNumber string) ([]models.StockMovement, error)
 }
 
 type stockRepository struct {
@@ -50,6 +122,10 @@ func NewStockRepository(db *gorm.DB) StockRepository {
 	return &stockRepository{db: db}
 }
 
+func (r *stockRepository) WithContext(ctx context.Context) StockRepository {
+	return &stockRepository{db: r.db.WithContext(ctx)}
+}
+
 // =============== Items ===============
 
 func (r *stockRepository) CreateItem(item *models.StockItem) error {
@@ -128,6 +204,7 @@ func (r *stockRepository) ListItems(filter models.StockItemFilter) (*models.Pagi
 		Page:       filter.Page,
 		PageSize:   filter.PageSize,
 		TotalPages: totalPages,
+		HasNext:    pagination.HasNext(offset, filter.PageSize, total),
 	}, nil
 }
 
@@ -168,6 +245,10 @@ func (r *stockRepository) ListLocations(filter models.StockLocationFilter) (*mod
 		query = query.Where("type = ?", filter.Type)
 	}
 
+	if filter.TechnicianID != "" {
+		query = query.Where("technician_id = ?", filter.TechnicianID)
+	}
+
 	if filter.Search != "" {
 		search := "%" + filter.Search + "%"
 		query = query.Where("name ILIKE ?", search)
@@ -203,6 +284,7 @@ func (r *stockRepository) ListLocations(filter models.StockLocationFilter) (*mod
 		Page:       filter.Page,
 		PageSize:   filter.PageSize,
 		TotalPages: totalPages,
+		HasNext:    pagination.HasNext(offset, filter.PageSize, total),
 	}, nil
 }
 
@@ -218,8 +300,8 @@ func (r *stockRepository) CreateMovementTx(tx *gorm.DB, movement *models.StockMo
 
 func (r *stockRepository) GetMovementByID(id string) (*models.StockMovement, error) {
 	var movement models.StockMovement
-	err := r.db.Preload("Item").Preload("FromLocation").Preload("ToLocation").Preload("Performer").
-		Where("id = ?", id).First(&movement).Error
+	err := r.db.Preload("Item").Preload("FromLocation").Preload("ToLocation").Preload("Performer").Preload("Serials").
+		Preload("Supplier").Where("id = ?", id).First(&movement).Error
 	if err != nil {
 		return nil, err
 	}
@@ -240,6 +322,10 @@ func (r *stockRepository) ListMovements(filter models.StockMovementFilter) (*mod
 		query = query.Where("type = ?", filter.Type)
 	}
 
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
 	if filter.ItemID != "" {
 		query = query.Where("item_id = ?", filter.ItemID)
 	}
@@ -252,6 +338,10 @@ func (r *stockRepository) ListMovements(filter models.StockMovementFilter) (*mod
 		query = query.Where("ticket_id = ?", filter.TicketID)
 	}
 
+	if filter.SupplierID != "" {
+		query = query.Where("supplier_id = ?", filter.SupplierID)
+	}
+
 	if filter.StartDate != nil {
 		query = query.Where("performed_at >= ?", *filter.StartDate)
 	}
@@ -260,6 +350,10 @@ func (r *stockRepository) ListMovements(filter models.StockMovementFilter) (*mod
 		query = query.Where("performed_at <= ?", *filter.EndDate)
 	}
 
+	if filter.Cursor != nil {
+		return r.listMovementsByCursor(query, filter)
+	}
+
 	err := query.Count(&total).Error
 	if err != nil {
 		return nil, err
@@ -273,7 +367,7 @@ func (r *stockRepository) ListMovements(filter models.StockMovementFilter) (*mod
 	}
 
 	offset := (filter.Page - 1) * filter.PageSize
-	err = query.Preload("Item").Preload("FromLocation").Preload("ToLocation").
+	err = query.Preload("Item").Preload("FromLocation").Preload("ToLocation").Preload("Supplier").
 		Order("performed_at DESC").Offset(offset).Limit(filter.PageSize).Find(&movements).Error
 	if err != nil {
 		return nil, err
@@ -287,6 +381,32 @@ func (r *stockRepository) ListMovements(filter models.StockMovementFilter) (*mod
 		Page:       filter.Page,
 		PageSize:   filter.PageSize,
 		TotalPages: totalPages,
+		HasNext:    pagination.HasNext(offset, filter.PageSize, total),
+	}, nil
+}
+
+// listMovementsByCursor is ListMovements paged by keyset, newest first; the total is not counted
+func (r *stockRepository) listMovementsByCursor(query *gorm.DB, filter models.StockMovementFilter) (*models.PaginatedStockMovements, error) {
+	if filter.PageSize < 1 {
+		filter.PageSize = 20
+	}
+	var movements []models.StockMovement
+	err := query.Preload("Item").Preload("FromLocation").Preload("ToLocation").Preload("Supplier").
+		Scopes(keysetScope(*filter.Cursor, filter.PageSize, true, "stock_movements.id::text", "stock_movements.performed_at")).
+		Find(&movements).Error
+	if err != nil {
+		return nil, err
+	}
+
+	movements, next, prev := pagination.CursorPage(movements, *filter.Cursor, filter.PageSize, func(m models.StockMovement) ([]time.Time, string) {
+		return []time.Time{m.PerformedAt}, m.ID
+	})
+	return &models.PaginatedStockMovements{
+		Data:       movements,
+		PageSize:   filter.PageSize,
+		HasNext:    next != "",
+		NextCursor: next,
+		PrevCursor: prev,
 	}, nil
 }
 
@@ -298,6 +418,12 @@ func (r *stockRepository) GetBalance(itemID, locationID string) (*models.StockBa
 	if err != nil {
 		return nil, err
 	}
+	reserved, _, err := r.ReservedQuantityTx(r.db, itemID, locationID, "")
+	if err != nil {
+		return nil, err
+	}
+	balance.Reserved = reserved
+	balance.Available = balance.Quantity - reserved
 	return &balance, nil
 }
 
@@ -326,12 +452,30 @@ func (r *stockRepository) UpsertBalance(tx *gorm.DB, balance *models.StockBalanc
 	return result.Error
 }
 
+func (r *stockRepository) EnsureBalance(tx *gorm.DB, scopeID, itemID, locationID string) error {
+	balance := &models.StockBalance{ScopeID: scopeID, ItemID: itemID, LocationID: locationID, UpdatedAt: time.Now()}
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "item_id"}, {Name: "location_id"}},
+		DoNothing: true,
+	}).Create(balance).Error
+}
+
+// Per-location levels override the item MinQty
+const (
+	stockLevelJoin  = "LEFT JOIN stock_levels ON stock_levels.location_id = stock_balances.location_id AND stock_levels.item_id = stock_balances.item_id"
+	effectiveMinQty = "COALESCE(stock_levels.min_qty, stock_items.min_qty)"
+
+	// Quantity held by active reservations per item and location
+	stockReservedJoin = "LEFT JOIN (SELECT item_id, location_id, SUM(quantity) AS quantity FROM stock_reservations WHERE status = 'ACTIVE' GROUP BY item_id, location_id) reserved ON reserved.item_id = stock_balances.item_id AND reserved.location_id = stock_balances.location_id"
+)
+
 func (r *stockRepository) ListBalances(filter models.StockBalanceFilter) (*models.PaginatedStockBalances, error) {
 	var total int64
 
 	query := r.db.Model(&models.StockBalance{}).
 		Joins("JOIN stock_items ON stock_items.id = stock_balances.item_id").
-		Joins("JOIN stock_locations ON stock_locations.id = stock_balances.location_id")
+		Joins("JOIN stock_locations ON stock_locations.id = stock_balances.location_id").
+		Joins(stockLevelJoin)
 
 	if filter.ScopeID != "" {
 		query = query.Where("stock_balances.scope_id = ?", filter.ScopeID)
@@ -352,7 +496,7 @@ func (r *stockRepository) ListBalances(filter models.StockBalanceFilter) (*model
 	}
 
 	if filter.LowStock {
-		query = query.Where("stock_balances.quantity <= stock_items.min_qty")
+		query = query.Where("stock_balances.quantity <= " + effectiveMinQty)
 	}
 
 	err := query.Count(&total).Error
@@ -376,21 +520,28 @@ func (r *stockRepository) ListBalances(filter models.StockBalanceFilter) (*model
 		ItemID       string
 		LocationID   string
 		Quantity     int
+		Reserved     int
 		UpdatedAt    time.Time
 		ItemSKU      string
 		ItemName     string
 		ItemUnit     string
 		LocationName string
 		LocationType string
+		MinQty       int
+		MaxQty       int
 	}
 
-	err = r.db.Table("stock_balances").
+	err = r.db.Model(&models.StockBalance{}).
 		Select(`stock_balances.id, stock_balances.scope_id, stock_balances.item_id, 
 				stock_balances.location_id, stock_balances.quantity, stock_balances.updated_at,
+				COALESCE(reserved.quantity, 0) as reserved,
 				stock_items.sku as item_sku, stock_items.name as item_name, stock_items.unit as item_unit,
-				stock_locations.name as location_name, stock_locations.type as location_type`).
+				stock_locations.name as location_name, stock_locations.type as location_type,
+				` + effectiveMinQty + ` as min_qty, COALESCE(stock_levels.max_qty, 0) as max_qty`).
 		Joins("JOIN stock_items ON stock_items.id = stock_balances.item_id").
 		Joins("JOIN stock_locations ON stock_locations.id = stock_balances.location_id").
+		Joins(stockLevelJoin).
+		Joins(stockReservedJoin).
 		Where(buildBalanceConditions(filter)).
 		Order("stock_items.name ASC, stock_locations.name ASC").
 		Offset(offset).Limit(filter.PageSize).
@@ -409,11 +560,15 @@ func (r *stockRepository) ListBalances(filter models.StockBalanceFilter) (*model
 			ItemID:       r.ItemID,
 			LocationID:   r.LocationID,
 			Quantity:     r.Quantity,
+			Reserved:     r.Reserved,
+			Available:    r.Quantity - r.Reserved,
 			ItemSKU:      r.ItemSKU,
 			ItemName:     r.ItemName,
 			ItemUnit:     r.ItemUnit,
 			LocationName: r.LocationName,
 			LocationType: r.LocationType,
+			MinQty:       r.MinQty,
+			MaxQty:       r.MaxQty,
 			UpdatedAt:    r.UpdatedAt.Format(time.RFC3339),
 		}
 	}
@@ -426,6 +581,7 @@ func (r *stockRepository) ListBalances(filter models.StockBalanceFilter) (*model
 		Page:       filter.Page,
 		PageSize:   filter.PageSize,
 		TotalPages: totalPages,
+		HasNext:    pagination.HasNext(offset, filter.PageSize, total),
 	}, nil
 }
 
@@ -446,7 +602,7 @@ func buildBalanceConditions(filter models.StockBalanceFilter) string {
 			search, search, search)
 	}
 	if filter.LowStock {
-		conditions += " AND stock_balances.quantity <= stock_items.min_qty"
+		conditions += " AND stock_balances.quantity <= " + effectiveMinQty
 	}
 	return conditions
 }
@@ -456,3 +612,755 @@ func buildBalanceConditions(filter models.StockBalanceFilter) string {
 func (r *stockRepository) BeginTx() *gorm.DB {
 	return r.db.Begin()
 }
+
+// =============== Compatibility ===============
+
+func (r *stockRepository) ListCompatibilities(itemID string) ([]models.StockItemCompatibility, error) {
+	var compatibilities []models.StockItemCompatibility
+	err := r.db.Where("item_id = ?", itemID).
+		Order("brand ASC, model ASC").
+		Find(&compatibilities).Error
+	return compatibilities, err
+}
+
+func (r *stockRepository) CreateCompatibility(compatibility *models.StockItemCompatibility) error {
+	return r.db.Create(compatibility).Error
+}
+
+func (r *stockRepository) DeleteCompatibility(itemID, id string) error {
+	result := r.db.Where("id = ? AND item_id = ?", id, itemID).Delete(&models.StockItemCompatibility{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// CheckCompatibility reports whether the item has compatibility entries at all and
+// whether one of them covers the brand/model (brand-wide entries have an empty model)
+func (r *stockRepository) CheckCompatibility(itemID, brand, model string) (bool, bool, error) {
+	var row struct {
+		Total   int64
+		Matches int64
+	}
+	err := r.db.Model(&models.StockItemCompatibility{}).
+		Select("COUNT(*) AS total, "+
+			"COUNT(*) FILTER (WHERE LOWER(brand) = LOWER(?) AND (model = '' OR LOWER(model) = LOWER(?))) AS matches", brand, model).
+		Where("item_id = ?", itemID).
+		Scan(&row).Error
+	if err != nil {
+		return false, false, err
+	}
+	return row.Total > 0, row.Matches > 0, nil
+}
+
+// FindCompatibleItems returns the active items listed for the brand/model, exact model
+// matches first, optionally followed by universal items (no entries at all)
+func (r *stockRepository) FindCompatibleItems(filter models.CompatiblePartsFilter) ([]models.CompatiblePartResponse, error) {
+	var rows []struct {
+		models.StockItem
+		ModelMatch bool
+	}
+	matched := r.db.Model(&models.StockItemCompatibility{}).
+		Select("item_id, BOOL_OR(model <> '') AS model_match").
+		Where("LOWER(brand) = LOWER(?) AND (model = '' OR LOWER(model) = LOWER(?))", filter.Brand, filter.Model).
+		Group("item_id")
+
+	query := r.db.Model(&models.StockItem{}).
+		Select("stock_items.*, COALESCE(m.model_match, false) AS model_match").
+		Joins("LEFT JOIN (?) m ON m.item_id = stock_items.id", matched).
+		Where("stock_items.is_active = ?", true)
+	if filter.IncludeUniversal {
+		query = query.Where("m.item_id IS NOT NULL OR NOT EXISTS (SELECT 1 FROM stock_item_compatibilities c WHERE c.item_id = stock_items.id)")
+	} else {
+		query = query.Where("m.item_id IS NOT NULL")
+	}
+	if filter.Search != "" {
+		search := "%" + filter.Search + "%"
+		query = query.Where("stock_items.name ILIKE ? OR stock_items.sku ILIKE ?", search, search)
+	}
+	if filter.OnlyInStock && filter.LocationID != "" {
+		query = query.Where("EXISTS (SELECT 1 FROM stock_balances b WHERE b.item_id = stock_items.id AND b.location_id = ? AND b.quantity > 0)", filter.LocationID)
+	}
+
+	err := query.
+		Order("m.item_id IS NULL, model_match DESC, stock_items.name ASC").
+		Limit(200).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Universal items have no match row; tell them apart from brand-wide matches
+	var mappedIDs []string
+	if filter.IncludeUniversal && len(rows) > 0 {
+		ids := make([]string, len(rows))
+		for i, row := range rows {
+			ids[i] = row.ID
+		}
+		if err := r.db.Model(&models.StockItemCompatibility{}).
+			Where("item_id IN ?", ids).
+			Distinct().
+			Pluck("item_id", &mappedIDs).Error; err != nil {
+			return nil, err
+		}
+	}
+	mapped := make(map[string]bool, len(mappedIDs))
+	for _, id := range mappedIDs {
+		mapped[id] = true
+	}
+
+	parts := make([]models.CompatiblePartResponse, len(rows))
+	for i, row := range rows {
+		matchedBy := models.CompatibilityMatchBrand
+		switch {
+		case row.ModelMatch:
+			matchedBy = models.CompatibilityMatchModel
+		case filter.IncludeUniversal && !mapped[row.ID]:
+			matchedBy = models.CompatibilityMatchUniversal
+		}
+		parts[i] = models.CompatiblePartResponse{Item: row.StockItem, MatchedBy: matchedBy}
+	}
+	return parts, nil
+}
+
+func (r *stockRepository) GetLocationQuantities(locationID string, itemIDs []string) (map[string]int, error) {
+	quantities := make(map[string]int, len(itemIDs))
+	if len(itemIDs) == 0 {
+		return quantities, nil
+	}
+
+	var balances []models.StockBalance
+	err := r.db.Where("location_id = ? AND item_id IN ?", locationID, itemIDs).Find(&balances).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, balance := range balances {
+		quantities[balance.ItemID] += balance.Quantity
+	}
+	return quantities, nil
+}
+
+// =============== Levels ===============
+
+func (r *stockRepository) ListLevels(filter models.StockLevelFilter) ([]models.StockLevel, error) {
+	var levels []models.StockLevel
+	query := r.db.Preload("Item").Preload("Location")
+	if filter.LocationID != "" {
+		query = query.Where("location_id = ?", filter.LocationID)
+	}
+	if filter.ItemID != "" {
+		query = query.Where("item_id = ?", filter.ItemID)
+	}
+	err := query.Order("created_at ASC").Find(&levels).Error
+	return levels, err
+}
+
+func (r *stockRepository) GetLevelByID(id string) (*models.StockLevel, error) {
+	var level models.StockLevel
+	err := r.db.Preload("Item").Preload("Location").First(&level, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &level, nil
+}
+
+// UpsertLevel creates or replaces the levels of an item at a location
+func (r *stockRepository) UpsertLevel(level *models.StockLevel) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "location_id"}, {Name: "item_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"min_qty", "max_qty", "updated_by", "updated_at"}),
+	}).Omit("Item", "Location").Create(level).Error
+}
+
+func (r *stockRepository) DeleteLevel(id string) error {
+	result := r.db.Delete(&models.StockLevel{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ListLevelRows returns every item stocked at, or with levels for, an active location
+// along with its quantity and effective levels
+func (r *stockRepository) ListLevelRows(scopeID string) ([]models.StockLevelRow, error) {
+	var rows []models.StockLevelRow
+	query := r.db.Table("stock_locations l").
+		Select(`l.scope_id, l.id AS location_id, l.name AS location_name, l.type AS location_type,
+				i.id AS item_id, i.sku AS item_sku, i.name AS item_name, i.unit AS item_unit,
+				COALESCE(b.quantity, 0) AS quantity,
+				COALESCE(sl.min_qty, i.min_qty) AS min_qty, COALESCE(sl.max_qty, 0) AS max_qty`).
+		Joins("CROSS JOIN stock_items i").
+		Joins("LEFT JOIN stock_balances b ON b.location_id = l.id AND b.item_id = i.id").
+		Joins("LEFT JOIN stock_levels sl ON sl.location_id = l.id AND sl.item_id = i.id").
+		Where("l.is_active = ? AND i.is_active = ?", true, true).
+		Where("b.id IS NOT NULL OR sl.id IS NOT NULL")
+	// The tenant scope doesn't rewrite aliased tables
+	if tenantID, ok := tenant.FromContext(r.db.Statement.Context); ok {
+		query = query.Where("l.tenant_id = ? AND i.tenant_id = ?", tenantID, tenantID)
+	}
+	if scopeID != "" {
+		query = query.Where("l.scope_id = ?", scopeID)
+	}
+	err := query.Order("l.name ASC, i.name ASC").Scan(&rows).Error
+	return rows, err
+}
+
+// =============== Cycle Counting ===============
+
+// ListCountCandidates returns the items stocked at a location with the quantity moved
+// out of it since usageSince, their last count and whether a count is already pending
+func (r *stockRepository) ListCountCandidates(locationID string, usageSince time.Time) ([]models.CycleCountCandidate, error) {
+	var candidates []models.CycleCountCandidate
+	err := r.db.Raw(`
+		SELECT i.id AS item_id, i.sku AS item_sku, i.name AS item_name, b.quantity,
+			COALESCE(u.usage, 0) AS usage, c.last_counted_at,
+			EXISTS (
+				SELECT 1 FROM stock_count_tasks t
+				WHERE t.location_id = b.location_id AND t.item_id = b.item_id AND t.status = ?
+			) AS has_open_task
+		FROM stock_balances b
+		JOIN stock_items i ON i.id = b.item_id AND i.is_active = true
+		LEFT JOIN (
+			SELECT item_id, SUM(quantity) AS usage FROM stock_movements
+			WHERE from_location_id = ? AND type IN ? AND status = ? AND performed_at >= ?
+			GROUP BY item_id
+		) u ON u.item_id = b.item_id
+		LEFT JOIN (
+			SELECT item_id, MAX(counted_at) AS last_counted_at FROM stock_counts
+			WHERE location_id = ?
+			GROUP BY item_id
+		) c ON c.item_id = b.item_id
+		WHERE b.location_id = ?
+		ORDER BY i.name ASC`,
+		models.CountTaskPending,
+		locationID,
+		[]models.StockMovementType{models.MovementTypeSaidaConsumoOS, models.MovementTypeSaidaPerda, models.MovementTypeTransferencia},
+		models.MovementStatusApproved,
+		usageSince,
+		locationID,
+		locationID,
+	).Scan(&candidates).Error
+	return candidates, err
+}
+
+func (r *stockRepository) CreateCountTasks(tasks []models.StockCountTask) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	return r.db.Create(&tasks).Error
+}
+
+func (r *stockRepository) GetCountTaskByID(id string) (*models.StockCountTask, error) {
+	var task models.StockCountTask
+	err := r.db.Preload("Item").Preload("Location").Preload("Assignee").First(&task, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func (r *stockRepository) UpdateCountTask(task *models.StockCountTask) error {
+	return r.db.Omit("Item", "Location", "Assignee").Save(task).Error
+}
+
+func (r *stockRepository) ListCountTasks(filter models.StockCountTaskFilter) (*models.PaginatedStockCountTasks, error) {
+	var tasks []models.StockCountTask
+	var total int64
+
+	query := r.db.Model(&models.StockCountTask{})
+
+	if filter.ScopeID != "" {
+		query = query.Where("scope_id = ?", filter.ScopeID)
+	}
+
+	if filter.LocationID != "" {
+		query = query.Where("location_id = ?", filter.LocationID)
+	}
+
+	if filter.AssignedTo != "" {
+		query = query.Where("assigned_to = ?", filter.AssignedTo)
+	}
+
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	err := query.Count(&total).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.PageSize < 1 {
+		filter.PageSize = 20
+	}
+
+	offset := (filter.Page - 1) * filter.PageSize
+	err = query.Preload("Item").Preload("Location").Preload("Assignee").
+		Order("due_date ASC, created_at ASC").Offset(offset).Limit(filter.PageSize).Find(&tasks).Error
+	if err != nil {
+		return nil, err
+	}
+
+	totalPages := int(math.Ceil(float64(total) / float64(filter.PageSize)))
+
+	return &models.PaginatedStockCountTasks{
+		Data:       tasks,
+		Total:      total,
+		Page:       filter.Page,
+		PageSize:   filter.PageSize,
+		TotalPages: totalPages,
+		HasNext:    pagination.HasNext(offset, filter.PageSize, total),
+	}, nil
+}
+
+func (r *stockRepository) CountOverdueTasks(scopeID, locationID string, now time.Time) (int64, error) {
+	var total int64
+	query := r.db.Model(&models.StockCountTask{}).Where("status = ? AND due_date < ?", models.CountTaskPending, now)
+	if scopeID != "" {
+		query = query.Where("scope_id = ?", scopeID)
+	}
+	if locationID != "" {
+		query = query.Where("location_id = ?", locationID)
+	}
+	err := query.Count(&total).Error
+	return total, err
+}
+
+func (r *stockRepository) CreateCount(count *models.StockCount) error {
+	return r.db.Create(count).Error
+}
+
+// FindCounts returns the counts made in [From, To), oldest first
+func (r *stockRepository) FindCounts(filter models.CountAccuracyFilter) ([]models.StockCount, error) {
+	var counts []models.StockCount
+	query := r.db.Preload("Location").Where("counted_at >= ? AND counted_at < ?", filter.From, filter.To)
+	if filter.ScopeID != "" {
+		query = query.Where("scope_id = ?", filter.ScopeID)
+	}
+	if filter.LocationID != "" {
+		query = query.Where("location_id = ?", filter.LocationID)
+	}
+	err := query.Order("counted_at ASC").Find(&counts).Error
+	return counts, err
+}
+
+// =============== Transfer Approval ===============
+
+// GetMovementForUpdate locks the movement so concurrent decisions cannot both apply
+func (r *stockRepository) GetMovementForUpdate(tx *gorm.DB, id string) (*models.StockMovement, error) {
+	var movement models.StockMovement
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&movement, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &movement, nil
+}
+
+func (r *stockRepository) UpdateMovementTx(tx *gorm.DB, movement *models.StockMovement) error {
+	return tx.Omit("Item", "FromLocation", "ToLocation", "Performer", "Supplier", "Serials").Save(movement).Error
+}
+
+// GetLastUnitCost returns the unit cost of the latest purchase of the item, nil if unknown
+func (r *stockRepository) GetLastUnitCost(itemID string) (*decimal.Decimal, error) {
+	var movement models.StockMovement
+	err := r.db.Where("item_id = ? AND type = ? AND unit_cost IS NOT NULL", itemID, models.MovementTypeEntradaCompra).
+		Order("performed_at DESC").First(&movement).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return movement.UnitCost, nil
+}
+
+// =============== Kits ===============
+
+func (r *stockRepository) ListKits(filter models.StockKitFilter) (*models.PaginatedStockKits, error) {
+	var kits []models.StockKit
+	var total int64
+
+	query := r.db.Model(&models.StockKit{})
+
+	if filter.Search != "" {
+		search := "%" + filter.Search + "%"
+		query = query.Where("name ILIKE ?", search)
+	}
+
+	if filter.CategoryID != "" {
+		query = query.Where("id IN (SELECT kit_id FROM stock_kit_categories WHERE category_id = ?)", filter.CategoryID)
+	}
+
+	if filter.IsActive != nil {
+		query = query.Where("is_active = ?", *filter.IsActive)
+	}
+
+	err := query.Count(&total).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.PageSize < 1 {
+		filter.PageSize = 20
+	}
+
+	offset := (filter.Page - 1) * filter.PageSize
+	err = query.Preload("Items.Item").Preload("Categories").
+		Order("name ASC").Offset(offset).Limit(filter.PageSize).Find(&kits).Error
+	if err != nil {
+		return nil, err
+	}
+
+	totalPages := int(math.Ceil(float64(total) / float64(filter.PageSize)))
+
+	return &models.PaginatedStockKits{
+		Data:       kits,
+		Total:      total,
+		Page:       filter.Page,
+		PageSize:   filter.PageSize,
+		TotalPages: totalPages,
+		HasNext:    pagination.HasNext(offset, filter.PageSize, total),
+	}, nil
+}
+
+func (r *stockRepository) GetKitByID(id string) (*models.StockKit, error) {
+	var kit models.StockKit
+	err := r.db.Preload("Items.Item").Preload("Categories").First(&kit, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &kit, nil
+}
+
+// SaveKit creates or updates the kit; non-nil items and categoryIDs replace the current ones
+func (r *stockRepository) SaveKit(kit *models.StockKit, items []models.StockKitItem, categoryIDs []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Items", "Categories").Save(kit).Error; err != nil {
+			return err
+		}
+
+		if items != nil {
+			if err := tx.Where("kit_id = ?", kit.ID).Delete(&models.StockKitItem{}).Error; err != nil {
+				return err
+			}
+			for i := range items {
+				items[i].KitID = kit.ID
+			}
+			if len(items) > 0 {
+				if err := tx.Omit("Item").Create(&items).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		if categoryIDs != nil {
+			categories := make([]models.Category, len(categoryIDs))
+			for i, id := range categoryIDs {
+				categories[i] = models.Category{ID: id}
+			}
+			if err := tx.Model(kit).Omit("Categories.*").Association("Categories").Replace(categories); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *stockRepository) DeleteKit(id string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM stock_kit_categories WHERE kit_id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("kit_id = ?", id).Delete(&models.StockKitItem{}).Error; err != nil {
+			return err
+		}
+		result := tx.Delete(&models.StockKit{}, "id = ?", id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+func (r *stockRepository) CountCategories(ids []string) (int64, error) {
+	var total int64
+	err := r.db.Model(&models.Category{}).Where("id IN ?", ids).Count(&total).Error
+	return total, err
+}
+
+// =============== RMA ===============
+
+func (r *stockRepository) ListRMAs(filter models.StockRMAFilter) ([]models.StockRMA, int64, error) {
+	var rmas []models.StockRMA
+	var total int64
+
+	query := r.db.Model(&models.StockRMA{})
+
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	if filter.Supplier != "" {
+		query = query.Where("supplier ILIKE ?", "%"+filter.Supplier+"%")
+	}
+
+	if filter.TicketID != "" {
+		query = query.Where("ticket_id = ?", filter.TicketID)
+	}
+
+	if filter.ItemID != "" {
+		query = query.Where("item_id = ?", filter.ItemID)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (filter.Page - 1) * filter.PageSize
+	err := query.Preload("Item").Preload("Ticket").Preload("QuarantineLocation").
+		Order("created_at DESC").Offset(offset).Limit(filter.PageSize).Find(&rmas).Error
+	return rmas, total, err
+}
+
+func (r *stockRepository) GetRMAByID(id string) (*models.StockRMA, error) {
+	var rma models.StockRMA
+	err := r.db.Preload("Item").Preload("Ticket").Preload("QuarantineLocation").First(&rma, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &rma, nil
+}
+
+func (r *stockRepository) GetRMAForUpdate(tx *gorm.DB, id string) (*models.StockRMA, error) {
+	var rma models.StockRMA
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&rma, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &rma, nil
+}
+
+func (r *stockRepository) SaveRMATx(tx *gorm.DB, rma *models.StockRMA) error {
+	return tx.Omit("Item", "Ticket", "QuarantineLocation").Save(rma).Error
+}
+
+// FindLatestConsumption returns the latest SAIDA_CONSUMO_OS of the item on the ticket
+func (r *stockRepository) FindLatestConsumption(ticketID, itemID string) (*models.StockMovement, error) {
+	var movement models.StockMovement
+	err := r.db.Where("ticket_id = ? AND item_id = ? AND type = ?", ticketID, itemID, models.MovementTypeSaidaConsumoOS).
+		Order("performed_at DESC").First(&movement).Error
+	if err != nil {
+		return nil, err
+	}
+	return &movement, nil
+}
+
+// =============== Reservations ===============
+
+func (r *stockRepository) ListReservations(filter models.StockReservationFilter) (*models.PaginatedStockReservations, error) {
+	var reservations []models.StockReservation
+	var total int64
+
+	query := r.db.Model(&models.StockReservation{})
+
+	if filter.TicketID != "" {
+		query = query.Where("ticket_id = ?", filter.TicketID)
+	}
+
+	if filter.ItemID != "" {
+		query = query.Where("item_id = ?", filter.ItemID)
+	}
+
+	if filter.LocationID != "" {
+		query = query.Where("location_id = ?", filter.LocationID)
+	}
+
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.PageSize < 1 {
+		filter.PageSize = 20
+	}
+
+	offset := (filter.Page - 1) * filter.PageSize
+	err := query.Preload("Item").Preload("Location").
+		Order("created_at DESC").Offset(offset).Limit(filter.PageSize).Find(&reservations).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.PaginatedStockReservations{
+		Data:       reservations,
+		Total:      total,
+		Page:       filter.Page,
+		PageSize:   filter.PageSize,
+		TotalPages: int(math.Ceil(float64(total) / float64(filter.PageSize))),
+		HasNext:    pagination.HasNext(offset, filter.PageSize, total),
+	}, nil
+}
+
+func (r *stockRepository) GetReservationByID(id string) (*models.StockReservation, error) {
+	var reservation models.StockReservation
+	err := r.db.Preload("Item").Preload("Location").First(&reservation, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &reservation, nil
+}
+
+func (r *stockRepository) GetReservationForUpdate(tx *gorm.DB, id string) (*models.StockReservation, error) {
+	var reservation models.StockReservation
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&reservation, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &reservation, nil
+}
+
+func (r *stockRepository) CreateReservationTx(tx *gorm.DB, reservation *models.StockReservation) error {
+	return tx.Create(reservation).Error
+}
+
+func (r *stockRepository) SaveReservationTx(tx *gorm.DB, reservation *models.StockReservation) error {
+	return tx.Omit("Item", "Location").Save(reservation).Error
+}
+
+func (r *stockRepository) ReservedQuantityTx(tx *gorm.DB, itemID, locationID, ticketID string) (int, int, error) {
+	var sums struct {
+		Total     int
+		ForTicket int
+	}
+	err := tx.Model(&models.StockReservation{}).
+		Select("COALESCE(SUM(quantity), 0) AS total, COALESCE(SUM(CASE WHEN ticket_id::text = ? THEN quantity ELSE 0 END), 0) AS for_ticket", ticketID).
+		Where("item_id = ? AND location_id = ? AND status = ?", itemID, locationID, models.ReservationStatusActive).
+		Scan(&sums).Error
+	return sums.Total, sums.ForTicket, err
+}
+
+func (r *stockRepository) FindActiveReservationsForUpdate(tx *gorm.DB, ticketID, itemID, locationID string) ([]models.StockReservation, error) {
+	var reservations []models.StockReservation
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("ticket_id = ? AND item_id = ? AND location_id = ? AND status = ?",
+			ticketID, itemID, locationID, models.ReservationStatusActive).
+		Order("created_at ASC").
+		Find(&reservations).Error
+	return reservations, err
+}
+
+// =============== Serial numbers ===============
+
+func (r *stockRepository) ListSerials(filter models.StockSerialFilter) (*models.PaginatedStockSerials, error) {
+	var units []models.StockSerialUnit
+	var total int64
+
+	query := r.db.Model(&models.StockSerialUnit{})
+
+	if filter.ItemID != "" {
+		query = query.Where("item_id = ?", filter.ItemID)
+	}
+
+	if filter.LocationID != "" {
+		query = query.Where("location_id = ?", filter.LocationID)
+	}
+
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	if filter.Search != "" {
+		query = query.Where("serial_number ILIKE ?", "%"+filter.Search+"%")
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.PageSize < 1 {
+		filter.PageSize = 20
+	}
+
+	offset := (filter.Page - 1) * filter.PageSize
+	err := query.Preload("Item").Preload("Location").
+		Order("serial_number ASC").Offset(offset).Limit(filter.PageSize).Find(&units).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.PaginatedStockSerials{
+		Data:       units,
+		Total:      total,
+		Page:       filter.Page,
+		PageSize:   filter.PageSize,
+		TotalPages: int(math.Ceil(float64(total) / float64(filter.PageSize))),
+		HasNext:    pagination.HasNext(offset, filter.PageSize, total),
+	}, nil
+}
+
+// FindSerialUnits returns the units with the serial number; different items may share one
+func (r *stockRepository) FindSerialUnits(serialNumber, itemID string) ([]models.StockSerialUnit, error) {
+	var units []models.StockSerialUnit
+	query := r.db.Preload("Item").Preload("Location").Where("serial_number = ?", serialNumber)
+	if itemID != "" {
+		query = query.Where("item_id = ?", itemID)
+	}
+	err := query.Order("created_at ASC").Find(&units).Error
+	return units, err
+}
+
+func (r *stockRepository) GetSerialUnitForUpdate(tx *gorm.DB, itemID, serialNumber string) (*models.StockSerialUnit, error) {
+	var unit models.StockSerialUnit
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&unit, "item_id = ? AND serial_number = ?", itemID, serialNumber).Error
+	if err != nil {
+		return nil, err
+	}
+	return &unit, nil
+}
+
+func (r *stockRepository) SaveSerialUnitTx(tx *gorm.DB, unit *models.StockSerialUnit) error {
+	return tx.Omit("Item", "Location").Save(unit).Error
+}
+
+func (r *stockRepository) ListMovementSerialsTx(tx *gorm.DB, movementID string) ([]string, error) {
+	var serials []string
+	err := tx.Model(&models.StockMovementSerial{}).Where("movement_id = ?", movementID).
+		Order("serial_number ASC").Pluck("serial_number", &serials).Error
+	return serials, err
+}
+
+// FindSerialMovements returns the movements of the unit, oldest first; pending and rejected
+// movements are included so the history shows every request made for it
+func (r *stockRepository) FindSerialMovements(itemID, serialNumber string) ([]models.StockMovement, error) {
+	var movements []models.StockMovement
+	err := r.db.Preload("FromLocation").Preload("ToLocation").Preload("Performer").
+		Where("id IN (?)", r.db.Model(&models.StockMovementSerial{}).Select("movement_id").
+			Where("item_id = ? AND serial_number = ?", itemID, serialNumber)).
+		Order("performed_at ASC").Find(&movements).Error
+	return movements, err
+}
@@ -0,0 +1,258 @@
+package repositories
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/storage"
+	"gorm.io/gorm"
+)
+
+type StorageRepository interface {
+	// Quotas
+	ListQuotas() ([]models.StorageQuota, error)
+	FindQuota(nodeID *uint, module string) (*models.StorageQuota, error)
+	UpsertQuota(quota *models.StorageQuota) error
+	DeleteQuota(id uint) error
+
+	// Accounting
+	TenantRootID(nodeID *uint) (*uint, error)
+	TenantUsage(rootID *uint) (int64, error)
+	UsageByTenant() ([]models.StorageTenantUsage, error)
+	UsageByAge(now time.Time) ([]models.StorageAgeBucket, error)
+
+	// Orphans
+	ReferencedPaths() ([]string, error)
+	// LocalFileKeys returns the keys of the /files uploads kept on local disk, relative to the upload dir
+	LocalFileKeys() ([]string, error)
+	FindDeletedTicketFiles(deletedBefore time.Time) ([]models.TicketFile, error)
+	DeleteTicketFile(id string) error
+}
+
+type storageRepository struct {
+	db *gorm.DB
+}
+
+func NewStorageRepository(db *gorm.DB) StorageRepository {
+	return &storageRepository{db: db}
+}
+
+func (r *storageRepository) ListQuotas() ([]models.StorageQuota, error) {
+	var quotas []models.StorageQuota
+	err := r.db.Order("node_id NULLS FIRST, module ASC").Find(&quotas).Error
+	return quotas, err
+}
+
+// FindQuota returns the most specific quota for the tenant and module:
+// tenant+module, tenant, default+module, default. Nil when nothing is configured.
+func (r *storageRepository) FindQuota(nodeID *uint, module string) (*models.StorageQuota, error) {
+	type scope struct {
+		nodeID *uint
+		module string
+	}
+	scopes := []scope{{nodeID, module}, {nodeID, ""}, {nil, module}, {nil, ""}}
+
+	for _, sc := range scopes {
+		var quota models.StorageQuota
+		query := r.db.Where("module = ?", sc.module)
+		if sc.nodeID != nil {
+			query = query.Where("node_id = ?", *sc.nodeID)
+		} else {
+			query = query.Where("node_id IS NULL")
+		}
+		err := query.First(&quota).Error
+		if err == nil {
+			return &quota, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+func (r *storageRepository) UpsertQuota(quota *models.StorageQuota) error {
+	var existing models.StorageQuota
+	query := r.db.Where("module = ?", quota.Module)
+	if quota.NodeID != nil {
+		query = query.Where("node_id = ?", *quota.NodeID)
+	} else {
+		query = query.Where("node_id IS NULL")
+	}
+
+	err := query.First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return r.db.Create(quota).Error
+	}
+	if err != nil {
+		return err
+	}
+	quota.ID = existing.ID
+	quota.CreatedAt = existing.CreatedAt
+	return r.db.Save(quota).Error
+}
+
+func (r *storageRepository) DeleteQuota(id uint) error {
+	result := r.db.Delete(&models.StorageQuota{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// TenantRootID resolves the root of the node's hierarchy branch (first segment of its path)
+func (r *storageRepository) TenantRootID(nodeID *uint) (*uint, error) {
+	if nodeID == nil {
+		return nil, nil
+	}
+	var node models.Node
+	if err := r.db.Select("id, path").First(&node, *nodeID).Error; err != nil {
+		return nil, err
+	}
+	root := node.ID
+	if first := strings.Split(node.Path, ".")[0]; first != "" {
+		if id, err := strconv.ParseUint(first, 10, 64); err == nil {
+			root = uint(id)
+		}
+	}
+	return &root, nil
+}
+
+// TenantUsage sums the stored bytes of attachments of tickets under the tenant root.
+// Client documents belong to no hierarchy node and count for the nil tenant.
+func (r *storageRepository) TenantUsage(rootID *uint) (int64, error) {
+	var total int64
+	query := r.db.Table("ticket_files").
+		Select("COALESCE(SUM(GREATEST(ticket_files.stored_bytes, ticket_files.file_size)), 0)").
+		Joins("JOIN tickets ON tickets.id = ticket_files.ticket_id").
+		Joins("LEFT JOIN nodes ON nodes.id = tickets.node_id")
+	if rootID != nil {
+		root := strconv.FormatUint(uint64(*rootID), 10)
+		query = query.Where("nodes.path = ? OR nodes.path LIKE ?", root, root+".%")
+	} else {
+		query = query.Where("tickets.node_id IS NULL")
+	}
+	if err := query.Scan(&total).Error; err != nil {
+		return 0, err
+	}
+	if rootID == nil {
+		var documents int64
+		if err := r.db.Model(&models.ClientDocument{}).Select("COALESCE(SUM(file_size), 0)").Scan(&documents).Error; err != nil {
+			return 0, err
+		}
+		total += documents
+	}
+	return total, nil
+}
+
+func (r *storageRepository) UsageByTenant() ([]models.StorageTenantUsage, error) {
+	var rows []struct {
+		Root  string
+		Files int64
+		Bytes int64
+	}
+	err := r.db.Table("ticket_files").
+		Select("split_part(nodes.path, '.', 1) AS root, COUNT(*) AS files, COALESCE(SUM(GREATEST(ticket_files.stored_bytes, ticket_files.file_size)), 0) AS bytes").
+		Joins("JOIN tickets ON tickets.id = ticket_files.ticket_id").
+		Joins("LEFT JOIN nodes ON nodes.id = tickets.node_id").
+		Group("root").
+		Order("bytes DESC").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	usage := make([]models.StorageTenantUsage, 0, len(rows))
+	for _, row := range rows {
+		entry := models.StorageTenantUsage{Files: row.Files, Bytes: row.Bytes}
+		if id, err := strconv.ParseUint(row.Root, 10, 64); err == nil {
+			nodeID := uint(id)
+			entry.NodeID = &nodeID
+			var node models.Node
+			if r.db.Select("id, name").First(&node, nodeID).Error == nil {
+				entry.NodeName = node.Name
+			}
+		}
+		usage = append(usage, entry)
+	}
+	return usage, nil
+}
+
+func (r *storageRepository) UsageByAge(now time.Time) ([]models.StorageAgeBucket, error) {
+	var rows []models.StorageAgeBucket
+	err := r.db.Table("ticket_files").
+		Select(`CASE
+			WHEN created_at >= ? THEN '0-30d'
+			WHEN created_at >= ? THEN '30-90d'
+			WHEN created_at >= ? THEN '90-365d'
+			ELSE '365d+' END AS bucket,
+			COUNT(*) AS files,
+			COALESCE(SUM(GREATEST(stored_bytes, file_size)), 0) AS bytes`,
+			now.AddDate(0, 0, -30), now.AddDate(0, 0, -90), now.AddDate(-1, 0, 0)).
+		Group("bucket").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Always return every bucket, in age order
+	order := []string{"0-30d", "30-90d", "90-365d", "365d+"}
+	byBucket := make(map[string]models.StorageAgeBucket, len(rows))
+	for _, row := range rows {
+		byBucket[row.Bucket] = row
+	}
+	buckets := make([]models.StorageAgeBucket, 0, len(order))
+	for _, name := range order {
+		bucket := byBucket[name]
+		bucket.Bucket = name
+		buckets = append(buckets, bucket)
+	}
+	return buckets, nil
+}
+
+// ReferencedPaths returns every path on disk that an attachment or client document record points to
+func (r *storageRepository) ReferencedPaths() ([]string, error) {
+	var files []models.TicketFile
+	if err := r.db.Select("file_path, thumbnail_path, sanitized_path, webp_path").Find(&files).Error; err != nil {
+		return nil, err
+	}
+	var documents []string
+	if err := r.db.Model(&models.ClientDocument{}).Pluck("file_path", &documents).Error; err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(files)+len(documents))
+	for _, f := range files {
+		for _, p := range []string{f.FilePath, f.ThumbnailPath, f.SanitizedPath, f.WebPPath} {
+			if p != "" {
+				paths = append(paths, p)
+			}
+		}
+	}
+	return append(paths, documents...), nil
+}
+
+func (r *storageRepository) LocalFileKeys() ([]string, error) {
+	var keys []string
+	err := r.db.Model(&models.StoredFile{}).Where("backend = ?", storage.BackendLocal).Pluck("key", &keys).Error
+	return keys, err
+}
+
+// FindDeletedTicketFiles returns attachments whose ticket no longer exists or
+// was deleted before the given time
+func (r *storageRepository) FindDeletedTicketFiles(deletedBefore time.Time) ([]models.TicketFile, error) {
+	var files []models.TicketFile
+	err := r.db.
+		Where("ticket_id NOT IN (SELECT id FROM tickets WHERE deleted_at IS NULL OR deleted_at > ?)", deletedBefore).
+		Find(&files).Error
+	return files, err
+}
+
+func (r *storageRepository) DeleteTicketFile(id string) error {
+	return r.db.Delete(&models.TicketFile{}, "id = ?", id).Error
+}
@@ -0,0 +1,74 @@
+package repositories
+
+import (
+	"context"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+type StoredFileRepository interface {
+	// WithContext returns the repository running its statements with ctx, inside the tenant it carries
+	WithContext(ctx context.Context) StoredFileRepository
+	Create(file *models.StoredFile) error
+	FindByID(id string) (*models.StoredFile, error)
+	FindByOwner(ownerType, ownerID string) ([]models.StoredFile, error)
+	Update(file *models.StoredFile) error
+	Delete(id string) error
+
+	// AddEntryAttachment / RemoveEntryAttachment keep FinancialEntry.AttachmentURLs in
+	// sync with the entry's files
+	AddEntryAttachment(entryID, url string) error
+	RemoveEntryAttachment(entryID, url string) error
+}
+
+type storedFileRepository struct {
+	db *gorm.DB
+}
+
+func NewStoredFileRepository(db *gorm.DB) StoredFileRepository {
+	return &storedFileRepository{db: db}
+}
+
+func (r *storedFileRepository) WithContext(ctx context.Context) StoredFileRepository {
+	return &storedFileRepository{db: r.db.WithContext(ctx)}
+}
+
+func (r *storedFileRepository) Create(file *models.StoredFile) error {
+	return r.db.Create(file).Error
+}
+
+func (r *storedFileRepository) FindByID(id string) (*models.StoredFile, error) {
+	var file models.StoredFile
+	if err := r.db.Where("id = ?", id).First(&file).Error; err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+func (r *storedFileRepository) FindByOwner(ownerType, ownerID string) ([]models.StoredFile, error) {
+	var files []models.StoredFile
+	err := r.db.Where("owner_type = ? AND owner_id = ?", ownerType, ownerID).
+		Order("created_at ASC").
+		Find(&files).Error
+	return files, err
+}
+
+func (r *storedFileRepository) Update(file *models.StoredFile) error {
+	return r.db.Save(file).Error
+}
+
+func (r *storedFileRepository) Delete(id string) error {
+	return r.db.Delete(&models.StoredFile{}, "id = ?", id).Error
+}
+
+func (r *storedFileRepository) AddEntryAttachment(entryID, url string) error {
+	return r.db.Model(&models.FinancialEntry{}).
+		Where("id = ? AND NOT (? = ANY(COALESCE(attachment_urls, '{}')))", entryID, url).
+		Update("attachment_urls", gorm.Expr("array_append(COALESCE(attachment_urls, '{}'), ?)", url)).Error
+}
+
+func (r *storedFileRepository) RemoveEntryAttachment(entryID, url string) error {
+	return r.db.Model(&models.FinancialEntry{}).
+		Where("id = ?", entryID).
+		Update("attachment_urls", gorm.Expr("array_remove(attachment_urls, ?)", url)).Error
+}
@@ -0,0 +1,83 @@
+package repositories
+
+import (
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+type SupplierRepository interface {
+	FindAll(page, size int, filters *models.SupplierFilters) ([]models.Supplier, int64, error)
+	FindByID(id string) (*models.Supplier, error)
+	ExistsCNPJ(cnpj, excludeID string) (bool, error)
+	Create(supplier *models.Supplier) error
+	Update(supplier *models.Supplier) error
+	Delete(id string) error
+}
+
+type supplierRepository struct {
+	db *gorm.DB
+}
+
+func NewSupplierRepository(db *gorm.DB) SupplierRepository {
+	return &supplierRepository{db: db}
+}
+
+func (r *supplierRepository) FindAll(page, size int, filters *models.SupplierFilters) ([]models.Supplier, int64, error) {
+	var suppliers []models.Supplier
+	var total int64
+
+	query := r.db.Model(&models.Supplier{})
+	if filters != nil {
+		if filters.Search != "" {
+			like := "%" + filters.Search + "%"
+			query = query.Where("name ILIKE ? OR trade_name ILIKE ? OR cnpj LIKE ?", like, like, like)
+		}
+		if !filters.Inactive {
+			query = query.Where("active = ?", true)
+		}
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("name").Offset(page * size).Limit(size).Find(&suppliers).Error
+	return suppliers, total, err
+}
+
+func (r *supplierRepository) FindByID(id string) (*models.Supplier, error) {
+	var supplier models.Supplier
+	if err := r.db.First(&supplier, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &supplier, nil
+}
+
+func (r *supplierRepository) ExistsCNPJ(cnpj, excludeID string) (bool, error) {
+	var count int64
+	query := r.db.Model(&models.Supplier{}).Where("cnpj = ?", cnpj)
+	if excludeID != "" {
+		query = query.Where("id <> ?", excludeID)
+	}
+	err := query.Count(&count).Error
+	return count > 0, err
+}
+
+func (r *supplierRepository) Create(supplier *models.Supplier) error {
+	return r.db.Create(supplier).Error
+}
+
+func (r *supplierRepository) Update(supplier *models.Supplier) error {
+	return r.db.Save(supplier).Error
+}
+
+// Delete soft-deletes the supplier; movements and entries keep referencing it
+func (r *supplierRepository) Delete(id string) error {
+	result := r.db.Delete(&models.Supplier{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
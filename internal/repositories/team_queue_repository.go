@@ -0,0 +1,274 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// queueFinishedStatuses are the statuses that take a ticket out of its queue backlog
+var queueFinishedStatuses = []string{
+	string(models.TicketStatusClosed), string(models.TicketStatusUnproductive), string(models.TicketStatusCancelled),
+}
+
+type TeamQueueRepository interface {
+	// Queues
+	FindQueues(activeOnly bool) ([]models.TeamQueueLoad, error)
+	FindQueueByID(id string) (*models.TeamQueue, error)
+	CreateQueue(queue *models.TeamQueue) error
+	UpdateQueue(queue *models.TeamQueue) error
+
+	// Settings
+	GetSettings() (*models.TeamQueueSettings, error)
+	SaveSettings(settings *models.TeamQueueSettings) error
+
+	// Tickets
+	FindUnqueuedTickets(limit int) ([]models.Ticket, error)
+	FindRebalanceCandidates(queueID string, limit int) ([]models.Ticket, error)
+	FindQueueTickets(queueID string, page, size int) ([]models.Ticket, int64, error)
+	FindAssignment(ticketID string) (*models.TicketQueueAssignment, error)
+	FindNodePaths(nodeIDs []uint) (map[uint]string, error)
+	// Assign puts the ticket in the queue. Without replace, a ticket already queued is left
+	// alone and false is returned.
+	Assign(assignment *models.TicketQueueAssignment, replace bool) (bool, error)
+
+	// Metrics
+	FindBacklogRows() ([]QueueBacklogRow, error)
+	CountAssignedSince(since time.Time) (map[string]int64, error)
+}
+
+// QueueBacklogRow aggregates the open tickets of a queue for one priority
+type QueueBacklogRow struct {
+	QueueID      string
+	Priority     models.TicketPriority
+	Count        int64
+	Unowned      int64
+	OldestOpenAt time.Time
+}
+
+type teamQueueRepository struct {
+	db *gorm.DB
+}
+
+func NewTeamQueueRepository(db *gorm.DB) TeamQueueRepository {
+	return &teamQueueRepository{db: db}
+}
+
+// FindQueues returns the queues with the headcount of their node and their open backlog
+func (r *teamQueueRepository) FindQueues(activeOnly bool) ([]models.TeamQueueLoad, error) {
+	var queues []models.TeamQueueLoad
+	query := r.db.Table("team_queues q").
+		Select(`q.*, n.path AS node_path,
+			(SELECT COUNT(DISTINCT m.user_id) FROM memberships m WHERE m.node_id = q.node_id) AS headcount,
+			(SELECT COUNT(*) FROM ticket_queue_assignments a
+				JOIN tickets t ON t.id = a.ticket_id AND t.deleted_at IS NULL
+				WHERE a.queue_id = q.id AND t.status NOT IN ?) AS backlog`, queueFinishedStatuses).
+		Joins("LEFT JOIN nodes n ON n.id = q.node_id")
+	if activeOnly {
+		query = query.Where("q.active = ?", true)
+	}
+	err := query.Order("q.name").Scan(&queues).Error
+	return queues, err
+}
+
+func (r *teamQueueRepository) FindQueueByID(id string) (*models.TeamQueue, error) {
+	var queue models.TeamQueue
+	err := r.db.Preload("Node").Where("id = ?", id).First(&queue).Error
+	if err != nil {
+		return nil, err
+	}
+	return &queue, nil
+}
+
+func (r *teamQueueRepository) CreateQueue(queue *models.TeamQueue) error {
+	return r.db.Omit("Node").Create(queue).Error
+}
+
+func (r *teamQueueRepository) UpdateQueue(queue *models.TeamQueue) error {
+	return r.db.Omit("Node").Save(queue).Error
+}
+
+// GetSettings returns the stored settings, or the defaults when nothing is configured
+func (r *teamQueueRepository) GetSettings() (*models.TeamQueueSettings, error) {
+	var settings models.TeamQueueSettings
+	err := r.db.Order("id").First(&settings).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.DefaultTeamQueueSettings(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+func (r *teamQueueRepository) SaveSettings(settings *models.TeamQueueSettings) error {
+	return r.db.Save(settings).Error
+}
+
+// FindUnqueuedTickets returns the open service tickets nobody owns yet and that are in
+// no queue, oldest first
+func (r *teamQueueRepository) FindUnqueuedTickets(limit int) ([]models.Ticket, error) {
+	var tickets []models.Ticket
+	err := r.db.
+		Where("status = ? AND type = ?", models.TicketStatusOpen, models.TicketTypeService).
+		Where("id NOT IN (SELECT ticket_id FROM ticket_technicians)").
+		Where("id NOT IN (SELECT ticket_id FROM ticket_queue_assignments)").
+		Order("created_at ASC").
+		Limit(limit).
+		Find(&tickets).Error
+	return tickets, err
+}
+
+// FindRebalanceCandidates returns the open tickets of a queue that nobody works on yet,
+// newest first: the oldest ones stay with the team that has been holding them
+func (r *teamQueueRepository) FindRebalanceCandidates(queueID string, limit int) ([]models.Ticket, error) {
+	var tickets []models.Ticket
+	err := r.db.
+		Where("status = ?", models.TicketStatusOpen).
+		Where("id IN (SELECT ticket_id FROM ticket_queue_assignments WHERE queue_id = ?)", queueID).
+		Where("id NOT IN (SELECT ticket_id FROM ticket_technicians)").
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&tickets).Error
+	return tickets, err
+}
+
+// FindQueueTickets returns the open tickets of a queue, oldest first
+func (r *teamQueueRepository) FindQueueTickets(queueID string, page, size int) ([]models.Ticket, int64, error) {
+	var tickets []models.Ticket
+	var total int64
+
+	query := r.db.Model(&models.Ticket{}).
+		Where("status NOT IN ?", queueFinishedStatuses).
+		Where("id IN (SELECT ticket_id FROM ticket_queue_assignments WHERE queue_id = ?)", queueID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.
+		Preload("Client").
+		Preload("Category").
+		Preload("Technicians").
+		Order("created_at ASC").
+		Offset(page * size).
+		Limit(size).
+		Find(&tickets).Error
+	return tickets, total, err
+}
+
+func (r *teamQueueRepository) FindAssignment(ticketID string) (*models.TicketQueueAssignment, error) {
+	var assignment models.TicketQueueAssignment
+	err := r.db.Preload("Queue").Where("ticket_id = ?", ticketID).First(&assignment).Error
+	if err != nil {
+		return nil, err
+	}
+	return &assignment, nil
+}
+
+func (r *teamQueueRepository) FindNodePaths(nodeIDs []uint) (map[uint]string, error) {
+	paths := make(map[uint]string)
+	if len(nodeIDs) == 0 {
+		return paths, nil
+	}
+	var nodes []models.Node
+	if err := r.db.Select("id", "path").Where("id IN ?", nodeIDs).Find(&nodes).Error; err != nil {
+		return nil, err
+	}
+	for _, node := range nodes {
+		paths[node.ID] = node.Path
+	}
+	return paths, nil
+}
+
+// Assign writes the assignment, counts it on the queue and notes it on the ticket timeline
+// in one transaction
+func (r *teamQueueRepository) Assign(assignment *models.TicketQueueAssignment, replace bool) (bool, error) {
+	assigned := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var previous models.TicketQueueAssignment
+		err := tx.Where("ticket_id = ?", assignment.TicketID).First(&previous).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		exists := err == nil
+		if exists && (!replace || previous.QueueID == assignment.QueueID) {
+			return nil
+		}
+
+		conflict := clause.OnConflict{
+			Columns:   []clause.Column{{Name: "ticket_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"queue_id", "method", "policy", "assigned_by", "assigned_at"}),
+		}
+		if !replace {
+			conflict = clause.OnConflict{DoNothing: true}
+		}
+		result := tx.Clauses(conflict).Omit(clause.Associations).Create(assignment)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil // queued concurrently
+		}
+
+		err = tx.Model(&models.TeamQueue{}).Where("id = ?", assignment.QueueID).
+			UpdateColumn("assigned_count", gorm.Expr("assigned_count + 1")).Error
+		if err != nil {
+			return err
+		}
+
+		payload := map[string]interface{}{
+			"queueId": assignment.QueueID,
+			"method":  assignment.Method,
+		}
+		if assignment.Policy != "" {
+			payload["policy"] = assignment.Policy
+		}
+		if exists {
+			payload["previousQueueId"] = previous.QueueID
+		}
+		if err := tx.Create(models.NewTicketEvent(assignment.TicketID, models.TicketEventQueueAssigned, assignment.AssignedBy, payload)).Error; err != nil {
+			return err
+		}
+		assigned = true
+		return nil
+	})
+	return assigned, err
+}
+
+// FindBacklogRows aggregates the open tickets of every queue by priority
+func (r *teamQueueRepository) FindBacklogRows() ([]QueueBacklogRow, error) {
+	var rows []QueueBacklogRow
+	err := r.db.Table("ticket_queue_assignments a").
+		Select(`a.queue_id, t.priority, COUNT(*) AS count,
+			COUNT(*) FILTER (WHERE NOT EXISTS (SELECT 1 FROM ticket_technicians tt WHERE tt.ticket_id = t.id)) AS unowned,
+			MIN(t.created_at) AS oldest_open_at`).
+		Joins("JOIN tickets t ON t.id = a.ticket_id AND t.deleted_at IS NULL").
+		Where("t.status NOT IN ?", queueFinishedStatuses).
+		Group("a.queue_id, t.priority").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// CountAssignedSince counts the tickets that entered each queue since a point in time
+func (r *teamQueueRepository) CountAssignedSince(since time.Time) (map[string]int64, error) {
+	var rows []struct {
+		QueueID string
+		Count   int64
+	}
+	err := r.db.Model(&models.TicketQueueAssignment{}).
+		Select("queue_id, COUNT(*) AS count").
+		Where("assigned_at >= ?", since).
+		Group("queue_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.QueueID] = row.Count
+	}
+	return counts, nil
+}
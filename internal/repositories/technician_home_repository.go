@@ -0,0 +1,90 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+// TechnicianHomeRepository reads the data of the technician app home screen
+type TechnicianHomeRepository interface {
+	// FindDayTickets returns the open tickets of the technician scheduled in the day, plus
+	// those already in progress, by schedule
+	FindDayTickets(technicianID string, dayStart, dayEnd time.Time) ([]models.Ticket, error)
+	FindTechnicianLocations(technicianID string) ([]models.StockLocation, error)
+	FindLocationStock(locationIDs []string) ([]models.TechnicianHomeStock, error)
+	CountIncomingTransfers(locationIDs []string) (int64, error)
+	CountPendingCountTasks(locationIDs []string, dueBy time.Time) (int64, error)
+}
+
+type technicianHomeRepository struct {
+	db *gorm.DB
+}
+
+func NewTechnicianHomeRepository(db *gorm.DB) TechnicianHomeRepository {
+	return &technicianHomeRepository{db: db}
+}
+
+func (r *technicianHomeRepository) FindDayTickets(technicianID string, dayStart, dayEnd time.Time) ([]models.Ticket, error) {
+	var tickets []models.Ticket
+	err := r.db.Model(&models.Ticket{}).
+		Joins("JOIN ticket_technicians tt ON tt.ticket_id = tickets.id").
+		Where("tt.technician_id = ?", technicianID).
+		Where("tickets.status NOT IN ?", []models.TicketStatus{
+			models.TicketStatusClosed, models.TicketStatusCancelled, models.TicketStatusUnproductive,
+		}).
+		Where("(tickets.scheduled_start >= ? AND tickets.scheduled_start < ?) OR tickets.status = ?",
+			dayStart, dayEnd, models.TicketStatusInProgress).
+		Preload("Client").
+		Preload("Category").
+		Order("tickets.scheduled_start ASC NULLS LAST, tickets.created_at ASC").
+		Find(&tickets).Error
+	return tickets, err
+}
+
+func (r *technicianHomeRepository) FindTechnicianLocations(technicianID string) ([]models.StockLocation, error) {
+	var locations []models.StockLocation
+	err := r.db.Where("technician_id = ? AND type = ? AND is_active = ?", technicianID, models.LocationTechnician, true).
+		Order("name ASC").
+		Find(&locations).Error
+	return locations, err
+}
+
+func (r *technicianHomeRepository) FindLocationStock(locationIDs []string) ([]models.TechnicianHomeStock, error) {
+	stock := []models.TechnicianHomeStock{}
+	if len(locationIDs) == 0 {
+		return stock, nil
+	}
+	err := r.db.Model(&models.StockBalance{}).
+		Select("stock_balances.location_id, stock_locations.name AS location_name, stock_balances.item_id, "+
+			"stock_items.sku AS item_sku, stock_items.name AS item_name, stock_items.unit AS item_unit, stock_balances.quantity").
+		Joins("JOIN stock_items ON stock_items.id = stock_balances.item_id").
+		Joins("JOIN stock_locations ON stock_locations.id = stock_balances.location_id").
+		Where("stock_balances.location_id IN ? AND stock_balances.quantity > 0", locationIDs).
+		Order("stock_locations.name ASC, stock_items.name ASC").
+		Scan(&stock).Error
+	return stock, err
+}
+
+func (r *technicianHomeRepository) CountIncomingTransfers(locationIDs []string) (int64, error) {
+	var count int64
+	if len(locationIDs) == 0 {
+		return 0, nil
+	}
+	err := r.db.Model(&models.StockMovement{}).
+		Where("to_location_id IN ? AND status = ?", locationIDs, models.MovementStatusPending).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *technicianHomeRepository) CountPendingCountTasks(locationIDs []string, dueBy time.Time) (int64, error) {
+	var count int64
+	if len(locationIDs) == 0 {
+		return 0, nil
+	}
+	err := r.db.Model(&models.StockCountTask{}).
+		Where("location_id IN ? AND status = ? AND due_date < ?", locationIDs, models.CountTaskPending, dueBy).
+		Count(&count).Error
+	return count, err
+}
@@ -1,6 +1,7 @@
 package repositories
 
 import (
+	"context"
 	"github.com/shigake/tech-iq-back/internal/models"
 	"gorm.io/gorm"
 )
@@ -10,6 +11,7 @@ type TechnicianRepository interface {
 	FindAll(page, size int) ([]models.Technician, int64, error)
 	FindByID(id string) (*models.Technician, error)
 	FindByUserID(userID string) (*models.Technician, error)
+	// Update saves the technician if it is still at its Version and bumps it (ErrVersionConflict otherwise)
 	Update(technician *models.Technician) error
 	Delete(id string) error
 	FindByCity(city string) ([]models.Technician, error)
@@ -23,6 +25,11 @@ type TechnicianRepository interface {
 	GetDistinctCities() ([]string, error)
 	GetRecent(limit int) ([]models.Technician, error)
 	GetAll() ([]models.Technician, error) // Retorna todos os técnicos sem paginação
+	// WithScope returns the repository restricted to the hierarchy scope of a user
+	WithScope(scope *models.AccessScope) TechnicianRepository
+	// WithContext returns the repository running its statements with ctx, inside the
+	// tenant it carries
+	WithContext(ctx context.Context) TechnicianRepository
 }
 
 type technicianRepository struct {
@@ -33,10 +40,21 @@ func NewTechnicianRepository(db *gorm.DB) TechnicianRepository {
 	return &technicianRepository{db: db}
 }
 
+func (r *technicianRepository) WithContext(ctx context.Context) TechnicianRepository {
+	return &technicianRepository{db: r.db.WithContext(ctx)}
+}
+
 func (r *technicianRepository) Create(technician *models.Technician) error {
 	return r.db.Create(technician).Error
 }
 
+func (r *technicianRepository) WithScope(scope *models.AccessScope) TechnicianRepository {
+	if !scope.Restricted() {
+		return r
+	}
+	return &technicianRepository{db: scopedDB(r.db, scope, "technicians.node_id")}
+}
+
 func (r *technicianRepository) FindAll(page, size int) ([]models.Technician, int64, error) {
 	var technicians []models.Technician
 	var total int64
@@ -71,7 +89,7 @@ func (r *technicianRepository) FindByUserID(userID string) (*models.Technician,
 }
 
 func (r *technicianRepository) Update(technician *models.Technician) error {
-	return r.db.Save(technician).Error
+	return updateVersioned(r.db, technician, &technician.Version)
 }
 
 func (r *technicianRepository) Delete(id string) error {
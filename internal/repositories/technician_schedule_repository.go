@@ -0,0 +1,127 @@
+package repositories
+
+import (
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TechnicianScheduleRepository interface {
+	// Working weeks
+	FindByTechnician(technicianID string) (*models.TechnicianSchedule, error)
+	FindByTechnicians(technicianIDs []string) ([]models.TechnicianSchedule, error)
+	// FindAll returns every schedule with its technician, for the on-duty board
+	FindAll() ([]models.TechnicianSchedule, error)
+	// Save writes the schedule and replaces its working hours
+	Save(schedule *models.TechnicianSchedule) error
+	Delete(technicianID string) error
+
+	// Time off; dates are YYYY-MM-DD, inclusive
+	FindTimeOff(technicianIDs []string, from, to string) ([]models.TechnicianTimeOff, error)
+	FindTimeOffByID(id string) (*models.TechnicianTimeOff, error)
+	CreateTimeOff(timeOff *models.TechnicianTimeOff) error
+	DeleteTimeOff(id string) error
+}
+
+type technicianScheduleRepository struct {
+	db *gorm.DB
+}
+
+func NewTechnicianScheduleRepository(db *gorm.DB) TechnicianScheduleRepository {
+	return &technicianScheduleRepository{db: db}
+}
+
+func preloadWorkingHours(db *gorm.DB) *gorm.DB {
+	return db.Order("weekday ASC, start_time ASC")
+}
+
+func (r *technicianScheduleRepository) FindByTechnician(technicianID string) (*models.TechnicianSchedule, error) {
+	var schedule models.TechnicianSchedule
+	err := r.db.
+		Preload("WorkingHours", preloadWorkingHours).
+		Where("technician_id = ?", technicianID).
+		First(&schedule).Error
+	if err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+func (r *technicianScheduleRepository) FindByTechnicians(technicianIDs []string) ([]models.TechnicianSchedule, error) {
+	var schedules []models.TechnicianSchedule
+	err := r.db.
+		Preload("WorkingHours", preloadWorkingHours).
+		Where("technician_id IN ?", technicianIDs).
+		Find(&schedules).Error
+	return schedules, err
+}
+
+func (r *technicianScheduleRepository) FindAll() ([]models.TechnicianSchedule, error) {
+	var schedules []models.TechnicianSchedule
+	err := r.db.
+		Preload("WorkingHours", preloadWorkingHours).
+		Preload("Technician").
+		Find(&schedules).Error
+	return schedules, err
+}
+
+func (r *technicianScheduleRepository) Save(schedule *models.TechnicianSchedule) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Save(schedule).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("technician_id = ?", schedule.TechnicianID).Delete(&models.TechnicianWorkingHours{}).Error; err != nil {
+			return err
+		}
+		for i := range schedule.WorkingHours {
+			schedule.WorkingHours[i].ID = 0
+			schedule.WorkingHours[i].TechnicianID = schedule.TechnicianID
+		}
+		if len(schedule.WorkingHours) == 0 {
+			return nil
+		}
+		return tx.Create(&schedule.WorkingHours).Error
+	})
+}
+
+func (r *technicianScheduleRepository) Delete(technicianID string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("technician_id = ?", technicianID).Delete(&models.TechnicianWorkingHours{}).Error; err != nil {
+			return err
+		}
+		result := tx.Where("technician_id = ?", technicianID).Delete(&models.TechnicianSchedule{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// FindTimeOff returns the time off of the technicians overlapping the dates
+func (r *technicianScheduleRepository) FindTimeOff(technicianIDs []string, from, to string) ([]models.TechnicianTimeOff, error) {
+	var timeOff []models.TechnicianTimeOff
+	err := r.db.
+		Where("technician_id IN ? AND start_date <= ? AND end_date >= ?", technicianIDs, to, from).
+		Order("start_date ASC").
+		Find(&timeOff).Error
+	return timeOff, err
+}
+
+func (r *technicianScheduleRepository) FindTimeOffByID(id string) (*models.TechnicianTimeOff, error) {
+	var timeOff models.TechnicianTimeOff
+	if err := r.db.First(&timeOff, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &timeOff, nil
+}
+
+func (r *technicianScheduleRepository) CreateTimeOff(timeOff *models.TechnicianTimeOff) error {
+	return r.db.Create(timeOff).Error
+}
+
+func (r *technicianScheduleRepository) DeleteTimeOff(id string) error {
+	return r.db.Delete(&models.TechnicianTimeOff{}, "id = ?", id).Error
+}
@@ -0,0 +1,61 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/tenant"
+	"gorm.io/gorm"
+)
+
+type TenantRepository interface {
+	FindAll() ([]models.Tenant, error)
+	FindByID(id string) (*models.Tenant, error)
+	FindBySlug(slug string) (*models.Tenant, error)
+	Create(t *models.Tenant) error
+	Update(t *models.Tenant) error
+	// CreateUser creates the user inside the tenant
+	CreateUser(tenantID string, user *models.User) error
+}
+
+type tenantRepository struct {
+	db *gorm.DB
+}
+
+func NewTenantRepository(db *gorm.DB) TenantRepository {
+	return &tenantRepository{db: db}
+}
+
+func (r *tenantRepository) FindAll() ([]models.Tenant, error) {
+	var tenants []models.Tenant
+	err := r.db.Order("name ASC").Find(&tenants).Error
+	return tenants, err
+}
+
+func (r *tenantRepository) FindByID(id string) (*models.Tenant, error) {
+	var t models.Tenant
+	if err := r.db.First(&t, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *tenantRepository) FindBySlug(slug string) (*models.Tenant, error) {
+	var t models.Tenant
+	if err := r.db.First(&t, "slug = ?", slug).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *tenantRepository) Create(t *models.Tenant) error {
+	return r.db.Create(t).Error
+}
+
+func (r *tenantRepository) Update(t *models.Tenant) error {
+	return r.db.Save(t).Error
+}
+
+func (r *tenantRepository) CreateUser(tenantID string, user *models.User) error {
+	return r.db.WithContext(tenant.WithID(context.Background(), tenantID)).Create(user).Error
+}
@@ -0,0 +1,143 @@
+//go:build integration
+
+package repositories_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"github.com/shigake/tech-iq-back/internal/tenant"
+	"github.com/shigake/tech-iq-back/internal/testutil"
+	"gorm.io/gorm"
+)
+
+func TestTenantScopeIsolatesClients(t *testing.T) {
+	env.Reset(t)
+	ctxA := tenant.WithID(context.Background(), tenant.DefaultID)
+	ctxB := tenant.WithID(context.Background(), testutil.OtherTenantID)
+	clients := repositories.NewClientRepository(env.DB)
+
+	clientB := &models.Client{FullName: "Tenant B Client", Email: "b@other.test"}
+	if err := clients.WithContext(ctxB).Create(clientB); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if clientB.TenantID != testutil.OtherTenantID {
+		t.Fatalf("created client stamped with tenant %q, want %q", clientB.TenantID, testutil.OtherTenantID)
+	}
+
+	t.Run("read by id", func(t *testing.T) {
+		if _, err := clients.WithContext(ctxA).GetByID(clientB.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Fatalf("tenant A read tenant B's client: err = %v", err)
+		}
+	})
+
+	t.Run("list and search", func(t *testing.T) {
+		listed, _, err := clients.WithContext(ctxA).GetAll(0, 100)
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		found, _, err := clients.WithContext(ctxA).Search("Tenant B", 0, 100)
+		if err != nil {
+			t.Fatalf("search: %v", err)
+		}
+		for _, c := range append(listed, found...) {
+			if c.ID == clientB.ID {
+				t.Fatal("tenant A listed tenant B's client")
+			}
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		stolen := *clientB
+		stolen.FullName = "Changed by A"
+		if err := clients.WithContext(ctxA).Update(&stolen); !errors.Is(err, repositories.ErrVersionConflict) {
+			t.Fatalf("tenant A update of tenant B's client: err = %v, want ErrVersionConflict", err)
+		}
+		current, err := clients.WithContext(ctxB).GetByID(clientB.ID)
+		if err != nil {
+			t.Fatalf("reload: %v", err)
+		}
+		if current.FullName != clientB.FullName || current.TenantID != testutil.OtherTenantID {
+			t.Fatalf("tenant B's client changed to %q in tenant %q", current.FullName, current.TenantID)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		if err := clients.WithContext(ctxA).Delete(clientB.ID); err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+		if _, err := clients.WithContext(ctxB).GetByID(clientB.ID); err != nil {
+			t.Fatalf("tenant A deleted tenant B's client: %v", err)
+		}
+	})
+}
+
+func TestTenantScopeIsolatesTickets(t *testing.T) {
+	env.Reset(t)
+	ctxA := tenant.WithID(context.Background(), tenant.DefaultID)
+	ctxB := tenant.WithID(context.Background(), testutil.OtherTenantID)
+	tickets := repositories.NewTicketRepository(env.DB)
+
+	ticketB := &models.Ticket{
+		ErrorDescription: "Tenant B ticket",
+		Status:           models.TicketStatusOpen,
+		Priority:         models.TicketPriorityNormal,
+	}
+	if err := tickets.WithContext(ctxB).Create(ticketB); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	if _, err := tickets.WithContext(ctxA).FindByID(ticketB.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("tenant A read tenant B's ticket: err = %v", err)
+	}
+	listed, _, err := tickets.WithContext(ctxA).FindAll(0, 100, &models.TicketFilters{})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	for _, ticket := range listed {
+		if ticket.ID == ticketB.ID {
+			t.Fatal("tenant A listed tenant B's ticket")
+		}
+	}
+
+	if err := tickets.WithContext(ctxA).UpdateStatus(ticketB.ID, string(models.TicketStatusClosed), testutil.AdminUserID, ""); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("tenant A status change of tenant B's ticket: err = %v", err)
+	}
+	if err := tickets.WithContext(ctxA).Delete(ticketB.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	current, err := tickets.WithContext(ctxB).FindByID(ticketB.ID)
+	if err != nil {
+		t.Fatalf("tenant A deleted tenant B's ticket: %v", err)
+	}
+	if current.Status != models.TicketStatusOpen {
+		t.Fatalf("tenant A moved tenant B's ticket to %s", current.Status)
+	}
+}
+
+func TestTenantScopeIsolatesUsers(t *testing.T) {
+	env.Reset(t)
+	ctxB := tenant.WithID(context.Background(), testutil.OtherTenantID)
+	users := repositories.NewUserRepository(env.DB)
+
+	// The fixture users belong to the default tenant
+	if _, err := users.WithContext(ctxB).FindByID(testutil.EmployeeUserID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("tenant B read a default tenant user: err = %v", err)
+	}
+	listed, _, err := users.WithContext(ctxB).GetAllPaginated(1, 100, "")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(listed) != 0 {
+		t.Fatalf("tenant B listed %d users of the default tenant", len(listed))
+	}
+	if err := users.WithContext(ctxB).Delete(testutil.EmployeeUserID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := users.FindByID(testutil.EmployeeUserID); err != nil {
+		t.Fatalf("tenant B deleted a default tenant user: %v", err)
+	}
+}
@@ -0,0 +1,217 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TicketBudgetRepository interface {
+	FindBudget(ticketID string) (*models.TicketBudget, error)
+	SaveBudget(budget *models.TicketBudget) error
+	DeleteBudget(ticketID string) error
+	// FindBudgetsCreatedBetween returns the budgets of the tickets opened in the period
+	FindBudgetsCreatedBetween(from, to time.Time) ([]models.TicketBudget, error)
+
+	// Spending
+	FindSpending(ticketIDs []string) (map[string]*TicketSpending, error)
+	// SumExpenses returns the expenses of the ticket counted against its budget, leaving
+	// out the given entry (the one being edited)
+	SumExpenses(ticketID, excludeEntryID string) (decimal.Decimal, error)
+	FindLeadTechnicians(ticketIDs []string) (map[string]models.Technician, error)
+
+	// Overrides
+	FindOverrides(ticketID string) ([]models.TicketBudgetOverride, error)
+	FindOverrideByID(id string) (*models.TicketBudgetOverride, error)
+	CreateOverride(override *models.TicketBudgetOverride) error
+	// DecideOverride saves the decision unless the override was decided meanwhile, in
+	// which case false is returned
+	DecideOverride(override *models.TicketBudgetOverride) (bool, error)
+}
+
+// TicketSpending is what a ticket has spent against its budget. Parts are consumption
+// movements valued at their unit cost, else at the last purchase cost of the item.
+type TicketSpending struct {
+	PartsApproved    decimal.Decimal
+	PartsPending     decimal.Decimal
+	Expenses         decimal.Decimal
+	ApprovedOverride decimal.Decimal
+}
+
+type ticketBudgetRepository struct {
+	db *gorm.DB
+}
+
+func NewTicketBudgetRepository(db *gorm.DB) TicketBudgetRepository {
+	return &ticketBudgetRepository{db: db}
+}
+
+func (r *ticketBudgetRepository) FindBudget(ticketID string) (*models.TicketBudget, error) {
+	var budget models.TicketBudget
+	if err := r.db.Where("ticket_id = ?", ticketID).First(&budget).Error; err != nil {
+		return nil, err
+	}
+	return &budget, nil
+}
+
+func (r *ticketBudgetRepository) SaveBudget(budget *models.TicketBudget) error {
+	return r.db.Omit(clause.Associations).Save(budget).Error
+}
+
+func (r *ticketBudgetRepository) DeleteBudget(ticketID string) error {
+	return r.db.Where("ticket_id = ?", ticketID).Delete(&models.TicketBudget{}).Error
+}
+
+func (r *ticketBudgetRepository) FindBudgetsCreatedBetween(from, to time.Time) ([]models.TicketBudget, error) {
+	var budgets []models.TicketBudget
+	err := r.db.
+		Joins("Ticket").
+		Where(`"Ticket".created_at >= ? AND "Ticket".created_at < ?`, from, to).
+		Order(`"Ticket".created_at ASC`).
+		Find(&budgets).Error
+	return budgets, err
+}
+
+func (r *ticketBudgetRepository) FindSpending(ticketIDs []string) (map[string]*TicketSpending, error) {
+	spending := make(map[string]*TicketSpending, len(ticketIDs))
+	if len(ticketIDs) == 0 {
+		return spending, nil
+	}
+	for _, id := range ticketIDs {
+		spending[id] = &TicketSpending{}
+	}
+
+	var parts []struct {
+		TicketID string
+		Status   models.StockMovementStatus
+		Amount   decimal.Decimal
+	}
+	err := r.db.Raw(`
+		SELECT m.ticket_id, m.status, COALESCE(SUM(m.quantity * COALESCE(m.unit_cost, (
+			SELECT p.unit_cost FROM stock_movements p
+			WHERE p.item_id = m.item_id AND p.type = ? AND p.unit_cost IS NOT NULL
+			ORDER BY p.performed_at DESC LIMIT 1
+		), 0)), 0) AS amount
+		FROM stock_movements m
+		WHERE m.ticket_id IN ? AND m.type = ? AND m.status IN ?
+		GROUP BY m.ticket_id, m.status`,
+		models.MovementTypeEntradaCompra,
+		ticketIDs,
+		models.MovementTypeSaidaConsumoOS,
+		[]models.StockMovementStatus{models.MovementStatusApproved, models.MovementStatusPending},
+	).Scan(&parts).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range parts {
+		if row.Status == models.MovementStatusPending {
+			spending[row.TicketID].PartsPending = row.Amount
+		} else {
+			spending[row.TicketID].PartsApproved = row.Amount
+		}
+	}
+
+	var sums []struct {
+		TicketID string
+		Amount   decimal.Decimal
+	}
+	if err := r.expensesQuery(ticketIDs).
+		Select("ticket_id, COALESCE(SUM(amount), 0) AS amount").
+		Group("ticket_id").
+		Scan(&sums).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range sums {
+		spending[row.TicketID].Expenses = row.Amount
+	}
+
+	sums = nil
+	err = r.db.Model(&models.TicketBudgetOverride{}).
+		Select("ticket_id, COALESCE(SUM(amount), 0) AS amount").
+		Where("ticket_id IN ? AND status = ?", ticketIDs, models.BudgetOverrideApproved).
+		Group("ticket_id").
+		Scan(&sums).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range sums {
+		spending[row.TicketID].ApprovedOverride = row.Amount
+	}
+	return spending, nil
+}
+
+func (r *ticketBudgetRepository) SumExpenses(ticketID, excludeEntryID string) (decimal.Decimal, error) {
+	var total decimal.Decimal
+	query := r.expensesQuery([]string{ticketID})
+	if excludeEntryID != "" {
+		query = query.Where("id <> ?", excludeEntryID)
+	}
+	err := query.Select("COALESCE(SUM(amount), 0)").Scan(&total).Error
+	return total, err
+}
+
+// expensesQuery selects the live expense entries of the tickets, technician labor payouts apart
+func (r *ticketBudgetRepository) expensesQuery(ticketIDs []string) *gorm.DB {
+	return r.db.Model(&models.FinancialEntry{}).
+		Where("ticket_id IN ? AND type = ? AND status <> ?",
+			ticketIDs, models.FinancialEntryTypeExpense, models.FinancialEntryStatusCancelled).
+		Where("NOT (category = ? AND COALESCE(subcategory, '') IN ?)", "technician_payment", models.BudgetExcludedPayouts)
+}
+
+func (r *ticketBudgetRepository) FindLeadTechnicians(ticketIDs []string) (map[string]models.Technician, error) {
+	leads := make(map[string]models.Technician, len(ticketIDs))
+	if len(ticketIDs) == 0 {
+		return leads, nil
+	}
+	var assignments []models.TicketTechnician
+	err := r.db.
+		Preload("Technician").
+		Where("ticket_id IN ? AND role = ?", ticketIDs, models.AssignmentRoleLead).
+		Find(&assignments).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range assignments {
+		if a.Technician != nil {
+			leads[a.TicketID] = *a.Technician
+		}
+	}
+	return leads, nil
+}
+
+func (r *ticketBudgetRepository) FindOverrides(ticketID string) ([]models.TicketBudgetOverride, error) {
+	var overrides []models.TicketBudgetOverride
+	err := r.db.
+		Preload("Requester").
+		Where("ticket_id = ?", ticketID).
+		Order("created_at DESC").
+		Find(&overrides).Error
+	return overrides, err
+}
+
+func (r *ticketBudgetRepository) FindOverrideByID(id string) (*models.TicketBudgetOverride, error) {
+	var override models.TicketBudgetOverride
+	if err := r.db.Preload("Ticket").Preload("Requester").Where("id = ?", id).First(&override).Error; err != nil {
+		return nil, err
+	}
+	return &override, nil
+}
+
+func (r *ticketBudgetRepository) CreateOverride(override *models.TicketBudgetOverride) error {
+	return r.db.Omit(clause.Associations).Create(override).Error
+}
+
+func (r *ticketBudgetRepository) DecideOverride(override *models.TicketBudgetOverride) (bool, error) {
+	result := r.db.Model(&models.TicketBudgetOverride{}).
+		Where("id = ? AND status = ?", override.ID, models.BudgetOverridePending).
+		Updates(map[string]interface{}{
+			"status":         override.Status,
+			"decided_by":     override.DecidedBy,
+			"decided_at":     override.DecidedAt,
+			"decision_notes": override.DecisionNotes,
+		})
+	return result.RowsAffected == 1, result.Error
+}
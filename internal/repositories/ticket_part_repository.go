@@ -0,0 +1,56 @@
+package repositories
+
+import (
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+type TicketPartRepository interface {
+	Create(part *models.TicketPart) error
+	FindByID(id string) (*models.TicketPart, error)
+	FindByTicket(ticketID string) ([]models.TicketPart, error)
+	// FindUnbilled returns the billable parts of the ticket not billed yet, leaving out
+	// those whose movement was rejected
+	FindUnbilled(ticketID string) ([]models.TicketPart, error)
+}
+
+type ticketPartRepository struct {
+	db *gorm.DB
+}
+
+func NewTicketPartRepository(db *gorm.DB) TicketPartRepository {
+	return &ticketPartRepository{db: db}
+}
+
+func (r *ticketPartRepository) Create(part *models.TicketPart) error {
+	return r.db.Create(part).Error
+}
+
+func (r *ticketPartRepository) FindByID(id string) (*models.TicketPart, error) {
+	var part models.TicketPart
+	err := r.db.Preload("Item").Preload("Location").Preload("Movement").First(&part, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &part, nil
+}
+
+func (r *ticketPartRepository) FindByTicket(ticketID string) ([]models.TicketPart, error) {
+	var parts []models.TicketPart
+	err := r.db.Preload("Item").Preload("Location").Preload("Movement").
+		Where("ticket_id = ?", ticketID).
+		Order("created_at").
+		Find(&parts).Error
+	return parts, err
+}
+
+func (r *ticketPartRepository) FindUnbilled(ticketID string) ([]models.TicketPart, error) {
+	var parts []models.TicketPart
+	err := r.db.Preload("Item").
+		Joins("JOIN stock_movements ON stock_movements.id = ticket_parts.movement_id").
+		Where("ticket_parts.ticket_id = ? AND ticket_parts.billable AND ticket_parts.billed_entry_id IS NULL", ticketID).
+		Where("stock_movements.status <> ?", models.MovementStatusRejected).
+		Order("ticket_parts.created_at").
+		Find(&parts).Error
+	return parts, err
+}
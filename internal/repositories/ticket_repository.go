@@ -1,32 +1,82 @@
 package repositories
 
 import (
+	"context"
+	"time"
+
 	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/tenant"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type TicketRepository interface {
 	Create(ticket *models.Ticket) error
 	FindAll(page, size int, filters *models.TicketFilters) ([]models.Ticket, int64, error)
+	// FindByCursor returns the tickets of the filters past filters.Cursor, newest first, as
+	// fetched by keysetScope (not counted)
+	FindByCursor(size int, filters *models.TicketFilters) ([]models.Ticket, error)
 	FindByID(id string) (*models.Ticket, error)
+	// FindChangedSince returns the tickets of the filters changed (updated or deleted) after
+	// the keyset position (changedAt, id), in change order; deleted ones have DeletedAt set
+	FindChangedSince(changedAt time.Time, afterID string, limit int, filters *models.TicketFilters) ([]models.Ticket, error)
+	// Update saves the ticket if it is still at its Version and bumps it (ErrVersionConflict otherwise)
 	Update(ticket *models.Ticket) error
 	Delete(id string) error
 	CountByStatus(status string) (int64, error)
 	CountAll() (int64, error)
 	GroupByStatus() ([]models.TicketsByStatus, error)
-	UpdateStatus(id string, status string) error
+	GroupBySource() ([]models.TicketsBySource, error)
+	UpdateStatus(id string, status string, actorID string, notes string) error
 	AssignTechnicians(id string, technicians []models.Technician) error
+	SetAssignments(id string, assignments []models.TicketTechnician) error
+	// AssignIfUnassigned sets the crew only while the ticket has none (false otherwise)
+	AssignIfUnassigned(id string, assignments []models.TicketTechnician) (bool, error)
+	FindAssignments(id string) ([]models.TicketTechnician, error)
 	GetRecent(limit int) ([]models.Ticket, error)
+	SetComplaintResolvedAt(ticketID string, resolvedAt *time.Time) error
+	ClearCancellation(ticketID string) error
+	// WithContext returns the repository running its statements with ctx, inside the
+	// tenant it carries
+	WithContext(ctx context.Context) TicketRepository
+	// WithScope returns the repository finding and writing tickets by id only inside the
+	// hierarchy scope (the listings take it in TicketFilters.Scope)
+	WithScope(scope *models.AccessScope) TicketRepository
+	// NodeInScope tells whether tickets may be placed in the node, inside the scope
+	NodeInScope(nodeID uint) (bool, error)
 }
 
 type ticketRepository struct {
-	db *gorm.DB
+	db    *gorm.DB
+	scope *models.AccessScope
 }
 
 func NewTicketRepository(db *gorm.DB) TicketRepository {
 	return &ticketRepository{db: db}
 }
 
+func (r *ticketRepository) WithContext(ctx context.Context) TicketRepository {
+	return &ticketRepository{db: r.db.WithContext(ctx), scope: r.scope}
+}
+
+// WithScope keeps the scope apart from db (unlike scopedDB): the repository also writes
+// the events, SLA pauses and crew of the tickets, which have no node column.
+func (r *ticketRepository) WithScope(scope *models.AccessScope) TicketRepository {
+	if !scope.Restricted() {
+		return r
+	}
+	return &ticketRepository{db: r.db, scope: scope}
+}
+
+func (r *ticketRepository) NodeInScope(nodeID uint) (bool, error) {
+	return nodeInScope(r.db, r.scope, nodeID)
+}
+
+// tickets applies the repository scope to a query on the tickets table
+func (r *ticketRepository) tickets(db *gorm.DB) *gorm.DB {
+	return db.Scopes(NodeScope(r.scope, "tickets.node_id"))
+}
+
 func (r *ticketRepository) Create(ticket *models.Ticket) error {
 	return r.db.Create(ticket).Error
 }
@@ -35,41 +85,7 @@ func (r *ticketRepository) FindAll(page, size int, filters *models.TicketFilters
 	var tickets []models.Ticket
 	var total int64
 
-	query := r.db.Model(&models.Ticket{})
-
-	// Apply filters
-	if filters != nil {
-		if filters.Status != "" {
-			query = query.Where("status = ?", filters.Status)
-		}
-		if filters.Priority != "" {
-			query = query.Where("priority = ?", filters.Priority)
-		}
-		if filters.NodeID != "" {
-			query = query.Where("node_id = ?", filters.NodeID)
-		}
-		if filters.ClientID != "" {
-			query = query.Where("client_id = ?", filters.ClientID)
-		}
-		if filters.CategoryID != "" {
-			query = query.Where("category_id = ?", filters.CategoryID)
-		}
-		if filters.TechnicianID != "" {
-			query = query.Joins("JOIN ticket_technicians tt ON tt.ticket_id = tickets.id").
-				Where("tt.technician_id = ?", filters.TechnicianID)
-		}
-		if filters.Search != "" {
-			search := "%" + filters.Search + "%"
-			query = query.Where("error_description ILIKE ? OR serial_number ILIKE ? OR computer_brand ILIKE ? OR computer_model ILIKE ?", 
-				search, search, search, search)
-		}
-		if filters.DateFrom != "" {
-			query = query.Where("created_at >= ?", filters.DateFrom)
-		}
-		if filters.DateTo != "" {
-			query = query.Where("created_at <= ?", filters.DateTo)
-		}
-	}
+	query := applyTicketFilters(r.db.Model(&models.Ticket{}), filters)
 
 	// Count total with filters applied
 	query.Count(&total)
@@ -81,6 +97,7 @@ func (r *ticketRepository) FindAll(page, size int, filters *models.TicketFilters
 		Preload("Client").
 		Preload("Category").
 		Preload("Technicians").
+		Preload("Assignments.Technician").
 		Offset(offset).
 		Limit(size).
 		Order("created_at DESC").
@@ -92,14 +109,99 @@ func (r *ticketRepository) FindAll(page, size int, filters *models.TicketFilters
 	return tickets, total, nil
 }
 
+func (r *ticketRepository) FindByCursor(size int, filters *models.TicketFilters) ([]models.Ticket, error) {
+	var tickets []models.Ticket
+	err := applyTicketFilters(r.db.Model(&models.Ticket{}), filters).
+		Scopes(keysetScope(*filters.Cursor, size, true, "tickets.id::text", "tickets.created_at")).
+		Preload("Node").
+		Preload("Client").
+		Preload("Category").
+		Preload("Technicians").
+		Preload("Assignments.Technician").
+		Find(&tickets).Error
+	return tickets, err
+}
+
+// applyTicketFilters adds the conditions of the list filters to query
+func applyTicketFilters(query *gorm.DB, filters *models.TicketFilters) *gorm.DB {
+	if filters == nil {
+		return query
+	}
+	query = query.Scopes(NodeScope(filters.Scope, "tickets.node_id"))
+	if filters.Status != "" {
+		query = query.Where("status = ?", filters.Status)
+	}
+	if filters.Type != "" {
+		query = query.Where("type = ?", filters.Type)
+	}
+	if filters.Priority != "" {
+		query = query.Where("priority = ?", filters.Priority)
+	}
+	if filters.Source != "" {
+		query = query.Where("source = ?", filters.Source)
+	}
+	if filters.CampaignID != "" {
+		query = query.Where("campaign_id = ?", filters.CampaignID)
+	}
+	if filters.NodeID != "" {
+		query = query.Where("node_id = ?", filters.NodeID)
+	}
+	if filters.ClientID != "" {
+		query = query.Where("client_id = ?", filters.ClientID)
+	}
+	if filters.CategoryID != "" {
+		query = query.Where("category_id = ?", filters.CategoryID)
+	}
+	if filters.TechnicianID != "" {
+		query = query.Joins("JOIN ticket_technicians tt ON tt.ticket_id = tickets.id").
+			Where("tt.technician_id = ?", filters.TechnicianID)
+		if filters.TechnicianRole != "" {
+			query = query.Where("tt.role = ?", filters.TechnicianRole)
+		}
+	}
+	if filters.Search != "" {
+		search := "%" + filters.Search + "%"
+		query = query.Where("error_description ILIKE ? OR serial_number ILIKE ? OR computer_brand ILIKE ? OR computer_model ILIKE ?", 
+			search, search, search, search)
+	}
+	if filters.DateFrom != "" {
+		query = query.Where("created_at >= ?", filters.DateFrom)
+	}
+	if filters.DateTo != "" {
+		query = query.Where("created_at <= ?", filters.DateTo)
+	}
+	return query
+}
+
+// ticketChangedAt is when a ticket last changed: soft deletes don't touch updated_at
+const ticketChangedAt = "COALESCE(tickets.deleted_at, tickets.updated_at)"
+
+func (r *ticketRepository) FindChangedSince(changedAt time.Time, afterID string, limit int, filters *models.TicketFilters) ([]models.Ticket, error) {
+	var tickets []models.Ticket
+	err := applyTicketFilters(r.db.Unscoped().Model(&models.Ticket{}), filters).
+		Where("("+ticketChangedAt+" > ? OR ("+ticketChangedAt+" = ? AND tickets.id::text > ?))", changedAt, changedAt, afterID).
+		Preload("Node").
+		Preload("Client").
+		Preload("Category").
+		Preload("Technicians").
+		Preload("Assignments.Technician").
+		Order(ticketChangedAt + ", tickets.id::text").
+		Limit(limit).
+		Find(&tickets).Error
+	return tickets, err
+}
+
 func (r *ticketRepository) FindByID(id string) (*models.Ticket, error) {
 	var ticket models.Ticket
-	err := r.db.
+	err := r.tickets(r.db).
 		Preload("Node").
 		Preload("Client").
 		Preload("Category").
 		Preload("Technicians").
+		Preload("Assignments.Technician").
 		Preload("Files").
+		Preload("Complaint").
+		Preload("Cancellation.Reason").
 		Where("id = ?", id).
 		First(&ticket).Error
 	if err != nil {
@@ -109,13 +211,18 @@ func (r *ticketRepository) FindByID(id string) (*models.Ticket, error) {
 }
 
 func (r *ticketRepository) Update(ticket *models.Ticket) error {
-	return r.db.Save(ticket).Error
+	return updateVersioned(r.tickets(r.db), ticket, &ticket.Version)
 }
 
 func (r *ticketRepository) Delete(id string) error {
-	// First remove technician associations
-	r.db.Exec("DELETE FROM ticket_technicians WHERE ticket_id = ?", id)
-	return r.db.Where("id = ?", id).Delete(&models.Ticket{}).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := r.tickets(tx).Where("id = ?", id).Delete(&models.Ticket{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		// Raw SQL is not tenant scoped: the associations go only once the ticket did
+		return tx.Exec("DELETE FROM ticket_technicians WHERE ticket_id = ?", id).Error
+	})
 }
 
 func (r *ticketRepository) CountByStatus(status string) (int64, error) {
@@ -140,16 +247,166 @@ func (r *ticketRepository) GroupByStatus() ([]models.TicketsByStatus, error) {
 	return result, err
 }
 
-func (r *ticketRepository) UpdateStatus(id string, status string) error {
-	return r.db.Model(&models.Ticket{}).Where("id = ?", id).Update("status", status).Error
+func (r *ticketRepository) GroupBySource() ([]models.TicketsBySource, error) {
+	var result []models.TicketsBySource
+	err := r.db.Model(&models.Ticket{}).
+		Select("source, COUNT(*) as count").
+		Group("source").
+		Order("count DESC").
+		Scan(&result).Error
+	return result, err
+}
+
+// UpdateStatus changes the status and records the transition on the ticket timeline.
+// Entering or leaving a waiting status opens or closes an SLA pause in the same
+// transaction; closing stamps closed_at and enqueues the income entry and the
+// consumption of the reserved parts in the outbox, reopening clears closed_at.
+func (r *ticketRepository) UpdateStatus(id string, status string, actorID string, notes string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var ticket models.Ticket
+		if err := r.tickets(tx).Select("id, tenant_id, status").First(&ticket, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if string(ticket.Status) == status {
+			return nil
+		}
+
+		// A status change is an edit too: offline copies based on the old version conflict
+		updates := map[string]interface{}{"status": status, "version": gorm.Expr("version + 1")}
+		if models.TicketStatus(status) == models.TicketStatusClosed {
+			closedAt := time.Now()
+			updates["closed_at"] = closedAt
+			if err := enqueueTicketClosed(tx, &ticket, actorID, closedAt); err != nil {
+				return err
+			}
+		} else if ticket.Status == models.TicketStatusClosed {
+			updates["closed_at"] = nil
+		}
+		if err := tx.Model(&models.Ticket{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(models.NewTicketEvent(id, models.TicketEventStatusChanged, actorID, map[string]string{
+			"from": string(ticket.Status),
+			"to":   status,
+		})).Error; err != nil {
+			return err
+		}
+
+		if models.IsWaitingStatus(ticket.Status) {
+			if err := resumeSLA(tx, id, actorID, notes); err != nil {
+				return err
+			}
+		}
+		if models.IsWaitingStatus(models.TicketStatus(status)) {
+			return pauseSLA(tx, id, models.TicketStatus(status), actorID, notes)
+		}
+		return nil
+	})
+}
+
+// enqueueTicketClosed writes the outbox events of a ticket closing, in the tenant of the
+// ticket whoever closed it
+func enqueueTicketClosed(tx *gorm.DB, ticket *models.Ticket, actorID string, closedAt time.Time) error {
+	tx = tx.WithContext(tenant.WithID(tx.Statement.Context, ticket.TenantID))
+	payload := models.TicketClosedPayload{TicketID: ticket.ID, ClosedBy: actorID, ClosedAt: closedAt}
+	for _, topic := range []string{models.OutboxTopicTicketIncome, models.OutboxTopicTicketStock} {
+		event, err := models.NewOutboxEvent(topic, "ticket", ticket.ID, payload)
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(event).Error; err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (r *ticketRepository) AssignTechnicians(id string, technicians []models.Technician) error {
-	var ticket models.Ticket
-	if err := r.db.First(&ticket, "id = ?", id).Error; err != nil {
-		return err
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var ticket models.Ticket
+		if err := r.tickets(tx).First(&ticket, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&ticket).Association("Technicians").Replace(technicians); err != nil {
+			return err
+		}
+		return r.bumpVersion(tx, id)
+	})
+}
+
+// SetAssignments replaces the ticket crew (technicians with roles) atomically
+func (r *ticketRepository) SetAssignments(id string, assignments []models.TicketTechnician) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := r.bumpVersion(tx, id); err != nil {
+			return err
+		}
+		if err := tx.Where("ticket_id = ?", id).Delete(&models.TicketTechnician{}).Error; err != nil {
+			return err
+		}
+		if len(assignments) == 0 {
+			return nil
+		}
+		for i := range assignments {
+			assignments[i].TicketID = id
+		}
+		return tx.Create(&assignments).Error
+	})
+}
+
+// AssignIfUnassigned sets the crew of a ticket that has no technician yet. The ticket row
+// is locked while its crew is checked, so two writers can't both assign it.
+func (r *ticketRepository) AssignIfUnassigned(id string, assignments []models.TicketTechnician) (bool, error) {
+	assigned := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var ticket models.Ticket
+		if err := r.tickets(tx).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").First(&ticket, "id = ?", id).Error; err != nil {
+			return err
+		}
+		var crew int64
+		if err := tx.Model(&models.TicketTechnician{}).Where("ticket_id = ?", id).Count(&crew).Error; err != nil {
+			return err
+		}
+		if crew > 0 {
+			return nil
+		}
+
+		if err := r.bumpVersion(tx, id); err != nil {
+			return err
+		}
+		for i := range assignments {
+			assignments[i].TicketID = id
+		}
+		if err := tx.Create(&assignments).Error; err != nil {
+			return err
+		}
+		assigned = true
+		return nil
+	})
+	return assigned, err
+}
+
+// bumpVersion marks a change of the ticket made outside Update (crew, status), so the
+// copies read before it conflict; gorm.ErrRecordNotFound if the ticket is out of reach
+func (r *ticketRepository) bumpVersion(tx *gorm.DB, id string) error {
+	result := r.tickets(tx).Model(&models.Ticket{}).Where("id = ?", id).
+		Update("version", gorm.Expr("version + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
 	}
-	return r.db.Model(&ticket).Association("Technicians").Replace(technicians)
+	return nil
+}
+
+func (r *ticketRepository) FindAssignments(id string) ([]models.TicketTechnician, error) {
+	var assignments []models.TicketTechnician
+	err := r.db.Preload("Technician").
+		Where("ticket_id = ?", id).
+		Order("CASE WHEN role = 'LEAD' THEN 0 ELSE 1 END, created_at ASC").
+		Find(&assignments).Error
+	return assignments, err
 }
 
 func (r *ticketRepository) GetRecent(limit int) ([]models.Ticket, error) {
@@ -157,3 +414,15 @@ func (r *ticketRepository) GetRecent(limit int) ([]models.Ticket, error) {
 	err := r.db.Order("updated_at DESC").Limit(limit).Find(&tickets).Error
 	return tickets, err
 }
+
+// SetComplaintResolvedAt stamps (or clears, on reopening) the resolution of a complaint ticket
+func (r *ticketRepository) SetComplaintResolvedAt(ticketID string, resolvedAt *time.Time) error {
+	return r.db.Model(&models.TicketComplaint{}).
+		Where("ticket_id = ?", ticketID).
+		Update("resolved_at", resolvedAt).Error
+}
+
+// ClearCancellation removes the cancellation record of a ticket being reopened
+func (r *ticketRepository) ClearCancellation(ticketID string) error {
+	return r.db.Where("ticket_id = ?", ticketID).Delete(&models.TicketCancellation{}).Error
+}
@@ -0,0 +1,83 @@
+package repositories
+
+import (
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+type TicketTimelineRepository interface {
+	CreateComment(comment *models.TicketComment) error
+	ListComments(ticketID string) ([]models.TicketComment, error)
+	FindEventsAfter(ticketID string, afterID uint, limit int) ([]models.TicketEvent, error)
+	// FindEventsByType returns the first events of the given types, oldest first
+	FindEventsByType(ticketID string, types []string, limit int) ([]models.TicketEvent, error)
+	LatestEventID(ticketID string) (uint, error)
+}
+
+type ticketTimelineRepository struct {
+	db *gorm.DB
+}
+
+func NewTicketTimelineRepository(db *gorm.DB) TicketTimelineRepository {
+	return &ticketTimelineRepository{db: db}
+}
+
+// CreateComment stores the comment and its timeline event atomically. Client comments
+// get their own event type so they don't count as a response from the team.
+func (r *ticketTimelineRepository) CreateComment(comment *models.TicketComment) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(comment).Error; err != nil {
+			return err
+		}
+		if comment.Source == models.CommentSourceClient {
+			return tx.Create(models.NewTicketEvent(comment.TicketID, models.TicketEventClientComment, "", map[string]string{
+				"commentId":  comment.ID,
+				"authorName": comment.AuthorName,
+				"body":       comment.Body,
+			})).Error
+		}
+		return tx.Create(models.NewTicketEvent(comment.TicketID, models.TicketEventCommentCreated, comment.UserID, map[string]string{
+			"commentId": comment.ID,
+			"userId":    comment.UserID,
+			"body":      comment.Body,
+		})).Error
+	})
+}
+
+func (r *ticketTimelineRepository) ListComments(ticketID string) ([]models.TicketComment, error) {
+	var comments []models.TicketComment
+	err := r.db.Preload("User").
+		Where("ticket_id = ?", ticketID).
+		Order("created_at ASC").
+		Find(&comments).Error
+	return comments, err
+}
+
+func (r *ticketTimelineRepository) FindEventsAfter(ticketID string, afterID uint, limit int) ([]models.TicketEvent, error) {
+	var events []models.TicketEvent
+	err := r.db.
+		Where("ticket_id = ? AND id > ?", ticketID, afterID).
+		Order("id ASC").
+		Limit(limit).
+		Find(&events).Error
+	return events, err
+}
+
+func (r *ticketTimelineRepository) FindEventsByType(ticketID string, types []string, limit int) ([]models.TicketEvent, error) {
+	var events []models.TicketEvent
+	err := r.db.
+		Where("ticket_id = ? AND type IN ?", ticketID, types).
+		Order("id ASC").
+		Limit(limit).
+		Find(&events).Error
+	return events, err
+}
+
+func (r *ticketTimelineRepository) LatestEventID(ticketID string) (uint, error) {
+	var id uint
+	err := r.db.Model(&models.TicketEvent{}).
+		Where("ticket_id = ?", ticketID).
+		Select("COALESCE(MAX(id), 0)").
+		Scan(&id).Error
+	return id, err
+}
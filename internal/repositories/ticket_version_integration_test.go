@@ -0,0 +1,63 @@
+//go:build integration
+
+package repositories_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"github.com/shigake/tech-iq-back/internal/testutil"
+)
+
+// TestTicketCrewChangesBumpVersion checks that the writes made outside Update make the
+// copies read before them conflict
+func TestTicketCrewChangesBumpVersion(t *testing.T) {
+	env.Reset(t)
+	tickets := repositories.NewTicketRepository(env.DB)
+
+	ticket := &models.Ticket{ErrorDescription: "Versioned ticket", Status: models.TicketStatusOpen, Priority: models.TicketPriorityNormal}
+	if err := tickets.Create(ticket); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	writes := []struct {
+		name  string
+		write func() error
+	}{
+		{"set assignments", func() error {
+			return tickets.SetAssignments(ticket.ID, []models.TicketTechnician{
+				{TechnicianID: testutil.TechnicianID, Role: models.AssignmentRoleLead},
+			})
+		}},
+		{"assign technicians", func() error {
+			return tickets.AssignTechnicians(ticket.ID, []models.Technician{{ID: testutil.TechnicianID}})
+		}},
+		{"update status", func() error {
+			return tickets.UpdateStatus(ticket.ID, string(models.TicketStatusInProgress), testutil.AdminUserID, "")
+		}},
+	}
+	for _, w := range writes {
+		t.Run(w.name, func(t *testing.T) {
+			stale, err := tickets.FindByID(ticket.ID)
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if err := w.write(); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+			current, err := tickets.FindByID(ticket.ID)
+			if err != nil {
+				t.Fatalf("reload: %v", err)
+			}
+			if current.Version != stale.Version+1 {
+				t.Fatalf("version = %d, want %d", current.Version, stale.Version+1)
+			}
+			stale.ErrorDescription = "Stale edit"
+			if err := tickets.Update(stale); !errors.Is(err, repositories.ErrVersionConflict) {
+				t.Fatalf("stale update: err = %v, want ErrVersionConflict", err)
+			}
+		})
+	}
+}
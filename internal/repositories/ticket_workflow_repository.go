@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+type TicketWorkflowRepository interface {
+	// FindByCategory returns the transitions of the category, or of the default workflow
+	// when categoryID is nil
+	FindByCategory(categoryID *string) ([]models.TicketStatusTransition, error)
+	// Replace swaps the transitions of the category (or default workflow) in one transaction
+	Replace(categoryID *string, transitions []models.TicketStatusTransition) error
+	DeleteByCategory(categoryID *string) error
+}
+
+type ticketWorkflowRepository struct {
+	db *gorm.DB
+}
+
+func NewTicketWorkflowRepository(db *gorm.DB) TicketWorkflowRepository {
+	return &ticketWorkflowRepository{db: db}
+}
+
+func byCategory(db *gorm.DB, categoryID *string) *gorm.DB {
+	if categoryID == nil {
+		return db.Where("category_id IS NULL")
+	}
+	return db.Where("category_id = ?", *categoryID)
+}
+
+func (r *ticketWorkflowRepository) FindByCategory(categoryID *string) ([]models.TicketStatusTransition, error) {
+	var transitions []models.TicketStatusTransition
+	err := byCategory(r.db, categoryID).Order("from_status, to_status").Find(&transitions).Error
+	return transitions, err
+}
+
+func (r *ticketWorkflowRepository) Replace(categoryID *string, transitions []models.TicketStatusTransition) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := byCategory(tx, categoryID).Delete(&models.TicketStatusTransition{}).Error; err != nil {
+			return err
+		}
+		if len(transitions) == 0 {
+			return nil
+		}
+		return tx.Create(&transitions).Error
+	})
+}
+
+func (r *ticketWorkflowRepository) DeleteByCategory(categoryID *string) error {
+	return byCategory(r.db, categoryID).Delete(&models.TicketStatusTransition{}).Error
+}
@@ -1,6 +1,7 @@
 package repositories
 
 import (
+	"context"
 	"github.com/shigake/tech-iq-back/internal/models"
 	"gorm.io/gorm"
 )
@@ -14,6 +15,10 @@ type UserRepository interface {
 	GetAll() ([]models.User, error)
 	GetAllPaginated(page, limit int, search string) ([]models.User, int64, error)
 	CountByRole(role string) (int64, error)
+	FindByRole(role string) ([]models.User, error)
+	// WithContext returns the repository running its statements with ctx, inside the
+	// tenant it carries
+	WithContext(ctx context.Context) UserRepository
 }
 
 type userRepository struct {
@@ -24,6 +29,10 @@ func NewUserRepository(db *gorm.DB) UserRepository {
 	return &userRepository{db: db}
 }
 
+func (r *userRepository) WithContext(ctx context.Context) UserRepository {
+	return &userRepository{db: r.db.WithContext(ctx)}
+}
+
 func (r *userRepository) Create(user *models.User) error {
 	return r.db.Create(user).Error
 }
@@ -96,3 +105,9 @@ func (r *userRepository) CountByRole(role string) (int64, error) {
 	err := r.db.Model(&models.User{}).Where("role = ?", role).Count(&count).Error
 	return count, err
 }
+
+func (r *userRepository) FindByRole(role string) ([]models.User, error) {
+	var users []models.User
+	err := r.db.Where("role = ?", role).Find(&users).Error
+	return users, err
+}
@@ -0,0 +1,27 @@
+package repositories
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// ErrVersionConflict is returned when the row was changed since it was read
+var ErrVersionConflict = errors.New("record was modified by another user")
+
+// updateVersioned saves every field of model, like Save, as long as the stored row is
+// still at *version (the version model was read at). The version is bumped on success.
+func updateVersioned(db *gorm.DB, model interface{}, version *int) error {
+	expected := *version
+	*version = expected + 1
+	result := db.Model(model).Where("version = ?", expected).Select("*").Updates(model)
+	if result.Error != nil {
+		*version = expected
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		*version = expected
+		return ErrVersionConflict
+	}
+	return nil
+}
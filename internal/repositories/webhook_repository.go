@@ -0,0 +1,147 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WebhookRepository interface {
+	// WithContext returns the repository running its statements with ctx, inside the tenant it carries
+	WithContext(ctx context.Context) WebhookRepository
+	FindAll() ([]models.WebhookSubscription, error)
+	// FindActiveForEvent returns the active subscriptions listening to the event
+	FindActiveForEvent(event string) ([]models.WebhookSubscription, error)
+	FindByID(id string) (*models.WebhookSubscription, error)
+	Create(subscription *models.WebhookSubscription) error
+	Update(subscription *models.WebhookSubscription) error
+	// Delete removes the subscription with its delivery log
+	Delete(id string) error
+
+	CreateDeliveries(deliveries []models.WebhookDelivery) error
+	// ClaimDueDeliveries locks the pending deliveries due at now and pushes their next
+	// attempt by lease, so other instances skip them while they are being sent
+	ClaimDueDeliveries(now time.Time, lease time.Duration, limit int) ([]models.WebhookDelivery, error)
+	UpdateDelivery(delivery *models.WebhookDelivery) error
+	FindDeliveries(filter models.WebhookDeliveryFilter, page, size int) ([]models.WebhookDelivery, int64, error)
+	FindDeliveryByID(id string) (*models.WebhookDelivery, error)
+}
+
+type webhookRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookRepository(db *gorm.DB) WebhookRepository {
+	return &webhookRepository{db: db}
+}
+
+func (r *webhookRepository) WithContext(ctx context.Context) WebhookRepository {
+	return &webhookRepository{db: r.db.WithContext(ctx)}
+}
+
+func (r *webhookRepository) FindAll() ([]models.WebhookSubscription, error) {
+	var subscriptions []models.WebhookSubscription
+	err := r.db.Order("name").Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+func (r *webhookRepository) FindActiveForEvent(event string) ([]models.WebhookSubscription, error) {
+	var subscriptions []models.WebhookSubscription
+	err := r.db.Where("active = ? AND (cardinality(events) = 0 OR events IS NULL OR ? = ANY(events))", true, event).
+		Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+func (r *webhookRepository) FindByID(id string) (*models.WebhookSubscription, error) {
+	var subscription models.WebhookSubscription
+	if err := r.db.First(&subscription, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+func (r *webhookRepository) Create(subscription *models.WebhookSubscription) error {
+	return r.db.Create(subscription).Error
+}
+
+func (r *webhookRepository) Update(subscription *models.WebhookSubscription) error {
+	return r.db.Save(subscription).Error
+}
+
+func (r *webhookRepository) Delete(id string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&models.WebhookSubscription{}, "id = ?", id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Delete(&models.WebhookDelivery{}, "subscription_id = ?", id).Error
+	})
+}
+
+func (r *webhookRepository) CreateDeliveries(deliveries []models.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return r.db.Create(&deliveries).Error
+}
+
+func (r *webhookRepository) ClaimDueDeliveries(now time.Time, lease time.Duration, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.WebhookDeliveryPending, now).
+			Order("next_attempt_at").
+			Limit(limit).
+			Find(&deliveries).Error
+		if err != nil || len(deliveries) == 0 {
+			return err
+		}
+		ids := make([]string, len(deliveries))
+		for i := range deliveries {
+			ids[i] = deliveries[i].ID
+		}
+		return tx.Model(&models.WebhookDelivery{}).
+			Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(lease)).Error
+	})
+	return deliveries, err
+}
+
+func (r *webhookRepository) UpdateDelivery(delivery *models.WebhookDelivery) error {
+	return r.db.Save(delivery).Error
+}
+
+func (r *webhookRepository) FindDeliveries(filter models.WebhookDeliveryFilter, page, size int) ([]models.WebhookDelivery, int64, error) {
+	query := r.db.Model(&models.WebhookDelivery{})
+	if filter.SubscriptionID != "" {
+		query = query.Where("subscription_id = ?", filter.SubscriptionID)
+	}
+	if filter.Event != "" {
+		query = query.Where("event = ?", filter.Event)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var deliveries []models.WebhookDelivery
+	err := query.Order("created_at DESC").Offset(page * size).Limit(size).Find(&deliveries).Error
+	return deliveries, total, err
+}
+
+func (r *webhookRepository) FindDeliveryByID(id string) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	if err := r.db.First(&delivery, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
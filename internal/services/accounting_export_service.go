@@ -0,0 +1,302 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAccountingLayoutNotFound   = errors.New("accounting layout not found")
+	ErrAccountingInvalidField     = errors.New("unknown layout column field")
+	ErrAccountingWidthRequired    = errors.New("every column of a fixed-width layout needs a width")
+	ErrAccountingInvalidDelimiter = errors.New("the delimiter must not be a quote or a line break")
+	ErrAccountingDuplicateMapping = errors.New("the category is mapped more than once")
+	ErrFinancialExportNotFound    = errors.New("financial export not found")
+	ErrFinancialExportInvalidDate = errors.New("invalid date format, expected YYYY-MM-DD")
+	ErrFinancialExportRange       = errors.New("end date must not be before start date, at most 366 days apart")
+	ErrFinancialExportNotReady    = errors.New("the export was not generated yet")
+)
+
+const maxFinancialExportDays = 366
+
+// AccountingExportService keeps the accounting layouts and generates the financial
+// exports with them. Requesting an export only queues it: the file is generated in the
+// outbox dispatcher and kept through the file service.
+type AccountingExportService interface {
+	ListLayouts() ([]models.AccountingLayout, error)
+	GetLayout(id string) (*models.AccountingLayout, error)
+	CreateLayout(req *models.SaveAccountingLayoutRequest, userID string) (*models.AccountingLayout, error)
+	UpdateLayout(id string, req *models.SaveAccountingLayoutRequest) (*models.AccountingLayout, error)
+	DeleteLayout(id string) error
+
+	RequestExport(req *models.CreateFinancialExportRequest, userID string) (*models.FinancialExport, error)
+	ListExports(filter models.FinancialExportFilter, page, size int) (*models.PaginatedResponse, error)
+	GetExport(id string) (*models.FinancialExport, error)
+	// Generate writes the file of the export; it is the handler of the export topic
+	Generate(id string) (string, error)
+	DownloadURL(id string) (*models.FileDownload, error)
+	// WithContext returns the service running its queries with ctx, inside the tenant it carries
+	WithContext(ctx context.Context) AccountingExportService
+}
+
+type accountingExportService struct {
+	repo  repositories.AccountingExportRepository
+	files FileService
+}
+
+func NewAccountingExportService(repo repositories.AccountingExportRepository, files FileService) AccountingExportService {
+	return &accountingExportService{repo: repo, files: files}
+}
+
+func (s *accountingExportService) WithContext(ctx context.Context) AccountingExportService {
+	scoped := *s
+	scoped.repo = s.repo.WithContext(ctx)
+	scoped.files = s.files.WithContext(ctx)
+	return &scoped
+}
+
+// RegisterAccountingExportHandlers registers the outbox handler generating the queued exports
+func RegisterAccountingExportHandlers(outbox OutboxService, exports AccountingExportService) {
+	outbox.Register(models.OutboxTopicFinancialExport, func(ctx context.Context, event *models.OutboxEvent) (string, error) {
+		var payload models.FinancialExportPayload
+		if err := json.Unmarshal([]byte(event.Payload), &payload); err != nil {
+			return "", fmt.Errorf("invalid payload: %w", err)
+		}
+		return exports.WithContext(ctx).Generate(payload.ExportID)
+	})
+}
+
+func (s *accountingExportService) ListLayouts() ([]models.AccountingLayout, error) {
+	return s.repo.FindLayouts()
+}
+
+func (s *accountingExportService) GetLayout(id string) (*models.AccountingLayout, error) {
+	layout, err := s.repo.FindLayoutByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAccountingLayoutNotFound
+		}
+		return nil, err
+	}
+	return layout, nil
+}
+
+func (s *accountingExportService) CreateLayout(req *models.SaveAccountingLayoutRequest, userID string) (*models.AccountingLayout, error) {
+	layout := &models.AccountingLayout{CreatedBy: userID}
+	if err := applyAccountingLayout(layout, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.SaveLayout(layout); err != nil {
+		return nil, err
+	}
+	return layout, nil
+}
+
+func (s *accountingExportService) UpdateLayout(id string, req *models.SaveAccountingLayoutRequest) (*models.AccountingLayout, error) {
+	layout, err := s.GetLayout(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyAccountingLayout(layout, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.SaveLayout(layout); err != nil {
+		return nil, err
+	}
+	return layout, nil
+}
+
+func (s *accountingExportService) DeleteLayout(id string) error {
+	if _, err := s.GetLayout(id); err != nil {
+		return err
+	}
+	return s.repo.DeleteLayout(id)
+}
+
+// applyAccountingLayout checks the request and copies it to the layout with the defaults
+func applyAccountingLayout(layout *models.AccountingLayout, req *models.SaveAccountingLayoutRequest) error {
+	for _, column := range req.Columns {
+		if !slices.Contains(models.AccountingFields, column.Field) {
+			return fmt.Errorf("%w: %s", ErrAccountingInvalidField, column.Field)
+		}
+		if req.Format == models.AccountingFormatFixedWidth && column.Width <= 0 {
+			return ErrAccountingWidthRequired
+		}
+	}
+	seen := make(map[string]bool, len(req.Accounts))
+	for _, mapping := range req.Accounts {
+		key := string(mapping.Type) + "|" + mapping.Category + "|" + mapping.Subcategory
+		if seen[key] {
+			return fmt.Errorf("%w: %s", ErrAccountingDuplicateMapping, strings.TrimSuffix(mapping.Category+"/"+mapping.Subcategory, "/"))
+		}
+		seen[key] = true
+	}
+
+	delimiter := req.Delimiter
+	if delimiter == "" {
+		delimiter = ";"
+	}
+	if strings.ContainsAny(delimiter, "\"\r\n") {
+		return ErrAccountingInvalidDelimiter
+	}
+	separator := ","
+	if req.DecimalSeparator != nil {
+		separator = *req.DecimalSeparator
+	}
+	dateFormat := req.DateFormat
+	if dateFormat == "" {
+		dateFormat = "DD/MM/YYYY"
+	}
+	charset := req.Encoding
+	if charset == "" {
+		charset = "UTF-8"
+	}
+
+	layout.Name = req.Name
+	layout.Format = req.Format
+	layout.Delimiter = delimiter
+	layout.IncludeHeader = req.IncludeHeader && req.Format == models.AccountingFormatCSV
+	layout.DateFormat = dateFormat
+	layout.DecimalSeparator = separator
+	layout.Encoding = charset
+	layout.Columns = req.Columns
+	layout.Accounts = req.Accounts
+	layout.DefaultDebitAccount = req.DefaultDebitAccount
+	layout.DefaultCreditAccount = req.DefaultCreditAccount
+	return nil
+}
+
+func (s *accountingExportService) RequestExport(req *models.CreateFinancialExportRequest, userID string) (*models.FinancialExport, error) {
+	start, err := time.Parse("2006-01-02", req.StartDate)
+	if err != nil {
+		return nil, ErrFinancialExportInvalidDate
+	}
+	end, err := time.Parse("2006-01-02", req.EndDate)
+	if err != nil {
+		return nil, ErrFinancialExportInvalidDate
+	}
+	if end.Before(start) || end.Sub(start) > maxFinancialExportDays*24*time.Hour {
+		return nil, ErrFinancialExportRange
+	}
+	if _, err := s.GetLayout(req.LayoutID); err != nil {
+		return nil, err
+	}
+	dateField := req.DateField
+	if dateField == "" {
+		dateField = "entry_date"
+	}
+
+	export := &models.FinancialExport{
+		LayoutID:    req.LayoutID,
+		StartDate:   start,
+		EndDate:     end,
+		DateField:   dateField,
+		Type:        req.Type,
+		Status:      models.FinancialExportPending,
+		RequestedBy: userID,
+	}
+	if err := s.repo.EnqueueExport(export); err != nil {
+		return nil, err
+	}
+	return s.GetExport(export.ID)
+}
+
+func (s *accountingExportService) ListExports(filter models.FinancialExportFilter, page, size int) (*models.PaginatedResponse, error) {
+	exports, total, err := s.repo.FindExports(filter, page, size)
+	if err != nil {
+		return nil, err
+	}
+	return models.NewPaginatedResponse(exports, page, size, total), nil
+}
+
+func (s *accountingExportService) GetExport(id string) (*models.FinancialExport, error) {
+	export, err := s.repo.FindExportByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrFinancialExportNotFound
+		}
+		return nil, err
+	}
+	return export, nil
+}
+
+// Generate writes the entries of the export with its layout and stores the file. Errors
+// are recorded on the export and returned for the outbox to retry.
+func (s *accountingExportService) Generate(id string) (string, error) {
+	export, err := s.GetExport(id)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case export.Status == models.FinancialExportCompleted:
+		return "export already generated", nil
+	case export.Layout == nil:
+		export.Status = models.FinancialExportFailed
+		export.Error = ErrAccountingLayoutNotFound.Error()
+		if err := s.repo.UpdateExport(export); err != nil {
+			return "", err
+		}
+		return "layout deleted, export failed", nil
+	}
+
+	entries, err := s.repo.FindEntries(export.DateField, export.StartDate, export.EndDate, export.Type)
+	if err != nil {
+		return "", s.fail(export, err)
+	}
+	content, unmapped, err := writeAccountingFile(export.Layout, entries)
+	if err != nil {
+		return "", s.fail(export, err)
+	}
+
+	name, contentType := "lancamentos_"+export.StartDate.Format("20060102")+"_"+export.EndDate.Format("20060102"), "text/plain"
+	if export.Layout.Format == models.AccountingFormatCSV {
+		name, contentType = name+".csv", "text/csv"
+	} else {
+		name += ".txt"
+	}
+	file, err := s.files.Store(models.FileOwnerFinancialExport, export.ID, name, contentType, content, export.RequestedBy)
+	if err != nil {
+		return "", s.fail(export, err)
+	}
+
+	now := time.Now()
+	export.Status = models.FinancialExportCompleted
+	export.FileID = &file.ID
+	export.RowCount = len(entries)
+	export.Unmapped = unmapped
+	export.Error = ""
+	export.CompletedAt = &now
+	if err := s.repo.UpdateExport(export); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d entries exported", len(entries)), nil
+}
+
+func (s *accountingExportService) fail(export *models.FinancialExport, cause error) error {
+	export.Error = cause.Error()
+	if err := s.repo.UpdateExport(export); err != nil {
+		slog.Warn("Failed to record financial export error", "export_id", export.ID, "error", err)
+	}
+	return cause
+}
+
+func (s *accountingExportService) DownloadURL(id string) (*models.FileDownload, error) {
+	export, err := s.GetExport(id)
+	if err != nil {
+		return nil, err
+	}
+	if export.Status != models.FinancialExportCompleted || export.FileID == nil {
+		return nil, ErrFinancialExportNotReady
+	}
+	return s.files.DownloadURL(*export.FileID)
+}
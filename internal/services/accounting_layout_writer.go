@@ -0,0 +1,200 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// accountingDateLayouts maps the date formats of the layouts to Go time layouts
+var accountingDateLayouts = map[string]string{
+	"DD/MM/YYYY": "02/01/2006",
+	"DDMMYYYY":   "02012006",
+	"YYYY-MM-DD": "2006-01-02",
+	"YYYYMMDD":   "20060102",
+}
+
+// accountBooking is where an entry is booked in the chart of accounts
+type accountBooking struct {
+	debit, credit, history string
+	mapped                 bool
+}
+
+// writeAccountingFile writes one record per entry in the layout and returns the content
+// in the encoding of the layout, with how many entries fell back to the default accounts
+func writeAccountingFile(layout *models.AccountingLayout, entries []models.FinancialEntry) ([]byte, int, error) {
+	var buf bytes.Buffer
+	unmapped := 0
+
+	records := make([][]string, 0, len(entries))
+	for i := range entries {
+		booking := bookEntry(layout, &entries[i])
+		if !booking.mapped {
+			unmapped++
+		}
+		record := make([]string, len(layout.Columns))
+		for j, column := range layout.Columns {
+			record[j] = accountingValue(layout, column, &entries[i], booking)
+		}
+		records = append(records, record)
+	}
+
+	if layout.Format == models.AccountingFormatFixedWidth {
+		for _, record := range records {
+			for j, column := range layout.Columns {
+				buf.WriteString(fixedWidth(record[j], column))
+			}
+			buf.WriteString("\r\n")
+		}
+	} else {
+		writer := csv.NewWriter(&buf)
+		writer.Comma, _ = utf8.DecodeRuneInString(layout.Delimiter)
+		writer.UseCRLF = true
+		if layout.IncludeHeader {
+			header := make([]string, len(layout.Columns))
+			for j, column := range layout.Columns {
+				header[j] = column.Header
+				if header[j] == "" {
+					header[j] = column.Field
+				}
+			}
+			writer.Write(header)
+		}
+		writer.WriteAll(records)
+		if err := writer.Error(); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	if layout.Encoding == "WINDOWS-1252" {
+		encoded, err := encoding.ReplaceUnsupported(charmap.Windows1252.NewEncoder()).Bytes(buf.Bytes())
+		if err != nil {
+			return nil, 0, err
+		}
+		return encoded, unmapped, nil
+	}
+	return buf.Bytes(), unmapped, nil
+}
+
+// bookEntry finds the accounts of the entry: the mapping of its subcategory, else of its
+// category, else the default accounts of the layout
+func bookEntry(layout *models.AccountingLayout, entry *models.FinancialEntry) accountBooking {
+	var categoryMatch *models.AccountMapping
+	for i := range layout.Accounts {
+		mapping := &layout.Accounts[i]
+		if mapping.Type != entry.Type || mapping.Category != entry.Category {
+			continue
+		}
+		if mapping.Subcategory != "" && mapping.Subcategory == entry.Subcategory {
+			return accountBooking{debit: mapping.DebitAccount, credit: mapping.CreditAccount, history: mapping.HistoryCode, mapped: true}
+		}
+		if mapping.Subcategory == "" {
+			categoryMatch = mapping
+		}
+	}
+	if categoryMatch != nil {
+		return accountBooking{debit: categoryMatch.DebitAccount, credit: categoryMatch.CreditAccount, history: categoryMatch.HistoryCode, mapped: true}
+	}
+	return accountBooking{debit: layout.DefaultDebitAccount, credit: layout.DefaultCreditAccount}
+}
+
+func accountingValue(layout *models.AccountingLayout, column models.AccountingColumn, entry *models.FinancialEntry, booking accountBooking) string {
+	switch column.Field {
+	case models.AccountingFieldDate:
+		return accountingDate(layout, &entry.EntryDate)
+	case models.AccountingFieldDueDate:
+		return accountingDate(layout, entry.DueDate)
+	case models.AccountingFieldPaymentDate:
+		return accountingDate(layout, entry.PaymentDate)
+	case models.AccountingFieldDebitAccount:
+		return booking.debit
+	case models.AccountingFieldCreditAccount:
+		return booking.credit
+	case models.AccountingFieldHistoryCode:
+		return booking.history
+	case models.AccountingFieldAmount:
+		return accountingAmount(entry.Amount, layout.DecimalSeparator)
+	case models.AccountingFieldDescription:
+		return strings.Join(strings.Fields(entry.Description), " ")
+	case models.AccountingFieldEntryID:
+		return entry.ID
+	case models.AccountingFieldType:
+		return string(entry.Type)
+	case models.AccountingFieldCategory:
+		return entry.Category
+	case models.AccountingFieldSubcategory:
+		return entry.Subcategory
+	case models.AccountingFieldStatus:
+		return string(entry.Status)
+	case models.AccountingFieldCounterpartName:
+		if entry.Client != nil {
+			return entry.Client.FullName
+		}
+		if entry.Supplier != nil {
+			return entry.Supplier.Name
+		}
+	case models.AccountingFieldCounterpartDoc:
+		if entry.Client != nil {
+			return onlyDigits(entry.Client.CNPJ + entry.Client.CPF)
+		}
+		if entry.Supplier != nil {
+			return onlyDigits(entry.Supplier.CNPJ)
+		}
+	case models.AccountingFieldPaymentReference:
+		return entry.PaymentReference
+	case models.AccountingFieldFixed:
+		return column.Value
+	}
+	return ""
+}
+
+func accountingDate(layout *models.AccountingLayout, date *time.Time) string {
+	if date == nil {
+		return ""
+	}
+	return date.Format(accountingDateLayouts[layout.DateFormat])
+}
+
+// accountingAmount writes the amount with two decimals and the separator, or in cents
+// without one
+func accountingAmount(amount float64, separator string) string {
+	if separator == "" {
+		return strconv.FormatInt(int64(math.Round(amount*100)), 10)
+	}
+	return strings.Replace(strconv.FormatFloat(amount, 'f', 2, 64), ".", separator, 1)
+}
+
+// fixedWidth pads the value to the width of the column, or cuts it
+func fixedWidth(value string, column models.AccountingColumn) string {
+	runes := []rune(value)
+	if len(runes) >= column.Width {
+		return string(runes[:column.Width])
+	}
+	pad := column.Pad
+	if pad == "" {
+		pad = " "
+	}
+	padding := strings.Repeat(pad, column.Width-len(runes))
+	if column.Align == "right" {
+		return padding + value
+	}
+	return value + padding
+}
+
+func onlyDigits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, s)
+}
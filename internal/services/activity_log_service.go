@@ -1,9 +1,12 @@
 package services
 
 import (
+	"context"
 	"math"
 
+	"github.com/shigake/tech-iq-back/internal/logger"
 	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
 	"github.com/shigake/tech-iq-back/internal/repositories"
 )
 
@@ -15,6 +18,8 @@ type ActivityLogService interface {
 	GetAll(filter *models.ActivityLogFilter, page, limit int) (*models.PaginatedActivityLogs, error)
 	GetRecentLogs(limit int) ([]models.ActivityLog, error)
 	LogAction(userID, action, resource, resourceID, description, ipAddress, userAgent string) error
+	// LogActionContext is LogAction within a request: the record keeps its request ID
+	LogActionContext(ctx context.Context, userID, action, resource, resourceID, description, ipAddress, userAgent string) error
 }
 
 type activityLogService struct {
@@ -73,6 +78,7 @@ func (s *activityLogService) GetByUserID(userID string, page, limit int) (*model
 		Page:       page,
 		PageSize:   limit,
 		TotalPages: totalPages,
+		HasNext:    pagination.HasNext((page-1)*limit, limit, total),
 	}, nil
 }
 
@@ -97,6 +103,7 @@ func (s *activityLogService) GetAll(filter *models.ActivityLogFilter, page, limi
 		Page:       page,
 		PageSize:   limit,
 		TotalPages: totalPages,
+		HasNext:    pagination.HasNext((page-1)*limit, limit, total),
 	}, nil
 }
 
@@ -108,6 +115,10 @@ func (s *activityLogService) GetRecentLogs(limit int) ([]models.ActivityLog, err
 }
 
 func (s *activityLogService) LogAction(userID, action, resource, resourceID, description, ipAddress, userAgent string) error {
+	return s.LogActionContext(context.Background(), userID, action, resource, resourceID, description, ipAddress, userAgent)
+}
+
+func (s *activityLogService) LogActionContext(ctx context.Context, userID, action, resource, resourceID, description, ipAddress, userAgent string) error {
 	log := &models.ActivityLog{
 		UserID:      userID,
 		Action:      action,
@@ -116,6 +127,7 @@ func (s *activityLogService) LogAction(userID, action, resource, resourceID, des
 		Description: description,
 		IPAddress:   ipAddress,
 		UserAgent:   userAgent,
+		RequestID:   logger.RequestID(ctx),
 	}
-	return s.repo.Create(log)
+	return s.repo.WithContext(ctx).Create(log)
 }
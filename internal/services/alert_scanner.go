@@ -0,0 +1,322 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+)
+
+const (
+	defaultAlertScanInterval = 5 * time.Minute
+	alertScanPageSize        = 500
+	kpiComplianceWindowDays  = 30
+)
+
+// alertDetector finds the current occurrences of one alert type
+type alertDetector struct {
+	alertType string
+	detect    func(now time.Time) ([]models.Alert, error)
+}
+
+func (s *alertService) detectors() []alertDetector {
+	return []alertDetector{
+		{models.AlertTypeSLAAtRisk, s.detectSLAAtRisk},
+		{models.AlertTypeLowStock, s.detectLowStock},
+		{models.AlertTypeOverduePayment, s.detectOverduePayments},
+		{models.AlertTypeMockedLocation, s.detectMockedLocations},
+		{models.AlertTypeKPIBreach, s.detectKPIBreaches},
+		{models.AlertTypeCacheDown, s.detectCacheDown},
+		{models.AlertTypeJobBacklog, s.detectJobBacklog},
+	}
+}
+
+// Scan runs every detector, raising or refreshing their alerts and auto-resolving the
+// ones whose condition cleared. A failing detector does not resolve anything of its type.
+func (s *alertService) Scan() (*models.AlertScanResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	result := &models.AlertScanResult{}
+	for _, d := range s.detectors() {
+		alerts, err := d.detect(now)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", d.alertType, err))
+			continue
+		}
+
+		seen := make(map[string]bool, len(alerts))
+		failed := false
+		for i := range alerts {
+			alerts[i].Type = d.alertType
+			seen[alerts[i].DedupKey] = true
+			created, err := s.raise(&alerts[i], now)
+			if err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", d.alertType, err))
+				failed = true
+				break
+			}
+			if created {
+				result.Raised++
+			} else {
+				result.Refreshed++
+			}
+		}
+		if failed {
+			continue
+		}
+
+		resolved, err := s.resolveCleared(d.alertType, seen, now)
+		result.Resolved += resolved
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", d.alertType, err))
+		}
+	}
+	return result, nil
+}
+
+// Start runs the scanner periodically in the background until Stop is called
+func (s *alertService) Start(interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultAlertScanInterval
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				result, _ := s.Scan()
+				for _, e := range result.Errors {
+					slog.Warn("Alert scan failed", "error", e)
+				}
+				if result.Raised > 0 || result.Resolved > 0 {
+					slog.Info("Alert scan completed", "raised", result.Raised, "resolved", result.Resolved)
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (s *alertService) Stop() {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+		s.stop = nil
+	}
+}
+
+// detectSLAAtRisk flags running tickets past the risk share of their target, critical once breached
+func (s *alertService) detectSLAAtRisk(now time.Time) ([]models.Alert, error) {
+	slas, err := s.slaService.FindAtRisk(s.config.SLARiskPercent)
+	if err != nil {
+		return nil, err
+	}
+
+	alerts := make([]models.Alert, 0, len(slas))
+	for _, sla := range slas {
+		alert := models.Alert{
+			Module:       models.AlertModuleSLA,
+			Severity:     models.AlertSeverityWarning,
+			Title:        fmt.Sprintf("OS %s com SLA em risco", sla.OSNumber),
+			ResourceType: "TICKET",
+			ResourceID:   sla.TicketID,
+			DedupKey:     "sla:" + sla.TicketID,
+		}
+		if sla.Breached {
+			alert.Severity = models.AlertSeverityCritical
+			alert.Title = fmt.Sprintf("OS %s com SLA estourado", sla.OSNumber)
+		}
+		alert.Message = fmt.Sprintf("Prioridade %s: %.1fh de %.0fh consumidas (%.1fh em espera descontadas), prazo %s",
+			sla.Priority, sla.EffectiveHours, sla.TargetHours, sla.PausedHours, sla.DueAt.Format("02/01/2006 15:04"))
+		alerts = append(alerts, alert)
+	}
+	return alerts, nil
+}
+
+// detectLowStock flags balances at or below their minimum, critical when depleted
+func (s *alertService) detectLowStock(now time.Time) ([]models.Alert, error) {
+	var alerts []models.Alert
+	for page := 1; ; page++ {
+		balances, err := s.stockService.ListBalances(models.StockBalanceFilter{
+			LowStock: true,
+			Page:     page,
+			PageSize: alertScanPageSize,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range balances.Data {
+			alert := models.Alert{
+				Module:       models.AlertModuleStock,
+				Severity:     models.AlertSeverityWarning,
+				Title:        fmt.Sprintf("Estoque baixo: %s em %s", b.ItemName, b.LocationName),
+				Message:      fmt.Sprintf("%s (%s): %d %s, mínimo %d", b.ItemName, b.ItemSKU, b.Quantity, b.ItemUnit, b.MinQty),
+				ResourceType: "STOCK_BALANCE",
+				ResourceID:   b.ID,
+				DedupKey:     "stock:" + b.LocationID + ":" + b.ItemID,
+			}
+			if b.Quantity <= 0 {
+				alert.Severity = models.AlertSeverityCritical
+				alert.Title = fmt.Sprintf("Estoque esgotado: %s em %s", b.ItemName, b.LocationName)
+			}
+			alerts = append(alerts, alert)
+		}
+		if page >= balances.TotalPages {
+			return alerts, nil
+		}
+	}
+}
+
+// detectOverduePayments flags overdue receivables and payables, critical after 30 days
+func (s *alertService) detectOverduePayments(now time.Time) ([]models.Alert, error) {
+	if _, err := s.financialService.MarkOverdueEntries(); err != nil {
+		return nil, err
+	}
+
+	var alerts []models.Alert
+	for page := 1; ; page++ {
+		entries, total, err := s.financialService.ListEntries(models.FinancialEntryFilter{
+			Status: models.FinancialEntryStatusOverdue,
+			Page:   page,
+			Limit:  alertScanPageSize,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			title := "Conta a receber vencida"
+			if e.Type == models.FinancialEntryTypeExpense {
+				title = "Conta a pagar vencida"
+			}
+			alert := models.Alert{
+				Module:       models.AlertModuleFinancial,
+				Severity:     models.AlertSeverityWarning,
+				Title:        fmt.Sprintf("%s: %s", title, e.Description),
+				Message:      fmt.Sprintf("%s %.2f", e.Currency, e.Amount),
+				ResourceType: "FINANCIAL_ENTRY",
+				ResourceID:   e.ID,
+				DedupKey:     "financial:" + e.ID,
+			}
+			if e.DueDate != nil {
+				days := int(now.Sub(*e.DueDate).Hours() / 24)
+				alert.Message += fmt.Sprintf(", vencida em %s (%d dias)", e.DueDate.Format("02/01/2006"), days)
+				if days > 30 {
+					alert.Severity = models.AlertSeverityCritical
+				}
+			}
+			alerts = append(alerts, alert)
+		}
+		if int64(page*alertScanPageSize) >= total {
+			return alerts, nil
+		}
+	}
+}
+
+// detectMockedLocations flags technicians who sent GPS fixes from a mock provider recently
+func (s *alertService) detectMockedLocations(now time.Time) ([]models.Alert, error) {
+	rows, err := s.repo.CountMockedLocations(now.Add(-s.config.GeoLookback))
+	if err != nil {
+		return nil, err
+	}
+
+	alerts := make([]models.Alert, 0, len(rows))
+	for _, row := range rows {
+		name := row.TechnicianName
+		if name == "" {
+			name = row.TechnicianID
+		}
+		alerts = append(alerts, models.Alert{
+			Module:       models.AlertModuleGeo,
+			Severity:     models.AlertSeverityWarning,
+			Title:        fmt.Sprintf("Localização simulada: %s", name),
+			Message:      fmt.Sprintf("%d registros com GPS simulado, o último em %s", row.Count, row.LastAt.Format("02/01/2006 15:04")),
+			ResourceType: "TECHNICIAN",
+			ResourceID:   row.TechnicianID,
+			DedupKey:     "geo:mocked:" + row.TechnicianID,
+		})
+	}
+	return alerts, nil
+}
+
+// detectKPIBreaches flags the SLA compliance of the last 30 days below its target
+func (s *alertService) detectKPIBreaches(now time.Time) ([]models.Alert, error) {
+	if s.config.SLAComplianceTarget <= 0 {
+		return nil, nil
+	}
+	report, err := s.slaService.GetComplianceReport(now.AddDate(0, 0, -kpiComplianceWindowDays), now)
+	if err != nil {
+		return nil, err
+	}
+	target := float64(s.config.SLAComplianceTarget)
+	if report.Total == 0 || report.CompliancePercent >= target {
+		return nil, nil
+	}
+
+	alert := models.Alert{
+		Module:       models.AlertModuleKPI,
+		Severity:     models.AlertSeverityWarning,
+		Title:        "Cumprimento de SLA abaixo da meta",
+		Message:      fmt.Sprintf("%.1f%% nos últimos %d dias (%d de %d OS), meta %.0f%%", report.CompliancePercent, kpiComplianceWindowDays, report.Met, report.Total, target),
+		ResourceType: "KPI",
+		ResourceID:   "sla_compliance",
+		DedupKey:     "kpi:sla_compliance",
+	}
+	if report.CompliancePercent < target-10 {
+		alert.Severity = models.AlertSeverityCritical
+	}
+	return []models.Alert{alert}, nil
+}
+
+// detectCacheDown flags a configured Redis that does not answer
+func (s *alertService) detectCacheDown(now time.Time) ([]models.Alert, error) {
+	if s.statusService == nil {
+		return nil, nil
+	}
+	for _, component := range s.statusService.GetStatus().Components {
+		if component.Name != "cache" || component.Status != models.SystemStatusOutage {
+			continue
+		}
+		return []models.Alert{{
+			Module:       models.AlertModuleSystem,
+			Severity:     models.AlertSeverityCritical,
+			Title:        "Cache Redis indisponível",
+			Message:      "O Redis não responde; as consultas estão indo direto ao banco de dados",
+			ResourceType: "COMPONENT",
+			ResourceID:   "cache",
+			DedupKey:     "system:cache",
+		}}, nil
+	}
+	return nil, nil
+}
+
+// detectJobBacklog flags background jobs whose pending queue is above the threshold
+func (s *alertService) detectJobBacklog(now time.Time) ([]models.Alert, error) {
+	if s.statusService == nil {
+		return nil, nil
+	}
+	var alerts []models.Alert
+	for _, job := range s.statusService.GetStatus().Jobs {
+		if job.Status == models.SystemStatusOperational {
+			continue
+		}
+		alerts = append(alerts, models.Alert{
+			Module:       models.AlertModuleSystem,
+			Severity:     models.AlertSeverityWarning,
+			Title:        fmt.Sprintf("Fila do job %s acumulada", job.Name),
+			Message:      fmt.Sprintf("%d pendentes, %d com falha", job.Pending, job.Failed),
+			ResourceType: "JOB",
+			ResourceID:   job.Name,
+			DedupKey:     "system:backlog:" + job.Name,
+		})
+	}
+	return alerts, nil
+}
@@ -0,0 +1,334 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAlertNotFound        = errors.New("alert not found")
+	ErrAlertResolved        = errors.New("alert is already resolved")
+	ErrAlertAcknowledged    = errors.New("alert is already acknowledged")
+	ErrAlertOwnerNotFound   = errors.New("alert owner not found")
+	ErrAlertInvalidSeverity = errors.New("invalid severity, expected INFO, WARNING or CRITICAL")
+)
+
+// alertModules is the badge order of the topbar
+var alertModules = []string{
+	models.AlertModuleSLA, models.AlertModuleStock, models.AlertModuleFinancial, models.AlertModuleGeo, models.AlertModuleKPI,
+	models.AlertModuleSystem,
+}
+
+var alertSeverityRank = map[string]int{
+	models.AlertSeverityInfo:     0,
+	models.AlertSeverityWarning:  1,
+	models.AlertSeverityCritical: 2,
+}
+
+// AlertConfig tunes the alert scanner
+type AlertConfig struct {
+	SLARiskPercent      int           // share of the SLA target used before a ticket is at risk
+	SLAComplianceTarget int           // KPI: minimum SLA compliance (%) over the last 30 days
+	GeoLookback         time.Duration // window checked for mocked GPS fixes
+}
+
+// AlertService is the operational alerts center: alerts raised by the scanner are
+// acknowledged, assigned and resolved by users, or resolved automatically once their
+// condition clears
+type AlertService interface {
+	List(page, size int, filters *models.AlertFilters) (*models.PaginatedResponse, error)
+	Get(id string) (*models.Alert, error)
+	Acknowledge(id, userID string) (*models.Alert, error)
+	Assign(id string, req *models.AssignAlertRequest) (*models.Alert, error)
+	Resolve(id, userID string, req *models.ResolveAlertRequest) (*models.Alert, error)
+	Badges() (*models.AlertBadges, error)
+	Scan() (*models.AlertScanResult, error)
+	Start(interval time.Duration)
+	Stop()
+}
+
+type alertService struct {
+	repo             repositories.AlertRepository
+	userRepo         repositories.UserRepository
+	slaService       SLAService
+	stockService     StockService
+	financialService *FinancialService
+	statusService    StatusService
+	notifications    NotificationService
+	chat             ChatService
+	events           EventPublisher
+	config           AlertConfig
+
+	mu   sync.Mutex // one scan at a time
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewAlertService(
+	repo repositories.AlertRepository,
+	userRepo repositories.UserRepository,
+	slaService SLAService,
+	stockService StockService,
+	financialService *FinancialService,
+	statusService StatusService,
+	notifications NotificationService,
+	chat ChatService,
+	events EventPublisher,
+	config AlertConfig,
+) AlertService {
+	if config.SLARiskPercent <= 0 {
+		config.SLARiskPercent = 80
+	}
+	if config.GeoLookback <= 0 {
+		config.GeoLookback = 24 * time.Hour
+	}
+	return &alertService{
+		repo:             repo,
+		userRepo:         userRepo,
+		slaService:       slaService,
+		stockService:     stockService,
+		financialService: financialService,
+		statusService:    statusService,
+		notifications:    notifications,
+		chat:             chat,
+		events:           events,
+		config:           config,
+	}
+}
+
+func (s *alertService) List(page, size int, filters *models.AlertFilters) (*models.PaginatedResponse, error) {
+	if filters != nil && filters.Severity != "" {
+		filters.Severity = strings.ToUpper(filters.Severity)
+		if _, ok := alertSeverityRank[filters.Severity]; !ok {
+			return nil, ErrAlertInvalidSeverity
+		}
+	}
+
+	alerts, total, err := s.repo.FindAll(page, size, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewPaginatedResponse(alerts, page, size, total), nil
+}
+
+func (s *alertService) Get(id string) (*models.Alert, error) {
+	alert, err := s.repo.FindByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAlertNotFound
+		}
+		return nil, err
+	}
+	return alert, nil
+}
+
+// Acknowledge marks the alert as seen; an unowned alert is taken by whoever acknowledges it
+func (s *alertService) Acknowledge(id, userID string) (*models.Alert, error) {
+	alert, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	switch alert.Status {
+	case models.AlertStatusResolved:
+		return nil, ErrAlertResolved
+	case models.AlertStatusAcknowledged:
+		return nil, ErrAlertAcknowledged
+	}
+
+	now := time.Now()
+	alert.Status = models.AlertStatusAcknowledged
+	alert.AcknowledgedBy = &userID
+	alert.AcknowledgedAt = &now
+	if alert.OwnerID == nil {
+		alert.OwnerID = &userID
+	}
+	if err := s.repo.Update(alert); err != nil {
+		return nil, err
+	}
+	return s.Get(id)
+}
+
+func (s *alertService) Assign(id string, req *models.AssignAlertRequest) (*models.Alert, error) {
+	alert, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if alert.Status == models.AlertStatusResolved {
+		return nil, ErrAlertResolved
+	}
+
+	alert.OwnerID = nil
+	if req.OwnerID != "" {
+		if _, err := s.userRepo.FindByID(req.OwnerID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrAlertOwnerNotFound
+			}
+			return nil, err
+		}
+		alert.OwnerID = &req.OwnerID
+	}
+	if err := s.repo.Update(alert); err != nil {
+		return nil, err
+	}
+	return s.Get(id)
+}
+
+// Resolve closes the alert by hand; if the condition persists the scanner raises a new one
+func (s *alertService) Resolve(id, userID string, req *models.ResolveAlertRequest) (*models.Alert, error) {
+	alert, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if alert.Status == models.AlertStatusResolved {
+		return nil, ErrAlertResolved
+	}
+
+	now := time.Now()
+	alert.Status = models.AlertStatusResolved
+	alert.ResolvedBy = &userID
+	alert.ResolvedAt = &now
+	alert.ResolutionNote = strings.TrimSpace(req.Note)
+	if err := s.repo.Update(alert); err != nil {
+		return nil, err
+	}
+	return s.Get(id)
+}
+
+// Badges counts the active alerts per module for the topbar; every module is listed,
+// even without alerts
+func (s *alertService) Badges() (*models.AlertBadges, error) {
+	rows, err := s.repo.CountActive()
+	if err != nil {
+		return nil, err
+	}
+
+	badges := &models.AlertBadges{Modules: make([]models.AlertBadge, len(alertModules))}
+	index := make(map[string]int)
+	for i, module := range alertModules {
+		badges.Modules[i].Module = module
+		index[module] = i
+	}
+	for _, row := range rows {
+		i, ok := index[row.Module]
+		if !ok {
+			continue
+		}
+		badge := &badges.Modules[i]
+		if row.Status == models.AlertStatusOpen {
+			badge.Open += row.Count
+			badges.Open += row.Count
+		} else {
+			badge.Acknowledged += row.Count
+		}
+		if row.Severity == models.AlertSeverityCritical {
+			badge.Critical += row.Count
+			badges.Critical += row.Count
+		}
+		badges.Total += row.Count
+	}
+	return badges, nil
+}
+
+// raise creates the alert, or refreshes the active alert of the same condition. An
+// escalated severity sends an acknowledged alert back to OPEN.
+func (s *alertService) raise(alert *models.Alert, now time.Time) (created bool, err error) {
+	existing, err := s.repo.FindActiveByKey(alert.DedupKey)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+	if existing == nil {
+		alert.Status = models.AlertStatusOpen
+		alert.LastSeenAt = now
+		alert.Occurrences = 1
+		if err := s.repo.Create(alert); err != nil {
+			return false, err
+		}
+		s.notify(alert)
+		return true, nil
+	}
+
+	escalated := alertSeverityRank[alert.Severity] > alertSeverityRank[existing.Severity]
+	if escalated && existing.Status == models.AlertStatusAcknowledged {
+		existing.Status = models.AlertStatusOpen
+		existing.AcknowledgedBy = nil
+		existing.AcknowledgedAt = nil
+	}
+	existing.Severity = alert.Severity
+	existing.Title = alert.Title
+	existing.Message = alert.Message
+	existing.LastSeenAt = now
+	existing.Occurrences++
+	if err := s.repo.Update(existing); err != nil {
+		return false, err
+	}
+	if escalated {
+		s.notify(existing)
+	}
+	return false, nil
+}
+
+// notify posts a new or escalated alert to the chat channels routed to it and sends its
+// notification to its owner, or to the admins while it has none. Only breached SLAs and
+// low stock notify; low stock is also published as the stock.low webhook event.
+func (s *alertService) notify(alert *models.Alert) {
+	if s.chat != nil {
+		go s.chat.PostAlert(*alert)
+	}
+	if s.events != nil && alert.Type == models.AlertTypeLowStock {
+		go s.events.Publish(models.WebhookEventStockLow, *alert)
+	}
+	if s.notifications == nil {
+		return
+	}
+	var event string
+	switch {
+	case alert.Type == models.AlertTypeSLAAtRisk && alert.Severity == models.AlertSeverityCritical:
+		event = models.NotificationSLABreach
+	case alert.Type == models.AlertTypeLowStock:
+		event = models.NotificationLowStock
+	default:
+		return
+	}
+
+	notification := models.Notification{
+		Event:        event,
+		Title:        alert.Title,
+		Message:      alert.Message,
+		ResourceType: alert.ResourceType,
+		ResourceID:   alert.ResourceID,
+	}
+	if alert.OwnerID != nil {
+		go s.notifications.Notify([]string{*alert.OwnerID}, notification)
+		return
+	}
+	go s.notifications.NotifyRole("ADMIN", notification)
+}
+
+// resolveCleared auto-resolves the active alerts of a type whose condition is gone
+func (s *alertService) resolveCleared(alertType string, seen map[string]bool, now time.Time) (int, error) {
+	active, err := s.repo.FindActiveByType(alertType)
+	if err != nil {
+		return 0, err
+	}
+	resolved := 0
+	for i := range active {
+		if seen[active[i].DedupKey] {
+			continue
+		}
+		active[i].Status = models.AlertStatusResolved
+		active[i].ResolvedAt = &now
+		active[i].ResolutionNote = "Resolvido automaticamente: condição normalizada"
+		if err := s.repo.Update(&active[i]); err != nil {
+			return resolved, err
+		}
+		resolved++
+	}
+	return resolved, nil
+}
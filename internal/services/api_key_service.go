@@ -0,0 +1,203 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// The last use of a key is written at most this often
+const apiKeyTouchInterval = time.Minute
+
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrAPIKeyRevoked  = errors.New("api key has been revoked")
+	ErrAPIKeyInvalid  = errors.New("invalid api key")
+)
+
+// APIKeyService manages the API keys of machine integrations and authenticates the
+// requests made with them
+type APIKeyService interface {
+	List() ([]models.APIKey, error)
+	Get(id string) (*models.APIKey, error)
+	// Create returns the key in clear text; only its hash is stored
+	Create(adminID string, req *models.CreateAPIKeyRequest) (*models.APIKeyCreated, error)
+	Update(adminID, id string, req *models.UpdateAPIKeyRequest) (*models.APIKey, error)
+	Revoke(adminID, id string) (*models.APIKey, error)
+
+	// Authenticate returns the active key matching the X-API-Key header value, of any
+	// tenant; the request then runs in the key's tenant
+	Authenticate(rawKey, ipAddress string) (*models.APIKey, error)
+
+	// WithContext returns the service managing the keys of the tenant in ctx; created
+	// keys and their service accounts belong to that tenant
+	WithContext(ctx context.Context) APIKeyService
+}
+
+type apiKeyService struct {
+	repo               repositories.APIKeyRepository
+	activityLogService ActivityLogService
+}
+
+func NewAPIKeyService(repo repositories.APIKeyRepository, activityLogService ActivityLogService) APIKeyService {
+	return &apiKeyService{
+		repo:               repo,
+		activityLogService: activityLogService,
+	}
+}
+
+func (s *apiKeyService) WithContext(ctx context.Context) APIKeyService {
+	return &apiKeyService{
+		repo:               s.repo.WithContext(ctx),
+		activityLogService: s.activityLogService,
+	}
+}
+
+func (s *apiKeyService) List() ([]models.APIKey, error) {
+	return s.repo.FindAll()
+}
+
+func (s *apiKeyService) Get(id string) (*models.APIKey, error) {
+	key, err := s.repo.FindByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+	return key, nil
+}
+
+func (s *apiKeyService) Create(adminID string, req *models.CreateAPIKeyRequest) (*models.APIKeyCreated, error) {
+	prefixBytes := make([]byte, 6)
+	secretBytes := make([]byte, 32)
+	passwordBytes := make([]byte, 32)
+	for _, b := range [][]byte{prefixBytes, secretBytes, passwordBytes} {
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+	}
+	prefix := hex.EncodeToString(prefixBytes)
+	secret := base64.RawURLEncoding.EncodeToString(secretBytes)
+
+	// The service account can't sign in: its password is never known
+	password, err := bcrypt.GenerateFromPassword([]byte(hex.EncodeToString(passwordBytes)), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(req.Name)
+	user := &models.User{
+		Email:     fmt.Sprintf("apikey-%s@integrations.invalid", prefix),
+		Password:  string(password),
+		FirstName: "API",
+		LastName:  name,
+		Role:      models.RoleIntegration,
+		Active:    true,
+	}
+	key := &models.APIKey{
+		Name:       name,
+		Prefix:     prefix,
+		SecretHash: hashAPIKeySecret(secret),
+		Scopes:     req.Scopes,
+		ExpiresAt:  req.ExpiresAt,
+		CreatedBy:  adminID,
+	}
+	if err := s.repo.Create(key, user); err != nil {
+		return nil, err
+	}
+
+	s.logAction(adminID, "CREATE", key.ID, fmt.Sprintf("API key %q created with scopes %s", key.Name, strings.Join(key.Scopes, ", ")))
+	return &models.APIKeyCreated{
+		APIKey: key,
+		Key:    models.APIKeyTokenPrefix + prefix + "_" + secret,
+	}, nil
+}
+
+func (s *apiKeyService) Update(adminID, id string, req *models.UpdateAPIKeyRequest) (*models.APIKey, error) {
+	key, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if key.RevokedAt != nil {
+		return nil, ErrAPIKeyRevoked
+	}
+	if req.Name != nil {
+		key.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Scopes != nil {
+		key.Scopes = req.Scopes
+	}
+	if err := s.repo.Update(key); err != nil {
+		return nil, err
+	}
+
+	s.logAction(adminID, "UPDATE", key.ID, fmt.Sprintf("API key %q scopes set to %s", key.Name, strings.Join(key.Scopes, ", ")))
+	return key, nil
+}
+
+func (s *apiKeyService) Revoke(adminID, id string) (*models.APIKey, error) {
+	key, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if key.RevokedAt != nil {
+		return nil, ErrAPIKeyRevoked
+	}
+	now := time.Now()
+	if err := s.repo.Revoke(key, now); err != nil {
+		return nil, err
+	}
+	key.RevokedAt = &now
+
+	s.logAction(adminID, "DELETE", key.ID, fmt.Sprintf("API key %q revoked", key.Name))
+	return key, nil
+}
+
+func (s *apiKeyService) Authenticate(rawKey, ipAddress string) (*models.APIKey, error) {
+	parts := strings.SplitN(strings.TrimPrefix(rawKey, models.APIKeyTokenPrefix), "_", 2)
+	if !strings.HasPrefix(rawKey, models.APIKeyTokenPrefix) || len(parts) != 2 {
+		return nil, ErrAPIKeyInvalid
+	}
+	key, err := s.repo.FindByPrefix(parts[0])
+	if err != nil {
+		return nil, ErrAPIKeyInvalid
+	}
+	if subtle.ConstantTimeCompare([]byte(key.SecretHash), []byte(hashAPIKeySecret(parts[1]))) != 1 {
+		return nil, ErrAPIKeyInvalid
+	}
+	now := time.Now()
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && now.After(*key.ExpiresAt)) {
+		return nil, ErrAPIKeyInvalid
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > apiKeyTouchInterval {
+		if err := s.repo.TouchLastUsed(key.ID, ipAddress, now); err != nil {
+			slog.Warn("Failed to record use of API key", "key_id", key.ID, "error", err)
+		}
+	}
+	return key, nil
+}
+
+func (s *apiKeyService) logAction(userID, action, keyID, description string) {
+	if err := s.activityLogService.LogAction(userID, action, "api_key", keyID, description, "", ""); err != nil {
+		slog.Warn("Failed to log API key", "key_id", keyID, "error", err)
+	}
+}
+
+func hashAPIKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
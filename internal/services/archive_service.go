@@ -0,0 +1,208 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrArchivedTicketNotFound = errors.New("archived ticket not found")
+	ErrArchiveRestoreConflict = errors.New("a ticket with this ID already exists")
+	ErrArchiveInvalidMonths   = errors.New("months must be at least 1")
+)
+
+const (
+	defaultArchiveInterval = 24 * time.Hour
+	archiveBatchSize       = 500
+)
+
+// ArchiveService moves long-closed tickets out of the hot tables and brings them back on demand
+type ArchiveService interface {
+	// Run archives the tickets closed more than months ago, archiveBatchSize at a time
+	Run(months int, actorID string) (*models.ArchiveRunResult, error)
+	Search(page, size int, filters *models.ArchiveSearchFilters) (*models.PaginatedResponse, error)
+	Get(ticketID string) (*models.ArchivedTicketDetail, error)
+	Restore(ticketID, actorID string) error
+	Start(interval time.Duration)
+	Stop()
+}
+
+type archiveService struct {
+	repo               repositories.ArchiveRepository
+	activityLogService ActivityLogService
+	months             int // default age for scheduled runs
+
+	mu   sync.Mutex // one run at a time
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewArchiveService(repo repositories.ArchiveRepository, activityLogService ActivityLogService, months int) ArchiveService {
+	if months < 1 {
+		months = 12
+	}
+	return &archiveService{
+		repo:               repo,
+		activityLogService: activityLogService,
+		months:             months,
+	}
+}
+
+func (s *archiveService) Run(months int, actorID string) (*models.ArchiveRunResult, error) {
+	if months == 0 {
+		months = s.months
+	}
+	if months < 1 {
+		return nil, ErrArchiveInvalidMonths
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := time.Now().AddDate(0, -months, 0)
+	result := &models.ArchiveRunResult{Cutoff: cutoff}
+	skipped, err := s.repo.CountBlocked(cutoff)
+	if err != nil {
+		return nil, err
+	}
+	result.Skipped = skipped
+
+	for {
+		ids, err := s.repo.FindArchivable(cutoff, archiveBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		archived := 0
+		for _, id := range ids {
+			if err := s.repo.Archive(id, actorID); err != nil {
+				result.Failed++
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", id, err))
+				continue
+			}
+			archived++
+		}
+		result.Archived += archived
+		// a batch that archived nothing would be picked again forever
+		if len(ids) < archiveBatchSize || archived == 0 {
+			break
+		}
+	}
+
+	if result.Archived > 0 && actorID != "" {
+		description := fmt.Sprintf("Arquivou %d chamados fechados antes de %s", result.Archived, cutoff.Format("02/01/2006"))
+		if err := s.activityLogService.LogAction(actorID, "tickets_archived", "ticket", "", description, "", ""); err != nil {
+			slog.Warn("Failed to audit ticket archival", "error", err)
+		}
+	}
+	return result, nil
+}
+
+func (s *archiveService) Search(page, size int, filters *models.ArchiveSearchFilters) (*models.PaginatedResponse, error) {
+	tickets, total, err := s.repo.Search(page, size, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewPaginatedResponse(tickets, page, size, total), nil
+}
+
+func (s *archiveService) Get(ticketID string) (*models.ArchivedTicketDetail, error) {
+	archived, err := s.repo.FindByID(ticketID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrArchivedTicketNotFound
+		}
+		return nil, err
+	}
+
+	detail := &models.ArchivedTicketDetail{
+		ArchivedTicket: *archived,
+		Ticket:         json.RawMessage(archived.Data),
+		Records:        make(map[string]json.RawMessage),
+	}
+	rows := make(map[string][]json.RawMessage)
+	for _, record := range archived.Records {
+		rows[record.SourceTable] = append(rows[record.SourceTable], json.RawMessage(record.Data))
+	}
+	for table, list := range rows {
+		data, err := json.Marshal(list)
+		if err != nil {
+			return nil, err
+		}
+		detail.Records[table] = data
+	}
+	return detail, nil
+}
+
+func (s *archiveService) Restore(ticketID, actorID string) error {
+	if _, err := s.repo.FindByID(ticketID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrArchivedTicketNotFound
+		}
+		return err
+	}
+	exists, err := s.repo.ExistsInHot(ticketID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrArchiveRestoreConflict
+	}
+
+	if err := s.repo.Restore(ticketID, actorID); err != nil {
+		return err
+	}
+	if err := s.activityLogService.LogAction(actorID, "ticket_restored", "ticket", ticketID, "Restaurou chamado do arquivo", "", ""); err != nil {
+		slog.Warn("Failed to audit ticket restore", "ticket_id", ticketID, "error", err)
+	}
+	return nil
+}
+
+// Start runs the archival periodically in the background until Stop is called
+func (s *archiveService) Start(interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultArchiveInterval
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				result, err := s.Run(s.months, "")
+				if err != nil {
+					slog.Warn("Ticket archival failed", "error", err)
+					continue
+				}
+				for _, e := range result.Errors {
+					slog.Warn("Ticket archival failed", "error", e)
+				}
+				if result.Archived > 0 {
+					slog.Info("Ticket archival completed", "archived", result.Archived, "cutoff", result.Cutoff.Format("2006-01-02"))
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (s *archiveService) Stop() {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+		s.stop = nil
+	}
+}
@@ -0,0 +1,229 @@
+package services
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	thumbnailMaxSize = 320
+	// Decoding allocates 4 bytes per pixel: a small file claiming huge dimensions would
+	// exhaust memory, so images are measured before being decoded
+	maxImagePixels = 50_000_000
+)
+
+func decodeImage(path string) (image.Image, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+
+	config, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return nil, "", err
+	}
+	if int64(config.Width)*int64(config.Height) > maxImagePixels {
+		return nil, "", fmt.Errorf("image is %dx%d, above the %d pixel limit", config.Width, config.Height, maxImagePixels)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, "", err
+	}
+
+	img, format, err := image.Decode(f)
+	if err != nil {
+		return nil, "", err
+	}
+	// GIFs are flattened to their first frame and stored as PNG
+	if format == "gif" {
+		format = "png"
+	}
+	return img, format, nil
+}
+
+// writeThumbnail stores a JPEG copy whose longest side is at most thumbnailMaxSize
+func writeThumbnail(img image.Image, path string) error {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w > thumbnailMaxSize || h > thumbnailMaxSize {
+		if w >= h {
+			h = h * thumbnailMaxSize / w
+			w = thumbnailMaxSize
+		} else {
+			w = w * thumbnailMaxSize / h
+			h = thumbnailMaxSize
+		}
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	return writeImage(resizeImage(img, w, h), "jpeg", path)
+}
+
+// writeSanitized re-encodes the decoded pixels, which leaves all metadata behind
+func writeSanitized(img image.Image, format, path string) error {
+	return writeImage(img, format, path)
+}
+
+func writeImage(img image.Image, format, path string) error {
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	switch format {
+	case "png":
+		return png.Encode(out, img)
+	default:
+		return jpeg.Encode(out, img, &jpeg.Options{Quality: 85})
+	}
+}
+
+// resizeImage downscales using a box filter (average of the source pixels)
+func resizeImage(src image.Image, width, height int) image.Image {
+	bounds := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	scaleX := float64(bounds.Dx()) / float64(width)
+	scaleY := float64(bounds.Dy()) / float64(height)
+
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + int(float64(y)*scaleY)
+		y1 := bounds.Min.Y + int(float64(y+1)*scaleY)
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + int(float64(x)*scaleX)
+			x1 := bounds.Min.X + int(float64(x+1)*scaleX)
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1 && sy < bounds.Max.Y; sy++ {
+				for sx := x0; sx < x1 && sx < bounds.Max.X; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			if n == 0 {
+				continue
+			}
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(b / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
+
+// =============== WebP ===============
+
+func lookupCwebp() string {
+	path, err := exec.LookPath("cwebp")
+	if err != nil {
+		return ""
+	}
+	return path
+}
+
+func convertToWebP(cwebpPath, src, dst string) error {
+	output, err := exec.Command(cwebpPath, "-quiet", "-q", "80", src, "-o", dst).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// =============== Antivirus ===============
+
+// clamAVScanner streams files to a clamd daemon using the INSTREAM command
+type clamAVScanner struct {
+	address string
+}
+
+// Scan returns the raw clamd verdict and whether a signature was found
+func (c *clamAVScanner) Scan(path string) (string, bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false, err
+	}
+	defer f.Close()
+	return c.ScanReader(f)
+}
+
+// ScanReader streams the content to clamd, see Scan
+func (c *clamAVScanner) ScanReader(f io.Reader) (string, bool, error) {
+	conn, err := net.DialTimeout("tcp", c.address, 10*time.Second)
+	if err != nil {
+		return "", false, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Minute))
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return "", false, err
+	}
+
+	buf := make([]byte, 32*1024)
+	size := make([]byte, 4)
+	for {
+		n, readErr := f.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := conn.Write(size); err != nil {
+				return "", false, err
+			}
+			if _, err := conn.Write(buf[:n]); err != nil {
+				return "", false, err
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return "", false, readErr
+		}
+	}
+	// Zero-length chunk terminates the stream
+	binary.BigEndian.PutUint32(size, 0)
+	if _, err := conn.Write(size); err != nil {
+		return "", false, err
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && err != io.EOF {
+		return "", false, err
+	}
+	reply = strings.TrimSpace(strings.TrimRight(reply, "\x00"))
+	reply = strings.TrimPrefix(reply, "stream: ")
+
+	switch {
+	case reply == "OK":
+		return reply, false, nil
+	case strings.HasSuffix(reply, "FOUND"):
+		return reply, true, nil
+	default:
+		return "", false, fmt.Errorf("unexpected clamd reply: %s", reply)
+	}
+}
@@ -0,0 +1,449 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+)
+
+var (
+	ErrAttachmentNotFound    = errors.New("attachment not found")
+	ErrAttachmentTooLarge    = errors.New("attachment exceeds the maximum size")
+	ErrAttachmentNotReady    = errors.New("attachment is still being processed")
+	ErrAttachmentInfected    = errors.New("attachment was quarantined by the antivirus")
+	ErrAttachmentNoVariant   = errors.New("requested variant is not available for this attachment")
+	ErrAttachmentNotRetrying = errors.New("only failed attachments can be reprocessed")
+)
+
+const (
+	maxAttachmentSize        = 20 * 1024 * 1024
+	maxAttachmentAttempts    = 5
+	attachmentQueueSize      = 100
+	attachmentSweepBatchSize = 20
+	defaultAttachmentSweep   = time.Minute
+)
+
+// AttachmentConfig configures storage and the external tools used by the pipeline
+type AttachmentConfig struct {
+	UploadDir     string
+	ClamAVAddress string // host:port of clamd, empty disables scanning
+	CwebpPath     string // path to cwebp, empty looks it up on PATH
+}
+
+type AttachmentService interface {
+	Upload(ticketID, userID string, header *multipart.FileHeader) (*models.TicketFile, error)
+	GetByTicket(ticketID string) ([]models.TicketFile, error)
+	GetFile(ticketID, fileID string) (*models.TicketFile, error)
+	// ResolvePath returns the path on disk of the requested variant (original, thumbnail, sanitized, webp)
+	ResolvePath(file *models.TicketFile, variant string) (string, error)
+	Reprocess(ticketID, fileID string) (*models.TicketFile, error)
+	Delete(ticketID, fileID string) error
+
+	Start(interval time.Duration)
+	Stop()
+	// SetWorkers changes how many attachments are processed at the same time
+	SetWorkers(n int)
+	Workers() int
+	// WithContext returns the service running its queries with ctx, inside the tenant it carries
+	WithContext(ctx context.Context) AttachmentService
+}
+
+type attachmentService struct {
+	repo           repositories.AttachmentRepository
+	ticketRepo     repositories.TicketRepository
+	storageService StorageService
+	config         AttachmentConfig
+	scanner        *clamAVScanner
+
+	queue chan string
+	stop  chan struct{}
+	done  chan struct{}
+
+	// Worker slots, resizable while running; shared with the copies made by WithContext
+	slots *attachmentSlots
+}
+
+type attachmentSlots struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	workers int
+	running int
+}
+
+func NewAttachmentService(repo repositories.AttachmentRepository, ticketRepo repositories.TicketRepository, storageService StorageService, config AttachmentConfig) AttachmentService {
+	if config.UploadDir == "" {
+		config.UploadDir = "./uploads"
+	}
+	if config.CwebpPath == "" {
+		config.CwebpPath = lookupCwebp()
+	}
+
+	svc := &attachmentService{
+		repo:           repo,
+		ticketRepo:     ticketRepo,
+		storageService: storageService,
+		config:         config,
+		queue:          make(chan string, attachmentQueueSize),
+		slots:          &attachmentSlots{workers: 1},
+	}
+	svc.slots.cond = sync.NewCond(&svc.slots.mu)
+	if config.ClamAVAddress != "" {
+		svc.scanner = &clamAVScanner{address: config.ClamAVAddress}
+	}
+	return svc
+}
+
+func (s *attachmentService) WithContext(ctx context.Context) AttachmentService {
+	scoped := *s
+	scoped.ticketRepo = s.ticketRepo.WithContext(ctx)
+	return &scoped
+}
+
+func (s *attachmentService) Upload(ticketID, userID string, header *multipart.FileHeader) (*models.TicketFile, error) {
+	ticket, err := s.ticketRepo.FindByID(ticketID)
+	if err != nil {
+		return nil, err
+	}
+	if header.Size > maxAttachmentSize {
+		return nil, ErrAttachmentTooLarge
+	}
+	if s.storageService != nil {
+		if err := s.storageService.CheckQuota(ticket.NodeID, models.StorageModuleTickets, header.Size); err != nil {
+			return nil, err
+		}
+	}
+
+	src, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	file := &models.TicketFile{
+		TicketID:         ticketID,
+		FileName:         filepath.Base(header.Filename),
+		FileType:         header.Header.Get("Content-Type"),
+		FileSize:         header.Size,
+		StoredBytes:      header.Size,
+		ProcessingStatus: models.AttachmentStatusPending,
+		UploadedBy:       userID,
+	}
+	// The record is created first so the ID can be used as the file name
+	if err := s.repo.Create(file); err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Join(s.config.UploadDir, models.StorageModuleTickets, ticketID)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		s.repo.Delete(file.ID)
+		return nil, err
+	}
+	file.FilePath = filepath.Join(dir, file.ID+strings.ToLower(filepath.Ext(file.FileName)))
+
+	dst, err := os.OpenFile(file.FilePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		s.repo.Delete(file.ID)
+		return nil, err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(file.FilePath)
+		s.repo.Delete(file.ID)
+		return nil, err
+	}
+	dst.Close()
+
+	if err := s.repo.Update(file); err != nil {
+		return nil, err
+	}
+
+	s.enqueue(file.ID)
+	return file, nil
+}
+
+func (s *attachmentService) GetByTicket(ticketID string) ([]models.TicketFile, error) {
+	return s.repo.FindByTicket(ticketID)
+}
+
+func (s *attachmentService) GetFile(ticketID, fileID string) (*models.TicketFile, error) {
+	file, err := s.repo.FindByID(fileID)
+	if err != nil || file.TicketID != ticketID {
+		return nil, ErrAttachmentNotFound
+	}
+	return file, nil
+}
+
+func (s *attachmentService) ResolvePath(file *models.TicketFile, variant string) (string, error) {
+	switch file.ProcessingStatus {
+	case models.AttachmentStatusInfected:
+		return "", ErrAttachmentInfected
+	case models.AttachmentStatusReady:
+	default:
+		return "", ErrAttachmentNotReady
+	}
+
+	switch variant {
+	case "", "original":
+		return file.FilePath, nil
+	case "thumbnail":
+		if file.HasVariant(variant) {
+			return file.ThumbnailPath, nil
+		}
+	case "sanitized":
+		if file.HasVariant(variant) {
+			return file.SanitizedPath, nil
+		}
+		// Non-image files carry no EXIF, the original is safe to share
+		if !isProcessableImage(file) {
+			return file.FilePath, nil
+		}
+	case "webp":
+		if file.HasVariant(variant) {
+			return file.WebPPath, nil
+		}
+	}
+	return "", ErrAttachmentNoVariant
+}
+
+func (s *attachmentService) Reprocess(ticketID, fileID string) (*models.TicketFile, error) {
+	file, err := s.GetFile(ticketID, fileID)
+	if err != nil {
+		return nil, err
+	}
+	if file.ProcessingStatus != models.AttachmentStatusFailed {
+		return nil, ErrAttachmentNotRetrying
+	}
+
+	file.Attempts = 0
+	file.NextAttemptAt = nil
+	file.ProcessingError = ""
+	if err := s.repo.Update(file); err != nil {
+		return nil, err
+	}
+	s.enqueue(file.ID)
+	return file, nil
+}
+
+func (s *attachmentService) Delete(ticketID, fileID string) error {
+	file, err := s.GetFile(ticketID, fileID)
+	if err != nil {
+		return err
+	}
+	for _, path := range []string{file.FilePath, file.ThumbnailPath, file.SanitizedPath, file.WebPPath} {
+		if path != "" {
+			os.Remove(path)
+		}
+	}
+	return s.repo.Delete(file.ID)
+}
+
+// =============== Pipeline ===============
+
+// Start launches the processing worker. New uploads are picked up immediately
+// through the queue, failed ones are retried with backoff on every sweep.
+func (s *attachmentService) Start(interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultAttachmentSweep
+	}
+	if err := s.repo.ResetProcessing(); err != nil {
+		slog.Warn("Failed to requeue interrupted attachments", "error", err)
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		s.sweep()
+		for {
+			select {
+			case id := <-s.queue:
+				s.dispatch(id)
+			case <-ticker.C:
+				s.sweep()
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (s *attachmentService) Stop() {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+		s.stop = nil
+	}
+	// Let the attachments being processed finish
+	s.slots.mu.Lock()
+	for s.slots.running > 0 {
+		s.slots.cond.Wait()
+	}
+	s.slots.mu.Unlock()
+}
+
+func (s *attachmentService) enqueue(id string) {
+	select {
+	case s.queue <- id:
+	default:
+		// Queue full: the next sweep picks it up from the database
+	}
+}
+
+func (s *attachmentService) sweep() {
+	files, err := s.repo.FindDue(time.Now(), maxAttachmentAttempts, attachmentSweepBatchSize)
+	if err != nil {
+		slog.Warn("Failed to load pending attachments", "error", err)
+		return
+	}
+	for _, f := range files {
+		s.dispatch(f.ID)
+	}
+}
+
+// dispatch processes the attachment as soon as a worker slot is free
+func (s *attachmentService) dispatch(id string) {
+	s.slots.mu.Lock()
+	for s.slots.running >= s.slots.workers {
+		s.slots.cond.Wait()
+	}
+	s.slots.running++
+	s.slots.mu.Unlock()
+
+	go func() {
+		defer func() {
+			s.slots.mu.Lock()
+			s.slots.running--
+			s.slots.cond.Broadcast()
+			s.slots.mu.Unlock()
+		}()
+		s.process(id)
+	}()
+}
+
+func (s *attachmentService) SetWorkers(n int) {
+	if n < 1 {
+		n = 1
+	}
+	s.slots.mu.Lock()
+	s.slots.workers = n
+	s.slots.cond.Broadcast()
+	s.slots.mu.Unlock()
+}
+
+func (s *attachmentService) Workers() int {
+	s.slots.mu.Lock()
+	defer s.slots.mu.Unlock()
+	return s.slots.workers
+}
+
+func (s *attachmentService) process(id string) {
+	claimed, err := s.repo.Claim(id)
+	if err != nil || !claimed {
+		return
+	}
+	file, err := s.repo.FindByID(id)
+	if err != nil {
+		return
+	}
+
+	file.Attempts++
+	if err := s.runPipeline(file); err != nil {
+		file.ProcessingStatus = models.AttachmentStatusFailed
+		file.ProcessingError = err.Error()
+		// Exponential backoff: 2, 4, 8, 16... minutes
+		next := time.Now().Add(time.Duration(math.Pow(2, float64(file.Attempts))) * time.Minute)
+		file.NextAttemptAt = &next
+		slog.Warn("Attachment processing failed", "file_id", file.ID, "attempts", file.Attempts, "max_attachment_attempts", maxAttachmentAttempts, "error", err)
+	}
+	if err := s.repo.Update(file); err != nil {
+		slog.Warn("Failed to save attachment", "file_id", file.ID, "error", err)
+	}
+}
+
+// runPipeline scans the file and builds the image variants. It sets the final
+// status on success; any returned error is retried.
+func (s *attachmentService) runPipeline(file *models.TicketFile) error {
+	if s.scanner != nil {
+		result, infected, err := s.scanner.Scan(file.FilePath)
+		if err != nil {
+			return fmt.Errorf("antivirus scan: %w", err)
+		}
+		file.ScanResult = result
+		if infected {
+			os.Remove(file.FilePath)
+			file.ProcessingStatus = models.AttachmentStatusInfected
+			file.ProcessingError = ""
+			now := time.Now()
+			file.ProcessedAt = &now
+			return nil
+		}
+	} else {
+		file.ScanResult = "SKIPPED"
+	}
+
+	if isProcessableImage(file) {
+		base := strings.TrimSuffix(file.FilePath, filepath.Ext(file.FilePath))
+
+		img, format, err := decodeImage(file.FilePath)
+		if err != nil {
+			return fmt.Errorf("decode image: %w", err)
+		}
+
+		thumbPath := base + "_thumb.jpg"
+		if err := writeThumbnail(img, thumbPath); err != nil {
+			return fmt.Errorf("thumbnail: %w", err)
+		}
+		file.ThumbnailPath = thumbPath
+
+		// Re-encoding drops EXIF/metadata (GPS, device) from the copy shared with clients
+		sanitizedPath := base + "_sanitized." + format
+		if err := writeSanitized(img, format, sanitizedPath); err != nil {
+			return fmt.Errorf("strip metadata: %w", err)
+		}
+		file.SanitizedPath = sanitizedPath
+
+		if s.config.CwebpPath != "" {
+			webpPath := base + ".webp"
+			if err := convertToWebP(s.config.CwebpPath, sanitizedPath, webpPath); err != nil {
+				return fmt.Errorf("webp conversion: %w", err)
+			}
+			file.WebPPath = webpPath
+		}
+	}
+
+	file.StoredBytes = 0
+	for _, path := range []string{file.FilePath, file.ThumbnailPath, file.SanitizedPath, file.WebPPath} {
+		if info, err := os.Stat(path); path != "" && err == nil {
+			file.StoredBytes += info.Size()
+		}
+	}
+
+	now := time.Now()
+	file.ProcessingStatus = models.AttachmentStatusReady
+	file.ProcessingError = ""
+	file.NextAttemptAt = nil
+	file.ProcessedAt = &now
+	return nil
+}
+
+func isProcessableImage(file *models.TicketFile) bool {
+	switch strings.ToLower(filepath.Ext(file.FileName)) {
+	case ".jpg", ".jpeg", ".png", ".gif":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,519 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAuditSigningKeyMissing = errors.New("audit export signing key is not configured")
+	ErrAuditBundleNotFound    = errors.New("audit export bundle not found")
+	ErrAuditBundleUnreadable  = errors.New("audit export bundle file is missing or unreadable")
+)
+
+const (
+	auditChainBatchSize     = 1000
+	auditChainMaxBreaks     = 100
+	auditBundlePeriod       = 24 * time.Hour
+	auditBundlesPerRun      = 31
+	defaultAuditExportCheck = time.Hour
+)
+
+// AuditChainConfig configures the signed export bundles of the activity log
+type AuditChainConfig struct {
+	ExportDir string
+	// SigningKey is an Ed25519 seed (32 bytes) or private key (64 bytes), base64 or hex
+	// encoded; without it no bundles are exported
+	SigningKey string
+}
+
+// AuditChainService keeps the activity log tamper evident: it checks the hash chain of the
+// records and exports signed daily bundles that can be checked against the database later
+type AuditChainService interface {
+	// SealUnchained links the records written before the hash chain existed
+	SealUnchained() (int64, error)
+	// Verify checks the chain of the records created in the period
+	Verify(from, to time.Time) (*models.AuditChainVerification, error)
+
+	ListBundles(page, size int) (*models.PaginatedResponse, error)
+	// OpenBundle returns the bundle with the path of its file
+	OpenBundle(id string) (*models.AuditExportBundle, string, error)
+	// ExportPending exports a bundle for every full day not exported yet
+	ExportPending() (*models.AuditExportResult, error)
+	VerifyBundle(id string) (*models.AuditBundleVerification, error)
+	// PublicKey returns the base64 Ed25519 key that verifies the bundle signatures
+	PublicKey() (string, error)
+
+	Start(interval time.Duration)
+	Stop()
+}
+
+type auditChainService struct {
+	logs      repositories.ActivityLogRepository
+	exports   repositories.AuditExportRepository
+	exportDir string
+	key       ed25519.PrivateKey
+	mu        sync.Mutex
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+func NewAuditChainService(logs repositories.ActivityLogRepository, exports repositories.AuditExportRepository, config AuditChainConfig) AuditChainService {
+	if config.ExportDir == "" {
+		config.ExportDir = "./audit-exports"
+	}
+	svc := &auditChainService{
+		logs:      logs,
+		exports:   exports,
+		exportDir: config.ExportDir,
+	}
+	if config.SigningKey != "" {
+		key, err := parseSigningKey(config.SigningKey)
+		if err != nil {
+			slog.Warn("Invalid audit signing key, signed exports disabled", "error", err)
+		} else {
+			svc.key = key
+		}
+	}
+	return svc
+}
+
+func (s *auditChainService) SealUnchained() (int64, error) {
+	return s.logs.SealUnchained()
+}
+
+// =============== Verification ===============
+
+// chainChecker walks records in sequence order and collects where the chain breaks
+type chainChecker struct {
+	linked    bool // prevSeq and prevHash are known
+	prevSeq   int64
+	prevHash  string
+	breaks    []models.AuditChainBreak
+	truncated bool
+}
+
+func (c *chainChecker) add(sequence int64, logID, reason string) {
+	if len(c.breaks) >= auditChainMaxBreaks {
+		c.truncated = true
+		return
+	}
+	c.breaks = append(c.breaks, models.AuditChainBreak{Sequence: sequence, LogID: logID, Reason: reason})
+}
+
+func (c *chainChecker) check(record *models.ActivityLog) {
+	if record.Sequence == nil {
+		c.add(0, record.ID, models.AuditBreakUnsealed)
+		return
+	}
+	sequence := *record.Sequence
+	if c.linked {
+		if sequence > c.prevSeq+1 {
+			c.add(c.prevSeq+1, "", models.AuditBreakMissingRecord)
+		} else if record.PrevHash != c.prevHash {
+			c.add(sequence, record.ID, models.AuditBreakLinkMismatch)
+		}
+	}
+	if record.ComputeHash() != record.Hash {
+		c.add(sequence, record.ID, models.AuditBreakHashMismatch)
+	}
+	c.linked = true
+	c.prevSeq = sequence
+	c.prevHash = record.Hash
+}
+
+func (s *auditChainService) Verify(from, to time.Time) (*models.AuditChainVerification, error) {
+	result := &models.AuditChainVerification{From: from, To: to}
+	checker := &chainChecker{}
+
+	after := int64(0)
+	for {
+		batch, err := s.logs.FindChained(from, to, after, auditChainBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		for i := range batch {
+			record := &batch[i]
+			if result.Records == 0 {
+				result.FirstSequence = *record.Sequence
+				if err := s.linkPredecessor(checker, *record.Sequence); err != nil {
+					return nil, err
+				}
+			}
+			checker.check(record)
+			result.Records++
+		}
+		if len(batch) < auditChainBatchSize {
+			break
+		}
+		after = *batch[len(batch)-1].Sequence
+	}
+
+	if result.Records > 0 {
+		result.LastSequence = checker.prevSeq
+		result.LastHash = checker.prevHash
+		// A record deleted at the end of the period only shows as a gap before the next one
+		next, err := s.logs.FindNextChained(result.LastSequence)
+		if err != nil {
+			return nil, err
+		}
+		if next != nil && *next.Sequence > result.LastSequence+1 {
+			checker.add(result.LastSequence+1, "", models.AuditBreakMissingRecord)
+		}
+	}
+
+	unsealed, err := s.logs.FindUnsealed(from, to, auditChainMaxBreaks)
+	if err != nil {
+		return nil, err
+	}
+	for i := range unsealed {
+		checker.check(&unsealed[i])
+	}
+
+	result.Breaks = checker.breaks
+	if result.Breaks == nil {
+		result.Breaks = []models.AuditChainBreak{}
+	}
+	result.BreaksTruncated = checker.truncated
+	result.Valid = len(result.Breaks) == 0
+	result.VerifiedAt = time.Now()
+	return result, nil
+}
+
+// linkPredecessor points the checker at the record before the first one of the period
+func (s *auditChainService) linkPredecessor(checker *chainChecker, sequence int64) error {
+	if sequence == 1 {
+		checker.linked = true
+		return nil
+	}
+	before, err := s.logs.FindBySequence(sequence - 1)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			checker.add(sequence-1, "", models.AuditBreakMissingRecord)
+			return nil
+		}
+		return err
+	}
+	checker.linked = true
+	checker.prevSeq = sequence - 1
+	checker.prevHash = before.Hash
+	return nil
+}
+
+// =============== Export bundles ===============
+
+func (s *auditChainService) ListBundles(page, size int) (*models.PaginatedResponse, error) {
+	bundles, total, err := s.exports.FindBundles(page, size)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewPaginatedResponse(bundles, page, size, total), nil
+}
+
+func (s *auditChainService) OpenBundle(id string) (*models.AuditExportBundle, string, error) {
+	bundle, err := s.exports.FindBundleByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", ErrAuditBundleNotFound
+		}
+		return nil, "", err
+	}
+	if _, err := os.Stat(bundle.FilePath); err != nil {
+		return nil, "", ErrAuditBundleUnreadable
+	}
+	return bundle, bundle.FilePath, nil
+}
+
+func (s *auditChainService) ExportPending() (*models.AuditExportResult, error) {
+	if s.key == nil {
+		return nil, ErrAuditSigningKeyMissing
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := &models.AuditExportResult{Bundles: []models.AuditExportBundle{}}
+	var start time.Time
+	latest, err := s.exports.FindLatestBundle()
+	if err != nil {
+		return nil, err
+	}
+	if latest != nil {
+		start = latest.PeriodEnd.UTC()
+	} else {
+		first, err := s.logs.FindFirstChained()
+		if err != nil {
+			return nil, err
+		}
+		if first == nil {
+			return result, nil
+		}
+		t := first.CreatedAt.UTC()
+		start = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+
+	// Only closed days are exported; a long backlog is worked off over several runs
+	now := time.Now()
+	for i := 0; i < auditBundlesPerRun; i++ {
+		end := start.Add(auditBundlePeriod)
+		if end.After(now) {
+			break
+		}
+		bundle, err := s.exportPeriod(start, end)
+		if err != nil {
+			return result, fmt.Errorf("export %s: %w", start.Format("2006-01-02"), err)
+		}
+		result.Bundles = append(result.Bundles, *bundle)
+		start = end
+	}
+	return result, nil
+}
+
+func (s *auditChainService) exportPeriod(start, end time.Time) (*models.AuditExportBundle, error) {
+	records := []models.ActivityLog{}
+	after := int64(0)
+	for {
+		batch, err := s.logs.FindChained(start, end, after, auditChainBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, batch...)
+		if len(batch) < auditChainBatchSize {
+			break
+		}
+		after = *batch[len(batch)-1].Sequence
+	}
+
+	manifest := models.AuditBundleManifest{
+		Version:       1,
+		PeriodStart:   start,
+		PeriodEnd:     end,
+		Records:       int64(len(records)),
+		HashAlgorithm: "SHA-256",
+		SignAlgorithm: "Ed25519",
+		PublicKey:     base64.StdEncoding.EncodeToString(s.key.Public().(ed25519.PublicKey)),
+		GeneratedAt:   time.Now().UTC(),
+	}
+	if len(records) > 0 {
+		manifest.FirstSequence = *records[0].Sequence
+		manifest.LastSequence = *records[len(records)-1].Sequence
+		manifest.FirstPrevHash = records[0].PrevHash
+		manifest.LastHash = records[len(records)-1].Hash
+	} else {
+		// An empty day still carries the chain forward, proving nothing was written
+		prev, err := s.logs.FindLastChainedBefore(start)
+		if err != nil {
+			return nil, err
+		}
+		if prev != nil {
+			manifest.FirstPrevHash = prev.Hash
+			manifest.LastHash = prev.Hash
+		}
+	}
+
+	recordsJSON, err := json.Marshal(records)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(recordsJSON)
+	manifest.RecordsHash = hex.EncodeToString(sum[:])
+
+	manifestJSON, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(s.key, manifestJSON))
+
+	content, err := json.Marshal(models.AuditBundleFile{
+		Manifest:  manifestJSON,
+		Signature: signature,
+		Records:   recordsJSON,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(s.exportDir, 0o750); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(s.exportDir, fmt.Sprintf("audit-%s.json", start.Format("2006-01-02")))
+	if err := os.WriteFile(path, content, 0o440); err != nil {
+		return nil, err
+	}
+
+	bundle := &models.AuditExportBundle{
+		PeriodStart:   start,
+		PeriodEnd:     end,
+		Records:       manifest.Records,
+		FirstSequence: manifest.FirstSequence,
+		LastSequence:  manifest.LastSequence,
+		FirstPrevHash: manifest.FirstPrevHash,
+		LastHash:      manifest.LastHash,
+		RecordsHash:   manifest.RecordsHash,
+		Signature:     signature,
+		PublicKey:     manifest.PublicKey,
+		FilePath:      path,
+		FileSize:      int64(len(content)),
+	}
+	if err := s.exports.CreateBundle(bundle); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return bundle, nil
+}
+
+func (s *auditChainService) VerifyBundle(id string) (*models.AuditBundleVerification, error) {
+	bundle, path, err := s.OpenBundle(id)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, ErrAuditBundleUnreadable
+	}
+	var file models.AuditBundleFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, ErrAuditBundleUnreadable
+	}
+	var manifest models.AuditBundleManifest
+	if err := json.Unmarshal(file.Manifest, &manifest); err != nil {
+		return nil, ErrAuditBundleUnreadable
+	}
+
+	result := &models.AuditBundleVerification{BundleID: bundle.ID, VerifiedAt: time.Now()}
+
+	// The key recorded when the bundle was exported, so bundles outlive key rotations
+	publicKey, err := base64.StdEncoding.DecodeString(bundle.PublicKey)
+	signature, sigErr := base64.StdEncoding.DecodeString(file.Signature)
+	result.SignatureValid = err == nil && sigErr == nil &&
+		len(publicKey) == ed25519.PublicKeySize &&
+		manifest.PublicKey == bundle.PublicKey &&
+		ed25519.Verify(ed25519.PublicKey(publicKey), file.Manifest, signature)
+
+	sum := sha256.Sum256(file.Records)
+	var records []models.ActivityLog
+	result.ContentValid = hex.EncodeToString(sum[:]) == manifest.RecordsHash &&
+		manifest.RecordsHash == bundle.RecordsHash &&
+		json.Unmarshal(file.Records, &records) == nil &&
+		int64(len(records)) == manifest.Records
+
+	checker := &chainChecker{linked: true, prevSeq: manifest.FirstSequence - 1, prevHash: manifest.FirstPrevHash}
+	for i := range records {
+		checker.check(&records[i])
+	}
+	result.ChainValid = len(checker.breaks) == 0 && checker.prevHash == manifest.LastHash
+
+	// The records still in the database must be the ones that were signed
+	result.MatchesDatabase = true
+	if manifest.Records > 0 {
+		current, err := s.logs.FindBySequenceRange(manifest.FirstSequence, manifest.LastSequence)
+		if err != nil {
+			return nil, err
+		}
+		exported := make(map[int64]string, len(records))
+		for _, r := range records {
+			if r.Sequence != nil {
+				exported[*r.Sequence] = r.Hash
+			}
+		}
+		found := make(map[int64]bool, len(current))
+		for i := range current {
+			record := &current[i]
+			found[*record.Sequence] = true
+			if exported[*record.Sequence] != record.Hash || record.ComputeHash() != record.Hash {
+				checker.add(*record.Sequence, record.ID, models.AuditBreakHashMismatch)
+				result.MatchesDatabase = false
+			}
+		}
+		for sequence := range exported {
+			if !found[sequence] {
+				checker.add(sequence, "", models.AuditBreakMissingRecord)
+				result.MatchesDatabase = false
+			}
+		}
+	}
+
+	result.Breaks = checker.breaks
+	if result.Breaks == nil {
+		result.Breaks = []models.AuditChainBreak{}
+	}
+	result.Valid = result.SignatureValid && result.ContentValid && result.ChainValid && result.MatchesDatabase
+	return result, nil
+}
+
+func (s *auditChainService) PublicKey() (string, error) {
+	if s.key == nil {
+		return "", ErrAuditSigningKeyMissing
+	}
+	return base64.StdEncoding.EncodeToString(s.key.Public().(ed25519.PublicKey)), nil
+}
+
+// Start exports the closed days periodically until Stop is called
+func (s *auditChainService) Start(interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultAuditExportCheck
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				result, err := s.ExportPending()
+				if err != nil {
+					slog.Warn("Audit log export failed", "error", err)
+				}
+				if result != nil && len(result.Bundles) > 0 {
+					slog.Info("Exported signed audit log bundles", "count", len(result.Bundles))
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (s *auditChainService) Stop() {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+		s.stop = nil
+	}
+}
+
+func parseSigningKey(encoded string) (ed25519.PrivateKey, error) {
+	encoded = strings.TrimSpace(encoded)
+	raw, err := hex.DecodeString(encoded)
+	if err != nil {
+		raw, err = base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, errors.New("key must be hex or base64")
+		}
+	}
+	switch len(raw) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(raw), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(raw), nil
+	default:
+		return nil, fmt.Errorf("expected %d or %d bytes, got %d", ed25519.SeedSize, ed25519.PrivateKeySize, len(raw))
+	}
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+)
+
+// CSV exports stop at this many events; narrow the filters to get the rest
+const auditExportLimit = 50000
+
+var (
+	ErrAuditInvalidSource = errors.New("invalid source, expected activity, financial or access")
+	ErrAuditInvalidPeriod = errors.New("from must be before to")
+)
+
+// AuditService queries the activity, financial and access audit stores as one trail
+type AuditService interface {
+	Query(filter *models.AuditFilter, page, limit int) (*models.PaginatedAuditEvents, error)
+	// QueryByCursor is Query paged by keyset from the cursor; the total is not counted
+	QueryByCursor(filter *models.AuditFilter, cursor pagination.Cursor, limit int) (*models.PaginatedAuditEvents, error)
+	// ExportCSV renders the events matching filter, newest first; truncated tells whether
+	// the export limit was reached
+	ExportCSV(filter *models.AuditFilter) (data []byte, truncated bool, err error)
+}
+
+type auditService struct {
+	repo repositories.AuditRepository
+}
+
+func NewAuditService(repo repositories.AuditRepository) AuditService {
+	return &auditService{repo: repo}
+}
+
+func (s *auditService) Query(filter *models.AuditFilter, page, limit int) (*models.PaginatedAuditEvents, error) {
+	if err := validateAuditFilter(filter); err != nil {
+		return nil, err
+	}
+	if page < 1 {
+		page = 1
+	}
+
+	events, total, err := s.repo.FindAll(filter, page, limit)
+	if err != nil {
+		return nil, err
+	}
+	return &models.PaginatedAuditEvents{
+		Data:       events,
+		Total:      total,
+		Page:       page,
+		PageSize:   limit,
+		TotalPages: pagination.TotalPages(total, limit),
+		HasNext:    pagination.HasNext((page-1)*limit, limit, total),
+	}, nil
+}
+
+func (s *auditService) QueryByCursor(filter *models.AuditFilter, cursor pagination.Cursor, limit int) (*models.PaginatedAuditEvents, error) {
+	if err := validateAuditFilter(filter); err != nil {
+		return nil, err
+	}
+
+	events, err := s.repo.FindByCursor(filter, cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+	events, next, prev := pagination.CursorPage(events, cursor, limit, func(e models.AuditEvent) ([]time.Time, string) {
+		return []time.Time{e.CreatedAt}, e.Source + ":" + e.ID
+	})
+	return &models.PaginatedAuditEvents{
+		Data:       events,
+		PageSize:   limit,
+		HasNext:    next != "",
+		NextCursor: next,
+		PrevCursor: prev,
+	}, nil
+}
+
+func (s *auditService) ExportCSV(filter *models.AuditFilter) ([]byte, bool, error) {
+	if err := validateAuditFilter(filter); err != nil {
+		return nil, false, err
+	}
+	events, err := s.repo.FindForExport(filter, auditExportLimit+1)
+	if err != nil {
+		return nil, false, err
+	}
+	truncated := len(events) > auditExportLimit
+	if truncated {
+		events = events[:auditExportLimit]
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Write([]string{"createdAt", "source", "action", "entityType", "entityId", "userId", "userName", "description", "changes", "ipAddress", "userAgent", "requestId", "id"})
+	for _, e := range events {
+		writer.Write([]string{
+			e.CreatedAt.UTC().Format(time.RFC3339),
+			e.Source,
+			e.Action,
+			e.EntityType,
+			e.EntityID,
+			e.UserID,
+			e.UserName,
+			e.Description,
+			e.Changes,
+			e.IPAddress,
+			e.UserAgent,
+			e.RequestID,
+			e.ID,
+		})
+	}
+	writer.Flush()
+	return buf.Bytes(), truncated, writer.Error()
+}
+
+func validateAuditFilter(filter *models.AuditFilter) error {
+	if filter.Source != "" {
+		valid := false
+		for _, source := range models.AuditSources {
+			if filter.Source == source {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return ErrAuditInvalidSource
+		}
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && filter.From.After(filter.To) {
+		return ErrAuditInvalidPeriod
+	}
+	return nil
+}
@@ -1,9 +1,14 @@
 package services
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -13,26 +18,59 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrSessionNotFound     = errors.New("session not found")
+)
+
+// sessionStateCacheTTL bounds how long a session revoked on another instance stays usable here
+const sessionStateCacheTTL = 30 * time.Second
+
+// sessionStateCacheSize caps the cached session states; the cache is emptied when reached
+const sessionStateCacheSize = 10000
+
 type AuthService interface {
 	SignIn(req *models.SignInRequest, ipAddress, userAgent string) (*models.AuthResponse, error)
-	SignUp(req *models.SignUpRequest) (*models.AuthResponse, error)
-	RefreshToken(tokenString string) (*models.AuthResponse, error)
+	SignUp(req *models.SignUpRequest, ipAddress, userAgent string) (*models.AuthResponse, error)
+	// RefreshToken rotates the refresh token of the session and issues a new access token
+	RefreshToken(refreshToken, ipAddress, userAgent string) (*models.AuthResponse, error)
+	// ChangePassword also revokes every session of the user
 	ChangePassword(userID string, req *models.ChangePasswordRequest, ipAddress, userAgent string) error
+
+	// Sessions
+	ListSessions(userID, currentSessionID string) ([]models.SessionDTO, error)
+	RevokeSession(userID, sessionID, ipAddress, userAgent string) error
+	// RevokeAllSessions revokes the sessions of the user except exceptSessionID, if given
+	RevokeAllSessions(userID, exceptSessionID, ipAddress, userAgent string) (int64, error)
+	// IsSessionRevoked is checked on every authenticated request, against a short-lived
+	// cache of the session states; an error means the state could not be read
+	IsSessionRevoked(sessionID string) (bool, error)
 }
 
 type authService struct {
-	userRepo        repositories.UserRepository
-	securityLogRepo repositories.SecurityLogRepository
-	hierarchyRepo   repositories.HierarchyRepository
-	config          *config.Config
+	userRepo         repositories.UserRepository
+	securityLogRepo  repositories.SecurityLogRepository
+	hierarchyRepo    repositories.HierarchyRepository
+	refreshTokenRepo repositories.RefreshTokenRepository
+	config           *config.Config
+
+	mu       sync.Mutex
+	sessions map[string]cachedSession
 }
 
-func NewAuthService(userRepo repositories.UserRepository, securityLogRepo repositories.SecurityLogRepository, hierarchyRepo repositories.HierarchyRepository, config *config.Config) AuthService {
+type cachedSession struct {
+	revoked   bool
+	expiresAt time.Time // revoked sessions never expire: the revocation is final
+}
+
+func NewAuthService(userRepo repositories.UserRepository, securityLogRepo repositories.SecurityLogRepository, hierarchyRepo repositories.HierarchyRepository, refreshTokenRepo repositories.RefreshTokenRepository, config *config.Config) AuthService {
 	return &authService{
-		userRepo:        userRepo,
-		securityLogRepo: securityLogRepo,
-		hierarchyRepo:   hierarchyRepo,
-		config:          config,
+		userRepo:         userRepo,
+		securityLogRepo:  securityLogRepo,
+		hierarchyRepo:    hierarchyRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		config:           config,
+		sessions:         make(map[string]cachedSession),
 	}
 }
 
@@ -48,12 +86,12 @@ func (s *authService) logSecurityEvent(userID, email, action, ipAddress, userAge
 		CreatedAt: time.Now(),
 	}
 	if err := s.securityLogRepo.Create(secLog); err != nil {
-		log.Printf("Failed to log security event: %v", err)
+		slog.Warn("Failed to log security event", "error", err)
 	}
 }
 
 func (s *authService) SignIn(req *models.SignInRequest, ipAddress, userAgent string) (*models.AuthResponse, error) {
-	log.Printf("SignIn attempt for email: %s", req.Email)
+	slog.Debug("SignIn attempt", "email", req.Email)
 	
 	user, err := s.userRepo.FindByEmail(req.Email)
 	if err != nil {
@@ -68,12 +106,18 @@ func (s *authService) SignIn(req *models.SignInRequest, ipAddress, userAgent str
 		return nil, errors.New("user account is deactivated")
 	}
 
+	// API key service accounts only authenticate with their key
+	if user.Role == models.RoleIntegration {
+		s.logSecurityEvent(user.ID, req.Email, "login_failed", ipAddress, userAgent, "Integration account", false)
+		return nil, errors.New("invalid credentials")
+	}
+
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
 		s.logSecurityEvent(user.ID, req.Email, "login_failed", ipAddress, userAgent, "Invalid password", false)
 		return nil, errors.New("invalid credentials")
 	}
 
-	token, err := s.generateToken(user)
+	response, err := s.startSession(user, req.DeviceName, ipAddress, userAgent)
 	if err != nil {
 		return nil, err
 	}
@@ -81,31 +125,11 @@ func (s *authService) SignIn(req *models.SignInRequest, ipAddress, userAgent str
 	// Log successful login
 	s.logSecurityEvent(user.ID, user.Email, "login_success", ipAddress, userAgent, "", true)
 
-	// Get user permissions from hierarchy
-	var permissions []string
-	if user.Role == "ADMIN" {
-		// Admin has all permissions
-		allPerms, _ := s.hierarchyRepo.GetAllPermissions()
-		permissions = make([]string, len(allPerms))
-		for i, p := range allPerms {
-			permissions[i] = p.Code
-		}
-	} else {
-		// Get permissions from user memberships
-		permissions, _ = s.hierarchyRepo.GetUserPermissions(user.ID)
-	}
-
-	return &models.AuthResponse{
-		Token:       token,
-		FirstName:   user.FirstName,
-		LastName:    user.LastName,
-		Email:       user.Email,
-		Role:        user.Role,
-		Permissions: permissions,
-	}, nil
+	response.Permissions = s.permissions(user)
+	return response, nil
 }
 
-func (s *authService) SignUp(req *models.SignUpRequest) (*models.AuthResponse, error) {
+func (s *authService) SignUp(req *models.SignUpRequest, ipAddress, userAgent string) (*models.AuthResponse, error) {
 	// Check if user already exists
 	existingUser, _ := s.userRepo.FindByEmail(req.Email)
 	if existingUser != nil {
@@ -131,83 +155,142 @@ func (s *authService) SignUp(req *models.SignUpRequest) (*models.AuthResponse, e
 		return nil, err
 	}
 
-	token, err := s.generateToken(user)
+	response, err := s.startSession(user, "", ipAddress, userAgent)
 	if err != nil {
 		return nil, err
 	}
 
 	// New users have no permissions until assigned
-	return &models.AuthResponse{
-		Token:       token,
-		FirstName:   user.FirstName,
-		LastName:    user.LastName,
-		Email:       user.Email,
-		Role:        user.Role,
-		Permissions: []string{},
-	}, nil
+	response.Permissions = []string{}
+	return response, nil
 }
 
-func (s *authService) RefreshToken(tokenString string) (*models.AuthResponse, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return []byte(s.config.JWTSecret), nil
-	})
+func (s *authService) RefreshToken(refreshToken, ipAddress, userAgent string) (*models.AuthResponse, error) {
+	hash := hashRefreshToken(refreshToken)
+	session, err := s.refreshTokenRepo.FindByHash(hash)
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	now := time.Now()
+	if session.RevokedAt != nil || now.After(session.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if session.TokenHash != hash {
+		// The token was already rotated: someone else holds a copy of it
+		if _, err := s.refreshTokenRepo.Revoke(session.ID, models.SessionRevokedTokenReuse, now); err != nil {
+			slog.Warn("Failed to revoke session", "session_id", session.ID, "error", err)
+		} else {
+			s.rememberSession(session.ID, true)
+		}
+		s.logSecurityEvent(session.UserID, "", "refresh_token_reuse", ipAddress, userAgent, "Session "+session.ID+" revoked", false)
+		return nil, ErrInvalidRefreshToken
+	}
 
-	if err != nil || !token.Valid {
-		return nil, errors.New("invalid token")
+	user, err := s.userRepo.FindByID(session.UserID)
+	if err != nil || !user.Active {
+		if _, err := s.refreshTokenRepo.Revoke(session.ID, models.SessionRevokedLogout, now); err == nil {
+			s.rememberSession(session.ID, true)
+		}
+		return nil, ErrInvalidRefreshToken
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return nil, errors.New("invalid token claims")
+	newRefreshToken, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	rotated, err := s.refreshTokenRepo.Rotate(session.ID, hash, hashRefreshToken(newRefreshToken), now.Add(s.config.JWTRefreshExpiration), now)
+	if err != nil {
+		return nil, err
+	}
+	if !rotated {
+		// A concurrent refresh rotated it first
+		return nil, ErrInvalidRefreshToken
 	}
 
-	// Handle both string and float64 user IDs from JWT
-	var userID string
-	switch v := claims["userId"].(type) {
-	case string:
-		userID = v
-	case float64:
-		// Legacy support for numeric IDs, convert to string
-		userID = fmt.Sprintf("%.0f", v)
-	default:
-		return nil, errors.New("invalid user ID in token")
+	token, err := s.generateToken(user, session.ID)
+	if err != nil {
+		return nil, err
 	}
+	return &models.AuthResponse{
+		Token:        token,
+		RefreshToken: newRefreshToken,
+		SessionID:    session.ID,
+		FirstName:    user.FirstName,
+		LastName:     user.LastName,
+		Email:        user.Email,
+		Role:         user.Role,
+		Permissions:  s.permissions(user),
+	}, nil
+}
 
-	user, err := s.userRepo.FindByID(userID)
+// startSession creates the session of a login and issues its tokens
+func (s *authService) startSession(user *models.User, deviceName, ipAddress, userAgent string) (*models.AuthResponse, error) {
+	refreshToken, err := newRefreshToken()
 	if err != nil {
-		return nil, errors.New("user not found")
+		return nil, err
+	}
+	now := time.Now()
+	session := &models.RefreshToken{
+		UserID:     user.ID,
+		TokenHash:  hashRefreshToken(refreshToken),
+		DeviceName: deviceName,
+		UserAgent:  userAgent,
+		IPAddress:  ipAddress,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(s.config.JWTRefreshExpiration),
+	}
+	if err := s.refreshTokenRepo.Create(session); err != nil {
+		return nil, err
 	}
 
-	newToken, err := s.generateToken(user)
+	token, err := s.generateToken(user, session.ID)
 	if err != nil {
 		return nil, err
 	}
+	return &models.AuthResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		SessionID:    session.ID,
+		FirstName:    user.FirstName,
+		LastName:     user.LastName,
+		Email:        user.Email,
+		Role:         user.Role,
+	}, nil
+}
 
-	// Get user permissions
-	var permissions []string
+// permissions returns the permission codes of the user: all of them for admins, those of
+// the memberships otherwise
+func (s *authService) permissions(user *models.User) []string {
 	if user.Role == "ADMIN" {
 		allPerms, _ := s.hierarchyRepo.GetAllPermissions()
-		permissions = make([]string, len(allPerms))
+		permissions := make([]string, len(allPerms))
 		for i, p := range allPerms {
 			permissions[i] = p.Code
 		}
-	} else {
-		permissions, _ = s.hierarchyRepo.GetUserPermissions(user.ID)
+		return permissions
 	}
+	permissions, _ := s.hierarchyRepo.GetUserPermissions(user.ID)
+	return permissions
+}
 
-	return &models.AuthResponse{
-		Token:       newToken,
-		FirstName:   user.FirstName,
-		LastName:    user.LastName,
-		Email:       user.Email,
-		Role:        user.Role,
-		Permissions: permissions,
-	}, nil
+func newRefreshToken() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
 
-func (s *authService) generateToken(user *models.User) (string, error) {
+func (s *authService) generateToken(user *models.User, sessionID string) (string, error) {
 	claims := jwt.MapClaims{
+		"sid":       sessionID,
 		"userId":    user.ID,
+		"tenantId":  user.TenantID,
 		"email":     user.Email,
 		"role":      user.Role,
 		"roles":     []string{user.Role},
@@ -244,7 +327,84 @@ func (s *authService) ChangePassword(userID string, req *models.ChangePasswordRe
 		return err
 	}
 
+	// Sessions opened with the old password are signed out everywhere
+	revoked, err := s.refreshTokenRepo.RevokeAllForUser(userID, "", models.SessionRevokedPasswordChange, time.Now())
+	if err != nil {
+		slog.Warn("Failed to revoke sessions of user", "user_id", userID, "error", err)
+	}
+	s.forgetSessions()
+
 	// Log successful password change
-	s.logSecurityEvent(userID, user.Email, "password_change", ipAddress, userAgent, "", true)
+	s.logSecurityEvent(userID, user.Email, "password_change", ipAddress, userAgent, fmt.Sprintf("%d sessions revoked", revoked), true)
 	return nil
 }
+
+func (s *authService) ListSessions(userID, currentSessionID string) ([]models.SessionDTO, error) {
+	sessions, err := s.refreshTokenRepo.FindActiveByUser(userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	dtos := make([]models.SessionDTO, 0, len(sessions))
+	for _, session := range sessions {
+		dtos = append(dtos, models.SessionDTO{RefreshToken: session, Current: session.ID == currentSessionID})
+	}
+	return dtos, nil
+}
+
+func (s *authService) RevokeSession(userID, sessionID, ipAddress, userAgent string) error {
+	session, err := s.refreshTokenRepo.FindByID(sessionID)
+	if err != nil || session.UserID != userID || session.RevokedAt != nil {
+		return ErrSessionNotFound
+	}
+	if _, err := s.refreshTokenRepo.Revoke(sessionID, models.SessionRevokedLogout, time.Now()); err != nil {
+		return err
+	}
+	s.rememberSession(sessionID, true)
+	s.logSecurityEvent(userID, "", "session_revoked", ipAddress, userAgent, "Session "+sessionID, true)
+	return nil
+}
+
+func (s *authService) RevokeAllSessions(userID, exceptSessionID, ipAddress, userAgent string) (int64, error) {
+	revoked, err := s.refreshTokenRepo.RevokeAllForUser(userID, exceptSessionID, models.SessionRevokedLogout, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	s.forgetSessions()
+	s.logSecurityEvent(userID, "", "sessions_revoked", ipAddress, userAgent, fmt.Sprintf("%d sessions revoked", revoked), true)
+	return revoked, nil
+}
+
+func (s *authService) IsSessionRevoked(sessionID string) (bool, error) {
+	s.mu.Lock()
+	cached, ok := s.sessions[sessionID]
+	s.mu.Unlock()
+	if ok && (cached.revoked || time.Now().Before(cached.expiresAt)) {
+		return cached.revoked, nil
+	}
+
+	revoked, err := s.refreshTokenRepo.IsRevoked(sessionID)
+	if err != nil {
+		slog.Error("Failed to check session, rejecting the request", "session_id", sessionID, "error", err)
+		return false, err
+	}
+	s.rememberSession(sessionID, revoked)
+	return revoked, nil
+}
+
+// rememberSession caches the revocation state of the session for IsSessionRevoked
+func (s *authService) rememberSession(sessionID string, revoked bool) {
+	s.mu.Lock()
+	if len(s.sessions) >= sessionStateCacheSize {
+		s.sessions = make(map[string]cachedSession)
+	}
+	s.sessions[sessionID] = cachedSession{revoked: revoked, expiresAt: time.Now().Add(sessionStateCacheTTL)}
+	s.mu.Unlock()
+}
+
+// forgetSessions drops the cached states after revoking sessions by user, whose IDs are
+// not known here
+func (s *authService) forgetSessions() {
+	s.mu.Lock()
+	s.sessions = make(map[string]cachedSession)
+	s.mu.Unlock()
+}
@@ -0,0 +1,227 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"github.com/shigake/tech-iq-back/internal/storage"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrBrandingNotFound        = errors.New("branding not found")
+	ErrBrandingNodeNotFound    = errors.New("node not found")
+	ErrBrandingNodeNotTopLevel = errors.New("branding can only be set on top-level nodes")
+	ErrBrandingLogoInvalid     = errors.New("logo must be a ready NODE_BRANDING file of the node")
+	ErrBrandingLogoTooLarge    = errors.New("logo exceeds 512 KB")
+)
+
+// maxBrandingLogoSize keeps the logo small enough to be embedded in every printed document
+const maxBrandingLogoSize = 512 * 1024
+
+// BrandingService manages the branding of top-level nodes and resolves the branding a
+// document of a node is generated with
+type BrandingService interface {
+	List() ([]models.NodeBranding, error)
+	Get(nodeID uint) (*models.NodeBranding, error)
+	Upsert(nodeID uint, req *models.UpsertNodeBrandingRequest, userID string) (*models.NodeBranding, error)
+	Delete(nodeID uint) error
+	// Resolve returns the branding of the top-level node above nodeID, with the logo
+	// content; the defaults when nodeID is nil or its top-level node has no branding
+	Resolve(nodeID *uint) (*models.DocumentBranding, error)
+}
+
+type brandingService struct {
+	repo          repositories.BrandingRepository
+	hierarchyRepo repositories.HierarchyRepository
+	fileRepo      repositories.StoredFileRepository
+	backend       storage.Backend
+	companyName   string
+}
+
+func NewBrandingService(
+	repo repositories.BrandingRepository,
+	hierarchyRepo repositories.HierarchyRepository,
+	fileRepo repositories.StoredFileRepository,
+	backend storage.Backend,
+	companyName string,
+) BrandingService {
+	return &brandingService{
+		repo:          repo,
+		hierarchyRepo: hierarchyRepo,
+		fileRepo:      fileRepo,
+		backend:       backend,
+		companyName:   companyName,
+	}
+}
+
+func (s *brandingService) List() ([]models.NodeBranding, error) {
+	return s.repo.FindAll()
+}
+
+func (s *brandingService) Get(nodeID uint) (*models.NodeBranding, error) {
+	branding, err := s.repo.FindByNodeID(nodeID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBrandingNotFound
+		}
+		return nil, err
+	}
+	return branding, nil
+}
+
+func (s *brandingService) Upsert(nodeID uint, req *models.UpsertNodeBrandingRequest, userID string) (*models.NodeBranding, error) {
+	node, err := s.hierarchyRepo.GetNodeByID(nodeID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBrandingNodeNotFound
+		}
+		return nil, err
+	}
+	if node.ParentID != nil {
+		return nil, ErrBrandingNodeNotTopLevel
+	}
+
+	branding := &models.NodeBranding{
+		NodeID:             nodeID,
+		DisplayName:        strings.TrimSpace(req.DisplayName),
+		PrimaryColor:       strings.ToLower(req.PrimaryColor),
+		AccentColor:        strings.ToLower(req.AccentColor),
+		FooterText:         strings.TrimSpace(req.FooterText),
+		ServiceOrderPrefix: strings.TrimSpace(req.ServiceOrderPrefix),
+		UpdatedBy:          userID,
+	}
+	if existing, err := s.repo.FindByNodeID(nodeID); err == nil {
+		branding.CreatedAt = existing.CreatedAt
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if req.LogoFileID != "" {
+		if _, err := s.logoFile(nodeID, req.LogoFileID); err != nil {
+			return nil, err
+		}
+		branding.LogoFileID = &req.LogoFileID
+	}
+
+	if err := s.repo.Save(branding); err != nil {
+		return nil, err
+	}
+	return s.Get(nodeID)
+}
+
+func (s *brandingService) Delete(nodeID uint) error {
+	if err := s.repo.Delete(nodeID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrBrandingNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *brandingService) Resolve(nodeID *uint) (*models.DocumentBranding, error) {
+	resolved := &models.DocumentBranding{DisplayName: s.companyName}
+	if nodeID == nil {
+		return resolved, nil
+	}
+
+	node, err := s.hierarchyRepo.GetNodeByID(*nodeID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return resolved, nil
+		}
+		return nil, err
+	}
+	rootID := topLevelNodeID(node)
+
+	branding, err := s.repo.FindByNodeID(rootID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return resolved, nil
+		}
+		return nil, err
+	}
+
+	resolved.NodeID = &rootID
+	if branding.DisplayName != "" {
+		resolved.DisplayName = branding.DisplayName
+	}
+	resolved.PrimaryColor = branding.PrimaryColor
+	resolved.AccentColor = branding.AccentColor
+	resolved.FooterText = branding.FooterText
+	resolved.ServiceOrderPrefix = branding.ServiceOrderPrefix
+
+	// A missing logo does not stop the document from being generated
+	if branding.LogoFileID != nil {
+		if err := s.loadLogo(resolved, rootID, *branding.LogoFileID); err != nil {
+			slog.Warn("Failed to load logo of node", "root_id", rootID, "error", err)
+		}
+	}
+	return resolved, nil
+}
+
+// logoFile checks the file is a ready logo uploaded for the node
+func (s *brandingService) logoFile(nodeID uint, fileID string) (*models.StoredFile, error) {
+	file, err := s.fileRepo.FindByID(fileID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBrandingLogoInvalid
+		}
+		return nil, err
+	}
+	if file.OwnerType != models.FileOwnerNodeBranding || file.OwnerID != strconv.FormatUint(uint64(nodeID), 10) ||
+		file.Status != models.FileStatusReady {
+		return nil, ErrBrandingLogoInvalid
+	}
+	if file.Size > maxBrandingLogoSize {
+		return nil, ErrBrandingLogoTooLarge
+	}
+	return file, nil
+}
+
+func (s *brandingService) loadLogo(resolved *models.DocumentBranding, nodeID uint, fileID string) error {
+	file, err := s.logoFile(nodeID, fileID)
+	if err != nil {
+		return err
+	}
+	content, err := s.backend.Open(file.Key)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	logo, err := io.ReadAll(io.LimitReader(content, maxBrandingLogoSize+1))
+	if err != nil {
+		return err
+	}
+	if len(logo) > maxBrandingLogoSize {
+		return ErrBrandingLogoTooLarge
+	}
+	resolved.Logo = logo
+	resolved.LogoContentType = file.ContentType
+	return nil
+}
+
+// topLevelNodeID is the first node of the node's materialized path ("1.2.3" -> 1)
+func topLevelNodeID(node *models.Node) uint {
+	first, _, _ := strings.Cut(node.Path, ".")
+	id, err := strconv.ParseUint(first, 10, 32)
+	if err != nil {
+		return node.ID
+	}
+	return uint(id)
+}
+
+// serviceOrderNumber is the OS number as printed on the node's documents
+func serviceOrderNumber(branding *models.DocumentBranding, osNumber string) string {
+	if branding == nil || branding.ServiceOrderPrefix == "" {
+		return osNumber
+	}
+	return branding.ServiceOrderPrefix + osNumber
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+)
+
+const (
+	// Feed window: recent past (for reference) and the upcoming agenda
+	calendarFeedPast   = 30 * 24 * time.Hour
+	calendarFeedFuture = 90 * 24 * time.Hour
+
+	icsTimeFormat = "20060102T150405Z"
+	// RFC 5545 §3.1: lines longer than 75 octets are folded
+	icsLineLimit = 75
+)
+
+// renderCalendar builds an RFC 5545 calendar with one VEVENT per scheduled ticket.
+// UIDs are stable per ticket so subscribed calendars move events when a ticket is rescheduled.
+func renderCalendar(technician *models.Technician, tickets []models.Ticket, now time.Time) []byte {
+	var buf bytes.Buffer
+	line := func(name, value string) {
+		writeICSLine(&buf, name+":"+value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//TechERP//Technician Schedule//PT-BR")
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	line("X-WR-CALNAME", escapeICSText("TechERP - "+technician.FullName))
+	line("X-WR-TIMEZONE", "America/Sao_Paulo")
+	// Hint for clients on how often to refresh the subscription
+	line("REFRESH-INTERVAL;VALUE=DURATION", "PT15M")
+	line("X-PUBLISHED-TTL", "PT15M")
+
+	for _, ticket := range tickets {
+		if ticket.ScheduledStart == nil || ticket.ScheduledEnd == nil {
+			continue
+		}
+
+		summary := "OS " + ticket.OSNumber
+		if ticket.Client != nil && ticket.Client.FullName != "" {
+			summary += " - " + ticket.Client.FullName
+		}
+
+		line("BEGIN", "VEVENT")
+		line("UID", ticket.ID+"@techerp")
+		line("DTSTAMP", now.UTC().Format(icsTimeFormat))
+		line("LAST-MODIFIED", ticket.UpdatedAt.UTC().Format(icsTimeFormat))
+		// SEQUENCE must grow on every change; seconds since creation do
+		line("SEQUENCE", strconv.FormatInt(int64(ticket.UpdatedAt.Sub(ticket.CreatedAt).Seconds()), 10))
+		line("DTSTART", ticket.ScheduledStart.UTC().Format(icsTimeFormat))
+		line("DTEND", ticket.ScheduledEnd.UTC().Format(icsTimeFormat))
+		line("SUMMARY", escapeICSText(summary))
+		if location := clientAddress(ticket.Client); location != "" {
+			line("LOCATION", escapeICSText(location))
+		}
+		if ticket.ErrorDescription != "" {
+			line("DESCRIPTION", escapeICSText(ticket.ErrorDescription))
+		}
+		line("STATUS", "CONFIRMED")
+		if ticket.Priority == models.TicketPriorityUrgent || ticket.Priority == models.TicketPriorityHigh {
+			line("PRIORITY", "1")
+		}
+		line("END", "VEVENT")
+	}
+
+	line("END", "VCALENDAR")
+	return buf.Bytes()
+}
+
+func clientAddress(client *models.Client) string {
+	if client == nil {
+		return ""
+	}
+	street := strings.TrimSpace(strings.Join(nonEmpty(client.Street, client.Number), ", "))
+	return strings.Join(nonEmpty(street, client.Neighborhood, client.City, client.State), " - ")
+}
+
+func nonEmpty(values ...string) []string {
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// escapeICSText escapes TEXT values (RFC 5545 §3.3.11)
+func escapeICSText(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	replacer := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`, "\r", "")
+	return replacer.Replace(s)
+}
+
+// writeICSLine writes a CRLF-terminated content line, folding it at 75 octets
+// without splitting multi-byte characters
+func writeICSLine(buf *bytes.Buffer, s string) {
+	limit := icsLineLimit
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(s[cut]) {
+			cut--
+		}
+		buf.WriteString(s[:cut])
+		buf.WriteString("\r\n ")
+		s = s[cut:]
+		// Continuation lines start with a space, which counts toward the limit
+		limit = icsLineLimit - 1
+	}
+	buf.WriteString(s)
+	buf.WriteString("\r\n")
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
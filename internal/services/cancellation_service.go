@@ -0,0 +1,206 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCancellationReasonNotFound = errors.New("cancellation reason not found")
+	ErrCancellationReasonInactive = errors.New("cancellation reason is inactive")
+	ErrCancellationReasonExists   = errors.New("cancellation reason code already exists")
+	ErrCancellationTicketNotFound = errors.New("ticket not found")
+	ErrTicketAlreadyCancelled     = errors.New("ticket is already cancelled")
+	ErrTicketNotCancellable       = errors.New("closed tickets cannot be cancelled")
+	ErrCancellationInvalidPeriod  = errors.New("invalid period, expected day, week or month")
+)
+
+// CancellationService cancels tickets against the managed reason taxonomy and reports on them
+type CancellationService interface {
+	ListReasons(includeInactive bool) ([]models.CancellationReason, error)
+	CreateReason(req *models.CreateCancellationReasonRequest) (*models.CancellationReason, error)
+	UpdateReason(id string, req *models.UpdateCancellationReasonRequest) (*models.CancellationReason, error)
+	Cancel(ticketID, userID string, req *models.CancelTicketRequest) (*models.Ticket, error)
+	GetReport(from, to time.Time, period string) (*models.CancellationReport, error)
+	// WithContext returns the service running its queries with ctx, inside the tenant it carries
+	WithContext(ctx context.Context) CancellationService
+}
+
+type cancellationService struct {
+	repo       repositories.CancellationRepository
+	ticketRepo repositories.TicketRepository
+}
+
+func NewCancellationService(repo repositories.CancellationRepository, ticketRepo repositories.TicketRepository) CancellationService {
+	return &cancellationService{
+		repo:       repo,
+		ticketRepo: ticketRepo,
+	}
+}
+
+func (s *cancellationService) WithContext(ctx context.Context) CancellationService {
+	scoped := *s
+	scoped.ticketRepo = s.ticketRepo.WithContext(ctx)
+	return &scoped
+}
+
+func (s *cancellationService) ListReasons(includeInactive bool) ([]models.CancellationReason, error) {
+	return s.repo.FindReasons(includeInactive)
+}
+
+func (s *cancellationService) CreateReason(req *models.CreateCancellationReasonRequest) (*models.CancellationReason, error) {
+	code := strings.ToUpper(strings.TrimSpace(req.Code))
+	exists, err := s.repo.ExistsReasonCode(code)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrCancellationReasonExists
+	}
+
+	reason := &models.CancellationReason{
+		Code:        code,
+		Name:        strings.TrimSpace(req.Name),
+		Description: req.Description,
+		Active:      true,
+		SortOrder:   req.SortOrder,
+	}
+	if err := s.repo.CreateReason(reason); err != nil {
+		return nil, err
+	}
+	return reason, nil
+}
+
+// UpdateReason edits a reason; reasons are deactivated rather than deleted so past
+// cancellations keep their classification
+func (s *cancellationService) UpdateReason(id string, req *models.UpdateCancellationReasonRequest) (*models.CancellationReason, error) {
+	reason, err := s.findReason(id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Name != nil {
+		reason.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Description != nil {
+		reason.Description = *req.Description
+	}
+	if req.Active != nil {
+		reason.Active = *req.Active
+	}
+	if req.SortOrder != nil {
+		reason.SortOrder = *req.SortOrder
+	}
+	if err := s.repo.UpdateReason(reason); err != nil {
+		return nil, err
+	}
+	return reason, nil
+}
+
+func (s *cancellationService) findReason(id string) (*models.CancellationReason, error) {
+	reason, err := s.repo.FindReasonByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCancellationReasonNotFound
+		}
+		return nil, err
+	}
+	return reason, nil
+}
+
+// Cancel moves an open ticket to CANCELADO with a reason from the taxonomy
+func (s *cancellationService) Cancel(ticketID, userID string, req *models.CancelTicketRequest) (*models.Ticket, error) {
+	ticket, err := s.ticketRepo.FindByID(ticketID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCancellationTicketNotFound
+		}
+		return nil, err
+	}
+	switch ticket.Status {
+	case models.TicketStatusCancelled:
+		return nil, ErrTicketAlreadyCancelled
+	case models.TicketStatusClosed:
+		return nil, ErrTicketNotCancellable
+	}
+
+	reason, err := s.findReason(req.ReasonID)
+	if err != nil {
+		return nil, err
+	}
+	if !reason.Active {
+		return nil, ErrCancellationReasonInactive
+	}
+
+	cancellation := &models.TicketCancellation{
+		TicketID:    ticket.ID,
+		ReasonID:    reason.ID,
+		Notes:       strings.TrimSpace(req.Notes),
+		CancelledBy: userID,
+		CancelledAt: time.Now(),
+	}
+	if err := s.repo.Cancel(cancellation); err != nil {
+		return nil, err
+	}
+	return s.ticketRepo.FindByID(ticketID)
+}
+
+// GetReport breaks down the tickets cancelled in [from, to) per reason, client state and period
+func (s *cancellationService) GetReport(from, to time.Time, period string) (*models.CancellationReport, error) {
+	switch period {
+	case "":
+		period = "month"
+	case "day", "week", "month":
+	default:
+		return nil, ErrCancellationInvalidPeriod
+	}
+
+	byReason, err := s.repo.CountByReason(from, to)
+	if err != nil {
+		return nil, err
+	}
+	byRegion, err := s.repo.CountByRegion(from, to)
+	if err != nil {
+		return nil, err
+	}
+	byPeriod, err := s.repo.CountByPeriod(from, to, period)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.CancellationReport{
+		From:     from,
+		To:       to,
+		Period:   period,
+		ByReason: byReason,
+		ByRegion: byRegion,
+		ByPeriod: byPeriod,
+	}
+	for _, r := range byReason {
+		report.Total += r.Count
+	}
+	for i := range report.ByRegion {
+		if report.ByRegion[i].Key == "" {
+			report.ByRegion[i].Label = "Sem região"
+		} else {
+			report.ByRegion[i].Label = report.ByRegion[i].Key
+		}
+	}
+	for i := range report.ByPeriod {
+		report.ByPeriod[i].Label = report.ByPeriod[i].Key
+	}
+	for _, rows := range [][]models.CancellationCount{report.ByReason, report.ByRegion, report.ByPeriod} {
+		for i := range rows {
+			if report.Total > 0 {
+				rows[i].Percent = math.Round(float64(rows[i].Count)*1000/float64(report.Total)) / 10
+			}
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,407 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"gorm.io/gorm"
+)
+
+const slackPostMessageURL = "https://slack.com/api/chat.postMessage"
+
+var (
+	ErrChatChannelNotFound = errors.New("chat channel not found")
+	ErrChatChannelTarget   = errors.New("slack channels need a webhookUrl or a botToken and slackChannel, teams channels a webhookUrl")
+	ErrChatNodeNotFound    = errors.New("node not found")
+)
+
+// chatSeverityColor colors the Slack attachment and the Teams card of an alert
+var chatSeverityColor = map[string]string{
+	models.AlertSeverityInfo:     "439FE0",
+	models.AlertSeverityWarning:  "F2C744",
+	models.AlertSeverityCritical: "D40E0D",
+}
+
+// ChatService posts operational alerts (SLA breaches, low stock...) and the daily alert
+// digest to the Slack and Teams channels of the nodes
+type ChatService interface {
+	List() ([]models.ChatChannel, error)
+	Get(id string) (*models.ChatChannel, error)
+	Create(userID string, req *models.ChatChannelRequest) (*models.ChatChannel, error)
+	Update(id string, req *models.ChatChannelRequest) (*models.ChatChannel, error)
+	Delete(id string) error
+
+	// Test posts a test message to the channel, whether it is active or not
+	Test(id string) (*models.ChatPostResult, error)
+	// PostAlert posts a new or escalated alert to the channels routed to it
+	PostAlert(alert models.Alert)
+	// SendDigest posts the alerts of the last 24 hours to the digest channels
+	SendDigest() (*models.ChatDigestResult, error)
+
+	// Start checks every hour whether the daily digest is due at the hour
+	Start(hour int)
+	Stop()
+}
+
+type chatService struct {
+	repo          repositories.ChatChannelRepository
+	hierarchyRepo repositories.HierarchyRepository
+	client        *http.Client
+
+	mu         sync.Mutex // one digest at a time
+	lastDigest string     // date of the last scheduled digest
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+func NewChatService(repo repositories.ChatChannelRepository, hierarchyRepo repositories.HierarchyRepository) ChatService {
+	return &chatService{
+		repo:          repo,
+		hierarchyRepo: hierarchyRepo,
+		client:        &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *chatService) List() ([]models.ChatChannel, error) {
+	return s.repo.FindAll()
+}
+
+func (s *chatService) Get(id string) (*models.ChatChannel, error) {
+	channel, err := s.repo.FindByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrChatChannelNotFound
+		}
+		return nil, err
+	}
+	return channel, nil
+}
+
+func (s *chatService) Create(userID string, req *models.ChatChannelRequest) (*models.ChatChannel, error) {
+	channel := &models.ChatChannel{Active: true, CreatedBy: userID}
+	if err := s.apply(channel, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(channel); err != nil {
+		return nil, err
+	}
+	return s.Get(channel.ID)
+}
+
+func (s *chatService) Update(id string, req *models.ChatChannelRequest) (*models.ChatChannel, error) {
+	channel, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.apply(channel, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Update(channel); err != nil {
+		return nil, err
+	}
+	return s.Get(channel.ID)
+}
+
+func (s *chatService) apply(channel *models.ChatChannel, req *models.ChatChannelRequest) error {
+	if req.NodeID != nil {
+		if _, err := s.hierarchyRepo.GetNodeByID(*req.NodeID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrChatNodeNotFound
+			}
+			return err
+		}
+	}
+
+	channel.Name = strings.TrimSpace(req.Name)
+	channel.Provider = req.Provider
+	channel.NodeID = req.NodeID
+	channel.SlackChannel = strings.TrimSpace(req.SlackChannel)
+	channel.AlertTypes = req.AlertTypes
+	channel.MinSeverity = req.MinSeverity
+	if channel.MinSeverity == "" {
+		channel.MinSeverity = models.AlertSeverityWarning
+	}
+	channel.DailyDigest = req.DailyDigest
+	if req.WebhookURL != nil {
+		channel.WebhookURL = strings.TrimSpace(*req.WebhookURL)
+	}
+	if req.BotToken != nil {
+		channel.BotToken = strings.TrimSpace(*req.BotToken)
+	}
+	if req.Active != nil {
+		channel.Active = *req.Active
+	}
+
+	switch {
+	case channel.Provider == models.ChatProviderTeams && channel.WebhookURL == "":
+		return ErrChatChannelTarget
+	case channel.Provider == models.ChatProviderSlack && channel.WebhookURL == "" &&
+		(channel.BotToken == "" || channel.SlackChannel == ""):
+		return ErrChatChannelTarget
+	}
+	return nil
+}
+
+func (s *chatService) Delete(id string) error {
+	if err := s.repo.Delete(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrChatChannelNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *chatService) Test(id string) (*models.ChatPostResult, error) {
+	channel, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	err = s.post(channel, "Tech IQ", fmt.Sprintf("Mensagem de teste do canal %s.", channel.Name), chatSeverityColor[models.AlertSeverityInfo])
+	if err != nil {
+		return &models.ChatPostResult{Error: err.Error()}, nil
+	}
+	return &models.ChatPostResult{Delivered: true}, nil
+}
+
+func (s *chatService) PostAlert(alert models.Alert) {
+	channels, err := s.repo.FindActive()
+	if err != nil {
+		slog.Warn("Failed to load chat channels", "error", err)
+		return
+	}
+
+	// The node of the ticket is only looked up when a node channel may take the alert
+	var ticketPath *string
+	for i := range channels {
+		channel := &channels[i]
+		if !chatRoutes(channel, &alert) {
+			continue
+		}
+		if channel.NodeID != nil {
+			if alert.ResourceType != "TICKET" || channel.Node == nil {
+				continue
+			}
+			if ticketPath == nil {
+				path, err := s.repo.TicketNodePath(alert.ResourceID)
+				if err != nil {
+					slog.Warn("Failed to resolve node of ticket", "resource_id", alert.ResourceID, "error", err)
+				}
+				ticketPath = &path
+			}
+			if !inNodePath(*ticketPath, channel.Node.Path) {
+				continue
+			}
+		}
+
+		title := fmt.Sprintf("[%s] %s", alert.Severity, alert.Title)
+		if err := s.post(channel, title, alert.Message, chatSeverityColor[alert.Severity]); err != nil {
+			slog.Warn("Failed to post alert to chat channel", "alert_id", alert.ID, "channel", channel.Name, "error", err)
+		}
+	}
+}
+
+// chatRoutes reports whether the channel takes alerts of the type and severity
+func chatRoutes(channel *models.ChatChannel, alert *models.Alert) bool {
+	if alertSeverityRank[alert.Severity] < alertSeverityRank[channel.MinSeverity] {
+		return false
+	}
+	if len(channel.AlertTypes) == 0 {
+		return true
+	}
+	for _, alertType := range channel.AlertTypes {
+		if alertType == alert.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// inNodePath reports whether path is the node at root or one of its descendants
+func inNodePath(path, root string) bool {
+	return path != "" && (path == root || strings.HasPrefix(path, root+"."))
+}
+
+func (s *chatService) SendDigest() (*models.ChatDigestResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	channels, err := s.repo.FindActive()
+	if err != nil {
+		return nil, err
+	}
+
+	since := time.Now().Add(-24 * time.Hour)
+	result := &models.ChatDigestResult{}
+	for i := range channels {
+		channel := &channels[i]
+		if !channel.DailyDigest {
+			continue
+		}
+		nodePath := ""
+		if channel.NodeID != nil {
+			if channel.Node == nil {
+				continue
+			}
+			nodePath = channel.Node.Path
+		}
+
+		rows, err := s.repo.DigestCounts(since, nodePath)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", channel.Name, err))
+			continue
+		}
+		if err := s.post(channel, "Resumo diário de alertas", digestText(rows), digestColor(rows)); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", channel.Name, err))
+			continue
+		}
+		result.Sent++
+	}
+	return result, nil
+}
+
+func digestText(rows []models.AlertDigestRow) string {
+	if len(rows) == 0 {
+		return "Nenhum alerta nas últimas 24 horas."
+	}
+	var b strings.Builder
+	b.WriteString("Tipo | novos (24h) | ativos | críticos\n")
+	for _, row := range rows {
+		fmt.Fprintf(&b, "%s | %d | %d | %d\n", row.Type, row.Raised, row.Active, row.Critical)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func digestColor(rows []models.AlertDigestRow) string {
+	severity := models.AlertSeverityInfo
+	for _, row := range rows {
+		if row.Critical > 0 {
+			return chatSeverityColor[models.AlertSeverityCritical]
+		}
+		if row.Active > 0 {
+			severity = models.AlertSeverityWarning
+		}
+	}
+	return chatSeverityColor[severity]
+}
+
+// post sends a message through the incoming webhook of the channel, or the Slack bot
+// token when it has no webhook
+func (s *chatService) post(channel *models.ChatChannel, title, text, color string) error {
+	var payload interface{}
+	switch channel.Provider {
+	case models.ChatProviderTeams:
+		payload = map[string]interface{}{
+			"@type":      "MessageCard",
+			"@context":   "https://schema.org/extensions",
+			"summary":    title,
+			"themeColor": color,
+			"title":      title,
+			"text":       strings.ReplaceAll(text, "\n", "  \n"), // markdown line breaks
+		}
+	default:
+		message := map[string]interface{}{
+			"text": title,
+			"attachments": []map[string]interface{}{
+				{"color": "#" + color, "text": text},
+			},
+		}
+		if channel.WebhookURL == "" {
+			message["channel"] = channel.SlackChannel
+			return s.postSlackBot(channel.BotToken, message)
+		}
+		payload = message
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = postWebhook(s.client, channel.WebhookURL, "", "application/json", body)
+	return err
+}
+
+// postSlackBot calls chat.postMessage, which answers 200 with ok=false on errors
+func (s *chatService) postSlackBot(token string, message map[string]interface{}) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, slackPostMessageURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&result); err != nil {
+		return fmt.Errorf("slack returned %s", resp.Status)
+	}
+	if !result.OK {
+		return fmt.Errorf("slack returned %s", result.Error)
+	}
+	return nil
+}
+
+func (s *chatService) Start(hour int) {
+	if hour < 0 || hour > 23 {
+		hour = 8
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				today := now.Format("2006-01-02")
+				if now.Hour() != hour || s.lastDigest == today {
+					continue
+				}
+				s.lastDigest = today
+				result, err := s.SendDigest()
+				if err != nil {
+					slog.Warn("Chat digest failed", "error", err)
+					continue
+				}
+				for _, msg := range result.Errors {
+					slog.Warn("Chat digest not delivered", "error", msg)
+				}
+				if result.Sent > 0 {
+					slog.Info("Posted the daily alert digest to chat channels", "sent", result.Sent)
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (s *chatService) Stop() {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+	}
+}
@@ -0,0 +1,293 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrChecklistTemplateNotFound = errors.New("category has no checklist template")
+	ErrChecklistCategoryNotFound = errors.New("ticket category not found")
+	ErrChecklistTicketNotFound   = errors.New("ticket not found")
+	ErrChecklistItemNotFound     = errors.New("checklist item not found")
+	ErrChecklistDuplicateItem    = errors.New("checklist item listed more than once")
+	ErrChecklistIncomplete       = errors.New("mandatory checklist items pending")
+)
+
+// ChecklistService keeps the checklist templates of the ticket categories and the
+// checklists of the tickets. A ticket gets the steps of its category's template the first
+// time its checklist is read or checked, so templates apply to open tickets too.
+type ChecklistService interface {
+	ListTemplates() ([]models.ChecklistTemplate, error)
+	GetTemplate(categoryID string) (*models.ChecklistTemplate, error)
+	SetTemplate(req *models.SetChecklistTemplateRequest) (*models.ChecklistTemplate, error)
+	DeleteTemplate(categoryID string) error
+
+	GetTicketChecklist(ticketID string) (*models.TicketChecklist, error)
+	UpdateItem(ticketID, itemID string, req *models.UpdateChecklistItemRequest, userID string) (*models.TicketChecklistItem, error)
+	// CheckClosure fails with ErrChecklistIncomplete when the ticket moves to
+	// PARA_FECHAMENTO or FECHADO with mandatory steps pending and its template blocks closure
+	CheckClosure(ticket *models.Ticket, to models.TicketStatus) error
+	// WithContext returns the service running its queries with ctx, inside the tenant it carries
+	WithContext(ctx context.Context) ChecklistService
+}
+
+type checklistService struct {
+	repo         repositories.ChecklistRepository
+	ticketRepo   repositories.TicketRepository
+	categoryRepo repositories.CategoryRepository
+}
+
+func NewChecklistService(repo repositories.ChecklistRepository, ticketRepo repositories.TicketRepository, categoryRepo repositories.CategoryRepository) ChecklistService {
+	return &checklistService{repo: repo, ticketRepo: ticketRepo, categoryRepo: categoryRepo}
+}
+
+func (s *checklistService) WithContext(ctx context.Context) ChecklistService {
+	scoped := *s
+	scoped.repo = s.repo.WithContext(ctx)
+	scoped.ticketRepo = s.ticketRepo.WithContext(ctx)
+	scoped.categoryRepo = s.categoryRepo.WithContext(ctx)
+	return &scoped
+}
+
+// =============== Templates ===============
+
+func (s *checklistService) ListTemplates() ([]models.ChecklistTemplate, error) {
+	return s.repo.FindTemplates()
+}
+
+func (s *checklistService) GetTemplate(categoryID string) (*models.ChecklistTemplate, error) {
+	template, err := s.repo.FindTemplateByCategory(categoryID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrChecklistTemplateNotFound
+		}
+		return nil, err
+	}
+	return template, nil
+}
+
+// SetTemplate creates or replaces the template of the category. Items keep their ID while
+// their label is unchanged, so the steps already ticked on tickets stay matched.
+func (s *checklistService) SetTemplate(req *models.SetChecklistTemplateRequest) (*models.ChecklistTemplate, error) {
+	category, err := s.categoryRepo.GetByID(req.CategoryID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrChecklistCategoryNotFound
+		}
+		return nil, err
+	}
+	if category.Type != models.CategoryTypeTicket {
+		return nil, ErrChecklistCategoryNotFound
+	}
+
+	template, err := s.repo.FindTemplateByCategory(req.CategoryID)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		template = &models.ChecklistTemplate{CategoryID: req.CategoryID}
+	}
+	existing := make(map[string]string, len(template.Items))
+	for _, item := range template.Items {
+		existing[checklistLabelKey(item.Label)] = item.ID
+	}
+
+	template.Name = strings.TrimSpace(req.Name)
+	template.BlockClosure = req.BlockClosure == nil || *req.BlockClosure
+	items := make([]models.ChecklistTemplateItem, 0, len(req.Items))
+	seen := make(map[string]bool, len(req.Items))
+	for i, input := range req.Items {
+		label := strings.TrimSpace(input.Label)
+		key := checklistLabelKey(label)
+		if seen[key] {
+			return nil, fmt.Errorf("%w: %s", ErrChecklistDuplicateItem, label)
+		}
+		seen[key] = true
+		items = append(items, models.ChecklistTemplateItem{
+			ID:          existing[key],
+			Label:       label,
+			Description: input.Description,
+			Mandatory:   input.Mandatory,
+			SortOrder:   i,
+		})
+	}
+	template.Items = items
+
+	if err := s.repo.SaveTemplate(template); err != nil {
+		return nil, err
+	}
+	return s.GetTemplate(req.CategoryID)
+}
+
+func checklistLabelKey(label string) string {
+	return strings.ToLower(strings.TrimSpace(label))
+}
+
+// DeleteTemplate drops the template of the category; the checklists of its tickets keep
+// the steps already done and lose the others
+func (s *checklistService) DeleteTemplate(categoryID string) error {
+	template, err := s.GetTemplate(categoryID)
+	if err != nil {
+		return err
+	}
+	return s.repo.DeleteTemplate(template)
+}
+
+// =============== Ticket checklists ===============
+
+func (s *checklistService) GetTicketChecklist(ticketID string) (*models.TicketChecklist, error) {
+	ticket, err := s.ticketRepo.FindByID(ticketID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrChecklistTicketNotFound
+		}
+		return nil, err
+	}
+	return s.checklist(ticket)
+}
+
+func (s *checklistService) UpdateItem(ticketID, itemID string, req *models.UpdateChecklistItemRequest, userID string) (*models.TicketChecklistItem, error) {
+	// Reading the checklist brings it up to date with the template first
+	checklist, err := s.GetTicketChecklist(ticketID)
+	if err != nil {
+		return nil, err
+	}
+	var item *models.TicketChecklistItem
+	for i := range checklist.Items {
+		if checklist.Items[i].ID == itemID {
+			item = &checklist.Items[i]
+			break
+		}
+	}
+	if item == nil {
+		return nil, ErrChecklistItemNotFound
+	}
+
+	if req.Done && !item.Done {
+		now := time.Now()
+		item.DoneAt = &now
+		item.DoneBy = &userID
+	} else if !req.Done {
+		item.DoneAt = nil
+		item.DoneBy = nil
+	}
+	item.Done = req.Done
+	item.Notes = req.Notes
+	if err := s.repo.UpdateTicketItem(item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (s *checklistService) CheckClosure(ticket *models.Ticket, to models.TicketStatus) error {
+	if to != models.TicketStatusForClosing && to != models.TicketStatusClosed {
+		return nil
+	}
+	checklist, err := s.checklist(ticket)
+	if err != nil {
+		return err
+	}
+	if !checklist.BlockClosure || checklist.Complete {
+		return nil
+	}
+	pending := make([]string, 0, checklist.MandatoryPending)
+	for _, item := range checklist.Items {
+		if item.Mandatory && !item.Done {
+			pending = append(pending, item.Label)
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrChecklistIncomplete, strings.Join(pending, ", "))
+}
+
+// checklist brings the checklist of the ticket in line with the template of its category
+// and returns it. Steps not done follow the template: new ones are added, edited ones
+// updated and removed ones dropped. Done steps are never touched.
+func (s *checklistService) checklist(ticket *models.Ticket) (*models.TicketChecklist, error) {
+	items, err := s.repo.FindTicketItems(ticket.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var template *models.ChecklistTemplate
+	if ticket.CategoryID != nil {
+		template, err = s.repo.FindTemplateByCategory(*ticket.CategoryID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+
+	templateItems := map[string]models.ChecklistTemplateItem{}
+	if template != nil {
+		for _, item := range template.Items {
+			templateItems[item.ID] = item
+		}
+	}
+	var create, update []models.TicketChecklistItem
+	var deleteIDs []string
+	present := make(map[string]bool, len(items))
+	for _, item := range items {
+		present[item.TemplateItemID] = true
+		if item.Done {
+			continue
+		}
+		source, ok := templateItems[item.TemplateItemID]
+		if !ok {
+			deleteIDs = append(deleteIDs, item.ID)
+			continue
+		}
+		if item.Label != source.Label || item.Description != source.Description ||
+			item.Mandatory != source.Mandatory || item.SortOrder != source.SortOrder {
+			item.Label, item.Description = source.Label, source.Description
+			item.Mandatory, item.SortOrder = source.Mandatory, source.SortOrder
+			update = append(update, item)
+		}
+	}
+	if template != nil {
+		for _, source := range template.Items {
+			if present[source.ID] {
+				continue
+			}
+			create = append(create, models.TicketChecklistItem{
+				TicketID:       ticket.ID,
+				TemplateItemID: source.ID,
+				Label:          source.Label,
+				Description:    source.Description,
+				Mandatory:      source.Mandatory,
+				SortOrder:      source.SortOrder,
+			})
+		}
+	}
+
+	if len(create) > 0 || len(update) > 0 || len(deleteIDs) > 0 {
+		if err := s.repo.SyncTicketItems(create, update, deleteIDs); err != nil {
+			return nil, err
+		}
+		if items, err = s.repo.FindTicketItems(ticket.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	checklist := &models.TicketChecklist{TicketID: ticket.ID, Items: items}
+	if checklist.Items == nil {
+		checklist.Items = []models.TicketChecklistItem{}
+	}
+	if template != nil {
+		checklist.TemplateID = &template.ID
+		checklist.BlockClosure = template.BlockClosure
+	}
+	for _, item := range checklist.Items {
+		if item.Mandatory && !item.Done {
+			checklist.MandatoryPending++
+		}
+	}
+	checklist.Complete = checklist.MandatoryPending == 0
+	return checklist, nil
+}
@@ -0,0 +1,592 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrClientDocumentNotFound     = errors.New("client document not found")
+	ErrClientDocumentClient       = errors.New("client not found")
+	ErrDocumentCategoryNotFound   = errors.New("document category not found")
+	ErrDocumentCategoryInactive   = errors.New("document category is inactive")
+	ErrDocumentCategoryExists     = errors.New("document category name already exists")
+	ErrDocumentExpiryRequired     = errors.New("documents of this category need an expiry date")
+	ErrDocumentInvalidDate        = errors.New("invalid date, expected YYYY-MM-DD")
+	ErrDocumentInfected           = errors.New("document was rejected by the antivirus")
+	ErrDocumentDownloadForbidden  = errors.New("your role cannot download documents of this category")
+	ErrDocumentLinkNotFound       = errors.New("download link not found")
+	ErrDocumentLinkUnavailable    = errors.New("download link expired, revoked or used up")
+	ErrDocumentExpiresBeforeIssue = errors.New("expiry date must not be before the issue date")
+)
+
+const (
+	defaultDocumentReminderInterval = 6 * time.Hour
+	defaultDocumentLinkHours        = 24
+)
+
+// ClientDocumentConfig configures where vault files are stored and how they are scanned
+type ClientDocumentConfig struct {
+	UploadDir     string
+	ClamAVAddress string // host:port of clamd, empty disables scanning
+}
+
+// ClientDocumentService keeps the document vault of the clients: categorized files with
+// expiry dates, reminders before they expire and role-checked downloads and share links
+type ClientDocumentService interface {
+	ListCategories(activeOnly bool) ([]models.ClientDocumentCategory, error)
+	CreateCategory(req *models.ClientDocumentCategoryRequest) (*models.ClientDocumentCategory, error)
+	UpdateCategory(id uint, req *models.ClientDocumentCategoryRequest) (*models.ClientDocumentCategory, error)
+
+	List(page, size int, filters *models.ClientDocumentFilters) (*models.PaginatedResponse, error)
+	Get(clientID, documentID string) (*models.ClientDocument, error)
+	Upload(clientID, userID string, req *models.ClientDocumentRequest, header *multipart.FileHeader) (*models.ClientDocument, error)
+	Update(clientID, documentID, userID string, req *models.ClientDocumentRequest) (*models.ClientDocument, error)
+	Delete(clientID, documentID, userID string) error
+	// Open checks the role may download the document and returns it with its path on disk
+	Open(clientID, documentID, userID, role string) (*models.ClientDocument, string, error)
+
+	ListLinks(clientID, documentID string) ([]models.ClientDocumentLink, error)
+	CreateLink(clientID, documentID, userID, role string, req *models.CreateDocumentLinkRequest) (*models.DocumentLinkResponse, error)
+	RevokeLink(clientID, documentID, linkID, userID string) error
+	// OpenLink counts a download through a public link and returns the document with its path
+	OpenLink(token, ip string) (*models.ClientDocument, string, error)
+
+	// SendReminders e-mails the admins about the documents entering their reminder window
+	SendReminders() (*models.DocumentReminderResult, error)
+	Start(interval time.Duration)
+	Stop()
+}
+
+type clientDocumentService struct {
+	repo               repositories.ClientDocumentRepository
+	clientRepo         repositories.ClientRepository
+	userRepo           repositories.UserRepository
+	storageService     StorageService
+	activityLogService ActivityLogService
+	notifier           MessageSender
+	config             ClientDocumentConfig
+	scanner            *clamAVScanner
+	stop               chan struct{}
+	done               chan struct{}
+}
+
+func NewClientDocumentService(
+	repo repositories.ClientDocumentRepository,
+	clientRepo repositories.ClientRepository,
+	userRepo repositories.UserRepository,
+	storageService StorageService,
+	activityLogService ActivityLogService,
+	notifier MessageSender,
+	config ClientDocumentConfig,
+) ClientDocumentService {
+	if config.UploadDir == "" {
+		config.UploadDir = "./uploads"
+	}
+	svc := &clientDocumentService{
+		repo:               repo,
+		clientRepo:         clientRepo,
+		userRepo:           userRepo,
+		storageService:     storageService,
+		activityLogService: activityLogService,
+		notifier:           notifier,
+		config:             config,
+	}
+	if config.ClamAVAddress != "" {
+		svc.scanner = &clamAVScanner{address: config.ClamAVAddress}
+	}
+	return svc
+}
+
+// =============== Categories ===============
+
+func (s *clientDocumentService) ListCategories(activeOnly bool) ([]models.ClientDocumentCategory, error) {
+	return s.repo.FindCategories(activeOnly)
+}
+
+func (s *clientDocumentService) CreateCategory(req *models.ClientDocumentCategoryRequest) (*models.ClientDocumentCategory, error) {
+	category := &models.ClientDocumentCategory{ReminderDays: 30, DownloadRoles: "ADMIN,EMPLOYEE", Active: true}
+	if err := s.checkCategoryName(req.Name, category.ID); err != nil {
+		return nil, err
+	}
+	applyCategoryRequest(category, req)
+	if err := s.repo.SaveCategory(category); err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+func (s *clientDocumentService) UpdateCategory(id uint, req *models.ClientDocumentCategoryRequest) (*models.ClientDocumentCategory, error) {
+	category, err := s.repo.FindCategoryByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDocumentCategoryNotFound
+		}
+		return nil, err
+	}
+	if err := s.checkCategoryName(req.Name, category.ID); err != nil {
+		return nil, err
+	}
+	applyCategoryRequest(category, req)
+	if err := s.repo.SaveCategory(category); err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+func (s *clientDocumentService) checkCategoryName(name string, currentID uint) error {
+	categories, err := s.repo.FindCategories(false)
+	if err != nil {
+		return err
+	}
+	for _, c := range categories {
+		if c.ID != currentID && strings.EqualFold(c.Name, strings.TrimSpace(name)) {
+			return ErrDocumentCategoryExists
+		}
+	}
+	return nil
+}
+
+func applyCategoryRequest(category *models.ClientDocumentCategory, req *models.ClientDocumentCategoryRequest) {
+	category.Name = strings.TrimSpace(req.Name)
+	category.Description = strings.TrimSpace(req.Description)
+	category.RequiresExpiry = req.RequiresExpiry
+	if req.ReminderDays != nil {
+		category.ReminderDays = *req.ReminderDays
+	}
+	if len(req.DownloadRoles) > 0 {
+		category.DownloadRoles = strings.Join(req.DownloadRoles, ",")
+	}
+	if req.Active != nil {
+		category.Active = *req.Active
+	}
+}
+
+// =============== Documents ===============
+
+func (s *clientDocumentService) List(page, size int, filters *models.ClientDocumentFilters) (*models.PaginatedResponse, error) {
+	now := time.Now()
+	documents, total, err := s.repo.FindDocuments(page, size, filters, now)
+	if err != nil {
+		return nil, err
+	}
+	for i := range documents {
+		documents[i].Status = documents[i].ExpiryStatus(now)
+	}
+
+	return models.NewPaginatedResponse(documents, page, size, total), nil
+}
+
+func (s *clientDocumentService) Get(clientID, documentID string) (*models.ClientDocument, error) {
+	document, err := s.repo.FindDocumentByID(documentID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrClientDocumentNotFound
+		}
+		return nil, err
+	}
+	if document.ClientID != clientID {
+		return nil, ErrClientDocumentNotFound
+	}
+	document.Status = document.ExpiryStatus(time.Now())
+	return document, nil
+}
+
+func (s *clientDocumentService) Upload(clientID, userID string, req *models.ClientDocumentRequest, header *multipart.FileHeader) (*models.ClientDocument, error) {
+	client, err := s.clientRepo.GetByID(clientID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrClientDocumentClient
+		}
+		return nil, err
+	}
+	if header.Size > maxAttachmentSize {
+		return nil, ErrAttachmentTooLarge
+	}
+
+	document := &models.ClientDocument{
+		ClientID:   client.ID,
+		FileName:   filepath.Base(header.Filename),
+		FileType:   header.Header.Get("Content-Type"),
+		FileSize:   header.Size,
+		UploadedBy: userID,
+	}
+	if err := s.applyDocumentRequest(document, req); err != nil {
+		return nil, err
+	}
+	if document.Title == "" {
+		document.Title = document.FileName
+	}
+	if s.storageService != nil {
+		if err := s.storageService.CheckQuota(nil, models.StorageModuleClientDocuments, header.Size); err != nil {
+			return nil, err
+		}
+	}
+
+	src, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	dir := filepath.Join(s.config.UploadDir, models.StorageModuleClientDocuments, client.ID)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	// The ID is set up front so it can be used as the file name
+	document.BeforeCreate(nil)
+	document.FilePath = filepath.Join(dir, document.ID+strings.ToLower(filepath.Ext(document.FileName)))
+
+	dst, err := os.OpenFile(document.FilePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(document.FilePath)
+		return nil, err
+	}
+	dst.Close()
+
+	// Documents are shared outside the company, so they are scanned before being stored
+	document.ScanResult = "SKIPPED"
+	if s.scanner != nil {
+		result, infected, err := s.scanner.Scan(document.FilePath)
+		if err != nil {
+			os.Remove(document.FilePath)
+			return nil, fmt.Errorf("antivirus scan: %w", err)
+		}
+		if infected {
+			os.Remove(document.FilePath)
+			return nil, ErrDocumentInfected
+		}
+		document.ScanResult = result
+	}
+
+	if err := s.repo.CreateDocument(document); err != nil {
+		os.Remove(document.FilePath)
+		return nil, err
+	}
+	s.audit(userID, "client_document_uploaded", document, "Documento anexado ao cliente "+client.FullName)
+	return s.Get(client.ID, document.ID)
+}
+
+func (s *clientDocumentService) Update(clientID, documentID, userID string, req *models.ClientDocumentRequest) (*models.ClientDocument, error) {
+	document, err := s.Get(clientID, documentID)
+	if err != nil {
+		return nil, err
+	}
+	previousExpiry := document.ExpiresAt
+	if err := s.applyDocumentRequest(document, req); err != nil {
+		return nil, err
+	}
+	// A renewed expiry date gets its own reminder
+	if !sameDate(previousExpiry, document.ExpiresAt) {
+		document.ReminderSentAt = nil
+	}
+	if err := s.repo.UpdateDocument(document); err != nil {
+		return nil, err
+	}
+	s.audit(userID, "client_document_updated", document, "Documento do cliente atualizado")
+	return s.Get(clientID, documentID)
+}
+
+func (s *clientDocumentService) Delete(clientID, documentID, userID string) error {
+	document, err := s.Get(clientID, documentID)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.DeleteDocument(document.ID); err != nil {
+		return err
+	}
+	os.Remove(document.FilePath)
+	s.audit(userID, "client_document_deleted", document, "Documento do cliente removido")
+	return nil
+}
+
+// applyDocumentRequest validates the category and the dates and sets them on the document
+func (s *clientDocumentService) applyDocumentRequest(document *models.ClientDocument, req *models.ClientDocumentRequest) error {
+	category, err := s.repo.FindCategoryByID(req.CategoryID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrDocumentCategoryNotFound
+		}
+		return err
+	}
+	if !category.Active && category.ID != document.CategoryID {
+		return ErrDocumentCategoryInactive
+	}
+
+	issuedAt, err := parseDocumentDate(req.IssuedAt)
+	if err != nil {
+		return err
+	}
+	expiresAt, err := parseDocumentDate(req.ExpiresAt)
+	if err != nil {
+		return err
+	}
+	if expiresAt == nil && category.RequiresExpiry {
+		return ErrDocumentExpiryRequired
+	}
+	if issuedAt != nil && expiresAt != nil && expiresAt.Before(*issuedAt) {
+		return ErrDocumentExpiresBeforeIssue
+	}
+
+	document.CategoryID = category.ID
+	document.Category = category
+	if title := strings.TrimSpace(req.Title); title != "" {
+		document.Title = title
+	}
+	document.IssuedAt = issuedAt
+	document.ExpiresAt = expiresAt
+	document.Notes = strings.TrimSpace(req.Notes)
+	return nil
+}
+
+func (s *clientDocumentService) Open(clientID, documentID, userID, role string) (*models.ClientDocument, string, error) {
+	document, err := s.Get(clientID, documentID)
+	if err != nil {
+		return nil, "", err
+	}
+	if document.Category == nil || !document.Category.AllowsDownload(role) {
+		return nil, "", ErrDocumentDownloadForbidden
+	}
+	s.audit(userID, "client_document_downloaded", document, "Documento do cliente baixado")
+	return document, document.FilePath, nil
+}
+
+// =============== Links ===============
+
+func (s *clientDocumentService) ListLinks(clientID, documentID string) ([]models.ClientDocumentLink, error) {
+	if _, err := s.Get(clientID, documentID); err != nil {
+		return nil, err
+	}
+	return s.repo.FindLinks(documentID)
+}
+
+func (s *clientDocumentService) CreateLink(clientID, documentID, userID, role string, req *models.CreateDocumentLinkRequest) (*models.DocumentLinkResponse, error) {
+	document, err := s.Get(clientID, documentID)
+	if err != nil {
+		return nil, err
+	}
+	if document.Category == nil || !document.Category.AllowsDownload(role) {
+		return nil, ErrDocumentDownloadForbidden
+	}
+
+	hours := req.ExpiresInHours
+	if hours <= 0 {
+		hours = defaultDocumentLinkHours
+	}
+	token, err := generateDocumentToken()
+	if err != nil {
+		return nil, err
+	}
+	link := &models.ClientDocumentLink{
+		DocumentID:   document.ID,
+		Token:        token,
+		ExpiresAt:    time.Now().Add(time.Duration(hours) * time.Hour),
+		MaxDownloads: req.MaxDownloads,
+		CreatedBy:    userID,
+	}
+	if err := s.repo.CreateLink(link); err != nil {
+		return nil, err
+	}
+	s.audit(userID, "client_document_link_created", document,
+		fmt.Sprintf("Link de download do documento criado, válido até %s", link.ExpiresAt.Format("02/01/2006 15:04")))
+
+	return &models.DocumentLinkResponse{
+		ClientDocumentLink: *link,
+		Token:              token,
+		URL:                "/api/v1/public/client-documents/" + token,
+	}, nil
+}
+
+func (s *clientDocumentService) RevokeLink(clientID, documentID, linkID, userID string) error {
+	document, err := s.Get(clientID, documentID)
+	if err != nil {
+		return err
+	}
+	link, err := s.repo.FindLinkByID(linkID)
+	if err != nil || link.DocumentID != document.ID {
+		return ErrDocumentLinkNotFound
+	}
+	if err := s.repo.RevokeLink(link.ID, time.Now()); err != nil {
+		return err
+	}
+	s.audit(userID, "client_document_link_revoked", document, "Link de download do documento revogado")
+	return nil
+}
+
+func (s *clientDocumentService) OpenLink(token, ip string) (*models.ClientDocument, string, error) {
+	link, err := s.repo.FindLinkByToken(token)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", ErrDocumentLinkNotFound
+		}
+		return nil, "", err
+	}
+	if link.Document == nil {
+		return nil, "", ErrDocumentLinkNotFound
+	}
+	used, err := s.repo.UseLink(link.ID, time.Now())
+	if err != nil {
+		return nil, "", err
+	}
+	if !used {
+		return nil, "", ErrDocumentLinkUnavailable
+	}
+	s.audit(link.CreatedBy, "client_document_link_downloaded", link.Document, "Documento baixado pelo link público (IP "+ip+")")
+	return link.Document, link.Document.FilePath, nil
+}
+
+// =============== Reminders ===============
+
+func (s *clientDocumentService) SendReminders() (*models.DocumentReminderResult, error) {
+	result := &models.DocumentReminderResult{}
+	now := time.Now()
+	documents, err := s.repo.FindDueReminders(now)
+	if err != nil {
+		return nil, err
+	}
+	if len(documents) == 0 {
+		return result, nil
+	}
+	if s.notifier == nil || s.userRepo == nil {
+		return result, nil
+	}
+	admins, err := s.userRepo.FindByRole("ADMIN")
+	if err != nil {
+		return nil, err
+	}
+
+	var body strings.Builder
+	body.WriteString("Os documentos de clientes abaixo estão vencidos ou vencem em breve:\n\n")
+	ids := make([]string, 0, len(documents))
+	for _, d := range documents {
+		client := d.ClientID
+		if d.Client != nil {
+			client = d.Client.FullName
+		}
+		category := ""
+		if d.Category != nil {
+			category = d.Category.Name
+		}
+		fmt.Fprintf(&body, "- %s (%s) — %s: vence em %s\n", d.Title, category, client, d.ExpiresAt.Format("02/01/2006"))
+		ids = append(ids, d.ID)
+	}
+	subject := fmt.Sprintf("%d documento(s) de clientes a vencer", len(documents))
+
+	for _, admin := range admins {
+		if !admin.Active || admin.Email == "" {
+			continue
+		}
+		if err := s.notifier.Send(admin.Email, subject, body.String()); err != nil {
+			if errors.Is(err, ErrMessagingNotConfigured) {
+				// Keep the documents due so they are reminded once e-mail is set up
+				return result, nil
+			}
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", admin.Email, err))
+			continue
+		}
+		result.Recipients++
+	}
+	if result.Recipients == 0 {
+		return result, nil
+	}
+
+	if err := s.repo.MarkReminded(ids, now); err != nil {
+		return nil, err
+	}
+	result.Documents = len(ids)
+	return result, nil
+}
+
+// Start sends the expiry reminders periodically until Stop is called
+func (s *clientDocumentService) Start(interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultDocumentReminderInterval
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				result, err := s.SendReminders()
+				if err != nil {
+					slog.Warn("Client document reminders failed", "error", err)
+					continue
+				}
+				for _, e := range result.Errors {
+					slog.Warn("Client document reminder not delivered", "error", e)
+				}
+				if result.Documents > 0 {
+					slog.Info("Reminded users of expiring client documents", "recipients", result.Recipients, "documents", result.Documents)
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (s *clientDocumentService) Stop() {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+		s.stop = nil
+	}
+}
+
+func (s *clientDocumentService) audit(userID, action string, document *models.ClientDocument, description string) {
+	if s.activityLogService == nil {
+		return
+	}
+	description = fmt.Sprintf("%s: %s", description, document.Title)
+	if err := s.activityLogService.LogAction(userID, action, "client_document", document.ID, description, "", ""); err != nil {
+		slog.Warn("Failed to audit client document", "document_id", document.ID, "error", err)
+	}
+}
+
+func parseDocumentDate(s string) (*time.Time, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	t, err := time.Parse("2006-01-02", strings.TrimSpace(s))
+	if err != nil {
+		return nil, ErrDocumentInvalidDate
+	}
+	return &t, nil
+}
+
+func sameDate(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Format("2006-01-02") == b.Format("2006-01-02")
+}
+
+func generateDocumentToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
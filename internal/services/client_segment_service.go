@@ -0,0 +1,292 @@
+package services
+
+import (
+	"errors"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrClientTagNotFound    = errors.New("client tag not found")
+	ErrClientTagNameTaken   = errors.New("a client tag with this name already exists")
+	ErrClientNotFound       = errors.New("client not found")
+	ErrInvalidClientSegment = errors.New("invalid client segment, expected DIMENSION:VALUE with dimension REVENUE_TIER, TICKET_VOLUME or CHURN_RISK and value HIGH, MEDIUM or LOW")
+)
+
+const defaultClientSegmentInterval = 24 * time.Hour
+
+// Segmentation rules
+const (
+	segmentRevenueMonths      = 12
+	segmentRevenueHighShare   = 0.2 // top 20% of the clients with income are HIGH
+	segmentRevenueMediumShare = 0.5 // the next 30% are MEDIUM
+	segmentTicketDays         = 90
+	segmentTicketsHigh        = 10
+	segmentTicketsMedium      = 3
+	segmentChurnHighDays      = 180 // no ticket for this long, or a detractor answer (0-6)
+	segmentChurnMediumDays    = 90  // no ticket for this long, or a passive answer (7-8)
+)
+
+type ClientSegmentService interface {
+	ListTags() ([]models.ClientTag, error)
+	CreateTag(req *models.ClientTagRequest) (*models.ClientTag, error)
+	UpdateTag(id string, req *models.ClientTagRequest) (*models.ClientTag, error)
+	DeleteTag(id string) error
+
+	GetClient(clientID string) (*models.ClientSegmentation, error)
+	SetClientTags(clientID string, req *models.SetClientTagsRequest) (*models.ClientSegmentation, error)
+
+	Summary() (*models.ClientSegmentSummary, error)
+	// Recalculate recomputes the segment membership of every client
+	Recalculate() (*models.ClientSegmentSummary, error)
+	Start(interval time.Duration)
+	Stop()
+}
+
+type clientSegmentService struct {
+	repo       repositories.ClientSegmentRepository
+	clientRepo repositories.ClientRepository
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+func NewClientSegmentService(repo repositories.ClientSegmentRepository, clientRepo repositories.ClientRepository) ClientSegmentService {
+	return &clientSegmentService{repo: repo, clientRepo: clientRepo}
+}
+
+func (s *clientSegmentService) ListTags() ([]models.ClientTag, error) {
+	return s.repo.FindTags()
+}
+
+func (s *clientSegmentService) CreateTag(req *models.ClientTagRequest) (*models.ClientTag, error) {
+	tag := &models.ClientTag{}
+	if err := s.applyTag(tag, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateTag(tag); err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+func (s *clientSegmentService) UpdateTag(id string, req *models.ClientTagRequest) (*models.ClientTag, error) {
+	tag, err := s.getTag(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.applyTag(tag, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateTag(tag); err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+func (s *clientSegmentService) DeleteTag(id string) error {
+	if _, err := s.getTag(id); err != nil {
+		return err
+	}
+	return s.repo.DeleteTag(id)
+}
+
+func (s *clientSegmentService) getTag(id string) (*models.ClientTag, error) {
+	tag, err := s.repo.FindTagByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrClientTagNotFound
+	}
+	return tag, err
+}
+
+// applyTag copies the request onto the tag; names are unique regardless of case
+func (s *clientSegmentService) applyTag(tag *models.ClientTag, req *models.ClientTagRequest) error {
+	name := strings.TrimSpace(req.Name)
+	existing, err := s.repo.FindTagByName(name)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if existing != nil && existing.ID != tag.ID {
+		return ErrClientTagNameTaken
+	}
+	tag.Name = name
+	tag.Color = strings.ToUpper(req.Color)
+	return nil
+}
+
+func (s *clientSegmentService) GetClient(clientID string) (*models.ClientSegmentation, error) {
+	if _, err := s.clientRepo.GetByID(clientID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrClientNotFound
+		}
+		return nil, err
+	}
+
+	tags, err := s.repo.FindClientTags(clientID)
+	if err != nil {
+		return nil, err
+	}
+	segments, err := s.repo.FindClientSegments(clientID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.ClientSegmentation{ClientID: clientID, Tags: tags, Segments: segments}, nil
+}
+
+func (s *clientSegmentService) SetClientTags(clientID string, req *models.SetClientTagsRequest) (*models.ClientSegmentation, error) {
+	if _, err := s.clientRepo.GetByID(clientID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrClientNotFound
+		}
+		return nil, err
+	}
+
+	tagIDs := make([]string, 0, len(req.TagIDs))
+	seen := make(map[string]bool, len(req.TagIDs))
+	for _, id := range req.TagIDs {
+		if !seen[id] {
+			seen[id] = true
+			tagIDs = append(tagIDs, id)
+		}
+	}
+	if len(tagIDs) > 0 {
+		found, err := s.repo.CountTags(tagIDs)
+		if err != nil {
+			return nil, err
+		}
+		if found != int64(len(tagIDs)) {
+			return nil, ErrClientTagNotFound
+		}
+	}
+
+	if err := s.repo.SetClientTags(clientID, tagIDs); err != nil {
+		return nil, err
+	}
+	return s.GetClient(clientID)
+}
+
+func (s *clientSegmentService) Summary() (*models.ClientSegmentSummary, error) {
+	counts, computedAt, err := s.repo.CountSegments()
+	if err != nil {
+		return nil, err
+	}
+	return &models.ClientSegmentSummary{ComputedAt: computedAt, Segments: counts}, nil
+}
+
+func (s *clientSegmentService) Recalculate() (*models.ClientSegmentSummary, error) {
+	now := time.Now()
+	metrics, err := s.repo.SegmentMetrics(now.AddDate(0, -segmentRevenueMonths, 0), now.AddDate(0, 0, -segmentTicketDays))
+	if err != nil {
+		return nil, err
+	}
+
+	segments := make([]models.ClientSegment, 0, len(metrics)*3)
+	tiers := revenueTiers(metrics)
+	for _, m := range metrics {
+		segments = append(segments,
+			models.ClientSegment{ClientID: m.ClientID, Dimension: models.SegmentRevenueTier, Value: tiers[m.ClientID], Metric: m.Revenue, ComputedAt: now},
+			models.ClientSegment{ClientID: m.ClientID, Dimension: models.SegmentTicketVolume, Value: ticketVolume(m.Tickets), Metric: float64(m.Tickets), ComputedAt: now},
+		)
+		// Clients that never opened a ticket have nothing to churn from
+		if m.LastTicketAt != nil {
+			days := now.Sub(*m.LastTicketAt).Hours() / 24
+			segments = append(segments, models.ClientSegment{
+				ClientID: m.ClientID, Dimension: models.SegmentChurnRisk, Value: churnRisk(days, m.LastNPSScore), Metric: float64(int(days)), ComputedAt: now,
+			})
+		}
+	}
+
+	if err := s.repo.ReplaceSegments(segments); err != nil {
+		return nil, err
+	}
+	return s.Summary()
+}
+
+// revenueTiers ranks the clients with income: the top share is HIGH, the next MEDIUM and
+// the rest, along with the clients without income, LOW
+func revenueTiers(metrics []models.ClientSegmentMetrics) map[string]string {
+	tiers := make(map[string]string, len(metrics))
+	earning := make([]models.ClientSegmentMetrics, 0, len(metrics))
+	for _, m := range metrics {
+		tiers[m.ClientID] = models.SegmentLow
+		if m.Revenue > 0 {
+			earning = append(earning, m)
+		}
+	}
+	sort.Slice(earning, func(i, j int) bool { return earning[i].Revenue > earning[j].Revenue })
+
+	for i, m := range earning {
+		rank := float64(i) / float64(len(earning))
+		switch {
+		case rank < segmentRevenueHighShare:
+			tiers[m.ClientID] = models.SegmentHigh
+		case rank < segmentRevenueMediumShare:
+			tiers[m.ClientID] = models.SegmentMedium
+		}
+	}
+	return tiers
+}
+
+func ticketVolume(tickets int64) string {
+	switch {
+	case tickets >= segmentTicketsHigh:
+		return models.SegmentHigh
+	case tickets >= segmentTicketsMedium:
+		return models.SegmentMedium
+	default:
+		return models.SegmentLow
+	}
+}
+
+func churnRisk(daysSinceTicket float64, npsScore *int) string {
+	switch {
+	case daysSinceTicket >= segmentChurnHighDays, npsScore != nil && *npsScore <= 6:
+		return models.SegmentHigh
+	case daysSinceTicket >= segmentChurnMediumDays, npsScore != nil && *npsScore <= 8:
+		return models.SegmentMedium
+	default:
+		return models.SegmentLow
+	}
+}
+
+// Start recomputes the segments right away and then periodically until Stop is called
+func (s *clientSegmentService) Start(interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultClientSegmentInterval
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			summary, err := s.Recalculate()
+			if err != nil {
+				slog.Warn("Client segmentation failed", "error", err)
+			} else {
+				slog.Info("Client segments recalculated", "count", len(summary.Segments))
+			}
+
+			select {
+			case <-ticker.C:
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (s *clientSegmentService) Stop() {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+		s.stop = nil
+	}
+}
@@ -0,0 +1,340 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"gorm.io/gorm"
+)
+
+// complaintSLA is the time the ombudsman has to resolve a complaint, tighter than service tickets
+var complaintSLA = map[models.TicketPriority]time.Duration{
+	models.TicketPriorityUrgent: 24 * time.Hour,
+	models.TicketPriorityHigh:   48 * time.Hour,
+	models.TicketPriorityNormal: 72 * time.Hour,
+	models.TicketPriorityLow:    120 * time.Hour,
+}
+
+// Each founded complaint per 100 closed tickets costs this many performance points
+const complaintScorePenalty = 10
+
+var (
+	ErrComplaintNotFound              = errors.New("complaint not found")
+	ErrComplaintOriginalNotFound      = errors.New("original ticket not found")
+	ErrComplaintOriginalIsComplaint   = errors.New("a complaint must reference a service ticket")
+	ErrComplaintTechnicianNotOnTicket = errors.New("technician is not assigned to the original ticket")
+	ErrComplaintInvalidRootCause      = errors.New("invalid root cause")
+	ErrComplaintAlreadyClosed         = errors.New("complaint is already closed")
+)
+
+type ComplaintService interface {
+	Create(userID string, req *models.CreateComplaintRequest) (*models.ComplaintDTO, error)
+	List(page, size int, filters *models.ComplaintFilters) (*models.PaginatedResponse, error)
+	Get(ticketID string) (*models.ComplaintDTO, error)
+	Classify(ticketID, userID string, req *models.ClassifyComplaintRequest) (*models.ComplaintDTO, error)
+	Close(ticketID, userID string, req *models.ClassifyComplaintRequest) (*models.ComplaintDTO, error)
+	GetReport(from, to time.Time) (*models.ComplaintReport, error)
+}
+
+type complaintService struct {
+	repo          repositories.ComplaintRepository
+	ticketService TicketService
+}
+
+func NewComplaintService(repo repositories.ComplaintRepository, ticketService TicketService) ComplaintService {
+	return &complaintService{
+		repo:          repo,
+		ticketService: ticketService,
+	}
+}
+
+// Create opens a complaint ticket linked to the original service ticket and the technician
+// who handled it (the lead, unless another assignee is given)
+func (s *complaintService) Create(userID string, req *models.CreateComplaintRequest) (*models.ComplaintDTO, error) {
+	original, err := s.ticketService.GetByID(req.OriginalTicketID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrComplaintOriginalNotFound
+		}
+		return nil, err
+	}
+	if original.Type == models.TicketTypeComplaint {
+		return nil, ErrComplaintOriginalIsComplaint
+	}
+
+	technicianID, err := complaintTechnician(original, req.TechnicianID)
+	if err != nil {
+		return nil, err
+	}
+
+	priority := models.TicketPriority(req.Priority)
+	if priority == "" {
+		priority = models.TicketPriorityNormal
+	}
+	now := time.Now()
+	dueAt := now.Add(complaintSLA[priority])
+
+	source := strings.ToUpper(strings.TrimSpace(req.Source))
+	ticket := &models.Ticket{
+		Type:             models.TicketTypeComplaint,
+		Status:           models.TicketStatusOpen,
+		Priority:         priority,
+		Source:           models.TicketSource(source),
+		ErrorDescription: strings.TrimSpace(req.Description),
+		NodeID:           original.NodeID,
+		ClientID:         original.ClientID,
+		CategoryID:       original.CategoryID,
+		ComputerBrand:    original.ComputerBrand,
+		ComputerModel:    original.ComputerModel,
+		SerialNumber:     original.SerialNumber,
+		StartDate:        &now,
+		DueDate:          &dueAt,
+	}
+	complaint := &models.TicketComplaint{
+		OriginalTicketID: original.ID,
+		TechnicianID:     technicianID,
+		Source:           source,
+		SLADueAt:         dueAt,
+		CreatedBy:        userID,
+	}
+	// The complaint source is free text; only a known channel is kept on the ticket
+	if !ticket.Source.IsValid() {
+		ticket.Source = models.TicketSourcePortal
+	}
+	if err := s.repo.Create(ticket, complaint, userID); err != nil {
+		return nil, err
+	}
+	return s.Get(ticket.ID)
+}
+
+func complaintTechnician(original *models.Ticket, requested string) (*string, error) {
+	if requested == "" {
+		for _, a := range original.Assignments {
+			if a.Role == models.AssignmentRoleLead {
+				id := a.TechnicianID
+				return &id, nil
+			}
+		}
+		return nil, nil
+	}
+	for _, a := range original.Assignments {
+		if a.TechnicianID == requested {
+			return &requested, nil
+		}
+	}
+	return nil, ErrComplaintTechnicianNotOnTicket
+}
+
+func (s *complaintService) List(page, size int, filters *models.ComplaintFilters) (*models.PaginatedResponse, error) {
+	tickets, total, err := s.repo.FindAll(page, size, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	dtos := make([]models.ComplaintDTO, len(tickets))
+	for i := range tickets {
+		dtos[i] = toComplaintDTO(&tickets[i], tickets[i].Complaint, now)
+	}
+
+	return models.NewPaginatedResponse(dtos, page, size, total), nil
+}
+
+func (s *complaintService) Get(ticketID string) (*models.ComplaintDTO, error) {
+	ticket, complaint, err := s.find(ticketID)
+	if err != nil {
+		return nil, err
+	}
+	dto := toComplaintDTO(ticket, complaint, time.Now())
+	return &dto, nil
+}
+
+// Classify records the root cause; it can be revised until the complaint is closed
+func (s *complaintService) Classify(ticketID, userID string, req *models.ClassifyComplaintRequest) (*models.ComplaintDTO, error) {
+	ticket, complaint, err := s.find(ticketID)
+	if err != nil {
+		return nil, err
+	}
+	if ticket.Status == models.TicketStatusClosed {
+		return nil, ErrComplaintAlreadyClosed
+	}
+
+	rootCause := models.ComplaintRootCause(strings.ToUpper(req.RootCause))
+	if !rootCause.IsValid() {
+		return nil, ErrComplaintInvalidRootCause
+	}
+
+	now := time.Now()
+	complaint.RootCause = rootCause
+	complaint.RootCauseNotes = strings.TrimSpace(req.Notes)
+	complaint.ClassifiedBy = userID
+	complaint.ClassifiedAt = &now
+	if err := s.repo.Update(complaint); err != nil {
+		return nil, err
+	}
+	return s.Get(ticketID)
+}
+
+// Close classifies the complaint and closes its ticket in one step
+func (s *complaintService) Close(ticketID, userID string, req *models.ClassifyComplaintRequest) (*models.ComplaintDTO, error) {
+	if _, err := s.Classify(ticketID, userID, req); err != nil {
+		return nil, err
+	}
+	if err := s.ticketService.UpdateStatus(ticketID, string(models.TicketStatusClosed)); err != nil {
+		return nil, err
+	}
+	return s.Get(ticketID)
+}
+
+func (s *complaintService) find(ticketID string) (*models.Ticket, *models.TicketComplaint, error) {
+	ticket, err := s.ticketService.GetByID(ticketID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrComplaintNotFound
+		}
+		return nil, nil, err
+	}
+	if ticket.Type != models.TicketTypeComplaint {
+		return nil, nil, ErrComplaintNotFound
+	}
+
+	complaint, err := s.repo.FindByTicketID(ticketID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrComplaintNotFound
+		}
+		return nil, nil, err
+	}
+	return ticket, complaint, nil
+}
+
+func toComplaintDTO(ticket *models.Ticket, complaint *models.TicketComplaint, now time.Time) models.ComplaintDTO {
+	dto := models.ComplaintDTO{
+		TicketDTO: ticket.ToDTO(),
+		Complaint: complaint,
+	}
+	if complaint == nil {
+		return dto
+	}
+	if complaint.OriginalTicket != nil {
+		dto.OriginalOSNumber = complaint.OriginalTicket.OSNumber
+		// The original ticket is linked by ID; avoid nesting it in full
+		complaint.OriginalTicket = nil
+	}
+	if complaint.Technician != nil {
+		dto.TechnicianName = complaint.Technician.FullName
+		complaint.Technician = nil
+	}
+	dto.SLABreached = complaint.SLABreached(now)
+	return dto
+}
+
+// GetReport summarizes the complaints opened in [from, to) per technician, category and root cause
+func (s *complaintService) GetReport(from, to time.Time) (*models.ComplaintReport, error) {
+	complaints, err := s.repo.FindCreatedBetween(from, to)
+	if err != nil {
+		return nil, err
+	}
+	closedTickets, err := s.repo.CountClosedTicketsByTechnician(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.ComplaintReport{
+		From:        from,
+		To:          to,
+		Total:       len(complaints),
+		ByRootCause: make(map[models.ComplaintRootCause]int),
+	}
+	byTechnician := make(map[string]*models.TechnicianComplaintStats)
+	byCategory := make(map[string]*models.CategoryComplaintStats)
+	now := time.Now()
+
+	for _, complaint := range complaints {
+		founded := complaint.RootCause.Founded()
+		switch {
+		case complaint.ResolvedAt == nil:
+			report.Open++
+		case founded:
+			report.Founded++
+		case complaint.RootCause == models.RootCauseUnfounded:
+			report.Unfounded++
+		}
+		if complaint.SLABreached(now) {
+			report.SLABreached++
+		}
+		if complaint.RootCause != "" {
+			report.ByRootCause[complaint.RootCause]++
+		}
+
+		if complaint.TechnicianID != nil {
+			stats := byTechnician[*complaint.TechnicianID]
+			if stats == nil {
+				stats = &models.TechnicianComplaintStats{TechnicianID: *complaint.TechnicianID}
+				if complaint.Technician != nil {
+					stats.TechnicianName = complaint.Technician.FullName
+				}
+				byTechnician[*complaint.TechnicianID] = stats
+			}
+			stats.Complaints++
+			if founded {
+				stats.Founded++
+			}
+		}
+
+		var categoryID, categoryName string
+		if complaint.OriginalTicket != nil && complaint.OriginalTicket.Category != nil {
+			categoryID, categoryName = complaint.OriginalTicket.Category.ID, complaint.OriginalTicket.Category.Name
+		}
+		stats := byCategory[categoryID]
+		if stats == nil {
+			stats = &models.CategoryComplaintStats{CategoryID: categoryID, CategoryName: categoryName}
+			byCategory[categoryID] = stats
+		}
+		stats.Complaints++
+		if founded {
+			stats.Founded++
+		}
+	}
+
+	report.ByTechnician = make([]models.TechnicianComplaintStats, 0, len(byTechnician))
+	for id, stats := range byTechnician {
+		stats.ClosedTickets = closedTickets[id]
+		stats.ComplaintRate, stats.PerformanceScore = complaintPerformance(stats.Founded, stats.ClosedTickets)
+		report.ByTechnician = append(report.ByTechnician, *stats)
+	}
+	sort.Slice(report.ByTechnician, func(i, j int) bool {
+		if report.ByTechnician[i].PerformanceScore != report.ByTechnician[j].PerformanceScore {
+			return report.ByTechnician[i].PerformanceScore < report.ByTechnician[j].PerformanceScore
+		}
+		return report.ByTechnician[i].TechnicianName < report.ByTechnician[j].TechnicianName
+	})
+
+	report.ByCategory = make([]models.CategoryComplaintStats, 0, len(byCategory))
+	for _, stats := range byCategory {
+		report.ByCategory = append(report.ByCategory, *stats)
+	}
+	sort.Slice(report.ByCategory, func(i, j int) bool {
+		return report.ByCategory[i].Complaints > report.ByCategory[j].Complaints
+	})
+
+	return report, nil
+}
+
+// complaintPerformance returns the founded complaints per 100 closed tickets and the
+// resulting performance score (100 minus the penalty, floored at 0)
+func complaintPerformance(founded int, closedTickets int64) (float64, float64) {
+	if founded == 0 {
+		return 0, 100
+	}
+	// A complaint without closed tickets in the period weighs as one per ticket
+	tickets := math.Max(float64(closedTickets), float64(founded))
+	rate := float64(founded) * 100 / tickets
+	score := math.Max(0, 100-rate*complaintScorePenalty)
+	return math.Round(rate*10) / 10, math.Round(score*10) / 10
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+)
+
+const (
+	defaultComplianceDays = 30
+	maxComplianceDays     = 365
+)
+
+var ErrComplianceInvalidDays = errors.New("days must be between 0 and 365")
+
+// ComplianceService aggregates the tracked expirations of the modules into one report per
+// responsible node. Client documents (contracts, insurance certificates, authorizations) are
+// the only expirations tracked so far.
+type ComplianceService interface {
+	GetExpiryReport(days int) (*models.ExpiryComplianceReport, error)
+}
+
+type complianceService struct {
+	repo repositories.ComplianceRepository
+}
+
+func NewComplianceService(repo repositories.ComplianceRepository) ComplianceService {
+	return &complianceService{repo: repo}
+}
+
+// GetExpiryReport lists what expires within the next days (default 30), already expired
+// included, grouped by node; the expired and the nodes with most of them come first
+func (s *complianceService) GetExpiryReport(days int) (*models.ExpiryComplianceReport, error) {
+	if days == 0 {
+		days = defaultComplianceDays
+	}
+	if days < 0 || days > maxComplianceDays {
+		return nil, ErrComplianceInvalidDays
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	expirations, err := s.repo.FindClientDocumentExpirations(today.AddDate(0, 0, days))
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.ExpiryComplianceReport{
+		GeneratedAt: now,
+		Days:        days,
+		Groups:      make([]models.ComplianceNodeGroup, 0),
+	}
+	groups := make(map[uint]int) // node ID -> index in report.Groups
+	unassigned := -1
+	for _, e := range expirations {
+		expiresAt := time.Date(e.ExpiresAt.Year(), e.ExpiresAt.Month(), e.ExpiresAt.Day(), 0, 0, 0, 0, now.Location())
+		e.DaysLeft = int(math.Round(expiresAt.Sub(today).Hours() / 24))
+		e.Status = models.DocumentStatusExpiring
+		if e.DaysLeft < 0 {
+			e.Status = models.DocumentStatusExpired
+		}
+
+		var i int
+		var ok bool
+		if e.NodeID == nil {
+			if unassigned < 0 {
+				unassigned = len(report.Groups)
+				report.Groups = append(report.Groups, models.ComplianceNodeGroup{NodeName: "Sem área"})
+			}
+			i = unassigned
+		} else if i, ok = groups[*e.NodeID]; !ok {
+			i = len(report.Groups)
+			groups[*e.NodeID] = i
+			report.Groups = append(report.Groups, models.ComplianceNodeGroup{NodeID: e.NodeID, NodeName: ptrToString(e.NodeName)})
+		}
+
+		group := &report.Groups[i]
+		group.Expirations = append(group.Expirations, e)
+		if e.Status == models.DocumentStatusExpired {
+			group.Expired++
+			report.Expired++
+		} else {
+			group.Expiring++
+			report.Expiring++
+		}
+		report.Total++
+	}
+
+	sort.SliceStable(report.Groups, func(a, b int) bool {
+		ga, gb := report.Groups[a], report.Groups[b]
+		if ga.Expired != gb.Expired {
+			return ga.Expired > gb.Expired
+		}
+		return len(ga.Expirations) > len(gb.Expirations)
+	})
+	return report, nil
+}
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCoverageAreaNotFound     = errors.New("coverage area not found")
+	ErrCoverageOwnerNotFound    = errors.New("coverage owner not found")
+	ErrCoverageOwnerNotBranch   = errors.New("stock location is not a BRANCH")
+	ErrCoverageNoMunicipalities = errors.New("a MUNICIPALITIES area needs at least one municipality")
+	ErrCoverageInvalidPolygon   = errors.New("a POLYGON area needs at least 3 valid points")
+	ErrCoverageClientNotFound   = errors.New("client not found")
+	ErrCoverageSiteRequired     = errors.New("give clientId, city and state, or lat and lng")
+	ErrClientOutOfCoverage      = errors.New("client site is outside every coverage area")
+)
+
+const coverageNoAreasNote = "no coverage area defined"
+
+// CoverageService manages the serviceable areas of branches and technicians and checks
+// whether a client site is covered
+type CoverageService interface {
+	List(filters *models.CoverageAreaFilters) ([]models.CoverageArea, error)
+	Get(id string) (*models.CoverageArea, error)
+	Create(userID string, req *models.CoverageAreaRequest) (*models.CoverageArea, error)
+	Update(id string, req *models.CoverageAreaRequest) (*models.CoverageArea, error)
+	Delete(id string) error
+	Check(req *models.CoverageCheckRequest) (*models.CoverageCheckResult, error)
+	// ClientWarning explains why a client site is out of coverage; empty when covered
+	// or when no coverage area is defined yet
+	ClientWarning(clientID string) (string, error)
+}
+
+type coverageService struct {
+	repo           repositories.CoverageRepository
+	clientRepo     repositories.ClientRepository
+	technicianRepo repositories.TechnicianRepository
+	stockRepo      repositories.StockRepository
+}
+
+func NewCoverageService(
+	repo repositories.CoverageRepository,
+	clientRepo repositories.ClientRepository,
+	technicianRepo repositories.TechnicianRepository,
+	stockRepo repositories.StockRepository,
+) CoverageService {
+	return &coverageService{
+		repo:           repo,
+		clientRepo:     clientRepo,
+		technicianRepo: technicianRepo,
+		stockRepo:      stockRepo,
+	}
+}
+
+func (s *coverageService) List(filters *models.CoverageAreaFilters) ([]models.CoverageArea, error) {
+	return s.repo.FindAll(filters)
+}
+
+func (s *coverageService) Get(id string) (*models.CoverageArea, error) {
+	area, err := s.repo.FindByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCoverageAreaNotFound
+		}
+		return nil, err
+	}
+	return area, nil
+}
+
+func (s *coverageService) Create(userID string, req *models.CoverageAreaRequest) (*models.CoverageArea, error) {
+	area := &models.CoverageArea{Active: true, CreatedBy: userID}
+	if err := s.applyArea(area, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(area); err != nil {
+		return nil, err
+	}
+	return area, nil
+}
+
+func (s *coverageService) Update(id string, req *models.CoverageAreaRequest) (*models.CoverageArea, error) {
+	area, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.applyArea(area, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Update(area); err != nil {
+		return nil, err
+	}
+	return area, nil
+}
+
+func (s *coverageService) Delete(id string) error {
+	if err := s.repo.Delete(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrCoverageAreaNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// applyArea validates the request and copies it into the area
+func (s *coverageService) applyArea(area *models.CoverageArea, req *models.CoverageAreaRequest) error {
+	if _, err := s.ownerName(req.OwnerType, req.OwnerID); err != nil {
+		return err
+	}
+
+	area.OwnerType = req.OwnerType
+	area.OwnerID = req.OwnerID
+	area.Name = strings.TrimSpace(req.Name)
+	area.Kind = req.Kind
+	area.Municipalities = models.MunicipalityList{}
+	area.Polygon = models.GeoPolygon{}
+	switch req.Kind {
+	case models.CoverageKindMunicipalities:
+		if len(req.Municipalities) == 0 {
+			return ErrCoverageNoMunicipalities
+		}
+		for _, m := range req.Municipalities {
+			area.Municipalities = append(area.Municipalities, models.Municipality{
+				City:  strings.TrimSpace(m.City),
+				State: strings.ToUpper(strings.TrimSpace(m.State)),
+			})
+		}
+	case models.CoverageKindPolygon:
+		if len(req.Polygon) < 3 {
+			return ErrCoverageInvalidPolygon
+		}
+		for _, p := range req.Polygon {
+			if p.Latitude < -90 || p.Latitude > 90 || p.Longitude < -180 || p.Longitude > 180 {
+				return ErrCoverageInvalidPolygon
+			}
+		}
+		area.Polygon = req.Polygon
+	}
+	if req.Active != nil {
+		area.Active = *req.Active
+	}
+	return nil
+}
+
+func (s *coverageService) ownerName(ownerType, ownerID string) (string, error) {
+	switch ownerType {
+	case models.CoverageOwnerBranch:
+		location, err := s.stockRepo.GetLocationByID(ownerID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return "", ErrCoverageOwnerNotFound
+			}
+			return "", err
+		}
+		if location.Type != models.LocationBranch {
+			return "", ErrCoverageOwnerNotBranch
+		}
+		return location.Name, nil
+	case models.CoverageOwnerTechnician:
+		technician, err := s.technicianRepo.FindByID(ownerID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return "", ErrCoverageOwnerNotFound
+			}
+			return "", err
+		}
+		return technician.FullName, nil
+	}
+	return "", ErrCoverageOwnerNotFound
+}
+
+// Check lists the active areas covering the site. Municipality areas match on city and
+// state; polygon areas need coordinates, given or taken from the known city coordinates.
+func (s *coverageService) Check(req *models.CoverageCheckRequest) (*models.CoverageCheckResult, error) {
+	result := &models.CoverageCheckResult{
+		City:      strings.TrimSpace(req.City),
+		State:     strings.ToUpper(strings.TrimSpace(req.State)),
+		Latitude:  req.Latitude,
+		Longitude: req.Longitude,
+		Matches:   []models.CoverageMatch{},
+	}
+	if req.ClientID != "" {
+		client, err := s.clientRepo.GetByID(req.ClientID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrCoverageClientNotFound
+			}
+			return nil, err
+		}
+		result.City, result.State = client.City, strings.ToUpper(client.State)
+	}
+	hasCoordinates := result.Latitude != nil && result.Longitude != nil
+	if result.City == "" && !hasCoordinates {
+		return nil, ErrCoverageSiteRequired
+	}
+	if !hasCoordinates {
+		if lat, lng, exact := GetCoordinatesForLocation(result.City, result.State); exact {
+			result.Latitude, result.Longitude = &lat, &lng
+			hasCoordinates = true
+		}
+	}
+
+	areas, err := s.repo.FindAll(&models.CoverageAreaFilters{})
+	if err != nil {
+		return nil, err
+	}
+
+	city := normalizePlace(result.City)
+	skippedPolygons := false
+	owners := make(map[string]string)
+	for _, area := range areas {
+		covered := false
+		switch area.Kind {
+		case models.CoverageKindMunicipalities:
+			for _, m := range area.Municipalities {
+				if normalizePlace(m.City) == city && strings.EqualFold(m.State, result.State) {
+					covered = true
+					break
+				}
+			}
+		case models.CoverageKindPolygon:
+			if !hasCoordinates {
+				skippedPolygons = true
+				continue
+			}
+			covered = area.Polygon.Contains(*result.Latitude, *result.Longitude)
+		}
+		if !covered {
+			continue
+		}
+
+		key := area.OwnerType + ":" + area.OwnerID
+		name, ok := owners[key]
+		if !ok {
+			name, _ = s.ownerName(area.OwnerType, area.OwnerID)
+			owners[key] = name
+		}
+		result.Matches = append(result.Matches, models.CoverageMatch{
+			AreaID:    area.ID,
+			AreaName:  area.Name,
+			Kind:      area.Kind,
+			OwnerType: area.OwnerType,
+			OwnerID:   area.OwnerID,
+			OwnerName: name,
+		})
+	}
+
+	result.Covered = len(result.Matches) > 0
+	switch {
+	case len(areas) == 0:
+		result.Note = coverageNoAreasNote
+	case !result.Covered && skippedPolygons:
+		result.Note = "site coordinates unknown, polygon areas were not checked"
+	}
+	return result, nil
+}
+
+func (s *coverageService) ClientWarning(clientID string) (string, error) {
+	result, err := s.Check(&models.CoverageCheckRequest{ClientID: clientID})
+	if err != nil {
+		if errors.Is(err, ErrCoverageSiteRequired) {
+			return "", nil // client without address, nothing to check
+		}
+		return "", err
+	}
+	if result.Covered || result.Note == coverageNoAreasNote {
+		return "", nil
+	}
+	return fmt.Sprintf("%s/%s is outside every coverage area", result.City, result.State), nil
+}
+
+// normalizePlace compares city names regardless of case and accents ("São Paulo" = "sao paulo")
+func normalizePlace(s string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	out, _, err := transform.String(t, s)
+	if err != nil {
+		out = s
+	}
+	return strings.ToLower(strings.TrimSpace(out))
+}
@@ -1,39 +1,122 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"time"
 
+	"github.com/redis/go-redis/v9"
+	"github.com/shigake/tech-iq-back/internal/cache"
 	"github.com/shigake/tech-iq-back/internal/models"
 	"github.com/shigake/tech-iq-back/internal/repositories"
+	"github.com/shigake/tech-iq-back/internal/tenant"
 )
 
+// DashboardService computes the dashboard aggregates. GetStats and GetTicketsByStatus
+// are cached for a short while and dropped when tickets, technicians or clients change
+// (see DashboardCacheInvalidator); fresh skips the cache. The cache is kept per tenant.
 type DashboardService interface {
-	GetStats() (*models.DashboardStats, error)
-	GetTicketsByStatus() ([]models.TicketsByStatus, error)
+	GetStats(fresh bool) (*models.DashboardStats, error)
+	GetTicketsByStatus(fresh bool) ([]models.TicketsByStatus, error)
+	GetTicketsBySource() ([]models.TicketsBySource, error)
 	GetTechniciansByState() ([]models.TechniciansByState, error)
 	GetRecentActivity(limit int) ([]models.RecentActivity, error)
+	// WithContext returns the service computing the dashboards of the tenant carried by ctx
+	WithContext(ctx context.Context) DashboardService
 }
 
 type dashboardService struct {
 	technicianRepo repositories.TechnicianRepository
 	ticketRepo     repositories.TicketRepository
 	clientRepo     repositories.ClientRepository
+	cache          *cache.RedisClient
+	tenantID       string // the tenant of the cached dashboards
 }
 
 func NewDashboardService(
 	technicianRepo repositories.TechnicianRepository,
 	ticketRepo repositories.TicketRepository,
 	clientRepo repositories.ClientRepository,
+	cache *cache.RedisClient,
 ) DashboardService {
 	return &dashboardService{
 		technicianRepo: technicianRepo,
 		ticketRepo:     ticketRepo,
 		clientRepo:     clientRepo,
+		cache:          cache,
+		tenantID:       tenant.DefaultID,
 	}
 }
 
-func (s *dashboardService) GetStats() (*models.DashboardStats, error) {
+func (s *dashboardService) WithContext(ctx context.Context) DashboardService {
+	scoped := *s
+	scoped.technicianRepo = s.technicianRepo.WithContext(ctx)
+	scoped.ticketRepo = s.ticketRepo.WithContext(ctx)
+	scoped.clientRepo = s.clientRepo.WithContext(ctx)
+	if tenantID, ok := tenant.FromContext(ctx); ok {
+		scoped.tenantID = tenantID
+	}
+	return &scoped
+}
+
+// DashboardCacheInvalidator returns the change hook dropping the cached dashboards
+// computed from the changed table, in the tenant of the change. Changes made without a
+// tenant (jobs, seeds, financial entries) drop the dashboards of every tenant.
+func DashboardCacheInvalidator(redisClient *cache.RedisClient) func(ctx context.Context, table string) {
+	return func(ctx context.Context, table string) {
+		tenantID, ok := tenant.FromContext(ctx)
+		drop := redisClient.Delete
+		if !ok {
+			tenantID, drop = cache.AllTenants, redisClient.DeletePattern
+		}
+
+		var err error
+		switch table {
+		case "tickets":
+			err = errors.Join(drop(cache.DashboardCacheKey(tenantID)), drop(cache.DashboardChartCacheKey(tenantID)))
+		case "technicians", "clients":
+			err = drop(cache.DashboardCacheKey(tenantID))
+		case "financial_entries":
+			err = redisClient.DeletePattern(cache.FinancialDashboardPattern(tenantID))
+		default:
+			return
+		}
+		if err != nil && !errors.Is(err, cache.ErrCacheDegraded) {
+			slog.Warn("Failed to invalidate dashboard cache", "table", table, "error", err)
+		}
+	}
+}
+
+// cached reads key from the cache into dest, reporting whether it was there
+func cached(redisClient *cache.RedisClient, key string, dest interface{}) bool {
+	if redisClient == nil {
+		return false
+	}
+	err := redisClient.Get(key, dest)
+	if err != nil && err != redis.Nil && !errors.Is(err, cache.ErrCacheDegraded) {
+		slog.Debug("Cache error", "cache_key", key, "error", err)
+	}
+	return err == nil
+}
+
+// setCached keeps value in the cache for ttl; failures only cost a cache miss
+func setCached(redisClient *cache.RedisClient, key string, value interface{}, ttl time.Duration) {
+	if redisClient == nil {
+		return
+	}
+	if err := redisClient.Set(key, value, ttl); err != nil && !errors.Is(err, cache.ErrCacheDegraded) {
+		slog.Warn("Failed to cache result", "cache_key", key, "error", err)
+	}
+}
+
+func (s *dashboardService) GetStats(fresh bool) (*models.DashboardStats, error) {
+	var stats models.DashboardStats
+	if !fresh && cached(s.cache, cache.DashboardCacheKey(s.tenantID), &stats) {
+		return &stats, nil
+	}
+
 	totalTechnicians, _ := s.technicianRepo.CountAll()
 	activeTechnicians, _ := s.technicianRepo.CountByStatus("ATIVO")
 	totalTickets, _ := s.ticketRepo.CountAll()
@@ -41,8 +124,9 @@ func (s *dashboardService) GetStats() (*models.DashboardStats, error) {
 	inProgressTickets, _ := s.ticketRepo.CountByStatus("EM_ATENDIMENTO")
 	closedTickets, _ := s.ticketRepo.CountByStatus("FECHADO")
 	totalClients, _ := s.clientRepo.Count()
+	bySource, _ := s.GetTicketsBySource()
 
-	return &models.DashboardStats{
+	stats = models.DashboardStats{
 		TotalTechnicians:  totalTechnicians,
 		ActiveTechnicians: activeTechnicians,
 		TotalTickets:      totalTickets,
@@ -50,11 +134,41 @@ func (s *dashboardService) GetStats() (*models.DashboardStats, error) {
 		InProgressTickets: inProgressTickets,
 		ClosedTickets:     closedTickets,
 		TotalClients:      totalClients,
-	}, nil
+		TicketsBySource:   bySource,
+	}
+	setCached(s.cache, cache.DashboardCacheKey(s.tenantID), stats, cache.DashboardTTL)
+	return &stats, nil
+}
+
+func (s *dashboardService) GetTicketsByStatus(fresh bool) ([]models.TicketsByStatus, error) {
+	var data []models.TicketsByStatus
+	if !fresh && cached(s.cache, cache.DashboardChartCacheKey(s.tenantID), &data) {
+		return data, nil
+	}
+	data, err := s.ticketRepo.GroupByStatus()
+	if err != nil {
+		return nil, err
+	}
+	setCached(s.cache, cache.DashboardChartCacheKey(s.tenantID), data, cache.DashboardTTL)
+	return data, nil
 }
 
-func (s *dashboardService) GetTicketsByStatus() ([]models.TicketsByStatus, error) {
-	return s.ticketRepo.GroupByStatus()
+// GetTicketsBySource counts the tickets of every source channel, zero included
+func (s *dashboardService) GetTicketsBySource() ([]models.TicketsBySource, error) {
+	rows, err := s.ticketRepo.GroupBySource()
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Source] = row.Count
+	}
+
+	result := make([]models.TicketsBySource, 0, len(models.TicketSources))
+	for _, source := range models.TicketSources {
+		result = append(result, models.TicketsBySource{Source: string(source), Count: counts[string(source)]})
+	}
+	return result, nil
 }
 
 func (s *dashboardService) GetTechniciansByState() ([]models.TechniciansByState, error) {
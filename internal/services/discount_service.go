@@ -0,0 +1,393 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrDiscountNotFound       = errors.New("discount not found")
+	ErrDiscountNotPending     = errors.New("discount is not pending approval")
+	ErrDiscountSelfApproval   = errors.New("a discount cannot be approved by its requester")
+	ErrDiscountInvalidRole    = errors.New("invalid role")
+	ErrDiscountInvalidLimit   = errors.New("maxPercent must be between 0 and 100")
+	ErrDiscountInvalidAmount  = errors.New("grossAmount must be positive")
+	ErrDiscountInvalidValue   = errors.New("give either percent (0-100] or amount (0-grossAmount]")
+	ErrDiscountTicketNotFound = errors.New("ticket not found")
+	ErrDiscountUserNotFound   = errors.New("user not found")
+)
+
+var percentBase = decimal.NewFromInt(100)
+
+// DiscountService applies discounts on quotes and invoices within the role limits and
+// routes the ones beyond the limit to admin approval
+type DiscountService interface {
+	GetLimits() ([]models.DiscountLimit, error)
+	SetLimit(role, userID string, req *models.UpdateDiscountLimitRequest) (*models.DiscountLimit, error)
+	Create(userID string, req *models.CreateDiscountRequest) (*models.Discount, error)
+	Get(id string) (*models.Discount, error)
+	List(page, size int, filters *models.DiscountFilters) (*models.PaginatedResponse, error)
+	Approve(id, userID string, req *models.DiscountDecisionRequest) (*models.Discount, error)
+	Reject(id, userID string, req *models.DiscountDecisionRequest) (*models.Discount, error)
+	GetReport(from, to time.Time) (*models.DiscountReport, error)
+	// WithContext returns the service running its queries with ctx, inside the tenant it carries
+	WithContext(ctx context.Context) DiscountService
+}
+
+type discountService struct {
+	repo               repositories.DiscountRepository
+	userRepo           repositories.UserRepository
+	ticketRepo         repositories.TicketRepository
+	activityLogService ActivityLogService
+	notifier           MessageSender
+}
+
+func NewDiscountService(
+	repo repositories.DiscountRepository,
+	userRepo repositories.UserRepository,
+	ticketRepo repositories.TicketRepository,
+	activityLogService ActivityLogService,
+	notifier MessageSender,
+) DiscountService {
+	return &discountService{
+		repo:               repo,
+		userRepo:           userRepo,
+		ticketRepo:         ticketRepo,
+		activityLogService: activityLogService,
+		notifier:           notifier,
+	}
+}
+
+func (s *discountService) WithContext(ctx context.Context) DiscountService {
+	scoped := *s
+	scoped.userRepo = s.userRepo.WithContext(ctx)
+	scoped.ticketRepo = s.ticketRepo.WithContext(ctx)
+	return &scoped
+}
+
+// GetLimits returns the limit of every role, falling back to the defaults
+func (s *discountService) GetLimits() ([]models.DiscountLimit, error) {
+	stored, err := s.repo.FindLimits()
+	if err != nil {
+		return nil, err
+	}
+	byRole := make(map[string]models.DiscountLimit, len(stored))
+	for _, l := range stored {
+		byRole[l.Role] = l
+	}
+
+	limits := make([]models.DiscountLimit, 0, len(models.DefaultDiscountLimits))
+	for _, role := range []string{"ADMIN", "EMPLOYEE", "USER"} {
+		if l, ok := byRole[role]; ok {
+			limits = append(limits, l)
+		} else {
+			limits = append(limits, models.DiscountLimit{Role: role, MaxPercent: models.DefaultDiscountLimits[role]})
+		}
+	}
+	return limits, nil
+}
+
+func (s *discountService) SetLimit(role, userID string, req *models.UpdateDiscountLimitRequest) (*models.DiscountLimit, error) {
+	role = strings.ToUpper(role)
+	if _, ok := models.DefaultDiscountLimits[role]; !ok {
+		return nil, ErrDiscountInvalidRole
+	}
+	if req.MaxPercent.IsNegative() || req.MaxPercent.GreaterThan(percentBase) {
+		return nil, ErrDiscountInvalidLimit
+	}
+
+	limit := &models.DiscountLimit{
+		Role:       role,
+		MaxPercent: req.MaxPercent.Round(2),
+		UpdatedBy:  userID,
+		UpdatedAt:  time.Now(),
+	}
+	if err := s.repo.SaveLimit(limit); err != nil {
+		return nil, err
+	}
+	s.audit(userID, "discount_limit_updated", "discount_limit", role,
+		fmt.Sprintf("Limite de desconto do perfil %s: %s%%", role, limit.MaxPercent.StringFixed(2)))
+	return limit, nil
+}
+
+func (s *discountService) limitFor(role string) (decimal.Decimal, error) {
+	limits, err := s.GetLimits()
+	if err != nil {
+		return decimal.Zero, err
+	}
+	for _, l := range limits {
+		if l.Role == role {
+			return l.MaxPercent, nil
+		}
+	}
+	return decimal.Zero, nil
+}
+
+// Create records a discount; within the requester role limit it is applied right away,
+// beyond it stays PENDING until an admin decides
+func (s *discountService) Create(userID string, req *models.CreateDiscountRequest) (*models.Discount, error) {
+	if !req.GrossAmount.IsPositive() {
+		return nil, ErrDiscountInvalidAmount
+	}
+	gross := req.GrossAmount.Round(2)
+
+	var percent, amount decimal.Decimal
+	switch {
+	case req.Percent != nil && req.Amount == nil:
+		percent = req.Percent.Round(2)
+		amount = gross.Mul(percent).Div(percentBase).Round(2)
+	case req.Amount != nil && req.Percent == nil:
+		amount = req.Amount.Round(2)
+		percent = amount.Mul(percentBase).Div(gross).Round(2)
+	default:
+		return nil, ErrDiscountInvalidValue
+	}
+	if !amount.IsPositive() || amount.GreaterThan(gross) {
+		return nil, ErrDiscountInvalidValue
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDiscountUserNotFound
+		}
+		return nil, err
+	}
+	limit, err := s.limitFor(user.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	discount := &models.Discount{
+		DocumentType:  req.DocumentType,
+		DocumentRef:   strings.TrimSpace(req.DocumentRef),
+		TicketID:      req.TicketID,
+		GrossAmount:   gross,
+		Percent:       percent,
+		Amount:        amount,
+		Reason:        strings.TrimSpace(req.Reason),
+		Status:        models.DiscountStatusApproved,
+		RequestedBy:   user.ID,
+		RequesterRole: user.Role,
+		LimitPercent:  limit,
+	}
+	if req.TicketID != nil {
+		ticket, err := s.ticketRepo.FindByID(*req.TicketID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrDiscountTicketNotFound
+			}
+			return nil, err
+		}
+		discount.ClientID = ticket.ClientID
+	}
+	if discount.OverLimit() {
+		discount.Status = models.DiscountStatusPending
+	}
+
+	if err := s.repo.Create(discount); err != nil {
+		return nil, err
+	}
+	created, err := s.Get(discount.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if created.Status == models.DiscountStatusPending {
+		s.audit(userID, "discount_requested", "discount", created.ID,
+			fmt.Sprintf("Desconto de %s%% aguardando aprovação (limite %s%%) em %s", percent.StringFixed(2), limit.StringFixed(2), discountDocument(created)))
+		go s.notifyApprovers(*created)
+	} else {
+		s.audit(userID, "discount_applied", "discount", created.ID,
+			fmt.Sprintf("Desconto de %s%% aplicado em %s", percent.StringFixed(2), discountDocument(created)))
+	}
+	return created, nil
+}
+
+func (s *discountService) Get(id string) (*models.Discount, error) {
+	discount, err := s.repo.FindByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDiscountNotFound
+		}
+		return nil, err
+	}
+	return discount, nil
+}
+
+func (s *discountService) List(page, size int, filters *models.DiscountFilters) (*models.PaginatedResponse, error) {
+	discounts, total, err := s.repo.FindAll(page, size, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewPaginatedResponse(discounts, page, size, total), nil
+}
+
+// Approve applies a pending discount
+func (s *discountService) Approve(id, userID string, req *models.DiscountDecisionRequest) (*models.Discount, error) {
+	return s.decide(id, userID, req, models.DiscountStatusApproved)
+}
+
+// Reject refuses a pending discount; the document keeps its full price
+func (s *discountService) Reject(id, userID string, req *models.DiscountDecisionRequest) (*models.Discount, error) {
+	return s.decide(id, userID, req, models.DiscountStatusRejected)
+}
+
+func (s *discountService) decide(id, userID string, req *models.DiscountDecisionRequest, status string) (*models.Discount, error) {
+	discount, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if discount.Status != models.DiscountStatusPending {
+		return nil, ErrDiscountNotPending
+	}
+	if discount.RequestedBy == userID {
+		return nil, ErrDiscountSelfApproval
+	}
+
+	now := time.Now()
+	discount.Status = status
+	discount.DecidedBy = &userID
+	discount.DecidedAt = &now
+	if notes := strings.TrimSpace(req.Notes); notes != "" {
+		discount.DecisionNotes = &notes
+	}
+	ok, err := s.repo.Decide(discount)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrDiscountNotPending
+	}
+
+	decided, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if status == models.DiscountStatusApproved {
+		s.audit(userID, "discount_approved", "discount", id, "Desconto aprovado em "+discountDocument(decided))
+	} else {
+		s.audit(userID, "discount_rejected", "discount", id, "Desconto rejeitado em "+discountDocument(decided))
+	}
+	go s.notifyRequester(*decided)
+	return decided, nil
+}
+
+// GetReport returns the discounts given per user in [from, to)
+func (s *discountService) GetReport(from, to time.Time) (*models.DiscountReport, error) {
+	users, err := s.repo.SummarizeByUser(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.DiscountReport{
+		From:           from,
+		To:             to,
+		Users:          users,
+		GrossAmount:    decimal.Zero,
+		DiscountAmount: decimal.Zero,
+		AvgPercent:     decimal.Zero,
+	}
+	for i := range report.Users {
+		u := &report.Users[i]
+		u.AvgPercent = discountPercent(u.DiscountAmount, u.GrossAmount)
+		report.Applied += u.Applied
+		report.GrossAmount = report.GrossAmount.Add(u.GrossAmount)
+		report.DiscountAmount = report.DiscountAmount.Add(u.DiscountAmount)
+	}
+	report.AvgPercent = discountPercent(report.DiscountAmount, report.GrossAmount)
+	return report, nil
+}
+
+func discountPercent(amount, gross decimal.Decimal) decimal.Decimal {
+	if !gross.IsPositive() {
+		return decimal.Zero
+	}
+	return amount.Mul(percentBase).Div(gross).Round(2)
+}
+
+func (s *discountService) audit(userID, action, entityType, entityID, description string) {
+	if s.activityLogService == nil {
+		return
+	}
+	if err := s.activityLogService.LogAction(userID, action, entityType, entityID, description, "", ""); err != nil {
+		slog.Warn("Failed to audit discount", "entity_id", entityID, "error", err)
+	}
+}
+
+// notifyApprovers e-mails every admin but the requester about a pending discount
+func (s *discountService) notifyApprovers(discount models.Discount) {
+	if s.notifier == nil {
+		return
+	}
+	admins, err := s.userRepo.FindByRole("ADMIN")
+	if err != nil {
+		slog.Warn("Failed to load discount approvers", "error", err)
+		return
+	}
+
+	subject := "Desconto aguardando aprovação"
+	body := fmt.Sprintf("%s\n\nLimite do solicitante: %s%%\nMotivo: %s\nSolicitante: %s",
+		discountSummary(&discount), discount.LimitPercent.StringFixed(2), discount.Reason, discountRequesterName(&discount))
+	for _, admin := range admins {
+		if admin.ID == discount.RequestedBy || !admin.Active || admin.Email == "" {
+			continue
+		}
+		if err := s.notifier.Send(admin.Email, subject, body); err != nil && !errors.Is(err, ErrMessagingNotConfigured) {
+			slog.Warn("Failed to notify about discount", "email", admin.Email, "discount_id", discount.ID, "error", err)
+		}
+	}
+}
+
+// notifyRequester e-mails the requester the decision on their discount
+func (s *discountService) notifyRequester(discount models.Discount) {
+	if s.notifier == nil || discount.Requester == nil || discount.Requester.Email == "" {
+		return
+	}
+
+	decision := "aprovado"
+	if discount.Status == models.DiscountStatusRejected {
+		decision = "rejeitado"
+	}
+	subject := "Desconto " + decision
+	body := fmt.Sprintf("Seu desconto foi %s.\n\n%s", decision, discountSummary(&discount))
+	if discount.DecisionNotes != nil {
+		body += "\nObservações: " + *discount.DecisionNotes
+	}
+	if err := s.notifier.Send(discount.Requester.Email, subject, body); err != nil && !errors.Is(err, ErrMessagingNotConfigured) {
+		slog.Warn("Failed to notify about discount", "email", discount.Requester.Email, "discount_id", discount.ID, "error", err)
+	}
+}
+
+func discountDocument(discount *models.Discount) string {
+	document := "orçamento"
+	if discount.DocumentType == models.DiscountDocumentInvoice {
+		document = "fatura"
+	}
+	return document + " " + discount.DocumentRef
+}
+
+func discountSummary(discount *models.Discount) string {
+	return fmt.Sprintf("%s\nValor bruto: R$ %s\nDesconto: %s%% (R$ %s)",
+		discountDocument(discount), discount.GrossAmount.StringFixed(2), discount.Percent.StringFixed(2), discount.Amount.StringFixed(2))
+}
+
+func discountRequesterName(discount *models.Discount) string {
+	if discount.Requester != nil {
+		if discount.Requester.FullName != "" {
+			return discount.Requester.FullName
+		}
+		return discount.Requester.Email
+	}
+	return discount.RequestedBy
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"math"
+	"sort"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+)
+
+// DispatchScoreOptions controls the candidate filtering done by ScoreTechnicians
+type DispatchScoreOptions struct {
+	Weights        models.DispatchWeights
+	MaxRadiusKm    float64
+	MaxOpenTickets int
+	RequireSkill   bool
+	// ClientLocation replaces the coordinates of the client city, when the address is geocoded
+	ClientLocation *models.GeoPoint
+	// Positions are the live locations of the technicians; the others are placed at their city
+	Positions map[string]models.GeoPoint
+}
+
+// ScoreTechnicians ranks active technicians for a ticket. Each component is
+// normalized to [0,1] (skill match, proximity within the radius and spare
+// capacity) and combined using the given weights. Technicians outside the
+// radius, over capacity or without the required skill are left out.
+func ScoreTechnicians(ticket *models.Ticket, technicians []models.Technician, openTickets map[string]int, opts DispatchScoreOptions) []models.ScoredTechnician {
+	weights := opts.Weights
+	if weights.Skill+weights.Distance+weights.Workload <= 0 {
+		weights = models.DefaultDispatchWeights()
+	}
+
+	var skill string
+	if ticket.Category != nil {
+		skill = ticket.Category.Name
+	}
+
+	var clientCity, clientState string
+	if ticket.Client != nil {
+		clientCity, clientState = ticket.Client.City, ticket.Client.State
+	}
+	clientLat, clientLng, _ := GetCoordinatesForLocation(clientCity, clientState)
+	if opts.ClientLocation != nil {
+		clientLat, clientLng = opts.ClientLocation.Latitude, opts.ClientLocation.Longitude
+	}
+
+	scored := make([]models.ScoredTechnician, 0, len(technicians))
+	for _, t := range technicians {
+		if t.Status != "ATIVO" {
+			continue
+		}
+
+		skilled := skill != "" && hasSkill(t.Skills, skill)
+		if opts.RequireSkill && skill != "" && !skilled {
+			continue
+		}
+
+		open := openTickets[t.ID]
+		if opts.MaxOpenTickets > 0 && open >= opts.MaxOpenTickets {
+			continue
+		}
+
+		lat, lng, _ := GetCoordinatesForLocation(t.City, t.State)
+		source := models.LocationSourceBase
+		if position, ok := opts.Positions[t.ID]; ok {
+			lat, lng, source = position.Latitude, position.Longitude, models.LocationSourceLive
+		}
+		distanceKm := CalculateDistance(lat, lng, clientLat, clientLng) / 1000
+		if opts.MaxRadiusKm > 0 && ticket.Client != nil && distanceKm > opts.MaxRadiusKm {
+			continue
+		}
+
+		skillScore := 0.0
+		if skilled || skill == "" {
+			skillScore = 1
+		}
+		distanceScore := 1.0
+		if opts.MaxRadiusKm > 0 {
+			distanceScore = 1 - distanceKm/opts.MaxRadiusKm
+			if distanceScore < 0 {
+				distanceScore = 0
+			}
+		}
+		workloadScore := 1.0
+		if opts.MaxOpenTickets > 0 {
+			workloadScore = 1 - float64(open)/float64(opts.MaxOpenTickets)
+		} else {
+			workloadScore = 1 / float64(open+1)
+		}
+
+		total := weights.Skill*skillScore + weights.Distance*distanceScore + weights.Workload*workloadScore
+		total /= weights.Skill + weights.Distance + weights.Workload
+
+		scored = append(scored, models.ScoredTechnician{
+			TechnicianID:   t.ID,
+			TechnicianName: t.FullName,
+			Score:          math.Round(total*10000) / 10000,
+			DistanceKm:     math.Round(distanceKm*10) / 10,
+			OpenTickets:    open,
+			HasSkill:       skilled,
+			LocationSource: source,
+		})
+	}
+
+	sort.SliceStable(scored, func(i, j int) bool {
+		if scored[i].Score != scored[j].Score {
+			return scored[i].Score > scored[j].Score
+		}
+		return scored[i].DistanceKm < scored[j].DistanceKm
+	})
+	return scored
+}
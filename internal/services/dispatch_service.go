@@ -0,0 +1,431 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"github.com/shigake/tech-iq-back/internal/tenant"
+)
+
+var (
+	ErrDispatchRuleNotFound = errors.New("dispatch rule not found")
+	ErrInvalidDispatchRule  = errors.New("invalid dispatch rule")
+)
+
+const (
+	defaultDispatchInterval = time.Minute
+	// Tickets older than this are never picked up by the dispatcher, so enabling
+	// a rule does not reassign the historical backlog
+	dispatchLookback = 24 * time.Hour
+)
+
+type DispatchService interface {
+	ListRules() ([]models.DispatchRule, error)
+	CreateRule(req *models.CreateDispatchRuleRequest, userID string) (*models.DispatchRule, error)
+	UpdateRule(id string, req *models.CreateDispatchRuleRequest) (*models.DispatchRule, error)
+	DeleteRule(id string) error
+
+	GetDecisions(filter *models.DispatchDecisionFilter) (*models.PaginatedDispatchDecisions, error)
+	GetManualQueue() ([]models.Ticket, error)
+	Simulate(req *models.SimulateDispatchRequest) (*models.SimulateDispatchResponse, error)
+	SuggestTechnicians(ticketID string, query *models.SuggestTechniciansQuery) (*models.TechnicianSuggestions, error)
+
+	RunOnce() (*models.DispatchRunResult, error)
+	Start(interval time.Duration)
+	Stop()
+
+	// WithContext returns the service running its queries with ctx, inside the tenant it
+	// carries; its passes only dispatch the tickets of that tenant
+	WithContext(ctx context.Context) DispatchService
+}
+
+type dispatchService struct {
+	repo               repositories.DispatchRepository
+	ticketService      TicketService
+	technicianRepo     repositories.TechnicianRepository
+	activityLogService ActivityLogService
+	geo                *GeoService // live technician locations and client geocoding
+	tenants            repositories.TenantRepository
+	tenantID           string // set by WithContext, empty runs every active tenant
+
+	// mu serializes the passes of this process; other instances are kept from
+	// assigning the same ticket by AssignIfUnassigned
+	mu   *sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewDispatchService(
+	repo repositories.DispatchRepository,
+	ticketService TicketService,
+	technicianRepo repositories.TechnicianRepository,
+	activityLogService ActivityLogService,
+	geo *GeoService,
+	tenants repositories.TenantRepository,
+) DispatchService {
+	return &dispatchService{
+		repo:               repo,
+		ticketService:      ticketService,
+		technicianRepo:     technicianRepo,
+		activityLogService: activityLogService,
+		geo:                geo,
+		tenants:            tenants,
+		mu:                 &sync.Mutex{},
+	}
+}
+
+func (s *dispatchService) WithContext(ctx context.Context) DispatchService {
+	return s.withContext(ctx)
+}
+
+func (s *dispatchService) withContext(ctx context.Context) *dispatchService {
+	scoped := *s
+	scoped.repo = s.repo.WithContext(ctx)
+	scoped.ticketService = s.ticketService.WithContext(ctx)
+	scoped.technicianRepo = s.technicianRepo.WithContext(ctx)
+	if s.geo != nil {
+		scoped.geo = s.geo.WithContext(ctx)
+	}
+	scoped.tenantID, _ = tenant.FromContext(ctx)
+	return &scoped
+}
+
+// =============== Rules ===============
+
+func (s *dispatchService) ListRules() ([]models.DispatchRule, error) {
+	return s.repo.ListRules()
+}
+
+func (s *dispatchService) CreateRule(req *models.CreateDispatchRuleRequest, userID string) (*models.DispatchRule, error) {
+	rule := &models.DispatchRule{CreatedBy: userID}
+	if err := applyDispatchRuleRequest(rule, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateRule(rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (s *dispatchService) UpdateRule(id string, req *models.CreateDispatchRuleRequest) (*models.DispatchRule, error) {
+	rule, err := s.repo.FindRuleByID(id)
+	if err != nil {
+		return nil, ErrDispatchRuleNotFound
+	}
+	if err := applyDispatchRuleRequest(rule, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateRule(rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (s *dispatchService) DeleteRule(id string) error {
+	if _, err := s.repo.FindRuleByID(id); err != nil {
+		return ErrDispatchRuleNotFound
+	}
+	return s.repo.DeleteRule(id)
+}
+
+func applyDispatchRuleRequest(rule *models.DispatchRule, req *models.CreateDispatchRuleRequest) error {
+	if req.Priority != "" {
+		switch models.TicketPriority(req.Priority) {
+		case models.TicketPriorityLow, models.TicketPriorityNormal, models.TicketPriorityHigh, models.TicketPriorityUrgent:
+		default:
+			return fmt.Errorf("%w: unknown priority %s", ErrInvalidDispatchRule, req.Priority)
+		}
+	}
+	if req.MaxWaitMinutes < 0 || req.MaxRadiusKm < 0 || req.MaxOpenTickets < 0 {
+		return fmt.Errorf("%w: limits cannot be negative", ErrInvalidDispatchRule)
+	}
+
+	weights := models.DefaultDispatchWeights()
+	if req.Weights != nil {
+		weights = *req.Weights
+		if weights.Skill < 0 || weights.Distance < 0 || weights.Workload < 0 || weights.Skill+weights.Distance+weights.Workload <= 0 {
+			return fmt.Errorf("%w: weights must be non-negative and not all zero", ErrInvalidDispatchRule)
+		}
+	}
+
+	rule.Name = req.Name
+	rule.SortOrder = req.SortOrder
+	rule.Priority = req.Priority
+	rule.CategoryID = req.CategoryID
+	rule.NodeID = req.NodeID
+	rule.Enabled = req.Enabled == nil || *req.Enabled
+	rule.RequireSkill = req.RequireSkill == nil || *req.RequireSkill
+	rule.WeightSkill = weights.Skill
+	rule.WeightDistance = weights.Distance
+	rule.WeightWorkload = weights.Workload
+
+	rule.MaxWaitMinutes = req.MaxWaitMinutes
+	if rule.MaxWaitMinutes == 0 {
+		rule.MaxWaitMinutes = 15
+	}
+	rule.MaxRadiusKm = req.MaxRadiusKm
+	if rule.MaxRadiusKm == 0 {
+		rule.MaxRadiusKm = 100
+	}
+	rule.MaxOpenTickets = req.MaxOpenTickets
+	if rule.MaxOpenTickets == 0 {
+		rule.MaxOpenTickets = 5
+	}
+	return nil
+}
+
+// =============== Audit / Queue ===============
+
+func (s *dispatchService) GetDecisions(filter *models.DispatchDecisionFilter) (*models.PaginatedDispatchDecisions, error) {
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	filter.PageSize = pagination.Size(pagination.Default, filter.PageSize)
+
+	decisions, total, err := s.repo.FindDecisions(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.PaginatedDispatchDecisions{
+		Data:       decisions,
+		Total:      total,
+		Page:       filter.Page,
+		PageSize:   filter.PageSize,
+		TotalPages: int(math.Ceil(float64(total) / float64(filter.PageSize))),
+		HasNext:    pagination.HasNext((filter.Page-1)*filter.PageSize, filter.PageSize, total),
+	}, nil
+}
+
+func (s *dispatchService) GetManualQueue() ([]models.Ticket, error) {
+	return s.repo.FindManualQueue()
+}
+
+// =============== Dispatcher ===============
+
+// Start runs the dispatcher periodically in the background until Stop is called
+func (s *dispatchService) Start(interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultDispatchInterval
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := s.RunOnce(); err != nil {
+					slog.Warn("Auto-dispatch pass failed", "error", err)
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (s *dispatchService) Stop() {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+		s.stop = nil
+	}
+}
+
+// RunOnce evaluates every new unassigned ticket against the dispatch rules. The rules
+// are shared, but each tenant is dispatched in its own pass, among its own technicians.
+func (s *dispatchService) RunOnce() (*models.DispatchRunResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := &models.DispatchRunResult{}
+
+	rules, err := s.repo.ListRules()
+	if err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return result, nil
+	}
+
+	tenantIDs := []string{s.tenantID}
+	if s.tenantID == "" {
+		tenants, err := s.tenants.FindAll()
+		if err != nil {
+			return nil, err
+		}
+		tenantIDs = tenantIDs[:0]
+		for _, t := range tenants {
+			if t.Active {
+				tenantIDs = append(tenantIDs, t.ID)
+			}
+		}
+	}
+	for _, tenantID := range tenantIDs {
+		scoped := s.withContext(tenant.WithID(context.Background(), tenantID))
+		if err := scoped.dispatchTenant(rules, result); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// dispatchTenant runs the pass over the tickets of the tenant the service is bound to
+func (s *dispatchService) dispatchTenant(rules []models.DispatchRule, result *models.DispatchRunResult) error {
+	tickets, err := s.repo.FindUndispatchedTickets(time.Now().Add(-dispatchLookback))
+	if err != nil {
+		return err
+	}
+	if len(tickets) == 0 {
+		return nil
+	}
+
+	technicians, err := s.technicianRepo.GetAll()
+	if err != nil {
+		return err
+	}
+	openTickets, err := s.repo.CountOpenTicketsByTechnician()
+	if err != nil {
+		return err
+	}
+
+	for i := range tickets {
+		ticket := &tickets[i]
+		rule := matchDispatchRule(rules, ticket)
+		if rule == nil {
+			continue
+		}
+		result.Evaluated++
+
+		switch s.dispatchTicket(ticket, rule, technicians, openTickets) {
+		case models.DispatchOutcomeAssigned:
+			result.Assigned++
+		case models.DispatchOutcomeNoCandidate:
+			result.NoCandidate++
+		case models.DispatchOutcomeFallbackManual:
+			result.FallbackManual++
+		}
+	}
+	return nil
+}
+
+// dispatchTicket assigns the best-ranked technician or, once the rule's wait
+// time is over, hands the ticket over to the manual queue. The outcome is empty
+// when the ticket got technicians elsewhere during the pass.
+func (s *dispatchService) dispatchTicket(ticket *models.Ticket, rule *models.DispatchRule, technicians []models.Technician, openTickets map[string]int) models.DispatchOutcome {
+	firstPass := ticket.DispatchStatus == ""
+	if firstPass {
+		if err := s.repo.UpdateDispatchStatus(ticket.ID, models.DispatchStatusPending); err != nil {
+			slog.Warn("Failed to mark ticket as pending dispatch", "ticket_id", ticket.ID, "error", err)
+		}
+	}
+
+	candidates := ScoreTechnicians(ticket, technicians, openTickets, DispatchScoreOptions{
+		Weights:        rule.Weights(),
+		MaxRadiusKm:    rule.MaxRadiusKm,
+		MaxOpenTickets: rule.MaxOpenTickets,
+		RequireSkill:   rule.RequireSkill,
+	})
+
+	for _, best := range candidates {
+		_, err := s.ticketService.AssignIfUnassigned(ticket.ID, []models.TicketAssignmentInput{
+			{TechnicianID: best.TechnicianID, Role: string(models.AssignmentRoleLead)},
+		})
+		if errors.Is(err, ErrTicketAlreadyAssigned) {
+			// Assigned by hand or by another instance since the pass started
+			return ""
+		}
+		if err != nil {
+			slog.Warn("Auto-dispatch could not assign ticket", "ticket_id", ticket.ID, "technician_id", best.TechnicianID, "error", err)
+			continue
+		}
+		if err := s.repo.UpdateDispatchStatus(ticket.ID, models.DispatchStatusAutoAssigned); err != nil {
+			slog.Warn("Failed to mark ticket as auto-assigned", "ticket_id", ticket.ID, "error", err)
+		}
+		openTickets[best.TechnicianID]++
+
+		technicianID := best.TechnicianID
+		s.recordDecision(ticket, rule, &technicianID, best.Score, models.DispatchOutcomeAssigned,
+			fmt.Sprintf("Assigned to %s (score %.2f, %.1f km, %d open tickets)", best.TechnicianName, best.Score, best.DistanceKm, best.OpenTickets),
+			candidates)
+		s.notifyAssignment(technicians, technicianID, ticket)
+		return models.DispatchOutcomeAssigned
+	}
+
+	deadline := ticket.CreatedAt.Add(time.Duration(rule.MaxWaitMinutes) * time.Minute)
+	if time.Now().After(deadline) {
+		if err := s.repo.UpdateDispatchStatus(ticket.ID, models.DispatchStatusManualQueue); err != nil {
+			slog.Warn("Failed to move ticket to the manual queue", "ticket_id", ticket.ID, "error", err)
+		}
+		s.recordDecision(ticket, rule, nil, 0, models.DispatchOutcomeFallbackManual,
+			fmt.Sprintf("No eligible technician within %d minutes, moved to manual queue", rule.MaxWaitMinutes),
+			candidates)
+		return models.DispatchOutcomeFallbackManual
+	}
+
+	// Only audit the first miss, later passes keep retrying silently until the deadline
+	if firstPass {
+		s.recordDecision(ticket, rule, nil, 0, models.DispatchOutcomeNoCandidate,
+			"No eligible technician yet, retrying until "+deadline.Format(time.RFC3339),
+			candidates)
+	}
+	return models.DispatchOutcomeNoCandidate
+}
+
+func (s *dispatchService) recordDecision(ticket *models.Ticket, rule *models.DispatchRule, technicianID *string, score float64, outcome models.DispatchOutcome, reason string, candidates []models.ScoredTechnician) {
+	snapshot, err := json.Marshal(candidates)
+	if err != nil || candidates == nil {
+		snapshot = []byte("[]")
+	}
+
+	ruleID := rule.ID
+	decision := &models.DispatchDecision{
+		TicketID:     ticket.ID,
+		RuleID:       &ruleID,
+		TechnicianID: technicianID,
+		Outcome:      outcome,
+		Score:        score,
+		Reason:       reason,
+		Candidates:   string(snapshot),
+	}
+	if err := s.repo.CreateDecision(decision); err != nil {
+		slog.Warn("Failed to record dispatch decision for ticket", "ticket_id", ticket.ID, "error", err)
+	}
+}
+
+// notifyAssignment records the automatic assignment on the technician's activity feed
+func (s *dispatchService) notifyAssignment(technicians []models.Technician, technicianID string, ticket *models.Ticket) {
+	if s.activityLogService == nil {
+		return
+	}
+	for _, t := range technicians {
+		if t.ID == technicianID && t.UserID != nil {
+			description := fmt.Sprintf("OS %s atribuída automaticamente", ticket.OSNumber)
+			s.activityLogService.LogAction(*t.UserID, "ticket_auto_assigned", "ticket", ticket.ID, description, "", "")
+			return
+		}
+	}
+}
+
+// matchDispatchRule returns the first enabled rule (by sort order) matching the ticket
+func matchDispatchRule(rules []models.DispatchRule, ticket *models.Ticket) *models.DispatchRule {
+	for i := range rules {
+		if rules[i].Matches(ticket) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
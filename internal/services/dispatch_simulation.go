@@ -0,0 +1,218 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+)
+
+var ErrInvalidSimulationDate = errors.New("invalid simulation date, expected YYYY-MM-DD")
+
+const defaultServiceMinutes = 120
+
+// slaResponseTargets is the maximum time between opening a ticket and the
+// technician arriving on site, per priority
+var slaResponseTargets = map[models.TicketPriority]time.Duration{
+	models.TicketPriorityUrgent: 4 * time.Hour,
+	models.TicketPriorityHigh:   8 * time.Hour,
+	models.TicketPriorityNormal: 24 * time.Hour,
+	models.TicketPriorityLow:    48 * time.Hour,
+}
+
+// Simulate replays the tickets opened on a given day against alternative
+// dispatch policies without touching any data. Every policy (and the baseline
+// built from the real assignments) runs through the same model: a technician
+// handles one visit at a time, travels from their base at the average speed
+// and stays on site for serviceMinutes.
+func (s *dispatchService) Simulate(req *models.SimulateDispatchRequest) (*models.SimulateDispatchResponse, error) {
+	day, err := time.ParseInLocation("2006-01-02", req.Date, time.Local)
+	if err != nil {
+		return nil, ErrInvalidSimulationDate
+	}
+	serviceMinutes := req.ServiceMinutes
+	if serviceMinutes <= 0 {
+		serviceMinutes = defaultServiceMinutes
+	}
+
+	policies := req.Policies
+	if len(policies) == 0 {
+		rules, err := s.repo.ListRules()
+		if err != nil {
+			return nil, err
+		}
+		for _, rule := range rules {
+			if rule.Enabled {
+				policies = append(policies, models.DispatchPolicy{
+					Name:           rule.Name,
+					Weights:        rule.Weights(),
+					MaxRadiusKm:    rule.MaxRadiusKm,
+					MaxOpenTickets: rule.MaxOpenTickets,
+					RequireSkill:   rule.RequireSkill,
+				})
+			}
+		}
+	}
+
+	tickets, err := s.repo.FindTicketsCreatedBetween(day, day.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	technicians, err := s.technicianRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	openAtStart, err := s.repo.CountOpenTicketsByTechnicianAt(day)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]*models.Technician, len(technicians))
+	for i := range technicians {
+		byID[technicians[i].ID] = &technicians[i]
+	}
+
+	response := &models.SimulateDispatchResponse{Date: req.Date}
+
+	// Baseline: the technician that was actually assigned (lead first)
+	baseline := newDispatchReplay("actual", serviceMinutes)
+	for i := range tickets {
+		baseline.visit(&tickets[i], byID[actualLeadTechnician(&tickets[i])])
+	}
+	response.Baseline = baseline.metrics()
+
+	for _, policy := range policies {
+		workload := make(map[string]int, len(openAtStart))
+		for id, count := range openAtStart {
+			workload[id] = count
+		}
+		opts := DispatchScoreOptions{
+			Weights:        policy.Weights,
+			MaxRadiusKm:    policy.MaxRadiusKm,
+			MaxOpenTickets: policy.MaxOpenTickets,
+			RequireSkill:   policy.RequireSkill,
+		}
+
+		replay := newDispatchReplay(policy.Name, serviceMinutes)
+		for i := range tickets {
+			ticket := &tickets[i]
+			candidates := ScoreTechnicians(ticket, technicians, workload, opts)
+			if len(candidates) == 0 {
+				replay.visit(ticket, nil)
+				continue
+			}
+			workload[candidates[0].TechnicianID]++
+			replay.visit(ticket, byID[candidates[0].TechnicianID])
+		}
+		response.Results = append(response.Results, replay.metrics())
+	}
+
+	return response, nil
+}
+
+// dispatchReplay accumulates the simulated visits of a single policy
+type dispatchReplay struct {
+	name           string
+	serviceMinutes int
+	freeAt         map[string]time.Time
+
+	tickets       int
+	assigned      int
+	slaMet        int
+	travelKm      float64
+	responseTotal time.Duration
+	workload      map[string]int
+}
+
+func newDispatchReplay(name string, serviceMinutes int) *dispatchReplay {
+	return &dispatchReplay{
+		name:           name,
+		serviceMinutes: serviceMinutes,
+		freeAt:         make(map[string]time.Time),
+		workload:       make(map[string]int),
+	}
+}
+
+func (r *dispatchReplay) visit(ticket *models.Ticket, technician *models.Technician) {
+	r.tickets++
+	if technician == nil {
+		return
+	}
+	r.assigned++
+	r.workload[technician.ID]++
+
+	var clientCity, clientState string
+	if ticket.Client != nil {
+		clientCity, clientState = ticket.Client.City, ticket.Client.State
+	}
+	clientLat, clientLng, _ := GetCoordinatesForLocation(clientCity, clientState)
+	lat, lng, _ := GetCoordinatesForLocation(technician.City, technician.State)
+	distanceKm := CalculateDistance(lat, lng, clientLat, clientLng) / 1000
+	r.travelKm += distanceKm
+
+	departure := ticket.CreatedAt
+	if free, ok := r.freeAt[technician.ID]; ok && free.After(departure) {
+		departure = free
+	}
+	travel := time.Duration(distanceKm / models.DefaultSchedulingSettings().AverageSpeedKmh * float64(time.Hour))
+	arrival := departure.Add(travel)
+	r.freeAt[technician.ID] = arrival.Add(time.Duration(r.serviceMinutes) * time.Minute)
+
+	response := arrival.Sub(ticket.CreatedAt)
+	r.responseTotal += response
+
+	target, ok := slaResponseTargets[ticket.Priority]
+	if !ok {
+		target = slaResponseTargets[models.TicketPriorityNormal]
+	}
+	if response <= target {
+		r.slaMet++
+	}
+}
+
+func (r *dispatchReplay) metrics() models.DispatchSimulationMetrics {
+	m := models.DispatchSimulationMetrics{
+		Policy:          r.name,
+		Tickets:         r.tickets,
+		Assigned:        r.assigned,
+		Unassigned:      r.tickets - r.assigned,
+		SLAMet:          r.slaMet,
+		TotalTravelKm:   math.Round(r.travelKm*10) / 10,
+		TechniciansUsed: len(r.workload),
+		Workload:        r.workload,
+	}
+	if r.tickets > 0 {
+		m.SLAMetPercent = math.Round(float64(r.slaMet)/float64(r.tickets)*10000) / 100
+	}
+	if r.assigned > 0 {
+		m.AvgTravelKm = math.Round(r.travelKm/float64(r.assigned)*10) / 10
+		m.AvgResponseMin = math.Round(r.responseTotal.Minutes()/float64(r.assigned)*10) / 10
+	}
+
+	if len(r.workload) > 0 {
+		mean := float64(r.assigned) / float64(len(r.workload))
+		var variance float64
+		for _, count := range r.workload {
+			if count > m.MaxPerTech {
+				m.MaxPerTech = count
+			}
+			variance += (float64(count) - mean) * (float64(count) - mean)
+		}
+		m.WorkloadStdDev = math.Round(math.Sqrt(variance/float64(len(r.workload)))*100) / 100
+	}
+	return m
+}
+
+// actualLeadTechnician returns the technician that really handled the ticket
+func actualLeadTechnician(ticket *models.Ticket) string {
+	for _, a := range ticket.Assignments {
+		if a.Role == models.AssignmentRoleLead {
+			return a.TechnicianID
+		}
+	}
+	if len(ticket.Assignments) > 0 {
+		return ticket.Assignments[0].TechnicianID
+	}
+	return ""
+}
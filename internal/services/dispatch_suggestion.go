@@ -0,0 +1,124 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+var ErrDispatchTicketNotFound = errors.New("ticket not found")
+
+const (
+	defaultSuggestionLimit    = 10
+	defaultSuggestionRadiusKm = 200
+	// Last locations older than this no longer tell where the technician is
+	suggestionLiveLocationMaxAge = 12 * time.Hour
+)
+
+// SuggestTechnicians ranks the technicians for assigning the ticket by hand. It uses the
+// same scorer as the auto-dispatch, with the weights of the matching rule, measuring the
+// distance from the live location of each technician (geo cache) to the geocoded client.
+func (s *dispatchService) SuggestTechnicians(ticketID string, query *models.SuggestTechniciansQuery) (*models.TechnicianSuggestions, error) {
+	ticket, err := s.repo.FindTicket(ticketID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDispatchTicketNotFound
+		}
+		return nil, err
+	}
+
+	suggestions := &models.TechnicianSuggestions{
+		TicketID:           ticket.ID,
+		ClientGeoPrecision: models.GeoPrecisionNone,
+		Weights:            models.DefaultDispatchWeights(),
+		RadiusKm:           defaultSuggestionRadiusKm,
+	}
+	opts := DispatchScoreOptions{RequireSkill: query.RequireSkill}
+
+	rules, err := s.repo.ListRules()
+	if err != nil {
+		return nil, err
+	}
+	if rule := matchDispatchRule(rules, ticket); rule != nil {
+		ruleID := rule.ID
+		suggestions.RuleID = &ruleID
+		if weights := rule.Weights(); weights.Skill+weights.Distance+weights.Workload > 0 {
+			suggestions.Weights = weights
+		}
+		if rule.MaxRadiusKm > 0 {
+			suggestions.RadiusKm = rule.MaxRadiusKm
+		}
+		opts.MaxOpenTickets = rule.MaxOpenTickets
+		opts.RequireSkill = opts.RequireSkill || rule.RequireSkill
+	}
+	if query.RadiusKm > 0 {
+		suggestions.RadiusKm = query.RadiusKm
+	}
+	opts.Weights = suggestions.Weights
+	opts.MaxRadiusKm = suggestions.RadiusKm
+
+	if ticket.Client != nil && s.geo != nil {
+		lat, lng, precision := s.geo.GeocodeClient(ticket.Client)
+		suggestions.ClientGeoPrecision = precision
+		if lat != nil && lng != nil {
+			suggestions.ClientLatitude, suggestions.ClientLongitude = lat, lng
+			opts.ClientLocation = &models.GeoPoint{Latitude: *lat, Longitude: *lng}
+		}
+	}
+	opts.Positions = s.livePositions()
+
+	technicians, err := s.technicianRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	// Technicians already on the ticket are not suggested again
+	assigned := make(map[string]bool, len(ticket.Technicians))
+	for _, t := range ticket.Technicians {
+		assigned[t.ID] = true
+	}
+	available := make([]models.Technician, 0, len(technicians))
+	for _, t := range technicians {
+		if !assigned[t.ID] {
+			available = append(available, t)
+		}
+	}
+
+	openTickets, err := s.repo.CountOpenTicketsByTechnician()
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := ScoreTechnicians(ticket, available, openTickets, opts)
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultSuggestionLimit
+	}
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	suggestions.Candidates = candidates
+	return suggestions, nil
+}
+
+// livePositions returns the recent real locations of the technicians from the geo cache
+func (s *dispatchService) livePositions() map[string]models.GeoPoint {
+	positions := make(map[string]models.GeoPoint)
+	if s.geo == nil {
+		return positions
+	}
+	technicians, err := s.geo.GetAllTechniciansFromCache()
+	if err != nil {
+		return positions
+	}
+
+	since := time.Now().Add(-suggestionLiveLocationMaxAge).Unix()
+	for _, t := range technicians {
+		if !t.HasRealLocation || t.LastUpdateTime == nil || *t.LastUpdateTime < since {
+			continue
+		}
+		positions[t.TechnicianID] = models.GeoPoint{Latitude: t.Latitude, Longitude: t.Longitude}
+	}
+	return positions
+}
@@ -0,0 +1,394 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"github.com/shigake/tech-iq-back/internal/storage"
+	"github.com/shigake/tech-iq-back/internal/tenant"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrFileNotFound        = errors.New("file not found")
+	ErrFileOwnerNotFound   = errors.New("file owner not found")
+	ErrFileTooLarge        = errors.New("file exceeds the maximum size")
+	ErrFileTypeNotAllowed  = errors.New("file type not allowed")
+	ErrFileNotUploaded     = errors.New("file content was not uploaded")
+	ErrFileSizeMismatch    = errors.New("uploaded content does not match the declared size")
+	ErrFileNotReady        = errors.New("file upload was not completed")
+	ErrFileInfected        = errors.New("file was quarantined by the antivirus")
+	ErrFileAlreadyComplete = errors.New("file upload was already completed")
+)
+
+const defaultFileURLTTL = 15 * time.Minute
+
+// FileScanner is the antivirus hook run on completed uploads; it returns the verdict
+// and whether the content is infected
+type FileScanner interface {
+	ScanReader(r io.Reader) (string, bool, error)
+}
+
+// FileConfig configures the /files uploads
+type FileConfig struct {
+	MaxSize       int64
+	URLTTL        time.Duration
+	ClamAVAddress string // empty disables scanning
+}
+
+// FileService keeps the files of tickets, financial entries, stock movements and node
+// logos in the storage backend. Uploads go straight from the client to the backend
+// through a presigned URL, to a staging object; completing the upload checks the size,
+// runs the antivirus and moves the content to the file's key, so the URL can't replace
+// the content of a completed file.
+type FileService interface {
+	CreateUpload(req *models.CreateFileUploadRequest, userID string) (*models.FileUpload, error)
+	Complete(id string) (*models.StoredFile, error)
+	// Store writes content produced by the server itself (e.g. invoice XML) as a ready file
+	Store(ownerType, ownerID, fileName, contentType string, content []byte, userID string) (*models.StoredFile, error)
+	List(ownerType, ownerID string) ([]models.StoredFile, error)
+	Get(id string) (*models.StoredFile, error)
+	DownloadURL(id string) (*models.FileDownload, error)
+	Delete(id string) error
+	// OwnerNode returns the hierarchy node of the record owning files, the node the
+	// permissions on its files are checked on (nil outside the hierarchy)
+	OwnerNode(ownerType, ownerID string) (*uint, error)
+	// WithContext returns the service running its queries with ctx, inside the tenant it carries
+	WithContext(ctx context.Context) FileService
+}
+
+type fileService struct {
+	repo           repositories.StoredFileRepository
+	nodes          repositories.ResourceNodeRepository
+	hierarchyRepo  repositories.HierarchyRepository
+	userRepo       repositories.UserRepository
+	storageService StorageService
+	backend        storage.Backend
+	scanner        FileScanner
+	config         FileConfig
+}
+
+func NewFileService(
+	repo repositories.StoredFileRepository,
+	nodes repositories.ResourceNodeRepository,
+	hierarchyRepo repositories.HierarchyRepository,
+	userRepo repositories.UserRepository,
+	storageService StorageService,
+	backend storage.Backend,
+	config FileConfig,
+) FileService {
+	if config.URLTTL <= 0 {
+		config.URLTTL = defaultFileURLTTL
+	}
+	if config.MaxSize <= 0 {
+		config.MaxSize = maxAttachmentSize
+	}
+	svc := &fileService{
+		repo:           repo,
+		nodes:          nodes,
+		hierarchyRepo:  hierarchyRepo,
+		userRepo:       userRepo,
+		storageService: storageService,
+		backend:        backend,
+		config:         config,
+	}
+	if config.ClamAVAddress != "" {
+		svc.scanner = &clamAVScanner{address: config.ClamAVAddress}
+	}
+	return svc
+}
+
+func (s *fileService) WithContext(ctx context.Context) FileService {
+	scoped := *s
+	scoped.repo = s.repo.WithContext(ctx)
+	scoped.nodes = s.nodes.WithContext(ctx)
+	return &scoped
+}
+
+func (s *fileService) CreateUpload(req *models.CreateFileUploadRequest, userID string) (*models.FileUpload, error) {
+	if req.Size > s.config.MaxSize {
+		return nil, ErrFileTooLarge
+	}
+	contentType := strings.ToLower(strings.TrimSpace(req.ContentType))
+	if !allowedFileType(req.OwnerType, contentType) {
+		return nil, ErrFileTypeNotAllowed
+	}
+
+	nodeID, err := s.ownerNode(req.OwnerType, req.OwnerID)
+	if err != nil {
+		return nil, err
+	}
+	if s.storageService != nil {
+		if err := s.storageService.CheckQuota(nodeID, models.StorageModuleFiles, req.Size); err != nil {
+			return nil, err
+		}
+	}
+
+	file := &models.StoredFile{
+		OwnerType:   req.OwnerType,
+		OwnerID:     req.OwnerID,
+		FileName:    filepath.Base(req.FileName),
+		ContentType: contentType,
+		Size:        req.Size,
+		Backend:     s.backend.Name(),
+		Status:      models.FileStatusPendingUpload,
+		UploadedBy:  userID,
+	}
+	// The record is created first so the ID can be used as the object name
+	if err := s.repo.Create(file); err != nil {
+		return nil, err
+	}
+	file.Key = fmt.Sprintf("%s/%s/%s/%s%s", models.StorageModuleFiles, strings.ToLower(file.OwnerType), file.OwnerID,
+		file.ID, strings.ToLower(filepath.Ext(file.FileName)))
+	if err := s.repo.Update(file); err != nil {
+		return nil, err
+	}
+
+	url, err := s.backend.PresignUpload(stagingKey(file), file.ContentType, s.config.URLTTL)
+	if err != nil {
+		s.repo.Delete(file.ID)
+		return nil, err
+	}
+	return &models.FileUpload{
+		File:      file,
+		UploadURL: url,
+		Method:    "PUT",
+		Headers:   map[string]string{"Content-Type": file.ContentType},
+		ExpiresAt: time.Now().Add(s.config.URLTTL),
+	}, nil
+}
+
+// Complete checks the uploaded content and scans it; infected content is deleted from
+// the backend and the record kept as INFECTED
+func (s *fileService) Complete(id string) (*models.StoredFile, error) {
+	file, err := s.find(id)
+	if err != nil {
+		return nil, err
+	}
+	if file.Status != models.FileStatusPendingUpload {
+		return nil, ErrFileAlreadyComplete
+	}
+
+	staged := stagingKey(file)
+	size, err := s.backend.Size(staged)
+	if errors.Is(err, storage.ErrObjectNotFound) {
+		return nil, ErrFileNotUploaded
+	}
+	if err != nil {
+		return nil, err
+	}
+	if size != file.Size {
+		s.backend.Delete(staged)
+		return nil, ErrFileSizeMismatch
+	}
+
+	if s.scanner != nil {
+		content, err := s.backend.Open(staged)
+		if err != nil {
+			return nil, err
+		}
+		verdict, infected, err := s.scanner.ScanReader(content)
+		content.Close()
+		if err != nil {
+			return nil, fmt.Errorf("antivirus scan failed: %w", err)
+		}
+		file.ScanResult = verdict
+		if infected {
+			file.Status = models.FileStatusInfected
+			if err := s.backend.Delete(staged); err != nil {
+				slog.Warn("Failed to delete infected file", "file_id", file.ID, "error", err)
+			}
+			if err := s.repo.Update(file); err != nil {
+				return nil, err
+			}
+			return nil, ErrFileInfected
+		}
+	}
+
+	// The checked content is copied out of the staging object, which the upload URL
+	// can still overwrite until it expires
+	content, err := s.backend.Open(staged)
+	if err != nil {
+		return nil, err
+	}
+	err = s.backend.Put(file.Key, file.ContentType, content, file.Size)
+	content.Close()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.backend.Delete(staged); err != nil {
+		slog.Warn("Failed to delete staged upload", "file_id", file.ID, "error", err)
+	}
+
+	now := time.Now()
+	file.Status = models.FileStatusReady
+	file.UploadedAt = &now
+	if err := s.repo.Update(file); err != nil {
+		return nil, err
+	}
+	if file.OwnerType == models.FileOwnerFinancialEntry {
+		if err := s.repo.AddEntryAttachment(file.OwnerID, fileURL(file.ID)); err != nil {
+			slog.Warn("Failed to link file to financial entry", "file_id", file.ID, "owner_id", file.OwnerID, "error", err)
+		}
+	}
+	return file, nil
+}
+
+func (s *fileService) Store(ownerType, ownerID, fileName, contentType string, content []byte, userID string) (*models.StoredFile, error) {
+	if !allowedFileType(ownerType, contentType) {
+		return nil, ErrFileTypeNotAllowed
+	}
+	now := time.Now()
+	file := &models.StoredFile{
+		OwnerType:   ownerType,
+		OwnerID:     ownerID,
+		FileName:    filepath.Base(fileName),
+		ContentType: contentType,
+		Size:        int64(len(content)),
+		Backend:     s.backend.Name(),
+		Status:      models.FileStatusPendingUpload,
+		UploadedBy:  userID,
+	}
+	// The files the server produces, often from a background job, belong to the tenant of
+	// the user they are produced for
+	repo := s.repo
+	if user, err := s.userRepo.FindByID(userID); err == nil {
+		repo = s.repo.WithContext(tenant.WithID(context.Background(), user.TenantID))
+	}
+	if err := repo.Create(file); err != nil {
+		return nil, err
+	}
+	file.Key = fmt.Sprintf("%s/%s/%s/%s%s", models.StorageModuleFiles, strings.ToLower(file.OwnerType), file.OwnerID,
+		file.ID, strings.ToLower(filepath.Ext(file.FileName)))
+	if err := s.backend.Put(file.Key, file.ContentType, bytes.NewReader(content), file.Size); err != nil {
+		repo.Delete(file.ID)
+		return nil, err
+	}
+	file.Status = models.FileStatusReady
+	file.UploadedAt = &now
+	if err := repo.Update(file); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (s *fileService) List(ownerType, ownerID string) ([]models.StoredFile, error) {
+	return s.repo.FindByOwner(ownerType, ownerID)
+}
+
+func (s *fileService) Get(id string) (*models.StoredFile, error) {
+	return s.find(id)
+}
+
+func (s *fileService) DownloadURL(id string) (*models.FileDownload, error) {
+	file, err := s.find(id)
+	if err != nil {
+		return nil, err
+	}
+	switch file.Status {
+	case models.FileStatusReady:
+	case models.FileStatusInfected:
+		return nil, ErrFileInfected
+	default:
+		return nil, ErrFileNotReady
+	}
+
+	url, err := s.backend.PresignDownload(file.Key, file.FileName, s.config.URLTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &models.FileDownload{URL: url, ExpiresAt: time.Now().Add(s.config.URLTTL)}, nil
+}
+
+func (s *fileService) Delete(id string) error {
+	file, err := s.find(id)
+	if err != nil {
+		return err
+	}
+	key := file.Key
+	if file.Status == models.FileStatusPendingUpload {
+		key = stagingKey(file)
+	}
+	if err := s.backend.Delete(key); err != nil {
+		return err
+	}
+	if file.OwnerType == models.FileOwnerFinancialEntry {
+		if err := s.repo.RemoveEntryAttachment(file.OwnerID, fileURL(file.ID)); err != nil {
+			slog.Warn("Failed to unlink file from financial entry", "file_id", file.ID, "owner_id", file.OwnerID, "error", err)
+		}
+	}
+	return s.repo.Delete(file.ID)
+}
+
+func (s *fileService) find(id string) (*models.StoredFile, error) {
+	file, err := s.repo.FindByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrFileNotFound
+	}
+	return file, err
+}
+
+func (s *fileService) OwnerNode(ownerType, ownerID string) (*uint, error) {
+	return s.ownerNode(ownerType, ownerID)
+}
+
+// ownerNode checks the owner exists and returns its hierarchy node (quota tenant). The
+// owners of the files stored by the server are not checked and have no node.
+func (s *fileService) ownerNode(ownerType, ownerID string) (*uint, error) {
+	var nodeID *uint
+	var err error
+	switch ownerType {
+	case models.FileOwnerTicket:
+		nodeID, err = s.nodes.TicketNode(ownerID)
+	case models.FileOwnerFinancialEntry:
+		nodeID, err = s.nodes.FinancialEntryNode(ownerID)
+	case models.FileOwnerStockMovement:
+		nodeID, err = s.nodes.StockMovementNode(ownerID)
+	case models.FileOwnerNodeBranding:
+		id, parseErr := strconv.ParseUint(ownerID, 10, 32)
+		if parseErr != nil {
+			return nil, ErrFileOwnerNotFound
+		}
+		var node *models.Node
+		if node, err = s.hierarchyRepo.GetNodeByID(uint(id)); err == nil {
+			nodeID = &node.ID
+		}
+	case models.FileOwnerInvoice, models.FileOwnerFinancialExport, models.FileOwnerReportRun:
+		return nil, nil
+	default:
+		return nil, ErrFileOwnerNotFound
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrFileOwnerNotFound
+	}
+	return nodeID, err
+}
+
+// stagingKey is the object the content of a pending upload is PUT to
+func stagingKey(file *models.StoredFile) string {
+	return "staging/" + file.Key
+}
+
+func allowedFileType(ownerType, contentType string) bool {
+	for _, allowed := range models.FileContentTypes[ownerType] {
+		if allowed == contentType {
+			return true
+		}
+	}
+	return false
+}
+
+// fileURL is the API path of a file, as listed in FinancialEntry.AttachmentURLs
+func fileURL(id string) string {
+	return "/api/v1/files/" + id
+}
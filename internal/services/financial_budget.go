@@ -0,0 +1,284 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrBudgetNotFound        = errors.New("budget not found")
+	ErrBudgetExists          = errors.New("the category already has a budget for the month")
+	ErrBudgetInvalidMonth    = errors.New("invalid month format, expected YYYY-MM")
+	ErrBudgetInvalidCategory = errors.New("invalid category for the given type")
+	ErrBudgetInvalidRange    = errors.New("end month must not be before start month, at most 24 months apart")
+)
+
+const (
+	defaultBudgetThreshold = 10.0 // percent
+	maxBudgetReportMonths  = 24
+)
+
+// =============== Budgets ===============
+
+// CreateBudget plans the income or expense of a category in a month
+func (s *FinancialService) CreateBudget(req models.CreateBudgetRequest, userID string, ip string, userAgent string) (*models.Budget, error) {
+	month, err := parseBudgetMonth(req.Month)
+	if err != nil {
+		return nil, err
+	}
+	if !s.ValidateCategory(req.Type, req.Category, "") {
+		return nil, ErrBudgetInvalidCategory
+	}
+	if _, err := s.repo.FindBudget(req.Type, req.Category, budgetMonth(month)); err == nil {
+		return nil, ErrBudgetExists
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	budget := &models.Budget{
+		Type:      req.Type,
+		Category:  req.Category,
+		Month:     budgetMonth(month),
+		Amount:    req.Amount,
+		Notes:     req.Notes,
+		CreatedBy: userID,
+	}
+	if err := s.repo.CreateBudget(budget); err != nil {
+		return nil, err
+	}
+
+	s.repo.LogChange("budget", budget.ID, "create", budget, userID, ip, userAgent)
+
+	return budget, nil
+}
+
+// GetBudgetByID retrieves a budget by ID
+func (s *FinancialService) GetBudgetByID(id string) (*models.Budget, error) {
+	budget, err := s.repo.GetBudgetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBudgetNotFound
+		}
+		return nil, err
+	}
+	return budget, nil
+}
+
+// ListBudgets lists budgets with filters
+func (s *FinancialService) ListBudgets(filter models.BudgetFilter) ([]models.Budget, int64, error) {
+	for _, month := range []string{filter.FromMonth, filter.ToMonth} {
+		if month == "" {
+			continue
+		}
+		if _, err := parseBudgetMonth(month); err != nil {
+			return nil, 0, err
+		}
+	}
+	return s.repo.ListBudgets(filter)
+}
+
+// UpdateBudget changes the amount or notes of a budget
+func (s *FinancialService) UpdateBudget(id string, req models.UpdateBudgetRequest, userID string, ip string, userAgent string) (*models.Budget, error) {
+	budget, err := s.GetBudgetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	before := *budget
+	if req.Amount != nil {
+		budget.Amount = *req.Amount
+	}
+	if req.Notes != nil {
+		budget.Notes = *req.Notes
+	}
+	budget.UpdatedBy = &userID
+	if err := s.repo.UpdateBudget(budget); err != nil {
+		return nil, err
+	}
+
+	s.repo.LogChange("budget", budget.ID, "update", map[string]interface{}{
+		"before": before,
+		"after":  budget,
+	}, userID, ip, userAgent)
+
+	return budget, nil
+}
+
+// DeleteBudget deletes a budget; the report shows its entries as unbudgeted
+func (s *FinancialService) DeleteBudget(id string, userID string, ip string, userAgent string) error {
+	budget, err := s.GetBudgetByID(id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.DeleteBudget(id); err != nil {
+		return err
+	}
+
+	s.repo.LogChange("budget", id, "delete", budget, userID, ip, userAgent)
+
+	return nil
+}
+
+// GetBudgetVsActualReport compares the budgets of the months with the entries that were
+// not cancelled, category by category. The current month is projected from its daily run
+// rate; entries of categories without a budget are listed as unbudgeted.
+func (s *FinancialService) GetBudgetVsActualReport(filter models.BudgetVsActualFilter) (*models.BudgetVsActualReport, error) {
+	now := time.Now()
+	if filter.StartMonth == "" {
+		filter.StartMonth = now.Format("2006") + "-01"
+	}
+	if filter.EndMonth == "" {
+		filter.EndMonth = now.Format("2006") + "-12"
+	}
+	start, err := parseBudgetMonth(filter.StartMonth)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseBudgetMonth(filter.EndMonth)
+	if err != nil {
+		return nil, err
+	}
+	if end.Before(start) || end.After(start.AddDate(0, maxBudgetReportMonths-1, 0)) {
+		return nil, ErrBudgetInvalidRange
+	}
+	if filter.Threshold <= 0 {
+		filter.Threshold = defaultBudgetThreshold
+	}
+
+	budgets, err := s.repo.GetBudgetsInRange(budgetMonth(start), budgetMonth(end), filter.Type)
+	if err != nil {
+		return nil, err
+	}
+	actuals, err := s.repo.GetActualsByCategoryMonth(start, end.AddDate(0, 1, -1), filter.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	type lineKey struct {
+		month    string
+		kind     models.FinancialEntryType
+		category string
+	}
+	lines := make(map[lineKey]*models.BudgetVarianceLine)
+	for i := range budgets {
+		budget := &budgets[i]
+		lines[lineKey{budget.Month, budget.Type, budget.Category}] = &models.BudgetVarianceLine{
+			Month:    budget.Month,
+			Type:     budget.Type,
+			Category: budget.Category,
+			BudgetID: &budget.ID,
+			Budgeted: budget.Amount,
+		}
+	}
+	for _, actual := range actuals {
+		key := lineKey{actual.Month, actual.Type, actual.Category}
+		line, ok := lines[key]
+		if !ok {
+			line = &models.BudgetVarianceLine{Month: actual.Month, Type: actual.Type, Category: actual.Category}
+			lines[key] = line
+		}
+		line.Actual = actual.Total
+	}
+
+	report := &models.BudgetVsActualReport{
+		StartMonth: budgetMonth(start),
+		EndMonth:   budgetMonth(end),
+		AsOf:       now,
+		Threshold:  filter.Threshold,
+		Lines:      make([]models.BudgetVarianceLine, 0, len(lines)),
+	}
+	for _, line := range lines {
+		computeBudgetVariance(line, now, filter.Threshold)
+		report.Lines = append(report.Lines, *line)
+
+		totals := &report.Expense
+		if line.Type == models.FinancialEntryTypeIncome {
+			totals = &report.Income
+		}
+		totals.Budgeted += line.Budgeted
+		totals.Actual += line.Actual
+		totals.Projected += line.Projected
+	}
+	sort.Slice(report.Lines, func(i, j int) bool {
+		a, b := report.Lines[i], report.Lines[j]
+		if a.Month != b.Month {
+			return a.Month < b.Month
+		}
+		if a.Type != b.Type {
+			return a.Type > b.Type // income first
+		}
+		return a.Category < b.Category
+	})
+
+	for _, totals := range []*models.BudgetVarianceTotals{&report.Income, &report.Expense} {
+		totals.Budgeted = round2(totals.Budgeted)
+		totals.Actual = round2(totals.Actual)
+		totals.Projected = round2(totals.Projected)
+		totals.Variance = round2(totals.Actual - totals.Budgeted)
+		totals.ProjectedVariance = round2(totals.Projected - totals.Budgeted)
+	}
+	report.BudgetedBalance = round2(report.Income.Budgeted - report.Expense.Budgeted)
+	report.ProjectedBalance = round2(report.Income.Projected - report.Expense.Projected)
+
+	return report, nil
+}
+
+// computeBudgetVariance fills the variance, projection and status of a line. Months
+// before now project their actual; the current month extrapolates the actual to its
+// last day; later months project the entries already recorded for them.
+func computeBudgetVariance(line *models.BudgetVarianceLine, now time.Time, threshold float64) {
+	line.Actual = round2(line.Actual)
+	line.Projected = line.Actual
+	if line.Month == budgetMonth(now) {
+		daysInMonth := time.Date(now.Year(), now.Month()+1, 0, 0, 0, 0, 0, now.Location()).Day()
+		line.Projected = round2(line.Actual * float64(daysInMonth) / float64(now.Day()))
+	}
+	line.Variance = round2(line.Actual - line.Budgeted)
+	line.ProjectedVariance = round2(line.Projected - line.Budgeted)
+
+	if line.BudgetID == nil {
+		line.Status = models.BudgetStatusUnbudgeted
+		line.Favorable = line.Type == models.FinancialEntryTypeIncome
+		return
+	}
+	if line.Budgeted > 0 {
+		percent := round2(line.Variance / line.Budgeted * 100)
+		line.VariancePercent = &percent
+	}
+
+	switch {
+	case line.Budgeted == 0 && line.Projected == 0:
+		line.Status = models.BudgetStatusOnTrack
+	case line.Budgeted == 0:
+		line.Status = models.BudgetStatusOver
+	case math.Abs(line.ProjectedVariance/line.Budgeted*100) <= threshold:
+		line.Status = models.BudgetStatusOnTrack
+	case line.ProjectedVariance > 0:
+		line.Status = models.BudgetStatusOver
+	default:
+		line.Status = models.BudgetStatusUnder
+	}
+	if line.Type == models.FinancialEntryTypeIncome {
+		line.Favorable = line.ProjectedVariance >= 0
+	} else {
+		line.Favorable = line.ProjectedVariance <= 0
+	}
+}
+
+// parseBudgetMonth parses a YYYY-MM month into its first day
+func parseBudgetMonth(month string) (time.Time, error) {
+	parsed, err := time.ParseInLocation("2006-01", month, time.Local)
+	if err != nil {
+		return time.Time{}, ErrBudgetInvalidMonth
+	}
+	return parsed, nil
+}
+
+func budgetMonth(t time.Time) string {
+	return t.Format("2006-01")
+}
@@ -0,0 +1,444 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/tenant"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRecurringEntryNotFound     = errors.New("recurring entry not found")
+	ErrRecurringEntryNotActive    = errors.New("only active recurring entries can be paused")
+	ErrRecurringEntryNotPaused    = errors.New("only paused recurring entries can be resumed")
+	ErrRecurringEntryNoOccurrence = errors.New("the recurring entry has no occurrence left before its end date")
+)
+
+const (
+	defaultRecurringPreview = 12
+	maxRecurringPreview     = 60
+	// Upcoming occurrences are listed at most a year ahead
+	maxRecurringUpcomingDays = 366
+)
+
+// =============== Recurring Entries ===============
+
+// CreateRecurringEntry creates a recurring entry. Occurrences before today are not
+// generated: the first entry is the one of the first occurrence from today on.
+func (s *FinancialService) CreateRecurringEntry(req models.CreateRecurringEntryRequest, userID string, ip string, userAgent string) (*models.RecurringEntry, error) {
+	if !s.ValidateCategory(req.Type, req.Category, req.Subcategory) {
+		return nil, errors.New("invalid category or subcategory for the given type")
+	}
+
+	startDate, err := time.Parse("2006-01-02", req.StartDate)
+	if err != nil {
+		return nil, errors.New("invalid start date format, expected YYYY-MM-DD")
+	}
+	var endDate *time.Time
+	if req.EndDate != "" {
+		parsed, err := time.Parse("2006-01-02", req.EndDate)
+		if err != nil {
+			return nil, errors.New("invalid end date format, expected YYYY-MM-DD")
+		}
+		if parsed.Before(startDate) {
+			return nil, errors.New("end date must not be before start date")
+		}
+		endDate = &parsed
+	}
+
+	recurring := &models.RecurringEntry{
+		Type:          req.Type,
+		Category:      req.Category,
+		Subcategory:   req.Subcategory,
+		Description:   req.Description,
+		Amount:        req.Amount,
+		Frequency:     req.Frequency,
+		StartDate:     startDate,
+		EndDate:       endDate,
+		DueDays:       req.DueDays,
+		Status:        models.RecurringEntryStatusActive,
+		PaymentMethod: req.PaymentMethod,
+		CreatedBy:     userID,
+	}
+	if req.TechnicianID != "" {
+		recurring.TechnicianID = &req.TechnicianID
+	}
+	if req.ClientID != "" {
+		recurring.ClientID = &req.ClientID
+	}
+
+	skipToToday(recurring)
+	if recurring.Status == models.RecurringEntryStatusFinished {
+		return nil, ErrRecurringEntryNoOccurrence
+	}
+
+	if err := s.repo.CreateRecurringEntry(recurring); err != nil {
+		return nil, err
+	}
+
+	s.repo.LogChange("recurring_entry", recurring.ID, "create", recurring, userID, ip, userAgent)
+
+	return s.repo.GetRecurringEntryByID(recurring.ID)
+}
+
+// GetRecurringEntryByID retrieves a recurring entry by ID
+func (s *FinancialService) GetRecurringEntryByID(id string) (*models.RecurringEntry, error) {
+	recurring, err := s.repo.GetRecurringEntryByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRecurringEntryNotFound
+		}
+		return nil, err
+	}
+	return recurring, nil
+}
+
+// ListRecurringEntries lists recurring entries with filters
+func (s *FinancialService) ListRecurringEntries(filter models.RecurringEntryFilter) ([]models.RecurringEntry, int64, error) {
+	return s.repo.ListRecurringEntries(filter)
+}
+
+// UpdateRecurringEntry changes the next occurrences of a recurring entry; the entries
+// already generated are left as they are
+func (s *FinancialService) UpdateRecurringEntry(id string, req models.UpdateRecurringEntryRequest, userID string, ip string, userAgent string) (*models.RecurringEntry, error) {
+	existing, err := s.GetRecurringEntryByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	updated := *existing
+	updated.Technician, updated.Client = nil, nil
+	if req.Category != "" {
+		updated.Category = req.Category
+	}
+	if req.Subcategory != "" {
+		updated.Subcategory = req.Subcategory
+	}
+	if !s.ValidateCategory(updated.Type, updated.Category, updated.Subcategory) {
+		return nil, errors.New("invalid category or subcategory for the given type")
+	}
+	if req.Description != "" {
+		updated.Description = req.Description
+	}
+	if req.Amount > 0 {
+		updated.Amount = req.Amount
+	}
+	if req.DueDays != nil {
+		updated.DueDays = *req.DueDays
+	}
+	if req.TechnicianID != nil {
+		updated.TechnicianID = nilIfEmpty(*req.TechnicianID)
+	}
+	if req.ClientID != nil {
+		updated.ClientID = nilIfEmpty(*req.ClientID)
+	}
+	if req.PaymentMethod != nil {
+		updated.PaymentMethod = *req.PaymentMethod
+	}
+
+	if req.EndDate != nil {
+		updated.EndDate = nil
+		if *req.EndDate != "" {
+			endDate, err := time.Parse("2006-01-02", *req.EndDate)
+			if err != nil {
+				return nil, errors.New("invalid end date format, expected YYYY-MM-DD")
+			}
+			if endDate.Before(updated.StartDate) {
+				return nil, errors.New("end date must not be before start date")
+			}
+			updated.EndDate = &endDate
+		}
+		// A finished entry whose end date moved forward runs again from today
+		if updated.Status == models.RecurringEntryStatusFinished {
+			updated.Status = models.RecurringEntryStatusActive
+			skipToToday(&updated)
+		} else if updated.EndDate != nil && updated.NextDate.After(*updated.EndDate) {
+			updated.Status = models.RecurringEntryStatusFinished
+		}
+	}
+	updated.UpdatedBy = &userID
+
+	if err := s.repo.UpdateRecurringEntry(&updated); err != nil {
+		return nil, err
+	}
+
+	changes := map[string]interface{}{
+		"before": existing,
+		"after":  updated,
+	}
+	s.repo.LogChange("recurring_entry", id, "update", changes, userID, ip, userAgent)
+
+	return s.repo.GetRecurringEntryByID(id)
+}
+
+// DeleteRecurringEntry deletes a recurring entry; the entries it generated are kept
+func (s *FinancialService) DeleteRecurringEntry(id string, userID string, ip string, userAgent string) error {
+	existing, err := s.GetRecurringEntryByID(id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.DeleteRecurringEntry(id); err != nil {
+		return err
+	}
+
+	s.repo.LogChange("recurring_entry", id, "delete", existing, userID, ip, userAgent)
+
+	return nil
+}
+
+// PauseRecurringEntry stops generating entries until the recurring entry is resumed
+func (s *FinancialService) PauseRecurringEntry(id string, userID string, ip string, userAgent string) (*models.RecurringEntry, error) {
+	recurring, err := s.GetRecurringEntryByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if recurring.Status != models.RecurringEntryStatusActive {
+		return nil, ErrRecurringEntryNotActive
+	}
+
+	recurring.Technician, recurring.Client = nil, nil
+	recurring.Status = models.RecurringEntryStatusPaused
+	recurring.UpdatedBy = &userID
+	if err := s.repo.UpdateRecurringEntry(recurring); err != nil {
+		return nil, err
+	}
+
+	s.repo.LogChange("recurring_entry", id, "pause", recurring, userID, ip, userAgent)
+
+	return s.repo.GetRecurringEntryByID(id)
+}
+
+// ResumeRecurringEntry generates entries again; the occurrences that fell while it was
+// paused are skipped
+func (s *FinancialService) ResumeRecurringEntry(id string, userID string, ip string, userAgent string) (*models.RecurringEntry, error) {
+	recurring, err := s.GetRecurringEntryByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if recurring.Status != models.RecurringEntryStatusPaused {
+		return nil, ErrRecurringEntryNotPaused
+	}
+
+	recurring.Technician, recurring.Client = nil, nil
+	recurring.Status = models.RecurringEntryStatusActive
+	skipToToday(recurring)
+	recurring.UpdatedBy = &userID
+	if err := s.repo.UpdateRecurringEntry(recurring); err != nil {
+		return nil, err
+	}
+
+	s.repo.LogChange("recurring_entry", id, "resume", recurring, userID, ip, userAgent)
+
+	return s.repo.GetRecurringEntryByID(id)
+}
+
+// PreviewRecurringEntry lists the next occurrences of a recurring entry (count, default
+// 12), up to its end date
+func (s *FinancialService) PreviewRecurringEntry(id string, count int) ([]models.RecurringOccurrence, error) {
+	recurring, err := s.GetRecurringEntryByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if count <= 0 {
+		count = defaultRecurringPreview
+	}
+	if count > maxRecurringPreview {
+		count = maxRecurringPreview
+	}
+
+	occurrences := []models.RecurringOccurrence{}
+	if recurring.Status == models.RecurringEntryStatusFinished {
+		return occurrences, nil
+	}
+	for n := recurring.NextOccurrence; len(occurrences) < count; n++ {
+		date := recurring.OccurrenceDate(n)
+		if recurring.EndDate != nil && date.After(*recurring.EndDate) {
+			break
+		}
+		occurrences = append(occurrences, occurrenceOf(recurring, date))
+	}
+	return occurrences, nil
+}
+
+// GetUpcomingOccurrences lists the occurrences of every active recurring entry from
+// today up to the given number of days, by date
+func (s *FinancialService) GetUpcomingOccurrences(days int) ([]models.RecurringOccurrence, error) {
+	if days <= 0 || days > maxRecurringUpcomingDays {
+		days = 30
+	}
+	until := today().AddDate(0, 0, days)
+
+	recurring, err := s.repo.GetActiveRecurringEntries(until)
+	if err != nil {
+		return nil, err
+	}
+
+	occurrences := []models.RecurringOccurrence{}
+	for i := range recurring {
+		for n := recurring[i].NextOccurrence; ; n++ {
+			date := recurring[i].OccurrenceDate(n)
+			if date.After(until) || (recurring[i].EndDate != nil && date.After(*recurring[i].EndDate)) {
+				break
+			}
+			occurrences = append(occurrences, occurrenceOf(&recurring[i], date))
+		}
+	}
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		return occurrences[i].EntryDate.Before(occurrences[j].EntryDate)
+	})
+	return occurrences, nil
+}
+
+// MaterializeRecurringEntries creates the pending entries of every occurrence due up to
+// today and returns how many were created. An occurrence is generated once even if the
+// job runs twice: entries are unique per recurring entry and date.
+func (s *FinancialService) MaterializeRecurringEntries() (int, error) {
+	now := today()
+	recurring, err := s.repo.GetActiveRecurringEntries(now)
+	if err != nil {
+		return 0, err
+	}
+
+	created := 0
+	for i := range recurring {
+		r := &recurring[i]
+		// The job has no tenant, the entries go to the tenant of their recurring entry
+		repo := s.repo.WithContext(tenant.WithID(context.Background(), r.TenantID))
+		for r.Status == models.RecurringEntryStatusActive && !r.NextDate.After(now) {
+			entry := entryOf(r, r.NextDate)
+			r.LastEntryDate = &entry.EntryDate
+			advanceOccurrence(r)
+
+			ok, err := repo.MaterializeOccurrence(r, entry)
+			if err != nil {
+				return created, err
+			}
+			if ok {
+				created++
+				repo.LogChange("financial_entry", entry.ID, "create", entry, r.CreatedBy, "", "recurring-scheduler")
+			}
+		}
+	}
+	return created, nil
+}
+
+// Start runs MaterializeRecurringEntries right away and then every interval
+func (s *FinancialService) Start(interval time.Duration) {
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	run := func() {
+		created, err := s.MaterializeRecurringEntries()
+		if err != nil {
+			slog.Warn("Recurring financial entries failed", "error", err)
+		}
+		if created > 0 {
+			slog.Info("Recurring financial entries created", "created", created)
+		}
+	}
+
+	go func() {
+		defer close(s.done)
+		run()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				run()
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (s *FinancialService) Stop() {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+		s.stop = nil
+	}
+}
+
+// skipToToday moves the recurring entry to its first occurrence from today on, never
+// back, and finishes it when that is past its end date
+func skipToToday(r *models.RecurringEntry) {
+	now := today()
+	n := r.NextOccurrence
+	for r.OccurrenceDate(n).Before(now) {
+		n++
+	}
+	r.NextOccurrence = n
+	r.NextDate = r.OccurrenceDate(n)
+	if r.EndDate != nil && r.NextDate.After(*r.EndDate) {
+		r.Status = models.RecurringEntryStatusFinished
+	}
+}
+
+// advanceOccurrence moves the recurring entry to its next occurrence
+func advanceOccurrence(r *models.RecurringEntry) {
+	r.NextOccurrence++
+	r.NextDate = r.OccurrenceDate(r.NextOccurrence)
+	if r.EndDate != nil && r.NextDate.After(*r.EndDate) {
+		r.Status = models.RecurringEntryStatusFinished
+	}
+}
+
+// entryOf builds the pending entry of an occurrence
+func entryOf(r *models.RecurringEntry, date time.Time) *models.FinancialEntry {
+	occurrence := occurrenceOf(r, date)
+	recurringID := r.ID
+	return &models.FinancialEntry{
+		Type:             r.Type,
+		Category:         r.Category,
+		Subcategory:      r.Subcategory,
+		Description:      r.Description,
+		Amount:           r.Amount,
+		Currency:         r.Currency,
+		EntryDate:        date,
+		DueDate:          occurrence.DueDate,
+		Status:           models.FinancialEntryStatusPending,
+		TechnicianID:     r.TechnicianID,
+		ClientID:         r.ClientID,
+		RecurringEntryID: &recurringID,
+		PaymentMethod:    r.PaymentMethod,
+		CreatedBy:        r.CreatedBy,
+		Version:          1,
+	}
+}
+
+func occurrenceOf(r *models.RecurringEntry, date time.Time) models.RecurringOccurrence {
+	occurrence := models.RecurringOccurrence{
+		RecurringEntryID: r.ID,
+		Description:      r.Description,
+		Type:             r.Type,
+		Category:         r.Category,
+		Amount:           r.Amount,
+		EntryDate:        date,
+	}
+	if r.DueDays > 0 {
+		due := date.AddDate(0, 0, r.DueDays)
+		occurrence.DueDate = &due
+	}
+	return occurrence
+}
+
+// today is the current date at midnight UTC, like the dates parsed from YYYY-MM-DD
+func today() time.Time {
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func nilIfEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
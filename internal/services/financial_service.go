@@ -1,26 +1,96 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"time"
 
+	"github.com/shigake/tech-iq-back/internal/cache"
 	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
 	"github.com/shigake/tech-iq-back/internal/repositories"
+	"github.com/shigake/tech-iq-back/internal/tenant"
+	"github.com/shopspring/decimal"
 )
 
+// ErrTicketPayoutsExist is returned when the ticket already has pending or paid technician payments
+var ErrTicketPayoutsExist = errors.New("ticket already has technician payments, cancel them before paying it out again")
+
 type FinancialService struct {
-	repo         *repositories.FinancialRepository
-	categoryRepo repositories.CategoryRepository
+	repo          *repositories.FinancialRepository
+	categoryRepo  repositories.CategoryRepository
+	budgets       TicketBudgetService
+	supplierRepo  repositories.SupplierRepository
+	notifications NotificationService
+	events        EventPublisher
+	cache         *cache.RedisClient
+	stop          chan struct{}
+	done          chan struct{}
+}
+
+func NewFinancialService(repo *repositories.FinancialRepository, categoryRepo repositories.CategoryRepository, budgets TicketBudgetService, supplierRepo repositories.SupplierRepository, notifications NotificationService, events EventPublisher, cache *cache.RedisClient) *FinancialService {
+	return &FinancialService{repo: repo, categoryRepo: categoryRepo, budgets: budgets, supplierRepo: supplierRepo, notifications: notifications, events: events, cache: cache}
 }
 
-func NewFinancialService(repo *repositories.FinancialRepository, categoryRepo repositories.CategoryRepository) *FinancialService {
-	return &FinancialService{repo: repo, categoryRepo: categoryRepo}
+// WithContext returns the service running its queries with ctx, inside the tenant it
+// carries. The recurring entries loop is left to the service it was called on.
+func (s *FinancialService) WithContext(ctx context.Context) *FinancialService {
+	scoped := *s
+	scoped.repo = s.repo.WithContext(ctx)
+	scoped.categoryRepo = s.categoryRepo.WithContext(ctx)
+	if s.events != nil {
+		scoped.events = s.events.ForContext(ctx)
+	}
+	return &scoped
 }
 
 // =============== Financial Entries ===============
 
 // CreateEntry creates a new financial entry
 func (s *FinancialService) CreateEntry(req models.CreateFinancialEntryRequest, userID string, ip string, userAgent string) (*models.FinancialEntry, error) {
+	entry, err := s.newEntry(req, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkTicketBudget(entry, ""); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.CreateEntry(entry); err != nil {
+		return nil, err
+	}
+
+	// Audit log
+	s.repo.LogChange("financial_entry", entry.ID, "create", entry, userID, ip, userAgent)
+
+	return s.repo.GetEntryByID(entry.ID)
+}
+
+// CreateTicketIncome creates the closing income of a ticket together with the billing of
+// its parts. It returns false, creating nothing, when the ticket already has one.
+func (s *FinancialService) CreateTicketIncome(req models.CreateFinancialEntryRequest, parts []models.TicketPart, userID string, ip string, userAgent string) (*models.FinancialEntry, bool, error) {
+	entry, err := s.newEntry(req, userID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	partIDs := make([]string, len(parts))
+	for i, part := range parts {
+		partIDs[i] = part.ID
+	}
+	created, err := s.repo.CreateTicketIncome(entry, partIDs)
+	if err != nil || !created {
+		return nil, false, err
+	}
+
+	s.repo.LogChange("financial_entry", entry.ID, "create", entry, userID, ip, userAgent)
+	return entry, true, nil
+}
+
+// newEntry validates the request and builds the pending entry it describes
+func (s *FinancialService) newEntry(req models.CreateFinancialEntryRequest, userID string) (*models.FinancialEntry, error) {
 	// Validate category
 	if !s.ValidateCategory(req.Type, req.Category, req.Subcategory) {
 		return nil, errors.New("invalid category or subcategory for the given type")
@@ -67,15 +137,97 @@ func (s *FinancialService) CreateEntry(req models.CreateFinancialEntryRequest, u
 	if req.ClientID != "" {
 		entry.ClientID = &req.ClientID
 	}
+	if req.SupplierID != "" {
+		if err := activeSupplier(s.supplierRepo, req.SupplierID); err != nil {
+			return nil, err
+		}
+		entry.SupplierID = &req.SupplierID
+	}
 
-	if err := s.repo.CreateEntry(entry); err != nil {
-		return nil, err
+	return entry, nil
+}
+
+// checkTicketBudget refuses expenses that take their ticket over its expense budget.
+// Technician labor payouts are not counted against it.
+func (s *FinancialService) checkTicketBudget(entry *models.FinancialEntry, entryID string) error {
+	if s.budgets == nil || entry.TicketID == nil || entry.Type != models.FinancialEntryTypeExpense ||
+		entry.Status == models.FinancialEntryStatusCancelled {
+		return nil
+	}
+	if entry.Category == "technician_payment" {
+		for _, sub := range models.BudgetExcludedPayouts {
+			if entry.Subcategory == sub {
+				return nil
+			}
+		}
+	}
+	return s.budgets.CheckExpense(*entry.TicketID, decimal.NewFromFloat(entry.Amount), entryID)
+}
+
+// CreateTicketPayouts creates one pending technician payment per ticket assignee.
+// Entries use the technician_payment category so they show up in the
+// technician payments report. A ticket is paid out once: the payments must be
+// cancelled before it can be paid out again.
+func (s *FinancialService) CreateTicketPayouts(ticketID string, shares []models.TicketPayoutShare, req models.CreateTicketPayoutRequest, userID string, ip string, userAgent string) ([]models.FinancialEntry, error) {
+	if len(shares) == 0 {
+		return nil, errors.New("ticket has no assigned technicians")
 	}
 
-	// Audit log
-	s.repo.LogChange("financial_entry", entry.ID, "create", entry, userID, ip, userAgent)
+	entryDate, err := time.Parse("2006-01-02", req.EntryDate)
+	if err != nil {
+		return nil, errors.New("invalid entry date format, expected YYYY-MM-DD")
+	}
 
-	return s.repo.GetEntryByID(entry.ID)
+	var dueDate *time.Time
+	if req.DueDate != "" {
+		parsed, err := time.Parse("2006-01-02", req.DueDate)
+		if err != nil {
+			return nil, errors.New("invalid due date format, expected YYYY-MM-DD")
+		}
+		dueDate = &parsed
+	}
+
+	description := req.Description
+	if description == "" {
+		description = "Pagamento de técnico"
+	}
+
+	entries := make([]models.FinancialEntry, 0, len(shares))
+	for _, share := range shares {
+		if share.Amount <= 0 {
+			continue
+		}
+		ticket := ticketID
+		technician := share.TechnicianID
+		entry := models.FinancialEntry{
+			Type:         models.FinancialEntryTypeExpense,
+			Category:     "technician_payment",
+			Subcategory:  "commission",
+			Description:  description + " (" + string(share.Role) + ")",
+			Amount:       share.Amount,
+			EntryDate:    entryDate,
+			DueDate:      dueDate,
+			Status:       models.FinancialEntryStatusPending,
+			TicketID:     &ticket,
+			TechnicianID: &technician,
+			CreatedBy:    userID,
+			Version:      1,
+		}
+		entries = append(entries, entry)
+	}
+
+	created, err := s.repo.CreateTicketPayouts(ticketID, entries)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		return nil, ErrTicketPayoutsExist
+	}
+	for i := range entries {
+		s.repo.LogChange("financial_entry", entries[i].ID, "create", &entries[i], userID, ip, userAgent)
+	}
+
+	return entries, nil
 }
 
 // GetEntryByID retrieves a financial entry by ID
@@ -148,6 +300,12 @@ func (s *FinancialService) UpdateEntry(id string, req models.UpdateFinancialEntr
 	if req.ClientID != "" {
 		updated.ClientID = &req.ClientID
 	}
+	if req.SupplierID != "" && req.SupplierID != ptrToString(existing.SupplierID) {
+		if err := activeSupplier(s.supplierRepo, req.SupplierID); err != nil {
+			return nil, err
+		}
+		updated.SupplierID = &req.SupplierID
+	}
 	if req.PaymentMethod != "" {
 		updated.PaymentMethod = req.PaymentMethod
 	}
@@ -160,6 +318,10 @@ func (s *FinancialService) UpdateEntry(id string, req models.UpdateFinancialEntr
 	updated.UpdatedBy = &userID
 	updated.Version = req.Version + 1
 
+	if err := s.checkTicketBudget(&updated, id); err != nil {
+		return nil, err
+	}
+
 	if err := s.repo.UpdateEntry(&updated); err != nil {
 		if errors.Is(err, errors.New("record not found")) {
 			return nil, errors.New("entry was modified by another user, please refresh and try again")
@@ -237,6 +399,19 @@ func (s *FinancialService) ListEntries(filter models.FinancialEntryFilter) ([]mo
 	return s.repo.ListEntries(filter)
 }
 
+// ListEntriesByCursor lists the entries page at filter.Cursor, with the cursors of the
+// pages after and before it
+func (s *FinancialService) ListEntriesByCursor(filter models.FinancialEntryFilter) ([]models.FinancialEntry, string, string, error) {
+	entries, err := s.repo.ListEntriesByCursor(filter)
+	if err != nil {
+		return nil, "", "", err
+	}
+	entries, next, prev := pagination.CursorPage(entries, *filter.Cursor, filter.Limit, func(e models.FinancialEntry) ([]time.Time, string) {
+		return []time.Time{e.EntryDate, e.CreatedAt}, e.ID
+	})
+	return entries, next, prev, nil
+}
+
 // =============== Payment Batches ===============
 
 // CreateBatch creates a new payment batch
@@ -357,7 +532,47 @@ func (s *FinancialService) ApproveBatch(batchID string, userID string, ip string
 
 	s.repo.LogChange("payment_batch", batchID, "approve", nil, userID, ip, userAgent)
 
-	return s.repo.GetBatchByID(batchID)
+	approved, err := s.repo.GetBatchByID(batchID)
+	if err != nil {
+		return nil, err
+	}
+	s.notifyBatchApproved(approved, userID)
+	return approved, nil
+}
+
+// notifyBatchApproved tells the batch creator and the technicians paid by it that the
+// batch was approved
+func (s *FinancialService) notifyBatchApproved(batch *models.PaymentBatch, approverID string) {
+	if s.notifications == nil {
+		return
+	}
+	notification := models.Notification{
+		Event:        models.NotificationPaymentBatchApproved,
+		Title:        fmt.Sprintf("Lote de pagamento %s aprovado", batch.Name),
+		ResourceType: "PAYMENT_BATCH",
+		ResourceID:   batch.ID,
+	}
+
+	notification.Message = fmt.Sprintf("%d lançamentos, total R$ %.2f, período %s a %s",
+		batch.EntriesCount, batch.TotalAmount, batch.PeriodStart.Format("02/01/2006"), batch.PeriodEnd.Format("02/01/2006"))
+	if batch.CreatedBy != approverID {
+		go s.notifications.Notify([]string{batch.CreatedBy}, notification)
+	}
+
+	var technicianIDs []string
+	seen := make(map[string]bool)
+	for _, entry := range batch.Entries {
+		if entry.TechnicianID != nil && !seen[*entry.TechnicianID] {
+			seen[*entry.TechnicianID] = true
+			technicianIDs = append(technicianIDs, *entry.TechnicianID)
+		}
+	}
+	if len(technicianIDs) > 0 {
+		paid := notification
+		paid.Message = fmt.Sprintf("Seus pagamentos do período %s a %s foram aprovados",
+			batch.PeriodStart.Format("02/01/2006"), batch.PeriodEnd.Format("02/01/2006"))
+		go s.notifications.NotifyTechnicians(technicianIDs, paid)
+	}
 }
 
 // PayBatch marks a batch as paid
@@ -377,7 +592,14 @@ func (s *FinancialService) PayBatch(batchID string, req models.PayBatchRequest,
 
 	s.repo.LogChange("payment_batch", batchID, "pay", req, userID, ip, userAgent)
 
-	return s.repo.GetBatchByID(batchID)
+	paid, err := s.repo.GetBatchByID(batchID)
+	if err != nil {
+		return nil, err
+	}
+	if s.events != nil {
+		go s.events.Publish(models.WebhookEventFinancialBatchPaid, paid)
+	}
+	return paid, nil
 }
 
 // DeleteBatch deletes a payment batch
@@ -404,8 +626,23 @@ func (s *FinancialService) DeleteBatch(batchID string, userID string, ip string,
 
 // GetDashboard retrieves financial dashboard data
 func (s *FinancialService) GetDashboard(filter models.DashboardFilter) (*models.FinancialDashboard, error) {
+	tenantID := filter.TenantID
+	if tenantID == "" {
+		tenantID = tenant.DefaultID
+	}
+	key := cache.FinancialDashboardCacheKey(tenantID, filter.Period, filter.StartDate, filter.EndDate, filter.Clients.TagID, filter.Clients.Segment)
+	var dashboard models.FinancialDashboard
+	if !filter.Fresh && cached(s.cache, key, &dashboard) {
+		return &dashboard, nil
+	}
+
 	startDate, endDate := s.getPeriodDates(filter.Period, filter.StartDate, filter.EndDate)
-	return s.repo.GetDashboardData(startDate, endDate)
+	data, err := s.repo.GetDashboardData(startDate, endDate, filter.Clients)
+	if err != nil {
+		return nil, err
+	}
+	setCached(s.cache, key, data, cache.FinancialDashboardTTL)
+	return data, nil
 }
 
 // GetCashFlowReport retrieves cash flow report
@@ -424,7 +661,7 @@ func (s *FinancialService) GetCashFlowReport(filter models.CashFlowFilter) (*mod
 		groupBy = "day"
 	}
 
-	return s.repo.GetCashFlowReport(startDate, endDate, groupBy)
+	return s.repo.GetCashFlowReport(startDate, endDate, groupBy, filter.Clients)
 }
 
 // GetTechnicianPaymentsReport retrieves technician payments report
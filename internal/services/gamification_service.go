@@ -0,0 +1,362 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"gorm.io/gorm"
+)
+
+// Points of each achievement
+const (
+	pointsClosedTicket  = 10
+	pointsOnTimeCheckIn = 5
+	pointsPromoter      = 20
+)
+
+const (
+	// A check-in up to this long after the scheduled start is still on time
+	onTimeCheckInGrace = 15 * time.Minute
+	// NPS answers credit the technicians of the client's tickets closed this long before
+	promoterWindow = 30 * 24 * time.Hour
+)
+
+var (
+	ErrBadgeNotFound             = errors.New("badge not found")
+	ErrBadgeCodeTaken            = errors.New("badge code already in use")
+	ErrInvalidGamificationPeriod = errors.New("invalid period, expected WEEK or MONTH")
+)
+
+// GamificationService scores the opted-in technicians on closed tickets, on-time check-ins
+// and NPS promoters, ranks them per region and awards the badges defined by admins
+type GamificationService interface {
+	Leaderboard(period, state string) (*models.Leaderboard, error)
+	GetMine(userID string) (*models.MyGamification, error)
+	UpdateMine(userID string, req *models.GamificationProfileRequest) (*models.GamificationProfile, error)
+
+	ListBadges(activeOnly bool) ([]models.GamificationBadge, error)
+	CreateBadge(req *models.GamificationBadgeRequest) (*models.GamificationBadge, error)
+	UpdateBadge(id string, req *models.GamificationBadgeRequest) (*models.GamificationBadge, error)
+	DeleteBadge(id string) error
+}
+
+type gamificationService struct {
+	repo           repositories.GamificationRepository
+	technicianRepo repositories.TechnicianRepository
+}
+
+func NewGamificationService(repo repositories.GamificationRepository, technicianRepo repositories.TechnicianRepository) GamificationService {
+	return &gamificationService{repo: repo, technicianRepo: technicianRepo}
+}
+
+// gamificationPeriod returns the current week (from Monday) or month, in the local time
+// of the technicians
+func gamificationPeriod(period string, now time.Time) (from, to time.Time, err error) {
+	loc, err := time.LoadLocation(homeTimezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	now = now.In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	switch period {
+	case models.GamificationPeriodWeek:
+		from = today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+		return from, from.AddDate(0, 0, 7), nil
+	case models.GamificationPeriodMonth:
+		from = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+		return from, from.AddDate(0, 1, 0), nil
+	default:
+		return from, to, ErrInvalidGamificationPeriod
+	}
+}
+
+func (s *gamificationService) Leaderboard(period, state string) (*models.Leaderboard, error) {
+	period = strings.ToUpper(period)
+	if period == "" {
+		period = models.GamificationPeriodWeek
+	}
+	from, to, err := gamificationPeriod(period, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	candidates, err := s.repo.FindCandidates(strings.TrimSpace(state))
+	if err != nil {
+		return nil, err
+	}
+	entries, err := s.rank(candidates, period, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Leaderboard{Period: period, From: from, To: to, State: state, Entries: entries}, nil
+}
+
+// rank scores the candidates over the period, best first; ties share the rank
+func (s *gamificationService) rank(candidates []models.GamificationCandidate, period string, from, to time.Time) ([]models.LeaderboardEntry, error) {
+	entries := []models.LeaderboardEntry{}
+	if len(candidates) == 0 {
+		return entries, nil
+	}
+
+	ids := make([]string, 0, len(candidates))
+	for _, candidate := range candidates {
+		ids = append(ids, candidate.TechnicianID)
+	}
+	stats, err := s.stats(ids, from, to)
+	if err != nil {
+		return nil, err
+	}
+	badges, err := s.periodBadges(period)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, candidate := range candidates {
+		name := candidate.DisplayName
+		if name == "" {
+			name = candidate.FullName
+		}
+		entries = append(entries, models.LeaderboardEntry{
+			TechnicianID:      candidate.TechnicianID,
+			DisplayName:       name,
+			State:             candidate.State,
+			GamificationStats: stats[candidate.TechnicianID],
+			Badges:            earnedBadges(badges, stats[candidate.TechnicianID]),
+		})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Points != entries[j].Points {
+			return entries[i].Points > entries[j].Points
+		}
+		return entries[i].DisplayName < entries[j].DisplayName
+	})
+	for i := range entries {
+		entries[i].Rank = i + 1
+		if i > 0 && entries[i].Points == entries[i-1].Points {
+			entries[i].Rank = entries[i-1].Rank
+		}
+	}
+	return entries, nil
+}
+
+func (s *gamificationService) stats(ids []string, from, to time.Time) (map[string]models.GamificationStats, error) {
+	closed, err := s.repo.CountClosedTickets(ids, from, to)
+	if err != nil {
+		return nil, err
+	}
+	onTime, err := s.repo.CountOnTimeCheckIns(ids, from, to, onTimeCheckInGrace)
+	if err != nil {
+		return nil, err
+	}
+	promoters, err := s.repo.CountPromoters(ids, from, to, promoterWindow)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make(map[string]models.GamificationStats, len(ids))
+	for _, id := range ids {
+		stat := models.GamificationStats{
+			ClosedTickets:  closed[id],
+			OnTimeCheckIns: onTime[id],
+			Promoters:      promoters[id],
+		}
+		stat.Points = stat.ClosedTickets*pointsClosedTicket + stat.OnTimeCheckIns*pointsOnTimeCheckIn + stat.Promoters*pointsPromoter
+		stats[id] = stat
+	}
+	return stats, nil
+}
+
+func (s *gamificationService) periodBadges(period string) ([]models.GamificationBadge, error) {
+	badges, err := s.repo.FindBadges(true)
+	if err != nil {
+		return nil, err
+	}
+	matching := badges[:0]
+	for _, badge := range badges {
+		if badge.Period == period {
+			matching = append(matching, badge)
+		}
+	}
+	return matching, nil
+}
+
+func earnedBadges(badges []models.GamificationBadge, stats models.GamificationStats) []models.GamificationBadge {
+	earned := []models.GamificationBadge{}
+	for _, badge := range badges {
+		var value int64
+		switch badge.Metric {
+		case models.BadgeMetricPoints:
+			value = stats.Points
+		case models.BadgeMetricClosedTickets:
+			value = stats.ClosedTickets
+		case models.BadgeMetricOnTimeCheckIns:
+			value = stats.OnTimeCheckIns
+		case models.BadgeMetricPromoters:
+			value = stats.Promoters
+		}
+		if value >= badge.Threshold {
+			earned = append(earned, badge)
+		}
+	}
+	return earned
+}
+
+// GetMine returns the technician's own stats, also while opted out; the rank in the
+// regional leaderboard is only given once opted in
+func (s *gamificationService) GetMine(userID string) (*models.MyGamification, error) {
+	technician, profile, err := s.profileOf(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.MyGamification{Profile: *profile}
+	for _, period := range []string{models.GamificationPeriodWeek, models.GamificationPeriodMonth} {
+		from, to, err := gamificationPeriod(period, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		periodStats := models.GamificationPeriodStats{Period: period, From: from}
+
+		if profile.OptedIn {
+			candidates, err := s.repo.FindCandidates(technician.State)
+			if err != nil {
+				return nil, err
+			}
+			entries, err := s.rank(candidates, period, from, to)
+			if err != nil {
+				return nil, err
+			}
+			for _, entry := range entries {
+				if entry.TechnicianID == technician.ID {
+					periodStats.GamificationStats = entry.GamificationStats
+					periodStats.Rank = entry.Rank
+					periodStats.Badges = entry.Badges
+				}
+			}
+		} else {
+			stats, err := s.stats([]string{technician.ID}, from, to)
+			if err != nil {
+				return nil, err
+			}
+			badges, err := s.periodBadges(period)
+			if err != nil {
+				return nil, err
+			}
+			periodStats.GamificationStats = stats[technician.ID]
+			periodStats.Badges = earnedBadges(badges, stats[technician.ID])
+		}
+		result.Periods = append(result.Periods, periodStats)
+	}
+	return result, nil
+}
+
+// UpdateMine opts the technician in or out; opting out removes them from the leaderboards
+// right away
+func (s *gamificationService) UpdateMine(userID string, req *models.GamificationProfileRequest) (*models.GamificationProfile, error) {
+	_, profile, err := s.profileOf(userID)
+	if err != nil {
+		return nil, err
+	}
+	if req.OptedIn && !profile.OptedIn {
+		now := time.Now()
+		profile.OptedInAt = &now
+	}
+	if !req.OptedIn {
+		profile.OptedInAt = nil
+	}
+	profile.OptedIn = req.OptedIn
+	profile.DisplayName = strings.TrimSpace(req.DisplayName)
+	if err := s.repo.SaveProfile(profile); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// profileOf returns the technician of the user and their profile, opted out by default
+func (s *gamificationService) profileOf(userID string) (*models.Technician, *models.GamificationProfile, error) {
+	technician, err := s.technicianRepo.FindByUserID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrNotATechnician
+		}
+		return nil, nil, err
+	}
+	profile, err := s.repo.FindProfile(technician.ID)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, err
+		}
+		profile = &models.GamificationProfile{TechnicianID: technician.ID}
+	}
+	return technician, profile, nil
+}
+
+func (s *gamificationService) ListBadges(activeOnly bool) ([]models.GamificationBadge, error) {
+	return s.repo.FindBadges(activeOnly)
+}
+
+func (s *gamificationService) CreateBadge(req *models.GamificationBadgeRequest) (*models.GamificationBadge, error) {
+	badge := &models.GamificationBadge{Active: true}
+	if err := s.applyBadge(badge, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateBadge(badge); err != nil {
+		return nil, err
+	}
+	return badge, nil
+}
+
+func (s *gamificationService) UpdateBadge(id string, req *models.GamificationBadgeRequest) (*models.GamificationBadge, error) {
+	badge, err := s.repo.FindBadgeByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBadgeNotFound
+		}
+		return nil, err
+	}
+	if err := s.applyBadge(badge, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateBadge(badge); err != nil {
+		return nil, err
+	}
+	return badge, nil
+}
+
+func (s *gamificationService) applyBadge(badge *models.GamificationBadge, req *models.GamificationBadgeRequest) error {
+	code := strings.ToUpper(strings.TrimSpace(req.Code))
+	existing, err := s.repo.FindBadgeByCode(code)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if existing != nil && existing.ID != badge.ID {
+		return ErrBadgeCodeTaken
+	}
+
+	badge.Code = code
+	badge.Name = strings.TrimSpace(req.Name)
+	badge.Description = req.Description
+	badge.Icon = req.Icon
+	badge.Metric = req.Metric
+	badge.Threshold = req.Threshold
+	badge.Period = req.Period
+	if req.Active != nil {
+		badge.Active = *req.Active
+	}
+	return nil
+}
+
+func (s *gamificationService) DeleteBadge(id string) error {
+	if err := s.repo.DeleteBadge(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrBadgeNotFound
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shigake/tech-iq-back/internal/models"
+)
+
+const (
+	defaultGeoBackfillLimit = 1000
+	maxGeoBackfillLimit     = 5000
+)
+
+// Raio aproximado dos pontos do backfill conforme a precisão do endereço
+var backfillAccuracyM = map[string]float64{
+	models.GeoPrecisionCity:  5000,
+	models.GeoPrecisionState: 50000,
+}
+
+// BackfillFromTickets preenche dados históricos para as análises e o mapa de calor: geocodifica
+// os endereços dos clientes (provedor configurado, senão cidade ou capital do estado) e registra um CHECKIN aproximado
+// de cada técnico nos tickets antigos sem localização. Só considera visitas dentro da retenção,
+// já que as anteriores seriam removidas na próxima limpeza. Cada execução processa até limit
+// clientes e visitas; é idempotente, então pode ser repetida enquanto Remaining for true.
+func (s *GeoService) BackfillFromTickets(userID string, req *models.GeoBackfillRequest) (*models.GeoBackfillResult, error) {
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultGeoBackfillLimit
+	}
+	if limit > maxGeoBackfillLimit {
+		limit = maxGeoBackfillLimit
+	}
+
+	settings, err := s.geoRepo.GetGeoSettings(nil)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	result := &models.GeoBackfillResult{
+		Since:  now.AddDate(0, 0, -settings.RetentionDays),
+		DryRun: req.DryRun,
+	}
+
+	clients, err := s.geoRepo.FindClientsToGeocode(limit)
+	if err != nil {
+		return nil, err
+	}
+	for i := range clients {
+		client := &clients[i]
+		geocoded := s.geocoder.Lookup(client)
+		if geocoded.Precision == models.GeoPrecisionNone {
+			result.ClientsNotFound++
+		} else {
+			result.ClientsGeocoded++
+		}
+		if req.DryRun {
+			continue
+		}
+		if err := s.geoRepo.UpdateClientCoordinates(client.ID, geocoded.Latitude, geocoded.Longitude, geocoded.Precision, now); err != nil {
+			return nil, err
+		}
+	}
+
+	// No dry run os clientes acima não foram gravados, então só contam as visitas dos já geocodificados
+	visits, err := s.geoRepo.FindVisitsToBackfill(result.Since, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	provider := models.LocationProviderBackfill
+	locations := make([]models.TechnicianLocation, 0, len(visits))
+	for _, v := range visits {
+		ticketID, err := uuid.Parse(v.TicketID)
+		if err != nil {
+			continue
+		}
+		accuracy := backfillAccuracyM[v.GeoPrecision]
+		visitedAt := v.VisitedAt
+		locations = append(locations, models.TechnicianLocation{
+			TechnicianID:  v.TechnicianID,
+			TicketID:      &ticketID,
+			EventType:     models.EventTypeCheckin,
+			Latitude:      v.Latitude,
+			Longitude:     v.Longitude,
+			AccuracyM:     &accuracy,
+			Provider:      &provider,
+			DeviceTime:    &visitedAt,
+			ServerTime:    visitedAt,
+			IsOfflineSync: true,
+		})
+	}
+	if !req.DryRun && len(locations) > 0 {
+		if err := s.geoRepo.CreateLocations(locations); err != nil {
+			return nil, err
+		}
+	}
+	result.LocationsCreated = len(locations)
+	result.Remaining = len(clients) == limit || len(visits) == limit
+
+	if !req.DryRun && s.activityLogService != nil {
+		description := fmt.Sprintf("Backfill geográfico: %d clientes geocodificados, %d não encontrados, %d localizações",
+			result.ClientsGeocoded, result.ClientsNotFound, result.LocationsCreated)
+		if err := s.activityLogService.LogAction(userID, "geo_backfill", "technician_location", "", description, "", ""); err != nil {
+			slog.Warn("Failed to audit geo backfill", "error", err)
+		}
+	}
+	return result, nil
+}
+
+// geocodeAddress aproxima as coordenadas do endereço pela cidade ou, sem ela, pela capital do
+// estado; diferente de GetCoordinatesForLocation, não cai em Brasília quando nada é encontrado
+func geocodeAddress(city, state string) (*float64, *float64, string) {
+	city = strings.TrimSpace(city)
+	if coords, ok := BrazilCityCoordinates[city]; ok && city != "" {
+		return &coords.Latitude, &coords.Longitude, models.GeoPrecisionCity
+	}
+	if coords, ok := BrazilStateCoordinates[strings.ToUpper(strings.TrimSpace(state))]; ok {
+		return &coords.Latitude, &coords.Longitude, models.GeoPrecisionState
+	}
+	return nil, nil, models.GeoPrecisionNone
+}
+
+// GeocodeClient devolve as coordenadas do cliente, geocodificando e gravando o endereço quando
+// ainda não foi feito
+func (s *GeoService) GeocodeClient(client *models.Client) (*float64, *float64, string) {
+	return s.geocoder.Locate(client)
+}
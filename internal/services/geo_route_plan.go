@@ -0,0 +1,288 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRoutePlanTechnicianNotFound = errors.New("técnico não encontrado")
+	ErrRoutePlanNotFound           = errors.New("nenhum plano de rota gravado para este dia")
+	ErrRoutePlanInvalidDate        = errors.New("data inválida, use YYYY-MM-DD")
+	ErrRoutePlanInvalidStart       = errors.New("informe startLatitude e startLongitude juntos")
+)
+
+const (
+	routePlanAlgorithm  = "NEAREST_NEIGHBOR_2OPT"
+	routePlanMaxTwoOpt  = 50 // passadas de melhoria do 2-opt
+	geoPrecisionExact   = "EXACT"
+	routePlanDateLayout = "2006-01-02"
+)
+
+type routePoint struct {
+	lat, lng float64
+}
+
+func (a routePoint) km(b routePoint) float64 {
+	return CalculateDistance(a.lat, a.lng, b.lat, b.lng) / 1000
+}
+
+// PlanRoute calcula a ordem de visitas dos tickets agendados do técnico no dia: vizinho mais
+// próximo a partir do ponto de partida, refinado com 2-opt. Tickets sem coordenadas ficam no
+// fim da lista, sem ordem.
+func (s *GeoService) PlanRoute(technicianID string, req *models.RoutePlanRequest) (*models.TechnicianRoutePlan, error) {
+	technician, err := s.technicianRepo.FindByID(technicianID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRoutePlanTechnicianNotFound
+		}
+		return nil, err
+	}
+	if (req.StartLatitude == nil) != (req.StartLongitude == nil) {
+		return nil, ErrRoutePlanInvalidStart
+	}
+
+	loc, err := time.LoadLocation(homeTimezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	now := time.Now().In(loc)
+	date := req.Date
+	if date == "" {
+		date = now.Format(routePlanDateLayout)
+	}
+	dayStart, err := time.ParseInLocation(routePlanDateLayout, date, loc)
+	if err != nil {
+		return nil, ErrRoutePlanInvalidDate
+	}
+	dayEnd := dayStart.AddDate(0, 0, 1)
+
+	tickets, err := s.geoRepo.FindRouteTickets(technician.ID, dayStart, dayEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	speed := models.DefaultSchedulingSettings().AverageSpeedKmh
+	plan := &models.TechnicianRoutePlan{
+		TechnicianID:    technician.ID,
+		Date:            date,
+		Algorithm:       routePlanAlgorithm,
+		AverageSpeedKmh: speed,
+		Stops:           models.RouteStops{},
+	}
+
+	// Paradas com coordenadas, na ordem dos horários agendados
+	var located []models.RouteStop
+	var points []routePoint
+	var unlocated []models.RouteStop
+	for _, ticket := range tickets {
+		stop := routeStop(&ticket)
+		if stop.Latitude == nil || stop.Longitude == nil {
+			unlocated = append(unlocated, stop)
+			continue
+		}
+		located = append(located, stop)
+		points = append(points, routePoint{*stop.Latitude, *stop.Longitude})
+	}
+
+	start, source := s.routeStart(technician, req, points, dayStart, dayEnd)
+	plan.StartSource = source
+	if start != nil {
+		plan.StartLatitude, plan.StartLongitude = &start.lat, &start.lng
+	}
+
+	if len(points) > 0 {
+		order := nearestNeighborRoute(*start, points)
+		order = twoOptRoute(*start, order, points)
+
+		previous := *start
+		for i, idx := range order {
+			stop := located[idx]
+			legKm := previous.km(points[idx])
+			stop.Order = i + 1
+			stop.LegDistanceKm = round2(legKm)
+			stop.LegMinutes = int(math.Ceil(legKm / speed * 60))
+			plan.TotalDistanceKm += legKm
+			plan.TotalTravelMinutes += stop.LegMinutes
+			plan.Stops = append(plan.Stops, stop)
+			previous = points[idx]
+		}
+
+		baseline := make([]int, len(points))
+		for i := range baseline {
+			baseline[i] = i
+		}
+		plan.BaselineDistanceKm = round2(routeLength(*start, baseline, points))
+		plan.TotalDistanceKm = round2(plan.TotalDistanceKm)
+	}
+	plan.Stops = append(plan.Stops, unlocated...)
+
+	return plan, nil
+}
+
+// SaveRoutePlan calcula e grava o plano do dia, substituindo o anterior
+func (s *GeoService) SaveRoutePlan(userID, technicianID string, req *models.RoutePlanRequest) (*models.TechnicianRoutePlan, error) {
+	plan, err := s.PlanRoute(technicianID, req)
+	if err != nil {
+		return nil, err
+	}
+	plan.CreatedBy = userID
+	if err := s.geoRepo.SaveRoutePlan(plan); err != nil {
+		return nil, err
+	}
+	return s.GetSavedRoutePlan(plan.TechnicianID, plan.Date)
+}
+
+// GetSavedRoutePlan obtém o plano gravado do técnico no dia (padrão: hoje)
+func (s *GeoService) GetSavedRoutePlan(technicianID, date string) (*models.TechnicianRoutePlan, error) {
+	if date == "" {
+		loc, err := time.LoadLocation(homeTimezone)
+		if err != nil {
+			loc = time.UTC
+		}
+		date = time.Now().In(loc).Format(routePlanDateLayout)
+	} else if _, err := time.Parse(routePlanDateLayout, date); err != nil {
+		return nil, ErrRoutePlanInvalidDate
+	}
+
+	plan, err := s.geoRepo.GetRoutePlan(technicianID, date)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRoutePlanNotFound
+		}
+		return nil, err
+	}
+	plan.Saved = true
+	return plan, nil
+}
+
+// routeStart escolhe o ponto de partida: o informado, a última localização do técnico no
+// dia, a cidade do cadastro ou, sem nenhuma referência, a primeira visita agendada
+func (s *GeoService) routeStart(technician *models.Technician, req *models.RoutePlanRequest, points []routePoint, dayStart, dayEnd time.Time) (*routePoint, string) {
+	if req.StartLatitude != nil && req.StartLongitude != nil {
+		return &routePoint{*req.StartLatitude, *req.StartLongitude}, models.RouteStartRequest
+	}
+
+	// As localizações podem estar gravadas com o ID do usuário vinculado
+	ids := []string{technician.ID}
+	if technician.UserID != nil {
+		ids = append(ids, *technician.UserID)
+	}
+	for _, id := range ids {
+		last, err := s.geoRepo.GetLastLocation(id)
+		if err == nil && !last.ServerTime.Before(dayStart) && last.ServerTime.Before(dayEnd) {
+			return &routePoint{last.Latitude, last.Longitude}, models.RouteStartLastLocation
+		}
+	}
+
+	if lat, lng, precision := geocodeAddress(technician.City, technician.State); precision != models.GeoPrecisionNone {
+		return &routePoint{*lat, *lng}, models.RouteStartBase
+	}
+	if len(points) > 0 {
+		return &points[0], models.RouteStartFirstStop
+	}
+	return nil, models.RouteStartFirstStop
+}
+
+// routeStop monta a parada do ticket com as coordenadas do cliente, ou as da cidade/estado
+func routeStop(ticket *models.Ticket) models.RouteStop {
+	stop := models.RouteStop{
+		TicketID:       ticket.ID,
+		OSNumber:       ticket.OSNumber,
+		Status:         string(ticket.Status),
+		ClientID:       ticket.ClientID,
+		ScheduledStart: ticket.ScheduledStart,
+		GeoPrecision:   models.GeoPrecisionNone,
+	}
+	client := ticket.Client
+	if client == nil {
+		return stop
+	}
+	stop.ClientName, stop.City, stop.State = client.FullName, client.City, client.State
+
+	if client.Latitude != nil && client.Longitude != nil {
+		stop.Latitude, stop.Longitude = client.Latitude, client.Longitude
+		stop.GeoPrecision = client.GeoPrecision
+		if stop.GeoPrecision == "" {
+			stop.GeoPrecision = geoPrecisionExact
+		}
+		return stop
+	}
+	stop.Latitude, stop.Longitude, stop.GeoPrecision = geocodeAddress(client.City, client.State)
+	return stop
+}
+
+// nearestNeighborRoute visita sempre o ponto mais próximo ainda não visitado
+func nearestNeighborRoute(start routePoint, points []routePoint) []int {
+	visited := make([]bool, len(points))
+	order := make([]int, 0, len(points))
+	current := start
+	for len(order) < len(points) {
+		next, best := -1, math.MaxFloat64
+		for i, p := range points {
+			if visited[i] {
+				continue
+			}
+			if d := current.km(p); d < best {
+				next, best = i, d
+			}
+		}
+		visited[next] = true
+		order = append(order, next)
+		current = points[next]
+	}
+	return order
+}
+
+// twoOptRoute inverte trechos da rota enquanto isso a encurtar (rota aberta, partida fixa)
+func twoOptRoute(start routePoint, order []int, points []routePoint) []int {
+	at := func(i int) routePoint {
+		if i < 0 {
+			return start
+		}
+		return points[order[i]]
+	}
+
+	for pass := 0; pass < routePlanMaxTwoOpt; pass++ {
+		improved := false
+		for i := 0; i < len(order)-1; i++ {
+			for j := i + 1; j < len(order); j++ {
+				// Troca as arestas (i-1, i) e (j, j+1) por (i-1, j) e (i, j+1)
+				before := at(i - 1).km(at(i))
+				after := at(i - 1).km(at(j))
+				if j+1 < len(order) {
+					before += at(j).km(at(j + 1))
+					after += at(i).km(at(j + 1))
+				}
+				if after < before-1e-9 {
+					for a, b := i, j; a < b; a, b = a+1, b-1 {
+						order[a], order[b] = order[b], order[a]
+					}
+					improved = true
+				}
+			}
+		}
+		if !improved {
+			break
+		}
+	}
+	return order
+}
+
+func routeLength(start routePoint, order []int, points []routePoint) float64 {
+	total := 0.0
+	previous := start
+	for _, idx := range order {
+		total += previous.km(points[idx])
+		previous = points[idx]
+	}
+	return total
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -1,33 +1,56 @@
 package services
 
 import (
+	"context"
 	"errors"
-	"log"
+	"log/slog"
 	"math"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/shigake/tech-iq-back/internal/cache"
 	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
 	"github.com/shigake/tech-iq-back/internal/repositories"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTechnicianNotAssigned = errors.New("técnico não está atribuído a este ticket")
+	ErrNoHierarchyScope      = errors.New("usuário não pertence a nenhum escopo da hierarquia")
 )
 
 type GeoService struct {
-	geoRepo          *repositories.GeoRepository
-	userRepo         repositories.UserRepository
-	technicianRepo   repositories.TechnicianRepository
-	hierarchyService *HierarchyService
-	redisClient      *cache.RedisClient
+	geoRepo            *repositories.GeoRepository
+	fenceRepo          repositories.GeoFenceRepository
+	userRepo           repositories.UserRepository
+	technicianRepo     repositories.TechnicianRepository
+	clientRepo         repositories.ClientRepository
+	hierarchyService   *HierarchyService
+	activityLogService ActivityLogService
+	redisClient        *cache.RedisClient
+	geocoder           GeocodingService
+	hub                *LocationHub
+	fenceMu            *sync.Mutex // uma avaliação de cercas por vez, compartilhado por WithContext
+	stop               chan struct{}
+	done               chan struct{}
 }
 
-func NewGeoService(geoRepo *repositories.GeoRepository, userRepo repositories.UserRepository, technicianRepo repositories.TechnicianRepository, hierarchyService *HierarchyService, redisClient *cache.RedisClient) *GeoService {
+func NewGeoService(geoRepo *repositories.GeoRepository, fenceRepo repositories.GeoFenceRepository, userRepo repositories.UserRepository, technicianRepo repositories.TechnicianRepository, clientRepo repositories.ClientRepository, hierarchyService *HierarchyService, activityLogService ActivityLogService, redisClient *cache.RedisClient, geocoder GeocodingService) *GeoService {
 	svc := &GeoService{
-		geoRepo:          geoRepo,
-		userRepo:         userRepo,
-		technicianRepo:   technicianRepo,
-		hierarchyService: hierarchyService,
-		redisClient:      redisClient,
+		geoRepo:            geoRepo,
+		fenceRepo:          fenceRepo,
+		userRepo:           userRepo,
+		technicianRepo:     technicianRepo,
+		clientRepo:         clientRepo,
+		hierarchyService:   hierarchyService,
+		activityLogService: activityLogService,
+		redisClient:        redisClient,
+		geocoder:           geocoder,
+		hub:                NewLocationHub(),
+		fenceMu:            &sync.Mutex{},
 	}
 	
 	// Carregar cache de técnicos em background
@@ -36,6 +59,26 @@ func NewGeoService(geoRepo *repositories.GeoRepository, userRepo repositories.Us
 	return svc
 }
 
+// WithContext retorna o serviço executando suas consultas com ctx, no tenant que ele
+// carrega. O cancelamento de ctx não é seguido: a última localização é gravada depois
+// que a chamada retorna.
+func (s *GeoService) WithContext(ctx context.Context) *GeoService {
+	ctx = context.WithoutCancel(ctx)
+	return &GeoService{
+		geoRepo:            s.geoRepo.WithContext(ctx),
+		fenceRepo:          s.fenceRepo,
+		userRepo:           s.userRepo.WithContext(ctx),
+		technicianRepo:     s.technicianRepo.WithContext(ctx),
+		clientRepo:         s.clientRepo.WithContext(ctx),
+		hierarchyService:   s.hierarchyService,
+		activityLogService: s.activityLogService,
+		redisClient:        s.redisClient,
+		geocoder:           s.geocoder,
+		hub:                s.hub,
+		fenceMu:            s.fenceMu,
+	}
+}
+
 // CreateLocation cria um registro de localização
 func (s *GeoService) CreateLocation(technicianID string, req *models.CreateLocationRequest) (*models.TechnicianLocation, error) {
 	// Validar coordenadas
@@ -52,6 +95,11 @@ func (s *GeoService) CreateLocation(technicianID string, req *models.CreateLocat
 		}
 	}
 
+	// Check-in/check-out só é permitido para técnicos atribuídos ao ticket
+	if err := s.validateAssignment(technicianID, req.TicketID, req.EventType); err != nil {
+		return nil, err
+	}
+
 	// Criar localização
 	location := &models.TechnicianLocation{
 		TechnicianID: technicianID,
@@ -73,6 +121,10 @@ func (s *GeoService) CreateLocation(technicianID string, req *models.CreateLocat
 		return nil, err
 	}
 
+	s.markAssignmentEvent(location)
+	s.publishLocation(location)
+	s.evaluateFences(location)
+
 	// Atualizar última localização
 	go s.updateLastLocation(location)
 
@@ -104,6 +156,13 @@ func (s *GeoService) CreateBatchLocations(technicianID string, req *models.Batch
 			}
 		}
 
+		if err := s.validateAssignment(technicianID, item.TicketID, item.EventType); err != nil {
+			result.Status = "error"
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+
 		// Criar localização
 		location := &models.TechnicianLocation{
 			TechnicianID:  technicianID,
@@ -128,6 +187,9 @@ func (s *GeoService) CreateBatchLocations(technicianID string, req *models.Batch
 		} else {
 			result.ServerID = location.ID
 			result.Status = "created"
+			s.markAssignmentEvent(location)
+			s.publishLocation(location)
+			s.evaluateFences(location)
 			go s.updateLastLocation(location)
 		}
 
@@ -224,6 +286,123 @@ func (s *GeoService) GetLastLocations(userID uuid.UUID, filter repositories.GeoF
 	return responses, total, nil
 }
 
+// FindTechniciansInArea busca no banco as últimas localizações dos técnicos dentro da área
+// (raio e/ou caixa do mapa), sem carregar todos os técnicos. Com centro, vêm ordenadas
+// por distância. Como em GetLastLocations, apenas admins veem.
+func (s *GeoService) FindTechniciansInArea(userID uuid.UUID, area repositories.GeoArea) ([]models.TechnicianLocationResponse, error) {
+	user, err := s.userRepo.FindByID(userID.String())
+	if err != nil {
+		return nil, err
+	}
+	if user.Role != "ADMIN" && user.Role != "admin" {
+		return []models.TechnicianLocationResponse{}, nil
+	}
+
+	locations, err := s.geoRepo.FindLastLocationsInArea(area)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]models.TechnicianLocationResponse, 0, len(locations))
+	for _, loc := range locations {
+		response := models.TechnicianLocationResponse{
+			TechnicianID: loc.TechnicianID,
+			TicketID:     loc.TicketID,
+			Name:         loc.TechnicianName,
+			Status:       loc.TechnicianStatus,
+			Location: &models.LocationInfo{
+				Latitude:   loc.Latitude,
+				Longitude:  loc.Longitude,
+				AccuracyM:  loc.AccuracyM,
+				ServerTime: loc.ServerTime,
+				EventType:  loc.EventType,
+				MinutesAgo: int(time.Since(loc.ServerTime).Minutes()),
+			},
+		}
+		if loc.DistanceM != nil {
+			km := *loc.DistanceM / 1000
+			response.DistanceKm = &km
+		}
+		responses = append(responses, response)
+	}
+	return responses, nil
+}
+
+// SubscribeLocations inscreve o usuário no stream de localizações em tempo real. Admins
+// recebem todos os técnicos; os demais, só os membros dos seus escopos da hierarquia
+// (e descendentes). scopeID diferente de zero restringe a um node.
+func (s *GeoService) SubscribeLocations(userID, role string, scopeID uint) (*LocationSubscription, error) {
+	var userIDs []string
+	var err error
+	switch {
+	case role == "ADMIN" && scopeID == 0:
+		return s.hub.Subscribe(nil), nil
+	case role == "ADMIN":
+		userIDs, err = s.hierarchyService.GetNodeUserIDs(scopeID)
+	default:
+		if scopeID == 0 {
+			scopes, err := s.hierarchyService.GetUserScopes(userID)
+			if err != nil {
+				return nil, err
+			}
+			if len(scopes) == 0 {
+				return nil, ErrNoHierarchyScope
+			}
+		}
+		userIDs, err = s.hierarchyService.GetScopeUserIDs(userID, scopeID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if userIDs == nil {
+		userIDs = []string{}
+	}
+	return s.hub.Subscribe(userIDs), nil
+}
+
+// UnsubscribeLocations encerra a inscrição
+func (s *GeoService) UnsubscribeLocations(sub *LocationSubscription) {
+	s.hub.Unsubscribe(sub)
+}
+
+// publishLocation envia a nova localização aos dashboards conectados
+func (s *GeoService) publishLocation(location *models.TechnicianLocation) {
+	if !s.hub.HasSubscribers() {
+		return
+	}
+
+	event := models.LocationStreamEvent{
+		LocationID:    location.ID,
+		TechnicianID:  location.TechnicianID,
+		UserID:        location.TechnicianID,
+		TicketID:      location.TicketID,
+		EventType:     location.EventType,
+		Latitude:      location.Latitude,
+		Longitude:     location.Longitude,
+		AccuracyM:     location.AccuracyM,
+		SpeedMps:      location.SpeedMps,
+		HeadingDeg:    location.HeadingDeg,
+		DeviceTime:    location.DeviceTime,
+		ServerTime:    location.ServerTime,
+		IsMocked:      location.IsMocked,
+		IsOfflineSync: location.IsOfflineSync,
+	}
+	// Localizações são enviadas com o ID do usuário; o evento leva também o ID do técnico
+	if technician, err := s.technicianRepo.FindByUserID(location.TechnicianID); err == nil {
+		event.TechnicianID = technician.ID
+		event.Name = technician.FullName
+		event.Status = technician.Status
+	} else if technician, err := s.technicianRepo.FindByID(location.TechnicianID); err == nil && technician != nil {
+		event.Name = technician.FullName
+		event.Status = technician.Status
+		if technician.UserID != nil {
+			event.UserID = *technician.UserID
+		}
+	}
+
+	s.hub.Publish(event)
+}
+
 // containsIgnoreCase verifica se a string contém a substring (case-insensitive)
 func containsIgnoreCase(s, substr string) bool {
 	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
@@ -242,6 +421,12 @@ func (s *GeoService) GetTechnicianHistory(userID uuid.UUID, technicianID string,
 	if err != nil {
 		return nil, 0, err
 	}
+	var next, prev string
+	if filter.Cursor != nil {
+		locations, next, prev = pagination.CursorPage(locations, *filter.Cursor, filter.Limit, func(loc models.TechnicianLocation) ([]time.Time, string) {
+			return []time.Time{loc.ServerTime}, loc.ID.String()
+		})
+	}
 
 	// Buscar resumo
 	summary, err := s.geoRepo.GetHistorySummary(technicianID, filter.From, filter.To)
@@ -257,8 +442,10 @@ func (s *GeoService) GetTechnicianHistory(userID uuid.UUID, technicianID string,
 			From: filter.From,
 			To:   filter.To,
 		},
-		Summary:   *summary,
-		Locations: make([]models.LocationHistoryItem, 0, len(locations)),
+		Summary:    *summary,
+		Locations:  make([]models.LocationHistoryItem, 0, len(locations)),
+		NextCursor: next,
+		PrevCursor: prev,
 	}
 
 	for _, loc := range locations {
@@ -399,6 +586,36 @@ func (s *GeoService) CleanupOldLocations() (int64, error) {
 	return s.geoRepo.DeleteOldLocations(settings.RetentionDays)
 }
 
+// StartCleanup executa CleanupOldLocations a cada intervalo
+func (s *GeoService) StartCleanup(interval time.Duration) {
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := s.CleanupOldLocations(); err != nil {
+					slog.Warn("Geo location cleanup failed", "error", err)
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (s *GeoService) StopCleanup() {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+		s.stop = nil
+	}
+}
+
 // Helpers
 
 func (s *GeoService) validateCoordinates(lat, lng float64) error {
@@ -450,6 +667,38 @@ func (s *GeoService) updateLastLocation(location *models.TechnicianLocation) {
 	go s.updateTechnicianInCache(location.TechnicianID)
 }
 
+// validateAssignment verifica se o técnico faz parte da equipe do ticket no check-in/check-out
+func (s *GeoService) validateAssignment(technicianID string, ticketID *uuid.UUID, eventType models.EventType) error {
+	if ticketID == nil || eventType == models.EventTypeHeartbeat {
+		return nil
+	}
+	if _, err := s.geoRepo.GetAssignment(ticketID.String(), s.resolveTechnicianID(technicianID)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTechnicianNotAssigned
+		}
+		return err
+	}
+	return nil
+}
+
+// markAssignmentEvent registra o horário de check-in/check-out na atribuição
+func (s *GeoService) markAssignmentEvent(location *models.TechnicianLocation) {
+	if location.TicketID == nil || location.EventType == models.EventTypeHeartbeat {
+		return
+	}
+	technicianID := s.resolveTechnicianID(location.TechnicianID)
+	s.geoRepo.MarkAssignmentEvent(location.TicketID.String(), technicianID, location.EventType, location.ServerTime)
+}
+
+// resolveTechnicianID converte o ID do usuário logado no ID do técnico vinculado
+// (localizações são enviadas com o ID do usuário)
+func (s *GeoService) resolveTechnicianID(id string) string {
+	if technician, err := s.technicianRepo.FindByUserID(id); err == nil {
+		return technician.ID
+	}
+	return id
+}
+
 // CalculateDistance calcula a distância entre dois pontos em metros (Haversine)
 func CalculateDistance(lat1, lng1, lat2, lng2 float64) float64 {
 	const earthRadius = 6371000 // metros
@@ -470,23 +719,23 @@ func CalculateDistance(lat1, lng1, lat2, lng2 float64) float64 {
 // loadTechniciansToCache carrega todos os técnicos no Redis cache
 func (s *GeoService) loadTechniciansToCache() {
 	if s.redisClient == nil {
-		log.Println("⚠️ Redis client not available, skipping geo cache load")
+		slog.Warn("Redis client not available, skipping geo cache load")
 		return
 	}
 	
 	// Verificar se já foi carregado recentemente
 	if s.redisClient.IsGeoCacheLoaded() {
 		count, _ := s.redisClient.GetGeoCacheCount()
-		log.Printf("✅ Geo cache already loaded with %d technicians", count)
+		slog.Info("Geo cache already loaded with technicians", "count", count)
 		return
 	}
 	
-	log.Println("🔄 Loading all technicians to geo cache...")
+	slog.Info("Loading all technicians to geo cache...")
 	
 	// Buscar todos os técnicos ativos
 	technicians, err := s.technicianRepo.GetAll()
 	if err != nil {
-		log.Printf("❌ Error loading technicians: %v", err)
+		slog.Error("Error loading technicians", "error", err)
 		return
 	}
 	
@@ -535,11 +784,11 @@ func (s *GeoService) loadTechniciansToCache() {
 	
 	// Salvar no Redis
 	if err := s.redisClient.SetAllTechniciansGeo(geoData); err != nil {
-		log.Printf("❌ Error saving technicians to cache: %v", err)
+		slog.Error("Error saving technicians to cache", "error", err)
 		return
 	}
 	
-	log.Printf("✅ Loaded %d technicians to geo cache", len(geoData))
+	slog.Info("Loaded technicians to geo cache", "count", len(geoData))
 }
 
 // updateTechnicianInCache atualiza um técnico específico no cache quando recebe nova localização
@@ -584,7 +833,8 @@ func (s *GeoService) updateTechnicianInCache(technicianID string) {
 // GetAllTechniciansFromCache retorna todos os técnicos do cache Redis
 // Fallback para o banco de dados se o cache estiver vazio
 func (s *GeoService) GetAllTechniciansFromCache() ([]cache.TechnicianGeoData, error) {
-	if s.redisClient == nil {
+	// Em modo degradado não adianta tentar recarregar o cache
+	if s.redisClient == nil || s.redisClient.Degraded() {
 		return s.loadTechniciansDirectly()
 	}
 	
@@ -592,7 +842,7 @@ func (s *GeoService) GetAllTechniciansFromCache() ([]cache.TechnicianGeoData, er
 	technicians, err := s.redisClient.GetAllTechniciansGeo()
 	if err != nil || len(technicians) == 0 {
 		// Cache miss - carregar do banco
-		log.Println("⚠️ Geo cache miss, loading from database...")
+		slog.Warn("Geo cache miss, loading from database...")
 		go s.loadTechniciansToCache()
 		return s.loadTechniciansDirectly()
 	}
@@ -0,0 +1,439 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"gorm.io/gorm"
+)
+
+var ErrGeocodeClientNotFound = errors.New("cliente não encontrado")
+
+const (
+	defaultGeocodingBatchSize = 100
+	defaultNominatimURL       = "https://nominatim.openstreetmap.org"
+	googleGeocodingURL        = "https://maps.googleapis.com/maps/api/geocode/json"
+	// Endereços não encontrados pelo provedor são consultados de novo depois disso
+	geocodeNotFoundTTL = 7 * 24 * time.Hour
+	// Política de uso do servidor público do Nominatim: no máximo uma consulta por segundo
+	nominatimMinInterval = time.Second
+)
+
+// GeocodingConfig configura o provedor de geocodificação e o job de backfill
+type GeocodingConfig struct {
+	Provider     string // local, nominatim ou google
+	NominatimURL string
+	UserAgent    string // obrigatório pelo Nominatim
+	GoogleAPIKey string
+	BatchSize    int // clientes por execução do job
+}
+
+// GeocodeAddress é o endereço enviado ao provedor
+type GeocodeAddress struct {
+	Street       string
+	Number       string
+	Neighborhood string
+	City         string
+	State        string
+	ZipCode      string
+}
+
+func geocodeClientAddress(client *models.Client) GeocodeAddress {
+	return GeocodeAddress{
+		Street:       client.Street,
+		Number:       client.Number,
+		Neighborhood: client.Neighborhood,
+		City:         client.City,
+		State:        client.State,
+		ZipCode:      client.ZipCode,
+	}
+}
+
+// hasStreet indica se há mais que cidade e estado, que as tabelas locais já resolvem
+func (a GeocodeAddress) hasStreet() bool {
+	return strings.TrimSpace(a.Street) != "" || strings.TrimSpace(a.ZipCode) != ""
+}
+
+// Query monta o endereço em texto livre
+func (a GeocodeAddress) Query() string {
+	var parts []string
+	street := strings.TrimSpace(a.Street)
+	if number := strings.TrimSpace(a.Number); street != "" && number != "" {
+		street += ", " + number
+	}
+	for _, part := range []string{street, a.Neighborhood, a.City, a.State, a.ZipCode} {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(append(parts, "Brasil"), ", ")
+}
+
+// cacheKey identifica o endereço normalizado (sem diferenciar maiúsculas e espaços)
+func (a GeocodeAddress) cacheKey() string {
+	sum := sha256.Sum256([]byte(strings.Join(strings.Fields(strings.ToLower(a.Query())), " ")))
+	return hex.EncodeToString(sum[:])
+}
+
+// GeocodingProvider geocodifica um endereço; devolve nil quando não o encontra
+type GeocodingProvider interface {
+	Name() string
+	Geocode(address GeocodeAddress) (*models.GeocodeResult, error)
+}
+
+// NewGeocodingProvider devolve o provedor configurado; nil para local, que usa só as
+// tabelas de cidades e capitais
+func NewGeocodingProvider(cfg GeocodingConfig) GeocodingProvider {
+	client := &http.Client{Timeout: 10 * time.Second}
+	switch strings.ToLower(cfg.Provider) {
+	case models.GeocodingProviderNominatim:
+		baseURL := cfg.NominatimURL
+		if baseURL == "" {
+			baseURL = defaultNominatimURL
+		}
+		return &nominatimProvider{baseURL: strings.TrimRight(baseURL, "/"), userAgent: cfg.UserAgent, client: client}
+	case models.GeocodingProviderGoogle:
+		return &googleGeocodingProvider{apiKey: cfg.GoogleAPIKey, client: client}
+	}
+	return nil
+}
+
+// GeocodingService geocodifica os endereços dos clientes (provedor configurado, com cache,
+// e as tabelas de cidades e capitais quando ele não encontra) e grava as coordenadas
+type GeocodingService interface {
+	// Lookup geocodifica o endereço do cliente sem gravar
+	Lookup(client *models.Client) *models.GeocodeResult
+	// Locate devolve as coordenadas do cliente, geocodificando e gravando quando ainda não foi feito
+	Locate(client *models.Client) (*float64, *float64, string)
+	// Regeocode geocodifica de novo o endereço do cliente e grava
+	Regeocode(clientID string) (*models.Client, error)
+	// Backfill geocodifica até limit clientes que ainda não têm coordenadas
+	Backfill(limit int) (geocoded, notFound int, err error)
+	Start(interval time.Duration)
+	Stop()
+}
+
+type geocodingService struct {
+	geoRepo    *repositories.GeoRepository
+	clientRepo repositories.ClientRepository
+	provider   GeocodingProvider
+	batchSize  int
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+func NewGeocodingService(geoRepo *repositories.GeoRepository, clientRepo repositories.ClientRepository, cfg GeocodingConfig) GeocodingService {
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultGeocodingBatchSize
+	}
+	return &geocodingService{
+		geoRepo:    geoRepo,
+		clientRepo: clientRepo,
+		provider:   NewGeocodingProvider(cfg),
+		batchSize:  batchSize,
+	}
+}
+
+func (s *geocodingService) Lookup(client *models.Client) *models.GeocodeResult {
+	address := geocodeClientAddress(client)
+	if s.provider != nil && address.hasStreet() {
+		if result := s.lookupProvider(address); result != nil {
+			return result
+		}
+	}
+
+	lat, lng, precision := geocodeAddress(client.City, client.State)
+	return &models.GeocodeResult{
+		Latitude:  lat,
+		Longitude: lng,
+		Precision: precision,
+		Provider:  models.GeocodingProviderLocal,
+	}
+}
+
+// lookupProvider consulta o cache e, sem resultado válido, o provedor; nil quando o endereço
+// não foi encontrado ou o provedor falhou
+func (s *geocodingService) lookupProvider(address GeocodeAddress) *models.GeocodeResult {
+	key := address.cacheKey()
+	if entry, err := s.geoRepo.GetGeocodeCache(key); err == nil {
+		if entry.Precision != models.GeoPrecisionNone {
+			return &models.GeocodeResult{
+				Latitude:  entry.Latitude,
+				Longitude: entry.Longitude,
+				Precision: entry.Precision,
+				Provider:  entry.Provider,
+				Cached:    true,
+			}
+		}
+		if time.Since(entry.CreatedAt) < geocodeNotFoundTTL {
+			return nil
+		}
+	}
+
+	result, err := s.provider.Geocode(address)
+	if err != nil {
+		slog.Warn("Geocoding failed", "provider", s.provider.Name(), "error", err)
+		return nil
+	}
+	entry := &models.GeocodeCacheEntry{
+		Key:       key,
+		Address:   address.Query(),
+		Provider:  s.provider.Name(),
+		Precision: models.GeoPrecisionNone,
+		CreatedAt: time.Now(),
+	}
+	if result != nil {
+		result.Provider = s.provider.Name()
+		entry.Latitude, entry.Longitude, entry.Precision = result.Latitude, result.Longitude, result.Precision
+	}
+	if err := s.geoRepo.SaveGeocodeCache(entry); err != nil {
+		slog.Warn("Failed to cache geocoding result", "error", err)
+	}
+	return result
+}
+
+func (s *geocodingService) Locate(client *models.Client) (*float64, *float64, string) {
+	if client.GeoPrecision != "" {
+		return client.Latitude, client.Longitude, client.GeoPrecision
+	}
+	// Coordenadas informadas no cadastro
+	if client.Latitude != nil && client.Longitude != nil {
+		return client.Latitude, client.Longitude, geoPrecisionExact
+	}
+
+	result := s.Lookup(client)
+	if err := s.save(client, result); err != nil {
+		slog.Warn("Failed to store coordinates of client", "client_id", client.ID, "error", err)
+	}
+	return result.Latitude, result.Longitude, result.Precision
+}
+
+func (s *geocodingService) Regeocode(clientID string) (*models.Client, error) {
+	client, err := s.clientRepo.GetByID(clientID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrGeocodeClientNotFound
+		}
+		return nil, err
+	}
+	if err := s.save(client, s.Lookup(client)); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+func (s *geocodingService) Backfill(limit int) (int, int, error) {
+	clients, err := s.geoRepo.FindClientsToGeocode(limit)
+	if err != nil {
+		return 0, 0, err
+	}
+	geocoded, notFound := 0, 0
+	for i := range clients {
+		result := s.Lookup(&clients[i])
+		if err := s.save(&clients[i], result); err != nil {
+			return geocoded, notFound, err
+		}
+		if result.Precision == models.GeoPrecisionNone {
+			notFound++
+		} else {
+			geocoded++
+		}
+	}
+	return geocoded, notFound, nil
+}
+
+// save grava as coordenadas no cliente
+func (s *geocodingService) save(client *models.Client, result *models.GeocodeResult) error {
+	now := time.Now()
+	if err := s.geoRepo.UpdateClientCoordinates(client.ID, result.Latitude, result.Longitude, result.Precision, now); err != nil {
+		return err
+	}
+	client.Latitude, client.Longitude, client.GeoPrecision, client.GeocodedAt = result.Latitude, result.Longitude, result.Precision, &now
+	return nil
+}
+
+// Start executa o Backfill a cada interval
+func (s *geocodingService) Start(interval time.Duration) {
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				geocoded, notFound, err := s.Backfill(s.batchSize)
+				if err != nil {
+					slog.Warn("Client geocoding failed", "error", err)
+					continue
+				}
+				if geocoded+notFound > 0 {
+					slog.Info("Client geocoding completed", "geocoded", geocoded, "not_found", notFound)
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (s *geocodingService) Stop() {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+		s.stop = nil
+	}
+}
+
+// nominatimProvider consulta o Nominatim (OpenStreetMap), respeitando o intervalo mínimo entre
+// consultas
+type nominatimProvider struct {
+	baseURL   string
+	userAgent string
+	client    *http.Client
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+func (p *nominatimProvider) Name() string {
+	return models.GeocodingProviderNominatim
+}
+
+func (p *nominatimProvider) Geocode(address GeocodeAddress) (*models.GeocodeResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if wait := nominatimMinInterval - time.Since(p.last); wait > 0 {
+		time.Sleep(wait)
+	}
+	defer func() { p.last = time.Now() }()
+
+	params := url.Values{}
+	params.Set("q", address.Query())
+	params.Set("format", "jsonv2")
+	params.Set("limit", "1")
+	params.Set("countrycodes", "br")
+	req, err := http.NewRequest(http.MethodGet, p.baseURL+"/search?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", p.userAgent)
+	req.Header.Set("Accept-Language", "pt-BR")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("nominatim returned %d", resp.StatusCode)
+	}
+
+	var places []struct {
+		Lat         string `json:"lat"`
+		Lon         string `json:"lon"`
+		AddressType string `json:"addresstype"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&places); err != nil {
+		return nil, err
+	}
+	if len(places) == 0 {
+		return nil, nil
+	}
+	lat, err := strconv.ParseFloat(places[0].Lat, 64)
+	if err != nil {
+		return nil, err
+	}
+	lng, err := strconv.ParseFloat(places[0].Lon, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	precision := models.GeoPrecisionAddress
+	switch places[0].AddressType {
+	case "city", "town", "village", "municipality":
+		precision = models.GeoPrecisionCity
+	case "state":
+		precision = models.GeoPrecisionState
+	}
+	return &models.GeocodeResult{Latitude: &lat, Longitude: &lng, Precision: precision}, nil
+}
+
+// googleGeocodingProvider consulta a Google Geocoding API
+type googleGeocodingProvider struct {
+	apiKey string
+	client *http.Client
+}
+
+func (p *googleGeocodingProvider) Name() string {
+	return models.GeocodingProviderGoogle
+}
+
+func (p *googleGeocodingProvider) Geocode(address GeocodeAddress) (*models.GeocodeResult, error) {
+	params := url.Values{}
+	params.Set("address", address.Query())
+	params.Set("region", "br")
+	params.Set("language", "pt-BR")
+	params.Set("key", p.apiKey)
+
+	resp, err := p.client.Get(googleGeocodingURL + "?" + params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Status       string `json:"status"`
+		ErrorMessage string `json:"error_message"`
+		Results      []struct {
+			Types    []string `json:"types"`
+			Geometry struct {
+				Location struct {
+					Lat float64 `json:"lat"`
+					Lng float64 `json:"lng"`
+				} `json:"location"`
+			} `json:"geometry"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	switch body.Status {
+	case "OK":
+	case "ZERO_RESULTS":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("google geocoding returned %s: %s", body.Status, body.ErrorMessage)
+	}
+	if len(body.Results) == 0 {
+		return nil, nil
+	}
+
+	result := body.Results[0]
+	precision := models.GeoPrecisionAddress
+	for _, t := range result.Types {
+		switch t {
+		case "locality", "administrative_area_level_2":
+			precision = models.GeoPrecisionCity
+		case "administrative_area_level_1":
+			precision = models.GeoPrecisionState
+		}
+	}
+	lat, lng := result.Geometry.Location.Lat, result.Geometry.Location.Lng
+	return &models.GeocodeResult{Latitude: &lat, Longitude: &lng, Precision: precision}, nil
+}
@@ -0,0 +1,301 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrGeoFenceNotFound       = errors.New("cerca não encontrada")
+	ErrGeoFenceInvalidCircle  = errors.New("uma cerca CIRCLE precisa de centerLat, centerLng e radiusM")
+	ErrGeoFenceInvalidPolygon = errors.New("uma cerca POLYGON precisa de pelo menos 3 pontos válidos")
+	ErrGeoFenceClientNotFound = errors.New("cliente não encontrado")
+	ErrGeoFenceNodeNotFound   = errors.New("node da hierarquia não encontrado")
+)
+
+// geoFenceMaxAccuracyM: pontos com precisão pior que isso não mudam o estado das cercas
+const geoFenceMaxAccuracyM = 200.0
+
+// ListFences lista as cercas
+func (s *GeoService) ListFences(filters *models.GeoFenceFilters) ([]models.GeoFence, error) {
+	return s.fenceRepo.FindAll(filters)
+}
+
+// GetFence obtém uma cerca
+func (s *GeoService) GetFence(id string) (*models.GeoFence, error) {
+	fence, err := s.fenceRepo.FindByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrGeoFenceNotFound
+		}
+		return nil, err
+	}
+	return fence, nil
+}
+
+// CreateFence cria uma cerca
+func (s *GeoService) CreateFence(req *models.GeoFenceRequest, userID string) (*models.GeoFence, error) {
+	fence := &models.GeoFence{NotifyEntry: true, NotifyExit: true, Active: true, CreatedBy: userID}
+	if err := s.applyFence(fence, req); err != nil {
+		return nil, err
+	}
+	if err := s.fenceRepo.Create(fence); err != nil {
+		return nil, err
+	}
+	return fence, nil
+}
+
+// UpdateFence atualiza uma cerca. Os técnicos dentro dela são reavaliados na próxima
+// localização; ao desativar, as presenças são descartadas sem eventos de saída.
+func (s *GeoService) UpdateFence(id string, req *models.GeoFenceRequest) (*models.GeoFence, error) {
+	fence, err := s.GetFence(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.applyFence(fence, req); err != nil {
+		return nil, err
+	}
+	if err := s.fenceRepo.Update(fence); err != nil {
+		return nil, err
+	}
+	if !fence.Active {
+		if err := s.fenceRepo.DeletePresences(fence.ID); err != nil {
+			return nil, err
+		}
+	}
+	return fence, nil
+}
+
+// DeleteFence remove uma cerca, mantendo o histórico de eventos
+func (s *GeoService) DeleteFence(id string) error {
+	if err := s.fenceRepo.Delete(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrGeoFenceNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// GetFenceTechnicians lista os técnicos que estão dentro da cerca agora
+func (s *GeoService) GetFenceTechnicians(id string) ([]models.GeoFenceTechnician, error) {
+	if _, err := s.GetFence(id); err != nil {
+		return nil, err
+	}
+	presences, err := s.fenceRepo.FindPresences(id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	technicians := make([]models.GeoFenceTechnician, 0, len(presences))
+	for _, p := range presences {
+		item := models.GeoFenceTechnician{
+			TechnicianID: p.TechnicianID,
+			EnteredAt:    p.EnteredAt,
+			LastSeenAt:   p.LastSeenAt,
+			DwellMinutes: int(now.Sub(p.EnteredAt).Minutes()),
+		}
+		if p.Technician != nil {
+			item.Name = p.Technician.FullName
+			item.Status = p.Technician.Status
+		}
+		technicians = append(technicians, item)
+	}
+	return technicians, nil
+}
+
+// GetFenceEvents lista as entradas e saídas da cerca, mais recentes primeiro
+func (s *GeoService) GetFenceEvents(id string, page, size int) ([]models.GeoFenceEvent, int64, error) {
+	fence, err := s.GetFence(id)
+	if err != nil {
+		return nil, 0, err
+	}
+	events, total, err := s.fenceRepo.FindEvents(id, page, size)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range events {
+		events[i].FenceName = fence.Name
+	}
+	return events, total, nil
+}
+
+// applyFence valida a requisição e copia para a cerca
+func (s *GeoService) applyFence(fence *models.GeoFence, req *models.GeoFenceRequest) error {
+	if req.ClientID != nil && *req.ClientID != "" {
+		if _, err := s.clientRepo.GetByID(*req.ClientID); err != nil {
+			return ErrGeoFenceClientNotFound
+		}
+		fence.ClientID = req.ClientID
+	} else {
+		fence.ClientID = nil
+	}
+	if req.NodeID != nil && *req.NodeID != 0 {
+		if _, err := s.hierarchyService.GetNode(*req.NodeID); err != nil {
+			return ErrGeoFenceNodeNotFound
+		}
+		fence.NodeID = req.NodeID
+	} else {
+		fence.NodeID = nil
+	}
+
+	fence.Name = strings.TrimSpace(req.Name)
+	fence.Shape = req.Shape
+	fence.CenterLat, fence.CenterLng, fence.RadiusM = nil, nil, nil
+	fence.Polygon = models.GeoPolygon{}
+	switch req.Shape {
+	case models.GeoFenceShapeCircle:
+		if req.CenterLat == nil || req.CenterLng == nil || req.RadiusM == nil {
+			return ErrGeoFenceInvalidCircle
+		}
+		if err := s.validateCoordinates(*req.CenterLat, *req.CenterLng); err != nil || *req.RadiusM <= 0 {
+			return ErrGeoFenceInvalidCircle
+		}
+		fence.CenterLat, fence.CenterLng, fence.RadiusM = req.CenterLat, req.CenterLng, req.RadiusM
+	case models.GeoFenceShapePolygon:
+		if len(req.Polygon) < 3 {
+			return ErrGeoFenceInvalidPolygon
+		}
+		for _, p := range req.Polygon {
+			if err := s.validateCoordinates(p.Latitude, p.Longitude); err != nil {
+				return ErrGeoFenceInvalidPolygon
+			}
+		}
+		fence.Polygon = req.Polygon
+	}
+
+	if req.NotifyEntry != nil {
+		fence.NotifyEntry = *req.NotifyEntry
+	}
+	if req.NotifyExit != nil {
+		fence.NotifyExit = *req.NotifyExit
+	}
+	if req.Active != nil {
+		fence.Active = *req.Active
+	}
+	return nil
+}
+
+// evaluateFences compara a nova localização com as cercas ativas e registra as entradas
+// e saídas do técnico. Localizações simuladas ou imprecisas são ignoradas.
+func (s *GeoService) evaluateFences(location *models.TechnicianLocation) {
+	if location.IsMocked || (location.AccuracyM != nil && *location.AccuracyM > geoFenceMaxAccuracyM) {
+		return
+	}
+
+	s.fenceMu.Lock()
+	defer s.fenceMu.Unlock()
+
+	fences, err := s.fenceRepo.FindAll(&models.GeoFenceFilters{})
+	if err != nil {
+		slog.Warn("Failed to load geofences", "error", err)
+		return
+	}
+	if len(fences) == 0 {
+		return
+	}
+
+	// Localizações são enviadas com o ID do usuário
+	technician, err := s.technicianRepo.FindByUserID(location.TechnicianID)
+	if err != nil {
+		if technician, err = s.technicianRepo.FindByID(location.TechnicianID); err != nil {
+			return
+		}
+	}
+
+	presences, err := s.fenceRepo.FindPresencesByTechnician(technician.ID)
+	if err != nil {
+		slog.Warn("Failed to load geofence presences", "technician_id", technician.ID, "error", err)
+		return
+	}
+	inside := make(map[string]models.GeoFencePresence, len(presences))
+	for _, p := range presences {
+		inside[p.FenceID] = p
+	}
+
+	// Na sincronização offline vale o horário do dispositivo
+	at := location.ServerTime
+	if location.IsOfflineSync && location.DeviceTime != nil {
+		at = *location.DeviceTime
+	}
+
+	for i := range fences {
+		fence := &fences[i]
+		presence, wasInside := inside[fence.ID]
+		// Ponto mais antigo que o último visto (lote offline fora de ordem)
+		if wasInside && at.Before(presence.LastSeenAt) {
+			continue
+		}
+
+		switch isInside := fence.Contains(location.Latitude, location.Longitude); {
+		case isInside && !wasInside:
+			presence = models.GeoFencePresence{
+				FenceID:        fence.ID,
+				TechnicianID:   technician.ID,
+				UserID:         location.TechnicianID,
+				EnteredAt:      at,
+				LastSeenAt:     at,
+				LastLocationID: location.ID,
+			}
+			if err := s.fenceRepo.SavePresence(&presence); err != nil {
+				slog.Warn("Failed to save geofence presence", "error", err)
+				continue
+			}
+			s.recordFenceEvent(fence, technician, location, models.GeoFenceEventEntry, at, nil)
+		case isInside:
+			presence.LastSeenAt = at
+			presence.LastLocationID = location.ID
+			if err := s.fenceRepo.SavePresence(&presence); err != nil {
+				slog.Warn("Failed to save geofence presence", "error", err)
+			}
+		case wasInside:
+			if err := s.fenceRepo.DeletePresence(fence.ID, technician.ID); err != nil {
+				slog.Warn("Failed to delete geofence presence", "error", err)
+				continue
+			}
+			dwell := int64(at.Sub(presence.EnteredAt).Seconds())
+			s.recordFenceEvent(fence, technician, location, models.GeoFenceEventExit, at, &dwell)
+		}
+	}
+}
+
+// recordFenceEvent grava o evento, registra no log de atividades e avisa os dashboards
+func (s *GeoService) recordFenceEvent(fence *models.GeoFence, technician *models.Technician, location *models.TechnicianLocation, eventType string, at time.Time, dwell *int64) {
+	event := &models.GeoFenceEvent{
+		FenceID:      fence.ID,
+		FenceName:    fence.Name,
+		TechnicianID: technician.ID,
+		UserID:       location.TechnicianID,
+		EventType:    eventType,
+		LocationID:   location.ID,
+		Latitude:     location.Latitude,
+		Longitude:    location.Longitude,
+		DwellSeconds: dwell,
+		OccurredAt:   at,
+	}
+	if err := s.fenceRepo.CreateEvent(event); err != nil {
+		slog.Warn("Failed to record geofence event", "error", err)
+		return
+	}
+
+	action, description := "geofence_entry", fmt.Sprintf("%s entrou na cerca %s", technician.FullName, fence.Name)
+	if eventType == models.GeoFenceEventExit {
+		action, description = "geofence_exit", fmt.Sprintf("%s saiu da cerca %s", technician.FullName, fence.Name)
+	}
+	if s.activityLogService != nil {
+		if err := s.activityLogService.LogAction(location.TechnicianID, action, "geo_fence", fence.ID, description, "", ""); err != nil {
+			slog.Warn("Failed to audit geofence event", "event_id", event.ID, "error", err)
+		}
+	}
+
+	if (eventType == models.GeoFenceEventEntry && fence.NotifyEntry) || (eventType == models.GeoFenceEventExit && fence.NotifyExit) {
+		s.hub.PublishFence(*event)
+	}
+}
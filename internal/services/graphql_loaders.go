@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"github.com/vikstrous/dataloadgen"
+)
+
+// graphqlLoaders collect the relations asked for by the rows of a level and load them in
+// one query, instead of one per row. They are created per query, so they cache within it.
+type graphqlLoaders struct {
+	clients           *dataloadgen.Loader[string, *models.Client]
+	ticketTechnicians *dataloadgen.Loader[string, []*models.Technician]
+	ticketStockUsage  *dataloadgen.Loader[string, []*models.StockMovement]
+	ticketFinancial   *dataloadgen.Loader[string, *models.TicketFinancialTotals]
+	clientTickets     *dataloadgen.Loader[nestedTicketsKey, []*models.Ticket]
+	technicianTickets *dataloadgen.Loader[nestedTicketsKey, []*models.Ticket]
+}
+
+// nestedTicketsKey asks for the latest tickets of a client or technician
+type nestedTicketsKey struct {
+	ParentID string
+	Limit    int
+}
+
+type graphqlLoadersKey struct{}
+
+func graphqlLoadersFrom(ctx context.Context) *graphqlLoaders {
+	return ctx.Value(graphqlLoadersKey{}).(*graphqlLoaders)
+}
+
+// newGraphQLLoaders creates the loaders of a query. The loads run with the context of the
+// query, inside its tenant and with the access of its user.
+func newGraphQLLoaders(repo repositories.GraphQLRepository) *graphqlLoaders {
+	return &graphqlLoaders{
+		clients:           dataloadgen.NewLoader(graphqlFetch(repo, loadGraphQLClients)),
+		ticketTechnicians: dataloadgen.NewLoader(graphqlFetch(repo, loadGraphQLTicketTechnicians)),
+		ticketStockUsage:  dataloadgen.NewLoader(graphqlFetch(repo, loadGraphQLTicketStockUsage)),
+		ticketFinancial:   dataloadgen.NewLoader(graphqlFetch(repo, loadGraphQLTicketFinancial)),
+		clientTickets:     dataloadgen.NewLoader(graphqlFetch(repo, loadGraphQLClientTickets)),
+		technicianTickets: dataloadgen.NewLoader(graphqlFetch(repo, loadGraphQLTechnicianTickets)),
+	}
+}
+
+// graphqlFetch adapts a batch load to a loader: the repository runs with the context of
+// the batch, and an error fails every key of it
+func graphqlFetch[K comparable, V any](repo repositories.GraphQLRepository, load func(ctx context.Context, repo repositories.GraphQLRepository, keys []K) ([]V, error)) func(context.Context, []K) ([]V, []error) {
+	return func(ctx context.Context, keys []K) ([]V, []error) {
+		values, err := load(ctx, repo.WithContext(ctx), keys)
+		if err != nil {
+			return nil, []error{err}
+		}
+		return values, nil
+	}
+}
+
+func loadGraphQLClients(ctx context.Context, repo repositories.GraphQLRepository, ids []string) ([]*models.Client, error) {
+	clients, err := repo.ClientsByIDs(ids, graphqlAccessFrom(ctx).Scope)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*models.Client, len(clients))
+	for i := range clients {
+		byID[clients[i].ID] = &clients[i]
+	}
+	values := make([]*models.Client, len(ids))
+	for i, id := range ids {
+		values[i] = byID[id]
+	}
+	return values, nil
+}
+
+func loadGraphQLTicketTechnicians(_ context.Context, repo repositories.GraphQLRepository, ticketIDs []string) ([][]*models.Technician, error) {
+	assignments, err := repo.AssignmentsByTicketIDs(ticketIDs)
+	if err != nil {
+		return nil, err
+	}
+	byTicket := make(map[string][]*models.Technician, len(ticketIDs))
+	for _, id := range ticketIDs {
+		byTicket[id] = []*models.Technician{}
+	}
+	for i := range assignments {
+		if assignments[i].Technician != nil {
+			byTicket[assignments[i].TicketID] = append(byTicket[assignments[i].TicketID], assignments[i].Technician)
+		}
+	}
+	values := make([][]*models.Technician, len(ticketIDs))
+	for i, id := range ticketIDs {
+		values[i] = byTicket[id]
+	}
+	return values, nil
+}
+
+func loadGraphQLTicketStockUsage(_ context.Context, repo repositories.GraphQLRepository, ticketIDs []string) ([][]*models.StockMovement, error) {
+	movements, err := repo.StockUsageByTicketIDs(ticketIDs)
+	if err != nil {
+		return nil, err
+	}
+	byTicket := make(map[string][]*models.StockMovement, len(ticketIDs))
+	for _, id := range ticketIDs {
+		byTicket[id] = []*models.StockMovement{}
+	}
+	for i := range movements {
+		if movements[i].TicketID != nil {
+			byTicket[*movements[i].TicketID] = append(byTicket[*movements[i].TicketID], &movements[i])
+		}
+	}
+	values := make([][]*models.StockMovement, len(ticketIDs))
+	for i, id := range ticketIDs {
+		values[i] = byTicket[id]
+	}
+	return values, nil
+}
+
+// loadGraphQLTicketFinancial returns zero totals for the tickets without entries
+func loadGraphQLTicketFinancial(_ context.Context, repo repositories.GraphQLRepository, ticketIDs []string) ([]*models.TicketFinancialTotals, error) {
+	totals, err := repo.FinancialTotalsByTicketIDs(ticketIDs)
+	if err != nil {
+		return nil, err
+	}
+	byTicket := make(map[string]*models.TicketFinancialTotals, len(totals))
+	for i := range totals {
+		byTicket[totals[i].TicketID] = &totals[i]
+	}
+	values := make([]*models.TicketFinancialTotals, len(ticketIDs))
+	for i, id := range ticketIDs {
+		if t, ok := byTicket[id]; ok {
+			values[i] = t
+		} else {
+			values[i] = &models.TicketFinancialTotals{TicketID: id}
+		}
+	}
+	return values, nil
+}
+
+// groupByLimit splits the keys of a batch by limit, since each limit is a separate query
+func groupByLimit(keys []nestedTicketsKey) map[int][]string {
+	groups := make(map[int][]string)
+	for _, key := range keys {
+		groups[key.Limit] = append(groups[key.Limit], key.ParentID)
+	}
+	return groups
+}
+
+func loadGraphQLClientTickets(ctx context.Context, repo repositories.GraphQLRepository, keys []nestedTicketsKey) ([][]*models.Ticket, error) {
+	byKey := make(map[nestedTicketsKey][]*models.Ticket, len(keys))
+	for _, key := range keys {
+		byKey[key] = []*models.Ticket{}
+	}
+	for limit, clientIDs := range groupByLimit(keys) {
+		tickets, err := repo.TicketsByClientIDs(clientIDs, limit, graphqlAccessFrom(ctx).Scope)
+		if err != nil {
+			return nil, err
+		}
+		for i := range tickets {
+			if tickets[i].ClientID != nil {
+				key := nestedTicketsKey{ParentID: *tickets[i].ClientID, Limit: limit}
+				byKey[key] = append(byKey[key], &tickets[i])
+			}
+		}
+	}
+	values := make([][]*models.Ticket, len(keys))
+	for i, key := range keys {
+		values[i] = byKey[key]
+	}
+	return values, nil
+}
+
+func loadGraphQLTechnicianTickets(ctx context.Context, repo repositories.GraphQLRepository, keys []nestedTicketsKey) ([][]*models.Ticket, error) {
+	byKey := make(map[nestedTicketsKey][]*models.Ticket, len(keys))
+	for _, key := range keys {
+		byKey[key] = []*models.Ticket{}
+	}
+	for limit, technicianIDs := range groupByLimit(keys) {
+		links, err := repo.TicketLinksByTechnicianIDs(technicianIDs, limit, graphqlAccessFrom(ctx).Scope)
+		if err != nil {
+			return nil, err
+		}
+		if len(links) == 0 {
+			continue
+		}
+		ticketIDs := make([]string, 0, len(links))
+		for _, link := range links {
+			ticketIDs = append(ticketIDs, link.TicketID)
+		}
+		loaded, err := repo.TicketsByIDs(ticketIDs)
+		if err != nil {
+			return nil, err
+		}
+		tickets := make(map[string]*models.Ticket, len(loaded))
+		for i := range loaded {
+			tickets[loaded[i].ID] = &loaded[i]
+		}
+		for _, link := range links {
+			if t, ok := tickets[link.TicketID]; ok {
+				key := nestedTicketsKey{ParentID: link.TechnicianID, Limit: limit}
+				byKey[key] = append(byKey[key], t)
+			}
+		}
+	}
+	values := make([][]*models.Ticket, len(keys))
+	for i, key := range keys {
+		values[i] = byKey[key]
+	}
+	return values, nil
+}
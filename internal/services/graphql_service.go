@@ -0,0 +1,354 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/executor"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/shigake/tech-iq-back/internal/graph"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"github.com/shigake/tech-iq-back/internal/tenant"
+	"gorm.io/gorm"
+)
+
+// Nested ticket lists (tickets of a client or technician) return the latest ones, up to
+// this many per parent unless the query asks for fewer
+const graphqlNestedTickets = 20
+
+// graphqlComplexityLimit bounds the fields a query may resolve; a nested ticket list counts
+// its fields once per ticket it may return, so deep trees of lists are rejected upfront
+const graphqlComplexityLimit = 1000
+
+// Permissions of the fields the GraphQL endpoint route doesn't already require
+const (
+	graphqlFinancePermission = "finance.view"
+	graphqlStockPermission   = "inventory.view"
+)
+
+// GraphQLAccess is what the user running a query may see
+type GraphQLAccess struct {
+	Scope *models.AccessScope
+	// Can tells whether the user holds the permission
+	Can func(permission string) bool
+}
+
+type graphqlAccessKey struct{}
+
+func graphqlAccessFrom(ctx context.Context) GraphQLAccess {
+	access, _ := ctx.Value(graphqlAccessKey{}).(GraphQLAccess)
+	if access.Can == nil {
+		access.Can = func(string) bool { return false }
+	}
+	return access
+}
+
+// GraphQLService serves the read-only GraphQL API over tickets, technicians, clients,
+// stock balances and financial summaries. The schema is internal/graph/schema.graphqls;
+// the relations of the rows are loaded through per-query dataloaders.
+type GraphQLService interface {
+	Execute(ctx context.Context, params *graphql.RawParams, access GraphQLAccess) *graphql.Response
+}
+
+type graphqlService struct {
+	executor       *executor.Executor
+	repo           repositories.GraphQLRepository
+	ticketRepo     repositories.TicketRepository
+	clientRepo     repositories.ClientRepository
+	technicianRepo repositories.TechnicianRepository
+	stock          StockService
+	financial      *FinancialService
+}
+
+func NewGraphQLService(repo repositories.GraphQLRepository, ticketRepo repositories.TicketRepository, clientRepo repositories.ClientRepository, technicianRepo repositories.TechnicianRepository, stock StockService, financial *FinancialService) GraphQLService {
+	s := &graphqlService{
+		repo:           repo,
+		ticketRepo:     ticketRepo,
+		clientRepo:     clientRepo,
+		technicianRepo: technicianRepo,
+		stock:          stock,
+		financial:      financial,
+	}
+
+	config := graph.Config{Resolvers: s}
+	config.Complexity.Client.Tickets = func(childComplexity int, limit *int) int {
+		return childComplexity * nestedTicketLimit(limit)
+	}
+	config.Complexity.Technician.Tickets = func(childComplexity int, limit *int) int {
+		return childComplexity * nestedTicketLimit(limit)
+	}
+	s.executor = executor.New(graph.NewExecutableSchema(config))
+	s.executor.Use(extension.Introspection{})
+	s.executor.Use(extension.FixedComplexityLimit(graphqlComplexityLimit))
+	return s
+}
+
+func (s *graphqlService) Execute(ctx context.Context, params *graphql.RawParams, access GraphQLAccess) *graphql.Response {
+	ctx = context.WithValue(ctx, graphqlAccessKey{}, access)
+	ctx = context.WithValue(ctx, graphqlLoadersKey{}, newGraphQLLoaders(s.repo))
+	ctx = graphql.StartOperationTrace(ctx)
+	now := graphql.Now()
+	params.ReadTime = graphql.TraceTiming{Start: now, End: now}
+
+	operation, errs := s.executor.CreateOperationContext(ctx, params)
+	if errs != nil {
+		return s.executor.DispatchError(graphql.WithOperationContext(ctx, operation), errs)
+	}
+	responses, ctx := s.executor.DispatchOperation(ctx, operation)
+	return responses(ctx)
+}
+
+func (s *graphqlService) Query() graph.QueryResolver { return &graphqlQueryResolver{s} }
+
+func (s *graphqlService) Ticket() graph.TicketResolver { return &graphqlTicketResolver{s} }
+
+func (s *graphqlService) Client() graph.ClientResolver { return &graphqlClientResolver{s} }
+
+func (s *graphqlService) Technician() graph.TechnicianResolver {
+	return &graphqlTechnicianResolver{s}
+}
+
+func (s *graphqlService) StockMovement() graph.StockMovementResolver {
+	return &graphqlStockMovementResolver{s}
+}
+
+// notFoundAsNil turns a missing record into a null field
+func notFoundAsNil[T any](value *T, err error) (*T, error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func requireGraphQLPermission(ctx context.Context, permission string) error {
+	if !graphqlAccessFrom(ctx).Can(permission) {
+		return fmt.Errorf("permission %s required", permission)
+	}
+	return nil
+}
+
+// graphqlPointers returns pointers to the rows, the shape the generated schema resolves
+func graphqlPointers[T any](rows []T) []*T {
+	pointers := make([]*T, len(rows))
+	for i := range rows {
+		pointers[i] = &rows[i]
+	}
+	return pointers
+}
+
+func graphqlString(arg *string) string {
+	if arg == nil {
+		return ""
+	}
+	return *arg
+}
+
+func graphqlInt(arg *int, fallback int) int {
+	if arg == nil {
+		return fallback
+	}
+	return *arg
+}
+
+func nestedTicketLimit(limit *int) int {
+	n := graphqlInt(limit, graphqlNestedTickets)
+	if n < 1 || n > graphqlNestedTickets {
+		n = graphqlNestedTickets
+	}
+	return n
+}
+
+// =============== Query ===============
+
+type graphqlQueryResolver struct{ *graphqlService }
+
+func (r *graphqlQueryResolver) Ticket(ctx context.Context, id string) (*models.Ticket, error) {
+	return notFoundAsNil(r.ticketRepo.WithContext(ctx).FindByID(id))
+}
+
+func (r *graphqlQueryResolver) Tickets(ctx context.Context, status, typeArg, priority, source, clientID, technicianID, categoryID, nodeID, search, dateFrom, dateTo *string, page, size *int) ([]*models.Ticket, error) {
+	filters := &models.TicketFilters{
+		Status:       graphqlString(status),
+		Type:         graphqlString(typeArg),
+		Priority:     graphqlString(priority),
+		Source:       graphqlString(source),
+		ClientID:     graphqlString(clientID),
+		TechnicianID: graphqlString(technicianID),
+		CategoryID:   graphqlString(categoryID),
+		NodeID:       graphqlString(nodeID),
+		Search:       graphqlString(search),
+		DateFrom:     graphqlString(dateFrom),
+		DateTo:       graphqlString(dateTo),
+		Scope:        graphqlAccessFrom(ctx).Scope,
+	}
+	tickets, _, err := r.ticketRepo.WithContext(ctx).FindAll(graphqlInt(page, 0), pagination.Size(pagination.Tickets, graphqlInt(size, 0)), filters)
+	return graphqlPointers(tickets), err
+}
+
+func (r *graphqlQueryResolver) Client(ctx context.Context, id string) (*models.Client, error) {
+	return notFoundAsNil(r.clientRepo.WithContext(ctx).WithScope(graphqlAccessFrom(ctx).Scope).GetByID(id))
+}
+
+func (r *graphqlQueryResolver) Clients(ctx context.Context, search *string, page, size *int) ([]*models.Client, error) {
+	repo := r.clientRepo.WithContext(ctx).WithScope(graphqlAccessFrom(ctx).Scope)
+	pageNumber := graphqlInt(page, 0)
+	pageSize := pagination.Size(pagination.Clients, graphqlInt(size, 0))
+	if term := graphqlString(search); term != "" {
+		clients, _, err := repo.Search(term, pageNumber, pageSize)
+		return graphqlPointers(clients), err
+	}
+	clients, _, err := repo.GetAll(pageNumber, pageSize)
+	return graphqlPointers(clients), err
+}
+
+func (r *graphqlQueryResolver) Technician(ctx context.Context, id string) (*models.Technician, error) {
+	return notFoundAsNil(r.technicianRepo.WithContext(ctx).WithScope(graphqlAccessFrom(ctx).Scope).FindByID(id))
+}
+
+func (r *graphqlQueryResolver) Technicians(ctx context.Context, search, status, typeArg, city, state *string, page, size *int) ([]*models.Technician, error) {
+	technicians, _, err := r.technicianRepo.WithContext(ctx).WithScope(graphqlAccessFrom(ctx).Scope).SearchWithFilters(
+		graphqlString(search), graphqlString(status), graphqlString(typeArg), graphqlString(city), graphqlString(state),
+		graphqlInt(page, 0), pagination.Size(pagination.Technicians, graphqlInt(size, 0)),
+	)
+	return graphqlPointers(technicians), err
+}
+
+func (r *graphqlQueryResolver) StockBalances(ctx context.Context, itemID, locationID, search *string, lowStock *bool, page, size *int) ([]*models.StockBalanceResponse, error) {
+	if err := requireGraphQLPermission(ctx, graphqlStockPermission); err != nil {
+		return nil, err
+	}
+	result, err := r.stock.WithContext(ctx).ListBalances(models.StockBalanceFilter{
+		ItemID:     graphqlString(itemID),
+		LocationID: graphqlString(locationID),
+		Search:     graphqlString(search),
+		LowStock:   lowStock != nil && *lowStock,
+		Page:       graphqlInt(page, 1),
+		PageSize:   pagination.Size(pagination.Stock, graphqlInt(size, 0)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return graphqlPointers(result.Data), nil
+}
+
+func (r *graphqlQueryResolver) FinancialSummary(ctx context.Context, period, startDate, endDate *string) (*models.FinancialSummary, error) {
+	if err := requireGraphQLPermission(ctx, graphqlFinancePermission); err != nil {
+		return nil, err
+	}
+	tenantID, _ := tenant.FromContext(ctx)
+	dashboard, err := r.financial.WithContext(ctx).GetDashboard(models.DashboardFilter{
+		Period:    graphqlString(period),
+		StartDate: graphqlString(startDate),
+		EndDate:   graphqlString(endDate),
+		TenantID:  tenantID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &models.FinancialSummary{
+		TotalIncome:     dashboard.Summary.TotalIncome,
+		TotalExpense:    dashboard.Summary.TotalExpense,
+		Balance:         dashboard.Summary.Balance,
+		PendingPayments: dashboard.PendingPayments,
+		OverdueCount:    dashboard.OverdueCount,
+	}, nil
+}
+
+// =============== Relations ===============
+
+type graphqlTicketResolver struct{ *graphqlService }
+
+func (r *graphqlTicketResolver) Status(_ context.Context, obj *models.Ticket) (string, error) {
+	return string(obj.Status), nil
+}
+
+func (r *graphqlTicketResolver) Type(_ context.Context, obj *models.Ticket) (string, error) {
+	return string(obj.Type), nil
+}
+
+func (r *graphqlTicketResolver) Priority(_ context.Context, obj *models.Ticket) (string, error) {
+	return string(obj.Priority), nil
+}
+
+func (r *graphqlTicketResolver) Source(_ context.Context, obj *models.Ticket) (string, error) {
+	return string(obj.Source), nil
+}
+
+func (r *graphqlTicketResolver) DispatchStatus(_ context.Context, obj *models.Ticket) (string, error) {
+	return string(obj.DispatchStatus), nil
+}
+
+// Client uses the client preloaded with the ticket, if any
+func (r *graphqlTicketResolver) Client(ctx context.Context, obj *models.Ticket) (*models.Client, error) {
+	if obj.Client != nil || obj.ClientID == nil {
+		return obj.Client, nil
+	}
+	return graphqlLoadersFrom(ctx).clients.Load(ctx, *obj.ClientID)
+}
+
+func (r *graphqlTicketResolver) Technicians(ctx context.Context, obj *models.Ticket) ([]*models.Technician, error) {
+	return graphqlLoadersFrom(ctx).ticketTechnicians.Load(ctx, obj.ID)
+}
+
+func (r *graphqlTicketResolver) StockUsage(ctx context.Context, obj *models.Ticket) ([]*models.StockMovement, error) {
+	if err := requireGraphQLPermission(ctx, graphqlStockPermission); err != nil {
+		return nil, err
+	}
+	return graphqlLoadersFrom(ctx).ticketStockUsage.Load(ctx, obj.ID)
+}
+
+func (r *graphqlTicketResolver) Financial(ctx context.Context, obj *models.Ticket) (*models.TicketFinancialTotals, error) {
+	if err := requireGraphQLPermission(ctx, graphqlFinancePermission); err != nil {
+		return nil, err
+	}
+	return graphqlLoadersFrom(ctx).ticketFinancial.Load(ctx, obj.ID)
+}
+
+type graphqlClientResolver struct{ *graphqlService }
+
+func (r *graphqlClientResolver) Tickets(ctx context.Context, obj *models.Client, limit *int) ([]*models.Ticket, error) {
+	return graphqlLoadersFrom(ctx).clientTickets.Load(ctx, nestedTicketsKey{ParentID: obj.ID, Limit: nestedTicketLimit(limit)})
+}
+
+type graphqlTechnicianResolver struct{ *graphqlService }
+
+func (r *graphqlTechnicianResolver) Emails(_ context.Context, obj *models.Technician) ([]*models.EmailEntry, error) {
+	return graphqlPointers(obj.Emails), nil
+}
+
+func (r *graphqlTechnicianResolver) Phones(_ context.Context, obj *models.Technician) ([]*models.PhoneEntry, error) {
+	return graphqlPointers(obj.Phones), nil
+}
+
+func (r *graphqlTechnicianResolver) Skills(_ context.Context, obj *models.Technician) ([]string, error) {
+	skills := make([]string, 0, len(obj.Skills))
+	for skill, has := range obj.Skills {
+		if has {
+			skills = append(skills, skill)
+		}
+	}
+	sort.Strings(skills)
+	return skills, nil
+}
+
+func (r *graphqlTechnicianResolver) Tickets(ctx context.Context, obj *models.Technician, limit *int) ([]*models.Ticket, error) {
+	return graphqlLoadersFrom(ctx).technicianTickets.Load(ctx, nestedTicketsKey{ParentID: obj.ID, Limit: nestedTicketLimit(limit)})
+}
+
+type graphqlStockMovementResolver struct{ *graphqlService }
+
+func (r *graphqlStockMovementResolver) Type(_ context.Context, obj *models.StockMovement) (string, error) {
+	return string(obj.Type), nil
+}
+
+func (r *graphqlStockMovementResolver) Status(_ context.Context, obj *models.StockMovement) (string, error) {
+	return string(obj.Status), nil
+}
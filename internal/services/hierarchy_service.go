@@ -1,9 +1,15 @@
 package services
 
 import (
+	"errors"
+	"strings"
+
+	"github.com/shigake/tech-iq-back/internal/models"
 	"github.com/shigake/tech-iq-back/internal/repositories"
 )
 
+var ErrScopeNotAccessible = errors.New("escopo fora do acesso do usuário")
+
 type HierarchyService struct {
 	hierarchyRepo repositories.HierarchyRepository
 }
@@ -46,3 +52,53 @@ func (s *HierarchyService) CanViewNode(userID string, nodeID uint) (bool, error)
 
 	return false, nil
 }
+
+// GetScopeUserIDs retorna os usuários membros dos escopos do usuário e de seus descendentes.
+// Com scopeID diferente de zero restringe a esse node, que precisa estar dentro dos escopos.
+func (s *HierarchyService) GetScopeUserIDs(userID string, scopeID uint) ([]string, error) {
+	memberships, err := s.hierarchyRepo.GetUserMemberships(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	nodeIDs := make([]uint, 0, len(memberships))
+	for _, m := range memberships {
+		if m.NodeID != 0 {
+			nodeIDs = append(nodeIDs, m.NodeID)
+		}
+	}
+
+	if scopeID != 0 {
+		node, err := s.hierarchyRepo.GetNodeByID(scopeID)
+		if err != nil {
+			return nil, ErrScopeNotAccessible
+		}
+		allowed := false
+		for _, m := range memberships {
+			if m.Node != nil && (node.Path == m.Node.Path || strings.HasPrefix(node.Path, m.Node.Path+".")) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return nil, ErrScopeNotAccessible
+		}
+		nodeIDs = []uint{scopeID}
+	}
+
+	return s.hierarchyRepo.GetScopeMemberUserIDs(nodeIDs)
+}
+
+// GetNodeUserIDs retorna os usuários membros do node e de seus descendentes, sem verificar
+// o acesso (uso administrativo)
+func (s *HierarchyService) GetNodeUserIDs(nodeID uint) ([]string, error) {
+	if _, err := s.hierarchyRepo.GetNodeByID(nodeID); err != nil {
+		return nil, ErrScopeNotAccessible
+	}
+	return s.hierarchyRepo.GetScopeMemberUserIDs([]uint{nodeID})
+}
+
+// GetNode retorna um node da hierarquia
+func (s *HierarchyService) GetNode(nodeID uint) (*models.Node, error) {
+	return s.hierarchyRepo.GetNodeByID(nodeID)
+}
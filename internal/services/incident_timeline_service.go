@@ -0,0 +1,406 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"gorm.io/gorm"
+)
+
+const (
+	// Longest window a timeline can cover
+	incidentTimelineMaxWindow = 7 * 24 * time.Hour
+	// A minute is a 5xx spike when it has enough requests and this share of them failed
+	errorSpikeMinSamples = 20
+	errorSpikeRate       = 0.05
+	errorSpikeCritical   = 0.25
+	incidentErrorLimit   = 200
+)
+
+var (
+	ErrDeployMarkerNotFound  = errors.New("deploy marker not found")
+	ErrIncidentWindowInvalid = errors.New("to must be after from")
+	ErrIncidentWindowTooLong = errors.New("the window can't exceed 7 days")
+)
+
+// IncidentTimelineService assembles the events of every module in a time window into a
+// single timeline for postmortems: 5xx spikes from the request metrics, panics, failed
+// jobs, failed security events, system alerts, status page incidents and the deploys
+// posted by the CI pipeline.
+type IncidentTimelineService interface {
+	Build(from, to time.Time) (*models.IncidentTimeline, error)
+	// RenderMarkdown formats the timeline as the postmortem document
+	RenderMarkdown(timeline *models.IncidentTimeline) string
+
+	// Deploy markers
+	ListDeploys(from, to time.Time) ([]models.DeployMarker, error)
+	CreateDeploy(userID string, req *models.CreateDeployMarkerRequest) (*models.DeployMarker, error)
+	DeleteDeploy(id string) error
+}
+
+type incidentTimelineService struct {
+	repo repositories.IncidentTimelineRepository
+}
+
+func NewIncidentTimelineService(repo repositories.IncidentTimelineRepository) IncidentTimelineService {
+	return &incidentTimelineService{repo: repo}
+}
+
+func (s *incidentTimelineService) Build(from, to time.Time) (*models.IncidentTimeline, error) {
+	if !to.After(from) {
+		return nil, ErrIncidentWindowInvalid
+	}
+	if to.Sub(from) > incidentTimelineMaxWindow {
+		return nil, ErrIncidentWindowTooLong
+	}
+
+	var events []models.IncidentTimelineEvent
+	for _, collect := range []func(from, to time.Time) ([]models.IncidentTimelineEvent, error){
+		s.deployEvents,
+		s.errorRateEvents,
+		s.errorEvents,
+		s.jobEvents,
+		s.securityEvents,
+		s.alertEvents,
+		s.incidentEvents,
+	} {
+		collected, err := collect(from, to)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, collected...)
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].At.Before(events[j].At) })
+	counts := make(map[string]int)
+	for _, event := range events {
+		counts[event.Source]++
+	}
+	if events == nil {
+		events = []models.IncidentTimelineEvent{}
+	}
+	return &models.IncidentTimeline{
+		From:        from,
+		To:          to,
+		GeneratedAt: time.Now(),
+		Counts:      counts,
+		Events:      events,
+	}, nil
+}
+
+func (s *incidentTimelineService) deployEvents(from, to time.Time) ([]models.IncidentTimelineEvent, error) {
+	markers, err := s.repo.FindDeploys(from, to)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]models.IncidentTimelineEvent, 0, len(markers))
+	for _, marker := range markers {
+		title := "Deploy " + marker.Version
+		if marker.Environment != "" {
+			title += " (" + marker.Environment + ")"
+		}
+		details := marker.Description
+		if marker.CommitSHA != "" {
+			details = strings.TrimSpace("commit " + marker.CommitSHA + " " + details)
+		}
+		events = append(events, models.IncidentTimelineEvent{
+			At:           marker.DeployedAt,
+			Source:       models.TimelineSourceDeploy,
+			Severity:     models.AlertSeverityInfo,
+			Title:        title,
+			Details:      details,
+			ResourceType: "deploy_marker",
+			ResourceID:   marker.ID,
+		})
+	}
+	return events, nil
+}
+
+// errorRateEvents merges the consecutive spike minutes into one event per spike
+func (s *incidentTimelineService) errorRateEvents(from, to time.Time) ([]models.IncidentTimelineEvent, error) {
+	buckets, err := s.repo.ErrorRateByMinute(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []models.IncidentTimelineEvent
+	var start, end time.Time
+	var samples, failed int64
+	peak := 0.0
+	flush := func() error {
+		if samples == 0 {
+			return nil
+		}
+		routes, err := s.repo.TopErrorRoutes(start, end, 3)
+		if err != nil {
+			return err
+		}
+		top := make([]string, 0, len(routes))
+		for _, route := range routes {
+			top = append(top, fmt.Sprintf("%s %s (%d)", route.Method, route.Path, route.Errors))
+		}
+
+		severity := models.AlertSeverityWarning
+		if peak >= errorSpikeCritical {
+			severity = models.AlertSeverityCritical
+		}
+		endAt := end
+		events = append(events, models.IncidentTimelineEvent{
+			At:       start,
+			EndAt:    &endAt,
+			Source:   models.TimelineSourceErrorRate,
+			Severity: severity,
+			Title:    fmt.Sprintf("5xx spike: %d of %d requests failed (peak %.0f%%)", failed, samples, math.Round(peak*100)),
+			Details:  strings.Join(top, ", "),
+		})
+		samples, failed, peak = 0, 0, 0
+		return nil
+	}
+
+	for _, bucket := range buckets {
+		rate := 0.0
+		if bucket.Samples > 0 {
+			rate = float64(bucket.Errors) / float64(bucket.Samples)
+		}
+		if bucket.Samples < errorSpikeMinSamples || rate < errorSpikeRate {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		// A gap of a quiet minute closes the spike
+		if samples > 0 && bucket.Bucket.After(end) {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+		if samples == 0 {
+			start = bucket.Bucket
+		}
+		end = bucket.Bucket.Add(time.Minute)
+		samples += bucket.Samples
+		failed += bucket.Errors
+		peak = math.Max(peak, rate)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (s *incidentTimelineService) errorEvents(from, to time.Time) ([]models.IncidentTimelineEvent, error) {
+	logs, err := s.repo.CriticalErrors(from, to, incidentErrorLimit)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]models.IncidentTimelineEvent, 0, len(logs))
+	for _, entry := range logs {
+		events = append(events, models.IncidentTimelineEvent{
+			At:           entry.CreatedAt,
+			Source:       models.TimelineSourceError,
+			Severity:     models.AlertSeverityCritical,
+			Title:        fmt.Sprintf("%s %s: %s", entry.Method, entry.Endpoint, entry.Action),
+			Details:      entry.ErrorMessage,
+			ResourceType: "error_log",
+			ResourceID:   entry.ID,
+		})
+	}
+	return events, nil
+}
+
+func (s *incidentTimelineService) jobEvents(from, to time.Time) ([]models.IncidentTimelineEvent, error) {
+	files, err := s.repo.FailedAttachments(from, to)
+	if err != nil {
+		return nil, err
+	}
+	var events []models.IncidentTimelineEvent
+	for _, file := range files {
+		if file.NextAttemptAt == nil {
+			continue
+		}
+		// The processing job schedules the retry 2^attempts minutes after the failure
+		failedAt := file.NextAttemptAt.Add(-time.Duration(math.Pow(2, float64(file.Attempts))) * time.Minute)
+		if failedAt.Before(from) || !failedAt.Before(to) {
+			continue
+		}
+		events = append(events, models.IncidentTimelineEvent{
+			At:           failedAt,
+			Source:       models.TimelineSourceJob,
+			Severity:     models.AlertSeverityWarning,
+			Title:        fmt.Sprintf("Attachment processing failed (attempt %d)", file.Attempts),
+			Details:      file.ProcessingError,
+			ResourceType: "ticket_file",
+			ResourceID:   file.ID,
+		})
+	}
+
+	actions, err := s.repo.FailedRemediations(from, to)
+	if err != nil {
+		return nil, err
+	}
+	for _, action := range actions {
+		title := "Remediation failed: " + action.Setting + " = " + action.NewValue
+		if action.Rule != nil {
+			title = "Remediation failed: " + action.Rule.Name
+		}
+		events = append(events, models.IncidentTimelineEvent{
+			At:           action.AppliedAt,
+			Source:       models.TimelineSourceJob,
+			Severity:     models.AlertSeverityWarning,
+			Title:        title,
+			Details:      action.Error,
+			ResourceType: "remediation_action",
+			ResourceID:   action.ID,
+		})
+	}
+	return events, nil
+}
+
+func (s *incidentTimelineService) securityEvents(from, to time.Time) ([]models.IncidentTimelineEvent, error) {
+	buckets, err := s.repo.FailedSecurityEvents(from, to)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]models.IncidentTimelineEvent, 0, len(buckets))
+	for _, bucket := range buckets {
+		severity := models.AlertSeverityInfo
+		if bucket.Attempts >= 10 {
+			severity = models.AlertSeverityWarning
+		}
+		endAt := bucket.Bucket.Add(time.Minute)
+		events = append(events, models.IncidentTimelineEvent{
+			At:       bucket.Bucket,
+			EndAt:    &endAt,
+			Source:   models.TimelineSourceSecurity,
+			Severity: severity,
+			Title:    fmt.Sprintf("%d × %s", bucket.Attempts, bucket.Action),
+			Details:  fmt.Sprintf("%d accounts, %d IPs", bucket.Accounts, bucket.IPs),
+		})
+	}
+	return events, nil
+}
+
+func (s *incidentTimelineService) alertEvents(from, to time.Time) ([]models.IncidentTimelineEvent, error) {
+	alerts, err := s.repo.SystemAlerts(from, to)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]models.IncidentTimelineEvent, 0, len(alerts))
+	for _, alert := range alerts {
+		events = append(events, models.IncidentTimelineEvent{
+			At:           alert.CreatedAt,
+			EndAt:        alert.ResolvedAt,
+			Source:       models.TimelineSourceAlert,
+			Severity:     alert.Severity,
+			Title:        alert.Title,
+			Details:      alert.Message,
+			ResourceType: "alert",
+			ResourceID:   alert.ID,
+		})
+	}
+	return events, nil
+}
+
+func (s *incidentTimelineService) incidentEvents(from, to time.Time) ([]models.IncidentTimelineEvent, error) {
+	incidents, err := s.repo.StatusIncidents(from, to)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]models.IncidentTimelineEvent, 0, len(incidents))
+	for _, incident := range incidents {
+		severity := models.AlertSeverityWarning
+		if incident.Severity == models.IncidentSeverityCritical {
+			severity = models.AlertSeverityCritical
+		}
+		events = append(events, models.IncidentTimelineEvent{
+			At:           incident.StartedAt,
+			EndAt:        incident.ResolvedAt,
+			Source:       models.TimelineSourceIncident,
+			Severity:     severity,
+			Title:        fmt.Sprintf("%s [%s, %s]", incident.Title, incident.Severity, incident.Status),
+			Details:      incident.Message,
+			ResourceType: "status_incident",
+			ResourceID:   incident.ID,
+		})
+	}
+	return events, nil
+}
+
+func (s *incidentTimelineService) RenderMarkdown(timeline *models.IncidentTimeline) string {
+	const layout = "2006-01-02 15:04:05 MST"
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# Incident timeline\n\n")
+	fmt.Fprintf(&b, "- Window: %s → %s\n", timeline.From.Format(layout), timeline.To.Format(layout))
+	fmt.Fprintf(&b, "- Generated: %s\n", timeline.GeneratedAt.Format(layout))
+
+	sources := make([]string, 0, len(timeline.Counts))
+	for source := range timeline.Counts {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	for _, source := range sources {
+		fmt.Fprintf(&b, "- %s: %d\n", source, timeline.Counts[source])
+	}
+
+	b.WriteString("\n| Time | Until | Source | Severity | Event | Details |\n")
+	b.WriteString("|---|---|---|---|---|---|\n")
+	for _, event := range timeline.Events {
+		until := ""
+		if event.EndAt != nil {
+			until = event.EndAt.Format(layout)
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %s |\n",
+			event.At.Format(layout), until, event.Source, event.Severity,
+			markdownCell(event.Title), markdownCell(event.Details))
+	}
+	if len(timeline.Events) == 0 {
+		b.WriteString("\nNo events in this window.\n")
+	}
+	return b.String()
+}
+
+// markdownCell keeps free text from breaking the table row
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	s = strings.ReplaceAll(s, "\r", "")
+	return strings.ReplaceAll(s, "\n", " ")
+}
+
+func (s *incidentTimelineService) ListDeploys(from, to time.Time) ([]models.DeployMarker, error) {
+	return s.repo.FindDeploys(from, to)
+}
+
+func (s *incidentTimelineService) CreateDeploy(userID string, req *models.CreateDeployMarkerRequest) (*models.DeployMarker, error) {
+	marker := &models.DeployMarker{
+		Version:     strings.TrimSpace(req.Version),
+		Environment: strings.TrimSpace(req.Environment),
+		CommitSHA:   strings.TrimSpace(req.CommitSHA),
+		Description: req.Description,
+		DeployedAt:  time.Now(),
+		CreatedBy:   userID,
+	}
+	if req.DeployedAt != nil {
+		marker.DeployedAt = *req.DeployedAt
+	}
+	if err := s.repo.CreateDeploy(marker); err != nil {
+		return nil, err
+	}
+	return marker, nil
+}
+
+func (s *incidentTimelineService) DeleteDeploy(id string) error {
+	if err := s.repo.DeleteDeploy(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrDeployMarkerNotFound
+		}
+		return err
+	}
+	return nil
+}
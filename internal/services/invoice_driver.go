@@ -0,0 +1,280 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// Invoicing drivers
+const (
+	InvoiceDriverNone    = "none"
+	InvoiceDriverSandbox = "sandbox" // issues fake invoices locally, for development
+	InvoiceDriverGateway = "gateway" // posts to the HTTP gateway of the NFS-e provider
+)
+
+var ErrInvoicingNotConfigured = errors.New("invoicing driver not configured")
+
+// InvoiceConfig configures the invoicing driver and what the invoices are issued with
+type InvoiceConfig struct {
+	Driver       string
+	GatewayURL   string
+	GatewayToken string
+	ServiceCode  string          // municipal service code (item of the LC 116 list)
+	ISSRate      decimal.Decimal // percent
+}
+
+// InvoiceIssueRequest is what the driver sends to the provider; Reference is the invoice
+// ID, so a provider that sees it twice returns the first invoice instead of a new one
+type InvoiceIssueRequest struct {
+	Reference   string          `json:"reference"`
+	Kind        string          `json:"kind"`
+	Amount      decimal.Decimal `json:"amount"`
+	ISSRate     decimal.Decimal `json:"issRate"`
+	ISSAmount   decimal.Decimal `json:"issAmount"`
+	ServiceCode string          `json:"serviceCode"`
+	Description string          `json:"description"`
+	Taker       InvoiceTaker    `json:"taker"`
+}
+
+// InvoiceTaker is the client the invoice is issued to (tomador)
+type InvoiceTaker struct {
+	Name     string `json:"name"`
+	Document string `json:"document"` // CPF or CNPJ, digits only
+	Email    string `json:"email,omitempty"`
+	Street   string `json:"street,omitempty"`
+	Number   string `json:"number,omitempty"`
+	District string `json:"district,omitempty"`
+	City     string `json:"city,omitempty"`
+	State    string `json:"state,omitempty"`
+	ZipCode  string `json:"zipCode,omitempty"`
+}
+
+// InvoiceIssueResult is the answer of the provider: ISSUED with the documents, PROCESSING
+// with the protocol to query later, or REJECTED with the reason in Message
+type InvoiceIssueResult struct {
+	Status           string `json:"status"`
+	Protocol         string `json:"protocol"`
+	Number           string `json:"number"`
+	VerificationCode string `json:"verificationCode"`
+	Message          string `json:"message"`
+	XML              []byte `json:"xml"` // base64 in JSON
+	PDF              []byte `json:"pdf"`
+}
+
+// InvoiceDriver issues invoices with a provider. Transport failures are returned as
+// errors and retried; refusals come back as a REJECTED result.
+type InvoiceDriver interface {
+	Name() string
+	Issue(req *InvoiceIssueRequest) (*InvoiceIssueResult, error)
+	// Query returns the state of an invoice the provider answered PROCESSING for
+	Query(protocol string) (*InvoiceIssueResult, error)
+	Cancel(invoice *models.Invoice, reason string) error
+}
+
+// NewInvoiceDriver returns the configured driver; nil when invoicing is off
+func NewInvoiceDriver(cfg InvoiceConfig) InvoiceDriver {
+	switch strings.ToLower(cfg.Driver) {
+	case InvoiceDriverSandbox:
+		return &sandboxInvoiceDriver{}
+	case InvoiceDriverGateway:
+		return &gatewayInvoiceDriver{
+			baseURL: strings.TrimRight(cfg.GatewayURL, "/"),
+			token:   cfg.GatewayToken,
+			client:  &http.Client{Timeout: 30 * time.Second},
+		}
+	}
+	return nil
+}
+
+// gatewayInvoiceDriver talks to an HTTP gateway in front of the municipal webservices:
+// POST /invoices issues, GET /invoices/{protocol} queries and POST
+// /invoices/{protocol}/cancel cancels, all answering an InvoiceIssueResult
+type gatewayInvoiceDriver struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+func (d *gatewayInvoiceDriver) Name() string {
+	return InvoiceDriverGateway
+}
+
+func (d *gatewayInvoiceDriver) Issue(req *InvoiceIssueRequest) (*InvoiceIssueResult, error) {
+	var result InvoiceIssueResult
+	if err := d.do(http.MethodPost, "/invoices", req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (d *gatewayInvoiceDriver) Query(protocol string) (*InvoiceIssueResult, error) {
+	var result InvoiceIssueResult
+	if err := d.do(http.MethodGet, "/invoices/"+url.PathEscape(protocol), nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (d *gatewayInvoiceDriver) Cancel(invoice *models.Invoice, reason string) error {
+	return d.do(http.MethodPost, "/invoices/"+url.PathEscape(invoice.Protocol)+"/cancel",
+		map[string]string{"reason": reason, "number": invoice.Number}, nil)
+}
+
+func (d *gatewayInvoiceDriver) do(method, path string, body, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, d.baseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+d.token)
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// 422 is a refusal of the provider, answered with the result like a success
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusUnprocessableEntity {
+		return fmt.Errorf("invoicing gateway returned %s", resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid invoicing gateway answer: %w", err)
+	}
+	return nil
+}
+
+// sandboxInvoiceDriver issues every invoice at once with a made-up number, writing an
+// ABRASF-like XML and a PDF so the whole flow can be tried without a provider
+type sandboxInvoiceDriver struct{}
+
+func (d *sandboxInvoiceDriver) Name() string {
+	return InvoiceDriverSandbox
+}
+
+type sandboxNFSe struct {
+	XMLName          xml.Name `xml:"CompNfse"`
+	Number           string   `xml:"Nfse>InfNfse>Numero"`
+	VerificationCode string   `xml:"Nfse>InfNfse>CodigoVerificacao"`
+	IssuedAt         string   `xml:"Nfse>InfNfse>DataEmissao"`
+	Reference        string   `xml:"Nfse>InfNfse>OutrasInformacoes"`
+	Amount           string   `xml:"Nfse>InfNfse>Servico>Valores>ValorServicos"`
+	ISSRate          string   `xml:"Nfse>InfNfse>Servico>Valores>Aliquota"`
+	ISSAmount        string   `xml:"Nfse>InfNfse>Servico>Valores>ValorIss"`
+	ServiceCode      string   `xml:"Nfse>InfNfse>Servico>ItemListaServico"`
+	Description      string   `xml:"Nfse>InfNfse>Servico>Discriminacao"`
+	TakerDocument    string   `xml:"Nfse>InfNfse>TomadorServico>IdentificacaoTomador>CpfCnpj"`
+	TakerName        string   `xml:"Nfse>InfNfse>TomadorServico>RazaoSocial"`
+}
+
+func (d *sandboxInvoiceDriver) Issue(req *InvoiceIssueRequest) (*InvoiceIssueResult, error) {
+	now := time.Now()
+	number := now.Format("2006") + fmt.Sprintf("%09d", now.UnixNano()%1e9)
+	code := strings.ToUpper(strings.ReplaceAll(req.Reference, "-", "")[:8])
+
+	doc := sandboxNFSe{
+		Number:           number,
+		VerificationCode: code,
+		IssuedAt:         now.Format("2006-01-02T15:04:05"),
+		Reference:        req.Reference,
+		Amount:           req.Amount.StringFixed(2),
+		ISSRate:          req.ISSRate.StringFixed(2),
+		ISSAmount:        req.ISSAmount.StringFixed(2),
+		ServiceCode:      req.ServiceCode,
+		Description:      req.Description,
+		TakerDocument:    req.Taker.Document,
+		TakerName:        req.Taker.Name,
+	}
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	pdf, err := sandboxInvoicePDF(req, number, code, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return &InvoiceIssueResult{
+		Status:           models.InvoiceStatusIssued,
+		Protocol:         "SANDBOX-" + number,
+		Number:           number,
+		VerificationCode: code,
+		XML:              append([]byte(xml.Header), body...),
+		PDF:              pdf,
+	}, nil
+}
+
+func (d *sandboxInvoiceDriver) Query(protocol string) (*InvoiceIssueResult, error) {
+	return nil, fmt.Errorf("sandbox invoice %s is not pending", protocol)
+}
+
+func (d *sandboxInvoiceDriver) Cancel(invoice *models.Invoice, reason string) error {
+	return nil
+}
+
+// sandboxInvoicePDF is a one-page DANFSE-like summary of the invoice
+func sandboxInvoicePDF(req *InvoiceIssueRequest, number, code string, issuedAt time.Time) ([]byte, error) {
+	doc := newPDFDocument()
+	right := pdfPageWidth - pdfMargin
+	y := pdfMargin + 20
+
+	doc.Text(pdfMargin, y, 16, true, "", "NFS-e - Nota Fiscal de Serviços Eletrônica")
+	y += 18
+	doc.Text(pdfMargin, y, 9, false, "#B91C1C", "SANDBOX - documento sem valor fiscal")
+	y += 20
+	doc.Line(pdfMargin, y, right, y, "#999999")
+	y += 18
+
+	for _, row := range [][2]string{
+		{"Número", number},
+		{"Código de verificação", code},
+		{"Emissão", issuedAt.Format("02/01/2006 15:04")},
+		{"Tomador", req.Taker.Name},
+		{"CPF/CNPJ", req.Taker.Document},
+		{"Código do serviço", req.ServiceCode},
+	} {
+		doc.Text(pdfMargin, y, 10, true, "", row[0])
+		doc.Text(pdfMargin+150, y, 10, false, "", row[1])
+		y += 16
+	}
+
+	y += 8
+	doc.Text(pdfMargin, y, 10, true, "", "Discriminação dos serviços")
+	y += 16
+	for _, line := range doc.Wrap(req.Description, 10, false, right-pdfMargin) {
+		doc.Text(pdfMargin, y, 10, false, "", line)
+		y += 14
+	}
+
+	y += 12
+	doc.Line(pdfMargin, y, right, y, "#999999")
+	y += 18
+	for _, row := range [][2]string{
+		{"Valor dos serviços", "R$ " + req.Amount.StringFixed(2)},
+		{"Alíquota ISS", req.ISSRate.StringFixed(2) + "%"},
+		{"Valor do ISS", "R$ " + req.ISSAmount.StringFixed(2)},
+	} {
+		doc.Text(pdfMargin, y, 10, true, "", row[0])
+		doc.TextRight(right, y, 10, false, "", row[1])
+		y += 16
+	}
+	return doc.Bytes()
+}
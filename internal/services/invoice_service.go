@@ -0,0 +1,371 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvoiceNotFound           = errors.New("invoice not found")
+	ErrInvoiceEntryNotFound      = errors.New("financial entry not found")
+	ErrInvoiceEntryNotIncome     = errors.New("only income entries can be invoiced")
+	ErrInvoiceEntryCancelled     = errors.New("cancelled entries cannot be invoiced")
+	ErrInvoiceEntryInvoiced      = errors.New("the entry already has an invoice")
+	ErrInvoiceClientRequired     = errors.New("the entry has no client to invoice")
+	ErrInvoiceClientDocument     = errors.New("the client has no CPF or CNPJ")
+	ErrInvoiceNotRetryable       = errors.New("only invoices in error, rejected or stuck processing can be retried")
+	ErrInvoiceNotCancellable     = errors.New("invoices being processed or already cancelled cannot be cancelled")
+	ErrInvoiceDocumentMissing    = errors.New("the invoice has no such document")
+	errInvoiceProviderProcessing = errors.New("invoice still processing at the provider")
+)
+
+// InvoiceService issues the invoices of income entries. Creating one only queues it: the
+// issuance runs in the outbox dispatcher, which retries it with backoff while the
+// provider is unreachable or still processing it.
+type InvoiceService interface {
+	Create(req *models.CreateInvoiceRequest, userID string) (*models.Invoice, error)
+	List(filter models.InvoiceFilter, page, size int) (*models.PaginatedResponse, error)
+	Get(id string) (*models.Invoice, error)
+	// Issue runs one issuance attempt; it is the handler of the invoice issuance topic
+	Issue(id string) (string, error)
+	// Retry queues again an invoice in error, rejected or whose attempts ran out
+	Retry(id string) (*models.Invoice, error)
+	Cancel(id, reason string) (*models.Invoice, error)
+	// DocumentURL returns a download URL of the XML or PDF of an issued invoice
+	DocumentURL(id, document string) (*models.FileDownload, error)
+	// WithContext returns the service running its queries with ctx, inside the tenant it carries
+	WithContext(ctx context.Context) InvoiceService
+}
+
+type invoiceService struct {
+	repo      repositories.InvoiceRepository
+	financial *FinancialService
+	files     FileService
+	driver    InvoiceDriver
+	config    InvoiceConfig
+}
+
+func NewInvoiceService(repo repositories.InvoiceRepository, financial *FinancialService, files FileService, config InvoiceConfig) InvoiceService {
+	return &invoiceService{
+		repo:      repo,
+		financial: financial,
+		files:     files,
+		driver:    NewInvoiceDriver(config),
+		config:    config,
+	}
+}
+
+func (s *invoiceService) WithContext(ctx context.Context) InvoiceService {
+	scoped := *s
+	scoped.financial = s.financial.WithContext(ctx)
+	scoped.files = s.files.WithContext(ctx)
+	return &scoped
+}
+
+// RegisterInvoiceHandlers registers the outbox handler issuing the queued invoices
+func RegisterInvoiceHandlers(outbox OutboxService, invoices InvoiceService) {
+	outbox.Register(models.OutboxTopicInvoiceIssue, func(ctx context.Context, event *models.OutboxEvent) (string, error) {
+		var payload models.InvoiceIssuePayload
+		if err := json.Unmarshal([]byte(event.Payload), &payload); err != nil {
+			return "", fmt.Errorf("invalid payload: %w", err)
+		}
+		return invoices.WithContext(ctx).Issue(payload.InvoiceID)
+	})
+}
+
+func (s *invoiceService) Create(req *models.CreateInvoiceRequest, userID string) (*models.Invoice, error) {
+	entry, err := s.financial.GetEntryByID(req.EntryID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvoiceEntryNotFound
+		}
+		return nil, err
+	}
+	if entry.Type != models.FinancialEntryTypeIncome {
+		return nil, ErrInvoiceEntryNotIncome
+	}
+	if entry.Status == models.FinancialEntryStatusCancelled {
+		return nil, ErrInvoiceEntryCancelled
+	}
+	if entry.Client == nil {
+		return nil, ErrInvoiceClientRequired
+	}
+	if invoiceDocument(entry.Client) == "" {
+		return nil, ErrInvoiceClientDocument
+	}
+	if _, err := s.repo.FindActiveByEntry(entry.ID); err == nil {
+		return nil, ErrInvoiceEntryInvoiced
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	kind := req.Kind
+	if kind == "" {
+		kind = models.InvoiceKindNFSe
+	}
+	description := strings.TrimSpace(req.Description)
+	if description == "" {
+		description = entry.Description
+	}
+	amount := decimal.NewFromFloat(entry.Amount).Round(2)
+	driver := InvoiceDriverNone
+	if s.driver != nil {
+		driver = s.driver.Name()
+	}
+
+	invoice := &models.Invoice{
+		Kind:        kind,
+		EntryID:     entry.ID,
+		ClientID:    entry.Client.ID,
+		TicketID:    entry.TicketID,
+		Amount:      amount,
+		ISSRate:     s.config.ISSRate,
+		ISSAmount:   amount.Mul(s.config.ISSRate).Div(decimal.NewFromInt(100)).Round(2),
+		ServiceCode: s.config.ServiceCode,
+		Description: description,
+		Status:      models.InvoiceStatusPending,
+		Driver:      driver,
+		CreatedBy:   userID,
+	}
+	if err := s.repo.Enqueue(invoice); err != nil {
+		return nil, err
+	}
+	return s.Get(invoice.ID)
+}
+
+func (s *invoiceService) List(filter models.InvoiceFilter, page, size int) (*models.PaginatedResponse, error) {
+	invoices, total, err := s.repo.FindAll(filter, page, size)
+	if err != nil {
+		return nil, err
+	}
+	return models.NewPaginatedResponse(invoices, page, size, total), nil
+}
+
+func (s *invoiceService) Get(id string) (*models.Invoice, error) {
+	invoice, err := s.repo.FindByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvoiceNotFound
+		}
+		return nil, err
+	}
+	return invoice, nil
+}
+
+// Issue sends the invoice to the provider, or queries it when the provider already took
+// it. Errors make the outbox retry the attempt; a rejection is final until retried by hand.
+func (s *invoiceService) Issue(id string) (string, error) {
+	invoice, err := s.Get(id)
+	if err != nil {
+		return "", err
+	}
+	switch invoice.Status {
+	case models.InvoiceStatusIssued, models.InvoiceStatusRejected, models.InvoiceStatusCancelled:
+		return "invoice already " + strings.ToLower(invoice.Status), nil
+	}
+
+	now := time.Now()
+	invoice.Attempts++
+	invoice.LastAttemptAt = &now
+	if s.driver == nil {
+		return "", s.fail(invoice, ErrInvoicingNotConfigured)
+	}
+	invoice.Driver = s.driver.Name()
+
+	var result *InvoiceIssueResult
+	if invoice.Status == models.InvoiceStatusProcessing && invoice.Protocol != "" {
+		result, err = s.driver.Query(invoice.Protocol)
+	} else {
+		result, err = s.driver.Issue(s.issueRequest(invoice))
+	}
+	if err != nil {
+		return "", s.fail(invoice, err)
+	}
+	if result.Protocol != "" {
+		invoice.Protocol = result.Protocol
+	}
+
+	switch result.Status {
+	case models.InvoiceStatusIssued:
+		if err := s.storeDocuments(invoice, result); err != nil {
+			return "", s.fail(invoice, err)
+		}
+		invoice.Status = models.InvoiceStatusIssued
+		invoice.Number = result.Number
+		invoice.VerificationCode = result.VerificationCode
+		invoice.IssuedAt = &now
+		invoice.LastError = ""
+		if err := s.repo.Update(invoice); err != nil {
+			return "", err
+		}
+		return "invoice issued: " + invoice.Number, nil
+	case models.InvoiceStatusRejected:
+		invoice.Status = models.InvoiceStatusRejected
+		invoice.LastError = result.Message
+		if err := s.repo.Update(invoice); err != nil {
+			return "", err
+		}
+		return "invoice rejected: " + result.Message, nil
+	case models.InvoiceStatusProcessing:
+		invoice.Status = models.InvoiceStatusProcessing
+		invoice.LastError = ""
+		if err := s.repo.Update(invoice); err != nil {
+			return "", err
+		}
+		return "", errInvoiceProviderProcessing
+	default:
+		return "", s.fail(invoice, fmt.Errorf("unknown invoice status %q from the provider", result.Status))
+	}
+}
+
+// fail records a failed attempt and returns its error, for the outbox to retry it.
+// Invoices the provider already took (it returned a protocol) are PROCESSING, so the retry
+// queries them instead of issuing a second one.
+func (s *invoiceService) fail(invoice *models.Invoice, cause error) error {
+	invoice.Status = models.InvoiceStatusError
+	if invoice.Protocol != "" {
+		invoice.Status = models.InvoiceStatusProcessing
+	}
+	invoice.LastError = cause.Error()
+	if err := s.repo.Update(invoice); err != nil {
+		slog.Warn("Failed to record invoice attempt", "invoice_id", invoice.ID, "error", err)
+	}
+	return cause
+}
+
+// storeDocuments keeps the XML and PDF returned by the provider, once: a retry after a
+// failed update doesn't store them again
+func (s *invoiceService) storeDocuments(invoice *models.Invoice, result *InvoiceIssueResult) error {
+	name := "nfse-" + invoice.ID
+	if result.Number != "" {
+		name = "nfse-" + result.Number
+	}
+	if invoice.XMLFileID == nil && len(result.XML) > 0 {
+		file, err := s.files.Store(models.FileOwnerInvoice, invoice.ID, name+".xml", "application/xml", result.XML, invoice.CreatedBy)
+		if err != nil {
+			return fmt.Errorf("storing invoice XML: %w", err)
+		}
+		invoice.XMLFileID = &file.ID
+	}
+	if invoice.PDFFileID == nil && len(result.PDF) > 0 {
+		file, err := s.files.Store(models.FileOwnerInvoice, invoice.ID, name+".pdf", "application/pdf", result.PDF, invoice.CreatedBy)
+		if err != nil {
+			return fmt.Errorf("storing invoice PDF: %w", err)
+		}
+		invoice.PDFFileID = &file.ID
+	}
+	return nil
+}
+
+func (s *invoiceService) issueRequest(invoice *models.Invoice) *InvoiceIssueRequest {
+	req := &InvoiceIssueRequest{
+		Reference:   invoice.ID,
+		Kind:        invoice.Kind,
+		Amount:      invoice.Amount,
+		ISSRate:     invoice.ISSRate,
+		ISSAmount:   invoice.ISSAmount,
+		ServiceCode: invoice.ServiceCode,
+		Description: invoice.Description,
+	}
+	if client := invoice.Client; client != nil {
+		req.Taker = InvoiceTaker{
+			Name:     client.FullName,
+			Document: invoiceDocument(client),
+			Email:    client.Email,
+			Street:   client.Street,
+			Number:   client.Number,
+			District: client.Neighborhood,
+			City:     client.City,
+			State:    client.State,
+			ZipCode:  client.ZipCode,
+		}
+	}
+	return req
+}
+
+func (s *invoiceService) Retry(id string) (*models.Invoice, error) {
+	invoice, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	switch invoice.Status {
+	case models.InvoiceStatusError, models.InvoiceStatusProcessing:
+	case models.InvoiceStatusRejected:
+		// A rejected invoice is sent again as new, with the client data as it is now
+		invoice.Protocol = ""
+		invoice.Status = models.InvoiceStatusPending
+	default:
+		return nil, ErrInvoiceNotRetryable
+	}
+	if invoice.Status == models.InvoiceStatusError {
+		invoice.Status = models.InvoiceStatusPending
+	}
+	invoice.Entry, invoice.Client = nil, nil
+	if err := s.repo.Enqueue(invoice); err != nil {
+		return nil, err
+	}
+	return s.Get(invoice.ID)
+}
+
+// Cancel cancels the invoice with the provider when it was issued; invoices the provider
+// never took are only marked cancelled
+func (s *invoiceService) Cancel(id, reason string) (*models.Invoice, error) {
+	invoice, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	switch invoice.Status {
+	case models.InvoiceStatusProcessing, models.InvoiceStatusCancelled:
+		return nil, ErrInvoiceNotCancellable
+	case models.InvoiceStatusIssued:
+		if s.driver == nil {
+			return nil, ErrInvoicingNotConfigured
+		}
+		if err := s.driver.Cancel(invoice, reason); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	invoice.Status = models.InvoiceStatusCancelled
+	invoice.CancelledAt = &now
+	invoice.CancelReason = reason
+	if err := s.repo.Update(invoice); err != nil {
+		return nil, err
+	}
+	return invoice, nil
+}
+
+func (s *invoiceService) DocumentURL(id, document string) (*models.FileDownload, error) {
+	invoice, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	fileID := invoice.PDFFileID
+	if document == "xml" {
+		fileID = invoice.XMLFileID
+	}
+	if fileID == nil {
+		return nil, ErrInvoiceDocumentMissing
+	}
+	return s.files.DownloadURL(*fileID)
+}
+
+// invoiceDocument is the CNPJ of the client, else its CPF, digits only
+func invoiceDocument(client *models.Client) string {
+	document := client.CNPJ
+	if document == "" {
+		document = client.CPF
+	}
+	return onlyDigits(document)
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+)
+
+// locationBuffer é quantos eventos uma inscrição acumula antes de descartar
+const locationBuffer = 64
+
+// LocationHub distribui as localizações recebidas aos dashboards conectados. Fica em
+// memória: cada instância da API só entrega as localizações que ela mesma recebeu.
+type LocationHub struct {
+	mu            sync.RWMutex
+	subscriptions map[*LocationSubscription]struct{}
+}
+
+// LocationSubscription recebe as localizações e os eventos de cerca dos técnicos
+// visíveis para o inscrito
+type LocationSubscription struct {
+	Events      chan models.LocationStreamEvent
+	FenceEvents chan models.GeoFenceEvent
+	// userIDs são os usuários visíveis; nil quando todos são (admin sem escopo)
+	userIDs map[string]bool
+	dropped atomic.Int64
+}
+
+func NewLocationHub() *LocationHub {
+	return &LocationHub{subscriptions: make(map[*LocationSubscription]struct{})}
+}
+
+// Subscribe cria uma inscrição; userIDs nil recebe as localizações de todos os técnicos
+func (h *LocationHub) Subscribe(userIDs []string) *LocationSubscription {
+	sub := &LocationSubscription{
+		Events:      make(chan models.LocationStreamEvent, locationBuffer),
+		FenceEvents: make(chan models.GeoFenceEvent, locationBuffer),
+	}
+	if userIDs != nil {
+		sub.userIDs = make(map[string]bool, len(userIDs))
+		for _, id := range userIDs {
+			sub.userIDs[id] = true
+		}
+	}
+
+	h.mu.Lock()
+	h.subscriptions[sub] = struct{}{}
+	h.mu.Unlock()
+	return sub
+}
+
+func (h *LocationHub) Unsubscribe(sub *LocationSubscription) {
+	h.mu.Lock()
+	delete(h.subscriptions, sub)
+	h.mu.Unlock()
+}
+
+// HasSubscribers evita montar eventos quando ninguém está conectado
+func (h *LocationHub) HasSubscribers() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subscriptions) > 0
+}
+
+// Publish entrega o evento sem bloquear: uma inscrição lenta perde o evento, que fica
+// contado em Dropped
+func (h *LocationHub) Publish(event models.LocationStreamEvent) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for sub := range h.subscriptions {
+		if !sub.visible(event.UserID, event.TechnicianID) {
+			continue
+		}
+		select {
+		case sub.Events <- event:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
+
+// PublishFence entrega um evento de entrada ou saída de cerca, como Publish
+func (h *LocationHub) PublishFence(event models.GeoFenceEvent) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for sub := range h.subscriptions {
+		if !sub.visible(event.UserID, event.TechnicianID) {
+			continue
+		}
+		select {
+		case sub.FenceEvents <- event:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
+
+func (sub *LocationSubscription) visible(userID, technicianID string) bool {
+	return sub.userIDs == nil || sub.userIDs[userID] || sub.userIDs[technicianID]
+}
+
+// Dropped retorna e zera a quantidade de eventos descartados desde a última chamada
+func (sub *LocationSubscription) Dropped() int64 {
+	return sub.dropped.Swap(0)
+}
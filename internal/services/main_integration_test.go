@@ -0,0 +1,27 @@
+//go:build integration
+
+package services_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/shigake/tech-iq-back/internal/testutil"
+)
+
+// env is shared by the tests of the package; each test starts with env.Reset
+var env *testutil.Env
+
+func TestMain(m *testing.M) {
+	var err error
+	env, err = testutil.StartEnv(context.Background())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	env.Close()
+	os.Exit(code)
+}
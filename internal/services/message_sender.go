@@ -0,0 +1,177 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+var ErrMessagingNotConfigured = errors.New("messaging channel not configured")
+
+// MessageSender delivers a message to a client through one channel (e-mail, WhatsApp, SMS...)
+type MessageSender interface {
+	Channel() string
+	Send(to, subject, body string) error
+}
+
+// MessageAttachment is a file sent along with a message
+type MessageAttachment struct {
+	Name        string
+	ContentType string
+	Content     []byte
+}
+
+// AttachmentSender is a channel that can deliver files with the message (e-mail)
+type AttachmentSender interface {
+	SendWithAttachments(to, subject, body string, attachments []MessageAttachment) error
+}
+
+// SMTPConfig configures the e-mail channel
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+type smtpSender struct {
+	cfg SMTPConfig
+}
+
+// NewSMTPSender returns the e-mail channel; without a host every send fails with ErrMessagingNotConfigured
+func NewSMTPSender(cfg SMTPConfig) MessageSender {
+	return &smtpSender{cfg: cfg}
+}
+
+func (s *smtpSender) Channel() string {
+	return "EMAIL"
+}
+
+func (s *smtpSender) Send(to, subject, body string) error {
+	return s.SendWithAttachments(to, subject, body, nil)
+}
+
+// SendWithAttachments sends a plain text message, as multipart/mixed when it has attachments
+func (s *smtpSender) SendWithAttachments(to, subject, body string, attachments []MessageAttachment) error {
+	if s.cfg.Host == "" || s.cfg.From == "" {
+		return ErrMessagingNotConfigured
+	}
+	if strings.ContainsAny(to, "\r\n") {
+		return fmt.Errorf("invalid recipient %q", to)
+	}
+
+	var msg bytes.Buffer
+	msg.WriteString("From: " + s.cfg.From + "\r\n")
+	msg.WriteString("To: " + to + "\r\n")
+	msg.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n")
+	msg.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	text := strings.ReplaceAll(body, "\n", "\r\n")
+
+	if len(attachments) == 0 {
+		msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+		msg.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+		msg.WriteString(text)
+	} else {
+		parts := multipart.NewWriter(&msg)
+		msg.WriteString("Content-Type: multipart/mixed; boundary=" + parts.Boundary() + "\r\n\r\n")
+		part, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {"text/plain; charset=utf-8"},
+			"Content-Transfer-Encoding": {"8bit"},
+		})
+		if err != nil {
+			return err
+		}
+		part.Write([]byte(text))
+		for _, attachment := range attachments {
+			part, err := parts.CreatePart(textproto.MIMEHeader{
+				"Content-Type":              {attachment.ContentType},
+				"Content-Transfer-Encoding": {"base64"},
+				"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name})},
+			})
+			if err != nil {
+				return err
+			}
+			encoded := base64.StdEncoding.EncodeToString(attachment.Content)
+			for len(encoded) > 76 {
+				part.Write([]byte(encoded[:76] + "\r\n"))
+				encoded = encoded[76:]
+			}
+			part.Write([]byte(encoded + "\r\n"))
+		}
+		if err := parts.Close(); err != nil {
+			return err
+		}
+	}
+
+	var auth smtp.Auth
+	if s.cfg.Username != "" {
+		auth = smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)
+	}
+	return smtp.SendMail(net.JoinHostPort(s.cfg.Host, s.cfg.Port), auth, s.cfg.From, []string{to}, msg.Bytes())
+}
+
+// GatewayConfig configures a channel delivered through an HTTP gateway (SMS, push)
+type GatewayConfig struct {
+	URL   string
+	Token string
+}
+
+type gatewaySender struct {
+	channel string
+	cfg     GatewayConfig
+	client  *http.Client
+}
+
+// NewGatewaySender returns a channel that posts {to, subject, body} as JSON to the gateway,
+// with the token as bearer; without a URL every send fails with ErrMessagingNotConfigured
+func NewGatewaySender(channel string, cfg GatewayConfig) MessageSender {
+	return &gatewaySender{
+		channel: channel,
+		cfg:     cfg,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *gatewaySender) Channel() string {
+	return s.channel
+}
+
+func (s *gatewaySender) Send(to, subject, body string) error {
+	if s.cfg.URL == "" {
+		return ErrMessagingNotConfigured
+	}
+	payload, err := json.Marshal(map[string]string{"to": to, "subject": subject, "body": body})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.cfg.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.cfg.Token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s gateway returned %s", strings.ToLower(s.channel), resp.Status)
+	}
+	return nil
+}
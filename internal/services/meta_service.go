@@ -0,0 +1,145 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// MetaService describes the data model for integrators
+type MetaService interface {
+	// Schema is built once from the GORM models by reflection and cached, the model
+	// only changes with a deploy
+	Schema() (*models.SchemaDescription, error)
+}
+
+type metaService struct {
+	db       *gorm.DB
+	entities []interface{}
+
+	once   sync.Once
+	schema *models.SchemaDescription
+	err    error
+}
+
+func NewMetaService(db *gorm.DB, entities []interface{}) MetaService {
+	return &metaService{db: db, entities: entities}
+}
+
+func (s *metaService) Schema() (*models.SchemaDescription, error) {
+	s.once.Do(func() {
+		s.schema, s.err = s.build()
+	})
+	return s.schema, s.err
+}
+
+func (s *metaService) build() (*models.SchemaDescription, error) {
+	cache := &sync.Map{}
+	description := &models.SchemaDescription{
+		FormatVersion: models.SchemaFormatVersion,
+		Entities:      make([]models.SchemaEntity, 0, len(s.entities)),
+	}
+	for _, entity := range s.entities {
+		sch, err := schema.Parse(entity, cache, s.db.NamingStrategy)
+		if err != nil {
+			return nil, err
+		}
+		description.Entities = append(description.Entities, describeEntity(sch))
+	}
+	sort.Slice(description.Entities, func(i, j int) bool {
+		return description.Entities[i].Name < description.Entities[j].Name
+	})
+
+	// The version hashes the entities only, so it is stable across restarts
+	data, err := json.Marshal(description.Entities)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	description.Version = hex.EncodeToString(sum[:])[:12]
+	description.GeneratedAt = time.Now()
+	return description, nil
+}
+
+func describeEntity(sch *schema.Schema) models.SchemaEntity {
+	entity := models.SchemaEntity{
+		Name:          sch.Name,
+		Table:         sch.Table,
+		PrimaryKey:    []string{},
+		Fields:        []models.SchemaField{},
+		Relationships: []models.SchemaRelationship{},
+	}
+	for _, f := range sch.PrimaryFields {
+		entity.PrimaryKey = append(entity.PrimaryKey, f.DBName)
+	}
+
+	for _, f := range sch.Fields {
+		name := jsonFieldName(f.StructField)
+		if f.DBName == "" || name == "-" {
+			continue // associations, and columns never exposed by the API (password hashes...)
+		}
+		fieldType := f.IndirectFieldType
+		entity.Fields = append(entity.Fields, models.SchemaField{
+			Name:       name,
+			Column:     f.DBName,
+			GoType:     f.FieldType.String(),
+			DataType:   string(f.DataType),
+			PrimaryKey: f.PrimaryKey,
+			Nullable:   !f.NotNull && !f.PrimaryKey,
+			Unique:     f.Unique,
+			Default:    f.DefaultValue,
+			Size:       f.Size,
+			Enum:       models.SchemaEnums[enumTypeName(fieldType)],
+		})
+	}
+
+	relations := make([]*schema.Relationship, 0, len(sch.Relationships.Relations))
+	for _, rel := range sch.Relationships.Relations {
+		relations = append(relations, rel)
+	}
+	sort.Slice(relations, func(i, j int) bool { return relations[i].Name < relations[j].Name })
+	for _, rel := range relations {
+		relationship := models.SchemaRelationship{
+			Name:        jsonFieldName(rel.Field.StructField),
+			Type:        string(rel.Type),
+			Entity:      rel.FieldSchema.Name,
+			ForeignKeys: []string{},
+		}
+		for _, ref := range rel.References {
+			if ref.ForeignKey != nil && ref.ForeignKey.DBName != "" {
+				relationship.ForeignKeys = append(relationship.ForeignKeys, ref.ForeignKey.DBName)
+			}
+		}
+		if rel.JoinTable != nil {
+			relationship.JoinTable = rel.JoinTable.Table
+		}
+		entity.Relationships = append(entity.Relationships, relationship)
+	}
+	return entity
+}
+
+// jsonFieldName is the name of the field in API payloads
+func jsonFieldName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// enumTypeName is the name of a models enum type, empty for any other type
+func enumTypeName(t reflect.Type) string {
+	if t == nil || t.Kind() != reflect.String || t.PkgPath() != reflect.TypeOf(models.TicketStatus("")).PkgPath() {
+		return ""
+	}
+	return t.Name()
+}
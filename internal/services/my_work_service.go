@@ -0,0 +1,137 @@
+package services
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+)
+
+const (
+	myWorkItems = 10
+	// Mentions older than this no longer show in the inbox
+	myWorkMentionDays = 14
+)
+
+type MyWorkService interface {
+	// GetSummary assembles the inbox of the user: approvals (admins only, as they decide
+	// them), tickets of the user's team queues, mentions and open alerts (staff)
+	GetSummary(userID, userRole string) (*models.MyWorkSummary, error)
+}
+
+type myWorkService struct {
+	repo     repositories.MyWorkRepository
+	userRepo repositories.UserRepository
+}
+
+func NewMyWorkService(repo repositories.MyWorkRepository, userRepo repositories.UserRepository) MyWorkService {
+	return &myWorkService{repo: repo, userRepo: userRepo}
+}
+
+// GetSummary loads the sections in parallel, each one writing its own fields
+func (s *myWorkService) GetSummary(userID, userRole string) (*models.MyWorkSummary, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	staff := userRole == "ADMIN" || userRole == "EMPLOYEE"
+
+	summary := &models.MyWorkSummary{
+		Approvals:     models.MyWorkApprovals{Items: []models.MyWorkApproval{}},
+		Tickets:       models.MyWorkTickets{Items: []models.MyWorkTicket{}},
+		Mentions:      models.MyWorkMentions{Items: []models.TicketComment{}},
+		Notifications: models.MyWorkNotifications{Items: []models.Alert{}},
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	run := func(section func() error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := section(); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	if userRole == "ADMIN" {
+		run(func() error {
+			approvals, err := s.repo.FindPendingApprovals(myWorkItems)
+			if err != nil {
+				return err
+			}
+			sort.SliceStable(approvals.Items, func(i, j int) bool {
+				return approvals.Items[i].CreatedAt.After(approvals.Items[j].CreatedAt)
+			})
+			if len(approvals.Items) > myWorkItems {
+				approvals.Items = approvals.Items[:myWorkItems]
+			}
+			summary.Approvals = *approvals
+			return nil
+		})
+	}
+
+	run(func() error {
+		tickets, total, err := s.repo.FindQueueTickets(userID, myWorkItems)
+		if err != nil {
+			return err
+		}
+		summary.Tickets.Total = total
+		for _, ticket := range tickets {
+			item := models.MyWorkTicket{
+				ID:        ticket.ID,
+				OSNumber:  ticket.OSNumber,
+				Status:    ticket.Status,
+				Priority:  ticket.Priority,
+				DueDate:   ticket.DueDate,
+				CreatedAt: ticket.CreatedAt,
+			}
+			if ticket.Client != nil {
+				item.Client = ticket.Client.FullName
+			}
+			summary.Tickets.Items = append(summary.Tickets.Items, item)
+		}
+		return nil
+	})
+
+	// Users are mentioned by the name part of their e-mail (@maria.silva)
+	if handle, _, ok := strings.Cut(user.Email, "@"); ok && handle != "" {
+		run(func() error {
+			since := time.Now().AddDate(0, 0, -myWorkMentionDays)
+			comments, total, err := s.repo.FindMentions(handle, since, myWorkItems)
+			if err != nil {
+				return err
+			}
+			summary.Mentions.Total = total
+			summary.Mentions.Items = comments
+			return nil
+		})
+	}
+
+	if staff {
+		run(func() error {
+			alerts, total, err := s.repo.FindOpenAlerts(userID, myWorkItems)
+			if err != nil {
+				return err
+			}
+			summary.Notifications.Unread = total
+			summary.Notifications.Items = alerts
+			return nil
+		})
+	}
+
+	wg.Wait()
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return summary, nil
+}
@@ -0,0 +1,274 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"gorm.io/gorm"
+)
+
+var ErrNotificationNotFound = errors.New("notification not found")
+
+// NotificationChannel delivers a notification to its user through one medium
+type NotificationChannel interface {
+	Name() string
+	Deliver(user *models.User, notification *models.Notification) error
+}
+
+// NotificationConfig selects the channels notifications go out through. The webhook
+// subscriptions are always posted to; WEBHOOK adds the endpoint of WebhookURL.
+type NotificationConfig struct {
+	Channels     []string // DATABASE, EMAIL, WEBHOOK
+	WebhookURL   string
+	WebhookToken string
+}
+
+// NotificationService keeps the in-app inbox of each user and fans event notifications
+// out through the configured channels
+type NotificationService interface {
+	List(userID string, page, size int, filters *models.NotificationFilters) (*models.PaginatedResponse, error)
+	UnreadCount(userID string) (int64, error)
+	MarkRead(userID, id string) (*models.NotificationReadResult, error)
+	MarkAllRead(userID string) (*models.NotificationReadResult, error)
+	// Notify delivers the notification to each user; failures are logged, never returned
+	Notify(userIDs []string, notification models.Notification)
+	// NotifyTechnicians notifies the user accounts of the technicians, if they have one
+	NotifyTechnicians(technicianIDs []string, notification models.Notification)
+	// NotifyRole notifies every active user with the role
+	NotifyRole(role string, notification models.Notification)
+}
+
+type notificationService struct {
+	repo           repositories.NotificationRepository
+	userRepo       repositories.UserRepository
+	technicianRepo repositories.TechnicianRepository
+	channels       []NotificationChannel
+}
+
+func NewNotificationService(
+	repo repositories.NotificationRepository,
+	userRepo repositories.UserRepository,
+	technicianRepo repositories.TechnicianRepository,
+	webhookRepo repositories.WebhookRepository,
+	email MessageSender,
+	config NotificationConfig,
+) NotificationService {
+	s := &notificationService{
+		repo:           repo,
+		userRepo:       userRepo,
+		technicianRepo: technicianRepo,
+	}
+	webhook := &webhookChannel{
+		subscriptions: webhookRepo,
+		client:        &http.Client{Timeout: 10 * time.Second},
+	}
+	for _, name := range config.Channels {
+		switch strings.ToUpper(strings.TrimSpace(name)) {
+		case models.NotificationChannelDatabase:
+			s.channels = append(s.channels, &databaseChannel{repo: repo})
+		case models.NotificationChannelEmail:
+			if email != nil {
+				s.channels = append(s.channels, &emailChannel{sender: email})
+			}
+		case models.NotificationChannelWebhook:
+			webhook.url = config.WebhookURL
+			webhook.token = config.WebhookToken
+		case "":
+		default:
+			slog.Warn("Unknown notification channel ignored", "name", name)
+		}
+	}
+	s.channels = append(s.channels, webhook)
+	return s
+}
+
+func (s *notificationService) List(userID string, page, size int, filters *models.NotificationFilters) (*models.PaginatedResponse, error) {
+	notifications, total, err := s.repo.FindByUser(userID, page, size, filters)
+	if err != nil {
+		return nil, err
+	}
+	return models.NewPaginatedResponse(notifications, page, size, total), nil
+}
+
+func (s *notificationService) UnreadCount(userID string) (int64, error) {
+	return s.repo.CountUnread(userID)
+}
+
+func (s *notificationService) MarkRead(userID, id string) (*models.NotificationReadResult, error) {
+	if _, err := s.repo.FindByID(userID, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotificationNotFound
+		}
+		return nil, err
+	}
+	updated, err := s.repo.MarkRead(userID, id, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return s.readResult(userID, updated)
+}
+
+func (s *notificationService) MarkAllRead(userID string) (*models.NotificationReadResult, error) {
+	updated, err := s.repo.MarkAllRead(userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return s.readResult(userID, updated)
+}
+
+func (s *notificationService) readResult(userID string, updated int64) (*models.NotificationReadResult, error) {
+	unread, err := s.repo.CountUnread(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.NotificationReadResult{Updated: updated, Unread: unread}, nil
+}
+
+func (s *notificationService) Notify(userIDs []string, notification models.Notification) {
+	seen := make(map[string]bool, len(userIDs))
+	for _, userID := range userIDs {
+		if userID == "" || seen[userID] {
+			continue
+		}
+		seen[userID] = true
+
+		user, err := s.userRepo.FindByID(userID)
+		if err != nil {
+			slog.Warn("Failed to load user for notification", "user_id", userID, "event", notification.Event, "error", err)
+			continue
+		}
+		s.deliver(user, notification)
+	}
+}
+
+func (s *notificationService) NotifyTechnicians(technicianIDs []string, notification models.Notification) {
+	userIDs := make([]string, 0, len(technicianIDs))
+	for _, technicianID := range technicianIDs {
+		technician, err := s.technicianRepo.FindByID(technicianID)
+		if err != nil {
+			slog.Warn("Failed to load technician for notification", "technician_id", technicianID, "event", notification.Event, "error", err)
+			continue
+		}
+		if technician.UserID != nil {
+			userIDs = append(userIDs, *technician.UserID)
+		}
+	}
+	s.Notify(userIDs, notification)
+}
+
+func (s *notificationService) NotifyRole(role string, notification models.Notification) {
+	users, err := s.userRepo.FindByRole(role)
+	if err != nil {
+		slog.Warn("Failed to load users for notification", "role", role, "event", notification.Event, "error", err)
+		return
+	}
+	for i := range users {
+		s.deliver(&users[i], notification)
+	}
+}
+
+// deliver sends a copy of the notification to an active user through every channel
+func (s *notificationService) deliver(user *models.User, notification models.Notification) {
+	if !user.Active {
+		return
+	}
+	notification.ID = ""
+	notification.UserID = user.ID
+	for _, channel := range s.channels {
+		if err := channel.Deliver(user, &notification); err != nil && !errors.Is(err, ErrMessagingNotConfigured) {
+			slog.Warn("Failed to deliver notification", "event", notification.Event, "user_id", user.ID, "channel", channel.Name(), "error", err)
+		}
+	}
+}
+
+// databaseChannel stores the notification in the user's in-app inbox
+type databaseChannel struct {
+	repo repositories.NotificationRepository
+}
+
+func (c *databaseChannel) Name() string {
+	return models.NotificationChannelDatabase
+}
+
+func (c *databaseChannel) Deliver(user *models.User, notification *models.Notification) error {
+	return c.repo.Create(notification)
+}
+
+// emailChannel e-mails the notification to the user
+type emailChannel struct {
+	sender MessageSender
+}
+
+func (c *emailChannel) Name() string {
+	return models.NotificationChannelEmail
+}
+
+func (c *emailChannel) Deliver(user *models.User, notification *models.Notification) error {
+	if user.Email == "" {
+		return nil
+	}
+	return c.sender.Send(user.Email, notification.Title, notification.Message)
+}
+
+// webhookChannel posts the notification to the endpoint of the WEBHOOK channel, as JSON,
+// and to every webhook subscription listening to its event, in the shape of its template
+type webhookChannel struct {
+	url           string
+	token         string
+	subscriptions repositories.WebhookRepository
+	client        *http.Client
+}
+
+func (c *webhookChannel) Name() string {
+	return models.NotificationChannelWebhook
+}
+
+func (c *webhookChannel) Deliver(user *models.User, notification *models.Notification) error {
+	subscriptions, err := c.subscriptions.FindActiveForEvent(notification.Event)
+	if err != nil {
+		return err
+	}
+	if c.url == "" && len(subscriptions) == 0 {
+		return ErrMessagingNotConfigured
+	}
+
+	payload := models.WebhookPayload{
+		Event:        notification.Event,
+		UserID:       user.ID,
+		Email:        user.Email,
+		Title:        notification.Title,
+		Message:      notification.Message,
+		ResourceType: notification.ResourceType,
+		ResourceID:   notification.ResourceID,
+		CreatedAt:    time.Now(),
+	}
+	if c.url != "" {
+		subscriptions = append(subscriptions, models.WebhookSubscription{Name: "default", URL: c.url, Token: c.token})
+	}
+
+	var errs []error
+	for i := range subscriptions {
+		subscription := &subscriptions[i]
+		body, contentType, err := webhookBody(subscription, payload)
+		if err == nil {
+			_, _, err = sendWebhook(c.client, webhookPost{
+				URL:         subscription.URL,
+				Token:       subscription.Token,
+				Secret:      subscription.Secret,
+				ContentType: contentType,
+				Body:        body,
+				Headers:     map[string]string{"X-Webhook-Event": notification.Event},
+			})
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", subscription.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,526 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"gorm.io/gorm"
+)
+
+const (
+	npsDefaultQuestion        = "Em uma escala de 0 a 10, o quanto você recomendaria nossos serviços a um amigo ou colega?"
+	npsDefaultActiveSinceDays = 180
+	npsDefaultCooldownDays    = 90
+	npsTrendMonths            = 12
+
+	NPSGroupByMonth   = "month"
+	NPSGroupByQuarter = "quarter"
+)
+
+var (
+	ErrNPSCampaignNotFound    = errors.New("NPS campaign not found")
+	ErrNPSCampaignAlreadySent = errors.New("NPS campaign was already sent")
+	ErrNPSCampaignClosed      = errors.New("NPS campaign is closed")
+	ErrNPSInvalidPeriod       = errors.New("invalid period, expected YYYY-MM-DD with start before end")
+	ErrNPSEmptySample         = errors.New("no eligible clients for this campaign sample")
+	ErrNPSUnknownChannel      = errors.New("no messaging channel configured for this campaign")
+	ErrNPSSurveyNotFound      = errors.New("survey not found")
+	ErrNPSAlreadyAnswered     = errors.New("survey was already answered")
+	ErrNPSInvalidGroupBy      = errors.New("invalid groupBy, expected month or quarter")
+)
+
+type NPSService interface {
+	// Campaigns
+	ListCampaigns(page, size int) (*models.PaginatedResponse, error)
+	GetCampaign(id string) (*models.NPSCampaign, error)
+	CreateCampaign(userID string, req *models.CreateNPSCampaignRequest) (*models.NPSCampaign, error)
+	SendCampaign(id string) (*models.NPSCampaign, error)
+	CloseCampaign(id string) (*models.NPSCampaign, error)
+	GetInvitations(campaignID string) ([]models.NPSInvitationResponse, error)
+	GetResults(campaignID string) (*models.NPSCampaignResults, error)
+
+	// Public survey
+	GetSurvey(token string) (*models.NPSSurveyView, error)
+	Answer(token string, req *models.NPSAnswerRequest) error
+
+	// Reporting
+	GetTrend(from, to time.Time, groupBy, state string) ([]models.NPSTrendPoint, error)
+	GetDashboard() (*models.NPSDashboard, error)
+}
+
+type npsService struct {
+	repo      repositories.NPSRepository
+	senders   map[string]MessageSender
+	surveyURL string
+}
+
+// NewNPSService creates the NPS service; surveyURL must contain a {token} placeholder
+func NewNPSService(repo repositories.NPSRepository, surveyURL string, senders ...MessageSender) NPSService {
+	s := &npsService{
+		repo:      repo,
+		senders:   make(map[string]MessageSender),
+		surveyURL: surveyURL,
+	}
+	for _, sender := range senders {
+		s.senders[sender.Channel()] = sender
+	}
+	return s
+}
+
+func (s *npsService) ListCampaigns(page, size int) (*models.PaginatedResponse, error) {
+	campaigns, total, err := s.repo.FindCampaigns(page, size)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewPaginatedResponse(campaigns, page, size, total), nil
+}
+
+func (s *npsService) GetCampaign(id string) (*models.NPSCampaign, error) {
+	campaign, err := s.repo.FindCampaignByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNPSCampaignNotFound
+		}
+		return nil, err
+	}
+	return campaign, nil
+}
+
+func (s *npsService) CreateCampaign(userID string, req *models.CreateNPSCampaignRequest) (*models.NPSCampaign, error) {
+	start, errStart := time.Parse("2006-01-02", req.PeriodStart)
+	end, errEnd := time.Parse("2006-01-02", req.PeriodEnd)
+	if errStart != nil || errEnd != nil || end.Before(start) {
+		return nil, ErrNPSInvalidPeriod
+	}
+
+	campaign := &models.NPSCampaign{
+		Name:            strings.TrimSpace(req.Name),
+		Question:        strings.TrimSpace(req.Question),
+		Channel:         req.Channel,
+		Status:          models.NPSCampaignDraft,
+		PeriodStart:     start,
+		PeriodEnd:       end,
+		SampleSize:      req.SampleSize,
+		NodeID:          req.NodeID,
+		State:           strings.ToUpper(req.State),
+		ActiveSinceDays: req.ActiveSinceDays,
+		CooldownDays:    npsDefaultCooldownDays,
+		ClientTagID:     req.ClientTagID,
+		ClientSegment:   strings.ToUpper(strings.TrimSpace(req.ClientSegment)),
+		CreatedBy:       userID,
+	}
+	if !(models.ClientSegmentFilter{Segment: campaign.ClientSegment}).IsValid() {
+		return nil, ErrInvalidClientSegment
+	}
+	if campaign.Question == "" {
+		campaign.Question = npsDefaultQuestion
+	}
+	if campaign.Channel == "" {
+		campaign.Channel = models.NPSChannelEmail
+	}
+	if campaign.ActiveSinceDays == 0 {
+		campaign.ActiveSinceDays = npsDefaultActiveSinceDays
+	}
+	if req.CooldownDays != nil {
+		campaign.CooldownDays = *req.CooldownDays
+	}
+
+	if err := s.repo.CreateCampaign(campaign); err != nil {
+		return nil, err
+	}
+	return campaign, nil
+}
+
+// SendCampaign draws the client sample, creates one invitation per client and delivers
+// them in the background through the campaign channel
+func (s *npsService) SendCampaign(id string) (*models.NPSCampaign, error) {
+	campaign, err := s.GetCampaign(id)
+	if err != nil {
+		return nil, err
+	}
+	if campaign.Status != models.NPSCampaignDraft {
+		return nil, ErrNPSCampaignAlreadySent
+	}
+
+	var sender MessageSender
+	if campaign.Channel != models.NPSChannelLink {
+		sender = s.senders[campaign.Channel]
+		if sender == nil {
+			return nil, ErrNPSUnknownChannel
+		}
+	}
+
+	now := time.Now()
+	clients, err := s.repo.SelectSample(repositories.NPSSampleFilter{
+		Size:          campaign.SampleSize,
+		NodeID:        campaign.NodeID,
+		State:         campaign.State,
+		ActiveSince:   now.AddDate(0, 0, -campaign.ActiveSinceDays),
+		SurveyedSince: now.AddDate(0, 0, -campaign.CooldownDays),
+		RequireEmail:  campaign.Channel == models.NPSChannelEmail,
+		Clients:       models.ClientSegmentFilter{TagID: campaign.ClientTagID, Segment: campaign.ClientSegment},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(clients) == 0 {
+		return nil, ErrNPSEmptySample
+	}
+
+	invitations := make([]models.NPSInvitation, 0, len(clients))
+	for _, client := range clients {
+		token, err := generateSchedulingToken()
+		if err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, models.NPSInvitation{
+			CampaignID: campaign.ID,
+			ClientID:   client.ID,
+			Token:      token,
+			State:      client.State,
+			Channel:    campaign.Channel,
+			SentTo:     client.Email,
+		})
+	}
+	if err := s.repo.CreateInvitations(invitations); err != nil {
+		return nil, err
+	}
+
+	campaign.InvitationCount = len(invitations)
+	campaign.SentAt = &now
+	campaign.Status = models.NPSCampaignOpen
+	if sender != nil {
+		campaign.Status = models.NPSCampaignSending
+	}
+	if err := s.repo.UpdateCampaign(campaign); err != nil {
+		return nil, err
+	}
+
+	if sender != nil {
+		go s.deliver(*campaign, invitations, sender)
+	}
+	return campaign, nil
+}
+
+func (s *npsService) deliver(campaign models.NPSCampaign, invitations []models.NPSInvitation, sender MessageSender) {
+	subject := campaign.Name
+	failed := 0
+	for i := range invitations {
+		invitation := &invitations[i]
+		body := fmt.Sprintf("%s\n\nResponda em: %s\n\nSua opinião nos ajuda a melhorar nosso atendimento.",
+			campaign.Question, s.surveyLink(invitation.Token))
+
+		if err := sender.Send(invitation.SentTo, subject, body); err != nil {
+			invitation.SendError = err.Error()
+			failed++
+		} else {
+			sentAt := time.Now()
+			invitation.SentAt = &sentAt
+		}
+		if err := s.repo.UpdateInvitation(invitation); err != nil {
+			slog.Warn("Failed to save NPS invitation", "invitation_id", invitation.ID, "error", err)
+		}
+	}
+
+	// Reload so a close issued while sending is kept
+	current, err := s.repo.FindCampaignByID(campaign.ID)
+	if err != nil {
+		slog.Warn("Failed to reload NPS campaign", "campaign_id", campaign.ID, "error", err)
+		return
+	}
+	if current.Status == models.NPSCampaignSending {
+		current.Status = models.NPSCampaignOpen
+		if err := s.repo.UpdateCampaign(current); err != nil {
+			slog.Warn("Failed to open NPS campaign", "campaign_id", campaign.ID, "error", err)
+		}
+	}
+	if failed > 0 {
+		slog.Warn("NPS invitations failed to send", "campaign_id", campaign.ID, "failed", failed, "total", len(invitations))
+	}
+}
+
+func (s *npsService) CloseCampaign(id string) (*models.NPSCampaign, error) {
+	campaign, err := s.GetCampaign(id)
+	if err != nil {
+		return nil, err
+	}
+	if campaign.Status == models.NPSCampaignClosed {
+		return nil, ErrNPSCampaignClosed
+	}
+
+	now := time.Now()
+	campaign.Status = models.NPSCampaignClosed
+	campaign.ClosedAt = &now
+	if err := s.repo.UpdateCampaign(campaign); err != nil {
+		return nil, err
+	}
+	return campaign, nil
+}
+
+func (s *npsService) GetInvitations(campaignID string) ([]models.NPSInvitationResponse, error) {
+	if _, err := s.GetCampaign(campaignID); err != nil {
+		return nil, err
+	}
+	invitations, err := s.repo.FindInvitations(campaignID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]models.NPSInvitationResponse, 0, len(invitations))
+	for _, invitation := range invitations {
+		result = append(result, models.NPSInvitationResponse{
+			NPSInvitation: invitation,
+			Link:          s.surveyLink(invitation.Token),
+		})
+	}
+	return result, nil
+}
+
+func (s *npsService) GetResults(campaignID string) (*models.NPSCampaignResults, error) {
+	campaign, err := s.GetCampaign(campaignID)
+	if err != nil {
+		return nil, err
+	}
+	invitations, err := s.repo.FindInvitations(campaignID)
+	if err != nil {
+		return nil, err
+	}
+
+	results := &models.NPSCampaignResults{
+		Campaign: *campaign,
+		Invited:  len(invitations),
+		Comments: []models.NPSComment{},
+	}
+	var responses []models.NPSResponse
+	for _, invitation := range invitations {
+		if invitation.SentAt != nil || invitation.Channel == models.NPSChannelLink {
+			results.Delivered++
+		}
+		if invitation.Score == nil || invitation.RespondedAt == nil {
+			continue
+		}
+
+		score := *invitation.Score
+		results.Distribution[score]++
+		responses = append(responses, models.NPSResponse{
+			Score:       score,
+			State:       invitation.State,
+			RespondedAt: *invitation.RespondedAt,
+		})
+		if invitation.Comment != "" {
+			comment := models.NPSComment{
+				State:       invitation.State,
+				Score:       score,
+				Comment:     invitation.Comment,
+				RespondedAt: *invitation.RespondedAt,
+			}
+			if invitation.Client != nil {
+				comment.ClientName = invitation.Client.FullName
+			}
+			results.Comments = append(results.Comments, comment)
+		}
+	}
+
+	results.Overall = computeNPS(responses)
+	results.ByRegion = npsByRegion(responses)
+	if results.Invited > 0 {
+		results.ResponseRate = round1(float64(len(responses)) * 100 / float64(results.Invited))
+	}
+	return results, nil
+}
+
+func (s *npsService) GetSurvey(token string) (*models.NPSSurveyView, error) {
+	invitation, campaign, err := s.findSurvey(token)
+	if err != nil {
+		return nil, err
+	}
+
+	view := &models.NPSSurveyView{
+		CampaignName: campaign.Name,
+		Question:     campaign.Question,
+		Answered:     invitation.RespondedAt != nil,
+		Closed:       campaign.Status == models.NPSCampaignClosed,
+		Score:        invitation.Score,
+		RespondedAt:  invitation.RespondedAt,
+	}
+	if invitation.Client != nil {
+		view.ClientName = invitation.Client.FullName
+	}
+	return view, nil
+}
+
+func (s *npsService) Answer(token string, req *models.NPSAnswerRequest) error {
+	invitation, campaign, err := s.findSurvey(token)
+	if err != nil {
+		return err
+	}
+	if campaign.Status == models.NPSCampaignClosed {
+		return ErrNPSCampaignClosed
+	}
+	if invitation.RespondedAt != nil {
+		return ErrNPSAlreadyAnswered
+	}
+
+	now := time.Now()
+	score := *req.Score
+	invitation.Score = &score
+	invitation.Comment = strings.TrimSpace(req.Comment)
+	invitation.RespondedAt = &now
+	return s.repo.UpdateInvitation(invitation)
+}
+
+func (s *npsService) findSurvey(token string) (*models.NPSInvitation, *models.NPSCampaign, error) {
+	invitation, err := s.repo.FindInvitationByToken(token)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrNPSSurveyNotFound
+		}
+		return nil, nil, err
+	}
+	campaign, err := s.repo.FindCampaignByID(invitation.CampaignID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrNPSSurveyNotFound
+		}
+		return nil, nil, err
+	}
+	return invitation, campaign, nil
+}
+
+// GetTrend returns the NPS of every month or quarter between from and to, including empty periods
+func (s *npsService) GetTrend(from, to time.Time, groupBy, state string) ([]models.NPSTrendPoint, error) {
+	if groupBy == "" {
+		groupBy = NPSGroupByMonth
+	}
+	if groupBy != NPSGroupByMonth && groupBy != NPSGroupByQuarter {
+		return nil, ErrNPSInvalidGroupBy
+	}
+
+	responses, err := s.repo.FindResponses(from, to, strings.ToUpper(state))
+	if err != nil {
+		return nil, err
+	}
+	return npsTrend(responses, from, to, groupBy), nil
+}
+
+func (s *npsService) GetDashboard() (*models.NPSDashboard, error) {
+	now := time.Now()
+	quarterStart := time.Date(now.Year(), time.Month((int(now.Month())-1)/3*3+1), 1, 0, 0, 0, 0, now.Location())
+	trendStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).AddDate(0, -(npsTrendMonths - 1), 0)
+
+	from := quarterStart.AddDate(0, -3, 0)
+	if trendStart.Before(from) {
+		from = trendStart
+	}
+	responses, err := s.repo.FindResponses(from, now, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var current, previous, lastYear []models.NPSResponse
+	for _, response := range responses {
+		switch {
+		case !response.RespondedAt.Before(quarterStart):
+			current = append(current, response)
+		case !response.RespondedAt.Before(quarterStart.AddDate(0, -3, 0)):
+			previous = append(previous, response)
+		}
+		if !response.RespondedAt.Before(trendStart) {
+			lastYear = append(lastYear, response)
+		}
+	}
+
+	return &models.NPSDashboard{
+		Current:  computeNPS(current),
+		Previous: computeNPS(previous),
+		Trend:    npsTrend(lastYear, trendStart, now, NPSGroupByMonth),
+		ByRegion: npsByRegion(lastYear),
+	}, nil
+}
+
+func (s *npsService) surveyLink(token string) string {
+	return strings.ReplaceAll(s.surveyURL, "{token}", token)
+}
+
+// computeNPS returns %promoters (9-10) - %detractors (0-6), from -100 to 100
+func computeNPS(responses []models.NPSResponse) models.NPSScore {
+	var score models.NPSScore
+	for _, response := range responses {
+		switch {
+		case response.Score >= 9:
+			score.Promoters++
+		case response.Score >= 7:
+			score.Passives++
+		default:
+			score.Detractors++
+		}
+	}
+	score.Responses = len(responses)
+	if score.Responses > 0 {
+		score.NPS = round1(float64(score.Promoters-score.Detractors) * 100 / float64(score.Responses))
+	}
+	return score
+}
+
+func npsByRegion(responses []models.NPSResponse) []models.NPSRegionScore {
+	byState := make(map[string][]models.NPSResponse)
+	for _, response := range responses {
+		byState[response.State] = append(byState[response.State], response)
+	}
+
+	regions := make([]models.NPSRegionScore, 0, len(byState))
+	for state, stateResponses := range byState {
+		regions = append(regions, models.NPSRegionScore{State: state, NPSScore: computeNPS(stateResponses)})
+	}
+	sort.Slice(regions, func(i, j int) bool {
+		if regions[i].Responses != regions[j].Responses {
+			return regions[i].Responses > regions[j].Responses
+		}
+		return regions[i].State < regions[j].State
+	})
+	return regions
+}
+
+func npsTrend(responses []models.NPSResponse, from, to time.Time, groupBy string) []models.NPSTrendPoint {
+	byPeriod := make(map[string][]models.NPSResponse)
+	for _, response := range responses {
+		key := npsPeriodKey(response.RespondedAt.In(from.Location()), groupBy)
+		byPeriod[key] = append(byPeriod[key], response)
+	}
+
+	step := 1
+	if groupBy == NPSGroupByQuarter {
+		step = 3
+	}
+	cursor := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, from.Location())
+	if groupBy == NPSGroupByQuarter {
+		cursor = time.Date(from.Year(), time.Month((int(from.Month())-1)/3*3+1), 1, 0, 0, 0, 0, from.Location())
+	}
+
+	var trend []models.NPSTrendPoint
+	for cursor.Before(to) {
+		key := npsPeriodKey(cursor, groupBy)
+		trend = append(trend, models.NPSTrendPoint{Period: key, NPSScore: computeNPS(byPeriod[key])})
+		cursor = cursor.AddDate(0, step, 0)
+	}
+	return trend
+}
+
+func npsPeriodKey(t time.Time, groupBy string) string {
+	if groupBy == NPSGroupByQuarter {
+		return fmt.Sprintf("%d-Q%d", t.Year(), (int(t.Month())-1)/3+1)
+	}
+	return t.Format("2006-01")
+}
+
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
@@ -0,0 +1,627 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrOnCallScheduleNotFound = errors.New("on-call schedule not found")
+	ErrOnCallNoSchedule       = errors.New("no active on-call schedule covers the ticket region")
+	ErrOnCallInvalidSchedule  = errors.New("invalid on-call schedule")
+	ErrOnCallPageNotFound     = errors.New("on-call page not found")
+	ErrOnCallTicketNotFound   = errors.New("ticket not found")
+	ErrOnCallNotEmergency     = errors.New("priority dispatch is only available for urgent tickets")
+	ErrOnCallTicketFinished   = errors.New("ticket is already finished")
+	ErrOnCallBusinessHours    = errors.New("within business hours: use the regular dispatch or force the page")
+	ErrOnCallAlreadyPaged     = errors.New("the on-call is already being paged for this ticket")
+	ErrOnCallPageNotPending   = errors.New("on-call page is no longer pending")
+	ErrOnCallNotPaged         = errors.New("only a paged technician can acknowledge")
+)
+
+const (
+	onCallChannelPush         = "PUSH"
+	onCallChannelSMS          = "SMS"
+	defaultEscalationInterval = time.Minute
+)
+
+// OnCallService handles the on-call rotations and the emergency dispatch that pages them
+type OnCallService interface {
+	ListSchedules() ([]models.OnCallSchedule, error)
+	GetSchedule(id string) (*models.OnCallSchedule, error)
+	CreateSchedule(req *models.OnCallScheduleRequest) (*models.OnCallSchedule, error)
+	UpdateSchedule(id string, req *models.OnCallScheduleRequest) (*models.OnCallSchedule, error)
+	// WhoIsOnCall returns the escalation order of a schedule at a moment
+	WhoIsOnCall(scheduleID string, at time.Time) (*models.OnCallNow, error)
+
+	// PriorityDispatch pages the on-call of the ticket region
+	PriorityDispatch(ticketID string, req *models.PriorityDispatchRequest, userID string) (*models.OnCallPage, error)
+	ListPages(page, size int, filters *models.OnCallPageFilters) (*models.PaginatedResponse, error)
+	GetPage(id string) (*models.OnCallPage, error)
+	MyPages(userID string) ([]models.OnCallPage, error)
+	Acknowledge(pageID, userID, userRole string) (*models.OnCallPage, error)
+	Cancel(pageID, userID string) (*models.OnCallPage, error)
+
+	// Escalate pages the next technician of every page whose acknowledgement timed out
+	Escalate() (*models.OnCallEscalationResult, error)
+	Start(interval time.Duration)
+	Stop()
+}
+
+type onCallService struct {
+	repo               repositories.OnCallRepository
+	ticketRepo         repositories.TicketRepository
+	technicianRepo     repositories.TechnicianRepository
+	ticketService      TicketService
+	activityLogService ActivityLogService
+	senders            map[string]MessageSender
+
+	mu   sync.Mutex // serializes page state changes
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewOnCallService(
+	repo repositories.OnCallRepository,
+	ticketRepo repositories.TicketRepository,
+	technicianRepo repositories.TechnicianRepository,
+	ticketService TicketService,
+	activityLogService ActivityLogService,
+	senders ...MessageSender,
+) OnCallService {
+	s := &onCallService{
+		repo:               repo,
+		ticketRepo:         ticketRepo,
+		technicianRepo:     technicianRepo,
+		ticketService:      ticketService,
+		activityLogService: activityLogService,
+		senders:            make(map[string]MessageSender),
+	}
+	for _, sender := range senders {
+		s.senders[sender.Channel()] = sender
+	}
+	return s
+}
+
+// =============== Schedules ===============
+
+func (s *onCallService) ListSchedules() ([]models.OnCallSchedule, error) {
+	return s.repo.FindSchedules()
+}
+
+func (s *onCallService) GetSchedule(id string) (*models.OnCallSchedule, error) {
+	schedule, err := s.repo.FindScheduleByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOnCallScheduleNotFound
+		}
+		return nil, err
+	}
+	return schedule, nil
+}
+
+func (s *onCallService) CreateSchedule(req *models.OnCallScheduleRequest) (*models.OnCallSchedule, error) {
+	schedule := &models.OnCallSchedule{
+		Timezone:          "America/Sao_Paulo",
+		BusinessStartHour: 8,
+		BusinessEndHour:   18,
+		RotationStart:     time.Now().Truncate(24 * time.Hour),
+		ShiftHours:        168,
+		AckTimeoutMinutes: 15,
+		Active:            true,
+	}
+	if err := s.applyScheduleRequest(schedule, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateSchedule(schedule); err != nil {
+		return nil, err
+	}
+	return s.GetSchedule(schedule.ID)
+}
+
+func (s *onCallService) UpdateSchedule(id string, req *models.OnCallScheduleRequest) (*models.OnCallSchedule, error) {
+	schedule, err := s.GetSchedule(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.applyScheduleRequest(schedule, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateSchedule(schedule); err != nil {
+		return nil, err
+	}
+	return s.GetSchedule(id)
+}
+
+func (s *onCallService) applyScheduleRequest(schedule *models.OnCallSchedule, req *models.OnCallScheduleRequest) error {
+	if req.Timezone != "" {
+		if _, err := time.LoadLocation(req.Timezone); err != nil {
+			return fmt.Errorf("%w: unknown timezone %s", ErrOnCallInvalidSchedule, req.Timezone)
+		}
+		schedule.Timezone = req.Timezone
+	}
+	if req.BusinessStartHour != nil {
+		schedule.BusinessStartHour = *req.BusinessStartHour
+	}
+	if req.BusinessEndHour != nil {
+		schedule.BusinessEndHour = *req.BusinessEndHour
+	}
+	if schedule.BusinessStartHour >= schedule.BusinessEndHour {
+		return fmt.Errorf("%w: business hours must start before they end", ErrOnCallInvalidSchedule)
+	}
+
+	seen := make(map[string]bool, len(req.TechnicianIDs))
+	members := make([]models.OnCallMember, 0, len(req.TechnicianIDs))
+	for i, technicianID := range req.TechnicianIDs {
+		if seen[technicianID] {
+			return fmt.Errorf("%w: technician %s listed twice", ErrOnCallInvalidSchedule, technicianID)
+		}
+		seen[technicianID] = true
+		if _, err := s.technicianRepo.FindByID(technicianID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: technician %s not found", ErrOnCallInvalidSchedule, technicianID)
+			}
+			return err
+		}
+		members = append(members, models.OnCallMember{TechnicianID: technicianID, Position: i})
+	}
+
+	schedule.Name = strings.TrimSpace(req.Name)
+	schedule.State = strings.ToUpper(req.State)
+	if req.RotationStart != nil {
+		schedule.RotationStart = *req.RotationStart
+	}
+	if req.ShiftHours > 0 {
+		schedule.ShiftHours = req.ShiftHours
+	}
+	if req.AckTimeoutMinutes > 0 {
+		schedule.AckTimeoutMinutes = req.AckTimeoutMinutes
+	}
+	if req.Active != nil {
+		schedule.Active = *req.Active
+	}
+	schedule.Members = members
+	return nil
+}
+
+func (s *onCallService) WhoIsOnCall(scheduleID string, at time.Time) (*models.OnCallNow, error) {
+	schedule, err := s.GetSchedule(scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	order, shiftEnds := escalationOrder(schedule, at)
+
+	now := &models.OnCallNow{
+		ScheduleID:      schedule.ID,
+		ScheduleName:    schedule.Name,
+		State:           schedule.State,
+		At:              at,
+		ShiftEndsAt:     shiftEnds,
+		BusinessHours:   isBusinessHours(schedule, at),
+		EscalationOrder: make([]models.OnCallEntry, 0, len(order)),
+	}
+	for level, member := range order {
+		entry := models.OnCallEntry{Level: level, TechnicianID: member.TechnicianID}
+		if member.Technician != nil {
+			entry.TechnicianName = member.Technician.FullName
+		}
+		now.EscalationOrder = append(now.EscalationOrder, entry)
+	}
+	return now, nil
+}
+
+// escalationOrder returns the members starting with the primary of the shift containing at,
+// followed by the others in rotation order, and the end of that shift
+func escalationOrder(schedule *models.OnCallSchedule, at time.Time) ([]models.OnCallMember, time.Time) {
+	n := len(schedule.Members)
+	shift := time.Duration(schedule.ShiftHours) * time.Hour
+	if n == 0 || shift <= 0 {
+		return nil, at
+	}
+
+	elapsed := at.Sub(schedule.RotationStart)
+	shifts := int64(elapsed / shift)
+	if elapsed < 0 && elapsed%shift != 0 {
+		shifts-- // floor for moments before the rotation start
+	}
+	primary := int(((shifts % int64(n)) + int64(n)) % int64(n))
+
+	order := make([]models.OnCallMember, 0, n)
+	order = append(order, schedule.Members[primary:]...)
+	order = append(order, schedule.Members[:primary]...)
+	return order, schedule.RotationStart.Add(time.Duration(shifts+1) * shift)
+}
+
+// isBusinessHours reports whether at falls on a weekday between the business hours of the
+// schedule, in its timezone
+func isBusinessHours(schedule *models.OnCallSchedule, at time.Time) bool {
+	loc, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		loc = time.Local
+	}
+	local := at.In(loc)
+	if local.Weekday() == time.Saturday || local.Weekday() == time.Sunday {
+		return false
+	}
+	return local.Hour() >= schedule.BusinessStartHour && local.Hour() < schedule.BusinessEndHour
+}
+
+// =============== Pages ===============
+
+func (s *onCallService) PriorityDispatch(ticketID string, req *models.PriorityDispatchRequest, userID string) (*models.OnCallPage, error) {
+	ticket, err := s.ticketRepo.FindByID(ticketID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOnCallTicketNotFound
+		}
+		return nil, err
+	}
+	if ticket.Priority != models.TicketPriorityUrgent {
+		return nil, ErrOnCallNotEmergency
+	}
+	switch ticket.Status {
+	case models.TicketStatusClosed, models.TicketStatusUnproductive, models.TicketStatusCancelled:
+		return nil, ErrOnCallTicketFinished
+	}
+
+	state := ""
+	if ticket.Client != nil {
+		state = strings.ToUpper(ticket.Client.State)
+	}
+	schedule, err := s.repo.FindScheduleForState(state)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOnCallNoSchedule
+		}
+		return nil, err
+	}
+	now := time.Now()
+	order, _ := escalationOrder(schedule, now)
+	if len(order) == 0 {
+		return nil, ErrOnCallNoSchedule
+	}
+	outside := !isBusinessHours(schedule, now)
+	if !outside && !req.Force {
+		return nil, ErrOnCallBusinessHours
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.repo.FindPendingPageByTicket(ticketID); err == nil {
+		return nil, ErrOnCallAlreadyPaged
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	primary := order[0]
+	page := &models.OnCallPage{
+		TicketID:             ticketID,
+		ScheduleID:           schedule.ID,
+		Status:               models.OnCallPagePending,
+		TechnicianID:         primary.TechnicianID,
+		Reason:               strings.TrimSpace(req.Reason),
+		OutsideBusinessHours: outside,
+		RequestedBy:          userID,
+		AckDeadline:          now.Add(time.Duration(schedule.AckTimeoutMinutes) * time.Minute),
+	}
+	attempt := s.notify(ticket, primary.Technician, 0, now)
+	event := models.NewTicketEvent(ticketID, models.TicketEventOnCallPaged, userID, map[string]interface{}{
+		"scheduleId":   schedule.ID,
+		"technicianId": primary.TechnicianID,
+		"channels":     attempt.Channels,
+		"ackDeadline":  page.AckDeadline,
+	})
+	if err := s.repo.SavePage(page, attempt, event); err != nil {
+		return nil, err
+	}
+
+	description := fmt.Sprintf("Acionou o plantão (%s) para o chamado %s", schedule.Name, ticket.OSNumber)
+	if err := s.activityLogService.LogAction(userID, "ticket_priority_dispatch", "ticket", ticketID, description, "", ""); err != nil {
+		slog.Warn("Failed to audit priority dispatch of ticket", "ticket_id", ticketID, "error", err)
+	}
+	return s.GetPage(page.ID)
+}
+
+func (s *onCallService) ListPages(page, size int, filters *models.OnCallPageFilters) (*models.PaginatedResponse, error) {
+	pages, total, err := s.repo.FindPages(page, size, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewPaginatedResponse(pages, page, size, total), nil
+}
+
+func (s *onCallService) GetPage(id string) (*models.OnCallPage, error) {
+	page, err := s.repo.FindPageByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOnCallPageNotFound
+		}
+		return nil, err
+	}
+	return page, nil
+}
+
+// MyPages returns the pending pages of the technician linked to the user
+func (s *onCallService) MyPages(userID string) ([]models.OnCallPage, error) {
+	technician, err := s.technicianRepo.FindByUserID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return []models.OnCallPage{}, nil
+		}
+		return nil, err
+	}
+	return s.repo.FindPendingPagesForTechnician(technician.ID)
+}
+
+// Acknowledge closes the page and puts the technician on the ticket as lead. Technicians
+// acknowledge for themselves, if they have been paged; staff acknowledge on behalf of the
+// technician currently paged.
+func (s *onCallService) Acknowledge(pageID, userID, userRole string) (*models.OnCallPage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	page, err := s.GetPage(pageID)
+	if err != nil {
+		return nil, err
+	}
+	if page.Status != models.OnCallPagePending {
+		return nil, ErrOnCallPageNotPending
+	}
+
+	technicianID := page.TechnicianID
+	if userRole != "ADMIN" && userRole != "EMPLOYEE" {
+		technician, err := s.technicianRepo.FindByUserID(userID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrOnCallNotPaged
+			}
+			return nil, err
+		}
+		if !wasPaged(page, technician.ID) {
+			return nil, ErrOnCallNotPaged
+		}
+		technicianID = technician.ID
+	}
+
+	if err := s.assignLead(page.TicketID, technicianID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	page.Status = models.OnCallPageAcknowledged
+	page.TechnicianID = technicianID
+	page.AcknowledgedAt = &now
+	page.AcknowledgedBy = userID
+	page.ClosedAt = &now
+	event := models.NewTicketEvent(page.TicketID, models.TicketEventOnCallAcknowledged, userID, map[string]interface{}{
+		"pageId":          page.ID,
+		"technicianId":    technicianID,
+		"level":           page.Level,
+		"responseMinutes": int(now.Sub(page.CreatedAt).Minutes()),
+	})
+	if err := s.repo.SavePage(page, nil, event); err != nil {
+		return nil, err
+	}
+
+	if err := s.activityLogService.LogAction(userID, "on_call_acknowledged", "ticket", page.TicketID, "Confirmou o atendimento do plantão", "", ""); err != nil {
+		slog.Warn("Failed to audit on-call acknowledgement of ticket", "ticket_id", page.TicketID, "error", err)
+	}
+	return s.GetPage(page.ID)
+}
+
+func wasPaged(page *models.OnCallPage, technicianID string) bool {
+	for _, attempt := range page.Attempts {
+		if attempt.TechnicianID == technicianID {
+			return true
+		}
+	}
+	return false
+}
+
+// assignLead makes the technician lead of the ticket; the current crew stays as assistants
+func (s *onCallService) assignLead(ticketID, technicianID string) error {
+	current, err := s.ticketService.GetAssignments(ticketID)
+	if err != nil {
+		return err
+	}
+	inputs := []models.TicketAssignmentInput{{TechnicianID: technicianID, Role: string(models.AssignmentRoleLead)}}
+	for _, a := range current {
+		if a.TechnicianID == technicianID {
+			continue
+		}
+		inputs = append(inputs, models.TicketAssignmentInput{TechnicianID: a.TechnicianID, Role: string(models.AssignmentRoleAssistant)})
+	}
+	_, err = s.ticketService.SetAssignments(ticketID, inputs)
+	return err
+}
+
+func (s *onCallService) Cancel(pageID, userID string) (*models.OnCallPage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	page, err := s.GetPage(pageID)
+	if err != nil {
+		return nil, err
+	}
+	if page.Status != models.OnCallPagePending {
+		return nil, ErrOnCallPageNotPending
+	}
+
+	now := time.Now()
+	page.Status = models.OnCallPageCancelled
+	page.ClosedAt = &now
+	if err := s.repo.SavePage(page, nil, nil); err != nil {
+		return nil, err
+	}
+	if err := s.activityLogService.LogAction(userID, "on_call_cancelled", "ticket", page.TicketID, "Cancelou o acionamento do plantão", "", ""); err != nil {
+		slog.Warn("Failed to audit on-call cancellation of ticket", "ticket_id", page.TicketID, "error", err)
+	}
+	return s.GetPage(page.ID)
+}
+
+func (s *onCallService) Escalate() (*models.OnCallEscalationResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	pages, err := s.repo.FindExpiredPages(now)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.OnCallEscalationResult{}
+	for i := range pages {
+		page := &pages[i]
+		if page.Ticket == nil || page.Schedule == nil {
+			continue
+		}
+		// the rotation is evaluated at the moment the page was opened
+		order, _ := escalationOrder(page.Schedule, page.CreatedAt)
+		previous := page.TechnicianID
+
+		if page.Level+1 >= len(order) {
+			page.Status = models.OnCallPageExhausted
+			page.ClosedAt = &now
+			event := models.NewTicketEvent(page.TicketID, models.TicketEventOnCallExhausted, "", map[string]interface{}{
+				"pageId": page.ID,
+				"levels": page.Level + 1,
+			})
+			if err := s.repo.SavePage(page, nil, event); err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", page.ID, err))
+				continue
+			}
+			slog.Error("Nobody on call acknowledged ticket", "os_number", page.Ticket.OSNumber, "levels", page.Level+1)
+			result.Exhausted++
+			continue
+		}
+
+		page.Level++
+		next := order[page.Level]
+		page.TechnicianID = next.TechnicianID
+		page.AckDeadline = now.Add(time.Duration(page.Schedule.AckTimeoutMinutes) * time.Minute)
+		attempt := s.notify(page.Ticket, next.Technician, page.Level, now)
+		event := models.NewTicketEvent(page.TicketID, models.TicketEventOnCallEscalated, "", map[string]interface{}{
+			"pageId":               page.ID,
+			"level":                page.Level,
+			"previousTechnicianId": previous,
+			"technicianId":         next.TechnicianID,
+			"channels":             attempt.Channels,
+		})
+		if err := s.repo.SavePage(page, attempt, event); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", page.ID, err))
+			continue
+		}
+		result.Escalated++
+	}
+	return result, nil
+}
+
+// notify pages a technician through push and SMS; the attempt records what was delivered
+func (s *onCallService) notify(ticket *models.Ticket, technician *models.Technician, level int, now time.Time) *models.OnCallPageAttempt {
+	attempt := &models.OnCallPageAttempt{Level: level, SentAt: now}
+	if technician == nil {
+		attempt.Errors = "technician not found"
+		return attempt
+	}
+	attempt.TechnicianID = technician.ID
+
+	subject := fmt.Sprintf("Plantão: chamado urgente OS %s", ticket.OSNumber)
+	var body strings.Builder
+	if ticket.Client != nil {
+		fmt.Fprintf(&body, "Cliente: %s", ticket.Client.FullName)
+		if ticket.Client.City != "" {
+			fmt.Fprintf(&body, " (%s/%s)", ticket.Client.City, ticket.Client.State)
+		}
+		body.WriteString("\n")
+	}
+	if ticket.ErrorDescription != "" {
+		fmt.Fprintf(&body, "Problema: %s\n", ticket.ErrorDescription)
+	}
+	body.WriteString("Confirme o atendimento no aplicativo.")
+
+	recipients := map[string]string{onCallChannelPush: "", onCallChannelSMS: ""}
+	if technician.UserID != nil {
+		recipients[onCallChannelPush] = *technician.UserID
+	}
+	for _, phone := range technician.Phones {
+		if phone.Number != "" {
+			recipients[onCallChannelSMS] = phone.Number
+			break
+		}
+	}
+
+	var delivered, failed []string
+	for _, channel := range []string{onCallChannelPush, onCallChannelSMS} {
+		sender := s.senders[channel]
+		to := recipients[channel]
+		switch {
+		case sender == nil:
+			failed = append(failed, channel+": "+ErrMessagingNotConfigured.Error())
+		case to == "":
+			failed = append(failed, channel+": no recipient")
+		default:
+			if err := sender.Send(to, subject, body.String()); err != nil {
+				failed = append(failed, channel+": "+err.Error())
+				continue
+			}
+			delivered = append(delivered, channel)
+		}
+	}
+	attempt.Channels = strings.Join(delivered, ",")
+	attempt.Errors = strings.Join(failed, "; ")
+	if len(delivered) == 0 {
+		slog.Warn("Could not page technician for ticket", "technician_id", technician.ID, "os_number", ticket.OSNumber, "errors", attempt.Errors)
+	}
+	return attempt
+}
+
+// Start runs the escalation periodically in the background until Stop is called
+func (s *onCallService) Start(interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultEscalationInterval
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				result, err := s.Escalate()
+				if err != nil {
+					slog.Warn("On-call escalation failed", "error", err)
+					continue
+				}
+				for _, e := range result.Errors {
+					slog.Warn("On-call escalation failed", "error", e)
+				}
+				if result.Escalated > 0 {
+					slog.Info("On-call escalation paged the next technician on tickets", "escalated", result.Escalated)
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (s *onCallService) Stop() {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+		s.stop = nil
+	}
+}
@@ -0,0 +1,225 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"github.com/shigake/tech-iq-back/internal/tenant"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultOutboxInterval = 5 * time.Second
+	outboxBatch           = 50
+	// A claimed event is retried after the lease if its instance dies while handling it
+	outboxLease = 5 * time.Minute
+	// Attempts of an event before it fails; the wait doubles from outboxRetryBase, so the
+	// last attempt comes about 4 hours after the first
+	outboxMaxAttempts = 10
+	outboxRetryBase   = 30 * time.Second
+	outboxRetryMax    = 2 * time.Hour
+)
+
+var ErrOutboxEventNotFound = errors.New("outbox event not found")
+
+// OutboxHandler does the work of an event and returns a short note of what it did. It
+// may run more than once for the same event, so it must be idempotent. ctx carries the
+// tenant of the event.
+type OutboxHandler func(ctx context.Context, event *models.OutboxEvent) (string, error)
+
+// OutboxService dispatches the outbox events to the handlers of their topics, retrying
+// the failures with backoff
+type OutboxService interface {
+	Register(topic string, handler OutboxHandler)
+	// ProcessDue handles one batch of due events and returns how many were handled
+	ProcessDue() (int, error)
+	List(filter models.OutboxEventFilter, page, size int) (*models.PaginatedResponse, error)
+	Get(id string) (*models.OutboxEvent, error)
+	// Retry queues the event again with a fresh set of attempts
+	Retry(id string) (*models.OutboxEvent, error)
+	Start(interval time.Duration)
+	Stop()
+	// WithContext returns the service running its queries with ctx, inside the tenant it carries
+	WithContext(ctx context.Context) OutboxService
+}
+
+type outboxService struct {
+	repo     repositories.OutboxRepository
+	handlers map[string]OutboxHandler
+	mu       sync.Mutex
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func NewOutboxService(repo repositories.OutboxRepository) OutboxService {
+	return &outboxService{repo: repo, handlers: make(map[string]OutboxHandler)}
+}
+
+// WithContext serves the requests; processing is left to the service it was called on
+func (s *outboxService) WithContext(ctx context.Context) OutboxService {
+	return &outboxService{repo: s.repo.WithContext(ctx), handlers: s.handlers}
+}
+
+func (s *outboxService) Register(topic string, handler OutboxHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[topic] = handler
+}
+
+func (s *outboxService) List(filter models.OutboxEventFilter, page, size int) (*models.PaginatedResponse, error) {
+	events, total, err := s.repo.FindAll(filter, page, size)
+	if err != nil {
+		return nil, err
+	}
+	return models.NewPaginatedResponse(events, page, size, total), nil
+}
+
+func (s *outboxService) Get(id string) (*models.OutboxEvent, error) {
+	event, err := s.repo.FindByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOutboxEventNotFound
+		}
+		return nil, err
+	}
+	return event, nil
+}
+
+func (s *outboxService) Retry(id string) (*models.OutboxEvent, error) {
+	event, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	event.Status = models.OutboxPending
+	event.Attempts = 0
+	event.NextAttemptAt = &now
+	if err := s.repo.Update(event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+func (s *outboxService) ProcessDue() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events, err := s.repo.ClaimDue(time.Now(), outboxLease, outboxBatch)
+	if err != nil {
+		return 0, err
+	}
+	for i := range events {
+		event := &events[i]
+		s.attempt(event)
+		if err := s.repo.Update(event); err != nil {
+			return i + 1, err
+		}
+	}
+	return len(events), nil
+}
+
+// attempt runs the handler of the event and schedules its retry when it fails
+func (s *outboxService) attempt(event *models.OutboxEvent) {
+	now := time.Now()
+	event.Attempts++
+	event.LastAttemptAt = &now
+
+	handler, ok := s.handlers[event.Topic]
+	if !ok {
+		event.Status = models.OutboxFailed
+		event.NextAttemptAt = nil
+		event.Error = "no handler for topic " + event.Topic
+		return
+	}
+
+	result, err := runOutboxHandler(handler, event)
+	if err == nil {
+		event.Status = models.OutboxProcessed
+		event.NextAttemptAt = nil
+		event.ProcessedAt = &now
+		if len(result) > 255 {
+			result = result[:255]
+		}
+		event.Result = result
+		event.Error = ""
+		return
+	}
+
+	event.Error = err.Error()
+	if event.Attempts >= outboxMaxAttempts {
+		event.Status = models.OutboxFailed
+		event.NextAttemptAt = nil
+		slog.Warn("Outbox event failed", "id", event.ID, "topic", event.Topic, "error", err)
+		return
+	}
+	next := now.Add(outboxBackoff(event.Attempts))
+	event.NextAttemptAt = &next
+}
+
+// runOutboxHandler turns a handler panic into an error, so one bad event doesn't stop the
+// dispatcher
+func runOutboxHandler(handler OutboxHandler, event *models.OutboxEvent) (result string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("handler panic: %v", r)
+		}
+	}()
+	return handler(tenant.WithID(context.Background(), event.TenantID), event)
+}
+
+// outboxBackoff is the wait after the nth failed attempt: 30s, 1m, 2m... up to 2h
+func outboxBackoff(attempts int) time.Duration {
+	delay := outboxRetryBase
+	for i := 1; i < attempts && delay < outboxRetryMax; i++ {
+		delay *= 2
+	}
+	if delay > outboxRetryMax {
+		delay = outboxRetryMax
+	}
+	return delay
+}
+
+func (s *outboxService) Start(interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultOutboxInterval
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				// Drain the queue batch by batch before waiting for the next tick
+				for {
+					processed, err := s.ProcessDue()
+					if err != nil {
+						slog.Warn("Outbox dispatch failed", "error", err)
+						break
+					}
+					if processed < outboxBatch {
+						break
+					}
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (s *outboxService) Stop() {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+	}
+}
@@ -0,0 +1,84 @@
+//go:build integration
+
+package services_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"github.com/shigake/tech-iq-back/internal/services"
+	"github.com/shigake/tech-iq-back/internal/testutil"
+)
+
+func TestPaymentBatchLifecycle(t *testing.T) {
+	env.Reset(t)
+	financial := services.NewFinancialService(
+		repositories.NewFinancialRepository(env.DB),
+		repositories.NewCategoryRepository(env.DB),
+		nil,
+		repositories.NewSupplierRepository(env.DB),
+		nil,
+		nil,
+		nil,
+	)
+	today := time.Now().Format("2006-01-02")
+
+	var entryIDs []string
+	for _, amount := range []float64{150, 250.5} {
+		entry, err := financial.CreateEntry(models.CreateFinancialEntryRequest{
+			Type:         models.FinancialEntryTypeExpense,
+			Category:     "Pagamento Técnicos",
+			Subcategory:  "Comissão",
+			Description:  "Fixture payout",
+			Amount:       amount,
+			EntryDate:    today,
+			TechnicianID: testutil.TechnicianID,
+		}, testutil.AdminUserID, "", "")
+		if err != nil {
+			t.Fatalf("create entry: %v", err)
+		}
+		entryIDs = append(entryIDs, entry.ID)
+	}
+
+	batch, err := financial.CreateBatch(models.CreatePaymentBatchRequest{Name: "Fixture batch", PeriodStart: today, PeriodEnd: today}, testutil.AdminUserID, "", "")
+	if err != nil {
+		t.Fatalf("create batch: %v", err)
+	}
+	if _, err := financial.PayBatch(batch.ID, models.PayBatchRequest{}, testutil.AdminUserID, "", ""); err == nil {
+		t.Fatal("draft batch paid")
+	}
+
+	batch, err = financial.AddEntriesToBatch(batch.ID, models.AddBatchEntriesRequest{EntryIDs: entryIDs}, testutil.AdminUserID, "", "")
+	if err != nil {
+		t.Fatalf("add entries: %v", err)
+	}
+	if batch.EntriesCount != 2 || batch.TotalAmount != 400.5 {
+		t.Fatalf("batch totals = %d entries, %.2f; want 2, 400.50", batch.EntriesCount, batch.TotalAmount)
+	}
+
+	if _, err := financial.ApproveBatch(batch.ID, testutil.AdminUserID, "", ""); err != nil {
+		t.Fatalf("approve: %v", err)
+	}
+	paid, err := financial.PayBatch(batch.ID, models.PayBatchRequest{PaymentReference: "PIX-FIXTURE"}, testutil.AdminUserID, "", "")
+	if err != nil {
+		t.Fatalf("pay: %v", err)
+	}
+	if paid.Status != models.PaymentBatchStatusPaid || paid.PaidAt == nil {
+		t.Fatalf("batch status = %s, paid at %v", paid.Status, paid.PaidAt)
+	}
+	for _, id := range entryIDs {
+		entry, err := financial.GetEntryByID(id)
+		if err != nil {
+			t.Fatalf("entry: %v", err)
+		}
+		if entry.Status != models.FinancialEntryStatusPaid || entry.PaymentDate == nil {
+			t.Fatalf("entry %s status = %s, payment date %v", id, entry.Status, entry.PaymentDate)
+		}
+	}
+
+	if _, err := financial.PayBatch(batch.ID, models.PayBatchRequest{}, testutil.AdminUserID, "", ""); err == nil {
+		t.Fatal("batch paid twice")
+	}
+}
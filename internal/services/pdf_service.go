@@ -0,0 +1,464 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg" // logos and signatures
+	_ "image/png"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"github.com/skip2/go-qrcode"
+)
+
+var ErrReceiptUnavailable = errors.New("receipts are only issued for approved or paid batches")
+
+// maxOrderParts caps the parts listed on a service order
+const maxOrderParts = 500
+
+// PDFService renders the A4 documents handed to clients and technicians
+type PDFService interface {
+	// ServiceOrder renders the service order of a ticket with the parts used and the signatures
+	ServiceOrder(ticketID string) ([]byte, error)
+	// BatchReceipt renders the payment receipt of an approved or paid batch
+	BatchReceipt(batchID string) ([]byte, error)
+	// WithContext returns the service running its queries with ctx, inside the tenant it carries
+	WithContext(ctx context.Context) PDFService
+}
+
+type pdfService struct {
+	orders        *ticketPrintService
+	stockRepo     repositories.StockRepository
+	financialRepo *repositories.FinancialRepository
+	branding      BrandingService
+}
+
+func NewPDFService(
+	ticketRepo repositories.TicketRepository,
+	stockRepo repositories.StockRepository,
+	financialRepo *repositories.FinancialRepository,
+	branding BrandingService,
+	trackingURL string,
+) PDFService {
+	return &pdfService{
+		orders:        &ticketPrintService{ticketRepo: ticketRepo, branding: branding, trackingURL: trackingURL},
+		stockRepo:     stockRepo,
+		financialRepo: financialRepo,
+		branding:      branding,
+	}
+}
+
+func (s *pdfService) WithContext(ctx context.Context) PDFService {
+	orders := *s.orders
+	orders.ticketRepo = s.orders.ticketRepo.WithContext(ctx)
+	scoped := *s
+	scoped.orders = &orders
+	scoped.stockRepo = s.stockRepo.WithContext(ctx)
+	scoped.financialRepo = s.financialRepo.WithContext(ctx)
+	return &scoped
+}
+
+// orderPart is a stock item consumed by the ticket
+type orderPart struct {
+	SKU      string
+	Name     string
+	Unit     string
+	Quantity int
+}
+
+func (s *pdfService) ServiceOrder(ticketID string) ([]byte, error) {
+	ticket, err := s.orders.ticketRepo.FindByID(ticketID)
+	if err != nil {
+		return nil, err
+	}
+	branding, err := s.branding.Resolve(ticket.NodeID)
+	if err != nil {
+		return nil, err
+	}
+	order := s.orders.buildOrder(ticket, branding)
+	parts, err := s.ticketParts(ticket.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	l := newPDFLayout(order.PrimaryColor, order.AccentColor)
+	l.header(order.Logo, order.Company, "ORDEM DE SERVIÇO", order.OSNumber, "Emitida em "+order.PrintedAt)
+
+	l.section("Atendimento")
+	l.field("Status", order.Status)
+	l.field("Prioridade", order.Priority)
+	l.field("Categoria", order.Category)
+	l.field("Abertura", order.OpenedAt)
+	l.field("Agendado", order.Scheduled)
+
+	if order.Client != "" {
+		l.section("Cliente")
+		l.field("Nome", order.Client)
+		l.field("CPF/CNPJ", order.ClientDoc)
+		l.field("Telefone", order.ClientPhone)
+		l.field("Endereço", order.Address)
+	}
+
+	l.section("Equipamento")
+	l.field("Equipamento", order.Equipment)
+	l.field("Nº de série", order.Serial)
+	l.field("Técnicos", strings.Join(order.Technicians, ", "))
+
+	if order.Problem != "" {
+		l.section("Problema relatado")
+		l.paragraph(order.Problem)
+	}
+
+	l.section("Peças utilizadas")
+	if len(parts) == 0 {
+		l.paragraph("Nenhuma peça registrada.")
+	} else {
+		rows := make([][]string, len(parts))
+		for i, part := range parts {
+			rows[i] = []string{part.SKU, part.Name, fmt.Sprintf("%d %s", part.Quantity, part.Unit)}
+		}
+		l.table([]string{"Código", "Item", "Qtd."}, []float64{0.2, 0.62, 0.18}, []bool{false, false, true}, rows)
+	}
+
+	l.section("Assinaturas")
+	signedAt := ""
+	if ticket.SignedAt != nil {
+		signedAt = "Assinado em " + ticket.SignedAt.In(printLocation()).Format("02/01/2006 15:04")
+	}
+	clientLabel := "Cliente"
+	if ticket.SignedByName != "" {
+		clientLabel += " - " + ticket.SignedByName
+	}
+	l.signatures(decodeSignature(ticket.TechnicianSignature), "Técnico", decodeSignature(ticket.ClientSignature), clientLabel)
+	if signedAt != "" {
+		l.note(signedAt)
+	}
+
+	if order.TrackingURL != "" {
+		if qr, err := qrcode.New(order.TrackingURL, qrcode.Medium); err == nil {
+			l.qrCode(qr.Image(256), "Acompanhe seu atendimento")
+		}
+	}
+	l.footer(order.Footer)
+	return l.doc.Bytes()
+}
+
+// ticketParts sums the approved consumption of each stock item by the ticket
+func (s *pdfService) ticketParts(ticketID string) ([]orderPart, error) {
+	movements, err := s.stockRepo.ListMovements(models.StockMovementFilter{
+		TicketID: ticketID,
+		Type:     string(models.MovementTypeSaidaConsumoOS),
+		Status:   string(models.MovementStatusApproved),
+		PageSize: maxOrderParts,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var parts []orderPart
+	index := make(map[string]int)
+	for _, movement := range movements.Data {
+		i, ok := index[movement.ItemID]
+		if !ok {
+			part := orderPart{SKU: movement.ItemID, Unit: "UN"}
+			if movement.Item != nil {
+				part.SKU, part.Name, part.Unit = movement.Item.SKU, movement.Item.Name, movement.Item.Unit
+			}
+			i = len(parts)
+			index[movement.ItemID] = i
+			parts = append(parts, part)
+		}
+		parts[i].Quantity += movement.Quantity
+	}
+	return parts, nil
+}
+
+func (s *pdfService) BatchReceipt(batchID string) ([]byte, error) {
+	batch, err := s.financialRepo.GetBatchByID(batchID)
+	if err != nil {
+		return nil, err
+	}
+	if batch.Status != models.PaymentBatchStatusApproved && batch.Status != models.PaymentBatchStatusProcessing &&
+		batch.Status != models.PaymentBatchStatusPaid {
+		return nil, ErrReceiptUnavailable
+	}
+	branding, err := s.branding.Resolve(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	loc := printLocation()
+	title := "RECIBO DE PAGAMENTO"
+	if batch.Status != models.PaymentBatchStatusPaid {
+		title = "AUTORIZAÇÃO DE PAGAMENTO"
+	}
+	l := newPDFLayout(branding.PrimaryColor, branding.AccentColor)
+	l.header(branding.Logo, branding.DisplayName, title, batch.Name, "Emitido em "+time.Now().In(loc).Format("02/01/2006 15:04"))
+
+	l.section("Lote")
+	l.field("Período", batch.PeriodStart.Format("02/01/2006")+" a "+batch.PeriodEnd.Format("02/01/2006"))
+	l.field("Descrição", batch.Description)
+	l.field("Lançamentos", fmt.Sprintf("%d", len(batch.Entries)))
+	l.field("Total", formatBRL(batch.TotalAmount))
+	if batch.ApprovedAt != nil {
+		approval := batch.ApprovedAt.In(loc).Format("02/01/2006 15:04")
+		if batch.ApprovedByUser != nil {
+			approval += " por " + batch.ApprovedByUser.FullName
+		}
+		l.field("Aprovado em", approval)
+	}
+	if batch.PaidAt != nil {
+		l.field("Pago em", batch.PaidAt.In(loc).Format("02/01/2006 15:04"))
+		l.field("Referência", batch.PaymentReference)
+	}
+
+	l.section("Lançamentos")
+	rows := make([][]string, 0, len(batch.Entries))
+	var total float64
+	for _, entry := range batch.Entries {
+		payee := ""
+		if entry.Technician != nil {
+			payee = entry.Technician.FullName
+		}
+		rows = append(rows, []string{entry.EntryDate.Format("02/01/2006"), entry.Description, payee, formatBRL(entry.Amount)})
+		total += entry.Amount
+	}
+	rows = append(rows, []string{"", "", "Total", formatBRL(total)})
+	l.table([]string{"Data", "Descrição", "Favorecido", "Valor"}, []float64{0.14, 0.44, 0.26, 0.16}, []bool{false, false, false, true}, rows)
+
+	l.signatures(nil, "Responsável financeiro", nil, "Recebedor")
+	l.footer(branding.FooterText)
+	return l.doc.Bytes()
+}
+
+// decodeSignature decodes a base64 signature image, with or without its data URL prefix
+func decodeSignature(signature string) image.Image {
+	if signature == "" {
+		return nil
+	}
+	if i := strings.Index(signature, ","); i >= 0 && strings.HasPrefix(signature, "data:") {
+		signature = signature[i+1:]
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(signature))
+	if err != nil {
+		return nil
+	}
+	img, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil
+	}
+	return img
+}
+
+// formatBRL formats an amount as Brazilian currency, e.g. R$ 1.234,56
+func formatBRL(amount float64) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	cents := int64(math.Round(amount * 100))
+	digits := fmt.Sprintf("%d", cents/100)
+	var grouped strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			grouped.WriteByte('.')
+		}
+		grouped.WriteRune(d)
+	}
+	return fmt.Sprintf("%sR$ %s,%02d", sign, grouped.String(), cents%100)
+}
+
+// =============== Layout ===============
+
+// pdfLayout stacks the blocks of a document top to bottom, breaking pages as needed
+type pdfLayout struct {
+	doc     *pdfDocument
+	y       float64
+	primary string
+	accent  string
+}
+
+const (
+	pdfBodySize  = 9.5
+	pdfLineGap   = 13.0
+	pdfLabelSize = 110.0 // width of the field labels
+)
+
+func newPDFLayout(primary, accent string) *pdfLayout {
+	if !hexColorPattern.MatchString(accent) {
+		accent = "#999999"
+	}
+	return &pdfLayout{doc: newPDFDocument(), y: pdfMargin, primary: primary, accent: accent}
+}
+
+func (l *pdfLayout) width() float64 {
+	return pdfPageWidth - 2*pdfMargin
+}
+
+// ensure starts a new page when height doesn't fit on the current one
+func (l *pdfLayout) ensure(height float64) {
+	if l.y+height > pdfPageHeight-pdfMargin {
+		l.newPage()
+	}
+}
+
+func (l *pdfLayout) newPage() {
+	l.doc.AddPage()
+	l.y = pdfMargin
+}
+
+func (l *pdfLayout) header(logo []byte, company, title, number, issued string) {
+	right := pdfPageWidth - pdfMargin
+	if img, _, err := image.Decode(bytes.NewReader(logo)); err == nil {
+		l.doc.Image(img, pdfMargin, l.y, 140, 50)
+		l.doc.Text(pdfMargin, l.y+64, 10, true, "", company)
+	} else {
+		l.doc.Text(pdfMargin, l.y+20, 14, true, l.primary, company)
+	}
+	l.doc.TextRight(right, l.y+14, 12, true, l.primary, title)
+	l.doc.TextRight(right, l.y+36, 18, true, "", number)
+	l.doc.TextRight(right, l.y+52, 8, false, "#555555", issued)
+	l.y += 74
+	l.doc.Line(pdfMargin, l.y, right, l.y, l.accent)
+	l.y += 8
+}
+
+func (l *pdfLayout) section(title string) {
+	l.ensure(40)
+	l.y += 12
+	l.doc.FillRect(pdfMargin, l.y, l.width(), 16, "#eeeeee")
+	l.doc.Text(pdfMargin+4, l.y+11.5, 10, true, l.primary, title)
+	l.y += 22
+}
+
+// field writes a label and its value, wrapped; empty values are skipped
+func (l *pdfLayout) field(label, value string) {
+	if strings.TrimSpace(value) == "" {
+		return
+	}
+	lines := l.doc.Wrap(value, pdfBodySize, false, l.width()-pdfLabelSize)
+	for i, line := range lines {
+		l.ensure(pdfLineGap)
+		if i == 0 {
+			l.doc.Text(pdfMargin, l.y+9, pdfBodySize, true, "", label)
+		}
+		l.doc.Text(pdfMargin+pdfLabelSize, l.y+9, pdfBodySize, false, "", line)
+		l.y += pdfLineGap
+	}
+}
+
+func (l *pdfLayout) paragraph(text string) {
+	for _, line := range l.doc.Wrap(text, pdfBodySize, false, l.width()) {
+		l.ensure(pdfLineGap)
+		l.doc.Text(pdfMargin, l.y+9, pdfBodySize, false, "", line)
+		l.y += pdfLineGap
+	}
+}
+
+func (l *pdfLayout) note(text string) {
+	l.ensure(pdfLineGap)
+	l.doc.Text(pdfMargin, l.y+9, 8, false, "#555555", text)
+	l.y += pdfLineGap
+}
+
+// table writes rows under a header repeated on every page; widths are fractions of the
+// page width and cells are truncated to fit
+func (l *pdfLayout) table(header []string, widths []float64, alignRight []bool, rows [][]string) {
+	columns := make([]float64, len(widths))
+	x := pdfMargin
+	for i, w := range widths {
+		columns[i] = x
+		x += w * l.width()
+	}
+	cell := func(i int, text string, bold bool) {
+		text = l.truncate(text, bold, widths[i]*l.width()-6)
+		if alignRight[i] {
+			l.doc.TextRight(columns[i]+widths[i]*l.width()-3, l.y+9, pdfBodySize, bold, "", text)
+		} else {
+			l.doc.Text(columns[i]+3, l.y+9, pdfBodySize, bold, "", text)
+		}
+	}
+	writeHeader := func() {
+		for i, title := range header {
+			cell(i, title, true)
+		}
+		l.y += pdfLineGap
+		l.doc.Line(pdfMargin, l.y-2, pdfMargin+l.width(), l.y-2, l.accent)
+	}
+
+	writeHeader()
+	for _, row := range rows {
+		if l.y+pdfLineGap > pdfPageHeight-pdfMargin {
+			l.newPage()
+			writeHeader()
+		}
+		for i, text := range row {
+			cell(i, text, false)
+		}
+		l.y += pdfLineGap
+	}
+}
+
+func (l *pdfLayout) truncate(text string, bold bool, width float64) string {
+	if l.doc.TextWidth(text, pdfBodySize, bold) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && l.doc.TextWidth(string(runes)+"...", pdfBodySize, bold) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
+
+// signatures draws two signature boxes side by side; without an image the line is left
+// blank to be signed on paper
+func (l *pdfLayout) signatures(left image.Image, leftLabel string, right image.Image, rightLabel string) {
+	const boxHeight = 70.0
+	l.ensure(boxHeight + 30)
+	l.y += 10
+	boxWidth := (l.width() - 40) / 2
+	for i, signature := range []struct {
+		img   image.Image
+		label string
+	}{{left, leftLabel}, {right, rightLabel}} {
+		x := pdfMargin + float64(i)*(boxWidth+40)
+		if signature.img != nil {
+			l.doc.Image(signature.img, x+10, l.y, boxWidth-20, boxHeight-6)
+		}
+		l.doc.Line(x, l.y+boxHeight, x+boxWidth, l.y+boxHeight, "")
+		l.doc.Text(x, l.y+boxHeight+12, 8.5, false, "", l.truncate(signature.label, false, boxWidth))
+	}
+	l.y += boxHeight + 20
+}
+
+func (l *pdfLayout) qrCode(img image.Image, caption string) {
+	const size = 80.0
+	l.ensure(size + 20)
+	l.y += 6
+	x := pdfPageWidth - pdfMargin - size
+	l.doc.Image(img, x, l.y, size, size)
+	l.doc.TextRight(x-8, l.y+size/2, 8.5, false, "#555555", caption)
+	l.y += size
+}
+
+func (l *pdfLayout) footer(text string) {
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	lines := l.doc.Wrap(text, 8, false, l.width())
+	l.ensure(float64(len(lines))*11 + 12)
+	l.y += 8
+	l.doc.Line(pdfMargin, l.y, pdfMargin+l.width(), l.y, l.accent)
+	for _, line := range lines {
+		l.y += 11
+		l.doc.Text(pdfMargin, l.y, 8, false, "#555555", line)
+	}
+}
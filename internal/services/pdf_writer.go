@@ -0,0 +1,154 @@
+package services
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/png"
+	"strconv"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// A4 in points, with the margins used by every document
+const (
+	pdfPageWidth  = 595.28
+	pdfPageHeight = 841.89
+	pdfMargin     = 40.0
+)
+
+// pdfDocument writes A4 PDFs through gofpdf with the standard Helvetica fonts, so nothing
+// has to be embedded but the images. Text is translated to Windows-1252, which covers
+// Portuguese. Coordinates are in points from the top-left corner of the page.
+type pdfDocument struct {
+	pdf       *gofpdf.Fpdf
+	translate func(string) string
+	images    int
+}
+
+func newPDFDocument() *pdfDocument {
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.SetMargins(0, 0, 0)
+	pdf.SetAutoPageBreak(false, 0)
+	d := &pdfDocument{pdf: pdf, translate: pdf.UnicodeTranslatorFromDescriptor("")}
+	d.AddPage()
+	return d
+}
+
+func (d *pdfDocument) AddPage() {
+	d.pdf.AddPage()
+}
+
+// Text writes s with its baseline at y; color is #rrggbb, black when empty or invalid
+func (d *pdfDocument) Text(x, y, size float64, bold bool, color, s string) {
+	d.setFont(size, bold)
+	d.pdf.SetTextColor(pdfRGB(color))
+	s = strings.NewReplacer("\r", "", "\n", " ").Replace(s)
+	d.pdf.Text(x, y, d.translate(s))
+}
+
+// TextRight writes s ending at x
+func (d *pdfDocument) TextRight(x, y, size float64, bold bool, color, s string) {
+	d.Text(x-d.TextWidth(s, size, bold), y, size, bold, color, s)
+}
+
+// TextWidth measures s in points
+func (d *pdfDocument) TextWidth(s string, size float64, bold bool) float64 {
+	d.setFont(size, bold)
+	return d.pdf.GetStringWidth(d.translate(s))
+}
+
+func (d *pdfDocument) setFont(size float64, bold bool) {
+	style := ""
+	if bold {
+		style = "B"
+	}
+	d.pdf.SetFont("Helvetica", style, size)
+}
+
+// Wrap breaks s into lines no wider than width, keeping its line breaks
+func (d *pdfDocument) Wrap(s string, size float64, bold bool, width float64) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if line != "" && d.TextWidth(candidate, size, bold) > width {
+				lines = append(lines, line)
+				candidate = word
+			}
+			line = candidate
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Line draws a line 0.5pt wide
+func (d *pdfDocument) Line(x1, y1, x2, y2 float64, color string) {
+	d.pdf.SetDrawColor(pdfRGB(color))
+	d.pdf.SetLineWidth(0.5)
+	d.pdf.Line(x1, y1, x2, y2)
+}
+
+// FillRect fills a rectangle whose top-left corner is x, y
+func (d *pdfDocument) FillRect(x, y, w, h float64, color string) {
+	d.pdf.SetFillColor(pdfRGB(color))
+	d.pdf.Rect(x, y, w, h, "F")
+}
+
+// Image draws img in the box whose top-left corner is x, y, keeping its aspect ratio.
+// Transparent pixels become white.
+func (d *pdfDocument) Image(img image.Image, x, y, w, h float64) {
+	bounds := img.Bounds()
+	if bounds.Dx() == 0 || bounds.Dy() == 0 {
+		return
+	}
+	// Flattened to 8-bit RGB, the PNG flavour gofpdf reads whatever the source was
+	flat := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(flat, flat.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, bounds.Min, draw.Over)
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, flat); err != nil {
+		d.pdf.SetError(err)
+		return
+	}
+
+	d.images++
+	name := "image" + strconv.Itoa(d.images)
+	options := gofpdf.ImageOptions{ImageType: "PNG"}
+	if d.pdf.RegisterImageOptionsReader(name, options, &encoded) == nil {
+		return
+	}
+	scale := w / float64(bounds.Dx())
+	if s := h / float64(bounds.Dy()); s < scale {
+		scale = s
+	}
+	d.pdf.ImageOptions(name, x, y, float64(bounds.Dx())*scale, float64(bounds.Dy())*scale, false, options, 0, "")
+}
+
+// Bytes assembles the document
+func (d *pdfDocument) Bytes() ([]byte, error) {
+	var out bytes.Buffer
+	if err := d.pdf.Output(&out); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// pdfRGB converts #rrggbb to its components, black when the color is invalid
+func pdfRGB(color string) (r, g, b int) {
+	if !hexColorPattern.MatchString(color) {
+		return 0, 0, 0
+	}
+	var rgb [3]int
+	for i := range rgb {
+		v, _ := strconv.ParseUint(color[1+2*i:3+2*i], 16, 8)
+		rgb[i] = int(v)
+	}
+	return rgb[0], rgb[1], rgb[2]
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"log/slog"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/shigake/tech-iq-back/internal/cache"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+)
+
+// PermissionService resolves the node-scoped permissions granted by the hierarchy roles.
+// A membership grants the permissions of its role on the node and on its descendants.
+//
+// ADMIN users have every permission. Users without any membership keep the coarse rules
+// of their role: EMPLOYEE has every permission and USER (technicians) only the ".view"
+// ones, so nothing changes for them until they are placed in the hierarchy.
+type PermissionService interface {
+	// HasPermission tells whether the user holds the permission; with nodeID 0 on any
+	// node, otherwise on that node (through a membership on it or on an ancestor)
+	HasPermission(userID, userRole, code string, nodeID uint) (bool, error)
+	// InvalidateUser drops the cached permissions of a user (membership changes)
+	InvalidateUser(userID string)
+	// InvalidateAll drops every cached permission (role or node changes)
+	InvalidateAll()
+}
+
+type permissionService struct {
+	hierarchyRepo repositories.HierarchyRepository
+	cache         *cache.RedisClient
+}
+
+func NewPermissionService(hierarchyRepo repositories.HierarchyRepository, cache *cache.RedisClient) PermissionService {
+	return &permissionService{hierarchyRepo: hierarchyRepo, cache: cache}
+}
+
+func (s *permissionService) HasPermission(userID, userRole, code string, nodeID uint) (bool, error) {
+	if userRole == "ADMIN" {
+		return true, nil
+	}
+
+	permissions, err := s.nodePermissions(userID)
+	if err != nil {
+		return false, err
+	}
+	if len(permissions) == 0 {
+		return userRole == "EMPLOYEE" || strings.HasSuffix(code, ".view"), nil
+	}
+
+	var nodePath string
+	if nodeID != 0 {
+		node, err := s.hierarchyRepo.GetNodeByID(nodeID)
+		if err != nil {
+			return false, nil
+		}
+		nodePath = node.Path
+	}
+
+	for _, p := range permissions {
+		if p.Code != code {
+			continue
+		}
+		if nodeID == 0 || nodePath == p.NodePath || strings.HasPrefix(nodePath, p.NodePath+".") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *permissionService) InvalidateUser(userID string) {
+	if s.cache == nil {
+		return
+	}
+	if err := s.cache.Delete(cache.UserPermissionsCacheKey(userID)); err != nil && err != cache.ErrCacheDegraded {
+		slog.Warn("Failed to invalidate permissions of user", "user_id", userID, "error", err)
+	}
+}
+
+func (s *permissionService) InvalidateAll() {
+	if s.cache == nil {
+		return
+	}
+	if err := s.cache.DeletePattern(cache.UserPermissionsPattern); err != nil && err != cache.ErrCacheDegraded {
+		slog.Warn("Failed to invalidate cached permissions", "error", err)
+	}
+}
+
+// nodePermissions returns the permissions per node of the user, from the cache when there
+func (s *permissionService) nodePermissions(userID string) ([]models.NodePermission, error) {
+	key := cache.UserPermissionsCacheKey(userID)
+	if s.cache != nil {
+		var cached []models.NodePermission
+		err := s.cache.Get(key, &cached)
+		if err == nil {
+			return cached, nil
+		}
+		if err != redis.Nil && err != cache.ErrCacheDegraded {
+			slog.Debug("Cache error", "error", err)
+		}
+	}
+
+	permissions, err := s.hierarchyRepo.GetUserNodePermissions(userID)
+	if err != nil {
+		return nil, err
+	}
+	if s.cache != nil {
+		if err := s.cache.Set(key, permissions, cache.UserPermissionsTTL); err != nil && err != cache.ErrCacheDegraded {
+			slog.Warn("Failed to cache permissions", "error", err)
+		}
+	}
+	return permissions, nil
+}
@@ -0,0 +1,476 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+const priceDateLayout = "2006-01-02"
+
+var (
+	ErrPriceListNotFound       = errors.New("price list not found")
+	ErrPriceEntryNotFound      = errors.New("price list entry not found")
+	ErrPriceClientNotFound     = errors.New("client not found")
+	ErrPriceCategoryNotFound   = errors.New("category not found")
+	ErrPriceItemNotFound       = errors.New("stock item not found")
+	ErrPriceTicketNotFound     = errors.New("ticket not found")
+	ErrPriceInvalidDate        = errors.New("invalid date, expected YYYY-MM-DD")
+	ErrPriceInvalidPeriod      = errors.New("validTo must not be before validFrom")
+	ErrPriceNegative           = errors.New("price must not be negative")
+	ErrPriceInvalidQuantity    = errors.New("quantity must be positive")
+	ErrPriceEntryInvalidTarget = errors.New("PART entries need itemId only; SERVICE and LABOR entries take an optional categoryId only")
+	ErrPriceEntryDuplicate     = errors.New("price list already has an entry for this item or category")
+)
+
+// PriceListService manages client and default price lists and resolves the effective
+// price of quote, invoice and profitability lines
+type PriceListService interface {
+	List(filters *models.PriceListFilters) ([]models.PriceList, error)
+	Get(id string) (*models.PriceList, error)
+	Create(userID string, req *models.CreatePriceListRequest) (*models.PriceList, error)
+	Update(id, userID string, req *models.UpdatePriceListRequest) (*models.PriceList, error)
+	AddEntry(listID, userID string, req *models.PriceListEntryRequest) (*models.PriceList, error)
+	UpdateEntry(listID, entryID, userID string, req *models.PriceListEntryRequest) (*models.PriceList, error)
+	DeleteEntry(listID, entryID, userID string) error
+	GetHistory(listID string) ([]models.PriceChange, error)
+	Resolve(req *models.ResolvePricesRequest) (*models.ResolvePricesResponse, error)
+}
+
+type priceListService struct {
+	repo         repositories.PriceListRepository
+	clientRepo   repositories.ClientRepository
+	categoryRepo repositories.CategoryRepository
+	ticketRepo   repositories.TicketRepository
+	stockRepo    repositories.StockRepository
+}
+
+func NewPriceListService(
+	repo repositories.PriceListRepository,
+	clientRepo repositories.ClientRepository,
+	categoryRepo repositories.CategoryRepository,
+	ticketRepo repositories.TicketRepository,
+	stockRepo repositories.StockRepository,
+) PriceListService {
+	return &priceListService{
+		repo:         repo,
+		clientRepo:   clientRepo,
+		categoryRepo: categoryRepo,
+		ticketRepo:   ticketRepo,
+		stockRepo:    stockRepo,
+	}
+}
+
+func (s *priceListService) List(filters *models.PriceListFilters) ([]models.PriceList, error) {
+	return s.repo.FindAll(filters)
+}
+
+func (s *priceListService) Get(id string) (*models.PriceList, error) {
+	list, err := s.repo.FindByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPriceListNotFound
+		}
+		return nil, err
+	}
+	return list, nil
+}
+
+func (s *priceListService) Create(userID string, req *models.CreatePriceListRequest) (*models.PriceList, error) {
+	if req.ClientID != nil {
+		if _, err := s.clientRepo.GetByID(*req.ClientID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrPriceClientNotFound
+			}
+			return nil, err
+		}
+	}
+	validFrom, err := parsePriceDate(req.ValidFrom)
+	if err != nil {
+		return nil, err
+	}
+	validTo, err := parseOptionalPriceDate(req.ValidTo)
+	if err != nil {
+		return nil, err
+	}
+	if validTo != nil && validTo.Before(validFrom) {
+		return nil, ErrPriceInvalidPeriod
+	}
+
+	currency := strings.ToUpper(req.Currency)
+	if currency == "" {
+		currency = "BRL"
+	}
+	list := &models.PriceList{
+		Name:      strings.TrimSpace(req.Name),
+		ClientID:  req.ClientID,
+		ValidFrom: validFrom,
+		ValidTo:   validTo,
+		Currency:  currency,
+		IsActive:  true,
+		CreatedBy: userID,
+	}
+	change := &models.PriceChange{
+		Action:      models.PriceChangeListCreated,
+		Description: fmt.Sprintf("Tabela criada com vigência %s", formatPricePeriod(list)),
+		ChangedBy:   userID,
+	}
+	if err := s.repo.Create(list, change); err != nil {
+		return nil, err
+	}
+	return s.Get(list.ID)
+}
+
+func (s *priceListService) Update(id, userID string, req *models.UpdatePriceListRequest) (*models.PriceList, error) {
+	list, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []string
+	if req.Name != nil {
+		if name := strings.TrimSpace(*req.Name); name != list.Name {
+			changes = append(changes, fmt.Sprintf("nome: %s → %s", list.Name, name))
+			list.Name = name
+		}
+	}
+	period := formatPricePeriod(list)
+	if req.ValidFrom != nil {
+		if list.ValidFrom, err = parsePriceDate(*req.ValidFrom); err != nil {
+			return nil, err
+		}
+	}
+	if req.ValidTo != nil {
+		if list.ValidTo, err = parseOptionalPriceDate(req.ValidTo); err != nil {
+			return nil, err
+		}
+	}
+	if list.ValidTo != nil && list.ValidTo.Before(list.ValidFrom) {
+		return nil, ErrPriceInvalidPeriod
+	}
+	if p := formatPricePeriod(list); p != period {
+		changes = append(changes, fmt.Sprintf("vigência: %s → %s", period, p))
+	}
+	if req.IsActive != nil && *req.IsActive != list.IsActive {
+		list.IsActive = *req.IsActive
+		if list.IsActive {
+			changes = append(changes, "tabela reativada")
+		} else {
+			changes = append(changes, "tabela desativada")
+		}
+	}
+	if len(changes) == 0 {
+		return list, nil
+	}
+
+	change := &models.PriceChange{
+		PriceListID: list.ID,
+		Action:      models.PriceChangeListUpdated,
+		Description: strings.Join(changes, "; "),
+		ChangedBy:   userID,
+	}
+	if err := s.repo.Update(list, change); err != nil {
+		return nil, err
+	}
+	return s.Get(id)
+}
+
+func (s *priceListService) AddEntry(listID, userID string, req *models.PriceListEntryRequest) (*models.PriceList, error) {
+	list, err := s.Get(listID)
+	if err != nil {
+		return nil, err
+	}
+	entry := &models.PriceListEntry{PriceListID: list.ID}
+	if err := s.applyEntry(list, entry, req); err != nil {
+		return nil, err
+	}
+
+	change := &models.PriceChange{
+		PriceListID: list.ID,
+		Action:      models.PriceChangeEntryAdded,
+		Description: fmt.Sprintf("%s incluído: %s", entryLabel(entry), entry.Price.StringFixed(2)),
+		NewPrice:    &entry.Price,
+		ChangedBy:   userID,
+	}
+	if err := s.repo.SaveEntry(entry, change); err != nil {
+		return nil, err
+	}
+	return s.Get(listID)
+}
+
+func (s *priceListService) UpdateEntry(listID, entryID, userID string, req *models.PriceListEntryRequest) (*models.PriceList, error) {
+	list, err := s.Get(listID)
+	if err != nil {
+		return nil, err
+	}
+	entry, err := s.findEntry(listID, entryID)
+	if err != nil {
+		return nil, err
+	}
+	oldPrice := entry.Price
+	if err := s.applyEntry(list, entry, req); err != nil {
+		return nil, err
+	}
+
+	description := fmt.Sprintf("%s alterado", entryLabel(entry))
+	if !oldPrice.Equal(entry.Price) {
+		description = fmt.Sprintf("%s: %s → %s", entryLabel(entry), oldPrice.StringFixed(2), entry.Price.StringFixed(2))
+	}
+	change := &models.PriceChange{
+		PriceListID: list.ID,
+		Action:      models.PriceChangeEntryUpdated,
+		Description: description,
+		OldPrice:    &oldPrice,
+		NewPrice:    &entry.Price,
+		ChangedBy:   userID,
+	}
+	if err := s.repo.SaveEntry(entry, change); err != nil {
+		return nil, err
+	}
+	return s.Get(listID)
+}
+
+func (s *priceListService) DeleteEntry(listID, entryID, userID string) error {
+	entry, err := s.findEntry(listID, entryID)
+	if err != nil {
+		return err
+	}
+	change := &models.PriceChange{
+		PriceListID: listID,
+		EntryID:     &entry.ID,
+		Action:      models.PriceChangeEntryRemoved,
+		Description: fmt.Sprintf("%s removido", entryLabel(entry)),
+		OldPrice:    &entry.Price,
+		ChangedBy:   userID,
+	}
+	return s.repo.DeleteEntry(entry, change)
+}
+
+func (s *priceListService) GetHistory(listID string) ([]models.PriceChange, error) {
+	if _, err := s.Get(listID); err != nil {
+		return nil, err
+	}
+	return s.repo.FindChanges(listID)
+}
+
+func (s *priceListService) findEntry(listID, entryID string) (*models.PriceListEntry, error) {
+	entry, err := s.repo.FindEntry(listID, entryID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPriceEntryNotFound
+		}
+		return nil, err
+	}
+	return entry, nil
+}
+
+// applyEntry validates the request against the list and copies it into the entry
+func (s *priceListService) applyEntry(list *models.PriceList, entry *models.PriceListEntry, req *models.PriceListEntryRequest) error {
+	kind := models.PriceEntryKind(req.Kind)
+	if req.Price.IsNegative() {
+		return ErrPriceNegative
+	}
+
+	if kind == models.PriceKindPart {
+		if req.ItemID == nil || req.CategoryID != nil {
+			return ErrPriceEntryInvalidTarget
+		}
+		if _, err := s.stockRepo.GetItemByID(*req.ItemID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrPriceItemNotFound
+			}
+			return err
+		}
+	} else {
+		if req.ItemID != nil {
+			return ErrPriceEntryInvalidTarget
+		}
+		if req.CategoryID != nil {
+			if _, err := s.categoryRepo.GetByID(*req.CategoryID); err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return ErrPriceCategoryNotFound
+				}
+				return err
+			}
+		}
+	}
+
+	for _, e := range list.Entries {
+		if e.ID != entry.ID && e.Kind == kind &&
+			sameTarget(e.ItemID, req.ItemID) && sameTarget(e.CategoryID, req.CategoryID) {
+			return ErrPriceEntryDuplicate
+		}
+	}
+
+	unit := strings.ToUpper(strings.TrimSpace(req.Unit))
+	if unit == "" {
+		unit = "UN"
+		if kind == models.PriceKindLabor {
+			unit = "H"
+		}
+	}
+	entry.Kind = kind
+	entry.ItemID = req.ItemID
+	entry.CategoryID = req.CategoryID
+	entry.Description = strings.TrimSpace(req.Description)
+	entry.Unit = unit
+	entry.Price = req.Price.Round(2)
+	return nil
+}
+
+func sameTarget(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+func entryLabel(entry *models.PriceListEntry) string {
+	if entry.Description != "" {
+		return fmt.Sprintf("%s %s", entry.Kind, entry.Description)
+	}
+	return string(entry.Kind)
+}
+
+// Resolve returns the effective price of each line for a client (or the ticket client) on a date.
+// Lists are tried client first, newest first, then the default lists; within a list a category
+// price beats the generic SERVICE/LABOR price.
+func (s *priceListService) Resolve(req *models.ResolvePricesRequest) (*models.ResolvePricesResponse, error) {
+	date := req.Date
+	if date == "" {
+		date = time.Now().Format(priceDateLayout)
+	}
+	day, err := parsePriceDate(date)
+	if err != nil {
+		return nil, err
+	}
+
+	clientID := req.ClientID
+	var ticketCategoryID string
+	if req.TicketID != "" {
+		ticket, err := s.ticketRepo.FindByID(req.TicketID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrPriceTicketNotFound
+			}
+			return nil, err
+		}
+		if clientID == "" && ticket.ClientID != nil {
+			clientID = *ticket.ClientID
+		}
+		if ticket.CategoryID != nil {
+			ticketCategoryID = *ticket.CategoryID
+		}
+	}
+
+	lists, err := s.repo.FindApplicable(clientID, day)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.ResolvePricesResponse{
+		ClientID: clientID,
+		Date:     day,
+		Currency: "BRL",
+		Lines:    make([]models.ResolvedPrice, 0, len(req.Lines)),
+		Total:    decimal.Zero,
+	}
+	if len(lists) > 0 {
+		response.Currency = lists[0].Currency
+	}
+
+	for _, line := range req.Lines {
+		if line.Quantity.IsZero() {
+			line.Quantity = decimal.NewFromInt(1)
+		}
+		if !line.Quantity.IsPositive() {
+			return nil, ErrPriceInvalidQuantity
+		}
+		if line.Kind != string(models.PriceKindPart) && line.CategoryID == "" {
+			line.CategoryID = ticketCategoryID
+		}
+
+		resolved := models.ResolvedPrice{PriceLine: line, UnitPrice: decimal.Zero, Total: decimal.Zero}
+		if list, entry := findPrice(lists, line); entry != nil {
+			resolved.Found = true
+			resolved.UnitPrice = entry.Price
+			resolved.Total = entry.Price.Mul(line.Quantity).Round(2)
+			resolved.Unit = entry.Unit
+			resolved.Source = "DEFAULT"
+			if list.ClientID != nil {
+				resolved.Source = "CLIENT"
+			}
+			resolved.PriceListID = list.ID
+			resolved.PriceListName = list.Name
+			resolved.EntryID = entry.ID
+			response.Total = response.Total.Add(resolved.Total)
+		} else {
+			response.Missing++
+		}
+		response.Lines = append(response.Lines, resolved)
+	}
+	return response, nil
+}
+
+// findPrice walks the lists in priority order and returns the first matching entry
+func findPrice(lists []models.PriceList, line models.PriceLine) (*models.PriceList, *models.PriceListEntry) {
+	kind := models.PriceEntryKind(line.Kind)
+	for i := range lists {
+		var generic *models.PriceListEntry
+		for j := range lists[i].Entries {
+			e := &lists[i].Entries[j]
+			if e.Kind != kind {
+				continue
+			}
+			if kind == models.PriceKindPart {
+				if e.ItemID != nil && *e.ItemID == line.ItemID {
+					return &lists[i], e
+				}
+				continue
+			}
+			if e.CategoryID == nil {
+				generic = e
+			} else if *e.CategoryID == line.CategoryID {
+				return &lists[i], e
+			}
+		}
+		if generic != nil {
+			return &lists[i], generic
+		}
+	}
+	return nil, nil
+}
+
+func parsePriceDate(s string) (time.Time, error) {
+	t, err := time.Parse(priceDateLayout, s)
+	if err != nil {
+		return time.Time{}, ErrPriceInvalidDate
+	}
+	return t, nil
+}
+
+// parseOptionalPriceDate treats nil and "" as open-ended
+func parseOptionalPriceDate(s *string) (*time.Time, error) {
+	if s == nil || *s == "" {
+		return nil, nil
+	}
+	t, err := parsePriceDate(*s)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func formatPricePeriod(list *models.PriceList) string {
+	to := "indeterminado"
+	if list.ValidTo != nil {
+		to = list.ValidTo.Format("02/01/2006")
+	}
+	return fmt.Sprintf("%s a %s", list.ValidFrom.Format("02/01/2006"), to)
+}
@@ -0,0 +1,307 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrPrivacyRequestNotFound = errors.New("privacy request not found")
+	ErrPrivacyWrongPassword   = errors.New("password is incorrect")
+	ErrDeletionAlreadyPending = errors.New("account deletion is already scheduled")
+	ErrNoPendingDeletion      = errors.New("no account deletion is scheduled")
+	ErrPrivacyRequestClosed   = errors.New("privacy request is no longer scheduled")
+)
+
+const (
+	defaultDeletionGrace    = 30 * 24 * time.Hour
+	defaultDeletionInterval = time.Hour
+)
+
+// PrivacyService handles the data subject requests of the users (LGPD): a
+// machine-readable export of their personal data and the deletion of their account.
+// Deletions wait for a grace period, during which the user can cancel and admins can
+// reject or run them early; the account is then anonymized in place instead of removed,
+// so tickets, entries and audit records keep a valid reference. The hash-chained activity
+// log is kept unchanged as the legal audit trail.
+type PrivacyService interface {
+	Export(userID string) (*models.PersonalDataExport, error)
+	RequestDeletion(userID string, req *models.RequestAccountDeletionRequest) (*models.PrivacyRequest, error)
+	CancelDeletion(userID string) (*models.PrivacyRequest, error)
+	ListMine(userID string) ([]models.PrivacyRequest, error)
+
+	List(page, size int, filters *models.PrivacyRequestFilters) (*models.PaginatedResponse, error)
+	Get(id string) (*models.PrivacyRequest, error)
+	// Approve runs a scheduled deletion now, skipping the rest of the grace period
+	Approve(id, adminID string, req *models.ProcessPrivacyRequest) (*models.PrivacyRequest, error)
+	Reject(id, adminID string, req *models.ProcessPrivacyRequest) (*models.PrivacyRequest, error)
+
+	// ProcessDue anonymizes the accounts whose grace period ended
+	ProcessDue() (int, error)
+	Start(interval time.Duration)
+	Stop()
+}
+
+type privacyService struct {
+	repo               repositories.PrivacyRepository
+	userRepo           repositories.UserRepository
+	refreshTokenRepo   repositories.RefreshTokenRepository
+	activityLogService ActivityLogService
+	permissions        PermissionService
+	grace              time.Duration
+	stop               chan struct{}
+	done               chan struct{}
+}
+
+func NewPrivacyService(
+	repo repositories.PrivacyRepository,
+	userRepo repositories.UserRepository,
+	refreshTokenRepo repositories.RefreshTokenRepository,
+	activityLogService ActivityLogService,
+	permissions PermissionService,
+	grace time.Duration,
+) PrivacyService {
+	if grace <= 0 {
+		grace = defaultDeletionGrace
+	}
+	return &privacyService{
+		repo:               repo,
+		userRepo:           userRepo,
+		refreshTokenRepo:   refreshTokenRepo,
+		activityLogService: activityLogService,
+		permissions:        permissions,
+		grace:              grace,
+	}
+}
+
+func (s *privacyService) Export(userID string) (*models.PersonalDataExport, error) {
+	now := time.Now()
+	request := &models.PrivacyRequest{
+		UserID:      userID,
+		Type:        models.PrivacyRequestExport,
+		Status:      models.PrivacyStatusCompleted,
+		ProcessedAt: &now,
+	}
+	if err := s.repo.CreateRequest(request); err != nil {
+		return nil, err
+	}
+
+	export, err := s.repo.CollectPersonalData(userID)
+	if err != nil {
+		return nil, err
+	}
+	s.audit(userID, "export", request.ID, "Personal data exported")
+	return export, nil
+}
+
+func (s *privacyService) RequestDeletion(userID string, req *models.RequestAccountDeletionRequest) (*models.PrivacyRequest, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		return nil, ErrPrivacyWrongPassword
+	}
+	if _, err := s.repo.FindScheduledDeletion(userID); err == nil {
+		return nil, ErrDeletionAlreadyPending
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	scheduledFor := time.Now().Add(s.grace)
+	request := &models.PrivacyRequest{
+		UserID:       userID,
+		Type:         models.PrivacyRequestDeletion,
+		Status:       models.PrivacyStatusScheduled,
+		Reason:       req.Reason,
+		ScheduledFor: &scheduledFor,
+	}
+	if err := s.repo.CreateRequest(request); err != nil {
+		return nil, err
+	}
+	s.audit(userID, "request_deletion", request.ID,
+		fmt.Sprintf("Account deletion scheduled for %s", scheduledFor.Format("2006-01-02 15:04")))
+	return request, nil
+}
+
+func (s *privacyService) CancelDeletion(userID string) (*models.PrivacyRequest, error) {
+	request, err := s.repo.FindScheduledDeletion(userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNoPendingDeletion
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	request.Status = models.PrivacyStatusCancelled
+	request.ProcessedAt = &now
+	if err := s.repo.UpdateRequest(request); err != nil {
+		return nil, err
+	}
+	s.audit(userID, "cancel_deletion", request.ID, "Account deletion cancelled by the user")
+	return request, nil
+}
+
+func (s *privacyService) ListMine(userID string) ([]models.PrivacyRequest, error) {
+	return s.repo.FindRequestsByUser(userID)
+}
+
+func (s *privacyService) List(page, size int, filters *models.PrivacyRequestFilters) (*models.PaginatedResponse, error) {
+	requests, total, err := s.repo.FindRequests(page, size, filters)
+	if err != nil {
+		return nil, err
+	}
+	return models.NewPaginatedResponse(requests, page, size, total), nil
+}
+
+func (s *privacyService) Get(id string) (*models.PrivacyRequest, error) {
+	request, err := s.repo.FindRequestByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPrivacyRequestNotFound
+	}
+	return request, err
+}
+
+func (s *privacyService) Approve(id, adminID string, req *models.ProcessPrivacyRequest) (*models.PrivacyRequest, error) {
+	request, err := s.scheduled(id)
+	if err != nil {
+		return nil, err
+	}
+	request.ProcessedBy = adminID
+	request.Notes = req.Notes
+	if err := s.anonymize(request); err != nil {
+		return nil, err
+	}
+	s.audit(adminID, "approve_deletion", request.ID,
+		fmt.Sprintf("Account deletion of user %s run before the end of the grace period", request.UserID))
+	return request, nil
+}
+
+func (s *privacyService) Reject(id, adminID string, req *models.ProcessPrivacyRequest) (*models.PrivacyRequest, error) {
+	request, err := s.scheduled(id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	request.Status = models.PrivacyStatusRejected
+	request.ProcessedAt = &now
+	request.ProcessedBy = adminID
+	request.Notes = req.Notes
+	if err := s.repo.UpdateRequest(request); err != nil {
+		return nil, err
+	}
+	s.audit(adminID, "reject_deletion", request.ID,
+		fmt.Sprintf("Account deletion of user %s rejected: %s", request.UserID, req.Notes))
+	return request, nil
+}
+
+func (s *privacyService) ProcessDue() (int, error) {
+	requests, err := s.repo.FindDueDeletions(time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	processed := 0
+	for i := range requests {
+		if err := s.anonymize(&requests[i]); err != nil {
+			slog.Warn("Account deletion failed", "deletion_request_id", requests[i].ID, "error", err)
+			continue
+		}
+		s.audit(requests[i].UserID, "delete_account", requests[i].ID, "Account anonymized after the grace period")
+		processed++
+	}
+	return processed, nil
+}
+
+func (s *privacyService) Start(interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultDeletionInterval
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				processed, err := s.ProcessDue()
+				if err != nil {
+					slog.Warn("Account deletions failed", "error", err)
+					continue
+				}
+				if processed > 0 {
+					slog.Info("Anonymized accounts after their deletion grace period", "processed", processed)
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (s *privacyService) Stop() {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+	}
+}
+
+func (s *privacyService) scheduled(id string) (*models.PrivacyRequest, error) {
+	request, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if request.Type != models.PrivacyRequestDeletion || request.Status != models.PrivacyStatusScheduled {
+		return nil, ErrPrivacyRequestClosed
+	}
+	return request, nil
+}
+
+// anonymize ends the sessions of the account, replaces its personal data with
+// placeholders and a password nobody knows, then completes the request
+func (s *privacyService) anonymize(request *models.PrivacyRequest) error {
+	if _, err := s.refreshTokenRepo.RevokeAllForUser(request.UserID, "", models.SessionRevokedAccountDeleted, time.Now()); err != nil {
+		return err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return err
+	}
+	password, err := bcrypt.GenerateFromPassword([]byte(hex.EncodeToString(secret)), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	email := fmt.Sprintf("deleted-%s@anonymized.invalid", request.UserID)
+	if err := s.repo.Anonymize(request.UserID, email, string(password)); err != nil {
+		return err
+	}
+	if s.permissions != nil {
+		s.permissions.InvalidateUser(request.UserID)
+	}
+
+	now := time.Now()
+	request.Status = models.PrivacyStatusCompleted
+	request.ProcessedAt = &now
+	return s.repo.UpdateRequest(request)
+}
+
+func (s *privacyService) audit(userID, action, requestID, description string) {
+	if err := s.activityLogService.LogAction(userID, action, "privacy_request", requestID, description, "", ""); err != nil {
+		slog.Warn("Failed to log privacy request", "privacy_request_id", requestID, "error", err)
+	}
+}
@@ -0,0 +1,507 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"github.com/shigake/tech-iq-back/internal/tenant"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRecallCampaignNotFound   = errors.New("recall campaign not found")
+	ErrRecallCampaignNotDraft   = errors.New("only DRAFT campaigns can be edited")
+	ErrRecallCampaignTransition = errors.New("invalid campaign status transition")
+	ErrRecallCampaignEmpty      = errors.New("no equipment matches the campaign criteria")
+	ErrRecallCampaignTooLarge   = errors.New("the campaign criteria match too many equipment, narrow them down")
+	ErrInvalidRecallCampaign    = errors.New("invalid recall campaign")
+)
+
+const (
+	// defaultRecallBatchSize is how many tickets the job generates per run, over all the
+	// running campaigns, so a large campaign does not flood the ticket queues at once
+	defaultRecallBatchSize = 50
+	maxRecallTargets       = 20000
+	recallPreviewSample    = 20
+	recallReportFailures   = 50
+	recallDateLayout       = "2006-01-02"
+)
+
+// RecallCampaignService selects the equipment matching the criteria of a campaign and
+// generates its preventive or recall tickets in throttled batches
+type RecallCampaignService interface {
+	List(status string) ([]models.RecallCampaign, error)
+	Get(id string) (*models.RecallCampaign, error)
+	Create(req *models.RecallCampaignRequest, userID string) (*models.RecallCampaign, error)
+	Update(id string, req *models.RecallCampaignRequest) (*models.RecallCampaign, error)
+	// Preview counts the equipment the criteria select, with a sample
+	Preview(req *models.RecallCampaignRequest) (*models.RecallCampaignPreview, error)
+
+	// StartCampaign selects the targets of a DRAFT campaign, or resumes a PAUSED one
+	StartCampaign(id, userID string) (*models.RecallCampaign, error)
+	PauseCampaign(id, userID string) (*models.RecallCampaign, error)
+	// CancelCampaign stops the campaign; the targets still pending are skipped
+	CancelCampaign(id, userID string) (*models.RecallCampaign, error)
+
+	GetTargets(id, status string, page, size int) (*models.PaginatedResponse, error)
+	GetReport(id string) (*models.RecallCampaignReport, error)
+
+	// ProcessBatch generates the tickets of the next pending targets of the running campaigns
+	ProcessBatch() (int, error)
+	Start(interval time.Duration)
+	Stop()
+	// WithContext returns the service running its queries with ctx, inside the tenant it carries
+	WithContext(ctx context.Context) RecallCampaignService
+}
+
+type recallCampaignService struct {
+	repo               repositories.RecallCampaignRepository
+	ticketService      TicketService
+	activityLogService ActivityLogService
+	batchSize          int
+	stop               chan struct{}
+	done               chan struct{}
+}
+
+func NewRecallCampaignService(
+	repo repositories.RecallCampaignRepository,
+	ticketService TicketService,
+	activityLogService ActivityLogService,
+	batchSize int,
+) RecallCampaignService {
+	if batchSize <= 0 {
+		batchSize = defaultRecallBatchSize
+	}
+	return &recallCampaignService{
+		repo:               repo,
+		ticketService:      ticketService,
+		activityLogService: activityLogService,
+		batchSize:          batchSize,
+	}
+}
+
+func (s *recallCampaignService) WithContext(ctx context.Context) RecallCampaignService {
+	scoped := *s
+	scoped.repo = s.repo.WithContext(ctx)
+	scoped.ticketService = s.ticketService.WithContext(ctx)
+	return &scoped
+}
+
+func (s *recallCampaignService) List(status string) ([]models.RecallCampaign, error) {
+	return s.repo.FindAll(status)
+}
+
+func (s *recallCampaignService) Get(id string) (*models.RecallCampaign, error) {
+	campaign, err := s.repo.FindByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRecallCampaignNotFound
+		}
+		return nil, err
+	}
+	return campaign, nil
+}
+
+func (s *recallCampaignService) Create(req *models.RecallCampaignRequest, userID string) (*models.RecallCampaign, error) {
+	campaign := &models.RecallCampaign{Status: models.RecallCampaignDraft, CreatedBy: userID}
+	if err := s.apply(campaign, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(campaign); err != nil {
+		return nil, err
+	}
+	return campaign, nil
+}
+
+func (s *recallCampaignService) Update(id string, req *models.RecallCampaignRequest) (*models.RecallCampaign, error) {
+	campaign, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if campaign.Status != models.RecallCampaignDraft {
+		return nil, ErrRecallCampaignNotDraft
+	}
+	if err := s.apply(campaign, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Update(campaign); err != nil {
+		return nil, err
+	}
+	return campaign, nil
+}
+
+func (s *recallCampaignService) Preview(req *models.RecallCampaignRequest) (*models.RecallCampaignPreview, error) {
+	criteria, err := recallCriteria(req)
+	if err != nil {
+		return nil, err
+	}
+	total, clients, err := s.repo.CountAssets(criteria)
+	if err != nil {
+		return nil, err
+	}
+	sample, err := s.repo.FindAssets(criteria, recallPreviewSample)
+	if err != nil {
+		return nil, err
+	}
+	if sample == nil {
+		sample = []models.RecallCampaignAsset{}
+	}
+	return &models.RecallCampaignPreview{Total: total, Clients: clients, Sample: sample}, nil
+}
+
+// apply validates the request and copies it to the campaign
+func (s *recallCampaignService) apply(campaign *models.RecallCampaign, req *models.RecallCampaignRequest) error {
+	criteria, err := recallCriteria(req)
+	if err != nil {
+		return err
+	}
+	campaign.CategoryID = nil
+	if req.CategoryID != nil && *req.CategoryID != "" {
+		exists, err := s.repo.CategoryExists(*req.CategoryID)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("%w: category not found", ErrInvalidRecallCampaign)
+		}
+		campaign.CategoryID = req.CategoryID
+	}
+
+	campaign.Name = strings.TrimSpace(req.Name)
+	campaign.Type = models.RecallCampaignType(req.Type)
+	campaign.Description = strings.TrimSpace(req.Description)
+	campaign.Priority = models.TicketPriority(req.Priority)
+	if campaign.Priority == "" {
+		campaign.Priority = models.TicketPriorityNormal
+	}
+	campaign.RecallCampaignCriteria = *criteria
+	return nil
+}
+
+// recallCriteria parses the criteria of the request; the install date range is inclusive
+func recallCriteria(req *models.RecallCampaignRequest) (*models.RecallCampaignCriteria, error) {
+	criteria := &models.RecallCampaignCriteria{
+		Brand: strings.TrimSpace(req.Brand),
+		Model: strings.TrimSpace(req.Model),
+		City:  strings.TrimSpace(req.City),
+	}
+	for _, state := range req.States {
+		criteria.States = append(criteria.States, strings.ToUpper(strings.TrimSpace(state)))
+	}
+	if req.InstalledFrom != "" {
+		from, err := time.Parse(recallDateLayout, req.InstalledFrom)
+		if err != nil {
+			return nil, fmt.Errorf("%w: installedFrom must be YYYY-MM-DD", ErrInvalidRecallCampaign)
+		}
+		criteria.InstalledFrom = &from
+	}
+	if req.InstalledTo != "" {
+		to, err := time.Parse(recallDateLayout, req.InstalledTo)
+		if err != nil {
+			return nil, fmt.Errorf("%w: installedTo must be YYYY-MM-DD", ErrInvalidRecallCampaign)
+		}
+		to = to.AddDate(0, 0, 1)
+		criteria.InstalledTo = &to
+	}
+	if criteria.InstalledFrom != nil && criteria.InstalledTo != nil && !criteria.InstalledFrom.Before(*criteria.InstalledTo) {
+		return nil, fmt.Errorf("%w: installedFrom must not be after installedTo", ErrInvalidRecallCampaign)
+	}
+	if criteria.Brand == "" && criteria.Model == "" && criteria.InstalledFrom == nil && criteria.InstalledTo == nil &&
+		len(criteria.States) == 0 && criteria.City == "" {
+		return nil, fmt.Errorf("%w: set at least one criterion", ErrInvalidRecallCampaign)
+	}
+	return criteria, nil
+}
+
+func (s *recallCampaignService) StartCampaign(id, userID string) (*models.RecallCampaign, error) {
+	campaign, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	switch campaign.Status {
+	case models.RecallCampaignPaused:
+		campaign.Status = models.RecallCampaignRunning
+		if err := s.repo.Update(campaign); err != nil {
+			return nil, err
+		}
+	case models.RecallCampaignDraft:
+		assets, err := s.repo.FindAssets(&campaign.RecallCampaignCriteria, maxRecallTargets+1)
+		if err != nil {
+			return nil, err
+		}
+		if len(assets) == 0 {
+			return nil, ErrRecallCampaignEmpty
+		}
+		if len(assets) > maxRecallTargets {
+			return nil, ErrRecallCampaignTooLarge
+		}
+
+		targets := make([]models.RecallCampaignTarget, len(assets))
+		for i, asset := range assets {
+			targets[i] = models.RecallCampaignTarget{
+				CampaignID:    campaign.ID,
+				ClientID:      asset.ClientID,
+				SerialNumber:  asset.SerialNumber,
+				ComputerBrand: asset.ComputerBrand,
+				ComputerModel: asset.ComputerModel,
+				InstalledAt:   asset.InstalledAt,
+				Status:        models.RecallTargetPending,
+			}
+		}
+		now := time.Now()
+		campaign.Status = models.RecallCampaignRunning
+		campaign.TotalTargets = len(targets)
+		campaign.StartedAt = &now
+		if err := s.repo.StartCampaign(campaign, targets); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrRecallCampaignTransition
+	}
+
+	s.audit(userID, "recall_campaign_start", campaign)
+	return campaign, nil
+}
+
+func (s *recallCampaignService) PauseCampaign(id, userID string) (*models.RecallCampaign, error) {
+	campaign, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if campaign.Status != models.RecallCampaignRunning {
+		return nil, ErrRecallCampaignTransition
+	}
+	campaign.Status = models.RecallCampaignPaused
+	if err := s.repo.Update(campaign); err != nil {
+		return nil, err
+	}
+	s.audit(userID, "recall_campaign_pause", campaign)
+	return campaign, nil
+}
+
+func (s *recallCampaignService) CancelCampaign(id, userID string) (*models.RecallCampaign, error) {
+	campaign, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	switch campaign.Status {
+	case models.RecallCampaignDraft, models.RecallCampaignRunning, models.RecallCampaignPaused:
+	default:
+		return nil, ErrRecallCampaignTransition
+	}
+
+	now := time.Now()
+	if err := s.repo.SkipPendingTargets(campaign.ID, now); err != nil {
+		return nil, err
+	}
+	campaign.Status = models.RecallCampaignCancelled
+	campaign.CompletedAt = &now
+	if err := s.repo.Update(campaign); err != nil {
+		return nil, err
+	}
+	s.audit(userID, "recall_campaign_cancel", campaign)
+	return campaign, nil
+}
+
+func (s *recallCampaignService) GetTargets(id, status string, page, size int) (*models.PaginatedResponse, error) {
+	if _, err := s.Get(id); err != nil {
+		return nil, err
+	}
+	targets, total, err := s.repo.FindTargets(id, status, page, size)
+	if err != nil {
+		return nil, err
+	}
+	return models.NewPaginatedResponse(targets, page, size, total), nil
+}
+
+func (s *recallCampaignService) GetReport(id string) (*models.RecallCampaignReport, error) {
+	campaign, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	byTarget, err := s.repo.CountTargetsByStatus(id)
+	if err != nil {
+		return nil, err
+	}
+	byTicket, err := s.repo.CountTicketsByStatus(id)
+	if err != nil {
+		return nil, err
+	}
+	byState, err := s.repo.CountTicketsByState(id)
+	if err != nil {
+		return nil, err
+	}
+	failures, _, err := s.repo.FindTargets(id, models.RecallTargetFailed, 0, recallReportFailures)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.RecallCampaignReport{
+		Campaign:       *campaign,
+		ByTargetStatus: nonNilCounts(byTarget),
+		ByTicketStatus: nonNilCounts(byTicket),
+		ByState:        nonNilCounts(byState),
+		Failures:       failures,
+	}
+	if report.Failures == nil {
+		report.Failures = []models.RecallCampaignTarget{}
+	}
+	for _, row := range byTarget {
+		if row.Key == models.RecallTargetPending {
+			report.Pending = row.Count
+		}
+	}
+	generated := 0
+	for _, row := range byTicket {
+		generated += row.Count
+		if row.Key == string(models.TicketStatusClosed) {
+			report.ClosedTickets = row.Count
+		}
+	}
+	if campaign.TotalTargets > 0 {
+		report.GenerationPercent = percentOf(campaign.TotalTargets-report.Pending, campaign.TotalTargets)
+	}
+	if generated > 0 {
+		report.CompletionPercent = percentOf(report.ClosedTickets, generated)
+	}
+	return report, nil
+}
+
+func nonNilCounts(counts []models.RecallCampaignCount) []models.RecallCampaignCount {
+	if counts == nil {
+		return []models.RecallCampaignCount{}
+	}
+	return counts
+}
+
+func percentOf(part, total int) float64 {
+	return math.Round(float64(part)*1000/float64(total)) / 10
+}
+
+// ProcessBatch generates up to batchSize tickets, serving the running campaigns in turn
+// from the oldest; a campaign without pending targets left is completed
+func (s *recallCampaignService) ProcessBatch() (int, error) {
+	campaigns, err := s.repo.FindRunning()
+	if err != nil {
+		return 0, err
+	}
+
+	generated := 0
+	budget := s.batchSize
+	for i := range campaigns {
+		campaign := &campaigns[i]
+		if budget > 0 {
+			targets, err := s.repo.FindPendingTargets(campaign.ID, budget)
+			if err != nil {
+				return generated, err
+			}
+			for j := range targets {
+				if s.generate(campaign, &targets[j]) {
+					generated++
+				}
+				if err := s.repo.UpdateTarget(&targets[j]); err != nil {
+					return generated, err
+				}
+			}
+			budget -= len(targets)
+		}
+
+		pending, err := s.repo.RefreshProgress(campaign)
+		if err != nil {
+			return generated, err
+		}
+		if pending == 0 {
+			now := time.Now()
+			campaign.Status = models.RecallCampaignCompleted
+			campaign.CompletedAt = &now
+			if err := s.repo.Update(campaign); err != nil {
+				return generated, err
+			}
+			slog.Info("Recall campaign completed", "campaign", campaign.Name, "created_tickets", campaign.CreatedTickets, "failed_targets", campaign.FailedTargets)
+		}
+	}
+	return generated, nil
+}
+
+// generate opens the ticket of the target inside the tenant of the campaign, recording
+// the outcome on it
+func (s *recallCampaignService) generate(campaign *models.RecallCampaign, target *models.RecallCampaignTarget) bool {
+	req := &models.CreateTicketRequest{
+		ErrorDescription: fmt.Sprintf("[%s] %s\n\n%s", campaign.Type, campaign.Name, campaign.Description),
+		Priority:         string(campaign.Priority),
+		ClientID:         target.ClientID,
+		ComputerBrand:    target.ComputerBrand,
+		ComputerModel:    target.ComputerModel,
+		SerialNumber:     target.SerialNumber,
+		CampaignID:       campaign.ID,
+	}
+	if campaign.CategoryID != nil {
+		req.CategoryID = *campaign.CategoryID
+	}
+
+	now := time.Now()
+	target.ProcessedAt = &now
+	ticket, err := s.ticketService.WithContext(tenant.WithID(context.Background(), campaign.TenantID)).Create(req)
+	if err != nil {
+		target.Status = models.RecallTargetFailed
+		target.Error = err.Error()
+		return false
+	}
+	target.Status = models.RecallTargetCreated
+	target.TicketID = &ticket.ID
+	target.Error = ""
+	return true
+}
+
+func (s *recallCampaignService) audit(userID, action string, campaign *models.RecallCampaign) {
+	if s.activityLogService == nil {
+		return
+	}
+	description := fmt.Sprintf("Campanha %s: %s (%d equipamentos)", campaign.Name, campaign.Status, campaign.TotalTargets)
+	if err := s.activityLogService.LogAction(userID, action, "recall_campaign", campaign.ID, description, "", ""); err != nil {
+		slog.Warn("Failed to audit recall campaign", "campaign_id", campaign.ID, "error", err)
+	}
+}
+
+// Start runs ProcessBatch every interval
+func (s *recallCampaignService) Start(interval time.Duration) {
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				generated, err := s.ProcessBatch()
+				if err != nil {
+					slog.Warn("Recall campaign batch failed", "error", err)
+					continue
+				}
+				if generated > 0 {
+					slog.Info("Recall campaigns generated tickets", "generated", generated)
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (s *recallCampaignService) Stop() {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+		s.stop = nil
+	}
+}
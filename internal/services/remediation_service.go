@@ -0,0 +1,380 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRemediationRuleNotFound   = errors.New("remediation rule not found")
+	ErrRemediationAlertType      = errors.New("unknown alert type")
+	ErrRemediationActionNotFound = errors.New("remediation action not found")
+	ErrRemediationNotApplied     = errors.New("only applied remediation actions can be reverted")
+)
+
+const defaultRemediationInterval = time.Minute
+
+// remediationAlertTypes are the alert types rules can react to
+var remediationAlertTypes = []string{
+	models.AlertTypeCacheDown, models.AlertTypeJobBacklog,
+	models.AlertTypeSLAAtRisk, models.AlertTypeLowStock, models.AlertTypeOverduePayment,
+	models.AlertTypeMockedLocation, models.AlertTypeKPIBreach,
+}
+
+// RemediationService is the runbook automation engine: rules react to active alerts by
+// changing a runtime setting, and undo the change once the alert is resolved. Every
+// change is recorded as an action that can also be reverted by hand.
+type RemediationService interface {
+	ListRules() ([]models.RemediationRule, error)
+	CreateRule(req *models.RemediationRuleRequest, userID string) (*models.RemediationRule, error)
+	UpdateRule(id string, req *models.RemediationRuleRequest) (*models.RemediationRule, error)
+	DeleteRule(id string) error
+
+	ListActions(page, size int, status string) (*models.PaginatedResponse, error)
+	Revert(id, userID string, req *models.RevertRemediationRequest) (*models.RemediationAction, error)
+
+	// Run applies and reverts the rules against the active alerts
+	Run() (*models.RemediationRunResult, error)
+	Start(interval time.Duration)
+	Stop()
+}
+
+type remediationService struct {
+	repo               repositories.RemediationRepository
+	alertRepo          repositories.AlertRepository
+	settingsService    SettingsService
+	activityLogService ActivityLogService
+
+	mu   sync.Mutex // one run at a time
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewRemediationService(
+	repo repositories.RemediationRepository,
+	alertRepo repositories.AlertRepository,
+	settingsService SettingsService,
+	activityLogService ActivityLogService,
+) RemediationService {
+	return &remediationService{
+		repo:               repo,
+		alertRepo:          alertRepo,
+		settingsService:    settingsService,
+		activityLogService: activityLogService,
+	}
+}
+
+// =============== Rules ===============
+
+func (s *remediationService) ListRules() ([]models.RemediationRule, error) {
+	return s.repo.FindRules(false)
+}
+
+func (s *remediationService) CreateRule(req *models.RemediationRuleRequest, userID string) (*models.RemediationRule, error) {
+	rule := &models.RemediationRule{RevertOnResolve: true, CooldownMinutes: 30, Enabled: true, CreatedBy: userID}
+	if err := s.applyRuleRequest(rule, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.SaveRule(rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (s *remediationService) UpdateRule(id string, req *models.RemediationRuleRequest) (*models.RemediationRule, error) {
+	rule, err := s.findRule(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.applyRuleRequest(rule, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.SaveRule(rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// DeleteRule removes the rule; a change it still has in effect stays until reverted by hand
+func (s *remediationService) DeleteRule(id string) error {
+	if _, err := s.findRule(id); err != nil {
+		return err
+	}
+	return s.repo.DeleteRule(id)
+}
+
+func (s *remediationService) findRule(id string) (*models.RemediationRule, error) {
+	rule, err := s.repo.FindRuleByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRemediationRuleNotFound
+		}
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (s *remediationService) applyRuleRequest(rule *models.RemediationRule, req *models.RemediationRuleRequest) error {
+	alertType := strings.ToUpper(strings.TrimSpace(req.AlertType))
+	known := false
+	for _, t := range remediationAlertTypes {
+		if t == alertType {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return fmt.Errorf("%w: %s", ErrRemediationAlertType, req.AlertType)
+	}
+	// Only the registered runtime settings, with a valid value, can be automated
+	value, err := s.settingsService.Normalize(req.Setting, req.Value)
+	if err != nil {
+		return err
+	}
+
+	rule.Name = strings.TrimSpace(req.Name)
+	rule.AlertType = alertType
+	rule.ResourceID = strings.TrimSpace(req.ResourceID)
+	rule.Setting = req.Setting
+	rule.Value = value
+	if req.RevertOnResolve != nil {
+		rule.RevertOnResolve = *req.RevertOnResolve
+	}
+	if req.CooldownMinutes != nil {
+		rule.CooldownMinutes = *req.CooldownMinutes
+	}
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+	return nil
+}
+
+// =============== Actions ===============
+
+func (s *remediationService) ListActions(page, size int, status string) (*models.PaginatedResponse, error) {
+	actions, total, err := s.repo.FindActions(page, size, status)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewPaginatedResponse(actions, page, size, total), nil
+}
+
+func (s *remediationService) Revert(id, userID string, req *models.RevertRemediationRequest) (*models.RemediationAction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	action, err := s.repo.FindActionByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRemediationActionNotFound
+		}
+		return nil, err
+	}
+	if action.Status != models.RemediationApplied {
+		return nil, ErrRemediationNotApplied
+	}
+
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		reason = "Revertido manualmente"
+	}
+	if err := s.revert(action, &userID, reason); err != nil {
+		return nil, err
+	}
+
+	// Otherwise the rule applies again after its cooldown while the alert is active
+	if req.DisableRule && action.Rule != nil {
+		action.Rule.Enabled = false
+		if err := s.repo.SaveRule(action.Rule); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.activityLogService != nil {
+		description := fmt.Sprintf("Reverteu a automação %s: %s voltou para %s", action.ID, action.Setting, action.PreviousValue)
+		if err := s.activityLogService.LogAction(userID, "remediation_reverted", "system_setting", action.Setting, description, "", ""); err != nil {
+			slog.Warn("Failed to audit remediation revert", "action_id", action.ID, "error", err)
+		}
+	}
+	return s.repo.FindActionByID(id)
+}
+
+// revert restores the previous value of the setting, unless it was changed by hand after
+// the action: that change wins and the action is only closed
+func (s *remediationService) revert(action *models.RemediationAction, userID *string, reason string) error {
+	current, err := s.settingsService.Get(action.Setting)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	action.RevertedAt = &now
+	action.RevertedBy = userID
+	action.RevertReason = reason
+	action.Status = models.RemediationReverted
+	if current.Value != action.NewValue {
+		action.Status = models.RemediationSuperseded
+	} else {
+		actor := ""
+		source := models.SettingSourceRemediation
+		if userID != nil {
+			actor = *userID
+			source = models.SettingSourceManual
+		}
+		if _, err := s.settingsService.Apply(action.Setting, action.PreviousValue, source, actor); err != nil {
+			return err
+		}
+	}
+	_, err = s.repo.CloseAction(action)
+	return err
+}
+
+// =============== Engine ===============
+
+func (s *remediationService) Run() (*models.RemediationRunResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := &models.RemediationRunResult{}
+	rules, err := s.repo.FindRules(true)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	activeByType := make(map[string][]models.Alert)
+	for _, rule := range rules {
+		alerts, ok := activeByType[rule.AlertType]
+		if !ok {
+			alerts, err = s.alertRepo.FindActiveByType(rule.AlertType)
+			if err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", rule.Name, err))
+				continue
+			}
+			activeByType[rule.AlertType] = alerts
+		}
+		var alert *models.Alert
+		for i := range alerts {
+			if rule.ResourceID == "" || alerts[i].ResourceID == rule.ResourceID {
+				alert = &alerts[i]
+				break
+			}
+		}
+
+		applied, err := s.repo.FindAppliedAction(rule.ID)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", rule.Name, err))
+			continue
+		}
+
+		switch {
+		case alert != nil && applied == nil:
+			ok, err := s.apply(&rule, alert, now)
+			if err != nil {
+				result.Failed++
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", rule.Name, err))
+			} else if ok {
+				result.Applied++
+			}
+		case alert == nil && applied != nil && rule.RevertOnResolve:
+			if err := s.revert(applied, nil, "Revertido automaticamente: alerta resolvido"); err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", rule.Name, err))
+				continue
+			}
+			result.Reverted++
+			slog.Info("Remediation reverted", "rule", rule.Name, "setting", applied.Setting, "previous_value", applied.PreviousValue)
+		}
+	}
+	return result, nil
+}
+
+// apply changes the setting of the rule for the alert, reporting false when there was
+// nothing to do (cooling down, or the setting already has the value)
+func (s *remediationService) apply(rule *models.RemediationRule, alert *models.Alert, now time.Time) (bool, error) {
+	last, err := s.repo.FindLastAction(rule.ID)
+	if err != nil {
+		return false, err
+	}
+	if last != nil && now.Sub(last.AppliedAt) < time.Duration(rule.CooldownMinutes)*time.Minute {
+		return false, nil
+	}
+	current, err := s.settingsService.Get(rule.Setting)
+	if err != nil {
+		return false, err
+	}
+	if current.Value == rule.Value {
+		return false, nil
+	}
+
+	action := &models.RemediationAction{
+		RuleID:        rule.ID,
+		AlertID:       alert.ID,
+		Setting:       rule.Setting,
+		PreviousValue: current.Value,
+		NewValue:      rule.Value,
+		Status:        models.RemediationApplied,
+		AppliedAt:     now,
+	}
+	previous, applyErr := s.settingsService.Apply(rule.Setting, rule.Value, models.SettingSourceRemediation, "")
+	if applyErr != nil {
+		action.Status = models.RemediationFailed
+		action.Error = applyErr.Error()
+	} else {
+		action.PreviousValue = previous
+	}
+	if err := s.repo.CreateAction(action); err != nil {
+		return false, err
+	}
+	if applyErr != nil {
+		return false, applyErr
+	}
+	slog.Info("Remediation applied", "rule", rule.Name, "alert", alert.Title, "setting", rule.Setting, "value", rule.Value)
+	return true, nil
+}
+
+// Start runs the rules periodically until Stop is called
+func (s *remediationService) Start(interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultRemediationInterval
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				result, err := s.Run()
+				if err != nil {
+					slog.Warn("Remediation run failed", "error", err)
+					continue
+				}
+				for _, e := range result.Errors {
+					slog.Warn("Remediation failed", "error", e)
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (s *remediationService) Stop() {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+		s.stop = nil
+	}
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+)
+
+// scheduledReport is a generated report: the summary goes in the e-mail body and the
+// records in the attached CSV
+type scheduledReport struct {
+	title   string
+	summary []string
+	records [][]string
+}
+
+func (r *scheduledReport) CSV() ([]byte, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.WriteAll(r.records)
+	if err := writer.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// reportPeriod returns the [start, end) window of the period at now, in the location of now
+func reportPeriod(period string, now time.Time) (time.Time, time.Time) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch period {
+	case models.ReportPeriodPreviousWeek:
+		weekday := int(today.Weekday())
+		if weekday == 0 {
+			weekday = 7
+		}
+		monday := today.AddDate(0, 0, 1-weekday)
+		return monday.AddDate(0, 0, -7), monday
+	case models.ReportPeriodPreviousMonth:
+		first := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		return first.AddDate(0, -1, 0), first
+	case models.ReportPeriodLast7Days:
+		return today.AddDate(0, 0, -7), today
+	case models.ReportPeriodLast30Days:
+		return today.AddDate(0, 0, -30), today
+	case models.ReportPeriodMonthToDate:
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()), now
+	default:
+		return today.AddDate(0, 0, -1), today
+	}
+}
+
+// financialDashboardReport lists the totals and the amounts per category of the entries
+// dated in the period
+func (s *reportScheduleService) financialDashboardReport(start, end time.Time) (*scheduledReport, error) {
+	dashboard, err := s.financial.GetDashboard(models.DashboardFilter{
+		Period:    "custom",
+		StartDate: start.Format("2006-01-02"),
+		EndDate:   end.Add(-time.Nanosecond).Format("2006-01-02"),
+		Fresh:     true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &scheduledReport{
+		title: "Painel financeiro",
+		summary: []string{
+			"Receitas: " + formatBRL(dashboard.Summary.TotalIncome),
+			"Despesas: " + formatBRL(dashboard.Summary.TotalExpense),
+			"Saldo: " + formatBRL(dashboard.Summary.Balance),
+			fmt.Sprintf("Pagamentos pendentes: %d", dashboard.PendingPayments),
+			fmt.Sprintf("Lançamentos vencidos: %d", dashboard.OverdueCount),
+		},
+		records: [][]string{
+			{"secao", "item", "valor"},
+			{"resumo", "receitas", reportAmount(dashboard.Summary.TotalIncome)},
+			{"resumo", "despesas", reportAmount(dashboard.Summary.TotalExpense)},
+			{"resumo", "saldo", reportAmount(dashboard.Summary.Balance)},
+			{"resumo", "pagamentos_pendentes", strconv.FormatInt(dashboard.PendingPayments, 10)},
+			{"resumo", "vencidos", strconv.FormatInt(dashboard.OverdueCount, 10)},
+		},
+	}
+	for _, section := range []struct {
+		name       string
+		categories map[string]float64
+	}{
+		{"receita_por_categoria", dashboard.ByCategory.Income},
+		{"despesa_por_categoria", dashboard.ByCategory.Expense},
+	} {
+		categories := make([]string, 0, len(section.categories))
+		for category := range section.categories {
+			categories = append(categories, category)
+		}
+		sort.Strings(categories)
+		for _, category := range categories {
+			report.records = append(report.records, []string{section.name, category, reportAmount(section.categories[category])})
+		}
+	}
+	return report, nil
+}
+
+// slaComplianceReport lists the SLA compliance of the tickets closed in the period, overall
+// and per priority, category and source
+func (s *reportScheduleService) slaComplianceReport(start, end time.Time) (*scheduledReport, error) {
+	compliance, err := s.sla.GetComplianceReport(start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &scheduledReport{
+		title: "Cumprimento de SLA",
+		summary: []string{
+			fmt.Sprintf("Chamados encerrados: %d", compliance.Total),
+			fmt.Sprintf("Dentro do SLA: %d (%.1f%%)", compliance.Met, compliance.CompliancePercent),
+			fmt.Sprintf("SLA estourado: %d", compliance.Breached),
+			fmt.Sprintf("Primeira resposta no prazo: %.1f%%", compliance.ResponseCompliancePercent),
+		},
+		records: [][]string{{
+			"agrupamento", "chave", "total", "no_prazo", "estourados", "cumprimento_pct",
+			"cumprimento_bruto_pct", "resposta_estourada", "cumprimento_resposta_pct",
+		}},
+	}
+	row := func(group string, r models.SLAComplianceRow) {
+		report.records = append(report.records, []string{
+			group, r.Key, strconv.Itoa(r.Total), strconv.Itoa(r.Met), strconv.Itoa(r.Breached),
+			reportAmount(r.CompliancePercent), reportAmount(r.RawCompliancePercent),
+			strconv.Itoa(r.ResponseBreached), reportAmount(r.ResponseCompliancePercent),
+		})
+	}
+	compliance.SLAComplianceRow.Key = "TOTAL"
+	row("geral", compliance.SLAComplianceRow)
+	for _, r := range compliance.ByPriority {
+		row("prioridade", r)
+	}
+	for _, r := range compliance.ByCategory {
+		row("categoria", r)
+	}
+	for _, r := range compliance.BySource {
+		row("origem", r)
+	}
+	return report, nil
+}
+
+// lowStockReport lists the current balances at or below their minimum
+func (s *reportScheduleService) lowStockReport() (*scheduledReport, error) {
+	report := &scheduledReport{
+		title: "Estoque baixo",
+		records: [][]string{{
+			"sku", "item", "unidade", "local", "tipo_local", "quantidade", "reservado", "disponivel", "minimo",
+		}},
+	}
+	depleted := 0
+	for page := 1; ; page++ {
+		balances, err := s.stock.ListBalances(models.StockBalanceFilter{
+			LowStock: true,
+			Page:     page,
+			PageSize: alertScanPageSize,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range balances.Data {
+			if b.Quantity <= 0 {
+				depleted++
+			}
+			report.records = append(report.records, []string{
+				b.ItemSKU, b.ItemName, b.ItemUnit, b.LocationName, b.LocationType, strconv.Itoa(b.Quantity),
+				strconv.Itoa(b.Reserved), strconv.Itoa(b.Available), strconv.Itoa(b.MinQty),
+			})
+		}
+		if page >= balances.TotalPages {
+			break
+		}
+	}
+	report.summary = []string{
+		fmt.Sprintf("Itens abaixo do mínimo: %d", len(report.records)-1),
+		fmt.Sprintf("Itens esgotados: %d", depleted),
+	}
+	return report, nil
+}
+
+func reportAmount(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
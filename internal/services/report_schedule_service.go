@@ -0,0 +1,426 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrReportScheduleNotFound = errors.New("report schedule not found")
+	ErrReportTimezone         = errors.New("unknown timezone")
+	ErrReportCronNeverRuns    = errors.New("the cron expression never matches a date")
+	ErrReportRunNotFound      = errors.New("report run not found")
+	ErrReportRunNoFile        = errors.New("the run has no report file")
+)
+
+const (
+	defaultReportTimezone       = "America/Sao_Paulo"
+	defaultReportScheduleCheck  = time.Minute
+	reportSchedulesPerCheck     = 50
+	reportScheduleFailureNotice = 3 // consecutive failures after which the admins are notified too
+)
+
+var reportPeriodLabels = map[string]string{
+	models.ReportPeriodPreviousDay:   "dia anterior",
+	models.ReportPeriodPreviousWeek:  "semana anterior",
+	models.ReportPeriodPreviousMonth: "mês anterior",
+	models.ReportPeriodLast7Days:     "últimos 7 dias",
+	models.ReportPeriodLast30Days:    "últimos 30 dias",
+	models.ReportPeriodMonthToDate:   "mês até a data",
+}
+
+// ReportScheduleService e-mails the scheduled reports: the report job runs the schedules
+// whose cron is due, attaches the CSV, keeps it in the file storage and records the run.
+// A run not delivered to any recipient notifies the owner of the schedule.
+type ReportScheduleService interface {
+	List(page, size int) (*models.PaginatedResponse, error)
+	Get(id string) (*models.ReportSchedule, error)
+	Create(req *models.ReportScheduleRequest, userID string) (*models.ReportSchedule, error)
+	Update(id string, req *models.ReportScheduleRequest) (*models.ReportSchedule, error)
+	Delete(id string) error
+	// RunNow generates and delivers the report right away, outside of its cron
+	RunNow(id, userID string) (*models.ReportRun, error)
+	ListRuns(id string, page, size int) (*models.PaginatedResponse, error)
+	RunDownloadURL(id, runID string) (*models.FileDownload, error)
+	// RunDue runs the schedules whose next run is due, returning how many ran
+	RunDue() (int, error)
+	Start(interval time.Duration)
+	Stop()
+}
+
+type reportScheduleService struct {
+	repo          repositories.ReportScheduleRepository
+	financial     *FinancialService
+	sla           SLAService
+	stock         StockService
+	files         FileService
+	email         MessageSender
+	notifications NotificationService
+
+	mu   sync.Mutex // one check at a time
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewReportScheduleService(
+	repo repositories.ReportScheduleRepository,
+	financial *FinancialService,
+	sla SLAService,
+	stock StockService,
+	files FileService,
+	email MessageSender,
+	notifications NotificationService,
+) ReportScheduleService {
+	return &reportScheduleService{
+		repo:          repo,
+		financial:     financial,
+		sla:           sla,
+		stock:         stock,
+		files:         files,
+		email:         email,
+		notifications: notifications,
+	}
+}
+
+func (s *reportScheduleService) List(page, size int) (*models.PaginatedResponse, error) {
+	schedules, total, err := s.repo.FindAll(page, size)
+	if err != nil {
+		return nil, err
+	}
+	return models.NewPaginatedResponse(schedules, page, size, total), nil
+}
+
+func (s *reportScheduleService) Get(id string) (*models.ReportSchedule, error) {
+	schedule, err := s.repo.FindByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrReportScheduleNotFound
+		}
+		return nil, err
+	}
+	return schedule, nil
+}
+
+func (s *reportScheduleService) Create(req *models.ReportScheduleRequest, userID string) (*models.ReportSchedule, error) {
+	schedule := &models.ReportSchedule{CreatedBy: userID}
+	if err := applyReportSchedule(schedule, req, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(schedule); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+func (s *reportScheduleService) Update(id string, req *models.ReportScheduleRequest) (*models.ReportSchedule, error) {
+	schedule, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyReportSchedule(schedule, req, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Update(schedule); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+func (s *reportScheduleService) Delete(id string) error {
+	if err := s.repo.Delete(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrReportScheduleNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// applyReportSchedule checks the cron and timezone of the request and copies it to the
+// schedule, with its next run from now
+func applyReportSchedule(schedule *models.ReportSchedule, req *models.ReportScheduleRequest, now time.Time) error {
+	timezone := req.Timezone
+	if timezone == "" {
+		timezone = defaultReportTimezone
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrReportTimezone, timezone)
+	}
+	cron, err := parseCron(req.Cron)
+	if err != nil {
+		return err
+	}
+	next, ok := cron.Next(now.In(loc))
+	if !ok {
+		return ErrReportCronNeverRuns
+	}
+	period := req.Period
+	if period == "" {
+		period = models.ReportPeriodPreviousDay
+	}
+	recipients := make([]string, 0, len(req.Recipients))
+	for _, recipient := range req.Recipients {
+		recipients = append(recipients, strings.ToLower(strings.TrimSpace(recipient)))
+	}
+
+	schedule.Name = req.Name
+	schedule.ReportType = req.ReportType
+	schedule.Cron = strings.Join(strings.Fields(req.Cron), " ")
+	schedule.Timezone = timezone
+	schedule.Period = period
+	schedule.Recipients = recipients
+	schedule.Active = req.Active == nil || *req.Active
+	schedule.NextRunAt = nil
+	if schedule.Active {
+		schedule.NextRunAt = &next
+	}
+	return nil
+}
+
+func (s *reportScheduleService) RunNow(id, userID string) (*models.ReportRun, error) {
+	schedule, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.run(schedule, models.ReportTriggerManual, userID)
+}
+
+func (s *reportScheduleService) ListRuns(id string, page, size int) (*models.PaginatedResponse, error) {
+	if _, err := s.Get(id); err != nil {
+		return nil, err
+	}
+	runs, total, err := s.repo.FindRuns(id, page, size)
+	if err != nil {
+		return nil, err
+	}
+	return models.NewPaginatedResponse(runs, page, size, total), nil
+}
+
+func (s *reportScheduleService) RunDownloadURL(id, runID string) (*models.FileDownload, error) {
+	run, err := s.repo.FindRunByID(id, runID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrReportRunNotFound
+		}
+		return nil, err
+	}
+	if run.FileID == nil {
+		return nil, ErrReportRunNoFile
+	}
+	return s.files.DownloadURL(*run.FileID)
+}
+
+func (s *reportScheduleService) RunDue() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	schedules, err := s.repo.FindDue(now, reportSchedulesPerCheck)
+	if err != nil {
+		return 0, err
+	}
+	ran := 0
+	for i := range schedules {
+		schedule := &schedules[i]
+		var next *time.Time
+		if loc, err := time.LoadLocation(schedule.Timezone); err == nil {
+			if cron, err := parseCron(schedule.Cron); err == nil {
+				if t, ok := cron.Next(now.In(loc)); ok {
+					next = &t
+				}
+			}
+		}
+		if next == nil {
+			slog.Warn("Report schedule has an invalid cron or timezone, stopped", "schedule_id", schedule.ID, "cron", schedule.Cron, "timezone", schedule.Timezone)
+		}
+
+		// Claiming moves the next run first, so a failing report is retried on its
+		// next occurrence instead of every check
+		claimed, err := s.repo.Claim(schedule.ID, *schedule.NextRunAt, next)
+		if err != nil {
+			return ran, err
+		}
+		if !claimed {
+			continue
+		}
+		if _, err := s.run(schedule, models.ReportTriggerScheduled, ""); err != nil {
+			return ran, err
+		}
+		ran++
+	}
+	return ran, nil
+}
+
+// run generates the report of the schedule, stores it and e-mails it to the recipients,
+// recording the run. Only errors of the run record itself are returned; a failed report
+// is recorded as a FAILED run.
+func (s *reportScheduleService) run(schedule *models.ReportSchedule, trigger, userID string) (*models.ReportRun, error) {
+	loc, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		loc = time.Local
+	}
+	now := time.Now().In(loc)
+	run := &models.ReportRun{
+		ScheduleID:  schedule.ID,
+		Trigger:     trigger,
+		Status:      models.ReportRunRunning,
+		TriggeredBy: userID,
+		StartedAt:   now,
+	}
+	if err := s.repo.CreateRun(run); err != nil {
+		return nil, err
+	}
+
+	var failures []string
+	report, err := s.generate(schedule, run, now)
+	if err != nil {
+		failures = append(failures, "geração: "+err.Error())
+	} else {
+		failures = s.deliver(schedule, run, report)
+	}
+
+	finished := time.Now()
+	run.FinishedAt = &finished
+	run.Error = strings.Join(failures, "; ")
+	run.Status = models.ReportRunSuccess
+	if run.Delivered == 0 {
+		run.Status = models.ReportRunFailed
+	}
+	if err := s.repo.UpdateRun(run); err != nil {
+		return nil, err
+	}
+	if err := s.repo.RecordOutcome(schedule.ID, run.Status, finished); err != nil {
+		slog.Warn("Failed to record report schedule outcome", "schedule_id", schedule.ID, "error", err)
+	}
+
+	if run.Status == models.ReportRunFailed {
+		slog.Warn("Scheduled report failed", "schedule_id", schedule.ID, "run_id", run.ID, "error", run.Error)
+		s.alertFailure(schedule, run)
+	}
+	return run, nil
+}
+
+func (s *reportScheduleService) generate(schedule *models.ReportSchedule, run *models.ReportRun, now time.Time) (*scheduledReport, error) {
+	switch schedule.ReportType {
+	case models.ReportTypeLowStock:
+		return s.lowStockReport()
+	case models.ReportTypeFinancialDashboard, models.ReportTypeSLACompliance:
+		start, end := reportPeriod(schedule.Period, now)
+		run.PeriodStart, run.PeriodEnd = &start, &end
+		if schedule.ReportType == models.ReportTypeSLACompliance {
+			return s.slaComplianceReport(start, end)
+		}
+		return s.financialDashboardReport(start, end)
+	default:
+		return nil, fmt.Errorf("unknown report type %s", schedule.ReportType)
+	}
+}
+
+// deliver stores the CSV of the report and e-mails it to each recipient, returning the
+// failures. A storage failure does not stop the e-mails.
+func (s *reportScheduleService) deliver(schedule *models.ReportSchedule, run *models.ReportRun, report *scheduledReport) []string {
+	var failures []string
+	content, err := report.CSV()
+	if err != nil {
+		return []string{"geração: " + err.Error()}
+	}
+	name := fmt.Sprintf("%s_%s.csv", strings.ToLower(schedule.ReportType), run.StartedAt.Format("20060102_1504"))
+	if file, err := s.files.Store(models.FileOwnerReportRun, run.ID, name, "text/csv", content, schedule.CreatedBy); err != nil {
+		failures = append(failures, "armazenamento: "+err.Error())
+	} else {
+		run.FileID = &file.ID
+	}
+
+	subject := fmt.Sprintf("%s: %s", report.title, schedule.Name)
+	var body strings.Builder
+	fmt.Fprintf(&body, "%s\n\n", schedule.Name)
+	if run.PeriodStart != nil {
+		fmt.Fprintf(&body, "Período: %s a %s (%s)\n\n", run.PeriodStart.Format("02/01/2006"),
+			run.PeriodEnd.Add(-time.Nanosecond).Format("02/01/2006"), reportPeriodLabels[schedule.Period])
+	}
+	for _, line := range report.summary {
+		body.WriteString(line + "\n")
+	}
+	body.WriteString("\nO relatório completo segue em anexo.\n")
+	attachments := []MessageAttachment{{Name: name, ContentType: "text/csv", Content: content}}
+
+	for _, recipient := range schedule.Recipients {
+		var err error
+		if sender, ok := s.email.(AttachmentSender); ok {
+			err = sender.SendWithAttachments(recipient, subject, body.String(), attachments)
+		} else {
+			err = s.email.Send(recipient, subject, body.String())
+		}
+		if err != nil {
+			failures = append(failures, recipient+": "+err.Error())
+			continue
+		}
+		run.Delivered++
+	}
+	return failures
+}
+
+// alertFailure notifies the owner of the schedule of a failed run, and the admins as well
+// once it keeps failing
+func (s *reportScheduleService) alertFailure(schedule *models.ReportSchedule, run *models.ReportRun) {
+	if s.notifications == nil {
+		return
+	}
+	notification := models.Notification{
+		Event:        models.NotificationReportFailed,
+		Title:        fmt.Sprintf("Relatório agendado %q não foi enviado", schedule.Name),
+		Message:      run.Error,
+		ResourceType: "REPORT_SCHEDULE",
+		ResourceID:   schedule.ID,
+	}
+	s.notifications.Notify([]string{schedule.CreatedBy}, notification)
+	if schedule.ConsecutiveFailures+1 == reportScheduleFailureNotice {
+		s.notifications.NotifyRole("ADMIN", notification)
+	}
+}
+
+// Start runs the due schedules periodically in the background until Stop is called
+func (s *reportScheduleService) Start(interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultReportScheduleCheck
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				ran, err := s.RunDue()
+				if err != nil {
+					slog.Warn("Scheduled reports check failed", "error", err)
+				}
+				if ran > 0 {
+					slog.Info("Scheduled reports ran", "reports", ran)
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (s *reportScheduleService) Stop() {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+		s.stop = nil
+	}
+}
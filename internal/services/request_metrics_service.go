@@ -0,0 +1,153 @@
+package services
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+)
+
+const (
+	requestMetricsBuffer    = 10000
+	requestMetricsBatchSize = 500
+	// Window used to compute the p95 against the latency budgets
+	latencyBudgetWindow = time.Hour
+	// Window used to report payload sizes per route
+	payloadSizeWindow = 24 * time.Hour
+	// Raw request metrics are kept for a week
+	requestMetricsRetention = 7 * 24 * time.Hour
+)
+
+type RequestMetricsService interface {
+	// Record queues a request metric; it never blocks the request and drops the metric when the buffer is full
+	Record(metric models.RequestMetric)
+	GetLatencyBudgets() ([]models.LatencyBudgetStatus, error)
+	GetPayloadSizes() ([]models.RoutePayload, error)
+	Start(flushInterval time.Duration)
+	Stop()
+}
+
+type requestMetricsService struct {
+	repo    repositories.RequestMetricRepository
+	budgets []models.LatencyBudget
+	queue   chan models.RequestMetric
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func NewRequestMetricsService(repo repositories.RequestMetricRepository) RequestMetricsService {
+	return &requestMetricsService{
+		repo:    repo,
+		budgets: models.DefaultLatencyBudgets,
+		queue:   make(chan models.RequestMetric, requestMetricsBuffer),
+	}
+}
+
+func (s *requestMetricsService) Record(metric models.RequestMetric) {
+	if metric.ID == "" {
+		metric.ID = uuid.New().String()
+	}
+	if metric.CreatedAt.IsZero() {
+		metric.CreatedAt = time.Now()
+	}
+	select {
+	case s.queue <- metric:
+	default:
+	}
+}
+
+func (s *requestMetricsService) GetLatencyBudgets() ([]models.LatencyBudgetStatus, error) {
+	routes, err := s.repo.LatencyByRoute(time.Now().Add(-latencyBudgetWindow))
+	if err != nil {
+		return nil, err
+	}
+
+	observed := make(map[string]models.RouteLatency, len(routes))
+	for _, route := range routes {
+		observed[route.Method+" "+route.Path] = route
+	}
+
+	statuses := make([]models.LatencyBudgetStatus, 0, len(s.budgets))
+	for _, budget := range s.budgets {
+		status := models.LatencyBudgetStatus{
+			Method:   budget.Method,
+			Path:     budget.Path,
+			BudgetMs: budget.P95Ms,
+		}
+		if route, ok := observed[budget.Method+" "+budget.Path]; ok {
+			status.P95Ms = route.P95Ms
+			status.Samples = route.Samples
+			status.Exceeded = route.P95Ms > budget.P95Ms
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func (s *requestMetricsService) GetPayloadSizes() ([]models.RoutePayload, error) {
+	return s.repo.PayloadByRoute(time.Now().Add(-payloadSizeWindow))
+}
+
+// Start flushes queued metrics in batches and prunes old rows
+func (s *requestMetricsService) Start(flushInterval time.Duration) {
+	if s.stop != nil {
+		return
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+
+		flush := time.NewTicker(flushInterval)
+		defer flush.Stop()
+		prune := time.NewTicker(time.Hour)
+		defer prune.Stop()
+
+		batch := make([]models.RequestMetric, 0, requestMetricsBatchSize)
+		write := func() {
+			if err := s.repo.CreateBatch(batch); err != nil {
+				slog.Warn("Failed to store request metrics", "error", err)
+			}
+			batch = batch[:0]
+		}
+
+		for {
+			select {
+			case metric := <-s.queue:
+				batch = append(batch, metric)
+				if len(batch) >= requestMetricsBatchSize {
+					write()
+				}
+			case <-flush.C:
+				write()
+			case <-prune.C:
+				if _, err := s.repo.DeleteBefore(time.Now().Add(-requestMetricsRetention)); err != nil {
+					slog.Warn("Failed to prune request metrics", "error", err)
+				}
+			case <-s.stop:
+				// Drain what is already queued before exiting
+				for {
+					select {
+					case metric := <-s.queue:
+						batch = append(batch, metric)
+					default:
+						write()
+						return
+					}
+				}
+			}
+		}
+	}()
+}
+
+func (s *requestMetricsService) Stop() {
+	if s.stop == nil {
+		return
+	}
+	close(s.stop)
+	<-s.done
+	s.stop = nil
+}
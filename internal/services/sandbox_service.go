@@ -0,0 +1,261 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSandboxNotFound = errors.New("sandbox not found")
+	ErrSandboxExpired  = errors.New("sandbox has already expired")
+)
+
+const (
+	defaultSandboxTTL             = 72 * time.Hour
+	defaultSandboxCleanupInterval = 15 * time.Minute
+)
+
+// SandboxService provisions throwaway tenants where integration partners can exercise the
+// API against demo data. Each sandbox gets its own tenant and top-level node with demo
+// clients and tickets, and a USER account of that tenant linked to a demo technician and
+// member of the node only, so the partner never sees the production data. Expired
+// sandboxes are cleaned up by the background job.
+type SandboxService interface {
+	// Create provisions the sandbox; the returned password is not stored and cannot be shown again
+	Create(req *models.CreateSandboxRequest, adminID string) (*models.SandboxCredentials, error)
+	List(status string) ([]models.SandboxTenant, error)
+	Get(id string) (*models.SandboxTenant, error)
+	// Delete cleans up the sandbox now instead of waiting for its expiry
+	Delete(id, adminID string) (*models.SandboxTenant, error)
+
+	// ProcessExpired cleans up the sandboxes whose expiry passed
+	ProcessExpired() (int, error)
+	Start(interval time.Duration)
+	Stop()
+}
+
+type sandboxService struct {
+	repo               repositories.SandboxRepository
+	activityLogService ActivityLogService
+	ttl                time.Duration
+	stop               chan struct{}
+	done               chan struct{}
+}
+
+func NewSandboxService(
+	repo repositories.SandboxRepository,
+	activityLogService ActivityLogService,
+	ttl time.Duration,
+) SandboxService {
+	if ttl <= 0 {
+		ttl = defaultSandboxTTL
+	}
+	return &sandboxService{
+		repo:               repo,
+		activityLogService: activityLogService,
+		ttl:                ttl,
+	}
+}
+
+func (s *sandboxService) Create(req *models.CreateSandboxRequest, adminID string) (*models.SandboxCredentials, error) {
+	secret := make([]byte, 12)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	password := hex.EncodeToString(secret)
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := s.ttl
+	if req.TTLHours > 0 {
+		ttl = time.Duration(req.TTLHours) * time.Hour
+	}
+	tag := secret[:4]
+	email := fmt.Sprintf("sandbox-%s@sandbox.techiq.local", hex.EncodeToString(tag))
+
+	sandbox := &models.SandboxTenant{
+		Name:      req.Name,
+		Status:    models.SandboxStatusActive,
+		Email:     email,
+		ExpiresAt: time.Now().Add(ttl),
+		CreatedBy: adminID,
+	}
+	seed := sandboxSeed(req.Name, email, string(hash))
+	seed.Tenant.Slug = "sandbox-" + hex.EncodeToString(tag)
+	if err := s.repo.Provision(sandbox, seed); err != nil {
+		return nil, err
+	}
+
+	s.logAction(adminID, "CREATE", sandbox.ID,
+		fmt.Sprintf("Sandbox %q created for %s, expiring %s", sandbox.Name, email, sandbox.ExpiresAt.Format(time.RFC3339)))
+	return &models.SandboxCredentials{Sandbox: sandbox, Email: email, Password: password}, nil
+}
+
+func (s *sandboxService) List(status string) ([]models.SandboxTenant, error) {
+	return s.repo.FindAll(status)
+}
+
+func (s *sandboxService) Get(id string) (*models.SandboxTenant, error) {
+	sandbox, err := s.repo.FindByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSandboxNotFound
+		}
+		return nil, err
+	}
+	return sandbox, nil
+}
+
+func (s *sandboxService) Delete(id, adminID string) (*models.SandboxTenant, error) {
+	sandbox, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if sandbox.Status != models.SandboxStatusActive {
+		return nil, ErrSandboxExpired
+	}
+	if err := s.cleanup(sandbox); err != nil {
+		return nil, err
+	}
+	s.logAction(adminID, "DELETE", sandbox.ID, fmt.Sprintf("Sandbox %q removed before its expiry", sandbox.Name))
+	return sandbox, nil
+}
+
+func (s *sandboxService) ProcessExpired() (int, error) {
+	sandboxes, err := s.repo.FindExpired(time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	processed := 0
+	for i := range sandboxes {
+		sandbox, err := s.Get(sandboxes[i].ID)
+		if err == nil {
+			err = s.cleanup(sandbox)
+		}
+		if err != nil {
+			slog.Warn("Sandbox cleanup failed", "sandbox_id", sandboxes[i].ID, "error", err)
+			continue
+		}
+		s.logAction("", "EXPIRE", sandbox.ID, fmt.Sprintf("Sandbox %q expired and was cleaned up", sandbox.Name))
+		processed++
+	}
+	return processed, nil
+}
+
+func (s *sandboxService) Start(interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultSandboxCleanupInterval
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				processed, err := s.ProcessExpired()
+				if err != nil {
+					slog.Warn("Sandbox cleanup failed", "error", err)
+					continue
+				}
+				if processed > 0 {
+					slog.Info("Cleaned up expired sandboxes", "processed", processed)
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (s *sandboxService) Stop() {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+	}
+}
+
+func (s *sandboxService) cleanup(sandbox *models.SandboxTenant) error {
+	now := time.Now()
+	// The email is freed so the partner address can't sign in again
+	disabledEmail := fmt.Sprintf("sandbox-%s@sandbox.invalid", sandbox.ID)
+	if err := s.repo.Cleanup(sandbox, disabledEmail, now); err != nil {
+		return err
+	}
+	sandbox.Status = models.SandboxStatusExpired
+	sandbox.CleanedAt = &now
+	return nil
+}
+
+func (s *sandboxService) logAction(userID, action, sandboxID, description string) {
+	if err := s.activityLogService.LogAction(userID, action, "sandbox", sandboxID, description, "", ""); err != nil {
+		slog.Warn("Failed to log sandbox", "sandbox_id", sandboxID, "error", err)
+	}
+}
+
+// sandboxSeed builds the partner account and the demo data of a sandbox
+func sandboxSeed(name, email, passwordHash string) *models.SandboxSeed {
+	seed := &models.SandboxSeed{
+		Tenant: &models.Tenant{Name: "Sandbox " + name, Active: true},
+		User: &models.User{
+			Email:     email,
+			Password:  passwordHash,
+			FirstName: "Sandbox",
+			LastName:  name,
+			FullName:  "Sandbox " + name,
+			Role:      "USER",
+			Active:    true,
+		},
+		Technician: &models.Technician{
+			FullName: "Técnico Demo (" + name + ")",
+			Status:   "ATIVO",
+			Type:     "PARCERIA",
+			City:     "São Paulo",
+			State:    "SP",
+		},
+	}
+
+	cities := []struct{ City, State string }{{"São Paulo", "SP"}, {"Campinas", "SP"}, {"Rio de Janeiro", "RJ"}}
+	for i, place := range cities {
+		seed.Clients = append(seed.Clients, models.Client{
+			FullName: fmt.Sprintf("Cliente Demo %d", i+1),
+			Email:    fmt.Sprintf("cliente%d@demo.invalid", i+1),
+			City:     place.City,
+			State:    place.State,
+		})
+	}
+
+	statuses := []models.TicketStatus{
+		models.TicketStatusOpen, models.TicketStatusOpen, models.TicketStatusInProgress,
+		models.TicketStatusInProgress, models.TicketStatusClosed,
+	}
+	now := time.Now()
+	for i, status := range statuses {
+		due := now.Add(time.Duration(i+1) * 24 * time.Hour)
+		ticket := models.Ticket{
+			Status:           status,
+			ErrorDescription: fmt.Sprintf("Chamado de demonstração %d", i+1),
+			DueDate:          &due,
+		}
+		if status == models.TicketStatusClosed {
+			ticket.ClosedAt = &now
+		}
+		seed.Tickets = append(seed.Tickets, ticket)
+	}
+	return seed
+}
@@ -0,0 +1,493 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"gorm.io/gorm"
+)
+
+const (
+	satisfactionCSATQuestion = "De 1 a 5, como você avalia o atendimento recebido neste chamado?"
+	satisfactionBatchSize    = 200
+	// Ratings at or below this notify the admins
+	satisfactionLowRating = 2
+)
+
+var (
+	ErrSatisfactionSurveyNotFound  = errors.New("satisfaction survey not found")
+	ErrSatisfactionSurveyExpired   = errors.New("satisfaction survey expired")
+	ErrSatisfactionAlreadyAnswered = errors.New("satisfaction survey was already answered")
+	ErrSatisfactionTicketNotClosed = errors.New("only closed tickets can be surveyed")
+	ErrSatisfactionInvalidGroupBy  = errors.New("invalid groupBy, expected month or quarter")
+	ErrSatisfactionInvalidPeriod   = errors.New("invalid period, from must be before to")
+)
+
+// SatisfactionConfig configures the surveys sent after tickets are closed
+type SatisfactionConfig struct {
+	SurveyURL     string        // public survey page; {token} is replaced
+	Lookback      time.Duration // tickets closed longer ago are never surveyed
+	ReminderAfter time.Duration // time without an answer before each reminder
+	MaxReminders  int
+	ExpiresAfter  time.Duration
+}
+
+// SatisfactionService surveys the clients after their tickets are closed (CSAT and NPS),
+// reminds them while unanswered and reports the scores by technician, category and period
+type SatisfactionService interface {
+	// GetTicketSurvey returns the survey of a ticket with its link
+	GetTicketSurvey(ticketID string) (*models.TicketFeedbackResponse, error)
+	// CreateTicketSurvey returns the survey of a closed ticket, creating a link-only one
+	// when the scheduler hasn't surveyed it yet
+	CreateTicketSurvey(ticketID string) (*models.TicketFeedbackResponse, error)
+
+	GetSurvey(token string) (*models.SatisfactionSurveyView, error)
+	Answer(token string, req *models.SatisfactionAnswerRequest) error
+
+	GetReport(from, to time.Time, groupBy string) (*models.SatisfactionReport, error)
+
+	// ProcessSurveys creates the surveys of newly closed tickets, e-mails them and sends
+	// the reminders that are due
+	ProcessSurveys() (*models.SatisfactionRunResult, error)
+	Start(interval time.Duration)
+	Stop()
+}
+
+type satisfactionService struct {
+	repo          repositories.SatisfactionRepository
+	ticketRepo    repositories.TicketRepository
+	notifications NotificationService
+	sender        MessageSender
+	config        SatisfactionConfig
+	stop          chan struct{}
+	done          chan struct{}
+}
+
+func NewSatisfactionService(
+	repo repositories.SatisfactionRepository,
+	ticketRepo repositories.TicketRepository,
+	notifications NotificationService,
+	sender MessageSender,
+	config SatisfactionConfig,
+) SatisfactionService {
+	if config.Lookback <= 0 {
+		config.Lookback = 7 * 24 * time.Hour
+	}
+	if config.ReminderAfter <= 0 {
+		config.ReminderAfter = 72 * time.Hour
+	}
+	if config.MaxReminders < 0 {
+		config.MaxReminders = 0
+	}
+	if config.ExpiresAfter <= 0 {
+		config.ExpiresAfter = 30 * 24 * time.Hour
+	}
+	return &satisfactionService{
+		repo:          repo,
+		ticketRepo:    ticketRepo,
+		notifications: notifications,
+		sender:        sender,
+		config:        config,
+	}
+}
+
+// =============== Surveys ===============
+
+func (s *satisfactionService) GetTicketSurvey(ticketID string) (*models.TicketFeedbackResponse, error) {
+	feedback, err := s.repo.FindByTicket(ticketID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSatisfactionSurveyNotFound
+		}
+		return nil, err
+	}
+	return s.surveyResponse(feedback), nil
+}
+
+func (s *satisfactionService) CreateTicketSurvey(ticketID string) (*models.TicketFeedbackResponse, error) {
+	ticket, err := s.ticketRepo.FindByID(ticketID)
+	if err != nil {
+		return nil, err
+	}
+	if ticket.Status != models.TicketStatusClosed {
+		return nil, ErrSatisfactionTicketNotClosed
+	}
+
+	feedback, err := s.repo.FindByTicket(ticket.ID)
+	if err == nil {
+		return s.surveyResponse(feedback), nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	feedback, err = s.newSurvey(ticket, models.NPSChannelLink)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(feedback); err != nil {
+		return nil, err
+	}
+	return s.surveyResponse(feedback), nil
+}
+
+func (s *satisfactionService) GetSurvey(token string) (*models.SatisfactionSurveyView, error) {
+	feedback, err := s.findSurvey(token)
+	if err != nil {
+		return nil, err
+	}
+
+	view := &models.SatisfactionSurveyView{
+		CSATQuestion: satisfactionCSATQuestion,
+		NPSQuestion:  npsDefaultQuestion,
+		Answered:     feedback.RespondedAt != nil,
+		Expired:      time.Now().After(feedback.ExpiresAt),
+		CSATScore:    feedback.CSATScore,
+		NPSScore:     feedback.NPSScore,
+		RespondedAt:  feedback.RespondedAt,
+	}
+	if ticket := feedback.Ticket; ticket != nil {
+		view.OSNumber = ticket.OSNumber
+		view.ClosedAt = ticket.ClosedAt
+		if ticket.Client != nil {
+			view.ClientName = ticket.Client.FullName
+		}
+		if lead := leadAssignment(ticket.Assignments); lead != nil && lead.Technician != nil {
+			view.TechnicianName = firstName(lead.Technician.FullName)
+		}
+	}
+	return view, nil
+}
+
+func (s *satisfactionService) Answer(token string, req *models.SatisfactionAnswerRequest) error {
+	feedback, err := s.findSurvey(token)
+	if err != nil {
+		return err
+	}
+	if feedback.RespondedAt != nil {
+		return ErrSatisfactionAlreadyAnswered
+	}
+	now := time.Now()
+	if now.After(feedback.ExpiresAt) {
+		return ErrSatisfactionSurveyExpired
+	}
+
+	csat := *req.CSATScore
+	feedback.CSATScore = &csat
+	if req.NPSScore != nil {
+		nps := *req.NPSScore
+		feedback.NPSScore = &nps
+	}
+	feedback.Comment = strings.TrimSpace(req.Comment)
+	feedback.RespondedAt = &now
+	if err := s.repo.Update(feedback); err != nil {
+		return err
+	}
+
+	if csat <= satisfactionLowRating && s.notifications != nil && feedback.Ticket != nil {
+		message := fmt.Sprintf("O cliente avaliou o atendimento com nota %d de 5.", csat)
+		if feedback.Comment != "" {
+			message += " Comentário: " + feedback.Comment
+		}
+		go s.notifications.NotifyRole("ADMIN", models.Notification{
+			Event:        models.NotificationLowSatisfaction,
+			Title:        fmt.Sprintf("Avaliação baixa no chamado %s", feedback.Ticket.OSNumber),
+			Message:      message,
+			ResourceType: "ticket",
+			ResourceID:   feedback.TicketID,
+		})
+	}
+	return nil
+}
+
+func (s *satisfactionService) findSurvey(token string) (*models.TicketFeedback, error) {
+	feedback, err := s.repo.FindByToken(token)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSatisfactionSurveyNotFound
+		}
+		return nil, err
+	}
+	return feedback, nil
+}
+
+// newSurvey builds the survey of a ticket, attributed to its lead technician and category
+func (s *satisfactionService) newSurvey(ticket *models.Ticket, channel string) (*models.TicketFeedback, error) {
+	token, err := generateSchedulingToken()
+	if err != nil {
+		return nil, err
+	}
+	feedback := &models.TicketFeedback{
+		TicketID:   ticket.ID,
+		ClientID:   ticket.ClientID,
+		CategoryID: ticket.CategoryID,
+		Token:      token,
+		Channel:    channel,
+		ExpiresAt:  time.Now().Add(s.config.ExpiresAfter),
+	}
+	if lead := leadAssignment(ticket.Assignments); lead != nil {
+		technicianID := lead.TechnicianID
+		feedback.TechnicianID = &technicianID
+	}
+	return feedback, nil
+}
+
+func (s *satisfactionService) surveyResponse(feedback *models.TicketFeedback) *models.TicketFeedbackResponse {
+	return &models.TicketFeedbackResponse{
+		TicketFeedback: *feedback,
+		Link:           s.surveyLink(feedback.Token),
+	}
+}
+
+func (s *satisfactionService) surveyLink(token string) string {
+	return strings.ReplaceAll(s.config.SurveyURL, "{token}", token)
+}
+
+// =============== Scheduler ===============
+
+func (s *satisfactionService) ProcessSurveys() (*models.SatisfactionRunResult, error) {
+	result := &models.SatisfactionRunResult{}
+	now := time.Now()
+
+	tickets, err := s.repo.FindClosedWithoutSurvey(now.Add(-s.config.Lookback), satisfactionBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	for i := range tickets {
+		ticket := &tickets[i]
+		channel := models.NPSChannelLink
+		if s.sender != nil && ticket.Client != nil && ticket.Client.Email != "" {
+			channel = models.NPSChannelEmail
+		}
+		feedback, err := s.newSurvey(ticket, channel)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.repo.Create(feedback); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("ticket %s: %v", ticket.OSNumber, err))
+			continue
+		}
+		result.Created++
+		if channel != models.NPSChannelEmail {
+			continue
+		}
+
+		feedback.SentTo = ticket.Client.Email
+		subject := fmt.Sprintf("Como foi o atendimento do chamado %s?", ticket.OSNumber)
+		body := fmt.Sprintf("Olá, %s!\n\nO chamado %s foi concluído. Conte para nós como foi o atendimento, leva menos de um minuto:\n%s\n\nSua opinião nos ajuda a melhorar nosso atendimento.",
+			ticket.Client.FullName, ticket.OSNumber, s.surveyLink(feedback.Token))
+		if err := s.sender.Send(feedback.SentTo, subject, body); err != nil {
+			feedback.SendError = err.Error()
+			result.Errors = append(result.Errors, fmt.Sprintf("ticket %s: %v", ticket.OSNumber, err))
+		} else {
+			sentAt := time.Now()
+			feedback.SentAt = &sentAt
+			result.Sent++
+		}
+		if err := s.repo.Update(feedback); err != nil {
+			slog.Warn("Failed to save satisfaction survey", "ticket_id", ticket.ID, "error", err)
+		}
+	}
+
+	if s.sender == nil || s.config.MaxReminders == 0 {
+		return result, nil
+	}
+	due, err := s.repo.FindDueReminders(now.Add(-s.config.ReminderAfter), s.config.MaxReminders, satisfactionBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	for i := range due {
+		feedback := &due[i]
+		osNumber := ""
+		if feedback.Ticket != nil {
+			osNumber = feedback.Ticket.OSNumber
+		}
+
+		// Counted even when the send fails, so a bad address isn't retried forever
+		remindedAt := time.Now()
+		feedback.Reminders++
+		feedback.LastReminderAt = &remindedAt
+		subject := fmt.Sprintf("Lembrete: avalie o atendimento do chamado %s", osNumber)
+		body := fmt.Sprintf("Olá!\n\nAinda dá tempo de avaliar o atendimento do chamado %s:\n%s\n\nSua opinião nos ajuda a melhorar nosso atendimento.",
+			osNumber, s.surveyLink(feedback.Token))
+		if err := s.sender.Send(feedback.SentTo, subject, body); err != nil {
+			feedback.SendError = err.Error()
+			result.Errors = append(result.Errors, fmt.Sprintf("ticket %s reminder: %v", osNumber, err))
+		} else {
+			feedback.SendError = ""
+			result.Reminded++
+		}
+		if err := s.repo.Update(feedback); err != nil {
+			slog.Warn("Failed to save satisfaction survey", "ticket_id", feedback.TicketID, "error", err)
+		}
+	}
+	return result, nil
+}
+
+// Start surveys the closed tickets and sends the reminders periodically until Stop is called
+func (s *satisfactionService) Start(interval time.Duration) {
+	if interval <= 0 {
+		interval = 15 * time.Minute
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				result, err := s.ProcessSurveys()
+				if err != nil {
+					slog.Warn("Satisfaction surveys failed", "error", err)
+					continue
+				}
+				for _, e := range result.Errors {
+					slog.Warn("Satisfaction survey not delivered", "error", e)
+				}
+				if result.Created > 0 || result.Reminded > 0 {
+					slog.Info("Satisfaction surveys processed", "created", result.Created, "sent", result.Sent, "reminded", result.Reminded)
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (s *satisfactionService) Stop() {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+		s.stop = nil
+	}
+}
+
+// =============== Reports ===============
+
+func (s *satisfactionService) GetReport(from, to time.Time, groupBy string) (*models.SatisfactionReport, error) {
+	if groupBy == "" {
+		groupBy = NPSGroupByMonth
+	}
+	if groupBy != NPSGroupByMonth && groupBy != NPSGroupByQuarter {
+		return nil, ErrSatisfactionInvalidGroupBy
+	}
+	if !from.Before(to) {
+		return nil, ErrSatisfactionInvalidPeriod
+	}
+
+	responses, err := s.repo.FindResponses(from, to)
+	if err != nil {
+		return nil, err
+	}
+	surveys, err := s.repo.CountSurveys(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.SatisfactionReport{
+		From:    from,
+		To:      to,
+		Surveys: surveys,
+		Overall: computeSatisfaction(responses),
+		ByTechnician: satisfactionByGroup(responses, func(r models.SatisfactionResponse) (string, string) {
+			if r.TechnicianID == "" {
+				return "", "Sem técnico"
+			}
+			return r.TechnicianID, r.TechnicianName
+		}),
+		ByCategory: satisfactionByGroup(responses, func(r models.SatisfactionResponse) (string, string) {
+			if r.CategoryID == "" {
+				return "", "Sem categoria"
+			}
+			return r.CategoryID, r.CategoryName
+		}),
+		ByPeriod: satisfactionByPeriod(responses, from, to, groupBy),
+	}
+	if surveys > 0 {
+		report.ResponseRate = round1(float64(len(responses)) * 100 / float64(surveys))
+	}
+	return report, nil
+}
+
+// computeSatisfaction returns the average rating and the CSAT (% of ratings 4-5), plus the
+// NPS of the answers to the optional question
+func computeSatisfaction(responses []models.SatisfactionResponse) models.SatisfactionScore {
+	var score models.SatisfactionScore
+	var total int
+	var nps []models.NPSResponse
+	for _, response := range responses {
+		if response.CSATScore < 1 || response.CSATScore > 5 {
+			continue
+		}
+		score.Responses++
+		score.Distribution[response.CSATScore-1]++
+		total += response.CSATScore
+		if response.CSATScore >= 4 {
+			score.Satisfied++
+		}
+		if response.NPSScore != nil {
+			nps = append(nps, models.NPSResponse{Score: *response.NPSScore, RespondedAt: response.RespondedAt})
+		}
+	}
+	if score.Responses > 0 {
+		score.AverageRating = math.Round(float64(total)*100/float64(score.Responses)) / 100
+		score.CSAT = round1(float64(score.Satisfied) * 100 / float64(score.Responses))
+	}
+	score.NPS = computeNPS(nps)
+	return score
+}
+
+func satisfactionByGroup(responses []models.SatisfactionResponse, key func(models.SatisfactionResponse) (string, string)) []models.SatisfactionGroupScore {
+	byKey := make(map[string][]models.SatisfactionResponse)
+	names := make(map[string]string)
+	for _, response := range responses {
+		id, name := key(response)
+		byKey[id] = append(byKey[id], response)
+		names[id] = name
+	}
+
+	groups := make([]models.SatisfactionGroupScore, 0, len(byKey))
+	for id, groupResponses := range byKey {
+		groups = append(groups, models.SatisfactionGroupScore{ID: id, Name: names[id], SatisfactionScore: computeSatisfaction(groupResponses)})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Responses != groups[j].Responses {
+			return groups[i].Responses > groups[j].Responses
+		}
+		return groups[i].Name < groups[j].Name
+	})
+	return groups
+}
+
+func satisfactionByPeriod(responses []models.SatisfactionResponse, from, to time.Time, groupBy string) []models.SatisfactionPeriodScore {
+	byPeriod := make(map[string][]models.SatisfactionResponse)
+	for _, response := range responses {
+		key := npsPeriodKey(response.RespondedAt.In(from.Location()), groupBy)
+		byPeriod[key] = append(byPeriod[key], response)
+	}
+
+	step := 1
+	cursor := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, from.Location())
+	if groupBy == NPSGroupByQuarter {
+		step = 3
+		cursor = time.Date(from.Year(), time.Month((int(from.Month())-1)/3*3+1), 1, 0, 0, 0, 0, from.Location())
+	}
+
+	var periods []models.SatisfactionPeriodScore
+	for cursor.Before(to) {
+		key := npsPeriodKey(cursor, groupBy)
+		periods = append(periods, models.SatisfactionPeriodScore{Period: key, SatisfactionScore: computeSatisfaction(byPeriod[key])})
+		cursor = cursor.AddDate(0, step, 0)
+	}
+	return periods
+}
@@ -0,0 +1,788 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSchedulingLinkNotFound     = errors.New("scheduling link not found")
+	ErrSchedulingLinkExpired      = errors.New("scheduling link expired or already used")
+	ErrSlotUnavailable            = errors.New("selected slot is no longer available")
+	ErrTicketNotSchedulable       = errors.New("ticket cannot be scheduled in its current status")
+	ErrInvalidSlotStart           = errors.New("invalid slot start, expected RFC3339 timestamp")
+	ErrInvalidEnforcement         = errors.New("invalid enforcement, expected REJECT or WARN")
+	ErrCalendarFeedNotFound       = errors.New("calendar feed not found")
+	ErrCalendarAccessDenied       = errors.New("only office users or the technician can manage this calendar feed")
+	ErrInvalidCalendarRange       = errors.New("invalid range, expected from before to and at most 92 days apart")
+	ErrCalendarTechnicianRequired = errors.New("technicianId is required")
+)
+
+// ScheduleConflictError is returned when an appointment cannot be reached in
+// time from (or to) the technician's neighbouring appointments
+type ScheduleConflictError struct {
+	Conflicts []models.ScheduleConflict
+	CanForce  bool
+}
+
+func (e *ScheduleConflictError) Error() string {
+	return fmt.Sprintf("appointment conflicts with %d existing appointment(s)", len(e.Conflicts))
+}
+
+// Scheduling defaults
+const (
+	workdayStartHour         = 8
+	workdayEndHour           = 18
+	defaultSlotMinutes       = 120
+	defaultSlotSearchDays    = 7
+	maxSlotSearchDays        = 30
+	maxTechniciansPerSlot    = 3
+	maxServiceRadiusKm       = 150.0
+	defaultLinkExpirationHrs = 72
+	maxCalendarRangeDays     = 92
+)
+
+type SchedulingService interface {
+	CreateLink(ticketID, userID string, req *models.CreateSchedulingLinkRequest) (*models.SchedulingLinkResponse, error)
+	GetSlotsForTicket(ticketID string, query models.SlotQuery) (*models.AvailableSlotsResponse, error)
+	GetSlotsByToken(token string, query models.SlotQuery) (*models.AvailableSlotsResponse, error)
+	ConfirmForTicket(ticketID string, req *models.ConfirmAppointmentRequest) (*models.AppointmentConfirmation, error)
+	ConfirmByToken(token string, req *models.ConfirmAppointmentRequest) (*models.AppointmentConfirmation, error)
+
+	// ICS feed per technician
+	GetCalendarToken(technicianID, userID, role string) (*models.CalendarFeedResponse, error)
+	RotateCalendarToken(technicianID, userID, role string) (*models.CalendarFeedResponse, error)
+	GetCalendarFeed(technicianID, token string) ([]byte, error)
+
+	// Calendar view: every technician for office users, their own agenda for technicians
+	GetCalendar(query *models.CalendarQuery, userID, role string) (*models.TicketCalendar, error)
+	// ExportCalendar renders the agenda of one technician over the range as ICS
+	ExportCalendar(query *models.CalendarQuery, userID, role string) ([]byte, error)
+
+	// Travel-time settings
+	GetSettings() ([]models.SchedulingSettings, error)
+	UpdateSettings(req *models.UpdateSchedulingSettingsRequest) (*models.SchedulingSettings, error)
+	// WithContext returns the service running its queries with ctx, inside the tenant it carries
+	WithContext(ctx context.Context) SchedulingService
+}
+
+type schedulingService struct {
+	repo               repositories.SchedulingRepository
+	ticketRepo         repositories.TicketRepository
+	technicianRepo     repositories.TechnicianRepository
+	activityLogService ActivityLogService
+}
+
+func NewSchedulingService(
+	repo repositories.SchedulingRepository,
+	ticketRepo repositories.TicketRepository,
+	technicianRepo repositories.TechnicianRepository,
+	activityLogService ActivityLogService,
+) SchedulingService {
+	return &schedulingService{
+		repo:               repo,
+		ticketRepo:         ticketRepo,
+		technicianRepo:     technicianRepo,
+		activityLogService: activityLogService,
+	}
+}
+
+func (s *schedulingService) WithContext(ctx context.Context) SchedulingService {
+	scoped := *s
+	scoped.ticketRepo = s.ticketRepo.WithContext(ctx)
+	scoped.technicianRepo = s.technicianRepo.WithContext(ctx)
+	return &scoped
+}
+
+func (s *schedulingService) CreateLink(ticketID, userID string, req *models.CreateSchedulingLinkRequest) (*models.SchedulingLinkResponse, error) {
+	ticket, err := s.ticketRepo.FindByID(ticketID)
+	if err != nil {
+		return nil, err
+	}
+	if !isSchedulable(ticket) {
+		return nil, ErrTicketNotSchedulable
+	}
+
+	hours := defaultLinkExpirationHrs
+	if req != nil && req.ExpiresInHours > 0 {
+		hours = req.ExpiresInHours
+	}
+
+	token, err := generateSchedulingToken()
+	if err != nil {
+		return nil, err
+	}
+
+	link := &models.SchedulingLink{
+		TicketID:  ticket.ID,
+		Token:     token,
+		ExpiresAt: time.Now().Add(time.Duration(hours) * time.Hour),
+		CreatedBy: userID,
+	}
+	if err := s.repo.CreateLink(link); err != nil {
+		return nil, err
+	}
+
+	return &models.SchedulingLinkResponse{
+		Token:     link.Token,
+		TicketID:  link.TicketID,
+		ExpiresAt: link.ExpiresAt,
+	}, nil
+}
+
+func (s *schedulingService) GetSlotsForTicket(ticketID string, query models.SlotQuery) (*models.AvailableSlotsResponse, error) {
+	ticket, err := s.ticketRepo.FindByID(ticketID)
+	if err != nil {
+		return nil, err
+	}
+	return s.availableSlots(ticket, query)
+}
+
+func (s *schedulingService) GetSlotsByToken(token string, query models.SlotQuery) (*models.AvailableSlotsResponse, error) {
+	link, err := s.usableLink(token)
+	if err != nil {
+		return nil, err
+	}
+	ticket, err := s.ticketRepo.FindByID(link.TicketID)
+	if err != nil {
+		return nil, err
+	}
+	return s.availableSlots(ticket, query)
+}
+
+func (s *schedulingService) ConfirmForTicket(ticketID string, req *models.ConfirmAppointmentRequest) (*models.AppointmentConfirmation, error) {
+	ticket, err := s.ticketRepo.FindByID(ticketID)
+	if err != nil {
+		return nil, err
+	}
+	return s.confirm(ticket, req, "")
+}
+
+func (s *schedulingService) ConfirmByToken(token string, req *models.ConfirmAppointmentRequest) (*models.AppointmentConfirmation, error) {
+	link, err := s.usableLink(token)
+	if err != nil {
+		return nil, err
+	}
+	ticket, err := s.ticketRepo.FindByID(link.TicketID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Clients cannot override travel-time warnings
+	req.Force = false
+	return s.confirm(ticket, req, link.ID)
+}
+
+func (s *schedulingService) usableLink(token string) (*models.SchedulingLink, error) {
+	link, err := s.repo.FindLinkByToken(token)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSchedulingLinkNotFound
+		}
+		return nil, err
+	}
+	if !link.IsUsable() {
+		return nil, ErrSchedulingLinkExpired
+	}
+	return link, nil
+}
+
+// confirm books the appointment, consuming linkID in the same transaction when set
+func (s *schedulingService) confirm(ticket *models.Ticket, req *models.ConfirmAppointmentRequest, linkID string) (*models.AppointmentConfirmation, error) {
+	if !isSchedulable(ticket) {
+		return nil, ErrTicketNotSchedulable
+	}
+
+	start, err := time.Parse(time.RFC3339, req.Start)
+	if err != nil {
+		return nil, ErrInvalidSlotStart
+	}
+	duration := req.DurationMinutes
+	if duration <= 0 {
+		duration = defaultSlotMinutes
+	}
+	end := start.Add(time.Duration(duration) * time.Minute)
+
+	if !start.After(time.Now()) || !withinWorkday(start, end) {
+		return nil, ErrSlotUnavailable
+	}
+
+	candidates, err := s.candidateTechnicians(ticket)
+	if err != nil {
+		return nil, err
+	}
+	var chosen *candidateTechnician
+	for i := range candidates {
+		if candidates[i].technician.ID == req.TechnicianID {
+			chosen = &candidates[i]
+			break
+		}
+	}
+	if chosen == nil {
+		return nil, ErrSlotUnavailable
+	}
+
+	settings, err := s.repo.GetSettings(ticket.NodeID)
+	if err != nil {
+		return nil, err
+	}
+	dayStart, dayEnd := dayBounds(start.In(businessLocation()))
+
+	var warnings []models.ScheduleConflict
+	booking := &repositories.ScheduleBooking{
+		TicketID:   ticket.ID,
+		Technician: chosen.technician,
+		Start:      start,
+		End:        end,
+		DayStart:   dayStart,
+		DayEnd:     dayEnd,
+		LinkID:     linkID,
+	}
+	err = s.repo.BookSchedule(booking, func(scheduled []models.Ticket) error {
+		conflicts := s.travelConflicts(ticket, scheduled, start, end, settings)
+		if len(conflicts) > 0 {
+			canForce := settings.Enforcement == models.ScheduleEnforcementWarn && !hasOverlap(conflicts)
+			if !canForce || !req.Force {
+				return &ScheduleConflictError{Conflicts: conflicts, CanForce: canForce}
+			}
+			warnings = conflicts
+		}
+		return nil
+	})
+	if err != nil {
+		if linkID != "" && errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSchedulingLinkExpired
+		}
+		return nil, err
+	}
+
+	s.notifyTechnician(&chosen.technician, ticket, start)
+
+	return &models.AppointmentConfirmation{
+		TicketID:       ticket.ID,
+		OSNumber:       ticket.OSNumber,
+		TechnicianID:   chosen.technician.ID,
+		TechnicianName: chosen.technician.FullName,
+		ScheduledStart: start,
+		ScheduledEnd:   end,
+		Warnings:       warnings,
+	}, nil
+}
+
+// availableSlots builds the list of bookable windows for the ticket, based on
+// working hours, technician skills, service radius and existing appointments
+func (s *schedulingService) availableSlots(ticket *models.Ticket, query models.SlotQuery) (*models.AvailableSlotsResponse, error) {
+	if !isSchedulable(ticket) {
+		return nil, ErrTicketNotSchedulable
+	}
+
+	duration := query.DurationMinutes
+	if duration <= 0 {
+		duration = defaultSlotMinutes
+	}
+	days := query.Days
+	if days <= 0 {
+		days = defaultSlotSearchDays
+	}
+	if days > maxSlotSearchDays {
+		days = maxSlotSearchDays
+	}
+	now := time.Now()
+	from := query.From
+	if from.Before(now) {
+		from = now
+	}
+	from = from.In(businessLocation())
+	dayStart := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
+	until := dayStart.AddDate(0, 0, days)
+
+	response := &models.AvailableSlotsResponse{
+		TicketID:        ticket.ID,
+		OSNumber:        ticket.OSNumber,
+		DurationMinutes: duration,
+		Slots:           []models.AppointmentSlot{},
+	}
+
+	candidates, err := s.candidateTechnicians(ticket)
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		return response, nil
+	}
+
+	settings, err := s.repo.GetSettings(ticket.NodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(candidates))
+	for i, c := range candidates {
+		ids[i] = c.technician.ID
+	}
+	scheduled, err := s.repo.FindScheduledTickets(ids, dayStart, until)
+	if err != nil {
+		return nil, err
+	}
+	busy := make(map[string][]models.Ticket)
+	for _, t := range scheduled {
+		for _, tech := range t.Technicians {
+			busy[tech.ID] = append(busy[tech.ID], t)
+		}
+	}
+
+	slotLength := time.Duration(duration) * time.Minute
+	for day := dayStart; day.Before(until); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() == time.Sunday {
+			continue
+		}
+		opening := time.Date(day.Year(), day.Month(), day.Day(), workdayStartHour, 0, 0, 0, day.Location())
+		closing := time.Date(day.Year(), day.Month(), day.Day(), workdayEndHour, 0, 0, 0, day.Location())
+
+		for start := opening; !start.Add(slotLength).After(closing); start = start.Add(slotLength) {
+			if !start.After(from) {
+				continue
+			}
+			end := start.Add(slotLength)
+			added := 0
+			for _, c := range candidates {
+				if len(s.travelConflicts(ticket, busy[c.technician.ID], start, end, settings)) > 0 {
+					continue
+				}
+				response.Slots = append(response.Slots, models.AppointmentSlot{
+					TechnicianID:   c.technician.ID,
+					TechnicianName: c.technician.FullName,
+					Start:          start,
+					End:            end,
+					DistanceKm:     c.distanceKm,
+				})
+				added++
+				if added >= maxTechniciansPerSlot {
+					break
+				}
+			}
+		}
+	}
+
+	return response, nil
+}
+
+type candidateTechnician struct {
+	technician models.Technician
+	distanceKm float64
+}
+
+// candidateTechnicians returns the active technicians that have the skill for
+// the ticket category and are within the service radius of the client,
+// closest first
+func (s *schedulingService) candidateTechnicians(ticket *models.Ticket) ([]candidateTechnician, error) {
+	technicians, err := s.technicianRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var active []models.Technician
+	for _, t := range technicians {
+		if t.Status == "ATIVO" {
+			active = append(active, t)
+		}
+	}
+
+	// Only enforce the skill when at least one technician declares it,
+	// otherwise categories without a matching skill would never be bookable
+	if ticket.Category != nil && ticket.Category.Name != "" {
+		var skilled []models.Technician
+		for _, t := range active {
+			if hasSkill(t.Skills, ticket.Category.Name) {
+				skilled = append(skilled, t)
+			}
+		}
+		if len(skilled) > 0 {
+			active = skilled
+		}
+	}
+
+	var clientCity, clientState string
+	if ticket.Client != nil {
+		clientCity, clientState = ticket.Client.City, ticket.Client.State
+	}
+	clientLat, clientLng, _ := GetCoordinatesForLocation(clientCity, clientState)
+
+	candidates := make([]candidateTechnician, 0, len(active))
+	for _, t := range active {
+		lat, lng, _ := GetCoordinatesForLocation(t.City, t.State)
+		distanceKm := CalculateDistance(lat, lng, clientLat, clientLng) / 1000
+		if ticket.Client != nil && distanceKm > maxServiceRadiusKm {
+			continue
+		}
+		candidates = append(candidates, candidateTechnician{technician: t, distanceKm: distanceKm})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].distanceKm < candidates[j].distanceKm
+	})
+	return candidates, nil
+}
+
+// notifyTechnician records the new appointment on the technician's activity feed
+func (s *schedulingService) notifyTechnician(technician *models.Technician, ticket *models.Ticket, start time.Time) {
+	if s.activityLogService == nil || technician.UserID == nil {
+		return
+	}
+	description := fmt.Sprintf("Atendimento agendado para OS %s em %s", ticket.OSNumber, start.Format("02/01/2006 15:04"))
+	s.activityLogService.LogAction(*technician.UserID, "appointment_scheduled", "ticket", ticket.ID, description, "", "")
+}
+
+func isSchedulable(ticket *models.Ticket) bool {
+	return ticket.Status != models.TicketStatusClosed && ticket.Status != models.TicketStatusUnproductive &&
+		ticket.Status != models.TicketStatusCancelled
+}
+
+func hasSkill(skills models.SkillsMap, skill string) bool {
+	for name, enabled := range skills {
+		if enabled && strings.EqualFold(name, skill) {
+			return true
+		}
+	}
+	return false
+}
+
+// withinWorkday checks the window against the working hours of the business,
+// whatever offset the client sent it in
+func withinWorkday(start, end time.Time) bool {
+	loc := businessLocation()
+	start, end = start.In(loc), end.In(loc)
+	if start.Weekday() == time.Sunday || start.YearDay() != end.YearDay() {
+		return false
+	}
+	opening := time.Date(start.Year(), start.Month(), start.Day(), workdayStartHour, 0, 0, 0, start.Location())
+	closing := time.Date(start.Year(), start.Month(), start.Day(), workdayEndHour, 0, 0, 0, start.Location())
+	return !start.Before(opening) && !end.After(closing)
+}
+
+// travelConflicts checks the proposed window against the technician's other
+// appointments, requiring travel time plus buffer between consecutive jobs
+func (s *schedulingService) travelConflicts(ticket *models.Ticket, scheduled []models.Ticket, start, end time.Time, settings *models.SchedulingSettings) []models.ScheduleConflict {
+	var conflicts []models.ScheduleConflict
+	buffer := time.Duration(settings.BufferMinutes) * time.Minute
+
+	for _, other := range scheduled {
+		if other.ID == ticket.ID || other.ScheduledStart == nil || other.ScheduledEnd == nil {
+			continue
+		}
+		otherStart, otherEnd := *other.ScheduledStart, *other.ScheduledEnd
+
+		conflict := models.ScheduleConflict{
+			TicketID: other.ID,
+			OSNumber: other.OSNumber,
+			Start:    otherStart,
+			End:      otherEnd,
+		}
+
+		if otherStart.Before(end) && otherEnd.After(start) {
+			conflict.Reason = "OVERLAP"
+			conflicts = append(conflicts, conflict)
+			continue
+		}
+
+		travelMinutes := travelTimeMinutes(other.Client, ticket.Client, settings.AverageSpeedKmh)
+		gap := time.Duration(travelMinutes)*time.Minute + buffer
+		conflict.TravelMinutes = travelMinutes
+
+		if !otherEnd.After(start) && otherEnd.Add(gap).After(start) {
+			conflict.Reason = "TRAVEL_BEFORE"
+			conflicts = append(conflicts, conflict)
+		} else if !otherStart.Before(end) && end.Add(gap).After(otherStart) {
+			conflict.Reason = "TRAVEL_AFTER"
+			conflicts = append(conflicts, conflict)
+		}
+	}
+
+	return conflicts
+}
+
+func (s *schedulingService) GetSettings() ([]models.SchedulingSettings, error) {
+	settings, err := s.repo.ListSettings()
+	if err != nil {
+		return nil, err
+	}
+	if len(settings) == 0 || settings[0].NodeID != nil {
+		settings = append([]models.SchedulingSettings{*models.DefaultSchedulingSettings()}, settings...)
+	}
+	return settings, nil
+}
+
+func (s *schedulingService) UpdateSettings(req *models.UpdateSchedulingSettingsRequest) (*models.SchedulingSettings, error) {
+	all, err := s.repo.ListSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	var settings *models.SchedulingSettings
+	for i := range all {
+		if sameNode(all[i].NodeID, req.NodeID) {
+			settings = &all[i]
+			break
+		}
+	}
+	if settings == nil {
+		settings = models.DefaultSchedulingSettings()
+		settings.NodeID = req.NodeID
+	}
+
+	if req.BufferMinutes != nil {
+		if *req.BufferMinutes < 0 {
+			return nil, errors.New("bufferMinutes cannot be negative")
+		}
+		settings.BufferMinutes = *req.BufferMinutes
+	}
+	if req.AverageSpeedKmh != nil {
+		if *req.AverageSpeedKmh <= 0 {
+			return nil, errors.New("averageSpeedKmh must be greater than zero")
+		}
+		settings.AverageSpeedKmh = *req.AverageSpeedKmh
+	}
+	if req.Enforcement != nil {
+		enforcement := models.ScheduleEnforcement(strings.ToUpper(*req.Enforcement))
+		if !enforcement.IsValid() {
+			return nil, ErrInvalidEnforcement
+		}
+		settings.Enforcement = enforcement
+	}
+
+	if err := s.repo.UpsertSettings(settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// travelTimeMinutes estimates the driving time between two clients using the
+// Haversine distance and the configured average speed
+func travelTimeMinutes(from, to *models.Client, speedKmh float64) int {
+	if from == nil || to == nil || speedKmh <= 0 {
+		return 0
+	}
+	fromLat, fromLng, _ := GetCoordinatesForLocation(from.City, from.State)
+	toLat, toLng, _ := GetCoordinatesForLocation(to.City, to.State)
+	distanceKm := CalculateDistance(fromLat, fromLng, toLat, toLng) / 1000
+	return int(math.Ceil(distanceKm / speedKmh * 60))
+}
+
+func hasOverlap(conflicts []models.ScheduleConflict) bool {
+	for _, c := range conflicts {
+		if c.Reason == "OVERLAP" {
+			return true
+		}
+	}
+	return false
+}
+
+func sameNode(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// GetCalendarToken returns the feed token of a technician, creating it on first use
+func (s *schedulingService) GetCalendarToken(technicianID, userID, role string) (*models.CalendarFeedResponse, error) {
+	if err := s.checkCalendarAccess(technicianID, userID, role); err != nil {
+		return nil, err
+	}
+
+	token, err := s.repo.FindCalendarToken(technicianID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return s.issueCalendarToken(technicianID, userID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return calendarFeedResponse(token), nil
+}
+
+// RotateCalendarToken replaces the feed token; subscriptions with the old URL stop working
+func (s *schedulingService) RotateCalendarToken(technicianID, userID, role string) (*models.CalendarFeedResponse, error) {
+	if err := s.checkCalendarAccess(technicianID, userID, role); err != nil {
+		return nil, err
+	}
+	return s.issueCalendarToken(technicianID, userID)
+}
+
+func (s *schedulingService) GetCalendarFeed(technicianID, token string) ([]byte, error) {
+	stored, err := s.repo.FindCalendarToken(technicianID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCalendarFeedNotFound
+		}
+		return nil, err
+	}
+	if token == "" || subtle.ConstantTimeCompare([]byte(stored.Token), []byte(token)) != 1 {
+		return nil, ErrCalendarFeedNotFound
+	}
+
+	technician, err := s.technicianRepo.FindByID(technicianID)
+	if err != nil {
+		return nil, ErrCalendarFeedNotFound
+	}
+
+	now := time.Now()
+	tickets, err := s.repo.FindScheduledTickets([]string{technicianID}, now.Add(-calendarFeedPast), now.Add(calendarFeedFuture))
+	if err != nil {
+		return nil, err
+	}
+	return renderCalendar(technician, tickets, now), nil
+}
+
+func (s *schedulingService) GetCalendar(query *models.CalendarQuery, userID, role string) (*models.TicketCalendar, error) {
+	tickets, err := s.calendarTickets(query, userID, role)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]models.CalendarEvent, 0, len(tickets))
+	// Events of each technician, in start order, to flag the overlapping ones
+	agenda := make(map[string][]int)
+	for _, ticket := range tickets {
+		event := models.CalendarEvent{
+			TicketID:      ticket.ID,
+			OSNumber:      ticket.OSNumber,
+			Title:         "OS " + ticket.OSNumber,
+			Start:         *ticket.ScheduledStart,
+			End:           *ticket.ScheduledEnd,
+			Status:        ticket.Status,
+			Priority:      ticket.Priority,
+			ClientID:      ticket.ClientID,
+			Location:      clientAddress(ticket.Client),
+			TechnicianIDs: make([]string, 0, len(ticket.Technicians)),
+		}
+		if ticket.Client != nil && ticket.Client.FullName != "" {
+			event.ClientName = ticket.Client.FullName
+			event.Title += " - " + ticket.Client.FullName
+		}
+		for _, technician := range ticket.Technicians {
+			event.TechnicianIDs = append(event.TechnicianIDs, technician.ID)
+			agenda[technician.ID] = append(agenda[technician.ID], len(events))
+		}
+		events = append(events, event)
+	}
+	for _, indexes := range agenda {
+		for i, a := range indexes {
+			for _, b := range indexes[i+1:] {
+				if events[b].Start.Before(events[a].End) {
+					events[a].Conflict = true
+					events[b].Conflict = true
+				}
+			}
+		}
+	}
+
+	return &models.TicketCalendar{
+		TechnicianID: query.TechnicianID,
+		From:         query.From,
+		To:           query.To,
+		Events:       events,
+	}, nil
+}
+
+func (s *schedulingService) ExportCalendar(query *models.CalendarQuery, userID, role string) ([]byte, error) {
+	if query.TechnicianID == "" {
+		return nil, ErrCalendarTechnicianRequired
+	}
+	tickets, err := s.calendarTickets(query, userID, role)
+	if err != nil {
+		return nil, err
+	}
+	technician, err := s.technicianRepo.FindByID(query.TechnicianID)
+	if err != nil {
+		return nil, err
+	}
+	return renderCalendar(technician, tickets, time.Now()), nil
+}
+
+// calendarTickets checks the range and the access to the agenda, then loads the tickets
+func (s *schedulingService) calendarTickets(query *models.CalendarQuery, userID, role string) ([]models.Ticket, error) {
+	if !query.From.Before(query.To) || query.To.Sub(query.From) > maxCalendarRangeDays*24*time.Hour {
+		return nil, ErrInvalidCalendarRange
+	}
+	if query.TechnicianID == "" {
+		if role != "ADMIN" && role != "EMPLOYEE" {
+			return nil, ErrCalendarTechnicianRequired
+		}
+	} else if err := s.checkCalendarAccess(query.TechnicianID, userID, role); err != nil {
+		return nil, err
+	}
+	return s.repo.FindCalendarTickets(query)
+}
+
+func (s *schedulingService) checkCalendarAccess(technicianID, userID, role string) error {
+	technician, err := s.technicianRepo.FindByID(technicianID)
+	if err != nil {
+		return err
+	}
+	if role == "ADMIN" || role == "EMPLOYEE" {
+		return nil
+	}
+	if technician.UserID != nil && *technician.UserID == userID {
+		return nil
+	}
+	return ErrCalendarAccessDenied
+}
+
+func (s *schedulingService) issueCalendarToken(technicianID, userID string) (*models.CalendarFeedResponse, error) {
+	value, err := generateSchedulingToken()
+	if err != nil {
+		return nil, err
+	}
+	token := &models.TechnicianCalendarToken{
+		TechnicianID: technicianID,
+		Token:        value,
+		CreatedBy:    userID,
+		CreatedAt:    time.Now(),
+	}
+	if err := s.repo.SaveCalendarToken(token); err != nil {
+		return nil, err
+	}
+	return calendarFeedResponse(token), nil
+}
+
+func calendarFeedResponse(token *models.TechnicianCalendarToken) *models.CalendarFeedResponse {
+	return &models.CalendarFeedResponse{
+		TechnicianID: token.TechnicianID,
+		Token:        token.Token,
+		URL:          "/api/v1/technicians/" + token.TechnicianID + "/schedule.ics?token=" + token.Token,
+	}
+}
+
+// businessLocation is the timezone working hours are defined in
+func businessLocation() *time.Location {
+	loc, err := time.LoadLocation(homeTimezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+func dayBounds(t time.Time) (time.Time, time.Time) {
+	start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	return start, start.AddDate(0, 0, 1)
+}
+
+func generateSchedulingToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
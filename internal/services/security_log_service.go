@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
 	"github.com/shigake/tech-iq-back/internal/repositories"
 )
 
@@ -69,6 +70,7 @@ func (s *securityLogService) GetAll(filter *models.SecurityLogFilter, page, limi
 		Size:          limit,
 		TotalElements: total,
 		TotalPages:    totalPages,
+		HasNext:       pagination.HasNext((page-1)*limit, limit, total),
 	}, nil
 }
 
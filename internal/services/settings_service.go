@@ -0,0 +1,300 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/cache"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+)
+
+var (
+	ErrSettingNotFound = errors.New("setting not found")
+	ErrSettingInvalid  = errors.New("invalid setting value")
+)
+
+const maxAttachmentWorkers = 16
+
+// runtimeSetting is a knob that can be changed while the server runs. Only these can be
+// changed through the settings API and by remediation rules.
+type runtimeSetting struct {
+	key          string
+	description  string
+	defaultValue string
+	// normalize validates the value and returns it in its canonical form
+	normalize func(value string) (string, error)
+	apply     func(value string)
+}
+
+// SettingsService manages the runtime settings: their values are stored and applied
+// again on startup
+type SettingsService interface {
+	List() ([]models.SystemSettingView, error)
+	Get(key string) (*models.SystemSettingView, error)
+	// Update changes a setting by hand
+	Update(key string, req *models.UpdateSettingRequest, userID string) (*models.SystemSettingView, error)
+	// Apply changes a setting and returns the value it had; userID is empty for rules
+	Apply(key, value, source, userID string) (previous string, err error)
+	// Normalize validates a value for the setting and returns it in canonical form
+	Normalize(key, value string) (string, error)
+	// ApplyStored applies the stored values, called once on startup
+	ApplyStored()
+}
+
+type settingsService struct {
+	repo               repositories.RemediationRepository
+	activityLogService ActivityLogService
+	settings           []runtimeSetting
+
+	mu sync.Mutex
+}
+
+func NewSettingsService(
+	repo repositories.RemediationRepository,
+	activityLogService ActivityLogService,
+	redisClient *cache.RedisClient,
+	attachmentService AttachmentService,
+) SettingsService {
+	s := &settingsService{
+		repo:               repo,
+		activityLogService: activityLogService,
+		settings: []runtimeSetting{
+			{
+				key:          models.SettingCacheDegraded,
+				description:  "Ignora o cache Redis e consulta direto o banco de dados",
+				defaultValue: "false",
+				normalize:    normalizeBool,
+				apply: func(value string) {
+					if redisClient != nil {
+						redisClient.SetDegraded(value == "true")
+					}
+				},
+			},
+			{
+				key:          models.SettingAttachmentWorkers,
+				description:  fmt.Sprintf("Anexos processados ao mesmo tempo (1 a %d)", maxAttachmentWorkers),
+				defaultValue: "1",
+				normalize:    normalizeIntRange(1, maxAttachmentWorkers),
+				apply: func(value string) {
+					n, _ := strconv.Atoi(value)
+					attachmentService.SetWorkers(n)
+				},
+			},
+		},
+	}
+	for _, resource := range pagination.Resources() {
+		s.settings = append(s.settings, paginationSetting(resource))
+	}
+	return s
+}
+
+// paginationSetting holds the page size limits of a resource
+func paginationSetting(resource string) runtimeSetting {
+	defaults := pagination.DefaultLimits(resource)
+	return runtimeSetting{
+		key:          models.SettingPaginationPrefix + resource,
+		description:  fmt.Sprintf("Tamanho de página padrão/máximo de %s (máximo até %d)", resource, pagination.HardMax),
+		defaultValue: fmt.Sprintf("%d/%d", defaults.Default, defaults.Max),
+		normalize:    normalizePageLimits,
+		apply: func(value string) {
+			l, _ := parsePageLimits(value)
+			pagination.Set(resource, l)
+		},
+	}
+}
+
+func (s *settingsService) List() ([]models.SystemSettingView, error) {
+	stored, err := s.storedSettings()
+	if err != nil {
+		return nil, err
+	}
+	views := make([]models.SystemSettingView, 0, len(s.settings))
+	for _, def := range s.settings {
+		views = append(views, settingView(def, stored[def.key]))
+	}
+	return views, nil
+}
+
+func (s *settingsService) Get(key string) (*models.SystemSettingView, error) {
+	def, ok := s.find(key)
+	if !ok {
+		return nil, ErrSettingNotFound
+	}
+	stored, err := s.storedSettings()
+	if err != nil {
+		return nil, err
+	}
+	view := settingView(def, stored[key])
+	return &view, nil
+}
+
+func (s *settingsService) Update(key string, req *models.UpdateSettingRequest, userID string) (*models.SystemSettingView, error) {
+	previous, err := s.Apply(key, req.Value, models.SettingSourceManual, userID)
+	if err != nil {
+		return nil, err
+	}
+	view, err := s.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if s.activityLogService != nil && previous != view.Value {
+		description := fmt.Sprintf("Alterou a configuração %s de %s para %s", key, previous, view.Value)
+		if err := s.activityLogService.LogAction(userID, "setting_changed", "system_setting", key, description, "", ""); err != nil {
+			slog.Warn("Failed to audit setting change", "key", key, "error", err)
+		}
+	}
+	return view, nil
+}
+
+func (s *settingsService) Normalize(key, value string) (string, error) {
+	def, ok := s.find(key)
+	if !ok {
+		return "", ErrSettingNotFound
+	}
+	normalized, err := def.normalize(value)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s: %v", ErrSettingInvalid, key, err)
+	}
+	return normalized, nil
+}
+
+func (s *settingsService) Apply(key, value, source, userID string) (string, error) {
+	def, ok := s.find(key)
+	if !ok {
+		return "", ErrSettingNotFound
+	}
+	value, err := s.Normalize(key, value)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, err := s.storedSettings()
+	if err != nil {
+		return "", err
+	}
+	previous := def.defaultValue
+	if current, ok := stored[key]; ok {
+		previous = current.Value
+	}
+
+	setting := &models.SystemSetting{Key: key, Value: value, Source: source, UpdatedBy: userID, UpdatedAt: time.Now()}
+	if err := s.repo.SaveSetting(setting); err != nil {
+		return "", err
+	}
+	def.apply(value)
+	return previous, nil
+}
+
+func (s *settingsService) ApplyStored() {
+	stored, err := s.storedSettings()
+	if err != nil {
+		slog.Warn("Failed to load runtime settings", "error", err)
+		return
+	}
+	for _, def := range s.settings {
+		setting, ok := stored[def.key]
+		if !ok {
+			continue
+		}
+		value, err := def.normalize(setting.Value)
+		if err != nil {
+			slog.Warn("Ignoring invalid stored setting", "key", def.key, "value", setting.Value)
+			continue
+		}
+		def.apply(value)
+		if value != def.defaultValue {
+			slog.Info("Runtime setting loaded", "key", def.key, "value", value, "source", setting.Source)
+		}
+	}
+}
+
+func (s *settingsService) find(key string) (runtimeSetting, bool) {
+	for _, def := range s.settings {
+		if def.key == key {
+			return def, true
+		}
+	}
+	return runtimeSetting{}, false
+}
+
+func (s *settingsService) storedSettings() (map[string]models.SystemSetting, error) {
+	settings, err := s.repo.FindSettings()
+	if err != nil {
+		return nil, err
+	}
+	stored := make(map[string]models.SystemSetting, len(settings))
+	for _, setting := range settings {
+		stored[setting.Key] = setting
+	}
+	return stored, nil
+}
+
+func settingView(def runtimeSetting, stored models.SystemSetting) models.SystemSettingView {
+	view := models.SystemSettingView{
+		Key:         def.key,
+		Description: def.description,
+		Value:       def.defaultValue,
+		Default:     def.defaultValue,
+		Source:      models.SettingSourceDefault,
+	}
+	if stored.Key != "" {
+		view.Value = stored.Value
+		view.Source = stored.Source
+		view.UpdatedBy = stored.UpdatedBy
+		updatedAt := stored.UpdatedAt
+		view.UpdatedAt = &updatedAt
+	}
+	return view
+}
+
+func normalizeBool(value string) (string, error) {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return "", errors.New("expected true or false")
+	}
+	return strconv.FormatBool(b), nil
+}
+
+func normalizePageLimits(value string) (string, error) {
+	l, err := parsePageLimits(value)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d/%d", l.Default, l.Max), nil
+}
+
+func parsePageLimits(value string) (pagination.Limits, error) {
+	invalid := fmt.Errorf("expected default/max with 1 <= default <= max <= %d", pagination.HardMax)
+	def, max, ok := strings.Cut(value, "/")
+	if !ok {
+		return pagination.Limits{}, invalid
+	}
+	l := pagination.Limits{}
+	var err1, err2 error
+	l.Default, err1 = strconv.Atoi(strings.TrimSpace(def))
+	l.Max, err2 = strconv.Atoi(strings.TrimSpace(max))
+	if err1 != nil || err2 != nil || l.Default < 1 || l.Default > l.Max || l.Max > pagination.HardMax {
+		return pagination.Limits{}, invalid
+	}
+	return l, nil
+}
+
+func normalizeIntRange(min, max int) func(string) (string, error) {
+	return func(value string) (string, error) {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < min || n > max {
+			return "", fmt.Errorf("expected a number from %d to %d", min, max)
+		}
+		return strconv.Itoa(n), nil
+	}
+}
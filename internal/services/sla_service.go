@@ -0,0 +1,629 @@
+package services
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSLATicketNotFound = errors.New("ticket not found")
+	ErrSLAPolicyNotFound = errors.New("SLA policy not found")
+	ErrSLAPolicyTargets  = errors.New("responseMinutes must not exceed resolutionMinutes")
+	ErrSLAPolicyCategory = errors.New("category not found")
+	ErrSLAPolicyConflict = errors.New("another active SLA policy already covers this source, category and priority")
+)
+
+// slaResolutionTargets is the maximum time between opening and closing a ticket, per
+// priority; time spent waiting on the client or on parts does not count
+var slaResolutionTargets = map[models.TicketPriority]time.Duration{
+	models.TicketPriorityUrgent: 24 * time.Hour,
+	models.TicketPriorityHigh:   48 * time.Hour,
+	models.TicketPriorityNormal: 72 * time.Hour,
+	models.TicketPriorityLow:    120 * time.Hour,
+}
+
+// slaFirstResponseTargets is the maximum time between opening a ticket and its first
+// response, per priority
+var slaFirstResponseTargets = map[models.TicketPriority]time.Duration{
+	models.TicketPriorityUrgent: time.Hour,
+	models.TicketPriorityHigh:   4 * time.Hour,
+	models.TicketPriorityNormal: 8 * time.Hour,
+	models.TicketPriorityLow:    24 * time.Hour,
+}
+
+// slaRecentBreaches is how many breaches the dashboard lists
+const slaRecentBreaches = 20
+
+// SLAService computes the response and resolution SLA clocks of tickets, paused while
+// they wait, against the SLA policies
+type SLAService interface {
+	GetTicketSLA(ticketID string) (*models.TicketSLA, error)
+	GetComplianceReport(from, to time.Time) (*models.SLAComplianceReport, error)
+	// FindAtRisk returns the open tickets, not paused, that used riskPercent of their target or more
+	FindAtRisk(riskPercent int) ([]models.TicketSLA, error)
+	GetDashboard(from, to time.Time, riskPercent int) (*models.SLADashboard, error)
+
+	// Policies
+	ListPolicies() ([]models.SLAPolicy, error)
+	CreatePolicy(req *models.SLAPolicyRequest, userID string) (*models.SLAPolicy, error)
+	UpdatePolicy(id string, req *models.SLAPolicyRequest) (*models.SLAPolicy, error)
+	DeletePolicy(id string) error
+
+	// FlagBreaches records the open tickets that missed a target since the last run
+	FlagBreaches() (int, error)
+	Start(interval time.Duration)
+	Stop()
+}
+
+type slaService struct {
+	repo       repositories.SLARepository
+	ticketRepo repositories.TicketRepository
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+func NewSLAService(repo repositories.SLARepository, ticketRepo repositories.TicketRepository) SLAService {
+	return &slaService{
+		repo:       repo,
+		ticketRepo: ticketRepo,
+	}
+}
+
+func (s *slaService) GetTicketSLA(ticketID string) (*models.TicketSLA, error) {
+	ticket, err := s.ticketRepo.FindByID(ticketID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSLATicketNotFound
+		}
+		return nil, err
+	}
+	slas, _, err := s.clocks([]models.Ticket{*ticket}, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return &slas[0], nil
+}
+
+// GetComplianceReport measures the tickets closed in [from, to) against their targets,
+// both with the waiting time discounted and raw, so the effect of the pauses shows
+func (s *slaService) GetComplianceReport(from, to time.Time) (*models.SLAComplianceReport, error) {
+	tickets, err := s.repo.FindClosedBetween(from, to)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	slas, pauses, err := s.clocks(tickets, now)
+	if err != nil {
+		return nil, err
+	}
+
+	priorities := []models.TicketPriority{
+		models.TicketPriorityUrgent, models.TicketPriorityHigh, models.TicketPriorityNormal, models.TicketPriorityLow,
+	}
+	total := &complianceTally{}
+	byPriority := make(map[models.TicketPriority]*complianceTally)
+	for _, p := range priorities {
+		byPriority[p] = &complianceTally{}
+	}
+	byCategory := make(map[string]*complianceTally)
+	var categories []string
+	bySource := make(map[models.TicketSource]*complianceTally)
+	for _, source := range models.TicketSources {
+		bySource[source] = &complianceTally{}
+	}
+
+	for i := range slas {
+		sla := &slas[i]
+		total.add(sla)
+		row, ok := byPriority[sla.Priority]
+		if !ok {
+			row = byPriority[models.TicketPriorityNormal]
+		}
+		row.add(sla)
+
+		category := "NONE"
+		if sla.CategoryID != nil {
+			category = *sla.CategoryID
+		}
+		if byCategory[category] == nil {
+			byCategory[category] = &complianceTally{}
+			categories = append(categories, category)
+		}
+		byCategory[category].add(sla)
+
+		if tally, ok := bySource[sla.Source]; ok {
+			tally.add(sla)
+		}
+	}
+
+	report := &models.SLAComplianceReport{
+		From:             from,
+		To:               to,
+		SLAComplianceRow: total.result("TOTAL"),
+		ByPriority:       []models.SLAComplianceRow{},
+		ByCategory:       []models.SLAComplianceRow{},
+		BySource:         []models.SLAComplianceRow{},
+	}
+	for _, p := range priorities {
+		report.ByPriority = append(report.ByPriority, byPriority[p].result(string(p)))
+	}
+	for _, category := range categories {
+		report.ByCategory = append(report.ByCategory, byCategory[category].result(category))
+	}
+	for _, source := range models.TicketSources {
+		report.BySource = append(report.BySource, bySource[source].result(string(source)))
+	}
+	sort.SliceStable(report.ByCategory, func(i, j int) bool {
+		return report.ByCategory[i].Total > report.ByCategory[j].Total
+	})
+	report.Waiting = summarizeWaiting(pauses, now)
+	return report, nil
+}
+
+func (s *slaService) FindAtRisk(riskPercent int) ([]models.TicketSLA, error) {
+	tickets, err := s.repo.FindOpenTickets()
+	if err != nil {
+		return nil, err
+	}
+	slas, _, err := s.clocks(tickets, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	atRisk := []models.TicketSLA{}
+	for i := range slas {
+		if slas[i].Paused {
+			continue
+		}
+		if slas[i].EffectiveHours*100 >= slas[i].TargetHours*float64(riskPercent) {
+			atRisk = append(atRisk, slas[i])
+		}
+	}
+	return atRisk, nil
+}
+
+// GetDashboard combines the compliance of the tickets closed in [from, to) with the
+// state of the clocks still running and the latest breaches
+func (s *slaService) GetDashboard(from, to time.Time, riskPercent int) (*models.SLADashboard, error) {
+	report, err := s.GetComplianceReport(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	tickets, err := s.repo.FindOpenTickets()
+	if err != nil {
+		return nil, err
+	}
+	slas, _, err := s.clocks(tickets, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	running := models.SLARunningSummary{Open: len(slas)}
+	for i := range slas {
+		sla := &slas[i]
+		if sla.Paused {
+			running.Paused++
+		}
+		if sla.ResponseBreached {
+			running.ResponseBreached++
+		}
+		switch {
+		case sla.Breached:
+			running.Breached++
+		case !sla.Paused && sla.EffectiveHours*100 >= sla.TargetHours*float64(riskPercent):
+			running.AtRisk++
+		}
+	}
+
+	breaches, err := s.repo.FindRecentBreaches(slaRecentBreaches)
+	if err != nil {
+		return nil, err
+	}
+	return &models.SLADashboard{
+		Compliance:     *report,
+		Running:        running,
+		RecentBreaches: breaches,
+	}, nil
+}
+
+func (s *slaService) ListPolicies() ([]models.SLAPolicy, error) {
+	return s.repo.FindPolicies(false)
+}
+
+func (s *slaService) CreatePolicy(req *models.SLAPolicyRequest, userID string) (*models.SLAPolicy, error) {
+	policy := &models.SLAPolicy{Active: true, CreatedBy: userID}
+	if err := s.applyPolicy(policy, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreatePolicy(policy); err != nil {
+		return nil, err
+	}
+	return s.repo.FindPolicyByID(policy.ID)
+}
+
+func (s *slaService) UpdatePolicy(id string, req *models.SLAPolicyRequest) (*models.SLAPolicy, error) {
+	policy, err := s.repo.FindPolicyByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSLAPolicyNotFound
+		}
+		return nil, err
+	}
+	policy.Category = nil
+	if err := s.applyPolicy(policy, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdatePolicy(policy); err != nil {
+		return nil, err
+	}
+	return s.repo.FindPolicyByID(policy.ID)
+}
+
+func (s *slaService) DeletePolicy(id string) error {
+	if err := s.repo.DeletePolicy(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSLAPolicyNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// applyPolicy validates the request and copies it to the policy; two active policies
+// cannot cover the same source, category and priority
+func (s *slaService) applyPolicy(policy *models.SLAPolicy, req *models.SLAPolicyRequest) error {
+	if req.ResponseMinutes > req.ResolutionMinutes {
+		return ErrSLAPolicyTargets
+	}
+
+	policy.CategoryID = nil
+	if req.CategoryID != nil && *req.CategoryID != "" {
+		exists, err := s.repo.CategoryExists(*req.CategoryID)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrSLAPolicyCategory
+		}
+		policy.CategoryID = req.CategoryID
+	}
+	policy.Priority = nil
+	if req.Priority != nil && *req.Priority != "" {
+		policy.Priority = req.Priority
+	}
+	policy.Source = nil
+	if req.Source != nil && *req.Source != "" {
+		policy.Source = req.Source
+	}
+
+	policy.Name = strings.TrimSpace(req.Name)
+	policy.ResponseMinutes = req.ResponseMinutes
+	policy.ResolutionMinutes = req.ResolutionMinutes
+	if req.Active != nil {
+		policy.Active = *req.Active
+	}
+
+	if policy.Active {
+		policies, err := s.repo.FindPolicies(true)
+		if err != nil {
+			return err
+		}
+		for i := range policies {
+			if policies[i].ID != policy.ID && sameString(policies[i].CategoryID, policy.CategoryID) &&
+				samePriority(policies[i].Priority, policy.Priority) && sameSource(policies[i].Source, policy.Source) {
+				return ErrSLAPolicyConflict
+			}
+		}
+	}
+	return nil
+}
+
+// FlagBreaches records, once per ticket and target, the open tickets past their response
+// or resolution target, each with a sla.breached event on the ticket timeline
+func (s *slaService) FlagBreaches() (int, error) {
+	tickets, err := s.repo.FindOpenTickets()
+	if err != nil {
+		return 0, err
+	}
+	slas, _, err := s.clocks(tickets, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	var ids []string
+	for i := range slas {
+		if slas[i].Breached || slas[i].ResponseBreached {
+			ids = append(ids, slas[i].TicketID)
+		}
+	}
+	recorded, err := s.repo.FindBreaches(ids)
+	if err != nil {
+		return 0, err
+	}
+	known := make(map[string]bool, len(recorded))
+	for _, b := range recorded {
+		known[b.TicketID+"/"+b.Kind] = true
+	}
+
+	flagged := 0
+	now := time.Now()
+	for i := range slas {
+		sla := &slas[i]
+		var breaches []models.TicketSLABreach
+		if sla.ResponseBreached && !known[sla.TicketID+"/"+models.SLABreachResponse] {
+			breaches = append(breaches, models.TicketSLABreach{Kind: models.SLABreachResponse, DueAt: sla.ResponseDueAt})
+		}
+		if sla.Breached && !known[sla.TicketID+"/"+models.SLABreachResolution] {
+			breaches = append(breaches, models.TicketSLABreach{Kind: models.SLABreachResolution, DueAt: sla.DueAt})
+		}
+		for j := range breaches {
+			breaches[j].TicketID = sla.TicketID
+			breaches[j].PolicyID = sla.PolicyID
+			breaches[j].DetectedAt = now
+			created, err := s.repo.CreateBreach(&breaches[j])
+			if err != nil {
+				return flagged, err
+			}
+			if created {
+				flagged++
+			}
+		}
+	}
+	return flagged, nil
+}
+
+// Start runs FlagBreaches every interval
+func (s *slaService) Start(interval time.Duration) {
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				flagged, err := s.FlagBreaches()
+				if err != nil {
+					slog.Warn("SLA breach check failed", "error", err)
+					continue
+				}
+				if flagged > 0 {
+					slog.Info("SLA breach check flagged breaches", "flagged", flagged)
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (s *slaService) Stop() {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+		s.stop = nil
+	}
+}
+
+// clocks computes the SLA clock of every ticket against the active policies, and
+// returns the waiting intervals it used
+func (s *slaService) clocks(tickets []models.Ticket, now time.Time) ([]models.TicketSLA, []models.TicketSLAPause, error) {
+	ids := make([]string, len(tickets))
+	for i := range tickets {
+		ids[i] = tickets[i].ID
+	}
+	pauses, err := s.repo.FindPausesByTickets(ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	responses, err := s.repo.FirstResponses(ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	policies, err := s.repo.FindPolicies(true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pausesByTicket := make(map[string][]models.TicketSLAPause)
+	for _, p := range pauses {
+		pausesByTicket[p.TicketID] = append(pausesByTicket[p.TicketID], p)
+	}
+
+	slas := make([]models.TicketSLA, len(tickets))
+	for i := range tickets {
+		var respondedAt *time.Time
+		if at, ok := responses[tickets[i].ID]; ok {
+			respondedAt = &at
+		}
+		targets := targetsFor(&tickets[i], policies)
+		slas[i] = *computeSLA(&tickets[i], pausesByTicket[tickets[i].ID], targets, respondedAt, now)
+	}
+	return slas, pauses, nil
+}
+
+// slaTargets are the response and resolution targets of a ticket; policy is nil when
+// the built-in targets of the priority apply
+type slaTargets struct {
+	policy     *models.SLAPolicy
+	response   time.Duration
+	resolution time.Duration
+}
+
+// targetsFor picks the most specific active policy that matches the ticket
+func targetsFor(ticket *models.Ticket, policies []models.SLAPolicy) slaTargets {
+	targets := slaTargets{
+		response:   slaFirstResponseTargets[models.TicketPriorityNormal],
+		resolution: slaResolutionTargets[models.TicketPriorityNormal],
+	}
+	if d, ok := slaFirstResponseTargets[ticket.Priority]; ok {
+		targets.response = d
+	}
+	if d, ok := slaResolutionTargets[ticket.Priority]; ok {
+		targets.resolution = d
+	}
+
+	best := -1
+	for i := range policies {
+		if ok, specificity := policies[i].Matches(ticket.Source, ticket.CategoryID, ticket.Priority); ok && specificity > best {
+			best = specificity
+			targets.policy = &policies[i]
+		}
+	}
+	if targets.policy != nil {
+		targets.response = time.Duration(targets.policy.ResponseMinutes) * time.Minute
+		targets.resolution = time.Duration(targets.policy.ResolutionMinutes) * time.Minute
+	}
+	return targets
+}
+
+// computeSLA runs the clock from opening to closing (or now), taking out every waiting
+// interval; an open pause keeps the ticket from breaching while it lasts. The response
+// clock runs until the first response; a ticket closed without one counts as answered
+// when it was closed.
+func computeSLA(ticket *models.Ticket, pauses []models.TicketSLAPause, targets slaTargets, respondedAt *time.Time, now time.Time) *models.TicketSLA {
+	end := now
+	if ticket.ClosedAt != nil {
+		end = *ticket.ClosedAt
+	} else if ticket.Status == models.TicketStatusClosed {
+		end = ticket.UpdatedAt
+	}
+
+	var paused time.Duration
+	open := false
+	for i := range pauses {
+		paused += pauses[i].Duration(end)
+		if pauses[i].EndedAt == nil {
+			open = true
+		}
+	}
+	elapsed := end.Sub(ticket.CreatedAt)
+	effective := elapsed - paused
+	if effective < 0 {
+		effective = 0
+	}
+
+	responseEnd := end
+	if respondedAt != nil {
+		responseEnd = *respondedAt
+	}
+
+	if pauses == nil {
+		pauses = []models.TicketSLAPause{}
+	}
+	sla := &models.TicketSLA{
+		TicketID:            ticket.ID,
+		OSNumber:            ticket.OSNumber,
+		Priority:            ticket.Priority,
+		Source:              ticket.Source,
+		CategoryID:          ticket.CategoryID,
+		Status:              ticket.Status,
+		TargetHours:         targets.resolution.Hours(),
+		OpenedAt:            ticket.CreatedAt,
+		ClosedAt:            ticket.ClosedAt,
+		ElapsedHours:        roundHours(elapsed),
+		PausedHours:         roundHours(paused),
+		EffectiveHours:      roundHours(effective),
+		DueAt:               ticket.CreatedAt.Add(targets.resolution + paused),
+		Paused:              open,
+		Breached:            effective > targets.resolution,
+		Pauses:              pauses,
+		ResponseTargetHours: targets.response.Hours(),
+		ResponseDueAt:       ticket.CreatedAt.Add(targets.response),
+		RespondedAt:         respondedAt,
+		ResponseBreached:    responseEnd.Sub(ticket.CreatedAt) > targets.response,
+	}
+	if targets.policy != nil {
+		sla.PolicyID = &targets.policy.ID
+		sla.PolicyName = targets.policy.Name
+	}
+	return sla
+}
+
+// complianceTally counts the tickets of one row of the compliance report
+type complianceTally struct {
+	row    models.SLAComplianceRow
+	rawMet int
+}
+
+func (t *complianceTally) add(sla *models.TicketSLA) {
+	t.row.Total++
+	if sla.Breached {
+		t.row.Breached++
+	} else {
+		t.row.Met++
+	}
+	if sla.ElapsedHours <= sla.TargetHours {
+		t.rawMet++
+	}
+	if sla.ResponseBreached {
+		t.row.ResponseBreached++
+	}
+}
+
+func (t *complianceTally) result(key string) models.SLAComplianceRow {
+	row := t.row
+	row.Key = key
+	row.CompliancePercent = compliancePercent(row.Met, row.Total)
+	row.RawCompliancePercent = compliancePercent(t.rawMet, row.Total)
+	row.ResponseCompliancePercent = compliancePercent(row.Total-row.ResponseBreached, row.Total)
+	return row
+}
+
+func sameString(a, b *string) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+func samePriority(a, b *models.TicketPriority) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+func sameSource(a, b *models.TicketSource) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+// summarizeWaiting aggregates the waiting time per waiting status
+func summarizeWaiting(pauses []models.TicketSLAPause, now time.Time) []models.SLAWaitingSummary {
+	summaries := []models.SLAWaitingSummary{}
+	for _, status := range []models.TicketStatus{models.TicketStatusWaitingClient, models.TicketStatusWaitingParts} {
+		summary := models.SLAWaitingSummary{Status: status}
+		tickets := make(map[string]bool)
+		var total time.Duration
+		for i := range pauses {
+			if pauses[i].Status != status {
+				continue
+			}
+			summary.Pauses++
+			tickets[pauses[i].TicketID] = true
+			total += pauses[i].Duration(now)
+		}
+		summary.Tickets = len(tickets)
+		summary.TotalHours = roundHours(total)
+		if summary.Pauses > 0 {
+			summary.AverageHours = roundHours(total / time.Duration(summary.Pauses))
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
+
+func compliancePercent(met, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(met)*1000/float64(total)) / 10
+}
+
+func roundHours(d time.Duration) float64 {
+	return math.Round(d.Hours()*10) / 10
+}
@@ -0,0 +1,304 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/cache"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"gorm.io/gorm"
+)
+
+const (
+	// The public page is cached so polling clients don't hit the database
+	statusCacheTTL = 15 * time.Second
+	// Resolved incidents stay visible for this long
+	statusIncidentWindow = 7 * 24 * time.Hour
+	// Pending jobs above this mark the job as degraded
+	statusBacklogThreshold = 100
+	// Bound of each dependency check of the readiness probe
+	readinessTimeout = 2 * time.Second
+)
+
+var ErrStatusIncidentNotFound = errors.New("incident not found")
+
+type StatusService interface {
+	GetStatus() *models.SystemStatus
+
+	// Probes
+	// Ready checks the database and the cache, uncached. Only the database is required:
+	// the API runs without the cache, slower.
+	Ready() *models.Readiness
+	// Drain makes the instance not ready for the rest of its life, on shutdown
+	Drain()
+
+	// Incidents
+	ListIncidents() ([]models.StatusIncident, error)
+	CreateIncident(userID string, req *models.CreateStatusIncidentRequest) (*models.StatusIncident, error)
+	UpdateIncident(id string, req *models.UpdateStatusIncidentRequest) (*models.StatusIncident, error)
+	DeleteIncident(id string) error
+}
+
+type statusService struct {
+	repo        repositories.StatusRepository
+	db          *gorm.DB
+	redisClient *cache.RedisClient
+
+	mu       sync.Mutex
+	cached   *models.SystemStatus
+	cachedAt time.Time
+
+	draining atomic.Bool
+}
+
+func NewStatusService(repo repositories.StatusRepository, db *gorm.DB, redisClient *cache.RedisClient) StatusService {
+	return &statusService{
+		repo:        repo,
+		db:          db,
+		redisClient: redisClient,
+	}
+}
+
+func (s *statusService) GetStatus() *models.SystemStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cached != nil && time.Since(s.cachedAt) < statusCacheTTL {
+		return s.cached
+	}
+	s.cached = s.buildStatus()
+	s.cachedAt = time.Now()
+	return s.cached
+}
+
+func (s *statusService) buildStatus() *models.SystemStatus {
+	status := &models.SystemStatus{
+		Status:     models.SystemStatusOperational,
+		Uptime:     int64(time.Since(startTime).Seconds()),
+		Version:    serverVersion,
+		Components: []models.ComponentStatus{{Name: "api", Status: models.SystemStatusOperational}},
+		Jobs:       []models.JobBacklog{},
+		Incidents:  []models.StatusIncident{},
+		UpdatedAt:  time.Now(),
+	}
+
+	database := s.checkDatabase()
+	status.Components = append(status.Components, database, s.checkRedis())
+
+	// Without the database neither the backlog nor the incidents can be read
+	if database.Status != models.SystemStatusOperational {
+		status.Status = models.SystemStatusOutage
+		return status
+	}
+
+	status.Jobs = s.jobBacklog()
+	if incidents, err := s.repo.FindIncidentsSince(time.Now().Add(-statusIncidentWindow)); err == nil {
+		status.Incidents = incidents
+	}
+
+	for _, component := range status.Components {
+		if component.Status == models.SystemStatusOutage {
+			status.Status = models.SystemStatusDegraded
+		}
+	}
+	for _, job := range status.Jobs {
+		if job.Status != models.SystemStatusOperational {
+			status.Status = models.SystemStatusDegraded
+		}
+	}
+	for _, incident := range status.Incidents {
+		if incident.Status == models.IncidentStatusResolved {
+			continue
+		}
+		if incident.Severity == models.IncidentSeverityCritical {
+			status.Status = models.SystemStatusOutage
+		} else if status.Status == models.SystemStatusOperational {
+			status.Status = models.SystemStatusDegraded
+		}
+	}
+
+	return status
+}
+
+func (s *statusService) Ready() *models.Readiness {
+	if s.draining.Load() {
+		return &models.Readiness{
+			Status:     models.SystemStatusDraining,
+			Components: []models.ComponentStatus{{Name: "api", Status: models.SystemStatusDraining}},
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), readinessTimeout)
+	defer cancel()
+	database := s.pingDatabase(ctx)
+	cache := s.pingRedis(ctx)
+	readiness := &models.Readiness{
+		Ready:      database.Status == models.SystemStatusOperational,
+		Status:     models.SystemStatusOperational,
+		Components: []models.ComponentStatus{database, cache},
+	}
+	switch {
+	case !readiness.Ready:
+		readiness.Status = models.SystemStatusOutage
+	case cache.Status == models.SystemStatusOutage:
+		readiness.Status = models.SystemStatusDegraded
+	}
+	return readiness
+}
+
+func (s *statusService) Drain() {
+	s.draining.Store(true)
+}
+
+func (s *statusService) checkDatabase() models.ComponentStatus {
+	return s.pingDatabase(context.Background())
+}
+
+func (s *statusService) pingDatabase(ctx context.Context) models.ComponentStatus {
+	component := models.ComponentStatus{Name: "database", Status: models.SystemStatusOutage}
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return component
+	}
+	start := time.Now()
+	if err := sqlDB.PingContext(ctx); err != nil {
+		return component
+	}
+	component.Status = models.SystemStatusOperational
+	component.LatencyMs = time.Since(start).Milliseconds()
+	return component
+}
+
+func (s *statusService) checkRedis() models.ComponentStatus {
+	return s.pingRedis(context.Background())
+}
+
+func (s *statusService) pingRedis(ctx context.Context) models.ComponentStatus {
+	component := models.ComponentStatus{Name: "cache", Status: models.SystemStatusDisabled}
+	if s.redisClient == nil {
+		return component
+	}
+	start := time.Now()
+	if err := s.redisClient.PingContext(ctx); err != nil {
+		component.Status = models.SystemStatusOutage
+		return component
+	}
+	component.Status = models.SystemStatusOperational
+	component.LatencyMs = time.Since(start).Milliseconds()
+	return component
+}
+
+func (s *statusService) jobBacklog() []models.JobBacklog {
+	var jobs []models.JobBacklog
+
+	if pending, manual, err := s.repo.CountDispatchBacklog(); err == nil {
+		jobs = append(jobs, backlogEntry("dispatch", pending, manual))
+	}
+	if pending, failed, err := s.repo.CountAttachmentBacklog(); err == nil {
+		jobs = append(jobs, backlogEntry("attachments", pending, failed))
+	}
+
+	return jobs
+}
+
+func backlogEntry(name string, pending, failed int64) models.JobBacklog {
+	job := models.JobBacklog{Name: name, Pending: pending, Failed: failed, Status: models.SystemStatusOperational}
+	if pending > statusBacklogThreshold {
+		job.Status = models.SystemStatusDegraded
+	}
+	return job
+}
+
+func (s *statusService) ListIncidents() ([]models.StatusIncident, error) {
+	return s.repo.ListIncidents(100)
+}
+
+func (s *statusService) CreateIncident(userID string, req *models.CreateStatusIncidentRequest) (*models.StatusIncident, error) {
+	incident := &models.StatusIncident{
+		Title:     strings.TrimSpace(req.Title),
+		Message:   req.Message,
+		Severity:  models.IncidentSeverityMinor,
+		Status:    models.IncidentStatusInvestigating,
+		StartedAt: time.Now(),
+		CreatedBy: userID,
+	}
+	if req.Severity != "" {
+		incident.Severity = models.IncidentSeverity(req.Severity)
+	}
+	if req.Status != "" {
+		incident.Status = models.IncidentStatus(req.Status)
+	}
+	if req.StartedAt != nil {
+		incident.StartedAt = *req.StartedAt
+	}
+	if incident.Status == models.IncidentStatusResolved {
+		now := time.Now()
+		incident.ResolvedAt = &now
+	}
+
+	if err := s.repo.CreateIncident(incident); err != nil {
+		return nil, err
+	}
+	s.invalidate()
+	return incident, nil
+}
+
+func (s *statusService) UpdateIncident(id string, req *models.UpdateStatusIncidentRequest) (*models.StatusIncident, error) {
+	incident, err := s.repo.FindIncidentByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrStatusIncidentNotFound
+		}
+		return nil, err
+	}
+
+	if req.Title != nil {
+		incident.Title = strings.TrimSpace(*req.Title)
+	}
+	if req.Message != nil {
+		incident.Message = *req.Message
+	}
+	if req.Severity != nil {
+		incident.Severity = models.IncidentSeverity(*req.Severity)
+	}
+	if req.Status != nil {
+		incident.Status = models.IncidentStatus(*req.Status)
+		if incident.Status == models.IncidentStatusResolved {
+			if incident.ResolvedAt == nil {
+				now := time.Now()
+				incident.ResolvedAt = &now
+			}
+		} else {
+			incident.ResolvedAt = nil
+		}
+	}
+
+	if err := s.repo.UpdateIncident(incident); err != nil {
+		return nil, err
+	}
+	s.invalidate()
+	return incident, nil
+}
+
+func (s *statusService) DeleteIncident(id string) error {
+	if err := s.repo.DeleteIncident(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrStatusIncidentNotFound
+		}
+		return err
+	}
+	s.invalidate()
+	return nil
+}
+
+// invalidate drops the cached page so incident changes show up immediately
+func (s *statusService) invalidate() {
+	s.mu.Lock()
+	s.cached = nil
+	s.mu.Unlock()
+}
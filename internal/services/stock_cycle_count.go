@@ -0,0 +1,362 @@
+package services
+
+import (
+	"errors"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+// Days between counts of the same item at a location, per ABC class
+var cycleCountIntervals = map[string]int{
+	models.ABCClassA: 30,
+	models.ABCClassB: 90,
+	models.ABCClassC: 180,
+}
+
+const (
+	cycleCountUsageDays     = 90 // outbound usage window for the ABC classification
+	defaultCycleCountItems  = 10
+	defaultCycleCountDueIn  = 7 * 24 * time.Hour
+	defaultCycleCountPeriod = 24 * time.Hour
+)
+
+// GenerateCycleCount creates count tasks for the items of a location that are due for
+// counting, most overdue first (never counted items lead, A before B before C)
+func (s *stockService) GenerateCycleCount(req models.GenerateCycleCountRequest, userID string) ([]models.StockCountTask, error) {
+	location, err := s.GetLocation(req.LocationID)
+	if err != nil {
+		return nil, err
+	}
+
+	maxItems := req.MaxItems
+	if maxItems <= 0 {
+		maxItems = defaultCycleCountItems
+	}
+	dueDate := time.Now().Add(defaultCycleCountDueIn)
+	if req.DueDate != nil {
+		dueDate = *req.DueDate
+	}
+
+	return s.generateCycleCount(location, maxItems, req.AssignedTo, dueDate, userID)
+}
+
+func (s *stockService) generateCycleCount(location *models.StockLocation, maxItems int, assignedTo *string, dueDate time.Time, userID string) ([]models.StockCountTask, error) {
+	now := time.Now()
+	candidates, err := s.repo.ListCountCandidates(location.ID, now.AddDate(0, 0, -cycleCountUsageDays))
+	if err != nil {
+		return nil, err
+	}
+
+	classes := classifyABC(candidates)
+
+	type dueItem struct {
+		candidate models.CycleCountCandidate
+		class     string
+		overdue   float64 // elapsed share of the class interval; never counted is +Inf-like
+	}
+	var due []dueItem
+	for _, c := range candidates {
+		if c.HasOpenTask {
+			continue
+		}
+		class := classes[c.ItemID]
+		interval := cycleCountIntervals[class]
+		overdue := 1e9
+		if c.LastCountedAt != nil {
+			overdue = now.Sub(*c.LastCountedAt).Hours() / 24 / float64(interval)
+		}
+		if overdue < 1 {
+			continue
+		}
+		due = append(due, dueItem{candidate: c, class: class, overdue: overdue})
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		if due[i].overdue != due[j].overdue {
+			return due[i].overdue > due[j].overdue
+		}
+		return due[i].class < due[j].class
+	})
+	if len(due) > maxItems {
+		due = due[:maxItems]
+	}
+
+	tasks := make([]models.StockCountTask, len(due))
+	for i, d := range due {
+		tasks[i] = models.StockCountTask{
+			ScopeID:    location.ScopeID,
+			LocationID: location.ID,
+			ItemID:     d.candidate.ItemID,
+			ABCClass:   d.class,
+			Status:     models.CountTaskPending,
+			AssignedTo: assignedTo,
+			DueDate:    dueDate,
+			CreatedBy:  userID,
+		}
+	}
+	if err := s.repo.CreateCountTasks(tasks); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// classifyABC ranks items by outbound usage: the items making up the first 80% of the
+// usage are A, the next 15% are B and everything else (including unused items) is C
+func classifyABC(candidates []models.CycleCountCandidate) map[string]string {
+	ranked := make([]models.CycleCountCandidate, len(candidates))
+	copy(ranked, candidates)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Usage > ranked[j].Usage
+	})
+
+	total := 0
+	for _, c := range ranked {
+		total += c.Usage
+	}
+
+	classes := make(map[string]string, len(ranked))
+	cumulative := 0
+	for _, c := range ranked {
+		class := models.ABCClassC
+		if c.Usage > 0 && total > 0 {
+			// Classify by the share reached before this item, so the top item is always A
+			share := float64(cumulative) / float64(total)
+			switch {
+			case share < 0.80:
+				class = models.ABCClassA
+			case share < 0.95:
+				class = models.ABCClassB
+			}
+		}
+		cumulative += c.Usage
+		classes[c.ItemID] = class
+	}
+	return classes
+}
+
+func (s *stockService) ListCountTasks(filter models.StockCountTaskFilter) (*models.PaginatedStockCountTasks, error) {
+	return s.repo.ListCountTasks(filter)
+}
+
+func (s *stockService) AssignCountTask(id string, req models.AssignCountTaskRequest) (*models.StockCountTask, error) {
+	task, err := s.pendingCountTask(id)
+	if err != nil {
+		return nil, err
+	}
+	task.AssignedTo = &req.AssignedTo
+	if err := s.repo.UpdateCountTask(task); err != nil {
+		return nil, err
+	}
+	return s.repo.GetCountTaskByID(id)
+}
+
+func (s *stockService) CancelCountTask(id string) (*models.StockCountTask, error) {
+	task, err := s.pendingCountTask(id)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	task.Status = models.CountTaskCancelled
+	task.CompletedAt = &now
+	if err := s.repo.UpdateCountTask(task); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+func (s *stockService) pendingCountTask(id string) (*models.StockCountTask, error) {
+	task, err := s.repo.GetCountTaskByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCountTaskNotFound
+		}
+		return nil, err
+	}
+	if task.Status != models.CountTaskPending {
+		return nil, ErrCountTaskNotPending
+	}
+	return task, nil
+}
+
+// recordCount stores the count made through the inventory-count flow and completes its task
+func (s *stockService) recordCount(req models.InventoryCountRequest, task *models.StockCountTask, previousQty int, movementID *string, userID string) (*models.StockCount, error) {
+	count := &models.StockCount{
+		ScopeID:     req.ScopeID,
+		LocationID:  req.LocationID,
+		ItemID:      req.ItemID,
+		PreviousQty: previousQty,
+		CountedQty:  req.CountedQuantity,
+		Delta:       req.CountedQuantity - previousQty,
+		MovementID:  movementID,
+		CountedBy:   userID,
+	}
+	if task != nil {
+		count.TaskID = &task.ID
+	}
+	if err := s.repo.CreateCount(count); err != nil {
+		return nil, err
+	}
+
+	if task != nil {
+		task.Status = models.CountTaskCounted
+		task.CountID = &count.ID
+		task.CompletedAt = &count.CountedAt
+		if err := s.repo.UpdateCountTask(task); err != nil {
+			return nil, err
+		}
+	}
+	return count, nil
+}
+
+// GetCountAccuracy reports how often counts matched the system balance in [From, To),
+// overall, per month and per location
+func (s *stockService) GetCountAccuracy(filter models.CountAccuracyFilter) (*models.CountAccuracyReport, error) {
+	counts, err := s.repo.FindCounts(filter)
+	if err != nil {
+		return nil, err
+	}
+	overdue, err := s.repo.CountOverdueTasks(filter.ScopeID, filter.LocationID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.CountAccuracyReport{
+		From:         filter.From,
+		To:           filter.To,
+		Trend:        []models.CountAccuracyPoint{},
+		ByLocation:   []models.LocationCountAccuracy{},
+		TasksOverdue: overdue,
+	}
+	byPeriod := make(map[string]*models.CountAccuracyPoint)
+	byLocation := make(map[string]*models.LocationCountAccuracy)
+
+	for _, count := range counts {
+		if count.TaskID != nil {
+			report.TasksCounted++
+		}
+		addCountAccuracy(&report.Overall, count)
+
+		period := count.CountedAt.Format("2006-01")
+		point := byPeriod[period]
+		if point == nil {
+			point = &models.CountAccuracyPoint{Period: period}
+			byPeriod[period] = point
+		}
+		addCountAccuracy(&point.CountAccuracy, count)
+
+		location := byLocation[count.LocationID]
+		if location == nil {
+			location = &models.LocationCountAccuracy{LocationID: count.LocationID}
+			if count.Location != nil {
+				location.LocationName = count.Location.Name
+			}
+			byLocation[count.LocationID] = location
+		}
+		addCountAccuracy(&location.CountAccuracy, count)
+	}
+
+	finishCountAccuracy(&report.Overall)
+	for _, point := range byPeriod {
+		finishCountAccuracy(&point.CountAccuracy)
+		report.Trend = append(report.Trend, *point)
+	}
+	sort.Slice(report.Trend, func(i, j int) bool {
+		return report.Trend[i].Period < report.Trend[j].Period
+	})
+	for _, location := range byLocation {
+		finishCountAccuracy(&location.CountAccuracy)
+		report.ByLocation = append(report.ByLocation, *location)
+	}
+	sort.Slice(report.ByLocation, func(i, j int) bool {
+		return report.ByLocation[i].AccuracyPct < report.ByLocation[j].AccuracyPct
+	})
+
+	return report, nil
+}
+
+func addCountAccuracy(a *models.CountAccuracy, count models.StockCount) {
+	a.Counts++
+	if count.Delta == 0 {
+		a.Accurate++
+	}
+	a.AbsoluteDelta += abs(count.Delta)
+}
+
+func finishCountAccuracy(a *models.CountAccuracy) {
+	if a.Counts > 0 {
+		a.AccuracyPct = round1(float64(a.Accurate) * 100 / float64(a.Counts))
+	}
+}
+
+// =============== Scheduler ===============
+
+// StartCycleCounts tops up the count tasks of every active location periodically until
+// StopCycleCounts is called
+func (s *stockService) StartCycleCounts(interval time.Duration, itemsPerLocation int) {
+	if interval <= 0 {
+		interval = defaultCycleCountPeriod
+	}
+	if itemsPerLocation <= 0 {
+		itemsPerLocation = defaultCycleCountItems
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				created, err := s.scheduleCycleCounts(itemsPerLocation)
+				if err != nil {
+					slog.Warn("Cycle count scheduling failed", "error", err)
+					continue
+				}
+				if created > 0 {
+					slog.Info("Cycle count scheduler created count tasks", "created", created)
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (s *stockService) StopCycleCounts() {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+		s.stop = nil
+	}
+}
+
+// scheduleCycleCounts generates due tasks for each active location, client sites excluded
+func (s *stockService) scheduleCycleCounts(itemsPerLocation int) (int, error) {
+	active := true
+	created := 0
+	for page := 1; ; page++ {
+		result, err := s.repo.ListLocations(models.StockLocationFilter{IsActive: &active, Page: page, PageSize: 100})
+		if err != nil {
+			return created, err
+		}
+		for i := range result.Data {
+			location := &result.Data[i]
+			if location.Type == models.LocationClient {
+				continue
+			}
+			tasks, err := s.generateCycleCount(location, itemsPerLocation, nil, time.Now().Add(defaultCycleCountDueIn), "")
+			if err != nil {
+				return created, err
+			}
+			created += len(tasks)
+		}
+		if page >= result.TotalPages {
+			return created, nil
+		}
+	}
+}
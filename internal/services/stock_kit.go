@@ -0,0 +1,414 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrKitNotFound         = errors.New("kit not found")
+	ErrKitNameExists       = errors.New("kit name already exists")
+	ErrKitEmpty            = errors.New("a kit needs at least one item")
+	ErrKitDuplicateItem    = errors.New("an item can appear only once in a kit")
+	ErrKitInactive         = errors.New("kit is inactive")
+	ErrKitCategoryNotFound = errors.New("category not found")
+)
+
+func (s *stockService) ListKits(filter models.StockKitFilter) (*models.PaginatedStockKits, error) {
+	return s.repo.ListKits(filter)
+}
+
+func (s *stockService) GetKit(id string) (*models.StockKit, error) {
+	kit, err := s.repo.GetKitByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrKitNotFound
+		}
+		return nil, err
+	}
+	return kit, nil
+}
+
+func (s *stockService) CreateKit(req models.CreateStockKitRequest, userID string) (*models.StockKit, error) {
+	name := strings.TrimSpace(req.Name)
+	if err := s.checkKitName(name, ""); err != nil {
+		return nil, err
+	}
+	items, err := s.kitItems(req.Items)
+	if err != nil {
+		return nil, err
+	}
+	categoryIDs := req.CategoryIDs
+	if categoryIDs == nil {
+		categoryIDs = []string{}
+	}
+	if err := s.checkKitCategories(categoryIDs); err != nil {
+		return nil, err
+	}
+
+	kit := &models.StockKit{
+		Name:        name,
+		Description: req.Description,
+		IsActive:    true,
+		CreatedBy:   userID,
+	}
+	if err := s.repo.SaveKit(kit, items, categoryIDs); err != nil {
+		return nil, err
+	}
+	return s.GetKit(kit.ID)
+}
+
+func (s *stockService) UpdateKit(id string, req models.UpdateStockKitRequest) (*models.StockKit, error) {
+	kit, err := s.GetKit(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if err := s.checkKitName(name, id); err != nil {
+			return nil, err
+		}
+		kit.Name = name
+	}
+	if req.Description != nil {
+		kit.Description = req.Description
+	}
+	if req.IsActive != nil {
+		kit.IsActive = *req.IsActive
+	}
+
+	var items []models.StockKitItem
+	if req.Items != nil {
+		if items, err = s.kitItems(*req.Items); err != nil {
+			return nil, err
+		}
+	}
+	var categoryIDs []string
+	if req.CategoryIDs != nil {
+		categoryIDs = *req.CategoryIDs
+		if categoryIDs == nil {
+			categoryIDs = []string{}
+		}
+		if err := s.checkKitCategories(categoryIDs); err != nil {
+			return nil, err
+		}
+	}
+
+	kit.Items, kit.Categories = nil, nil
+	if err := s.repo.SaveKit(kit, items, categoryIDs); err != nil {
+		return nil, err
+	}
+	return s.GetKit(id)
+}
+
+func (s *stockService) DeleteKit(id string) error {
+	if err := s.repo.DeleteKit(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrKitNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *stockService) checkKitName(name, currentID string) error {
+	kits, err := s.repo.ListKits(models.StockKitFilter{Search: name, PageSize: 100})
+	if err != nil {
+		return err
+	}
+	for _, k := range kits.Data {
+		if k.ID != currentID && strings.EqualFold(k.Name, name) {
+			return ErrKitNameExists
+		}
+	}
+	return nil
+}
+
+func (s *stockService) kitItems(reqs []models.StockKitItemRequest) ([]models.StockKitItem, error) {
+	if len(reqs) == 0 {
+		return nil, ErrKitEmpty
+	}
+	seen := make(map[string]bool, len(reqs))
+	items := make([]models.StockKitItem, len(reqs))
+	for i, r := range reqs {
+		if r.Quantity <= 0 {
+			return nil, ErrNegativeQuantity
+		}
+		if seen[r.ItemID] {
+			return nil, ErrKitDuplicateItem
+		}
+		seen[r.ItemID] = true
+		if _, err := s.GetItem(r.ItemID); err != nil {
+			return nil, err
+		}
+		items[i] = models.StockKitItem{ItemID: r.ItemID, Quantity: r.Quantity}
+	}
+	return items, nil
+}
+
+func (s *stockService) checkKitCategories(ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	unique := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		unique[id] = true
+	}
+	found, err := s.repo.CountCategories(ids)
+	if err != nil {
+		return err
+	}
+	if int(found) != len(unique) {
+		return ErrKitCategoryNotFound
+	}
+	return nil
+}
+
+// ConsumeKit expands the kit into one SAIDA_CONSUMO_OS movement per item, all or nothing
+func (s *stockService) ConsumeKit(id string, req models.ConsumeKitRequest, userID string) (*models.ConsumeKitResponse, error) {
+	kit, err := s.GetKit(id)
+	if err != nil {
+		return nil, err
+	}
+	if !kit.IsActive {
+		return nil, ErrKitInactive
+	}
+	if len(kit.Items) == 0 {
+		return nil, ErrKitEmpty
+	}
+	kits := req.Quantity
+	if kits == 0 {
+		kits = 1
+	}
+	if kits < 0 {
+		return nil, ErrNegativeQuantity
+	}
+
+	ticket, err := s.ticketRepo.FindByID(req.TicketID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrStockTicketNotFound
+		}
+		return nil, err
+	}
+	location, err := s.GetLocation(req.FromLocationID)
+	if err != nil {
+		return nil, err
+	}
+
+	serials := make(map[string][]string, len(kit.Items))
+	for _, line := range kit.Items {
+		if line.Item == nil {
+			return nil, ErrItemNotFound
+		}
+		if serials[line.ItemID], err = validateSerials(line.Item, line.Quantity*kits, req.SerialNumbers[line.ItemID]); err != nil {
+			return nil, err
+		}
+	}
+
+	// A kit is consumed all or nothing, so it cannot wait for approval item by item
+	if s.budgets != nil {
+		value := decimal.Zero
+		for _, line := range kit.Items {
+			lineValue, err := s.movementValue(line.ItemID, line.Quantity*kits, nil)
+			if err != nil {
+				return nil, err
+			}
+			if lineValue != nil {
+				value = value.Add(*lineValue)
+			}
+		}
+		reason, err := s.budgets.PartsOverrunReason(ticket.ID, value)
+		if err != nil {
+			return nil, err
+		}
+		if reason != "" {
+			return nil, ErrPartsBudgetExceeded
+		}
+	}
+
+	notes := "Kit " + kit.Name
+	if n := strings.TrimSpace(req.Notes); n != "" {
+		notes += ": " + n
+	}
+
+	tx := s.repo.BeginTx()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	now := time.Now()
+	movements := make([]*models.StockMovement, 0, len(kit.Items))
+	for _, line := range kit.Items {
+		quantity := line.Quantity * kits
+		if err := s.decreaseUnreserved(tx, location.ScopeID, line.ItemID, location.ID, ticket.ID, quantity); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		movement := &models.StockMovement{
+			ScopeID:        location.ScopeID,
+			Type:           models.MovementTypeSaidaConsumoOS,
+			ItemID:         line.ItemID,
+			FromLocationID: &location.ID,
+			TicketID:       &ticket.ID,
+			Quantity:       quantity,
+			Notes:          &notes,
+			PerformedBy:    userID,
+			PerformedAt:    now,
+			Status:         models.MovementStatusApproved,
+			Serials:        serialLinks(line.ItemID, serials[line.ItemID]),
+		}
+		if err := s.repo.CreateMovementTx(tx, movement); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if err := s.moveSerialsTx(tx, movement, serials[line.ItemID]); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if err := s.consumeReservationsTx(tx, movement); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		movements = append(movements, movement)
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	response := &models.ConsumeKitResponse{
+		KitID:     kit.ID,
+		TicketID:  ticket.ID,
+		Movements: make([]models.StockMovement, 0, len(movements)),
+	}
+	if !kitHasCategory(kit, ticket.CategoryID) {
+		response.Warnings = append(response.Warnings, fmt.Sprintf("kit %s is not attached to the category of OS %s", kit.Name, ticket.OSNumber))
+	}
+	for _, m := range movements {
+		created, err := s.repo.GetMovementByID(m.ID)
+		if err != nil {
+			return nil, err
+		}
+		response.Warnings = append(response.Warnings, s.compatibilityWarnings(created, ticket.ID)...)
+		response.Movements = append(response.Movements, *created)
+	}
+	return response, nil
+}
+
+func kitHasCategory(kit *models.StockKit, categoryID *string) bool {
+	if len(kit.Categories) == 0 || categoryID == nil {
+		return true
+	}
+	for _, c := range kit.Categories {
+		if c.ID == *categoryID {
+			return true
+		}
+	}
+	return false
+}
+
+// GetKitAvailability tells how many of the kit a location can supply, with the shortage per item
+func (s *stockService) GetKitAvailability(id, locationID string, quantity int) (*models.KitAvailability, error) {
+	kit, err := s.GetKit(id)
+	if err != nil {
+		return nil, err
+	}
+	location, err := s.GetLocation(locationID)
+	if err != nil {
+		return nil, err
+	}
+	return s.kitAvailability(kit, location, quantity)
+}
+
+// GetTicketKitAvailability checks, for a location (e.g. the technician van), the active kits
+// attached to the ticket category before dispatch
+func (s *stockService) GetTicketKitAvailability(ticketID, locationID string) ([]models.KitAvailability, error) {
+	ticket, err := s.ticketRepo.FindByID(ticketID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrStockTicketNotFound
+		}
+		return nil, err
+	}
+	location, err := s.GetLocation(locationID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]models.KitAvailability, 0)
+	if ticket.CategoryID == nil {
+		return result, nil
+	}
+	active := true
+	kits, err := s.repo.ListKits(models.StockKitFilter{CategoryID: *ticket.CategoryID, IsActive: &active, PageSize: 100})
+	if err != nil {
+		return nil, err
+	}
+	for i := range kits.Data {
+		availability, err := s.kitAvailability(&kits.Data[i], location, 1)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *availability)
+	}
+	return result, nil
+}
+
+func (s *stockService) kitAvailability(kit *models.StockKit, location *models.StockLocation, quantity int) (*models.KitAvailability, error) {
+	if quantity <= 0 {
+		quantity = 1
+	}
+
+	itemIDs := make([]string, len(kit.Items))
+	for i, line := range kit.Items {
+		itemIDs[i] = line.ItemID
+	}
+	onHand, err := s.repo.GetLocationQuantities(location.ID, itemIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	availability := &models.KitAvailability{
+		KitID:        kit.ID,
+		KitName:      kit.Name,
+		LocationID:   location.ID,
+		LocationName: location.Name,
+		Requested:    quantity,
+		Items:        make([]models.KitItemAvailability, 0, len(kit.Items)),
+	}
+	kitsAvailable := -1
+	for _, line := range kit.Items {
+		required := line.Quantity * quantity
+		item := models.KitItemAvailability{
+			ItemID:   line.ItemID,
+			Required: required,
+			OnHand:   onHand[line.ItemID],
+		}
+		if line.Item != nil {
+			item.ItemSKU, item.ItemName, item.ItemUnit = line.Item.SKU, line.Item.Name, line.Item.Unit
+		}
+		if item.OnHand < required {
+			item.Shortage = required - item.OnHand
+		}
+		if n := item.OnHand / line.Quantity; kitsAvailable < 0 || n < kitsAvailable {
+			kitsAvailable = n
+		}
+		availability.Items = append(availability.Items, item)
+	}
+	if kitsAvailable < 0 {
+		kitsAvailable = 0
+	}
+	availability.KitsAvailable = kitsAvailable
+	availability.Available = len(kit.Items) > 0 && kitsAvailable >= quantity
+	return availability, nil
+}
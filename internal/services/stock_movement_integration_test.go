@@ -0,0 +1,114 @@
+//go:build integration
+
+package services_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"github.com/shigake/tech-iq-back/internal/services"
+	"github.com/shigake/tech-iq-back/internal/testutil"
+)
+
+func newStockService() services.StockService {
+	return services.NewStockService(
+		repositories.NewStockRepository(env.DB),
+		repositories.NewTicketRepository(env.DB),
+		repositories.NewUserRepository(env.DB),
+		services.NewActivityLogService(repositories.NewActivityLogRepository(env.DB)),
+		services.TransferApprovalConfig{},
+		nil,
+		nil,
+		repositories.NewSupplierRepository(env.DB),
+	)
+}
+
+// moveConcurrently runs the same movement from n goroutines at once and returns their errors
+func moveConcurrently(stock services.StockService, req models.CreateStockMovementRequest, n int) []error {
+	errs := make([]error, n)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			_, errs[i] = stock.CreateMovement(req, testutil.AdminUserID)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	return errs
+}
+
+func balanceOf(t *testing.T, stock services.StockService, locationID string) int {
+	t.Helper()
+	balance, err := stock.GetBalance(testutil.StockItemID, locationID)
+	if err != nil {
+		t.Fatalf("balance of %s: %v", locationID, err)
+	}
+	return balance.Quantity
+}
+
+// TestConcurrentTransfersNeverOverdraw races more transfers than the warehouse can cover
+// into a location that has no balance yet
+func TestConcurrentTransfersNeverOverdraw(t *testing.T) {
+	env.Reset(t)
+	stock := newStockService()
+
+	const transfers, quantity = 20, 10
+	errs := moveConcurrently(stock, models.CreateStockMovementRequest{
+		ScopeID:        testutil.StockScopeID,
+		Type:           string(models.MovementTypeTransferencia),
+		ItemID:         testutil.StockItemID,
+		FromLocationID: testutil.WarehouseLocationID,
+		ToLocationID:   testutil.TechnicianLocationID,
+		Quantity:       quantity,
+	}, transfers)
+
+	succeeded := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, services.ErrInsufficientStock):
+			t.Fatalf("transfer: %v", err)
+		}
+	}
+	if want := testutil.WarehouseInitialStock / quantity; succeeded != want {
+		t.Fatalf("%d transfers succeeded, want %d", succeeded, want)
+	}
+	if got := balanceOf(t, stock, testutil.WarehouseLocationID); got != 0 {
+		t.Fatalf("warehouse balance = %d, want 0", got)
+	}
+	if got := balanceOf(t, stock, testutil.TechnicianLocationID); got != testutil.WarehouseInitialStock {
+		t.Fatalf("van balance = %d, want %d", got, testutil.WarehouseInitialStock)
+	}
+}
+
+// TestConcurrentEntriesCreateOneBalance checks that entries racing to create the balance
+// of a location all count
+func TestConcurrentEntriesCreateOneBalance(t *testing.T) {
+	env.Reset(t)
+	stock := newStockService()
+
+	const entries, quantity = 10, 3
+	errs := moveConcurrently(stock, models.CreateStockMovementRequest{
+		ScopeID:      testutil.StockScopeID,
+		Type:         string(models.MovementTypeEntradaCompra),
+		ItemID:       testutil.StockItemID,
+		ToLocationID: testutil.TechnicianLocationID,
+		Quantity:     quantity,
+	}, entries)
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("entry: %v", err)
+		}
+	}
+	if got := balanceOf(t, stock, testutil.TechnicianLocationID); got != entries*quantity {
+		t.Fatalf("van balance = %d, want %d", got, entries*quantity)
+	}
+}
@@ -0,0 +1,274 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrReservationNotFound     = errors.New("reservation not found")
+	ErrReservationNotActive    = errors.New("reservation is not active")
+	ErrReservationTicketClosed = errors.New("parts can only be reserved for open tickets")
+	ErrLocationInactive        = errors.New("location is inactive")
+	ErrStockReserved           = errors.New("stock is reserved for other tickets")
+)
+
+func (s *stockService) ListReservations(filter models.StockReservationFilter) (*models.PaginatedStockReservations, error) {
+	return s.repo.ListReservations(filter)
+}
+
+func (s *stockService) GetReservation(id string) (*models.StockReservation, error) {
+	reservation, err := s.repo.GetReservationByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrReservationNotFound
+		}
+		return nil, err
+	}
+	return reservation, nil
+}
+
+// CreateReservation holds the quantity at the location for the ticket; it fails when the
+// available quantity (balance minus the active reservations) is not enough
+func (s *stockService) CreateReservation(req models.CreateStockReservationRequest, userID string) (*models.StockReservation, error) {
+	if req.Quantity <= 0 {
+		return nil, ErrNegativeQuantity
+	}
+
+	ticket, err := s.ticketRepo.FindByID(req.TicketID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrStockTicketNotFound
+		}
+		return nil, err
+	}
+	switch ticket.Status {
+	case models.TicketStatusClosed, models.TicketStatusCancelled, models.TicketStatusUnproductive:
+		return nil, ErrReservationTicketClosed
+	}
+	if _, err := s.GetItem(req.ItemID); err != nil {
+		return nil, err
+	}
+	location, err := s.GetLocation(req.LocationID)
+	if err != nil {
+		return nil, err
+	}
+	if !location.IsActive {
+		return nil, ErrLocationInactive
+	}
+
+	tx := s.repo.BeginTx()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Locking the balance row serializes reservations and exits of the item at the location
+	balance, err := s.repo.GetBalanceForUpdate(tx, req.ItemID, req.LocationID)
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInsufficientStock
+		}
+		return nil, err
+	}
+	reserved, _, err := s.repo.ReservedQuantityTx(tx, req.ItemID, req.LocationID, "")
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if balance.Quantity-reserved < req.Quantity {
+		tx.Rollback()
+		return nil, ErrInsufficientStock
+	}
+
+	reservation := &models.StockReservation{
+		ScopeID:    location.ScopeID,
+		TicketID:   ticket.ID,
+		ItemID:     req.ItemID,
+		LocationID: location.ID,
+		Quantity:   req.Quantity,
+		Status:     models.ReservationStatusActive,
+		Notes:      stringPtrOrNil(strings.TrimSpace(req.Notes)),
+		CreatedBy:  userID,
+	}
+	if err := s.repo.CreateReservationTx(tx, reservation); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return s.GetReservation(reservation.ID)
+}
+
+// ReleaseReservation gives the quantity back to the location without consuming it
+func (s *stockService) ReleaseReservation(id string, req models.ReleaseStockReservationRequest) (*models.StockReservation, error) {
+	tx := s.repo.BeginTx()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	reservation, err := s.repo.GetReservationForUpdate(tx, id)
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrReservationNotFound
+		}
+		return nil, err
+	}
+	if reservation.Status != models.ReservationStatusActive {
+		tx.Rollback()
+		return nil, ErrReservationNotActive
+	}
+
+	now := time.Now()
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		reason = "released by hand"
+	}
+	reservation.Status = models.ReservationStatusReleased
+	reservation.ReleaseReason = &reason
+	reservation.ReleasedAt = &now
+	if err := s.repo.SaveReservationTx(tx, reservation); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return s.GetReservation(id)
+}
+
+// ConsumeTicketReservations turns the active reservations of the ticket into
+// SAIDA_CONSUMO_OS exits from their locations and returns how many it consumed.
+// Serial-tracked items are left for a manual exit, their units can't be picked
+// automatically. Reservations already waiting for a consumption approval are skipped,
+// so a failed run can be repeated.
+func (s *stockService) ConsumeTicketReservations(ticketID, userID string) (int, error) {
+	reservations, err := s.repo.ListReservations(models.StockReservationFilter{
+		TicketID: ticketID,
+		Status:   models.ReservationStatusActive,
+		PageSize: pagination.HardMax,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	consumed := 0
+	for _, reservation := range reservations.Data {
+		if reservation.Item != nil && reservation.Item.TrackSerial {
+			continue
+		}
+		pending, err := s.repo.ListMovements(models.StockMovementFilter{
+			Type:       string(models.MovementTypeSaidaConsumoOS),
+			Status:     string(models.MovementStatusPending),
+			ItemID:     reservation.ItemID,
+			LocationID: reservation.LocationID,
+			TicketID:   ticketID,
+			PageSize:   1,
+		})
+		if err != nil {
+			return consumed, err
+		}
+		if pending.Total > 0 {
+			continue
+		}
+
+		_, err = s.CreateMovement(models.CreateStockMovementRequest{
+			ScopeID:        reservation.ScopeID,
+			Type:           string(models.MovementTypeSaidaConsumoOS),
+			ItemID:         reservation.ItemID,
+			FromLocationID: reservation.LocationID,
+			TicketID:       ticketID,
+			Quantity:       reservation.Quantity,
+			Notes:          "Reserved parts consumed on ticket closing",
+		}, userID)
+		if err != nil {
+			return consumed, fmt.Errorf("reservation %s: %w", reservation.ID, err)
+		}
+		consumed++
+	}
+	return consumed, nil
+}
+
+// decreaseUnreserved is decreaseBalance for exits and transfers: the balance left must
+// still cover the active reservations, except the ones of ticketID this exit consumes
+func (s *stockService) decreaseUnreserved(tx *gorm.DB, scopeID, itemID, locationID, ticketID string, quantity int) error {
+	if err := s.decreaseBalance(tx, scopeID, itemID, locationID, quantity); err != nil {
+		return err
+	}
+
+	reserved, forTicket, err := s.repo.ReservedQuantityTx(tx, itemID, locationID, ticketID)
+	if err != nil {
+		return err
+	}
+	if reserved == 0 {
+		return nil
+	}
+	stillReserved := reserved - forTicket
+	if forTicket > quantity {
+		stillReserved += forTicket - quantity
+	}
+
+	balance, err := s.repo.GetBalanceForUpdate(tx, itemID, locationID)
+	if err != nil {
+		return err
+	}
+	if balance.Quantity < stillReserved {
+		return ErrStockReserved
+	}
+	return nil
+}
+
+// consumeReservationsTx marks the ticket's reservations covered by a consumption as
+// CONSUMED, oldest first; a reservation only partly consumed is split
+func (s *stockService) consumeReservationsTx(tx *gorm.DB, movement *models.StockMovement) error {
+	if movement.Type != models.MovementTypeSaidaConsumoOS || movement.TicketID == nil || movement.FromLocationID == nil {
+		return nil
+	}
+
+	reservations, err := s.repo.FindActiveReservationsForUpdate(tx, *movement.TicketID, movement.ItemID, *movement.FromLocationID)
+	if err != nil {
+		return err
+	}
+
+	remaining := movement.Quantity
+	for i := range reservations {
+		if remaining == 0 {
+			break
+		}
+		reservation := &reservations[i]
+		if reservation.Quantity > remaining {
+			consumed := *reservation
+			consumed.ID = ""
+			consumed.Quantity = remaining
+			consumed.Status = models.ReservationStatusConsumed
+			consumed.ConsumedMovementID = &movement.ID
+			if err := s.repo.CreateReservationTx(tx, &consumed); err != nil {
+				return err
+			}
+			reservation.Quantity -= remaining
+			remaining = 0
+		} else {
+			reservation.Status = models.ReservationStatusConsumed
+			reservation.ConsumedMovementID = &movement.ID
+			remaining -= reservation.Quantity
+		}
+		if err := s.repo.SaveReservationTx(tx, reservation); err != nil {
+			return err
+		}
+	}
+	return nil
+}
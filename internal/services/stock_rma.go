@@ -0,0 +1,377 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRMANotFound              = errors.New("RMA not found")
+	ErrRMAInvalidStatus         = errors.New("RMA is not in a status that allows this action")
+	ErrRMANotQuarantineLocation = errors.New("location is not a QUARANTINE location")
+	ErrRMAOriginalMismatch      = errors.New("original movement is not a consumption of this item on this ticket")
+	ErrRMAReplacementLocation   = errors.New("replacementLocationId is required for a REPLACEMENT outcome")
+	ErrRMAInvalidOutcome        = errors.New("invalid RMA outcome")
+)
+
+func (s *stockService) ListRMAs(filter models.StockRMAFilter) (*models.PaginatedStockRMAs, error) {
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.PageSize < 1 {
+		filter.PageSize = 20
+	}
+
+	rmas, total, err := s.repo.ListRMAs(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]models.StockRMAResponse, len(rmas))
+	for i := range rmas {
+		data[i] = toRMAResponse(&rmas[i])
+	}
+	return &models.PaginatedStockRMAs{
+		Data:       data,
+		Total:      total,
+		Page:       filter.Page,
+		PageSize:   filter.PageSize,
+		TotalPages: int(math.Ceil(float64(total) / float64(filter.PageSize))),
+		HasNext:    pagination.HasNext((filter.Page-1)*filter.PageSize, filter.PageSize, total),
+	}, nil
+}
+
+func (s *stockService) GetRMA(id string) (*models.StockRMAResponse, error) {
+	rma, err := s.repo.GetRMAByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRMANotFound
+		}
+		return nil, err
+	}
+	response := toRMAResponse(rma)
+	return &response, nil
+}
+
+func toRMAResponse(rma *models.StockRMA) models.StockRMAResponse {
+	response := models.StockRMAResponse{StockRMA: *rma}
+	if rma.Ticket != nil {
+		response.OSNumber = rma.Ticket.OSNumber
+	}
+	return response
+}
+
+// CreateRMA receives a defective part removed from a client into quarantine, linked to
+// the ticket and to the consumption that installed it
+func (s *stockService) CreateRMA(req models.CreateRMARequest, userID string) (*models.StockRMAResponse, error) {
+	if req.Quantity <= 0 {
+		return nil, ErrNegativeQuantity
+	}
+	item, err := s.GetItem(req.ItemID)
+	if err != nil {
+		return nil, err
+	}
+	serials, err := rmaSerials(item, req.Quantity, req.SerialNumber)
+	if err != nil {
+		return nil, err
+	}
+	ticket, err := s.ticketRepo.FindByID(req.TicketID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrStockTicketNotFound
+		}
+		return nil, err
+	}
+	quarantine, err := s.GetLocation(req.QuarantineLocationID)
+	if err != nil {
+		return nil, err
+	}
+	if quarantine.Type != models.LocationQuarantine {
+		return nil, ErrRMANotQuarantineLocation
+	}
+
+	var original *models.StockMovement
+	if req.OriginalMovementID != "" {
+		if original, err = s.GetMovement(req.OriginalMovementID); err != nil {
+			return nil, err
+		}
+		if original.Type != models.MovementTypeSaidaConsumoOS || original.ItemID != req.ItemID ||
+			original.TicketID == nil || *original.TicketID != ticket.ID {
+			return nil, ErrRMAOriginalMismatch
+		}
+	} else if original, err = s.repo.FindLatestConsumption(ticket.ID, req.ItemID); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	tx := s.repo.BeginTx()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	notes := fmt.Sprintf("RMA: peça defeituosa retirada na OS %s", ticket.OSNumber)
+	movement := &models.StockMovement{
+		ScopeID:      quarantine.ScopeID,
+		Type:         models.MovementTypeEntradaDevolucao,
+		ItemID:       req.ItemID,
+		ToLocationID: &quarantine.ID,
+		TicketID:     &ticket.ID,
+		Quantity:     req.Quantity,
+		Notes:        &notes,
+		PerformedBy:  userID,
+		Status:       models.MovementStatusApproved,
+	}
+	if err := s.applyMovementTx(tx, movement, serials); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	rma := &models.StockRMA{
+		ScopeID:              quarantine.ScopeID,
+		ItemID:               req.ItemID,
+		Quantity:             req.Quantity,
+		SerialNumber:         req.SerialNumber,
+		DefectDescription:    strings.TrimSpace(req.DefectDescription),
+		TicketID:             ticket.ID,
+		QuarantineLocationID: quarantine.ID,
+		ReturnMovementID:     movement.ID,
+		Status:               models.RMAStatusQuarantine,
+		CreatedBy:            userID,
+	}
+	if original != nil {
+		rma.OriginalMovementID = &original.ID
+	}
+	if err := s.repo.SaveRMATx(tx, rma); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return s.GetRMA(rma.ID)
+}
+
+// ShipRMA sends the part back to the supplier, taking it out of quarantine
+func (s *stockService) ShipRMA(id string, req models.ShipRMARequest, userID string) (*models.StockRMAResponse, error) {
+	tx := s.repo.BeginTx()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	rma, err := s.lockRMA(tx, id)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if rma.Status != models.RMAStatusQuarantine {
+		tx.Rollback()
+		return nil, ErrRMAInvalidStatus
+	}
+	serials, err := s.rmaItemSerials(rma)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	supplier := strings.TrimSpace(req.Supplier)
+	notes := fmt.Sprintf("%s enviado ao fornecedor %s", rma.RMANumber, supplier)
+	movement := &models.StockMovement{
+		ScopeID:        rma.ScopeID,
+		Type:           models.MovementTypeSaidaFornecedor,
+		ItemID:         rma.ItemID,
+		FromLocationID: &rma.QuarantineLocationID,
+		TicketID:       &rma.TicketID,
+		Quantity:       rma.Quantity,
+		Notes:          &notes,
+		PerformedBy:    userID,
+		Status:         models.MovementStatusApproved,
+	}
+	if err := s.applyMovementTx(tx, movement, serials); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	now := time.Now()
+	rma.Status = models.RMAStatusSentToSupplier
+	rma.Supplier = &supplier
+	rma.SupplierRMANumber = req.SupplierRMANumber
+	rma.TrackingCode = req.TrackingCode
+	rma.ShipmentMovementID = &movement.ID
+	rma.ShippedAt = &now
+	if err := s.repo.SaveRMATx(tx, rma); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return s.GetRMA(id)
+}
+
+// ResolveRMA closes the RMA with the supplier outcome (credit, replacement or rejection),
+// or scraps a part still in quarantine
+func (s *stockService) ResolveRMA(id string, req models.ResolveRMARequest, userID string) (*models.StockRMAResponse, error) {
+	outcome := strings.ToUpper(req.Outcome)
+	switch outcome {
+	case models.RMAOutcomeCredit, models.RMAOutcomeRejected, models.RMAOutcomeScrapped:
+	case models.RMAOutcomeReplacement:
+		if req.ReplacementLocationID == "" {
+			return nil, ErrRMAReplacementLocation
+		}
+		if _, err := s.GetLocation(req.ReplacementLocationID); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrRMAInvalidOutcome
+	}
+
+	tx := s.repo.BeginTx()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	rma, err := s.lockRMA(tx, id)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	// Only a part still in quarantine can be scrapped; supplier outcomes need it shipped
+	expected := models.RMAStatusSentToSupplier
+	if outcome == models.RMAOutcomeScrapped {
+		expected = models.RMAStatusQuarantine
+	}
+	if rma.Status != expected {
+		tx.Rollback()
+		return nil, ErrRMAInvalidStatus
+	}
+
+	var movement *models.StockMovement
+	var serials []string
+	switch outcome {
+	case models.RMAOutcomeScrapped:
+		if serials, err = s.rmaItemSerials(rma); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		notes := rma.RMANumber + " descartado"
+		movement = &models.StockMovement{
+			ScopeID:        rma.ScopeID,
+			Type:           models.MovementTypeSaidaPerda,
+			ItemID:         rma.ItemID,
+			FromLocationID: &rma.QuarantineLocationID,
+			TicketID:       &rma.TicketID,
+			Quantity:       rma.Quantity,
+			Notes:          &notes,
+		}
+	case models.RMAOutcomeReplacement:
+		item, err := s.GetItem(rma.ItemID)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if serials, err = validateSerials(item, rma.Quantity, req.ReplacementSerials); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		notes := rma.RMANumber + " reposição do fornecedor"
+		movement = &models.StockMovement{
+			ScopeID:      rma.ScopeID,
+			Type:         models.MovementTypeEntradaDevolucao,
+			ItemID:       rma.ItemID,
+			ToLocationID: &req.ReplacementLocationID,
+			Quantity:     rma.Quantity,
+			Notes:        &notes,
+		}
+	}
+	if movement != nil {
+		movement.PerformedBy = userID
+		movement.Status = models.MovementStatusApproved
+		if err := s.applyMovementTx(tx, movement, serials); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if outcome == models.RMAOutcomeReplacement {
+			rma.ReplacementMovementID = &movement.ID
+		}
+	}
+
+	now := time.Now()
+	rma.Status = models.RMAStatusClosed
+	rma.Outcome = &outcome
+	if outcome == models.RMAOutcomeCredit {
+		rma.CreditAmount = req.CreditAmount
+	}
+	rma.ResolutionNotes = req.Notes
+	rma.ResolvedAt = &now
+	if err := s.repo.SaveRMATx(tx, rma); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return s.GetRMA(id)
+}
+
+func (s *stockService) lockRMA(tx *gorm.DB, id string) (*models.StockRMA, error) {
+	rma, err := s.repo.GetRMAForUpdate(tx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRMANotFound
+		}
+		return nil, err
+	}
+	return rma, nil
+}
+
+// rmaSerials is the serial of the defective part as the serial list of the movements,
+// when its item tracks serials; for other items the serial is only informative
+func rmaSerials(item *models.StockItem, quantity int, serialNumber *string) ([]string, error) {
+	if !item.TrackSerial {
+		return nil, nil
+	}
+	return validateSerials(item, quantity, []string{ptrToString(serialNumber)})
+}
+
+func (s *stockService) rmaItemSerials(rma *models.StockRMA) ([]string, error) {
+	item, err := s.GetItem(rma.ItemID)
+	if err != nil {
+		return nil, err
+	}
+	return rmaSerials(item, rma.Quantity, rma.SerialNumber)
+}
+
+// applyMovementTx updates the balances for an entry or exit and records the movement
+// with the units it moves
+func (s *stockService) applyMovementTx(tx *gorm.DB, movement *models.StockMovement, serials []string) error {
+	if movement.FromLocationID != nil {
+		if err := s.decreaseBalance(tx, movement.ScopeID, movement.ItemID, *movement.FromLocationID, movement.Quantity); err != nil {
+			return err
+		}
+	}
+	if movement.ToLocationID != nil {
+		if err := s.increaseBalance(tx, movement.ScopeID, movement.ItemID, *movement.ToLocationID, movement.Quantity); err != nil {
+			return err
+		}
+	}
+	movement.Serials = serialLinks(movement.ItemID, serials)
+	if err := s.repo.CreateMovementTx(tx, movement); err != nil {
+		return err
+	}
+	return s.moveSerialsTx(tx, movement, serials)
+}
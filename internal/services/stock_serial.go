@@ -0,0 +1,148 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSerialsRequired     = errors.New("serialNumbers must list one serial number per unit of a serial-tracked item")
+	ErrSerialDuplicate     = errors.New("serial number repeated in the movement")
+	ErrSerialNotTracked    = errors.New("item does not track serial numbers")
+	ErrSerialNotFound      = errors.New("serial number not found")
+	ErrSerialInStock       = errors.New("serial number is already in stock")
+	ErrSerialNotAtLocation = errors.New("serial number is not in stock at the source location")
+)
+
+func (s *stockService) ListSerials(filter models.StockSerialFilter) (*models.PaginatedStockSerials, error) {
+	return s.repo.ListSerials(filter)
+}
+
+// GetSerialHistory traces the units with the serial number (of itemID, when given) through
+// every movement that listed them
+func (s *stockService) GetSerialHistory(serialNumber, itemID string) ([]models.SerialHistory, error) {
+	units, err := s.repo.FindSerialUnits(strings.TrimSpace(serialNumber), itemID)
+	if err != nil {
+		return nil, err
+	}
+	if len(units) == 0 {
+		return nil, ErrSerialNotFound
+	}
+
+	history := make([]models.SerialHistory, 0, len(units))
+	for _, unit := range units {
+		movements, err := s.repo.FindSerialMovements(unit.ItemID, unit.SerialNumber)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, models.SerialHistory{Unit: unit, Movements: movements})
+	}
+	return history, nil
+}
+
+// validateSerials checks the serial numbers given for quantity units of the item: exactly one
+// per unit, without repeats, for serial-tracked items and none for the others
+func validateSerials(item *models.StockItem, quantity int, serials []string) ([]string, error) {
+	if !item.TrackSerial {
+		if len(serials) > 0 {
+			return nil, ErrSerialNotTracked
+		}
+		return nil, nil
+	}
+	if len(serials) != quantity {
+		return nil, ErrSerialsRequired
+	}
+
+	normalized := make([]string, 0, len(serials))
+	seen := make(map[string]bool, len(serials))
+	for _, serial := range serials {
+		serial = strings.TrimSpace(serial)
+		if serial == "" {
+			return nil, ErrSerialsRequired
+		}
+		if seen[serial] {
+			return nil, ErrSerialDuplicate
+		}
+		seen[serial] = true
+		normalized = append(normalized, serial)
+	}
+	return normalized, nil
+}
+
+func serialLinks(itemID string, serials []string) []models.StockMovementSerial {
+	links := make([]models.StockMovementSerial, 0, len(serials))
+	for _, serial := range serials {
+		links = append(links, models.StockMovementSerial{ItemID: itemID, SerialNumber: serial})
+	}
+	return links
+}
+
+// checkSerialsTx locks the units the movement moves and checks they can make it: exits and
+// transfers take units in stock at the source, entries bring units that are not in stock.
+// Units seen for the first time are returned unsaved.
+func (s *stockService) checkSerialsTx(tx *gorm.DB, movement *models.StockMovement, serials []string) ([]*models.StockSerialUnit, error) {
+	units := make([]*models.StockSerialUnit, 0, len(serials))
+	for _, serial := range serials {
+		unit, err := s.repo.GetSerialUnitForUpdate(tx, movement.ItemID, serial)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+
+		if movement.FromLocationID != nil {
+			if unit == nil {
+				return nil, ErrSerialNotFound
+			}
+			if unit.Status != models.SerialStatusInStock || ptrToString(unit.LocationID) != *movement.FromLocationID {
+				return nil, ErrSerialNotAtLocation
+			}
+		} else if unit == nil {
+			unit = &models.StockSerialUnit{
+				ScopeID:      movement.ScopeID,
+				ItemID:       movement.ItemID,
+				SerialNumber: serial,
+			}
+		} else if unit.Status == models.SerialStatusInStock {
+			return nil, ErrSerialInStock
+		}
+		units = append(units, unit)
+	}
+	return units, nil
+}
+
+// moveSerialsTx applies a recorded movement to its units: they end up in stock at the
+// destination, or installed, lost or returned when the movement is an exit
+func (s *stockService) moveSerialsTx(tx *gorm.DB, movement *models.StockMovement, serials []string) error {
+	if len(serials) == 0 {
+		return nil
+	}
+	units, err := s.checkSerialsTx(tx, movement, serials)
+	if err != nil {
+		return err
+	}
+
+	status := models.SerialStatusInStock
+	if movement.ToLocationID == nil {
+		switch movement.Type {
+		case models.MovementTypeSaidaConsumoOS:
+			status = models.SerialStatusInstalled
+		case models.MovementTypeSaidaFornecedor:
+			status = models.SerialStatusReturned
+		default:
+			status = models.SerialStatusLost
+		}
+	}
+
+	for _, unit := range units {
+		unit.Status = status
+		unit.LocationID = movement.ToLocationID
+		unit.TicketID = movement.TicketID
+		unit.LastMovementID = movement.ID
+		if err := s.repo.SaveSerialUnitTx(tx, unit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
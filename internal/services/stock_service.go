@@ -1,7 +1,11 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/shigake/tech-iq-back/internal/models"
@@ -20,6 +24,18 @@ var (
 	ErrTransferSameLocation   = errors.New("transfer must be between different locations")
 	ErrNegativeQuantity       = errors.New("quantity must be greater than zero")
 	ErrItemSKUExists          = errors.New("SKU already exists")
+	ErrCompatibilityNotFound  = errors.New("compatibility entry not found")
+	ErrCompatibilityExists    = errors.New("compatibility entry already exists for this brand and model")
+	ErrEquipmentRequired      = errors.New("brand or ticketId is required")
+	ErrStockTicketNotFound    = errors.New("ticket not found")
+	ErrTicketWithoutEquipment = errors.New("ticket has no equipment brand")
+	ErrStockLevelNotFound     = errors.New("stock level not found")
+	ErrInvalidStockLevels     = errors.New("max_qty must be zero or not less than min_qty")
+	ErrCountTaskNotFound      = errors.New("count task not found")
+	ErrCountTaskNotPending    = errors.New("count task is not pending")
+	ErrCountTaskMismatch      = errors.New("count task is for another item or location")
+	ErrMovementNotPending     = errors.New("movement is not pending approval")
+	ErrSelfApproval           = errors.New("a transfer cannot be approved or rejected by its requester")
 )
 
 // Helper functions for pointer conversion
@@ -63,14 +79,107 @@ type StockService interface {
 
 	// Inventory Count
 	PerformInventoryCount(req models.InventoryCountRequest, userID string) (*models.InventoryCountResponse, error)
+
+	// Compatibility
+	ListCompatibilities(itemID string) ([]models.StockItemCompatibility, error)
+	AddCompatibility(itemID string, req models.CreateStockCompatibilityRequest, userID string) (*models.StockItemCompatibility, error)
+	RemoveCompatibility(itemID, id string) error
+	FindCompatibleParts(filter models.CompatiblePartsFilter) (*models.CompatiblePartsResponse, error)
+
+	// Per-location levels and replenishment
+	ListLevels(filter models.StockLevelFilter) ([]models.StockLevel, error)
+	SetLevel(req models.SetStockLevelRequest, userID string) (*models.StockLevel, error)
+	DeleteLevel(id string) error
+	GetReplenishmentSuggestions(filter models.ReplenishmentFilter) ([]models.ReplenishmentSuggestion, error)
+
+	// Cycle counting
+	GenerateCycleCount(req models.GenerateCycleCountRequest, userID string) ([]models.StockCountTask, error)
+	ListCountTasks(filter models.StockCountTaskFilter) (*models.PaginatedStockCountTasks, error)
+	AssignCountTask(id string, req models.AssignCountTaskRequest) (*models.StockCountTask, error)
+	CancelCountTask(id string) (*models.StockCountTask, error)
+	GetCountAccuracy(filter models.CountAccuracyFilter) (*models.CountAccuracyReport, error)
+	StartCycleCounts(interval time.Duration, itemsPerLocation int)
+	StopCycleCounts()
+
+	// Transfer approval
+	ApproveMovement(id, userID string, req models.MovementDecisionRequest) (*models.StockMovement, error)
+	RejectMovement(id, userID string, req models.MovementDecisionRequest) (*models.StockMovement, error)
+
+	// Kits
+	ListKits(filter models.StockKitFilter) (*models.PaginatedStockKits, error)
+	GetKit(id string) (*models.StockKit, error)
+	CreateKit(req models.CreateStockKitRequest, userID string) (*models.StockKit, error)
+	UpdateKit(id string, req models.UpdateStockKitRequest) (*models.StockKit, error)
+	DeleteKit(id string) error
+	ConsumeKit(id string, req models.ConsumeKitRequest, userID string) (*models.ConsumeKitResponse, error)
+	GetKitAvailability(id, locationID string, quantity int) (*models.KitAvailability, error)
+	GetTicketKitAvailability(ticketID, locationID string) ([]models.KitAvailability, error)
+
+	// RMA
+	ListRMAs(filter models.StockRMAFilter) (*models.PaginatedStockRMAs, error)
+	GetRMA(id string) (*models.StockRMAResponse, error)
+	CreateRMA(req models.CreateRMARequest, userID string) (*models.StockRMAResponse, error)
+	ShipRMA(id string, req models.ShipRMARequest, userID string) (*models.StockRMAResponse, error)
+	ResolveRMA(id string, req models.ResolveRMARequest, userID string) (*models.StockRMAResponse, error)
+
+	// Reservations (parts held for a ticket before consumption)
+	ListReservations(filter models.StockReservationFilter) (*models.PaginatedStockReservations, error)
+	GetReservation(id string) (*models.StockReservation, error)
+	CreateReservation(req models.CreateStockReservationRequest, userID string) (*models.StockReservation, error)
+	ReleaseReservation(id string, req models.ReleaseStockReservationRequest) (*models.StockReservation, error)
+	// ConsumeTicketReservations consumes the active reservations of a closed ticket
+	ConsumeTicketReservations(ticketID, userID string) (int, error)
+
+	// Serial numbers (units of items with TrackSerial)
+	ListSerials(filter models.StockSerialFilter) (*models.PaginatedStockSerials, error)
+	GetSerialHistory(serialNumber, itemID string) ([]models.SerialHistory, error)
+
+	// WithContext returns the service running its queries with ctx, inside the tenant it carries
+	WithContext(ctx context.Context) StockService
 }
 
 type stockService struct {
-	repo repositories.StockRepository
+	repo               repositories.StockRepository
+	ticketRepo         repositories.TicketRepository
+	userRepo           repositories.UserRepository
+	activityLogService ActivityLogService
+	approval           TransferApprovalConfig
+	notifier           MessageSender
+	budgets            TicketBudgetService
+	supplierRepo       repositories.SupplierRepository
+	stop               chan struct{}
+	done               chan struct{}
+}
+
+func NewStockService(
+	repo repositories.StockRepository,
+	ticketRepo repositories.TicketRepository,
+	userRepo repositories.UserRepository,
+	activityLogService ActivityLogService,
+	approval TransferApprovalConfig,
+	notifier MessageSender,
+	budgets TicketBudgetService,
+	supplierRepo repositories.SupplierRepository,
+) StockService {
+	return &stockService{
+		repo:               repo,
+		ticketRepo:         ticketRepo,
+		userRepo:           userRepo,
+		activityLogService: activityLogService,
+		approval:           approval,
+		notifier:           notifier,
+		budgets:            budgets,
+		supplierRepo:       supplierRepo,
+	}
 }
 
-func NewStockService(repo repositories.StockRepository) StockService {
-	return &stockService{repo: repo}
+// WithContext leaves the cycle count loop to the service it was called on
+func (s *stockService) WithContext(ctx context.Context) StockService {
+	scoped := *s
+	scoped.repo = s.repo.WithContext(ctx)
+	scoped.ticketRepo = s.ticketRepo.WithContext(ctx)
+	scoped.userRepo = s.userRepo.WithContext(ctx)
+	return &scoped
 }
 
 // =============== Items ===============
@@ -184,6 +293,9 @@ func (s *stockService) CreateLocation(req models.CreateStockLocationRequest) (*m
 		Name:     req.Name,
 		IsActive: true,
 	}
+	if req.TechnicianID != "" {
+		location.TechnicianID = &req.TechnicianID
+	}
 
 	err := s.repo.CreateLocation(location)
 	if err != nil {
@@ -222,6 +334,12 @@ func (s *stockService) UpdateLocation(id string, req models.UpdateStockLocationR
 	if req.IsActive != nil {
 		location.IsActive = *req.IsActive
 	}
+	if req.TechnicianID != nil {
+		location.TechnicianID = nil
+		if *req.TechnicianID != "" {
+			location.TechnicianID = req.TechnicianID
+		}
+	}
 
 	err = s.repo.UpdateLocation(location)
 	if err != nil {
@@ -261,13 +379,16 @@ func (s *stockService) CreateMovement(req models.CreateStockMovementRequest, use
 	}
 
 	// Validate item exists
-	_, err := s.repo.GetItemByID(req.ItemID)
+	item, err := s.repo.GetItemByID(req.ItemID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, ErrItemNotFound
 		}
 		return nil, err
 	}
+	if req.SerialNumbers, err = validateSerials(item, req.Quantity, req.SerialNumbers); err != nil {
+		return nil, err
+	}
 
 	// Validate locations exist
 	if req.FromLocationID != "" {
@@ -290,6 +411,28 @@ func (s *stockService) CreateMovement(req models.CreateStockMovementRequest, use
 		}
 	}
 
+	if req.SupplierID != "" {
+		if err := activeSupplier(s.supplierRepo, req.SupplierID); err != nil {
+			return nil, err
+		}
+	}
+
+	// High-value transfers and consumption over the ticket budget wait for approval
+	// before touching balances
+	var reason string
+	switch movementType {
+	case models.MovementTypeTransferencia:
+		reason, err = s.transferApprovalReason(req)
+	case models.MovementTypeSaidaConsumoOS:
+		reason, err = s.consumptionApprovalReason(req)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if reason != "" {
+		return s.requestMovementApproval(req, reason, userID)
+	}
+
 	// Begin transaction
 	tx := s.repo.BeginTx()
 	defer func() {
@@ -307,16 +450,20 @@ func (s *stockService) CreateMovement(req models.CreateStockMovementRequest, use
 			return nil, err
 		}
 
-	case models.MovementTypeSaidaConsumoOS, models.MovementTypeSaidaPerda:
-		// Exit: decrease balance at fromLocation
-		if err := s.decreaseBalance(tx, req.ScopeID, req.ItemID, req.FromLocationID, req.Quantity); err != nil {
+	case models.MovementTypeSaidaConsumoOS, models.MovementTypeSaidaPerda, models.MovementTypeSaidaFornecedor:
+		// Exit: decrease balance at fromLocation, reserved stock only goes to its own ticket
+		ticketID := ""
+		if movementType == models.MovementTypeSaidaConsumoOS {
+			ticketID = req.TicketID
+		}
+		if err := s.decreaseUnreserved(tx, req.ScopeID, req.ItemID, req.FromLocationID, ticketID, req.Quantity); err != nil {
 			tx.Rollback()
 			return nil, err
 		}
 
 	case models.MovementTypeTransferencia:
 		// Transfer: decrease from source, increase at destination
-		if err := s.decreaseBalance(tx, req.ScopeID, req.ItemID, req.FromLocationID, req.Quantity); err != nil {
+		if err := s.decreaseUnreserved(tx, req.ScopeID, req.ItemID, req.FromLocationID, "", req.Quantity); err != nil {
 			tx.Rollback()
 			return nil, err
 		}
@@ -348,17 +495,28 @@ func (s *stockService) CreateMovement(req models.CreateStockMovementRequest, use
 		FromLocationID: stringPtrOrNil(req.FromLocationID),
 		ToLocationID:   stringPtrOrNil(req.ToLocationID),
 		TicketID:       stringPtrOrNil(req.TicketID),
+		SupplierID:     stringPtrOrNil(req.SupplierID),
 		Quantity:       req.Quantity,
 		UnitCost:       req.UnitCost,
 		Notes:          stringPtrOrNil(req.Notes),
 		PerformedBy:    userID,
 		PerformedAt:    time.Now(),
+		Status:         models.MovementStatusApproved,
+		Serials:        serialLinks(req.ItemID, req.SerialNumbers),
 	}
 
 	if err := s.repo.CreateMovementTx(tx, movement); err != nil {
 		tx.Rollback()
 		return nil, err
 	}
+	if err := s.moveSerialsTx(tx, movement, req.SerialNumbers); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := s.consumeReservationsTx(tx, movement); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
 
 	// Commit transaction
 	if err := tx.Commit().Error; err != nil {
@@ -366,7 +524,14 @@ func (s *stockService) CreateMovement(req models.CreateStockMovementRequest, use
 	}
 
 	// Reload with relations
-	return s.repo.GetMovementByID(movement.ID)
+	created, err := s.repo.GetMovementByID(movement.ID)
+	if err != nil {
+		return nil, err
+	}
+	if movementType == models.MovementTypeSaidaConsumoOS && req.TicketID != "" {
+		created.Warnings = s.compatibilityWarnings(created, req.TicketID)
+	}
+	return created, nil
 }
 
 func (s *stockService) validateMovementLocations(movementType models.StockMovementType, fromLocationID, toLocationID string) error {
@@ -375,7 +540,7 @@ func (s *stockService) validateMovementLocations(movementType models.StockMoveme
 		if toLocationID == "" {
 			return ErrMissingToLocation
 		}
-	case models.MovementTypeSaidaConsumoOS, models.MovementTypeSaidaPerda:
+	case models.MovementTypeSaidaConsumoOS, models.MovementTypeSaidaPerda, models.MovementTypeSaidaFornecedor:
 		if fromLocationID == "" {
 			return ErrMissingFromLocation
 		}
@@ -401,22 +566,19 @@ func (s *stockService) validateMovementLocations(movementType models.StockMoveme
 
 func (s *stockService) increaseBalance(tx *gorm.DB, scopeID, itemID, locationID string, quantity int) error {
 	balance, err := s.repo.GetBalanceForUpdate(tx, itemID, locationID)
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			// Create new balance
-			balance = &models.StockBalance{
-				ScopeID:    scopeID,
-				ItemID:     itemID,
-				LocationID: locationID,
-				Quantity:   quantity,
-			}
-		} else {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Concurrent movements may create the balance at once: create it empty, then lock
+		// it, so no entry overwrites another
+		if err := s.repo.EnsureBalance(tx, scopeID, itemID, locationID); err != nil {
 			return err
 		}
-	} else {
-		balance.Quantity += quantity
+		balance, err = s.repo.GetBalanceForUpdate(tx, itemID, locationID)
+	}
+	if err != nil {
+		return err
 	}
 
+	balance.Quantity += quantity
 	return s.repo.UpsertBalance(tx, balance)
 }
 
@@ -466,6 +628,17 @@ func (s *stockService) ListBalances(filter models.StockBalanceFilter) (*models.P
 // =============== Inventory Count ===============
 
 func (s *stockService) PerformInventoryCount(req models.InventoryCountRequest, userID string) (*models.InventoryCountResponse, error) {
+	var task *models.StockCountTask
+	if req.TaskID != nil && *req.TaskID != "" {
+		var err error
+		if task, err = s.pendingCountTask(*req.TaskID); err != nil {
+			return nil, err
+		}
+		if task.ItemID != req.ItemID || task.LocationID != req.LocationID {
+			return nil, ErrCountTaskMismatch
+		}
+	}
+
 	// Get current balance
 	currentQty := 0
 	balance, err := s.repo.GetBalance(req.ItemID, req.LocationID)
@@ -478,8 +651,12 @@ func (s *stockService) PerformInventoryCount(req models.InventoryCountRequest, u
 
 	delta := req.CountedQuantity - currentQty
 	
-	// If no change, return response without creating movement
+	// If no change, record the count without creating movement
 	if delta == 0 {
+		count, err := s.recordCount(req, task, currentQty, nil, userID)
+		if err != nil {
+			return nil, err
+		}
 		return &models.InventoryCountResponse{
 			ItemID:          req.ItemID,
 			LocationID:      req.LocationID,
@@ -487,6 +664,7 @@ func (s *stockService) PerformInventoryCount(req models.InventoryCountRequest, u
 			CountedQty:      req.CountedQuantity,
 			Delta:           0,
 			AdjustmentMade:  false,
+			CountID:         count.ID,
 		}, nil
 	}
 
@@ -497,6 +675,8 @@ func (s *stockService) PerformInventoryCount(req models.InventoryCountRequest, u
 		ItemID:   req.ItemID,
 		Quantity: abs(delta),
 		Notes:    ptrToString(req.Notes),
+
+		SerialNumbers: req.SerialNumbers,
 	}
 
 	if delta > 0 {
@@ -512,6 +692,11 @@ func (s *stockService) PerformInventoryCount(req models.InventoryCountRequest, u
 		return nil, err
 	}
 
+	count, err := s.recordCount(req, task, currentQty, &movement.ID, userID)
+	if err != nil {
+		return nil, err
+	}
+
 	return &models.InventoryCountResponse{
 		ItemID:          req.ItemID,
 		LocationID:      req.LocationID,
@@ -520,6 +705,7 @@ func (s *stockService) PerformInventoryCount(req models.InventoryCountRequest, u
 		Delta:           delta,
 		AdjustmentMade:  true,
 		MovementID:      movement.ID,
+		CountID:         count.ID,
 	}, nil
 }
 
@@ -529,3 +715,280 @@ func abs(n int) int {
 	}
 	return n
 }
+
+// =============== Compatibility ===============
+
+func (s *stockService) ListCompatibilities(itemID string) ([]models.StockItemCompatibility, error) {
+	if _, err := s.GetItem(itemID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListCompatibilities(itemID)
+}
+
+func (s *stockService) AddCompatibility(itemID string, req models.CreateStockCompatibilityRequest, userID string) (*models.StockItemCompatibility, error) {
+	if _, err := s.GetItem(itemID); err != nil {
+		return nil, err
+	}
+
+	brand := strings.TrimSpace(req.Brand)
+	model := strings.TrimSpace(req.Model)
+	existing, err := s.repo.ListCompatibilities(itemID)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range existing {
+		if strings.EqualFold(e.Brand, brand) && strings.EqualFold(e.Model, model) {
+			return nil, ErrCompatibilityExists
+		}
+	}
+
+	compatibility := &models.StockItemCompatibility{
+		ItemID:    itemID,
+		Brand:     brand,
+		Model:     model,
+		Notes:     req.Notes,
+		CreatedBy: userID,
+	}
+	if err := s.repo.CreateCompatibility(compatibility); err != nil {
+		return nil, err
+	}
+	return compatibility, nil
+}
+
+func (s *stockService) RemoveCompatibility(itemID, id string) error {
+	if err := s.repo.DeleteCompatibility(itemID, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrCompatibilityNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// FindCompatibleParts lists the parts that fit an equipment, given directly or through
+// a ticket, with the quantity on hand when a location (e.g. the technician van) is given
+func (s *stockService) FindCompatibleParts(filter models.CompatiblePartsFilter) (*models.CompatiblePartsResponse, error) {
+	if filter.TicketID != "" {
+		ticket, err := s.ticketRepo.FindByID(filter.TicketID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrStockTicketNotFound
+			}
+			return nil, err
+		}
+		if strings.TrimSpace(ticket.ComputerBrand) == "" {
+			return nil, ErrTicketWithoutEquipment
+		}
+		filter.Brand, filter.Model = ticket.ComputerBrand, ticket.ComputerModel
+	}
+	filter.Brand = strings.TrimSpace(filter.Brand)
+	filter.Model = strings.TrimSpace(filter.Model)
+	if filter.Brand == "" {
+		return nil, ErrEquipmentRequired
+	}
+	if filter.LocationID != "" {
+		if _, err := s.GetLocation(filter.LocationID); err != nil {
+			return nil, err
+		}
+	}
+
+	parts, err := s.repo.FindCompatibleItems(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	if filter.LocationID != "" {
+		ids := make([]string, len(parts))
+		for i, part := range parts {
+			ids[i] = part.Item.ID
+		}
+		quantities, err := s.repo.GetLocationQuantities(filter.LocationID, ids)
+		if err != nil {
+			return nil, err
+		}
+		for i := range parts {
+			quantity := quantities[parts[i].Item.ID]
+			parts[i].Quantity = &quantity
+		}
+	}
+
+	return &models.CompatiblePartsResponse{
+		Brand: filter.Brand,
+		Model: filter.Model,
+		Data:  parts,
+	}, nil
+}
+
+// compatibilityWarnings checks a part consumed on a ticket against the ticket equipment.
+// Failures here never block the movement, which is already recorded.
+func (s *stockService) compatibilityWarnings(movement *models.StockMovement, ticketID string) []string {
+	ticket, err := s.ticketRepo.FindByID(ticketID)
+	if err != nil || strings.TrimSpace(ticket.ComputerBrand) == "" {
+		return nil
+	}
+
+	mapped, compatible, err := s.repo.CheckCompatibility(movement.ItemID, ticket.ComputerBrand, ticket.ComputerModel)
+	if err != nil || !mapped || compatible {
+		return nil
+	}
+
+	itemName := movement.ItemID
+	if movement.Item != nil {
+		itemName = movement.Item.Name
+	}
+	equipment := strings.TrimSpace(ticket.ComputerBrand + " " + ticket.ComputerModel)
+	return []string{fmt.Sprintf("%s is not listed as compatible with %s (OS %s)", itemName, equipment, ticket.OSNumber)}
+}
+
+// =============== Levels ===============
+
+func (s *stockService) ListLevels(filter models.StockLevelFilter) ([]models.StockLevel, error) {
+	return s.repo.ListLevels(filter)
+}
+
+// SetLevel creates or replaces the min/max of an item at a location
+func (s *stockService) SetLevel(req models.SetStockLevelRequest, userID string) (*models.StockLevel, error) {
+	if req.MinQty < 0 || req.MaxQty < 0 || (req.MaxQty > 0 && req.MaxQty < req.MinQty) {
+		return nil, ErrInvalidStockLevels
+	}
+	if _, err := s.GetItem(req.ItemID); err != nil {
+		return nil, err
+	}
+	if _, err := s.GetLocation(req.LocationID); err != nil {
+		return nil, err
+	}
+
+	level := &models.StockLevel{
+		LocationID: req.LocationID,
+		ItemID:     req.ItemID,
+		MinQty:     req.MinQty,
+		MaxQty:     req.MaxQty,
+		UpdatedBy:  userID,
+	}
+	if err := s.repo.UpsertLevel(level); err != nil {
+		return nil, err
+	}
+
+	levels, err := s.repo.ListLevels(models.StockLevelFilter{LocationID: req.LocationID, ItemID: req.ItemID})
+	if err != nil {
+		return nil, err
+	}
+	if len(levels) == 0 {
+		return nil, ErrStockLevelNotFound
+	}
+	return &levels[0], nil
+}
+
+func (s *stockService) DeleteLevel(id string) error {
+	if err := s.repo.DeleteLevel(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrStockLevelNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// GetReplenishmentSuggestions lists, per location at or below its minimum, the transfers
+// that bring it back up to its max (or min) from the surplus of warehouses and branches
+// in the same scope. Whatever no source can cover is suggested as a purchase.
+func (s *stockService) GetReplenishmentSuggestions(filter models.ReplenishmentFilter) ([]models.ReplenishmentSuggestion, error) {
+	rows, err := s.repo.ListLevelRows(filter.ScopeID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Surplus above the minimum at each source, per scope and item
+	type source struct {
+		row     models.StockLevelRow
+		surplus int
+	}
+	sources := make(map[string][]*source)
+	for _, row := range rows {
+		if row.LocationType != string(models.LocationWarehouse) && row.LocationType != string(models.LocationBranch) {
+			continue
+		}
+		if surplus := row.Quantity - row.MinQty; surplus > 0 {
+			key := row.ScopeID + "/" + row.ItemID
+			sources[key] = append(sources[key], &source{row: row, surplus: surplus})
+		}
+	}
+	// Warehouses give before branches, larger surplus first
+	for _, list := range sources {
+		sort.SliceStable(list, func(i, j int) bool {
+			if list[i].row.LocationType != list[j].row.LocationType {
+				return list[i].row.LocationType == string(models.LocationWarehouse)
+			}
+			return list[i].surplus > list[j].surplus
+		})
+	}
+
+	suggestions := make([]models.ReplenishmentSuggestion, 0)
+	byLocation := make(map[string]int)
+	for _, row := range rows {
+		if filter.LocationID != "" && row.LocationID != filter.LocationID {
+			continue
+		}
+		if row.LocationType == string(models.LocationClient) || row.LocationType == string(models.LocationQuarantine) ||
+			row.Quantity > row.MinQty {
+			continue
+		}
+		target := row.MinQty
+		if row.MaxQty > 0 {
+			target = row.MaxQty
+		}
+		need := target - row.Quantity
+		if need <= 0 {
+			continue
+		}
+
+		idx, ok := byLocation[row.LocationID]
+		if !ok {
+			idx = len(suggestions)
+			byLocation[row.LocationID] = idx
+			suggestions = append(suggestions, models.ReplenishmentSuggestion{
+				ScopeID:      row.ScopeID,
+				LocationID:   row.LocationID,
+				LocationName: row.LocationName,
+				LocationType: row.LocationType,
+				Transfers:    []models.ReplenishmentTransfer{},
+				Purchases:    []models.ReplenishmentPurchase{},
+			})
+		}
+		suggestion := &suggestions[idx]
+
+		for _, src := range sources[row.ScopeID+"/"+row.ItemID] {
+			if need == 0 {
+				break
+			}
+			if src.row.LocationID == row.LocationID || src.surplus == 0 {
+				continue
+			}
+			qty := need
+			if src.surplus < qty {
+				qty = src.surplus
+			}
+			src.surplus -= qty
+			need -= qty
+			suggestion.Transfers = append(suggestion.Transfers, models.ReplenishmentTransfer{
+				ItemID:           row.ItemID,
+				ItemSKU:          row.ItemSKU,
+				ItemName:         row.ItemName,
+				FromLocationID:   src.row.LocationID,
+				FromLocationName: src.row.LocationName,
+				Quantity:         qty,
+			})
+		}
+		if need > 0 {
+			suggestion.Purchases = append(suggestion.Purchases, models.ReplenishmentPurchase{
+				ItemID:   row.ItemID,
+				ItemSKU:  row.ItemSKU,
+				ItemName: row.ItemName,
+				ItemUnit: row.ItemUnit,
+				Quantity: need,
+			})
+		}
+	}
+
+	return suggestions, nil
+}
@@ -0,0 +1,317 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// TransferApprovalConfig sets when a TRANSFERENCIA needs approval; a zero threshold
+// disables that check and both at zero disables approvals altogether
+type TransferApprovalConfig struct {
+	ValueThreshold    decimal.Decimal // quantity x unit cost (given, else last purchase cost)
+	QuantityThreshold int
+}
+
+// transferApprovalReason explains why the transfer needs approval, empty when it does not
+func (s *stockService) transferApprovalReason(req models.CreateStockMovementRequest) (string, error) {
+	if s.approval.QuantityThreshold > 0 && req.Quantity > s.approval.QuantityThreshold {
+		return fmt.Sprintf("quantity %d exceeds the approval threshold of %d", req.Quantity, s.approval.QuantityThreshold), nil
+	}
+
+	if !s.approval.ValueThreshold.IsPositive() {
+		return "", nil
+	}
+	value, err := s.movementValue(req.ItemID, req.Quantity, req.UnitCost)
+	if err != nil || value == nil {
+		return "", err
+	}
+	if value.GreaterThan(s.approval.ValueThreshold) {
+		return fmt.Sprintf("value %s exceeds the approval threshold of %s", value.StringFixed(2), s.approval.ValueThreshold.StringFixed(2)), nil
+	}
+	return "", nil
+}
+
+// consumptionApprovalReason explains why consuming parts on the ticket needs approval
+// because it goes over the ticket parts budget, empty when it does not
+func (s *stockService) consumptionApprovalReason(req models.CreateStockMovementRequest) (string, error) {
+	if s.budgets == nil || req.TicketID == "" {
+		return "", nil
+	}
+	value, err := s.movementValue(req.ItemID, req.Quantity, req.UnitCost)
+	if err != nil || value == nil {
+		return "", err
+	}
+	return s.budgets.PartsOverrunReason(req.TicketID, *value)
+}
+
+// movementValue is quantity x unit cost (given, else last purchase cost), nil when the cost is unknown
+func (s *stockService) movementValue(itemID string, quantity int, unitCost *decimal.Decimal) (*decimal.Decimal, error) {
+	if unitCost == nil {
+		var err error
+		if unitCost, err = s.repo.GetLastUnitCost(itemID); err != nil {
+			return nil, err
+		}
+	}
+	if unitCost == nil {
+		return nil, nil
+	}
+	value := unitCost.Mul(decimal.NewFromInt(int64(quantity)))
+	return &value, nil
+}
+
+// requestMovementApproval records the transfer or consumption as PENDING without touching balances
+func (s *stockService) requestMovementApproval(req models.CreateStockMovementRequest, reason, userID string) (*models.StockMovement, error) {
+	// Fail early when the source cannot cover it; the balance is checked again on approval
+	balance, err := s.repo.GetBalance(req.ItemID, req.FromLocationID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if balance == nil || balance.Quantity < req.Quantity {
+		return nil, ErrInsufficientStock
+	}
+
+	movement := &models.StockMovement{
+		ScopeID:        req.ScopeID,
+		Type:           models.StockMovementType(req.Type),
+		ItemID:         req.ItemID,
+		FromLocationID: stringPtrOrNil(req.FromLocationID),
+		ToLocationID:   stringPtrOrNil(req.ToLocationID),
+		TicketID:       stringPtrOrNil(req.TicketID),
+		SupplierID:     stringPtrOrNil(req.SupplierID),
+		Quantity:       req.Quantity,
+		UnitCost:       req.UnitCost,
+		Notes:          stringPtrOrNil(req.Notes),
+		PerformedBy:    userID,
+		PerformedAt:    time.Now(),
+		Status:         models.MovementStatusPending,
+		ApprovalReason: &reason,
+		Serials:        serialLinks(req.ItemID, req.SerialNumbers),
+	}
+	tx := s.repo.BeginTx()
+	// The units must be at the source now; they only move, and are checked again, on approval
+	if _, err := s.checkSerialsTx(tx, movement, req.SerialNumbers); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := s.repo.CreateMovementTx(tx, movement); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	created, err := s.repo.GetMovementByID(movement.ID)
+	if err != nil {
+		return nil, err
+	}
+	s.auditTransfer(userID, movementAction(created, "requested"), created, movementLabel(created)+" aguardando aprovação: "+reason)
+	go s.notifyApprovers(*created)
+	return created, nil
+}
+
+// ApproveMovement applies a pending transfer or consumption to the balances
+func (s *stockService) ApproveMovement(id, userID string, req models.MovementDecisionRequest) (*models.StockMovement, error) {
+	return s.decideMovement(id, userID, req, models.MovementStatusApproved)
+}
+
+// RejectMovement discards a pending transfer or consumption; balances are left untouched
+func (s *stockService) RejectMovement(id, userID string, req models.MovementDecisionRequest) (*models.StockMovement, error) {
+	return s.decideMovement(id, userID, req, models.MovementStatusRejected)
+}
+
+func (s *stockService) decideMovement(id, userID string, req models.MovementDecisionRequest, status models.StockMovementStatus) (*models.StockMovement, error) {
+	tx := s.repo.BeginTx()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	movement, err := s.repo.GetMovementForUpdate(tx, id)
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMovementNotFound
+		}
+		return nil, err
+	}
+	if movement.Status != models.MovementStatusPending {
+		tx.Rollback()
+		return nil, ErrMovementNotPending
+	}
+	if movement.PerformedBy == userID {
+		tx.Rollback()
+		return nil, ErrSelfApproval
+	}
+
+	if status == models.MovementStatusApproved {
+		ticketID := ""
+		if movement.Type == models.MovementTypeSaidaConsumoOS {
+			ticketID = ptrToString(movement.TicketID)
+		}
+		if err := s.decreaseUnreserved(tx, movement.ScopeID, movement.ItemID, ptrToString(movement.FromLocationID), ticketID, movement.Quantity); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if movement.ToLocationID != nil {
+			if err := s.increaseBalance(tx, movement.ScopeID, movement.ItemID, *movement.ToLocationID, movement.Quantity); err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+		}
+		serials, err := s.repo.ListMovementSerialsTx(tx, movement.ID)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if err := s.moveSerialsTx(tx, movement, serials); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	movement.Status = status
+	movement.DecidedBy = &userID
+	movement.DecidedAt = &now
+	movement.DecisionNotes = stringPtrOrNil(strings.TrimSpace(req.Notes))
+	if err := s.repo.UpdateMovementTx(tx, movement); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if status == models.MovementStatusApproved {
+		if err := s.consumeReservationsTx(tx, movement); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	decided, err := s.repo.GetMovementByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if status == models.MovementStatusApproved {
+		s.auditTransfer(userID, movementAction(decided, "approved"), decided, movementLabel(decided)+" aprovada")
+	} else {
+		s.auditTransfer(userID, movementAction(decided, "rejected"), decided, movementLabel(decided)+" rejeitada")
+	}
+	go s.notifyRequester(*decided)
+	return decided, nil
+}
+
+func (s *stockService) auditTransfer(userID, action string, movement *models.StockMovement, description string) {
+	if s.activityLogService == nil {
+		return
+	}
+	description = fmt.Sprintf("%s (%d x %s)", description, movement.Quantity, transferItemName(movement))
+	if err := s.activityLogService.LogAction(userID, action, "stock_movement", movement.ID, description, "", ""); err != nil {
+		slog.Warn("Failed to audit stock movement", "movement_id", movement.ID, "error", err)
+	}
+}
+
+// notifyApprovers e-mails every admin but the requester about a pending movement
+func (s *stockService) notifyApprovers(movement models.StockMovement) {
+	if s.notifier == nil || s.userRepo == nil {
+		return
+	}
+	admins, err := s.userRepo.FindByRole("ADMIN")
+	if err != nil {
+		slog.Warn("Failed to load movement approvers", "error", err)
+		return
+	}
+
+	subject := movementLabel(&movement) + " aguardando aprovação"
+	body := fmt.Sprintf("%s\n\nMotivo: %s\nSolicitante: %s",
+		transferSummary(&movement), ptrToString(movement.ApprovalReason), transferPerformerName(&movement))
+	for _, admin := range admins {
+		if admin.ID == movement.PerformedBy || !admin.Active || admin.Email == "" {
+			continue
+		}
+		if err := s.notifier.Send(admin.Email, subject, body); err != nil && !errors.Is(err, ErrMessagingNotConfigured) {
+			slog.Warn("Failed to notify about movement", "email", admin.Email, "movement_id", movement.ID, "error", err)
+		}
+	}
+}
+
+// notifyRequester e-mails the requester the decision on their movement
+func (s *stockService) notifyRequester(movement models.StockMovement) {
+	if s.notifier == nil || movement.Performer == nil || movement.Performer.Email == "" {
+		return
+	}
+
+	decision := "aprovada"
+	if movement.Status == models.MovementStatusRejected {
+		decision = "rejeitada"
+	}
+	subject := movementLabel(&movement) + " " + decision
+	body := fmt.Sprintf("Sua solicitação foi %s.\n\n%s", decision, transferSummary(&movement))
+	if notes := ptrToString(movement.DecisionNotes); notes != "" {
+		body += "\nObservações: " + notes
+	}
+	if err := s.notifier.Send(movement.Performer.Email, subject, body); err != nil && !errors.Is(err, ErrMessagingNotConfigured) {
+		slog.Warn("Failed to notify about movement", "email", movement.Performer.Email, "movement_id", movement.ID, "error", err)
+	}
+}
+
+// movementLabel names the kind of movement waiting for approval in messages
+func movementLabel(movement *models.StockMovement) string {
+	if movement.Type == models.MovementTypeSaidaConsumoOS {
+		return "Baixa de peças acima do orçamento"
+	}
+	return "Transferência de estoque"
+}
+
+// movementAction is the audit action of an approval step, e.g. stock_transfer_approved
+func movementAction(movement *models.StockMovement, step string) string {
+	if movement.Type == models.MovementTypeSaidaConsumoOS {
+		return "stock_consumption_" + step
+	}
+	return "stock_transfer_" + step
+}
+
+func transferSummary(movement *models.StockMovement) string {
+	if movement.Type == models.MovementTypeSaidaConsumoOS {
+		from := ptrToString(movement.FromLocationID)
+		if movement.FromLocation != nil {
+			from = movement.FromLocation.Name
+		}
+		return fmt.Sprintf("%d x %s\nDe: %s\nOS: %s", movement.Quantity, transferItemName(movement), from, ptrToString(movement.TicketID))
+	}
+	from, to := ptrToString(movement.FromLocationID), ptrToString(movement.ToLocationID)
+	if movement.FromLocation != nil {
+		from = movement.FromLocation.Name
+	}
+	if movement.ToLocation != nil {
+		to = movement.ToLocation.Name
+	}
+	return fmt.Sprintf("%d x %s\nDe: %s\nPara: %s", movement.Quantity, transferItemName(movement), from, to)
+}
+
+func transferItemName(movement *models.StockMovement) string {
+	if movement.Item != nil {
+		return movement.Item.SKU + " " + movement.Item.Name
+	}
+	return movement.ItemID
+}
+
+func transferPerformerName(movement *models.StockMovement) string {
+	if movement.Performer != nil {
+		if movement.Performer.FullName != "" {
+			return movement.Performer.FullName
+		}
+		return movement.Performer.Email
+	}
+	return movement.PerformedBy
+}
@@ -0,0 +1,362 @@
+package services
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+)
+
+var (
+	ErrStorageQuotaExceeded = errors.New("storage quota exceeded")
+	ErrStorageQuotaNotFound = errors.New("storage quota not found")
+)
+
+const (
+	defaultStorageCleanupInterval = 24 * time.Hour
+	// Files younger than this are never treated as orphans, so uploads in
+	// flight (file written, record not yet saved) are not removed
+	orphanGracePeriod = 24 * time.Hour
+)
+
+type StorageService interface {
+	// CheckQuota fails with ErrStorageQuotaExceeded when adding bytes would exceed the tenant quota
+	CheckQuota(nodeID *uint, module string, bytes int64) error
+
+	GetReport() (*models.StorageReport, error)
+	FindOrphans() (*models.OrphanReport, error)
+	CleanupOrphans() (*models.StorageCleanupResult, error)
+
+	ListQuotas() ([]models.StorageQuota, error)
+	UpsertQuota(req *models.UpsertStorageQuotaRequest) (*models.StorageQuota, error)
+	DeleteQuota(id uint) error
+
+	Start(interval time.Duration)
+	Stop()
+}
+
+type storageService struct {
+	repo      repositories.StorageRepository
+	uploadDir string
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+func NewStorageService(repo repositories.StorageRepository, uploadDir string) StorageService {
+	if uploadDir == "" {
+		uploadDir = "./uploads"
+	}
+	return &storageService{repo: repo, uploadDir: uploadDir}
+}
+
+func (s *storageService) CheckQuota(nodeID *uint, module string, bytes int64) error {
+	rootID, err := s.repo.TenantRootID(nodeID)
+	if err != nil {
+		return err
+	}
+	quota, err := s.repo.FindQuota(rootID, module)
+	if err != nil {
+		return err
+	}
+	if quota == nil || quota.MaxBytes <= 0 {
+		return nil
+	}
+
+	used, err := s.repo.TenantUsage(rootID)
+	if err != nil {
+		return err
+	}
+	if used+bytes > quota.MaxBytes {
+		return ErrStorageQuotaExceeded
+	}
+	return nil
+}
+
+// =============== Report ===============
+
+func (s *storageService) GetReport() (*models.StorageReport, error) {
+	now := time.Now()
+	report := &models.StorageReport{GeneratedAt: now}
+
+	modules, err := s.diskUsageByModule()
+	if err != nil {
+		return nil, err
+	}
+	report.ByModule = modules
+	for _, m := range modules {
+		report.TotalFiles += m.Files
+		report.TotalBytes += m.Bytes
+	}
+
+	tenants, err := s.repo.UsageByTenant()
+	if err != nil {
+		return nil, err
+	}
+	for i := range tenants {
+		quota, err := s.repo.FindQuota(tenants[i].NodeID, models.StorageModuleTickets)
+		if err != nil {
+			return nil, err
+		}
+		if quota != nil && quota.MaxBytes > 0 {
+			tenants[i].QuotaBytes = quota.MaxBytes
+			tenants[i].UsagePercent = math.Round(float64(tenants[i].Bytes)/float64(quota.MaxBytes)*10000) / 100
+		}
+	}
+	report.ByTenant = tenants
+
+	if report.ByAge, err = s.repo.UsageByAge(now); err != nil {
+		return nil, err
+	}
+
+	orphans, err := s.FindOrphans()
+	if err != nil {
+		return nil, err
+	}
+	report.OrphanCount = orphans.Count
+	report.OrphanBytes = orphans.Bytes
+
+	return report, nil
+}
+
+// diskUsageByModule walks the upload directory; each top-level folder is a module
+func (s *storageService) diskUsageByModule() ([]models.StorageModuleUsage, error) {
+	usage := make(map[string]*models.StorageModuleUsage)
+	err := s.walkUploads(func(path, module string, info fs.FileInfo) {
+		m, ok := usage[module]
+		if !ok {
+			m = &models.StorageModuleUsage{Module: module}
+			usage[module] = m
+		}
+		m.Files++
+		m.Bytes += info.Size()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]models.StorageModuleUsage, 0, len(usage))
+	for _, m := range usage {
+		result = append(result, *m)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Bytes > result[j].Bytes })
+	return result, nil
+}
+
+// =============== Orphans ===============
+
+// FindOrphans lists files on disk no attachment references, plus attachments of deleted tickets
+func (s *storageService) FindOrphans() (*models.OrphanReport, error) {
+	report := &models.OrphanReport{Files: []models.OrphanFile{}}
+	cutoff := time.Now().Add(-orphanGracePeriod)
+
+	referenced, err := s.repo.ReferencedPaths()
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool, len(referenced))
+	for _, p := range referenced {
+		known[filepath.Clean(p)] = true
+	}
+	keys, err := s.repo.LocalFileKeys()
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		known[filepath.Join(s.uploadDir, key)] = true
+	}
+
+	err = s.walkUploads(func(path, module string, info fs.FileInfo) {
+		if known[filepath.Clean(path)] || info.ModTime().After(cutoff) {
+			return
+		}
+		report.Files = append(report.Files, models.OrphanFile{
+			Path:       path,
+			Module:     module,
+			Bytes:      info.Size(),
+			ModifiedAt: info.ModTime(),
+			Reason:     "UNREFERENCED_FILE",
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := s.repo.FindDeletedTicketFiles(cutoff)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range records {
+		report.Files = append(report.Files, models.OrphanFile{
+			Path:       f.FilePath,
+			Module:     models.StorageModuleTickets,
+			Bytes:      maxInt64(f.StoredBytes, f.FileSize),
+			ModifiedAt: f.CreatedAt,
+			Reason:     "DELETED_TICKET",
+			RecordID:   f.ID,
+		})
+	}
+
+	for _, f := range report.Files {
+		report.Bytes += f.Bytes
+	}
+	report.Count = len(report.Files)
+	return report, nil
+}
+
+// CleanupOrphans removes everything FindOrphans reports
+func (s *storageService) CleanupOrphans() (*models.StorageCleanupResult, error) {
+	orphans, err := s.FindOrphans()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.StorageCleanupResult{}
+	for _, orphan := range orphans.Files {
+		if orphan.RecordID == "" {
+			if !s.insideUploadDir(orphan.Path) {
+				continue
+			}
+			if err := os.Remove(orphan.Path); err != nil && !os.IsNotExist(err) {
+				slog.Warn("Failed to remove orphan file", "path", orphan.Path, "error", err)
+				continue
+			}
+			result.FilesRemoved++
+			result.BytesFreed += orphan.Bytes
+			continue
+		}
+
+		// Attachment of a deleted ticket: drop the record, the files become
+		// unreferenced and are removed together with their variants
+		if err := s.repo.DeleteTicketFile(orphan.RecordID); err != nil {
+			slog.Warn("Failed to remove attachment", "record_id", orphan.RecordID, "error", err)
+			continue
+		}
+		result.RecordsRemoved++
+		base := strings.TrimSuffix(orphan.Path, filepath.Ext(orphan.Path))
+		for _, p := range []string{orphan.Path, base + "_thumb.jpg", base + "_sanitized.jpeg", base + "_sanitized.png", base + ".webp"} {
+			if !s.insideUploadDir(p) {
+				continue
+			}
+			if err := os.Remove(p); err == nil {
+				result.FilesRemoved++
+			}
+		}
+		result.BytesFreed += orphan.Bytes
+	}
+	return result, nil
+}
+
+// Start runs the orphan cleanup periodically until Stop is called
+func (s *storageService) Start(interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultStorageCleanupInterval
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				result, err := s.CleanupOrphans()
+				if err != nil {
+					slog.Warn("Storage cleanup failed", "error", err)
+					continue
+				}
+				if result.FilesRemoved > 0 || result.RecordsRemoved > 0 {
+					slog.Info("Storage cleanup completed", "files_removed", result.FilesRemoved, "records_removed", result.RecordsRemoved, "bytes_freed", result.BytesFreed)
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (s *storageService) Stop() {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+		s.stop = nil
+	}
+}
+
+func (s *storageService) walkUploads(fn func(path, module string, info fs.FileInfo)) error {
+	err := filepath.WalkDir(s.uploadDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		module := "root"
+		if rel, err := filepath.Rel(s.uploadDir, path); err == nil {
+			if parts := strings.SplitN(filepath.ToSlash(rel), "/", 2); len(parts) == 2 {
+				module = parts[0]
+			}
+		}
+		fn(path, module, info)
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *storageService) insideUploadDir(path string) bool {
+	rel, err := filepath.Rel(s.uploadDir, path)
+	return err == nil && rel != "." && !strings.HasPrefix(rel, "..")
+}
+
+// =============== Quotas ===============
+
+func (s *storageService) ListQuotas() ([]models.StorageQuota, error) {
+	return s.repo.ListQuotas()
+}
+
+func (s *storageService) UpsertQuota(req *models.UpsertStorageQuotaRequest) (*models.StorageQuota, error) {
+	// Quotas are accounted per tenant, so a sub-node resolves to its root
+	rootID, err := s.repo.TenantRootID(req.NodeID)
+	if err != nil {
+		return nil, err
+	}
+	quota := &models.StorageQuota{
+		NodeID:   rootID,
+		Module:   strings.ToLower(strings.TrimSpace(req.Module)),
+		MaxBytes: req.MaxBytes,
+	}
+	if err := s.repo.UpsertQuota(quota); err != nil {
+		return nil, err
+	}
+	return quota, nil
+}
+
+func (s *storageService) DeleteQuota(id uint) error {
+	if err := s.repo.DeleteQuota(id); err != nil {
+		return ErrStorageQuotaNotFound
+	}
+	return nil
+}
+
+func maxInt64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSupplierNotFound   = errors.New("supplier not found")
+	ErrSupplierInactive   = errors.New("supplier is inactive")
+	ErrSupplierCNPJExists = errors.New("a supplier with this CNPJ already exists")
+)
+
+// SupplierService manages the vendors stock purchases and expenses are linked to
+type SupplierService interface {
+	List(page, size int, filters *models.SupplierFilters) (*models.PaginatedResponse, error)
+	Get(id string) (*models.Supplier, error)
+	Create(userID string, req *models.SupplierRequest) (*models.Supplier, error)
+	Update(id string, req *models.SupplierRequest) (*models.Supplier, error)
+	Delete(id string) error
+}
+
+type supplierService struct {
+	repo repositories.SupplierRepository
+}
+
+func NewSupplierService(repo repositories.SupplierRepository) SupplierService {
+	return &supplierService{repo: repo}
+}
+
+func (s *supplierService) List(page, size int, filters *models.SupplierFilters) (*models.PaginatedResponse, error) {
+	suppliers, total, err := s.repo.FindAll(page, size, filters)
+	if err != nil {
+		return nil, err
+	}
+	return models.NewPaginatedResponse(suppliers, page, size, total), nil
+}
+
+func (s *supplierService) Get(id string) (*models.Supplier, error) {
+	supplier, err := s.repo.FindByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSupplierNotFound
+		}
+		return nil, err
+	}
+	return supplier, nil
+}
+
+func (s *supplierService) Create(userID string, req *models.SupplierRequest) (*models.Supplier, error) {
+	supplier := &models.Supplier{Active: true, CreatedBy: userID}
+	if err := s.applySupplier(supplier, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(supplier); err != nil {
+		return nil, err
+	}
+	return supplier, nil
+}
+
+func (s *supplierService) Update(id string, req *models.SupplierRequest) (*models.Supplier, error) {
+	supplier, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.applySupplier(supplier, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Update(supplier); err != nil {
+		return nil, err
+	}
+	return supplier, nil
+}
+
+func (s *supplierService) Delete(id string) error {
+	if err := s.repo.Delete(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSupplierNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// applySupplier checks the CNPJ is not taken by another supplier and copies the request
+// into the supplier
+func (s *supplierService) applySupplier(supplier *models.Supplier, req *models.SupplierRequest) error {
+	cnpj := strings.TrimSpace(req.CNPJ)
+	if cnpj != "" {
+		exists, err := s.repo.ExistsCNPJ(cnpj, supplier.ID)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return ErrSupplierCNPJExists
+		}
+	}
+
+	supplier.Name = strings.TrimSpace(req.Name)
+	supplier.TradeName = req.TradeName
+	supplier.CNPJ = cnpj
+	supplier.Email = req.Email
+	supplier.Phone = req.Phone
+	supplier.ContactName = req.ContactName
+	supplier.Street = req.Street
+	supplier.Number = req.Number
+	supplier.Complement = req.Complement
+	supplier.Neighborhood = req.Neighborhood
+	supplier.City = req.City
+	supplier.State = strings.ToUpper(req.State)
+	supplier.ZipCode = req.ZipCode
+	supplier.Notes = req.Notes
+	if req.Active != nil {
+		supplier.Active = *req.Active
+	}
+	return nil
+}
+
+// activeSupplier loads a supplier a movement or entry is being linked to
+func activeSupplier(repo repositories.SupplierRepository, id string) error {
+	supplier, err := repo.FindByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSupplierNotFound
+		}
+		return err
+	}
+	if !supplier.Active {
+		return ErrSupplierInactive
+	}
+	return nil
+}
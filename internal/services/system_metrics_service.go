@@ -18,6 +18,8 @@ var (
 
 type SystemMetricsService interface {
 	GetMetrics() (*models.SystemMetrics, error)
+	GetLatencyBudgets() ([]models.LatencyBudgetStatus, error)
+	GetPayloadSizes() ([]models.RoutePayload, error)
 }
 
 type systemMetricsService struct {
@@ -26,6 +28,7 @@ type systemMetricsService struct {
 	userRepo        repositories.UserRepository
 	ticketRepo      repositories.TicketRepository
 	securityLogRepo repositories.SecurityLogRepository
+	requestMetrics  RequestMetricsService
 }
 
 func NewSystemMetricsService(
@@ -34,6 +37,7 @@ func NewSystemMetricsService(
 	userRepo repositories.UserRepository,
 	ticketRepo repositories.TicketRepository,
 	securityLogRepo repositories.SecurityLogRepository,
+	requestMetrics RequestMetricsService,
 ) SystemMetricsService {
 	return &systemMetricsService{
 		db:              db,
@@ -41,6 +45,7 @@ func NewSystemMetricsService(
 		userRepo:        userRepo,
 		ticketRepo:      ticketRepo,
 		securityLogRepo: securityLogRepo,
+		requestMetrics:  requestMetrics,
 	}
 }
 
@@ -67,7 +72,12 @@ func (s *systemMetricsService) GetMetrics() (*models.SystemMetrics, error) {
 	openTickets := s.getOpenTickets()
 
 	// Get request metrics (last 24h)
-	totalRequests, avgResponseTime, errorRate := s.getRequestMetrics(sqlDB)
+	totalRequests, avgResponseTime, p95ResponseTime, errorRate := s.getRequestMetrics(sqlDB)
+
+	latencyBudgets, err := s.requestMetrics.GetLatencyBudgets()
+	if err != nil {
+		latencyBudgets = []models.LatencyBudgetStatus{}
+	}
 
 	metrics := &models.SystemMetrics{
 		// Server info
@@ -92,6 +102,8 @@ func (s *systemMetricsService) GetMetrics() (*models.SystemMetrics, error) {
 		TotalRequests:   totalRequests,
 		AvgResponseTime: avgResponseTime,
 		ErrorRate:       errorRate,
+		P95ResponseTime: p95ResponseTime,
+		LatencyBudgets:  latencyBudgets,
 
 		// Cache metrics
 		CacheHitRate: s.getCacheHitRate(),
@@ -141,9 +153,18 @@ func (s *systemMetricsService) getOpenTickets() int64 {
 	return count
 }
 
-func (s *systemMetricsService) getRequestMetrics(sqlDB *sql.DB) (int64, float64, float64) {
+func (s *systemMetricsService) GetLatencyBudgets() ([]models.LatencyBudgetStatus, error) {
+	return s.requestMetrics.GetLatencyBudgets()
+}
+
+func (s *systemMetricsService) GetPayloadSizes() ([]models.RoutePayload, error) {
+	return s.requestMetrics.GetPayloadSizes()
+}
+
+func (s *systemMetricsService) getRequestMetrics(sqlDB *sql.DB) (int64, float64, float64, float64) {
 	var totalRequests int64
 	var avgResponseTime float64
+	var p95ResponseTime float64
 	var errorCount int64
 
 	since := time.Now().Add(-24 * time.Hour)
@@ -157,6 +178,12 @@ func (s *systemMetricsService) getRequestMetrics(sqlDB *sql.DB) (int64, float64,
 		Select("COALESCE(AVG(response_time), 0)").
 		Row().Scan(&avgResponseTime)
 
+	// p95 response time
+	s.db.Model(&models.RequestMetric{}).
+		Where("created_at >= ?", since).
+		Select("COALESCE(PERCENTILE_CONT(0.95) WITHIN GROUP (ORDER BY response_time), 0)").
+		Row().Scan(&p95ResponseTime)
+
 	// Error count (5xx status codes)
 	s.db.Model(&models.RequestMetric{}).
 		Where("created_at >= ?", since).
@@ -168,7 +195,7 @@ func (s *systemMetricsService) getRequestMetrics(sqlDB *sql.DB) (int64, float64,
 		errorRate = float64(errorCount) / float64(totalRequests) * 100
 	}
 
-	return totalRequests, avgResponseTime, errorRate
+	return totalRequests, avgResponseTime, p95ResponseTime, errorRate
 }
 
 func (s *systemMetricsService) getCacheHitRate() float64 {
@@ -0,0 +1,553 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTeamQueueNotFound       = errors.New("team queue not found")
+	ErrTeamQueueInactive       = errors.New("team queue is inactive")
+	ErrTeamQueueNodeNotFound   = errors.New("hierarchy node not found")
+	ErrTeamQueueTicketNotFound = errors.New("ticket not found")
+	ErrTicketNotQueued         = errors.New("ticket is not in any team queue")
+	ErrRebalanceSameQueue      = errors.New("source and target queues must differ")
+	ErrInvalidDistributionMode = errors.New("invalid distribution policy")
+)
+
+const (
+	defaultDistributionInterval = time.Minute
+	distributionBatchSize       = 200
+	defaultRebalanceLimit       = 10
+)
+
+// TeamQueueService distributes incoming unowned tickets across team queues
+type TeamQueueService interface {
+	ListQueues() ([]models.TeamQueueLoad, error)
+	CreateQueue(req *models.TeamQueueRequest) (*models.TeamQueue, error)
+	UpdateQueue(id string, req *models.TeamQueueRequest) (*models.TeamQueue, error)
+	GetQueueTickets(queueID string, page, size int) (*models.PaginatedResponse, error)
+
+	GetSettings() (*models.TeamQueueSettings, error)
+	UpdateSettings(req *models.UpdateTeamQueueSettingsRequest, userID string) (*models.TeamQueueSettings, error)
+
+	Metrics() ([]models.TeamQueueMetrics, error)
+	GetTicketQueue(ticketID string) (*models.TicketQueueAssignment, error)
+	MoveTicket(ticketID string, req *models.MoveTicketQueueRequest, userID string) (*models.TicketQueueAssignment, error)
+	Rebalance(req *models.RebalanceRequest, userID string) (*models.DistributionResult, error)
+
+	// Distribute queues the unowned tickets that are in no queue yet
+	Distribute() (*models.DistributionResult, error)
+	Start(interval time.Duration)
+	Stop()
+}
+
+type teamQueueService struct {
+	repo               repositories.TeamQueueRepository
+	hierarchyRepo      repositories.HierarchyRepository
+	ticketRepo         repositories.TicketRepository
+	activityLogService ActivityLogService
+
+	mu   sync.Mutex // serializes distribution and rebalancing
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewTeamQueueService(
+	repo repositories.TeamQueueRepository,
+	hierarchyRepo repositories.HierarchyRepository,
+	ticketRepo repositories.TicketRepository,
+	activityLogService ActivityLogService,
+) TeamQueueService {
+	return &teamQueueService{
+		repo:               repo,
+		hierarchyRepo:      hierarchyRepo,
+		ticketRepo:         ticketRepo,
+		activityLogService: activityLogService,
+	}
+}
+
+// =============== Queues ===============
+
+func (s *teamQueueService) ListQueues() ([]models.TeamQueueLoad, error) {
+	return s.repo.FindQueues(false)
+}
+
+func (s *teamQueueService) CreateQueue(req *models.TeamQueueRequest) (*models.TeamQueue, error) {
+	queue := &models.TeamQueue{Active: true}
+	if err := s.applyQueueRequest(queue, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateQueue(queue); err != nil {
+		return nil, err
+	}
+	return queue, nil
+}
+
+func (s *teamQueueService) UpdateQueue(id string, req *models.TeamQueueRequest) (*models.TeamQueue, error) {
+	queue, err := s.findQueue(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.applyQueueRequest(queue, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateQueue(queue); err != nil {
+		return nil, err
+	}
+	return queue, nil
+}
+
+func (s *teamQueueService) applyQueueRequest(queue *models.TeamQueue, req *models.TeamQueueRequest) error {
+	if _, err := s.hierarchyRepo.GetNodeByID(req.NodeID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTeamQueueNodeNotFound
+		}
+		return err
+	}
+	queue.Name = strings.TrimSpace(req.Name)
+	queue.NodeID = req.NodeID
+	queue.Weight = req.Weight
+	if req.Active != nil {
+		queue.Active = *req.Active
+	}
+	queue.Node = nil
+	return nil
+}
+
+func (s *teamQueueService) GetQueueTickets(queueID string, page, size int) (*models.PaginatedResponse, error) {
+	if _, err := s.findQueue(queueID); err != nil {
+		return nil, err
+	}
+	tickets, total, err := s.repo.FindQueueTickets(queueID, page, size)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewPaginatedResponse(tickets, page, size, total), nil
+}
+
+func (s *teamQueueService) findQueue(id string) (*models.TeamQueue, error) {
+	queue, err := s.repo.FindQueueByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTeamQueueNotFound
+		}
+		return nil, err
+	}
+	return queue, nil
+}
+
+// =============== Settings ===============
+
+func (s *teamQueueService) GetSettings() (*models.TeamQueueSettings, error) {
+	return s.repo.GetSettings()
+}
+
+func (s *teamQueueService) UpdateSettings(req *models.UpdateTeamQueueSettingsRequest, userID string) (*models.TeamQueueSettings, error) {
+	policy := models.DistributionPolicy(req.Policy)
+	if !policy.IsValid() {
+		return nil, ErrInvalidDistributionMode
+	}
+	settings, err := s.repo.GetSettings()
+	if err != nil {
+		return nil, err
+	}
+	previous := settings.Policy
+	settings.Policy = policy
+	settings.UpdatedBy = userID
+	if err := s.repo.SaveSettings(settings); err != nil {
+		return nil, err
+	}
+
+	if previous != policy {
+		description := fmt.Sprintf("Alterou a política de distribuição de %s para %s", previous, policy)
+		if err := s.activityLogService.LogAction(userID, "distribution_policy_changed", "team_queue", "", description, "", ""); err != nil {
+			slog.Warn("Failed to audit distribution policy change", "error", err)
+		}
+	}
+	return settings, nil
+}
+
+// =============== Metrics ===============
+
+func (s *teamQueueService) Metrics() ([]models.TeamQueueMetrics, error) {
+	queues, err := s.repo.FindQueues(false)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.repo.FindBacklogRows()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	assigned, err := s.repo.CountAssignedSince(now.Add(-24 * time.Hour))
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := make([]models.TeamQueueMetrics, 0, len(queues))
+	byQueue := make(map[string]*models.TeamQueueMetrics, len(queues))
+	for _, q := range queues {
+		metrics = append(metrics, models.TeamQueueMetrics{
+			QueueID:         q.ID,
+			Name:            q.Name,
+			NodeID:          q.NodeID,
+			Active:          q.Active,
+			Headcount:       q.Headcount,
+			ByPriority:      make(map[models.TicketPriority]int64),
+			AssignedLast24h: assigned[q.ID],
+		})
+	}
+	for i := range metrics {
+		byQueue[metrics[i].QueueID] = &metrics[i]
+	}
+
+	for _, row := range rows {
+		m, ok := byQueue[row.QueueID]
+		if !ok {
+			continue
+		}
+		m.Backlog += row.Count
+		m.Unowned += row.Unowned
+		m.ByPriority[row.Priority] += row.Count
+		if m.OldestOpenAt == nil || row.OldestOpenAt.Before(*m.OldestOpenAt) {
+			oldest := row.OldestOpenAt
+			m.OldestOpenAt = &oldest
+		}
+	}
+	for i := range metrics {
+		m := &metrics[i]
+		if m.Headcount > 0 {
+			m.BacklogPerHead = math.Round(float64(m.Backlog)*100/float64(m.Headcount)) / 100
+		}
+		if m.OldestOpenAt != nil {
+			m.OldestAgeHours = roundHours(now.Sub(*m.OldestOpenAt))
+		}
+	}
+	return metrics, nil
+}
+
+// =============== Assignment ===============
+
+func (s *teamQueueService) GetTicketQueue(ticketID string) (*models.TicketQueueAssignment, error) {
+	assignment, err := s.repo.FindAssignment(ticketID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTicketNotQueued
+		}
+		return nil, err
+	}
+	return assignment, nil
+}
+
+func (s *teamQueueService) MoveTicket(ticketID string, req *models.MoveTicketQueueRequest, userID string) (*models.TicketQueueAssignment, error) {
+	if _, err := s.ticketRepo.FindByID(ticketID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTeamQueueTicketNotFound
+		}
+		return nil, err
+	}
+	queue, err := s.findQueue(req.QueueID)
+	if err != nil {
+		return nil, err
+	}
+	if !queue.Active {
+		return nil, ErrTeamQueueInactive
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	assignment := &models.TicketQueueAssignment{
+		TicketID:   ticketID,
+		QueueID:    queue.ID,
+		Method:     models.QueueAssignmentManual,
+		AssignedBy: userID,
+		AssignedAt: time.Now(),
+	}
+	moved, err := s.repo.Assign(assignment, true)
+	if err != nil {
+		return nil, err
+	}
+	if moved {
+		description := fmt.Sprintf("Moveu o chamado para a fila %s", queue.Name)
+		if err := s.activityLogService.LogAction(userID, "ticket_queue_moved", "ticket", ticketID, description, "", ""); err != nil {
+			slog.Warn("Failed to audit queue move of ticket", "ticket_id", ticketID, "error", err)
+		}
+	}
+	return s.repo.FindAssignment(ticketID)
+}
+
+func (s *teamQueueService) Rebalance(req *models.RebalanceRequest, userID string) (*models.DistributionResult, error) {
+	from, err := s.findQueue(req.FromQueueID)
+	if err != nil {
+		return nil, err
+	}
+	if req.ToQueueID == from.ID {
+		return nil, ErrRebalanceSameQueue
+	}
+	if req.ToQueueID != "" {
+		to, err := s.findQueue(req.ToQueueID)
+		if err != nil {
+			return nil, err
+		}
+		if !to.Active {
+			return nil, ErrTeamQueueInactive
+		}
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultRebalanceLimit
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	settings, err := s.repo.GetSettings()
+	if err != nil {
+		return nil, err
+	}
+	queues, err := s.repo.FindQueues(true)
+	if err != nil {
+		return nil, err
+	}
+	targets := make([]*models.TeamQueueLoad, 0, len(queues))
+	for i := range queues {
+		if queues[i].ID == from.ID {
+			continue
+		}
+		if req.ToQueueID == "" || queues[i].ID == req.ToQueueID {
+			targets = append(targets, &queues[i])
+		}
+	}
+
+	tickets, err := s.repo.FindRebalanceCandidates(from.ID, limit)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.assignAll(tickets, targets, settings.Policy, models.QueueAssignmentRebalance, userID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Assigned > 0 {
+		description := fmt.Sprintf("Rebalanceou %d chamados da fila %s", result.Assigned, from.Name)
+		if err := s.activityLogService.LogAction(userID, "team_queue_rebalanced", "team_queue", from.ID, description, "", ""); err != nil {
+			slog.Warn("Failed to audit rebalancing of queue", "from_id", from.ID, "error", err)
+		}
+	}
+	return result, nil
+}
+
+func (s *teamQueueService) Distribute() (*models.DistributionResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	settings, err := s.repo.GetSettings()
+	if err != nil {
+		return nil, err
+	}
+	queues, err := s.repo.FindQueues(true)
+	if err != nil {
+		return nil, err
+	}
+	result := &models.DistributionResult{Policy: settings.Policy, ByQueue: make(map[string]int)}
+	if len(queues) == 0 {
+		return result, nil
+	}
+
+	tickets, err := s.repo.FindUnqueuedTickets(distributionBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	targets := make([]*models.TeamQueueLoad, len(queues))
+	for i := range queues {
+		targets[i] = &queues[i]
+	}
+	return s.assignAll(tickets, targets, settings.Policy, models.QueueAssignmentAuto, "", false)
+}
+
+// assignAll picks a queue for every ticket, keeping the in-memory counters up to date so
+// that a single pass spreads the tickets the same way successive passes would
+func (s *teamQueueService) assignAll(
+	tickets []models.Ticket,
+	queues []*models.TeamQueueLoad,
+	policy models.DistributionPolicy,
+	method models.QueueAssignmentMethod,
+	actorID string,
+	replace bool,
+) (*models.DistributionResult, error) {
+	result := &models.DistributionResult{Policy: policy, ByQueue: make(map[string]int)}
+
+	var nodeIDs []uint
+	for _, t := range tickets {
+		if t.NodeID != nil {
+			nodeIDs = append(nodeIDs, *t.NodeID)
+		}
+	}
+	paths, err := s.repo.FindNodePaths(nodeIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, ticket := range tickets {
+		ticketPath := ""
+		if ticket.NodeID != nil {
+			ticketPath = paths[*ticket.NodeID]
+		}
+		queue := pickQueue(eligibleQueues(ticketPath, queues), policy)
+		if queue == nil {
+			result.Skipped++
+			continue
+		}
+
+		assigned, err := s.repo.Assign(&models.TicketQueueAssignment{
+			TicketID:   ticket.ID,
+			QueueID:    queue.ID,
+			Method:     method,
+			Policy:     policy,
+			AssignedBy: actorID,
+			AssignedAt: now,
+		}, replace)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", ticket.ID, err))
+			continue
+		}
+		if !assigned {
+			continue
+		}
+		queue.AssignedCount++
+		queue.Backlog++
+		result.Assigned++
+		result.ByQueue[queue.ID]++
+	}
+	return result, nil
+}
+
+// eligibleQueues returns the queues of the deepest node on the ticket's path that has any;
+// tickets outside every queue's subtree, or without node, may go to any queue
+func eligibleQueues(ticketPath string, queues []*models.TeamQueueLoad) []*models.TeamQueueLoad {
+	if ticketPath == "" {
+		return queues
+	}
+	var eligible []*models.TeamQueueLoad
+	depth := -1
+	for _, q := range queues {
+		if q.NodePath == "" || (ticketPath != q.NodePath && !strings.HasPrefix(ticketPath, q.NodePath+".")) {
+			continue
+		}
+		d := strings.Count(q.NodePath, ".")
+		if d > depth {
+			eligible, depth = nil, d
+		}
+		if d == depth {
+			eligible = append(eligible, q)
+		}
+	}
+	if len(eligible) == 0 {
+		return queues
+	}
+	return eligible
+}
+
+// pickQueue returns the queue with the lowest score for the policy; ties go by name
+func pickQueue(queues []*models.TeamQueueLoad, policy models.DistributionPolicy) *models.TeamQueueLoad {
+	candidates := queues
+	if policy == models.DistributionWeighted {
+		// teams without anyone can't take tickets, unless no team has anyone
+		var staffed []*models.TeamQueueLoad
+		for _, q := range queues {
+			if queueWeight(q) > 0 {
+				staffed = append(staffed, q)
+			}
+		}
+		if len(staffed) > 0 {
+			candidates = staffed
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	score := func(q *models.TeamQueueLoad) float64 {
+		weight := math.Max(float64(queueWeight(q)), 1)
+		switch policy {
+		case models.DistributionWeighted:
+			return float64(q.AssignedCount+1) / weight
+		case models.DistributionLeastLoaded:
+			return float64(q.Backlog) / weight
+		default:
+			return float64(q.AssignedCount)
+		}
+	}
+	sorted := append([]*models.TeamQueueLoad(nil), candidates...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		si, sj := score(sorted[i]), score(sorted[j])
+		if si != sj {
+			return si < sj
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted[0]
+}
+
+// queueWeight is the configured weight of a queue, or the headcount of its node
+func queueWeight(q *models.TeamQueueLoad) int64 {
+	if q.Weight != nil {
+		return int64(*q.Weight)
+	}
+	return q.Headcount
+}
+
+// Start runs the distribution periodically in the background until Stop is called
+func (s *teamQueueService) Start(interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultDistributionInterval
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				result, err := s.Distribute()
+				if err != nil {
+					slog.Warn("Team queue distribution failed", "error", err)
+					continue
+				}
+				for _, e := range result.Errors {
+					slog.Warn("Team queue distribution failed", "error", e)
+				}
+				if result.Assigned > 0 {
+					slog.Info("Team queue distribution assigned tickets", "assigned", result.Assigned, "policy", result.Policy)
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (s *teamQueueService) Stop() {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+		s.stop = nil
+	}
+}
@@ -0,0 +1,218 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"gorm.io/gorm"
+)
+
+var ErrNotATechnician = errors.New("user is not linked to a technician")
+
+// homeTimezone is the day boundary of the technician home (tickets of the day)
+const homeTimezone = "America/Sao_Paulo"
+
+type TechnicianHomeService interface {
+	// GetHome assembles the home screen of the technician linked to the user
+	GetHome(userID string) (*models.TechnicianHome, error)
+}
+
+type technicianHomeService struct {
+	repo           repositories.TechnicianHomeRepository
+	technicianRepo repositories.TechnicianRepository
+	geoRepo        *repositories.GeoRepository
+	onCallService  OnCallService
+}
+
+func NewTechnicianHomeService(repo repositories.TechnicianHomeRepository, technicianRepo repositories.TechnicianRepository, geoRepo *repositories.GeoRepository, onCallService OnCallService) TechnicianHomeService {
+	return &technicianHomeService{
+		repo:           repo,
+		technicianRepo: technicianRepo,
+		geoRepo:        geoRepo,
+		onCallService:  onCallService,
+	}
+}
+
+// GetHome loads the sections of the home in parallel, each one writing its own fields;
+// the stock and its pending counts wait for the technician's locations
+func (s *technicianHomeService) GetHome(userID string) (*models.TechnicianHome, error) {
+	technician, err := s.technicianRepo.FindByUserID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotATechnician
+		}
+		return nil, err
+	}
+
+	loc, err := time.LoadLocation(homeTimezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	now := time.Now().In(loc)
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	dayEnd := dayStart.AddDate(0, 0, 1)
+
+	home := &models.TechnicianHome{
+		TechnicianID:   technician.ID,
+		TechnicianName: technician.FullName,
+		Date:           dayStart.Format("2006-01-02"),
+		Tickets:        []models.TechnicianHomeTicket{},
+		Stock:          []models.TechnicianHomeStock{},
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	run := func(section func() error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := section(); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	run(func() error {
+		tickets, err := s.repo.FindDayTickets(technician.ID, dayStart, dayEnd)
+		if err != nil {
+			return err
+		}
+		for i := range tickets {
+			home.Tickets = append(home.Tickets, homeTicket(&tickets[i]))
+		}
+		return nil
+	})
+
+	run(func() error {
+		locations, err := s.repo.FindTechnicianLocations(technician.ID)
+		if err != nil {
+			return err
+		}
+		ids := make([]string, len(locations))
+		for i, location := range locations {
+			ids[i] = location.ID
+		}
+		if home.Stock, err = s.repo.FindLocationStock(ids); err != nil {
+			return err
+		}
+		if home.Pending.IncomingTransfer, err = s.repo.CountIncomingTransfers(ids); err != nil {
+			return err
+		}
+		home.Pending.CountTasks, err = s.repo.CountPendingCountTasks(ids, dayEnd)
+		return err
+	})
+
+	run(func() error {
+		pages, err := s.onCallService.MyPages(userID)
+		if err != nil {
+			return err
+		}
+		home.Pending.OnCallPages = len(pages)
+		return nil
+	})
+
+	run(func() error {
+		shift, err := s.onCallShift(technician.ID, now)
+		if err != nil {
+			return err
+		}
+		// Geo locations are recorded by user; no location yet just means not checked in
+		if last, err := s.geoRepo.GetLastLocation(userID); err == nil {
+			shift.LastEvent = last.EventType
+			shift.LastSeenAt = &last.ServerTime
+			shift.CheckedIn = last.EventType != models.EventTypeCheckout
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		home.Shift = *shift
+		return nil
+	})
+
+	wg.Wait()
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return home, nil
+}
+
+// onCallShift finds the rotations the technician belongs to and reports the best level
+// held right now (0 = primary)
+func (s *technicianHomeService) onCallShift(technicianID string, at time.Time) (*models.TechnicianShiftStatus, error) {
+	shift := &models.TechnicianShiftStatus{}
+
+	schedules, err := s.onCallService.ListSchedules()
+	if err != nil {
+		return nil, err
+	}
+	for i := range schedules {
+		if !schedules[i].Active || !hasMember(&schedules[i], technicianID) {
+			continue
+		}
+		now, err := s.onCallService.WhoIsOnCall(schedules[i].ID, at)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range now.EscalationOrder {
+			if entry.TechnicianID != technicianID {
+				continue
+			}
+			if shift.OnCallLevel == nil || entry.Level < *shift.OnCallLevel {
+				level := entry.Level
+				shiftEnds := now.ShiftEndsAt
+				shift.OnCallLevel = &level
+				shift.OnCall = level == 0
+				shift.ScheduleName = now.ScheduleName
+				shift.ShiftEndsAt = &shiftEnds
+			}
+			break
+		}
+	}
+	return shift, nil
+}
+
+func hasMember(schedule *models.OnCallSchedule, technicianID string) bool {
+	for _, member := range schedule.Members {
+		if member.TechnicianID == technicianID {
+			return true
+		}
+	}
+	return false
+}
+
+func homeTicket(ticket *models.Ticket) models.TechnicianHomeTicket {
+	item := models.TechnicianHomeTicket{
+		ID:               ticket.ID,
+		OSNumber:         ticket.OSNumber,
+		Status:           ticket.Status,
+		Priority:         ticket.Priority,
+		ErrorDescription: ticket.ErrorDescription,
+		ScheduledStart:   ticket.ScheduledStart,
+		ScheduledEnd:     ticket.ScheduledEnd,
+	}
+	if ticket.Category != nil {
+		item.Category = ticket.Category.Name
+	}
+	if client := ticket.Client; client != nil {
+		item.Client = &models.HomeClient{
+			ID:           client.ID,
+			Name:         client.FullName,
+			Phone:        client.Phone,
+			Street:       client.Street,
+			Number:       client.Number,
+			Complement:   client.Complement,
+			Neighborhood: client.Neighborhood,
+			City:         client.City,
+			State:        client.State,
+			ZipCode:      client.ZipCode,
+		}
+	}
+	return item
+}
@@ -0,0 +1,473 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTechnicianScheduleNotFound = errors.New("technician schedule not found")
+	ErrInvalidTechnicianSchedule  = errors.New("invalid technician schedule")
+	ErrTimeOffNotFound            = errors.New("time off not found")
+	ErrInvalidTimeOff             = errors.New("invalid time off")
+	ErrInvalidAvailabilityRange   = errors.New("invalid range, expected start before end")
+)
+
+const (
+	defaultScheduleTimezone = "America/Sao_Paulo"
+	maxTimeOffDays          = 366
+)
+
+// TechnicianScheduleService manages the working weeks and time off of the technicians and
+// checks their availability for assignments
+type TechnicianScheduleService interface {
+	GetSchedule(technicianID string) (*models.TechnicianSchedule, error)
+	// SaveSchedule replaces the working week of the technician
+	SaveSchedule(technicianID, userID string, req *models.TechnicianScheduleRequest) (*models.TechnicianSchedule, error)
+	// DeleteSchedule makes the technician always available again
+	DeleteSchedule(technicianID, userID string) error
+
+	ListTimeOff(technicianID string, from, to time.Time) ([]models.TechnicianTimeOff, error)
+	AddTimeOff(technicianID, userID string, req *models.TechnicianTimeOffRequest) (*models.TechnicianTimeOff, error)
+	DeleteTimeOff(technicianID, timeOffID, userID string) error
+
+	// Availability checks the technician over a period
+	Availability(technicianID string, start, end time.Time) (*models.TechnicianAvailability, error)
+	// AssignmentConflicts checks the technicians against the ticket appointment, or against
+	// the current moment when the ticket is not scheduled
+	AssignmentConflicts(ticketID string, technicianIDs []string) ([]models.AvailabilityConflict, error)
+	// OnDuty returns the technicians within working hours at a moment
+	OnDuty(at time.Time) (*models.OnDutySummary, error)
+}
+
+type technicianScheduleService struct {
+	repo               repositories.TechnicianScheduleRepository
+	technicianRepo     repositories.TechnicianRepository
+	ticketRepo         repositories.TicketRepository
+	activityLogService ActivityLogService
+}
+
+func NewTechnicianScheduleService(
+	repo repositories.TechnicianScheduleRepository,
+	technicianRepo repositories.TechnicianRepository,
+	ticketRepo repositories.TicketRepository,
+	activityLogService ActivityLogService,
+) TechnicianScheduleService {
+	return &technicianScheduleService{
+		repo:               repo,
+		technicianRepo:     technicianRepo,
+		ticketRepo:         ticketRepo,
+		activityLogService: activityLogService,
+	}
+}
+
+// =============== Working weeks ===============
+
+func (s *technicianScheduleService) GetSchedule(technicianID string) (*models.TechnicianSchedule, error) {
+	schedule, err := s.repo.FindByTechnician(technicianID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTechnicianScheduleNotFound
+		}
+		return nil, err
+	}
+	return schedule, nil
+}
+
+func (s *technicianScheduleService) SaveSchedule(technicianID, userID string, req *models.TechnicianScheduleRequest) (*models.TechnicianSchedule, error) {
+	technician, err := s.technicianRepo.FindByID(technicianID)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule, err := s.repo.FindByTechnician(technicianID)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		schedule = &models.TechnicianSchedule{TechnicianID: technicianID, Timezone: defaultScheduleTimezone}
+	}
+	if req.Timezone != "" {
+		if _, err := time.LoadLocation(req.Timezone); err != nil {
+			return nil, fmt.Errorf("%w: unknown timezone %s", ErrInvalidTechnicianSchedule, req.Timezone)
+		}
+		schedule.Timezone = req.Timezone
+	}
+
+	hours := make([]models.TechnicianWorkingHours, 0, len(req.WorkingHours))
+	for _, input := range req.WorkingHours {
+		start, errStart := parseClock(input.Start)
+		end, errEnd := parseClock(input.End)
+		if errStart != nil || errEnd != nil {
+			return nil, fmt.Errorf("%w: times must be HH:MM", ErrInvalidTechnicianSchedule)
+		}
+		if end <= start {
+			return nil, fmt.Errorf("%w: %s %s-%s must end after it starts", ErrInvalidTechnicianSchedule,
+				time.Weekday(input.Weekday), input.Start, input.End)
+		}
+		for _, other := range hours {
+			if other.Weekday != input.Weekday {
+				continue
+			}
+			otherStart, _ := parseClock(other.Start)
+			otherEnd, _ := parseClock(other.End)
+			if start < otherEnd && otherStart < end {
+				return nil, fmt.Errorf("%w: %s periods overlap", ErrInvalidTechnicianSchedule, time.Weekday(input.Weekday))
+			}
+		}
+		hours = append(hours, models.TechnicianWorkingHours{
+			Weekday: input.Weekday,
+			Start:   formatClock(start),
+			End:     formatClock(end),
+		})
+	}
+	schedule.WorkingHours = hours
+	schedule.UpdatedBy = userID
+
+	if err := s.repo.Save(schedule); err != nil {
+		return nil, err
+	}
+	s.audit(userID, "UPDATE", technicianID, fmt.Sprintf("Jornada de %s atualizada (%d períodos)", technician.FullName, len(hours)))
+	return s.GetSchedule(technicianID)
+}
+
+func (s *technicianScheduleService) DeleteSchedule(technicianID, userID string) error {
+	if err := s.repo.Delete(technicianID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTechnicianScheduleNotFound
+		}
+		return err
+	}
+	s.audit(userID, "DELETE", technicianID, "Jornada removida")
+	return nil
+}
+
+// =============== Time off ===============
+
+func (s *technicianScheduleService) ListTimeOff(technicianID string, from, to time.Time) ([]models.TechnicianTimeOff, error) {
+	if _, err := s.technicianRepo.FindByID(technicianID); err != nil {
+		return nil, err
+	}
+	return s.repo.FindTimeOff([]string{technicianID}, from.Format("2006-01-02"), to.Format("2006-01-02"))
+}
+
+func (s *technicianScheduleService) AddTimeOff(technicianID, userID string, req *models.TechnicianTimeOffRequest) (*models.TechnicianTimeOff, error) {
+	technician, err := s.technicianRepo.FindByID(technicianID)
+	if err != nil {
+		return nil, err
+	}
+
+	startDate, err := time.Parse("2006-01-02", req.StartDate)
+	if err != nil {
+		return nil, fmt.Errorf("%w: startDate must be YYYY-MM-DD", ErrInvalidTimeOff)
+	}
+	endDate := startDate
+	if req.EndDate != "" {
+		if endDate, err = time.Parse("2006-01-02", req.EndDate); err != nil {
+			return nil, fmt.Errorf("%w: endDate must be YYYY-MM-DD", ErrInvalidTimeOff)
+		}
+	}
+	if endDate.Before(startDate) {
+		return nil, fmt.Errorf("%w: endDate before startDate", ErrInvalidTimeOff)
+	}
+	if endDate.Sub(startDate) > maxTimeOffDays*24*time.Hour {
+		return nil, fmt.Errorf("%w: at most %d days", ErrInvalidTimeOff, maxTimeOffDays)
+	}
+
+	timeOff := &models.TechnicianTimeOff{
+		TechnicianID: technicianID,
+		Type:         req.Type,
+		StartDate:    startDate,
+		EndDate:      endDate,
+		Reason:       strings.TrimSpace(req.Reason),
+		CreatedBy:    userID,
+	}
+	if err := s.repo.CreateTimeOff(timeOff); err != nil {
+		return nil, err
+	}
+	s.audit(userID, "CREATE", technicianID, fmt.Sprintf("%s de %s: %s a %s", timeOff.Type, technician.FullName,
+		req.StartDate, endDate.Format("2006-01-02")))
+	return timeOff, nil
+}
+
+func (s *technicianScheduleService) DeleteTimeOff(technicianID, timeOffID, userID string) error {
+	timeOff, err := s.repo.FindTimeOffByID(timeOffID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTimeOffNotFound
+		}
+		return err
+	}
+	if timeOff.TechnicianID != technicianID {
+		return ErrTimeOffNotFound
+	}
+	if err := s.repo.DeleteTimeOff(timeOffID); err != nil {
+		return err
+	}
+	s.audit(userID, "DELETE", technicianID, fmt.Sprintf("%s removido: %s a %s", timeOff.Type,
+		timeOff.StartDate.Format("2006-01-02"), timeOff.EndDate.Format("2006-01-02")))
+	return nil
+}
+
+// =============== Availability ===============
+
+func (s *technicianScheduleService) Availability(technicianID string, start, end time.Time) (*models.TechnicianAvailability, error) {
+	if end.Before(start) {
+		return nil, ErrInvalidAvailabilityRange
+	}
+	if _, err := s.technicianRepo.FindByID(technicianID); err != nil {
+		return nil, err
+	}
+	conflicts, schedules, err := s.conflicts([]string{technicianID}, start, end)
+	if err != nil {
+		return nil, err
+	}
+	return &models.TechnicianAvailability{
+		TechnicianID: technicianID,
+		Start:        start,
+		End:          end,
+		Available:    len(conflicts) == 0,
+		HasSchedule:  schedules[technicianID] != nil,
+		Conflicts:    conflicts,
+	}, nil
+}
+
+func (s *technicianScheduleService) AssignmentConflicts(ticketID string, technicianIDs []string) ([]models.AvailabilityConflict, error) {
+	ticket, err := s.ticketRepo.FindByID(ticketID)
+	if err != nil {
+		return nil, err
+	}
+	if len(technicianIDs) == 0 {
+		return nil, nil
+	}
+
+	start := time.Now()
+	end := start
+	if ticket.ScheduledStart != nil {
+		start, end = *ticket.ScheduledStart, *ticket.ScheduledStart
+		if ticket.ScheduledEnd != nil && ticket.ScheduledEnd.After(start) {
+			end = *ticket.ScheduledEnd
+		}
+	}
+	conflicts, _, err := s.conflicts(technicianIDs, start, end)
+	return conflicts, err
+}
+
+// conflicts checks the technicians over the period; unknown technicians are skipped. An
+// empty period (start = end) checks the moment.
+func (s *technicianScheduleService) conflicts(technicianIDs []string, start, end time.Time) ([]models.AvailabilityConflict, map[string]*models.TechnicianSchedule, error) {
+	technicians, err := s.technicianRepo.FindByIDs(technicianIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	schedules, timeOff, err := s.loadSchedules(technicianIDs, start, end)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conflicts := []models.AvailabilityConflict{}
+	for i := range technicians {
+		technician := &technicians[i]
+		conflict := models.AvailabilityConflict{TechnicianID: technician.ID, TechnicianName: technician.FullName}
+		if technician.Status != "" && technician.Status != "ATIVO" {
+			conflict.Reason = models.AvailabilityInactive
+			conflict.Detail = "status " + technician.Status
+			conflicts = append(conflicts, conflict)
+			continue
+		}
+		schedule := schedules[technician.ID]
+		if schedule == nil {
+			continue
+		}
+		loc := scheduleLocation(schedule)
+		if off := coveringTimeOff(timeOff[technician.ID], start.In(loc), end.In(loc)); off != nil {
+			conflict.Reason = models.AvailabilityTimeOff
+			conflict.Detail = fmt.Sprintf("%s %s to %s", off.Type, off.StartDate.Format("2006-01-02"), off.EndDate.Format("2006-01-02"))
+			conflicts = append(conflicts, conflict)
+			continue
+		}
+		if _, ok := workingPeriod(schedule, start, end); !ok {
+			conflict.Reason = models.AvailabilityOutsideHours
+			conflict.Detail = describeWorkingDay(schedule, start.In(loc))
+			conflicts = append(conflicts, conflict)
+		}
+	}
+	return conflicts, schedules, nil
+}
+
+// loadSchedules returns the schedules and the time off around the period, by technician
+func (s *technicianScheduleService) loadSchedules(technicianIDs []string, start, end time.Time) (map[string]*models.TechnicianSchedule, map[string][]models.TechnicianTimeOff, error) {
+	list, err := s.repo.FindByTechnicians(technicianIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	schedules := make(map[string]*models.TechnicianSchedule, len(list))
+	for i := range list {
+		schedules[list[i].TechnicianID] = &list[i]
+	}
+	timeOff, err := s.timeOffAround(technicianIDs, start, end)
+	if err != nil {
+		return nil, nil, err
+	}
+	return schedules, timeOff, nil
+}
+
+// timeOffAround loads the time off a day either side of the period, which covers its
+// local dates in any timezone
+func (s *technicianScheduleService) timeOffAround(technicianIDs []string, start, end time.Time) (map[string][]models.TechnicianTimeOff, error) {
+	list, err := s.repo.FindTimeOff(technicianIDs,
+		start.UTC().AddDate(0, 0, -1).Format("2006-01-02"),
+		end.UTC().AddDate(0, 0, 1).Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	timeOff := make(map[string][]models.TechnicianTimeOff)
+	for _, off := range list {
+		timeOff[off.TechnicianID] = append(timeOff[off.TechnicianID], off)
+	}
+	return timeOff, nil
+}
+
+func (s *technicianScheduleService) OnDuty(at time.Time) (*models.OnDutySummary, error) {
+	schedules, err := s.repo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	summary := &models.OnDutySummary{At: at, Technicians: []models.OnDutyTechnician{}}
+	if len(schedules) == 0 {
+		return summary, nil
+	}
+
+	ids := make([]string, len(schedules))
+	for i, schedule := range schedules {
+		ids[i] = schedule.TechnicianID
+	}
+	timeOff, err := s.timeOffAround(ids, at, at)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range schedules {
+		schedule := &schedules[i]
+		technician := schedule.Technician
+		if technician == nil || (technician.Status != "" && technician.Status != "ATIVO") {
+			continue
+		}
+		local := at.In(scheduleLocation(schedule))
+		if coveringTimeOff(timeOff[schedule.TechnicianID], local, local) != nil {
+			continue
+		}
+		shiftEnd, ok := workingPeriod(schedule, at, at)
+		if !ok {
+			continue
+		}
+		summary.Technicians = append(summary.Technicians, models.OnDutyTechnician{
+			TechnicianID:   technician.ID,
+			TechnicianName: technician.FullName,
+			City:           technician.City,
+			State:          technician.State,
+			ShiftEndsAt:    shiftEnd,
+		})
+	}
+	sort.Slice(summary.Technicians, func(i, j int) bool {
+		return summary.Technicians[i].TechnicianName < summary.Technicians[j].TechnicianName
+	})
+	summary.Total = len(summary.Technicians)
+	return summary, nil
+}
+
+func (s *technicianScheduleService) audit(userID, action, technicianID, description string) {
+	if s.activityLogService == nil {
+		return
+	}
+	if err := s.activityLogService.LogAction(userID, action, "technician_schedule", technicianID, description, "", ""); err != nil {
+		slog.Warn("Failed to audit technician schedule", "technician_id", technicianID, "error", err)
+	}
+}
+
+// workingPeriod finds the working period of the schedule containing the period, which
+// must fall on a single local day; it returns the end of that working period. An empty
+// period is contained when start <= moment < end.
+func workingPeriod(schedule *models.TechnicianSchedule, start, end time.Time) (time.Time, bool) {
+	loc := scheduleLocation(schedule)
+	localStart, localEnd := start.In(loc), end.In(loc)
+	day := time.Date(localStart.Year(), localStart.Month(), localStart.Day(), 0, 0, 0, 0, loc)
+	if localEnd.After(day.AddDate(0, 0, 1)) {
+		return time.Time{}, false
+	}
+
+	for _, hours := range schedule.WorkingHours {
+		if hours.Weekday != int(localStart.Weekday()) {
+			continue
+		}
+		from, errFrom := parseClock(hours.Start)
+		to, errTo := parseClock(hours.End)
+		if errFrom != nil || errTo != nil {
+			continue
+		}
+		opening := day.Add(time.Duration(from) * time.Minute)
+		closing := day.Add(time.Duration(to) * time.Minute)
+		if localStart.Before(opening) || !localStart.Before(closing) || localEnd.After(closing) {
+			continue
+		}
+		return closing, true
+	}
+	return time.Time{}, false
+}
+
+// coveringTimeOff returns the time off overlapping the local dates of the period
+func coveringTimeOff(timeOff []models.TechnicianTimeOff, localStart, localEnd time.Time) *models.TechnicianTimeOff {
+	from, to := localStart.Format("2006-01-02"), localEnd.Format("2006-01-02")
+	for i := range timeOff {
+		if timeOff[i].StartDate.Format("2006-01-02") <= to && timeOff[i].EndDate.Format("2006-01-02") >= from {
+			return &timeOff[i]
+		}
+	}
+	return nil
+}
+
+// describeWorkingDay lists the working periods of the weekday, e.g. "Monday 08:00-12:00, 13:00-18:00"
+func describeWorkingDay(schedule *models.TechnicianSchedule, local time.Time) string {
+	var periods []string
+	for _, hours := range schedule.WorkingHours {
+		if hours.Weekday == int(local.Weekday()) {
+			periods = append(periods, hours.Start+"-"+hours.End)
+		}
+	}
+	if len(periods) == 0 {
+		return local.Weekday().String() + " is not a working day"
+	}
+	return local.Weekday().String() + " " + strings.Join(periods, ", ")
+}
+
+func scheduleLocation(schedule *models.TechnicianSchedule) *time.Location {
+	loc, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// parseClock returns the minutes since midnight of an "HH:MM" time; "24:00" is the end of the day
+func parseClock(s string) (int, error) {
+	if s == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func formatClock(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
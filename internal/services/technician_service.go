@@ -1,8 +1,10 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"strings"
 	
 	"github.com/redis/go-redis/v9"
@@ -23,17 +25,42 @@ type TechnicianService interface {
 	GetByCity(city string) ([]models.TechnicianDTO, error)
 	GetByState(state string) ([]models.TechnicianDTO, error)
 	GetCities() ([]string, error)
+	// WithScope returns the service restricted to the hierarchy scope of a user
+	WithScope(scope *models.AccessScope) TechnicianService
+	// WithContext returns the service running its queries with ctx, inside the tenant it
+	// carries, and caching per tenant
+	WithContext(ctx context.Context) TechnicianService
 }
 
 type technicianService struct {
 	repo  repositories.TechnicianRepository
 	cache *cache.RedisClient
+	// invalidation drops the cached pages after writes, also when the reads skip the cache
+	invalidation *cache.RedisClient
 }
 
 func NewTechnicianService(repo repositories.TechnicianRepository, cache *cache.RedisClient) TechnicianService {
 	return &technicianService{
-		repo:  repo,
-		cache: cache,
+		repo:         repo,
+		cache:        cache,
+		invalidation: cache,
+	}
+}
+
+// WithScope skips the cache for restricted users: the cached pages are shared by everyone.
+// Their writes still drop the cached pages.
+func (s *technicianService) WithScope(scope *models.AccessScope) TechnicianService {
+	if !scope.Restricted() {
+		return s
+	}
+	return &technicianService{repo: s.repo.WithScope(scope), invalidation: s.invalidation}
+}
+
+func (s *technicianService) WithContext(ctx context.Context) TechnicianService {
+	return &technicianService{
+		repo:         s.repo.WithContext(ctx),
+		cache:        s.cache.ForContext(ctx),
+		invalidation: s.invalidation.ForContext(ctx),
 	}
 }
 
@@ -50,7 +77,7 @@ func (s *technicianService) Create(req *models.CreateTechnicianRequest) (*models
 }
 
 func (s *technicianService) GetAll(page, size int) (*models.PaginatedResponse, error) {
-	fmt.Printf(">>> GetAll called: page=%d, size=%d, cache_enabled=%v\n", page, size, s.cache != nil)
+	slog.Debug("Listing technicians", "page", page, "size", size, "cache_enabled", s.cache != nil)
 	
 	// Try cache first
 	cacheKey := cache.TechnicianCacheKey(page, size, "")
@@ -58,11 +85,11 @@ func (s *technicianService) GetAll(page, size int) (*models.PaginatedResponse, e
 		var cachedResult models.PaginatedResponse
 		cacheErr := s.cache.Get(cacheKey, &cachedResult)
 		if cacheErr == nil {
-			log.Printf("Cache HIT: %s", cacheKey)
+			slog.Debug("Cache HIT", "cache_key", cacheKey)
 			return &cachedResult, nil
 		}
 		if cacheErr != redis.Nil {
-			log.Printf("Cache error: %v", cacheErr)
+			slog.Debug("Cache error", "error", cacheErr)
 		}
 	}
 
@@ -78,25 +105,14 @@ func (s *technicianService) GetAll(page, size int) (*models.PaginatedResponse, e
 		dtos[i] = t.ToDTO()
 	}
 
-	totalPages := int(total) / size
-	if int(total)%size > 0 {
-		totalPages++
-	}
-
-	result := &models.PaginatedResponse{
-		Content:       dtos,
-		Page:          page,
-		Size:          size,
-		TotalElements: total,
-		TotalPages:    totalPages,
-	}
+	result := models.NewPaginatedResponse(dtos, page, size, total)
 
 	// Cache the result
 	if s.cache != nil {
 		if err := s.cache.Set(cacheKey, result, cache.TechnicianListTTL); err != nil {
-			log.Printf("Failed to cache result: %v", err)
+			slog.Warn("Failed to cache result", "error", err)
 		} else {
-			log.Printf("Cache SET: %s", cacheKey)
+			slog.Debug("Cache SET", "cache_key", cacheKey)
 		}
 	}
 
@@ -138,15 +154,27 @@ func (s *technicianService) Update(id string, req *models.CreateTechnicianReques
 	existing.HolderCPF = req.HolderCPF
 	existing.PixKey = req.PixKey
 	existing.Skills = req.Skills
+	existing.NodeID = req.NodeID
 
+	// The edit is saved only over the version it was based on
+	if req.Version > 0 {
+		existing.Version = req.Version
+	}
 	if err := s.repo.Update(existing); err != nil {
+		if errors.Is(err, repositories.ErrVersionConflict) {
+			current, findErr := s.repo.FindByID(id)
+			if findErr != nil {
+				return nil, findErr
+			}
+			return nil, NewVersionConflict("technician", existing.Version, existing, current, current.Version)
+		}
 		return nil, err
 	}
 
 	// Invalidate caches after update
 	s.invalidateTechnicianCaches()
-	if s.cache != nil {
-		s.cache.Delete(cache.TechnicianDetailCacheKey(id))
+	if s.invalidation != nil {
+		s.invalidation.Delete(cache.TechnicianDetailCacheKey(id))
 	}
 
 	return existing, nil
@@ -159,8 +187,8 @@ func (s *technicianService) Delete(id string) error {
 	
 	// Invalidate caches after delete
 	s.invalidateTechnicianCaches()
-	if s.cache != nil {
-		s.cache.Delete(cache.TechnicianDetailCacheKey(id))
+	if s.invalidation != nil {
+		s.invalidation.Delete(cache.TechnicianDetailCacheKey(id))
 	}
 	
 	return nil
@@ -173,11 +201,11 @@ func (s *technicianService) Search(query string, page, size int) (*models.Pagina
 		var cachedResult models.PaginatedResponse
 		cacheErr := s.cache.Get(cacheKey, &cachedResult)
 		if cacheErr == nil {
-			log.Printf("Cache HIT: %s", cacheKey)
+			slog.Debug("Cache HIT", "cache_key", cacheKey)
 			return &cachedResult, nil
 		}
 		if cacheErr != redis.Nil {
-			log.Printf("Cache error: %v", cacheErr)
+			slog.Debug("Cache error", "error", cacheErr)
 		}
 	}
 
@@ -192,25 +220,14 @@ func (s *technicianService) Search(query string, page, size int) (*models.Pagina
 		dtos[i] = t.ToDTO()
 	}
 
-	totalPages := int(total) / size
-	if int(total)%size > 0 {
-		totalPages++
-	}
-
-	result := &models.PaginatedResponse{
-		Content:       dtos,
-		Page:          page,
-		Size:          size,
-		TotalElements: total,
-		TotalPages:    totalPages,
-	}
+	result := models.NewPaginatedResponse(dtos, page, size, total)
 
 	// Cache the search result
 	if s.cache != nil {
 		if err := s.cache.Set(cacheKey, result, cache.TechnicianSearchTTL); err != nil {
-			log.Printf("Failed to cache search result: %v", err)
+			slog.Warn("Failed to cache search result", "error", err)
 		} else {
-			log.Printf("Cache SET: %s", cacheKey)
+			slog.Debug("Cache SET", "cache_key", cacheKey)
 		}
 	}
 
@@ -226,11 +243,11 @@ func (s *technicianService) SearchWithFilters(query, status, techType, city, sta
 		var cachedResult models.PaginatedResponse
 		cacheErr := s.cache.Get(cacheKey, &cachedResult)
 		if cacheErr == nil {
-			log.Printf("Cache HIT: %s", cacheKey)
+			slog.Debug("Cache HIT", "cache_key", cacheKey)
 			return &cachedResult, nil
 		}
 		if cacheErr != redis.Nil {
-			log.Printf("Cache error: %v", cacheErr)
+			slog.Debug("Cache error", "error", cacheErr)
 		}
 	}
 
@@ -245,25 +262,14 @@ func (s *technicianService) SearchWithFilters(query, status, techType, city, sta
 		dtos[i] = t.ToDTO()
 	}
 
-	totalPages := int(total) / size
-	if int(total)%size > 0 {
-		totalPages++
-	}
-
-	result := &models.PaginatedResponse{
-		Content:       dtos,
-		Page:          page,
-		Size:          size,
-		TotalElements: total,
-		TotalPages:    totalPages,
-	}
+	result := models.NewPaginatedResponse(dtos, page, size, total)
 
 	// Cache the result
 	if s.cache != nil {
 		if err := s.cache.Set(cacheKey, result, cache.TechnicianSearchTTL); err != nil {
-			log.Printf("Failed to cache filter result: %v", err)
+			slog.Warn("Failed to cache filter result", "error", err)
 		} else {
-			log.Printf("Cache SET: %s", cacheKey)
+			slog.Debug("Cache SET", "cache_key", cacheKey)
 		}
 	}
 
@@ -319,11 +325,11 @@ func (s *technicianService) GetByID(id string) (*models.Technician, error) {
 		var cachedTechnician models.Technician
 		cacheErr := s.cache.Get(cacheKey, &cachedTechnician)
 		if cacheErr == nil {
-			log.Printf("Cache HIT: %s", cacheKey)
+			slog.Debug("Cache HIT", "cache_key", cacheKey)
 			return &cachedTechnician, nil
 		}
 		if cacheErr != redis.Nil {
-			log.Printf("Cache error: %v", cacheErr)
+			slog.Debug("Cache error", "error", cacheErr)
 		}
 	}
 
@@ -336,9 +342,9 @@ func (s *technicianService) GetByID(id string) (*models.Technician, error) {
 	// Cache the result
 	if s.cache != nil {
 		if err := s.cache.Set(cacheKey, technician, cache.TechnicianDetailTTL); err != nil {
-			log.Printf("Failed to cache technician: %v", err)
+			slog.Warn("Failed to cache technician", "error", err)
 		} else {
-			log.Printf("Cache SET: %s", cacheKey)
+			slog.Debug("Cache SET", "cache_key", cacheKey)
 		}
 	}
 
@@ -352,11 +358,11 @@ func (s *technicianService) GetByCity(city string) ([]models.TechnicianDTO, erro
 		var cachedResult []models.TechnicianDTO
 		cacheErr := s.cache.Get(cacheKey, &cachedResult)
 		if cacheErr == nil {
-			log.Printf("Cache HIT: %s", cacheKey)
+			slog.Debug("Cache HIT", "cache_key", cacheKey)
 			return cachedResult, nil
 		}
 		if cacheErr != redis.Nil {
-			log.Printf("Cache error: %v", cacheErr)
+			slog.Debug("Cache error", "error", cacheErr)
 		}
 	}
 
@@ -374,9 +380,9 @@ func (s *technicianService) GetByCity(city string) ([]models.TechnicianDTO, erro
 	// Cache the result
 	if s.cache != nil {
 		if err := s.cache.Set(cacheKey, dtos, cache.TechnicianFilterTTL); err != nil {
-			log.Printf("Failed to cache city result: %v", err)
+			slog.Warn("Failed to cache city result", "error", err)
 		} else {
-			log.Printf("Cache SET: %s", cacheKey)
+			slog.Debug("Cache SET", "cache_key", cacheKey)
 		}
 	}
 
@@ -390,11 +396,11 @@ func (s *technicianService) GetByState(state string) ([]models.TechnicianDTO, er
 		var cachedResult []models.TechnicianDTO
 		cacheErr := s.cache.Get(cacheKey, &cachedResult)
 		if cacheErr == nil {
-			log.Printf("Cache HIT: %s", cacheKey)
+			slog.Debug("Cache HIT", "cache_key", cacheKey)
 			return cachedResult, nil
 		}
 		if cacheErr != redis.Nil {
-			log.Printf("Cache error: %v", cacheErr)
+			slog.Debug("Cache error", "error", cacheErr)
 		}
 	}
 
@@ -412,9 +418,9 @@ func (s *technicianService) GetByState(state string) ([]models.TechnicianDTO, er
 	// Cache the result
 	if s.cache != nil {
 		if err := s.cache.Set(cacheKey, dtos, cache.TechnicianFilterTTL); err != nil {
-			log.Printf("Failed to cache state result: %v", err)
+			slog.Warn("Failed to cache state result", "error", err)
 		} else {
-			log.Printf("Cache SET: %s", cacheKey)
+			slog.Debug("Cache SET", "cache_key", cacheKey)
 		}
 	}
 
@@ -428,11 +434,11 @@ func (s *technicianService) GetCities() ([]string, error) {
 		var cachedCities []string
 		cacheErr := s.cache.Get(cacheKey, &cachedCities)
 		if cacheErr == nil {
-			log.Printf("Cache HIT: %s", cacheKey)
+			slog.Debug("Cache HIT", "cache_key", cacheKey)
 			return cachedCities, nil
 		}
 		if cacheErr != redis.Nil {
-			log.Printf("Cache error: %v", cacheErr)
+			slog.Debug("Cache error", "error", cacheErr)
 		}
 	}
 
@@ -445,9 +451,9 @@ func (s *technicianService) GetCities() ([]string, error) {
 	// Cache the result
 	if s.cache != nil {
 		if err := s.cache.Set(cacheKey, cities, cache.TechnicianFilterTTL); err != nil {
-			log.Printf("Failed to cache cities: %v", err)
+			slog.Warn("Failed to cache cities", "error", err)
 		} else {
-			log.Printf("Cache SET: %s", cacheKey)
+			slog.Debug("Cache SET", "cache_key", cacheKey)
 		}
 	}
 
@@ -456,33 +462,33 @@ func (s *technicianService) GetCities() ([]string, error) {
 
 // invalidateTechnicianCaches clears all technician-related cache entries
 func (s *technicianService) invalidateTechnicianCaches() {
-	if s.cache == nil {
+	if s.invalidation == nil {
 		return
 	}
 
 	// Clear list caches
-	if err := s.cache.DeletePattern("technicians:list:*"); err != nil {
-		log.Printf("Failed to clear list cache: %v", err)
+	if err := s.invalidation.DeletePattern("technicians:list:*"); err != nil {
+		slog.Warn("Failed to clear list cache", "error", err)
 	}
 	
 	// Clear search caches
-	if err := s.cache.DeletePattern("technicians:search:*"); err != nil {
-		log.Printf("Failed to clear search cache: %v", err)
+	if err := s.invalidation.DeletePattern("technicians:search:*"); err != nil {
+		slog.Warn("Failed to clear search cache", "error", err)
 	}
 	
 	// Clear filter caches
-	if err := s.cache.DeletePattern("technicians:city:*"); err != nil {
-		log.Printf("Failed to clear city cache: %v", err)
+	if err := s.invalidation.DeletePattern("technicians:city:*"); err != nil {
+		slog.Warn("Failed to clear city cache", "error", err)
 	}
 	
-	if err := s.cache.DeletePattern("technicians:state:*"); err != nil {
-		log.Printf("Failed to clear state cache: %v", err)
+	if err := s.invalidation.DeletePattern("technicians:state:*"); err != nil {
+		slog.Warn("Failed to clear state cache", "error", err)
 	}
 	
 	// Clear cities list cache
-	if err := s.cache.Delete("technicians:cities:list"); err != nil {
-		log.Printf("Failed to clear cities list cache: %v", err)
+	if err := s.invalidation.Delete("technicians:cities:list"); err != nil {
+		slog.Warn("Failed to clear cities list cache", "error", err)
 	}
 	
-	log.Printf("Cache invalidation completed")
+	slog.Debug("Cache invalidation completed")
 }
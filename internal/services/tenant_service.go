@@ -0,0 +1,194 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"github.com/shigake/tech-iq-back/internal/tenant"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// Subdomain lookups are cached this long, so a deactivated tenant is refused within it
+const tenantSlugCacheTTL = time.Minute
+
+// The slug cache is emptied when it grows past this, bounding what random subdomains cost
+const tenantSlugCacheSize = 1000
+
+var (
+	ErrTenantNotFound      = errors.New("tenant not found")
+	ErrTenantSlugTaken     = errors.New("tenant slug already in use")
+	ErrTenantDefaultLocked = errors.New("the default tenant can't be deactivated")
+	ErrTenantEmailTaken    = errors.New("email already registered")
+)
+
+// TenantService manages the tenants (organizations) of the platform and resolves the
+// tenant served from a subdomain
+type TenantService interface {
+	List() ([]models.Tenant, error)
+	Get(id string) (*models.Tenant, error)
+	Create(adminID string, req *models.CreateTenantRequest) (*models.Tenant, error)
+	Update(adminID, id string, req *models.UpdateTenantRequest) (*models.Tenant, error)
+	// CreateAdmin creates an admin user inside the tenant
+	CreateAdmin(adminID, id string, req *models.CreateTenantAdminRequest) (*models.User, error)
+
+	ResolveSlug(slug string) (*models.Tenant, error)
+}
+
+type tenantService struct {
+	repo               repositories.TenantRepository
+	userRepo           repositories.UserRepository
+	activityLogService ActivityLogService
+
+	mu    sync.Mutex
+	slugs map[string]cachedTenant
+}
+
+type cachedTenant struct {
+	tenant    *models.Tenant
+	expiresAt time.Time
+}
+
+func NewTenantService(repo repositories.TenantRepository, userRepo repositories.UserRepository, activityLogService ActivityLogService) TenantService {
+	return &tenantService{
+		repo:               repo,
+		userRepo:           userRepo,
+		activityLogService: activityLogService,
+		slugs:              make(map[string]cachedTenant),
+	}
+}
+
+func (s *tenantService) List() ([]models.Tenant, error) {
+	return s.repo.FindAll()
+}
+
+func (s *tenantService) Get(id string) (*models.Tenant, error) {
+	t, err := s.repo.FindByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTenantNotFound
+		}
+		return nil, err
+	}
+	return t, nil
+}
+
+func (s *tenantService) Create(adminID string, req *models.CreateTenantRequest) (*models.Tenant, error) {
+	slug := strings.ToLower(strings.TrimSpace(req.Slug))
+	if _, err := s.repo.FindBySlug(slug); err == nil {
+		return nil, ErrTenantSlugTaken
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	t := &models.Tenant{
+		Name:   strings.TrimSpace(req.Name),
+		Slug:   slug,
+		Active: true,
+	}
+	if err := s.repo.Create(t); err != nil {
+		return nil, err
+	}
+
+	s.forget(slug)
+	s.logAction(adminID, "CREATE", t.ID, fmt.Sprintf("Tenant %q created on subdomain %s", t.Name, t.Slug))
+	return t, nil
+}
+
+func (s *tenantService) Update(adminID, id string, req *models.UpdateTenantRequest) (*models.Tenant, error) {
+	t, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	t.Name = strings.TrimSpace(req.Name)
+	if req.Active != nil {
+		if !*req.Active && t.ID == tenant.DefaultID {
+			return nil, ErrTenantDefaultLocked
+		}
+		t.Active = *req.Active
+	}
+	if err := s.repo.Update(t); err != nil {
+		return nil, err
+	}
+
+	s.forget(t.Slug)
+	s.logAction(adminID, "UPDATE", t.ID, fmt.Sprintf("Tenant %q updated (active: %t)", t.Name, t.Active))
+	return t, nil
+}
+
+func (s *tenantService) CreateAdmin(adminID, id string, req *models.CreateTenantAdminRequest) (*models.User, error) {
+	t, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	// Emails sign in without a tenant, so they stay unique across tenants
+	if existing, _ := s.userRepo.FindByEmail(req.Email); existing != nil {
+		return nil, ErrTenantEmailTaken
+	}
+
+	password, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	user := &models.User{
+		Email:     req.Email,
+		Password:  string(password),
+		FirstName: req.FirstName,
+		LastName:  req.LastName,
+		Role:      "ADMIN",
+		Active:    true,
+	}
+	if err := s.repo.CreateUser(t.ID, user); err != nil {
+		return nil, err
+	}
+
+	s.logAction(adminID, "CREATE", t.ID, fmt.Sprintf("Admin %s created for tenant %q", user.Email, t.Name))
+	return user, nil
+}
+
+func (s *tenantService) ResolveSlug(slug string) (*models.Tenant, error) {
+	slug = strings.ToLower(slug)
+	s.mu.Lock()
+	cached, ok := s.slugs[slug]
+	s.mu.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		if cached.tenant == nil {
+			return nil, ErrTenantNotFound
+		}
+		return cached.tenant, nil
+	}
+
+	t, err := s.repo.FindBySlug(slug)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	// Unknown slugs are cached too, so random subdomains don't each hit the database
+	s.mu.Lock()
+	if len(s.slugs) >= tenantSlugCacheSize {
+		s.slugs = make(map[string]cachedTenant)
+	}
+	s.slugs[slug] = cachedTenant{tenant: t, expiresAt: time.Now().Add(tenantSlugCacheTTL)}
+	s.mu.Unlock()
+	if t == nil {
+		return nil, ErrTenantNotFound
+	}
+	return t, nil
+}
+
+func (s *tenantService) forget(slug string) {
+	s.mu.Lock()
+	delete(s.slugs, slug)
+	s.mu.Unlock()
+}
+
+func (s *tenantService) logAction(userID, action, tenantID, description string) {
+	if err := s.activityLogService.LogAction(userID, action, "tenant", tenantID, description, "", ""); err != nil {
+		slog.Warn("Failed to log tenant change", "tenant_id", tenantID, "error", err)
+	}
+}
@@ -0,0 +1,453 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTicketBudgetNotFound       = errors.New("ticket has no budget")
+	ErrTicketBudgetTicketNotFound = errors.New("ticket not found")
+	ErrTicketBudgetEmpty          = errors.New("set a parts or expense budget, or quote lines to price it")
+	ErrTicketBudgetNegative       = errors.New("budget must not be negative")
+	ErrExpenseBudgetNotSet        = errors.New("ticket has no expense budget to override")
+	ErrExpenseBudgetExceeded      = errors.New("expense exceeds the ticket budget, request an approval override")
+	ErrPartsBudgetExceeded        = errors.New("consumption exceeds the ticket parts budget, consume the items one by one to request approval")
+	ErrBudgetOverrideNotFound     = errors.New("budget override not found")
+	ErrBudgetOverrideNotPending   = errors.New("budget override has already been decided")
+	ErrBudgetOverrideAmount       = errors.New("override amount must be positive")
+	ErrBudgetOverrideSelfApproval = errors.New("an override cannot be decided by its requester")
+)
+
+const defaultBudgetVarianceDays = 30
+
+// TicketBudgetService caps the parts and expenses of a ticket. Stock consumption above the
+// parts budget waits for approval as a pending movement; expenses above the expense budget
+// are refused until an override raising the cap is approved.
+type TicketBudgetService interface {
+	Get(ticketID string) (*models.TicketBudgetStatus, error)
+	Set(ticketID string, req *models.SetTicketBudgetRequest, userID string) (*models.TicketBudgetStatus, error)
+	Delete(ticketID, userID string) error
+
+	// PartsOverrunReason explains why consuming parts worth value needs approval, empty
+	// when the ticket has no parts budget or stays within it
+	PartsOverrunReason(ticketID string, value decimal.Decimal) (string, error)
+	// CheckExpense fails with ErrExpenseBudgetExceeded when the expense would take the
+	// ticket over its expense budget; excludeEntryID is the entry being edited
+	CheckExpense(ticketID string, amount decimal.Decimal, excludeEntryID string) error
+
+	ListOverrides(ticketID string) ([]models.TicketBudgetOverride, error)
+	RequestOverride(ticketID string, req *models.CreateBudgetOverrideRequest, userID string) (*models.TicketBudgetOverride, error)
+	ApproveOverride(id string, req *models.BudgetOverrideDecisionRequest, userID string) (*models.TicketBudgetOverride, error)
+	RejectOverride(id string, req *models.BudgetOverrideDecisionRequest, userID string) (*models.TicketBudgetOverride, error)
+
+	VarianceReport(from, to time.Time) (*models.BudgetVarianceReport, error)
+}
+
+type ticketBudgetService struct {
+	repo               repositories.TicketBudgetRepository
+	ticketRepo         repositories.TicketRepository
+	priceLists         PriceListService
+	activityLogService ActivityLogService
+}
+
+func NewTicketBudgetService(
+	repo repositories.TicketBudgetRepository,
+	ticketRepo repositories.TicketRepository,
+	priceLists PriceListService,
+	activityLogService ActivityLogService,
+) TicketBudgetService {
+	return &ticketBudgetService{
+		repo:               repo,
+		ticketRepo:         ticketRepo,
+		priceLists:         priceLists,
+		activityLogService: activityLogService,
+	}
+}
+
+// =============== Budget ===============
+
+func (s *ticketBudgetService) Get(ticketID string) (*models.TicketBudgetStatus, error) {
+	budget, err := s.repo.FindBudget(ticketID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTicketBudgetNotFound
+		}
+		return nil, err
+	}
+	return s.status(budget)
+}
+
+func (s *ticketBudgetService) Set(ticketID string, req *models.SetTicketBudgetRequest, userID string) (*models.TicketBudgetStatus, error) {
+	ticket, err := s.ticketRepo.FindByID(ticketID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTicketBudgetTicketNotFound
+		}
+		return nil, err
+	}
+	if req.PartsBudget == nil && req.ExpenseBudget == nil && len(req.Lines) == 0 {
+		return nil, ErrTicketBudgetEmpty
+	}
+	for _, amount := range []*decimal.Decimal{req.PartsBudget, req.ExpenseBudget} {
+		if amount != nil && amount.IsNegative() {
+			return nil, ErrTicketBudgetNegative
+		}
+	}
+
+	budget := &models.TicketBudget{
+		TicketID:      ticket.ID,
+		PartsBudget:   req.PartsBudget,
+		ExpenseBudget: req.ExpenseBudget,
+		Source:        models.BudgetSourceManual,
+		QuoteRef:      strings.TrimSpace(req.QuoteRef),
+		Notes:         strings.TrimSpace(req.Notes),
+		SetBy:         userID,
+	}
+	if existing, err := s.repo.FindBudget(ticket.ID); err == nil {
+		budget.CreatedAt = existing.CreatedAt
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// The parts of the quote are priced through the price lists of the ticket client
+	missing := 0
+	if len(req.Lines) > 0 {
+		budget.Source = models.BudgetSourceQuote
+		if budget.PartsBudget == nil {
+			parts := make([]models.PriceLine, 0, len(req.Lines))
+			for _, line := range req.Lines {
+				if models.PriceEntryKind(line.Kind) == models.PriceKindPart {
+					parts = append(parts, line)
+				}
+			}
+			total := decimal.Zero
+			if len(parts) > 0 {
+				resolved, err := s.priceLists.Resolve(&models.ResolvePricesRequest{TicketID: ticket.ID, Lines: parts})
+				if err != nil {
+					return nil, err
+				}
+				total, missing = resolved.Total, resolved.Missing
+			}
+			budget.PartsBudget = &total
+		}
+	}
+
+	if err := s.repo.SaveBudget(budget); err != nil {
+		return nil, err
+	}
+	s.audit(userID, "ticket_budget_set", ticket.ID, fmt.Sprintf("Orçamento da OS %s definido (peças %s, despesas %s)",
+		ticket.OSNumber, budgetAmount(budget.PartsBudget), budgetAmount(budget.ExpenseBudget)))
+
+	status, err := s.status(budget)
+	if err != nil {
+		return nil, err
+	}
+	status.MissingPrices = missing
+	return status, nil
+}
+
+func (s *ticketBudgetService) Delete(ticketID, userID string) error {
+	if _, err := s.repo.FindBudget(ticketID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTicketBudgetNotFound
+		}
+		return err
+	}
+	if err := s.repo.DeleteBudget(ticketID); err != nil {
+		return err
+	}
+	s.audit(userID, "ticket_budget_removed", ticketID, "Orçamento da OS removido")
+	return nil
+}
+
+func (s *ticketBudgetService) status(budget *models.TicketBudget) (*models.TicketBudgetStatus, error) {
+	spending, err := s.repo.FindSpending([]string{budget.TicketID})
+	if err != nil {
+		return nil, err
+	}
+	overrides, err := s.repo.FindOverrides(budget.TicketID)
+	if err != nil {
+		return nil, err
+	}
+	spent := spending[budget.TicketID]
+
+	status := &models.TicketBudgetStatus{
+		TicketBudget:     *budget,
+		PartsSpent:       spent.PartsApproved,
+		PartsPending:     spent.PartsPending,
+		ExpenseSpent:     spent.Expenses,
+		ExpenseOverrides: spent.ApprovedOverride,
+		Overrides:        overrides,
+	}
+	if budget.PartsBudget != nil {
+		remaining := budget.PartsBudget.Sub(spent.PartsApproved).Sub(spent.PartsPending)
+		status.PartsRemaining = &remaining
+	}
+	if budget.ExpenseBudget != nil {
+		remaining := budget.ExpenseBudget.Add(spent.ApprovedOverride).Sub(spent.Expenses)
+		status.ExpenseRemaining = &remaining
+	}
+	return status, nil
+}
+
+// =============== Enforcement ===============
+
+func (s *ticketBudgetService) PartsOverrunReason(ticketID string, value decimal.Decimal) (string, error) {
+	budget, err := s.repo.FindBudget(ticketID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", nil
+		}
+		return "", err
+	}
+	if budget.PartsBudget == nil {
+		return "", nil
+	}
+
+	spending, err := s.repo.FindSpending([]string{ticketID})
+	if err != nil {
+		return "", err
+	}
+	// Consumption still waiting for approval is committed too
+	committed := spending[ticketID].PartsApproved.Add(spending[ticketID].PartsPending)
+	if committed.Add(value).LessThanOrEqual(*budget.PartsBudget) {
+		return "", nil
+	}
+	return fmt.Sprintf("parts of %s exceed the ticket budget of %s (%s already committed)",
+		value.StringFixed(2), budget.PartsBudget.StringFixed(2), committed.StringFixed(2)), nil
+}
+
+func (s *ticketBudgetService) CheckExpense(ticketID string, amount decimal.Decimal, excludeEntryID string) error {
+	budget, err := s.repo.FindBudget(ticketID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if budget.ExpenseBudget == nil {
+		return nil
+	}
+
+	spent, err := s.repo.SumExpenses(ticketID, excludeEntryID)
+	if err != nil {
+		return err
+	}
+	spending, err := s.repo.FindSpending([]string{ticketID})
+	if err != nil {
+		return err
+	}
+	limit := budget.ExpenseBudget.Add(spending[ticketID].ApprovedOverride)
+	if spent.Add(amount).GreaterThan(limit) {
+		return fmt.Errorf("%w: %s spent of %s", ErrExpenseBudgetExceeded, spent.StringFixed(2), limit.StringFixed(2))
+	}
+	return nil
+}
+
+// =============== Overrides ===============
+
+func (s *ticketBudgetService) ListOverrides(ticketID string) ([]models.TicketBudgetOverride, error) {
+	return s.repo.FindOverrides(ticketID)
+}
+
+func (s *ticketBudgetService) RequestOverride(ticketID string, req *models.CreateBudgetOverrideRequest, userID string) (*models.TicketBudgetOverride, error) {
+	if !req.Amount.IsPositive() {
+		return nil, ErrBudgetOverrideAmount
+	}
+	budget, err := s.repo.FindBudget(ticketID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTicketBudgetNotFound
+		}
+		return nil, err
+	}
+	if budget.ExpenseBudget == nil {
+		return nil, ErrExpenseBudgetNotSet
+	}
+
+	override := &models.TicketBudgetOverride{
+		TicketID:    ticketID,
+		Amount:      req.Amount,
+		Reason:      strings.TrimSpace(req.Reason),
+		Status:      models.BudgetOverridePending,
+		RequestedBy: userID,
+	}
+	if err := s.repo.CreateOverride(override); err != nil {
+		return nil, err
+	}
+	s.audit(userID, "ticket_budget_override_requested", ticketID,
+		fmt.Sprintf("Ampliação de %s no orçamento de despesas solicitada: %s", override.Amount.StringFixed(2), override.Reason))
+	return s.repo.FindOverrideByID(override.ID)
+}
+
+func (s *ticketBudgetService) ApproveOverride(id string, req *models.BudgetOverrideDecisionRequest, userID string) (*models.TicketBudgetOverride, error) {
+	return s.decideOverride(id, req, userID, models.BudgetOverrideApproved)
+}
+
+func (s *ticketBudgetService) RejectOverride(id string, req *models.BudgetOverrideDecisionRequest, userID string) (*models.TicketBudgetOverride, error) {
+	return s.decideOverride(id, req, userID, models.BudgetOverrideRejected)
+}
+
+func (s *ticketBudgetService) decideOverride(id string, req *models.BudgetOverrideDecisionRequest, userID, status string) (*models.TicketBudgetOverride, error) {
+	override, err := s.repo.FindOverrideByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBudgetOverrideNotFound
+		}
+		return nil, err
+	}
+	if override.Status != models.BudgetOverridePending {
+		return nil, ErrBudgetOverrideNotPending
+	}
+	if override.RequestedBy == userID {
+		return nil, ErrBudgetOverrideSelfApproval
+	}
+
+	now := time.Now()
+	override.Status = status
+	override.DecidedBy = &userID
+	override.DecidedAt = &now
+	override.DecisionNotes = stringPtrOrNil(strings.TrimSpace(req.Notes))
+	decided, err := s.repo.DecideOverride(override)
+	if err != nil {
+		return nil, err
+	}
+	if !decided {
+		return nil, ErrBudgetOverrideNotPending
+	}
+
+	if status == models.BudgetOverrideApproved {
+		s.audit(userID, "ticket_budget_override_approved", override.TicketID,
+			fmt.Sprintf("Ampliação de %s no orçamento de despesas aprovada", override.Amount.StringFixed(2)))
+	} else {
+		s.audit(userID, "ticket_budget_override_rejected", override.TicketID,
+			fmt.Sprintf("Ampliação de %s no orçamento de despesas rejeitada", override.Amount.StringFixed(2)))
+	}
+	return s.repo.FindOverrideByID(id)
+}
+
+// =============== Variance ===============
+
+// VarianceReport compares budget and actual spending of the budgeted tickets opened in the
+// period. Parts count approved consumption only; the expense budget includes approved overrides.
+func (s *ticketBudgetService) VarianceReport(from, to time.Time) (*models.BudgetVarianceReport, error) {
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if from.IsZero() {
+		from = to.AddDate(0, 0, -defaultBudgetVarianceDays)
+	}
+
+	budgets, err := s.repo.FindBudgetsCreatedBetween(from, to)
+	if err != nil {
+		return nil, err
+	}
+	ticketIDs := make([]string, len(budgets))
+	for i, b := range budgets {
+		ticketIDs[i] = b.TicketID
+	}
+	spending, err := s.repo.FindSpending(ticketIDs)
+	if err != nil {
+		return nil, err
+	}
+	leads, err := s.repo.FindLeadTechnicians(ticketIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.BudgetVarianceReport{
+		From:         from,
+		To:           to,
+		ByTicket:     make([]models.BudgetVarianceRow, 0, len(budgets)),
+		ByTechnician: []models.BudgetVarianceRow{},
+	}
+	byTechnician := make(map[string]*models.BudgetVarianceRow)
+	for _, b := range budgets {
+		spent := spending[b.TicketID]
+		row := models.BudgetVarianceRow{
+			Key:           b.TicketID,
+			Label:         b.TicketID,
+			Tickets:       1,
+			PartsActual:   spent.PartsApproved,
+			ExpenseActual: spent.Expenses,
+		}
+		if b.Ticket != nil {
+			row.Label = b.Ticket.OSNumber
+		}
+		over := false
+		if b.PartsBudget != nil {
+			row.PartsBudget = *b.PartsBudget
+			over = row.PartsActual.GreaterThan(row.PartsBudget)
+		}
+		if b.ExpenseBudget != nil {
+			row.ExpenseBudget = b.ExpenseBudget.Add(spent.ApprovedOverride)
+			over = over || row.ExpenseActual.GreaterThan(row.ExpenseBudget)
+		}
+		if over {
+			row.OverBudget = 1
+		}
+		row.PartsVariance = row.PartsActual.Sub(row.PartsBudget)
+		row.ExpenseVariance = row.ExpenseActual.Sub(row.ExpenseBudget)
+		report.ByTicket = append(report.ByTicket, row)
+
+		lead, ok := leads[b.TicketID]
+		if !ok {
+			continue
+		}
+		tech := byTechnician[lead.ID]
+		if tech == nil {
+			tech = &models.BudgetVarianceRow{Key: lead.ID, Label: lead.FullName}
+			byTechnician[lead.ID] = tech
+		}
+		tech.Tickets++
+		tech.PartsBudget = tech.PartsBudget.Add(row.PartsBudget)
+		tech.PartsActual = tech.PartsActual.Add(row.PartsActual)
+		tech.PartsVariance = tech.PartsVariance.Add(row.PartsVariance)
+		tech.ExpenseBudget = tech.ExpenseBudget.Add(row.ExpenseBudget)
+		tech.ExpenseActual = tech.ExpenseActual.Add(row.ExpenseActual)
+		tech.ExpenseVariance = tech.ExpenseVariance.Add(row.ExpenseVariance)
+		tech.OverBudget += row.OverBudget
+	}
+
+	for _, row := range byTechnician {
+		report.ByTechnician = append(report.ByTechnician, *row)
+	}
+	// Largest overruns first
+	sort.Slice(report.ByTicket, func(i, j int) bool {
+		return totalVariance(report.ByTicket[i]).GreaterThan(totalVariance(report.ByTicket[j]))
+	})
+	sort.Slice(report.ByTechnician, func(i, j int) bool {
+		return totalVariance(report.ByTechnician[i]).GreaterThan(totalVariance(report.ByTechnician[j]))
+	})
+	return report, nil
+}
+
+func totalVariance(row models.BudgetVarianceRow) decimal.Decimal {
+	return row.PartsVariance.Add(row.ExpenseVariance)
+}
+
+func budgetAmount(amount *decimal.Decimal) string {
+	if amount == nil {
+		return "sem limite"
+	}
+	return amount.StringFixed(2)
+}
+
+func (s *ticketBudgetService) audit(userID, action, ticketID, description string) {
+	if s.activityLogService == nil {
+		return
+	}
+	if err := s.activityLogService.LogAction(userID, action, "ticket", ticketID, description, "", ""); err != nil {
+		slog.Warn("Failed to audit ticket budget", "ticket_id", ticketID, "error", err)
+	}
+}
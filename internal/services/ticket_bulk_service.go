@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+// bulkTicketPermissions is the permission each bulk action requires on the ticket's node
+var bulkTicketPermissions = map[string]string{
+	models.TicketBulkStatus: "tickets.edit",
+	models.TicketBulkAssign: "tickets.assign",
+	models.TicketBulkDelete: "tickets.delete",
+}
+
+// BulkTicketPermission returns the permission code the bulk action requires
+func BulkTicketPermission(action string) string {
+	return bulkTicketPermissions[action]
+}
+
+// TicketBulkService applies status changes, assignments and deletions to many tickets at
+// once, through the same service calls as the single-ticket endpoints
+type TicketBulkService interface {
+	// Execute checks the permission on the node of every ticket and reports each one;
+	// the batch is written to the activity log as a single entry
+	Execute(ctx context.Context, req *models.BulkTicketRequest, userID, userRole string) (*models.BulkTicketResult, error)
+}
+
+type ticketBulkService struct {
+	ticketService      TicketService
+	permissionService  PermissionService
+	activityLogService ActivityLogService
+}
+
+func NewTicketBulkService(ticketService TicketService, permissionService PermissionService, activityLogService ActivityLogService) TicketBulkService {
+	return &ticketBulkService{
+		ticketService:      ticketService,
+		permissionService:  permissionService,
+		activityLogService: activityLogService,
+	}
+}
+
+func (s *ticketBulkService) Execute(ctx context.Context, req *models.BulkTicketRequest, userID, userRole string) (*models.BulkTicketResult, error) {
+	code := BulkTicketPermission(req.Action)
+	if code == "" {
+		return nil, fmt.Errorf("unknown bulk action %q", req.Action)
+	}
+
+	tickets := s.ticketService.WithContext(ctx)
+	result := &models.BulkTicketResult{Action: req.Action, Results: []models.BulkTicketItemResult{}}
+	seen := make(map[string]bool, len(req.TicketIDs))
+	for _, id := range req.TicketIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		item := models.BulkTicketItemResult{TicketID: id, Success: true}
+		if err := s.apply(tickets, req, id, code, userID, userRole); err != nil {
+			item.Success = false
+			item.Error = err.Error()
+			result.Failed++
+		} else {
+			result.Succeeded++
+		}
+		result.Results = append(result.Results, item)
+	}
+	result.Total = len(result.Results)
+
+	s.audit(ctx, req, result, userID)
+	return result, nil
+}
+
+// apply runs the action on one ticket
+func (s *ticketBulkService) apply(tickets TicketService, req *models.BulkTicketRequest, id, code, userID, userRole string) error {
+	ticket, err := tickets.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("ticket not found")
+		}
+		return err
+	}
+
+	// API keys hold the permission through their scopes, checked before the batch starts
+	if userRole != models.RoleIntegration {
+		var nodeID uint
+		if ticket.NodeID != nil {
+			nodeID = *ticket.NodeID
+		}
+		allowed, err := s.permissionService.HasPermission(userID, userRole, code, nodeID)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			return fmt.Errorf("permission required: %s", code)
+		}
+	}
+
+	switch req.Action {
+	case models.TicketBulkStatus:
+		return tickets.ChangeStatus(id, userID, &models.UpdateStatusRequest{Status: req.Status, Notes: req.Notes})
+	case models.TicketBulkAssign:
+		assign := models.AssignTechnicianRequest{TechnicianIDs: req.TechnicianIDs}
+		_, err := tickets.SetAssignments(id, assign.ToAssignments())
+		return err
+	default:
+		return tickets.Delete(id)
+	}
+}
+
+func (s *ticketBulkService) audit(ctx context.Context, req *models.BulkTicketRequest, result *models.BulkTicketResult, userID string) {
+	if s.activityLogService == nil || userID == "" {
+		return
+	}
+
+	var description string
+	switch req.Action {
+	case models.TicketBulkStatus:
+		description = fmt.Sprintf("Alterou o status de %d chamados para %s", result.Succeeded, req.Status)
+	case models.TicketBulkAssign:
+		description = fmt.Sprintf("Atribuiu %d chamados aos técnicos %s", result.Succeeded, strings.Join(req.TechnicianIDs, ", "))
+	default:
+		description = fmt.Sprintf("Excluiu %d chamados", result.Succeeded)
+	}
+	succeeded := make([]string, 0, result.Succeeded)
+	for _, item := range result.Results {
+		if item.Success {
+			succeeded = append(succeeded, item.TicketID)
+		}
+	}
+	if len(succeeded) > 0 {
+		description += ": " + strings.Join(succeeded, ", ")
+	}
+	if result.Failed > 0 {
+		description += fmt.Sprintf(" (%d falharam)", result.Failed)
+	}
+
+	action := "tickets_bulk_" + strings.ToLower(req.Action)
+	if err := s.activityLogService.LogActionContext(ctx, userID, action, "ticket", "", description, "", ""); err != nil {
+		slog.WarnContext(ctx, "Failed to audit bulk ticket action", "error", err)
+	}
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"github.com/shopspring/decimal"
+)
+
+// Subcategory of the income entry created when a ticket is closed
+const ticketIncomeSubcategory = "os_completion"
+
+// RegisterTicketClosingHandlers registers the outbox handlers of the ticket closing topics:
+// the service income entry, billable parts included, and the consumption of the reserved parts
+func RegisterTicketClosingHandlers(outbox OutboxService, financial *FinancialService, prices PriceListService, parts TicketPartService, stock StockService, userRepo repositories.UserRepository) {
+	outbox.Register(models.OutboxTopicTicketIncome, func(ctx context.Context, event *models.OutboxEvent) (string, error) {
+		payload, err := decodeTicketClosed(event)
+		if err != nil {
+			return "", err
+		}
+		return createTicketIncome(financial.WithContext(ctx), prices, parts.WithContext(ctx), userRepo.WithContext(ctx), payload)
+	})
+	outbox.Register(models.OutboxTopicTicketStock, func(ctx context.Context, event *models.OutboxEvent) (string, error) {
+		payload, err := decodeTicketClosed(event)
+		if err != nil {
+			return "", err
+		}
+		actor, err := ticketClosingActor(userRepo.WithContext(ctx), payload)
+		if err != nil {
+			return "", err
+		}
+		consumed, err := stock.WithContext(ctx).ConsumeTicketReservations(payload.TicketID, actor)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d reservations sent for consumption", consumed), nil
+	})
+}
+
+func decodeTicketClosed(event *models.OutboxEvent) (*models.TicketClosedPayload, error) {
+	var payload models.TicketClosedPayload
+	if err := json.Unmarshal([]byte(event.Payload), &payload); err != nil {
+		return nil, fmt.Errorf("invalid payload: %w", err)
+	}
+	return &payload, nil
+}
+
+// createTicketIncome records the service price of the ticket and its billable parts not
+// billed yet as income, marking the parts billed along with it. It does nothing when the
+// ticket already has one, so retries don't duplicate it, or when there is neither a
+// service price nor billable parts.
+func createTicketIncome(financial *FinancialService, prices PriceListService, parts TicketPartService, userRepo repositories.UserRepository, payload *models.TicketClosedPayload) (string, error) {
+	entries, _, err := financial.ListEntries(models.FinancialEntryFilter{
+		Type:     models.FinancialEntryTypeIncome,
+		Category: "service",
+		TicketID: payload.TicketID,
+		Limit:    pagination.HardMax,
+	})
+	if err != nil {
+		return "", err
+	}
+	for _, entry := range entries {
+		if entry.Subcategory == ticketIncomeSubcategory && entry.Status != models.FinancialEntryStatusCancelled {
+			return "income already recorded: " + entry.ID, nil
+		}
+	}
+
+	resolved, err := prices.Resolve(&models.ResolvePricesRequest{
+		TicketID: payload.TicketID,
+		Date:     payload.ClosedAt.Format("2006-01-02"),
+		Lines:    []models.PriceLine{{Kind: "SERVICE"}},
+	})
+	if err != nil {
+		return "", err
+	}
+	total := decimal.Zero
+	if resolved.Missing == 0 {
+		total = resolved.Total
+	}
+	unbilled, err := parts.Unbilled(payload.TicketID)
+	if err != nil {
+		return "", err
+	}
+	for _, part := range unbilled {
+		if part.Total != nil {
+			total = total.Add(*part.Total)
+		}
+	}
+	if !total.IsPositive() {
+		return "no service price or billable parts for the ticket, income skipped", nil
+	}
+
+	actor, err := ticketClosingActor(userRepo, payload)
+	if err != nil {
+		return "", err
+	}
+	description := "Service of ticket " + payload.TicketID
+	if len(unbilled) > 0 {
+		description += fmt.Sprintf(" (%d billable parts)", len(unbilled))
+	}
+	amount, _ := total.Float64()
+	entry, created, err := financial.CreateTicketIncome(models.CreateFinancialEntryRequest{
+		Type:        models.FinancialEntryTypeIncome,
+		Category:    "service",
+		Subcategory: ticketIncomeSubcategory,
+		Description: description,
+		Amount:      amount,
+		EntryDate:   payload.ClosedAt.Format("2006-01-02"),
+		TicketID:    payload.TicketID,
+		ClientID:    resolved.ClientID,
+	}, unbilled, actor, "", "outbox")
+	if err != nil {
+		return "", err
+	}
+	if !created {
+		return "income already recorded by a concurrent run", nil
+	}
+	return "income recorded: " + entry.ID, nil
+}
+
+// ticketClosingActor is the user the follow-up records are attributed to: whoever closed
+// the ticket, or the first admin of the tenant of userRepo when the system closed it
+func ticketClosingActor(userRepo repositories.UserRepository, payload *models.TicketClosedPayload) (string, error) {
+	if payload.ClosedBy != "" {
+		return payload.ClosedBy, nil
+	}
+	admins, err := userRepo.FindByRole("ADMIN")
+	if err != nil {
+		return "", err
+	}
+	if len(admins) == 0 {
+		return "", fmt.Errorf("no admin to attribute the ticket closing to")
+	}
+	return admins[0].ID, nil
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTicketPartTicketNotFound   = errors.New("ticket not found")
+	ErrTicketPartTicketClosed     = errors.New("parts cannot be added to closed or cancelled tickets")
+	ErrTicketPartLocationRequired = errors.New("locationId is required: the caller has no single technician stock location")
+	ErrTicketPartPriceMissing     = errors.New("no price for the part, set unitPrice to bill it")
+	ErrTicketPartNegativePrice    = errors.New("unitPrice must not be negative")
+)
+
+// TicketPartService records the parts used on tickets: the stock leaves through a
+// SAIDA_CONSUMO_OS movement and billable parts are charged with the ticket income
+type TicketPartService interface {
+	Add(ticketID string, req *models.AddTicketPartRequest, userID string) (*models.TicketPartResponse, error)
+	List(ticketID string) (*models.TicketPartsSummary, error)
+	// Unbilled returns the billable parts the closing income of the ticket has to charge
+	Unbilled(ticketID string) ([]models.TicketPart, error)
+	// WithContext returns the service running its queries with ctx, inside the tenant it carries
+	WithContext(ctx context.Context) TicketPartService
+}
+
+type ticketPartService struct {
+	repo           repositories.TicketPartRepository
+	ticketRepo     repositories.TicketRepository
+	technicianRepo repositories.TechnicianRepository
+	stock          StockService
+	prices         PriceListService
+}
+
+func NewTicketPartService(
+	repo repositories.TicketPartRepository,
+	ticketRepo repositories.TicketRepository,
+	technicianRepo repositories.TechnicianRepository,
+	stock StockService,
+	prices PriceListService,
+) TicketPartService {
+	return &ticketPartService{
+		repo:           repo,
+		ticketRepo:     ticketRepo,
+		technicianRepo: technicianRepo,
+		stock:          stock,
+		prices:         prices,
+	}
+}
+
+func (s *ticketPartService) WithContext(ctx context.Context) TicketPartService {
+	scoped := *s
+	scoped.ticketRepo = s.ticketRepo.WithContext(ctx)
+	scoped.technicianRepo = s.technicianRepo.WithContext(ctx)
+	scoped.stock = s.stock.WithContext(ctx)
+	return &scoped
+}
+
+func (s *ticketPartService) Add(ticketID string, req *models.AddTicketPartRequest, userID string) (*models.TicketPartResponse, error) {
+	ticket, err := s.ticketRepo.FindByID(ticketID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTicketPartTicketNotFound
+		}
+		return nil, err
+	}
+	if ticket.Status == models.TicketStatusClosed || ticket.Status == models.TicketStatusCancelled {
+		return nil, ErrTicketPartTicketClosed
+	}
+
+	location, err := s.location(req.LocationID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Price before touching stock, so a part that can't be billed isn't consumed
+	var unitPrice, total *decimal.Decimal
+	if req.Billable {
+		price, err := s.unitPrice(ticket.ID, req)
+		if err != nil {
+			return nil, err
+		}
+		lineTotal := price.Mul(decimal.NewFromInt(int64(req.Quantity))).Round(2)
+		unitPrice, total = &price, &lineTotal
+	}
+
+	movement, err := s.stock.CreateMovement(models.CreateStockMovementRequest{
+		ScopeID:        location.ScopeID,
+		Type:           string(models.MovementTypeSaidaConsumoOS),
+		ItemID:         req.ItemID,
+		FromLocationID: location.ID,
+		TicketID:       ticket.ID,
+		Quantity:       req.Quantity,
+		Notes:          req.Notes,
+		SerialNumbers:  req.SerialNumbers,
+	}, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	part := &models.TicketPart{
+		TicketID:   ticket.ID,
+		ItemID:     req.ItemID,
+		LocationID: location.ID,
+		MovementID: movement.ID,
+		Quantity:   req.Quantity,
+		Billable:   req.Billable,
+		UnitPrice:  unitPrice,
+		Total:      total,
+		Notes:      req.Notes,
+		RecordedBy: userID,
+	}
+	if err := s.repo.Create(part); err != nil {
+		return nil, fmt.Errorf("movement %s recorded but not the ticket part: %w", movement.ID, err)
+	}
+	created, err := s.repo.FindByID(part.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.TicketPartResponse{TicketPart: *created, Warnings: movement.Warnings}, nil
+}
+
+// location is the location given, else the only active TECHNICIAN location of the caller
+func (s *ticketPartService) location(locationID, userID string) (*models.StockLocation, error) {
+	if locationID != "" {
+		return s.stock.GetLocation(locationID)
+	}
+	technician, err := s.technicianRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, ErrTicketPartLocationRequired
+	}
+	active := true
+	locations, err := s.stock.ListLocations(models.StockLocationFilter{
+		Type:         string(models.LocationTechnician),
+		TechnicianID: technician.ID,
+		IsActive:     &active,
+		PageSize:     2,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(locations.Data) != 1 {
+		return nil, ErrTicketPartLocationRequired
+	}
+	return &locations.Data[0], nil
+}
+
+func (s *ticketPartService) unitPrice(ticketID string, req *models.AddTicketPartRequest) (decimal.Decimal, error) {
+	if req.UnitPrice != nil {
+		if req.UnitPrice.IsNegative() {
+			return decimal.Zero, ErrTicketPartNegativePrice
+		}
+		return *req.UnitPrice, nil
+	}
+	resolved, err := s.prices.Resolve(&models.ResolvePricesRequest{
+		TicketID: ticketID,
+		Date:     time.Now().Format("2006-01-02"),
+		Lines: []models.PriceLine{{
+			Kind:     string(models.PriceKindPart),
+			ItemID:   req.ItemID,
+			Quantity: decimal.NewFromInt(int64(req.Quantity)),
+		}},
+	})
+	if err != nil {
+		return decimal.Zero, err
+	}
+	if resolved.Missing > 0 || len(resolved.Lines) == 0 {
+		return decimal.Zero, ErrTicketPartPriceMissing
+	}
+	return resolved.Lines[0].UnitPrice, nil
+}
+
+func (s *ticketPartService) List(ticketID string) (*models.TicketPartsSummary, error) {
+	if _, err := s.ticketRepo.FindByID(ticketID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTicketPartTicketNotFound
+		}
+		return nil, err
+	}
+	parts, err := s.repo.FindByTicket(ticketID)
+	if err != nil {
+		return nil, err
+	}
+	summary := &models.TicketPartsSummary{TicketID: ticketID, Parts: parts, BillableTotal: decimal.Zero}
+	if summary.Parts == nil {
+		summary.Parts = []models.TicketPart{}
+	}
+	for _, part := range parts {
+		if !part.Billable || part.Total == nil {
+			continue
+		}
+		if part.Movement != nil && part.Movement.Status == models.MovementStatusRejected {
+			continue
+		}
+		summary.BillableTotal = summary.BillableTotal.Add(*part.Total)
+	}
+	return summary, nil
+}
+
+func (s *ticketPartService) Unbilled(ticketID string) ([]models.TicketPart, error) {
+	return s.repo.FindUnbilled(ticketID)
+}
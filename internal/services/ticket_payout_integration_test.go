@@ -0,0 +1,54 @@
+//go:build integration
+
+package services_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"github.com/shigake/tech-iq-back/internal/services"
+	"github.com/shigake/tech-iq-back/internal/testutil"
+)
+
+func TestTicketIsPaidOutOnce(t *testing.T) {
+	env.Reset(t)
+	financialRepo := repositories.NewFinancialRepository(env.DB)
+	financial := services.NewFinancialService(
+		financialRepo,
+		repositories.NewCategoryRepository(env.DB),
+		nil,
+		repositories.NewSupplierRepository(env.DB),
+		nil,
+		nil,
+		nil,
+	)
+	ticket := &models.Ticket{ErrorDescription: "Paid out ticket", Status: models.TicketStatusClosed, Priority: models.TicketPriorityNormal}
+	if err := repositories.NewTicketRepository(env.DB).Create(ticket); err != nil {
+		t.Fatalf("create ticket: %v", err)
+	}
+
+	shares := []models.TicketPayoutShare{{TechnicianID: testutil.TechnicianID, Role: models.AssignmentRoleLead, SharePercent: 100, Amount: 300}}
+	req := models.CreateTicketPayoutRequest{TotalAmount: 300, EntryDate: time.Now().Format("2006-01-02")}
+
+	entries, err := financial.CreateTicketPayouts(ticket.ID, shares, req, testutil.AdminUserID, "", "")
+	if err != nil {
+		t.Fatalf("first payout: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("first payout created %d entries, want 1", len(entries))
+	}
+	if _, err := financial.CreateTicketPayouts(ticket.ID, shares, req, testutil.AdminUserID, "", ""); !errors.Is(err, services.ErrTicketPayoutsExist) {
+		t.Fatalf("second payout err = %v, want ErrTicketPayoutsExist", err)
+	}
+
+	// Cancelling the payments allows paying the ticket out again
+	if err := financialRepo.UpdateEntryStatus(entries[0].ID, models.FinancialEntryStatusCancelled, nil, "", testutil.AdminUserID); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	if _, err := financial.CreateTicketPayouts(ticket.ID, shares, req, testutil.AdminUserID, "", ""); err != nil {
+		t.Fatalf("payout after cancelling: %v", err)
+	}
+}
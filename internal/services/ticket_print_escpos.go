@@ -0,0 +1,175 @@
+package services
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// 80mm printers print 48 columns with the default font (Font A)
+const escposColumns = 48
+
+var (
+	escposInit        = []byte{0x1B, 0x40}       // ESC @
+	escposCodePage    = []byte{0x1B, 0x74, 0x10} // ESC t 16: WPC1252
+	escposAlignLeft   = []byte{0x1B, 0x61, 0x00}
+	escposAlignCenter = []byte{0x1B, 0x61, 0x01}
+	escposBoldOn      = []byte{0x1B, 0x45, 0x01}
+	escposBoldOff     = []byte{0x1B, 0x45, 0x00}
+	escposDoubleOn    = []byte{0x1D, 0x21, 0x11} // GS ! double width and height
+	escposDoubleOff   = []byte{0x1D, 0x21, 0x00}
+	escposFeedAndCut  = []byte{0x1D, 0x56, 0x42, 0x03} // GS V B: feed 3 lines and partial cut
+)
+
+// renderESCPOS renders the service order as raw ESC/POS commands for 80mm thermal printers
+func renderESCPOS(order *printedOrder) []byte {
+	var buf bytes.Buffer
+	encoder := encoding.ReplaceUnsupported(charmap.Windows1252.NewEncoder())
+	text := func(s string) {
+		encoded, err := encoder.String(s)
+		if err != nil {
+			encoded = s
+		}
+		buf.WriteString(encoded)
+	}
+	line := func(s string) {
+		text(s)
+		buf.WriteByte('\n')
+	}
+	separator := func() {
+		line(strings.Repeat("-", escposColumns))
+	}
+	field := func(label, value string) {
+		if value == "" {
+			return
+		}
+		buf.Write(escposBoldOn)
+		text(label + ": ")
+		buf.Write(escposBoldOff)
+		for i, l := range wrapText(value, escposColumns-utf8.RuneCountInString(label)-2, escposColumns-2) {
+			if i > 0 {
+				text("  ")
+			}
+			line(l)
+		}
+	}
+
+	buf.Write(escposInit)
+	buf.Write(escposCodePage)
+
+	buf.Write(escposAlignCenter)
+	buf.Write(escposBoldOn)
+	line(order.Company)
+	buf.Write(escposBoldOff)
+	line("ORDEM DE SERVIÇO")
+	buf.Write(escposDoubleOn)
+	line(order.OSNumber)
+	buf.Write(escposDoubleOff)
+	buf.Write(escposAlignLeft)
+	separator()
+
+	field("Abertura", order.OpenedAt)
+	field("Status", order.Status)
+	field("Prioridade", order.Priority)
+	field("Categoria", order.Category)
+	field("Agendado", order.Scheduled)
+	separator()
+
+	field("Cliente", order.Client)
+	field("CPF/CNPJ", order.ClientDoc)
+	field("Telefone", order.ClientPhone)
+	field("Endereço", order.Address)
+	separator()
+
+	field("Equipamento", order.Equipment)
+	field("Nº de série", order.Serial)
+	field("Técnico", strings.Join(order.Technicians, ", "))
+	if order.Problem != "" {
+		buf.Write(escposBoldOn)
+		line("Problema relatado:")
+		buf.Write(escposBoldOff)
+		for _, l := range wrapText(order.Problem, escposColumns, escposColumns) {
+			line(l)
+		}
+	}
+	separator()
+
+	buf.WriteString("\n\n")
+	line(strings.Repeat("_", escposColumns-8))
+	line("Assinatura do cliente")
+	buf.WriteString("\n")
+
+	buf.Write(escposAlignCenter)
+	writeESCPOSQRCode(&buf, order.TrackingURL)
+	line("Acompanhe seu atendimento")
+	line("Impresso em " + order.PrintedAt)
+	if order.Footer != "" {
+		for _, l := range wrapText(order.Footer, escposColumns, escposColumns) {
+			line(l)
+		}
+	}
+	buf.Write(escposAlignLeft)
+	buf.Write(escposFeedAndCut)
+
+	return buf.Bytes()
+}
+
+// writeESCPOSQRCode prints a QR code with the printer's native GS ( k commands
+func writeESCPOSQRCode(buf *bytes.Buffer, data string) {
+	if data == "" || len(data) > 7000 {
+		return
+	}
+	qr := func(fn byte, params ...byte) {
+		size := len(params) + 2
+		buf.Write([]byte{0x1D, 0x28, 0x6B, byte(size), byte(size >> 8), 0x31, fn})
+		buf.Write(params)
+	}
+
+	qr(0x41, 0x32, 0x00) // model 2
+	qr(0x43, 0x06)       // module size 6 dots
+	qr(0x45, 0x31)       // error correction M
+	qr(0x50, append([]byte{0x30}, data...)...)
+	qr(0x51, 0x30) // print
+	buf.WriteByte('\n')
+}
+
+// wrapText breaks s into lines of at most first runes for the first line and
+// rest runes for the following ones; longer words are split
+func wrapText(s string, first, rest int) []string {
+	var lines []string
+	var current []rune
+	width := first
+	flush := func() {
+		lines = append(lines, string(current))
+		current = current[:0]
+		width = rest
+	}
+
+	for _, paragraph := range strings.Split(s, "\n") {
+		for _, word := range strings.Fields(paragraph) {
+			runes := []rune(word)
+			if len(current) > 0 && len(current)+1+len(runes) > width {
+				flush()
+			}
+			for len(runes) > width {
+				if len(current) > 0 {
+					flush()
+				}
+				current = append(current, runes[:width]...)
+				runes = runes[width:]
+				flush()
+			}
+			if len(current) > 0 {
+				current = append(current, ' ')
+			}
+			current = append(current, runes...)
+		}
+		if len(current) > 0 {
+			flush()
+		}
+	}
+	return lines
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"html/template"
+	"regexp"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// 80mm paper has ~72mm of printable width
+var serviceOrderTemplate = template.Must(template.New("service-order").Parse(`<!DOCTYPE html>
+<html lang="pt-BR">
+<head>
+<meta charset="utf-8">
+<title>OS {{.OSNumber}}</title>
+<style>
+  @page { size: 80mm auto; margin: 0; }
+  body { width: 72mm; margin: 0 auto; padding: 2mm 0; font: 11px/1.35 "Courier New", monospace; color: #000; }
+  h1, h2 { text-align: center; margin: 0; }
+  h1 { font-size: 13px; }
+  h2 { font-size: 18px; margin: 1mm 0; }
+  .center { text-align: center; }
+  hr { border: 0; border-top: 1px dashed #000; margin: 2mm 0; }
+  dl { margin: 0; }
+  dt { font-weight: bold; display: inline; }
+  dt::after { content: ": "; }
+  dd { display: inline; margin: 0; }
+  dd::after { content: ""; display: block; }
+  .problem { white-space: pre-wrap; word-wrap: break-word; }
+  .signature { margin-top: 12mm; border-top: 1px solid #000; text-align: center; padding-top: 1mm; }
+  .qr img { width: 32mm; height: 32mm; image-rendering: pixelated; }
+  .logo img { max-width: 48mm; max-height: 20mm; }
+  .footer { white-space: pre-wrap; margin-top: 2mm; }
+  {{with .PrimaryColor}}h1, h2 { color: {{.}}; }{{end}}
+  {{with .AccentColor}}hr, .signature { border-color: {{.}}; }{{end}}
+</style>
+</head>
+<body onload="window.print()">
+{{with .LogoURL}}<div class="center logo"><img src="{{.}}" alt="Logo"></div>{{end}}
+<h1>{{.Company}}</h1>
+<div class="center">ORDEM DE SERVIÇO</div>
+<h2>{{.OSNumber}}</h2>
+<hr>
+<dl>
+  <dt>Abertura</dt><dd>{{.OpenedAt}}</dd>
+  <dt>Status</dt><dd>{{.Status}}</dd>
+  <dt>Prioridade</dt><dd>{{.Priority}}</dd>
+  {{with .Category}}<dt>Categoria</dt><dd>{{.}}</dd>{{end}}
+  {{with .Scheduled}}<dt>Agendado</dt><dd>{{.}}</dd>{{end}}
+</dl>
+{{if .Client}}<hr>
+<dl>
+  <dt>Cliente</dt><dd>{{.Client}}</dd>
+  {{with .ClientDoc}}<dt>CPF/CNPJ</dt><dd>{{.}}</dd>{{end}}
+  {{with .ClientPhone}}<dt>Telefone</dt><dd>{{.}}</dd>{{end}}
+  {{with .Address}}<dt>Endereço</dt><dd>{{.}}</dd>{{end}}
+</dl>{{end}}
+<hr>
+<dl>
+  {{with .Equipment}}<dt>Equipamento</dt><dd>{{.}}</dd>{{end}}
+  {{with .Serial}}<dt>Nº de série</dt><dd>{{.}}</dd>{{end}}
+  {{range .Technicians}}<dt>Técnico</dt><dd>{{.}}</dd>{{end}}
+</dl>
+{{with .Problem}}<p><b>Problema relatado:</b></p>
+<div class="problem">{{.}}</div>{{end}}
+<div class="signature">Assinatura do cliente</div>
+{{with .QRCode}}<div class="center qr"><img src="{{.}}" alt="QR code"></div>{{end}}
+<div class="center">Acompanhe seu atendimento</div>
+<div class="center">Impresso em {{.PrintedAt}}</div>
+{{with .Footer}}<div class="center footer">{{.}}</div>{{end}}
+</body>
+</html>
+`))
+
+// renderHTML80mm renders the service order as a printable page sized for 80mm paper
+func renderHTML80mm(order *printedOrder) ([]byte, error) {
+	data := struct {
+		*printedOrder
+		QRCode       template.URL
+		LogoURL      template.URL
+		PrimaryColor template.CSS
+		AccentColor  template.CSS
+	}{
+		printedOrder: order,
+		PrimaryColor: cssColor(order.PrimaryColor),
+		AccentColor:  cssColor(order.AccentColor),
+	}
+	if len(order.Logo) > 0 {
+		data.LogoURL = template.URL("data:" + order.LogoContentType + ";base64," + base64.StdEncoding.EncodeToString(order.Logo))
+	}
+
+	if order.TrackingURL != "" {
+		png, err := qrcode.Encode(order.TrackingURL, qrcode.Medium, 256)
+		if err != nil {
+			return nil, err
+		}
+		data.QRCode = template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(png))
+	}
+
+	var buf bytes.Buffer
+	if err := serviceOrderTemplate.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var hexColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// cssColor lets a #rrggbb color into the stylesheet; anything else is dropped
+func cssColor(color string) template.CSS {
+	if !hexColorPattern.MatchString(color) {
+		return ""
+	}
+	return template.CSS(color)
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+)
+
+const (
+	PrintFormatESCPOS   = "escpos"
+	PrintFormatHTML80mm = "html80mm"
+)
+
+var ErrUnsupportedPrintFormat = errors.New("unsupported print format, expected escpos or html80mm")
+
+var printStatusLabels = map[models.TicketStatus]string{
+	models.TicketStatusOpen:          "Aberto",
+	models.TicketStatusInProgress:    "Em atendimento",
+	models.TicketStatusForClosing:    "Para fechamento",
+	models.TicketStatusClosed:        "Fechado",
+	models.TicketStatusUnproductive:  "Improdutivo",
+	models.TicketStatusCancelled:     "Cancelado",
+	models.TicketStatusWaitingClient: "Aguardando cliente",
+	models.TicketStatusWaitingParts:  "Aguardando peças",
+}
+
+var printPriorityLabels = map[models.TicketPriority]string{
+	models.TicketPriorityLow:    "Baixa",
+	models.TicketPriorityNormal: "Normal",
+	models.TicketPriorityHigh:   "Alta",
+	models.TicketPriorityUrgent: "Urgente",
+}
+
+type TicketPrintService interface {
+	// Print renders the service order of a ticket, returning the content and its content type
+	Print(ticketID, format string) ([]byte, string, error)
+	// WithContext returns the service running its queries with ctx, inside the tenant it carries
+	WithContext(ctx context.Context) TicketPrintService
+}
+
+type ticketPrintService struct {
+	ticketRepo  repositories.TicketRepository
+	branding    BrandingService
+	trackingURL string
+}
+
+func NewTicketPrintService(ticketRepo repositories.TicketRepository, branding BrandingService, trackingURL string) TicketPrintService {
+	return &ticketPrintService{
+		ticketRepo:  ticketRepo,
+		branding:    branding,
+		trackingURL: trackingURL,
+	}
+}
+
+func (s *ticketPrintService) WithContext(ctx context.Context) TicketPrintService {
+	scoped := *s
+	scoped.ticketRepo = s.ticketRepo.WithContext(ctx)
+	return &scoped
+}
+
+// printedOrder is the layout-independent content of a printed service order
+type printedOrder struct {
+	Company     string
+	OSNumber    string
+	Status      string
+	Priority    string
+	OpenedAt    string
+	Scheduled   string
+	Category    string
+	Client      string
+	ClientDoc   string
+	ClientPhone string
+	Address     string
+	Equipment   string
+	Serial      string
+	Technicians []string
+	Problem     string
+	TrackingURL string
+	PrintedAt   string
+
+	// Branding of the ticket's top-level node
+	Footer          string
+	PrimaryColor    string
+	AccentColor     string
+	Logo            []byte
+	LogoContentType string
+}
+
+func (s *ticketPrintService) Print(ticketID, format string) ([]byte, string, error) {
+	if format == "" {
+		format = PrintFormatHTML80mm
+	}
+	if format != PrintFormatESCPOS && format != PrintFormatHTML80mm {
+		return nil, "", ErrUnsupportedPrintFormat
+	}
+
+	ticket, err := s.ticketRepo.FindByID(ticketID)
+	if err != nil {
+		return nil, "", err
+	}
+	branding, err := s.branding.Resolve(ticket.NodeID)
+	if err != nil {
+		return nil, "", err
+	}
+	order := s.buildOrder(ticket, branding)
+
+	if format == PrintFormatESCPOS {
+		return renderESCPOS(order), "application/octet-stream", nil
+	}
+	content, err := renderHTML80mm(order)
+	if err != nil {
+		return nil, "", err
+	}
+	return content, "text/html; charset=utf-8", nil
+}
+
+func (s *ticketPrintService) buildOrder(ticket *models.Ticket, branding *models.DocumentBranding) *printedOrder {
+	loc := printLocation()
+	order := &printedOrder{
+		Company:     branding.DisplayName,
+		OSNumber:    serviceOrderNumber(branding, ticket.OSNumber),
+		Status:      printStatusLabels[ticket.Status],
+		Priority:    printPriorityLabels[ticket.Priority],
+		OpenedAt:    ticket.CreatedAt.In(loc).Format("02/01/2006 15:04"),
+		Equipment:   strings.Join(nonEmpty(ticket.ComputerBrand, ticket.ComputerModel), " "),
+		Serial:      ticket.SerialNumber,
+		Problem:     strings.TrimSpace(ticket.ErrorDescription),
+		TrackingURL: s.trackingLink(ticket),
+		PrintedAt:   time.Now().In(loc).Format("02/01/2006 15:04"),
+
+		Footer:          branding.FooterText,
+		PrimaryColor:    branding.PrimaryColor,
+		AccentColor:     branding.AccentColor,
+		Logo:            branding.Logo,
+		LogoContentType: branding.LogoContentType,
+	}
+	if order.Status == "" {
+		order.Status = string(ticket.Status)
+	}
+	if order.Priority == "" {
+		order.Priority = string(ticket.Priority)
+	}
+
+	if ticket.ScheduledStart != nil {
+		order.Scheduled = ticket.ScheduledStart.In(loc).Format("02/01/2006 15:04")
+		if ticket.ScheduledEnd != nil {
+			order.Scheduled += " - " + ticket.ScheduledEnd.In(loc).Format("15:04")
+		}
+	}
+	if ticket.Category != nil {
+		order.Category = ticket.Category.Name
+	}
+	if ticket.Client != nil {
+		order.Client = ticket.Client.FullName
+		order.ClientDoc = ticket.Client.CNPJ
+		if order.ClientDoc == "" {
+			order.ClientDoc = ticket.Client.CPF
+		}
+		order.ClientPhone = ticket.Client.Phone
+		order.Address = clientAddress(ticket.Client)
+	}
+	for _, technician := range ticket.Technicians {
+		order.Technicians = append(order.Technicians, technician.FullName)
+	}
+	return order
+}
+
+// trackingLink fills the configured tracking URL; without one the QR code carries the OS number
+func (s *ticketPrintService) trackingLink(ticket *models.Ticket) string {
+	if s.trackingURL == "" {
+		return "OS " + ticket.OSNumber
+	}
+	return strings.NewReplacer("{id}", ticket.ID, "{osNumber}", ticket.OSNumber).Replace(s.trackingURL)
+}
+
+// printLocation is the timezone printed on service orders (branches are in Brazil)
+func printLocation() *time.Location {
+	loc, err := time.LoadLocation("America/Sao_Paulo")
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
@@ -1,13 +1,31 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"math"
+	"strings"
 	"time"
 
 	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/pagination"
 	"github.com/shigake/tech-iq-back/internal/repositories"
 )
 
+var (
+	ErrAssignmentLeadRequired     = errors.New("exactly one LEAD technician is required")
+	ErrAssignmentInvalidRole      = errors.New("invalid assignment role, expected LEAD or ASSISTANT")
+	ErrAssignmentDuplicate        = errors.New("technician assigned more than once")
+	ErrAssignmentInvalidShares    = errors.New("payout shares must be set for every assignee and sum to 100")
+	ErrTechnicianNotFound         = errors.New("technician not found")
+	ErrComplaintRootCauseRequired = errors.New("complaint tickets require a root-cause classification before closing")
+	ErrCancellationReasonRequired = errors.New("tickets are cancelled through POST /tickets/:id/cancel with a reason")
+	ErrTicketAlreadyAssigned      = errors.New("ticket already has technicians")
+	ErrTicketNodeRequired         = errors.New("nodeId is required: tickets of users placed in the hierarchy belong to one of their nodes")
+	ErrTicketNodeOutOfScope       = errors.New("node is outside the user's hierarchy scope")
+)
+
 type TicketService interface {
 	Create(req *models.CreateTicketRequest) (*models.Ticket, error)
 	GetAll(page, size int, filters *models.TicketFilters) (*models.PaginatedResponse, error)
@@ -16,16 +34,39 @@ type TicketService interface {
 	Update(id string, req *models.CreateTicketRequest) (*models.Ticket, error)
 	Delete(id string) error
 	UpdateStatus(id string, status string) error
+	// ChangeStatus is UpdateStatus on behalf of a user; notes explain a waiting status
+	ChangeStatus(id, userID string, req *models.UpdateStatusRequest) error
+	// AllowedTransitions lists the statuses the workflow lets the ticket move to
+	AllowedTransitions(id string) ([]models.AllowedTransition, error)
 	AssignTechnicians(id string, technicianIDs []string) error
+	SetAssignments(id string, inputs []models.TicketAssignmentInput) ([]models.TicketTechnician, error)
+	// AssignIfUnassigned is SetAssignments for a ticket without technicians;
+	// ErrTicketAlreadyAssigned when someone assigned it first
+	AssignIfUnassigned(id string, inputs []models.TicketAssignmentInput) ([]models.TicketTechnician, error)
+	GetAssignments(id string) ([]models.TicketTechnician, error)
+	GetPayoutSplit(id string, totalAmount float64) ([]models.TicketPayoutShare, error)
 	SignTicket(id string, req *models.SignTicketRequest) (*models.Ticket, error)
 	DeleteSignature(id string) (*models.Ticket, error)
+	// WithContext returns the service running its queries with ctx, inside the tenant it carries
+	WithContext(ctx context.Context) TicketService
+	// WithScope returns the service finding and changing tickets by id only inside the
+	// user's hierarchy scope
+	WithScope(scope *models.AccessScope) TicketService
 }
 
 type ticketService struct {
-	ticketRepo     repositories.TicketRepository
-	technicianRepo repositories.TechnicianRepository
-	clientRepo     repositories.ClientRepository
-	categoryRepo   repositories.CategoryRepository
+	ticketRepo          repositories.TicketRepository
+	technicianRepo      repositories.TechnicianRepository
+	clientRepo          repositories.ClientRepository
+	categoryRepo        repositories.CategoryRepository
+	coverageService     CoverageService
+	coverageEnforcement string // off, warn or block
+	notifications       NotificationService
+	events              EventPublisher
+	workflow            TicketWorkflowService
+	checklist           ChecklistService
+	// scope is the hierarchy scope of the user, set by WithScope for restricted users
+	scope *models.AccessScope
 }
 
 func NewTicketService(
@@ -33,26 +74,93 @@ func NewTicketService(
 	technicianRepo repositories.TechnicianRepository,
 	clientRepo repositories.ClientRepository,
 	categoryRepo repositories.CategoryRepository,
+	coverageService CoverageService,
+	coverageEnforcement string,
+	notifications NotificationService,
+	events EventPublisher,
+	workflow TicketWorkflowService,
+	checklist ChecklistService,
 ) TicketService {
 	return &ticketService{
-		ticketRepo:     ticketRepo,
-		technicianRepo: technicianRepo,
-		clientRepo:     clientRepo,
-		categoryRepo:   categoryRepo,
+		ticketRepo:          ticketRepo,
+		technicianRepo:      technicianRepo,
+		clientRepo:          clientRepo,
+		categoryRepo:        categoryRepo,
+		coverageService:     coverageService,
+		coverageEnforcement: coverageEnforcement,
+		notifications:       notifications,
+		events:              events,
+		workflow:            workflow,
+		checklist:           checklist,
+	}
+}
+
+func (s *ticketService) WithContext(ctx context.Context) TicketService {
+	scoped := *s
+	scoped.ticketRepo = s.ticketRepo.WithContext(ctx)
+	scoped.technicianRepo = s.technicianRepo.WithContext(ctx)
+	scoped.clientRepo = s.clientRepo.WithContext(ctx)
+	scoped.categoryRepo = s.categoryRepo.WithContext(ctx)
+	if s.checklist != nil {
+		scoped.checklist = s.checklist.WithContext(ctx)
+	}
+	if s.events != nil {
+		scoped.events = s.events.ForContext(ctx)
+	}
+	return &scoped
+}
+
+func (s *ticketService) WithScope(scope *models.AccessScope) TicketService {
+	if !scope.Restricted() {
+		return s
+	}
+	scoped := *s
+	scoped.ticketRepo = s.ticketRepo.WithScope(scope)
+	scoped.scope = scope
+	return &scoped
+}
+
+// checkNode validates the node a ticket is placed in: restricted users must place it in
+// a node of their scope, tickets without node being visible to everyone
+func (s *ticketService) checkNode(nodeID *uint) error {
+	if !s.scope.Restricted() {
+		return nil
+	}
+	if nodeID == nil {
+		return ErrTicketNodeRequired
+	}
+	inScope, err := s.ticketRepo.NodeInScope(*nodeID)
+	if err != nil {
+		return err
+	}
+	if !inScope {
+		return ErrTicketNodeOutOfScope
 	}
+	return nil
 }
 
 func (s *ticketService) Create(req *models.CreateTicketRequest) (*models.Ticket, error) {
+	if err := s.checkNode(req.NodeID); err != nil {
+		return nil, err
+	}
 	ticket := &models.Ticket{
 		ErrorDescription: req.ErrorDescription,
 		Priority:         models.TicketPriority(req.Priority),
 		Status:           models.TicketStatusOpen,
+		Source:           models.TicketSource(req.Source),
 		ComputerBrand:    req.GetBrand(),
 		ComputerModel:    req.GetModel(),
 		SerialNumber:     req.SerialNumber,
 		NodeID:           req.NodeID,
 	}
 
+	if !ticket.Source.IsValid() {
+		ticket.Source = models.TicketSourcePortal
+	}
+	if req.CampaignID != "" {
+		ticket.CampaignID = &req.CampaignID
+	}
+
 	// Set ClientID
 	if req.ClientID != "" {
 		ticket.ClientID = &req.ClientID
@@ -75,23 +183,74 @@ func (s *ticketService) Create(req *models.CreateTicketRequest) (*models.Ticket,
 		}
 	}
 
-	// Assign technicians
+	coverageWarning, err := s.checkCoverage(ticket.ClientID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate crew before creating the ticket
+	var assignments []models.TicketTechnician
 	if len(req.TechnicianIDs) > 0 {
-		technicians, err := s.technicianRepo.FindByIDs(req.TechnicianIDs)
-		if err != nil {
+		assignReq := models.AssignTechnicianRequest{TechnicianIDs: req.TechnicianIDs}
+		var err error
+		if assignments, err = s.buildAssignments(assignReq.ToAssignments()); err != nil {
 			return nil, err
 		}
-		ticket.Technicians = technicians
 	}
 
 	if err := s.ticketRepo.Create(ticket); err != nil {
 		return nil, err
 	}
 
+	if len(assignments) > 0 {
+		if err := s.ticketRepo.SetAssignments(ticket.ID, assignments); err != nil {
+			return nil, err
+		}
+		if ticket, err = s.ticketRepo.FindByID(ticket.ID); err != nil {
+			return nil, err
+		}
+		s.notifyAssigned(ticket, nil, assignments)
+	}
+
+	ticket.CoverageWarning = coverageWarning
+	if s.events != nil {
+		go s.events.Publish(models.WebhookEventTicketCreated, ticket.ToDTO())
+	}
 	return ticket, nil
 }
 
+// checkCoverage applies the coverage enforcement to the client site: blocks the ticket
+// or returns the warning to attach to it
+func (s *ticketService) checkCoverage(clientID *string) (string, error) {
+	if clientID == nil || s.coverageService == nil || s.coverageEnforcement == models.CoverageEnforcementOff {
+		return "", nil
+	}
+	warning, err := s.coverageService.ClientWarning(*clientID)
+	if err != nil || warning == "" {
+		return "", err
+	}
+	if s.coverageEnforcement == models.CoverageEnforcementBlock {
+		return "", fmt.Errorf("%w: %s", ErrClientOutOfCoverage, warning)
+	}
+	return warning, nil
+}
+
 func (s *ticketService) GetAll(page, size int, filters *models.TicketFilters) (*models.PaginatedResponse, error) {
+	if filters != nil && filters.Cursor != nil {
+		tickets, err := s.ticketRepo.FindByCursor(size, filters)
+		if err != nil {
+			return nil, err
+		}
+		tickets, next, prev := pagination.CursorPage(tickets, *filters.Cursor, size, func(t models.Ticket) ([]time.Time, string) {
+			return []time.Time{t.CreatedAt}, t.ID
+		})
+		dtos := make([]models.TicketDTO, len(tickets))
+		for i, t := range tickets {
+			dtos[i] = t.ToDTO()
+		}
+		return models.NewCursorResponse(dtos, size, next, prev), nil
+	}
+
 	tickets, total, err := s.ticketRepo.FindAll(page, size, filters)
 	if err != nil {
 		return nil, err
@@ -102,18 +261,7 @@ func (s *ticketService) GetAll(page, size int, filters *models.TicketFilters) (*
 		dtos[i] = t.ToDTO()
 	}
 
-	totalPages := int(total) / size
-	if int(total)%size > 0 {
-		totalPages++
-	}
-
-	return &models.PaginatedResponse{
-		Content:       dtos,
-		Page:          page,
-		Size:          size,
-		TotalElements: total,
-		TotalPages:    totalPages,
-	}, nil
+	return models.NewPaginatedResponse(dtos, page, size, total), nil
 }
 
 // GetAllForUser returns tickets filtered by user role
@@ -155,6 +303,9 @@ func (s *ticketService) Update(id string, req *models.CreateTicketRequest) (*mod
 	if err != nil {
 		return nil, err
 	}
+	if err := s.checkNode(req.NodeID); err != nil {
+		return nil, err
+	}
 
 	existing.ErrorDescription = req.ErrorDescription
 	existing.Priority = models.TicketPriority(req.Priority)
@@ -184,7 +335,18 @@ func (s *ticketService) Update(id string, req *models.CreateTicketRequest) (*mod
 		}
 	}
 
+	// The edit is saved only over the version it was based on
+	if req.Version > 0 {
+		existing.Version = req.Version
+	}
 	if err := s.ticketRepo.Update(existing); err != nil {
+		if errors.Is(err, repositories.ErrVersionConflict) {
+			current, findErr := s.ticketRepo.FindByID(id)
+			if findErr != nil {
+				return nil, findErr
+			}
+			return nil, NewVersionConflict("ticket", existing.Version, existing, current, current.Version)
+		}
 		return nil, err
 	}
 
@@ -196,28 +358,291 @@ func (s *ticketService) Delete(id string) error {
 }
 
 func (s *ticketService) UpdateStatus(id string, status string) error {
+	return s.ChangeStatus(id, "", &models.UpdateStatusRequest{Status: status})
+}
+
+func (s *ticketService) ChangeStatus(id, userID string, req *models.UpdateStatusRequest) error {
+	status := req.Status
 	validStatuses := map[string]bool{
-		"ABERTO":          true,
-		"EM_ATENDIMENTO":  true,
-		"PARA_FECHAMENTO": true,
-		"FECHADO":         true,
-		"IMPRODUTIVO":     true,
+		"ABERTO":             true,
+		"EM_ATENDIMENTO":     true,
+		"PARA_FECHAMENTO":    true,
+		"FECHADO":            true,
+		"IMPRODUTIVO":        true,
+		"AGUARDANDO_CLIENTE": true,
+		"AGUARDANDO_PECAS":   true,
 	}
 
+	if models.TicketStatus(status) == models.TicketStatusCancelled {
+		return ErrCancellationReasonRequired
+	}
 	if !validStatuses[status] {
 		return errors.New("invalid status")
 	}
 
-	return s.ticketRepo.UpdateStatus(id, status)
+	ticket, err := s.ticketRepo.FindByID(id)
+	if err != nil {
+		return err
+	}
+	if s.workflow != nil {
+		if err := s.workflow.Check(ticket, models.TicketStatus(status), req.Notes); err != nil {
+			return err
+		}
+	}
+	if s.checklist != nil && ticket.Status != models.TicketStatus(status) {
+		if err := s.checklist.CheckClosure(ticket, models.TicketStatus(status)); err != nil {
+			return err
+		}
+	}
+	if ticket.Status == models.TicketStatusCancelled {
+		// Reopening drops the cancellation so it no longer counts in the reports
+		if err := s.ticketRepo.ClearCancellation(id); err != nil {
+			return err
+		}
+	}
+	if ticket.Type != models.TicketTypeComplaint {
+		if err := s.ticketRepo.UpdateStatus(id, status, userID, req.Notes); err != nil {
+			return err
+		}
+		s.publishStatusChanged(ticket, status, userID, req.Notes)
+		return nil
+	}
+
+	closing := models.TicketStatus(status) == models.TicketStatusClosed
+	if closing && (ticket.Complaint == nil || ticket.Complaint.RootCause == "") {
+		return ErrComplaintRootCauseRequired
+	}
+	if err := s.ticketRepo.UpdateStatus(id, status, userID, req.Notes); err != nil {
+		return err
+	}
+	s.publishStatusChanged(ticket, status, userID, req.Notes)
+	if closing {
+		now := time.Now()
+		return s.ticketRepo.SetComplaintResolvedAt(id, &now)
+	}
+	return s.ticketRepo.SetComplaintResolvedAt(id, nil)
+}
+
+func (s *ticketService) AllowedTransitions(id string) ([]models.AllowedTransition, error) {
+	ticket, err := s.ticketRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.workflow.Allowed(ticket)
+}
+
+// publishStatusChanged queues the ticket.status_changed webhook event
+func (s *ticketService) publishStatusChanged(ticket *models.Ticket, status, userID, notes string) {
+	if s.events == nil || string(ticket.Status) == status {
+		return
+	}
+	go s.events.Publish(models.WebhookEventTicketStatusChanged, map[string]interface{}{
+		"ticketId":   ticket.ID,
+		"osNumber":   ticket.OSNumber,
+		"fromStatus": ticket.Status,
+		"toStatus":   status,
+		"changedBy":  userID,
+		"notes":      notes,
+	})
 }
 
+// AssignTechnicians keeps the legacy contract: the first technician leads, the rest assist
 func (s *ticketService) AssignTechnicians(id string, technicianIDs []string) error {
-	technicians, err := s.technicianRepo.FindByIDs(technicianIDs)
+	req := models.AssignTechnicianRequest{TechnicianIDs: technicianIDs}
+	_, err := s.SetAssignments(id, req.ToAssignments())
+	return err
+}
+
+// SetAssignments replaces the ticket crew after validating roles and payout shares
+func (s *ticketService) SetAssignments(id string, inputs []models.TicketAssignmentInput) ([]models.TicketTechnician, error) {
+	ticket, err := s.ticketRepo.FindByID(id)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	assignments, err := s.buildAssignments(inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	previous, err := s.ticketRepo.FindAssignments(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ticketRepo.SetAssignments(id, assignments); err != nil {
+		return nil, err
+	}
+	s.notifyAssigned(ticket, previous, assignments)
+	return s.ticketRepo.FindAssignments(id)
+}
+
+func (s *ticketService) AssignIfUnassigned(id string, inputs []models.TicketAssignmentInput) ([]models.TicketTechnician, error) {
+	ticket, err := s.ticketRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	assignments, err := s.buildAssignments(inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	assigned, err := s.ticketRepo.AssignIfUnassigned(id, assignments)
+	if err != nil {
+		return nil, err
+	}
+	if !assigned {
+		return nil, ErrTicketAlreadyAssigned
+	}
+	s.notifyAssigned(ticket, nil, assignments)
+	return s.ticketRepo.FindAssignments(id)
+}
+
+// notifyAssigned tells the technicians added to the crew about the ticket
+func (s *ticketService) notifyAssigned(ticket *models.Ticket, previous, assignments []models.TicketTechnician) {
+	if s.notifications == nil {
+		return
+	}
+	assigned := make(map[string]bool, len(previous))
+	for _, a := range previous {
+		assigned[a.TechnicianID] = true
+	}
+	var added []string
+	for _, a := range assignments {
+		if !assigned[a.TechnicianID] {
+			added = append(added, a.TechnicianID)
+		}
+	}
+	if len(added) == 0 {
+		return
+	}
+
+	message := fmt.Sprintf("Prioridade %s", ticket.Priority)
+	if ticket.Client != nil {
+		message += " - cliente " + ticket.Client.FullName
+	}
+	if ticket.ErrorDescription != "" {
+		message += "\n\n" + ticket.ErrorDescription
+	}
+	go s.notifications.NotifyTechnicians(added, models.Notification{
+		Event:        models.NotificationTicketAssigned,
+		Title:        fmt.Sprintf("OS %s atribuída a você", ticket.OSNumber),
+		Message:      message,
+		ResourceType: "TICKET",
+		ResourceID:   ticket.ID,
+	})
+}
+
+func (s *ticketService) GetAssignments(id string) ([]models.TicketTechnician, error) {
+	if _, err := s.ticketRepo.FindByID(id); err != nil {
+		return nil, err
+	}
+	return s.ticketRepo.FindAssignments(id)
+}
+
+// GetPayoutSplit divides a payout among the assignees. Explicit payout shares
+// are used when present, otherwise the amount is split equally. Rounding
+// differences go to the lead technician.
+func (s *ticketService) GetPayoutSplit(id string, totalAmount float64) ([]models.TicketPayoutShare, error) {
+	assignments, err := s.GetAssignments(id)
+	if err != nil {
+		return nil, err
+	}
+	if len(assignments) == 0 {
+		return []models.TicketPayoutShare{}, nil
+	}
+
+	shares := make([]models.TicketPayoutShare, len(assignments))
+	allocated := 0.0
+	leadIndex := 0
+	for i, a := range assignments {
+		percent := 100.0 / float64(len(assignments))
+		if a.PayoutShare != nil {
+			percent = *a.PayoutShare
+		}
+		amount := math.Round(totalAmount*percent) / 100
+		allocated += amount
+
+		name := ""
+		if a.Technician != nil {
+			name = a.Technician.FullName
+		}
+		if a.Role == models.AssignmentRoleLead {
+			leadIndex = i
+		}
+		shares[i] = models.TicketPayoutShare{
+			TechnicianID:   a.TechnicianID,
+			TechnicianName: name,
+			Role:           a.Role,
+			SharePercent:   math.Round(percent*100) / 100,
+			Amount:         amount,
+		}
+	}
+	shares[leadIndex].Amount = math.Round((shares[leadIndex].Amount+totalAmount-allocated)*100) / 100
+
+	return shares, nil
+}
+
+func (s *ticketService) buildAssignments(inputs []models.TicketAssignmentInput) ([]models.TicketTechnician, error) {
+	if len(inputs) == 0 {
+		return []models.TicketTechnician{}, nil
+	}
+
+	ids := make([]string, 0, len(inputs))
+	seen := make(map[string]bool)
+	leads := 0
+	sharesSet := 0
+	shareTotal := 0.0
+	assignments := make([]models.TicketTechnician, 0, len(inputs))
+
+	for _, in := range inputs {
+		if seen[in.TechnicianID] {
+			return nil, ErrAssignmentDuplicate
+		}
+		seen[in.TechnicianID] = true
+		ids = append(ids, in.TechnicianID)
+
+		role := models.AssignmentRole(strings.ToUpper(in.Role))
+		if role == "" {
+			role = models.AssignmentRoleAssistant
+		}
+		if !role.IsValid() {
+			return nil, ErrAssignmentInvalidRole
+		}
+		if role == models.AssignmentRoleLead {
+			leads++
+		}
+		if in.PayoutShare != nil {
+			if *in.PayoutShare < 0 {
+				return nil, ErrAssignmentInvalidShares
+			}
+			sharesSet++
+			shareTotal += *in.PayoutShare
+		}
+
+		assignments = append(assignments, models.TicketTechnician{
+			TechnicianID: in.TechnicianID,
+			Role:         role,
+			PayoutShare:  in.PayoutShare,
+		})
+	}
+
+	if leads != 1 {
+		return nil, ErrAssignmentLeadRequired
+	}
+	if sharesSet > 0 && (sharesSet != len(inputs) || math.Abs(shareTotal-100) > 0.01) {
+		return nil, ErrAssignmentInvalidShares
+	}
+
+	technicians, err := s.technicianRepo.FindByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(technicians) != len(ids) {
+		return nil, ErrTechnicianNotFound
 	}
 
-	return s.ticketRepo.AssignTechnicians(id, technicians)
+	return assignments, nil
 }
 
 func (s *ticketService) SignTicket(id string, req *models.SignTicketRequest) (*models.Ticket, error) {
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSyncTicketNotFound  = errors.New("ticket not found")
+	ErrTicketNotAcceptable = errors.New("only open tickets can be accepted")
+	// tickets.edit on the node of the ticket
+	ErrSyncPermissionRequired = errors.New("permission required: tickets.edit")
+	ErrInvalidSyncCursor      = errors.New("invalid sync cursor")
+)
+
+// ticketSyncPageSize bounds the server changes returned by one sync
+const ticketSyncPageSize = 200
+
+// TicketSyncService takes the ticket changes of the technician app: accepting assigned
+// tickets and the status changes made offline, through the same calls as the REST routes
+type TicketSyncService interface {
+	// Accept starts the service of an open ticket the user's technician is assigned to;
+	// accepting a ticket already in service is a no-op
+	Accept(ticketID, userID, userRole string) (*models.Ticket, error)
+	// Sync applies the changes in order, each one on its own, then returns the tickets
+	// of the user changed on the server since the cursor. A change over an older version
+	// of the ticket is not applied: it is stale when made before the last server change,
+	// a conflict otherwise.
+	Sync(req *models.TicketSyncRequest, userID, userRole string) (*models.TicketSyncResponse, error)
+	// WithContext returns the service running its queries with ctx, inside the tenant it carries
+	WithContext(ctx context.Context) TicketSyncService
+}
+
+type ticketSyncService struct {
+	ticketService     TicketService
+	ticketRepo        repositories.TicketRepository
+	technicianRepo    repositories.TechnicianRepository
+	permissionService PermissionService
+}
+
+func NewTicketSyncService(ticketService TicketService, ticketRepo repositories.TicketRepository, technicianRepo repositories.TechnicianRepository, permissionService PermissionService) TicketSyncService {
+	return &ticketSyncService{
+		ticketService:     ticketService,
+		ticketRepo:        ticketRepo,
+		technicianRepo:    technicianRepo,
+		permissionService: permissionService,
+	}
+}
+
+func (s *ticketSyncService) WithContext(ctx context.Context) TicketSyncService {
+	return &ticketSyncService{
+		ticketService:     s.ticketService.WithContext(ctx),
+		ticketRepo:        s.ticketRepo.WithContext(ctx),
+		technicianRepo:    s.technicianRepo.WithContext(ctx),
+		permissionService: s.permissionService,
+	}
+}
+
+func (s *ticketSyncService) Accept(ticketID, userID, userRole string) (*models.Ticket, error) {
+	ticket, err := s.load(ticketID, userID, userRole)
+	if err != nil {
+		return nil, err
+	}
+	switch ticket.Status {
+	case models.TicketStatusInProgress:
+		return ticket, nil
+	case models.TicketStatusOpen:
+	default:
+		return nil, ErrTicketNotAcceptable
+	}
+
+	req := &models.UpdateStatusRequest{Status: string(models.TicketStatusInProgress)}
+	if err := s.ticketService.ChangeStatus(ticketID, userID, req); err != nil {
+		return nil, err
+	}
+	return s.ticketService.GetByID(ticketID)
+}
+
+func (s *ticketSyncService) Sync(req *models.TicketSyncRequest, userID, userRole string) (*models.TicketSyncResponse, error) {
+	changedAt, afterID, err := decodeSyncCursor(req.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.TicketSyncResponse{
+		Results:    make([]models.TicketSyncResult, 0, len(req.Changes)),
+		Changed:    []models.TicketDTO{},
+		DeletedIDs: []string{},
+		Cursor:     req.Cursor,
+	}
+	for _, change := range req.Changes {
+		result := models.TicketSyncResult{LocalID: change.LocalID, TicketID: change.TicketID}
+		current, status, err := s.apply(change, userID, userRole)
+		result.Status = status
+		if err != nil {
+			result.Error = err.Error()
+		}
+		if current != nil {
+			dto := current.ToDTO()
+			result.Current = &dto
+		}
+		response.Results = append(response.Results, result)
+	}
+
+	// Technicians follow the tickets they are assigned to, the others their hierarchy scope
+	filters := &models.TicketFilters{Scope: models.NewAccessScope(userID, userRole)}
+	if userRole != "ADMIN" && userRole != "EMPLOYEE" {
+		technician, err := s.technicianRepo.FindByUserID(userID)
+		if err != nil {
+			return response, nil
+		}
+		filters.TechnicianID = technician.ID
+	}
+	tickets, err := s.ticketRepo.FindChangedSince(changedAt, afterID, ticketSyncPageSize+1, filters)
+	if err != nil {
+		return nil, err
+	}
+	if len(tickets) > ticketSyncPageSize {
+		tickets = tickets[:ticketSyncPageSize]
+		response.HasMore = true
+	}
+	for _, ticket := range tickets {
+		if ticket.DeletedAt.Valid {
+			// The first sync has no copy of deleted tickets to drop
+			if req.Cursor != "" {
+				response.DeletedIDs = append(response.DeletedIDs, ticket.ID)
+			}
+		} else {
+			response.Changed = append(response.Changed, ticket.ToDTO())
+		}
+	}
+	if n := len(tickets); n > 0 {
+		last := tickets[n-1]
+		lastChange := last.UpdatedAt
+		if last.DeletedAt.Valid {
+			lastChange = last.DeletedAt.Time
+		}
+		response.Cursor = encodeSyncCursor(lastChange, last.ID)
+	}
+	return response, nil
+}
+
+// apply returns the ticket after the change and the outcome
+func (s *ticketSyncService) apply(change models.TicketSyncChange, userID, userRole string) (*models.Ticket, string, error) {
+	ticket, err := s.load(change.TicketID, userID, userRole)
+	if err != nil {
+		return nil, models.TicketSyncError, err
+	}
+
+	// Retried uploads of a change already applied are not conflicts
+	if string(ticket.Status) == change.Status {
+		return ticket, models.TicketSyncApplied, nil
+	}
+	if change.BaseVersion != ticket.Version {
+		if change.ChangedAt != nil && change.ChangedAt.Before(ticket.UpdatedAt) {
+			return ticket, models.TicketSyncStale, nil
+		}
+		if change.BaseVersion > 0 {
+			return ticket, models.TicketSyncConflict, nil
+		}
+	}
+
+	req := &models.UpdateStatusRequest{Status: change.Status, Notes: change.Notes}
+	if err := s.ticketService.ChangeStatus(change.TicketID, userID, req); err != nil {
+		return ticket, models.TicketSyncError, err
+	}
+	// The change is in; failing to read it back only leaves the result without the ticket
+	current, _ := s.ticketService.GetByID(change.TicketID)
+	return current, models.TicketSyncApplied, nil
+}
+
+// load returns the ticket when the user may edit it: tickets.edit on its node and, for
+// technicians, an assignment to it
+func (s *ticketSyncService) load(ticketID, userID, userRole string) (*models.Ticket, error) {
+	ticket, err := s.ticketService.GetByID(ticketID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSyncTicketNotFound
+		}
+		return nil, err
+	}
+
+	var nodeID uint
+	if ticket.NodeID != nil {
+		nodeID = *ticket.NodeID
+	}
+	allowed, err := s.permissionService.HasPermission(userID, userRole, "tickets.edit", nodeID)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, ErrSyncPermissionRequired
+	}
+
+	if userRole == "ADMIN" || userRole == "EMPLOYEE" {
+		return ticket, nil
+	}
+	technician, err := s.technicianRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, ErrTechnicianNotAssigned
+	}
+	for _, assignment := range ticket.Assignments {
+		if assignment.TechnicianID == technician.ID {
+			return ticket, nil
+		}
+	}
+	return nil, ErrTechnicianNotAssigned
+}
+
+// The sync cursor is the position of the last server change returned, opaque to the app
+func encodeSyncCursor(changedAt time.Time, ticketID string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(changedAt.UTC().Format(time.RFC3339Nano) + "|" + ticketID))
+}
+
+func decodeSyncCursor(cursor string) (time.Time, string, error) {
+	if cursor == "" {
+		return time.Time{}, "", nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidSyncCursor
+	}
+	value, ticketID, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return time.Time{}, "", ErrInvalidSyncCursor
+	}
+	changedAt, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidSyncCursor
+	}
+	return changedAt, ticketID, nil
+}
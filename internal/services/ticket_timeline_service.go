@@ -0,0 +1,72 @@
+package services
+
+import (
+	"strings"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+)
+
+const maxTimelineEventBatch = 100
+
+type TicketTimelineService interface {
+	AddComment(ticketID, userID string, req *models.CreateTicketCommentRequest) (*models.TicketComment, error)
+	GetComments(ticketID string) ([]models.TicketComment, error)
+	// GetEvents returns timeline events after the given cursor (event ID), oldest first
+	GetEvents(ticketID string, afterID uint, limit int) ([]models.TicketEventDTO, error)
+	LatestEventID(ticketID string) (uint, error)
+}
+
+type ticketTimelineService struct {
+	repo          repositories.TicketTimelineRepository
+	ticketService TicketService
+}
+
+func NewTicketTimelineService(repo repositories.TicketTimelineRepository, ticketService TicketService) TicketTimelineService {
+	return &ticketTimelineService{
+		repo:          repo,
+		ticketService: ticketService,
+	}
+}
+
+func (s *ticketTimelineService) AddComment(ticketID, userID string, req *models.CreateTicketCommentRequest) (*models.TicketComment, error) {
+	if _, err := s.ticketService.GetByID(ticketID); err != nil {
+		return nil, err
+	}
+
+	comment := &models.TicketComment{
+		TicketID: ticketID,
+		UserID:   userID,
+		Body:     strings.TrimSpace(req.Body),
+	}
+	if err := s.repo.CreateComment(comment); err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+func (s *ticketTimelineService) GetComments(ticketID string) ([]models.TicketComment, error) {
+	if _, err := s.ticketService.GetByID(ticketID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListComments(ticketID)
+}
+
+func (s *ticketTimelineService) GetEvents(ticketID string, afterID uint, limit int) ([]models.TicketEventDTO, error) {
+	if limit <= 0 || limit > maxTimelineEventBatch {
+		limit = maxTimelineEventBatch
+	}
+	events, err := s.repo.FindEventsAfter(ticketID, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	dtos := make([]models.TicketEventDTO, len(events))
+	for i := range events {
+		dtos[i] = events[i].ToDTO()
+	}
+	return dtos, nil
+}
+
+func (s *ticketTimelineService) LatestEventID(ticketID string) (uint, error) {
+	return s.repo.LatestEventID(ticketID)
+}
@@ -0,0 +1,411 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"gorm.io/gorm"
+)
+
+const (
+	maxTrackingEvents = 500
+	// Locations older than this don't give an ETA: the technician may have stopped sharing
+	trackingLocationMaxAge = 30 * time.Minute
+)
+
+var (
+	ErrTrackingLinkInvalid     = errors.New("tracking link is invalid or expired")
+	ErrTrackingCommentsClosed  = errors.New("comments are closed for this ticket")
+	ErrTrackingTicketCancelled = errors.New("tracking links can't be created for cancelled tickets")
+)
+
+// trackingTimelineEvents are the events the client may see
+var trackingTimelineEvents = []string{
+	models.TicketEventStatusChanged,
+	models.TicketEventCheckin,
+	models.TicketEventCheckout,
+	models.TicketEventClientComment,
+}
+
+// TicketTrackingService backs the client tracking page: signed links to a ticket, a
+// client-safe view of its timeline and comments from the client
+type TicketTrackingService interface {
+	CreateLink(ticketID, userID string, req *models.CreateTrackingLinkRequest) (*models.TrackingLinkResponse, error)
+	GetTracking(token string) (*models.TicketTracking, error)
+	// AttachmentPath returns the metadata-stripped copy of a ticket attachment
+	AttachmentPath(token, fileID string) (string, error)
+	AddComment(token string, req *models.TrackingCommentRequest) (*models.TrackingEvent, error)
+	// WithContext returns the service running its queries with ctx, inside the tenant it carries
+	WithContext(ctx context.Context) TicketTrackingService
+}
+
+// TrackingConfig holds the link settings; Secret signs the tokens
+type TrackingConfig struct {
+	Secret    []byte
+	LinkTTL   time.Duration
+	PortalURL string // {token} is replaced
+}
+
+// trackingGrant is the signed content of a tracking token
+type trackingGrant struct {
+	TicketID  string `json:"t"`
+	ExpiresAt int64  `json:"e"`
+}
+
+type ticketTrackingService struct {
+	ticketRepo         repositories.TicketRepository
+	timelineRepo       repositories.TicketTimelineRepository
+	geoRepo            *repositories.GeoRepository
+	attachments        AttachmentService
+	activityLogService ActivityLogService
+	config             TrackingConfig
+}
+
+func NewTicketTrackingService(
+	ticketRepo repositories.TicketRepository,
+	timelineRepo repositories.TicketTimelineRepository,
+	geoRepo *repositories.GeoRepository,
+	attachments AttachmentService,
+	activityLogService ActivityLogService,
+	config TrackingConfig,
+) TicketTrackingService {
+	if config.LinkTTL <= 0 {
+		config.LinkTTL = 30 * 24 * time.Hour
+	}
+	return &ticketTrackingService{
+		ticketRepo:         ticketRepo,
+		timelineRepo:       timelineRepo,
+		geoRepo:            geoRepo,
+		attachments:        attachments,
+		activityLogService: activityLogService,
+		config:             config,
+	}
+}
+
+func (s *ticketTrackingService) WithContext(ctx context.Context) TicketTrackingService {
+	scoped := *s
+	scoped.ticketRepo = s.ticketRepo.WithContext(ctx)
+	scoped.geoRepo = s.geoRepo.WithContext(ctx)
+	scoped.attachments = s.attachments.WithContext(ctx)
+	return &scoped
+}
+
+func (s *ticketTrackingService) CreateLink(ticketID, userID string, req *models.CreateTrackingLinkRequest) (*models.TrackingLinkResponse, error) {
+	ticket, err := s.ticketRepo.FindByID(ticketID)
+	if err != nil {
+		return nil, err
+	}
+	if ticket.Status == models.TicketStatusCancelled {
+		return nil, ErrTrackingTicketCancelled
+	}
+
+	ttl := s.config.LinkTTL
+	if req != nil && req.ExpiresInHours > 0 {
+		ttl = time.Duration(req.ExpiresInHours) * time.Hour
+	}
+	expiresAt := time.Now().Add(ttl).Truncate(time.Second)
+
+	token, err := s.sign(trackingGrant{TicketID: ticket.ID, ExpiresAt: expiresAt.Unix()})
+	if err != nil {
+		return nil, err
+	}
+
+	if s.activityLogService != nil {
+		description := fmt.Sprintf("Tracking link created for ticket %s, valid until %s", ticket.OSNumber, expiresAt.Format(time.RFC3339))
+		if err := s.activityLogService.LogAction(userID, "CREATE_TRACKING_LINK", "ticket", ticket.ID, description, "", ""); err != nil {
+			slog.Warn("Failed to audit tracking link", "ticket_id", ticket.ID, "error", err)
+		}
+	}
+
+	return &models.TrackingLinkResponse{
+		Token:     token,
+		URL:       s.linkURL(token),
+		TicketID:  ticket.ID,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+func (s *ticketTrackingService) GetTracking(token string) (*models.TicketTracking, error) {
+	ticket, err := s.ticketFromToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := s.timelineRepo.FindEventsByType(ticket.ID, trackingTimelineEvents, maxTrackingEvents)
+	if err != nil {
+		return nil, err
+	}
+	timeline := []models.TrackingEvent{{
+		Type:  "ticket.created",
+		Label: "Chamado aberto",
+		At:    ticket.CreatedAt,
+	}}
+	for i := range events {
+		if event, ok := trackingEvent(&events[i]); ok {
+			timeline = append(timeline, event)
+		}
+	}
+
+	return &models.TicketTracking{
+		TicketID:       ticket.ID,
+		OSNumber:       ticket.OSNumber,
+		Status:         ticket.Status,
+		StatusLabel:    trackingStatusLabel(ticket.Status),
+		CreatedAt:      ticket.CreatedAt,
+		ScheduledStart: ticket.ScheduledStart,
+		ScheduledEnd:   ticket.ScheduledEnd,
+		ClosedAt:       ticket.ClosedAt,
+		Technician:     s.trackingTechnician(ticket),
+		Timeline:       timeline,
+		Attachments:    s.trackingFiles(ticket, token),
+		CanComment:     !isFinalTrackingStatus(ticket.Status),
+	}, nil
+}
+
+func (s *ticketTrackingService) AttachmentPath(token, fileID string) (string, error) {
+	ticket, err := s.ticketFromToken(token)
+	if err != nil {
+		return "", err
+	}
+	if s.attachments == nil {
+		return "", ErrAttachmentNotFound
+	}
+	file, err := s.attachments.GetFile(ticket.ID, fileID)
+	if err != nil {
+		return "", err
+	}
+	return s.attachments.ResolvePath(file, "sanitized")
+}
+
+// trackingFiles lists the attachments the client can download: only processed ones,
+// whose metadata-stripped copy exists
+func (s *ticketTrackingService) trackingFiles(ticket *models.Ticket, token string) []models.TrackingFile {
+	files := []models.TrackingFile{}
+	if s.attachments == nil {
+		return files
+	}
+	attachments, err := s.attachments.GetByTicket(ticket.ID)
+	if err != nil {
+		slog.Warn("Failed to load attachments for tracking", "ticket_id", ticket.ID, "error", err)
+		return files
+	}
+	for i := range attachments {
+		file := &attachments[i]
+		if _, err := s.attachments.ResolvePath(file, "sanitized"); err != nil {
+			continue
+		}
+		files = append(files, models.TrackingFile{
+			ID:       file.ID,
+			FileName: file.FileName,
+			FileType: file.FileType,
+			URL:      "/api/v1/public/tracking/" + token + "/files/" + file.ID,
+		})
+	}
+	return files
+}
+
+func (s *ticketTrackingService) AddComment(token string, req *models.TrackingCommentRequest) (*models.TrackingEvent, error) {
+	ticket, err := s.ticketFromToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if isFinalTrackingStatus(ticket.Status) {
+		return nil, ErrTrackingCommentsClosed
+	}
+
+	comment := &models.TicketComment{
+		TicketID:   ticket.ID,
+		Source:     models.CommentSourceClient,
+		AuthorName: strings.TrimSpace(req.Name),
+		Body:       strings.TrimSpace(req.Body),
+	}
+	if comment.AuthorName == "" && ticket.Client != nil {
+		comment.AuthorName = ticket.Client.FullName
+	}
+	if err := s.timelineRepo.CreateComment(comment); err != nil {
+		return nil, err
+	}
+
+	return &models.TrackingEvent{
+		Type:       models.TicketEventClientComment,
+		Label:      "Comentário do cliente",
+		AuthorName: comment.AuthorName,
+		Body:       comment.Body,
+		At:         comment.CreatedAt,
+	}, nil
+}
+
+// ticketFromToken checks the token and loads its ticket; tickets deleted or archived
+// since the link was created are reported as an invalid link
+func (s *ticketTrackingService) ticketFromToken(token string) (*models.Ticket, error) {
+	grant, err := s.verify(token)
+	if err != nil {
+		return nil, err
+	}
+	ticket, err := s.ticketRepo.FindByID(grant.TicketID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrTrackingLinkInvalid
+	}
+	return ticket, err
+}
+
+// trackingTechnician describes the lead technician, with an ETA while on the way
+func (s *ticketTrackingService) trackingTechnician(ticket *models.Ticket) *models.TrackingTechnician {
+	lead := leadAssignment(ticket.Assignments)
+	if lead == nil || lead.Technician == nil {
+		return nil
+	}
+
+	tech := &models.TrackingTechnician{
+		Name:        firstName(lead.Technician.FullName),
+		Stage:       models.TrackingTechnicianAssigned,
+		CheckedInAt: lead.CheckedInAt,
+	}
+	switch {
+	case lead.CheckedOutAt != nil || isFinalTrackingStatus(ticket.Status):
+		tech.Stage = models.TrackingTechnicianDone
+		return tech
+	case lead.CheckedInAt != nil:
+		tech.Stage = models.TrackingTechnicianOnSite
+		return tech
+	}
+
+	client := ticket.Client
+	if s.geoRepo == nil || client == nil || client.Latitude == nil || client.Longitude == nil {
+		return tech
+	}
+	location, err := s.geoRepo.GetLastLocation(lead.TechnicianID)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.Warn("Failed to load technician location for tracking", "ticket_id", ticket.ID, "error", err)
+		}
+		return tech
+	}
+	if time.Since(location.ServerTime) > trackingLocationMaxAge {
+		return tech
+	}
+
+	distanceKm := CalculateDistance(location.Latitude, location.Longitude, *client.Latitude, *client.Longitude) / 1000
+	eta := int(math.Ceil(distanceKm / models.DefaultSchedulingSettings().AverageSpeedKmh * 60))
+	rounded := math.Round(distanceKm*10) / 10
+	updatedAt := location.ServerTime
+	tech.Stage = models.TrackingTechnicianOnTheWay
+	tech.ETAMinutes = &eta
+	tech.DistanceKm = &rounded
+	tech.LocationUpdatedAt = &updatedAt
+	return tech
+}
+
+func (s *ticketTrackingService) linkURL(token string) string {
+	if s.config.PortalURL == "" {
+		return "/api/v1/public/tracking/" + token
+	}
+	return strings.ReplaceAll(s.config.PortalURL, "{token}", token)
+}
+
+func (s *ticketTrackingService) sign(grant trackingGrant) (string, error) {
+	payload, err := json.Marshal(grant)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + s.signature(encoded), nil
+}
+
+func (s *ticketTrackingService) verify(token string) (*trackingGrant, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(s.signature(encoded))) {
+		return nil, ErrTrackingLinkInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrTrackingLinkInvalid
+	}
+	var grant trackingGrant
+	if err := json.Unmarshal(payload, &grant); err != nil || grant.TicketID == "" {
+		return nil, ErrTrackingLinkInvalid
+	}
+	if time.Now().Unix() > grant.ExpiresAt {
+		return nil, ErrTrackingLinkInvalid
+	}
+	return &grant, nil
+}
+
+func (s *ticketTrackingService) signature(encoded string) string {
+	mac := hmac.New(sha256.New, s.config.Secret)
+	mac.Write([]byte("ticket-tracking:" + encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// trackingEvent turns a timeline event into its client-facing entry
+func trackingEvent(event *models.TicketEvent) (models.TrackingEvent, bool) {
+	var payload map[string]interface{}
+	_ = json.Unmarshal([]byte(event.Payload), &payload)
+	text := func(key string) string {
+		value, _ := payload[key].(string)
+		return value
+	}
+
+	entry := models.TrackingEvent{Type: event.Type, At: event.CreatedAt}
+	switch event.Type {
+	case models.TicketEventStatusChanged:
+		to := models.TicketStatus(text("to"))
+		if to == "" || to == models.TicketStatus(text("from")) {
+			return entry, false
+		}
+		entry.Label = "Status alterado para " + trackingStatusLabel(to)
+	case models.TicketEventCheckin:
+		entry.Label = "Técnico chegou ao local"
+	case models.TicketEventCheckout:
+		entry.Label = "Técnico concluiu a visita"
+	case models.TicketEventClientComment:
+		entry.Label = "Comentário do cliente"
+		entry.AuthorName = text("authorName")
+		entry.Body = text("body")
+	default:
+		return entry, false
+	}
+	return entry, true
+}
+
+func trackingStatusLabel(status models.TicketStatus) string {
+	if label, ok := printStatusLabels[status]; ok {
+		return label
+	}
+	return string(status)
+}
+
+func isFinalTrackingStatus(status models.TicketStatus) bool {
+	return status == models.TicketStatusClosed ||
+		status == models.TicketStatusCancelled ||
+		status == models.TicketStatusUnproductive
+}
+
+// leadAssignment returns the lead of the crew, or its first member when none leads
+func leadAssignment(assignments []models.TicketTechnician) *models.TicketTechnician {
+	var lead *models.TicketTechnician
+	for i := range assignments {
+		if lead == nil || assignments[i].Role == models.AssignmentRoleLead && lead.Role != models.AssignmentRoleLead {
+			lead = &assignments[i]
+		}
+	}
+	return lead
+}
+
+func firstName(fullName string) string {
+	if fields := strings.Fields(fullName); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
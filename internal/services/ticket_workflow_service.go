@@ -0,0 +1,239 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"gorm.io/gorm"
+)
+
+// Workflow sources
+const (
+	WorkflowSourceCategory = "CATEGORY"
+	WorkflowSourceDefault  = "DEFAULT"
+	WorkflowSourceBuiltIn  = "BUILT_IN"
+)
+
+var (
+	ErrTransitionNotAllowed        = errors.New("status transition not allowed")
+	ErrTransitionFieldsMissing     = errors.New("status transition requirements not met")
+	ErrWorkflowCategoryNotFound    = errors.New("ticket category not found")
+	ErrWorkflowDuplicateTransition = errors.New("transition listed more than once")
+)
+
+// builtInWorkflow applies until admins configure a default workflow: the usual flow of a
+// visit, waiting states in both directions, and reopening with a reason
+var builtInWorkflow = map[models.TicketStatus][]models.TicketStatus{
+	models.TicketStatusOpen: {
+		models.TicketStatusInProgress, models.TicketStatusWaitingClient, models.TicketStatusWaitingParts,
+		models.TicketStatusUnproductive, models.TicketStatusForClosing, models.TicketStatusClosed,
+	},
+	models.TicketStatusInProgress: {
+		models.TicketStatusOpen, models.TicketStatusWaitingClient, models.TicketStatusWaitingParts,
+		models.TicketStatusUnproductive, models.TicketStatusForClosing, models.TicketStatusClosed,
+	},
+	models.TicketStatusWaitingClient: {
+		models.TicketStatusOpen, models.TicketStatusInProgress, models.TicketStatusWaitingParts,
+		models.TicketStatusUnproductive, models.TicketStatusClosed,
+	},
+	models.TicketStatusWaitingParts: {
+		models.TicketStatusOpen, models.TicketStatusInProgress, models.TicketStatusWaitingClient,
+		models.TicketStatusClosed,
+	},
+	models.TicketStatusForClosing:   {models.TicketStatusInProgress, models.TicketStatusClosed},
+	models.TicketStatusUnproductive: {models.TicketStatusOpen, models.TicketStatusClosed},
+	models.TicketStatusClosed:       {models.TicketStatusOpen},
+	models.TicketStatusCancelled:    {models.TicketStatusOpen},
+}
+
+// builtInRequired lists the fields the built-in transitions require by target status;
+// reopening a closed or cancelled ticket also requires notes
+var builtInRequired = map[models.TicketStatus][]string{
+	models.TicketStatusUnproductive: {models.TransitionFieldNotes},
+}
+
+// TicketWorkflowService is the ticket status state machine: the transitions allowed per
+// category and the fields each one requires
+type TicketWorkflowService interface {
+	Get(categoryID *string) (*models.TicketWorkflow, error)
+	Set(req *models.SetWorkflowRequest) (*models.TicketWorkflow, error)
+	// Reset drops the transitions of the category (or the default workflow)
+	Reset(categoryID *string) error
+
+	// Allowed lists the statuses the ticket can move to
+	Allowed(ticket *models.Ticket) ([]models.AllowedTransition, error)
+	// Check validates a status change of the ticket with the notes given
+	Check(ticket *models.Ticket, to models.TicketStatus, notes string) error
+}
+
+type ticketWorkflowService struct {
+	repo         repositories.TicketWorkflowRepository
+	categoryRepo repositories.CategoryRepository
+}
+
+func NewTicketWorkflowService(repo repositories.TicketWorkflowRepository, categoryRepo repositories.CategoryRepository) TicketWorkflowService {
+	return &ticketWorkflowService{repo: repo, categoryRepo: categoryRepo}
+}
+
+// Get resolves the workflow a category follows: its own transitions, the default
+// workflow, or the built-in one
+func (s *ticketWorkflowService) Get(categoryID *string) (*models.TicketWorkflow, error) {
+	if categoryID != nil {
+		transitions, err := s.repo.FindByCategory(categoryID)
+		if err != nil {
+			return nil, err
+		}
+		if len(transitions) > 0 {
+			return &models.TicketWorkflow{CategoryID: categoryID, Source: WorkflowSourceCategory, Transitions: transitions}, nil
+		}
+	}
+
+	transitions, err := s.repo.FindByCategory(nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(transitions) > 0 {
+		return &models.TicketWorkflow{CategoryID: categoryID, Source: WorkflowSourceDefault, Transitions: transitions}, nil
+	}
+	return &models.TicketWorkflow{CategoryID: categoryID, Source: WorkflowSourceBuiltIn, Transitions: builtInTransitions()}, nil
+}
+
+func builtInTransitions() []models.TicketStatusTransition {
+	var transitions []models.TicketStatusTransition
+	for from, targets := range builtInWorkflow {
+		for _, to := range targets {
+			required := builtInRequired[to]
+			if to == models.TicketStatusOpen && (from == models.TicketStatusClosed || from == models.TicketStatusCancelled) {
+				required = []string{models.TransitionFieldNotes}
+			}
+			transitions = append(transitions, models.TicketStatusTransition{FromStatus: from, ToStatus: to, RequiredFields: required})
+		}
+	}
+	sort.Slice(transitions, func(i, j int) bool {
+		if transitions[i].FromStatus != transitions[j].FromStatus {
+			return transitions[i].FromStatus < transitions[j].FromStatus
+		}
+		return transitions[i].ToStatus < transitions[j].ToStatus
+	})
+	return transitions
+}
+
+func (s *ticketWorkflowService) Set(req *models.SetWorkflowRequest) (*models.TicketWorkflow, error) {
+	if err := s.checkCategory(req.CategoryID); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(req.Transitions))
+	transitions := make([]models.TicketStatusTransition, 0, len(req.Transitions))
+	for _, input := range req.Transitions {
+		key := input.FromStatus + ">" + input.ToStatus
+		if seen[key] {
+			return nil, fmt.Errorf("%w: %s to %s", ErrWorkflowDuplicateTransition, input.FromStatus, input.ToStatus)
+		}
+		seen[key] = true
+		transitions = append(transitions, models.TicketStatusTransition{
+			CategoryID:     req.CategoryID,
+			FromStatus:     models.TicketStatus(input.FromStatus),
+			ToStatus:       models.TicketStatus(input.ToStatus),
+			RequiredFields: input.RequiredFields,
+		})
+	}
+	if err := s.repo.Replace(req.CategoryID, transitions); err != nil {
+		return nil, err
+	}
+	return s.Get(req.CategoryID)
+}
+
+func (s *ticketWorkflowService) Reset(categoryID *string) error {
+	if err := s.checkCategory(categoryID); err != nil {
+		return err
+	}
+	return s.repo.DeleteByCategory(categoryID)
+}
+
+func (s *ticketWorkflowService) checkCategory(categoryID *string) error {
+	if categoryID == nil {
+		return nil
+	}
+	category, err := s.categoryRepo.GetByID(*categoryID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrWorkflowCategoryNotFound
+		}
+		return err
+	}
+	if category.Type != models.CategoryTypeTicket {
+		return ErrWorkflowCategoryNotFound
+	}
+	return nil
+}
+
+func (s *ticketWorkflowService) Allowed(ticket *models.Ticket) ([]models.AllowedTransition, error) {
+	workflow, err := s.Get(ticket.CategoryID)
+	if err != nil {
+		return nil, err
+	}
+	allowed := []models.AllowedTransition{}
+	for _, transition := range workflow.Transitions {
+		if transition.FromStatus != ticket.Status {
+			continue
+		}
+		required := []string(transition.RequiredFields)
+		if required == nil {
+			required = []string{}
+		}
+		allowed = append(allowed, models.AllowedTransition{
+			Status:         transition.ToStatus,
+			RequiredFields: required,
+			Missing:        missingTransitionFields(ticket, required, nil),
+		})
+	}
+	return allowed, nil
+}
+
+func (s *ticketWorkflowService) Check(ticket *models.Ticket, to models.TicketStatus, notes string) error {
+	if ticket.Status == to {
+		return nil
+	}
+	workflow, err := s.Get(ticket.CategoryID)
+	if err != nil {
+		return err
+	}
+	for _, transition := range workflow.Transitions {
+		if transition.FromStatus != ticket.Status || transition.ToStatus != to {
+			continue
+		}
+		if missing := missingTransitionFields(ticket, transition.RequiredFields, &notes); len(missing) > 0 {
+			return fmt.Errorf("%w: %s required", ErrTransitionFieldsMissing, strings.Join(missing, ", "))
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: %s to %s", ErrTransitionNotAllowed, ticket.Status, to)
+}
+
+// missingTransitionFields returns the required fields the ticket and the notes lack;
+// notes are not checked when nil
+func missingTransitionFields(ticket *models.Ticket, required []string, notes *string) []string {
+	missing := []string{}
+	for _, field := range required {
+		switch field {
+		case models.TransitionFieldNotes:
+			if notes != nil && strings.TrimSpace(*notes) == "" {
+				missing = append(missing, field)
+			}
+		case models.TransitionFieldTechnician:
+			if len(ticket.Assignments) == 0 && len(ticket.Technicians) == 0 {
+				missing = append(missing, field)
+			}
+		case models.TransitionFieldSignature:
+			if ticket.TechnicianSignature == "" || ticket.ClientSignature == "" {
+				missing = append(missing, field)
+			}
+		}
+	}
+	return missing
+}
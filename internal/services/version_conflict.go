@@ -0,0 +1,102 @@
+package services
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+)
+
+// Fields left out of conflict diffs: they differ between any two versions
+var versionConflictIgnored = map[string]bool{
+	"version":   true,
+	"createdAt": true,
+	"updatedAt": true,
+}
+
+// VersionConflictError is an update based on a stale version of a record. It matches
+// repositories.ErrVersionConflict with errors.Is.
+type VersionConflictError struct {
+	Entity           string
+	SubmittedVersion int
+	CurrentVersion   int
+	Current          interface{}
+	Conflicts        []models.FieldConflict
+}
+
+func (e *VersionConflictError) Error() string {
+	return e.Entity + " was modified by another user, please review the changes and try again"
+}
+
+func (e *VersionConflictError) Unwrap() error {
+	return repositories.ErrVersionConflict
+}
+
+// Response is the 409 body of the conflict
+func (e *VersionConflictError) Response() models.VersionConflictResponse {
+	return models.VersionConflictResponse{
+		Error:            e.Error(),
+		Entity:           e.Entity,
+		SubmittedVersion: e.SubmittedVersion,
+		CurrentVersion:   e.CurrentVersion,
+		Current:          e.Current,
+		Conflicts:        e.Conflicts,
+	}
+}
+
+// NewVersionConflict describes the update of entity to submitted, based on
+// submittedVersion, while the stored record is current at currentVersion
+func NewVersionConflict(entity string, submittedVersion int, submitted, current interface{}, currentVersion int) *VersionConflictError {
+	return &VersionConflictError{
+		Entity:           entity,
+		SubmittedVersion: submittedVersion,
+		CurrentVersion:   currentVersion,
+		Current:          current,
+		Conflicts:        diffFields(submitted, current),
+	}
+}
+
+// diffFields compares the JSON fields of two records of the same type
+func diffFields(submitted, current interface{}) []models.FieldConflict {
+	a, errA := jsonFields(submitted)
+	b, errB := jsonFields(current)
+	conflicts := []models.FieldConflict{}
+	if errA != nil || errB != nil {
+		return conflicts
+	}
+
+	names := make([]string, 0, len(a))
+	for name := range a {
+		names = append(names, name)
+	}
+	for name := range b {
+		if _, ok := a[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if versionConflictIgnored[name] || reflect.DeepEqual(a[name], b[name]) {
+			continue
+		}
+		conflicts = append(conflicts, models.FieldConflict{
+			Field:     name,
+			Submitted: a[name],
+			Current:   b[name],
+		})
+	}
+	return conflicts
+}
+
+func jsonFields(v interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	err = json.Unmarshal(data, &fields)
+	return fields, err
+}
@@ -0,0 +1,245 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shigake/tech-iq-back/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultWebhookDeliveryInterval = 10 * time.Second
+	webhookDeliveryBatch           = 50
+	// A claimed delivery is retried after the lease if its instance dies while sending
+	webhookDeliveryLease = 5 * time.Minute
+	// Attempts of a delivery before it fails; the wait doubles from webhookRetryBase, so
+	// the last attempt comes about 4 hours after the first
+	webhookMaxAttempts = 10
+	webhookRetryBase   = 30 * time.Second
+	webhookRetryMax    = 2 * time.Hour
+)
+
+func newWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(secret), nil
+}
+
+func (s *webhookService) RotateSecret(id string) (*models.WebhookSubscription, error) {
+	subscription, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+	subscription.Secret = secret
+	if err := s.repo.Update(subscription); err != nil {
+		return nil, err
+	}
+	subscription.NewSecret = secret
+	return subscription, nil
+}
+
+// Publish queues a delivery of the event for every active subscription listening to it.
+// Failures are logged: publishing never fails the operation that raised the event.
+func (s *webhookService) Publish(event string, data interface{}) {
+	subscriptions, err := s.repo.FindActiveForEvent(event)
+	if err != nil {
+		slog.Warn("Failed to load webhook subscriptions", "event", event, "error", err)
+		return
+	}
+	if len(subscriptions) == 0 {
+		return
+	}
+
+	now := time.Now()
+	envelope := models.WebhookEvent{ID: uuid.New().String(), Event: event, OccurredAt: now, Data: data}
+	payload, err := json.Marshal(envelope)
+	if err != nil {
+		slog.Warn("Failed to encode webhook event", "event", event, "error", err)
+		return
+	}
+
+	deliveries := make([]models.WebhookDelivery, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		deliveries = append(deliveries, models.WebhookDelivery{
+			SubscriptionID: subscription.ID,
+			EventID:        envelope.ID,
+			Event:          event,
+			Payload:        string(payload),
+			Status:         models.WebhookDeliveryPending,
+			NextAttemptAt:  &now,
+		})
+	}
+	if err := s.repo.CreateDeliveries(deliveries); err != nil {
+		slog.Warn("Failed to queue webhook event", "event", event, "error", err)
+	}
+}
+
+func (s *webhookService) ListDeliveries(filter models.WebhookDeliveryFilter, page, size int) (*models.PaginatedResponse, error) {
+	deliveries, total, err := s.repo.FindDeliveries(filter, page, size)
+	if err != nil {
+		return nil, err
+	}
+	return models.NewPaginatedResponse(deliveries, page, size, total), nil
+}
+
+func (s *webhookService) GetDelivery(id string) (*models.WebhookDelivery, error) {
+	delivery, err := s.repo.FindDeliveryByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebhookDeliveryNotFound
+		}
+		return nil, err
+	}
+	return delivery, nil
+}
+
+func (s *webhookService) Redeliver(id string) (*models.WebhookDelivery, error) {
+	delivery, err := s.GetDelivery(id)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	delivery.Status = models.WebhookDeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = &now
+	if err := s.repo.UpdateDelivery(delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+func (s *webhookService) ProcessDue() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deliveries, err := s.repo.ClaimDueDeliveries(time.Now(), webhookDeliveryLease, webhookDeliveryBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	subscriptions := make(map[string]*models.WebhookSubscription)
+	for i := range deliveries {
+		delivery := &deliveries[i]
+		subscription, ok := subscriptions[delivery.SubscriptionID]
+		if !ok {
+			subscription, err = s.repo.FindByID(delivery.SubscriptionID)
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return i, err
+			}
+			subscriptions[delivery.SubscriptionID] = subscription
+		}
+		s.attempt(delivery, subscription)
+		if err := s.repo.UpdateDelivery(delivery); err != nil {
+			return i + 1, err
+		}
+	}
+	return len(deliveries), nil
+}
+
+// attempt sends the delivery and schedules its retry when it fails
+func (s *webhookService) attempt(delivery *models.WebhookDelivery, subscription *models.WebhookSubscription) {
+	now := time.Now()
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+
+	if subscription == nil || !subscription.Active {
+		delivery.Status = models.WebhookDeliveryFailed
+		delivery.NextAttemptAt = nil
+		delivery.Error = "subscription deleted or inactive"
+		return
+	}
+
+	status, answer, err := sendWebhook(s.client, webhookPost{
+		URL:         subscription.URL,
+		Token:       subscription.Token,
+		Secret:      subscription.Secret,
+		ContentType: webhookDefaultContentType,
+		Body:        []byte(delivery.Payload),
+		Headers: map[string]string{
+			"X-Webhook-Event":    delivery.Event,
+			"X-Webhook-Delivery": delivery.ID,
+		},
+	})
+	delivery.DurationMs = time.Since(now).Milliseconds()
+	delivery.ResponseStatus = status
+	delivery.ResponseBody = answer
+	if err == nil {
+		delivery.Status = models.WebhookDeliverySucceeded
+		delivery.NextAttemptAt = nil
+		delivery.DeliveredAt = &now
+		delivery.Error = ""
+		return
+	}
+
+	delivery.Error = err.Error()
+	if delivery.Attempts >= webhookMaxAttempts {
+		delivery.Status = models.WebhookDeliveryFailed
+		delivery.NextAttemptAt = nil
+		return
+	}
+	next := now.Add(webhookBackoff(delivery.Attempts))
+	delivery.NextAttemptAt = &next
+}
+
+// webhookBackoff is the wait after the nth failed attempt: 30s, 1m, 2m... up to 2h
+func webhookBackoff(attempts int) time.Duration {
+	delay := webhookRetryBase
+	for i := 1; i < attempts && delay < webhookRetryMax; i++ {
+		delay *= 2
+	}
+	if delay > webhookRetryMax {
+		delay = webhookRetryMax
+	}
+	return delay
+}
+
+func (s *webhookService) Start(interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultWebhookDeliveryInterval
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				// Drain the queue batch by batch before waiting for the next tick
+				for {
+					processed, err := s.ProcessDue()
+					if err != nil {
+						slog.Warn("Webhook deliveries failed", "error", err)
+						break
+					}
+					if processed < webhookDeliveryBatch {
+						break
+					}
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (s *webhookService) Stop() {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+	}
+}
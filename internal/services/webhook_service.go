@@ -0,0 +1,347 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"github.com/shigake/tech-iq-back/internal/repositories"
+	"gorm.io/gorm"
+)
+
+const (
+	webhookDefaultContentType = "application/json"
+	// Rendered bodies above this size are refused, so a bad template can't flood a receiver
+	webhookMaxBody = 256 << 10
+	// Kept of the receiver's answer in the delivery log
+	webhookMaxResponse = 2 << 10
+)
+
+var (
+	ErrWebhookNotFound         = errors.New("webhook subscription not found")
+	ErrWebhookTemplateInvalid  = errors.New("invalid webhook template")
+	ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+)
+
+// webhookTemplateFuncs are available to the templates on top of the text/template builtins
+var webhookTemplateFuncs = template.FuncMap{
+	// json encodes a value, e.g. {"text": {{json .Message}}} keeps quotes and newlines valid
+	"json": func(v interface{}) (string, error) {
+		encoded, err := json.Marshal(v)
+		return string(encoded), err
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"formatTime": func(layout string, t time.Time) string {
+		return t.Format(layout)
+	},
+}
+
+// EventPublisher queues the domain events for the webhook subscriptions listening to them
+type EventPublisher interface {
+	Publish(event string, data interface{})
+	// ForContext returns the publisher queuing the events for the subscriptions of the
+	// tenant ctx carries. Events are published after the request returns, so the
+	// cancellation of ctx is dropped.
+	ForContext(ctx context.Context) EventPublisher
+}
+
+// WebhookService manages the webhook subscriptions notifications and domain events are
+// posted to, their payload templates, test deliveries and the delivery queue
+type WebhookService interface {
+	EventPublisher
+
+	List() ([]models.WebhookSubscription, error)
+	Get(id string) (*models.WebhookSubscription, error)
+	Create(userID string, req *models.WebhookSubscriptionRequest) (*models.WebhookSubscription, error)
+	Update(id string, req *models.WebhookSubscriptionRequest) (*models.WebhookSubscription, error)
+	Delete(id string) error
+
+	// ValidateTemplate renders the template with a sample payload
+	ValidateTemplate(req *models.WebhookTemplateRequest) *models.WebhookTemplatePreview
+	// TestFire posts a sample payload to the subscription, whether it is active or not
+	TestFire(id string) (*models.WebhookTestResult, error)
+	// RotateSecret replaces the signing secret, returned once in the response
+	RotateSecret(id string) (*models.WebhookSubscription, error)
+
+	ListDeliveries(filter models.WebhookDeliveryFilter, page, size int) (*models.PaginatedResponse, error)
+	GetDelivery(id string) (*models.WebhookDelivery, error)
+	// Redeliver queues the delivery again, with a fresh set of attempts
+	Redeliver(id string) (*models.WebhookDelivery, error)
+	// ProcessDue sends the deliveries due and returns how many were attempted
+	ProcessDue() (int, error)
+	Start(interval time.Duration)
+	Stop()
+	// WithContext returns the service running its queries with ctx, inside the tenant it carries
+	WithContext(ctx context.Context) WebhookService
+}
+
+type webhookService struct {
+	repo   repositories.WebhookRepository
+	client *http.Client
+
+	mu   sync.Mutex // one batch at a time per instance
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewWebhookService(repo repositories.WebhookRepository) WebhookService {
+	return &webhookService{
+		repo:   repo,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// WithContext serves the requests; the delivery loop is left to the service it was called on
+func (s *webhookService) WithContext(ctx context.Context) WebhookService {
+	return &webhookService{repo: s.repo.WithContext(ctx), client: s.client}
+}
+
+func (s *webhookService) ForContext(ctx context.Context) EventPublisher {
+	return s.WithContext(context.WithoutCancel(ctx))
+}
+
+func (s *webhookService) List() ([]models.WebhookSubscription, error) {
+	return s.repo.FindAll()
+}
+
+func (s *webhookService) Get(id string) (*models.WebhookSubscription, error) {
+	subscription, err := s.repo.FindByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, err
+	}
+	return subscription, nil
+}
+
+func (s *webhookService) Create(userID string, req *models.WebhookSubscriptionRequest) (*models.WebhookSubscription, error) {
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+	subscription := &models.WebhookSubscription{Active: true, CreatedBy: userID, Secret: secret}
+	if err := applyWebhookRequest(subscription, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(subscription); err != nil {
+		return nil, err
+	}
+	subscription.HasToken = subscription.Token != ""
+	subscription.NewSecret = secret
+	return subscription, nil
+}
+
+func (s *webhookService) Update(id string, req *models.WebhookSubscriptionRequest) (*models.WebhookSubscription, error) {
+	subscription, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyWebhookRequest(subscription, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Update(subscription); err != nil {
+		return nil, err
+	}
+	subscription.HasToken = subscription.Token != ""
+	return subscription, nil
+}
+
+func applyWebhookRequest(subscription *models.WebhookSubscription, req *models.WebhookSubscriptionRequest) error {
+	contentType := strings.TrimSpace(req.ContentType)
+	if contentType == "" {
+		contentType = webhookDefaultContentType
+	}
+	if strings.TrimSpace(req.Template) != "" {
+		if _, err := renderWebhookTemplate(req.Template, contentType, sampleWebhookPayload()); err != nil {
+			return err
+		}
+	}
+
+	subscription.Name = strings.TrimSpace(req.Name)
+	subscription.URL = strings.TrimSpace(req.URL)
+	subscription.Events = req.Events
+	subscription.Template = req.Template
+	subscription.ContentType = contentType
+	if req.Token != nil {
+		subscription.Token = *req.Token
+	}
+	if req.Active != nil {
+		subscription.Active = *req.Active
+	}
+	return nil
+}
+
+func (s *webhookService) Delete(id string) error {
+	if err := s.repo.Delete(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrWebhookNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *webhookService) ValidateTemplate(req *models.WebhookTemplateRequest) *models.WebhookTemplatePreview {
+	contentType := strings.TrimSpace(req.ContentType)
+	if contentType == "" {
+		contentType = webhookDefaultContentType
+	}
+	preview := &models.WebhookTemplatePreview{ContentType: contentType}
+	body, err := renderWebhookTemplate(req.Template, contentType, sampleWebhookPayload())
+	if err != nil {
+		preview.Error = err.Error()
+		return preview
+	}
+	preview.Valid = true
+	preview.Body = string(body)
+	return preview
+}
+
+func (s *webhookService) TestFire(id string) (*models.WebhookTestResult, error) {
+	subscription, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	body, contentType, err := webhookBody(subscription, sampleWebhookPayload())
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.WebhookTestResult{Body: string(body)}
+	start := time.Now()
+	status, _, err := sendWebhook(s.client, webhookPost{
+		URL:         subscription.URL,
+		Token:       subscription.Token,
+		Secret:      subscription.Secret,
+		ContentType: contentType,
+		Body:        body,
+		Headers:     map[string]string{"X-Webhook-Event": models.NotificationTicketAssigned},
+	})
+	result.DurationMs = time.Since(start).Milliseconds()
+	result.StatusCode = status
+	if err != nil {
+		result.Error = err.Error()
+		return result, nil
+	}
+	result.Delivered = true
+	return result, nil
+}
+
+// webhookBody renders the delivery of a subscription: its template, or the default JSON
+func webhookBody(subscription *models.WebhookSubscription, payload models.WebhookPayload) ([]byte, string, error) {
+	if strings.TrimSpace(subscription.Template) == "" {
+		body, err := json.Marshal(payload)
+		return body, webhookDefaultContentType, err
+	}
+	contentType := subscription.ContentType
+	if contentType == "" {
+		contentType = webhookDefaultContentType
+	}
+	body, err := renderWebhookTemplate(subscription.Template, contentType, payload)
+	return body, contentType, err
+}
+
+// renderWebhookTemplate executes the template; JSON content types must render valid JSON
+func renderWebhookTemplate(text, contentType string, payload models.WebhookPayload) ([]byte, error) {
+	tmpl, err := template.New("webhook").Funcs(webhookTemplateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrWebhookTemplateInvalid, err)
+	}
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, payload); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrWebhookTemplateInvalid, err)
+	}
+	if body.Len() > webhookMaxBody {
+		return nil, fmt.Errorf("%w: rendered body exceeds %d bytes", ErrWebhookTemplateInvalid, webhookMaxBody)
+	}
+	if strings.Contains(contentType, "json") && !json.Valid(body.Bytes()) {
+		return nil, fmt.Errorf("%w: rendered body is not valid JSON", ErrWebhookTemplateInvalid)
+	}
+	return body.Bytes(), nil
+}
+
+// webhookPost is a post to a receiver; with a secret it carries the HMAC signature
+type webhookPost struct {
+	URL         string
+	Token       string
+	Secret      string
+	ContentType string
+	Body        []byte
+	Headers     map[string]string
+}
+
+// postWebhook posts the body and returns the status code of the receiver
+func postWebhook(client *http.Client, url, token, contentType string, body []byte) (int, error) {
+	status, _, err := sendWebhook(client, webhookPost{URL: url, Token: token, ContentType: contentType, Body: body})
+	return status, err
+}
+
+// sendWebhook posts and returns the status code and the start of the body of the answer.
+// Signed posts send X-Webhook-Timestamp and X-Webhook-Signature, "sha256=" followed by the
+// hex HMAC-SHA256 of "<timestamp>.<body>" keyed with the secret.
+func sendWebhook(client *http.Client, post webhookPost) (int, string, error) {
+	req, err := http.NewRequest(http.MethodPost, post.URL, bytes.NewReader(post.Body))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", post.ContentType)
+	if post.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+post.Token)
+	}
+	for name, value := range post.Headers {
+		req.Header.Set(name, value)
+	}
+	if post.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set("X-Webhook-Timestamp", timestamp)
+		req.Header.Set("X-Webhook-Signature", "sha256="+signWebhook(post.Secret, timestamp, post.Body))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	answer, _ := io.ReadAll(io.LimitReader(resp.Body, webhookMaxResponse))
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode >= 300 {
+		return resp.StatusCode, string(answer), fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return resp.StatusCode, string(answer), nil
+}
+
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func sampleWebhookPayload() models.WebhookPayload {
+	return models.WebhookPayload{
+		Event:        models.NotificationTicketAssigned,
+		UserID:       "00000000-0000-0000-0000-000000000000",
+		Email:        "tecnico@example.com",
+		Title:        "OS 2026-000123 atribuída a você",
+		Message:      "Você foi atribuído à OS 2026-000123.\nCliente: Cliente Exemplo",
+		ResourceType: "ticket",
+		ResourceID:   "00000000-0000-0000-0000-000000000001",
+		CreatedAt:    time.Now(),
+	}
+}
@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3Config points to an S3-compatible bucket (AWS S3, MinIO, ...)
+type S3Config struct {
+	Endpoint  string // e.g. https://s3.sa-east-1.amazonaws.com or http://minio:9000
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// PathStyle addresses the bucket as endpoint/bucket/key (MinIO) instead of bucket.endpoint/key
+	PathStyle bool
+}
+
+// S3 stores the objects in a bucket. Every request, including the server's own, goes
+// through a query-string presigned URL (AWS Signature Version 4).
+type S3 struct {
+	config S3Config
+	scheme string
+	host   string
+	client *http.Client
+}
+
+func NewS3(config S3Config) (*S3, error) {
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", config.Endpoint)
+	}
+	if config.Bucket == "" {
+		return nil, fmt.Errorf("S3 bucket is required")
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	return &S3{
+		config: config,
+		scheme: endpoint.Scheme,
+		host:   endpoint.Host,
+		client: &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+func (s *S3) Name() string {
+	return BackendS3
+}
+
+func (s *S3) Put(key, contentType string, body io.Reader, size int64) error {
+	req, err := s.request(http.MethodPut, key, body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType)
+	_, err = s.do(req)
+	return err
+}
+
+func (s *S3) Open(key string) (io.ReadCloser, error) {
+	req, err := s.request(http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResponse(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *S3) Size(key string) (int64, error) {
+	req, err := s.request(http.MethodHead, key, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64)
+}
+
+func (s *S3) Delete(key string) error {
+	req, err := s.request(http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	_, err = s.do(req)
+	return err
+}
+
+func (s *S3) PresignUpload(key, contentType string, expires time.Duration) (string, error) {
+	return s.presign(http.MethodPut, key, nil, expires, time.Now())
+}
+
+func (s *S3) PresignDownload(key, fileName string, expires time.Duration) (string, error) {
+	params := url.Values{}
+	params.Set("response-content-disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	return s.presign(http.MethodGet, key, params, expires, time.Now())
+}
+
+func (s *S3) request(method, key string, body io.Reader) (*http.Request, error) {
+	signed, err := s.presign(method, key, nil, 15*time.Minute, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return http.NewRequest(method, signed, body)
+}
+
+func (s *S3) do(req *http.Request) (*http.Response, error) {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	return resp, checkResponse(resp)
+}
+
+func checkResponse(resp *http.Response) error {
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrObjectNotFound
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("S3 request failed: %s", resp.Status)
+	}
+	return nil
+}
+
+// presign builds a SigV4 query-signed URL; only the host header is signed and the
+// payload is left unsigned, so the URL works for any body the caller sends
+func (s *S3) presign(method, key string, params url.Values, expires time.Duration, now time.Time) (string, error) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	scope := day + "/" + s.config.Region + "/s3/aws4_request"
+
+	host, path := s.host, "/"+s.config.Bucket+"/"+key
+	if !s.config.PathStyle {
+		host, path = s.config.Bucket+"."+s.host, "/"+key
+	}
+
+	query := url.Values{}
+	for k, v := range params {
+		query[k] = v
+	}
+	query.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	query.Set("X-Amz-Credential", s.config.AccessKey+"/"+scope)
+	query.Set("X-Amz-Date", amzDate)
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expires.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+
+	canonicalQuery := canonicalQueryString(query)
+	canonicalRequest := strings.Join([]string{
+		method,
+		uriEncode(path, false),
+		canonicalQuery,
+		"host:" + host + "\n",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hexSHA256(canonicalRequest),
+	}, "\n")
+
+	key4 := hmacSHA256([]byte("AWS4"+s.config.SecretKey), day)
+	key4 = hmacSHA256(key4, s.config.Region)
+	key4 = hmacSHA256(key4, "s3")
+	key4 = hmacSHA256(key4, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key4, stringToSign))
+
+	return s.scheme + "://" + host + uriEncode(path, false) + "?" + canonicalQuery + "&X-Amz-Signature=" + signature, nil
+}
+
+func canonicalQueryString(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes everything but the unreserved characters (and "/" in paths)
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hexSHA256(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
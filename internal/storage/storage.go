@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+var (
+	ErrObjectNotFound = errors.New("object not found")
+	ErrInvalidToken   = errors.New("invalid or expired file token")
+)
+
+// Backend names
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+)
+
+// Backend stores file contents by key. Clients move the bytes themselves through
+// presigned URLs; the server only reads objects back to scan them.
+type Backend interface {
+	Name() string
+	Put(key, contentType string, body io.Reader, size int64) error
+	Open(key string) (io.ReadCloser, error)
+	// Size returns the size of the stored object, ErrObjectNotFound when missing
+	Size(key string) (int64, error)
+	Delete(key string) error
+
+	// PresignUpload returns the URL the client PUTs the content to, with that content type
+	PresignUpload(key, contentType string, expires time.Duration) (string, error)
+	// PresignDownload returns a URL serving the object as an attachment named fileName
+	PresignDownload(key, fileName string, expires time.Duration) (string, error)
+}
@@ -0,0 +1,37 @@
+// Package tenant carries the tenant of a request through its context. Database
+// statements run with such a context are kept inside the tenant (see
+// database.RegisterTenantScope) and cache keys can be prefixed with it.
+package tenant
+
+import "context"
+
+// DefaultID is the tenant every row belonged to before multi-tenancy; it owns the
+// platform administration and its cache keys keep no prefix
+const DefaultID = "00000000-0000-0000-0000-000000000001"
+
+type contextKey struct{}
+
+// WithID returns a copy of ctx carrying the tenant
+func WithID(ctx context.Context, tenantID string) context.Context {
+	if tenantID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, tenantID)
+}
+
+// FromContext returns the tenant carried by ctx, if any
+func FromContext(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	tenantID, ok := ctx.Value(contextKey{}).(string)
+	return tenantID, ok && tenantID != ""
+}
+
+// KeyPrefix is the cache key prefix of the tenant, empty for the default one
+func KeyPrefix(tenantID string) string {
+	if tenantID == "" || tenantID == DefaultID {
+		return ""
+	}
+	return "tenant:" + tenantID + ":"
+}
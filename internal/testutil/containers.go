@@ -0,0 +1,187 @@
+//go:build integration
+
+// Package testutil starts disposable Postgres and Redis containers, seeds a
+// deterministic dataset and provides HTTP helpers for integration tests.
+// Build with -tags integration (see `make test-integration`).
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/shigake/tech-iq-back/internal/cache"
+	"github.com/shigake/tech-iq-back/internal/config"
+	"github.com/shigake/tech-iq-back/internal/database"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"gorm.io/gorm"
+)
+
+const (
+	postgresImage = "postgres:16-alpine"
+	redisImage    = "redis:7-alpine"
+
+	testJWTSecret = "integration-test-secret"
+)
+
+// Env is a running Postgres + Redis pair with the schema migrated and fixtures seeded
+type Env struct {
+	Config *config.Config
+	DB     *gorm.DB
+	Redis  *cache.RedisClient
+
+	containers []testcontainers.Container
+}
+
+// NewEnv starts the containers, runs the migrations and seeds the fixtures.
+// Containers are terminated through tb.Cleanup. Call it once per package
+// (TestMain) and Reset between tests to keep runs fast and deterministic.
+func NewEnv(tb testing.TB) *Env {
+	tb.Helper()
+
+	env, err := StartEnv(context.Background())
+	if err != nil {
+		tb.Fatalf("testutil: %v", err)
+	}
+	tb.Cleanup(env.Close)
+	return env
+}
+
+// StartEnv is NewEnv for TestMain, where no testing.TB is available; call Close when done
+func StartEnv(ctx context.Context) (*Env, error) {
+	env := &Env{}
+
+	pg, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        postgresImage,
+			ExposedPorts: []string{"5432/tcp"},
+			Env: map[string]string{
+				"POSTGRES_USER":     "erp",
+				"POSTGRES_PASSWORD": "erp123",
+				"POSTGRES_DB":       "tech_erp_test",
+			},
+			// The server restarts once after init, wait for the second ready message
+			WaitingFor: wait.ForLog("database system is ready to accept connections").
+				WithOccurrence(2).
+				WithStartupTimeout(60 * time.Second),
+		},
+		Started: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("start postgres: %w", err)
+	}
+	env.containers = append(env.containers, pg)
+
+	rd, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        redisImage,
+			ExposedPorts: []string{"6379/tcp"},
+			WaitingFor:   wait.ForLog("Ready to accept connections").WithStartupTimeout(30 * time.Second),
+		},
+		Started: true,
+	})
+	if err != nil {
+		env.Close()
+		return nil, fmt.Errorf("start redis: %w", err)
+	}
+	env.containers = append(env.containers, rd)
+
+	cfg, err := containerConfig(ctx, pg, rd)
+	if err != nil {
+		env.Close()
+		return nil, err
+	}
+	env.Config = cfg
+
+	db, err := database.Connect(cfg)
+	if err != nil {
+		env.Close()
+		return nil, fmt.Errorf("connect postgres: %w", err)
+	}
+	env.DB = db
+
+	if err := database.Migrate(db); err != nil {
+		env.Close()
+		return nil, fmt.Errorf("migrate: %w", err)
+	}
+
+	env.Redis = cache.NewRedisClient(&cache.CacheConfig{
+		Host: cfg.RedisHost,
+		Port: cfg.RedisPort,
+	})
+	if err := env.Redis.Ping(); err != nil {
+		env.Close()
+		return nil, fmt.Errorf("ping redis: %w", err)
+	}
+
+	if err := Seed(db); err != nil {
+		env.Close()
+		return nil, fmt.Errorf("seed: %w", err)
+	}
+	return env, nil
+}
+
+func containerConfig(ctx context.Context, pg, rd testcontainers.Container) (*config.Config, error) {
+	pgHost, err := pg.Host(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pgPort, err := pg.MappedPort(ctx, "5432")
+	if err != nil {
+		return nil, err
+	}
+	rdHost, err := rd.Host(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rdPort, err := rd.MappedPort(ctx, "6379")
+	if err != nil {
+		return nil, err
+	}
+
+	return &config.Config{
+		DBHost:               pgHost,
+		DBPort:               pgPort.Port(),
+		DBUser:               "erp",
+		DBPassword:           "erp123",
+		DBName:               "tech_erp_test",
+		DBSSLMode:            "disable",
+		JWTSecret:            testJWTSecret,
+		JWTExpiration:        time.Hour,
+		JWTRefreshExpiration: 24 * time.Hour,
+		RedisHost:            rdHost,
+		RedisPort:            rdPort.Port(),
+		AppEnv:               "test",
+	}, nil
+}
+
+// Reset truncates every table and re-seeds the fixtures
+func (e *Env) Reset(tb testing.TB) {
+	tb.Helper()
+
+	if err := Truncate(e.DB); err != nil {
+		tb.Fatalf("testutil: truncate: %v", err)
+	}
+	database.SeedDefaultTenant(e.DB)
+	database.SeedAccessControl(e.DB)
+	database.SeedAdminUser(e.DB)
+	database.SeedFinancialCategories(e.DB)
+	if err := Seed(e.DB); err != nil {
+		tb.Fatalf("testutil: seed: %v", err)
+	}
+}
+
+// Close terminates the containers
+func (e *Env) Close() {
+	if e.DB != nil {
+		if sqlDB, err := e.DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	}
+	for i := len(e.containers) - 1; i >= 0; i-- {
+		_ = e.containers[i].Terminate(context.Background())
+	}
+	e.containers = nil
+}
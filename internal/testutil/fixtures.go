@@ -0,0 +1,206 @@
+//go:build integration
+
+package testutil
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shigake/tech-iq-back/internal/models"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Fixed fixture IDs so tests can reference seeded rows directly
+const (
+	FixturePassword = "Test@1234"
+
+	AdminUserID    = "00000000-0000-4000-8000-000000000001"
+	EmployeeUserID = "00000000-0000-4000-8000-000000000002"
+	ClientUserID   = "00000000-0000-4000-8000-000000000003"
+	TechUserID     = "00000000-0000-4000-8000-000000000004"
+
+	TechnicianID = "00000000-0000-4000-8000-000000000101"
+	ClientID     = "00000000-0000-4000-8000-000000000201"
+	CategoryID   = "00000000-0000-4000-8000-000000000301"
+
+	StockScopeID          = "00000000-0000-4000-8000-000000000401"
+	StockItemID           = "00000000-0000-4000-8000-000000000402"
+	StockSerialItemID     = "00000000-0000-4000-8000-000000000403"
+	WarehouseLocationID   = "00000000-0000-4000-8000-000000000411"
+	TechnicianLocationID  = "00000000-0000-4000-8000-000000000412"
+	WarehouseInitialStock = 100
+
+	// Second tenant, for isolation tests; the fixtures above belong to the default one
+	OtherTenantID   = "00000000-0000-4000-8000-000000000501"
+	OtherTenantSlug = "other"
+)
+
+// Hierarchy node IDs are serial; they are known after Seed (identities restart on Reset)
+var (
+	TenantNodeID uint
+	BranchNodeID uint
+	OtherNodeID  uint
+)
+
+// Seed inserts the deterministic fixture dataset. Existing rows are left untouched.
+func Seed(db *gorm.DB) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(FixturePassword), bcrypt.MinCost)
+	if err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		insert := func(value interface{}) error {
+			return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(value).Error
+		}
+
+		if err := insert(&models.Tenant{ID: OtherTenantID, Name: "Other Tenant", Slug: OtherTenantSlug, Active: true}); err != nil {
+			return err
+		}
+
+		users := []models.User{
+			fixtureUser(AdminUserID, "admin", "ADMIN", hash),
+			fixtureUser(EmployeeUserID, "employee", "EMPLOYEE", hash),
+			fixtureUser(ClientUserID, "client", "USER", hash),
+			fixtureUser(TechUserID, "technician", "USER", hash),
+		}
+		if err := insert(&users); err != nil {
+			return err
+		}
+
+		techUserID := TechUserID
+		technician := models.Technician{
+			ID:       TechnicianID,
+			FullName: "Fixture Technician",
+			Status:   "ATIVO",
+			Type:     "PARCERIA",
+			UserID:   &techUserID,
+			City:     "São Paulo",
+			State:    "SP",
+		}
+		if err := insert(&technician); err != nil {
+			return err
+		}
+
+		client := models.Client{ID: ClientID, FullName: "Fixture Client", Email: "client@fixture.test"}
+		if err := insert(&client); err != nil {
+			return err
+		}
+
+		category := models.Category{ID: CategoryID, Name: "Fixture", Type: models.CategoryTypeTicket, Active: true}
+		if err := insert(&category); err != nil {
+			return err
+		}
+
+		if err := seedHierarchy(tx); err != nil {
+			return err
+		}
+
+		return seedStock(insert)
+	})
+}
+
+func fixtureUser(id, name, role string, hash []byte) models.User {
+	return models.User{
+		ID:        id,
+		Email:     name + "@fixture.test",
+		Password:  string(hash),
+		FirstName: strings.ToUpper(name[:1]) + name[1:],
+		LastName:  "Fixture",
+		FullName:  strings.ToUpper(name[:1]) + name[1:] + " Fixture",
+		Role:      role,
+		Active:    true,
+	}
+}
+
+// seedHierarchy builds Tenant > Branch plus a second tenant used for move/isolation tests
+func seedHierarchy(tx *gorm.DB) error {
+	var hierarchy models.Hierarchy
+	if err := tx.Where(models.Hierarchy{Name: "Fixture"}).FirstOrCreate(&hierarchy).Error; err != nil {
+		return err
+	}
+
+	tenant, err := fixtureNode(tx, hierarchy.ID, nil, "Tenant")
+	if err != nil {
+		return err
+	}
+	branch, err := fixtureNode(tx, hierarchy.ID, tenant, "Branch")
+	if err != nil {
+		return err
+	}
+	other, err := fixtureNode(tx, hierarchy.ID, nil, "Other Tenant")
+	if err != nil {
+		return err
+	}
+
+	TenantNodeID, BranchNodeID, OtherNodeID = tenant.ID, branch.ID, other.ID
+	return nil
+}
+
+func fixtureNode(tx *gorm.DB, hierarchyID uint, parent *models.Node, name string) (*models.Node, error) {
+	node := models.Node{HierarchyID: hierarchyID, Name: name}
+	query := tx.Where("hierarchy_id = ? AND name = ?", hierarchyID, name)
+	if parent != nil {
+		node.ParentID = &parent.ID
+		node.Depth = parent.Depth + 1
+	}
+	if err := query.FirstOrCreate(&node).Error; err != nil {
+		return nil, err
+	}
+
+	path := fmt.Sprint(node.ID)
+	if parent != nil {
+		path = parent.Path + "." + path
+	}
+	if node.Path != path {
+		node.Path = path
+		if err := tx.Model(&node).Update("path", path).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &node, nil
+}
+
+func seedStock(insert func(value interface{}) error) error {
+	items := []models.StockItem{
+		{ID: StockItemID, SKU: "FIX-001", Name: "Fixture Cable", Unit: "UN", IsActive: true},
+		{ID: StockSerialItemID, SKU: "FIX-SER-001", Name: "Fixture Router", Unit: "UN", TrackSerial: true, IsActive: true},
+	}
+	if err := insert(&items); err != nil {
+		return err
+	}
+
+	locations := []models.StockLocation{
+		{ID: WarehouseLocationID, ScopeID: StockScopeID, Type: models.LocationWarehouse, Name: "Fixture Warehouse", IsActive: true},
+		{ID: TechnicianLocationID, ScopeID: StockScopeID, Type: models.LocationTechnician, Name: "Fixture Van", IsActive: true},
+	}
+	if err := insert(&locations); err != nil {
+		return err
+	}
+
+	balance := models.StockBalance{
+		ID:         "00000000-0000-4000-8000-000000000421",
+		ScopeID:    StockScopeID,
+		ItemID:     StockItemID,
+		LocationID: WarehouseLocationID,
+		Quantity:   WarehouseInitialStock,
+	}
+	return insert(&balance)
+}
+
+// Truncate empties every table and restarts identities so serial IDs repeat across runs
+func Truncate(db *gorm.DB) error {
+	var tables []string
+	if err := db.Raw("SELECT tablename FROM pg_tables WHERE schemaname = 'public'").Scan(&tables).Error; err != nil {
+		return err
+	}
+	if len(tables) == 0 {
+		return nil
+	}
+	for i, table := range tables {
+		tables[i] = `"` + table + `"`
+	}
+	return db.Exec("TRUNCATE " + strings.Join(tables, ", ") + " RESTART IDENTITY CASCADE").Error
+}
@@ -0,0 +1,120 @@
+//go:build integration
+
+package testutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/shigake/tech-iq-back/internal/tenant"
+)
+
+// Token signs a JWT with the same claims as authService for the given fixture user
+func (e *Env) Token(tb testing.TB, userID, email, role string) string {
+	tb.Helper()
+	return e.TenantToken(tb, tenant.DefaultID, userID, email, role)
+}
+
+// TenantToken is Token for a user of another tenant
+func (e *Env) TenantToken(tb testing.TB, tenantID, userID, email, role string) string {
+	tb.Helper()
+
+	claims := jwt.MapClaims{
+		"userId":    userID,
+		"tenantId":  tenantID,
+		"email":     email,
+		"role":      role,
+		"roles":     []string{role},
+		"createdAt": time.Now().UnixMilli(),
+		"iat":       time.Now().Unix(),
+		"exp":       time.Now().Add(e.Config.JWTExpiration).Unix(),
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(e.Config.JWTSecret))
+	if err != nil {
+		tb.Fatalf("testutil: sign token: %v", err)
+	}
+	return token
+}
+
+// AdminToken, EmployeeToken and TechnicianToken sign tokens for the seeded users
+func (e *Env) AdminToken(tb testing.TB) string {
+	return e.Token(tb, AdminUserID, "admin@fixture.test", "ADMIN")
+}
+
+func (e *Env) EmployeeToken(tb testing.TB) string {
+	return e.Token(tb, EmployeeUserID, "employee@fixture.test", "EMPLOYEE")
+}
+
+func (e *Env) TechnicianToken(tb testing.TB) string {
+	return e.Token(tb, TechUserID, "technician@fixture.test", "USER")
+}
+
+// Response is a fully read HTTP response
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// JSON decodes the response body into v, failing the test on error
+func (r *Response) JSON(tb testing.TB, v interface{}) {
+	tb.Helper()
+	if err := json.Unmarshal(r.Body, v); err != nil {
+		tb.Fatalf("testutil: decode %d response %q: %v", r.Status, r.Body, err)
+	}
+}
+
+// Do sends a request through the app without a network listener. body is
+// marshalled as JSON unless it is already an io.Reader; token may be empty.
+func Do(tb testing.TB, app *fiber.App, method, path string, body interface{}, token string) *Response {
+	tb.Helper()
+
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case io.Reader:
+		reader = b
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			tb.Fatalf("testutil: encode body: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	if reader != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		tb.Fatalf("testutil: %s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		tb.Fatalf("testutil: read body: %v", err)
+	}
+	return &Response{Status: resp.StatusCode, Header: resp.Header, Body: data}
+}
+
+// Expect fails the test when the response status differs from want
+func (r *Response) Expect(tb testing.TB, want int) *Response {
+	tb.Helper()
+	if r.Status != want {
+		tb.Fatalf("testutil: expected status %d, got %d: %s", want, r.Status, r.Body)
+	}
+	return r
+}
@@ -0,0 +1,218 @@
+//go:build integration
+
+package testutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// SpecEnv names the swagger 2.0 spec generated by `make openapi` (docs/openapi/swagger.json)
+const SpecEnv = "OPENAPI_SPEC"
+
+// Spec is the part of the generated spec the contract tests check responses against
+type Spec struct {
+	Paths       map[string]map[string]specOperation `json:"paths"`
+	Definitions map[string]*Schema                  `json:"definitions"`
+}
+
+type specOperation struct {
+	Responses map[string]struct {
+		Schema *Schema `json:"schema"`
+	} `json:"responses"`
+}
+
+// Schema is the subset of JSON schema swag emits
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	Items                *Schema            `json:"items"`
+	AllOf                []*Schema          `json:"allOf"`
+	AdditionalProperties json.RawMessage    `json:"additionalProperties"`
+}
+
+// LoadSpec reads the spec named by OPENAPI_SPEC and skips the test when it is not set
+// (`make test-contract` generates the spec and sets it)
+func LoadSpec(tb testing.TB) *Spec {
+	tb.Helper()
+	path := os.Getenv(SpecEnv)
+	if path == "" {
+		tb.Skipf("testutil: %s not set, run make test-contract", SpecEnv)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		tb.Fatalf("testutil: read spec: %v", err)
+	}
+	var spec Spec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		tb.Fatalf("testutil: decode spec: %v", err)
+	}
+	return &spec
+}
+
+// Conforms fails the test unless the spec documents the response status of the route
+// (as written in the spec, e.g. /tickets/{id}) and the body matches its schema.
+// Fields missing from the schema are reported, so handlers can't drift from their
+// annotations; null is accepted anywhere since swag does not mark nullable fields.
+func (r *Response) Conforms(tb testing.TB, spec *Spec, method, route string) *Response {
+	tb.Helper()
+	operation, ok := spec.Paths[route][strings.ToLower(method)]
+	if !ok {
+		tb.Fatalf("testutil: %s %s is not in the spec", method, route)
+	}
+	response, ok := operation.Responses[strconv.Itoa(r.Status)]
+	if !ok {
+		tb.Fatalf("testutil: %s %s: status %d is not in the spec: %s", method, route, r.Status, r.Body)
+	}
+	if response.Schema == nil {
+		return r
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(r.Body))
+	decoder.UseNumber()
+	var body interface{}
+	if err := decoder.Decode(&body); err != nil {
+		tb.Fatalf("testutil: %s %s: decode %q: %v", method, route, r.Body, err)
+	}
+	var errs []string
+	spec.validate(response.Schema, body, "$", &errs)
+	if len(errs) > 0 {
+		tb.Fatalf("testutil: %s %s %d does not match the spec:\n%s", method, route, r.Status, strings.Join(errs, "\n"))
+	}
+	return r
+}
+
+func (s *Spec) validate(schema *Schema, value interface{}, path string, errs *[]string) {
+	schema, err := s.resolve(schema)
+	if err != nil {
+		*errs = append(*errs, fmt.Sprintf("%s: %v", path, err))
+		return
+	}
+	if value == nil {
+		return
+	}
+
+	fail := func(want string) {
+		*errs = append(*errs, fmt.Sprintf("%s: want %s, got %T", path, want, value))
+	}
+	switch {
+	case schema.Type == "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			fail("array")
+			return
+		}
+		for i, item := range items {
+			if schema.Items != nil {
+				s.validate(schema.Items, item, fmt.Sprintf("%s[%d]", path, i), errs)
+			}
+		}
+	case schema.Type == "object" || schema.Properties != nil:
+		// Objects without properties are opaque to swag (decimals, raw JSON)
+		if schema.Properties == nil && schema.AdditionalProperties == nil {
+			return
+		}
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			fail("object")
+			return
+		}
+		s.validateObject(schema, object, path, errs)
+	case schema.Type == "string":
+		if _, ok := value.(string); !ok {
+			fail("string")
+		}
+	case schema.Type == "integer":
+		if n, ok := value.(json.Number); !ok {
+			fail("integer")
+		} else if _, err := n.Int64(); err != nil {
+			fail("integer")
+		}
+	case schema.Type == "number":
+		if _, ok := value.(json.Number); !ok {
+			fail("number")
+		}
+	case schema.Type == "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("boolean")
+		}
+	}
+}
+
+func (s *Spec) validateObject(schema *Schema, object map[string]interface{}, path string, errs *[]string) {
+	for _, name := range schema.Required {
+		if _, ok := object[name]; !ok {
+			*errs = append(*errs, fmt.Sprintf("%s: missing required field %q", path, name))
+		}
+	}
+
+	var additional *Schema
+	anyAdditional := false
+	switch raw := bytes.TrimSpace(schema.AdditionalProperties); {
+	case bytes.Equal(raw, []byte("true")):
+		anyAdditional = true
+	case len(raw) > 0 && raw[0] == '{':
+		additional = &Schema{}
+		if err := json.Unmarshal(raw, additional); err != nil {
+			*errs = append(*errs, fmt.Sprintf("%s: additionalProperties: %v", path, err))
+			return
+		}
+	}
+
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fieldPath := path + "." + name
+		switch property, ok := schema.Properties[name]; {
+		case ok:
+			s.validate(property, object[name], fieldPath, errs)
+		case additional != nil:
+			s.validate(additional, object[name], fieldPath, errs)
+		case !anyAdditional:
+			*errs = append(*errs, fmt.Sprintf("%s: field not in the spec", fieldPath))
+		}
+	}
+}
+
+// resolve follows $ref and merges allOf (swag's rendering of Type{field=Other}) into
+// one schema
+func (s *Spec) resolve(schema *Schema) (*Schema, error) {
+	for schema.Ref != "" {
+		name := strings.TrimPrefix(schema.Ref, "#/definitions/")
+		definition, ok := s.Definitions[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown definition %q", schema.Ref)
+		}
+		schema = definition
+	}
+	if len(schema.AllOf) == 0 {
+		return schema, nil
+	}
+
+	merged := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for _, part := range schema.AllOf {
+		part, err := s.resolve(part)
+		if err != nil {
+			return nil, err
+		}
+		for name, property := range part.Properties {
+			merged.Properties[name] = property
+		}
+		merged.Required = append(merged.Required, part.Required...)
+		if part.AdditionalProperties != nil {
+			merged.AdditionalProperties = part.AdditionalProperties
+		}
+	}
+	return merged, nil
+}
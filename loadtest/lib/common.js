@@ -0,0 +1,34 @@
+import http from 'k6/http';
+import { check, fail } from 'k6';
+
+export const BASE_URL = __ENV.BASE_URL || 'http://localhost:8080/api/v1';
+
+// p95 budgets per route, shared with models.DefaultLatencyBudgets
+export const budgets = JSON.parse(open('../budgets.json'));
+
+// thresholds builds k6 thresholds from the budgets of the given routes.
+// Requests must be tagged with { name: '<METHOD> <route>' }.
+export function thresholds(routes) {
+  const result = { http_req_failed: ['rate<0.01'] };
+  for (const route of routes) {
+    if (!(route in budgets)) {
+      fail(`no latency budget for ${route}`);
+    }
+    result[`http_req_duration{name:${route}}`] = [`p(95)<${budgets[route]}`];
+  }
+  return result;
+}
+
+export function login(email, password) {
+  const res = http.post(`${BASE_URL}/auth/signin`, JSON.stringify({ email, password }), {
+    headers: { 'Content-Type': 'application/json' },
+  });
+  if (!check(res, { 'signin 200': (r) => r.status === 200 })) {
+    fail(`signin failed for ${email}: ${res.status} ${res.body}`);
+  }
+  return res.json('token');
+}
+
+export function authHeaders(token) {
+  return { headers: { Authorization: `Bearer ${token}`, 'Content-Type': 'application/json' } };
+}
@@ -0,0 +1,35 @@
+// Dashboard and live map polling by managers
+import http from 'k6/http';
+import { check, sleep } from 'k6';
+import { BASE_URL, thresholds, login, authHeaders } from '../lib/common.js';
+
+const STATS = 'GET /api/v1/dashboard/stats';
+const MAP = 'GET /api/v1/geo/technicians/last';
+
+export const options = {
+  scenarios: {
+    dashboard: {
+      executor: 'constant-vus',
+      vus: Number(__ENV.VUS || 20),
+      duration: __ENV.DURATION || '2m',
+    },
+  },
+  thresholds: thresholds([STATS, MAP]),
+};
+
+export function setup() {
+  return { token: login(__ENV.ADMIN_EMAIL, __ENV.ADMIN_PASSWORD) };
+}
+
+export default function (data) {
+  const params = authHeaders(data.token);
+
+  const stats = http.get(`${BASE_URL}/dashboard/stats`, { ...params, tags: { name: STATS } });
+  check(stats, { 'stats 200': (r) => r.status === 200 });
+
+  const map = http.get(`${BASE_URL}/geo/technicians/last`, { ...params, tags: { name: MAP } });
+  check(map, { 'map 200': (r) => r.status === 200 });
+
+  // Dashboard refresh interval
+  sleep(5);
+}
@@ -0,0 +1,56 @@
+// Technicians sending HEARTBEAT locations (single and batched, as the mobile app does offline)
+import http from 'k6/http';
+import { check, sleep } from 'k6';
+import { BASE_URL, thresholds, login, authHeaders } from '../lib/common.js';
+
+const SINGLE = 'POST /api/v1/geo/locations';
+const BATCH = 'POST /api/v1/geo/locations/batch';
+
+export const options = {
+  scenarios: {
+    heartbeat: {
+      executor: 'constant-arrival-rate',
+      rate: Number(__ENV.RATE || 50),
+      timeUnit: '1s',
+      duration: __ENV.DURATION || '2m',
+      preAllocatedVUs: 20,
+      maxVUs: 200,
+    },
+  },
+  thresholds: thresholds([SINGLE, BATCH]),
+};
+
+export function setup() {
+  return { token: login(__ENV.TECH_EMAIL, __ENV.TECH_PASSWORD) };
+}
+
+function point(i) {
+  // Random walk around São Paulo
+  return {
+    eventType: 'HEARTBEAT',
+    latitude: -23.55 + Math.random() * 0.1,
+    longitude: -46.63 + Math.random() * 0.1,
+    accuracyM: 5 + Math.random() * 20,
+    deviceTime: new Date(Date.now() - i * 30000).toISOString(),
+  };
+}
+
+export default function (data) {
+  const params = authHeaders(data.token);
+
+  if (Math.random() < 0.9) {
+    const res = http.post(`${BASE_URL}/geo/locations`, JSON.stringify(point(0)), {
+      ...params,
+      tags: { name: SINGLE },
+    });
+    check(res, { 'heartbeat accepted': (r) => r.status === 200 || r.status === 201 });
+  } else {
+    const locations = Array.from({ length: 20 }, (_, i) => point(i));
+    const res = http.post(`${BASE_URL}/geo/locations/batch`, JSON.stringify({ locations }), {
+      ...params,
+      tags: { name: BATCH },
+    });
+    check(res, { 'batch accepted': (r) => r.status === 200 || r.status === 201 });
+  }
+  sleep(0.1);
+}
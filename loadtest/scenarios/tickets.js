@@ -0,0 +1,48 @@
+// Back-office users paging and filtering the ticket list and opening tickets
+import http from 'k6/http';
+import { check, sleep } from 'k6';
+import { BASE_URL, thresholds, login, authHeaders } from '../lib/common.js';
+
+const LIST = 'GET /api/v1/tickets';
+const DETAIL = 'GET /api/v1/tickets/:id';
+
+const STATUSES = ['', 'ABERTO', 'EM_ATENDIMENTO', 'PARA_FECHAMENTO', 'FECHADO'];
+
+export const options = {
+  scenarios: {
+    tickets: {
+      executor: 'ramping-vus',
+      startVUs: 0,
+      stages: [
+        { duration: '30s', target: Number(__ENV.VUS || 30) },
+        { duration: __ENV.DURATION || '2m', target: Number(__ENV.VUS || 30) },
+        { duration: '15s', target: 0 },
+      ],
+    },
+  },
+  thresholds: thresholds([LIST, DETAIL]),
+};
+
+export function setup() {
+  return { token: login(__ENV.ADMIN_EMAIL, __ENV.ADMIN_PASSWORD) };
+}
+
+export default function (data) {
+  const params = authHeaders(data.token);
+  const status = STATUSES[Math.floor(Math.random() * STATUSES.length)];
+  const page = Math.floor(Math.random() * 5);
+
+  const list = http.get(`${BASE_URL}/tickets?page=${page}&size=20&status=${status}`, {
+    ...params,
+    tags: { name: LIST },
+  });
+  check(list, { 'list 200': (r) => r.status === 200 });
+
+  const content = list.status === 200 ? list.json('content') || [] : [];
+  if (content.length > 0) {
+    const ticket = content[Math.floor(Math.random() * content.length)];
+    const detail = http.get(`${BASE_URL}/tickets/${ticket.id}`, { ...params, tags: { name: DETAIL } });
+    check(detail, { 'detail 200': (r) => r.status === 200 });
+  }
+  sleep(1);
+}